@@ -2,6 +2,9 @@ package analytics
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"sync"
 	"time"
 
@@ -173,3 +176,25 @@ func addSessionID(sessionID string, events []EventPayload) (Events, error) {
 	}
 	return eventMaps, nil
 }
+
+type debugSink struct {
+	w    io.Writer
+	sink Sink
+}
+
+// NewDebugSink returns a Sink that writes each batch of events to w as JSON,
+// exactly as they are passed to sink, before passing them on
+func NewDebugSink(w io.Writer, sink Sink) Sink {
+	return &debugSink{w: w, sink: sink}
+}
+
+func (d *debugSink) RecordAnalyticsEvents(events Events) error {
+	encoded, err := json.MarshalIndent(events, "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(d.w, "%s\n", encoded); err != nil {
+		return err
+	}
+	return d.sink.RecordAnalyticsEvents(events)
+}
@@ -1,7 +1,9 @@
 package analytics
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"sync"
 	"testing"
 	"time"
@@ -190,3 +192,23 @@ func Test_addSessionId(t *testing.T) {
 		}
 	}
 }
+
+func Test_debugSink(t *testing.T) {
+	buf := &bytes.Buffer{}
+	inner := newDummySink()
+	sink := NewDebugSink(buf, inner)
+	events := Events{{"hash": "abc", "event": "HIT", "sessionId": "my-uuid"}}
+	if err := sink.RecordAnalyticsEvents(events); err != nil {
+		t.Fatalf("RecordAnalyticsEvents: %v", err)
+	}
+	if len(inner.Events()) != 1 {
+		t.Errorf("events should be passed on to the wrapped sink")
+	}
+	printed := Events{}
+	if err := json.Unmarshal(buf.Bytes(), &printed); err != nil {
+		t.Fatalf("printed events are not JSON: %v", err)
+	}
+	if printed[0]["hash"] != "abc" || printed[0]["sessionId"] != "my-uuid" {
+		t.Errorf("printed events got %v, want %v", printed, events)
+	}
+}
@@ -0,0 +1,176 @@
+// Package audit implements `turbo audit`, which looks for package scripts and
+// turbo.json configuration that keep turbo from caching work effectively, and
+// reports them as a prioritized list of fixes.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/context"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+	"github.com/vercel/turbo/cli/internal/ui"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// Priority orders findings by how much fixing them is likely to matter
+type Priority string
+
+const (
+	// PriorityHigh findings likely cause incorrect cache hits or wasted work on every run
+	PriorityHigh Priority = "high"
+	// PriorityMedium findings likely cause missed cache hits
+	PriorityMedium Priority = "medium"
+	// PriorityLow findings are worth a look, but may be intentional
+	PriorityLow Priority = "low"
+)
+
+func (p Priority) rank() int {
+	switch p {
+	case PriorityHigh:
+		return 0
+	case PriorityMedium:
+		return 1
+	}
+	return 2
+}
+
+// Finding is a single problem found by a rule
+type Finding struct {
+	// Rule identifies the rule that produced this finding
+	Rule     string   `json:"rule"`
+	Priority Priority `json:"priority"`
+	// Target is the task or script the finding is about
+	Target  string `json:"target"`
+	Message string `json:"message"`
+	Fix     string `json:"fix"`
+}
+
+// auditor holds the repository state that rules inspect
+type auditor struct {
+	repoRoot        turbopath.AbsoluteSystemPath
+	rootPackageJSON *fs.PackageJSON
+	// packages does not include the root package
+	packages  map[string]*fs.PackageJSON
+	turboJSON *fs.TurboJSON
+}
+
+// rule inspects the repository and returns any problems it finds
+type rule func(a *auditor) ([]*Finding, error)
+
+var _rules = []rule{
+	scriptsOutsidePipeline,
+	rootScriptsBypassingTurbo,
+	cacheableTasksWithoutOutputs,
+	uncachedDeterministicTasks,
+	unusedEnvVars,
+}
+
+// ExecuteAudit executes the `audit` command.
+func ExecuteAudit(helper *cmdutil.Helper, args *turbostate.ParsedArgsFromRust) error {
+	base, err := helper.GetCmdBase(args)
+	if err != nil {
+		return err
+	}
+	a, err := newAuditor(base)
+	if err != nil {
+		base.LogError("audit failed: %v", err)
+		return err
+	}
+	findings, err := a.run()
+	if err != nil {
+		base.LogError("audit failed: %v", err)
+		return err
+	}
+
+	if args.Command.Audit.JSON {
+		rendered, err := json.MarshalIndent(findings, "", "  ")
+		if err != nil {
+			return err
+		}
+		base.UI.Output(string(rendered))
+		return nil
+	}
+	printFindings(base, findings)
+	return nil
+}
+
+func newAuditor(base *cmdutil.CmdBase) (*auditor, error) {
+	rootPackageJSON, err := fs.ReadPackageJSON(base.RepoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package.json: %w", err)
+	}
+	pkgDepGraph, err := context.BuildPackageGraph(base.RepoRoot, rootPackageJSON)
+	if err != nil {
+		var warnings *context.Warnings
+		if !errors.As(err, &warnings) {
+			return nil, err
+		}
+		base.LogWarning("Issues occurred when constructing package graph. Turbo will function, but some features may not be available", err)
+	}
+	turboJSON, err := fs.LoadTurboConfig(base.RepoRoot, rootPackageJSON, false)
+	if err != nil {
+		return nil, err
+	}
+	packages := make(map[string]*fs.PackageJSON)
+	for name, pkg := range pkgDepGraph.WorkspaceInfos.PackageJSONs {
+		if name != util.RootPkgName {
+			packages[name] = pkg
+		}
+	}
+	return &auditor{
+		repoRoot:        base.RepoRoot,
+		rootPackageJSON: rootPackageJSON,
+		packages:        packages,
+		turboJSON:       turboJSON,
+	}, nil
+}
+
+// run runs every rule and returns the findings, most important first
+func (a *auditor) run() ([]*Finding, error) {
+	findings := []*Finding{}
+	for _, rule := range _rules {
+		ruleFindings, err := rule(a)
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, ruleFindings...)
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Priority != findings[j].Priority {
+			return findings[i].Priority.rank() < findings[j].Priority.rank()
+		}
+		if findings[i].Rule != findings[j].Rule {
+			return findings[i].Rule < findings[j].Rule
+		}
+		return findings[i].Target < findings[j].Target
+	})
+	return findings, nil
+}
+
+func printFindings(base *cmdutil.CmdBase, findings []*Finding) {
+	if len(findings) == 0 {
+		base.UI.Output(util.Sprintf("${BOLD_GREEN}No problems found${RESET}"))
+		return
+	}
+	for i, finding := range findings {
+		var priority string
+		switch finding.Priority {
+		case PriorityHigh:
+			priority = util.Sprintf("${RED}high${RESET}  ")
+		case PriorityMedium:
+			priority = util.Sprintf("${YELLOW}medium${RESET}")
+		default:
+			priority = util.Sprintf("${GREY}low${RESET}   ")
+		}
+		base.UI.Output(fmt.Sprintf("%3d. %s %s %s %s", i+1, priority, ui.Bold(finding.Target), ui.Dim(fmt.Sprintf("[%s]", finding.Rule)), finding.Message))
+		base.UI.Output(ui.Dim(fmt.Sprintf("            → %s", finding.Fix)))
+	}
+	base.UI.Output("")
+	base.UI.Output(util.Sprintf("${BOLD}%v problems found${RESET}", len(findings)))
+}
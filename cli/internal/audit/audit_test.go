@@ -0,0 +1,180 @@
+package audit
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+)
+
+type workspace struct {
+	dir     string
+	name    string
+	scripts map[string]string
+	files   map[string]string
+}
+
+func setupAuditor(t *testing.T, rootScripts map[string]string, turboJSON string, workspaces []workspace) *auditor {
+	t.Helper()
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	rootPackageJSON := &fs.PackageJSON{Name: "root", Scripts: rootScripts}
+	assert.NilError(t, repoRoot.UntypedJoin("turbo.json").WriteFile([]byte(turboJSON), 0644))
+	config, err := fs.LoadTurboConfig(repoRoot, rootPackageJSON, false)
+	assert.NilError(t, err)
+
+	packages := make(map[string]*fs.PackageJSON)
+	for _, ws := range workspaces {
+		for path, contents := range ws.files {
+			file := repoRoot.UntypedJoin(ws.dir, path)
+			assert.NilError(t, file.EnsureDir())
+			assert.NilError(t, file.WriteFile([]byte(contents), 0644))
+		}
+		packages[ws.name] = &fs.PackageJSON{
+			Name:    ws.name,
+			Scripts: ws.scripts,
+			Dir:     turbopath.AnchoredUnixPath(ws.dir).ToSystemPath(),
+		}
+	}
+	return &auditor{
+		repoRoot:        repoRoot,
+		rootPackageJSON: rootPackageJSON,
+		packages:        packages,
+		turboJSON:       config,
+	}
+}
+
+func targetsFor(findings []*Finding) []string {
+	targets := []string{}
+	for _, finding := range findings {
+		targets = append(targets, finding.Rule+" "+string(finding.Priority)+" "+finding.Target)
+	}
+	return targets
+}
+
+func TestAudit(t *testing.T) {
+	testCases := []struct {
+		name        string
+		rootScripts map[string]string
+		turboJSON   string
+		workspaces  []workspace
+		want        []string
+	}{
+		{
+			name:      "no problems",
+			turboJSON: `{"pipeline": {"build": {"outputs": ["dist/**"]}, "dev": {"cache": false, "persistent": true}}}`,
+			workspaces: []workspace{
+				{dir: "apps/web", name: "web", scripts: map[string]string{"build": "next build", "dev": "next dev", "postinstall": "patch-package"}},
+			},
+			want: []string{},
+		},
+		{
+			name:      "scripts outside the pipeline",
+			turboJSON: `{"pipeline": {"build": {"outputs": ["dist/**"]}}}`,
+			workspaces: []workspace{
+				{dir: "apps/web", name: "web", scripts: map[string]string{"build": "tsc", "typecheck": "tsc --noEmit"}},
+				{dir: "packages/ui", name: "ui", scripts: map[string]string{"typecheck": "tsc --noEmit"}},
+			},
+			want: []string{"TA101 low typecheck"},
+		},
+		{
+			name:        "root scripts that bypass turbo",
+			rootScripts: map[string]string{"build": "npm run build --workspaces", "lint": "pnpm -r lint", "test": "turbo run test"},
+			turboJSON:   `{"pipeline": {}}`,
+			want:        []string{"TA102 medium //#build", "TA102 medium //#lint"},
+		},
+		{
+			name:      "cacheable tasks without outputs",
+			turboJSON: `{"pipeline": {"build": {}, "lint": {}, "web#bundle": {}, "docs": {}}}`,
+			workspaces: []workspace{
+				{dir: "apps/web", name: "web", scripts: map[string]string{"build": "vite build", "lint": "eslint .", "bundle": "esbuild src/index.ts"}},
+			},
+			want: []string{"TA103 high build", "TA103 high web#bundle", "TA103 low lint"},
+		},
+		{
+			name:      "tasks with a command in place of a script",
+			turboJSON: `{"pipeline": {"typecheck": {"command": "tsc --noEmit"}, "web#bundle": {"command": "esbuild src/index.ts"}, "docs#bundle": {"command": "esbuild src/index.ts"}}}`,
+			workspaces: []workspace{
+				{dir: "apps/web", name: "web"},
+			},
+			want: []string{"TA103 high typecheck", "TA103 high web#bundle"},
+		},
+		{
+			name:      "uncached deterministic tasks",
+			turboJSON: `{"pipeline": {"lint": {"cache": false}, "start": {"cache": false}, "test": {"cache": false}, "deploy": {"cache": false}}}`,
+			workspaces: []workspace{
+				{dir: "apps/web", name: "web", scripts: map[string]string{"lint": "eslint .", "start": "next start", "test": "jest --watch", "deploy": "vercel deploy"}},
+				{dir: "packages/ui", name: "ui", scripts: map[string]string{"lint": "prettier --check ."}},
+			},
+			want: []string{"TA104 medium lint"},
+		},
+		{
+			name:      "unused environment variables",
+			turboJSON: `{"globalEnv": ["CI", "UNUSED_GLOBAL"], "pipeline": {"build": {"outputs": ["dist/**"], "env": ["API_URL", "NEXT_PUBLIC_*", "STALE_KEY"]}}}`,
+			workspaces: []workspace{
+				{
+					dir:     "apps/web",
+					name:    "web",
+					scripts: map[string]string{"build": "next build"},
+					files: map[string]string{
+						"src/api.ts":            "fetch(process.env.API_URL)",
+						"scripts/ci.sh":         "if [ -n \"$CI\" ]; then exit 0; fi",
+						"node_modules/x/env.js": "process.env.STALE_KEY",
+					},
+				},
+			},
+			want: []string{"TA105 low build", "TA105 low globalEnv"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			a := setupAuditor(t, tc.rootScripts, tc.turboJSON, tc.workspaces)
+			findings, err := a.run()
+			assert.NilError(t, err)
+			assert.DeepEqual(t, targetsFor(findings), tc.want)
+		})
+	}
+}
+
+func TestFindingsAreSortedByPriority(t *testing.T) {
+	a := setupAuditor(t, map[string]string{"build": "yarn workspaces run build"}, `{"pipeline": {"build": {}}}`, []workspace{
+		{dir: "apps/web", name: "web", scripts: map[string]string{"build": "tsup", "storybook": "storybook dev"}},
+	})
+	findings, err := a.run()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, targetsFor(findings), []string{"TA103 high build", "TA102 medium //#build", "TA101 low storybook"})
+}
+
+func TestFindEnvVars(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	files := map[string]string{
+		".env.example":                  "ROOT_ONLY=1",
+		"apps/web/src/api.ts":           "const url = process.env.API_URL\nconst key = process.env.SHARED",
+		"apps/web/plugins/ui/index.ts":  "process.env.NESTED",
+		"packages/ui/index.ts":          "export const shared = process.env.SHARED",
+		"packages/ui/node_modules/x.js": "process.env.IGNORED",
+		"packages/ui/turbo.json":        `{"pipeline": {"build": {"env": ["CONFIG_ONLY"]}}}`,
+	}
+	for path, contents := range files {
+		file := repoRoot.UntypedJoin(path)
+		assert.NilError(t, file.EnsureDir())
+		assert.NilError(t, file.WriteFile([]byte(contents), 0644))
+	}
+	workspaceDirs := util.SetFromStrings([]string{"", "apps/web", "apps/web/plugins/ui", "packages/ui"})
+
+	found, err := findEnvVars(repoRoot, []string{"ROOT_ONLY", "API_URL", "SHARED", "NESTED", "IGNORED", "CONFIG_ONLY"}, workspaceDirs)
+	assert.NilError(t, err)
+	dirs := func(envVar string) []string {
+		list := found[envVar].UnsafeListOfStrings()
+		sort.Strings(list)
+		return list
+	}
+	assert.DeepEqual(t, dirs("ROOT_ONLY"), []string{""})
+	assert.DeepEqual(t, dirs("API_URL"), []string{"", "apps/web"})
+	assert.DeepEqual(t, dirs("SHARED"), []string{"", "apps/web", "packages/ui"})
+	assert.DeepEqual(t, dirs("NESTED"), []string{"", "apps/web", "apps/web/plugins/ui"})
+	assert.DeepEqual(t, dirs("IGNORED"), []string{})
+	assert.DeepEqual(t, dirs("CONFIG_ONLY"), []string{})
+}
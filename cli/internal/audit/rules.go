@@ -0,0 +1,395 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	turbofs "github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// _lifecycleScripts are run by the package manager rather than by users, so it is
+// expected that they aren't in the pipeline
+var _lifecycleScripts = util.SetFromStrings([]string{
+	"preinstall", "install", "postinstall",
+	"prepare", "prepublish", "prepublishOnly", "prepack", "postpack",
+	"publish", "postpublish",
+	"preversion", "version", "postversion",
+	"dependencies",
+})
+
+var (
+	// _workspaceFanOut matches commands that run a script across workspaces without turbo
+	_workspaceFanOut = regexp.MustCompile(`(--workspaces\b|(^|\s)-ws\b|\bpnpm\s+(-r|--recursive|--filter)\b|\blerna\s+run\b|\byarn\s+workspaces\s+(run|foreach)\b|\bnx\s+run-many\b)`)
+	// _buildTools matches commands that produce files that should be cached
+	_buildTools = regexp.MustCompile(`\b(tsc|tsup|webpack|rollup|esbuild|babel|swc|unbuild|microbundle|(next|vite|nuxt|remix|astro|gatsby|ng|svelte-kit|parcel)\s+build)\b`)
+	// _deterministicTools matches commands whose results only depend on their inputs
+	_deterministicTools = regexp.MustCompile(`\b(tsc|tsup|webpack|rollup|esbuild|babel|swc|eslint|prettier|stylelint|jest|vitest|mocha|ava|(next|vite|nuxt|remix|astro|gatsby|ng|svelte-kit|parcel)\s+build)\b`)
+	// _longRunning matches commands that watch for changes or serve, which shouldn't be cached
+	_longRunning = regexp.MustCompile(`(\b(dev|serve|start|preview)\b|--watch\b|(^|\s)-w\b)`)
+)
+
+// scriptsOutsidePipeline reports workspace scripts that `turbo run` can't run
+// because they aren't in the pipeline
+func scriptsOutsidePipeline(a *auditor) ([]*Finding, error) {
+	missing := make(map[string][]string)
+	for name, pkg := range a.packages {
+		for script := range pkg.Scripts {
+			if _lifecycleScripts.Includes(script) || a.turboJSON.Pipeline.HasTask(script) {
+				continue
+			}
+			missing[script] = append(missing[script], name)
+		}
+	}
+	findings := []*Finding{}
+	for script, packages := range missing {
+		sort.Strings(packages)
+		findings = append(findings, &Finding{
+			Rule:     "TA101",
+			Priority: PriorityLow,
+			Target:   script,
+			Message:  fmt.Sprintf("script is defined in %v but is not in the pipeline, so it always runs outside of turbo", describePackages(packages)),
+			Fix:      fmt.Sprintf("Add \"%v\" to the pipeline in turbo.json so that turbo can schedule and cache it", script),
+		})
+	}
+	return findings, nil
+}
+
+// rootScriptsBypassingTurbo reports root scripts that run workspace scripts with
+// the package manager instead of with turbo
+func rootScriptsBypassingTurbo(a *auditor) ([]*Finding, error) {
+	findings := []*Finding{}
+	for script, command := range a.rootPackageJSON.Scripts {
+		if !_workspaceFanOut.MatchString(command) {
+			continue
+		}
+		findings = append(findings, &Finding{
+			Rule:     "TA102",
+			Priority: PriorityMedium,
+			Target:   util.RootTaskID(script),
+			Message:  fmt.Sprintf("root script runs across workspaces without turbo: %v", command),
+			Fix:      "Replace the command with `turbo run <task>` so that the work is scheduled in parallel and cached",
+		})
+	}
+	return findings, nil
+}
+
+// cacheableTasksWithoutOutputs reports cached tasks that don't declare any outputs.
+// Those tasks only restore their logs on a cache hit, which is a problem when the
+// task builds files.
+func cacheableTasksWithoutOutputs(a *auditor) ([]*Finding, error) {
+	findings := []*Finding{}
+	for taskID, task := range a.turboJSON.Pipeline {
+		definition := task.TaskDefinition
+		if !definition.ShouldCache || definition.Persistent || len(definition.Outputs.Inclusions) > 0 {
+			continue
+		}
+		commands := a.commandsFor(taskID)
+		if len(commands) == 0 {
+			continue
+		}
+		finding := &Finding{
+			Rule:     "TA103",
+			Priority: PriorityLow,
+			Target:   taskID,
+			Message:  "task is cached but declares no outputs, so only its logs are restored on a cache hit",
+			Fix:      "Add the files this task writes to \"outputs\", or leave it empty if the task only checks files",
+		}
+		if anyMatch(_buildTools, commands) {
+			finding.Priority = PriorityHigh
+			finding.Message = "task runs a build tool but declares no outputs, so cache hits will not restore the built files"
+			finding.Fix = "Add the build output directories, e.g. \"dist/**\", to \"outputs\""
+		}
+		findings = append(findings, finding)
+	}
+	return findings, nil
+}
+
+// uncachedDeterministicTasks reports tasks with caching disabled whose scripts all
+// look like they would produce the same results for the same inputs
+func uncachedDeterministicTasks(a *auditor) ([]*Finding, error) {
+	findings := []*Finding{}
+	for taskID, task := range a.turboJSON.Pipeline {
+		definition := task.TaskDefinition
+		if definition.ShouldCache || definition.Persistent {
+			continue
+		}
+		commands := a.commandsFor(taskID)
+		if len(commands) == 0 || anyMatch(_longRunning, commands) {
+			continue
+		}
+		deterministic := true
+		for _, command := range commands {
+			if !_deterministicTools.MatchString(command) {
+				deterministic = false
+				break
+			}
+		}
+		if !deterministic {
+			continue
+		}
+		findings = append(findings, &Finding{
+			Rule:     "TA104",
+			Priority: PriorityMedium,
+			Target:   taskID,
+			Message:  "caching is disabled, but every script for this task looks deterministic",
+			Fix:      "Remove \"cache\": false so that turbo can skip this task when its inputs haven't changed",
+		})
+	}
+	return findings, nil
+}
+
+// unusedEnvVars reports environment variables that are declared as task or global
+// dependencies but never appear in the files of the workspaces that would read them.
+// Unused declarations cause needless cache misses when their values change.
+func unusedEnvVars(a *auditor) ([]*Finding, error) {
+	envVars := util.Set{}
+	declared := append([]string{}, a.turboJSON.GlobalEnv...)
+	for _, task := range a.turboJSON.Pipeline {
+		declared = append(declared, task.TaskDefinition.EnvVarDependencies...)
+	}
+	for _, envVar := range declared {
+		if !isWildcard(envVar) {
+			envVars.Add(envVar)
+		}
+	}
+	workspaceDirs := util.SetFromStrings([]string{workspaceDir(a.rootPackageJSON)})
+	for _, pkg := range a.packages {
+		workspaceDirs.Add(workspaceDir(pkg))
+	}
+	found, err := findEnvVars(a.repoRoot, envVars.UnsafeListOfStrings(), workspaceDirs)
+	if err != nil {
+		return nil, err
+	}
+	// appearsIn returns whether envVar is mentioned in any file in the given
+	// workspaces. Wildcard declarations can't be checked this way and are
+	// assumed to be used.
+	appearsIn := func(envVar string, dirs []string) bool {
+		if isWildcard(envVar) {
+			return true
+		}
+		for _, dir := range dirs {
+			if found[envVar].Includes(dir) {
+				return true
+			}
+		}
+		return false
+	}
+
+	findings := []*Finding{}
+	for _, envVar := range a.turboJSON.GlobalEnv {
+		if !appearsIn(envVar, []string{""}) {
+			findings = append(findings, unusedEnvVarFinding("globalEnv", envVar))
+		}
+	}
+	for taskID, task := range a.turboJSON.Pipeline {
+		var dirs []string
+		for _, pkg := range a.packagesFor(taskID) {
+			dirs = append(dirs, workspaceDir(pkg))
+		}
+		if len(dirs) == 0 {
+			continue
+		}
+		for _, envVar := range task.TaskDefinition.EnvVarDependencies {
+			if !appearsIn(envVar, dirs) {
+				findings = append(findings, unusedEnvVarFinding(taskID, envVar))
+			}
+		}
+	}
+	return findings, nil
+}
+
+func unusedEnvVarFinding(target string, envVar string) *Finding {
+	return &Finding{
+		Rule:     "TA105",
+		Priority: PriorityLow,
+		Target:   target,
+		Message:  fmt.Sprintf("%v is declared as a dependency but is never referenced", envVar),
+		Fix:      fmt.Sprintf("Remove %v from the configuration, since changes to it cause cache misses", envVar),
+	}
+}
+
+// packagesFor returns the workspaces that the given pipeline entry runs in:
+// those that have a script for it, or every workspace if it has a "command"
+func (a *auditor) packagesFor(taskID string) []*turbofs.PackageJSON {
+	definition := a.taskDefinition(taskID)
+	var packages []*turbofs.PackageJSON
+	if util.IsPackageTask(taskID) {
+		packageName, task := util.GetPackageTaskFromId(taskID)
+		pkg := a.rootPackageJSON
+		if packageName != util.RootPkgName {
+			pkg = a.packages[packageName]
+		}
+		if pkg != nil && definition.HasCommand(pkg, task) {
+			packages = append(packages, pkg)
+		}
+		return packages
+	}
+	for _, pkg := range a.packages {
+		if definition.HasCommand(pkg, taskID) {
+			packages = append(packages, pkg)
+		}
+	}
+	return packages
+}
+
+// commandsFor returns the commands that run for the given pipeline entry
+func (a *auditor) commandsFor(taskID string) []string {
+	definition := a.taskDefinition(taskID)
+	task := util.StripPackageName(taskID)
+	var commands []string
+	for _, pkg := range a.packagesFor(taskID) {
+		if definition.Command != "" {
+			commands = append(commands, definition.Command)
+		} else {
+			commands = append(commands, pkg.Scripts[task])
+		}
+	}
+	return commands
+}
+
+// taskDefinition returns the definition of the given pipeline entry, which is
+// empty if the pipeline has no such entry
+func (a *auditor) taskDefinition(taskID string) *turbofs.TaskDefinition {
+	if task, ok := a.turboJSON.Pipeline[taskID]; ok {
+		return &task.TaskDefinition
+	}
+	return &turbofs.TaskDefinition{}
+}
+
+func anyMatch(re *regexp.Regexp, commands []string) bool {
+	for _, command := range commands {
+		if re.MatchString(command) {
+			return true
+		}
+	}
+	return false
+}
+
+func describePackages(packages []string) string {
+	if len(packages) <= 3 {
+		return strings.Join(packages, ", ")
+	}
+	return fmt.Sprintf("%v and %v other workspaces", strings.Join(packages[:3], ", "), len(packages)-3)
+}
+
+// _skipDirs are never searched for environment variable references
+var _skipDirs = util.SetFromStrings([]string{"node_modules", ".git", ".turbo", ".next", "dist", "build", "out", "coverage"})
+
+// _maxSearchedFileSize avoids reading large generated or binary files
+const _maxSearchedFileSize = 1 << 20
+
+func isWildcard(envVar string) bool {
+	return strings.ContainsAny(envVar, "*!")
+}
+
+// workspaceDir returns the repo-relative unix directory of a workspace, which is
+// "" for the root workspace
+func workspaceDir(pkg *turbofs.PackageJSON) string {
+	dir := pkg.Dir.ToUnixPath().ToString()
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
+
+// findEnvVars walks the repository once and returns, for each of envVars, the
+// workspaceDirs containing a file that mentions it. Files are searched one line at
+// a time, so only a single line is held in memory.
+func findEnvVars(repoRoot turbopath.AbsoluteSystemPath, envVars []string, workspaceDirs util.Set) (map[string]util.Set, error) {
+	found := make(map[string]util.Set, len(envVars))
+	for _, envVar := range envVars {
+		found[envVar] = util.Set{}
+	}
+	root := repoRoot.ToString()
+	err := filepath.WalkDir(root, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if file != root && _skipDirs.Includes(entry.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.Name() == "turbo.json" {
+			// The configuration being audited always mentions the variable
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || info.Size() > _maxSearchedFileSize {
+			return nil
+		}
+		mentioned, err := searchFile(file, envVars)
+		if err != nil || len(mentioned) == 0 {
+			return nil
+		}
+		relativePath, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+		// The file belongs to every workspace that it is nested in
+		var dirs []string
+		for dir := filepath.ToSlash(filepath.Dir(relativePath)); ; dir = path.Dir(dir) {
+			if dir == "." || dir == "/" {
+				dirs = append(dirs, "")
+				break
+			}
+			if workspaceDirs.Includes(dir) {
+				dirs = append(dirs, dir)
+			}
+		}
+		for _, envVar := range mentioned {
+			for _, dir := range dirs {
+				found[envVar].Add(dir)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// searchFile returns which of envVars are mentioned in the named file
+func searchFile(name string, envVars []string) ([]string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	remaining := make([][]byte, len(envVars))
+	for i, envVar := range envVars {
+		remaining[i] = []byte(envVar)
+	}
+	var mentioned []string
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), _maxSearchedFileSize)
+	for scanner.Scan() && len(remaining) > 0 {
+		line := scanner.Bytes()
+		unmatched := remaining[:0]
+		for _, needle := range remaining {
+			if bytes.Contains(line, needle) {
+				mentioned = append(mentioned, string(needle))
+			} else {
+				unmatched = append(unmatched, needle)
+			}
+		}
+		remaining = unmatched
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return mentioned, nil
+}
@@ -0,0 +1,288 @@
+// Package auth signs in to a remote cache behind an OpenID Connect provider,
+// such as a corporate single sign-on, with the OAuth device authorization flow
+// (RFC 8628), and refreshes the tokens it gets.
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultScopes are requested when turbo.json doesn't list any. offline_access
+// asks for a refresh token, so that the token can be refreshed without signing
+// in again.
+var DefaultScopes = []string{"openid", "offline_access"}
+
+const (
+	_deviceCodeGrant   = "urn:ietf:params:oauth:grant-type:device_code"
+	_refreshTokenGrant = "refresh_token"
+	// _defaultInterval is how often the token is polled for when the provider
+	// doesn't say, and _slowDown is how much slower it is polled when the
+	// provider asks to
+	_defaultInterval = 5 * time.Second
+	_slowDown        = 5 * time.Second
+)
+
+// ErrAccessDenied is returned when the user denies the login
+var ErrAccessDenied = errors.New("the login was denied")
+
+// ErrExpiredCode is returned when the device code expires before the user
+// enters it
+var ErrExpiredCode = errors.New("the code expired before it was entered")
+
+// Provider is an OpenID Connect provider that turbo is registered with as a
+// public client
+type Provider struct {
+	Issuer   string
+	ClientID string
+	// Scopes default to DefaultScopes
+	Scopes []string
+	// Client defaults to http.DefaultClient
+	Client *http.Client
+
+	endpoints *endpoints
+}
+
+// Token is a token issued by a Provider
+type Token struct {
+	AccessToken string
+	// RefreshToken is empty if the provider didn't issue one
+	RefreshToken string
+	// Expiry is zero if the provider didn't say when the token expires
+	Expiry time.Time
+}
+
+// DeviceCode is the code that the user enters at the provider to authorize turbo
+type DeviceCode struct {
+	UserCode        string
+	VerificationURI string
+	// VerificationURIComplete includes the code, so that the user doesn't
+	// have to enter it. It is empty if the provider doesn't support it.
+	VerificationURIComplete string
+
+	deviceCode string
+	interval   time.Duration
+	expiresAt  time.Time
+}
+
+// endpoints are the parts of the provider's metadata that the flow uses
+type endpoints struct {
+	DeviceAuthorization string `json:"device_authorization_endpoint"`
+	Token               string `json:"token_endpoint"`
+}
+
+// tokenResponse is the response of the token endpoint, which is either a token
+// or an error
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// RequestDeviceCode starts a login. The user authorizes turbo by entering the
+// code at its verification URI while WaitForToken polls for the token.
+func (p *Provider) RequestDeviceCode(ctx context.Context) (*DeviceCode, error) {
+	endpoints, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if endpoints.DeviceAuthorization == "" {
+		return nil, fmt.Errorf("%v doesn't support the device authorization flow", p.Issuer)
+	}
+	scopes := p.Scopes
+	if len(scopes) == 0 {
+		scopes = DefaultScopes
+	}
+	var response struct {
+		DeviceCode              string `json:"device_code"`
+		UserCode                string `json:"user_code"`
+		VerificationURI         string `json:"verification_uri"`
+		VerificationURIComplete string `json:"verification_uri_complete"`
+		ExpiresIn               int64  `json:"expires_in"`
+		Interval                *int64 `json:"interval"`
+	}
+	statusCode, err := p.postForm(ctx, endpoints.DeviceAuthorization, url.Values{
+		"client_id": {p.ClientID},
+		"scope":     {strings.Join(scopes, " ")},
+	}, &response)
+	if err != nil {
+		return nil, err
+	}
+	if statusCode != http.StatusOK || response.DeviceCode == "" {
+		return nil, fmt.Errorf("failed to request a device code from %v: status %v", p.Issuer, statusCode)
+	}
+	code := &DeviceCode{
+		UserCode:                response.UserCode,
+		VerificationURI:         response.VerificationURI,
+		VerificationURIComplete: response.VerificationURIComplete,
+		deviceCode:              response.DeviceCode,
+		interval:                _defaultInterval,
+		expiresAt:               time.Now().Add(time.Duration(response.ExpiresIn) * time.Second),
+	}
+	if response.Interval != nil {
+		code.interval = time.Duration(*response.Interval) * time.Second
+	}
+	return code, nil
+}
+
+// WaitForToken polls the provider until the user authorizes the device code.
+// It returns ErrAccessDenied if the user denies it, and ErrExpiredCode if it
+// expires first.
+func (p *Provider) WaitForToken(ctx context.Context, code *DeviceCode) (*Token, error) {
+	endpoints, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	interval := code.interval
+	for {
+		if time.Now().After(code.expiresAt) {
+			return nil, ErrExpiredCode
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+
+		var response tokenResponse
+		statusCode, err := p.postForm(ctx, endpoints.Token, url.Values{
+			"grant_type":  {_deviceCodeGrant},
+			"device_code": {code.deviceCode},
+			"client_id":   {p.ClientID},
+		}, &response)
+		if err != nil {
+			return nil, err
+		}
+		switch response.Error {
+		case "":
+			return response.token(statusCode, "", p.Issuer)
+		case "authorization_pending":
+		case "slow_down":
+			interval += _slowDown
+		case "access_denied":
+			return nil, ErrAccessDenied
+		case "expired_token":
+			return nil, ErrExpiredCode
+		default:
+			return nil, response.err(p.Issuer)
+		}
+	}
+}
+
+// Refresh exchanges a refresh token for a new token. The refresh token is kept
+// if the provider doesn't issue a new one.
+func (p *Provider) Refresh(ctx context.Context, refreshToken string) (*Token, error) {
+	endpoints, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var response tokenResponse
+	statusCode, err := p.postForm(ctx, endpoints.Token, url.Values{
+		"grant_type":    {_refreshTokenGrant},
+		"refresh_token": {refreshToken},
+		"client_id":     {p.ClientID},
+	}, &response)
+	if err != nil {
+		return nil, err
+	}
+	if response.Error != "" {
+		return nil, response.err(p.Issuer)
+	}
+	return response.token(statusCode, refreshToken, p.Issuer)
+}
+
+// token returns the token of a successful response, keeping refreshToken if the
+// response doesn't have a new one
+func (r *tokenResponse) token(statusCode int, refreshToken string, issuer string) (*Token, error) {
+	if statusCode != http.StatusOK || r.AccessToken == "" {
+		return nil, fmt.Errorf("failed to get a token from %v: status %v", issuer, statusCode)
+	}
+	token := &Token{
+		AccessToken:  r.AccessToken,
+		RefreshToken: r.RefreshToken,
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	}
+	if r.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(r.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+func (r *tokenResponse) err(issuer string) error {
+	if r.ErrorDescription != "" {
+		return fmt.Errorf("%v returned %v: %v", issuer, r.Error, r.ErrorDescription)
+	}
+	return fmt.Errorf("%v returned %v", issuer, r.Error)
+}
+
+// discover reads the endpoints from the provider's metadata, once
+func (p *Provider) discover(ctx context.Context) (*endpoints, error) {
+	if p.endpoints != nil {
+		return p.endpoints, nil
+	}
+	metadataURL := strings.TrimSuffix(p.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the configuration of %v: %w", p.Issuer, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to read the configuration of %v: status %v", p.Issuer, resp.StatusCode)
+	}
+	endpoints := &endpoints{}
+	if err := json.NewDecoder(resp.Body).Decode(endpoints); err != nil {
+		return nil, fmt.Errorf("failed to read the configuration of %v: %w", p.Issuer, err)
+	}
+	if endpoints.Token == "" {
+		return nil, fmt.Errorf("the configuration of %v has no token_endpoint", p.Issuer)
+	}
+	p.endpoints = endpoints
+	return endpoints, nil
+}
+
+// postForm posts values to endpoint and decodes the JSON response into v,
+// returning the status code. Error responses of the token endpoint are JSON
+// too, so they are decoded whatever the status.
+func (p *Provider) postForm(ctx context.Context, endpoint string, values url.Values, v interface{}) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(values.Encode()))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client().Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reach %v: %w", p.Issuer, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if err := json.Unmarshal(body, v); err != nil && resp.StatusCode == http.StatusOK {
+		return 0, fmt.Errorf("failed to read the response of %v: %w", p.Issuer, err)
+	}
+	return resp.StatusCode, nil
+}
+
+func (p *Provider) client() *http.Client {
+	if p.Client != nil {
+		return p.Client
+	}
+	return http.DefaultClient
+}
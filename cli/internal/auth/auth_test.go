@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+// newTestProvider serves the metadata of a provider, and answers its token
+// endpoint with token
+func newTestProvider(t *testing.T, token func(form map[string]string) (int, interface{})) *Provider {
+	t.Helper()
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	writeJSON := func(w http.ResponseWriter, statusCode int, v interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(statusCode)
+		assert.NilError(t, json.NewEncoder(w).Encode(v))
+	}
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{
+			"issuer":                        server.URL,
+			"device_authorization_endpoint": server.URL + "/device",
+			"token_endpoint":                server.URL + "/token",
+		})
+	})
+	mux.HandleFunc("/device", func(w http.ResponseWriter, r *http.Request) {
+		assert.NilError(t, r.ParseForm())
+		assert.Equal(t, r.PostForm.Get("client_id"), "turbo")
+		assert.Equal(t, r.PostForm.Get("scope"), "openid offline_access")
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"device_code":      "device-code",
+			"user_code":        "ABCD-EFGH",
+			"verification_uri": server.URL + "/activate",
+			"expires_in":       600,
+			"interval":         0,
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		assert.NilError(t, r.ParseForm())
+		form := map[string]string{}
+		for key := range r.PostForm {
+			form[key] = r.PostForm.Get(key)
+		}
+		statusCode, response := token(form)
+		writeJSON(w, statusCode, response)
+	})
+	return &Provider{Issuer: server.URL, ClientID: "turbo", Client: server.Client()}
+}
+
+func TestDeviceLogin(t *testing.T) {
+	polls := 0
+	provider := newTestProvider(t, func(form map[string]string) (int, interface{}) {
+		assert.Equal(t, form["grant_type"], _deviceCodeGrant)
+		assert.Equal(t, form["device_code"], "device-code")
+		polls++
+		if polls < 3 {
+			return http.StatusBadRequest, map[string]string{"error": "authorization_pending"}
+		}
+		return http.StatusOK, map[string]interface{}{"access_token": "access", "refresh_token": "refresh", "expires_in": 3600}
+	})
+
+	code, err := provider.RequestDeviceCode(context.Background())
+	assert.NilError(t, err)
+	assert.Equal(t, code.UserCode, "ABCD-EFGH")
+	assert.Equal(t, code.VerificationURI, provider.Issuer+"/activate")
+
+	token, err := provider.WaitForToken(context.Background(), code)
+	assert.NilError(t, err)
+	assert.Equal(t, polls, 3)
+	assert.Equal(t, token.AccessToken, "access")
+	assert.Equal(t, token.RefreshToken, "refresh")
+	assert.Assert(t, time.Until(token.Expiry) > 59*time.Minute)
+}
+
+func TestDeviceLoginDenied(t *testing.T) {
+	provider := newTestProvider(t, func(form map[string]string) (int, interface{}) {
+		return http.StatusBadRequest, map[string]string{"error": "access_denied"}
+	})
+	code, err := provider.RequestDeviceCode(context.Background())
+	assert.NilError(t, err)
+	_, err = provider.WaitForToken(context.Background(), code)
+	assert.ErrorIs(t, err, ErrAccessDenied)
+}
+
+func TestRefresh(t *testing.T) {
+	provider := newTestProvider(t, func(form map[string]string) (int, interface{}) {
+		assert.Equal(t, form["grant_type"], _refreshTokenGrant)
+		if form["refresh_token"] != "refresh" {
+			return http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "the refresh token was revoked"}
+		}
+		return http.StatusOK, map[string]interface{}{"access_token": "new-access"}
+	})
+
+	token, err := provider.Refresh(context.Background(), "refresh")
+	assert.NilError(t, err)
+	assert.Equal(t, token.AccessToken, "new-access")
+	assert.Equal(t, token.RefreshToken, "refresh", "the refresh token is kept when no new one is issued")
+	assert.Assert(t, token.Expiry.IsZero())
+
+	_, err = provider.Refresh(context.Background(), "revoked")
+	assert.ErrorContains(t, err, "returned invalid_grant: the refresh token was revoked")
+}
@@ -0,0 +1,162 @@
+// Package boundaries implements `turbo boundaries`, which checks the dependencies
+// between workspaces against the tag rules in the "boundaries" key of turbo.json.
+//
+// It exits with an error when a rule is violated, so it can be run from CI or
+// as a root task in the pipeline.
+package boundaries
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/context"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+	"github.com/vercel/turbo/cli/internal/ui"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// Violation is a dependency that breaks a boundary rule
+type Violation struct {
+	Package    string
+	Dependency string
+	Reason     string
+}
+
+// ExecuteBoundaries executes the `boundaries` command.
+func ExecuteBoundaries(helper *cmdutil.Helper, args *turbostate.ParsedArgsFromRust) error {
+	base, err := helper.GetCmdBase(args)
+	if err != nil {
+		return err
+	}
+	if err := runBoundaries(base); err != nil {
+		base.LogError("boundaries failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+func runBoundaries(base *cmdutil.CmdBase) error {
+	rootPackageJSON, err := fs.ReadPackageJSON(base.RepoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		return fmt.Errorf("failed to read package.json: %w", err)
+	}
+	turboJSON, err := fs.LoadTurboConfig(base.RepoRoot, rootPackageJSON, false)
+	if err != nil {
+		return err
+	}
+	if len(turboJSON.Boundaries.Rules) == 0 {
+		base.UI.Warn("No boundary rules are configured. Add \"boundaries\" to turbo.json to check dependencies between workspaces")
+		return nil
+	}
+	pkgDepGraph, err := context.BuildPackageGraph(base.RepoRoot, rootPackageJSON)
+	if err != nil {
+		var warnings *context.Warnings
+		if !errors.As(err, &warnings) {
+			return err
+		}
+		base.LogWarning("Issues occurred when constructing package graph. Turbo will function, but some features may not be available", err)
+	}
+
+	dependencies := make(map[string][]string)
+	for name := range pkgDepGraph.WorkspaceInfos.PackageJSONs {
+		if name == util.RootPkgName {
+			continue
+		}
+		dependencies[name] = []string{}
+		for _, dep := range pkgDepGraph.WorkspaceGraph.DownEdges(name).List() {
+			depName := dep.(string)
+			if depName != core.ROOT_NODE_NAME && depName != util.RootPkgName {
+				dependencies[name] = append(dependencies[name], depName)
+			}
+		}
+	}
+
+	violations, err := check(turboJSON.Boundaries, dependencies)
+	if err != nil {
+		return err
+	}
+	if len(violations) == 0 {
+		base.UI.Output(util.Sprintf("${BOLD_GREEN}No boundary violations found${RESET}"))
+		return nil
+	}
+	for _, violation := range violations {
+		base.UI.Output(fmt.Sprintf("%s → %s %s", ui.Bold(violation.Package), ui.Bold(violation.Dependency), ui.Dim(violation.Reason)))
+	}
+	base.UI.Output("")
+	return fmt.Errorf("found %v boundary violations", len(violations))
+}
+
+// check validates the boundaries configuration and returns the dependencies that
+// violate its rules, sorted by package and dependency. dependencies maps each
+// workspace to the workspaces it directly depends on.
+func check(boundaries fs.Boundaries, dependencies map[string][]string) ([]*Violation, error) {
+	for pkg := range boundaries.Tags {
+		if _, ok := dependencies[pkg]; !ok {
+			return nil, fmt.Errorf("boundaries: tags are configured for unknown workspace %v", pkg)
+		}
+	}
+	for i, rule := range boundaries.Rules {
+		if rule.From == "" {
+			return nil, fmt.Errorf("boundaries: rule %v is missing \"from\"", i)
+		}
+		if len(rule.Allow) == 0 && len(rule.Deny) == 0 {
+			return nil, fmt.Errorf("boundaries: rule for %v must set \"allow\" or \"deny\"", rule.From)
+		}
+	}
+
+	tags := make(map[string]util.Set, len(boundaries.Tags))
+	for pkg, pkgTags := range boundaries.Tags {
+		tags[pkg] = util.SetFromStrings(pkgTags)
+	}
+
+	violations := []*Violation{}
+	for pkg, deps := range dependencies {
+		for _, rule := range boundaries.Rules {
+			if !tags[pkg].Includes(rule.From) {
+				continue
+			}
+			for _, dep := range deps {
+				if reason, ok := violates(rule, tags[dep]); ok {
+					violations = append(violations, &Violation{
+						Package:    pkg,
+						Dependency: dep,
+						Reason:     reason,
+					})
+				}
+			}
+		}
+	}
+	sort.Slice(violations, func(i, j int) bool {
+		if violations[i].Package != violations[j].Package {
+			return violations[i].Package < violations[j].Package
+		}
+		if violations[i].Dependency != violations[j].Dependency {
+			return violations[i].Dependency < violations[j].Dependency
+		}
+		return violations[i].Reason < violations[j].Reason
+	})
+	return violations, nil
+}
+
+// violates returns why a dependency with the given tags breaks the rule, if it does
+func violates(rule fs.BoundaryRule, depTags util.Set) (string, bool) {
+	for _, denied := range rule.Deny {
+		if depTags.Includes(denied) {
+			return fmt.Sprintf("workspaces tagged %v may not depend on workspaces tagged %v", rule.From, denied), true
+		}
+	}
+	if len(rule.Allow) == 0 {
+		return "", false
+	}
+	for _, allowed := range rule.Allow {
+		if depTags.Includes(allowed) {
+			return "", false
+		}
+	}
+	return fmt.Sprintf("workspaces tagged %v may only depend on workspaces tagged %v", rule.From, strings.Join(rule.Allow, ", ")), true
+}
@@ -0,0 +1,98 @@
+package boundaries
+
+import (
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+func TestCheck(t *testing.T) {
+	dependencies := map[string][]string{
+		"web":        {"@acme/ui", "api-client"},
+		"@acme/ui":   {"db"},
+		"api-client": {},
+		"db":         {},
+	}
+	tags := map[string][]string{
+		"web":        {"app"},
+		"@acme/ui":   {"ui"},
+		"api-client": {"shared"},
+		"db":         {"server"},
+	}
+	testCases := []struct {
+		name  string
+		rules []fs.BoundaryRule
+		want  []*Violation
+	}{
+		{
+			name:  "no rules",
+			rules: nil,
+			want:  []*Violation{},
+		},
+		{
+			name:  "deny",
+			rules: []fs.BoundaryRule{{From: "ui", Deny: []string{"server"}}},
+			want: []*Violation{
+				{Package: "@acme/ui", Dependency: "db", Reason: "workspaces tagged ui may not depend on workspaces tagged server"},
+			},
+		},
+		{
+			name:  "allow",
+			rules: []fs.BoundaryRule{{From: "app", Allow: []string{"ui"}}},
+			want: []*Violation{
+				{Package: "web", Dependency: "api-client", Reason: "workspaces tagged app may only depend on workspaces tagged ui"},
+			},
+		},
+		{
+			name:  "allow with several tags",
+			rules: []fs.BoundaryRule{{From: "app", Allow: []string{"ui", "shared"}}},
+			want:  []*Violation{},
+		},
+		{
+			name: "rules for untagged workspaces do nothing",
+			rules: []fs.BoundaryRule{
+				{From: "docs", Deny: []string{"server"}},
+			},
+			want: []*Violation{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			violations, err := check(fs.Boundaries{Tags: tags, Rules: tc.rules}, dependencies)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, violations, tc.want)
+		})
+	}
+}
+
+func TestCheckInvalidConfig(t *testing.T) {
+	dependencies := map[string][]string{"web": {}}
+	testCases := []struct {
+		name       string
+		boundaries fs.Boundaries
+		wantErr    string
+	}{
+		{
+			name:       "unknown workspace",
+			boundaries: fs.Boundaries{Tags: map[string][]string{"docs": {"app"}}},
+			wantErr:    "boundaries: tags are configured for unknown workspace docs",
+		},
+		{
+			name:       "missing from",
+			boundaries: fs.Boundaries{Rules: []fs.BoundaryRule{{Deny: []string{"server"}}}},
+			wantErr:    "boundaries: rule 0 is missing \"from\"",
+		},
+		{
+			name:       "no allow or deny",
+			boundaries: fs.Boundaries{Rules: []fs.BoundaryRule{{From: "ui"}}},
+			wantErr:    "boundaries: rule for ui must set \"allow\" or \"deny\"",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := check(tc.boundaries, dependencies)
+			assert.Error(t, err, tc.wantErr)
+		})
+	}
+}
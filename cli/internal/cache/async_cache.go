@@ -58,6 +58,10 @@ func (c *asyncCache) Exists(key string) ItemStatus {
 	return c.realCache.Exists(key)
 }
 
+func (c *asyncCache) Prefetch(hashes []string) {
+	c.realCache.Prefetch(hashes)
+}
+
 func (c *asyncCache) Clean(anchor turbopath.AbsoluteSystemPath) {
 	c.realCache.Clean(anchor)
 }
@@ -71,6 +75,7 @@ func (c *asyncCache) Shutdown() {
 	close(c.requests)
 	c.wg.Wait()
 	// fmt.Println("Shut down all cache workers")
+	c.realCache.Shutdown()
 }
 
 // run implements the actual async logic.
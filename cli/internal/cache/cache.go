@@ -7,9 +7,11 @@ package cache
 
 import (
 	"errors"
+	"os"
 	"sync"
 
 	"github.com/vercel/turbo/cli/internal/analytics"
+	"github.com/vercel/turbo/cli/internal/cacheitem"
 	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/turbopath"
 	"github.com/vercel/turbo/cli/internal/util"
@@ -22,6 +24,10 @@ type Cache interface {
 	// into their correct position as a side effect
 	Fetch(anchor turbopath.AbsoluteSystemPath, hash string, files []string) (bool, []turbopath.AnchoredSystemPath, int, error)
 	Exists(hash string) ItemStatus
+	// Prefetch starts downloading the artifacts for hashes in the background,
+	// in order, so that fetching them later doesn't wait on the network.
+	// Caches on the local filesystem ignore it.
+	Prefetch(hashes []string)
 	// Put caches files for a given hash
 	Put(anchor turbopath.AbsoluteSystemPath, hash string, duration int, files []turbopath.AnchoredSystemPath) error
 	Clean(anchor turbopath.AbsoluteSystemPath)
@@ -46,6 +52,9 @@ type CacheEvent struct {
 	Duration int    `mapstructure:"duration"`
 }
 
+// CacheDirEnvVar overrides the filesystem cache location when --cache-dir isn't passed
+const CacheDirEnvVar = "TURBO_CACHE_DIR"
+
 // DefaultLocation returns the default filesystem cache location, given a repo root
 func DefaultLocation(repoRoot turbopath.AbsoluteSystemPath) turbopath.AbsoluteSystemPath {
 	return repoRoot.UntypedJoin("node_modules", ".cache", "turbo")
@@ -62,19 +71,34 @@ var ErrNoCachesEnabled = errors.New("no caches are enabled")
 // Opts holds configuration options for the cache
 // TODO(gsoltis): further refactor this into fs cache opts and http cache opts
 type Opts struct {
-	OverrideDir     string
-	SkipRemote      bool
-	SkipFilesystem  bool
-	Workers         int
+	OverrideDir    string
+	SkipRemote     bool
+	SkipFilesystem bool
+	Workers        int
+	// MaxSize caps the size of the filesystem cache, or is 0 if it is unlimited
+	MaxSize         int64
 	RemoteCacheOpts fs.RemoteCacheOptions
+	// RemoteStats collects the transfers of the remote cache, if it is set
+	RemoteStats *RemoteStats
+	// Scope is the normalized namespace that artifacts are uploaded to in the
+	// remote cache, or empty if they are shared. See NormalizeScope.
+	Scope string
+	// Provenance is recorded with every artifact that is written, or nil to
+	// record none
+	Provenance *Provenance
 }
 
-// resolveCacheDir calculates the location turbo should use to cache artifacts,
-// based on the options supplied by the user.
-func (o *Opts) resolveCacheDir(repoRoot turbopath.AbsoluteSystemPath) turbopath.AbsoluteSystemPath {
+// ResolveCacheDir calculates the location turbo should use to cache artifacts,
+// based on the options supplied by the user: OverrideDir if it is set, then
+// TURBO_CACHE_DIR, then the default location. Relative paths are resolved from
+// the repo root.
+func (o *Opts) ResolveCacheDir(repoRoot turbopath.AbsoluteSystemPath) turbopath.AbsoluteSystemPath {
 	if o.OverrideDir != "" {
 		return fs.ResolveUnknownPath(repoRoot, o.OverrideDir)
 	}
+	if envDir := os.Getenv(CacheDirEnvVar); envDir != "" {
+		return fs.ResolveUnknownPath(repoRoot, envDir)
+	}
 	return DefaultLocation(repoRoot)
 }
 
@@ -82,7 +106,7 @@ var _remoteOnlyHelp = `Ignore the local filesystem cache for all tasks. Only
 allow reading and caching artifacts using the remote cache.`
 
 // New creates a new cache
-func New(opts Opts, repoRoot turbopath.AbsoluteSystemPath, client client, recorder analytics.Recorder, onCacheRemoved OnCacheRemoved) (Cache, error) {
+func New(opts Opts, repoRoot turbopath.AbsoluteSystemPath, client RemoteClient, recorder analytics.Recorder, onCacheRemoved OnCacheRemoved) (Cache, error) {
 	c, err := newSyncCache(opts, repoRoot, client, recorder, onCacheRemoved)
 	if err != nil && !errors.Is(err, ErrNoCachesEnabled) {
 		return nil, err
@@ -94,7 +118,7 @@ func New(opts Opts, repoRoot turbopath.AbsoluteSystemPath, client client, record
 }
 
 // newSyncCache can return an error with a usable noopCache.
-func newSyncCache(opts Opts, repoRoot turbopath.AbsoluteSystemPath, client client, recorder analytics.Recorder, onCacheRemoved OnCacheRemoved) (Cache, error) {
+func newSyncCache(opts Opts, repoRoot turbopath.AbsoluteSystemPath, client RemoteClient, recorder analytics.Recorder, onCacheRemoved OnCacheRemoved) (Cache, error) {
 	// Check to see if the user has turned off particular cache implementations.
 	useFsCache := !opts.SkipFilesystem
 	useHTTPCache := !opts.SkipRemote
@@ -241,6 +265,7 @@ func (mplex *cacheMultiplexer) Fetch(anchor turbopath.AbsoluteSystemPath, key st
 
 	// Retrieve from caches sequentially; if we did them simultaneously we could
 	// easily write the same file from two goroutines at once.
+	var corruptedErr error
 	for i, cache := range caches {
 		ok, actualFiles, duration, err := cache.Fetch(anchor, key, files)
 		if err != nil {
@@ -250,6 +275,10 @@ func (mplex *cacheMultiplexer) Fetch(anchor turbopath.AbsoluteSystemPath, key st
 					cache: cache,
 					err:   cd,
 				})
+			} else if errors.Is(err, cacheitem.ErrCorrupted) && corruptedErr == nil {
+				// A lower priority cache may still have a valid artifact, which
+				// then replaces the corrupted one.
+				corruptedErr = err
 			}
 			// We're ignoring the error in the else case, since with this cache
 			// abstraction, we want to check lower priority caches rather than fail
@@ -265,7 +294,7 @@ func (mplex *cacheMultiplexer) Fetch(anchor turbopath.AbsoluteSystemPath, key st
 		}
 	}
 
-	return false, nil, 0, nil
+	return false, nil, 0, corruptedErr
 }
 
 func (mplex *cacheMultiplexer) Exists(target string) ItemStatus {
@@ -279,6 +308,31 @@ func (mplex *cacheMultiplexer) Exists(target string) ItemStatus {
 	return syncCacheState
 }
 
+// Prefetch passes on to each cache the hashes whose artifacts aren't in the
+// caches before it on the local filesystem
+func (mplex *cacheMultiplexer) Prefetch(hashes []string) {
+	mplex.mu.RLock()
+	caches := make([]Cache, len(mplex.caches))
+	copy(caches, mplex.caches)
+	mplex.mu.RUnlock()
+
+	for _, cache := range caches {
+		if len(hashes) == 0 {
+			return
+		}
+		cache.Prefetch(hashes)
+		if fsCache, ok := cache.(*fsCache); ok {
+			missing := make([]string, 0, len(hashes))
+			for _, hash := range hashes {
+				if !fsCache.Exists(hash).Local {
+					missing = append(missing, hash)
+				}
+			}
+			hashes = missing
+		}
+	}
+}
+
 func (mplex *cacheMultiplexer) Clean(anchor turbopath.AbsoluteSystemPath) {
 	for _, cache := range mplex.caches {
 		cache.Clean(anchor)
@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// EncryptionKeyEnvVar holds the keys that artifacts are encrypted with, and
+// EncryptionKeyFileEnvVar the path of a file that holds them instead
+const (
+	EncryptionKeyEnvVar     = "TURBO_REMOTE_CACHE_ENCRYPTION_KEY"
+	EncryptionKeyFileEnvVar = "TURBO_REMOTE_CACHE_ENCRYPTION_KEY_FILE"
+)
+
+// _encryptedArtifactMagic starts every encrypted artifact, followed by the ID
+// of its key and its nonce. Compressed artifacts never start with it.
+var _encryptedArtifactMagic = []byte("TURBOENC\x01")
+
+const (
+	_encryptionKeySize   = 32
+	_encryptionKeyIDSize = 8
+)
+
+// errUnencryptedArtifact is returned for artifacts that were uploaded before
+// encryption was enabled
+var errUnencryptedArtifact = errors.New("artifact decryption failed: the artifact isn't encrypted")
+
+// ArtifactEncryption encrypts artifacts with AES-256-GCM before they are
+// uploaded, so that the remote cache only stores ciphertext. Keys are listed
+// newest first: the first one encrypts uploads, and the others still decrypt
+// the artifacts that were uploaded before it, which are found by the ID of
+// their key.
+type ArtifactEncryption struct {
+	enabled bool
+
+	once sync.Once
+	keys []encryptionKey
+	err  error
+}
+
+type encryptionKey struct {
+	id   []byte
+	aead cipher.AEAD
+}
+
+func (ae *ArtifactEncryption) isEnabled() bool {
+	return ae != nil && ae.enabled
+}
+
+// loadKeys reads the keys from EncryptionKeyEnvVar, or the file in
+// EncryptionKeyFileEnvVar. Keys are 32 bytes encoded in base64, separated by
+// commas or newlines.
+func (ae *ArtifactEncryption) loadKeys() ([]encryptionKey, error) {
+	ae.once.Do(func() {
+		source := EncryptionKeyEnvVar
+		value := os.Getenv(EncryptionKeyEnvVar)
+		if value == "" {
+			if path := os.Getenv(EncryptionKeyFileEnvVar); path != "" {
+				source = path
+				contents, err := os.ReadFile(path)
+				if err != nil {
+					ae.err = fmt.Errorf("failed to read encryption keys: %w", err)
+					return
+				}
+				value = string(contents)
+			}
+		}
+		fields := strings.FieldsFunc(value, func(r rune) bool {
+			return r == ',' || r == '\n' || r == '\r'
+		})
+		for _, field := range fields {
+			field = strings.TrimSpace(field)
+			if field == "" || strings.HasPrefix(field, "#") {
+				continue
+			}
+			key, err := newEncryptionKey(field)
+			if err != nil {
+				ae.err = fmt.Errorf("invalid key in %v: %w", source, err)
+				return
+			}
+			ae.keys = append(ae.keys, key)
+		}
+		if len(ae.keys) == 0 {
+			ae.err = fmt.Errorf("encryption key not found. You must specify a key in the %v environment variable, or a file of keys in %v", EncryptionKeyEnvVar, EncryptionKeyFileEnvVar)
+		}
+	})
+	return ae.keys, ae.err
+}
+
+func newEncryptionKey(encoded string) (encryptionKey, error) {
+	secret, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(secret) != _encryptionKeySize {
+		return encryptionKey{}, fmt.Errorf("expected %v bytes encoded in base64, such as the output of `openssl rand -base64 %v`", _encryptionKeySize, _encryptionKeySize)
+	}
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return encryptionKey{}, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return encryptionKey{}, err
+	}
+	// The ID identifies the key without revealing it
+	sum := sha256.Sum256(secret)
+	return encryptionKey{id: sum[:_encryptionKeyIDSize], aead: aead}, nil
+}
+
+// additionalData binds an artifact to its hash, so that the artifact of one
+// task can't be served as the artifact of another
+func additionalData(header []byte, hash string) []byte {
+	return append(append([]byte{}, header...), hash...)
+}
+
+// encrypt encrypts the artifact of hash with the newest key
+func (ae *ArtifactEncryption) encrypt(hash string, artifactBody []byte) ([]byte, error) {
+	keys, err := ae.loadKeys()
+	if err != nil {
+		return nil, err
+	}
+	key := keys[0]
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	header := make([]byte, 0, len(_encryptedArtifactMagic)+len(key.id)+len(nonce))
+	header = append(header, _encryptedArtifactMagic...)
+	header = append(header, key.id...)
+	header = append(header, nonce...)
+	return key.aead.Seal(header, nonce, artifactBody, additionalData(header, hash)), nil
+}
+
+// isEncrypted returns whether an artifact was encrypted
+func isEncrypted(artifactBody []byte) bool {
+	return bytes.HasPrefix(artifactBody, _encryptedArtifactMagic)
+}
+
+// decrypt decrypts the artifact of hash with the key it was encrypted with
+func (ae *ArtifactEncryption) decrypt(hash string, artifactBody []byte) ([]byte, error) {
+	keys, err := ae.loadKeys()
+	if err != nil {
+		return nil, fmt.Errorf("artifact decryption failed: %w", err)
+	}
+	idStart := len(_encryptedArtifactMagic)
+	nonceStart := idStart + _encryptionKeyIDSize
+	if len(artifactBody) < nonceStart {
+		return nil, errors.New("artifact decryption failed: the artifact is truncated")
+	}
+	id := artifactBody[idStart:nonceStart]
+	for _, key := range keys {
+		if !bytes.Equal(key.id, id) {
+			continue
+		}
+		ciphertextStart := nonceStart + key.aead.NonceSize()
+		if len(artifactBody) < ciphertextStart {
+			return nil, errors.New("artifact decryption failed: the artifact is truncated")
+		}
+		header := artifactBody[:ciphertextStart]
+		plaintext, err := key.aead.Open(nil, artifactBody[nonceStart:ciphertextStart], artifactBody[ciphertextStart:], additionalData(header, hash))
+		if err != nil {
+			return nil, fmt.Errorf("artifact decryption failed: %w", err)
+		}
+		return plaintext, nil
+	}
+	return nil, fmt.Errorf("artifact decryption failed: no key with ID %v", hex.EncodeToString(id))
+}
@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func newTestEncryptionKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, _encryptionKeySize))
+}
+
+func TestArtifactEncryption(t *testing.T) {
+	oldKey, newKey := newTestEncryptionKey(1), newTestEncryptionKey(2)
+	t.Setenv(EncryptionKeyEnvVar, oldKey)
+	old := &ArtifactEncryption{enabled: true}
+	ciphertext, err := old.encrypt("the-hash", []byte("artifact"))
+	assert.NilError(t, err)
+	assert.Assert(t, isEncrypted(ciphertext))
+	assert.Assert(t, !bytes.Contains(ciphertext, []byte("artifact")))
+
+	// After a rotation, the old key still decrypts the artifacts it encrypted
+	t.Setenv(EncryptionKeyEnvVar, newKey+","+oldKey)
+	rotated := &ArtifactEncryption{enabled: true}
+	plaintext, err := rotated.decrypt("the-hash", ciphertext)
+	assert.NilError(t, err)
+	assert.Equal(t, string(plaintext), "artifact")
+	reencrypted, err := rotated.encrypt("the-hash", []byte("artifact"))
+	assert.NilError(t, err)
+	_, err = old.decrypt("the-hash", reencrypted)
+	assert.ErrorContains(t, err, "no key with ID")
+
+	// An artifact can't be served for another hash
+	_, err = rotated.decrypt("another-hash", ciphertext)
+	assert.ErrorContains(t, err, "artifact decryption failed")
+}
+
+func TestArtifactEncryptionKeys(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "keys")
+	assert.NilError(t, os.WriteFile(keyFile, []byte("# rotated on 2026-10-01\n"+newTestEncryptionKey(3)+"\n"), 0600))
+	t.Setenv(EncryptionKeyEnvVar, "")
+	t.Setenv(EncryptionKeyFileEnvVar, keyFile)
+	keys, err := (&ArtifactEncryption{enabled: true}).loadKeys()
+	assert.NilError(t, err)
+	assert.Equal(t, len(keys), 1)
+
+	t.Setenv(EncryptionKeyFileEnvVar, "")
+	_, err = (&ArtifactEncryption{enabled: true}).loadKeys()
+	assert.ErrorContains(t, err, "encryption key not found")
+	t.Setenv(EncryptionKeyEnvVar, "c2hvcnQ=")
+	_, err = (&ArtifactEncryption{enabled: true}).loadKeys()
+	assert.ErrorContains(t, err, "invalid key in TURBO_REMOTE_CACHE_ENCRYPTION_KEY")
+}
+
+// uploadResp keeps the artifacts that are uploaded, and serves them back
+type uploadResp struct {
+	artifacts map[string][]byte
+	encodings map[string]string
+}
+
+func (sr *uploadResp) PutArtifact(hash string, body []byte, duration int, tag string, contentEncoding string) error {
+	sr.artifacts[hash] = body
+	sr.encodings[hash] = contentEncoding
+	return nil
+}
+
+func (sr *uploadResp) FetchArtifact(hash string) (*http.Response, error) {
+	body, ok := sr.artifacts[hash]
+	if !ok {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: ioutil.NopCloser(&bytes.Buffer{})}, nil
+	}
+	header := http.Header{}
+	if encoding := sr.encodings[hash]; encoding != "" {
+		header.Set("Content-Encoding", encoding)
+	}
+	return &http.Response{StatusCode: http.StatusOK, Header: header, Body: ioutil.NopCloser(bytes.NewReader(body))}, nil
+}
+
+func (sr *uploadResp) ArtifactExists(hash string) (*http.Response, error) {
+	panic("unimplemented")
+}
+
+func (sr *uploadResp) GetTeamID() string {
+	return ""
+}
+
+func TestEncryptedRemoteCache(t *testing.T) {
+	t.Setenv(EncryptionKeyEnvVar, newTestEncryptionKey(4))
+	src := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	assert.NilError(t, src.UntypedJoin("some-file").WriteFile([]byte("some-file-contents"), 0644), "WriteFile")
+	client := &uploadResp{artifacts: map[string][]byte{}, encodings: map[string]string{}}
+	opts := Opts{RemoteStats: NewRemoteStats(), RemoteCacheOpts: fs.RemoteCacheOptions{Encryption: true}}
+	cache := newHTTPCache(opts, client, &nullRecorder{})
+	cache.repoRoot = src
+
+	assert.NilError(t, cache.Put(src, "the-hash", 100, []turbopath.AnchoredSystemPath{"some-file"}))
+	assert.Assert(t, isEncrypted(client.artifacts["the-hash"]))
+	assert.Equal(t, client.encodings["the-hash"], "")
+
+	dst := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	cache.repoRoot = dst
+	hit, files, _, err := cache.Fetch(dst, "the-hash", nil)
+	assert.NilError(t, err)
+	assert.Assert(t, hit)
+	assert.Equal(t, len(files), 1)
+	contents, err := dst.UntypedJoin("some-file").ReadFile()
+	assert.NilError(t, err)
+	assert.Equal(t, string(contents), "some-file-contents")
+
+	// Artifacts uploaded before encryption was enabled aren't restored
+	plain := newHTTPCache(Opts{RemoteStats: NewRemoteStats()}, client, &nullRecorder{})
+	plain.repoRoot = src
+	assert.NilError(t, plain.Put(src, "plain-hash", 100, []turbopath.AnchoredSystemPath{"some-file"}))
+	_, _, _, err = cache.Fetch(dst, "plain-hash", nil)
+	assert.ErrorIs(t, err, errUnencryptedArtifact)
+}
@@ -7,7 +7,9 @@ package cache
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/vercel/turbo/cli/internal/analytics"
 	"github.com/vercel/turbo/cli/internal/cacheitem"
@@ -18,17 +20,23 @@ import (
 type fsCache struct {
 	cacheDirectory turbopath.AbsoluteSystemPath
 	recorder       analytics.Recorder
+	// maxSize is the total size of the artifacts to keep, or 0 if unlimited
+	maxSize int64
+	// provenance is recorded in the metadata of the artifacts that are written
+	provenance *Provenance
 }
 
 // newFsCache creates a new filesystem cache
 func newFsCache(opts Opts, recorder analytics.Recorder, repoRoot turbopath.AbsoluteSystemPath) (*fsCache, error) {
-	cacheDir := opts.resolveCacheDir(repoRoot)
+	cacheDir := opts.ResolveCacheDir(repoRoot)
 	if err := cacheDir.MkdirAll(0775); err != nil {
 		return nil, err
 	}
 	return &fsCache{
 		cacheDirectory: cacheDir,
 		recorder:       recorder,
+		maxSize:        opts.MaxSize,
+		provenance:     opts.Provenance,
 	}, nil
 }
 
@@ -56,6 +64,13 @@ func (f *fsCache) Fetch(anchor turbopath.AbsoluteSystemPath, hash string, _unuse
 	restoredFiles, restoreErr := cacheItem.Restore(anchor)
 	if restoreErr != nil {
 		_ = cacheItem.Close()
+		if errors.Is(restoreErr, cacheitem.ErrCorrupted) {
+			// Remove the artifact so that it is replaced by the next run
+			_ = removeArtifact(f.cacheDirectory, &fsArtifact{
+				hash:  hash,
+				files: []string{actualCachePath.Base(), hash + "-meta.json"},
+			})
+		}
 		return false, nil, 0, restoreErr
 	}
 
@@ -65,6 +80,7 @@ func (f *fsCache) Fetch(anchor turbopath.AbsoluteSystemPath, hash string, _unuse
 		return false, nil, 0, fmt.Errorf("error reading cache metadata: %w", err)
 	}
 	f.logFetch(true, hash, meta.Duration)
+	touchArtifact(actualCachePath, time.Now())
 
 	// Wait to see what happens with close.
 	closeErr := cacheItem.Close()
@@ -101,9 +117,13 @@ func (f *fsCache) logFetch(hit bool, hash string, duration int) {
 	f.recorder.LogEvent(payload)
 }
 
+// Put writes a content-addressed artifact. The files of the artifact are kept
+// in an object store that artifacts share, and restored from it as clones or
+// hardlinks, so that the files that don't change between builds take up space
+// once, and are restored without being copied.
 func (f *fsCache) Put(anchor turbopath.AbsoluteSystemPath, hash string, duration int, files []turbopath.AnchoredSystemPath) error {
-	cachePath := f.cacheDirectory.UntypedJoin(hash + ".tar.zst")
-	cacheItem, err := cacheitem.Create(cachePath)
+	cachePath := f.cacheDirectory.UntypedJoin(hash + ".tar")
+	cacheItem, err := cacheitem.CreateContentAddressed(cachePath)
 	if err != nil {
 		return err
 	}
@@ -117,8 +137,9 @@ func (f *fsCache) Put(anchor turbopath.AbsoluteSystemPath, hash string, duration
 	}
 
 	writeErr := WriteCacheMetaFile(f.cacheDirectory.UntypedJoin(hash+"-meta.json"), &CacheMetadata{
-		Duration: duration,
-		Hash:     hash,
+		Duration:   duration,
+		Hash:       hash,
+		Provenance: f.provenance.forArtifact(hash),
 	})
 
 	if writeErr != nil {
@@ -126,9 +147,18 @@ func (f *fsCache) Put(anchor turbopath.AbsoluteSystemPath, hash string, duration
 		return writeErr
 	}
 
-	return cacheItem.Close()
+	if err := cacheItem.Close(); err != nil {
+		return err
+	}
+	// An artifact written by an older version of turbo would no longer be read
+	_ = f.cacheDirectory.UntypedJoin(hash + ".tar.zst").Remove()
+	return nil
 }
 
+// Prefetch does nothing, since artifacts on the local filesystem are restored
+// without waiting on the network
+func (f *fsCache) Prefetch(hashes []string) {}
+
 func (f *fsCache) Clean(anchor turbopath.AbsoluteSystemPath) {
 	fmt.Println("Not implemented yet")
 }
@@ -137,13 +167,23 @@ func (f *fsCache) CleanAll() {
 	fmt.Println("Not implemented yet")
 }
 
-func (f *fsCache) Shutdown() {}
+// Shutdown removes the least recently used artifacts while the cache is larger
+// than its maximum size, once the artifacts of the run have been written
+func (f *fsCache) Shutdown() {
+	if f.maxSize <= 0 {
+		return
+	}
+	_, _ = CollectGarbage(f.cacheDirectory, GCOpts{MaxSize: f.maxSize}, time.Now())
+}
 
 // CacheMetadata stores duration and hash information for a cache entry so that aggregate Time Saved calculations
 // can be made from artifacts from various caches
 type CacheMetadata struct {
 	Hash     string `json:"hash"`
 	Duration int    `json:"duration"`
+	// Provenance is missing from artifacts written without it, or by older
+	// versions of turbo
+	Provenance *Provenance `json:"provenance,omitempty"`
 }
 
 // WriteCacheMetaFile writes cache metadata file at a path
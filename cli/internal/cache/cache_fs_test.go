@@ -85,7 +85,7 @@ func TestPut(t *testing.T) {
 	// This test checks outputs, so we go ahead and pull things back out.
 	// Attempting to satisfy our beliefs that the change is viable with
 	// as few changes to the tests as possible.
-	cacheItem, openErr := cacheitem.Open(dst.UntypedJoin(hash + ".tar.zst"))
+	cacheItem, openErr := cacheitem.Open(dst.UntypedJoin(hash + ".tar"))
 	assert.NilError(t, openErr, "Open")
 
 	_, restoreErr := cacheItem.Restore(dstCachePath)
@@ -250,3 +250,55 @@ func TestFetch(t *testing.T) {
 	assert.NilError(t, circleReadlinkErr, "Circle Readlink")
 	assert.Equal(t, circleTarget, srcCircleLinkTarget.ToString())
 }
+
+func TestFetchCorrupted(t *testing.T) {
+	src := turbopath.AbsoluteSystemPath(t.TempDir())
+	cacheDir := turbopath.AbsoluteSystemPath(t.TempDir())
+	assert.NilError(t, src.UntypedJoin("a").WriteFile([]byte("a"), 0644), "WriteFile")
+
+	cache := &fsCache{cacheDirectory: cacheDir, recorder: &dummyRecorder{}}
+	assert.NilError(t, cache.Put(src, "the-hash", 1, []turbopath.AnchoredSystemPath{"a"}), "Put")
+	archivePath := cacheDir.UntypedJoin("the-hash.tar")
+	artifact, err := archivePath.ReadFile()
+	assert.NilError(t, err, "ReadFile")
+	assert.NilError(t, archivePath.WriteFile(artifact[:len(artifact)/2], 0644), "WriteFile")
+
+	hit, _, _, err := cache.Fetch(turbopath.AbsoluteSystemPath(t.TempDir()), "the-hash", nil)
+	assert.ErrorIs(t, err, cacheitem.ErrCorrupted)
+	assert.Assert(t, !hit)
+	// The corrupted artifact is removed, so that the next run replaces it
+	assert.Assert(t, !archivePath.FileExists())
+	assert.Assert(t, !cacheDir.UntypedJoin("the-hash-meta.json").FileExists())
+}
+
+func TestPutProvenance(t *testing.T) {
+	src := turbopath.AbsoluteSystemPath(t.TempDir())
+	cacheDir := turbopath.AbsoluteSystemPath(t.TempDir())
+	assert.NilError(t, src.UntypedJoin("a").WriteFile([]byte("a"), 0644), "WriteFile")
+
+	provenance := &Provenance{User: "ci", GitSHA: "0123abc", TurboVersion: "1.9.0"}
+	cache := &fsCache{cacheDirectory: cacheDir, recorder: &dummyRecorder{}, provenance: provenance}
+	assert.NilError(t, cache.Put(src, "the-hash", 1, []turbopath.AnchoredSystemPath{"a"}), "Put")
+
+	meta, err := ReadCacheMetaFile(cacheDir.UntypedJoin("the-hash-meta.json"))
+	assert.NilError(t, err, "ReadCacheMetaFile")
+	assert.Equal(t, meta.Provenance.User, "ci")
+	assert.Equal(t, meta.Provenance.GitSHA, "0123abc")
+	assert.Equal(t, meta.Provenance.InputsDigest, "the-hash")
+	assert.Assert(t, meta.Provenance.CreatedAt != nil)
+	// The provenance of the run isn't changed by the artifacts that are written
+	assert.Equal(t, provenance.InputsDigest, "")
+}
+
+func TestProvenanceFromMetadata(t *testing.T) {
+	provenance := &Provenance{User: "ci", CIJobURL: "https://ci.example.com/jobs/1", GitSHA: "0123abc"}
+	assert.DeepEqual(t, provenance.Metadata(), map[string]string{
+		"user":       "ci",
+		"ci-job-url": "https://ci.example.com/jobs/1",
+		"git-sha":    "0123abc",
+	})
+	read := ProvenanceFromMetadata("the-hash", provenance.Metadata())
+	assert.Equal(t, read.CIJobURL, "https://ci.example.com/jobs/1")
+	assert.Equal(t, read.InputsDigest, "the-hash")
+	assert.Assert(t, ProvenanceFromMetadata("the-hash", map[string]string{"branch": "main"}) == nil)
+}
@@ -0,0 +1,250 @@
+package cache
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/cacheitem"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// MaxCacheSizeEnvVar caps the size of the filesystem cache when --max-cache-size isn't passed
+const MaxCacheSizeEnvVar = "TURBO_MAX_CACHE_SIZE"
+
+// _artifactSuffixes are the files that make up an artifact of the filesystem
+// cache, following its hash
+var _artifactSuffixes = []string{".tar.zst", ".tar", "-meta.json"}
+
+// _orphanObjectAge is how long an object that no artifact refers to is kept,
+// since another turbo could be writing the artifact that refers to it
+const _orphanObjectAge = time.Hour
+
+// GCOpts are the limits applied to the filesystem cache by CollectGarbage
+type GCOpts struct {
+	// MaxSize is the total size of the artifacts to keep, or 0 if unlimited
+	MaxSize int64
+	// MaxAge is how long an unused artifact is kept, or 0 if unlimited
+	MaxAge time.Duration
+}
+
+// GCResult is the outcome of CollectGarbage. The bytes include the objects of
+// content-addressed artifacts, which count once however many artifacts share
+// them.
+type GCResult struct {
+	Removed      int
+	RemovedBytes int64
+	Kept         int
+	KeptBytes    int64
+}
+
+// fsArtifact is an artifact of the filesystem cache and the files it is made of
+type fsArtifact struct {
+	hash     string
+	files    []string
+	size     int64
+	lastUsed time.Time
+	// objects are the files in the object store that the artifact refers to
+	objects []string
+}
+
+// fsObject is a file in the object store
+type fsObject struct {
+	size    int64
+	modTime time.Time
+}
+
+// CollectGarbage removes the artifacts of the filesystem cache in dir that
+// haven't been used for opts.MaxAge, and then the least recently used artifacts
+// while their total size exceeds opts.MaxSize. An artifact was last used when it
+// was written or restored. The objects that are no longer referred to by any
+// artifact are removed along with them.
+func CollectGarbage(dir turbopath.AbsoluteSystemPath, opts GCOpts, now time.Time) (*GCResult, error) {
+	artifacts, err := readArtifacts(dir)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := readObjects(cacheitem.ObjectsDir(dir))
+	if err != nil {
+		return nil, err
+	}
+	result := &GCResult{}
+	references := make(map[string]int, len(objects))
+	for _, artifact := range artifacts {
+		result.Kept++
+		result.KeptBytes += artifact.size
+		for _, object := range artifact.objects {
+			references[object]++
+		}
+	}
+
+	var firstErr error
+	removeObject := func(path string) {
+		object, ok := objects[path]
+		if !ok {
+			return
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return
+		}
+		delete(objects, path)
+		result.RemovedBytes += object.size
+		result.KeptBytes -= object.size
+	}
+	for _, object := range objects {
+		result.KeptBytes += object.size
+	}
+	for path, object := range objects {
+		if references[path] == 0 && now.Sub(object.modTime) > _orphanObjectAge {
+			removeObject(path)
+		}
+	}
+	// Oldest first, ties broken by hash so that the same artifacts are removed every time
+	sort.Slice(artifacts, func(i, j int) bool {
+		if !artifacts[i].lastUsed.Equal(artifacts[j].lastUsed) {
+			return artifacts[i].lastUsed.Before(artifacts[j].lastUsed)
+		}
+		return artifacts[i].hash < artifacts[j].hash
+	})
+
+	for _, artifact := range artifacts {
+		expired := opts.MaxAge > 0 && now.Sub(artifact.lastUsed) > opts.MaxAge
+		oversized := opts.MaxSize > 0 && result.KeptBytes > opts.MaxSize
+		if !expired && !oversized {
+			continue
+		}
+		if err := removeArtifact(dir, artifact); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		result.Removed++
+		result.RemovedBytes += artifact.size
+		result.Kept--
+		result.KeptBytes -= artifact.size
+		for _, object := range artifact.objects {
+			references[object]--
+			if references[object] == 0 {
+				removeObject(object)
+			}
+		}
+	}
+	return result, firstErr
+}
+
+// readArtifacts groups the files of the filesystem cache by artifact. Files that
+// don't belong to an artifact are ignored.
+func readArtifacts(dir turbopath.AbsoluteSystemPath) ([]*fsArtifact, error) {
+	entries, err := ioutil.ReadDir(dir.ToString())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	byHash := make(map[string]*fsArtifact)
+	var artifacts []*fsArtifact
+	for _, entry := range entries {
+		if !entry.Mode().IsRegular() {
+			continue
+		}
+		name := entry.Name()
+		hash := ""
+		for _, suffix := range _artifactSuffixes {
+			if strings.HasSuffix(name, suffix) {
+				hash = strings.TrimSuffix(name, suffix)
+				break
+			}
+		}
+		if hash == "" {
+			continue
+		}
+		artifact, ok := byHash[hash]
+		if !ok {
+			artifact = &fsArtifact{hash: hash}
+			byHash[hash] = artifact
+			artifacts = append(artifacts, artifact)
+		}
+		artifact.files = append(artifact.files, name)
+		artifact.size += entry.Size()
+		if entry.ModTime().After(artifact.lastUsed) {
+			artifact.lastUsed = entry.ModTime()
+		}
+		if name == hash+".tar" {
+			artifact.objects = readArtifactObjects(dir.UntypedJoin(name))
+		}
+	}
+	return artifacts, nil
+}
+
+// readArtifactObjects returns the objects that a content-addressed artifact
+// refers to. The objects of an artifact that can't be read are left for
+// CollectGarbage to remove once they are old.
+func readArtifactObjects(path turbopath.AbsoluteSystemPath) []string {
+	cacheItem, err := cacheitem.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = cacheItem.Close() }()
+	objects, err := cacheItem.Objects()
+	if err != nil {
+		return nil
+	}
+	paths := make([]string, len(objects))
+	for i, object := range objects {
+		paths[i] = object.ToString()
+	}
+	return paths
+}
+
+// readObjects returns the files of the object store, including the temporary
+// files of objects that are being written
+func readObjects(dir turbopath.AbsoluteSystemPath) (map[string]*fsObject, error) {
+	objects := make(map[string]*fsObject)
+	err := filepath.WalkDir(dir.ToString(), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			// The object was removed since the directory was read
+			return nil
+		}
+		objects[path] = &fsObject{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	return objects, err
+}
+
+// removeArtifact removes the files of an artifact, starting with its archive so
+// that a partial removal is a cache miss
+func removeArtifact(dir turbopath.AbsoluteSystemPath, artifact *fsArtifact) error {
+	sort.Slice(artifact.files, func(i, j int) bool {
+		return strings.HasSuffix(artifact.files[j], "-meta.json") && !strings.HasSuffix(artifact.files[i], "-meta.json")
+	})
+	for _, file := range artifact.files {
+		if err := dir.UntypedJoin(file).Remove(); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// touchArtifact marks an artifact as used, so that it is the last to be removed
+// by CollectGarbage
+func touchArtifact(path turbopath.AbsoluteSystemPath, now time.Time) {
+	_ = os.Chtimes(path.ToString(), now, now)
+}
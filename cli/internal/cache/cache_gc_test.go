@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/cacheitem"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func writeArtifact(t *testing.T, dir turbopath.AbsoluteSystemPath, hash string, size int, lastUsed time.Time) {
+	t.Helper()
+	for name, contents := range map[string][]byte{
+		hash + ".tar.zst":   make([]byte, size),
+		hash + "-meta.json": []byte("{}"),
+	} {
+		path := dir.UntypedJoin(name)
+		assert.NilError(t, path.WriteFile(contents, 0644), "WriteFile")
+		assert.NilError(t, os.Chtimes(path.ToString(), lastUsed, lastUsed), "Chtimes")
+	}
+}
+
+func TestCollectGarbage(t *testing.T) {
+	dir := turbopath.AbsoluteSystemPath(t.TempDir())
+	now := time.Now()
+	writeArtifact(t, dir, "oldest", 100, now.Add(-72*time.Hour))
+	writeArtifact(t, dir, "older", 100, now.Add(-48*time.Hour))
+	writeArtifact(t, dir, "newest", 100, now.Add(-time.Hour))
+	assert.NilError(t, dir.UntypedJoin("notes.txt").WriteFile(make([]byte, 1000), 0644), "WriteFile")
+
+	// Each artifact takes 102 bytes, with its metadata
+	result, err := CollectGarbage(dir, GCOpts{MaxSize: 250}, now)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, result, &GCResult{Removed: 1, RemovedBytes: 102, Kept: 2, KeptBytes: 204})
+	assert.Assert(t, !dir.UntypedJoin("oldest.tar.zst").FileExists())
+	assert.Assert(t, !dir.UntypedJoin("oldest-meta.json").FileExists())
+	assert.Assert(t, dir.UntypedJoin("older.tar.zst").FileExists())
+	assert.Assert(t, dir.UntypedJoin("notes.txt").FileExists())
+
+	result, err = CollectGarbage(dir, GCOpts{MaxAge: 24 * time.Hour}, now)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, result, &GCResult{Removed: 1, RemovedBytes: 102, Kept: 1, KeptBytes: 102})
+	assert.Assert(t, dir.UntypedJoin("newest.tar.zst").FileExists())
+
+	missing, err := CollectGarbage(dir.UntypedJoin("missing"), GCOpts{MaxSize: 1}, now)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, missing, &GCResult{})
+}
+
+func TestFetchMarksArtifactUsed(t *testing.T) {
+	src := turbopath.AbsoluteSystemPath(t.TempDir())
+	cacheDir := turbopath.AbsoluteSystemPath(t.TempDir())
+	assert.NilError(t, src.UntypedJoin("a").WriteFile([]byte("a"), 0644), "WriteFile")
+
+	cache := &fsCache{cacheDirectory: cacheDir, recorder: &dummyRecorder{}}
+	assert.NilError(t, cache.Put(src, "the-hash", 1, []turbopath.AnchoredSystemPath{"a"}), "Put")
+	lastWeek := time.Now().Add(-7 * 24 * time.Hour)
+	assert.NilError(t, os.Chtimes(cacheDir.UntypedJoin("the-hash.tar").ToString(), lastWeek, lastWeek), "Chtimes")
+	assert.NilError(t, os.Chtimes(cacheDir.UntypedJoin("the-hash-meta.json").ToString(), lastWeek, lastWeek), "Chtimes")
+
+	hit, _, _, err := cache.Fetch(turbopath.AbsoluteSystemPath(t.TempDir()), "the-hash", nil)
+	assert.NilError(t, err, "Fetch")
+	assert.Assert(t, hit)
+
+	result, err := CollectGarbage(cacheDir, GCOpts{MaxAge: 24 * time.Hour}, time.Now())
+	assert.NilError(t, err)
+	assert.Equal(t, result.Removed, 0)
+}
+
+func TestCollectGarbageObjects(t *testing.T) {
+	src := turbopath.AbsoluteSystemPath(t.TempDir())
+	cacheDir := turbopath.AbsoluteSystemPath(t.TempDir())
+	assert.NilError(t, src.UntypedJoin("shared").WriteFile(make([]byte, 100), 0644), "WriteFile")
+	assert.NilError(t, src.UntypedJoin("old").WriteFile([]byte("old"), 0644), "WriteFile")
+	assert.NilError(t, src.UntypedJoin("new").WriteFile([]byte("new"), 0644), "WriteFile")
+
+	cache := &fsCache{cacheDirectory: cacheDir, recorder: &dummyRecorder{}}
+	assert.NilError(t, cache.Put(src, "old-hash", 1, []turbopath.AnchoredSystemPath{"shared", "old"}), "Put")
+	assert.NilError(t, cache.Put(src, "new-hash", 1, []turbopath.AnchoredSystemPath{"shared", "new"}), "Put")
+	lastWeek := time.Now().Add(-7 * 24 * time.Hour)
+	for _, name := range []string{"old-hash.tar", "old-hash-meta.json"} {
+		assert.NilError(t, os.Chtimes(cacheDir.UntypedJoin(name).ToString(), lastWeek, lastWeek), "Chtimes")
+	}
+	// An object left behind by an artifact that was never written
+	orphan := cacheitem.ObjectsDir(cacheDir).UntypedJoin("ff", strings.Repeat("f", 64))
+	assert.NilError(t, orphan.EnsureDir(), "EnsureDir")
+	assert.NilError(t, orphan.WriteFile(make([]byte, 10), 0644), "WriteFile")
+	assert.NilError(t, os.Chtimes(orphan.ToString(), lastWeek, lastWeek), "Chtimes")
+
+	result, err := CollectGarbage(cacheDir, GCOpts{MaxAge: 24 * time.Hour}, time.Now())
+	assert.NilError(t, err)
+	assert.Equal(t, result.Removed, 1)
+	assert.Equal(t, result.Kept, 1)
+	// The objects of "old" and the orphan are removed, and the object of
+	// "shared" is kept for the artifact that still refers to it
+	objects, err := filepath.Glob(cacheitem.ObjectsDir(cacheDir).UntypedJoin("*", "*").ToString())
+	assert.NilError(t, err, "Glob")
+	assert.Equal(t, len(objects), 2)
+	assert.Assert(t, !orphan.FileExists())
+
+	hit, _, _, err := cache.Fetch(turbopath.AbsoluteSystemPath(t.TempDir()), "new-hash", nil)
+	assert.NilError(t, err, "Fetch")
+	assert.Assert(t, hit)
+}
@@ -5,6 +5,7 @@ package cache
 
 import (
 	"archive/tar"
+	"bufio"
 	"bytes"
 	"errors"
 	"fmt"
@@ -17,15 +18,18 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/DataDog/zstd"
-
 	"github.com/vercel/turbo/cli/internal/analytics"
+	"github.com/vercel/turbo/cli/internal/cacheitem"
+	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/tarpatch"
 	"github.com/vercel/turbo/cli/internal/turbopath"
 )
 
-type client interface {
-	PutArtifact(hash string, body []byte, duration int, tag string) error
+// RemoteClient is the API of a remote cache, such as the Vercel Remote Cache or
+// an S3-compatible object store
+type RemoteClient interface {
+	// PutArtifact uploads an artifact that was compressed with contentEncoding
+	PutArtifact(hash string, body []byte, duration int, tag string, contentEncoding string) error
 	FetchArtifact(hash string) (*http.Response, error)
 	ArtifactExists(hash string) (*http.Response, error)
 	GetTeamID() string
@@ -33,11 +37,18 @@ type client interface {
 
 type httpCache struct {
 	writable       bool
-	client         client
+	client         RemoteClient
 	requestLimiter limiter
 	recorder       analytics.Recorder
 	signerVerifier *ArtifactSignatureAuthentication
+	encryption     *ArtifactEncryption
 	repoRoot       turbopath.AbsoluteSystemPath
+	stats          *RemoteStats
+	failures       remoteFailures
+	// compression and compressionLevel are the fs.RemoteCacheOptions of uploads
+	compression      string
+	compressionLevel int
+	prefetcher       prefetcher
 }
 
 type limiter chan struct{}
@@ -62,7 +73,8 @@ func (cache *httpCache) Put(anchor turbopath.AbsoluteSystemPath, hash string, du
 	defer cache.requestLimiter.release()
 
 	r, w := io.Pipe()
-	go cache.write(w, hash, files)
+	var tarBytes int64
+	go cache.write(w, hash, files, &tarBytes)
 
 	// Read the entire artifact tar into memory so we can easily compute the signature.
 	// Note: retryablehttp.NewRequest reads the files into memory anyways so there's no
@@ -71,6 +83,15 @@ func (cache *httpCache) Put(anchor turbopath.AbsoluteSystemPath, hash string, du
 	if err != nil {
 		return fmt.Errorf("failed to store files in HTTP cache: %w", err)
 	}
+	contentEncoding := cache.compression
+	if cache.encryption.isEnabled() {
+		artifactBody, err = cache.encryption.encrypt(hash, artifactBody)
+		if err != nil {
+			return fmt.Errorf("failed to store files in HTTP cache: %w", err)
+		}
+		// The compression is recognized once the artifact is decrypted
+		contentEncoding = ""
+	}
 	tag := ""
 	if cache.signerVerifier.isEnabled() {
 		tag, err = cache.signerVerifier.generateTag(hash, artifactBody)
@@ -78,27 +99,61 @@ func (cache *httpCache) Put(anchor turbopath.AbsoluteSystemPath, hash string, du
 			return fmt.Errorf("failed to store files in HTTP cache: %w", err)
 		}
 	}
-	return cache.client.PutArtifact(hash, artifactBody, duration, tag)
+	startedAt := time.Now()
+	err = cache.client.PutArtifact(hash, artifactBody, duration, tag, contentEncoding)
+	transfer := RemoteTransfer{
+		Hash:              hash,
+		Direction:         TransferUpload,
+		Bytes:             int64(len(artifactBody)),
+		UncompressedBytes: tarBytes,
+		Compression:       cache.compression,
+		Duration:          time.Since(startedAt),
+		StatusCode:        http.StatusOK,
+	}
+	if err != nil {
+		transfer.StatusCode = errorStatusCode(err)
+	}
+	cache.stats.record(transfer)
+	if !isCacheDisabled(err) {
+		if disabledErr := cache.failures.record(transfer.StatusCode, err); disabledErr != nil {
+			return disabledErr
+		}
+	}
+	return err
 }
 
-// write writes a series of files into the given Writer.
-func (cache *httpCache) write(w io.WriteCloser, hash string, files []turbopath.AnchoredSystemPath) {
-	defer w.Close()
+// write writes a series of files into the given Writer, and sets tarBytes to
+// the size of the tar before it was compressed once the Writer is closed.
+func (cache *httpCache) write(w *io.PipeWriter, hash string, files []turbopath.AnchoredSystemPath, tarBytes *int64) {
 	defer func() { _ = w.Close() }()
-	zw := zstd.NewWriter(w)
+	zw, err := newCompressor(w, cache.compression, cache.compressionLevel)
+	if err != nil {
+		_ = w.CloseWithError(err)
+		return
+	}
 	defer func() { _ = zw.Close() }()
-	tw := tar.NewWriter(zw)
+	uncompressed := &countingWriter{Writer: zw}
+	defer func() { *tarBytes = uncompressed.count }()
+	tw := tar.NewWriter(uncompressed)
 	defer func() { _ = tw.Close() }()
+	manifest := cacheitem.NewManifest()
+	if err := manifest.WriteMarker(tw); err != nil {
+		log.Printf("[ERROR] Error uploading artifact %s to HTTP cache due to: %s", hash, err)
+		return
+	}
 	for _, file := range files {
 		// log.Printf("caching file %v", file)
-		if err := cache.storeFile(tw, file); err != nil {
+		if err := cache.storeFile(tw, manifest, file); err != nil {
 			log.Printf("[ERROR] Error uploading artifact %s to HTTP cache due to: %s", file, err)
 			// TODO(jaredpalmer): How can we cancel the request at this point?
 		}
 	}
+	if err := manifest.WriteChecksums(tw); err != nil {
+		log.Printf("[ERROR] Error uploading artifact %s to HTTP cache due to: %s", hash, err)
+	}
 }
 
-func (cache *httpCache) storeFile(tw *tar.Writer, repoRelativePath turbopath.AnchoredSystemPath) error {
+func (cache *httpCache) storeFile(tw *tar.Writer, manifest *cacheitem.Manifest, repoRelativePath turbopath.AnchoredSystemPath) error {
 	absoluteFilePath := repoRelativePath.RestoreAnchor(cache.repoRoot)
 	info, err := absoluteFilePath.Lstat()
 	if err != nil {
@@ -129,6 +184,7 @@ func (cache *httpCache) storeFile(tw *tar.Writer, repoRelativePath turbopath.Anc
 	if err := tw.WriteHeader(hdr); err != nil {
 		return err
 	} else if info.IsDir() || target != "" {
+		manifest.Add(hdr.Name)
 		return nil // nothing to write
 	}
 	f, err := absoluteFilePath.Open()
@@ -136,7 +192,7 @@ func (cache *httpCache) storeFile(tw *tar.Writer, repoRelativePath turbopath.Anc
 		return err
 	}
 	defer func() { _ = f.Close() }()
-	_, err = io.Copy(tw, f)
+	_, err = io.Copy(manifest.Writer(hdr.Name, tw), f)
 	if errors.Is(err, tar.ErrWriteTooLong) {
 		log.Printf("Error writing %v to tar file, info: %v, mode: %v, is regular: %v", repoRelativePath, info, info.Mode(), info.Mode().IsRegular())
 	}
@@ -144,9 +200,17 @@ func (cache *httpCache) storeFile(tw *tar.Writer, repoRelativePath turbopath.Anc
 }
 
 func (cache *httpCache) Fetch(anchor turbopath.AbsoluteSystemPath, key string, _unusedOutputGlobs []string) (bool, []turbopath.AnchoredSystemPath, int, error) {
-	cache.requestLimiter.acquire()
-	defer cache.requestLimiter.release()
-	hit, files, duration, err := cache.retrieve(key)
+	var hit bool
+	var files []turbopath.AnchoredSystemPath
+	var duration int
+	var err error
+	if download := cache.takePrefetch(key); download != nil {
+		hit, files, duration, err = cache.restorePrefetched(key, download)
+	} else {
+		cache.requestLimiter.acquire()
+		hit, files, duration, err = cache.retrieve(key)
+		cache.requestLimiter.release()
+	}
 	if err != nil {
 		// TODO: analytics event?
 		return false, files, duration, fmt.Errorf("failed to retrieve files from HTTP cache: %w", err)
@@ -198,70 +262,138 @@ func (cache *httpCache) exists(hash string) (bool, error) {
 }
 
 func (cache *httpCache) retrieve(hash string) (bool, []turbopath.AnchoredSystemPath, int, error) {
+	startedAt := time.Now()
+	transfer := RemoteTransfer{Hash: hash, Direction: TransferDownload}
+	body := &countingReader{}
+	defer func() {
+		transfer.Bytes = body.count
+		transfer.Duration = time.Since(startedAt)
+		cache.stats.record(transfer)
+	}()
 	resp, err := cache.client.FetchArtifact(hash)
 	if err != nil {
+		transfer.StatusCode = errorStatusCode(err)
+		if !isCacheDisabled(err) {
+			if disabledErr := cache.failures.record(transfer.StatusCode, err); disabledErr != nil {
+				return false, nil, 0, disabledErr
+			}
+		}
 		return false, nil, 0, err
 	}
 	defer resp.Body.Close()
+	transfer.StatusCode = resp.StatusCode
+	body.Reader = resp.Body
+	if disabledErr := cache.failures.record(resp.StatusCode, nil); disabledErr != nil {
+		return false, nil, 0, disabledErr
+	}
 	if resp.StatusCode == http.StatusNotFound {
 		return false, nil, 0, nil // doesn't exist - not an error
 	} else if resp.StatusCode != http.StatusOK {
 		b, _ := ioutil.ReadAll(resp.Body)
 		return false, nil, 0, fmt.Errorf("%s", string(b))
 	}
+	files, duration, err := cache.restoreArtifact(hash, resp.Header, body, &transfer)
+	if err != nil {
+		return false, nil, 0, err
+	}
+	return true, files, duration, nil
+}
+
+// restoreArtifact verifies and restores the body of a response to a request for
+// the artifact of hash, and returns the duration of the task that produced it
+func (cache *httpCache) restoreArtifact(hash string, header http.Header, body io.Reader, transfer *RemoteTransfer) ([]turbopath.AnchoredSystemPath, int, error) {
 	// If present, extract the duration from the response.
 	duration := 0
-	if resp.Header.Get("x-artifact-duration") != "" {
-		intVar, err := strconv.Atoi(resp.Header.Get("x-artifact-duration"))
+	if header.Get("x-artifact-duration") != "" {
+		intVar, err := strconv.Atoi(header.Get("x-artifact-duration"))
 		if err != nil {
-			return false, nil, 0, fmt.Errorf("invalid x-artifact-duration header: %w", err)
+			return nil, 0, fmt.Errorf("invalid x-artifact-duration header: %w", err)
 		}
 		duration = intVar
 	}
-	var tarReader io.Reader
+	var artifact io.Reader
 
-	defer func() { _ = resp.Body.Close() }()
 	if cache.signerVerifier.isEnabled() {
-		expectedTag := resp.Header.Get("x-artifact-tag")
+		expectedTag := header.Get("x-artifact-tag")
 		if expectedTag == "" {
 			// If the verifier is enabled all incoming artifact downloads must have a signature
-			return false, nil, 0, errors.New("artifact verification failed: Downloaded artifact is missing required x-artifact-tag header")
+			return nil, 0, errors.New("artifact verification failed: Downloaded artifact is missing required x-artifact-tag header")
 		}
-		b, err := ioutil.ReadAll(resp.Body)
+		b, err := ioutil.ReadAll(body)
 		if err != nil {
-			return false, nil, 0, fmt.Errorf("artifact verification failed: %w", err)
+			return nil, 0, fmt.Errorf("artifact verification failed: %w", err)
 		}
 		isValid, err := cache.signerVerifier.validate(hash, b, expectedTag)
 		if err != nil {
-			return false, nil, 0, fmt.Errorf("artifact verification failed: %w", err)
+			return nil, 0, fmt.Errorf("artifact verification failed: %w", err)
 		}
 		if !isValid {
 			err = fmt.Errorf("artifact verification failed: artifact tag does not match expected tag %s", expectedTag)
-			return false, nil, 0, err
+			return nil, 0, err
 		}
 		// The artifact has been verified and the body can be read and untarred
-		tarReader = bytes.NewReader(b)
+		artifact = bytes.NewReader(b)
 	} else {
-		tarReader = resp.Body
+		artifact = body
 	}
+	contentEncoding := header.Get("Content-Encoding")
+	artifact, encrypted, err := cache.decryptArtifact(hash, artifact)
+	if err != nil {
+		return nil, 0, err
+	}
+	if encrypted {
+		// The encoding of encrypted artifacts is recognized from their first
+		// bytes once they are decrypted
+		contentEncoding = ""
+	}
+	zr, compression, err := newDecompressor(artifact, contentEncoding)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = zr.Close() }()
+	transfer.Compression = compression
+	tarReader := &countingReader{Reader: zr}
 	files, err := restoreTar(cache.repoRoot, tarReader)
+	transfer.UncompressedBytes = tarReader.count
 	if err != nil {
-		return false, nil, 0, err
+		return nil, 0, err
 	}
-	return true, files, duration, nil
+	return files, duration, nil
+}
+
+// decryptArtifact decrypts an artifact that was encrypted before it was
+// uploaded. Artifacts that weren't are rejected once encryption is enabled.
+func (cache *httpCache) decryptArtifact(hash string, artifact io.Reader) (io.Reader, bool, error) {
+	buffered := bufio.NewReader(artifact)
+	magic, _ := buffered.Peek(len(_encryptedArtifactMagic))
+	if !isEncrypted(magic) {
+		if cache.encryption.isEnabled() {
+			return nil, false, errUnencryptedArtifact
+		}
+		return buffered, false, nil
+	}
+	b, err := ioutil.ReadAll(buffered)
+	if err != nil {
+		return nil, true, fmt.Errorf("artifact decryption failed: %w", err)
+	}
+	plaintext, err := cache.encryption.decrypt(hash, b)
+	if err != nil {
+		return nil, true, err
+	}
+	return bytes.NewReader(plaintext), true, nil
 }
 
-// restoreTar returns posix-style repo-relative paths of the files it
-// restored. In the future, these should likely be repo-relative system paths
-// so that they are suitable for being fed into cache.Put for other caches.
+// restoreTar restores the files in an uncompressed tar, and returns their
+// posix-style repo-relative paths. In the future, these should likely be
+// repo-relative system paths so that they are suitable for being fed into
+// cache.Put for other caches.
 // For now, I think this is working because windows also accepts /-delimited paths.
 func restoreTar(root turbopath.AbsoluteSystemPath, reader io.Reader) ([]turbopath.AnchoredSystemPath, error) {
 	files := []turbopath.AnchoredSystemPath{}
 	missingLinks := []*tar.Header{}
-	zr := zstd.NewReader(reader)
-	var closeError error
-	defer func() { closeError = zr.Close() }()
-	tr := tar.NewReader(zr)
+	manifest := cacheitem.NewManifest()
+	modes := cacheitem.NewModes(manifest)
+	tr := tar.NewReader(manifest.Archive(reader))
 	for {
 		hdr, err := tr.Next()
 		if err != nil {
@@ -272,10 +404,22 @@ func restoreTar(root turbopath.AbsoluteSystemPath, reader io.Reader) ([]turbopat
 						return nil, err
 					}
 				}
+				if err := modes.Finish(); err != nil {
+					return nil, err
+				}
+				if err := manifest.Verify(); err != nil {
+					return nil, err
+				}
 
-				return files, closeError
+				return files, nil
 			}
-			return nil, err
+			return nil, fmt.Errorf("%w: %v", cacheitem.ErrCorrupted, err)
+		}
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			if err := manifest.ReadHeader(hdr); err != nil {
+				return nil, err
+			}
+			continue
 		}
 		// hdr.Name is always a posix-style path
 		// FIXME: THIS IS A BUG.
@@ -289,23 +433,26 @@ func restoreTar(root turbopath.AbsoluteSystemPath, reader io.Reader) ([]turbopat
 		}
 		switch hdr.Typeflag {
 		case tar.TypeDir:
+			manifest.Add(hdr.Name)
 			if err := filename.MkdirAll(0775); err != nil {
 				return nil, err
 			}
+			if err := modes.Set(filename, hdr); err != nil {
+				return nil, err
+			}
 		case tar.TypeReg:
 			if dir := filename.Dir(); dir != "." {
 				if err := dir.MkdirAll(0775); err != nil {
 					return nil, err
 				}
 			}
-			if f, err := filename.OpenFile(os.O_WRONLY|os.O_TRUNC|os.O_CREATE, os.FileMode(hdr.Mode)); err != nil {
-				return nil, err
-			} else if _, err := io.Copy(f, tr); err != nil {
+			if _, err := cacheitem.RestoreContents(filename, os.FileMode(hdr.Mode), hdr.Size, manifest.Reader(hdr.Name, tr)); err != nil {
 				return nil, err
-			} else if err := f.Close(); err != nil {
+			} else if err := modes.Set(filename, hdr); err != nil {
 				return nil, err
 			}
 		case tar.TypeSymlink:
+			manifest.Add(hdr.Name)
 			if err := restoreSymlink(root, hdr, false); errors.Is(err, errNonexistentLinkTarget) {
 				missingLinks = append(missingLinks, hdr)
 			} else if err != nil {
@@ -357,19 +504,33 @@ func (cache *httpCache) CleanAll() {
 	// Also not possible.
 }
 
-func (cache *httpCache) Shutdown() {}
+func (cache *httpCache) Shutdown() {
+	cache.closePrefetches()
+}
 
-func newHTTPCache(opts Opts, client client, recorder analytics.Recorder) *httpCache {
+func newHTTPCache(opts Opts, client RemoteClient, recorder analytics.Recorder) *httpCache {
+	compression := opts.RemoteCacheOpts.Compression
+	if compression == "" {
+		compression = fs.CompressionZstd
+	}
+	if opts.Scope != "" {
+		client = newScopedClient(client, opts.Scope)
+	}
 	return &httpCache{
-		writable:       true,
-		client:         client,
-		requestLimiter: make(limiter, 20),
-		recorder:       recorder,
+		writable:         true,
+		client:           client,
+		requestLimiter:   make(limiter, 20),
+		recorder:         recorder,
+		stats:            opts.RemoteStats,
+		compression:      compression,
+		compressionLevel: opts.RemoteCacheOpts.CompressionLevel,
 		signerVerifier: &ArtifactSignatureAuthentication{
 			// TODO(Gaspar): this should use RemoteCacheOptions.TeamId once we start
 			// enforcing team restrictions for repositories.
-			teamId:  client.GetTeamID(),
-			enabled: opts.RemoteCacheOpts.Signature,
+			teamId:    client.GetTeamID(),
+			enabled:   opts.RemoteCacheOpts.Signature,
+			algorithm: opts.RemoteCacheOpts.SignatureAlgorithm,
 		},
+		encryption: &ArtifactEncryption{enabled: opts.RemoteCacheOpts.Encryption},
 	}
 }
@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// _maxConsecutiveRemoteFailures is how many requests to the remote cache can
+// fail in a row before the rest of the run stops using it, so that an
+// unreachable cache or a flaky proxy doesn't slow down every task
+const _maxConsecutiveRemoteFailures = 3
+
+// remoteFailures counts the requests to the remote cache that failed in a row
+type remoteFailures struct {
+	consecutive int32
+}
+
+// record counts a request to the remote cache that failed if err is set or
+// statusCode is a server error, and resets the count otherwise. Once
+// _maxConsecutiveRemoteFailures requests have failed in a row, it returns a
+// CacheDisabledError, which makes the multiplexer remove the remote cache.
+func (f *remoteFailures) record(statusCode int, err error) error {
+	if err == nil && statusCode < http.StatusInternalServerError {
+		atomic.StoreInt32(&f.consecutive, 0)
+		return nil
+	}
+	if atomic.AddInt32(&f.consecutive, 1) < _maxConsecutiveRemoteFailures {
+		return nil
+	}
+	return &util.CacheDisabledError{
+		Status:  util.CachingStatusDisabled,
+		Message: fmt.Sprintf("%v requests to the remote cache failed in a row", _maxConsecutiveRemoteFailures),
+	}
+}
+
+// isCacheDisabled is whether err is a CacheDisabledError, which removes the
+// remote cache already
+func isCacheDisabled(err error) bool {
+	cd := &util.CacheDisabledError{}
+	return errors.As(err, &cd)
+}
@@ -0,0 +1,239 @@
+package cache
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// _prefetchWorkers bounds the downloads started by Prefetch, which leaves room in
+// the request limiter for the tasks that fetch artifacts that weren't prefetched
+const _prefetchWorkers = 8
+
+// prefetch is the download of an artifact ahead of the task that restores it
+type prefetch struct {
+	// started is set once the download is taken by a worker. It is guarded by
+	// prefetcher.mu, and the other fields are set before done is closed.
+	started bool
+	done    chan struct{}
+	// found is false if the remote cache doesn't have the artifact
+	found bool
+	// file holds the body of the response, and header its headers
+	file     turbopath.AbsoluteSystemPath
+	header   http.Header
+	transfer RemoteTransfer
+	err      error
+}
+
+// prefetcher downloads artifacts of the remote cache into temporary files in
+// the order they are queued, so that the network time overlaps with tasks that
+// run in the meantime
+type prefetcher struct {
+	mu        sync.Mutex
+	downloads map[string]*prefetch
+	queue     []string
+	workers   int
+	dir       turbopath.AbsoluteSystemPath
+	closed    bool
+}
+
+// Prefetch queues the downloads of the artifacts for hashes, in order. Fetch
+// then restores a prefetched artifact without waiting on the network, or takes
+// over its download if it hasn't started yet.
+func (cache *httpCache) Prefetch(hashes []string) {
+	p := &cache.prefetcher
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	if p.downloads == nil {
+		p.downloads = make(map[string]*prefetch)
+	}
+	for _, hash := range hashes {
+		if _, ok := p.downloads[hash]; ok {
+			continue
+		}
+		p.downloads[hash] = &prefetch{done: make(chan struct{})}
+		p.queue = append(p.queue, hash)
+	}
+	for p.workers < _prefetchWorkers && p.workers < len(p.queue) {
+		p.workers++
+		go cache.prefetchWorker()
+	}
+}
+
+// prefetchWorker downloads the queued artifacts until the queue is empty
+func (cache *httpCache) prefetchWorker() {
+	p := &cache.prefetcher
+	for {
+		p.mu.Lock()
+		var hash string
+		var download *prefetch
+		for len(p.queue) > 0 && download == nil {
+			hash, p.queue = p.queue[0], p.queue[1:]
+			if d, ok := p.downloads[hash]; ok && !d.started {
+				download = d
+			}
+		}
+		if download == nil || p.closed {
+			p.workers--
+			p.mu.Unlock()
+			return
+		}
+		download.started = true
+		p.mu.Unlock()
+
+		cache.download(hash, download)
+	}
+}
+
+// download fetches the artifact for hash into a temporary file
+func (cache *httpCache) download(hash string, download *prefetch) {
+	defer close(download.done)
+	cache.requestLimiter.acquire()
+	defer cache.requestLimiter.release()
+
+	startedAt := time.Now()
+	download.transfer = RemoteTransfer{Hash: hash, Direction: TransferDownload}
+	body := &countingReader{}
+	defer func() {
+		download.transfer.Bytes = body.count
+		download.transfer.Duration = time.Since(startedAt)
+		if !download.found {
+			// Artifacts that were found are recorded once they are restored
+			cache.stats.record(download.transfer)
+		}
+	}()
+	resp, err := cache.client.FetchArtifact(hash)
+	if err != nil {
+		download.transfer.StatusCode = errorStatusCode(err)
+		download.err = err
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+	download.transfer.StatusCode = resp.StatusCode
+	body.Reader = resp.Body
+	if resp.StatusCode == http.StatusNotFound {
+		return
+	} else if resp.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(resp.Body)
+		download.err = fmt.Errorf("%s", string(b))
+		return
+	}
+
+	dir, err := cache.prefetchDir()
+	if err != nil {
+		download.err = err
+		return
+	}
+	file, err := ioutil.TempFile(dir.ToString(), hash)
+	if err != nil {
+		download.err = err
+		return
+	}
+	_, err = io.Copy(file, body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		download.err = err
+		return
+	}
+	download.file = turbopath.AbsoluteSystemPath(file.Name())
+	download.header = resp.Header
+	download.found = true
+}
+
+// prefetchDir returns the temporary directory of the downloads, creating it first
+func (cache *httpCache) prefetchDir() (turbopath.AbsoluteSystemPath, error) {
+	p := &cache.prefetcher
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.dir == "" {
+		dir, err := ioutil.TempDir("", "turbo-prefetch")
+		if err != nil {
+			return "", err
+		}
+		p.dir = turbopath.AbsoluteSystemPath(dir)
+	}
+	return p.dir, nil
+}
+
+// takePrefetch returns the download of the artifact for hash once it finishes,
+// or nil if it wasn't prefetched or its download hasn't started, in which case
+// the caller fetches it
+func (cache *httpCache) takePrefetch(hash string) *prefetch {
+	p := &cache.prefetcher
+	p.mu.Lock()
+	download, ok := p.downloads[hash]
+	if ok {
+		delete(p.downloads, hash)
+	}
+	p.mu.Unlock()
+	if !ok || !download.started {
+		return nil
+	}
+	<-download.done
+	if download.err != nil {
+		// The artifact is downloaded again, in case the error was transient
+		return nil
+	}
+	return download
+}
+
+// restorePrefetched restores the downloaded artifact for hash
+func (cache *httpCache) restorePrefetched(hash string, download *prefetch) (bool, []turbopath.AnchoredSystemPath, int, error) {
+	if !download.found {
+		return false, nil, 0, nil
+	}
+	defer func() {
+		cache.stats.record(download.transfer)
+		_ = download.file.Remove()
+	}()
+	file, err := download.file.Open()
+	if err != nil {
+		return false, nil, 0, err
+	}
+	defer func() { _ = file.Close() }()
+	files, duration, err := cache.restoreArtifact(hash, download.header, file, &download.transfer)
+	if err != nil {
+		return false, nil, 0, err
+	}
+	return true, files, duration, nil
+}
+
+// closePrefetches stops the downloads that haven't started, and removes the
+// artifacts that were downloaded but never restored
+func (cache *httpCache) closePrefetches() {
+	p := &cache.prefetcher
+	p.mu.Lock()
+	p.closed = true
+	downloads := p.downloads
+	p.downloads = nil
+	p.queue = nil
+	p.mu.Unlock()
+	for _, download := range downloads {
+		if !download.started {
+			continue
+		}
+		<-download.done
+		if download.found {
+			cache.stats.record(download.transfer)
+		}
+	}
+	// The directory is read once the downloads finish, since they create it
+	p.mu.Lock()
+	dir := p.dir
+	p.mu.Unlock()
+	if dir != "" {
+		_ = dir.RemoveAll()
+	}
+}
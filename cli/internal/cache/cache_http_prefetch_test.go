@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+// countingResp counts the requests for each artifact
+type countingResp struct {
+	artifactResp
+	mu      sync.Mutex
+	fetches map[string]int
+}
+
+func (sr *countingResp) FetchArtifact(hash string) (*http.Response, error) {
+	sr.mu.Lock()
+	sr.fetches[hash]++
+	sr.mu.Unlock()
+	return sr.artifactResp.FetchArtifact(hash)
+}
+
+func TestPrefetch(t *testing.T) {
+	root := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	client := &countingResp{
+		artifactResp: artifactResp{hash: "hit", artifact: makeValidTar(t).Bytes()},
+		fetches:      map[string]int{},
+	}
+	stats := NewRemoteStats()
+	cache := newHTTPCache(Opts{RemoteStats: stats}, client, &nullRecorder{})
+	cache.repoRoot = root
+
+	cache.Prefetch([]string{"hit", "miss", "unused"})
+	hit, files, _, err := cache.Fetch(root, "hit", nil)
+	assert.NilError(t, err)
+	assert.Assert(t, hit)
+	assert.Assert(t, len(files) > 0)
+	assert.Assert(t, root.UntypedJoin("my-pkg", "some-file").FileExists())
+	hit, _, _, err = cache.Fetch(root, "miss", nil)
+	assert.NilError(t, err)
+	assert.Assert(t, !hit)
+	cache.Shutdown()
+
+	// Each artifact is requested once, whether its download started before
+	// Fetch or not
+	assert.Equal(t, client.fetches["hit"], 1)
+	assert.Equal(t, client.fetches["miss"], 1)
+	assert.Assert(t, client.fetches["unused"] <= 1)
+	for _, transfer := range stats.Transfers() {
+		if transfer.Hash == "hit" {
+			assert.Equal(t, transfer.Compression, fs.CompressionZstd)
+		}
+	}
+
+	cache.prefetcher.mu.Lock()
+	dir := cache.prefetcher.dir
+	cache.prefetcher.mu.Unlock()
+	assert.Assert(t, dir == "" || !dir.DirExists(), "the downloads are removed on shutdown")
+	cache.Prefetch([]string{"hit"})
+	assert.Equal(t, len(cache.prefetcher.queue), 0, "nothing is prefetched after shutdown")
+}
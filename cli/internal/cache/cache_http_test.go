@@ -4,11 +4,16 @@ import (
 	"archive/tar"
 	"bytes"
 	"errors"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"os"
+	"runtime"
 	"testing"
 
 	"github.com/DataDog/zstd"
 
+	"github.com/vercel/turbo/cli/internal/cacheitem"
 	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/turbopath"
 	"github.com/vercel/turbo/cli/internal/util"
@@ -19,7 +24,7 @@ type errorResp struct {
 	err error
 }
 
-func (sr *errorResp) PutArtifact(hash string, body []byte, duration int, tag string) error {
+func (sr *errorResp) PutArtifact(hash string, body []byte, duration int, tag string, contentEncoding string) error {
 	return sr.err
 }
 
@@ -55,6 +60,92 @@ func TestRemoteCachingDisabled(t *testing.T) {
 	}
 }
 
+func TestRemoteFailuresDisableCache(t *testing.T) {
+	root := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	cache := newHTTPCache(Opts{}, &errorResp{err: errors.New("proxy timed out")}, &nullRecorder{})
+	cache.repoRoot = root
+	cd := &util.CacheDisabledError{}
+	for i := 1; i < _maxConsecutiveRemoteFailures; i++ {
+		_, _, _, err := cache.Fetch(root, "some-hash", nil)
+		assert.ErrorContains(t, err, "proxy timed out")
+		assert.Assert(t, !errors.As(err, &cd))
+	}
+	err := cache.Put(root, "some-hash", 0, nil)
+	assert.Assert(t, errors.As(err, &cd), "got %v", err)
+	assert.Equal(t, cd.Message, "3 requests to the remote cache failed in a row")
+
+	// Failures are only counted until a request gets a response
+	failures := &remoteFailures{}
+	assert.NilError(t, failures.record(0, errors.New("timeout")))
+	assert.NilError(t, failures.record(http.StatusBadGateway, nil))
+	assert.NilError(t, failures.record(http.StatusNotFound, nil))
+	assert.NilError(t, failures.record(0, errors.New("timeout")))
+	assert.NilError(t, failures.record(0, errors.New("timeout")))
+	assert.Assert(t, failures.record(0, errors.New("timeout")) != nil)
+}
+
+// artifactResp serves one artifact, and fails to store any
+type artifactResp struct {
+	hash     string
+	artifact []byte
+}
+
+type statusErr int
+
+func (e statusErr) Error() string       { return http.StatusText(int(e)) }
+func (e statusErr) HTTPStatusCode() int { return int(e) }
+
+func (sr *artifactResp) PutArtifact(hash string, body []byte, duration int, tag string, contentEncoding string) error {
+	return statusErr(http.StatusInternalServerError)
+}
+
+func (sr *artifactResp) FetchArtifact(hash string) (*http.Response, error) {
+	if hash != sr.hash {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: ioutil.NopCloser(&bytes.Buffer{})}, nil
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(sr.artifact))}, nil
+}
+
+func (sr *artifactResp) ArtifactExists(hash string) (*http.Response, error) {
+	panic("unimplemented")
+}
+
+func (sr *artifactResp) GetTeamID() string {
+	return ""
+}
+
+func TestRemoteStats(t *testing.T) {
+	root := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	artifact := makeValidTar(t).Bytes()
+	stats := NewRemoteStats()
+	cache := newHTTPCache(Opts{RemoteStats: stats}, &artifactResp{hash: "hit", artifact: artifact}, &nullRecorder{})
+	cache.repoRoot = root
+
+	hit, _, _, err := cache.Fetch(root, "hit", nil)
+	assert.NilError(t, err)
+	assert.Assert(t, hit)
+	hit, _, _, err = cache.Fetch(root, "miss", nil)
+	assert.NilError(t, err)
+	assert.Assert(t, !hit)
+	err = cache.Put(root, "miss", 100, []turbopath.AnchoredSystemPath{"extra-file"})
+	assert.ErrorContains(t, err, "Internal Server Error")
+
+	transfers := stats.Transfers()
+	assert.Equal(t, len(transfers), 3)
+	assert.Equal(t, transfers[0].Direction, TransferDownload)
+	assert.Equal(t, transfers[0].StatusCode, http.StatusOK)
+	assert.Equal(t, transfers[0].Bytes, int64(len(artifact)))
+	assert.Equal(t, transfers[0].Compression, fs.CompressionZstd)
+	assert.Assert(t, transfers[0].UncompressedBytes > 0)
+	assert.Equal(t, transfers[1].StatusCode, http.StatusNotFound)
+	assert.Equal(t, transfers[1].Bytes, int64(0))
+	assert.Equal(t, transfers[2].Direction, TransferUpload)
+	assert.Equal(t, transfers[2].Hash, "miss")
+	assert.Equal(t, transfers[2].StatusCode, http.StatusInternalServerError)
+	assert.Assert(t, transfers[2].Bytes > 0)
+	assert.Equal(t, transfers[2].Compression, fs.CompressionZstd)
+}
+
 func makeValidTar(t *testing.T) *bytes.Buffer {
 	// <repoRoot>
 	//   my-pkg/
@@ -187,7 +278,7 @@ func TestRestoreTar(t *testing.T) {
 		turbopath.AnchoredUnixPath("my-pkg/link-to-extra-file").ToSystemPath(),
 		turbopath.AnchoredUnixPath("my-pkg/broken-link").ToSystemPath(),
 	}
-	files, err := restoreTar(root, tar)
+	files, err := restoreTar(root, zstd.NewReader(tar))
 	assert.NilError(t, err, "readTar")
 
 	expectedSet := make(util.Set)
@@ -230,7 +321,7 @@ func TestRestoreInvalidTar(t *testing.T) {
 	// use a child directory so that blindly untarring will squash the file
 	// that we just wrote above.
 	repoRoot := root.UntypedJoin("repo")
-	_, err = restoreTar(repoRoot, tar)
+	_, err = restoreTar(repoRoot, zstd.NewReader(tar))
 	if err == nil {
 		t.Error("expected error untarring invalid tar")
 	}
@@ -240,6 +331,82 @@ func TestRestoreInvalidTar(t *testing.T) {
 	assert.Equal(t, string(contents), string(expectedContents), "expected to not overwrite file")
 }
 
+func TestRestoreTarManifest(t *testing.T) {
+	src := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	assert.NilError(t, src.UntypedJoin("my-pkg").MkdirAll(0755), "MkdirAll")
+	assert.NilError(t, src.UntypedJoin("my-pkg", "some-file").WriteFile([]byte("some-file-contents"), 0644), "WriteFile")
+	files := []turbopath.AnchoredSystemPath{
+		turbopath.AnchoredUnixPath("my-pkg").ToSystemPath(),
+		turbopath.AnchoredUnixPath("my-pkg/some-file").ToSystemPath(),
+	}
+
+	cache := &httpCache{repoRoot: src}
+	r, w := io.Pipe()
+	var tarBytes int64
+	go cache.write(w, "the-hash", files, &tarBytes)
+	artifact, err := ioutil.ReadAll(r)
+	assert.NilError(t, err, "ReadAll")
+	assert.Assert(t, tarBytes > int64(len("some-file-contents")))
+
+	restored, err := restoreTar(fs.AbsoluteSystemPathFromUpstream(t.TempDir()), zstd.NewReader(bytes.NewReader(artifact)))
+	assert.NilError(t, err, "restoreTar")
+	assert.Equal(t, len(restored), 2)
+
+	_, err = restoreTar(fs.AbsoluteSystemPathFromUpstream(t.TempDir()), zstd.NewReader(bytes.NewReader(artifact[:len(artifact)/2])))
+	assert.ErrorIs(t, err, cacheitem.ErrCorrupted)
+}
+
+func TestRestoreTarModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows has no executable bit")
+	}
+	src := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	bin := src.UntypedJoin("node_modules", ".pnpm", "pkg@1.0.0", "node_modules", "pkg", "bin")
+	assert.NilError(t, bin.MkdirAll(0755), "MkdirAll")
+	assert.NilError(t, bin.UntypedJoin("cli.js").WriteFile([]byte("#!/usr/bin/env node"), 0755), "WriteFile")
+	assert.NilError(t, src.UntypedJoin("node_modules", "empty").MkdirAll(0700), "MkdirAll")
+	assert.NilError(t, src.UntypedJoin("node_modules", "pkg").Symlink(".pnpm/pkg@1.0.0/node_modules/pkg"), "Symlink")
+	files := []turbopath.AnchoredSystemPath{}
+	for _, file := range []string{
+		"node_modules",
+		"node_modules/.pnpm",
+		"node_modules/.pnpm/pkg@1.0.0",
+		"node_modules/.pnpm/pkg@1.0.0/node_modules",
+		"node_modules/.pnpm/pkg@1.0.0/node_modules/pkg",
+		"node_modules/.pnpm/pkg@1.0.0/node_modules/pkg/bin",
+		"node_modules/.pnpm/pkg@1.0.0/node_modules/pkg/bin/cli.js",
+		"node_modules/empty",
+		"node_modules/pkg",
+	} {
+		files = append(files, turbopath.AnchoredUnixPath(file).ToSystemPath())
+	}
+
+	cache := &httpCache{repoRoot: src}
+	r, w := io.Pipe()
+	var tarBytes int64
+	go cache.write(w, "the-hash", files, &tarBytes)
+	artifact, err := ioutil.ReadAll(r)
+	assert.NilError(t, err, "ReadAll")
+
+	// The CLI exists without its executable bit, which restoring it brings back
+	root := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	cli := root.UntypedJoin("node_modules", "pkg", "bin", "cli.js")
+	assert.NilError(t, root.UntypedJoin("node_modules", ".pnpm", "pkg@1.0.0", "node_modules", "pkg", "bin").MkdirAll(0755), "MkdirAll")
+	assert.NilError(t, root.UntypedJoin("node_modules", ".pnpm", "pkg@1.0.0", "node_modules", "pkg", "bin", "cli.js").WriteFile([]byte("stale"), 0644), "WriteFile")
+	_, err = restoreTar(root, zstd.NewReader(bytes.NewReader(artifact)))
+	assert.NilError(t, err, "restoreTar")
+
+	info, err := cli.Stat()
+	assert.NilError(t, err, "Stat")
+	assert.Equal(t, info.Mode().Perm(), os.FileMode(0755))
+	info, err = root.UntypedJoin("node_modules", "empty").Lstat()
+	assert.NilError(t, err, "Lstat")
+	assert.Equal(t, info.Mode(), os.ModeDir|0700)
+	target, err := root.UntypedJoin("node_modules", "pkg").Readlink()
+	assert.NilError(t, err, "Readlink")
+	assert.Equal(t, target, ".pnpm/pkg@1.0.0/node_modules/pkg")
+}
+
 // Note that testing Put will require mocking the filesystem and is not currently the most
 // interesting test. The current implementation directly returns the error from PutArtifact.
 // We should still add the test once feasible to avoid future breakage.
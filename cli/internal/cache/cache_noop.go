@@ -18,6 +18,7 @@ func (c *noopCache) Exists(key string) ItemStatus {
 	return ItemStatus{}
 }
 
+func (c *noopCache) Prefetch(hashes []string)                  {}
 func (c *noopCache) Clean(anchor turbopath.AbsoluteSystemPath) {}
 func (c *noopCache) CleanAll()                                 {}
 func (c *noopCache) Shutdown()                                 {}
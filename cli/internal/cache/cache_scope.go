@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// CacheScopeEnvVar sets the scope of the remote cache when --cache-scope isn't passed
+const CacheScopeEnvVar = "TURBO_CACHE_SCOPE"
+
+// _maxScopeLength keeps the keys of scoped artifacts within the 128 characters
+// that remote caches accept for a hash
+const _maxScopeLength = 64
+
+// _invalidScopeChars are the runs of characters that can't be part of the key
+// of an artifact, such as the slashes of a branch name
+var _invalidScopeChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// NormalizeScope returns the form of scope that is used in the keys of
+// artifacts, such as "feature-login" for "feature/login"
+func NormalizeScope(scope string) (string, error) {
+	normalized := strings.Trim(_invalidScopeChars.ReplaceAllString(scope, "-"), "-")
+	if normalized == "" {
+		return "", fmt.Errorf("%q has no letters or digits", scope)
+	}
+	if len(normalized) > _maxScopeLength {
+		return "", fmt.Errorf("%q is longer than %v characters", scope, _maxScopeLength)
+	}
+	return normalized, nil
+}
+
+// scopedClient isolates the artifacts that a run writes to the remote cache in
+// a namespace of their own. Reads check the namespace first, and then fall back
+// to the artifacts that were uploaded without a scope, so that a feature branch
+// can use the cache of main without its outputs ever being read by main.
+type scopedClient struct {
+	RemoteClient
+	scope string
+}
+
+var _ RemoteClient = (*scopedClient)(nil)
+
+func newScopedClient(client RemoteClient, scope string) *scopedClient {
+	return &scopedClient{RemoteClient: client, scope: scope}
+}
+
+// key returns the key of the artifact of hash in the namespace of the scope
+func (c *scopedClient) key(hash string) string {
+	return c.scope + "-" + hash
+}
+
+// PutArtifact uploads the artifact to the namespace of the scope. The tag is
+// computed by the caller from hash, so signatures hold for either key.
+func (c *scopedClient) PutArtifact(hash string, body []byte, duration int, tag string, contentEncoding string) error {
+	return c.RemoteClient.PutArtifact(c.key(hash), body, duration, tag, contentEncoding)
+}
+
+// FetchArtifact downloads the artifact of the scope, or the shared one if the
+// scope doesn't have it
+func (c *scopedClient) FetchArtifact(hash string) (*http.Response, error) {
+	return c.withFallback(hash, c.RemoteClient.FetchArtifact)
+}
+
+// ArtifactExists checks the scope, and then the shared artifacts
+func (c *scopedClient) ArtifactExists(hash string) (*http.Response, error) {
+	return c.withFallback(hash, c.RemoteClient.ArtifactExists)
+}
+
+func (c *scopedClient) withFallback(hash string, request func(hash string) (*http.Response, error)) (*http.Response, error) {
+	resp, err := request(c.key(hash))
+	if err != nil || resp.StatusCode != http.StatusNotFound {
+		return resp, err
+	}
+	_ = resp.Body.Close()
+	return request(hash)
+}
@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+// mapResp stores artifacts in memory by their key
+type mapResp struct {
+	artifacts map[string][]byte
+	requests  []string
+}
+
+func (sr *mapResp) PutArtifact(hash string, body []byte, duration int, tag string, contentEncoding string) error {
+	sr.artifacts[hash] = body
+	return nil
+}
+
+func (sr *mapResp) FetchArtifact(hash string) (*http.Response, error) {
+	sr.requests = append(sr.requests, hash)
+	artifact, ok := sr.artifacts[hash]
+	if !ok {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: ioutil.NopCloser(&bytes.Buffer{})}, nil
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewReader(artifact))}, nil
+}
+
+func (sr *mapResp) ArtifactExists(hash string) (*http.Response, error) {
+	return sr.FetchArtifact(hash)
+}
+
+func (sr *mapResp) GetTeamID() string {
+	return ""
+}
+
+func TestNormalizeScope(t *testing.T) {
+	scope, err := NormalizeScope("feature/login")
+	assert.NilError(t, err)
+	assert.Equal(t, scope, "feature-login")
+	scope, err = NormalizeScope("/users/@me/")
+	assert.NilError(t, err)
+	assert.Equal(t, scope, "users-me")
+
+	_, err = NormalizeScope("//")
+	assert.ErrorContains(t, err, "no letters or digits")
+	_, err = NormalizeScope(string(bytes.Repeat([]byte("a"), _maxScopeLength+1)))
+	assert.ErrorContains(t, err, "longer than")
+}
+
+func TestScopedCache(t *testing.T) {
+	root := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	artifact := makeValidTar(t).Bytes()
+	client := &mapResp{artifacts: map[string][]byte{"shared": artifact}}
+	cache := newHTTPCache(Opts{Scope: "feature-login"}, client, &nullRecorder{})
+	cache.repoRoot = root
+
+	// The shared artifact is read once the scope misses
+	hit, _, _, err := cache.Fetch(root, "shared", nil)
+	assert.NilError(t, err)
+	assert.Assert(t, hit)
+	assert.DeepEqual(t, client.requests, []string{"feature-login-shared", "shared"})
+	assert.Assert(t, cache.Exists("shared").Remote)
+
+	// Uploads only go to the scope
+	assert.NilError(t, cache.Put(root, "scoped", 0, nil))
+	_, ok := client.artifacts["feature-login-scoped"]
+	assert.Assert(t, ok)
+	_, ok = client.artifacts["scoped"]
+	assert.Assert(t, !ok)
+	assert.Assert(t, cache.Exists("scoped").Remote)
+	assert.Assert(t, !cache.Exists("missing").Remote)
+}
@@ -4,19 +4,27 @@
 package cache
 
 import (
+	"bytes"
+	"crypto/ed25519"
 	"crypto/hmac"
 	"crypto/sha256"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"hash"
 	"os"
+
+	"github.com/vercel/turbo/cli/internal/fs"
 )
 
 type ArtifactSignatureAuthentication struct {
 	teamId  string
 	enabled bool
+	// algorithm is fs.SignatureHMACSHA256 when empty
+	algorithm string
 }
 
 func (asa *ArtifactSignatureAuthentication) isEnabled() bool {
@@ -33,7 +41,102 @@ func (asa *ArtifactSignatureAuthentication) secretKey() ([]byte, error) {
 	return []byte(secret), nil
 }
 
+// privateKey reads the PEM-encoded PKCS #8 key that signs uploaded artifacts
+// with the ed25519 algorithm
+func (asa *ArtifactSignatureAuthentication) privateKey() (ed25519.PrivateKey, error) {
+	block, err := readPEMKey("TURBO_REMOTE_CACHE_SIGNATURE_PRIVATE_KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TURBO_REMOTE_CACHE_SIGNATURE_PRIVATE_KEY: %w", err)
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("invalid TURBO_REMOTE_CACHE_SIGNATURE_PRIVATE_KEY: not an ed25519 key")
+	}
+	return privateKey, nil
+}
+
+// publicKey reads the PEM-encoded PKIX key that verifies downloaded artifacts
+// with the ed25519 algorithm. Machines that also upload artifacts may only set
+// the private key.
+func (asa *ArtifactSignatureAuthentication) publicKey() (ed25519.PublicKey, error) {
+	if os.Getenv("TURBO_REMOTE_CACHE_SIGNATURE_PUBLIC_KEY") == "" && os.Getenv("TURBO_REMOTE_CACHE_SIGNATURE_PRIVATE_KEY") != "" {
+		privateKey, err := asa.privateKey()
+		if err != nil {
+			return nil, err
+		}
+		return privateKey.Public().(ed25519.PublicKey), nil
+	}
+	block, err := readPEMKey("TURBO_REMOTE_CACHE_SIGNATURE_PUBLIC_KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TURBO_REMOTE_CACHE_SIGNATURE_PUBLIC_KEY: %w", err)
+	}
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("invalid TURBO_REMOTE_CACHE_SIGNATURE_PUBLIC_KEY: not an ed25519 key")
+	}
+	return publicKey, nil
+}
+
+func readPEMKey(envName string) (*pem.Block, error) {
+	value := os.Getenv(envName)
+	if len(value) == 0 {
+		return nil, fmt.Errorf("signature key not found. You must specify a key in the %v environment variable", envName)
+	}
+	block, _ := pem.Decode([]byte(value))
+	if block == nil {
+		return nil, fmt.Errorf("invalid %v: expected a PEM-encoded key", envName)
+	}
+	return block, nil
+}
+
+func (asa *ArtifactSignatureAuthentication) isEd25519() bool {
+	return asa.algorithm == fs.SignatureEd25519
+}
+
+// signedMessage is what the ed25519 algorithm signs: the same metadata as
+// the HMAC tag, followed by the artifact
+func (asa *ArtifactSignatureAuthentication) signedMessage(hash string, artifactBody []byte) ([]byte, error) {
+	metadata, err := asa.metadata(hash)
+	if err != nil {
+		return nil, err
+	}
+	var message bytes.Buffer
+	message.Write(metadata)
+	message.Write(artifactBody)
+	return message.Bytes(), nil
+}
+
+func (asa *ArtifactSignatureAuthentication) metadata(hash string) ([]byte, error) {
+	artifactMetadata := &struct {
+		Hash   string `json:"hash"`
+		TeamId string `json:"teamId"`
+	}{
+		Hash:   hash,
+		TeamId: asa.teamId,
+	}
+	return json.Marshal(artifactMetadata)
+}
+
 func (asa *ArtifactSignatureAuthentication) generateTag(hash string, artifactBody []byte) (string, error) {
+	if asa.isEd25519() {
+		privateKey, err := asa.privateKey()
+		if err != nil {
+			return "", err
+		}
+		message, err := asa.signedMessage(hash, artifactBody)
+		if err != nil {
+			return "", err
+		}
+		return base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, message)), nil
+	}
 	tag, err := asa.getTagGenerator(hash)
 	if err != nil {
 		return "", err
@@ -43,30 +146,36 @@ func (asa *ArtifactSignatureAuthentication) generateTag(hash string, artifactBod
 }
 
 func (asa *ArtifactSignatureAuthentication) getTagGenerator(hash string) (hash.Hash, error) {
-	teamId := asa.teamId
 	secret, err := asa.secretKey()
 	if err != nil {
 		return nil, err
 	}
-	artifactMetadata := &struct {
-		Hash   string `json:"hash"`
-		TeamId string `json:"teamId"`
-	}{
-		Hash:   hash,
-		TeamId: teamId,
-	}
-	metadata, err := json.Marshal(artifactMetadata)
+	metadata, err := asa.metadata(hash)
 	if err != nil {
 		return nil, err
 	}
 
-	// TODO(Gaspar) Support additional signing algorithms here
 	h := hmac.New(sha256.New, secret)
 	h.Write(metadata)
 	return h, nil
 }
 
 func (asa *ArtifactSignatureAuthentication) validate(hash string, artifactBody []byte, expectedTag string) (bool, error) {
+	if asa.isEd25519() {
+		publicKey, err := asa.publicKey()
+		if err != nil {
+			return false, fmt.Errorf("failed to verify artifact tag: %w", err)
+		}
+		signature, err := base64.StdEncoding.DecodeString(expectedTag)
+		if err != nil {
+			return false, nil
+		}
+		message, err := asa.signedMessage(hash, artifactBody)
+		if err != nil {
+			return false, fmt.Errorf("failed to verify artifact tag: %w", err)
+		}
+		return ed25519.Verify(publicKey, message, signature), nil
+	}
 	computedTag, err := asa.generateTag(hash, artifactBody)
 	if err != nil {
 		return false, fmt.Errorf("failed to verify artifact tag: %w", err)
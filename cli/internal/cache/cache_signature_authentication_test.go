@@ -4,13 +4,18 @@
 package cache
 
 import (
+	"crypto/ed25519"
 	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/vercel/turbo/cli/internal/fs"
 )
 
 func Test_SecretKeySuccess(t *testing.T) {
@@ -166,6 +171,54 @@ func Test_GenerateTagAndValidate(t *testing.T) {
 	}
 }
 
+func Test_Ed25519Tag(t *testing.T) {
+	hash := "the-artifact-hash"
+	artifactBody := []byte("the artifact body as bytes")
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	privateDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	assert.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(publicKey)
+	assert.NoError(t, err)
+
+	asa := &ArtifactSignatureAuthentication{
+		teamId:    "team_someid",
+		enabled:   true,
+		algorithm: fs.SignatureEd25519,
+	}
+
+	// Uploads need the private key
+	_, err = asa.generateTag(hash, artifactBody)
+	assert.EqualError(t, err, "signature key not found. You must specify a key in the TURBO_REMOTE_CACHE_SIGNATURE_PRIVATE_KEY environment variable")
+	t.Setenv("TURBO_REMOTE_CACHE_SIGNATURE_PRIVATE_KEY", string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})))
+	tag, err := asa.generateTag(hash, artifactBody)
+	assert.NoError(t, err)
+
+	// The public key is derived from the private key when it isn't set
+	isValid, err := asa.validate(hash, artifactBody, tag)
+	assert.NoError(t, err)
+	assert.True(t, isValid)
+
+	// Downloads only need the public key
+	t.Setenv("TURBO_REMOTE_CACHE_SIGNATURE_PRIVATE_KEY", "")
+	t.Setenv("TURBO_REMOTE_CACHE_SIGNATURE_PUBLIC_KEY", string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})))
+	isValid, err = asa.validate(hash, artifactBody, tag)
+	assert.NoError(t, err)
+	assert.True(t, isValid)
+
+	isValid, err = asa.validate(hash, []byte("a tampered artifact body"), tag)
+	assert.NoError(t, err)
+	assert.False(t, isValid)
+
+	isValid, err = asa.validate("another-artifact-hash", artifactBody, tag)
+	assert.NoError(t, err)
+	assert.False(t, isValid)
+
+	isValid, err = asa.validate(hash, artifactBody, "not base64!")
+	assert.NoError(t, err)
+	assert.False(t, isValid)
+}
+
 // Test utils
 
 // Return the Base64 encoded HMAC given the artifact metadata and artifact body
@@ -48,6 +48,7 @@ func (tc *testCache) Put(anchor turbopath.AbsoluteSystemPath, hash string, durat
 	return nil
 }
 
+func (tc *testCache) Prefetch(hashes []string)                  {}
 func (tc *testCache) Clean(anchor turbopath.AbsoluteSystemPath) {}
 func (tc *testCache) CleanAll()                                 {}
 func (tc *testCache) Shutdown()                                 {}
@@ -148,7 +149,7 @@ func TestExists(t *testing.T) {
 
 type fakeClient struct{}
 
-// FetchArtifact implements client
+// FetchArtifact implements RemoteClient
 func (*fakeClient) FetchArtifact(hash string) (*http.Response, error) {
 	panic("unimplemented")
 }
@@ -157,17 +158,17 @@ func (*fakeClient) ArtifactExists(hash string) (*http.Response, error) {
 	panic("unimplemented")
 }
 
-// GetTeamID implements client
+// GetTeamID implements RemoteClient
 func (*fakeClient) GetTeamID() string {
 	return "fake-team-id"
 }
 
-// PutArtifact implements client
-func (*fakeClient) PutArtifact(hash string, body []byte, duration int, tag string) error {
+// PutArtifact implements RemoteClient
+func (*fakeClient) PutArtifact(hash string, body []byte, duration int, tag string, contentEncoding string) error {
 	panic("unimplemented")
 }
 
-var _ client = &fakeClient{}
+var _ RemoteClient = &fakeClient{}
 
 func TestFetchCachingDisabled(t *testing.T) {
 	disabledCache := newDisabledCache()
@@ -314,3 +315,29 @@ func TestNew(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveCacheDir(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	absoluteDir := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	tests := []struct {
+		name        string
+		overrideDir string
+		envDir      string
+		want        turbopath.AbsoluteSystemPath
+	}{
+		{name: "default", want: repoRoot.UntypedJoin("node_modules", ".cache", "turbo")},
+		{name: "env", envDir: ".cache/branch", want: repoRoot.UntypedJoin(".cache", "branch")},
+		{name: "absolute env", envDir: absoluteDir.ToString(), want: absoluteDir},
+		{name: "flag", overrideDir: "flag-cache", want: repoRoot.UntypedJoin("flag-cache")},
+		{name: "flag takes precedence over env", overrideDir: "flag-cache", envDir: "env-cache", want: repoRoot.UntypedJoin("flag-cache")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(CacheDirEnvVar, tt.envDir)
+			opts := &Opts{OverrideDir: tt.overrideDir}
+			if got := opts.ResolveCacheDir(repoRoot); got != tt.want {
+				t.Errorf("ResolveCacheDir() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
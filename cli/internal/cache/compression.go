@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/DataDog/zstd"
+	"github.com/vercel/turbo/cli/internal/fs"
+)
+
+// _gzipMagic starts every gzip stream. It tells gzip artifacts apart from zstd
+// ones when a remote cache doesn't keep their Content-Encoding.
+var _gzipMagic = []byte{0x1f, 0x8b}
+
+// newCompressor compresses an artifact with compression at level, or at the
+// default level if it is 0. Artifacts are compressed with zstd by default.
+func newCompressor(w io.Writer, compression string, level int) (io.WriteCloser, error) {
+	switch compression {
+	case "", fs.CompressionZstd:
+		if level == 0 {
+			level = zstd.DefaultCompression
+		}
+		return zstd.NewWriterLevel(w, level), nil
+	case fs.CompressionGzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", compression)
+	}
+}
+
+// newDecompressor decompresses an artifact downloaded with the given
+// Content-Encoding, and returns the compression it used. Without an encoding,
+// the compression is found from the first bytes of the artifact.
+func newDecompressor(r io.Reader, contentEncoding string) (io.ReadCloser, string, error) {
+	compression := strings.ToLower(strings.TrimSpace(contentEncoding))
+	if compression == "" || compression == "identity" {
+		buffered := bufio.NewReader(r)
+		r = buffered
+		compression = fs.CompressionZstd
+		// A short artifact is left for the zstd reader to report as corrupted
+		if magic, _ := buffered.Peek(len(_gzipMagic)); bytes.Equal(magic, _gzipMagic) {
+			compression = fs.CompressionGzip
+		}
+	}
+	switch compression {
+	case fs.CompressionZstd:
+		return zstd.NewReader(r), compression, nil
+	case fs.CompressionGzip:
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, compression, err
+		}
+		return zr, compression, nil
+	default:
+		return nil, compression, fmt.Errorf("unsupported Content-Encoding %q", contentEncoding)
+	}
+}
@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func TestCompression(t *testing.T) {
+	src := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	assert.NilError(t, src.UntypedJoin("some-file").WriteFile([]byte("some-file-contents"), 0644), "WriteFile")
+	files := []turbopath.AnchoredSystemPath{"some-file"}
+
+	for _, compression := range []string{fs.CompressionZstd, fs.CompressionGzip} {
+		cache := &httpCache{repoRoot: src, compression: compression, compressionLevel: 1}
+		r, w := io.Pipe()
+		var tarBytes int64
+		go cache.write(w, "the-hash", files, &tarBytes)
+		artifact, err := ioutil.ReadAll(r)
+		assert.NilError(t, err, "ReadAll")
+
+		// Caches that don't keep the Content-Encoding still serve usable artifacts
+		for _, contentEncoding := range []string{compression, ""} {
+			zr, detected, err := newDecompressor(bytes.NewReader(artifact), contentEncoding)
+			assert.NilError(t, err, "newDecompressor")
+			assert.Equal(t, detected, compression)
+			tarReader := &countingReader{Reader: zr}
+			restored, err := restoreTar(fs.AbsoluteSystemPathFromUpstream(t.TempDir()), tarReader)
+			assert.NilError(t, err, "restoreTar")
+			assert.Equal(t, len(restored), 1)
+			assert.Equal(t, tarReader.count, tarBytes)
+		}
+	}
+
+	_, _, err := newDecompressor(bytes.NewReader(nil), "br")
+	assert.ErrorContains(t, err, `unsupported Content-Encoding "br"`)
+}
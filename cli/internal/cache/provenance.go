@@ -0,0 +1,74 @@
+package cache
+
+import "time"
+
+// Provenance records who or what wrote an artifact to the cache, so that an
+// artifact restored into a release build can be traced back to the run that
+// produced it
+type Provenance struct {
+	// User is the user that turbo ran as
+	User string `json:"user,omitempty"`
+	// CIJobURL links to the CI job of the run, when there is one
+	CIJobURL string `json:"ciJobUrl,omitempty"`
+	// GitSHA is the commit that was checked out
+	GitSHA       string `json:"gitSha,omitempty"`
+	TurboVersion string `json:"turboVersion,omitempty"`
+	// InputsDigest is the hash of the task, which digests all of its inputs,
+	// such as its files, environment variables and dependencies
+	InputsDigest string `json:"inputsDigest,omitempty"`
+	// CreatedAt is when the artifact was written to the filesystem cache
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+}
+
+// The keys of the provenance in the metadata of remote artifacts
+const (
+	_provenanceUserKey         = "user"
+	_provenanceCIJobURLKey     = "ci-job-url"
+	_provenanceGitSHAKey       = "git-sha"
+	_provenanceTurboVersionKey = "turbo-version"
+)
+
+// Metadata returns the fields of the provenance that are the same for every
+// artifact of a run, keyed as they are in the metadata of remote artifacts
+func (p *Provenance) Metadata() map[string]string {
+	metadata := map[string]string{}
+	for key, value := range map[string]string{
+		_provenanceUserKey:         p.User,
+		_provenanceCIJobURLKey:     p.CIJobURL,
+		_provenanceGitSHAKey:       p.GitSHA,
+		_provenanceTurboVersionKey: p.TurboVersion,
+	} {
+		if value != "" {
+			metadata[key] = value
+		}
+	}
+	return metadata
+}
+
+// ProvenanceFromMetadata reads the provenance of the remote artifact for hash
+// from its metadata, or returns nil if it has none
+func ProvenanceFromMetadata(hash string, metadata map[string]string) *Provenance {
+	p := &Provenance{
+		User:         metadata[_provenanceUserKey],
+		CIJobURL:     metadata[_provenanceCIJobURLKey],
+		GitSHA:       metadata[_provenanceGitSHAKey],
+		TurboVersion: metadata[_provenanceTurboVersionKey],
+	}
+	if p.User == "" && p.CIJobURL == "" && p.GitSHA == "" && p.TurboVersion == "" {
+		return nil
+	}
+	p.InputsDigest = hash
+	return p
+}
+
+// forArtifact returns the provenance of the artifact for hash, written now
+func (p *Provenance) forArtifact(hash string) *Provenance {
+	if p == nil {
+		return nil
+	}
+	artifact := *p
+	artifact.InputsDigest = hash
+	createdAt := time.Now().UTC()
+	artifact.CreatedAt = &createdAt
+	return &artifact
+}
@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// Directions of a RemoteTransfer
+const (
+	TransferDownload = "download"
+	TransferUpload   = "upload"
+)
+
+// RemoteTransfer is a request to download or upload an artifact from the remote cache
+type RemoteTransfer struct {
+	Hash      string
+	Direction string
+	// Bytes is the size of the compressed artifact that was transferred
+	Bytes int64
+	// UncompressedBytes is the size of the artifact's tar, or 0 if the artifact
+	// wasn't read
+	UncompressedBytes int64
+	// Compression is fs.CompressionZstd or fs.CompressionGzip, or empty if the
+	// artifact wasn't read
+	Compression string
+	Duration    time.Duration
+	// StatusCode is zero if the request failed without a response
+	StatusCode int
+}
+
+// RemoteStats collects the transfers made by the remote cache during a run,
+// and why the run stopped using the remote cache, if it did
+type RemoteStats struct {
+	mu        sync.Mutex
+	transfers []RemoteTransfer
+	disabled  string
+}
+
+// NewRemoteStats creates an empty RemoteStats
+func NewRemoteStats() *RemoteStats {
+	return &RemoteStats{}
+}
+
+func (s *RemoteStats) record(transfer RemoteTransfer) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transfers = append(s.transfers, transfer)
+}
+
+// Transfers returns the transfers made so far, in the order they finished
+func (s *RemoteStats) Transfers() []RemoteTransfer {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	transfers := make([]RemoteTransfer, len(s.transfers))
+	copy(transfers, s.transfers)
+	return transfers
+}
+
+// Disable records why the run stopped using the remote cache. Only the first
+// reason is kept.
+func (s *RemoteStats) Disable(reason string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.disabled == "" {
+		s.disabled = reason
+	}
+}
+
+// Disabled returns why the run stopped using the remote cache, or the empty
+// string if it didn't
+func (s *RemoteStats) Disabled() string {
+	if s == nil {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.disabled
+}
+
+// statusCodeError is implemented by the errors of remote clients that got a
+// response with an unexpected status code
+type statusCodeError interface {
+	HTTPStatusCode() int
+}
+
+func errorStatusCode(err error) int {
+	var statusErr statusCodeError
+	if errors.As(err, &statusErr) {
+		return statusErr.HTTPStatusCode()
+	}
+	return 0
+}
+
+// countingReader counts the bytes read from a response body
+type countingReader struct {
+	io.Reader
+	count int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+// countingWriter counts the bytes written to an artifact's compressor
+type countingWriter struct {
+	io.Writer
+	count int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.count += int64(n)
+	return n, err
+}
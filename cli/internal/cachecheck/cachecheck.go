@@ -0,0 +1,213 @@
+// Package cachecheck finds the task outputs and inputs that are at odds with
+// git: outputs that match files checked into the repository, and inputs that
+// match files git ignores. Both are common ways to misconfigure caching that
+// don't fail anything, so turbo reports them before running tasks.
+package cachecheck
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/vercel/turbo/cli/internal/doublestar"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// _maxExamples is how many of the matching files a warning lists
+const _maxExamples = 3
+
+// Kind is what is wrong with the configuration of a task
+type Kind string
+
+const (
+	// TrackedOutput is for tasks whose outputs match files tracked by git.
+	// Restoring the task from the cache overwrites the checked in files.
+	TrackedOutput Kind = "tracked_output"
+	// IgnoredInput is for tasks that hash files ignored by git. Those are
+	// usually build outputs or local files, so the hash of the task differs
+	// between machines, or changes every time a task runs.
+	IgnoredInput Kind = "ignored_input"
+)
+
+// Task is what is checked about a task
+type Task struct {
+	TaskID string
+	// Dir is the directory of the task's package, relative to the repository root
+	Dir turbopath.AnchoredUnixPath
+	// Outputs and ExcludedOutputs are the output globs, relative to Dir
+	Outputs         []string
+	ExcludedOutputs []string
+	// Inputs are the files hashed for the task, relative to Dir
+	Inputs []turbopath.AnchoredUnixPath
+}
+
+// Warning is a misconfiguration of a task, and how to fix it
+type Warning struct {
+	TaskID string `json:"taskId"`
+	Kind   Kind   `json:"kind"`
+	// Count is how many files are affected, and Files are the first of them,
+	// relative to the repository root
+	Count   int      `json:"count"`
+	Files   []string `json:"files"`
+	Message string   `json:"message"`
+	Fix     string   `json:"fix"`
+}
+
+func (w *Warning) String() string {
+	return fmt.Sprintf("%v: %v. %v", w.TaskID, w.Message, w.Fix)
+}
+
+// Check returns the warnings for the given tasks, ordered by task. It returns an
+// error if repoRoot is not in a git repository.
+func Check(repoRoot turbopath.AbsoluteSystemPath, tasks []Task) ([]*Warning, error) {
+	tracked, err := trackedFiles(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(tracked)
+
+	inputs := []string{}
+	seen := make(map[string]bool)
+	for _, task := range tasks {
+		for _, input := range task.Inputs {
+			file := repoPath(task.Dir, input.ToString())
+			if !seen[file] {
+				seen[file] = true
+				inputs = append(inputs, file)
+			}
+		}
+	}
+	ignored, err := ignoredFiles(repoRoot, inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	warnings := []*Warning{}
+	for _, task := range tasks {
+		if files := trackedOutputs(task, tracked); len(files) > 0 {
+			warnings = append(warnings, newWarning(task.TaskID, TrackedOutput, files,
+				"outputs match %d files that are tracked by git, such as %v, so restoring the task from the cache overwrites them",
+				"Narrow \"outputs\" to the files the task generates, or remove the generated files from git and add them to .gitignore",
+			))
+		}
+		files := []string{}
+		for _, input := range task.Inputs {
+			if file := repoPath(task.Dir, input.ToString()); ignored[file] {
+				files = append(files, file)
+			}
+		}
+		if len(files) > 0 {
+			sort.Strings(files)
+			warnings = append(warnings, newWarning(task.TaskID, IgnoredInput, files,
+				"inputs match %d files that are ignored by git, such as %v, so the task's hash depends on files that differ between machines or change when tasks run",
+				"Narrow \"inputs\", or exclude the ignored files with a \"!\" glob",
+			))
+		}
+	}
+	sort.SliceStable(warnings, func(i, j int) bool { return warnings[i].TaskID < warnings[j].TaskID })
+	return warnings, nil
+}
+
+// newWarning returns a warning about files, formatting their count and the
+// first of them into message
+func newWarning(taskID string, kind Kind, files []string, message string, fix string) *Warning {
+	examples := files
+	if len(examples) > _maxExamples {
+		examples = examples[:_maxExamples]
+	}
+	return &Warning{
+		TaskID:  taskID,
+		Kind:    kind,
+		Count:   len(files),
+		Files:   examples,
+		Message: fmt.Sprintf(message, len(files), strings.Join(examples, ", ")),
+		Fix:     fix,
+	}
+}
+
+// trackedOutputs returns the tracked files, which are sorted, that match the
+// outputs of task
+func trackedOutputs(task Task, tracked []string) []string {
+	if len(task.Outputs) == 0 {
+		return nil
+	}
+	prefix := ""
+	if dir := path.Clean(task.Dir.ToString()); dir != "." {
+		prefix = dir + "/"
+	}
+	files := []string{}
+	for i := sort.SearchStrings(tracked, prefix); i < len(tracked) && strings.HasPrefix(tracked[i], prefix); i++ {
+		file := strings.TrimPrefix(tracked[i], prefix)
+		if matchesAny(task.Outputs, file) && !matchesAny(task.ExcludedOutputs, file) {
+			files = append(files, tracked[i])
+		}
+	}
+	return files
+}
+
+func matchesAny(globs []string, file string) bool {
+	for _, glob := range globs {
+		if matched, err := doublestar.Match(path.Clean(glob), file); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// repoPath returns the path relative to the repository root of a file relative
+// to dir
+func repoPath(dir turbopath.AnchoredUnixPath, file string) string {
+	return path.Join(dir.ToString(), file)
+}
+
+// ignoredFiles returns which of the files, relative to repoRoot, git ignores.
+// Files that are tracked are never ignored.
+func ignoredFiles(repoRoot turbopath.AbsoluteSystemPath, files []string) (map[string]bool, error) {
+	ignored := make(map[string]bool)
+	if len(files) == 0 {
+		return ignored, nil
+	}
+	stdin := &bytes.Buffer{}
+	for _, file := range files {
+		stdin.WriteString(file)
+		stdin.WriteByte(0)
+	}
+	cmd := exec.Command("git", "check-ignore", "-z", "--stdin")
+	cmd.Dir = repoRoot.ToString()
+	cmd.Stdin = stdin
+	output, err := cmd.Output()
+	// check-ignore exits with 1 when none of the files are ignored
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		return ignored, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to check files ignored by git: %w", err)
+	}
+	for _, file := range splitNUL(output) {
+		ignored[file] = true
+	}
+	return ignored, nil
+}
+
+// trackedFiles returns the files tracked by git under repoRoot, relative to it
+func trackedFiles(repoRoot turbopath.AbsoluteSystemPath) ([]string, error) {
+	cmd := exec.Command("git", "ls-files", "-z")
+	cmd.Dir = repoRoot.ToString()
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files tracked by git: %w", err)
+	}
+	return splitNUL(output), nil
+}
+
+func splitNUL(output []byte) []string {
+	files := []string{}
+	for _, file := range strings.Split(string(output), "\x00") {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
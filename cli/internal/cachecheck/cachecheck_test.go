@@ -0,0 +1,96 @@
+package cachecheck
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func setupRepo(t *testing.T, files map[string]string) turbopath.AbsoluteSystemPath {
+	t.Helper()
+	repoRoot := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	for path, contents := range files {
+		file := repoRoot.UntypedJoin(path)
+		assert.NilError(t, file.EnsureDir())
+		assert.NilError(t, file.WriteFile([]byte(contents), 0644))
+	}
+	for _, args := range [][]string{{"init"}, {"add", "."}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repoRoot.ToString()
+		output, err := cmd.CombinedOutput()
+		assert.NilError(t, err, string(output))
+	}
+	return repoRoot
+}
+
+func TestCheck(t *testing.T) {
+	repoRoot := setupRepo(t, map[string]string{
+		".gitignore":                 "dist\n.env.local\n",
+		"apps/web/package.json":      "{}",
+		"apps/web/src/index.ts":      "",
+		"apps/web/dist/index.js":     "",
+		"apps/web/.env.local":        "",
+		"apps/web/generated/api.ts":  "",
+		"apps/web/generated/keep.md": "",
+		"packages/ui/package.json":   "{}",
+		"packages/ui/src/button.ts":  "",
+	})
+
+	warnings, err := Check(repoRoot, []Task{
+		{
+			TaskID:          "web#build",
+			Dir:             "apps/web",
+			Outputs:         []string{"dist/**", "generated/**"},
+			ExcludedOutputs: []string{"generated/*.md"},
+			Inputs:          []turbopath.AnchoredUnixPath{"package.json", "src/index.ts", "dist/index.js", ".env.local"},
+		},
+		{
+			TaskID:  "ui#build",
+			Dir:     "packages/ui",
+			Outputs: []string{"dist/**"},
+			Inputs:  []turbopath.AnchoredUnixPath{"package.json", "src/button.ts"},
+		},
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, len(warnings), 2)
+
+	assert.Equal(t, warnings[0].TaskID, "web#build")
+	assert.Equal(t, warnings[0].Kind, TrackedOutput)
+	assert.DeepEqual(t, warnings[0].Files, []string{"apps/web/generated/api.ts"})
+
+	assert.Equal(t, warnings[1].TaskID, "web#build")
+	assert.Equal(t, warnings[1].Kind, IgnoredInput)
+	assert.Equal(t, warnings[1].Count, 2)
+	assert.DeepEqual(t, warnings[1].Files, []string{"apps/web/.env.local", "apps/web/dist/index.js"})
+	assert.Equal(t, warnings[1].Message, "inputs match 2 files that are ignored by git, such as apps/web/.env.local, apps/web/dist/index.js, so the task's hash depends on files that differ between machines or change when tasks run")
+}
+
+func TestCheckRootTask(t *testing.T) {
+	repoRoot := setupRepo(t, map[string]string{
+		"package.json":     "{}",
+		"coverage/lcov.md": "",
+		"a.txt":            "",
+		"b.txt":            "",
+		"c.txt":            "",
+		"d.txt":            "",
+	})
+
+	warnings, err := Check(repoRoot, []Task{
+		{TaskID: "//#report", Dir: "", Outputs: []string{"coverage/**", "*.txt"}},
+		{TaskID: "//#lint", Dir: "", Inputs: []turbopath.AnchoredUnixPath{"package.json"}},
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, len(warnings), 1)
+	assert.Equal(t, warnings[0].TaskID, "//#report")
+	assert.Equal(t, warnings[0].Count, 5)
+	assert.DeepEqual(t, warnings[0].Files, []string{"a.txt", "b.txt", "c.txt"})
+}
+
+func TestCheckOutsideGit(t *testing.T) {
+	repoRoot := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	t.Setenv("GIT_CEILING_DIRECTORIES", repoRoot.Dir().ToString())
+	_, err := Check(repoRoot, []Task{{TaskID: "web#build", Outputs: []string{"dist/**"}}})
+	assert.ErrorContains(t, err, "failed to list files tracked by git")
+}
@@ -34,11 +34,26 @@ type CacheItem struct {
 	fileBuffer *bufio.Writer
 	handle     *os.File
 	compressed bool
+	// manifest records the checksums of the files added, for creation
+	manifest *Manifest
+
+	// objects is the object store of content-addressed artifacts. For
+	// creation, it is only set if regular files are written to it.
+	objects turbopath.AbsoluteSystemPath
+	// noClone and noLink are set once cloning or hardlinking an object has
+	// failed, so that the remaining objects are copied without trying again
+	noClone bool
+	noLink  bool
 }
 
 // Close any open pipes
 func (ci *CacheItem) Close() error {
 	if ci.tw != nil {
+		if ci.manifest != nil {
+			if err := ci.manifest.WriteChecksums(ci.tw); err != nil {
+				return err
+			}
+		}
 		if err := ci.tw.Close(); err != nil {
 			return err
 		}
@@ -0,0 +1,17 @@
+package cacheitem
+
+import (
+	"os"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"golang.org/x/sys/unix"
+)
+
+// cloneFile makes a copy-on-write clone of src at dst, on APFS
+func cloneFile(src turbopath.AbsoluteSystemPath, dst turbopath.AbsoluteSystemPath, mode os.FileMode) error {
+	if err := unix.Clonefile(src.ToString(), dst.ToString(), unix.CLONE_NOFOLLOW); err != nil {
+		return err
+	}
+	// The clone has the mode of the object, which can differ from the file's
+	return os.Chmod(dst.ToString(), mode)
+}
@@ -0,0 +1,27 @@
+package cacheitem
+
+import (
+	"os"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"golang.org/x/sys/unix"
+)
+
+// cloneFile makes a copy-on-write clone of src at dst, on filesystems that
+// support them such as Btrfs and XFS
+func cloneFile(src turbopath.AbsoluteSystemPath, dst turbopath.AbsoluteSystemPath, mode os.FileMode) error {
+	source, err := os.Open(src.ToString())
+	if err != nil {
+		return err
+	}
+	defer func() { _ = source.Close() }()
+	target, err := dst.OpenFile(os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlFileClone(int(target.Fd()), int(source.Fd())); err != nil {
+		_ = target.Close()
+		return err
+	}
+	return target.Close()
+}
@@ -0,0 +1,16 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package cacheitem
+
+import (
+	"errors"
+	"os"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// cloneFile fails, since cloning files is only supported on Linux and macOS
+func cloneFile(src turbopath.AbsoluteSystemPath, dst turbopath.AbsoluteSystemPath, mode os.FileMode) error {
+	return errors.New("cloning files is not supported on this platform")
+}
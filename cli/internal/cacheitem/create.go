@@ -28,14 +28,18 @@ func Create(path turbopath.AbsoluteSystemPath) (*CacheItem, error) {
 		compressed: strings.HasSuffix(path.ToString(), ".zst"),
 	}
 
-	cacheItem.init()
+	if err := cacheItem.init(); err != nil {
+		_ = handle.Close()
+		return nil, err
+	}
 	return cacheItem, nil
 }
 
 // init prepares the CacheItem for writing.
 // Wires all the writers end-to-end:
 // tar.Writer -> zstd.Writer -> fileBuffer -> file
-func (ci *CacheItem) init() {
+// and marks the tar as ending with a manifest.
+func (ci *CacheItem) init() error {
 	fileBuffer := bufio.NewWriterSize(ci.handle, 2^20) // Flush to disk in 1mb chunks.
 
 	var tw *tar.Writer
@@ -49,6 +53,8 @@ func (ci *CacheItem) init() {
 
 	ci.tw = tw
 	ci.fileBuffer = fileBuffer
+	ci.manifest = NewManifest()
+	return ci.manifest.WriteMarker(tw)
 }
 
 // AddFile adds a user-cached item to the tar.
@@ -62,6 +68,12 @@ func (ci *CacheItem) AddFile(fsAnchor turbopath.AbsoluteSystemPath, filePath tur
 		return lstatErr
 	}
 
+	// Junctions are archived as symlinks to the same directory, rather than
+	// as a directory that has the contents of the target.
+	if isJunction(fileInfo) {
+		fileInfo = junctionInfo{fileInfo}
+	}
+
 	// Determine if we need to populate the additional link argument to tar.FileInfoHeader.
 	var link string
 	if fileInfo.Mode()&os.ModeSymlink != 0 {
@@ -69,7 +81,7 @@ func (ci *CacheItem) AddFile(fsAnchor turbopath.AbsoluteSystemPath, filePath tur
 		if readlinkErr != nil {
 			return readlinkErr
 		}
-		link = linkTarget
+		link = portableLinkTarget(fsAnchor, sourcePath, linkTarget)
 	}
 
 	// Normalize the path within the cache.
@@ -94,21 +106,31 @@ func (ci *CacheItem) AddFile(fsAnchor turbopath.AbsoluteSystemPath, filePath tur
 	header.ModTime = time.Unix(0, 0)
 	header.ChangeTime = time.Unix(0, 0)
 
-	// Always write the header.
+	if header.Typeflag == tar.TypeReg && ci.objects != "" {
+		return ci.addObject(header, sourcePath)
+	}
+
+	// Write the header, followed by the contents of regular files.
 	if err := ci.tw.WriteHeader(header); err != nil {
 		return err
 	}
 
+	if header.Typeflag != tar.TypeReg {
+		ci.manifest.Add(header.Name)
+		return nil
+	}
+	body := ci.manifest.Writer(header.Name, ci.tw)
+
 	// If there is a body to be written, do so.
-	if header.Typeflag == tar.TypeReg && header.Size > 0 {
+	if header.Size > 0 {
 		// Windows has a distinct "sequential read" opening mode.
 		// We use a library that will switch to this mode for Windows.
-		sourceFile, sourceErr := sequential.OpenFile(sourcePath.ToString(), os.O_RDONLY, 0777)
+		sourceFile, sourceErr := sequential.OpenFile(longPath(sourcePath.ToString()), os.O_RDONLY, 0777)
 		if sourceErr != nil {
 			return sourceErr
 		}
 
-		if _, err := io.Copy(ci.tw, sourceFile); err != nil {
+		if _, err := io.Copy(body, sourceFile); err != nil {
 			return err
 		}
 
@@ -117,3 +139,16 @@ func (ci *CacheItem) AddFile(fsAnchor turbopath.AbsoluteSystemPath, filePath tur
 
 	return nil
 }
+
+// junctionInfo describes a junction as a symlink
+type junctionInfo struct {
+	os.FileInfo
+}
+
+func (j junctionInfo) Mode() os.FileMode {
+	return os.ModeSymlink | j.FileInfo.Mode().Perm()
+}
+
+func (j junctionInfo) IsDir() bool {
+	return false
+}
@@ -71,9 +71,9 @@ func TestCreate(t *testing.T) {
 					FileMode: 0 | 0644,
 				},
 			},
-			wantDarwin:  "4f39f1cab23906f3b89f313392ef7c26f2586e1c15fa6b577cce640c4781d082817927b4875a5413bc23e1248f0b198218998d70e7336e8b1244542ba446ca07",
-			wantUnix:    "4f39f1cab23906f3b89f313392ef7c26f2586e1c15fa6b577cce640c4781d082817927b4875a5413bc23e1248f0b198218998d70e7336e8b1244542ba446ca07",
-			wantWindows: "e304d1ba8c51209f97bd11dabf27ca06996b70a850db592343942c49480de47bcbb4b7131fb3dd4d7564021d3bc0e648919e4876572b46ac1da97fca92b009c5",
+			wantDarwin:  "a3db0db7fbef2af73311dd96e3a205e2880d69544fdd7ca0c113f372d0bdeef17050583cbc4054fabe9fb30d2e8472fd7d0c449263c2dd1933c6a2d44436bc9f",
+			wantUnix:    "a3db0db7fbef2af73311dd96e3a205e2880d69544fdd7ca0c113f372d0bdeef17050583cbc4054fabe9fb30d2e8472fd7d0c449263c2dd1933c6a2d44436bc9f",
+			wantWindows: "3b4bcbef6f5900ca039a81921d395f2d68b4d4b4b2b20b4f611de8e74b1f16af2184709d291dd47b40419ad892a316639f5bd723ae11d8a564c9d945b5539a9e",
 		},
 		{
 			name: "links",
@@ -98,9 +98,9 @@ func TestCreate(t *testing.T) {
 					FileMode: 0 | 0644,
 				},
 			},
-			wantDarwin:  "07278fdf37db4b212352367f391377bd6bac8f361dd834ae5522d809539bcf3b34d046873c1b45876d7372251446bb12c32f9fa9824914c4a1a01f6d7a206702",
-			wantUnix:    "07278fdf37db4b212352367f391377bd6bac8f361dd834ae5522d809539bcf3b34d046873c1b45876d7372251446bb12c32f9fa9824914c4a1a01f6d7a206702",
-			wantWindows: "d4dac527e40860ee1ba3fdf2b9b12a1eba385050cf4f5877558dd531f0ecf2a06952fd5f88b852ad99e010943ed7b7f1437b727796369524e85f0c06f25d62c9",
+			wantDarwin:  "6f650f0a8c048476c29b467372d731e307a5f7f370be413e8517cbff7e20973b17de570b33d9e67f9c1cfb22dd65bdfba3800e3d10f473f26e82311adcf4208d",
+			wantUnix:    "6f650f0a8c048476c29b467372d731e307a5f7f370be413e8517cbff7e20973b17de570b33d9e67f9c1cfb22dd65bdfba3800e3d10f473f26e82311adcf4208d",
+			wantWindows: "7a95a3a26212db1f12eb1ce527a35dd506ef2e4dfffd760f6792ad581b00ef3a7d581a13e654ffac5b289c06120d24be7c073adb5c66989d355e0e01087b763f",
 		},
 		{
 			name: "subdirectory",
@@ -114,9 +114,9 @@ func TestCreate(t *testing.T) {
 					FileMode: 0 | 0644,
 				},
 			},
-			wantDarwin:  "b513eea231daa84245d1d23d99fc398ccf17166ca49754ffbdcc1a3269cd75b7ad176a9c7095ff2481f71dca9fc350189747035f13d53b3a864e4fe35165233f",
-			wantUnix:    "b513eea231daa84245d1d23d99fc398ccf17166ca49754ffbdcc1a3269cd75b7ad176a9c7095ff2481f71dca9fc350189747035f13d53b3a864e4fe35165233f",
-			wantWindows: "a8c3cba54e4dc214d3b21c3fa284d4032fe317d2f88943159efd5d16f3551ab53fae5c92ebf8acdd1bdb85d1238510b7938772cb11a0daa1b72b5e0f2700b5c7",
+			wantDarwin:  "349e2eb297288a2c246d69dd129076791bb1d0965b19f5e467753203c6a8bdd10b6ae88ca4c4d297304b5b2b8aa754158ba7690cdc594b3985a810aadd620599",
+			wantUnix:    "349e2eb297288a2c246d69dd129076791bb1d0965b19f5e467753203c6a8bdd10b6ae88ca4c4d297304b5b2b8aa754158ba7690cdc594b3985a810aadd620599",
+			wantWindows: "68890b0ab5c0d56e49179e008c190207ba1e6041631711d160f72012a2072ed31df67c1bbd5623db403a46073f7673868a8f7468f04866880d8cbe6f5b458d62",
 		},
 		{
 			name: "symlink permissions",
@@ -127,9 +127,9 @@ func TestCreate(t *testing.T) {
 					FileMode: 0 | os.ModeSymlink | 0644,
 				},
 			},
-			wantDarwin:  "3ea9d8a4581a0c2ba77557c72447b240c5ac622edcdac570a0bf597c276c2917b4ea73e6c373bbac593a480e396845651fa4b51e049531ff5d44c0adb807c2d9",
-			wantUnix:    "99d953cbe1c0d8545e6f8382208fcefe14bcbefe39872f7b6310da14ac195b9a1b04b6d7b4b56f01a27216176193344a92488f99e124fcd68693f313f7137a1c",
-			wantWindows: "a4b1dc5c296f8ac4c9124727c1d84d70f72872c7bb4ced6d83ee312889e822baf1eaa72f88e624fb1aac4339d0a1f766ede77eabd2e4524eb26e89f883dc479d",
+			wantDarwin:  "83d85dccfe7cec5ea1da083e5693887adeda6c690c67609c6ffc5b02f8e2b1604f2a522c675b854e530c36b1544bcf6b35f706c770010a882ee969c9ca834729",
+			wantUnix:    "c704ff9b9856f8a79b26055c7370587dc547ee02f4f2b715b87647e3b893124191b8a49984a387df637cfa06c65029a37dbb79ca60914e7be4701ff35f9a1d72",
+			wantWindows: "157261db25b2b743f272b7d3320ba836e4f5ef583186088be3f732fa85fbf1ed026681c757ffb16461ce12bfd6a468d0f2e5a893517cc8a530e7de7b2d8d3085",
 		},
 		{
 			name: "unsupported types error",
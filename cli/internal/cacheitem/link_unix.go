@@ -0,0 +1,33 @@
+//go:build !windows
+// +build !windows
+
+package cacheitem
+
+import (
+	"os"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// isJunction returns false, since junctions only exist on Windows.
+func isJunction(fileInfo os.FileInfo) bool {
+	return false
+}
+
+// isSymlinkPrivilegeError returns false, since creating symlinks doesn't need
+// a privilege outside of Windows.
+func isSymlinkPrivilegeError(err error) bool {
+	return false
+}
+
+// portableLinkTarget returns the target unchanged. Outside of Windows, link
+// targets are archived exactly as they were created.
+func portableLinkTarget(anchor turbopath.AbsoluteSystemPath, link turbopath.AbsoluteSystemPath, target string) string {
+	return target
+}
+
+// longPath returns the path unchanged, since only Windows limits the length of
+// paths that can be opened.
+func longPath(path string) string {
+	return path
+}
@@ -0,0 +1,68 @@
+//go:build windows
+// +build windows
+
+package cacheitem
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// _errorPrivilegeNotHeld is ERROR_PRIVILEGE_NOT_HELD, returned when creating a
+// symlink without Developer Mode or the SeCreateSymbolicLinkPrivilege.
+const _errorPrivilegeNotHeld syscall.Errno = 1314
+
+// _maxDirPath is the longest path that the Windows APIs accept for a
+// directory, which is MAX_PATH less room for an 8.3 file name.
+const _maxDirPath = 248
+
+// isJunction returns whether the file is a junction. Depending on the
+// winsymlink GODEBUG setting, Lstat reports junctions either as symlinks or as
+// irregular directories.
+func isJunction(fileInfo os.FileInfo) bool {
+	return fileInfo.IsDir() && fileInfo.Mode()&os.ModeIrregular != 0
+}
+
+// isSymlinkPrivilegeError returns whether creating a symlink failed because
+// the user isn't allowed to create symlinks.
+func isSymlinkPrivilegeError(err error) bool {
+	return errors.Is(err, _errorPrivilegeNotHeld)
+}
+
+// portableLinkTarget makes an absolute link target inside of the anchor
+// relative to the link. Junctions always have absolute targets, which would
+// point back into the original checkout when restored somewhere else.
+func portableLinkTarget(anchor turbopath.AbsoluteSystemPath, link turbopath.AbsoluteSystemPath, target string) string {
+	if !filepath.IsAbs(target) {
+		return target
+	}
+	absoluteTarget := turbopath.AbsoluteSystemPathFromUpstream(filepath.Clean(target))
+	if contained, err := anchor.ContainsPath(absoluteTarget); err != nil || !contained {
+		return target
+	}
+	relativeTarget, err := filepath.Rel(link.Dir().ToString(), absoluteTarget.ToString())
+	if err != nil {
+		return target
+	}
+	return relativeTarget
+}
+
+// longPath returns the extended-length form of an absolute path that is too
+// long for the Windows APIs. The os package does this by itself, but APIs
+// that are called directly, such as sequential.OpenFile, need it done for them.
+func longPath(path string) string {
+	if len(path) < _maxDirPath || strings.HasPrefix(path, `\\?\`) || !filepath.IsAbs(path) {
+		return path
+	}
+	// Extended-length paths are not cleaned by Windows
+	path = filepath.Clean(path)
+	if strings.HasPrefix(path, `\\`) {
+		return `\\?\UNC\` + path[2:]
+	}
+	return `\\?\` + path
+}
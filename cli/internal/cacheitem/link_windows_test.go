@@ -0,0 +1,94 @@
+//go:build windows
+// +build windows
+
+package cacheitem
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func Test_longPath(t *testing.T) {
+	short := `C:\repo\file`
+	assert.Equal(t, longPath(short), short)
+
+	long := `C:\repo\` + strings.Repeat(`nested\`, 40) + "file"
+	assert.Equal(t, longPath(long), `\\?\`+long)
+	assert.Equal(t, longPath(`\\?\`+long), `\\?\`+long)
+
+	unc := `\\server\share\` + strings.Repeat(`nested\`, 40) + "file"
+	assert.Equal(t, longPath(unc), `\\?\UNC\server\share\`+strings.Repeat(`nested\`, 40)+"file")
+}
+
+func TestRestoreLongPath(t *testing.T) {
+	inputDir := turbopath.AbsoluteSystemPath(t.TempDir())
+	archiveDir := turbopath.AbsoluteSystemPath(t.TempDir())
+	outputDir := turbopath.AbsoluteSystemPath(t.TempDir())
+
+	// Longer than MAX_PATH once anchored in any of the temporary directories
+	deepPath := turbopath.AnchoredSystemPath(strings.Repeat(`node_modules\`, 25) + "index.js")
+	assert.NilError(t, deepPath.RestoreAnchor(inputDir).EnsureDir(), "EnsureDir")
+	assert.NilError(t, deepPath.RestoreAnchor(inputDir).WriteFile([]byte("deep"), 0644), "WriteFile")
+
+	archivePath := turbopath.AnchoredSystemPath("out.tar.zst").RestoreAnchor(archiveDir)
+	cacheItem, err := Create(archivePath)
+	assert.NilError(t, err, "Create")
+	assert.NilError(t, cacheItem.AddFile(inputDir, deepPath), "AddFile")
+	assert.NilError(t, cacheItem.Close(), "Close")
+
+	openedCacheItem, err := Open(archivePath)
+	assert.NilError(t, err, "Open")
+	restored, err := openedCacheItem.Restore(outputDir)
+	assert.NilError(t, err, "Restore")
+	assert.NilError(t, openedCacheItem.Close(), "Close")
+	assert.DeepEqual(t, restored, []turbopath.AnchoredSystemPath{deepPath})
+
+	contents, err := deepPath.RestoreAnchor(outputDir).ReadFile()
+	assert.NilError(t, err, "ReadFile")
+	assert.Equal(t, string(contents), "deep")
+}
+
+func TestCreateJunction(t *testing.T) {
+	inputDir := turbopath.AbsoluteSystemPath(t.TempDir())
+	archiveDir := turbopath.AbsoluteSystemPath(t.TempDir())
+	outputDir := turbopath.AbsoluteSystemPath(t.TempDir())
+
+	target := inputDir.UntypedJoin("target")
+	assert.NilError(t, target.MkdirAll(0755), "MkdirAll")
+	junction := inputDir.UntypedJoin("junction")
+	// Junctions don't need the privilege to create symlinks
+	output, err := exec.Command("cmd", "/c", "mklink", "/J", junction.ToString(), target.ToString()).CombinedOutput()
+	assert.NilError(t, err, string(output))
+
+	archivePath := turbopath.AnchoredSystemPath("out.tar.zst").RestoreAnchor(archiveDir)
+	cacheItem, err := Create(archivePath)
+	assert.NilError(t, err, "Create")
+	assert.NilError(t, cacheItem.AddFile(inputDir, "target"), "AddFile")
+	assert.NilError(t, cacheItem.AddFile(inputDir, "junction"), "AddFile")
+	assert.NilError(t, cacheItem.Close(), "Close")
+
+	openedCacheItem, err := Open(archivePath)
+	assert.NilError(t, err, "Open")
+	_, err = openedCacheItem.Restore(outputDir)
+	assert.NilError(t, err, "Restore")
+	assert.NilError(t, openedCacheItem.Close(), "Close")
+
+	// The junction is restored as a link relative to itself, so that it points
+	// into the output rather than back into the input. Without the privilege
+	// to create symlinks it is restored as a copy of the target.
+	restoredJunction := outputDir.UntypedJoin("junction")
+	info, err := restoredJunction.Lstat()
+	assert.NilError(t, err, "Lstat")
+	if info.Mode()&os.ModeSymlink != 0 {
+		linkTarget, err := restoredJunction.Readlink()
+		assert.NilError(t, err, "Readlink")
+		assert.Equal(t, linkTarget, "target")
+	} else {
+		assert.Assert(t, info.IsDir())
+	}
+}
@@ -0,0 +1,96 @@
+package cacheitem
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/DataDog/zstd"
+)
+
+// Entry describes a file, directory or symlink in a CacheItem
+type Entry struct {
+	Name     string      `json:"name"`
+	Type     string      `json:"type"`
+	Size     int64       `json:"size"`
+	Mode     os.FileMode `json:"mode"`
+	Linkname string      `json:"linkname,omitempty"`
+	// Checksum is the SHA-256 of the contents of regular files
+	Checksum string `json:"checksum,omitempty"`
+	// Object is the file in the object store that has the contents of a
+	// regular file of a content-addressed artifact
+	Object string `json:"object,omitempty"`
+}
+
+// List reads the entries of a CacheItem without restoring them, checking them
+// against its manifest like Restore does.
+func (ci *CacheItem) List() ([]Entry, error) {
+	var tr *tar.Reader
+	var closeError error
+	manifest := NewManifest()
+
+	if ci.compressed {
+		zr := zstd.NewReader(ci.handle)
+		defer func() { closeError = zr.Close() }()
+		tr = tar.NewReader(manifest.Archive(zr))
+	} else {
+		tr = tar.NewReader(manifest.Archive(ci.handle))
+	}
+
+	entries := make([]Entry, 0)
+	for {
+		header, trErr := tr.Next()
+		if trErr == io.EOF {
+			break
+		}
+		if trErr != nil {
+			return entries, fmt.Errorf("%w: %v", ErrCorrupted, trErr)
+		}
+		if header.Typeflag == tar.TypeXGlobalHeader {
+			if err := manifest.ReadHeader(header); err != nil {
+				return entries, err
+			}
+			continue
+		}
+
+		entry := Entry{
+			Name: header.Name,
+			Mode: header.FileInfo().Mode().Perm(),
+		}
+		switch header.Typeflag {
+		case tar.TypeReg:
+			entry.Type = "file"
+			entry.Size = header.Size
+			if digest, ok := header.PAXRecords[_objectRecord]; ok {
+				entry.Size, _ = strconv.ParseInt(header.PAXRecords[_objectSizeRecord], 10, 64)
+				if isDigest(digest) {
+					entry.Object = objectPath(ci.objects, digest).ToString()
+				}
+				manifest.AddChecksum(header.Name, digest)
+			} else if _, err := io.Copy(io.Discard, manifest.Reader(header.Name, tr)); err != nil {
+				return entries, err
+			}
+		case tar.TypeDir:
+			entry.Type = "directory"
+			manifest.Add(header.Name)
+		case tar.TypeSymlink:
+			entry.Type = "symlink"
+			entry.Linkname = header.Linkname
+			manifest.Add(header.Name)
+		default:
+			return entries, errUnsupportedFileType
+		}
+		entries = append(entries, entry)
+	}
+	if err := manifest.Verify(); err != nil {
+		return entries, err
+	}
+
+	checksums := manifest.checksums()
+	for i := range entries {
+		entries[i].Checksum = checksums[entries[i].Name]
+	}
+	return entries, closeError
+}
@@ -0,0 +1,28 @@
+package cacheitem
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestList(t *testing.T) {
+	for _, compressed := range []bool{false, true} {
+		cacheItem, err := Open(createArtifact(t, compressed))
+		assert.NilError(t, err, "Open")
+		entries, err := cacheItem.List()
+		assert.NilError(t, err, "List")
+		assert.NilError(t, cacheItem.Close(), "Close")
+
+		assert.Equal(t, len(entries), 3)
+		assert.Equal(t, entries[0].Name, "dist/")
+		assert.Equal(t, entries[0].Type, "directory")
+		assert.Equal(t, entries[0].Checksum, "")
+		assert.Equal(t, entries[1].Name, "dist/index.js")
+		assert.Equal(t, entries[1].Type, "file")
+		assert.Equal(t, entries[1].Size, int64(len("console.log('hello')")))
+		assert.Equal(t, len(entries[1].Checksum), 64)
+		assert.Equal(t, entries[2].Name, "dist/empty.js")
+		assert.Equal(t, entries[2].Size, int64(0))
+	}
+}
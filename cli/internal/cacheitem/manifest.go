@@ -0,0 +1,250 @@
+package cacheitem
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+)
+
+// ErrCorrupted is returned when the files restored from an artifact don't match
+// its manifest, for example because the artifact was truncated
+var ErrCorrupted = errors.New("cache artifact is corrupted")
+
+const (
+	// _manifestRecord is set in a PAX global header at the start of artifacts
+	// that end with a manifest, so that a missing manifest is detected
+	_manifestRecord = "TURBO.manifest"
+	// _manifestVersion is the checksum used by the manifest
+	_manifestVersion = "sha256"
+	// _checksumsRecord holds checksums of the entries in the PAX global headers at
+	// the end of the artifact, as a JSON object from entry name to checksum
+	_checksumsRecord = "TURBO.checksums"
+	// _modesRecord is set in the marker of artifacts whose entries have exactly
+	// the modes that they had on disk, so that they are restored with exactly
+	// those modes. See Modes.
+	_modesRecord  = "TURBO.modes"
+	_modesVersion = "exact"
+	// _checksumsChunkSize bounds the size of each of those headers, which tar
+	// limits to 1MiB
+	_checksumsChunkSize = 256 * 1024
+)
+
+// Manifest records the entries of an artifact as they are written or restored,
+// with the SHA-256 checksum of each regular file. Directories and symlinks have
+// an empty checksum.
+type Manifest struct {
+	entries map[string]hash.Hash
+	// archive counts the bytes of the artifact being restored
+	archive *countingReader
+	// marked is whether the artifact being restored ends with a manifest
+	marked bool
+	// exactModes is whether the artifact being restored records exact modes
+	exactModes bool
+	// expected are the checksums read from the artifact being restored
+	expected map[string]string
+	// known are the checksums of the files that aren't read, such as those in
+	// the object store
+	known map[string]string
+}
+
+// NewManifest returns an empty Manifest
+func NewManifest() *Manifest {
+	return &Manifest{entries: make(map[string]hash.Hash), known: make(map[string]string)}
+}
+
+// Archive returns a Reader for the tar of the artifact being restored. A tar
+// ends with two empty blocks, so reading nothing means that the artifact was
+// cut off, which zstd doesn't report.
+func (m *Manifest) Archive(r io.Reader) io.Reader {
+	m.archive = &countingReader{r: r}
+	return m.archive
+}
+
+// Add records a directory or symlink
+func (m *Manifest) Add(name string) {
+	m.entries[name] = nil
+}
+
+// AddChecksum records a regular file whose checksum is already known
+func (m *Manifest) AddChecksum(name string, checksum string) {
+	m.known[name] = checksum
+}
+
+// Writer returns a Writer for the contents of a regular file that records their checksum
+func (m *Manifest) Writer(name string, w io.Writer) io.Writer {
+	h := sha256.New()
+	m.entries[name] = h
+	return io.MultiWriter(w, h)
+}
+
+// Reader returns a Reader for the contents of a regular file that records their
+// checksum. Failing to read them means the artifact is corrupted.
+func (m *Manifest) Reader(name string, r io.Reader) io.Reader {
+	h := sha256.New()
+	m.entries[name] = h
+	return &corruptionReader{io.TeeReader(r, h)}
+}
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+// corruptionReader wraps the errors reading an artifact with ErrCorrupted
+type corruptionReader struct {
+	r io.Reader
+}
+
+func (cr *corruptionReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = fmt.Errorf("%w: %v", ErrCorrupted, err)
+	}
+	return n, err
+}
+
+func (m *Manifest) checksums() map[string]string {
+	checksums := make(map[string]string, len(m.entries))
+	for name, h := range m.entries {
+		if h == nil {
+			checksums[name] = ""
+		} else {
+			checksums[name] = hex.EncodeToString(h.Sum(nil))
+		}
+	}
+	for name, checksum := range m.known {
+		checksums[name] = checksum
+	}
+	return checksums
+}
+
+// WriteMarker marks an artifact as ending with a manifest. It is written before
+// any entry.
+func (m *Manifest) WriteMarker(tw *tar.Writer) error {
+	return tw.WriteHeader(&tar.Header{
+		Typeflag:   tar.TypeXGlobalHeader,
+		PAXRecords: map[string]string{_manifestRecord: _manifestVersion, _modesRecord: _modesVersion},
+	})
+}
+
+// WriteChecksums writes the checksums of the recorded entries, once every entry
+// has been written
+func (m *Manifest) WriteChecksums(tw *tar.Writer) error {
+	checksums := m.checksums()
+	names := make([]string, 0, len(checksums))
+	for name := range checksums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	chunk := make(map[string]string)
+	size := 0
+	flush := func() error {
+		contents, err := json.Marshal(chunk)
+		if err != nil {
+			return err
+		}
+		chunk = make(map[string]string)
+		size = 0
+		return tw.WriteHeader(&tar.Header{
+			Typeflag:   tar.TypeXGlobalHeader,
+			PAXRecords: map[string]string{_checksumsRecord: string(contents)},
+		})
+	}
+	for _, name := range names {
+		chunk[name] = checksums[name]
+		size += len(name) + len(checksums[name]) + 8
+		if size >= _checksumsChunkSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if len(chunk) > 0 || len(names) == 0 {
+		return flush()
+	}
+	return nil
+}
+
+// ReadHeader reads the marker or checksums in a PAX global header of an
+// artifact being restored. Other global headers are ignored.
+func (m *Manifest) ReadHeader(header *tar.Header) error {
+	if header.PAXRecords[_manifestRecord] == _manifestVersion {
+		m.marked = true
+	}
+	if header.PAXRecords[_modesRecord] == _modesVersion {
+		m.exactModes = true
+	}
+	contents, ok := header.PAXRecords[_checksumsRecord]
+	if !ok {
+		return nil
+	}
+	var checksums map[string]string
+	if err := json.Unmarshal([]byte(contents), &checksums); err != nil {
+		return fmt.Errorf("%w: the manifest is malformed: %v", ErrCorrupted, err)
+	}
+	if m.expected == nil {
+		m.expected = make(map[string]string, len(checksums))
+	}
+	for name, checksum := range checksums {
+		m.expected[name] = checksum
+	}
+	return nil
+}
+
+// ExactModes returns whether the artifact being restored records the exact modes
+// of its entries. It is known once the marker, the first header, is read.
+func (m *Manifest) ExactModes() bool {
+	return m.exactModes
+}
+
+// Verify checks that the restored entries are the ones in the manifest, with the
+// same contents. Artifacts without a manifest, written by older versions, pass.
+func (m *Manifest) Verify() error {
+	if m.archive != nil && m.archive.n == 0 {
+		return fmt.Errorf("%w: the artifact is empty", ErrCorrupted)
+	}
+	if !m.marked {
+		return nil
+	}
+	if m.expected == nil {
+		return fmt.Errorf("%w: the manifest is missing", ErrCorrupted)
+	}
+	actual := m.checksums()
+	names := make([]string, 0, len(actual))
+	for name := range actual {
+		names = append(names, name)
+	}
+	for name := range m.expected {
+		if _, ok := actual[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		checksum, restored := actual[name]
+		expected, listed := m.expected[name]
+		switch {
+		case !restored:
+			return fmt.Errorf("%w: %v is missing", ErrCorrupted, name)
+		case !listed:
+			return fmt.Errorf("%w: %v is not in the manifest", ErrCorrupted, name)
+		case checksum != expected:
+			return fmt.Errorf("%w: %v does not match its checksum", ErrCorrupted, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package cacheitem
+
+import (
+	"archive/tar"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func createArtifact(t *testing.T, compressed bool) turbopath.AbsoluteSystemPath {
+	t.Helper()
+	inputDir := turbopath.AbsoluteSystemPath(t.TempDir())
+	assert.NilError(t, inputDir.UntypedJoin("dist").MkdirAll(0755), "MkdirAll")
+	assert.NilError(t, inputDir.UntypedJoin("dist", "index.js").WriteFile([]byte("console.log('hello')"), 0644), "WriteFile")
+	assert.NilError(t, inputDir.UntypedJoin("dist", "empty.js").WriteFile([]byte{}, 0644), "WriteFile")
+
+	name := "out.tar"
+	if compressed {
+		name = "out.tar.zst"
+	}
+	archivePath := turbopath.AbsoluteSystemPath(t.TempDir()).UntypedJoin(name)
+	cacheItem, err := Create(archivePath)
+	assert.NilError(t, err, "Create")
+	for _, file := range []string{"dist", "dist/index.js", "dist/empty.js"} {
+		assert.NilError(t, cacheItem.AddFile(inputDir, turbopath.AnchoredUnixPath(file).ToSystemPath()), "AddFile")
+	}
+	assert.NilError(t, cacheItem.Close(), "Close")
+	return archivePath
+}
+
+func restoreArtifact(t *testing.T, archivePath turbopath.AbsoluteSystemPath) error {
+	t.Helper()
+	cacheItem, err := Open(archivePath)
+	assert.NilError(t, err, "Open")
+	_, restoreErr := cacheItem.Restore(generateAnchor(t))
+	assert.NilError(t, cacheItem.Close(), "Close")
+	return restoreErr
+}
+
+func TestManifest(t *testing.T) {
+	for _, compressed := range []bool{false, true} {
+		assert.NilError(t, restoreArtifact(t, createArtifact(t, compressed)), "Restore")
+	}
+
+	// Cutting the artifact short loses its checksums, after which only the two
+	// empty blocks ending the archive are written
+	archivePath := createArtifact(t, false)
+	contents, err := archivePath.ReadFile()
+	assert.NilError(t, err, "ReadFile")
+	for _, size := range []int{len(contents) - 1536, len(contents) / 2, 1536} {
+		truncated := turbopath.AbsoluteSystemPath(t.TempDir()).UntypedJoin("out.tar")
+		assert.NilError(t, truncated.WriteFile(contents[:size], 0644), "WriteFile")
+		assert.ErrorIs(t, restoreArtifact(t, truncated), ErrCorrupted)
+	}
+
+	compressedPath := createArtifact(t, true)
+	contents, err = compressedPath.ReadFile()
+	assert.NilError(t, err, "ReadFile")
+	truncated := turbopath.AbsoluteSystemPath(t.TempDir()).UntypedJoin("out.tar.zst")
+	assert.NilError(t, truncated.WriteFile(contents[:len(contents)/2], 0644), "WriteFile")
+	assert.ErrorIs(t, restoreArtifact(t, truncated), ErrCorrupted)
+}
+
+func TestManifestVerify(t *testing.T) {
+	marker := tarFile{Header: &tar.Header{
+		Typeflag:   tar.TypeXGlobalHeader,
+		PAXRecords: map[string]string{_manifestRecord: _manifestVersion},
+	}}
+	checksums := func(contents string) tarFile {
+		return tarFile{Header: &tar.Header{
+			Typeflag:   tar.TypeXGlobalHeader,
+			PAXRecords: map[string]string{_checksumsRecord: contents},
+		}}
+	}
+	file := tarFile{
+		Header: &tar.Header{Name: "index.js", Typeflag: tar.TypeReg, Mode: 0644},
+		Body:   "hello",
+	}
+	// sha256 of "hello"
+	hello := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+	tests := []struct {
+		name     string
+		tarFiles []tarFile
+		wantErr  error
+	}{
+		{
+			name:     "matching checksums",
+			tarFiles: []tarFile{marker, file, checksums(`{"index.js":"` + hello + `"}`)},
+		},
+		{
+			name:     "written before manifests",
+			tarFiles: []tarFile{file},
+		},
+		{
+			name:     "checksum mismatch",
+			tarFiles: []tarFile{marker, file, checksums(`{"index.js":"00"}`)},
+			wantErr:  ErrCorrupted,
+		},
+		{
+			name:     "missing file",
+			tarFiles: []tarFile{marker, file, checksums(`{"index.js":"` + hello + `","other.js":""}`)},
+			wantErr:  ErrCorrupted,
+		},
+		{
+			name:     "file not in the manifest",
+			tarFiles: []tarFile{marker, file, checksums(`{}`)},
+			wantErr:  ErrCorrupted,
+		},
+		{
+			name:     "missing manifest",
+			tarFiles: []tarFile{marker, file},
+			wantErr:  ErrCorrupted,
+		},
+		{
+			name:     "malformed manifest",
+			tarFiles: []tarFile{marker, file, checksums(`{"index.js"`)},
+			wantErr:  ErrCorrupted,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := restoreArtifact(t, generateTar(t, tt.tarFiles))
+			if tt.wantErr == nil {
+				assert.NilError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package cacheitem
+
+import (
+	"archive/tar"
+	"os"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// Modes sets the modes of the files and directories restored from an artifact to
+// the modes that they were archived with. Creating a file or directory applies
+// the umask to its mode, and doesn't change the mode of one that exists, so a
+// restored CLI could otherwise lose its executable bit. Symlinks are restored
+// with their mode already.
+//
+// Artifacts written by older versions don't record that their modes are exact,
+// and are restored as they always were, with the umask applied to new files and
+// directories.
+type Modes struct {
+	manifest *Manifest
+	// dirs are set once the whole artifact is restored, since files can't be
+	// restored into a directory without write permission
+	dirs []dirMode
+}
+
+type dirMode struct {
+	path turbopath.AbsoluteSystemPath
+	mode os.FileMode
+}
+
+// NewModes returns the Modes of the artifact that manifest reads
+func NewModes(manifest *Manifest) *Modes {
+	return &Modes{manifest: manifest}
+}
+
+// Set sets the mode of a restored regular file, or of a restored directory once
+// Finish is called
+func (m *Modes) Set(path turbopath.AbsoluteSystemPath, header *tar.Header) error {
+	if !m.manifest.ExactModes() {
+		return nil
+	}
+	mode := os.FileMode(header.Mode).Perm()
+	switch header.Typeflag {
+	case tar.TypeReg:
+		return path.Chmod(mode)
+	case tar.TypeDir:
+		m.dirs = append(m.dirs, dirMode{path: path, mode: mode})
+	}
+	return nil
+}
+
+// Finish sets the modes of the restored directories, deepest first
+func (m *Modes) Finish() error {
+	for i := len(m.dirs) - 1; i >= 0; i-- {
+		if err := m.dirs[i].path.Chmod(m.dirs[i].mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+//go:build darwin || linux
+// +build darwin linux
+
+package cacheitem
+
+import (
+	"archive/tar"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+// pnpmOutputs are the outputs of a task that installs a package with pnpm: the
+// package is in the virtual store, and node_modules links to it and its CLI
+var pnpmOutputs = []createFileDefinition{
+	{Path: "node_modules", FileMode: os.ModeDir | 0755},
+	{Path: "node_modules/.bin", FileMode: os.ModeDir | 0755},
+	{Path: "node_modules/.bin/cli", Linkname: "../pkg/bin/cli.js", FileMode: os.ModeSymlink | 0777},
+	{Path: "node_modules/.pnpm", FileMode: os.ModeDir | 0755},
+	{Path: "node_modules/.pnpm/pkg@1.0.0", FileMode: os.ModeDir | 0755},
+	{Path: "node_modules/.pnpm/pkg@1.0.0/node_modules", FileMode: os.ModeDir | 0755},
+	{Path: "node_modules/.pnpm/pkg@1.0.0/node_modules/pkg", FileMode: os.ModeDir | 0755},
+	{Path: "node_modules/.pnpm/pkg@1.0.0/node_modules/pkg/bin", FileMode: os.ModeDir | 0755},
+	{Path: "node_modules/.pnpm/pkg@1.0.0/node_modules/pkg/bin/cli.js", FileMode: 0755},
+	{Path: "node_modules/.pnpm/pkg@1.0.0/node_modules/pkg/index.js", FileMode: 0644},
+	{Path: "node_modules/.pnpm/pkg@1.0.0/node_modules/pkg/cache", FileMode: os.ModeDir | 0700},
+	{Path: "node_modules/pkg", Linkname: ".pnpm/pkg@1.0.0/node_modules/pkg", FileMode: os.ModeSymlink | 0777},
+}
+
+func TestRestoreModes(t *testing.T) {
+	inputDir := turbopath.AbsoluteSystemPath(t.TempDir())
+	archiveDir := turbopath.AbsoluteSystemPath(t.TempDir())
+	for _, compressed := range []bool{false, true} {
+		for _, contentAddressed := range []bool{false, true} {
+			if compressed && contentAddressed {
+				continue
+			}
+			archivePath := archiveDir.UntypedJoin("out.tar")
+			if compressed {
+				archivePath = archiveDir.UntypedJoin("out.tar.zst")
+			}
+			create := Create
+			if contentAddressed {
+				create = CreateContentAddressed
+			}
+			cacheItem, err := create(archivePath)
+			assert.NilError(t, err, "Create")
+			for _, file := range pnpmOutputs {
+				if !file.Path.RestoreAnchor(inputDir).Exists() {
+					assert.NilError(t, createEntry(t, inputDir, file), "createEntry")
+				}
+				assert.NilError(t, cacheItem.AddFile(inputDir, file.Path), "AddFile")
+			}
+			assert.NilError(t, cacheItem.Close(), "Close")
+
+			// The CLI exists without its executable bit, and the umask would
+			// take it away from new files and directories
+			anchor := generateAnchor(t)
+			cli := anchor.UntypedJoin("node_modules", ".pnpm", "pkg@1.0.0", "node_modules", "pkg", "bin", "cli.js")
+			assert.NilError(t, cli.Dir().MkdirAll(0755), "MkdirAll")
+			assert.NilError(t, cli.WriteFile([]byte("stale"), 0644), "WriteFile")
+			umask := syscall.Umask(0077)
+			cacheItem, err = Open(archivePath)
+			assert.NilError(t, err, "Open")
+			_, err = cacheItem.Restore(anchor)
+			syscall.Umask(umask)
+			assert.NilError(t, err, "Restore")
+			assert.NilError(t, cacheItem.Close(), "Close")
+
+			for _, file := range pnpmOutputs {
+				assertFileExists(t, anchor, restoreFile{
+					Name:     file.Path.ToUnixPath(),
+					Linkname: file.Linkname,
+					FileMode: file.FileMode,
+				})
+			}
+			contents, err := anchor.UntypedJoin("node_modules", ".bin", "cli").ReadFile()
+			assert.NilError(t, err, "ReadFile")
+			assert.Equal(t, string(contents), "file contents", "the CLI resolves through both symlinks")
+		}
+	}
+}
+
+func TestRestoreModesOlderArtifacts(t *testing.T) {
+	// Artifacts written before modes were exact are restored as they always
+	// were: the mode of an existing file is kept
+	archivePath := generateTar(t, []tarFile{
+		{Header: &tar.Header{Name: "cli.js", Typeflag: tar.TypeReg, Mode: 0755}, Body: "file contents"},
+	})
+	anchor := generateAnchor(t)
+	assert.NilError(t, anchor.UntypedJoin("cli.js").WriteFile([]byte("stale"), 0644), "WriteFile")
+
+	cacheItem, err := Open(archivePath)
+	assert.NilError(t, err, "Open")
+	_, err = cacheItem.Restore(anchor)
+	assert.NilError(t, err, "Restore")
+	assert.NilError(t, cacheItem.Close(), "Close")
+	assertFileExists(t, anchor, restoreFile{Name: "cli.js", FileMode: 0644})
+}
@@ -0,0 +1,261 @@
+package cacheitem
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/moby/sys/sequential"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// _objectsDirName is the directory of the object store, next to the artifacts
+// that refer to it
+const _objectsDirName = "objects"
+
+const (
+	// _objectRecord holds the SHA-256 of a regular file whose contents are in
+	// the object store, rather than in the tar
+	_objectRecord = "TURBO.object"
+	// _objectSizeRecord is the size of the file, since the tar entry is empty
+	_objectSizeRecord = "TURBO.objectSize"
+	// _objectModTimeRecord is the modification time of the object when the
+	// artifact was written, in nanoseconds. Writing to a file that was
+	// restored as a hardlink writes to the object, which changes it.
+	_objectModTimeRecord = "TURBO.objectModTime"
+)
+
+// ObjectsDir returns the object store of the content-addressed artifacts in dir
+func ObjectsDir(dir turbopath.AbsoluteSystemPath) turbopath.AbsoluteSystemPath {
+	return dir.UntypedJoin(_objectsDirName)
+}
+
+// objectPath returns where the contents with the given SHA-256 are stored
+func objectPath(objects turbopath.AbsoluteSystemPath, digest string) turbopath.AbsoluteSystemPath {
+	return objects.UntypedJoin(digest[:2], digest)
+}
+
+// isDigest checks that a digest read from an artifact can't point outside of
+// the object store
+func isDigest(digest string) bool {
+	if len(digest) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(digest)
+	return err == nil
+}
+
+// CreateContentAddressed makes a new CacheItem at path that keeps the contents
+// of regular files in the object store next to it, where they are shared by
+// every artifact that has the same file. The tar only holds the metadata of
+// the files, so it isn't compressed.
+func CreateContentAddressed(path turbopath.AbsoluteSystemPath) (*CacheItem, error) {
+	if strings.HasSuffix(path.ToString(), ".zst") {
+		return nil, errors.New("content-addressed artifacts are not compressed")
+	}
+	objects := ObjectsDir(path.Dir())
+	if err := objects.MkdirAll(0755); err != nil {
+		return nil, err
+	}
+	cacheItem, err := Create(path)
+	if err != nil {
+		return nil, err
+	}
+	cacheItem.objects = objects
+	return cacheItem, nil
+}
+
+// addObject copies the contents of a regular file into the object store, unless
+// they are there already, and writes a header that refers to them.
+func (ci *CacheItem) addObject(header *tar.Header, sourcePath turbopath.AbsoluteSystemPath) error {
+	sourceFile, err := sequential.OpenFile(longPath(sourcePath.ToString()), os.O_RDONLY, 0777)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = sourceFile.Close() }()
+
+	// The contents are written to a temporary file first, since their digest
+	// isn't known until they have all been read
+	temp, err := os.CreateTemp(longPath(ci.objects.ToString()), ".tmp-")
+	if err != nil {
+		return err
+	}
+	tempPath := temp.Name()
+	defer func() { _ = os.Remove(tempPath) }()
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(temp, h), sourceFile); err != nil {
+		_ = temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	digest := hex.EncodeToString(h.Sum(nil))
+
+	object := objectPath(ci.objects, digest)
+	if !objectMatches(object, digest) {
+		if err := os.Chmod(tempPath, os.FileMode(header.Mode).Perm()); err != nil {
+			return err
+		}
+		if err := object.Dir().MkdirAll(0755); err != nil {
+			return err
+		}
+		if err := os.Rename(tempPath, longPath(object.ToString())); err != nil {
+			return err
+		}
+	}
+	info, err := object.Lstat()
+	if err != nil {
+		return err
+	}
+
+	header.Size = 0
+	if header.PAXRecords == nil {
+		header.PAXRecords = make(map[string]string)
+	}
+	header.PAXRecords[_objectRecord] = digest
+	header.PAXRecords[_objectSizeRecord] = strconv.FormatInt(info.Size(), 10)
+	header.PAXRecords[_objectModTimeRecord] = strconv.FormatInt(info.ModTime().UnixNano(), 10)
+	if err := ci.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	ci.manifest.AddChecksum(header.Name, digest)
+	return nil
+}
+
+// objectMatches checks that the object exists and still has the contents that
+// it is named after. An object that was written to through a hardlink doesn't,
+// and is replaced.
+func objectMatches(object turbopath.AbsoluteSystemPath, digest string) bool {
+	f, err := os.Open(longPath(object.ToString()))
+	if err != nil {
+		return false
+	}
+	defer func() { _ = f.Close() }()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return false
+	}
+	return hex.EncodeToString(h.Sum(nil)) == digest
+}
+
+// checkObject returns the object that a header refers to, if it hasn't changed
+// since the artifact was written
+func (ci *CacheItem) checkObject(header *tar.Header) (turbopath.AbsoluteSystemPath, os.FileInfo, error) {
+	digest := header.PAXRecords[_objectRecord]
+	if !isDigest(digest) {
+		return "", nil, fmt.Errorf("%w: %v refers to an invalid object", ErrCorrupted, header.Name)
+	}
+	object := objectPath(ci.objects, digest)
+	info, err := object.Lstat()
+	if os.IsNotExist(err) {
+		return "", nil, fmt.Errorf("%w: the contents of %v are missing from the object store", ErrCorrupted, header.Name)
+	} else if err != nil {
+		return "", nil, err
+	}
+	size, sizeErr := strconv.ParseInt(header.PAXRecords[_objectSizeRecord], 10, 64)
+	modTime, modTimeErr := strconv.ParseInt(header.PAXRecords[_objectModTimeRecord], 10, 64)
+	if sizeErr != nil || modTimeErr != nil || !info.Mode().IsRegular() || info.Size() != size || info.ModTime().UnixNano() != modTime {
+		return "", nil, fmt.Errorf("%w: the contents of %v have changed in the object store", ErrCorrupted, header.Name)
+	}
+	return object, info, nil
+}
+
+// restoreObject restores a regular file from the object store
+func (ci *CacheItem) restoreObject(dirCache *cachedDirTree, anchor turbopath.AbsoluteSystemPath, header *tar.Header) (turbopath.AnchoredSystemPath, error) {
+	processedName, err := canonicalizeName(header.Name)
+	if err != nil {
+		return "", err
+	}
+	object, info, err := ci.checkObject(header)
+	if err != nil {
+		return "", err
+	}
+	if err := safeMkdirFile(dirCache, anchor, processedName, header.Mode); err != nil {
+		return "", err
+	}
+
+	// A file that was restored as a hardlink is an object, so it is replaced
+	// rather than written to, unless it is unchanged
+	target := processedName.RestoreAnchor(anchor)
+	if objectUnchanged(target, info, header.PAXRecords[_objectRecord]) {
+		return processedName, nil
+	}
+	if err := target.Remove(); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	mode := os.FileMode(header.Mode).Perm()
+	if err := ci.placeObject(object, target, mode, info.Mode().Perm()); err != nil {
+		return "", err
+	}
+	return processedName, nil
+}
+
+// placeObject puts a copy of the object at target, as a copy-on-write clone if
+// the filesystem supports them, or else as a hardlink. The object is copied on
+// filesystems that support neither, such as when the cache is on another
+// device than the repository. A hardlink would also change the mode of the
+// object, so objects with another mode are copied as well.
+func (ci *CacheItem) placeObject(object turbopath.AbsoluteSystemPath, target turbopath.AbsoluteSystemPath, mode os.FileMode, objectMode os.FileMode) error {
+	if !ci.noClone {
+		err := cloneFile(object, target, mode)
+		if err == nil {
+			return nil
+		}
+		// Whatever the reason, the next objects won't be cloned either
+		ci.noClone = true
+		_ = target.Remove()
+	}
+	if !ci.noLink && mode == objectMode {
+		if err := os.Link(longPath(object.ToString()), longPath(target.ToString())); err == nil {
+			return nil
+		}
+		ci.noLink = true
+	}
+	return copyObject(object, target, mode)
+}
+
+func copyObject(object turbopath.AbsoluteSystemPath, target turbopath.AbsoluteSystemPath, mode os.FileMode) error {
+	source, err := sequential.OpenFile(longPath(object.ToString()), os.O_RDONLY, 0777)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = source.Close() }()
+	f, err := target.OpenFile(os.O_WRONLY|os.O_TRUNC|os.O_CREATE, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, source); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Objects returns the files in the object store that the CacheItem refers to.
+// Artifacts that aren't content-addressed refer to none.
+func (ci *CacheItem) Objects() ([]turbopath.AbsoluteSystemPath, error) {
+	if ci.compressed {
+		return nil, nil
+	}
+	tr := tar.NewReader(ci.handle)
+	objects := []turbopath.AbsoluteSystemPath{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return objects, nil
+		}
+		if err != nil {
+			return objects, fmt.Errorf("%w: %v", ErrCorrupted, err)
+		}
+		if digest, ok := header.PAXRecords[_objectRecord]; ok && isDigest(digest) {
+			objects = append(objects, objectPath(ci.objects, digest))
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package cacheitem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func createContentAddressedArtifact(t *testing.T, inputDir turbopath.AbsoluteSystemPath, archivePath turbopath.AbsoluteSystemPath) {
+	t.Helper()
+	cacheItem, err := CreateContentAddressed(archivePath)
+	assert.NilError(t, err, "CreateContentAddressed")
+	for _, file := range []string{"dist", "dist/index.js", "dist/copy.js", "dist/run.sh"} {
+		assert.NilError(t, cacheItem.AddFile(inputDir, turbopath.AnchoredUnixPath(file).ToSystemPath()), "AddFile")
+	}
+	assert.NilError(t, cacheItem.Close(), "Close")
+}
+
+func writeContentAddressedInputs(t *testing.T) turbopath.AbsoluteSystemPath {
+	t.Helper()
+	inputDir := turbopath.AbsoluteSystemPath(t.TempDir())
+	assert.NilError(t, inputDir.UntypedJoin("dist").MkdirAll(0755), "MkdirAll")
+	assert.NilError(t, inputDir.UntypedJoin("dist", "index.js").WriteFile([]byte("console.log('hello')"), 0644), "WriteFile")
+	assert.NilError(t, inputDir.UntypedJoin("dist", "copy.js").WriteFile([]byte("console.log('hello')"), 0644), "WriteFile")
+	assert.NilError(t, inputDir.UntypedJoin("dist", "run.sh").WriteFile([]byte("#!/bin/sh"), 0755), "WriteFile")
+	return inputDir
+}
+
+func countObjects(t *testing.T, cacheDir turbopath.AbsoluteSystemPath) int {
+	t.Helper()
+	objects, err := filepath.Glob(ObjectsDir(cacheDir).UntypedJoin("*", "*").ToString())
+	assert.NilError(t, err, "Glob")
+	return len(objects)
+}
+
+func TestContentAddressed(t *testing.T) {
+	inputDir := writeContentAddressedInputs(t)
+	cacheDir := turbopath.AbsoluteSystemPath(t.TempDir())
+	createContentAddressedArtifact(t, inputDir, cacheDir.UntypedJoin("first.tar"))
+	// Files with the same contents share an object
+	assert.Equal(t, countObjects(t, cacheDir), 2)
+	createContentAddressedArtifact(t, inputDir, cacheDir.UntypedJoin("second.tar"))
+	assert.Equal(t, countObjects(t, cacheDir), 2)
+
+	cacheItem, err := Open(cacheDir.UntypedJoin("second.tar"))
+	assert.NilError(t, err, "Open")
+	entries, err := cacheItem.List()
+	assert.NilError(t, err, "List")
+	assert.NilError(t, cacheItem.Close(), "Close")
+	assert.Equal(t, len(entries), 4)
+	assert.Equal(t, entries[1].Name, "dist/index.js")
+	assert.Equal(t, entries[1].Size, int64(len("console.log('hello')")))
+	assert.Equal(t, entries[1].Object, ObjectsDir(cacheDir).UntypedJoin(entries[1].Checksum[:2], entries[1].Checksum).ToString())
+	assert.Equal(t, entries[2].Object, entries[1].Object)
+
+	cacheItem, err = Open(cacheDir.UntypedJoin("first.tar"))
+	assert.NilError(t, err, "Open")
+	objects, err := cacheItem.Objects()
+	assert.NilError(t, err, "Objects")
+	assert.NilError(t, cacheItem.Close(), "Close")
+	assert.Equal(t, len(objects), 3)
+
+	anchor := generateAnchor(t)
+	cacheItem, err = Open(cacheDir.UntypedJoin("first.tar"))
+	assert.NilError(t, err, "Open")
+	restored, err := cacheItem.Restore(anchor)
+	assert.NilError(t, err, "Restore")
+	assert.NilError(t, cacheItem.Close(), "Close")
+	assert.Equal(t, len(restored), 4)
+	contents, err := anchor.UntypedJoin("dist", "copy.js").ReadFile()
+	assert.NilError(t, err, "ReadFile")
+	assert.Equal(t, string(contents), "console.log('hello')")
+	info, err := anchor.UntypedJoin("dist", "run.sh").Lstat()
+	assert.NilError(t, err, "Lstat")
+	assert.Equal(t, info.Mode().Perm()&0100, os.FileMode(0100))
+}
+
+func TestContentAddressedCopies(t *testing.T) {
+	inputDir := writeContentAddressedInputs(t)
+	cacheDir := turbopath.AbsoluteSystemPath(t.TempDir())
+	createContentAddressedArtifact(t, inputDir, cacheDir.UntypedJoin("the-hash.tar"))
+
+	anchor := generateAnchor(t)
+	cacheItem, err := Open(cacheDir.UntypedJoin("the-hash.tar"))
+	assert.NilError(t, err, "Open")
+	// As on filesystems that support neither clones nor hardlinks
+	cacheItem.noClone = true
+	cacheItem.noLink = true
+	_, err = cacheItem.Restore(anchor)
+	assert.NilError(t, err, "Restore")
+	assert.NilError(t, cacheItem.Close(), "Close")
+
+	entries := restoredObjectEntries(t, cacheDir.UntypedJoin("the-hash.tar"))
+	restoredInfo, err := anchor.UntypedJoin("dist", "index.js").Lstat()
+	assert.NilError(t, err, "Lstat")
+	objectInfo, err := os.Lstat(entries["dist/index.js"])
+	assert.NilError(t, err, "Lstat")
+	assert.Assert(t, !os.SameFile(restoredInfo, objectInfo))
+}
+
+func TestContentAddressedChangedObject(t *testing.T) {
+	inputDir := writeContentAddressedInputs(t)
+	cacheDir := turbopath.AbsoluteSystemPath(t.TempDir())
+	archivePath := cacheDir.UntypedJoin("the-hash.tar")
+	createContentAddressedArtifact(t, inputDir, archivePath)
+
+	// As if a build wrote to a file that was restored as a hardlink
+	object := restoredObjectEntries(t, archivePath)["dist/index.js"]
+	f, err := os.OpenFile(object, os.O_WRONLY|os.O_APPEND, 0)
+	assert.NilError(t, err, "OpenFile")
+	_, err = f.WriteString("console.log('changed')")
+	assert.NilError(t, err, "WriteString")
+	assert.NilError(t, f.Close(), "Close")
+	assert.ErrorIs(t, restoreArtifact(t, archivePath), ErrCorrupted)
+
+	// Writing the artifact again replaces the object
+	createContentAddressedArtifact(t, inputDir, archivePath)
+	assert.NilError(t, restoreArtifact(t, archivePath))
+}
+
+// restoredObjectEntries returns the objects of the regular files of an artifact
+func restoredObjectEntries(t *testing.T, archivePath turbopath.AbsoluteSystemPath) map[string]string {
+	t.Helper()
+	cacheItem, err := Open(archivePath)
+	assert.NilError(t, err, "Open")
+	entries, err := cacheItem.List()
+	assert.NilError(t, err, "List")
+	assert.NilError(t, cacheItem.Close(), "Close")
+	objects := make(map[string]string)
+	for _, entry := range entries {
+		if entry.Object != "" {
+			objects[entry.Name] = entry.Object
+		}
+	}
+	return objects
+}
@@ -3,6 +3,7 @@ package cacheitem
 import (
 	"archive/tar"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"runtime"
@@ -16,7 +17,7 @@ import (
 
 // Open returns an existing CacheItem at the specified path.
 func Open(path turbopath.AbsoluteSystemPath) (*CacheItem, error) {
-	handle, err := sequential.OpenFile(path.ToString(), os.O_RDONLY, 0777)
+	handle, err := sequential.OpenFile(longPath(path.ToString()), os.O_RDONLY, 0777)
 	if err != nil {
 		return nil, err
 	}
@@ -25,6 +26,7 @@ func Open(path turbopath.AbsoluteSystemPath) (*CacheItem, error) {
 		Path:       path,
 		handle:     handle,
 		compressed: strings.HasSuffix(path.ToString(), ".zst"),
+		objects:    ObjectsDir(path.Dir()),
 	}, nil
 }
 
@@ -32,6 +34,8 @@ func Open(path turbopath.AbsoluteSystemPath) (*CacheItem, error) {
 func (ci *CacheItem) Restore(anchor turbopath.AbsoluteSystemPath) ([]turbopath.AnchoredSystemPath, error) {
 	var tr *tar.Reader
 	var closeError error
+	manifest := NewManifest()
+	modes := NewModes(manifest)
 
 	// We're reading a tar, possibly wrapped in zstd.
 	if ci.compressed {
@@ -42,9 +46,9 @@ func (ci *CacheItem) Restore(anchor turbopath.AbsoluteSystemPath) ([]turbopath.A
 		// set without triggering one of the numerous other errors, but we should still
 		// handle that possible edge case.
 		defer func() { closeError = zr.Close() }()
-		tr = tar.NewReader(zr)
+		tr = tar.NewReader(manifest.Archive(zr))
 	} else {
-		tr = tar.NewReader(ci.handle)
+		tr = tar.NewReader(manifest.Archive(ci.handle))
 	}
 
 	// On first attempt to restore it's possible that a link target doesn't exist.
@@ -83,18 +87,53 @@ func (ci *CacheItem) Restore(anchor turbopath.AbsoluteSystemPath) ([]turbopath.A
 			if symlinksErr != nil {
 				return restored, symlinksErr
 			}
+			if err := modes.Finish(); err != nil {
+				return restored, err
+			}
+			if err := manifest.Verify(); err != nil {
+				return restored, err
+			}
 
 			break
 		}
 		if trErr != nil {
-			return restored, trErr
+			// The tar was cut off or damaged
+			return restored, fmt.Errorf("%w: %v", ErrCorrupted, trErr)
+		}
+
+		// The manifest and its marker are in global headers, which aren't files
+		if header.Typeflag == tar.TypeXGlobalHeader {
+			if err := manifest.ReadHeader(header); err != nil {
+				return restored, err
+			}
+			continue
+		}
+
+		// Files of content-addressed artifacts are restored from the object store
+		if digest, ok := header.PAXRecords[_objectRecord]; ok && header.Typeflag == tar.TypeReg {
+			manifest.AddChecksum(header.Name, digest)
+			file, restoreErr := ci.restoreObject(dirCache, anchor, header)
+			if restoreErr != nil {
+				return restored, restoreErr
+			}
+			if err := modes.Set(file.RestoreAnchor(anchor), header); err != nil {
+				return restored, err
+			}
+			restored = append(restored, file)
+			continue
 		}
 
 		// The reader will not advance until tr.Next is called.
 		// We can treat this as file metadata + body reader.
+		var body io.Reader = tr
+		if header.Typeflag == tar.TypeReg {
+			body = manifest.Reader(header.Name, tr)
+		} else {
+			manifest.Add(header.Name)
+		}
 
 		// Attempt to place the file on disk.
-		file, restoreErr := restoreEntry(dirCache, anchor, header, tr)
+		file, restoreErr := restoreEntry(dirCache, anchor, header, body)
 		if restoreErr != nil {
 			if errors.Is(restoreErr, errMissingSymlinkTarget) {
 				// Links get one shot to be valid, then they're accumulated, DAG'd, and restored on delay.
@@ -103,6 +142,9 @@ func (ci *CacheItem) Restore(anchor turbopath.AbsoluteSystemPath) ([]turbopath.A
 			}
 			return restored, restoreErr
 		}
+		if err := modes.Set(file.RestoreAnchor(anchor), header); err != nil {
+			return restored, err
+		}
 		restored = append(restored, file)
 	}
 
@@ -110,7 +152,7 @@ func (ci *CacheItem) Restore(anchor turbopath.AbsoluteSystemPath) ([]turbopath.A
 }
 
 // restoreRegular is the entry point for all things read from the tar.
-func restoreEntry(dirCache *cachedDirTree, anchor turbopath.AbsoluteSystemPath, header *tar.Header, reader *tar.Reader) (turbopath.AnchoredSystemPath, error) {
+func restoreEntry(dirCache *cachedDirTree, anchor turbopath.AbsoluteSystemPath, header *tar.Header, reader io.Reader) (turbopath.AnchoredSystemPath, error) {
 	// We're permissive on creation, but restrictive on restoration.
 	// There is no need to prevent the cache creation in any case.
 	// And on restoration, if we fail, we simply run the task.
@@ -106,8 +106,8 @@ func checkPath(originalAnchor turbopath.AbsoluteSystemPath, accumulatedAnchor tu
 		return combinedPath, nil
 	}
 
-	// Find out if we have a symlink.
-	isSymlink := fileInfo.Mode()&os.ModeSymlink != 0
+	// Find out if we have a symlink. Junctions link to a directory the same way.
+	isSymlink := fileInfo.Mode()&os.ModeSymlink != 0 || isJunction(fileInfo)
 
 	// If we don't have a symlink it's safe.
 	if !isSymlink {
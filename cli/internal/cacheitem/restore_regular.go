@@ -9,7 +9,7 @@ import (
 )
 
 // restoreRegular restores a file.
-func restoreRegular(dirCache *cachedDirTree, anchor turbopath.AbsoluteSystemPath, header *tar.Header, reader *tar.Reader) (turbopath.AnchoredSystemPath, error) {
+func restoreRegular(dirCache *cachedDirTree, anchor turbopath.AbsoluteSystemPath, header *tar.Header, reader io.Reader) (turbopath.AnchoredSystemPath, error) {
 	// Assuming this was a `turbo`-created input, we currently have an AnchoredUnixPath.
 	// Assuming this is malicious input we don't really care if we do the wrong thing.
 	processedName, err := canonicalizeName(header.Name)
@@ -24,12 +24,8 @@ func restoreRegular(dirCache *cachedDirTree, anchor turbopath.AbsoluteSystemPath
 		return "", err
 	}
 
-	// Create the file.
-	if f, err := processedName.RestoreAnchor(anchor).OpenFile(os.O_WRONLY|os.O_TRUNC|os.O_CREATE, os.FileMode(header.Mode)); err != nil {
-		return "", err
-	} else if _, err := io.Copy(f, reader); err != nil {
-		return "", err
-	} else if err := f.Close(); err != nil {
+	// Create the file, unless it is unchanged.
+	if _, err := RestoreContents(processedName.RestoreAnchor(anchor), os.FileMode(header.Mode), header.Size, reader); err != nil {
 		return "", err
 	}
 	return processedName, nil
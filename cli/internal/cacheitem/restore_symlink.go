@@ -2,11 +2,12 @@ package cacheitem
 
 import (
 	"archive/tar"
-	"io/fs"
+	iofs "io/fs"
 	"os"
 	"path/filepath"
 
 	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/turbopath"
 )
 
@@ -56,11 +57,19 @@ func actuallyRestoreSymlink(dirCache *cachedDirTree, anchor turbopath.AbsoluteSy
 	// This does not support file names with `\` in them in a cross-platform manner.
 	symlinkErr := symlinkFrom.Symlink(header.Linkname)
 	if symlinkErr != nil {
-		return "", symlinkErr
+		if !isSymlinkPrivilegeError(symlinkErr) {
+			return "", symlinkErr
+		}
+		// Without the privilege to create symlinks, restore a copy of the
+		// target instead, so that the files are where the task expects them.
+		if err := copyLinkTarget(anchor, processedName, header.Linkname); err != nil {
+			return "", symlinkErr
+		}
+		return processedName, nil
 	}
 
 	// Darwin allows you to change the permissions of a symlink.
-	lchmodErr := symlinkFrom.Lchmod(fs.FileMode(header.Mode))
+	lchmodErr := symlinkFrom.Lchmod(iofs.FileMode(header.Mode))
 	if lchmodErr != nil {
 		return "", lchmodErr
 	}
@@ -68,6 +77,20 @@ func actuallyRestoreSymlink(dirCache *cachedDirTree, anchor turbopath.AbsoluteSy
 	return processedName, nil
 }
 
+// copyLinkTarget copies the target of a symlink to where the symlink would be
+// restored. Only targets inside of the anchor are copied, and since symlinks
+// are restored in topological order, the target has already been restored.
+func copyLinkTarget(anchor turbopath.AbsoluteSystemPath, processedName turbopath.AnchoredSystemPath, linkname string) error {
+	target := turbopath.AbsoluteSystemPathFromUpstream(canonicalizeLinkname(anchor, processedName, linkname))
+	if contained, err := anchor.ContainsPath(target); err != nil || !contained {
+		return errTraversal
+	}
+	if _, err := target.Lstat(); err != nil {
+		return errMissingSymlinkTarget
+	}
+	return fs.RecursiveCopy(target.ToString(), processedName.RestoreAnchor(anchor).ToString())
+}
+
 // topologicallyRestoreSymlinks ensures that targets of symlinks are created in advance
 // of the things that link to them. It does this by topologically sorting all
 // of the symlinks. This also enables us to ensure we do not create cycles.
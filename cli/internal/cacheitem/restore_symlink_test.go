@@ -0,0 +1,25 @@
+package cacheitem
+
+import (
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func Test_copyLinkTarget(t *testing.T) {
+	anchor := turbopath.AbsoluteSystemPath(t.TempDir())
+	targetFile := anchor.UntypedJoin("target", "file")
+	assert.NilError(t, targetFile.EnsureDir(), "EnsureDir")
+	assert.NilError(t, targetFile.WriteFile([]byte("contents"), 0644), "WriteFile")
+
+	assert.NilError(t, copyLinkTarget(anchor, "link", "target"), "copyLinkTarget")
+	contents, err := anchor.UntypedJoin("link", "file").ReadFile()
+	assert.NilError(t, err, "ReadFile")
+	assert.Equal(t, string(contents), "contents")
+
+	// Targets outside of the anchor are never copied
+	assert.ErrorIs(t, copyLinkTarget(anchor, "outside", "../outside"), errTraversal)
+	// Targets that haven't been restored can't be copied
+	assert.ErrorIs(t, copyLinkTarget(anchor, "missing", "does-not-exist"), errMissingSymlinkTarget)
+}
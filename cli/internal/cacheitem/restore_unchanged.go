@@ -0,0 +1,123 @@
+package cacheitem
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/moby/sys/sequential"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// _compareChunkSize is how much of a file is compared with the artifact at once
+const _compareChunkSize = 32 * 1024
+
+// RestoreContents writes the contents of a regular file from an artifact to
+// path. Restoring the outputs of a task usually only changes a few of them, so
+// the contents are compared with the file on disk as they are read, and an
+// identical file isn't written at all. A file that differs is replaced, rather
+// than written to, since it may be a hardlink to the object store. It returns
+// whether the file was written.
+func RestoreContents(path turbopath.AbsoluteSystemPath, mode os.FileMode, size int64, contents io.Reader) (bool, error) {
+	info, err := path.Lstat()
+	if err != nil || !info.Mode().IsRegular() || info.Size() != size {
+		return true, writeContents(path, mode, contents)
+	}
+	existing, err := sequential.OpenFile(longPath(path.ToString()), os.O_RDONLY, 0777)
+	if err != nil {
+		return true, writeContents(path, mode, contents)
+	}
+	defer func() { _ = existing.Close() }()
+
+	artifactChunk := make([]byte, _compareChunkSize)
+	diskChunk := make([]byte, _compareChunkSize)
+	var offset int64
+	for {
+		n, readErr := io.ReadFull(contents, artifactChunk)
+		if readErr != nil && readErr != io.EOF && readErr != io.ErrUnexpectedEOF {
+			return false, readErr
+		}
+		if n == 0 {
+			break
+		}
+		if _, err := io.ReadFull(existing, diskChunk[:n]); err != nil || !bytes.Equal(artifactChunk[:n], diskChunk[:n]) {
+			return true, replaceContents(path, info.Mode().Perm(), offset, io.MultiReader(bytes.NewReader(artifactChunk[:n]), contents))
+		}
+		offset += int64(n)
+		if readErr != nil {
+			break
+		}
+	}
+	return false, nil
+}
+
+// writeContents creates or truncates the file at path, like extracting a tar does
+func writeContents(path turbopath.AbsoluteSystemPath, mode os.FileMode, contents io.Reader) error {
+	f, err := path.OpenFile(os.O_WRONLY|os.O_TRUNC|os.O_CREATE, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, contents); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// replaceContents replaces the file at path with one that starts with the
+// first prefixSize bytes of the file, which matched the artifact, followed by
+// the rest of the contents. The file keeps its mode.
+func replaceContents(path turbopath.AbsoluteSystemPath, mode os.FileMode, prefixSize int64, rest io.Reader) error {
+	existing, err := sequential.OpenFile(longPath(path.ToString()), os.O_RDONLY, 0777)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = existing.Close() }()
+	temp, err := os.CreateTemp(longPath(filepath.Dir(path.ToString())), ".turbo-restore-")
+	if err != nil {
+		return err
+	}
+	tempPath := temp.Name()
+	defer func() { _ = os.Remove(tempPath) }()
+	if _, err := io.CopyN(temp, existing, prefixSize); err != nil {
+		_ = temp.Close()
+		return err
+	}
+	if _, err := io.Copy(temp, rest); err != nil {
+		_ = temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tempPath, mode); err != nil {
+		return err
+	}
+	_ = existing.Close()
+	return os.Rename(tempPath, longPath(path.ToString()))
+}
+
+// objectUnchanged is whether the file at target already has the contents of
+// the object with the given digest, either as a hardlink to it or as a copy
+func objectUnchanged(target turbopath.AbsoluteSystemPath, object os.FileInfo, digest string) bool {
+	info, err := target.Lstat()
+	if err != nil || !info.Mode().IsRegular() || info.Size() != object.Size() {
+		return false
+	}
+	if os.SameFile(info, object) {
+		return true
+	}
+	f, err := sequential.OpenFile(longPath(target.ToString()), os.O_RDONLY, 0777)
+	if err != nil {
+		return false
+	}
+	defer func() { _ = f.Close() }()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return false
+	}
+	return hex.EncodeToString(h.Sum(nil)) == digest
+}
@@ -0,0 +1,82 @@
+package cacheitem
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func TestRestoreContents(t *testing.T) {
+	dir := turbopath.AbsoluteSystemPath(t.TempDir())
+	path := dir.UntypedJoin("index.js")
+	longContents := strings.Repeat("a", _compareChunkSize+10)
+	assert.NilError(t, path.WriteFile([]byte(longContents), 0644), "WriteFile")
+	old := time.Now().Add(-time.Hour)
+	assert.NilError(t, os.Chtimes(path.ToString(), old, old), "Chtimes")
+
+	written, err := RestoreContents(path, 0644, int64(len(longContents)), strings.NewReader(longContents))
+	assert.NilError(t, err, "RestoreContents")
+	assert.Assert(t, !written, "unchanged files aren't written")
+	info, err := path.Lstat()
+	assert.NilError(t, err, "Lstat")
+	assert.Assert(t, info.ModTime().Equal(old))
+
+	// As if a build wrote to a file that was restored as a hardlink
+	link := dir.UntypedJoin("link.js")
+	assert.NilError(t, os.Link(path.ToString(), link.ToString()), "Link")
+	changed := longContents[:_compareChunkSize+5] + "bbbbb"
+	written, err = RestoreContents(path, 0644, int64(len(changed)), strings.NewReader(changed))
+	assert.NilError(t, err, "RestoreContents")
+	assert.Assert(t, written)
+	contents, err := path.ReadFile()
+	assert.NilError(t, err, "ReadFile")
+	assert.Equal(t, string(contents), changed)
+	contents, err = link.ReadFile()
+	assert.NilError(t, err, "ReadFile")
+	assert.Equal(t, string(contents), longContents, "the file is replaced, not written to")
+
+	written, err = RestoreContents(path, 0644, 5, strings.NewReader("short"))
+	assert.NilError(t, err, "RestoreContents")
+	assert.Assert(t, written)
+	contents, err = path.ReadFile()
+	assert.NilError(t, err, "ReadFile")
+	assert.Equal(t, string(contents), "short")
+}
+
+func TestContentAddressedUnchanged(t *testing.T) {
+	inputDir := writeContentAddressedInputs(t)
+	cacheDir := turbopath.AbsoluteSystemPath(t.TempDir())
+	createContentAddressedArtifact(t, inputDir, cacheDir.UntypedJoin("the-hash.tar"))
+
+	anchor := generateAnchor(t)
+	cacheItem, err := Open(cacheDir.UntypedJoin("the-hash.tar"))
+	assert.NilError(t, err, "Open")
+	cacheItem.noClone = true
+	cacheItem.noLink = true
+	_, err = cacheItem.Restore(anchor)
+	assert.NilError(t, err, "Restore")
+	assert.NilError(t, cacheItem.Close(), "Close")
+
+	unchanged := anchor.UntypedJoin("dist", "index.js")
+	old := time.Now().Add(-time.Hour)
+	assert.NilError(t, os.Chtimes(unchanged.ToString(), old, old), "Chtimes")
+	assert.NilError(t, anchor.UntypedJoin("dist", "run.sh").WriteFile([]byte("#!/bin/bash"), 0755), "WriteFile")
+
+	cacheItem, err = Open(cacheDir.UntypedJoin("the-hash.tar"))
+	assert.NilError(t, err, "Open")
+	restored, err := cacheItem.Restore(anchor)
+	assert.NilError(t, err, "Restore")
+	assert.NilError(t, cacheItem.Close(), "Close")
+	assert.Equal(t, len(restored), 4, "unchanged files are still restored outputs")
+
+	info, err := unchanged.Lstat()
+	assert.NilError(t, err, "Lstat")
+	assert.Assert(t, info.ModTime().Equal(old), "unchanged files aren't written")
+	contents, err := anchor.UntypedJoin("dist", "run.sh").ReadFile()
+	assert.NilError(t, err, "ReadFile")
+	assert.Equal(t, string(contents), "#!/bin/sh")
+}
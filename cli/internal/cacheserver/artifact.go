@@ -0,0 +1,194 @@
+package cacheserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/cacheitem"
+	"github.com/vercel/turbo/cli/internal/client"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// artifactInfo is what `turbo cache inspect` shows of an artifact
+type artifactInfo struct {
+	Hash string `json:"hash"`
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	// LastUsed is when the artifact was last written or restored
+	LastUsed time.Time `json:"lastUsed"`
+	// Duration is how long the task took to run, in milliseconds, or 0 if the
+	// metadata of the artifact is missing
+	Duration int               `json:"duration"`
+	Entries  []cacheitem.Entry `json:"entries"`
+}
+
+// findArtifact returns the path of the artifact for hash in dir, which is
+// compressed or not depending on the version of turbo that wrote it
+func findArtifact(dir turbopath.AbsoluteSystemPath, hash string) (turbopath.AbsoluteSystemPath, error) {
+	if hash == "" {
+		return "", errors.New("a hash must be specified")
+	}
+	for _, name := range []string{hash + ".tar", hash + ".tar.zst"} {
+		path := dir.UntypedJoin(name)
+		if path.FileExists() {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no artifact for %v in %v", hash, dir)
+}
+
+// inspect lists the entries of an artifact of the filesystem cache, with its
+// metadata, without restoring it
+func inspect(base *cmdutil.CmdBase, opts *turbostate.CachePayload) error {
+	dir := localCacheDir(base, opts)
+	path, err := findArtifact(dir, opts.Hash)
+	if err != nil {
+		return err
+	}
+	stat, err := path.Stat()
+	if err != nil {
+		return err
+	}
+	info := &artifactInfo{
+		Hash:     opts.Hash,
+		Path:     path.ToString(),
+		Size:     stat.Size(),
+		LastUsed: stat.ModTime(),
+	}
+	if meta, err := cache.ReadCacheMetaFile(dir.UntypedJoin(opts.Hash + "-meta.json")); err == nil {
+		info.Duration = meta.Duration
+	}
+
+	cacheItem, err := cacheitem.Open(path)
+	if err != nil {
+		return err
+	}
+	entries, listErr := cacheItem.List()
+	if err := cacheItem.Close(); err != nil && listErr == nil {
+		listErr = err
+	}
+	if listErr != nil {
+		return fmt.Errorf("failed to read %v: %w", path, listErr)
+	}
+	info.Entries = entries
+
+	if opts.JSON {
+		rendered, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return err
+		}
+		base.UI.Output(string(rendered))
+		return nil
+	}
+
+	base.UI.Output(util.Sprintf("${BOLD}%s${RESET}", info.Hash))
+	base.UI.Output(util.Sprintf("  Path      ${GREY}%s${RESET}", info.Path))
+	base.UI.Output(util.Sprintf("  Size      ${GREY}%.1f MB${RESET}", megabytes(info.Size)))
+	base.UI.Output(util.Sprintf("  Last used ${GREY}%s${RESET}", info.LastUsed.Format(time.RFC3339)))
+	if info.Duration > 0 {
+		base.UI.Output(util.Sprintf("  Duration  ${GREY}%s${RESET}", time.Duration(info.Duration)*time.Millisecond))
+	}
+	base.UI.Output("")
+	for _, entry := range info.Entries {
+		switch entry.Type {
+		case "file":
+			base.UI.Output(util.Sprintf("  %s %s ${GREY}%d bytes %s${RESET}", entry.Mode, entry.Name, entry.Size, entry.Checksum))
+		case "symlink":
+			base.UI.Output(util.Sprintf("  %s %s ${GREY}-> %s${RESET}", entry.Mode, entry.Name, entry.Linkname))
+		default:
+			base.UI.Output(util.Sprintf("  %s %s", entry.Mode, entry.Name))
+		}
+	}
+	return nil
+}
+
+// restore extracts an artifact of the filesystem cache into --to, rather than
+// into the repository like `turbo run` does
+func restore(base *cmdutil.CmdBase, opts *turbostate.CachePayload) error {
+	if opts.To == "" {
+		return errors.New("a directory to restore to must be specified with --to")
+	}
+	path, err := findArtifact(localCacheDir(base, opts), opts.Hash)
+	if err != nil {
+		return err
+	}
+	to := fs.ResolveUnknownPath(base.RepoRoot, opts.To)
+
+	cacheItem, err := cacheitem.Open(path)
+	if err != nil {
+		return err
+	}
+	restored, restoreErr := cacheItem.Restore(to)
+	if err := cacheItem.Close(); err != nil && restoreErr == nil {
+		restoreErr = err
+	}
+	if restoreErr != nil {
+		return fmt.Errorf("failed to restore %v: %w", path, restoreErr)
+	}
+	base.UI.Output(fmt.Sprintf("Restored %v files from %v to %v", len(restored), opts.Hash, to))
+	return nil
+}
+
+// provenance shows who or what produced the artifact for a hash. The metadata
+// of the filesystem cache is read first, and then the metadata that the remote
+// cache returns for the artifact.
+func provenance(base *cmdutil.CmdBase, opts *turbostate.CachePayload) error {
+	if opts.Hash == "" {
+		return errors.New("a hash must be specified")
+	}
+	source := "filesystem cache"
+	var found *cache.Provenance
+	if meta, err := cache.ReadCacheMetaFile(localCacheDir(base, opts).UntypedJoin(opts.Hash + "-meta.json")); err == nil {
+		found = meta.Provenance
+	} else if base.APIClient.IsLinked() {
+		source = "remote cache"
+		resp, err := base.APIClient.ArtifactExists(opts.Hash)
+		if err != nil {
+			return err
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			found = cache.ProvenanceFromMetadata(opts.Hash, client.ArtifactMetadata(resp.Header))
+		}
+	}
+	if found == nil {
+		return fmt.Errorf("no provenance recorded for %v. Artifacts written by older versions of turbo don't have one", opts.Hash)
+	}
+
+	if opts.JSON {
+		rendered, err := json.MarshalIndent(found, "", "  ")
+		if err != nil {
+			return err
+		}
+		base.UI.Output(string(rendered))
+		return nil
+	}
+
+	base.UI.Output(util.Sprintf("${BOLD}%s${RESET} ${GREY}(%s)${RESET}", opts.Hash, source))
+	for _, field := range []struct {
+		name  string
+		value string
+	}{
+		{"User", found.User},
+		{"CI job", found.CIJobURL},
+		{"Git SHA", found.GitSHA},
+		{"Turbo version", found.TurboVersion},
+		{"Inputs digest", found.InputsDigest},
+	} {
+		if field.value != "" {
+			base.UI.Output(util.Sprintf("  %-14s ${GREY}%s${RESET}", field.name, field.value))
+		}
+	}
+	if found.CreatedAt != nil {
+		base.UI.Output(util.Sprintf("  %-14s ${GREY}%s${RESET}", "Created at", found.CreatedAt.Format(time.RFC3339)))
+	}
+	return nil
+}
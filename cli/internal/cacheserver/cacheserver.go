@@ -0,0 +1,196 @@
+// Package cacheserver implements `turbo cache`. `turbo cache serve` serves the
+// remote cache API from a directory on local disk, so that teams can host a
+// remote cache on their own network or CI runners. `turbo cache gc` removes
+// artifacts from the local filesystem cache. `turbo cache inspect` and
+// `turbo cache restore` list and extract a single artifact of that cache, and
+// `turbo cache provenance` shows who or what produced an artifact.
+//
+// Clients use the server by setting --api to its address, --token to its token
+// and --team to any value. The token is read from TURBO_CACHE_SERVER_TOKEN, or
+// generated and printed at startup.
+package cacheserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/client"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/runsummary"
+	"github.com/vercel/turbo/cli/internal/signals"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+	"github.com/vercel/turbo/cli/internal/ui"
+)
+
+const _tokenEnvVar = "TURBO_CACHE_SERVER_TOKEN"
+
+// ExecuteCache executes the `cache` command.
+func ExecuteCache(ctx context.Context, helper *cmdutil.Helper, signalWatcher *signals.Watcher, args *turbostate.ParsedArgsFromRust) error {
+	base, err := helper.GetCmdBase(args)
+	if err != nil {
+		return err
+	}
+	if err := cacheCommand(ctx, base, signalWatcher, args.Command.Cache); err != nil {
+		base.LogError("cache failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+func cacheCommand(ctx context.Context, base *cmdutil.CmdBase, signalWatcher *signals.Watcher, opts *turbostate.CachePayload) error {
+	switch opts.Command {
+	case "Serve":
+		return serve(ctx, base, signalWatcher, opts)
+	case "Gc":
+		return gc(base, opts)
+	case "Inspect":
+		return inspect(base, opts)
+	case "Restore":
+		return restore(base, opts)
+	case "Provenance":
+		return provenance(base, opts)
+	default:
+		return fmt.Errorf("unknown subcommand: %v", opts.Command)
+	}
+}
+
+func serve(ctx context.Context, base *cmdutil.CmdBase, signalWatcher *signals.Watcher, opts *turbostate.CachePayload) error {
+	maxAge, err := runsummary.ParseMaxAge(opts.MaxAge)
+	if err != nil {
+		return fmt.Errorf("invalid --max-age: %w", err)
+	}
+	var maxSize int64
+	if opts.MaxSize != "" {
+		maxSize, err = client.ParseSize(opts.MaxSize)
+		if err != nil {
+			return fmt.Errorf("invalid --max-size: %w", err)
+		}
+	}
+	dir := base.RepoRoot.UntypedJoin(".turbo", "cache-server")
+	if opts.Dir != "" {
+		dir = fs.ResolveUnknownPath(base.RepoRoot, opts.Dir)
+	}
+	token := os.Getenv(_tokenEnvVar)
+	generatedToken := token == ""
+	if generatedToken {
+		bytes := make([]byte, 32)
+		if _, err := rand.Read(bytes); err != nil {
+			return err
+		}
+		token = hex.EncodeToString(bytes)
+	}
+
+	server, err := newServer(dir, token, maxAge, maxSize, base.Logger.Named("cache-server"))
+	if err != nil {
+		return fmt.Errorf("failed to open %v: %w", dir, err)
+	}
+	server.collectGarbage()
+	lis, err := net.Listen("tcp", opts.Listen)
+	if err != nil {
+		return err
+	}
+	httpServer := &http.Server{
+		Handler:           server,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	gcCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go server.collectGarbageEvery(gcCtx, _gcInterval)
+
+	base.UI.Info(fmt.Sprintf("Serving the remote cache in %v on http://%v", dir, lis.Addr()))
+	if generatedToken {
+		base.UI.Info(fmt.Sprintf("Token: %v %v", token, ui.Dim(fmt.Sprintf("(set %v to choose the token)", _tokenEnvVar))))
+	}
+	base.UI.Info(ui.Dim(fmt.Sprintf("Use it with TURBO_API=http://%v, TURBO_TOKEN set to the token and TURBO_TEAM set to any value", lis.Addr())))
+
+	errCh := make(chan error, 1)
+	go func() {
+		if err := httpServer.Serve(lis); err != nil && err != http.ErrServerClosed {
+			errCh <- err
+		}
+		close(errCh)
+	}()
+
+	select {
+	case err, ok := <-errCh:
+		if ok {
+			return err
+		}
+	case <-ctx.Done():
+	case <-signalWatcher.Done():
+	}
+	cancel()
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		// Downloads that are still in progress are cut off
+		return httpServer.Close()
+	}
+	return nil
+}
+
+// gc removes the artifacts of the filesystem cache that are beyond --max-age,
+// and then the least recently used ones while the cache is larger than
+// --max-size. The size defaults to TURBO_MAX_CACHE_SIZE, and then to the
+// max_cache_size user default.
+func gc(base *cmdutil.CmdBase, opts *turbostate.CachePayload) error {
+	maxSize := opts.MaxSize
+	if maxSize == "" {
+		maxSize = os.Getenv(cache.MaxCacheSizeEnvVar)
+	}
+	if maxSize == "" && base.UserConfig != nil {
+		maxSize = base.UserConfig.Default("max_cache_size")
+	}
+	gcOpts := cache.GCOpts{}
+	if maxSize != "" {
+		size, err := client.ParseSize(maxSize)
+		if err != nil {
+			return fmt.Errorf("invalid --max-size: %w", err)
+		}
+		gcOpts.MaxSize = size
+	}
+	if opts.MaxAge != "" {
+		maxAge, err := runsummary.ParseMaxAge(opts.MaxAge)
+		if err != nil {
+			return fmt.Errorf("invalid --max-age: %w", err)
+		}
+		gcOpts.MaxAge = maxAge
+	}
+	if gcOpts.MaxSize <= 0 && gcOpts.MaxAge <= 0 {
+		return errors.New("no limit to apply, pass --max-size or --max-age, or set a default with `turbo config set max_cache_size`")
+	}
+
+	dir := localCacheDir(base, opts)
+	result, err := cache.CollectGarbage(dir, gcOpts, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to clean %v: %w", dir, err)
+	}
+	base.UI.Output(fmt.Sprintf("Removed %v artifacts (%.1f MB) from %v", result.Removed, megabytes(result.RemovedBytes), dir))
+	base.UI.Output(ui.Dim(fmt.Sprintf("%v artifacts (%.1f MB) remain", result.Kept, megabytes(result.KeptBytes))))
+	return nil
+}
+
+// localCacheDir resolves --cache-dir, TURBO_CACHE_DIR or the cache_dir user
+// default to the filesystem cache directory, like `turbo run` does
+func localCacheDir(base *cmdutil.CmdBase, opts *turbostate.CachePayload) turbopath.AbsoluteSystemPath {
+	cacheDir := opts.CacheDir
+	if cacheDir == "" && os.Getenv(cache.CacheDirEnvVar) == "" && base.UserConfig != nil {
+		cacheDir = base.UserConfig.Default("cache_dir")
+	}
+	cacheOpts := &cache.Opts{OverrideDir: cacheDir}
+	return cacheOpts.ResolveCacheDir(base.RepoRoot)
+}
+
+func megabytes(bytes int64) float64 {
+	return float64(bytes) / (1024 * 1024)
+}
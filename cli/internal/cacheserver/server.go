@@ -0,0 +1,431 @@
+package cacheserver
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/vercel/turbo/cli/internal/client"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// _gcInterval is how often artifacts beyond the age and size limits are removed
+const _gcInterval = 10 * time.Minute
+
+// _uploadPrefix starts the names of the temporary files that uploads are
+// written to, before they are renamed into place
+const _uploadPrefix = ".upload-"
+
+// _validHash matches the hashes that artifacts may be stored under, which keeps
+// requests from naming files outside of the cache directory
+var _validHash = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,128}$`)
+
+// _metaHeaderPrefix starts the names of the headers of the metadata that
+// artifacts are uploaded with
+const _metaHeaderPrefix = "x-artifact-meta-"
+
+// artifactMetadata is stored next to each artifact, in <hash>.json
+type artifactMetadata struct {
+	Duration int    `json:"duration"`
+	Tag      string `json:"tag,omitempty"`
+	// Encoding is the Content-Encoding the artifact was uploaded with
+	Encoding string `json:"encoding,omitempty"`
+	// Meta is the metadata of the x-artifact-meta-* headers, such as the
+	// provenance of the artifact
+	Meta map[string]string `json:"meta,omitempty"`
+}
+
+type artifact struct {
+	size     int64
+	lastUsed time.Time
+}
+
+// counters are the metrics of the requests served since the server started
+type counters struct {
+	hits            int64
+	misses          int64
+	uploads         int64
+	downloadedBytes int64
+	uploadedBytes   int64
+	evictions       int64
+	unauthorized    int64
+}
+
+// Server implements the artifact endpoints of the Vercel Remote Cache API,
+// storing artifacts in a directory on local disk
+type Server struct {
+	dir    turbopath.AbsoluteSystemPath
+	token  string
+	logger hclog.Logger
+	// maxAge is how long an artifact is kept after it was last uploaded or downloaded
+	maxAge time.Duration
+	// maxSize is the total size of the artifacts to keep, or 0 if unlimited
+	maxSize int64
+	now     func() time.Time
+
+	mu        sync.Mutex
+	artifacts map[string]*artifact
+	size      int64
+	counters  counters
+}
+
+// newServer creates a Server for the artifacts in dir, which is created if it
+// doesn't exist
+func newServer(dir turbopath.AbsoluteSystemPath, token string, maxAge time.Duration, maxSize int64, logger hclog.Logger) (*Server, error) {
+	if err := dir.MkdirAll(0755); err != nil {
+		return nil, err
+	}
+	s := &Server{
+		dir:       dir,
+		token:     token,
+		logger:    logger,
+		maxAge:    maxAge,
+		maxSize:   maxSize,
+		now:       time.Now,
+		artifacts: make(map[string]*artifact),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load indexes the artifacts that are already in the directory. Their
+// modification time is when they were last used. Uploads that were interrupted
+// by the server stopping are removed.
+func (s *Server) load() error {
+	entries, err := ioutil.ReadDir(s.dir.ToString())
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, _uploadPrefix) {
+			_ = s.dir.UntypedJoin(name).Remove()
+			continue
+		}
+		if !entry.Mode().IsRegular() || !_validHash.MatchString(name) {
+			continue
+		}
+		s.artifacts[name] = &artifact{size: entry.Size(), lastUsed: entry.ModTime()}
+		s.size += entry.Size()
+	}
+	return nil
+}
+
+// collectGarbageEvery removes the artifacts beyond the limits every interval until ctx is done
+func (s *Server) collectGarbageEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.collectGarbage()
+		}
+	}
+}
+
+// collectGarbage removes the artifacts that haven't been used for maxAge, and
+// then the least recently used artifacts while their total size exceeds maxSize
+func (s *Server) collectGarbage() {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	remaining := make([]string, 0, len(s.artifacts))
+	for hash, artifact := range s.artifacts {
+		if now.Sub(artifact.lastUsed) > s.maxAge {
+			s.removeLocked(hash)
+		} else {
+			remaining = append(remaining, hash)
+		}
+	}
+	if s.maxSize <= 0 || s.size <= s.maxSize {
+		return
+	}
+	sort.Slice(remaining, func(i, j int) bool {
+		return s.artifacts[remaining[i]].lastUsed.Before(s.artifacts[remaining[j]].lastUsed)
+	})
+	for _, hash := range remaining {
+		if s.size <= s.maxSize {
+			return
+		}
+		s.removeLocked(hash)
+	}
+}
+
+func (s *Server) removeLocked(hash string) {
+	if err := s.artifactPath(hash).Remove(); err != nil && !os.IsNotExist(err) {
+		s.logger.Warn("failed to remove artifact", "hash", hash, "error", err)
+		return
+	}
+	_ = s.metadataPath(hash).Remove()
+	s.size -= s.artifacts[hash].size
+	delete(s.artifacts, hash)
+	s.counters.evictions++
+	s.logger.Debug("removed artifact", "hash", hash)
+}
+
+func (s *Server) artifactPath(hash string) turbopath.AbsoluteSystemPath {
+	return s.dir.UntypedJoin(hash)
+}
+
+func (s *Server) metadataPath(hash string) turbopath.AbsoluteSystemPath {
+	return s.dir.UntypedJoin(hash + ".json")
+}
+
+// ServeHTTP implements http.Handler
+//
+//	GET  /v8/artifacts/status  report that remote caching is enabled
+//	HEAD /v8/artifacts/{hash}  check whether an artifact exists
+//	GET  /v8/artifacts/{hash}  download an artifact
+//	PUT  /v8/artifacts/{hash}  upload an artifact
+//	POST /v8/artifacts/events  accept cache usage events, which are discarded
+//	GET  /metrics              metrics in the Prometheus text format, without a token
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.logger.Debug("request", "method", req.Method, "path", req.URL.Path)
+	if req.Method == http.MethodOptions {
+		s.preflight(w, req)
+		return
+	}
+	if req.URL.Path == "/metrics" && req.Method == http.MethodGet {
+		s.writeMetrics(w)
+		return
+	}
+	if !s.authorized(req) {
+		s.mu.Lock()
+		s.counters.unauthorized++
+		s.mu.Unlock()
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, errors.New("missing or invalid token"))
+		return
+	}
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	if len(parts) != 3 || parts[0] != "v8" || parts[1] != "artifacts" {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no route for %v %v", req.Method, req.URL.Path))
+		return
+	}
+	switch {
+	case parts[2] == "status" && req.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]string{"status": "enabled"})
+	case parts[2] == "events" && req.Method == http.MethodPost:
+		_, _ = io.Copy(ioutil.Discard, req.Body)
+		writeJSON(w, http.StatusOK, map[string]string{})
+	case !_validHash.MatchString(parts[2]):
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid hash %q", parts[2]))
+	case req.Method == http.MethodGet || req.Method == http.MethodHead:
+		s.getArtifact(w, req, parts[2])
+	case req.Method == http.MethodPut:
+		s.putArtifact(w, req, parts[2])
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("no route for %v %v", req.Method, req.URL.Path))
+	}
+}
+
+func (s *Server) authorized(req *http.Request) bool {
+	header := req.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return false
+	}
+	token := strings.TrimPrefix(header, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// preflight answers the CORS preflight requests that clients send with --preflight
+func (s *Server) preflight(w http.ResponseWriter, req *http.Request) {
+	allowedHeaders := "Authorization, Content-Type, Content-Encoding, User-Agent, x-artifact-duration, x-artifact-tag, x-artifact-client-ci"
+	// The names of the metadata headers depend on the metadata that is sent
+	for _, name := range strings.Split(req.Header.Get("Access-Control-Request-Headers"), ",") {
+		if name = strings.TrimSpace(strings.ToLower(name)); strings.HasPrefix(name, _metaHeaderPrefix) {
+			allowedHeaders += ", " + name
+		}
+	}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, PUT, POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", allowedHeaders)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) getArtifact(w http.ResponseWriter, req *http.Request, hash string) {
+	download := req.Method == http.MethodGet
+	s.mu.Lock()
+	artifact, ok := s.artifacts[hash]
+	if ok && download {
+		artifact.lastUsed = s.now()
+	}
+	if !ok && download {
+		s.counters.misses++
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no artifact with hash %v", hash))
+		return
+	}
+
+	f, err := s.artifactPath(hash).Open()
+	if os.IsNotExist(err) {
+		// The artifact was removed since it was looked up
+		writeError(w, http.StatusNotFound, fmt.Errorf("no artifact with hash %v", hash))
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to read artifact: %w", err))
+		return
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to read artifact: %w", err))
+		return
+	}
+	metadata := artifactMetadata{}
+	if raw, err := s.metadataPath(hash).ReadFile(); err == nil {
+		_ = json.Unmarshal(raw, &metadata)
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	w.Header().Set("x-artifact-duration", strconv.Itoa(metadata.Duration))
+	if metadata.Tag != "" {
+		w.Header().Set("x-artifact-tag", metadata.Tag)
+	}
+	if metadata.Encoding != "" {
+		w.Header().Set("Content-Encoding", metadata.Encoding)
+	}
+	for key, value := range metadata.Meta {
+		w.Header().Set(_metaHeaderPrefix+key, value)
+	}
+	w.WriteHeader(http.StatusOK)
+	if !download {
+		return
+	}
+	// The modification time is when the artifact was last used, should the server restart
+	now := s.now()
+	_ = os.Chtimes(s.artifactPath(hash).ToString(), now, now)
+	n, _ := io.Copy(w, f)
+	s.mu.Lock()
+	s.counters.hits++
+	s.counters.downloadedBytes += n
+	s.mu.Unlock()
+}
+
+func (s *Server) putArtifact(w http.ResponseWriter, req *http.Request, hash string) {
+	metadata := artifactMetadata{
+		Tag:      req.Header.Get("x-artifact-tag"),
+		Encoding: req.Header.Get("Content-Encoding"),
+	}
+	if meta := client.ArtifactMetadata(req.Header); len(meta) > 0 {
+		metadata.Meta = meta
+	}
+	if duration := req.Header.Get("x-artifact-duration"); duration != "" {
+		var err error
+		if metadata.Duration, err = strconv.Atoi(duration); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid x-artifact-duration %q", duration))
+			return
+		}
+	}
+	size, err := s.writeArtifact(req, hash, metadata)
+	if err != nil {
+		s.logger.Warn("failed to store artifact", "hash", hash, "error", err)
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("failed to store artifact: %w", err))
+		return
+	}
+
+	s.mu.Lock()
+	if previous, ok := s.artifacts[hash]; ok {
+		s.size -= previous.size
+	}
+	s.artifacts[hash] = &artifact{size: size, lastUsed: s.now()}
+	s.size += size
+	s.counters.uploads++
+	s.counters.uploadedBytes += size
+	overLimit := s.maxSize > 0 && s.size > s.maxSize
+	s.mu.Unlock()
+	if overLimit {
+		s.collectGarbage()
+	}
+	writeJSON(w, http.StatusOK, map[string][]string{"urls": {req.URL.Path}})
+}
+
+// writeArtifact writes the body of req to a temporary file, which is renamed
+// into place once it's complete so that downloads never see a partial artifact
+func (s *Server) writeArtifact(req *http.Request, hash string, metadata artifactMetadata) (int64, error) {
+	f, err := ioutil.TempFile(s.dir.ToString(), _uploadPrefix)
+	if err != nil {
+		return 0, err
+	}
+	tempPath := f.Name()
+	defer func() { _ = os.Remove(tempPath) }()
+	size, err := io.Copy(f, req.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	if req.ContentLength >= 0 && size != req.ContentLength {
+		return 0, fmt.Errorf("expected %v bytes, received %v", req.ContentLength, size)
+	}
+	rawMetadata, err := json.Marshal(metadata)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.metadataPath(hash).WriteFile(rawMetadata, 0644); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tempPath, s.artifactPath(hash).ToString()); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+func (s *Server) writeMetrics(w http.ResponseWriter) {
+	s.mu.Lock()
+	c := s.counters
+	artifactCount := len(s.artifacts)
+	size := s.size
+	s.mu.Unlock()
+	metrics := []struct {
+		name       string
+		metricType string
+		help       string
+		value      int64
+	}{
+		{"turbo_cache_hits_total", "counter", "Artifacts downloaded", c.hits},
+		{"turbo_cache_misses_total", "counter", "Downloads of artifacts that are not in the cache", c.misses},
+		{"turbo_cache_uploads_total", "counter", "Artifacts uploaded", c.uploads},
+		{"turbo_cache_downloaded_bytes_total", "counter", "Bytes of artifacts downloaded", c.downloadedBytes},
+		{"turbo_cache_uploaded_bytes_total", "counter", "Bytes of artifacts uploaded", c.uploadedBytes},
+		{"turbo_cache_evictions_total", "counter", "Artifacts removed for exceeding the age or size limit", c.evictions},
+		{"turbo_cache_unauthorized_requests_total", "counter", "Requests with a missing or invalid token", c.unauthorized},
+		{"turbo_cache_artifacts", "gauge", "Artifacts in the cache", int64(artifactCount)},
+		{"turbo_cache_size_bytes", "gauge", "Total size of the artifacts in the cache", size},
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.WriteHeader(http.StatusOK)
+	for _, metric := range metrics {
+		fmt.Fprintf(w, "# HELP %v %v.\n# TYPE %v %v\n%v %v\n", metric.name, metric.help, metric.name, metric.metricType, metric.name, metric.value)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,134 @@
+package cacheserver
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/vercel/turbo/cli/internal/client"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+const _testToken = "secret"
+
+func startServer(t *testing.T, dir turbopath.AbsoluteSystemPath, maxSize int64) (*Server, *httptest.Server) {
+	t.Helper()
+	server, err := newServer(dir, _testToken, time.Hour, maxSize, hclog.NewNullLogger())
+	assert.NilError(t, err)
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	return server, httpServer
+}
+
+func request(t *testing.T, method string, url string, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	assert.NilError(t, err)
+	req.Header.Set("Authorization", "Bearer "+_testToken)
+	resp, err := http.DefaultClient.Do(req)
+	assert.NilError(t, err)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp
+}
+
+func TestRequiresToken(t *testing.T) {
+	_, httpServer := startServer(t, fs.AbsoluteSystemPathFromUpstream(t.TempDir()), 0)
+	for _, header := range []string{"", "Bearer wrong", _testToken} {
+		req, err := http.NewRequest(http.MethodGet, httpServer.URL+"/v8/artifacts/status", nil)
+		assert.NilError(t, err)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.NilError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, resp.StatusCode, http.StatusUnauthorized, header)
+	}
+	resp := request(t, http.MethodGet, httpServer.URL+"/v8/artifacts/status", "")
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+
+	resp, err := http.Get(httpServer.URL + "/metrics")
+	assert.NilError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	metrics, err := ioutil.ReadAll(resp.Body)
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(string(metrics), "\nturbo_cache_unauthorized_requests_total 3\n"), string(metrics))
+}
+
+func TestArtifacts(t *testing.T) {
+	dir := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	_, httpServer := startServer(t, dir, 0)
+
+	// The server is used through the same client as the Vercel Remote Cache
+	apiClient := client.NewClient(client.RemoteConfig{
+		Token:    _testToken,
+		TeamSlug: "any",
+		APIURL:   httpServer.URL,
+	}, hclog.NewNullLogger(), "test", client.Opts{})
+	apiClient.SetArtifactMetadata(map[string]string{"git-sha": "0123abc"})
+	assert.NilError(t, apiClient.PutArtifact("abc123", []byte("artifact"), 1500, "signature", "zstd"))
+
+	resp, err := apiClient.FetchArtifact("abc123")
+	assert.NilError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.NilError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, string(body), "artifact")
+	assert.Equal(t, resp.Header.Get("x-artifact-duration"), "1500")
+	assert.Equal(t, resp.Header.Get("x-artifact-tag"), "signature")
+	assert.Equal(t, resp.Header.Get("Content-Encoding"), "zstd")
+
+	resp, err = apiClient.ArtifactExists("abc123")
+	assert.NilError(t, err)
+	_ = resp.Body.Close()
+	assert.DeepEqual(t, client.ArtifactMetadata(resp.Header), map[string]string{"git-sha": "0123abc"})
+
+	resp, err = apiClient.ArtifactExists("missing")
+	assert.NilError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusNotFound)
+
+	assert.Equal(t, request(t, http.MethodGet, httpServer.URL+"/v8/artifacts/bad.hash", "").StatusCode, http.StatusBadRequest)
+	assert.Equal(t, request(t, http.MethodPut, httpServer.URL+"/v8/artifacts/def456", "").StatusCode, http.StatusOK)
+	assert.Equal(t, request(t, http.MethodPut, httpServer.URL+"/v8/artifacts/def456", "").Header.Get("Content-Type"), "application/json")
+
+	// Artifacts are kept when the server restarts
+	restarted, err := newServer(dir, _testToken, time.Hour, 0, hclog.NewNullLogger())
+	assert.NilError(t, err)
+	assert.Equal(t, len(restarted.artifacts), 2)
+	assert.Equal(t, restarted.size, int64(len("artifact")))
+}
+
+func TestCollectGarbage(t *testing.T) {
+	dir := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	server, httpServer := startServer(t, dir, 10)
+	now := time.Now()
+	server.now = func() time.Time { return now }
+
+	assert.Equal(t, request(t, http.MethodPut, httpServer.URL+"/v8/artifacts/old", "12345").StatusCode, http.StatusOK)
+	now = now.Add(time.Minute)
+	assert.Equal(t, request(t, http.MethodPut, httpServer.URL+"/v8/artifacts/used", "12345").StatusCode, http.StatusOK)
+	now = now.Add(time.Minute)
+	assert.Equal(t, request(t, http.MethodGet, httpServer.URL+"/v8/artifacts/old", "").StatusCode, http.StatusOK)
+
+	// "used" was used least recently, so it is removed to stay within 10 bytes
+	now = now.Add(time.Minute)
+	assert.Equal(t, request(t, http.MethodPut, httpServer.URL+"/v8/artifacts/new", "12345").StatusCode, http.StatusOK)
+	assert.Equal(t, request(t, http.MethodGet, httpServer.URL+"/v8/artifacts/used", "").StatusCode, http.StatusNotFound)
+	assert.Assert(t, !dir.UntypedJoin("used").FileExists())
+	assert.Assert(t, !dir.UntypedJoin("used.json").FileExists())
+
+	// Artifacts expire an hour after they were last used
+	now = now.Add(time.Hour)
+	server.collectGarbage()
+	assert.Equal(t, request(t, http.MethodGet, httpServer.URL+"/v8/artifacts/old", "").StatusCode, http.StatusNotFound)
+	assert.Equal(t, request(t, http.MethodGet, httpServer.URL+"/v8/artifacts/new", "").StatusCode, http.StatusOK)
+	assert.Equal(t, server.counters.evictions, int64(2))
+	assert.Equal(t, server.counters.misses, int64(2))
+}
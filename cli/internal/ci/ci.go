@@ -56,3 +56,27 @@ func Info() Vendor {
 	}
 	return Vendor{}
 }
+
+// JobURL returns the URL of the CI job that is running, for the vendors that
+// expose one, or the empty string
+func JobURL() string {
+	switch Constant() {
+	case "GITHUB_ACTIONS":
+		server, repository, runID := os.Getenv("GITHUB_SERVER_URL"), os.Getenv("GITHUB_REPOSITORY"), os.Getenv("GITHUB_RUN_ID")
+		if server == "" || repository == "" || runID == "" {
+			return ""
+		}
+		return server + "/" + repository + "/actions/runs/" + runID
+	case "GITLAB":
+		return os.Getenv("CI_JOB_URL")
+	case "CIRCLE":
+		return os.Getenv("CIRCLE_BUILD_URL")
+	case "BUILDKITE":
+		return os.Getenv("BUILDKITE_BUILD_URL")
+	case "JENKINS":
+		return os.Getenv("BUILD_URL")
+	case "TRAVIS":
+		return os.Getenv("TRAVIS_BUILD_WEB_URL")
+	}
+	return ""
+}
@@ -103,3 +103,20 @@ func TestInfo(t *testing.T) {
 		})
 	}
 }
+
+func TestJobURL(t *testing.T) {
+	t.Setenv("GITHUB_ACTIONS", "true")
+	t.Setenv("GITHUB_SERVER_URL", "https://github.com")
+	t.Setenv("GITHUB_REPOSITORY", "vercel/turbo")
+	t.Setenv("GITHUB_RUN_ID", "42")
+	if got := JobURL(); got != "https://github.com/vercel/turbo/actions/runs/42" {
+		t.Errorf("JobURL() = %v on GitHub Actions", got)
+	}
+
+	t.Setenv("GITHUB_ACTIONS", "")
+	t.Setenv("GITLAB_CI", "true")
+	t.Setenv("CI_JOB_URL", "https://gitlab.com/group/project/-/jobs/7")
+	if got := JobURL(); got != "https://gitlab.com/group/project/-/jobs/7" {
+		t.Errorf("JobURL() = %v on GitLab CI", got)
+	}
+}
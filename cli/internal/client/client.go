@@ -1,6 +1,7 @@
 package client
 
 import (
+	"bytes"
 	"context"
 	"crypto/x509"
 	"encoding/json"
@@ -11,6 +12,7 @@ import (
 	"net/http"
 	"net/url"
 	"runtime"
+	"sort"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -27,7 +29,8 @@ type ApiClient struct {
 	token        string
 	turboVersion string
 
-	// Must be used via atomic package
+	// The requests that failed since the last one that got a response. Must
+	// be used via atomic package
 	currentFailCount uint64
 	// An http client
 	HttpClient *retryablehttp.Client
@@ -35,15 +38,54 @@ type ApiClient struct {
 	teamSlug   string
 	// Whether or not to send preflight requests before uploads
 	usePreflight bool
+	// Limit the combined rate of all artifact uploads and downloads. nil if unlimited
+	uploadLimiter   *rateLimiter
+	downloadLimiter *rateLimiter
+	// The rate limit of each artifact transfer, in bytes per second, or 0 if unlimited
+	transferLimit int64
+	// Metadata sent with each uploaded artifact, such as the branch it came from
+	artifactMetadata map[string]string
 }
 
-// ErrTooManyFailures is returned from remote cache API methods after `maxRemoteFailCount` errors have occurred
+// ErrTooManyFailures is returned from remote cache API methods after `maxRemoteFailCount` errors have occurred in a row
 var ErrTooManyFailures = errors.New("skipping HTTP Request, too many failures have occurred")
 
-// _maxRemoteFailCount is the number of failed requests before we stop trying to upload/download
+// _maxRemoteFailCount is the number of failed requests in a row before we stop trying to upload/download
 // artifacts to the remote cache
 const _maxRemoteFailCount = uint64(3)
 
+// _artifactEncodings are the Content-Encodings of the artifacts that the cache
+// can decompress. Asking for them also keeps the transport from decompressing
+// gzip artifacts on its own.
+const _artifactEncodings = "zstd, gzip"
+
+// _artifactMetaHeaderPrefix starts the name of the headers that carry the
+// metadata of an uploaded artifact
+const _artifactMetaHeaderPrefix = "x-artifact-meta-"
+
+// metadataHeaders returns the sorted names of the headers of metadata
+func metadataHeaders(prefix string, metadata map[string]string) []string {
+	names := make([]string, 0, len(metadata))
+	for key := range metadata {
+		names = append(names, prefix+key)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ArtifactMetadata returns the metadata that an artifact was uploaded with,
+// from the headers of the response to a request for it
+func ArtifactMetadata(header http.Header) map[string]string {
+	metadata := map[string]string{}
+	for name, values := range header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, _artifactMetaHeaderPrefix) && len(values) > 0 {
+			metadata[strings.TrimPrefix(name, _artifactMetaHeaderPrefix)] = values[0]
+		}
+	}
+	return metadata
+}
+
 // SetToken updates the ApiClient's Token
 func (c *ApiClient) SetToken(token string) {
 	c.token = token
@@ -61,6 +103,11 @@ type RemoteConfig struct {
 type Opts struct {
 	UsePreflight bool
 	Timeout      uint64
+	// Rate limits for artifact transfers, in bytes per second. 0 is unlimited.
+	// UploadLimit and DownloadLimit apply to all transfers combined.
+	UploadLimit   int64
+	DownloadLimit int64
+	TransferLimit int64
 }
 
 // ClientTimeout Exported ClientTimeout used in run.go
@@ -81,10 +128,13 @@ func NewClient(remoteConfig RemoteConfig, logger hclog.Logger, turboVersion stri
 			Backoff:      retryablehttp.DefaultBackoff,
 			Logger:       logger,
 		},
-		token:        remoteConfig.Token,
-		teamID:       remoteConfig.TeamID,
-		teamSlug:     remoteConfig.TeamSlug,
-		usePreflight: opts.UsePreflight,
+		token:           remoteConfig.Token,
+		teamID:          remoteConfig.TeamID,
+		teamSlug:        remoteConfig.TeamSlug,
+		usePreflight:    opts.UsePreflight,
+		uploadLimiter:   newRateLimiter(opts.UploadLimit),
+		downloadLimiter: newRateLimiter(opts.DownloadLimit),
+		transferLimit:   opts.TransferLimit,
 	}
 	client.HttpClient.CheckRetry = client.checkRetry
 	return client
@@ -105,6 +155,12 @@ func (c *ApiClient) SetTeamID(teamID string) {
 	c.teamID = teamID
 }
 
+// SetArtifactMetadata sets the metadata that is sent with every uploaded
+// artifact, each in an x-artifact-meta-<key> header
+func (c *ApiClient) SetArtifactMetadata(metadata map[string]string) {
+	c.artifactMetadata = metadata
+}
+
 // GetTeamID returns the currently configured team id
 func (c *ApiClient) GetTeamID() string {
 	return c.teamID
@@ -138,7 +194,9 @@ func (c *ApiClient) retryCachePolicy(resp *http.Response, err error) (bool, erro
 		return true, fmt.Errorf("unexpected HTTP status %s", resp.Status)
 	}
 
-	// swallow the error and stop retrying
+	// swallow the error and stop retrying. The server responded, so the
+	// failures so far are forgiven
+	atomic.StoreUint64(&c.currentFailCount, 0)
 	return false, nil
 }
 
@@ -175,7 +233,11 @@ func (c *ApiClient) makeUrl(endpoint string) string {
 }
 
 func (c *ApiClient) UserAgent() string {
-	return fmt.Sprintf("turbo %v %v %v (%v)", c.turboVersion, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return userAgent(c.turboVersion)
+}
+
+func userAgent(turboVersion string) string {
+	return fmt.Sprintf("turbo %v %v %v (%v)", turboVersion, runtime.Version(), runtime.GOOS, runtime.GOARCH)
 }
 
 // doPreflight returns response with closed body, latest request url, and any errors to the caller
@@ -212,6 +274,21 @@ func (c *ApiClient) doPreflight(requestURL string, requestMethod string, request
 	return resp, requestURL, nil
 }
 
+// statusError is returned for a response with an unexpected status code
+type statusError struct {
+	statusCode int
+	message    string
+}
+
+func (e *statusError) Error() string {
+	return e.message
+}
+
+// HTTPStatusCode is the status code of the response
+func (e *statusError) HTTPStatusCode() int {
+	return e.statusCode
+}
+
 type apiError struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
@@ -249,7 +326,7 @@ func (c *ApiClient) handle403(body io.Reader) error {
 	return disabledErr
 }
 
-func (c *ApiClient) PutArtifact(hash string, artifactBody []byte, duration int, tag string) error {
+func (c *ApiClient) PutArtifact(hash string, artifactBody []byte, duration int, tag string, contentEncoding string) error {
 	if err := c.okToRequest(); err != nil {
 		return err
 	}
@@ -264,7 +341,11 @@ func (c *ApiClient) PutArtifact(hash string, artifactBody []byte, duration int,
 	requestURL := c.makeUrl("/v8/artifacts/" + hash + encoded)
 	allowAuth := true
 	if c.usePreflight {
-		resp, latestRequestURL, err := c.doPreflight(requestURL, http.MethodPut, "Content-Type, x-artifact-duration, Authorization, User-Agent, x-artifact-tag")
+		requestHeaders := "Content-Type, Content-Encoding, x-artifact-duration, Authorization, User-Agent, x-artifact-tag"
+		for _, name := range metadataHeaders(_artifactMetaHeaderPrefix, c.artifactMetadata) {
+			requestHeaders += ", " + name
+		}
+		resp, latestRequestURL, err := c.doPreflight(requestURL, http.MethodPut, requestHeaders)
 		if err != nil {
 			return fmt.Errorf("pre-flight request failed before trying to store in HTTP cache: %w", err)
 		}
@@ -273,8 +354,23 @@ func (c *ApiClient) PutArtifact(hash string, artifactBody []byte, duration int,
 		allowAuth = strings.Contains(strings.ToLower(headers), strings.ToLower("Authorization"))
 	}
 
-	req, err := retryablehttp.NewRequest(http.MethodPut, requestURL, artifactBody)
+	var body interface{} = artifactBody
+	if c.uploadLimiter != nil || c.transferLimit > 0 {
+		// A new reader is created for each attempt, in case the upload is retried
+		body = retryablehttp.ReaderFunc(func() (io.Reader, error) {
+			return throttle(bytes.NewReader(artifactBody), c.uploadLimiter, newRateLimiter(c.transferLimit)), nil
+		})
+	}
+	req, err := retryablehttp.NewRequest(http.MethodPut, requestURL, body)
+	if err != nil {
+		return fmt.Errorf("[WARNING] Invalid cache URL: %w", err)
+	}
+	// The length isn't known from a ReaderFunc, and the cache expects it
+	req.ContentLength = int64(len(artifactBody))
 	req.Header.Set("Content-Type", "application/octet-stream")
+	if contentEncoding != "" {
+		req.Header.Set("Content-Encoding", contentEncoding)
+	}
 	req.Header.Set("x-artifact-duration", fmt.Sprintf("%v", duration))
 	if allowAuth {
 		req.Header.Set("Authorization", "Bearer "+c.token)
@@ -286,8 +382,8 @@ func (c *ApiClient) PutArtifact(hash string, artifactBody []byte, duration int,
 	if tag != "" {
 		req.Header.Set("x-artifact-tag", tag)
 	}
-	if err != nil {
-		return fmt.Errorf("[WARNING] Invalid cache URL: %w", err)
+	for key, value := range c.artifactMetadata {
+		req.Header.Set(_artifactMetaHeaderPrefix+key, value)
 	}
 
 	resp, err := c.HttpClient.Do(req)
@@ -299,7 +395,10 @@ func (c *ApiClient) PutArtifact(hash string, artifactBody []byte, duration int,
 		return c.handle403(resp.Body)
 	}
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("[ERROR] Failed to store files in HTTP cache: %s against URL %s", resp.Status, requestURL)
+		return &statusError{
+			statusCode: resp.StatusCode,
+			message:    fmt.Sprintf("[ERROR] Failed to store files in HTTP cache: %s against URL %s", resp.Status, requestURL),
+		}
 	}
 	return nil
 }
@@ -352,6 +451,7 @@ func (c *ApiClient) getArtifact(hash string, httpMethod string) (*http.Response,
 		req.Header.Set("Authorization", "Bearer "+c.token)
 	}
 	req.Header.Set("User-Agent", c.UserAgent())
+	req.Header.Set("Accept-Encoding", _artifactEncodings)
 	if err != nil {
 		return nil, fmt.Errorf("invalid cache URL: %w", err)
 	}
@@ -364,6 +464,12 @@ func (c *ApiClient) getArtifact(hash string, httpMethod string) (*http.Response,
 		_ = resp.Body.Close()
 		return nil, err
 	}
+	if httpMethod == http.MethodGet && (c.downloadLimiter != nil || c.transferLimit > 0) {
+		resp.Body = throttledReadCloser{
+			Reader: throttle(resp.Body, c.downloadLimiter, newRateLimiter(c.transferLimit)),
+			Closer: resp.Body,
+		}
+	}
 	return resp, nil
 }
 
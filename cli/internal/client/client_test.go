@@ -94,7 +94,7 @@ func Test_PutArtifact(t *testing.T) {
 	expectedArtifactBody := []byte("My string artifact")
 
 	// Test Put Artifact
-	apiClient.PutArtifact("hash", expectedArtifactBody, 500, "")
+	apiClient.PutArtifact("hash", expectedArtifactBody, 500, "", "")
 	testBody := <-ch
 	if !bytes.Equal(expectedArtifactBody, testBody) {
 		t.Errorf("Handler read '%v', wants '%v'", testBody, expectedArtifactBody)
@@ -102,6 +102,29 @@ func Test_PutArtifact(t *testing.T) {
 
 }
 
+func Test_PutArtifactMetadata(t *testing.T) {
+	ch := make(chan http.Header, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		defer req.Body.Close()
+		ch <- req.Header
+		w.WriteHeader(200)
+	}))
+	defer ts.Close()
+
+	apiClient := NewClient(RemoteConfig{TeamSlug: "my-team-slug", APIURL: ts.URL, Token: "my-token"}, hclog.Default(), "v1", Opts{})
+	apiClient.SetArtifactMetadata(map[string]string{"branch": "feature/login", "scope": "feature-login"})
+	if err := apiClient.PutArtifact("hash", []byte("artifact"), 500, "", ""); err != nil {
+		t.Fatalf("PutArtifact: %v", err)
+	}
+	header := <-ch
+	if got := header.Get("x-artifact-meta-branch"); got != "feature/login" {
+		t.Errorf("x-artifact-meta-branch is %q, wants %q", got, "feature/login")
+	}
+	if got := header.Get("x-artifact-meta-scope"); got != "feature-login" {
+		t.Errorf("x-artifact-meta-scope is %q, wants %q", got, "feature-login")
+	}
+}
+
 func Test_PutWhenCachingDisabled(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		defer func() { _ = req.Body.Close() }()
@@ -119,7 +142,7 @@ func Test_PutWhenCachingDisabled(t *testing.T) {
 	apiClient := NewClient(remoteConfig, hclog.Default(), "v1", Opts{})
 	expectedArtifactBody := []byte("My string artifact")
 	// Test Put Artifact
-	err := apiClient.PutArtifact("hash", expectedArtifactBody, 500, "")
+	err := apiClient.PutArtifact("hash", expectedArtifactBody, 500, "", "")
 	cd := &util.CacheDisabledError{}
 	if !errors.As(err, &cd) {
 		t.Errorf("expected cache disabled error, got %v", err)
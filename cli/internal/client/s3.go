@@ -0,0 +1,313 @@
+package client
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// S3Config describes a bucket in an S3-compatible object store
+type S3Config struct {
+	Bucket string
+	// Prefix is prepended to the key of every artifact, such as "turbo/"
+	Prefix string
+	// Region defaults to us-east-1
+	Region string
+	// Endpoint is the base URL of the store. It defaults to Amazon S3 in Region.
+	Endpoint string
+	// PathStyle puts the bucket in the path of URLs instead of in the host name,
+	// as most self-hosted stores expect
+	PathStyle bool
+	// Requests are unsigned if AccessKeyID is empty
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Metadata is stored with each uploaded artifact, such as the branch it
+	// came from, as x-amz-meta-artifact-meta-<key>
+	Metadata map[string]string
+}
+
+// The metadata of an artifact in the bucket, which holds what the Vercel Remote
+// Cache returns in the x-artifact-duration and x-artifact-tag headers
+const (
+	_s3DurationHeader = "x-amz-meta-artifact-duration"
+	_s3TagHeader      = "x-amz-meta-artifact-tag"
+	// _s3MetaHeaderPrefix mirrors the x-artifact-meta- headers of ApiClient
+	_s3MetaHeaderPrefix = "x-amz-meta-artifact-meta-"
+)
+
+// _emptyPayloadHash is the SHA-256 hash of an empty request body
+const _emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Client stores artifacts in a bucket of an S3-compatible object store, such
+// as Amazon S3, Google Cloud Storage or MinIO, in place of the Vercel Remote
+// Cache API. Requests are signed with AWS Signature Version 4.
+//
+// Amazon S3 only reports a missing artifact as missing if the credentials can
+// list the bucket. Otherwise it responds with 403, which is reported as an error.
+type S3Client struct {
+	config       S3Config
+	baseURL      *url.URL
+	turboVersion string
+	// An http client
+	HttpClient *retryablehttp.Client
+	// Limit the combined rate of all artifact uploads and downloads. nil if unlimited
+	uploadLimiter   *rateLimiter
+	downloadLimiter *rateLimiter
+	// The rate limit of each artifact transfer, in bytes per second, or 0 if unlimited
+	transferLimit int64
+	// now returns the time that requests are signed at
+	now func() time.Time
+}
+
+// NewS3Client creates a new S3Client. Of opts, only the timeout and rate limits apply.
+func NewS3Client(config S3Config, logger hclog.Logger, turboVersion string, opts Opts) (*S3Client, error) {
+	if config.Bucket == "" {
+		return nil, errors.New("a bucket is required for an S3 remote cache")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%v.amazonaws.com", config.Region)
+	}
+	baseURL, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+	if err != nil || baseURL.Host == "" || (baseURL.Scheme != "http" && baseURL.Scheme != "https") {
+		return nil, fmt.Errorf("invalid S3 endpoint %q, expected a URL such as https://storage.googleapis.com", endpoint)
+	}
+	return &S3Client{
+		config:       config,
+		baseURL:      baseURL,
+		turboVersion: turboVersion,
+		HttpClient: &retryablehttp.Client{
+			HTTPClient: &http.Client{
+				Timeout: time.Duration(opts.Timeout) * time.Second,
+			},
+			RetryWaitMin: 2 * time.Second,
+			RetryWaitMax: 10 * time.Second,
+			RetryMax:     2,
+			Backoff:      retryablehttp.DefaultBackoff,
+			CheckRetry:   retryablehttp.DefaultRetryPolicy,
+			Logger:       logger,
+		},
+		uploadLimiter:   newRateLimiter(opts.UploadLimit),
+		downloadLimiter: newRateLimiter(opts.DownloadLimit),
+		transferLimit:   opts.TransferLimit,
+		now:             time.Now,
+	}, nil
+}
+
+// GetTeamID returns the empty string, since a bucket doesn't belong to a team
+func (c *S3Client) GetTeamID() string {
+	return ""
+}
+
+// PutArtifact uploads the build artifact with the given hash to the bucket
+func (c *S3Client) PutArtifact(hash string, artifactBody []byte, duration int, tag string, contentEncoding string) error {
+	var body interface{} = artifactBody
+	if c.uploadLimiter != nil || c.transferLimit > 0 {
+		// A new reader is created for each attempt, in case the upload is retried
+		body = retryablehttp.ReaderFunc(func() (io.Reader, error) {
+			return throttle(bytes.NewReader(artifactBody), c.uploadLimiter, newRateLimiter(c.transferLimit)), nil
+		})
+	}
+	req, err := retryablehttp.NewRequest(http.MethodPut, c.objectURL(hash).String(), body)
+	if err != nil {
+		return fmt.Errorf("invalid S3 cache URL: %w", err)
+	}
+	req.ContentLength = int64(len(artifactBody))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if contentEncoding != "" {
+		req.Header.Set("Content-Encoding", contentEncoding)
+	}
+	req.Header.Set(_s3DurationHeader, fmt.Sprintf("%v", duration))
+	if tag != "" {
+		req.Header.Set(_s3TagHeader, tag)
+	}
+	for key, value := range c.config.Metadata {
+		req.Header.Set(_s3MetaHeaderPrefix+key, value)
+	}
+	payloadHash := sha256.Sum256(artifactBody)
+	c.sign(req.Request, hex.EncodeToString(payloadHash[:]))
+
+	resp, err := c.HttpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to store files in S3 cache: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return &statusError{
+			statusCode: resp.StatusCode,
+			message:    fmt.Sprintf("failed to store files in S3 cache: %v", s3Error(resp)),
+		}
+	}
+	return nil
+}
+
+// FetchArtifact downloads the build artifact with the given hash from the bucket
+func (c *S3Client) FetchArtifact(hash string) (*http.Response, error) {
+	return c.getArtifact(hash, http.MethodGet)
+}
+
+// ArtifactExists checks whether the build artifact with the given hash is in the bucket
+func (c *S3Client) ArtifactExists(hash string) (*http.Response, error) {
+	return c.getArtifact(hash, http.MethodHead)
+}
+
+// getArtifact returns the response for the artifact with the given hash, with
+// its metadata in the headers that the Vercel Remote Cache uses
+func (c *S3Client) getArtifact(hash string, httpMethod string) (*http.Response, error) {
+	req, err := retryablehttp.NewRequest(httpMethod, c.objectURL(hash).String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 cache URL: %w", err)
+	}
+	req.Header.Set("Accept-Encoding", _artifactEncodings)
+	c.sign(req.Request, _emptyPayloadHash)
+
+	resp, err := c.HttpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch artifact: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		defer func() { _ = resp.Body.Close() }()
+		return nil, &statusError{
+			statusCode: resp.StatusCode,
+			message:    fmt.Sprintf("failed to fetch artifact: %v", s3Error(resp)),
+		}
+	}
+	if duration := resp.Header.Get(_s3DurationHeader); duration != "" {
+		resp.Header.Set("x-artifact-duration", duration)
+	}
+	if tag := resp.Header.Get(_s3TagHeader); tag != "" {
+		resp.Header.Set("x-artifact-tag", tag)
+	}
+	for name, values := range resp.Header {
+		if key := strings.ToLower(name); strings.HasPrefix(key, _s3MetaHeaderPrefix) && len(values) > 0 {
+			resp.Header.Set(_artifactMetaHeaderPrefix+strings.TrimPrefix(key, _s3MetaHeaderPrefix), values[0])
+		}
+	}
+	if httpMethod == http.MethodGet && (c.downloadLimiter != nil || c.transferLimit > 0) {
+		resp.Body = throttledReadCloser{
+			Reader: throttle(resp.Body, c.downloadLimiter, newRateLimiter(c.transferLimit)),
+			Closer: resp.Body,
+		}
+	}
+	return resp, nil
+}
+
+// objectURL returns the URL of the object for the artifact with the given hash
+func (c *S3Client) objectURL(hash string) *url.URL {
+	u := *c.baseURL
+	path := "/" + c.config.Prefix + hash
+	if c.config.PathStyle {
+		path = "/" + c.config.Bucket + path
+	} else {
+		u.Host = c.config.Bucket + "." + u.Host
+	}
+	u.Path = u.Path + path
+	u.RawPath = s3EscapePath(u.Path)
+	return &u
+}
+
+// s3EscapePath percent-encodes every byte of a path except the unreserved
+// characters and "/", as Signature Version 4 requires of the canonical URI
+func s3EscapePath(path string) string {
+	var builder strings.Builder
+	for i := 0; i < len(path); i++ {
+		b := path[i]
+		if ('A' <= b && b <= 'Z') || ('a' <= b && b <= 'z') || ('0' <= b && b <= '9') || strings.IndexByte("-._~/", b) >= 0 {
+			builder.WriteByte(b)
+		} else {
+			fmt.Fprintf(&builder, "%%%02X", b)
+		}
+	}
+	return builder.String()
+}
+
+// sign adds the AWS Signature Version 4 authorization to req, whose body has
+// the given hex-encoded SHA-256 hash. Every header except User-Agent is signed.
+func (c *S3Client) sign(req *http.Request, payloadHash string) {
+	req.Header.Set("User-Agent", userAgent(c.turboVersion))
+	if c.config.AccessKeyID == "" {
+		return
+	}
+	amzDate := c.now().UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	if c.config.SessionToken != "" {
+		req.Header.Set("x-amz-security-token", c.config.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "user-agent" || name == "authorization" {
+			continue
+		}
+		headers[name] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	scope := date + "/" + c.config.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalRequestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+c.config.SecretAccessKey), date)
+	key = hmacSHA256(key, c.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%v/%v, SignedHeaders=%v, Signature=%v", c.config.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Error describes an error response, using the message in its XML body if it has one
+func s3Error(resp *http.Response) string {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	var s3Err struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := xml.Unmarshal(body, &s3Err); err == nil && s3Err.Code != "" {
+		return fmt.Sprintf("%v %v: %v", resp.Status, s3Err.Code, s3Err.Message)
+	}
+	return resp.Status
+}
@@ -0,0 +1,141 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// _sizeUnits are the suffixes accepted by ParseSize and ParseRate, longest first
+// so that "MiB" is matched before "B"
+var _sizeUnits = []struct {
+	suffix string
+	bytes  float64
+}{
+	{"kib", 1 << 10},
+	{"mib", 1 << 20},
+	{"gib", 1 << 30},
+	{"kb", 1e3},
+	{"mb", 1e6},
+	{"gb", 1e9},
+	{"b", 1},
+}
+
+// ParseSize parses a number of bytes, such as "500KB", "10GB" or "1.5MiB". A
+// number without a unit is a number of bytes.
+func ParseSize(value string) (int64, error) {
+	size, ok := parseBytes(value)
+	if !ok {
+		return 0, fmt.Errorf("%q is not a size, expected a value such as \"10GB\"", value)
+	}
+	return size, nil
+}
+
+// ParseRate parses a transfer rate in bytes per second, such as "500KB", "10MB/s"
+// or "1.5MiB". A number without a unit is a number of bytes.
+func ParseRate(value string) (int64, error) {
+	rate, ok := parseBytes(strings.TrimSuffix(strings.ToLower(strings.TrimSpace(value)), "/s"))
+	if !ok {
+		return 0, fmt.Errorf("%q is not a transfer rate, expected a value such as \"10MB\"", value)
+	}
+	return rate, nil
+}
+
+func parseBytes(value string) (int64, bool) {
+	s := strings.ToLower(strings.TrimSpace(value))
+	multiplier := 1.0
+	for _, unit := range _sizeUnits {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSuffix(s, unit.suffix)
+			multiplier = unit.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || n <= 0 || math.IsInf(n, 0) {
+		return 0, false
+	}
+	return int64(math.Max(1, n*multiplier)), true
+}
+
+// rateLimiter is a token bucket that allows bytesPerSecond, with bursts of up to
+// one second of transfer. It is safe to share between concurrent transfers.
+type rateLimiter struct {
+	mu             sync.Mutex
+	bytesPerSecond float64
+	tokens         float64
+	last           time.Time
+}
+
+func newRateLimiter(bytesPerSecond int64) *rateLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		bytesPerSecond: float64(bytesPerSecond),
+		tokens:         float64(bytesPerSecond),
+		last:           time.Now(),
+	}
+}
+
+// wait blocks until n more bytes may be transferred. Transfers that exceed the
+// available tokens borrow against the future, so later callers wait for them too.
+func (l *rateLimiter) wait(n int) {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = math.Min(l.bytesPerSecond, l.tokens+now.Sub(l.last).Seconds()*l.bytesPerSecond)
+	l.last = now
+	l.tokens -= float64(n)
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.bytesPerSecond * float64(time.Second))
+	}
+	l.mu.Unlock()
+	time.Sleep(delay)
+}
+
+// _throttleChunkSize bounds each read, so that a limiter is never asked for much
+// more than it allows in a burst
+const _throttleChunkSize = 32 * 1024
+
+// throttledReader limits the rate at which r is read to the slowest of limiters
+type throttledReader struct {
+	r        io.Reader
+	limiters []*rateLimiter
+}
+
+// throttle wraps r so that reading from it respects each of the non-nil limiters.
+// r is returned as-is if there are none.
+func throttle(r io.Reader, limiters ...*rateLimiter) io.Reader {
+	active := []*rateLimiter{}
+	for _, limiter := range limiters {
+		if limiter != nil {
+			active = append(active, limiter)
+		}
+	}
+	if len(active) == 0 {
+		return r
+	}
+	return &throttledReader{r: r, limiters: active}
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > _throttleChunkSize {
+		p = p[:_throttleChunkSize]
+	}
+	n, err := t.r.Read(p)
+	for _, limiter := range t.limiters {
+		limiter.wait(n)
+	}
+	return n, err
+}
+
+// throttledReadCloser throttles reads from a response body while still closing it
+type throttledReadCloser struct {
+	io.Reader
+	io.Closer
+}
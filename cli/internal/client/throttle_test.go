@@ -0,0 +1,71 @@
+package client
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	testCases := []struct {
+		value string
+		want  int64
+	}{
+		{value: "1024", want: 1024},
+		{value: "500B", want: 500},
+		{value: "500KB", want: 500_000},
+		{value: "10MB/s", want: 10_000_000},
+		{value: "1.5MiB", want: 1_572_864},
+		{value: "2 gb", want: 2_000_000_000},
+		{value: "1GiB/s", want: 1 << 30},
+	}
+	for _, tc := range testCases {
+		got, err := ParseRate(tc.value)
+		if err != nil {
+			t.Errorf("ParseRate(%q) returned error %v", tc.value, err)
+		} else if got != tc.want {
+			t.Errorf("ParseRate(%q) got %v, want %v", tc.value, got, tc.want)
+		}
+	}
+
+	for _, value := range []string{"", "fast", "MB", "0", "-5MB", "10TB"} {
+		if _, err := ParseRate(value); err == nil {
+			t.Errorf("ParseRate(%q) expected an error", value)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	if got, err := ParseSize("10GB"); err != nil || got != 10_000_000_000 {
+		t.Errorf("ParseSize(\"10GB\") got %v, %v", got, err)
+	}
+	if _, err := ParseSize("10MB/s"); err == nil {
+		t.Errorf("ParseSize(\"10MB/s\") expected an error")
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	if r := throttle(bytes.NewReader(nil), nil, nil); r == nil {
+		t.Fatal("throttle returned nil")
+	} else if _, ok := r.(*throttledReader); ok {
+		t.Error("throttle without limiters should return the reader as-is")
+	}
+
+	// The limiter starts with a second of tokens, so reading two and a half
+	// seconds' worth takes about one and a half seconds
+	limiter := newRateLimiter(100_000)
+	data := make([]byte, 250_000)
+	start := time.Now()
+	read, err := ioutil.ReadAll(throttle(bytes.NewReader(data), limiter))
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if len(read) != len(data) {
+		t.Errorf("read %v bytes, want %v", len(read), len(data))
+	}
+	if elapsed < time.Second {
+		t.Errorf("read took %v, want at least 1s", elapsed)
+	}
+}
@@ -9,13 +9,28 @@ import (
 	"runtime/trace"
 
 	"github.com/pkg/errors"
+	"github.com/vercel/turbo/cli/internal/audit"
+	"github.com/vercel/turbo/cli/internal/boundaries"
+	"github.com/vercel/turbo/cli/internal/cacheserver"
 	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/compare"
+	"github.com/vercel/turbo/cli/internal/completion"
 	"github.com/vercel/turbo/cli/internal/daemon"
+	"github.com/vercel/turbo/cli/internal/doctor"
+	"github.com/vercel/turbo/cli/internal/generate"
+	"github.com/vercel/turbo/cli/internal/insights"
+	"github.com/vercel/turbo/cli/internal/login"
+	"github.com/vercel/turbo/cli/internal/ls"
 	"github.com/vercel/turbo/cli/internal/process"
 	"github.com/vercel/turbo/cli/internal/prune"
+	"github.com/vercel/turbo/cli/internal/query"
 	"github.com/vercel/turbo/cli/internal/run"
+	"github.com/vercel/turbo/cli/internal/runs"
+	"github.com/vercel/turbo/cli/internal/serve"
 	"github.com/vercel/turbo/cli/internal/signals"
+	"github.com/vercel/turbo/cli/internal/telemetry"
 	"github.com/vercel/turbo/cli/internal/turbostate"
+	"github.com/vercel/turbo/cli/internal/userdefaults"
 	"github.com/vercel/turbo/cli/internal/util"
 )
 
@@ -64,12 +79,62 @@ func RunWithArgs(args *turbostate.ParsedArgsFromRust, turboVersion string) int {
 	var execErr error
 	go func() {
 		command := args.Command
-		if command.Daemon != nil {
-			execErr = daemon.ExecuteDaemon(ctx, helper, signalWatcher, args)
+		if command.Audit != nil {
+			execErr = audit.ExecuteAudit(helper, args)
+		} else if command.Bench != nil {
+			execErr = run.ExecuteBench(ctx, helper, signalWatcher, args)
+		} else if command.Boundaries != nil {
+			execErr = boundaries.ExecuteBoundaries(helper, args)
+		} else if command.Cache != nil {
+			execErr = cacheserver.ExecuteCache(ctx, helper, signalWatcher, args)
+		} else if command.Compare != nil {
+			execErr = compare.ExecuteCompare(helper, args)
+		} else if command.Complete != nil {
+			execErr = completion.ExecuteComplete(helper, args)
+		} else if command.Config != nil {
+			execErr = userdefaults.ExecuteConfig(helper, args)
+		} else if command.Daemon != nil {
+			execErr = daemon.ExecuteDaemon(ctx, helper, signalWatcher, args, run.ExplainTask)
+		} else if command.Doctor != nil {
+			execErr = doctor.ExecuteDoctor(ctx, helper, args)
+		} else if command.Explain != nil {
+			execErr = run.ExecuteExplain(ctx, helper, signalWatcher, args)
+		} else if command.Flaky != nil {
+			execErr = insights.ExecuteFlaky(helper, args)
+		} else if command.Gen != nil {
+			execErr = generate.ExecuteGen(helper, args)
+		} else if command.GlobalHash != nil {
+			execErr = run.ExecuteGlobalHash(helper, signalWatcher, args)
+		} else if command.HashInputs != nil {
+			execErr = run.ExecuteHashInputs(helper, signalWatcher, args)
+		} else if command.Info != nil {
+			execErr = ls.ExecuteInfo(helper, args)
+		} else if command.Insights != nil {
+			execErr = insights.ExecuteInsights(helper, args)
+		} else if command.Login != nil {
+			execErr = login.ExecuteLogin(ctx, helper, args)
+		} else if command.Ls != nil {
+			execErr = ls.ExecuteLs(helper, args)
+		} else if command.Prefetch != nil {
+			execErr = run.ExecutePrefetch(ctx, helper, signalWatcher, args)
 		} else if command.Prune != nil {
 			execErr = prune.ExecutePrune(helper, args)
+		} else if command.Query != nil {
+			execErr = query.ExecuteQuery(helper, args)
+		} else if command.Replay != nil {
+			execErr = run.ExecuteReplay(ctx, helper, signalWatcher, args)
 		} else if command.Run != nil {
 			execErr = run.ExecuteRun(ctx, helper, signalWatcher, args)
+		} else if command.Runs != nil {
+			execErr = runs.ExecuteRuns(helper, args)
+		} else if command.Serve != nil {
+			execErr = serve.ExecuteServe(ctx, helper, signalWatcher, args)
+		} else if command.Telemetry != nil {
+			execErr = telemetry.ExecuteTelemetry(helper, args)
+		} else if command.Token != nil {
+			execErr = login.ExecuteToken(helper, args)
+		} else if command.Worker != nil {
+			execErr = run.ExecuteWorker(ctx, helper, signalWatcher, args)
 		} else {
 			execErr = fmt.Errorf("unknown command: %v", command)
 		}
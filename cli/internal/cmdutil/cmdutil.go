@@ -14,6 +14,7 @@ import (
 
 	"github.com/fatih/color"
 	"github.com/mitchellh/cli"
+	"github.com/vercel/turbo/cli/internal/analytics"
 	"github.com/vercel/turbo/cli/internal/client"
 	"github.com/vercel/turbo/cli/internal/config"
 	"github.com/vercel/turbo/cli/internal/fs"
@@ -25,6 +26,8 @@ import (
 const (
 	// _envLogLevel is the environment log level
 	_envLogLevel = "TURBO_LOG_LEVEL"
+	// _envTelemetryDebug prints usage events as they are sent
+	_envTelemetryDebug = "TURBO_TELEMETRY_DEBUG"
 )
 
 // Helper is a struct used to hold configuration values passed via flag, env vars,
@@ -67,15 +70,26 @@ func (h *Helper) Cleanup(cliConfig *turbostate.ParsedArgsFromRust) {
 	for _, cleanup := range h.cleanups {
 		if err := cleanup.Close(); err != nil {
 			if ui == nil {
-				ui = h.getUI(cliConfig)
+				ui = h.getUI(cliConfig, "")
 			}
 			ui.Warn(fmt.Sprintf("failed cleanup: %v", err))
 		}
 	}
 }
 
-func (h *Helper) getUI(cliConfig *turbostate.ParsedArgsFromRust) cli.Ui {
+// getUI builds the UI for the given flags. defaultColor is the user-level
+// "color" default, which is used when neither the flags nor the environment
+// choose whether to use color.
+func (h *Helper) getUI(cliConfig *turbostate.ParsedArgsFromRust, defaultColor string) cli.Ui {
 	colorMode := ui.GetColorModeFromEnv()
+	if _, noColor := os.LookupEnv("NO_COLOR"); colorMode == ui.ColorModeUndefined && !noColor {
+		switch defaultColor {
+		case "always":
+			colorMode = ui.ColorModeForced
+		case "never":
+			colorMode = ui.ColorModeSuppressed
+		}
+	}
 	if cliConfig.GetNoColor() {
 		colorMode = ui.ColorModeSuppressed
 	}
@@ -135,13 +149,21 @@ func NewHelper(turboVersion string, args *turbostate.ParsedArgsFromRust) *Helper
 // GetCmdBase returns a CmdBase instance configured with values from this helper.
 // It additionally returns a mechanism to set an error, so
 func (h *Helper) GetCmdBase(cliConfig *turbostate.ParsedArgsFromRust) (*CmdBase, error) {
+	userConfig, err := config.ReadUserConfigFile(h.UserConfigPath, cliConfig)
+	if err != nil {
+		return nil, err
+	}
 	// terminal is for color/no-color output
-	terminal := h.getUI(cliConfig)
+	terminal := h.getUI(cliConfig, userConfig.Default("color"))
 	// logger is configured with verbosity level using --verbosity flag from end users
 	logger, err := h.getLogger()
 	if err != nil {
 		return nil, err
 	}
+	// Tokens passed with --token or TURBO_TOKEN are used as they are
+	if token, _ := cliConfig.GetToken(); token == "" && os.Getenv("TURBO_TOKEN") == "" {
+		refreshIssuedToken(userConfig, terminal, logger)
+	}
 	cwdRaw, err := cliConfig.GetCwd()
 	if err != nil {
 		return nil, err
@@ -159,10 +181,6 @@ func (h *Helper) GetCmdBase(cliConfig *turbostate.ParsedArgsFromRust) (*CmdBase,
 	if err != nil {
 		return nil, err
 	}
-	userConfig, err := config.ReadUserConfigFile(h.UserConfigPath, cliConfig)
-	if err != nil {
-		return nil, err
-	}
 	remoteConfig := repoConfig.GetRemoteConfig(userConfig.Token())
 	if remoteConfig.Token == "" && ui.IsCI {
 		vercelArtifactsToken := os.Getenv("VERCEL_ARTIFACTS_TOKEN")
@@ -174,6 +192,9 @@ func (h *Helper) GetCmdBase(cliConfig *turbostate.ParsedArgsFromRust) (*CmdBase,
 			remoteConfig.TeamID = vercelArtifactsOwner
 		}
 	}
+	if remoteConfig.TeamID == "" && remoteConfig.TeamSlug == "" {
+		remoteConfig.TeamSlug = userConfig.Default("team")
+	}
 
 	// Primacy: Arg > Env
 	timeout, err := cliConfig.GetRemoteCacheTimeout()
@@ -189,6 +210,32 @@ func (h *Helper) GetCmdBase(cliConfig *turbostate.ParsedArgsFromRust) (*CmdBase,
 		}
 	}
 
+	rateLimits := []struct {
+		flag   string
+		value  string
+		envVar string
+		limit  *int64
+	}{
+		{"--remote-cache-upload-limit", cliConfig.RemoteCacheUploadLimit, "TURBO_REMOTE_CACHE_UPLOAD_LIMIT", &h.clientOpts.UploadLimit},
+		{"--remote-cache-download-limit", cliConfig.RemoteCacheDownloadLimit, "TURBO_REMOTE_CACHE_DOWNLOAD_LIMIT", &h.clientOpts.DownloadLimit},
+		{"--remote-cache-transfer-limit", cliConfig.RemoteCacheTransferLimit, "TURBO_REMOTE_CACHE_TRANSFER_LIMIT", &h.clientOpts.TransferLimit},
+	}
+	for _, rateLimit := range rateLimits {
+		// Primacy: Arg > Env
+		source, value := rateLimit.flag, rateLimit.value
+		if value == "" {
+			source, value = rateLimit.envVar, os.Getenv(rateLimit.envVar)
+		}
+		if value == "" {
+			continue
+		}
+		limit, err := client.ParseRate(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %v: %w", source, err)
+		}
+		*rateLimit.limit = limit
+	}
+
 	apiClient := client.NewClient(
 		remoteConfig,
 		logger,
@@ -204,6 +251,7 @@ func (h *Helper) GetCmdBase(cliConfig *turbostate.ParsedArgsFromRust) (*CmdBase,
 		RepoConfig:   repoConfig,
 		UserConfig:   userConfig,
 		RemoteConfig: remoteConfig,
+		ClientOpts:   h.clientOpts,
 		TurboVersion: h.TurboVersion,
 	}, nil
 }
@@ -217,6 +265,8 @@ type CmdBase struct {
 	RepoConfig   *config.RepoConfig
 	UserConfig   *config.UserConfig
 	RemoteConfig client.RemoteConfig
+	// ClientOpts configures the client of any remote cache, not only APIClient
+	ClientOpts   client.Opts
 	TurboVersion string
 }
 
@@ -243,3 +293,18 @@ func (b *CmdBase) LogInfo(msg string) {
 	b.Logger.Info(msg)
 	b.UI.Info(fmt.Sprintf("%s%s", ui.InfoPrefix, color.WhiteString(" %v", msg)))
 }
+
+// AnalyticsSink returns where usage events should be sent: the API client if
+// this repo is linked and the user has not disabled telemetry, and nowhere
+// otherwise. Setting TURBO_TELEMETRY_DEBUG=1 additionally prints every batch of
+// events to stderr, so users can see exactly what is sent.
+func (b *CmdBase) AnalyticsSink() analytics.Sink {
+	var sink analytics.Sink = analytics.NullSink
+	if b.APIClient.IsLinked() && (b.UserConfig == nil || !b.UserConfig.TelemetryDisabled()) {
+		sink = b.APIClient
+	}
+	if debug, _ := strconv.ParseBool(os.Getenv(_envTelemetryDebug)); debug {
+		sink = analytics.NewDebugSink(os.Stderr, sink)
+	}
+	return sink
+}
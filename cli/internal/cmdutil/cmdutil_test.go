@@ -107,3 +107,33 @@ func TestRemoteCacheTimeoutPrimacy(t *testing.T) {
 		assert.Equal(t, base.APIClient.HttpClient.HTTPClient.Timeout, time.Duration(1)*time.Second)
 	})
 }
+
+func TestRemoteCacheRateLimits(t *testing.T) {
+	t.Setenv("TURBO_REMOTE_CACHE_UPLOAD_LIMIT", "1MB")
+	t.Setenv("TURBO_REMOTE_CACHE_DOWNLOAD_LIMIT", "2KiB/s")
+	args := &turbostate.ParsedArgsFromRust{
+		CWD:                    "",
+		RemoteCacheUploadLimit: "500KB",
+	}
+	h := NewHelper("test-version", args)
+
+	_, err := h.GetCmdBase(args)
+	if err != nil {
+		t.Fatalf("failed to get command base %v", err)
+	}
+	// The flag takes precedence over the environment variable
+	assert.Equal(t, h.clientOpts.UploadLimit, int64(500_000))
+	assert.Equal(t, h.clientOpts.DownloadLimit, int64(2048))
+	assert.Equal(t, h.clientOpts.TransferLimit, int64(0))
+}
+
+func TestRemoteCacheRateLimitInvalid(t *testing.T) {
+	t.Setenv("TURBO_REMOTE_CACHE_TRANSFER_LIMIT", "fast")
+	args := &turbostate.ParsedArgsFromRust{
+		CWD: "",
+	}
+	h := NewHelper("test-version", args)
+
+	_, err := h.GetCmdBase(args)
+	assert.ErrorContains(t, err, "invalid TURBO_REMOTE_CACHE_TRANSFER_LIMIT")
+}
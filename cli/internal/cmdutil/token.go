@@ -0,0 +1,47 @@
+package cmdutil
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/mitchellh/cli"
+	"github.com/vercel/turbo/cli/internal/auth"
+	"github.com/vercel/turbo/cli/internal/config"
+	"github.com/vercel/turbo/cli/internal/ui"
+)
+
+const (
+	// _refreshMargin is how long before it expires a token is refreshed, so
+	// that it doesn't expire during a run
+	_refreshMargin  = 5 * time.Minute
+	_refreshTimeout = 10 * time.Second
+)
+
+// refreshIssuedToken refreshes the saved token if it was issued by an OpenID
+// Connect provider and is about to expire. A token that can't be refreshed is
+// kept, and the requests that use it fail as they would have.
+func refreshIssuedToken(userConfig *config.UserConfig, terminal cli.Ui, logger hclog.Logger) {
+	issued := userConfig.IssuedToken()
+	if issued == nil || issued.RefreshToken == "" || issued.Expiry.IsZero() || time.Until(issued.Expiry) > _refreshMargin {
+		return
+	}
+	provider := &auth.Provider{Issuer: issued.Issuer, ClientID: issued.ClientID}
+	ctx, cancel := context.WithTimeout(context.Background(), _refreshTimeout)
+	defer cancel()
+	token, err := provider.Refresh(ctx, issued.RefreshToken)
+	if err != nil {
+		terminal.Warn(fmt.Sprintf("%v Failed to refresh the remote cache token, run `turbo login` to sign in again: %v", ui.WARNING_PREFIX, err))
+		return
+	}
+	logger.Debug("refreshed the remote cache token", "issuer", issued.Issuer, "expiry", token.Expiry)
+	if err := userConfig.SetIssuedToken(token.AccessToken, &config.IssuedToken{
+		Issuer:       issued.Issuer,
+		ClientID:     issued.ClientID,
+		RefreshToken: token.RefreshToken,
+		Expiry:       token.Expiry,
+	}); err != nil {
+		terminal.Warn(fmt.Sprintf("%v Failed to save the refreshed remote cache token: %v", ui.WARNING_PREFIX, err))
+	}
+}
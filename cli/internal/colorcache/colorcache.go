@@ -44,13 +44,10 @@ func (c *ColorCache) colorForKey(key string) colorFn {
 	return colorFn
 }
 
-// PrefixWithColor returns a string consisting of the provided prefix in a consistent
-// color based on the cacheKey
-func (c *ColorCache) PrefixWithColor(cacheKey string, prefix string) string {
-	colorFn := c.colorForKey(cacheKey)
-	if prefix != "" {
-		return colorFn("%s: ", prefix)
+// WithColor returns text in a consistent color based on the cacheKey
+func (c *ColorCache) WithColor(cacheKey string, text string) string {
+	if text == "" {
+		return ""
 	}
-
-	return ""
+	return c.colorForKey(cacheKey)("%s", text)
 }
@@ -0,0 +1,141 @@
+// Package compare implements `turbo compare`, which explains how the tasks of
+// two runs saved by `turbo run --summarize` differ
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/runsummary"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// ExecuteCompare executes the `compare` command.
+func ExecuteCompare(helper *cmdutil.Helper, args *turbostate.ParsedArgsFromRust) error {
+	base, err := helper.GetCmdBase(args)
+	if err != nil {
+		return err
+	}
+	if err := compare(base, args.Command.Compare); err != nil {
+		base.LogError("compare failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+func compare(base *cmdutil.CmdBase, opts *turbostate.ComparePayload) error {
+	comparison, err := runsummary.CompareRuns(base.RepoRoot, opts.Before, opts.After)
+	if err != nil {
+		return err
+	}
+
+	if opts.JSON {
+		rendered, err := json.MarshalIndent(comparison, "", "  ")
+		if err != nil {
+			return err
+		}
+		base.UI.Output(string(rendered))
+		return nil
+	}
+
+	if len(comparison.GlobalChanges) > 0 {
+		base.UI.Output(util.Sprintf("${BOLD}The global hash changed${RESET}, which changes the hash of every task"))
+		for _, change := range comparison.GlobalChanges {
+			base.UI.Output(fmt.Sprintf("  %v", change))
+		}
+		base.UI.Output("")
+	}
+
+	changed := 0
+	for _, task := range comparison.Tasks {
+		if task.HashChanged() {
+			changed++
+		}
+	}
+	base.UI.Output(util.Sprintf("${BOLD}%v of %v tasks changed${RESET} between %v and %v", changed, len(comparison.Tasks), comparison.Before, comparison.After))
+	for _, task := range comparison.Tasks {
+		switch {
+		case task.HashBefore == "":
+			base.UI.Output(util.Sprintf("  %v ${GREY}only in %v${RESET}", task.TaskID, comparison.After))
+		case task.HashAfter == "":
+			base.UI.Output(util.Sprintf("  %v ${GREY}only in %v${RESET}", task.TaskID, comparison.Before))
+		case task.HashChanged():
+			base.UI.Output(util.Sprintf("  %v ${GREY}%v -> %v${RESET}", task.TaskID, task.HashBefore, task.HashAfter))
+			for _, change := range task.Changes {
+				base.UI.Output(fmt.Sprintf("    %v", change))
+			}
+		}
+	}
+
+	// Durations are listed by how much they moved, largest first
+	var durations []*runsummary.TaskComparison
+	for _, task := range comparison.Tasks {
+		if task.DurationBefore != nil && task.DurationAfter != nil {
+			durations = append(durations, task)
+		}
+	}
+	if len(durations) == 0 {
+		return nil
+	}
+	sort.SliceStable(durations, func(i, j int) bool {
+		return abs(durationChange(durations[i])) > abs(durationChange(durations[j]))
+	})
+	base.UI.Output("")
+	base.UI.Output(util.Sprintf("${BOLD}Durations${RESET}"))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, util.Sprintf("  ${GREY}Task\tBefore\tAfter\tChange\tStatus${RESET}"))
+	for _, task := range durations {
+		fmt.Fprintf(
+			w,
+			"  %s\t%v\t%v\t%s\t%s\n",
+			task.TaskID,
+			milliseconds(*task.DurationBefore),
+			milliseconds(*task.DurationAfter),
+			formatChange(task),
+			formatStatus(task),
+		)
+	}
+	return w.Flush()
+}
+
+func durationChange(task *runsummary.TaskComparison) int64 {
+	return *task.DurationAfter - *task.DurationBefore
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func milliseconds(ms int64) time.Duration {
+	return time.Duration(ms) * time.Millisecond
+}
+
+func formatChange(task *runsummary.TaskComparison) string {
+	change := durationChange(task)
+	sign := "+"
+	if change < 0 {
+		sign = "-"
+	}
+	if *task.DurationBefore == 0 {
+		return fmt.Sprintf("%s%v", sign, milliseconds(abs(change)))
+	}
+	return fmt.Sprintf("%s%v (%s%.0f%%)", sign, milliseconds(abs(change)), sign, float64(abs(change))/float64(*task.DurationBefore)*100)
+}
+
+// formatStatus shows the statuses, which explain most large changes, such as
+// a task that was restored from the cache in one of the runs
+func formatStatus(task *runsummary.TaskComparison) string {
+	if task.StatusBefore == task.StatusAfter {
+		return task.StatusAfter
+	}
+	return fmt.Sprintf("%v -> %v", task.StatusBefore, task.StatusAfter)
+}
@@ -0,0 +1,119 @@
+// Package completion implements the hidden `turbo __complete` command, which
+// prints the candidates used by the dynamic parts of the shell completion
+// scripts generated by `turbo completion`.
+package completion
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/packagemanager"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// ExecuteComplete executes the `__complete` command.
+// Completion scripts run this on every keystroke, so failures are only logged
+// at debug level and result in no candidates rather than an error message.
+func ExecuteComplete(helper *cmdutil.Helper, args *turbostate.ParsedArgsFromRust) error {
+	base, err := helper.GetCmdBase(args)
+	if err != nil {
+		return err
+	}
+	var candidates []string
+	switch kind := args.Command.Complete.Kind; kind {
+	case "tasks":
+		candidates, err = taskNames(base.RepoRoot)
+	case "packages":
+		candidates, err = packageNames(base.RepoRoot)
+	default:
+		err = fmt.Errorf("unknown completion kind %v", kind)
+	}
+	if err != nil {
+		base.Logger.Debug("failed to compute completions", "error", err)
+		return nil
+	}
+	if len(candidates) > 0 {
+		base.UI.Output(strings.Join(candidates, "\n"))
+	}
+	return nil
+}
+
+// taskNames returns the names of the tasks defined in turbo.json and of the
+// scripts in workspace package.json files. Package-specific tasks such as
+// `web#build` contribute their task name.
+func taskNames(repoRoot turbopath.AbsoluteSystemPath) ([]string, error) {
+	rootPackageJSON, err := fs.ReadPackageJSON(repoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		return nil, err
+	}
+	turboJSON, err := fs.LoadTurboConfig(repoRoot, rootPackageJSON, false)
+	if err != nil {
+		return nil, err
+	}
+	names := make(util.Set)
+	for taskID := range turboJSON.Pipeline {
+		names.Add(util.StripPackageName(taskID))
+	}
+	workspaces, err := readWorkspaces(repoRoot, rootPackageJSON)
+	if err != nil {
+		return nil, err
+	}
+	for _, pkg := range workspaces {
+		for script := range pkg.Scripts {
+			names.Add(script)
+		}
+	}
+	return sortedStrings(names), nil
+}
+
+// packageNames returns the names of the workspaces in the repository
+func packageNames(repoRoot turbopath.AbsoluteSystemPath) ([]string, error) {
+	rootPackageJSON, err := fs.ReadPackageJSON(repoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		return nil, err
+	}
+	workspaces, err := readWorkspaces(repoRoot, rootPackageJSON)
+	if err != nil {
+		return nil, err
+	}
+	names := make(util.Set)
+	for _, pkg := range workspaces {
+		if pkg.Name != "" {
+			names.Add(pkg.Name)
+		}
+	}
+	return sortedStrings(names), nil
+}
+
+// readWorkspaces reads the workspace package.json files. It doesn't build the
+// full package graph so that it stays fast enough to run on every completion.
+func readWorkspaces(repoRoot turbopath.AbsoluteSystemPath, rootPackageJSON *fs.PackageJSON) ([]*fs.PackageJSON, error) {
+	packageManager, err := packagemanager.GetPackageManager(repoRoot, rootPackageJSON)
+	if err != nil {
+		return nil, err
+	}
+	workspaces, err := packageManager.GetWorkspaces(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	pkgs := make([]*fs.PackageJSON, 0, len(workspaces))
+	for _, workspace := range workspaces {
+		pkg, err := fs.ReadPackageJSON(fs.UnsafeToAbsoluteSystemPath(workspace))
+		if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs, nil
+}
+
+func sortedStrings(set util.Set) []string {
+	values := set.UnsafeListOfStrings()
+	sort.Strings(values)
+	return values
+}
@@ -0,0 +1,44 @@
+package completion
+
+import (
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func writeFiles(t *testing.T, files map[string]string) turbopath.AbsoluteSystemPath {
+	t.Helper()
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	for path, contents := range files {
+		file := repoRoot.UntypedJoin(path)
+		assert.NilError(t, file.EnsureDir())
+		assert.NilError(t, file.WriteFile([]byte(contents), 0644))
+	}
+	return repoRoot
+}
+
+func TestCandidates(t *testing.T) {
+	repoRoot := writeFiles(t, map[string]string{
+		"package.json":             `{"name": "root", "packageManager": "npm@8.19.2", "workspaces": ["apps/*", "packages/*"]}`,
+		"apps/web/package.json":    `{"name": "web", "scripts": {"build": "next build", "dev": "next dev"}}`,
+		"packages/ui/package.json": `{"name": "@acme/ui"}`,
+		"turbo.json": `{
+			"pipeline": {
+				"build": {"dependsOn": ["^build"]},
+				"web#build": {"dependsOn": ["^build"]},
+				"//#format": {},
+				"lint": {}
+			}
+		}`,
+	})
+
+	packages, err := packageNames(repoRoot)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, packages, []string{"@acme/ui", "web"})
+
+	tasks, err := taskNames(repoRoot)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, tasks, []string{"build", "dev", "format", "lint"})
+}
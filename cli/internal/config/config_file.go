@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"time"
 
 	"github.com/spf13/viper"
 	"github.com/vercel/turbo/cli/internal/client"
@@ -80,6 +81,71 @@ func (uc *UserConfig) SetToken(token string) error {
 	return uc.write()
 }
 
+// IssuedToken is how to refresh a token that `turbo login` got from an OpenID
+// Connect provider, configured with the "auth" of "remoteCache" in turbo.json
+type IssuedToken struct {
+	Issuer   string
+	ClientID string
+	// RefreshToken is empty if the provider didn't issue one
+	RefreshToken string
+	// Expiry is zero if the provider didn't say when the token expires
+	Expiry time.Time
+}
+
+// IssuedToken returns how to refresh the saved token, or nil if it wasn't
+// issued by an OpenID Connect provider
+func (uc *UserConfig) IssuedToken() *IssuedToken {
+	issuer := uc.userViper.GetString("token_issuer")
+	if issuer == "" {
+		return nil
+	}
+	return &IssuedToken{
+		Issuer:       issuer,
+		ClientID:     uc.userViper.GetString("token_client_id"),
+		RefreshToken: uc.userViper.GetString("refresh_token"),
+		Expiry:       uc.userViper.GetTime("token_expiry"),
+	}
+}
+
+// SetIssuedToken saves a Bearer token issued by an OpenID Connect provider,
+// along with how to refresh it, writing it to the user config file, creating
+// it if necessary
+func (uc *UserConfig) SetIssuedToken(token string, issued *IssuedToken) error {
+	expiry := ""
+	if !issued.Expiry.IsZero() {
+		expiry = issued.Expiry.UTC().Format(time.RFC3339)
+	}
+	if err := uc.userViper.MergeConfigMap(map[string]interface{}{
+		"token":           token,
+		"token_issuer":    issued.Issuer,
+		"token_client_id": issued.ClientID,
+		"refresh_token":   issued.RefreshToken,
+		"token_expiry":    expiry,
+	}); err != nil {
+		return err
+	}
+	return uc.write()
+}
+
+// TelemetryDisabled returns true if the user has opted out of sending usage
+// events, either with `turbo telemetry disable`, TURBO_TELEMETRY_DISABLED or
+// the DO_NOT_TRACK convention
+func (uc *UserConfig) TelemetryDisabled() bool {
+	if doNotTrack := os.Getenv("DO_NOT_TRACK"); doNotTrack == "1" || doNotTrack == "true" {
+		return true
+	}
+	return uc.userViper.GetBool("telemetry_disabled")
+}
+
+// SetTelemetryDisabled saves whether usage events are sent, writing it to the
+// user config file, creating it if necessary
+func (uc *UserConfig) SetTelemetryDisabled(disabled bool) error {
+	if err := uc.userViper.MergeConfigMap(map[string]interface{}{"telemetry_disabled": disabled}); err != nil {
+		return err
+	}
+	return uc.write()
+}
+
 // Internal call to save this config data to the user config file.
 func (uc *UserConfig) write() error {
 	if err := uc.path.EnsureDir(); err != nil {
@@ -103,6 +169,7 @@ func ReadUserConfigFile(path turbopath.AbsoluteSystemPath, cliConfig *turbostate
 	userViper.SetConfigType("json")
 	userViper.SetEnvPrefix("turbo")
 	userViper.MustBindEnv("token")
+	userViper.MustBindEnv("telemetry_disabled")
 
 	token, err := cliConfig.GetToken()
 	if err != nil {
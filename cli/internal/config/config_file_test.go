@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/turbostate"
@@ -155,3 +156,72 @@ func TestUserConfigFlags(t *testing.T) {
 	assert.Equal(t, userConfig.Token(), "my-token")
 	assert.Equal(t, userConfig.path, configPath)
 }
+
+func TestUserConfigIssuedToken(t *testing.T) {
+	configPath := fs.AbsoluteSystemPathFromUpstream(t.TempDir()).UntypedJoin("turborepo", "config.json")
+	args := &turbostate.ParsedArgsFromRust{}
+
+	userConfig, err := ReadUserConfigFile(configPath, args)
+	assert.NilError(t, err, "readUserConfigFile")
+	assert.Assert(t, userConfig.IssuedToken() == nil)
+
+	issued := &IssuedToken{
+		Issuer:       "https://sso.example.com",
+		ClientID:     "turbo",
+		RefreshToken: "refresh",
+		Expiry:       time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+	assert.NilError(t, userConfig.SetIssuedToken("access", issued), "SetIssuedToken")
+	config, err := ReadUserConfigFile(configPath, args)
+	assert.NilError(t, err, "readUserConfigFile")
+	assert.Equal(t, config.Token(), "access")
+	assert.DeepEqual(t, config.IssuedToken(), issued)
+}
+
+func TestUserConfigTelemetry(t *testing.T) {
+	t.Setenv("DO_NOT_TRACK", "")
+	t.Setenv("TURBO_TELEMETRY_DISABLED", "")
+	configPath := fs.AbsoluteSystemPathFromUpstream(t.TempDir()).UntypedJoin("turborepo", "config.json")
+	args := &turbostate.ParsedArgsFromRust{}
+
+	userConfig, err := ReadUserConfigFile(configPath, args)
+	assert.NilError(t, err, "readUserConfigFile")
+	assert.Equal(t, userConfig.TelemetryDisabled(), false)
+
+	assert.NilError(t, userConfig.SetTelemetryDisabled(true), "SetTelemetryDisabled")
+	config, err := ReadUserConfigFile(configPath, args)
+	assert.NilError(t, err, "readUserConfigFile")
+	assert.Equal(t, config.TelemetryDisabled(), true)
+
+	assert.NilError(t, config.SetTelemetryDisabled(false), "SetTelemetryDisabled")
+	t.Setenv("DO_NOT_TRACK", "1")
+	assert.Equal(t, config.TelemetryDisabled(), true)
+}
+
+func TestUserConfigDefaults(t *testing.T) {
+	configPath := fs.AbsoluteSystemPathFromUpstream(t.TempDir()).UntypedJoin("turborepo", "config.json")
+	args := &turbostate.ParsedArgsFromRust{}
+
+	userConfig, err := ReadUserConfigFile(configPath, args)
+	assert.NilError(t, err, "readUserConfigFile")
+	assert.Equal(t, userConfig.Default("concurrency"), "")
+
+	assert.NilError(t, userConfig.SetToken("my-token"), "SetToken")
+	assert.NilError(t, userConfig.SetDefault("concurrency", "50%"), "SetDefault")
+	assert.NilError(t, userConfig.SetDefault("output_logs", "errors-only"), "SetDefault")
+	assert.ErrorContains(t, userConfig.SetDefault("color", "sometimes"), "invalid value \"sometimes\" for color")
+	assert.ErrorContains(t, userConfig.SetDefault("max_cache_size", "big"), "invalid value \"big\" for max_cache_size")
+	assert.ErrorContains(t, userConfig.SetDefault("colour", "always"), "unknown setting \"colour\"")
+
+	config, err := ReadUserConfigFile(configPath, args)
+	assert.NilError(t, err, "readUserConfigFile")
+	assert.Equal(t, config.Token(), "my-token")
+	assert.Equal(t, config.Default("concurrency"), "50%")
+	assert.Equal(t, config.Default("output_logs"), "errors-only")
+
+	assert.NilError(t, config.UnsetDefault("concurrency"), "UnsetDefault")
+	final, err := ReadUserConfigFile(configPath, args)
+	assert.NilError(t, err, "readUserConfigFile")
+	assert.Equal(t, final.Default("concurrency"), "")
+	assert.Equal(t, final.Default("output_logs"), "errors-only")
+}
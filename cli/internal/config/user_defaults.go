@@ -0,0 +1,139 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vercel/turbo/cli/internal/client"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// userDefault is a setting in the user config file that applies to every
+// repository, beneath the repository's configuration, environment variables
+// and CLI flags
+type userDefault struct {
+	key         string
+	description string
+	validate    func(value string) error
+}
+
+var _userDefaults = []userDefault{
+	{
+		key:         "cache_dir",
+		description: "the filesystem cache directory, like --cache-dir",
+	},
+	{
+		key:         "color",
+		description: "always, never or auto, like --color and --no-color",
+		validate: func(value string) error {
+			switch value {
+			case "always", "never", "auto":
+				return nil
+			}
+			return fmt.Errorf("must be one of \"always|never|auto\"")
+		},
+	},
+	{
+		key:         "concurrency",
+		description: "the number or percentage of tasks run at once, like --concurrency",
+		validate: func(value string) error {
+			_, err := util.ParseConcurrency(value)
+			return err
+		},
+	},
+	{
+		key:         "max_cache_size",
+		description: "the size the filesystem cache is kept under, like --max-cache-size",
+		validate: func(value string) error {
+			_, err := client.ParseSize(value)
+			return err
+		},
+	},
+	{
+		key:         "output_logs",
+		description: "how task output is shown, like --output-logs",
+		validate: func(value string) error {
+			if _, err := util.FromTaskOutputModeString(value); err != nil {
+				return fmt.Errorf("must be one of \"%v\"", strings.Join(util.TaskOutputModeStrings, "|"))
+			}
+			return nil
+		},
+	},
+	{
+		key:         "team",
+		description: "the team whose remote cache is used in repositories that are not linked, like --team",
+	},
+}
+
+func findUserDefault(key string) (userDefault, error) {
+	for _, d := range _userDefaults {
+		if d.key == key {
+			return d, nil
+		}
+	}
+	return userDefault{}, fmt.Errorf("unknown setting %q, expected one of %v", key, strings.Join(UserDefaultKeys(), ", "))
+}
+
+// UserDefaultKeys returns the settings that can be given user-level defaults
+func UserDefaultKeys() []string {
+	keys := make([]string, len(_userDefaults))
+	for i, d := range _userDefaults {
+		keys[i] = d.key
+	}
+	return keys
+}
+
+// CheckUserDefaultKey returns an error if the given setting cannot be given a
+// user-level default
+func CheckUserDefaultKey(key string) error {
+	_, err := findUserDefault(key)
+	return err
+}
+
+// DescribeUserDefault returns a short description of the given setting
+func DescribeUserDefault(key string) string {
+	d, err := findUserDefault(key)
+	if err != nil {
+		return ""
+	}
+	return d.description
+}
+
+// Default returns the user-level default for the given setting, or the empty
+// string if it is not set
+func (uc *UserConfig) Default(key string) string {
+	return uc.userViper.GetString("defaults." + key)
+}
+
+// SetDefault validates and saves a user-level default, writing it to the
+// user config file, creating it if necessary
+func (uc *UserConfig) SetDefault(key string, value string) error {
+	d, err := findUserDefault(key)
+	if err != nil {
+		return err
+	}
+	if value == "" {
+		return fmt.Errorf("%v cannot be empty, use `turbo config unset %v` instead", key, key)
+	}
+	if d.validate != nil {
+		if err := d.validate(value); err != nil {
+			return fmt.Errorf("invalid value %q for %v: %w", value, key, err)
+		}
+	}
+	if err := uc.userViper.MergeConfigMap(map[string]interface{}{"defaults": map[string]interface{}{key: value}}); err != nil {
+		return err
+	}
+	return uc.write()
+}
+
+// UnsetDefault removes a user-level default from the user config file
+func (uc *UserConfig) UnsetDefault(key string) error {
+	if _, err := findUserDefault(key); err != nil {
+		return err
+	}
+	// Note that we can't use viper.Set to set a nil value, we have to merge it in
+	if err := uc.userViper.MergeConfigMap(map[string]interface{}{"defaults": map[string]interface{}{key: nil}}); err != nil {
+		return err
+	}
+	return uc.write()
+}
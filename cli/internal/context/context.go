@@ -73,6 +73,11 @@ type Context struct {
 	// Used to arbitrate access to the graph. We parallelise most build operations
 	// and Go maps aren't natively threadsafe so this is needed.
 	mutex sync.Mutex
+
+	// lockfileDeferred is set for graphs built from a Resolution, which parse
+	// the lockfile in repoRoot once it is needed, see LoadLockfile
+	lockfileDeferred bool
+	repoRoot         turbopath.AbsoluteSystemPath
 }
 
 // Splits "npm:^1.2.3" and "github:foo/bar.git" into a protocol part and a version part.
@@ -179,7 +184,7 @@ func BuildPackageGraph(repoRoot turbopath.AbsoluteSystemPath, rootPackageJSON *f
 	}
 	c.PackageManager = packageManager
 
-	if lockfile, err := c.PackageManager.ReadLockfile(repoRoot); err != nil {
+	if lockfile, err := c.PackageManager.ReadLockfile(repoRoot, rootPackageJSON); err != nil {
 		warnings.append(err)
 	} else {
 		c.Lockfile = lockfile
@@ -223,6 +228,9 @@ func BuildPackageGraph(repoRoot turbopath.AbsoluteSystemPath, rootPackageJSON *f
 	if err := populateGraphWaitGroup.Wait(); err != nil {
 		return nil, err
 	}
+	if err := c.addDefinedWorkspaces(repoRoot); err != nil {
+		return nil, fmt.Errorf("workspace configuration error: %w", err)
+	}
 	// Resolve dependencies for the root package. We override the vertexName in the graph
 	// for the root package, since it can have an arbitrary name. We need it to have our
 	// RootPkgName so that we can identify it as the root later on.
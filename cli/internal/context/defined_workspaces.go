@@ -0,0 +1,123 @@
+package context
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/globby"
+	"github.com/vercel/turbo/cli/internal/lockfile"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+
+	"github.com/pyr-sh/dag"
+)
+
+// addDefinedWorkspaces adds the workspaces defined in the "workspaces" of
+// turbo.json to the graph. It is called once the workspaces of the package
+// manager are in the graph, since defined workspaces can depend on them.
+func (c *Context) addDefinedWorkspaces(repoRoot turbopath.AbsoluteSystemPath) error {
+	definitions, err := fs.LoadWorkspaceDefinitions(repoRoot)
+	if err != nil {
+		return err
+	}
+
+	added := []*fs.PackageJSON{}
+	dependencies := make(map[string][]string)
+	for _, definition := range definitions {
+		var namePattern *regexp.Regexp
+		if definition.NamePattern != "" {
+			namePattern, err = definition.CompileNamePattern()
+			if err != nil {
+				return err
+			}
+		}
+		manifests := make([]string, len(definition.Packages))
+		for i, glob := range definition.Packages {
+			manifests[i] = filepath.Join(glob, definition.Manifest)
+		}
+		files, err := globby.GlobFiles(repoRoot.ToStringDuringMigration(), manifests, []string{"**/node_modules/**"})
+		if err != nil {
+			return err
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			pkg, err := readDefinedWorkspace(repoRoot, fs.UnsafeToAbsoluteSystemPath(file), namePattern, definition.Scripts)
+			if err != nil {
+				return err
+			}
+			if existing, ok := c.WorkspaceInfos.PackageJSONs[pkg.Name]; ok {
+				return fmt.Errorf("Failed to add workspace \"%s\" from %s, it already exists at %s", pkg.Name, pkg.Dir, existing.Dir)
+			}
+			c.WorkspaceGraph.Add(pkg.Name)
+			c.WorkspaceInfos.PackageJSONs[pkg.Name] = pkg
+			c.WorkspaceNames = append(c.WorkspaceNames, pkg.Name)
+			added = append(added, pkg)
+			dependencies[pkg.Name] = definition.Dependencies[pkg.Name]
+		}
+	}
+
+	// Edges are added once every workspace is known, since a defined workspace
+	// can depend on one that is defined after it
+	for _, pkg := range added {
+		internalDeps := []string{}
+		for _, dep := range dependencies[pkg.Name] {
+			if _, ok := c.WorkspaceInfos.PackageJSONs[dep]; !ok || dep == util.RootPkgName || dep == pkg.Name {
+				return fmt.Errorf("workspace %v depends on %v in turbo.json, which is not another workspace", pkg.Name, dep)
+			}
+			c.WorkspaceGraph.Connect(dag.BasicEdge(pkg.Name, dep))
+			internalDeps = append(internalDeps, dep)
+		}
+		if len(internalDeps) == 0 {
+			c.WorkspaceGraph.Connect(dag.BasicEdge(pkg.Name, core.ROOT_NODE_NAME))
+		}
+		sort.Strings(internalDeps)
+		pkg.InternalDeps = internalDeps
+		// Dependencies outside of the repository are covered by the manifest,
+		// which is one of the files of the workspace
+		pkg.TransitiveDeps = []lockfile.Package{}
+		hashOfExternalDeps, err := fs.HashObject(pkg.TransitiveDeps)
+		if err != nil {
+			return err
+		}
+		pkg.ExternalDepsHash = hashOfExternalDeps
+	}
+	return nil
+}
+
+// readDefinedWorkspace returns the workspace of the directory of manifestPath.
+// Its name is the first group of namePattern in the manifest, or the name of
+// the directory if namePattern is nil.
+func readDefinedWorkspace(repoRoot turbopath.AbsoluteSystemPath, manifestPath turbopath.AbsoluteSystemPath, namePattern *regexp.Regexp, scripts map[string]string) (*fs.PackageJSON, error) {
+	relativeManifestPath, err := repoRoot.PathTo(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	name := manifestPath.Dir().Base()
+	if namePattern != nil {
+		manifest, err := manifestPath.ReadFile()
+		if err != nil {
+			return nil, err
+		}
+		match := namePattern.FindSubmatch(manifest)
+		if match == nil || len(match[1]) == 0 {
+			return nil, fmt.Errorf("%v doesn't match the \"namePattern\" %v of its workspace in turbo.json", relativeManifestPath, namePattern)
+		}
+		name = string(match[1])
+	}
+
+	pkgScripts := make(map[string]string, len(scripts))
+	for task, command := range scripts {
+		pkgScripts[task] = command
+	}
+	return &fs.PackageJSON{
+		Name:               name,
+		Scripts:            pkgScripts,
+		PackageJSONPath:    turbopath.AnchoredSystemPathFromUpstream(relativeManifestPath),
+		Dir:                turbopath.AnchoredSystemPathFromUpstream(filepath.Dir(relativeManifestPath)),
+		DefinedInTurboJSON: true,
+	}, nil
+}
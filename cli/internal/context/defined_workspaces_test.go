@@ -0,0 +1,111 @@
+package context
+
+import (
+	"errors"
+	"testing"
+
+	testifyAssert "github.com/stretchr/testify/assert"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+func writeDefinedWorkspaces(t *testing.T, repoRoot turbopath.AbsoluteSystemPath, turboJSON string) {
+	files := map[string]string{
+		"turbo.json":               turboJSON,
+		"services/api/go.mod":      "module example.com/api\n\ngo 1.20\n",
+		"services/auth/go.mod":     "module example.com/auth\n\ngo 1.20\n",
+		"crates/parser/Cargo.toml": "[package]\nname = \"parser\"\n",
+	}
+	for path, contents := range files {
+		file := repoRoot.UntypedJoin(path)
+		if err := file.EnsureDir(); err != nil {
+			t.Fatal(err)
+		}
+		if err := file.WriteFile([]byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBuildPackageGraph_DefinedWorkspaces(t *testing.T) {
+	repoRoot := writeResolutionRepo(t)
+	writeDefinedWorkspaces(t, repoRoot, `{
+		"pipeline": {},
+		"workspaces": [
+			{
+				"packages": ["services/*"],
+				"manifest": "go.mod",
+				"namePattern": "^module\\s+(\\S+)",
+				"scripts": {"build": "go build ./..."},
+				"dependencies": {"example.com/api": ["example.com/auth", "parser", "ui"]}
+			},
+			{"packages": ["crates/*"], "manifest": "Cargo.toml"}
+		]
+	}`)
+
+	for _, fromResolution := range []bool{false, true} {
+		c, err := BuildPackageGraph(repoRoot, readRootPackageJSON(t, repoRoot))
+		var warnings *Warnings
+		testifyAssert.True(t, err == nil || errors.As(err, &warnings), "unexpected error: %v", err)
+		if fromResolution {
+			resolution, err := NewResolution(repoRoot, c)
+			testifyAssert.NoError(t, err)
+			testifyAssert.NotContains(t, resolution.Inputs, turbopath.AnchoredUnixPath("services/api/go.mod"))
+			c, err = BuildPackageGraphFromResolution(repoRoot, readRootPackageJSON(t, repoRoot), resolution)
+			testifyAssert.NoError(t, err)
+		}
+
+		testifyAssert.Subset(t, c.WorkspaceNames, []string{"example.com/api", "example.com/auth", "parser", "ui", "web"})
+		api := c.WorkspaceInfos.PackageJSONs["example.com/api"]
+		testifyAssert.True(t, api.DefinedInTurboJSON)
+		testifyAssert.Equal(t, turbopath.AnchoredUnixPath("services/api").ToSystemPath(), api.Dir)
+		testifyAssert.Equal(t, turbopath.AnchoredUnixPath("services/api/go.mod").ToSystemPath(), api.PackageJSONPath)
+		testifyAssert.Equal(t, map[string]string{"build": "go build ./..."}, api.Scripts)
+		testifyAssert.Equal(t, []string{"example.com/auth", "parser", "ui"}, api.InternalDeps)
+		testifyAssert.NotEmpty(t, api.ExternalDepsHash)
+		testifyAssert.True(t, hasEdge(c, "parser", core.ROOT_NODE_NAME))
+		testifyAssert.True(t, hasEdge(c, "example.com/api", "ui"))
+		testifyAssert.Empty(t, c.WorkspaceInfos.PackageJSONs["parser"].Scripts)
+	}
+}
+
+func TestBuildPackageGraph_DefinedWorkspaceErrors(t *testing.T) {
+	testCases := []struct {
+		name      string
+		turboJSON string
+		want      string
+	}{
+		{
+			name:      "unknown dependency",
+			turboJSON: `{"workspaces": [{"packages": ["services/*"], "manifest": "go.mod", "dependencies": {"api": ["missing"]}}]}`,
+			want:      "workspace api depends on missing in turbo.json, which is not another workspace",
+		},
+		{
+			name:      "duplicate name",
+			turboJSON: `{"workspaces": [{"packages": ["services/*"], "manifest": "go.mod", "namePattern": "^(go) "}]}`,
+			want:      "Failed to add workspace \"go\"",
+		},
+		{
+			name:      "name not found",
+			turboJSON: `{"workspaces": [{"packages": ["crates/*"], "manifest": "Cargo.toml", "namePattern": "^module (\\S+)"}]}`,
+			want:      "doesn't match the \"namePattern\"",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repoRoot := writeResolutionRepo(t)
+			writeDefinedWorkspaces(t, repoRoot, tc.turboJSON)
+			_, err := BuildPackageGraph(repoRoot, readRootPackageJSON(t, repoRoot))
+			testifyAssert.ErrorContains(t, err, tc.want)
+		})
+	}
+}
+
+func hasEdge(c *Context, from string, to string) bool {
+	for _, edge := range c.WorkspaceGraph.Edges() {
+		if edge.Source() == from && edge.Target() == to {
+			return true
+		}
+	}
+	return false
+}
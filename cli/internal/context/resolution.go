@@ -0,0 +1,222 @@
+package context
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/lockfile"
+	"github.com/vercel/turbo/cli/internal/packagemanager"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+	"github.com/vercel/turbo/cli/internal/workspace"
+
+	"github.com/pyr-sh/dag"
+	"golang.org/x/sync/errgroup"
+)
+
+// ErrStaleResolution is returned when one of the files a Resolution was
+// resolved from changed since
+var ErrStaleResolution = errors.New("the package graph changed since it was resolved")
+
+// _workspaceConfigFiles are the files, other than package.json files, that
+// list the workspaces of a repository
+var _workspaceConfigFiles = []string{"pnpm-workspace.yaml"}
+
+// Resolution is what BuildPackageGraph works out from the workspace globs and
+// the lockfile, which is the slow part of building the graph of a large
+// repository. The daemon keeps one up to date, so that `turbo run` can build the
+// graph again without globbing for workspaces or parsing the lockfile.
+type Resolution struct {
+	// Fingerprint covers the size and modification time of each of Inputs
+	Fingerprint string
+	// Inputs are the package.json files, the lockfile and the workspace
+	// configuration the graph was resolved from
+	Inputs []turbopath.AnchoredUnixPath
+	// HasLockfile is whether the lockfile was read successfully
+	HasLockfile bool
+	// Workspaces include the root workspace
+	Workspaces []*WorkspaceResolution
+}
+
+// WorkspaceResolution is what was resolved for a single workspace
+type WorkspaceResolution struct {
+	Name string
+	// PackageJSONPath is relative to the repository root
+	PackageJSONPath  turbopath.AnchoredUnixPath
+	InternalDeps     []string
+	TransitiveDeps   []lockfile.Package
+	ExternalDepsHash string
+}
+
+// NewResolution records what was resolved when building c, which must have
+// been built by BuildPackageGraph
+func NewResolution(repoRoot turbopath.AbsoluteSystemPath, c *Context) (*Resolution, error) {
+	resolution := &Resolution{
+		HasLockfile: !lockfile.IsNil(c.Lockfile),
+	}
+	for name, pkg := range c.WorkspaceInfos.PackageJSONs {
+		if pkg.DefinedInTurboJSON {
+			continue
+		}
+		packageJSONPath := pkg.PackageJSONPath.ToUnixPath()
+		if name == util.RootPkgName {
+			packageJSONPath = "package.json"
+		}
+		resolution.Workspaces = append(resolution.Workspaces, &WorkspaceResolution{
+			Name:             name,
+			PackageJSONPath:  packageJSONPath,
+			InternalDeps:     pkg.InternalDeps,
+			TransitiveDeps:   pkg.TransitiveDeps,
+			ExternalDepsHash: pkg.ExternalDepsHash,
+		})
+		resolution.Inputs = append(resolution.Inputs, packageJSONPath)
+	}
+	sort.Slice(resolution.Workspaces, func(i, j int) bool {
+		return resolution.Workspaces[i].Name < resolution.Workspaces[j].Name
+	})
+	resolution.Inputs = append(resolution.Inputs, turbopath.AnchoredUnixPath(c.PackageManager.Lockfile))
+	for _, file := range _workspaceConfigFiles {
+		resolution.Inputs = append(resolution.Inputs, turbopath.AnchoredUnixPath(file))
+	}
+	sort.Slice(resolution.Inputs, func(i, j int) bool {
+		return resolution.Inputs[i] < resolution.Inputs[j]
+	})
+
+	fingerprint, err := resolutionFingerprint(repoRoot, resolution.Inputs)
+	if err != nil {
+		return nil, err
+	}
+	resolution.Fingerprint = fingerprint
+	return resolution, nil
+}
+
+// resolutionFingerprint hashes the size and modification time of each of the
+// inputs. Inputs that don't exist are part of the fingerprint as well.
+func resolutionFingerprint(repoRoot turbopath.AbsoluteSystemPath, inputs []turbopath.AnchoredUnixPath) (string, error) {
+	entries := make([]string, 0, len(inputs))
+	for _, input := range inputs {
+		info, err := input.ToSystemPath().RestoreAnchor(repoRoot).Stat()
+		if errors.Is(err, os.ErrNotExist) {
+			entries = append(entries, fmt.Sprintf("%v missing", input))
+			continue
+		} else if err != nil {
+			return "", err
+		}
+		entries = append(entries, fmt.Sprintf("%v %v %v", input, info.Size(), info.ModTime().UnixNano()))
+	}
+	return fs.HashObject(entries)
+}
+
+// BuildPackageGraphFromResolution builds the same Context as BuildPackageGraph,
+// reading the package.json files of the workspaces in the resolution rather than
+// globbing for them, and leaving the lockfile to be parsed once something needs
+// it, see LoadLockfile. It returns ErrStaleResolution if any of the files the
+// resolution was resolved from changed.
+func BuildPackageGraphFromResolution(repoRoot turbopath.AbsoluteSystemPath, rootPackageJSON *fs.PackageJSON, resolution *Resolution) (*Context, error) {
+	fingerprint, err := resolutionFingerprint(repoRoot, resolution.Inputs)
+	if err != nil {
+		return nil, err
+	}
+	if fingerprint != resolution.Fingerprint {
+		return nil, ErrStaleResolution
+	}
+
+	c := &Context{
+		RootNode:         core.ROOT_NODE_NAME,
+		lockfileDeferred: resolution.HasLockfile,
+		repoRoot:         repoRoot,
+	}
+	c.WorkspaceInfos = workspace.Catalog{
+		PackageJSONs: map[string]*fs.PackageJSON{},
+		TurboConfigs: map[string]*fs.TurboJSON{},
+	}
+	packageManager, err := packagemanager.GetPackageManager(repoRoot, rootPackageJSON)
+	if err != nil {
+		return nil, err
+	}
+	c.PackageManager = packageManager
+
+	parseJSONWaitGroup := &errgroup.Group{}
+	for _, workspace := range resolution.Workspaces {
+		if workspace.Name == util.RootPkgName {
+			continue
+		}
+		pkgJSONPath := workspace.PackageJSONPath.ToSystemPath().RestoreAnchor(repoRoot)
+		parseJSONWaitGroup.Go(func() error {
+			return c.parsePackageJSON(repoRoot, pkgJSONPath)
+		})
+	}
+	if err := parseJSONWaitGroup.Wait(); err != nil {
+		return nil, err
+	}
+	c.WorkspaceInfos.PackageJSONs[util.RootPkgName] = rootPackageJSON
+
+	for _, workspace := range resolution.Workspaces {
+		pkg, ok := c.WorkspaceInfos.PackageJSONs[workspace.Name]
+		if !ok {
+			return nil, ErrStaleResolution
+		}
+		vertexName := workspace.Name
+		if vertexName == util.RootPkgName {
+			// The root package was added to the graph under its own name when
+			// it has no internal dependencies, see populateWorkspaceGraphForPackageJSON
+			vertexName = pkg.Name
+		}
+		c.applyResolution(pkg, workspace, vertexName)
+	}
+	// Workspaces defined in turbo.json aren't part of the resolution, since
+	// their manifests aren't package.json files
+	if err := c.addDefinedWorkspaces(repoRoot); err != nil {
+		return nil, fmt.Errorf("workspace configuration error: %w", err)
+	}
+	return c, nil
+}
+
+// applyResolution sets what was resolved for a workspace, and connects it to
+// its internal dependencies in the graph, like populateWorkspaceGraphForPackageJSON
+func (c *Context) applyResolution(pkg *fs.PackageJSON, resolution *WorkspaceResolution, leafName string) {
+	internalDeps := util.SetFromStrings(resolution.InternalDeps)
+	pkg.UnresolvedExternalDeps = make(map[string]string)
+	for _, deps := range []map[string]string{pkg.DevDependencies, pkg.OptionalDependencies, pkg.Dependencies} {
+		for dep, version := range deps {
+			if !internalDeps.Includes(dep) {
+				pkg.UnresolvedExternalDeps[dep] = version
+			}
+		}
+	}
+	for _, dep := range resolution.InternalDeps {
+		c.WorkspaceGraph.Connect(dag.BasicEdge(resolution.Name, dep))
+	}
+	if len(resolution.InternalDeps) == 0 {
+		c.WorkspaceGraph.Connect(dag.BasicEdge(leafName, core.ROOT_NODE_NAME))
+	}
+	pkg.InternalDeps = resolution.InternalDeps
+	pkg.TransitiveDeps = resolution.TransitiveDeps
+	pkg.ExternalDepsHash = resolution.ExternalDepsHash
+}
+
+// HasLockfile is whether the repository's lockfile was read, or can be read
+// once it is needed
+func (c *Context) HasLockfile() bool {
+	return c.lockfileDeferred || !lockfile.IsNil(c.Lockfile)
+}
+
+// LoadLockfile returns the Lockfile, parsing it first if the graph was built
+// from a Resolution
+func (c *Context) LoadLockfile() (lockfile.Lockfile, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.lockfileDeferred {
+		lockfile, err := c.PackageManager.ReadLockfile(c.repoRoot, c.WorkspaceInfos.PackageJSONs[util.RootPkgName])
+		if err != nil {
+			return nil, err
+		}
+		c.Lockfile = lockfile
+		c.lockfileDeferred = false
+	}
+	return c.Lockfile, nil
+}
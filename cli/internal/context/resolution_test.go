@@ -0,0 +1,92 @@
+package context
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	testifyAssert "github.com/stretchr/testify/assert"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+func writeResolutionRepo(t *testing.T) turbopath.AbsoluteSystemPath {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	files := map[string]string{
+		"package.json":              `{"name": "monorepo", "workspaces": ["packages/*"], "packageManager": "npm@8.19.2"}`,
+		"packages/web/package.json": `{"name": "web", "version": "1.0.0", "dependencies": {"ui": "*", "react": "^18.0.0"}}`,
+		"packages/ui/package.json":  `{"name": "ui", "version": "1.0.0", "devDependencies": {"typescript": "^4.9.0"}}`,
+	}
+	for path, contents := range files {
+		file := repoRoot.UntypedJoin(path)
+		if err := file.EnsureDir(); err != nil {
+			t.Fatal(err)
+		}
+		if err := file.WriteFile([]byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repoRoot
+}
+
+func readRootPackageJSON(t *testing.T, repoRoot turbopath.AbsoluteSystemPath) *fs.PackageJSON {
+	rootPackageJSON, err := fs.ReadPackageJSON(repoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rootPackageJSON
+}
+
+func TestBuildPackageGraphFromResolution(t *testing.T) {
+	repoRoot := writeResolutionRepo(t)
+	// Without a lockfile, the graph is built with a warning
+	built, err := BuildPackageGraph(repoRoot, readRootPackageJSON(t, repoRoot))
+	var warnings *Warnings
+	testifyAssert.True(t, err == nil || errors.As(err, &warnings), "unexpected error: %v", err)
+	resolution, err := NewResolution(repoRoot, built)
+	testifyAssert.NoError(t, err)
+	testifyAssert.False(t, resolution.HasLockfile)
+	testifyAssert.Contains(t, resolution.Inputs, turbopath.AnchoredUnixPath("packages/web/package.json"))
+	testifyAssert.Contains(t, resolution.Inputs, turbopath.AnchoredUnixPath("package-lock.json"))
+
+	resolved, err := BuildPackageGraphFromResolution(repoRoot, readRootPackageJSON(t, repoRoot), resolution)
+	testifyAssert.NoError(t, err)
+	testifyAssert.False(t, resolved.HasLockfile())
+	testifyAssert.ElementsMatch(t, resolved.WorkspaceNames, built.WorkspaceNames)
+	testifyAssert.ElementsMatch(t, resolved.WorkspaceGraph.Edges(), built.WorkspaceGraph.Edges())
+	for name, pkg := range built.WorkspaceInfos.PackageJSONs {
+		resolvedPkg, ok := resolved.WorkspaceInfos.PackageJSONs[name]
+		if !testifyAssert.True(t, ok, "missing workspace %v", name) {
+			continue
+		}
+		testifyAssert.Equal(t, pkg.Dir, resolvedPkg.Dir)
+		testifyAssert.Equal(t, pkg.InternalDeps, resolvedPkg.InternalDeps)
+		testifyAssert.Equal(t, pkg.UnresolvedExternalDeps, resolvedPkg.UnresolvedExternalDeps)
+		testifyAssert.Equal(t, pkg.ExternalDepsHash, resolvedPkg.ExternalDepsHash)
+	}
+	testifyAssert.Equal(t, []string{"ui"}, resolved.WorkspaceInfos.PackageJSONs["web"].InternalDeps)
+	testifyAssert.Equal(t, map[string]string{"react": "^18.0.0"}, resolved.WorkspaceInfos.PackageJSONs["web"].UnresolvedExternalDeps)
+	testifyAssert.NotNil(t, resolved.WorkspaceInfos.PackageJSONs[util.RootPkgName])
+}
+
+func TestBuildPackageGraphFromStaleResolution(t *testing.T) {
+	repoRoot := writeResolutionRepo(t)
+	built, _ := BuildPackageGraph(repoRoot, readRootPackageJSON(t, repoRoot))
+	resolution, err := NewResolution(repoRoot, built)
+	testifyAssert.NoError(t, err)
+
+	lockfile := repoRoot.UntypedJoin("package-lock.json")
+	testifyAssert.NoError(t, lockfile.WriteFile([]byte(`{"lockfileVersion": 2}`), 0644))
+	_, err = BuildPackageGraphFromResolution(repoRoot, readRootPackageJSON(t, repoRoot), resolution)
+	testifyAssert.ErrorIs(t, err, ErrStaleResolution, "a new lockfile makes the resolution stale")
+
+	resolution, err = NewResolution(repoRoot, built)
+	testifyAssert.NoError(t, err)
+	web := repoRoot.UntypedJoin("packages", "web", "package.json")
+	later := time.Now().Add(time.Minute)
+	testifyAssert.NoError(t, os.Chtimes(web.ToString(), later, later))
+	_, err = BuildPackageGraphFromResolution(repoRoot, readRootPackageJSON(t, repoRoot), resolution)
+	testifyAssert.ErrorIs(t, err, ErrStaleResolution, "a modified package.json makes the resolution stale")
+}
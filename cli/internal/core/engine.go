@@ -1,17 +1,21 @@
 package core
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/graph"
 	"github.com/vercel/turbo/cli/internal/util"
 
 	"github.com/pyr-sh/dag"
+	"golang.org/x/sync/semaphore"
 )
 
 const ROOT_NODE_NAME = "___ROOT___"
@@ -69,11 +73,26 @@ type EngineExecutionOptions struct {
 	Parallel bool
 	// Concurrency is the number of concurrent tasks that can be executed
 	Concurrency int
+	// Deterministic runs tasks one at a time, in dependency order with ties
+	// broken by task ID, so that every run visits tasks in the same order
+	Deterministic bool
+	// TaskDurations are how long tasks took the last time they were executed.
+	// They are used to start the tasks on the longest chains first.
+	TaskDurations map[string]time.Duration
+	// Speculation starts some tasks before their dependencies have finished,
+	// when concurrency slots would otherwise be idle
+	Speculation *Speculation
 }
 
 // Execute executes the pipeline, constructing an internal task graph and walking it accordingly.
 func (e *Engine) Execute(visitor Visitor, opts EngineExecutionOptions) []error {
-	var sema = util.NewSemaphore(opts.Concurrency)
+	if opts.Deterministic {
+		return e.executeDeterministic(visitor)
+	}
+	if !opts.Parallel && (len(opts.TaskDurations) > 0 || e.hasPriorities() || opts.Speculation != nil) {
+		return e.executePrioritized(visitor, opts)
+	}
+	var sema = semaphore.NewWeighted(int64(opts.Concurrency))
 	return e.TaskGraph.Walk(func(v dag.Vertex) error {
 		// Each vertex in the graph is a taskID (package#task format)
 		taskID := dag.VertexName(v)
@@ -83,16 +102,184 @@ func (e *Engine) Execute(visitor Visitor, opts EngineExecutionOptions) []error {
 			return nil
 		}
 
-		// Acquire the semaphore unless parallel
-		if !opts.Parallel {
-			sema.Acquire()
-			defer sema.Release()
+		// Acquire the task's weight from the semaphore unless parallel. Persistent
+		// tasks never release it, so they would starve every other task, and
+		// only start once their dependencies have finished anyway.
+		if !opts.Parallel && !e.isPersistent(taskID) {
+			weight := e.taskWeight(taskID, opts.Concurrency)
+			if err := sema.Acquire(context.Background(), weight); err != nil {
+				return err
+			}
+			defer sema.Release(weight)
 		}
 
 		return visitor(taskID)
 	})
 }
 
+// isPersistent is whether a task never exits, like a dev server
+func (e *Engine) isPersistent(taskID string) bool {
+	if e.completeGraph == nil {
+		return false
+	}
+	taskDefinition, ok := e.completeGraph.TaskDefinitions[taskID]
+	return ok && taskDefinition.Persistent
+}
+
+// taskWeight returns how many concurrency slots a task takes. Weights above the
+// concurrency are capped, so that heavy tasks still run, but only on their own.
+// Interactive tasks take every slot, so that nothing else writes to the
+// terminal while they prompt for input.
+func (e *Engine) taskWeight(taskID string, concurrency int) int64 {
+	weight := 1
+	if e.completeGraph != nil {
+		if taskDefinition, ok := e.completeGraph.TaskDefinitions[taskID]; ok {
+			if taskDefinition.Interactive {
+				weight = concurrency
+			} else if taskDefinition.Weight > 1 {
+				weight = taskDefinition.Weight
+			}
+		}
+	}
+	if weight > concurrency {
+		weight = concurrency
+	}
+	return int64(weight)
+}
+
+// executeDeterministic visits each task once all of its dependencies have been
+// visited, choosing the lowest task ID among the tasks that are ready. Like Walk,
+// tasks that depend on a failed task are skipped. Persistent tasks are started
+// in the background, since they never finish, and nothing can depend on them.
+func (e *Engine) executeDeterministic(visitor Visitor) []error {
+	var errsMu sync.Mutex
+	var persistentTasks sync.WaitGroup
+	var pending []string
+	for _, v := range e.TaskGraph.Vertices() {
+		pending = append(pending, dag.VertexName(v))
+	}
+	sort.Strings(pending)
+
+	var errs []error
+	visited := make(map[string]bool)
+	failed := make(map[string]bool)
+	for len(pending) > 0 {
+		next := -1
+		upstreamFailed := false
+		for i, taskID := range pending {
+			ready := true
+			upstreamFailed = false
+			for _, dep := range e.TaskGraph.DownEdges(taskID).List() {
+				depName := dag.VertexName(dep)
+				if !visited[depName] {
+					ready = false
+					break
+				}
+				if failed[depName] {
+					upstreamFailed = true
+				}
+			}
+			if ready {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			// Not reachable for an acyclic graph, but guards against looping forever
+			errsMu.Lock()
+			defer errsMu.Unlock()
+			return append(errs, fmt.Errorf("could not order the remaining tasks: %v", strings.Join(pending, ", ")))
+		}
+
+		taskID := pending[next]
+		pending = append(pending[:next], pending[next+1:]...)
+		visited[taskID] = true
+		if upstreamFailed {
+			failed[taskID] = true
+			continue
+		}
+		// Always skip the root node
+		if strings.Contains(taskID, ROOT_NODE_NAME) {
+			continue
+		}
+		if e.isPersistent(taskID) {
+			persistentTasks.Add(1)
+			go func() {
+				defer persistentTasks.Done()
+				if err := visitor(taskID); err != nil {
+					errsMu.Lock()
+					errs = append(errs, err)
+					errsMu.Unlock()
+				}
+			}()
+			continue
+		}
+		if err := visitor(taskID); err != nil {
+			failed[taskID] = true
+			errsMu.Lock()
+			errs = append(errs, err)
+			errsMu.Unlock()
+		}
+	}
+	persistentTasks.Wait()
+	return errs
+}
+
+// RemoveEntryTasks removes the given tasks from the graph, other than the ones
+// that tasks left in the graph depend on, and returns the ones it kept, sorted
+func (e *Engine) RemoveEntryTasks(taskIDs []string) []string {
+	remaining := make(util.Set)
+	for _, taskID := range taskIDs {
+		remaining.Add(taskID)
+	}
+	// Removing a task can leave the tasks it depends on without dependents
+	for removed := true; removed; {
+		removed = false
+		for _, taskID := range remaining.UnsafeListOfStrings() {
+			if e.TaskGraph.UpEdges(taskID).Len() == 0 {
+				e.TaskGraph.Remove(taskID)
+				remaining.Delete(taskID)
+				removed = true
+			}
+		}
+	}
+	kept := remaining.UnsafeListOfStrings()
+	sort.Strings(kept)
+	return kept
+}
+
+// FailedDependents maps each task that depends on one of the failed tasks,
+// directly or transitively, to the failed tasks it depends on. These are the
+// tasks that a walk of the graph skips.
+func (e *Engine) FailedDependents(failed []string) map[string][]string {
+	isFailed := make(map[string]bool, len(failed))
+	for _, taskID := range failed {
+		isFailed[taskID] = true
+	}
+	sorted := append([]string{}, failed...)
+	sort.Strings(sorted)
+
+	dependents := make(map[string][]string)
+	for _, failedTaskID := range sorted {
+		seen := map[string]bool{failedTaskID: true}
+		queue := []string{failedTaskID}
+		for len(queue) > 0 {
+			taskID := queue[0]
+			queue = queue[1:]
+			for _, dependent := range e.TaskGraph.UpEdges(taskID).List() {
+				dependentID := dag.VertexName(dependent)
+				if seen[dependentID] || isFailed[dependentID] {
+					continue
+				}
+				seen[dependentID] = true
+				dependents[dependentID] = append(dependents[dependentID], failedTaskID)
+				queue = append(queue, dependentID)
+			}
+		}
+	}
+	return dependents
+}
+
 // MissingTaskError is a specialized Error thrown in the case that we can't find a task.
 // We want to allow this error when getting task definitions, so we have to special case it.
 type MissingTaskError struct {
@@ -106,7 +293,7 @@ func (m *MissingTaskError) Error() string {
 }
 
 func (e *Engine) getTaskDefinition(pkg string, taskName string, taskID string) (*Task, error) {
-	pipeline, err := e.completeGraph.GetPipelineFromWorkspace(pkg, e.isSinglePackage)
+	turboJSON, err := e.completeGraph.GetTurboConfigFromWorkspace(pkg, e.isSinglePackage)
 
 	if err != nil {
 		if pkg != util.RootPkgName {
@@ -122,14 +309,21 @@ func (e *Engine) getTaskDefinition(pkg string, taskName string, taskID string) (
 		return nil, err
 	}
 
-	if task, ok := pipeline[taskID]; ok {
+	if task, ok := turboJSON.Pipeline[taskID]; ok {
+		return &Task{
+			Name:           taskName,
+			TaskDefinition: task.TaskDefinition,
+		}, nil
+	}
+
+	if task, ok := turboJSON.Pipeline[taskName]; ok {
 		return &Task{
 			Name:           taskName,
 			TaskDefinition: task.TaskDefinition,
 		}, nil
 	}
 
-	if task, ok := pipeline[taskName]; ok {
+	if task, ok := turboJSON.PackageJSONPipeline[taskName]; ok {
 		return &Task{
 			Name:           taskName,
 			TaskDefinition: task.TaskDefinition,
@@ -137,9 +331,9 @@ func (e *Engine) getTaskDefinition(pkg string, taskName string, taskID string) (
 	}
 
 	// An error here means turbo.json exists, but didn't define the task.
-	// Fallback to the root pipeline to find the task.
+	// Fallback to the pipeline it extends to find the task.
 	if pkg != util.RootPkgName {
-		return e.getTaskDefinition(util.RootPkgName, taskName, taskID)
+		return e.getTaskDefinition(e.extendedWorkspace(pkg), taskName, taskID)
 	}
 
 	// Return this as a custom type so we can ignore it specifically
@@ -150,6 +344,26 @@ func (e *Engine) getTaskDefinition(pkg string, taskName string, taskID string) (
 	}
 }
 
+// extendedWorkspace returns the workspace that the turbo.json in pkg extends.
+// It returns the root workspace if the chain of "extends" from pkg doesn't lead
+// to the root, which getTaskDefinitionChain reports.
+func (e *Engine) extendedWorkspace(pkg string) string {
+	visited := make(util.Set)
+	for workspace := pkg; workspace != util.RootPkgName; {
+		turboJSON, err := e.completeGraph.GetTurboConfigFromWorkspace(workspace, e.isSinglePackage)
+		if visited.Includes(workspace) || err != nil || len(turboJSON.Extends) != 1 {
+			return util.RootPkgName
+		}
+		visited.Add(workspace)
+		workspace = turboJSON.Extends[0]
+		if _, ok := e.completeGraph.WorkspaceInfos.PackageJSONs[workspace]; !ok {
+			return util.RootPkgName
+		}
+	}
+	turboJSON, _ := e.completeGraph.GetTurboConfigFromWorkspace(pkg, e.isSinglePackage)
+	return turboJSON.Extends[0]
+}
+
 // Prepare constructs the Task Graph for a list of packages and tasks
 func (e *Engine) Prepare(options *EngineBuildingOptions) error {
 	pkgs := options.Packages
@@ -210,6 +424,9 @@ func (e *Engine) Prepare(options *EngineBuildingOptions) error {
 	}
 
 	visited := make(util.Set)
+	// With --only, the tasks that were asked for in the packages that are in scope
+	inScope := util.SetFromStrings(pkgs)
+	requested := util.SetFromStrings(taskNames)
 
 	// validate that all tasks passed were found
 	missingList := missing.UnsafeListOfStrings()
@@ -280,78 +497,48 @@ func (e *Engine) Prepare(options *EngineBuildingOptions) error {
 			}
 		}
 
-		// Filter down the tasks if there's a filter in place
-		// https: //turbo.build/repo/docs/reference/command-line-reference#--only
-		if tasksOnly {
-			deps = deps.Filter(func(d interface{}) bool {
-				for _, target := range taskNames {
-					return fmt.Sprintf("%v", d) == target
-				}
-				return false
-			})
-			topoDeps = topoDeps.Filter(func(d interface{}) bool {
-				for _, target := range taskNames {
-					return fmt.Sprintf("%v", d) == target
-				}
-				return false
-			})
-		}
-
 		toTaskID := taskID
 
-		// hasTopoDeps will be true if the task depends on any tasks from dependency packages
-		// E.g. `dev: { dependsOn: [^dev] }`
-		hasTopoDeps := topoDeps.Len() > 0 && e.completeGraph.WorkspaceGraph.DownEdges(pkg).Len() > 0
-
-		// hasDeps will be true if the task depends on any tasks from its own package
-		// E.g. `build: { dependsOn: [dev] }`
-		hasDeps := deps.Len() > 0
-
-		// hasPackageTaskDeps will be true if this is a workspace-specific task, and
-		// it depends on another workspace-specific tasks
-		// E.g. `my-package#build: { dependsOn: [my-package#beforebuild] }`.
-		hasPackageTaskDeps := false
-		if _, ok := e.PackageTaskDeps[toTaskID]; ok {
-			hasPackageTaskDeps = true
-		}
+		// fromTaskIDs are the tasks that this task depends on
+		fromTaskIDs := []string{}
 
-		if hasTopoDeps {
+		// Topological dependencies are tasks from dependency packages
+		// E.g. `dev: { dependsOn: [^dev] }`
+		if topoDeps.Len() > 0 {
 			depPkgs := e.completeGraph.WorkspaceGraph.DownEdges(pkg)
 			for _, from := range topoDeps.UnsafeListOfStrings() {
 				// add task dep from all the package deps within repo
 				for depPkg := range depPkgs {
-					fromTaskID := util.GetTaskId(depPkg, from)
-					e.TaskGraph.Add(fromTaskID)
-					e.TaskGraph.Add(toTaskID)
-					e.TaskGraph.Connect(dag.BasicEdge(toTaskID, fromTaskID))
-					traversalQueue = append(traversalQueue, fromTaskID)
+					fromTaskIDs = append(fromTaskIDs, util.GetTaskId(depPkg, from))
 				}
 			}
 		}
 
-		if hasDeps {
-			for _, from := range deps.UnsafeListOfStrings() {
-				fromTaskID := util.GetTaskId(pkg, from)
-				e.TaskGraph.Add(fromTaskID)
-				e.TaskGraph.Add(toTaskID)
-				e.TaskGraph.Connect(dag.BasicEdge(toTaskID, fromTaskID))
-				traversalQueue = append(traversalQueue, fromTaskID)
-			}
+		// Dependencies on tasks from its own package
+		// E.g. `build: { dependsOn: [dev] }`
+		for _, from := range deps.UnsafeListOfStrings() {
+			fromTaskIDs = append(fromTaskIDs, util.GetTaskId(pkg, from))
 		}
 
-		if hasPackageTaskDeps {
-			if pkgTaskDeps, ok := e.PackageTaskDeps[toTaskID]; ok {
-				for _, fromTaskID := range pkgTaskDeps {
-					e.TaskGraph.Add(fromTaskID)
-					e.TaskGraph.Add(toTaskID)
-					e.TaskGraph.Connect(dag.BasicEdge(toTaskID, fromTaskID))
-					traversalQueue = append(traversalQueue, fromTaskID)
-				}
-			}
+		// If this is a workspace-specific task, it can depend on other workspace-specific tasks
+		// E.g. `my-package#build: { dependsOn: [my-package#beforebuild] }`.
+		fromTaskIDs = append(fromTaskIDs, e.PackageTaskDeps[toTaskID]...)
+
+		// Filter down the tasks if there's a filter in place
+		// https: //turbo.build/repo/docs/reference/command-line-reference#--only
+		if tasksOnly {
+			fromTaskIDs = e.filterOnlyDependencies(toTaskID, fromTaskIDs, inScope, requested)
+		}
+
+		for _, fromTaskID := range fromTaskIDs {
+			e.TaskGraph.Add(fromTaskID)
+			e.TaskGraph.Add(toTaskID)
+			e.TaskGraph.Connect(dag.BasicEdge(toTaskID, fromTaskID))
+			traversalQueue = append(traversalQueue, fromTaskID)
 		}
 
 		// Add the root node into the graph
-		if !hasDeps && !hasTopoDeps && !hasPackageTaskDeps {
+		if len(fromTaskIDs) == 0 {
 			e.TaskGraph.Add(ROOT_NODE_NAME)
 			e.TaskGraph.Add(toTaskID)
 			e.TaskGraph.Connect(dag.BasicEdge(toTaskID, ROOT_NODE_NAME))
@@ -361,6 +548,31 @@ func (e *Engine) Prepare(options *EngineBuildingOptions) error {
 	return nil
 }
 
+// filterOnlyDependencies returns the dependencies of taskID that --only runs:
+// the tasks that were asked for, in the packages that are in scope. The others
+// are recorded as assumed fresh, since the run relies on their outputs being
+// up to date without running them.
+func (e *Engine) filterOnlyDependencies(taskID string, fromTaskIDs []string, inScope util.Set, requested util.Set) []string {
+	kept := []string{}
+	skipped := []string{}
+	for _, fromTaskID := range fromTaskIDs {
+		fromPkg, fromTask := util.GetPackageTaskFromId(fromTaskID)
+		if inScope.Includes(fromPkg) && (requested.Includes(fromTask) || requested.Includes(fromTaskID)) {
+			kept = append(kept, fromTaskID)
+		} else {
+			skipped = append(skipped, fromTaskID)
+		}
+	}
+	if len(skipped) > 0 {
+		if e.completeGraph.AssumedFresh == nil {
+			e.completeGraph.AssumedFresh = map[string][]string{}
+		}
+		sort.Strings(skipped)
+		e.completeGraph.AssumedFresh[taskID] = skipped
+	}
+	return kept
+}
+
 // AddTask adds root tasks to the engine so they can be looked up later.
 func (e *Engine) AddTask(taskName string) {
 	if util.IsPackageTask(taskName) {
@@ -437,7 +649,7 @@ func (e *Engine) ValidatePersistentDependencies(graph *graph.CompleteGraph) erro
 			if !pkgExists {
 				return fmt.Errorf("Cannot find package %v", packageName)
 			}
-			_, hasScript := pkg.Scripts[taskName]
+			hasScript := depTaskDefinition.HasCommand(pkg, taskName)
 
 			// If both conditions are true set a value and break out of checking the dependencies
 			if depTaskDefinition.Persistent && hasScript {
@@ -462,11 +674,74 @@ func (e *Engine) ValidatePersistentDependencies(graph *graph.CompleteGraph) erro
 	return validationError
 }
 
+// TaskDefinitionSource is a definition of a task in one configuration file,
+// which is merged with the others that apply to the task
+type TaskDefinitionSource struct {
+	// File is the path of turbo.json or package.json from the repository root
+	File string `json:"file"`
+	// Key is the key of the definition in the file's pipeline, which is the
+	// task's name or ID
+	Key string `json:"key"`
+	// Keys are the keys that the definition sets, sorted
+	Keys []string `json:"keys"`
+}
+
+// ResolveTaskDefinition returns the definition of a task in a workspace, and
+// the definitions it was merged from, in the order they apply. The definition
+// is nil if the task isn't defined in any pipeline, so that `turbo run` doesn't
+// run it.
+func (e *Engine) ResolveTaskDefinition(pkg string, taskName string) (*fs.TaskDefinition, []TaskDefinitionSource, error) {
+	chain, err := e.getTaskDefinitionSources(util.GetTaskId(pkg, taskName), taskName)
+	if err != nil || len(chain) == 0 {
+		return nil, nil, err
+	}
+	taskDefinitions := make([]fs.BookkeepingTaskDefinition, len(chain))
+	sources := make([]TaskDefinitionSource, len(chain))
+	for i, source := range chain {
+		taskDefinitions[i] = source.definition
+		sources[i] = TaskDefinitionSource{File: source.file, Key: source.key, Keys: source.definition.DefinedKeys()}
+	}
+	taskDefinition, err := fs.MergeTaskDefinitions(taskDefinitions)
+	if err != nil {
+		return nil, nil, err
+	}
+	return taskDefinition, sources, nil
+}
+
+// sourcedTaskDefinition is a definition of a task, and where it is defined
+type sourcedTaskDefinition struct {
+	file       string
+	key        string
+	definition fs.BookkeepingTaskDefinition
+}
+
 // getTaskDefinitionChain gets a set of TaskDefinitions that apply to the taskID.
 // These definitions should be merged by the consumer.
 func (e *Engine) getTaskDefinitionChain(taskID string, taskName string) ([]fs.BookkeepingTaskDefinition, error) {
+	chain, err := e.getTaskDefinitionSources(taskID, taskName)
+	if err != nil {
+		return nil, err
+	}
+	if len(chain) == 0 {
+		if e.isSinglePackage {
+			return nil, fmt.Errorf("Could not find \"%s\" in root turbo.json", taskID)
+		}
+		taskIDPackage, _ := util.GetPackageTaskFromId(taskID)
+		return nil, fmt.Errorf("Could not find \"%s\" in root turbo.json or \"%s\" workspace", taskID, taskIDPackage)
+	}
+	taskDefinitions := make([]fs.BookkeepingTaskDefinition, len(chain))
+	for i, source := range chain {
+		taskDefinitions[i] = source.definition
+	}
+	return taskDefinitions, nil
+}
+
+// getTaskDefinitionSources is getTaskDefinitionChain, along with the file
+// that each definition is in. It returns no definitions for tasks that aren't
+// defined anywhere.
+func (e *Engine) getTaskDefinitionSources(taskID string, taskName string) ([]sourcedTaskDefinition, error) {
 	// Start a list of TaskDefinitions we've found for this TaskID
-	taskDefinitions := []fs.BookkeepingTaskDefinition{}
+	taskDefinitions := []sourcedTaskDefinition{}
 
 	rootPipeline, err := e.completeGraph.GetPipelineFromWorkspace(util.RootPkgName, e.isSinglePackage)
 	if err != nil {
@@ -477,15 +752,16 @@ func (e *Engine) getTaskDefinitionChain(taskID string, taskName string) ([]fs.Bo
 
 	// Look for the taskDefinition in the root pipeline.
 	if rootTaskDefinition, err := rootPipeline.GetTask(taskID, taskName); err == nil {
-		taskDefinitions = append(taskDefinitions, *rootTaskDefinition)
+		key := taskName
+		if _, ok := rootPipeline[taskID]; ok {
+			key = taskID
+		}
+		taskDefinitions = append(taskDefinitions, sourcedTaskDefinition{file: "turbo.json", key: key, definition: *rootTaskDefinition})
 	}
 
 	// If we're in a single package repo, we can just exit with the TaskDefinition in the root pipeline
 	// since there are no workspaces, and we don't need to follow any extends keys.
 	if e.isSinglePackage {
-		if len(taskDefinitions) == 0 {
-			return nil, fmt.Errorf("Could not find \"%s\" in root turbo.json", taskID)
-		}
 		return taskDefinitions, nil
 	}
 
@@ -493,40 +769,88 @@ func (e *Engine) getTaskDefinitionChain(taskID string, taskName string) ([]fs.Bo
 	// for a workspace task, since these can only be defined in the root turbo.json.
 	taskIDPackage, _ := util.GetPackageTaskFromId(taskID)
 	if taskIDPackage != util.RootPkgName && taskIDPackage != ROOT_NODE_NAME {
-		// If there is an error, we can ignore it, since turbo.json config is not required in the workspace.
-		if workspaceTurboJSON, err := e.completeGraph.GetTurboConfigFromWorkspace(taskIDPackage, e.isSinglePackage); err != nil {
-			// swallow the error where the config file doesn't exist, but bubble up other things
-			if !errors.Is(err, os.ErrNotExist) {
+		if rootPackageTask, ok := rootPipeline[taskID]; ok {
+			if err := e.checkPackageJSONConflicts(taskIDPackage, taskName, "turbo.json", rootPackageTask); err != nil {
 				return nil, err
 			}
-		} else {
-			// Run some validations on a workspace turbo.json. Note that these validations are on
-			// the whole struct, and not relevant to the taskID we're looking at right now.
-			validationErrors := workspaceTurboJSON.Validate([]fs.TurboJSONValidation{
-				validateNoPackageTaskSyntax,
-				validateExtends,
-			})
-
-			if len(validationErrors) > 0 {
-				fullError := errors.New("Invalid turbo.json")
-				for _, validationErr := range validationErrors {
-					fullError = fmt.Errorf("%w\n - %s", fullError, validationErr)
-				}
+		}
+		workspaceDefinitions, err := e.getWorkspaceTaskDefinitions(taskIDPackage, taskName)
+		if err != nil {
+			return nil, err
+		}
+		taskDefinitions = append(taskDefinitions, workspaceDefinitions...)
+	}
+
+	return taskDefinitions, nil
+}
 
-				return nil, fullError
+// getWorkspaceTaskDefinitions follows the "extends" of the turbo.json in the
+// workspace up to the root, and returns the definitions of taskName along the
+// way, from the one furthest from the workspace to the workspace's own.
+func (e *Engine) getWorkspaceTaskDefinitions(workspaceName string, taskName string) ([]sourcedTaskDefinition, error) {
+	taskDefinitions := []sourcedTaskDefinition{}
+	visited := make(util.Set)
+	extendedBy := ""
+	for workspace := workspaceName; workspace != util.RootPkgName; {
+		if visited.Includes(workspace) {
+			return nil, fmt.Errorf("Invalid turbo.json\n - \"extends\" in \"%s\" leads back to \"%s\"", extendedBy, workspace)
+		}
+		visited.Add(workspace)
+
+		if extendedBy != "" {
+			if _, ok := e.completeGraph.WorkspaceInfos.PackageJSONs[workspace]; !ok {
+				return nil, fmt.Errorf("Invalid turbo.json\n - \"%s\" extends \"%s\", which is not a workspace", extendedBy, workspace)
+			}
+		}
+		workspaceTurboJSON, err := e.completeGraph.GetTurboConfigFromWorkspace(workspace, e.isSinglePackage)
+		if err != nil {
+			// swallow the error where the config file doesn't exist, but bubble up other things.
+			// turbo.json config is not required in the workspace, unless it is extended.
+			if !errors.Is(err, os.ErrNotExist) {
+				return nil, err
+			}
+			if extendedBy != "" {
+				return nil, fmt.Errorf("Invalid turbo.json\n - \"%s\" extends \"%s\", which has no turbo.json", extendedBy, workspace)
 			}
+			break
+		}
 
-			// If there are no errors, we can (try to) add the TaskDefinition to our list.
-			if workspaceDefinition, ok := workspaceTurboJSON.Pipeline[taskName]; ok {
-				taskDefinitions = append(taskDefinitions, workspaceDefinition)
+		// Run some validations on a workspace turbo.json. Note that these validations are on
+		// the whole struct, and not relevant to the taskID we're looking at right now.
+		validationErrors := workspaceTurboJSON.Validate([]fs.TurboJSONValidation{
+			validateNoPackageTaskSyntax,
+			validateExtends,
+		})
+
+		if len(validationErrors) > 0 {
+			fullError := errors.New("Invalid turbo.json")
+			for _, validationErr := range validationErrors {
+				fullError = fmt.Errorf("%w\n - %s", fullError, validationErr)
 			}
+
+			return nil, fullError
 		}
-	}
 
-	if len(taskDefinitions) == 0 {
-		return nil, fmt.Errorf("Could not find \"%s\" in root turbo.json or \"%s\" workspace", taskID, taskIDPackage)
+		// If there are no errors, we can (try to) add the TaskDefinition to our list.
+		// The definitions of the workspaces that are extended apply first, and
+		// the ones in package.json apply after the ones in turbo.json.
+		workspaceDefinitions := []sourcedTaskDefinition{}
+		workspaceDir := e.completeGraph.WorkspaceInfos.PackageJSONs[workspace].Dir.ToUnixPath()
+		if workspaceDefinition, ok := workspaceTurboJSON.Pipeline[taskName]; ok {
+			turboJSONPath := workspaceDir.Join("turbo.json")
+			if err := e.checkPackageJSONConflicts(workspace, taskName, turboJSONPath.ToString(), workspaceDefinition); err != nil {
+				return nil, err
+			}
+			workspaceDefinitions = append(workspaceDefinitions, sourcedTaskDefinition{file: turboJSONPath.ToString(), key: taskName, definition: workspaceDefinition})
+		}
+		if packageJSONDefinition, ok := workspaceTurboJSON.PackageJSONPipeline[taskName]; ok {
+			packageJSONPath := workspaceDir.Join("package.json")
+			workspaceDefinitions = append(workspaceDefinitions, sourcedTaskDefinition{file: packageJSONPath.ToString(), key: taskName, definition: packageJSONDefinition})
+		}
+		taskDefinitions = append(workspaceDefinitions, taskDefinitions...)
+		extendedBy = workspace
+		workspace = workspaceTurboJSON.Extends[0]
 	}
-
 	return taskDefinitions, nil
 }
 
@@ -539,16 +863,47 @@ func validateNoPackageTaskSyntax(turboJSON *fs.TurboJSON) []error {
 			errors = append(errors, fmt.Errorf("\"%s\". Use \"%s\" instead", taskIDOrName, taskName))
 		}
 	}
+	for taskIDOrName := range turboJSON.PackageJSONPipeline {
+		if util.IsPackageTask(taskIDOrName) {
+			taskName := util.StripPackageName(taskIDOrName)
+			errors = append(errors, fmt.Errorf("\"%s\" in package.json. Use \"%s\" instead", taskIDOrName, taskName))
+		}
+	}
 
 	return errors
 }
 
+// checkPackageJSONConflicts returns an error listing the keys that the
+// definition of taskName in the "turbo" key of the workspace's package.json
+// sets, and that definition, from file, sets for the same workspace as well.
+// Neither would clearly take precedence over the other.
+func (e *Engine) checkPackageJSONConflicts(workspace string, taskName string, file string, definition fs.BookkeepingTaskDefinition) error {
+	turboJSON, err := e.completeGraph.GetTurboConfigFromWorkspace(workspace, e.isSinglePackage)
+	if err != nil {
+		return nil
+	}
+	packageJSONDefinition, ok := turboJSON.PackageJSONPipeline[taskName]
+	if !ok {
+		return nil
+	}
+	keys := definition.ConflictingKeys(packageJSONDefinition)
+	if len(keys) == 0 {
+		return nil
+	}
+	packageJSONPath := e.completeGraph.WorkspaceInfos.PackageJSONs[workspace].Dir.ToUnixPath().Join("package.json")
+	conflictErr := fmt.Errorf("Conflicting configuration for \"%s\" in workspace \"%s\"", taskName, workspace)
+	for _, key := range keys {
+		conflictErr = fmt.Errorf("%w\n - \"%s\" is set in both %s and %s", conflictErr, key, file, packageJSONPath)
+	}
+	return conflictErr
+}
+
 func validateExtends(turboJSON *fs.TurboJSON) []error {
 	extendErrors := []error{}
 	extends := turboJSON.Extends
 	// TODO(mehulkar): Enable extending from more than one workspace.
 	if len(extends) > 1 {
-		extendErrors = append(extendErrors, fmt.Errorf("You can only extend from the root workspace"))
+		extendErrors = append(extendErrors, fmt.Errorf("You can only extend from one workspace"))
 	}
 
 	// We don't support this right now
@@ -556,11 +911,6 @@ func validateExtends(turboJSON *fs.TurboJSON) []error {
 		extendErrors = append(extendErrors, fmt.Errorf("No \"extends\" key found"))
 	}
 
-	// TODO(mehulkar): Enable extending from non-root workspace.
-	if len(extends) == 1 && extends[0] != util.RootPkgName {
-		extendErrors = append(extendErrors, fmt.Errorf("You can only extend from the root workspace"))
-	}
-
 	return extendErrors
 }
 
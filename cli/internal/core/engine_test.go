@@ -0,0 +1,451 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/graph"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+	"github.com/vercel/turbo/cli/internal/workspace"
+	"gotest.tools/v3/assert"
+)
+
+func TestExecuteDeterministic(t *testing.T) {
+	// web#build and docs#build depend on ui#build, and web#test depends on web#build
+	graph := &dag.AcyclicGraph{}
+	for _, taskID := range []string{ROOT_NODE_NAME, "ui#build", "web#build", "docs#build", "web#test", "docs#lint"} {
+		graph.Add(taskID)
+	}
+	graph.Connect(dag.BasicEdge("ui#build", ROOT_NODE_NAME))
+	graph.Connect(dag.BasicEdge("docs#lint", ROOT_NODE_NAME))
+	graph.Connect(dag.BasicEdge("web#build", "ui#build"))
+	graph.Connect(dag.BasicEdge("docs#build", "ui#build"))
+	graph.Connect(dag.BasicEdge("web#test", "web#build"))
+	engine := &Engine{TaskGraph: graph}
+
+	for i := 0; i < 5; i++ {
+		var visited []string
+		errs := engine.Execute(func(taskID string) error {
+			visited = append(visited, taskID)
+			return nil
+		}, EngineExecutionOptions{Deterministic: true})
+		assert.Equal(t, len(errs), 0)
+		assert.DeepEqual(t, visited, []string{"docs#lint", "ui#build", "docs#build", "web#build", "web#test"})
+	}
+}
+
+func TestExecuteDeterministicSkipsDependentsOfFailures(t *testing.T) {
+	graph := &dag.AcyclicGraph{}
+	for _, taskID := range []string{ROOT_NODE_NAME, "ui#build", "web#build", "docs#lint"} {
+		graph.Add(taskID)
+	}
+	graph.Connect(dag.BasicEdge("ui#build", ROOT_NODE_NAME))
+	graph.Connect(dag.BasicEdge("docs#lint", ROOT_NODE_NAME))
+	graph.Connect(dag.BasicEdge("web#build", "ui#build"))
+	engine := &Engine{TaskGraph: graph}
+
+	var visited []string
+	errs := engine.Execute(func(taskID string) error {
+		visited = append(visited, taskID)
+		if taskID == "ui#build" {
+			return errors.New("build failed")
+		}
+		return nil
+	}, EngineExecutionOptions{Deterministic: true})
+	assert.Equal(t, len(errs), 1)
+	assert.DeepEqual(t, visited, []string{"docs#lint", "ui#build"})
+}
+
+func TestFailedDependents(t *testing.T) {
+	// web#test depends on web#build, which depends on ui#build and utils#build
+	graph := &dag.AcyclicGraph{}
+	for _, taskID := range []string{ROOT_NODE_NAME, "ui#build", "utils#build", "web#build", "web#test", "docs#lint"} {
+		graph.Add(taskID)
+	}
+	graph.Connect(dag.BasicEdge("ui#build", ROOT_NODE_NAME))
+	graph.Connect(dag.BasicEdge("utils#build", ROOT_NODE_NAME))
+	graph.Connect(dag.BasicEdge("docs#lint", ROOT_NODE_NAME))
+	graph.Connect(dag.BasicEdge("web#build", "ui#build"))
+	graph.Connect(dag.BasicEdge("web#build", "utils#build"))
+	graph.Connect(dag.BasicEdge("web#test", "web#build"))
+	engine := &Engine{TaskGraph: graph}
+
+	assert.DeepEqual(t, engine.FailedDependents([]string{"utils#build", "ui#build"}), map[string][]string{
+		"web#build": {"ui#build", "utils#build"},
+		"web#test":  {"ui#build", "utils#build"},
+	})
+	assert.DeepEqual(t, engine.FailedDependents([]string{"web#test", "docs#lint"}), map[string][]string{})
+}
+
+func TestExecuteWeighted(t *testing.T) {
+	// Each task is independent, so only their weights limit how many run at once
+	weights := map[string]int{"web#build": 3, "docs#build": 3, "ui#lint": 1, "api#build": 10, "web#lint": 0}
+	taskGraph := &dag.AcyclicGraph{}
+	taskGraph.Add(ROOT_NODE_NAME)
+	taskDefinitions := make(map[string]*fs.TaskDefinition)
+	for taskID, weight := range weights {
+		taskGraph.Add(taskID)
+		taskGraph.Connect(dag.BasicEdge(taskID, ROOT_NODE_NAME))
+		taskDefinitions[taskID] = &fs.TaskDefinition{Weight: weight}
+	}
+	engine := &Engine{TaskGraph: taskGraph, completeGraph: &graph.CompleteGraph{TaskDefinitions: taskDefinitions}}
+	assert.Equal(t, engine.taskWeight("api#build", 4), int64(4), "weights are capped at the concurrency")
+	assert.Equal(t, engine.taskWeight("web#lint", 4), int64(1), "tasks without a weight take one slot")
+	taskDefinitions["web#codegen"] = &fs.TaskDefinition{Interactive: true}
+	assert.Equal(t, engine.taskWeight("web#codegen", 4), int64(4), "interactive tasks run on their own")
+
+	var mu sync.Mutex
+	running := 0
+	maxRunning := 0
+	errs := engine.Execute(func(taskID string) error {
+		weight := int(engine.taskWeight(taskID, 4))
+		mu.Lock()
+		running += weight
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running -= weight
+		mu.Unlock()
+		return nil
+	}, EngineExecutionOptions{Concurrency: 4})
+	assert.Equal(t, len(errs), 0)
+	assert.Assert(t, maxRunning <= 4, "ran tasks with a total weight of %v", maxRunning)
+}
+
+func TestExecutePersistent(t *testing.T) {
+	// web#dev depends on ui#build and keeps running until the other tasks
+	// finish, like a dev server would until Ctrl-C
+	taskGraph := &dag.AcyclicGraph{}
+	for _, taskID := range []string{ROOT_NODE_NAME, "ui#build", "web#dev", "docs#dev", "web#lint"} {
+		taskGraph.Add(taskID)
+	}
+	taskGraph.Connect(dag.BasicEdge("ui#build", ROOT_NODE_NAME))
+	taskGraph.Connect(dag.BasicEdge("docs#dev", ROOT_NODE_NAME))
+	taskGraph.Connect(dag.BasicEdge("web#lint", ROOT_NODE_NAME))
+	taskGraph.Connect(dag.BasicEdge("web#dev", "ui#build"))
+	taskDefinitions := map[string]*fs.TaskDefinition{
+		"ui#build": {},
+		"web#dev":  {Persistent: true},
+		"docs#dev": {Persistent: true},
+		"web#lint": {},
+	}
+	engine := &Engine{TaskGraph: taskGraph, completeGraph: &graph.CompleteGraph{TaskDefinitions: taskDefinitions}}
+
+	for _, opts := range []EngineExecutionOptions{{Concurrency: 1}, {Deterministic: true}} {
+		var mu sync.Mutex
+		var finished []string
+		othersFinished := make(chan struct{})
+		errs := engine.Execute(func(taskID string) error {
+			if taskDefinitions[taskID].Persistent {
+				mu.Lock()
+				if taskID == "web#dev" {
+					assert.Assert(t, len(finished) > 0, "web#dev started before ui#build finished")
+				}
+				mu.Unlock()
+				select {
+				case <-othersFinished:
+				case <-time.After(2 * time.Second):
+					return errors.New("timed out waiting for the other tasks")
+				}
+				return nil
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			finished = append(finished, taskID)
+			if len(finished) == 2 {
+				close(othersFinished)
+			}
+			return nil
+		}, opts)
+		assert.Equal(t, len(errs), 0, "%+v", opts)
+	}
+}
+
+// newComposedEngine returns an engine for a monorepo with a workspace for each
+// of workspaces, and the given turbo.json in the root and in some of them
+func newComposedEngine(t *testing.T, workspaces []string, turboJSONs map[string]string) *Engine {
+	catalog := workspace.Catalog{
+		PackageJSONs: map[string]*fs.PackageJSON{util.RootPkgName: {}},
+		TurboConfigs: map[string]*fs.TurboJSON{},
+	}
+	for _, name := range workspaces {
+		catalog.PackageJSONs[name] = &fs.PackageJSON{Name: name, Dir: turbopath.AnchoredUnixPath("apps/" + name).ToSystemPath()}
+	}
+	for name, contents := range turboJSONs {
+		turboJSON := &fs.TurboJSON{}
+		assert.NilError(t, json.Unmarshal([]byte(contents), turboJSON))
+		catalog.TurboConfigs[name] = turboJSON
+	}
+	repoRoot := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	return NewEngine(&graph.CompleteGraph{WorkspaceInfos: catalog, RepoRoot: repoRoot, TaskDefinitions: map[string]*fs.TaskDefinition{}}, false)
+}
+
+func TestGetTaskDefinitionChainExtends(t *testing.T) {
+	engine := newComposedEngine(t, []string{"shared", "web", "docs"}, map[string]string{
+		util.RootPkgName: `{"pipeline": {"build": {"outputs": ["dist/**"], "inputs": ["src/**"], "env": ["NODE_ENV"]}}}`,
+		"shared":         `{"extends": ["//"], "pipeline": {"build": {"outputs": ["$TURBO_EXTENDS$", "lib/**"]}}}`,
+		"web":            `{"extends": ["shared"], "pipeline": {"build": {"inputs": ["$TURBO_EXTENDS$", "public/**"], "env": ["API_URL"]}}}`,
+	})
+
+	chain, err := engine.getTaskDefinitionChain("web#build", "build")
+	assert.NilError(t, err)
+	assert.Equal(t, len(chain), 3)
+	merged, err := fs.MergeTaskDefinitions(chain)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, merged.Outputs.Inclusions, []string{"dist/**", "lib/**"})
+	assert.DeepEqual(t, merged.Inputs, []string{"src/**", "public/**"})
+	assert.DeepEqual(t, merged.EnvVarDependencies, []string{"API_URL"})
+
+	// A workspace without a turbo.json only uses the root's
+	chain, err = engine.getTaskDefinitionChain("docs#build", "build")
+	assert.NilError(t, err)
+	assert.Equal(t, len(chain), 1)
+}
+
+func TestResolveTaskDefinition(t *testing.T) {
+	engine := newComposedEngine(t, []string{"shared", "web"}, map[string]string{
+		util.RootPkgName: `{"pipeline": {"build": {"outputs": ["dist/**"], "env": ["NODE_ENV"]}, "web#lint": {}}}`,
+		"shared":         `{"extends": ["//"], "pipeline": {"build": {"outputs": ["$TURBO_EXTENDS$", "lib/**"]}}}`,
+		"web":            `{"extends": ["shared"], "pipeline": {"build": {"inputs": ["src/**"]}}}`,
+	})
+
+	taskDefinition, sources, err := engine.ResolveTaskDefinition("web", "build")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, taskDefinition.Outputs.Inclusions, []string{"dist/**", "lib/**"})
+	assert.DeepEqual(t, taskDefinition.Inputs, []string{"src/**"})
+	assert.DeepEqual(t, sources, []TaskDefinitionSource{
+		{File: "turbo.json", Key: "build", Keys: []string{"env", "outputs"}},
+		{File: "apps/shared/turbo.json", Key: "build", Keys: []string{"outputs"}},
+		{File: "apps/web/turbo.json", Key: "build", Keys: []string{"inputs"}},
+	})
+
+	_, sources, err = engine.ResolveTaskDefinition("web", "lint")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, sources, []TaskDefinitionSource{{File: "turbo.json", Key: "web#lint", Keys: []string{}}})
+
+	// Tasks that no pipeline defines aren't an error
+	taskDefinition, sources, err = engine.ResolveTaskDefinition("web", "test")
+	assert.NilError(t, err)
+	assert.Assert(t, taskDefinition == nil)
+	assert.Equal(t, len(sources), 0)
+}
+
+func TestGetTaskDefinitionFromExtendedWorkspace(t *testing.T) {
+	engine := newComposedEngine(t, []string{"shared", "web", "docs"}, map[string]string{
+		util.RootPkgName: `{"pipeline": {"build": {}}}`,
+		"shared":         `{"extends": ["//"], "pipeline": {"lint": {"outputs": ["lint-report.json"]}}}`,
+		"web":            `{"extends": ["shared"], "pipeline": {"build": {}}}`,
+		"docs":           `{"extends": ["docs"], "pipeline": {}}`,
+	})
+
+	task, err := engine.getTaskDefinition("web", "lint", "web#lint")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, task.TaskDefinition.Outputs.Inclusions, []string{"lint-report.json"})
+
+	// A chain that doesn't lead to the root falls back to it
+	_, err = engine.getTaskDefinition("docs", "lint", "docs#lint")
+	var missing *MissingTaskError
+	assert.Assert(t, errors.As(err, &missing))
+}
+
+// setPackageJSONPipeline sets the "turbo" key of the package.json of workspace
+func setPackageJSONPipeline(t *testing.T, engine *Engine, workspace string, contents string) {
+	t.Helper()
+	packageJSONConfig := &fs.TurboJSON{}
+	assert.NilError(t, json.Unmarshal([]byte(contents), packageJSONConfig))
+	turboJSON, ok := engine.completeGraph.WorkspaceInfos.TurboConfigs[workspace]
+	if !ok {
+		turboJSON = &fs.TurboJSON{Extends: []string{util.RootPkgName}, Pipeline: fs.Pipeline{}}
+		engine.completeGraph.WorkspaceInfos.TurboConfigs[workspace] = turboJSON
+	}
+	turboJSON.PackageJSONPipeline = packageJSONConfig.Pipeline
+}
+
+func TestGetTaskDefinitionChainPackageJSON(t *testing.T) {
+	engine := newComposedEngine(t, []string{"web", "docs"}, map[string]string{
+		util.RootPkgName: `{"pipeline": {"build": {"outputs": ["dist/**"]}, "docs#build": {"env": ["DOCS_URL"]}}}`,
+		"web":            `{"extends": ["//"], "pipeline": {"build": {"inputs": ["src/**"]}}}`,
+	})
+	setPackageJSONPipeline(t, engine, "web", `{"pipeline": {"build": {"outputs": ["$TURBO_EXTENDS$", "types/**"]}, "lint": {}}}`)
+	setPackageJSONPipeline(t, engine, "docs", `{"pipeline": {"build": {"outputs": [".next/**"]}}}`)
+
+	chain, err := engine.getTaskDefinitionChain("web#build", "build")
+	assert.NilError(t, err)
+	assert.Equal(t, len(chain), 3)
+	merged, err := fs.MergeTaskDefinitions(chain)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, merged.Outputs.Inclusions, []string{"dist/**", "types/**"})
+	assert.DeepEqual(t, merged.Inputs, []string{"src/**"})
+
+	// Tasks that are only in package.json are found
+	_, err = engine.getTaskDefinition("web", "lint", "web#lint")
+	assert.NilError(t, err)
+
+	// A workspace without a turbo.json extends the root
+	chain, err = engine.getTaskDefinitionChain("docs#build", "build")
+	assert.NilError(t, err)
+	merged, err = fs.MergeTaskDefinitions(chain)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, merged.Outputs.Inclusions, []string{".next/**"})
+	assert.DeepEqual(t, merged.EnvVarDependencies, []string{"DOCS_URL"})
+}
+
+func TestGetTaskDefinitionChainPackageJSONConflicts(t *testing.T) {
+	engine := newComposedEngine(t, []string{"web", "docs"}, map[string]string{
+		util.RootPkgName: `{"pipeline": {"build": {"outputs": ["dist/**"]}, "docs#build": {"env": ["DOCS_URL"], "cache": false}}}`,
+		"web":            `{"extends": ["//"], "pipeline": {"build": {"inputs": ["src/**"], "outputs": ["lib/**"]}}}`,
+	})
+	setPackageJSONPipeline(t, engine, "web", `{"pipeline": {"build": {"outputs": ["types/**"], "inputs": ["src/**"]}}}`)
+	setPackageJSONPipeline(t, engine, "docs", `{"pipeline": {"build": {"env": ["API_URL"], "outputs": [".next/**"]}}}`)
+
+	_, err := engine.getTaskDefinitionChain("web#build", "build")
+	assert.Error(t, err, "Conflicting configuration for \"build\" in workspace \"web\"\n"+
+		" - \"inputs\" is set in both apps/web/turbo.json and apps/web/package.json\n"+
+		" - \"outputs\" is set in both apps/web/turbo.json and apps/web/package.json")
+
+	_, err = engine.getTaskDefinitionChain("docs#build", "build")
+	assert.Error(t, err, "Conflicting configuration for \"build\" in workspace \"docs\"\n"+
+		" - \"env\" is set in both turbo.json and apps/docs/package.json")
+}
+
+func TestGetTaskDefinitionChainExtendsErrors(t *testing.T) {
+	root := `{"pipeline": {"build": {}}}`
+	testCases := []struct {
+		name       string
+		turboJSONs map[string]string
+		want       string
+	}{
+		{
+			name:       "cycle",
+			turboJSONs: map[string]string{"web": `{"extends": ["shared"]}`, "shared": `{"extends": ["web"]}`},
+			want:       `"extends" in "shared" leads back to "web"`,
+		},
+		{
+			name:       "unknown workspace",
+			turboJSONs: map[string]string{"web": `{"extends": ["missing"]}`},
+			want:       `"web" extends "missing", which is not a workspace`,
+		},
+		{
+			name:       "no turbo.json",
+			turboJSONs: map[string]string{"web": `{"extends": ["shared"]}`},
+			want:       `"web" extends "shared", which has no turbo.json`,
+		},
+		{
+			name:       "several workspaces",
+			turboJSONs: map[string]string{"web": `{"extends": ["//", "shared"]}`},
+			want:       "You can only extend from one workspace",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.turboJSONs[util.RootPkgName] = root
+			engine := newComposedEngine(t, []string{"shared", "web"}, tc.turboJSONs)
+			_, err := engine.getTaskDefinitionChain("web#build", "build")
+			assert.ErrorContains(t, err, tc.want)
+		})
+	}
+}
+
+func TestPrepareTasksOnly(t *testing.T) {
+	testCases := []struct {
+		name         string
+		packages     []string
+		taskNames    []string
+		edges        map[string][]string
+		assumedFresh map[string][]string
+	}{
+		{
+			name:         "dependencies outside of scope",
+			packages:     []string{"web"},
+			taskNames:    []string{"build"},
+			edges:        map[string][]string{"web#build": {ROOT_NODE_NAME}},
+			assumedFresh: map[string][]string{"web#build": {"ui#build", "web#codegen"}},
+		},
+		{
+			name:      "packages in scope",
+			packages:  []string{"ui", "web"},
+			taskNames: []string{"build"},
+			edges:     map[string][]string{"web#build": {"ui#build"}, "ui#build": {ROOT_NODE_NAME}},
+			assumedFresh: map[string][]string{
+				"ui#build":  {"ui#codegen"},
+				"web#build": {"web#codegen"},
+			},
+		},
+		{
+			name:         "every task name",
+			packages:     []string{"web"},
+			taskNames:    []string{"build", "codegen"},
+			edges:        map[string][]string{"web#build": {"web#codegen"}, "web#codegen": {ROOT_NODE_NAME}},
+			assumedFresh: map[string][]string{"web#build": {"ui#build"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			engine := newComposedEngine(t, []string{"ui", "web"}, map[string]string{
+				util.RootPkgName: `{"pipeline": {"build": {"dependsOn": ["^build", "codegen"]}, "codegen": {}}}`,
+			})
+			engine.completeGraph.WorkspaceGraph.Add("ui")
+			engine.completeGraph.WorkspaceGraph.Add("web")
+			engine.completeGraph.WorkspaceGraph.Connect(dag.BasicEdge("web", "ui"))
+
+			assert.NilError(t, engine.Prepare(&EngineBuildingOptions{Packages: tc.packages, TaskNames: tc.taskNames, TasksOnly: true}))
+			for taskID, want := range tc.edges {
+				got := []string{}
+				for _, dep := range engine.TaskGraph.DownEdges(taskID).List() {
+					got = append(got, dep.(string))
+				}
+				assert.DeepEqual(t, got, want)
+			}
+			assert.DeepEqual(t, engine.completeGraph.AssumedFresh, tc.assumedFresh)
+		})
+	}
+}
+
+func TestPrepareRootTaskDependency(t *testing.T) {
+	engine := newComposedEngine(t, []string{"ui", "web"}, map[string]string{
+		util.RootPkgName: `{"pipeline": {"build": {"dependsOn": ["^build", "//#codegen"]}, "//#codegen": {"outputs": ["generated/**"]}}}`,
+	})
+	engine.completeGraph.WorkspaceGraph.Add("ui")
+	engine.completeGraph.WorkspaceGraph.Add("web")
+	engine.completeGraph.WorkspaceGraph.Connect(dag.BasicEdge("web", "ui"))
+
+	assert.NilError(t, engine.Prepare(&EngineBuildingOptions{Packages: []string{"ui", "web"}, TaskNames: []string{"build"}}))
+	// Both builds depend on the one root task
+	codegen := util.GetTaskId(util.RootPkgName, "codegen")
+	for taskID, want := range map[string][]string{
+		"ui#build":  {codegen},
+		"web#build": {codegen, "ui#build"},
+		codegen:     {ROOT_NODE_NAME},
+	} {
+		got := []string{}
+		for _, dep := range engine.TaskGraph.DownEdges(taskID).List() {
+			got = append(got, dep.(string))
+		}
+		sort.Strings(got)
+		assert.DeepEqual(t, got, want)
+	}
+	assert.DeepEqual(t, engine.completeGraph.TaskDefinitions[codegen].Outputs.Inclusions, []string{"generated/**"})
+}
+
+func TestRemoveEntryTasks(t *testing.T) {
+	engine := newPrioritizedEngine(nil)
+	kept := engine.RemoveEntryTasks([]string{"web#test", "web#build", "ui#build", "docs#build"})
+	assert.Equal(t, len(kept), 0)
+	assert.Assert(t, !engine.TaskGraph.HasVertex("ui#build"), "tasks are removed once their dependents are")
+
+	engine = newPrioritizedEngine(nil)
+	kept = engine.RemoveEntryTasks([]string{"web#build", "ui#build", "a#lint"})
+	assert.DeepEqual(t, kept, []string{"ui#build", "web#build"})
+	assert.Assert(t, engine.TaskGraph.HasVertex("web#test"))
+	assert.Assert(t, !engine.TaskGraph.HasVertex("a#lint"))
+}
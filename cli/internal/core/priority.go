@@ -0,0 +1,269 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pyr-sh/dag"
+)
+
+// taskRank orders the tasks that are ready to run. Tasks with a higher
+// priority start first, then the ones with the longest chain of tasks left to
+// run after them, by how long those took the last time.
+type taskRank struct {
+	priority     int
+	criticalPath time.Duration
+}
+
+func (r taskRank) before(other taskRank) bool {
+	if r.priority != other.priority {
+		return r.priority > other.priority
+	}
+	return r.criticalPath > other.criticalPath
+}
+
+// hasPriorities is whether any task in the pipeline sets a priority
+func (e *Engine) hasPriorities() bool {
+	if e.completeGraph == nil {
+		return false
+	}
+	for _, taskDefinition := range e.completeGraph.TaskDefinitions {
+		if taskDefinition.Priority != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// taskPriority returns the priority set for a task in the pipeline
+func (e *Engine) taskPriority(taskID string) int {
+	if e.completeGraph == nil {
+		return 0
+	}
+	if taskDefinition, ok := e.completeGraph.TaskDefinitions[taskID]; ok {
+		return taskDefinition.Priority
+	}
+	return 0
+}
+
+// taskRanks ranks every task in the graph. A task takes the highest priority
+// among itself and the tasks that depend on it, so that a high priority task
+// isn't held back by its dependencies. Its critical path is its own duration
+// plus the longest critical path among the tasks that depend on it.
+func (e *Engine) taskRanks(durations map[string]time.Duration) map[string]taskRank {
+	ranks := make(map[string]taskRank)
+	var rank func(taskID string) taskRank
+	rank = func(taskID string) taskRank {
+		if r, ok := ranks[taskID]; ok {
+			return r
+		}
+		r := taskRank{priority: e.taskPriority(taskID)}
+		var longestDependent time.Duration
+		for _, dependent := range e.TaskGraph.UpEdges(taskID).List() {
+			dependentRank := rank(dag.VertexName(dependent))
+			if dependentRank.priority > r.priority {
+				r.priority = dependentRank.priority
+			}
+			if dependentRank.criticalPath > longestDependent {
+				longestDependent = dependentRank.criticalPath
+			}
+		}
+		r.criticalPath = durations[taskID] + longestDependent
+		ranks[taskID] = r
+		return r
+	}
+	for _, v := range e.TaskGraph.Vertices() {
+		rank(dag.VertexName(v))
+	}
+	return ranks
+}
+
+// executePrioritized visits each task once all of its dependencies have been
+// visited, like Walk, but when there are more tasks ready to run than free
+// concurrency slots, it starts the highest ranked ones first. Like the
+// semaphore used by Walk, a heavy task that doesn't fit yet holds back the
+// tasks ranked after it, so that it isn't starved by lighter tasks. Tasks that
+// depend on a failed task are skipped.
+func (e *Engine) executePrioritized(visitor Visitor, opts EngineExecutionOptions) []error {
+	type result struct {
+		taskID string
+		weight int64
+		err    error
+	}
+	ranks := e.taskRanks(opts.TaskDurations)
+	remainingDeps := make(map[string]int)
+	failed := make(map[string]bool)
+	var ready []string
+	var roots []string
+	for _, v := range e.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		remainingDeps[taskID] = len(e.TaskGraph.DownEdges(taskID).List())
+		if remainingDeps[taskID] > 0 {
+			continue
+		}
+		// Always skip the root node
+		if strings.Contains(taskID, ROOT_NODE_NAME) {
+			roots = append(roots, taskID)
+		} else {
+			ready = append(ready, taskID)
+		}
+	}
+
+	// Tasks started early by opts.Speculation are speculating until all of
+	// their dependencies were visited. Their results are held until then, and
+	// dropped if a dependency failed.
+	speculation := opts.Speculation
+	speculating := make(map[string]bool)
+	held := make(map[string]result)
+	abandoned := make(map[string]bool)
+
+	// finish makes the dependents of a visited task ready once all of their
+	// dependencies were visited. Dependents of failed tasks are skipped.
+	var finish func(taskID string)
+	var complete func(r result)
+	finish = func(taskID string) {
+		for _, dependent := range e.TaskGraph.UpEdges(taskID).List() {
+			dependentID := dag.VertexName(dependent)
+			if failed[taskID] {
+				if speculating[dependentID] && !abandoned[dependentID] {
+					abandoned[dependentID] = true
+					delete(held, dependentID)
+					speculation.settle(dependentID, ErrUpstreamFailed)
+				}
+				failed[dependentID] = true
+			}
+			remainingDeps[dependentID]--
+			if remainingDeps[dependentID] > 0 {
+				continue
+			}
+			startedEarly := speculating[dependentID]
+			delete(speculating, dependentID)
+			if failed[dependentID] {
+				finish(dependentID)
+			} else if startedEarly {
+				speculation.settle(dependentID, nil)
+				if r, ok := held[dependentID]; ok {
+					delete(held, dependentID)
+					complete(r)
+				}
+			} else {
+				ready = append(ready, dependentID)
+			}
+		}
+	}
+
+	var errs []error
+	// complete records the result of visiting a task. A task that was started
+	// early with stale dependencies is visited again.
+	complete = func(r result) {
+		if errors.Is(r.err, ErrSpeculationStale) {
+			speculation.restart(r.taskID)
+			ready = append(ready, r.taskID)
+			return
+		}
+		if r.err != nil {
+			failed[r.taskID] = true
+			errs = append(errs, r.err)
+		}
+		finish(r.taskID)
+	}
+
+	for _, taskID := range roots {
+		finish(taskID)
+	}
+
+	byRank := func(taskIDs []string) {
+		sort.Slice(taskIDs, func(i, j int) bool {
+			a, b := ranks[taskIDs[i]], ranks[taskIDs[j]]
+			if a != b {
+				return a.before(b)
+			}
+			return taskIDs[i] < taskIDs[j]
+		})
+	}
+	results := make(chan result)
+	free := int64(opts.Concurrency)
+	weights := make(map[string]int64)
+	released := make(map[string]bool)
+	running := 0
+	start := func(taskID string, weight int64) {
+		free -= weight
+		weights[taskID] = weight
+		running++
+		go func() {
+			results <- result{taskID: taskID, weight: weight, err: visitor(taskID)}
+		}()
+	}
+	var waiting chan string
+	if speculation != nil {
+		waiting = speculation.waiting
+	}
+	for len(ready) > 0 || running > 0 {
+		byRank(ready)
+		for len(ready) > 0 {
+			taskID := ready[0]
+			// Persistent tasks don't take a slot, see Execute
+			var weight int64
+			if !e.isPersistent(taskID) {
+				weight = e.taskWeight(taskID, opts.Concurrency)
+				if weight > free {
+					break
+				}
+			}
+			ready = ready[1:]
+			start(taskID, weight)
+		}
+		// Slots that no ready task can use start the best ranked tasks that
+		// can run early
+		if len(ready) == 0 && free > 0 && speculation != nil {
+			var candidates []string
+			for taskID := range speculation.tasks {
+				if remainingDeps[taskID] > 0 && !failed[taskID] && !speculating[taskID] && !e.isPersistent(taskID) {
+					candidates = append(candidates, taskID)
+				}
+			}
+			byRank(candidates)
+			for _, taskID := range candidates {
+				weight := e.taskWeight(taskID, opts.Concurrency)
+				if weight > free {
+					break
+				}
+				speculating[taskID] = true
+				speculation.start(taskID)
+				start(taskID, weight)
+			}
+		}
+		if running == 0 {
+			if len(ready) > 0 {
+				return append(errs, fmt.Errorf("could not schedule the remaining tasks: %v", strings.Join(ready, ", ")))
+			}
+			break
+		}
+
+		select {
+		case taskID := <-waiting:
+			// A task that was started early waits for its dependencies
+			// without a slot
+			free += weights[taskID]
+			released[taskID] = true
+		case r := <-results:
+			running--
+			if !released[r.taskID] {
+				free += r.weight
+			}
+			delete(released, r.taskID)
+			switch {
+			case abandoned[r.taskID]:
+				delete(abandoned, r.taskID)
+			case speculating[r.taskID]:
+				held[r.taskID] = r
+			default:
+				complete(r)
+			}
+		}
+	}
+	return errs
+}
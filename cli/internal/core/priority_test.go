@@ -0,0 +1,84 @@
+package core
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/graph"
+	"gotest.tools/v3/assert"
+)
+
+// newPrioritizedEngine builds a graph where web#test depends on web#build, which
+// depends on ui#build, next to independent lint tasks and docs#build
+func newPrioritizedEngine(taskDefinitions map[string]*fs.TaskDefinition) *Engine {
+	taskGraph := &dag.AcyclicGraph{}
+	for _, taskID := range []string{ROOT_NODE_NAME, "a#lint", "b#lint", "c#lint", "ui#build", "web#build", "web#test", "docs#build"} {
+		taskGraph.Add(taskID)
+	}
+	for _, taskID := range []string{"a#lint", "b#lint", "c#lint", "ui#build", "docs#build"} {
+		taskGraph.Connect(dag.BasicEdge(taskID, ROOT_NODE_NAME))
+	}
+	taskGraph.Connect(dag.BasicEdge("web#build", "ui#build"))
+	taskGraph.Connect(dag.BasicEdge("web#test", "web#build"))
+	return &Engine{TaskGraph: taskGraph, completeGraph: &graph.CompleteGraph{TaskDefinitions: taskDefinitions}}
+}
+
+func TestTaskRanks(t *testing.T) {
+	engine := newPrioritizedEngine(map[string]*fs.TaskDefinition{
+		"web#test":   {Priority: 2},
+		"docs#build": {Priority: 1},
+	})
+	ranks := engine.taskRanks(map[string]time.Duration{
+		"ui#build":  10 * time.Second,
+		"web#build": time.Minute,
+		"a#lint":    time.Second,
+	})
+	assert.Equal(t, ranks["ui#build"], taskRank{priority: 2, criticalPath: 70 * time.Second}, "dependencies take the priority of their dependents")
+	assert.Equal(t, ranks["web#test"], taskRank{priority: 2})
+	assert.Equal(t, ranks["docs#build"], taskRank{priority: 1})
+	assert.Equal(t, ranks["a#lint"], taskRank{criticalPath: time.Second})
+}
+
+func TestExecutePrioritized(t *testing.T) {
+	engine := newPrioritizedEngine(map[string]*fs.TaskDefinition{
+		"docs#build": {Priority: 1},
+	})
+	durations := map[string]time.Duration{
+		"web#build": time.Minute,
+		"c#lint":    time.Second,
+	}
+
+	var visited []string
+	errs := engine.Execute(func(taskID string) error {
+		visited = append(visited, taskID)
+		return nil
+	}, EngineExecutionOptions{Concurrency: 1, TaskDurations: durations})
+	assert.Equal(t, len(errs), 0)
+	assert.DeepEqual(t, visited, []string{"docs#build", "ui#build", "web#build", "c#lint", "a#lint", "b#lint", "web#test"})
+}
+
+func TestExecutePrioritizedSkipsDependentsOfFailures(t *testing.T) {
+	engine := newPrioritizedEngine(map[string]*fs.TaskDefinition{})
+	durations := map[string]time.Duration{"ui#build": time.Minute}
+
+	var mu sync.Mutex
+	var visited []string
+	errs := engine.Execute(func(taskID string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		visited = append(visited, taskID)
+		if taskID == "ui#build" {
+			return errors.New("build failed")
+		}
+		return nil
+	}, EngineExecutionOptions{Concurrency: 2, TaskDurations: durations})
+	assert.Equal(t, len(errs), 1)
+	assert.Equal(t, len(visited), 5)
+	for _, taskID := range visited {
+		assert.Assert(t, taskID != "web#build" && taskID != "web#test", "ran %v after ui#build failed", taskID)
+	}
+}
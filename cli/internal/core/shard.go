@@ -0,0 +1,217 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// _defaultShardTaskDuration is how long a task is assumed to take when no task
+// in the graph has a duration from a previous run
+const _defaultShardTaskDuration = time.Second
+
+// Shard is one of the parts of a run that is split across several machines,
+// counting from 1, as in --shard=2/5
+type Shard struct {
+	Index int
+	Total int
+}
+
+// ParseShard parses a shard written as <index>/<total>
+func ParseShard(value string) (Shard, error) {
+	index, total, ok := strings.Cut(value, "/")
+	if !ok {
+		return Shard{}, fmt.Errorf("%q should be written as <index>/<total>, such as 2/5", value)
+	}
+	shard := Shard{}
+	var err error
+	if shard.Index, err = strconv.Atoi(strings.TrimSpace(index)); err != nil {
+		return Shard{}, fmt.Errorf("%q has an invalid index: %w", value, err)
+	}
+	if shard.Total, err = strconv.Atoi(strings.TrimSpace(total)); err != nil {
+		return Shard{}, fmt.Errorf("%q has an invalid total: %w", value, err)
+	}
+	if shard.Total < 1 || shard.Index < 1 || shard.Index > shard.Total {
+		return Shard{}, fmt.Errorf("%q should have an index between 1 and the total", value)
+	}
+	return shard, nil
+}
+
+func (s Shard) String() string {
+	return fmt.Sprintf("%v/%v", s.Index, s.Total)
+}
+
+// ShardTasks removes every task from the graph that doesn't belong to shard,
+// and returns the tasks that were removed, sorted.
+//
+// The independent subgraphs of the graph are split between the shards, by how
+// long their tasks took the last time they were executed. A subgraph that
+// would take longer than its share of a shard is split further by the tasks
+// that no other task depends on, and each shard runs those tasks along with
+// all of their dependencies. The dependencies that end up in several shards
+// are restored from the remote cache by whichever shard gets to them last.
+// The split only depends on the graph and the durations, so every shard of a
+// run computes the same one.
+func (e *Engine) ShardTasks(shard Shard, durations map[string]time.Duration) ([]string, error) {
+	var entryTaskIDs []string
+	for _, v := range e.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if strings.Contains(taskID, ROOT_NODE_NAME) {
+			continue
+		}
+		if e.TaskGraph.UpEdges(taskID).Len() == 0 {
+			entryTaskIDs = append(entryTaskIDs, taskID)
+		}
+	}
+	sort.Strings(entryTaskIDs)
+
+	duration := shardTaskDuration(durations)
+	cost := func(taskIDs util.Set) time.Duration {
+		var total time.Duration
+		for _, taskID := range taskIDs.UnsafeListOfStrings() {
+			total += duration(taskID)
+		}
+		return total
+	}
+
+	// Entry tasks that share a dependency are in the same subgraph
+	closures := make(map[string]util.Set, len(entryTaskIDs))
+	subgraphs := make(map[string]string, len(entryTaskIDs))
+	var subgraphOf func(taskID string) string
+	subgraphOf = func(taskID string) string {
+		if subgraphs[taskID] == taskID {
+			return taskID
+		}
+		subgraphs[taskID] = subgraphOf(subgraphs[taskID])
+		return subgraphs[taskID]
+	}
+	entryOf := make(map[string]string)
+	allTaskIDs := make(util.Set)
+	for _, taskID := range entryTaskIDs {
+		closure, err := e.taskClosure(taskID)
+		if err != nil {
+			return nil, err
+		}
+		closures[taskID] = closure
+		subgraphs[taskID] = taskID
+		for _, dependency := range closure.UnsafeListOfStrings() {
+			allTaskIDs.Add(dependency)
+			if other, ok := entryOf[dependency]; ok {
+				subgraphs[subgraphOf(taskID)] = subgraphOf(other)
+			} else {
+				entryOf[dependency] = taskID
+			}
+		}
+	}
+	share := cost(allTaskIDs) / time.Duration(shard.Total)
+
+	type unit struct {
+		name    string
+		taskIDs util.Set
+		cost    time.Duration
+	}
+	subgraphTaskIDs := make(map[string]util.Set)
+	for _, taskID := range entryTaskIDs {
+		root := subgraphOf(taskID)
+		if _, ok := subgraphTaskIDs[root]; !ok {
+			subgraphTaskIDs[root] = make(util.Set)
+		}
+		for _, dependency := range closures[taskID].UnsafeListOfStrings() {
+			subgraphTaskIDs[root].Add(dependency)
+		}
+	}
+	var units []unit
+	for _, taskID := range entryTaskIDs {
+		root := subgraphOf(taskID)
+		if subgraphCost := cost(subgraphTaskIDs[root]); subgraphCost <= share {
+			if root == taskID {
+				units = append(units, unit{name: taskID, taskIDs: subgraphTaskIDs[root], cost: subgraphCost})
+			}
+			continue
+		}
+		units = append(units, unit{name: taskID, taskIDs: closures[taskID], cost: cost(closures[taskID])})
+	}
+	// The most expensive units are placed first, while the shards are the
+	// most even, with ties broken by name so that the order is stable
+	sort.Slice(units, func(i, j int) bool {
+		if units[i].cost != units[j].cost {
+			return units[i].cost > units[j].cost
+		}
+		return units[i].name < units[j].name
+	})
+
+	shardTaskIDs := make([]util.Set, shard.Total)
+	loads := make([]time.Duration, shard.Total)
+	for i := range shardTaskIDs {
+		shardTaskIDs[i] = make(util.Set)
+	}
+	for _, u := range units {
+		best := 0
+		var bestLoad time.Duration
+		for i, taskIDs := range shardTaskIDs {
+			load := loads[i] + cost(u.taskIDs.Difference(taskIDs))
+			if i == 0 || load < bestLoad {
+				best, bestLoad = i, load
+			}
+		}
+		loads[best] = bestLoad
+		for _, taskID := range u.taskIDs.UnsafeListOfStrings() {
+			shardTaskIDs[best].Add(taskID)
+		}
+	}
+
+	keep := shardTaskIDs[shard.Index-1]
+	var removed []string
+	for _, v := range e.TaskGraph.Vertices() {
+		taskID := dag.VertexName(v)
+		if strings.Contains(taskID, ROOT_NODE_NAME) || keep.Includes(taskID) {
+			continue
+		}
+		e.TaskGraph.Remove(v)
+		removed = append(removed, taskID)
+	}
+	sort.Strings(removed)
+	return removed, nil
+}
+
+// taskClosure returns taskID along with every task that it depends on,
+// directly or not, other than the root node
+func (e *Engine) taskClosure(taskID string) (util.Set, error) {
+	closure := make(util.Set)
+	closure.Add(taskID)
+	ancestors, err := e.TaskGraph.Ancestors(taskID)
+	if err != nil {
+		return nil, err
+	}
+	for _, ancestor := range ancestors {
+		if dependency := dag.VertexName(ancestor); !strings.Contains(dependency, ROOT_NODE_NAME) {
+			closure.Add(dependency)
+		}
+	}
+	return closure, nil
+}
+
+// shardTaskDuration returns how long each task is expected to take. Tasks
+// without a duration from a previous run are expected to take as long as the
+// average task that has one.
+func shardTaskDuration(durations map[string]time.Duration) func(taskID string) time.Duration {
+	fallback := _defaultShardTaskDuration
+	if len(durations) > 0 {
+		var total time.Duration
+		for _, duration := range durations {
+			total += duration
+		}
+		fallback = total / time.Duration(len(durations))
+	}
+	return func(taskID string) time.Duration {
+		if duration, ok := durations[taskID]; ok {
+			return duration
+		}
+		return fallback
+	}
+}
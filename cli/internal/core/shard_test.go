@@ -0,0 +1,68 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pyr-sh/dag"
+	"gotest.tools/v3/assert"
+)
+
+func TestParseShard(t *testing.T) {
+	shard, err := ParseShard("2/5")
+	assert.NilError(t, err)
+	assert.Equal(t, shard, Shard{Index: 2, Total: 5})
+	assert.Equal(t, shard.String(), "2/5")
+
+	for value, want := range map[string]string{
+		"2":   "should be written as <index>/<total>",
+		"a/5": "invalid index",
+		"1/b": "invalid total",
+		"0/5": "between 1 and the total",
+		"6/5": "between 1 and the total",
+	} {
+		_, err := ParseShard(value)
+		assert.ErrorContains(t, err, want, value)
+	}
+}
+
+func TestShardTasks(t *testing.T) {
+	durations := map[string]time.Duration{
+		"a#lint":     time.Second,
+		"b#lint":     time.Second,
+		"c#lint":     time.Second,
+		"ui#build":   10 * time.Second,
+		"web#build":  time.Minute,
+		"web#test":   20 * time.Second,
+		"docs#build": 30 * time.Second,
+	}
+
+	first := newPrioritizedEngine(nil)
+	removed, err := first.ShardTasks(Shard{Index: 1, Total: 2}, durations)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, removed, []string{"a#lint", "b#lint", "c#lint", "docs#build"})
+
+	second := newPrioritizedEngine(nil)
+	removed, err = second.ShardTasks(Shard{Index: 2, Total: 2}, durations)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, removed, []string{"ui#build", "web#build", "web#test"})
+	assert.Assert(t, second.TaskGraph.HasVertex(ROOT_NODE_NAME))
+	assert.Equal(t, len(second.TaskGraph.DownEdges("docs#build").List()), 1)
+
+	// Shards without any tasks to run keep only the root node
+	last := newPrioritizedEngine(nil)
+	_, err = last.ShardTasks(Shard{Index: 6, Total: 6}, durations)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, last.TaskGraph.Vertices(), []dag.Vertex{ROOT_NODE_NAME})
+}
+
+func TestShardTasksSharedDependencies(t *testing.T) {
+	// Without durations, web#test and web#lint share web#build and ui#build,
+	// so they are cheaper to run together than to split up
+	engine := newPrioritizedEngine(nil)
+	engine.TaskGraph.Add("web#lint")
+	engine.TaskGraph.Connect(dag.BasicEdge("web#lint", "web#build"))
+	removed, err := engine.ShardTasks(Shard{Index: 1, Total: 2}, nil)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, removed, []string{"a#lint", "b#lint", "c#lint", "docs#build"})
+}
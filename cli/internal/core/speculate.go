@@ -0,0 +1,124 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrUpstreamFailed is returned by Speculation.Wait when a dependency of a task
+// that was started early failed, so that the task would have been skipped
+var ErrUpstreamFailed = errors.New("a dependency failed")
+
+// ErrSpeculationStale is returned by a visitor for a task that was started
+// early, but can't keep its results, for example because a dependency changed
+// the files that it read. The task is visited again once a slot is free.
+var ErrSpeculationStale = errors.New("the task was started with stale dependencies")
+
+// Speculation starts tasks before their dependencies have finished, when
+// concurrency slots would otherwise be idle. It is meant for tasks that are
+// likely to miss the cache, and whose dependencies are likely to be restored
+// from it without changing any files.
+//
+// A visitor runs a task that was started early as usual, then calls Wait
+// before it keeps any of its results, such as saving them to the cache. Wait
+// frees the task's slot while the dependencies finish.
+type Speculation struct {
+	tasks   map[string]bool
+	waiting chan string
+
+	mu      sync.Mutex
+	started map[string]*speculativeTask
+}
+
+type speculativeTask struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	settled chan error
+}
+
+// NewSpeculation returns a Speculation that can start the given tasks early
+func NewSpeculation(taskIDs []string) *Speculation {
+	tasks := make(map[string]bool, len(taskIDs))
+	for _, taskID := range taskIDs {
+		tasks[taskID] = true
+	}
+	return &Speculation{
+		tasks:   tasks,
+		waiting: make(chan string),
+		started: make(map[string]*speculativeTask),
+	}
+}
+
+// Context returns the context for running taskID. It is canceled once a
+// dependency of the task fails, if the task was started early.
+func (s *Speculation) Context(taskID string) context.Context {
+	if s == nil {
+		return context.Background()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if task, ok := s.started[taskID]; ok {
+		return task.ctx
+	}
+	return context.Background()
+}
+
+// StartedEarly is whether taskID was started before its dependencies finished
+func (s *Speculation) StartedEarly(taskID string) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.started[taskID]
+	return ok
+}
+
+// Wait returns once the dependencies of taskID have finished, with
+// ErrUpstreamFailed if one of them failed. It returns immediately for tasks
+// that weren't started early.
+func (s *Speculation) Wait(taskID string) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	task, ok := s.started[taskID]
+	s.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	s.waiting <- taskID
+	return <-task.settled
+}
+
+// start records that taskID is started before its dependencies have finished
+func (s *Speculation) start(taskID string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.started[taskID] = &speculativeTask{ctx: ctx, cancel: cancel, settled: make(chan error, 1)}
+}
+
+// settle unblocks Wait for a task that was started early. A failed
+// dependency also cancels the task's context.
+func (s *Speculation) settle(taskID string, err error) {
+	s.mu.Lock()
+	task := s.started[taskID]
+	s.mu.Unlock()
+	if err != nil {
+		task.cancel()
+	}
+	task.settled <- err
+}
+
+// restart forgets that taskID was started early, so that it is visited again
+// like any other task
+func (s *Speculation) restart(taskID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if task, ok := s.started[taskID]; ok {
+		task.cancel()
+		delete(s.started, taskID)
+	}
+}
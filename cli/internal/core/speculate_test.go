@@ -0,0 +1,92 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+// speculativeVisitor visits the tasks of newPrioritizedEngine. ui#build only
+// finishes, with buildErr, once web#build was started early, and web#build
+// waits for it with speculation and returns webBuild's result.
+func speculativeVisitor(t *testing.T, speculation *Speculation, buildErr error, webBuild func(waitErr error) error) (Visitor, func() []string) {
+	var mu sync.Mutex
+	var visited []string
+	webBuildStarted := make(chan struct{})
+	var once sync.Once
+	visitor := func(taskID string) error {
+		mu.Lock()
+		visited = append(visited, taskID)
+		mu.Unlock()
+		switch taskID {
+		case "ui#build":
+			select {
+			case <-webBuildStarted:
+			case <-time.After(5 * time.Second):
+				t.Error("web#build wasn't started early")
+			}
+			return buildErr
+		case "web#build":
+			once.Do(func() { close(webBuildStarted) })
+			return webBuild(speculation.Wait(taskID))
+		}
+		return nil
+	}
+	return visitor, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return visited
+	}
+}
+
+func TestSpeculationStartsTasksEarly(t *testing.T) {
+	engine := newPrioritizedEngine(map[string]*fs.TaskDefinition{})
+	speculation := NewSpeculation([]string{"web#build"})
+	visitor, visited := speculativeVisitor(t, speculation, nil, func(waitErr error) error {
+		return waitErr
+	})
+
+	errs := engine.Execute(visitor, EngineExecutionOptions{Concurrency: 2, Speculation: speculation})
+	assert.Equal(t, len(errs), 0)
+	assert.Equal(t, len(visited()), 7)
+	assert.Equal(t, visited()[6], "web#test", "web#test waits for web#build")
+}
+
+func TestSpeculationUpstreamFailed(t *testing.T) {
+	engine := newPrioritizedEngine(map[string]*fs.TaskDefinition{})
+	speculation := NewSpeculation([]string{"web#build"})
+	visitor, visited := speculativeVisitor(t, speculation, errors.New("build failed"), func(waitErr error) error {
+		assert.ErrorIs(t, waitErr, ErrUpstreamFailed)
+		assert.ErrorIs(t, speculation.Context("web#build").Err(), context.Canceled)
+		return waitErr
+	})
+
+	errs := engine.Execute(visitor, EngineExecutionOptions{Concurrency: 2, Speculation: speculation})
+	assert.Equal(t, len(errs), 1, "only the failure of ui#build is reported")
+	for _, taskID := range visited() {
+		assert.Assert(t, taskID != "web#test", "ran web#test after ui#build failed")
+	}
+}
+
+func TestSpeculationStale(t *testing.T) {
+	engine := newPrioritizedEngine(map[string]*fs.TaskDefinition{})
+	speculation := NewSpeculation([]string{"web#build"})
+	attempts := 0
+	visitor, visited := speculativeVisitor(t, speculation, nil, func(waitErr error) error {
+		attempts++
+		if attempts == 1 {
+			return ErrSpeculationStale
+		}
+		return waitErr
+	})
+
+	errs := engine.Execute(visitor, EngineExecutionOptions{Concurrency: 2, Speculation: speculation})
+	assert.Equal(t, len(errs), 0)
+	assert.Equal(t, attempts, 2, "web#build is visited again")
+	assert.Equal(t, len(visited()), 8)
+}
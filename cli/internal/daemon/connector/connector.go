@@ -38,8 +38,10 @@ var (
 // it needs to be started.
 type Opts struct {
 	ServerTimeout time.Duration
-	DontStart     bool // if true, don't attempt to start the daemon
-	DontKill      bool // if true, don't attempt to kill the daemon
+	DontStart     bool   // if true, don't attempt to start the daemon
+	DontKill      bool   // if true, don't attempt to kill the daemon
+	Watcher       string // passed to a daemon that is started as --watcher
+	PollInterval  string // passed to a daemon that is started as --poll-interval
 }
 
 // Client represents a connection to the daemon process
@@ -378,6 +380,12 @@ func (c *Connector) startDaemon() (int, error) {
 	if c.Opts.ServerTimeout != 0 {
 		args = append(args, fmt.Sprintf("--idle-time=%v", c.Opts.ServerTimeout.String()))
 	}
+	if c.Opts.Watcher != "" {
+		args = append(args, fmt.Sprintf("--watcher=%v", c.Opts.Watcher))
+	}
+	if c.Opts.PollInterval != "" {
+		args = append(args, fmt.Sprintf("--poll-interval=%v", c.Opts.PollInterval))
+	}
 	c.Logger.Debug(fmt.Sprintf("starting turbod binary %v", c.Bin))
 	cmd := exec.Command(c.Bin, args...)
 	// For the daemon to have its own process group id so that any attempts
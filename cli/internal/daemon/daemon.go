@@ -7,18 +7,23 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	grpc_recovery "github.com/grpc-ecosystem/go-grpc-middleware/recovery"
 	"github.com/hashicorp/go-hclog"
 	"github.com/nightlyone/lockfile"
 	"github.com/pkg/errors"
+	"github.com/vercel/turbo/cli/internal/cache"
 	"github.com/vercel/turbo/cli/internal/cmdutil"
 	"github.com/vercel/turbo/cli/internal/daemon/connector"
+	"github.com/vercel/turbo/cli/internal/filewatcher"
 	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/runsummary"
 	"github.com/vercel/turbo/cli/internal/server"
 	"github.com/vercel/turbo/cli/internal/signals"
 	"github.com/vercel/turbo/cli/internal/turbopath"
@@ -34,6 +39,9 @@ type daemon struct {
 	timeout    time.Duration
 	reqCh      chan struct{}
 	timedOutCh chan struct{}
+	// metricsAddr is where /metrics is served, if set
+	metricsAddr    string
+	metricsHandler http.Handler
 }
 
 func getRepoHash(repoRoot turbopath.AbsoluteSystemPath) string {
@@ -77,8 +85,12 @@ func (d *daemon) logError(err error) {
 // we do not need to read the log file.
 var _logFileFlags = os.O_WRONLY | os.O_APPEND | os.O_CREATE
 
+// TaskExplainer explains tasks for the ExplainTask rpc. It is run.ExplainTask,
+// which is passed in since the run package depends on this one.
+type TaskExplainer func(ctx context.Context, base *cmdutil.CmdBase, signalWatcher *signals.Watcher, args *turbostate.ParsedArgsFromRust, taskID string, maxRuns int) (*runsummary.TaskExplanation, error)
+
 // ExecuteDaemon executes the root daemon command
-func ExecuteDaemon(ctx context.Context, helper *cmdutil.Helper, signalWatcher *signals.Watcher, args *turbostate.ParsedArgsFromRust) error {
+func ExecuteDaemon(ctx context.Context, helper *cmdutil.Helper, signalWatcher *signals.Watcher, args *turbostate.ParsedArgsFromRust, explainTask TaskExplainer) error {
 	if args.Command.Daemon.Command != "" {
 		var subcommandError error
 		if args.Command.Daemon.Command == "Status" {
@@ -126,20 +138,40 @@ func ExecuteDaemon(ctx context.Context, helper *cmdutil.Helper, signalWatcher *s
 		Name:   "turbod",
 	})
 
+	// The daemon is usually started by a run, so the address can also come
+	// from the environment of that run
+	metricsAddr := args.Command.Daemon.MetricsAddr
+	if metricsAddr == "" {
+		metricsAddr = os.Getenv("TURBO_DAEMON_METRICS_ADDR")
+	}
+
+	watcherOpts, err := getWatcherOpts(args.Command.Daemon)
+	if err != nil {
+		return err
+	}
+
 	d := &daemon{
-		logger:     logger,
-		repoRoot:   base.RepoRoot,
-		timeout:    idleTimeout,
-		reqCh:      make(chan struct{}),
-		timedOutCh: make(chan struct{}),
+		logger:      logger,
+		repoRoot:    base.RepoRoot,
+		timeout:     idleTimeout,
+		reqCh:       make(chan struct{}),
+		timedOutCh:  make(chan struct{}),
+		metricsAddr: metricsAddr,
 	}
 	serverName := getRepoHash(base.RepoRoot)
-	turboServer, err := server.New(serverName, d.logger.Named("rpc server"), base.RepoRoot, base.TurboVersion, logFilePath)
+	// The daemon is shared by every run in the repository, so it can only know
+	// about a cache directory set with TURBO_CACHE_DIR, not with --cache-dir
+	cacheOpts := &cache.Opts{}
+	turboServer, err := server.New(serverName, d.logger.Named("rpc server"), base.RepoRoot, base.TurboVersion, logFilePath, cacheOpts.ResolveCacheDir(base.RepoRoot), watcherOpts)
 	if err != nil {
 		d.logError(err)
 		return err
 	}
 	defer func() { _ = turboServer.Close() }()
+	turboServer.SetTaskExplainer(func(ctx context.Context, taskID string, maxRuns int) (*runsummary.TaskExplanation, error) {
+		return explainTask(ctx, base, signalWatcher, args, taskID, maxRuns)
+	})
+	d.metricsHandler = turboServer.Metrics()
 	err = d.runTurboServer(ctx, turboServer, signalWatcher)
 	if err != nil {
 		d.logError(err)
@@ -148,6 +180,31 @@ func ExecuteDaemon(ctx context.Context, helper *cmdutil.Helper, signalWatcher *s
 	return nil
 }
 
+// getWatcherOpts returns how the daemon watches files. Like the metrics address,
+// they can also come from the environment of the run that starts the daemon.
+func getWatcherOpts(daemonPayload *turbostate.DaemonPayload) (filewatcher.BackendOpts, error) {
+	watcher := daemonPayload.Watcher
+	if watcher == "" {
+		watcher = os.Getenv("TURBO_DAEMON_WATCHER")
+	}
+	pollInterval := daemonPayload.PollInterval
+	if pollInterval == "" {
+		pollInterval = os.Getenv("TURBO_DAEMON_POLL_INTERVAL")
+	}
+	opts := filewatcher.BackendOpts{Watcher: watcher}
+	if pollInterval != "" {
+		interval, err := time.ParseDuration(pollInterval)
+		if err != nil {
+			return opts, errors.Wrap(err, "invalid poll interval")
+		}
+		if interval <= 0 {
+			return opts, fmt.Errorf("invalid poll interval %v, it must be positive", pollInterval)
+		}
+		opts.PollInterval = interval
+	}
+	return opts, nil
+}
+
 var errInactivityTimeout = errors.New("turbod shut down from inactivity")
 
 // tryAcquirePidfileLock attempts to ensure that only one daemon is running from the given pid file path
@@ -198,6 +255,14 @@ func (d *daemon) runTurboServer(parentContext context.Context, rpcServer rpcServ
 		return status.Error(codes.Internal, "server panicked")
 	}
 
+	if d.metricsAddr != "" {
+		metricsServer, err := d.serveMetrics()
+		if err != nil {
+			return errors.Wrapf(err, "failed to serve metrics at %v", d.metricsAddr)
+		}
+		defer func() { _ = metricsServer.Close() }()
+	}
+
 	// If we have the lock, assume that we are the owners of the socket file,
 	// whether it already exists or not. That means we are free to remove it.
 	sockPath := getUnixSocket(d.repoRoot)
@@ -209,12 +274,20 @@ func (d *daemon) runTurboServer(parentContext context.Context, rpcServer rpcServ
 	if err != nil {
 		return err
 	}
+	// Stopping gracefully waits for every stream to end, so streams are ended
+	// first
+	streamsCtx, endStreams := context.WithCancel(ctx)
+	defer endStreams()
 	// We don't need to explicitly close 'lis', the grpc server will handle that
 	s := grpc.NewServer(
 		grpc.ChainUnaryInterceptor(
 			d.onRequest,
 			grpc_recovery.UnaryServerInterceptor(grpc_recovery.WithRecoveryHandler(panicHandler)),
 		),
+		grpc.ChainStreamInterceptor(
+			d.onStream(streamsCtx),
+			grpc_recovery.StreamServerInterceptor(grpc_recovery.WithRecoveryHandler(panicHandler)),
+		),
 	)
 	go d.timeoutLoop(ctx)
 
@@ -242,12 +315,14 @@ func (d *daemon) runTurboServer(parentContext context.Context, rpcServer rpcServ
 	case <-d.timedOutCh:
 		// This is the inactivity timeout case
 		exitErr = errInactivityTimeout
+		endStreams()
 		s.GracefulStop()
 	case <-ctx.Done():
 		// If a request handler panics, it will cancel this context
 		s.GracefulStop()
 	case <-signalWatcher.Done():
 		// This is fired if caught a signal
+		endStreams()
 		s.GracefulStop()
 	}
 	// Wait for the server to exit, if it hasn't already.
@@ -260,11 +335,50 @@ func (d *daemon) runTurboServer(parentContext context.Context, rpcServer rpcServ
 	return exitErr
 }
 
+// serveMetrics serves the daemon's metrics at /metrics on metricsAddr until
+// the returned server is closed
+func (d *daemon) serveMetrics() (*http.Server, error) {
+	lis, err := net.Listen("tcp", d.metricsAddr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", d.metricsHandler)
+	metricsServer := &http.Server{Handler: mux}
+	go func() {
+		if err := metricsServer.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			d.logError(errors.Wrap(err, "metrics server"))
+		}
+	}()
+	d.logger.Info(fmt.Sprintf("serving metrics at http://%v/metrics", lis.Addr()))
+	return metricsServer, nil
+}
+
 func (d *daemon) onRequest(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
 	d.reqCh <- struct{}{}
 	return handler(ctx, req)
 }
 
+// onStream counts the start of a stream as a request, and ends the stream
+// when streamsCtx is done
+func (d *daemon) onStream(streamsCtx context.Context) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		d.reqCh <- struct{}{}
+		ctx, cancel := context.WithCancel(ss.Context())
+		defer cancel()
+		go func() {
+			select {
+			case <-streamsCtx.Done():
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		wrapped := grpc_middleware.WrapServerStream(ss)
+		wrapped.WrappedContext = ctx
+		return handler(srv, wrapped)
+	}
+}
+
 func (d *daemon) timeoutLoop(ctx context.Context) {
 	timeoutCh := time.After(d.timeout)
 outer:
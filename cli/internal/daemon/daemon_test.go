@@ -12,10 +12,12 @@ import (
 
 	"github.com/hashicorp/go-hclog"
 	"github.com/nightlyone/lockfile"
+	"github.com/vercel/turbo/cli/internal/filewatcher"
 	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/server"
 	"github.com/vercel/turbo/cli/internal/signals"
 	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/turbostate"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/test/grpc_testing"
@@ -260,3 +262,29 @@ func TestCleanupOnPanic(t *testing.T) {
 		t.Errorf("expected to clean up %v, but it still exists", pidPath)
 	}
 }
+
+func TestGetWatcherOpts(t *testing.T) {
+	opts, err := getWatcherOpts(&turbostate.DaemonPayload{})
+	assert.NilError(t, err, "getWatcherOpts")
+	assert.DeepEqual(t, opts, filewatcher.BackendOpts{})
+
+	opts, err = getWatcherOpts(&turbostate.DaemonPayload{Watcher: "poll", PollInterval: "250ms"})
+	assert.NilError(t, err, "getWatcherOpts")
+	assert.DeepEqual(t, opts, filewatcher.BackendOpts{Watcher: "poll", PollInterval: 250 * time.Millisecond})
+
+	t.Setenv("TURBO_DAEMON_WATCHER", "poll")
+	t.Setenv("TURBO_DAEMON_POLL_INTERVAL", "2s")
+	opts, err = getWatcherOpts(&turbostate.DaemonPayload{})
+	assert.NilError(t, err, "getWatcherOpts")
+	assert.DeepEqual(t, opts, filewatcher.BackendOpts{Watcher: "poll", PollInterval: 2 * time.Second})
+
+	// Flags take precedence over the environment
+	opts, err = getWatcherOpts(&turbostate.DaemonPayload{Watcher: "native"})
+	assert.NilError(t, err, "getWatcherOpts")
+	assert.DeepEqual(t, opts, filewatcher.BackendOpts{Watcher: "native", PollInterval: 2 * time.Second})
+
+	_, err = getWatcherOpts(&turbostate.DaemonPayload{PollInterval: "0s"})
+	assert.ErrorContains(t, err, "invalid poll interval")
+	_, err = getWatcherOpts(&turbostate.DaemonPayload{PollInterval: "soon"})
+	assert.ErrorContains(t, err, "invalid poll interval")
+}
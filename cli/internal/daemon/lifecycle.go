@@ -19,7 +19,9 @@ func RunLifecycle(ctx context.Context, helper *cmdutil.Helper, args *turbostate.
 		return err
 	}
 	l := &lifecycle{
-		base,
+		base:         base,
+		watcher:      args.Command.Daemon.Watcher,
+		pollInterval: args.Command.Daemon.PollInterval,
 	}
 
 	if args.Command.Daemon.Command == "Restart" {
@@ -48,6 +50,10 @@ func RunLifecycle(ctx context.Context, helper *cmdutil.Helper, args *turbostate.
 
 type lifecycle struct {
 	base *cmdutil.CmdBase
+	// watcher and pollInterval are passed to a daemon that is started, as
+	// `turbo daemon --watcher=poll restart` does
+	watcher      string
+	pollInterval string
 }
 
 // logError logs an error and outputs it to the UI.
@@ -57,7 +63,10 @@ func (l *lifecycle) logError(err error) {
 }
 
 func (l *lifecycle) ensureStarted(ctx context.Context) error {
-	client, err := GetClient(ctx, l.base.RepoRoot, l.base.Logger, l.base.TurboVersion, ClientOpts{})
+	client, err := GetClient(ctx, l.base.RepoRoot, l.base.Logger, l.base.TurboVersion, ClientOpts{
+		Watcher:      l.watcher,
+		PollInterval: l.pollInterval,
+	})
 	if err != nil {
 		return err
 	}
@@ -20,7 +20,7 @@ func RunStatus(ctx context.Context, helper *cmdutil.Helper, args *turbostate.Par
 		return err
 	}
 	l := &lifecycle{
-		base,
+		base: base,
 	}
 	if err := l.status(ctx, args.Command.Daemon.JSON); err != nil {
 		l.logError(err)
@@ -47,6 +47,13 @@ func (l *lifecycle) status(ctx context.Context, outputJSON bool) error {
 		return l.reportStatusError(err, outputJSON)
 	}
 	if outputJSON {
+		// Tools such as editors read the hashes to tell which packages changed.
+		// A daemon that can't hash the packages still has a status.
+		packages, err := turboClient.PackageHashes(ctx, nil)
+		if err != nil {
+			l.base.Logger.Debug("failed to get package hashes", "error", err)
+		}
+		status.Packages = packages
 		rendered, err := json.MarshalIndent(status, "", "  ")
 		if err != nil {
 			return err
@@ -4,9 +4,14 @@ package daemonclient
 
 import (
 	"context"
+	"time"
 
+	"github.com/vercel/turbo/cli/internal/cache"
+	turbocontext "github.com/vercel/turbo/cli/internal/context"
 	"github.com/vercel/turbo/cli/internal/daemon/connector"
 	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/lockfile"
+	"github.com/vercel/turbo/cli/internal/runsummary"
 	"github.com/vercel/turbo/cli/internal/turbodprotocol"
 	"github.com/vercel/turbo/cli/internal/turbopath"
 )
@@ -22,6 +27,15 @@ type Status struct {
 	LogFile  turbopath.AbsoluteSystemPath `json:"logFile"`
 	PidFile  turbopath.AbsoluteSystemPath `json:"pidFile"`
 	SockFile turbopath.AbsoluteSystemPath `json:"sockFile"`
+	// Packages are only included in the JSON status
+	Packages []*PackageHash `json:"packages,omitempty"`
+}
+
+// PackageHash is the hash of the files of a package, as the daemon last saw them
+type PackageHash struct {
+	Package string `json:"package"`
+	Path    string `json:"path"`
+	Hash    string `json:"hash"`
 }
 
 // New creates a new instance of a DaemonClient.
@@ -54,6 +68,19 @@ func (d *DaemonClient) NotifyOutputsWritten(ctx context.Context, hash string, re
 	return err
 }
 
+// NotifyRunFinished reports the outcome of a run, to be counted in the
+// daemon's metrics
+func (d *DaemonClient) NotifyRunFinished(ctx context.Context, cacheHits int, cacheMisses int, failed bool, duration time.Duration, hashDuration time.Duration) error {
+	_, err := d.client.NotifyRunFinished(ctx, &turbodprotocol.NotifyRunFinishedRequest{
+		CacheHits:        uint32(cacheHits),
+		CacheMisses:      uint32(cacheMisses),
+		Failed:           failed,
+		DurationMsec:     uint64(duration.Milliseconds()),
+		HashDurationMsec: uint64(hashDuration.Milliseconds()),
+	})
+	return err
+}
+
 // Status returns the DaemonStatus from the daemon
 func (d *DaemonClient) Status(ctx context.Context) (*Status, error) {
 	resp, err := d.client.Status(ctx, &turbodprotocol.StatusRequest{})
@@ -68,3 +95,128 @@ func (d *DaemonClient) Status(ctx context.Context) (*Status, error) {
 		SockFile: d.client.SockPath,
 	}, nil
 }
+
+// PackageHashes returns the current hashes of the given packages, or of every
+// package if none are given
+func (d *DaemonClient) PackageHashes(ctx context.Context, packages []string) ([]*PackageHash, error) {
+	resp, err := d.client.GetPackageHashes(ctx, &turbodprotocol.GetPackageHashesRequest{
+		Packages: packages,
+	})
+	if err != nil {
+		return nil, err
+	}
+	packageHashes := make([]*PackageHash, 0, len(resp.PackageHashes))
+	for _, packageHash := range resp.PackageHashes {
+		packageHashes = append(packageHashes, &PackageHash{
+			Package: packageHash.Package,
+			Path:    packageHash.Path,
+			Hash:    packageHash.Hash,
+		})
+	}
+	return packageHashes, nil
+}
+
+// PackageGraph returns the package graph resolved by the daemon, see
+// turbocontext.BuildPackageGraphFromResolution
+func (d *DaemonClient) PackageGraph(ctx context.Context) (*turbocontext.Resolution, error) {
+	resp, err := d.client.GetPackageGraph(ctx, &turbodprotocol.GetPackageGraphRequest{})
+	if err != nil {
+		return nil, err
+	}
+	resolution := &turbocontext.Resolution{
+		Fingerprint: resp.Fingerprint,
+		Inputs:      make([]turbopath.AnchoredUnixPath, 0, len(resp.Inputs)),
+		HasLockfile: resp.HasLockfile,
+		Workspaces:  make([]*turbocontext.WorkspaceResolution, 0, len(resp.Workspaces)),
+	}
+	for _, input := range resp.Inputs {
+		resolution.Inputs = append(resolution.Inputs, turbopath.AnchoredUnixPath(input))
+	}
+	for _, workspace := range resp.Workspaces {
+		transitiveDeps := make([]lockfile.Package, 0, len(workspace.TransitiveDeps))
+		for _, dep := range workspace.TransitiveDeps {
+			transitiveDeps = append(transitiveDeps, lockfile.Package{
+				Key:     dep.Key,
+				Version: dep.Version,
+				Found:   dep.Found,
+			})
+		}
+		internalDeps := workspace.InternalDeps
+		if internalDeps == nil {
+			internalDeps = []string{}
+		}
+		resolution.Workspaces = append(resolution.Workspaces, &turbocontext.WorkspaceResolution{
+			Name:             workspace.Name,
+			PackageJSONPath:  turbopath.AnchoredUnixPath(workspace.PackageJsonPath),
+			InternalDeps:     internalDeps,
+			TransitiveDeps:   transitiveDeps,
+			ExternalDepsHash: workspace.ExternalDepsHash,
+		})
+	}
+	return resolution, nil
+}
+
+// InvalidatePackageGraph makes the daemon resolve the package graph again, for
+// tools that change the workspaces in ways the daemon doesn't watch
+func (d *DaemonClient) InvalidatePackageGraph(ctx context.Context) error {
+	_, err := d.client.InvalidatePackageGraph(ctx, &turbodprotocol.InvalidatePackageGraphRequest{})
+	return err
+}
+
+// GlobalFileHashes returns the hashes of the files that match the
+// globalDependencies globs, other than the ignored ones, along with the given
+// files, which are relative to the repository root
+func (d *DaemonClient) GlobalFileHashes(ctx context.Context, globs []string, ignores []string, files []string) (map[turbopath.AnchoredUnixPath]string, error) {
+	resp, err := d.client.GetGlobalFileHashes(ctx, &turbodprotocol.GetGlobalFileHashesRequest{
+		Globs:   globs,
+		Ignores: ignores,
+		Files:   files,
+	})
+	if err != nil {
+		return nil, err
+	}
+	fileHashes := make(map[turbopath.AnchoredUnixPath]string, len(resp.FileHashes))
+	for file, hash := range resp.FileHashes {
+		fileHashes[turbopath.AnchoredUnixPath(file)] = hash
+	}
+	return fileHashes, nil
+}
+
+// ExplainTask asks the daemon whether a task, given as <package>#<task>, would
+// be restored from the cache, and what changed since its last run in the most
+// recent maxRuns run summaries. The daemon hashes the task with its own
+// environment variables.
+func (d *DaemonClient) ExplainTask(ctx context.Context, taskID string, maxRuns int) (*runsummary.TaskExplanation, error) {
+	resp, err := d.client.ExplainTask(ctx, &turbodprotocol.ExplainTaskRequest{
+		TaskId: taskID,
+		Runs:   uint32(maxRuns),
+	})
+	if err != nil {
+		return nil, err
+	}
+	explanation := &runsummary.TaskExplanation{
+		TaskID:              resp.TaskId,
+		Hash:                resp.Hash,
+		CacheState:          cache.ItemStatus{Local: resp.Local, Remote: resp.Remote},
+		ExpectedCacheStatus: resp.ExpectedCacheStatus,
+		Reason:              resp.Reason,
+		GlobalChanges:       resp.GlobalChanges,
+		Changes:             resp.Changes,
+	}
+	// Empty lists are sent as nil
+	if explanation.GlobalChanges == nil {
+		explanation.GlobalChanges = []string{}
+	}
+	if explanation.Changes == nil {
+		explanation.Changes = []string{}
+	}
+	if previous := resp.PreviousRun; previous != nil {
+		explanation.PreviousRun = &runsummary.PreviousTaskRun{
+			RunID:     previous.RunId,
+			CreatedAt: time.UnixMilli(int64(previous.CreatedAtMsec)),
+			Hash:      previous.Hash,
+			Status:    previous.Status,
+		}
+	}
+	return explanation, nil
+}
@@ -0,0 +1,268 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	turbocontext "github.com/vercel/turbo/cli/internal/context"
+	"github.com/vercel/turbo/cli/internal/daemon"
+	"github.com/vercel/turbo/cli/internal/daemon/connector"
+	"github.com/vercel/turbo/cli/internal/daemonclient"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/packagemanager"
+	"github.com/vercel/turbo/cli/internal/scm"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// doctor holds the state shared between checks
+type doctor struct {
+	base            *cmdutil.CmdBase
+	cacheDir        turbopath.AbsoluteSystemPath
+	rootPackageJSON *fs.PackageJSON
+}
+
+func checkPackageManager(_ context.Context, d *doctor) *Diagnostic {
+	diagnostic := &Diagnostic{
+		Code: "TD101",
+		Name: "Package manager",
+	}
+	rootPackageJSON, err := fs.ReadPackageJSON(d.base.RepoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		diagnostic.Status = StatusFailed
+		diagnostic.Message = fmt.Sprintf("failed to read package.json: %v", err)
+		diagnostic.Fix = "Run turbo from the root of your monorepo, or pass --cwd"
+		return diagnostic
+	}
+	d.rootPackageJSON = rootPackageJSON
+
+	packageManager, err := packagemanager.GetPackageManager(d.base.RepoRoot, rootPackageJSON)
+	if err != nil {
+		diagnostic.Status = StatusFailed
+		diagnostic.Message = "could not detect a package manager"
+		diagnostic.Fix = "Set the \"packageManager\" field in your root package.json, or run `npx @turbo/codemod add-package-manager`"
+		return diagnostic
+	}
+	diagnostic.Status = StatusOK
+	diagnostic.Message = packageManager.Name
+	if rootPackageJSON.PackageManager == "" {
+		diagnostic.Status = StatusWarning
+		diagnostic.Message = fmt.Sprintf("%v (inferred from lockfile)", packageManager.Name)
+		diagnostic.Fix = "Set the \"packageManager\" field in your root package.json to pin the package manager"
+	}
+	return diagnostic
+}
+
+func checkWorkspaces(_ context.Context, d *doctor) *Diagnostic {
+	diagnostic := &Diagnostic{
+		Code: "TD102",
+		Name: "Workspaces",
+	}
+	if d.rootPackageJSON == nil {
+		return skipped(diagnostic, "root package.json could not be read")
+	}
+	pkgDepGraph, err := turbocontext.BuildPackageGraph(d.base.RepoRoot, d.rootPackageJSON)
+	var warnings *turbocontext.Warnings
+	if err != nil && !errors.As(err, &warnings) {
+		diagnostic.Status = StatusFailed
+		diagnostic.Message = err.Error()
+		diagnostic.Fix = "Check that every workspace has a package.json with a unique \"name\""
+		return diagnostic
+	}
+	if err := util.ValidateGraph(&pkgDepGraph.WorkspaceGraph); err != nil {
+		diagnostic.Status = StatusFailed
+		diagnostic.Message = fmt.Sprintf("invalid workspace dependency graph: %v", err)
+		diagnostic.Fix = "Remove the dependency cycle between your workspaces"
+		return diagnostic
+	}
+	if warnings != nil {
+		diagnostic.Status = StatusWarning
+		diagnostic.Message = warnings.Error()
+		diagnostic.Fix = "Make sure your lockfile is up to date by running your package manager's install command"
+		return diagnostic
+	}
+	diagnostic.Status = StatusOK
+	// WorkspaceNames includes the root workspace
+	diagnostic.Message = fmt.Sprintf("%v workspaces found", len(pkgDepGraph.WorkspaceNames)-1)
+	return diagnostic
+}
+
+func checkTurboJSON(_ context.Context, d *doctor) *Diagnostic {
+	diagnostic := &Diagnostic{
+		Code: "TD103",
+		Name: "turbo.json",
+	}
+	if d.rootPackageJSON == nil {
+		return skipped(diagnostic, "root package.json could not be read")
+	}
+	turboJSON, err := fs.LoadTurboConfig(d.base.RepoRoot, d.rootPackageJSON, false)
+	if err != nil {
+		diagnostic.Status = StatusFailed
+		diagnostic.Message = err.Error()
+		diagnostic.Fix = "Fix the errors in turbo.json. See https://turbo.build/repo/docs/reference/configuration"
+		return diagnostic
+	}
+	diagnostic.Status = StatusOK
+	diagnostic.Message = fmt.Sprintf("%v tasks defined in the pipeline", len(turboJSON.Pipeline))
+	return diagnostic
+}
+
+func checkGit(_ context.Context, d *doctor) *Diagnostic {
+	diagnostic := &Diagnostic{
+		Code: "TD104",
+		Name: "Git",
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		diagnostic.Status = StatusWarning
+		diagnostic.Message = "git is not available on the PATH"
+		diagnostic.Fix = "Install git so that turbo can use it for faster file hashing and --filter=[ref]"
+		return diagnostic
+	}
+	if _, err := scm.FromInRepo(d.base.RepoRoot); err != nil {
+		diagnostic.Status = StatusWarning
+		diagnostic.Message = "not inside a git repository, files will be hashed manually"
+		diagnostic.Fix = "Run `git init` in the root of your monorepo"
+		return diagnostic
+	}
+	diagnostic.Status = StatusOK
+	diagnostic.Message = "available"
+	return diagnostic
+}
+
+func checkDaemon(ctx context.Context, d *doctor) *Diagnostic {
+	diagnostic := &Diagnostic{
+		Code: "TD105",
+		Name: "Daemon",
+	}
+	client, err := daemon.GetClient(ctx, d.base.RepoRoot, d.base.Logger, d.base.TurboVersion, daemon.ClientOpts{
+		// Checking on the daemon should not change its state
+		DontStart: true,
+		DontKill:  true,
+	})
+	if err != nil {
+		diagnostic.Status = StatusWarning
+		if errors.Is(err, connector.ErrDaemonNotRunning) {
+			diagnostic.Message = "not running, it will be started on the next run"
+			diagnostic.Fix = "Run `turbo daemon start` to start it now"
+		} else if errors.Is(err, connector.ErrVersionMismatch) {
+			diagnostic.Message = "running a different version of turbo"
+			diagnostic.Fix = "Run `turbo daemon restart`"
+		} else {
+			diagnostic.Message = fmt.Sprintf("failed to connect: %v", err)
+			diagnostic.Fix = "Run `turbo daemon restart`, or pass --no-daemon to run without it"
+		}
+		return diagnostic
+	}
+	defer func() { _ = client.Close() }()
+
+	status, err := daemonclient.New(client).Status(ctx)
+	if err != nil {
+		diagnostic.Status = StatusWarning
+		diagnostic.Message = fmt.Sprintf("failed to get status: %v", err)
+		diagnostic.Fix = "Run `turbo daemon restart`"
+		return diagnostic
+	}
+	diagnostic.Status = StatusOK
+	diagnostic.Message = fmt.Sprintf("running, logs at %v", status.LogFile)
+	return diagnostic
+}
+
+func checkCacheDir(_ context.Context, d *doctor) *Diagnostic {
+	diagnostic := &Diagnostic{
+		Code: "TD106",
+		Name: "Local cache",
+	}
+	cacheDir := d.cacheDir
+	// The cache directory is created on the first run, so check that its closest
+	// existing ancestor is writable rather than creating it here
+	writableDir := cacheDir
+	for !writableDir.DirExists() && writableDir.Dir() != writableDir {
+		writableDir = writableDir.Dir()
+	}
+	probe, err := os.CreateTemp(writableDir.ToString(), ".turbo-doctor-")
+	if err != nil {
+		diagnostic.Status = StatusFailed
+		diagnostic.Message = fmt.Sprintf("%v is not writable: %v", cacheDir, err)
+		diagnostic.Fix = "Check the permissions of the cache directory, or pass --cache-dir to `turbo run` to use another location"
+		return diagnostic
+	}
+	_ = probe.Close()
+	_ = os.Remove(probe.Name())
+	if !cacheDir.DirExists() {
+		diagnostic.Status = StatusOK
+		diagnostic.Message = fmt.Sprintf("%v does not exist yet, it will be created on the next run", cacheDir)
+		return diagnostic
+	}
+
+	var size int64
+	artifacts := 0
+	err = fs.Walk(cacheDir.ToStringDuringMigration(), func(name string, isDir bool) error {
+		if isDir {
+			return nil
+		}
+		info, err := os.Lstat(name)
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		artifacts++
+		return nil
+	})
+	if err != nil {
+		diagnostic.Status = StatusWarning
+		diagnostic.Message = fmt.Sprintf("writable, but failed to measure size: %v", err)
+		return diagnostic
+	}
+	diagnostic.Status = StatusOK
+	diagnostic.Message = fmt.Sprintf("%v is writable, %v files using %.1f MB", cacheDir, artifacts, float64(size)/(1024*1024))
+	return diagnostic
+}
+
+func checkRemoteCache(_ context.Context, d *doctor) *Diagnostic {
+	diagnostic := &Diagnostic{
+		Code: "TD107",
+		Name: "Remote cache",
+	}
+	if !d.base.APIClient.IsLinked() {
+		diagnostic.Status = StatusWarning
+		diagnostic.Message = "not linked, only the local cache will be used"
+		diagnostic.Fix = "Run `turbo login` and `turbo link` to enable Remote Caching"
+		return diagnostic
+	}
+	status, err := d.base.APIClient.GetCachingStatus()
+	if err != nil {
+		diagnostic.Status = StatusFailed
+		diagnostic.Message = fmt.Sprintf("failed to contact the remote cache: %v", err)
+		diagnostic.Fix = "Check your network connection and --api, or run `turbo login` to refresh your token"
+		return diagnostic
+	}
+	switch status {
+	case util.CachingStatusEnabled:
+		diagnostic.Status = StatusOK
+		diagnostic.Message = "enabled"
+	case util.CachingStatusOverLimit:
+		diagnostic.Status = StatusWarning
+		diagnostic.Message = "usage limit reached, artifacts will not be uploaded or downloaded"
+		diagnostic.Fix = "Check your team's usage in the Vercel dashboard"
+	case util.CachingStatusPaused:
+		diagnostic.Status = StatusWarning
+		diagnostic.Message = "paused, artifacts will not be uploaded or downloaded"
+		diagnostic.Fix = "Check your team's spending settings in the Vercel dashboard"
+	default:
+		diagnostic.Status = StatusWarning
+		diagnostic.Message = "disabled for this team"
+		diagnostic.Fix = "Enable Remote Caching in your team settings"
+	}
+	return diagnostic
+}
+
+// skipped marks a diagnostic as failed because a prerequisite check failed
+func skipped(diagnostic *Diagnostic, reason string) *Diagnostic {
+	diagnostic.Status = StatusFailed
+	diagnostic.Message = fmt.Sprintf("skipped, %v", reason)
+	return diagnostic
+}
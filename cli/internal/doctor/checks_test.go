@@ -0,0 +1,145 @@
+package doctor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/mitchellh/cli"
+	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+func newDoctor(t *testing.T, files map[string]string) *doctor {
+	t.Helper()
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	for path, contents := range files {
+		file := repoRoot.UntypedJoin(path)
+		assert.NilError(t, file.EnsureDir())
+		assert.NilError(t, file.WriteFile([]byte(contents), 0644))
+	}
+	return &doctor{
+		base: &cmdutil.CmdBase{
+			UI:       cli.NewMockUi(),
+			Logger:   hclog.NewNullLogger(),
+			RepoRoot: repoRoot,
+		},
+		cacheDir: cache.DefaultLocation(repoRoot),
+	}
+}
+
+const _validRootPackageJSON = `{"name": "root", "packageManager": "npm@8.19.2", "workspaces": ["apps/*", "packages/*"]}`
+
+func TestRepositoryChecks(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		check check
+		want  Status
+	}{
+		{
+			name: "pinned package manager",
+			files: map[string]string{
+				"package.json": _validRootPackageJSON,
+			},
+			check: checkPackageManager,
+			want:  StatusOK,
+		},
+		{
+			name: "inferred package manager",
+			files: map[string]string{
+				"package.json":      `{"name": "root", "workspaces": ["apps/*"]}`,
+				"package-lock.json": `{"lockfileVersion": 2}`,
+			},
+			check: checkPackageManager,
+			want:  StatusWarning,
+		},
+		{
+			name:  "missing package.json",
+			files: map[string]string{},
+			check: checkPackageManager,
+			want:  StatusFailed,
+		},
+		{
+			name: "workspace dependency cycle",
+			files: map[string]string{
+				"package.json":             _validRootPackageJSON,
+				"apps/web/package.json":    `{"name": "web", "dependencies": {"ui": "*"}}`,
+				"packages/ui/package.json": `{"name": "ui", "dependencies": {"web": "*"}}`,
+			},
+			check: checkWorkspaces,
+			want:  StatusFailed,
+		},
+		{
+			name: "valid turbo.json",
+			files: map[string]string{
+				"package.json": _validRootPackageJSON,
+				"turbo.json":   `{"pipeline": {"build": {"outputs": ["dist/**"]}}}`,
+			},
+			check: checkTurboJSON,
+			want:  StatusOK,
+		},
+		{
+			name: "invalid turbo.json",
+			files: map[string]string{
+				"package.json": _validRootPackageJSON,
+				"turbo.json":   `{"pipeline": `,
+			},
+			check: checkTurboJSON,
+			want:  StatusFailed,
+		},
+		{
+			name: "missing turbo.json",
+			files: map[string]string{
+				"package.json": _validRootPackageJSON,
+			},
+			check: checkTurboJSON,
+			want:  StatusFailed,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := newDoctor(t, tc.files)
+			// Later checks rely on the root package.json read by the first check
+			_ = checkPackageManager(context.Background(), d)
+			diagnostic := tc.check(context.Background(), d)
+			assert.Equal(t, diagnostic.Status, tc.want, diagnostic.Message)
+			if tc.want != StatusOK {
+				assert.Assert(t, diagnostic.Fix != "")
+			}
+		})
+	}
+}
+
+func TestChecksAreSkippedWithoutPackageJSON(t *testing.T) {
+	d := newDoctor(t, map[string]string{})
+	for _, check := range []check{checkWorkspaces, checkTurboJSON} {
+		diagnostic := check(context.Background(), d)
+		assert.Equal(t, diagnostic.Status, StatusFailed)
+		assert.Equal(t, diagnostic.Message, "skipped, root package.json could not be read")
+	}
+}
+
+func TestCheckCacheDir(t *testing.T) {
+	d := newDoctor(t, map[string]string{})
+
+	// A missing cache directory is fine, and isn't created
+	diagnostic := checkCacheDir(context.Background(), d)
+	assert.Equal(t, diagnostic.Status, StatusOK, diagnostic.Message)
+	assert.Assert(t, !d.cacheDir.Exists())
+
+	assert.NilError(t, d.cacheDir.UntypedJoin("abc.tar.zst").EnsureDir())
+	assert.NilError(t, d.cacheDir.UntypedJoin("abc.tar.zst").WriteFile([]byte("artifact"), 0644))
+	diagnostic = checkCacheDir(context.Background(), d)
+	assert.Equal(t, diagnostic.Status, StatusOK, diagnostic.Message)
+	assert.Equal(t, diagnostic.Message, d.cacheDir.ToString()+" is writable, 1 files using 0.0 MB")
+
+	// --cache-dir is resolved against the repository root
+	opts := &cache.Opts{OverrideDir: "custom-cache"}
+	d.cacheDir = opts.ResolveCacheDir(d.base.RepoRoot)
+	assert.Equal(t, d.cacheDir, d.base.RepoRoot.UntypedJoin("custom-cache"))
+	diagnostic = checkCacheDir(context.Background(), d)
+	assert.Equal(t, diagnostic.Status, StatusOK, diagnostic.Message)
+}
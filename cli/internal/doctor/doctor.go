@@ -0,0 +1,121 @@
+// Package doctor implements `turbo doctor`, which checks the environment and
+// repository configuration end to end and suggests fixes for any problems found.
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/process"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+	"github.com/vercel/turbo/cli/internal/ui"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// Status is the outcome of a single diagnostic check
+type Status string
+
+const (
+	// StatusOK indicates that the check passed
+	StatusOK Status = "ok"
+	// StatusWarning indicates that turbo will work, but possibly degraded
+	StatusWarning Status = "warning"
+	// StatusFailed indicates that turbo will not work correctly until this is fixed
+	StatusFailed Status = "failed"
+)
+
+// Diagnostic is the result of running a single check
+type Diagnostic struct {
+	// Code uniquely identifies the check, so that it can be looked up in documentation
+	Code    string `json:"code"`
+	Name    string `json:"name"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+	// Fix is an actionable suggestion, only populated when the check did not pass
+	Fix string `json:"fix,omitempty"`
+}
+
+// check runs a single diagnostic against the repository
+type check func(ctx context.Context, d *doctor) *Diagnostic
+
+// _checks are run in order. Later checks may rely on state gathered by earlier ones.
+var _checks = []check{
+	checkPackageManager,
+	checkWorkspaces,
+	checkTurboJSON,
+	checkGit,
+	checkDaemon,
+	checkCacheDir,
+	checkRemoteCache,
+}
+
+// ExecuteDoctor executes the `doctor` command.
+func ExecuteDoctor(ctx context.Context, helper *cmdutil.Helper, args *turbostate.ParsedArgsFromRust) error {
+	base, err := helper.GetCmdBase(args)
+	if err != nil {
+		return err
+	}
+	cacheOpts := &cache.Opts{OverrideDir: args.Command.Doctor.CacheDir}
+	d := &doctor{
+		base:     base,
+		cacheDir: cacheOpts.ResolveCacheDir(base.RepoRoot),
+	}
+	diagnostics := d.run(ctx)
+
+	if args.Command.Doctor.JSON {
+		rendered, err := json.MarshalIndent(diagnostics, "", "  ")
+		if err != nil {
+			return err
+		}
+		base.UI.Output(string(rendered))
+	} else {
+		printDiagnostics(base, diagnostics)
+	}
+
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Status == StatusFailed {
+			return &process.ChildExit{
+				ExitCode: 1,
+			}
+		}
+	}
+	return nil
+}
+
+func (d *doctor) run(ctx context.Context) []*Diagnostic {
+	diagnostics := make([]*Diagnostic, 0, len(_checks))
+	for _, check := range _checks {
+		diagnostics = append(diagnostics, check(ctx, d))
+	}
+	return diagnostics
+}
+
+func printDiagnostics(base *cmdutil.CmdBase, diagnostics []*Diagnostic) {
+	problems := 0
+	for _, diagnostic := range diagnostics {
+		var icon string
+		switch diagnostic.Status {
+		case StatusOK:
+			icon = util.Sprintf("${GREEN}✓${RESET}")
+		case StatusWarning:
+			icon = util.Sprintf("${YELLOW}!${RESET}")
+			problems++
+		default:
+			icon = util.Sprintf("${RED}✗${RESET}")
+			problems++
+		}
+		base.UI.Output(fmt.Sprintf("%s %s %s %s", icon, ui.Bold(diagnostic.Name), ui.Dim(fmt.Sprintf("[%s]", diagnostic.Code)), diagnostic.Message))
+		if diagnostic.Fix != "" {
+			base.UI.Output(ui.Dim(fmt.Sprintf("    → %s", diagnostic.Fix)))
+		}
+	}
+	base.UI.Output("")
+	if problems == 0 {
+		base.UI.Output(util.Sprintf("${BOLD_GREEN}No problems found${RESET}"))
+	} else {
+		base.UI.Output(util.Sprintf("${BOLD}%v of %v checks reported problems${RESET}", problems, len(diagnostics)))
+	}
+}
@@ -489,6 +489,32 @@ func symlink(oldname, newname string) {
 	}
 }
 
+func TestGlobWalkSkipDir(t *testing.T) {
+	fsys := os.DirFS("test")
+	for _, pattern := range []string{"a/**", "{a/**,abc}"} {
+		var matches []string
+		err := GlobWalk(fsys, pattern, func(p string, d fs.DirEntry) error {
+			if p == "a/b" {
+				return fs.SkipDir
+			}
+			matches = append(matches, p)
+			return nil
+		})
+		if err != nil {
+			t.Errorf("GlobWalk(%#q) returned error %v", pattern, err)
+			continue
+		}
+		for _, match := range matches {
+			if strings.HasPrefix(match, "a/b/") {
+				t.Errorf("GlobWalk(%#q) returned %v from a skipped directory", pattern, match)
+			}
+		}
+		if !inSlice("a/c/b", matches) {
+			t.Errorf("GlobWalk(%#q) returned %#v; expected it to include a/c/b", pattern, matches)
+		}
+	}
+}
+
 func TestGlobSorted(t *testing.T) {
 	fsys := os.DirFS("test")
 	expected := []string{"a", "abc", "abcd", "abcde", "abxbbxdbxebxczzx", "abxbbxdbxebxczzy", "axbxcxdxe", "axbxcxdxexxx", "a☺b"}
@@ -6,10 +6,12 @@ package doublestar
 import (
 	"io/fs"
 	"path"
+	"strings"
 )
 
 // GlobWalkFunc is a callback function for GlobWalk(). If the function returns an error, GlobWalk
-// will end immediately and return the same error.
+// will end immediately and return the same error, unless the error is fs.SkipDir for a
+// directory, in which case GlobWalk doesn't descend into that directory.
 type GlobWalkFunc func(path string, d fs.DirEntry) error
 
 // GlobWalk calls the callback function `fn` for every file matching pattern.
@@ -49,6 +51,9 @@ func doGlobWalk(fsys fs.FS, pattern string, firstSegment bool, fn GlobWalkFunc)
 		info, err := fs.Stat(fsys, pattern)
 		if err == nil {
 			err = fn(pattern, newDirEntryFromFileInfo(info))
+			if err == fs.SkipDir {
+				return nil
+			}
 			return err
 		}
 		// ignore IO errors
@@ -115,8 +120,18 @@ func globAltsWalk(fsys fs.FS, pattern string, openingIdx, closingIdx int, firstS
 		}
 	}
 
+	// The alts were walked before calling fn, so skipping a directory only
+	// skips the matches inside of it
+	var skippedDirs []string
 	for _, m := range matches {
+		if isInSkippedDir(m.Path, skippedDirs) {
+			continue
+		}
 		if err := fn(m.Path, m.Entry); err != nil {
+			if err == fs.SkipDir && m.Entry.IsDir() {
+				skippedDirs = append(skippedDirs, m.Path)
+				continue
+			}
 			return err
 		}
 	}
@@ -179,7 +194,10 @@ func globDirWalk(fsys fs.FS, dir, pattern string, canMatchFiles bool, fn GlobWal
 		if err != nil || !info.IsDir() {
 			return nil
 		}
-		return fn(dir, newDirEntryFromFileInfo(info))
+		if err = fn(dir, newDirEntryFromFileInfo(info)); err != fs.SkipDir {
+			return err
+		}
+		return nil
 	}
 
 	if pattern == "**" {
@@ -189,6 +207,9 @@ func globDirWalk(fsys fs.FS, dir, pattern string, canMatchFiles bool, fn GlobWal
 			return nil
 		}
 		if err = fn(dir, newDirEntryFromFileInfo(info)); err != nil {
+			if err == fs.SkipDir {
+				return nil
+			}
 			return err
 		}
 		return globDoubleStarWalk(fsys, dir, canMatchFiles, fn)
@@ -209,7 +230,7 @@ func globDirWalk(fsys fs.FS, dir, pattern string, canMatchFiles bool, fn GlobWal
 				return err
 			}
 			if matched {
-				if err = fn(path.Join(dir, name), info); err != nil {
+				if err = fn(path.Join(dir, name), info); err != nil && err != fs.SkipDir {
 					return err
 				}
 			}
@@ -232,6 +253,9 @@ func globDoubleStarWalk(fsys fs.FS, dir string, canMatchFiles bool, fn GlobWalkF
 		if isDir(fsys, dir, name, info) {
 			p := path.Join(dir, name)
 			if e := fn(p, info); e != nil {
+				if e == fs.SkipDir {
+					continue
+				}
 				return e
 			}
 			if e := globDoubleStarWalk(fsys, p, canMatchFiles, fn); e != nil {
@@ -247,6 +271,16 @@ func globDoubleStarWalk(fsys fs.FS, dir string, canMatchFiles bool, fn GlobWalkF
 	return nil
 }
 
+// isInSkippedDir returns whether p is inside of one of the skipped directories
+func isInSkippedDir(p string, skippedDirs []string) bool {
+	for _, dir := range skippedDirs {
+		if strings.HasPrefix(p, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
 type dirEntryFromFileInfo struct {
 	fi fs.FileInfo
 }
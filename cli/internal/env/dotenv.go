@@ -0,0 +1,110 @@
+package env
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+var _dotEnvKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// LoadDotEnv reads the given env files from each of dirs and returns the variables
+// they define. Files in later dirs take precedence over files in earlier dirs, and
+// within a dir, files earlier in the list take precedence over later ones, so that
+// [".env.local", ".env"] lets .env.local override .env. Missing files are skipped.
+func LoadDotEnv(dirs []turbopath.AbsoluteSystemPath, files []string) (EnvironmentVariableMap, error) {
+	loaded := EnvironmentVariableMap{}
+	seen := make(map[turbopath.AbsoluteSystemPath]bool)
+	for _, dir := range dirs {
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		for i := len(files) - 1; i >= 0; i-- {
+			path := dir.UntypedJoin(files[i])
+			contents, err := os.ReadFile(path.ToString())
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			vars, err := ParseDotEnv(contents)
+			if err != nil {
+				return nil, fmt.Errorf("%v: %w", path, err)
+			}
+			loaded.Merge(vars)
+		}
+	}
+	return loaded, nil
+}
+
+// ParseDotEnv parses the contents of a .env file. It supports comments, an
+// optional "export " prefix, and single or double quoted values. Double quoted
+// values may span lines and contain \n escapes; other values are taken literally.
+func ParseDotEnv(contents []byte) (EnvironmentVariableMap, error) {
+	vars := EnvironmentVariableMap{}
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		key = strings.TrimSpace(key)
+		if !ok || !_dotEnvKey.MatchString(key) {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", lineNumber)
+		}
+		value = strings.TrimSpace(value)
+		start := lineNumber
+
+		switch {
+		case strings.HasPrefix(value, `"`):
+			value = value[1:]
+			// Keep reading lines until the closing quote
+			for closingQuote(value) < 0 {
+				if !scanner.Scan() {
+					return nil, fmt.Errorf("line %d: unterminated quoted value", start)
+				}
+				lineNumber++
+				value += "\n" + scanner.Text()
+			}
+			value = value[:closingQuote(value)]
+			value = strings.NewReplacer(`\n`, "\n", `\"`, `"`, `\\`, `\`).Replace(value)
+		case strings.HasPrefix(value, "'"):
+			end := strings.Index(value[1:], "'")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated quoted value", start)
+			}
+			value = value[1 : end+1]
+		default:
+			if comment := strings.Index(value, " #"); comment >= 0 {
+				value = strings.TrimSpace(value[:comment])
+			}
+		}
+		vars[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// closingQuote returns the index of the first double quote in value that is not
+// escaped, or -1 if there isn't one
+func closingQuote(value string) int {
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' {
+			i++
+		} else if value[i] == '"' {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,104 @@
+package env
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+func TestParseDotEnv(t *testing.T) {
+	contents := strings.Join([]string{
+		"# a comment",
+		"",
+		"PLAIN=value",
+		"export EXPORTED=exported",
+		"SPACED = spaced value # trailing comment",
+		`DOUBLE="double # not a comment"`,
+		`ESCAPED="line one\nsay \"hi\""`,
+		`MULTILINE="first`,
+		`second"`,
+		"SINGLE='single $NOT_EXPANDED \\n'",
+		"EMPTY=",
+		"URL=https://example.com/#anchor",
+	}, "\n")
+	got, err := ParseDotEnv([]byte(contents))
+	if err != nil {
+		t.Fatalf("ParseDotEnv: %v", err)
+	}
+	want := EnvironmentVariableMap{
+		"PLAIN":     "value",
+		"EXPORTED":  "exported",
+		"SPACED":    "spaced value",
+		"DOUBLE":    "double # not a comment",
+		"ESCAPED":   "line one\nsay \"hi\"",
+		"MULTILINE": "first\nsecond",
+		"SINGLE":    "single $NOT_EXPANDED \\n",
+		"EMPTY":     "",
+		"URL":       "https://example.com/#anchor",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseDotEnv got %v, want %v", got, want)
+	}
+}
+
+func TestParseDotEnvErrors(t *testing.T) {
+	tests := []struct {
+		contents string
+		want     string
+	}{
+		{contents: "A=1\nnot a variable", want: "line 2: expected KEY=VALUE"},
+		{contents: "1A=1", want: "line 1: expected KEY=VALUE"},
+		{contents: "A=\"unterminated\nB=2", want: "line 1: unterminated quoted value"},
+		{contents: "A='unterminated", want: "line 1: unterminated quoted value"},
+	}
+	for _, tt := range tests {
+		_, err := ParseDotEnv([]byte(tt.contents))
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("ParseDotEnv(%q) got error %v, want %v", tt.contents, err, tt.want)
+		}
+	}
+}
+
+func TestLoadDotEnv(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	pkgDir := repoRoot.UntypedJoin("apps", "web")
+	if err := pkgDir.MkdirAll(0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	files := map[turbopath.AbsoluteSystemPath]string{
+		repoRoot.UntypedJoin(".env"):       "ROOT=root\nSHARED=root\nLOCAL=root",
+		repoRoot.UntypedJoin(".env.local"): "LOCAL=root-local",
+		pkgDir.UntypedJoin(".env"):         "SHARED=web\nPACKAGE=web",
+	}
+	for path, contents := range files {
+		if err := os.WriteFile(path.ToString(), []byte(contents), 0644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+
+	got, err := LoadDotEnv([]turbopath.AbsoluteSystemPath{repoRoot, pkgDir}, []string{".env.local", ".env", ".env.missing"})
+	if err != nil {
+		t.Fatalf("LoadDotEnv: %v", err)
+	}
+	want := EnvironmentVariableMap{
+		"ROOT":    "root",
+		"SHARED":  "web",
+		"LOCAL":   "root-local",
+		"PACKAGE": "web",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadDotEnv got %v, want %v", got, want)
+	}
+
+	if err := os.WriteFile(pkgDir.UntypedJoin(".env.local").ToString(), []byte("invalid"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	_, err = LoadDotEnv([]turbopath.AbsoluteSystemPath{repoRoot, pkgDir}, []string{".env.local"})
+	if err == nil || !strings.Contains(err.Error(), "line 1: expected KEY=VALUE") {
+		t.Errorf("LoadDotEnv got error %v, want a parse error", err)
+	}
+}
@@ -24,6 +24,9 @@ type BySource struct {
 type DetailedMap struct {
 	All      EnvironmentVariableMap
 	BySource BySource
+	// PassThrough are variables from "passThroughEnv". They are not part of All,
+	// because they don't affect the hash.
+	PassThrough EnvironmentVariableMap
 }
 
 // Merge takes another EnvironmentVariableMap and merges it into the receiver
@@ -92,16 +95,57 @@ func getEnvMap() EnvironmentVariableMap {
 	return envMap
 }
 
-// fromKeys returns a map of env vars and their values from a given set of env var names
+// fromKeys returns a map of env vars and their values from a given set of env var names.
+// Names without wildcards are included even if they are unset. Names with "*" wildcards,
+// such as NEXT_PUBLIC_*, include every set variable that they match, and names starting
+// with "!" exclude the variables they match.
 func fromKeys(all EnvironmentVariableMap, keys []string) EnvironmentVariableMap {
 	output := EnvironmentVariableMap{}
+	var includes []*regexp.Regexp
+	var excludes []*regexp.Regexp
 	for _, key := range keys {
-		output[key] = all[key]
+		if strings.HasPrefix(key, "!") {
+			excludes = append(excludes, wildcardRegexp(strings.TrimPrefix(key, "!")))
+		} else if strings.Contains(key, "*") {
+			includes = append(includes, wildcardRegexp(key))
+		} else {
+			output[key] = all[key]
+		}
+	}
+	for _, include := range includes {
+		for k, v := range all {
+			if include.MatchString(k) {
+				output[k] = v
+			}
+		}
+	}
+	for _, exclude := range excludes {
+		for k := range output {
+			if exclude.MatchString(k) {
+				delete(output, k)
+			}
+		}
 	}
 
 	return output
 }
 
+// wildcardRegexp matches the whole name of an env var against a name in which
+// "*" matches any characters
+func wildcardRegexp(name string) *regexp.Regexp {
+	parts := strings.Split(name, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+}
+
+// GetPassThroughEnvVars returns the env vars named by "passThroughEnv", which
+// may use the same wildcards as "env"
+func GetPassThroughEnvVars(keys []string) EnvironmentVariableMap {
+	return fromKeys(getEnvMap(), keys)
+}
+
 func fromMatching(all EnvironmentVariableMap, keyMatchers []string, shouldExclude func(k, v string) bool) (EnvironmentVariableMap, error) {
 	output := EnvironmentVariableMap{}
 	compileFailures := []string{}
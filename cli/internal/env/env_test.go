@@ -295,3 +295,54 @@ func TestGetHashableEnvVars(t *testing.T) {
 		})
 	}
 }
+
+func TestGetHashableEnvVarsWildcards(t *testing.T) {
+	tests := []struct {
+		name    string
+		envKeys []string
+		want    EnvironmentVariablePairs
+	}{
+		{
+			name:    "wildcard",
+			envKeys: []string{"NEXT_PUBLIC_*"},
+			want:    EnvironmentVariablePairs{"NEXT_PUBLIC_API=api", "NEXT_PUBLIC_SECRET=secret"},
+		},
+		{
+			name:    "wildcard with exclusion",
+			envKeys: []string{"NEXT_PUBLIC_*", "!NEXT_PUBLIC_SECRET"},
+			want:    EnvironmentVariablePairs{"NEXT_PUBLIC_API=api"},
+		},
+		{
+			name:    "wildcards only match set variables",
+			envKeys: []string{"*_URL", "UNSET"},
+			want:    EnvironmentVariablePairs{"DATABASE_URL=postgres", "UNSET="},
+		},
+		{
+			name:    "regular expression characters are literal",
+			envKeys: []string{"NEXT.PUBLIC_*"},
+			want:    EnvironmentVariablePairs{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnvs([]string{"NEXT_PUBLIC_API=api", "NEXT_PUBLIC_SECRET=secret", "DATABASE_URL=postgres"})
+			defer os.Clearenv()
+			res, err := GetHashableEnvVars(tt.envKeys, nil, "")
+			if err != nil {
+				t.Fatalf("GetHashableEnvVars: %v", err)
+			}
+			if got := res.All.ToHashable(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetPassThroughEnvVars(t *testing.T) {
+	setEnvs([]string{"AWS_ACCESS_KEY_ID=key", "AWS_SECRET_ACCESS_KEY=secret", "HOME=/home"})
+	defer os.Clearenv()
+	got := GetPassThroughEnvVars([]string{"AWS_*"}).Names()
+	if want := []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
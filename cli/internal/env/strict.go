@@ -0,0 +1,110 @@
+package env
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// _strictSystemEnvVars are kept in the environment of tasks in strict mode,
+// since shells, package managers and compilers need them to run. Names are
+// compared in upper case, since they are case-insensitive on Windows.
+var _strictSystemEnvVars = map[string]bool{
+	"APPDATA":                true,
+	"CI":                     true,
+	"COLORTERM":              true,
+	"COMSPEC":                true,
+	"FORCE_COLOR":            true,
+	"HOME":                   true,
+	"HOMEDRIVE":              true,
+	"HOMEPATH":               true,
+	"LANG":                   true,
+	"LANGUAGE":               true,
+	"LOCALAPPDATA":           true,
+	"LOGNAME":                true,
+	"NO_COLOR":               true,
+	"NUMBER_OF_PROCESSORS":   true,
+	"OS":                     true,
+	"PATH":                   true,
+	"PATHEXT":                true,
+	"PROCESSOR_ARCHITECTURE": true,
+	"PROGRAMDATA":            true,
+	"PROGRAMFILES":           true,
+	"PROGRAMFILES(X86)":      true,
+	"SHELL":                  true,
+	"SYSTEMDRIVE":            true,
+	"SYSTEMROOT":             true,
+	"TEMP":                   true,
+	"TERM":                   true,
+	"TMP":                    true,
+	"TMPDIR":                 true,
+	"TZ":                     true,
+	"USER":                   true,
+	"USERPROFILE":            true,
+	"WINDIR":                 true,
+}
+
+// _strictSystemEnvPrefixes are prefixes of the system variables kept in strict mode
+var _strictSystemEnvPrefixes = []string{"LC_", "XDG_"}
+
+func isStrictSystemEnvVar(name string) bool {
+	name = strings.ToUpper(name)
+	if _strictSystemEnvVars[name] {
+		return true
+	}
+	for _, prefix := range _strictSystemEnvPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// StrictEnvironment splits turbo's environment for a task that runs in strict
+// env mode. The kept variables are the ones named by keys, which may use the
+// same wildcards as "env", the ones in allowed, and the system variables that
+// tools need to run. Removed lists the names of the other variables, sorted.
+func StrictEnvironment(keys []string, allowed ...EnvironmentVariableMap) (kept EnvironmentVariableMap, removed []string) {
+	return strictEnvironment(getEnvMap(), keys, allowed...)
+}
+
+func strictEnvironment(all EnvironmentVariableMap, keys []string, allowed ...EnvironmentVariableMap) (EnvironmentVariableMap, []string) {
+	declared := fromKeys(all, keys)
+	kept := EnvironmentVariableMap{}
+	removed := []string{}
+	for name, value := range all {
+		isAllowed := isStrictSystemEnvVar(name)
+		if _, ok := declared[name]; ok {
+			isAllowed = true
+		}
+		for _, allowedVars := range allowed {
+			if _, ok := allowedVars[name]; ok {
+				isAllowed = true
+			}
+		}
+		if isAllowed {
+			kept[name] = value
+		} else {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+	return kept, removed
+}
+
+// MentionedEnvVars returns the names that appear as whole words in output,
+// which is how a task that failed in strict env mode usually reports a
+// variable it is missing
+func MentionedEnvVars(output []byte, names []string) []string {
+	mentioned := []string{}
+	for _, name := range names {
+		if name == "" || !bytes.Contains(output, []byte(name)) {
+			continue
+		}
+		if regexp.MustCompile(`(^|[^A-Za-z0-9_])` + regexp.QuoteMeta(name) + `($|[^A-Za-z0-9_])`).Match(output) {
+			mentioned = append(mentioned, name)
+		}
+	}
+	return mentioned
+}
@@ -0,0 +1,55 @@
+package env
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStrictEnvironment(t *testing.T) {
+	all := EnvironmentVariableMap{
+		"PATH":             "/usr/bin",
+		"Path":             "C:\\Windows",
+		"LC_ALL":           "C",
+		"API_URL":          "https://example.com",
+		"NEXT_PUBLIC_NAME": "web",
+		"AWS_SECRET":       "secret",
+		"NPM_TOKEN":        "token",
+		"TURBO_TOKEN":      "token",
+	}
+	kept, removed := strictEnvironment(
+		all,
+		[]string{"API_URL", "UNSET"},
+		EnvironmentVariableMap{"NEXT_PUBLIC_NAME": "web"},
+		EnvironmentVariableMap{"NPM_TOKEN": "token"},
+	)
+	wantKept := EnvironmentVariableMap{
+		"PATH":             "/usr/bin",
+		"Path":             "C:\\Windows",
+		"LC_ALL":           "C",
+		"API_URL":          "https://example.com",
+		"NEXT_PUBLIC_NAME": "web",
+		"NPM_TOKEN":        "token",
+	}
+	if !reflect.DeepEqual(kept, wantKept) {
+		t.Errorf("strictEnvironment() kept %v, want %v", kept, wantKept)
+	}
+	if wantRemoved := []string{"AWS_SECRET", "TURBO_TOKEN"}; !reflect.DeepEqual(removed, wantRemoved) {
+		t.Errorf("strictEnvironment() removed %v, want %v", removed, wantRemoved)
+	}
+
+	kept, _ = strictEnvironment(all, []string{"AWS_*", "!AWS_SECRET", "API_*"})
+	if _, ok := kept["AWS_SECRET"]; ok {
+		t.Errorf("strictEnvironment() kept an excluded variable")
+	}
+	if _, ok := kept["API_URL"]; !ok {
+		t.Errorf("strictEnvironment() removed a variable matching a wildcard")
+	}
+}
+
+func TestMentionedEnvVars(t *testing.T) {
+	output := []byte("Error: DATABASE_URL is not set\nusing API_URL_BASE from defaults\n")
+	got := MentionedEnvVars(output, []string{"API_URL", "DATABASE_URL", "SECRET"})
+	if want := []string{"DATABASE_URL"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("MentionedEnvVars() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,134 @@
+package filetrace
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/vercel/turbo/cli/internal/doublestar"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// Report compares the files a task accessed with its configuration. Paths and
+// globs are relative to the package directory, like in turbo.json, except for
+// ExternalReads, which are relative to the repository root.
+type Report struct {
+	// SuggestedOutputs cover every file the task wrote
+	SuggestedOutputs []string
+	// UndeclaredWrites were written by the task but are not matched by "outputs",
+	// so they won't be restored from the cache
+	UndeclaredWrites []string
+	// UnusedOutputs are globs in "outputs" that matched none of the written files
+	UnusedOutputs []string
+	// SuggestedInputs cover every file in the package that the task read. They
+	// are only set when the task declares "inputs".
+	SuggestedInputs []string
+	// UndeclaredReads were read by the task but are not matched by "inputs", so
+	// changing them doesn't invalidate the cache
+	UndeclaredReads []string
+	// ExternalReads were read from outside the package. Changes to them only
+	// invalidate the cache if they are covered by globalDependencies or belong to
+	// a workspace the task depends on.
+	ExternalReads []string
+}
+
+// Analyze compares the accesses of a task in the package at pkgDir, a unix path
+// relative to the repository root, with its task definition
+func Analyze(accesses *Accesses, pkgDir string, definition *fs.TaskDefinition) *Report {
+	report := &Report{}
+	var reads []string
+	for _, file := range accesses.Reads {
+		if rel, ok := relativeTo(pkgDir, file); ok {
+			reads = append(reads, rel)
+		} else {
+			report.ExternalReads = append(report.ExternalReads, file)
+		}
+	}
+	var writes []string
+	for _, file := range accesses.Writes {
+		// Writes outside of the package can't be declared as outputs
+		if rel, ok := relativeTo(pkgDir, file); ok {
+			writes = append(writes, rel)
+		}
+	}
+
+	report.SuggestedOutputs = suggestGlobs(writes)
+	usedOutputs := make(util.Set)
+	for _, file := range writes {
+		matched := false
+		for _, glob := range definition.Outputs.Inclusions {
+			if matches(glob, file) {
+				matched = true
+				usedOutputs.Add(glob)
+			}
+		}
+		if !matched || matchesAny(definition.Outputs.Exclusions, file) {
+			report.UndeclaredWrites = append(report.UndeclaredWrites, file)
+		}
+	}
+	for _, glob := range definition.Outputs.Inclusions {
+		if !usedOutputs.Includes(glob) {
+			report.UnusedOutputs = append(report.UnusedOutputs, glob)
+		}
+	}
+
+	// Without "inputs", every file in the package is an input
+	if accesses.ReadsTracked && len(definition.Inputs) > 0 {
+		report.SuggestedInputs = suggestGlobs(reads)
+		for _, file := range reads {
+			if !matchesAny(definition.Inputs, file) {
+				report.UndeclaredReads = append(report.UndeclaredReads, file)
+			}
+		}
+	}
+	return report
+}
+
+// IsEmpty returns true if the report has nothing to point out
+func (r *Report) IsEmpty() bool {
+	return len(r.UndeclaredWrites) == 0 && len(r.UnusedOutputs) == 0 && len(r.UndeclaredReads) == 0 && len(r.ExternalReads) == 0
+}
+
+func relativeTo(dir string, file string) (string, bool) {
+	if dir == "" || dir == "." {
+		return file, true
+	}
+	if strings.HasPrefix(file, dir+"/") {
+		return file[len(dir)+1:], true
+	}
+	return "", false
+}
+
+// suggestGlobs groups files by their top-level directory, so that a build
+// writing dist/a.js and dist/b.js is summarized as dist/**
+func suggestGlobs(files []string) []string {
+	if len(files) == 0 {
+		return nil
+	}
+	globs := make(util.Set)
+	for _, file := range files {
+		if i := strings.Index(file, "/"); i >= 0 {
+			globs.Add(path.Join(file[:i], "**"))
+		} else {
+			globs.Add(file)
+		}
+	}
+	suggestions := globs.UnsafeListOfStrings()
+	sort.Strings(suggestions)
+	return suggestions
+}
+
+func matchesAny(globs []string, file string) bool {
+	for _, glob := range globs {
+		if matches(glob, file) {
+			return true
+		}
+	}
+	return false
+}
+
+func matches(glob string, file string) bool {
+	matched, err := doublestar.Match(strings.TrimPrefix(glob, "./"), file)
+	return err == nil && matched
+}
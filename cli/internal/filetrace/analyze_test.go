@@ -0,0 +1,78 @@
+package filetrace
+
+import (
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+func TestAnalyze(t *testing.T) {
+	testCases := []struct {
+		name       string
+		accesses   *Accesses
+		definition *fs.TaskDefinition
+		want       *Report
+	}{
+		{
+			name: "matching configuration",
+			accesses: &Accesses{
+				Reads:        []string{"apps/web/src/index.ts"},
+				Writes:       []string{"apps/web/dist/index.js", "apps/web/dist/chunks/a.js"},
+				ReadsTracked: true,
+			},
+			definition: &fs.TaskDefinition{
+				Outputs: fs.TaskOutputs{Inclusions: []string{"dist/**"}},
+				Inputs:  []string{"src/**"},
+			},
+			want: &Report{
+				SuggestedOutputs: []string{"dist/**"},
+				SuggestedInputs:  []string{"src/**"},
+			},
+		},
+		{
+			name: "undeclared and unused outputs",
+			accesses: &Accesses{
+				Writes: []string{"apps/web/.next/server.js", "apps/web/.next/cache/a", "apps/web/stats.json"},
+			},
+			definition: &fs.TaskDefinition{
+				Outputs: fs.TaskOutputs{Inclusions: []string{".next/**", "dist/**"}, Exclusions: []string{".next/cache/**"}},
+			},
+			want: &Report{
+				SuggestedOutputs: []string{".next/**", "stats.json"},
+				UndeclaredWrites: []string{".next/cache/a", "stats.json"},
+				UnusedOutputs:    []string{"dist/**"},
+			},
+		},
+		{
+			name: "undeclared and external reads",
+			accesses: &Accesses{
+				Reads:        []string{"apps/web/src/index.ts", "apps/web/tsconfig.json", "tsconfig.base.json"},
+				ReadsTracked: true,
+			},
+			definition: &fs.TaskDefinition{
+				Inputs: []string{"src/**"},
+			},
+			want: &Report{
+				SuggestedInputs: []string{"src/**", "tsconfig.json"},
+				UndeclaredReads: []string{"tsconfig.json"},
+				ExternalReads:   []string{"tsconfig.base.json"},
+			},
+		},
+		{
+			name: "reads are not checked without inputs",
+			accesses: &Accesses{
+				Reads:        []string{"apps/web/src/index.ts"},
+				ReadsTracked: true,
+			},
+			definition: &fs.TaskDefinition{},
+			want:       &Report{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			report := Analyze(tc.accesses, "apps/web", tc.definition)
+			assert.DeepEqual(t, report, tc.want)
+		})
+	}
+}
@@ -0,0 +1,248 @@
+// Package filetrace records the files that a task reads and writes, so that its
+// "inputs" and "outputs" configuration can be checked against what it really does.
+//
+// On Linux, when strace is installed, the task runs under strace and both reads
+// and writes are recorded. Otherwise the package directory is snapshotted before
+// and after the task, which only detects writes.
+package filetrace
+
+import (
+	"bufio"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// _ignoredDirs are never reported, since they hold dependencies, VCS data or
+// turbo's own logs rather than files a task could declare
+var _ignoredDirs = util.SetFromStrings([]string{"node_modules", ".git", ".turbo"})
+
+// Accesses are the files a task read and wrote, as unix paths relative to the
+// repository root. Files outside the repository are not included.
+type Accesses struct {
+	Reads  []string
+	Writes []string
+	// ReadsTracked is false when only writes could be detected
+	ReadsTracked bool
+}
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// Trace records the file accesses of a single command
+type Trace struct {
+	repoRoot     turbopath.AbsoluteSystemPath
+	dir          turbopath.AbsoluteSystemPath
+	straceOutput string
+	before       map[string]fileState
+}
+
+// Start prepares cmd, which runs in dir, to have its file accesses recorded.
+// It must be called before cmd is started, and Finish once cmd has exited.
+func Start(cmd *exec.Cmd, repoRoot turbopath.AbsoluteSystemPath, dir turbopath.AbsoluteSystemPath) (*Trace, error) {
+	trace := &Trace{repoRoot: repoRoot, dir: dir}
+	if runtime.GOOS == "linux" {
+		if strace, err := exec.LookPath("strace"); err == nil {
+			f, err := os.CreateTemp("", "turbo-trace-*.log")
+			if err != nil {
+				return nil, err
+			}
+			if err := f.Close(); err != nil {
+				return nil, err
+			}
+			trace.straceOutput = f.Name()
+			cmd.Args = append([]string{strace, "-f", "-qq", "-e", "trace=open,openat,creat,rename,renameat,renameat2", "-o", f.Name(), "--"}, cmd.Args...)
+			cmd.Path = strace
+			return trace, nil
+		}
+	}
+	before, err := snapshot(dir)
+	if err != nil {
+		return nil, err
+	}
+	trace.before = before
+	return trace, nil
+}
+
+// Finish returns the files that were accessed since Start
+func (t *Trace) Finish() (*Accesses, error) {
+	if t.straceOutput == "" {
+		after, err := snapshot(t.dir)
+		if err != nil {
+			return nil, err
+		}
+		var writes []string
+		for path, state := range after {
+			if previous, ok := t.before[path]; !ok || previous != state {
+				writes = append(writes, path)
+			}
+		}
+		return t.accesses(nil, writes, false), nil
+	}
+
+	defer func() { _ = os.Remove(t.straceOutput) }()
+	f, err := os.Open(t.straceOutput)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	reads, writes, err := parseStrace(f, t.dir.ToString())
+	if err != nil {
+		return nil, err
+	}
+	return t.accesses(reads, writes, true), nil
+}
+
+// accesses converts absolute paths into repository-relative unix paths. Files
+// that were written are not also reported as read.
+func (t *Trace) accesses(reads []string, writes []string, readsTracked bool) *Accesses {
+	result := &Accesses{ReadsTracked: readsTracked}
+	written := make(util.Set)
+	for _, path := range writes {
+		if rel, ok := t.relative(path); ok && !written.Includes(rel) {
+			written.Add(rel)
+			result.Writes = append(result.Writes, rel)
+		}
+	}
+	read := make(util.Set)
+	for _, path := range reads {
+		if rel, ok := t.relative(path); ok && !written.Includes(rel) && !read.Includes(rel) {
+			read.Add(rel)
+			result.Reads = append(result.Reads, rel)
+		}
+	}
+	sort.Strings(result.Reads)
+	sort.Strings(result.Writes)
+	return result
+}
+
+func (t *Trace) relative(path string) (string, bool) {
+	rel, err := filepath.Rel(t.repoRoot.ToString(), path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	rel = filepath.ToSlash(rel)
+	for _, segment := range strings.Split(rel, "/") {
+		if _ignoredDirs.Includes(segment) {
+			return "", false
+		}
+	}
+	return rel, true
+}
+
+// snapshot records the size and modification time of every file under dir
+func snapshot(dir turbopath.AbsoluteSystemPath) (map[string]fileState, error) {
+	files := make(map[string]fileState)
+	err := filepath.WalkDir(dir.ToString(), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if _ignoredDirs.Includes(d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files[path] = fileState{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	return files, err
+}
+
+var (
+	// 1234 openat(AT_FDCWD, "/repo/file", O_RDONLY|O_CLOEXEC) = 3
+	_syscallLine = regexp.MustCompile(`^(\d+)\s+(\w+)\((.*)\)\s+=\s+(-?\d+)`)
+	// 1234 openat(AT_FDCWD, "/repo/file", O_RDONLY <unfinished ...>
+	_unfinishedLine = regexp.MustCompile(`^(\d+)\s+(.*) <unfinished \.\.\.>$`)
+	// 1234 <... openat resumed>) = 3
+	_resumedLine = regexp.MustCompile(`^(\d+)\s+<\.\.\. \w+ resumed>(.*)$`)
+	_quoted      = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"`)
+)
+
+// parseStrace returns the absolute paths of the files that were successfully
+// opened for reading, and of those that were created, opened for writing or
+// renamed into place. Relative paths are resolved against dir, which is only
+// correct as long as the task doesn't change directories.
+func parseStrace(r io.Reader, dir string) ([]string, []string, error) {
+	var reads []string
+	var writes []string
+	unfinished := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if match := _unfinishedLine.FindStringSubmatch(line); match != nil {
+			unfinished[match[1]] = match[2]
+			continue
+		}
+		if match := _resumedLine.FindStringSubmatch(line); match != nil {
+			line = match[1] + " " + unfinished[match[1]] + match[2]
+			delete(unfinished, match[1])
+		}
+		match := _syscallLine.FindStringSubmatch(line)
+		if match == nil || strings.HasPrefix(match[4], "-") {
+			continue
+		}
+		call, args := match[2], match[3]
+		paths := quotedStrings(args)
+		if len(paths) == 0 {
+			continue
+		}
+		switch call {
+		case "open", "openat":
+			if strings.Contains(args, "O_DIRECTORY") {
+				continue
+			}
+			path := resolve(dir, paths[0])
+			if strings.Contains(args, "O_WRONLY") || strings.Contains(args, "O_RDWR") || strings.Contains(args, "O_CREAT") {
+				writes = append(writes, path)
+			} else {
+				reads = append(reads, path)
+			}
+		case "creat":
+			writes = append(writes, resolve(dir, paths[0]))
+		case "rename", "renameat", "renameat2":
+			if len(paths) == 2 {
+				writes = append(writes, resolve(dir, paths[1]))
+			}
+		}
+	}
+	return reads, writes, scanner.Err()
+}
+
+func quotedStrings(args string) []string {
+	var values []string
+	for _, match := range _quoted.FindAllString(args, -1) {
+		value, err := strconv.Unquote(match)
+		if err != nil {
+			// strace escapes some bytes differently than Go; keep the raw value
+			value = match[1 : len(match)-1]
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+func resolve(dir string, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(dir, path)
+}
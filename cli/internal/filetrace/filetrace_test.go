@@ -0,0 +1,67 @@
+package filetrace
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+func TestParseStrace(t *testing.T) {
+	output := strings.Join([]string{
+		`100 openat(AT_FDCWD, "/repo/apps/web/src/index.ts", O_RDONLY|O_CLOEXEC) = 3`,
+		`100 openat(AT_FDCWD, "tsconfig.json", O_RDONLY) = 4`,
+		`100 openat(AT_FDCWD, "/repo/apps/web/missing.ts", O_RDONLY) = -1 ENOENT (No such file or directory)`,
+		`100 openat(AT_FDCWD, "/repo/apps/web/src", O_RDONLY|O_NONBLOCK|O_CLOEXEC|O_DIRECTORY) = 5`,
+		`101 openat(AT_FDCWD, "/repo/apps/web/dist/index.js", O_WRONLY|O_CREAT|O_TRUNC|O_CLOEXEC, 0666 <unfinished ...>`,
+		`100 creat("/repo/apps/web/dist/index.d.ts", 0644) = 6`,
+		`101 <... openat resumed>) = 7`,
+		`101 rename("/repo/apps/web/dist/.tmp", "/repo/apps/web/dist/index.js.map") = 0`,
+		`101 +++ exited with 0 +++`,
+	}, "\n")
+	reads, writes, err := parseStrace(strings.NewReader(output), "/repo/apps/web")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, reads, []string{"/repo/apps/web/src/index.ts", "/repo/apps/web/tsconfig.json"})
+	assert.DeepEqual(t, writes, []string{"/repo/apps/web/dist/index.d.ts", "/repo/apps/web/dist/index.js", "/repo/apps/web/dist/index.js.map"})
+}
+
+func TestAccesses(t *testing.T) {
+	trace := &Trace{repoRoot: fs.AbsoluteSystemPathFromUpstream(filepath.FromSlash("/repo"))}
+	accesses := trace.accesses(
+		[]string{"/repo/apps/web/src/index.ts", "/repo/apps/web/dist/index.js", "/repo/node_modules/react/index.js", "/usr/lib/libc.so", "/repo/apps/web/src/index.ts"},
+		[]string{"/repo/apps/web/dist/index.js", "/repo/apps/web/.turbo/turbo-build.log"},
+		true,
+	)
+	assert.DeepEqual(t, accesses, &Accesses{
+		Reads:        []string{"apps/web/src/index.ts"},
+		Writes:       []string{"apps/web/dist/index.js"},
+		ReadsTracked: true,
+	})
+}
+
+func TestSnapshotDetectsWrites(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	dir := repoRoot.UntypedJoin("apps", "web")
+	assert.NilError(t, dir.UntypedJoin("src").MkdirAll(0755))
+	assert.NilError(t, dir.UntypedJoin("src", "index.ts").WriteFile([]byte("source"), 0644))
+	assert.NilError(t, dir.UntypedJoin("README.md").WriteFile([]byte("readme"), 0644))
+
+	trace := &Trace{repoRoot: repoRoot, dir: dir}
+	before, err := snapshot(dir)
+	assert.NilError(t, err)
+	trace.before = before
+
+	assert.NilError(t, dir.UntypedJoin("dist").MkdirAll(0755))
+	assert.NilError(t, dir.UntypedJoin("dist", "index.js").WriteFile([]byte("output"), 0644))
+	assert.NilError(t, dir.UntypedJoin("README.md").WriteFile([]byte("generated readme"), 0644))
+	later := time.Now().Add(time.Minute)
+	assert.NilError(t, os.Chtimes(dir.UntypedJoin("README.md").ToString(), later, later))
+
+	accesses, err := trace.Finish()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, accesses, &Accesses{Writes: []string{"apps/web/README.md", "apps/web/dist/index.js"}})
+}
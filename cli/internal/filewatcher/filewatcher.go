@@ -79,12 +79,18 @@ type FileWatcher struct {
 	closed    bool
 }
 
-// New returns a new FileWatcher instance
-func New(logger hclog.Logger, repoRoot turbopath.AbsoluteSystemPath, backend Backend) *FileWatcher {
+// New returns a new FileWatcher instance. Changes in ignoredDirs, such as a
+// cache directory inside the repository, are not reported.
+func New(logger hclog.Logger, repoRoot turbopath.AbsoluteSystemPath, backend Backend, ignoredDirs ...turbopath.AbsoluteSystemPath) *FileWatcher {
 	excludes := make([]string, len(_ignores))
 	for i, ignore := range _ignores {
 		excludes[i] = filepath.ToSlash(repoRoot.UntypedJoin(ignore).ToString() + "/**")
 	}
+	for _, dir := range ignoredDirs {
+		if ok, err := repoRoot.ContainsPath(dir); err == nil && ok && dir != repoRoot {
+			excludes = append(excludes, filepath.ToSlash(dir.ToString()+"/**"))
+		}
+	}
 	excludePattern := "{" + strings.Join(excludes, ",") + "}"
 	return &FileWatcher{
 		backend:        backend,
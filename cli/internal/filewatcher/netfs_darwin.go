@@ -0,0 +1,31 @@
+package filewatcher
+
+import (
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"golang.org/x/sys/unix"
+)
+
+// _networkFilesystems are the names of the filesystems that FSEvents doesn't
+// see every change on
+var _networkFilesystems = map[string]bool{
+	"nfs":     true,
+	"smbfs":   true,
+	"afpfs":   true,
+	"webdav":  true,
+	"macfuse": true,
+	"osxfuse": true,
+}
+
+// networkFilesystem returns the name of the network filesystem that path is on,
+// or an empty string if it is on a local one
+func networkFilesystem(path turbopath.AbsoluteSystemPath) (string, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path.ToString(), &stat); err != nil {
+		return "", err
+	}
+	name := unix.ByteSliceToString(stat.Fstypename[:])
+	if _networkFilesystems[name] {
+		return name, nil
+	}
+	return "", nil
+}
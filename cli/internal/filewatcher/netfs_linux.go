@@ -0,0 +1,30 @@
+package filewatcher
+
+import (
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"golang.org/x/sys/unix"
+)
+
+// _networkFilesystems are the statfs magic numbers of the filesystems that
+// inotify doesn't see every change on
+var _networkFilesystems = map[uint32]string{
+	0x6969:     "nfs",
+	0x517b:     "smb",
+	0xfe534d42: "smb2",
+	0xff534d42: "cifs",
+	0x01021997: "9p",
+	0x00c36400: "ceph",
+	// virtiofs, which devcontainers use for bind mounts, is a FUSE filesystem
+	0x65735546: "fuse",
+}
+
+// networkFilesystem returns the name of the network filesystem that path is on,
+// or an empty string if it is on a local one
+func networkFilesystem(path turbopath.AbsoluteSystemPath) (string, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path.ToString(), &stat); err != nil {
+		return "", err
+	}
+	// The width and signedness of Type depend on the architecture
+	return _networkFilesystems[uint32(stat.Type)], nil
+}
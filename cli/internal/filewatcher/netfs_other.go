@@ -0,0 +1,12 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package filewatcher
+
+import "github.com/vercel/turbo/cli/internal/turbopath"
+
+// networkFilesystem always reports a local filesystem, since network ones can't
+// be detected on this platform
+func networkFilesystem(path turbopath.AbsoluteSystemPath) (string, error) {
+	return "", nil
+}
@@ -0,0 +1,228 @@
+package filewatcher
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/karrick/godirwalk"
+	"github.com/pkg/errors"
+	"github.com/vercel/turbo/cli/internal/doublestar"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// fileState is what the polling backend compares between scans to decide
+// whether a file changed
+type fileState struct {
+	modTime time.Time
+	size    int64
+	mode    os.FileMode
+}
+
+// pollingBackend finds changes by walking its roots every interval and comparing
+// what it finds to the previous walk. It is slower than the native backends, but
+// it sees changes that they miss, such as those on network filesystems, or those
+// made from the host of a container.
+type pollingBackend struct {
+	interval time.Duration
+	events   chan Event
+	errors   chan error
+	logger   hclog.Logger
+	done     chan struct{}
+	wg       sync.WaitGroup
+
+	mu      sync.Mutex
+	roots   map[turbopath.AbsoluteSystemPath][]string
+	files   map[turbopath.AbsoluteSystemPath]fileState
+	started bool
+	closed  bool
+}
+
+func newPollingBackend(logger hclog.Logger, interval time.Duration) *pollingBackend {
+	return &pollingBackend{
+		interval: interval,
+		events:   make(chan Event),
+		errors:   make(chan error),
+		logger:   logger.Named("poll"),
+		done:     make(chan struct{}),
+		roots:    make(map[turbopath.AbsoluteSystemPath][]string),
+		files:    make(map[turbopath.AbsoluteSystemPath]fileState),
+	}
+}
+
+func (p *pollingBackend) Events() <-chan Event {
+	return p.events
+}
+
+func (p *pollingBackend) Errors() <-chan error {
+	return p.errors
+}
+
+func (p *pollingBackend) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrFilewatchingClosed
+	}
+	p.closed = true
+	p.mu.Unlock()
+	// The polling goroutine has to stop before the channels it sends on are closed
+	close(p.done)
+	p.wg.Wait()
+	close(p.events)
+	close(p.errors)
+	return nil
+}
+
+func (p *pollingBackend) Start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return ErrFilewatchingClosed
+	}
+	if !p.started {
+		p.started = true
+		p.wg.Add(1)
+		go p.poll()
+	}
+	return nil
+}
+
+// AddRoot records the current state of a directory hierarchy. As with the other
+// backends, events are only sent for changes after this.
+func (p *pollingBackend) AddRoot(root turbopath.AbsoluteSystemPath, excludePatterns ...string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return ErrFilewatchingClosed
+	}
+	files, err := scanRoot(root, excludePatterns)
+	if err != nil {
+		return errors.Wrapf(err, "failed scanning %v", root)
+	}
+	p.roots[root] = excludePatterns
+	for name, state := range files {
+		p.files[name] = state
+	}
+	p.logger.Debug("polling directory", "root", root, "interval", p.interval)
+	return nil
+}
+
+func (p *pollingBackend) poll() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+		events, err := p.rescan()
+		if err != nil {
+			select {
+			case p.errors <- err:
+			case <-p.done:
+				return
+			}
+		}
+		for _, ev := range events {
+			select {
+			case p.events <- ev:
+			case <-p.done:
+				return
+			}
+		}
+	}
+}
+
+// rescan walks every root again and returns the changes since the last walk
+func (p *pollingBackend) rescan() ([]Event, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	files := make(map[turbopath.AbsoluteSystemPath]fileState, len(p.files))
+	for root, excludePatterns := range p.roots {
+		rootFiles, err := scanRoot(root, excludePatterns)
+		if err != nil {
+			// The previous state is kept, so that the changes are found once
+			// the root can be walked again
+			return nil, errors.Wrapf(err, "failed scanning %v", root)
+		}
+		for name, state := range rootFiles {
+			files[name] = state
+		}
+	}
+	events := diffFiles(p.files, files)
+	p.files = files
+	return events, nil
+}
+
+// diffFiles returns the events that turn before into after. They are sorted by
+// path, so that a directory is added before its contents.
+func diffFiles(before map[turbopath.AbsoluteSystemPath]fileState, after map[turbopath.AbsoluteSystemPath]fileState) []Event {
+	events := []Event{}
+	for name, state := range after {
+		previous, ok := before[name]
+		if !ok {
+			events = append(events, Event{Path: name, EventType: FileAdded})
+		} else if previous != state {
+			events = append(events, Event{Path: name, EventType: FileModified})
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			events = append(events, Event{Path: name, EventType: FileDeleted})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Path < events[j].Path
+	})
+	return events
+}
+
+// scanRoot returns the state of everything under root that isn't excluded. A
+// root that doesn't exist has nothing under it.
+func scanRoot(root turbopath.AbsoluteSystemPath, excludePatterns []string) (map[turbopath.AbsoluteSystemPath]fileState, error) {
+	files := make(map[turbopath.AbsoluteSystemPath]fileState)
+	if _, err := root.Lstat(); errors.Is(err, os.ErrNotExist) {
+		return files, nil
+	}
+	err := fs.WalkMode(root.ToString(), func(name string, isDir bool, mode os.FileMode) error {
+		for _, excludePattern := range excludePatterns {
+			excluded, err := doublestar.Match(excludePattern, filepath.ToSlash(name))
+			if err != nil {
+				return err
+			}
+			if excluded {
+				return godirwalk.SkipThis
+			}
+		}
+		path := fs.AbsoluteSystemPathFromUpstream(name)
+		info, err := path.Lstat()
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				// We can race with a file being removed. It will be found
+				// missing on the next scan, if it was seen before.
+				return nil
+			}
+			return err
+		}
+		state := fileState{mode: info.Mode()}
+		// The modification time and size of a directory change with its
+		// entries, which are reported on their own
+		if !info.IsDir() {
+			state.modTime = info.ModTime()
+			state.size = info.Size()
+		}
+		files[path] = state
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
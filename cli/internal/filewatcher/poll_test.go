@@ -0,0 +1,143 @@
+package filewatcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func TestPolling(t *testing.T) {
+	logger := hclog.Default()
+	logger.SetLevel(hclog.Debug)
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	err := repoRoot.UntypedJoin(".git").MkdirAll(0775)
+	assert.NilError(t, err, "MkdirAll")
+	err = repoRoot.UntypedJoin("parent", "child").MkdirAll(0775)
+	assert.NilError(t, err, "MkdirAll")
+	existingPath := repoRoot.UntypedJoin("parent", "existing")
+	err = existingPath.WriteFile([]byte("hello"), 0644)
+	assert.NilError(t, err, "WriteFile")
+
+	watcher, err := GetBackend(logger, repoRoot, BackendOpts{Watcher: WatcherPoll, PollInterval: 50 * time.Millisecond})
+	assert.NilError(t, err, "GetBackend")
+	fw := New(logger, repoRoot, watcher)
+	err = fw.Start()
+	assert.NilError(t, err, "fw.Start")
+	defer func() { _ = fw.Close() }()
+
+	ch := make(chan Event, 1)
+	c := &testClient{
+		notify: ch,
+	}
+	fw.AddClient(c)
+	expectWatching(t, c, []turbopath.AbsoluteSystemPath{
+		repoRoot,
+		repoRoot.UntypedJoin("parent"),
+		repoRoot.UntypedJoin("parent", "child"),
+	})
+
+	// Directories are walked as soon as they appear
+	deepPath := repoRoot.UntypedJoin("parent", "child", "deep", "path")
+	err = deepPath.MkdirAll(0775)
+	assert.NilError(t, err, "MkdirAll")
+	expectFilesystemEvent(t, ch, Event{
+		Path:      repoRoot.UntypedJoin("parent", "child", "deep"),
+		EventType: FileAdded,
+	})
+	expectFilesystemEvent(t, ch, Event{
+		Path:      deepPath,
+		EventType: FileAdded,
+	})
+
+	gitFilePath := repoRoot.UntypedJoin(".git", "git-file")
+	err = gitFilePath.WriteFile([]byte("nope"), 0644)
+	assert.NilError(t, err, "WriteFile")
+	expectNoFilesystemEvent(t, ch)
+}
+
+func TestPollingModifiedAndDeleted(t *testing.T) {
+	logger := hclog.Default()
+	root := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	modifiedPath := root.UntypedJoin("modified")
+	err := modifiedPath.WriteFile([]byte("hello"), 0644)
+	assert.NilError(t, err, "WriteFile")
+	deletedPath := root.UntypedJoin("deleted")
+	err = deletedPath.WriteFile([]byte("hello"), 0644)
+	assert.NilError(t, err, "WriteFile")
+
+	backend := newPollingBackend(logger, 50*time.Millisecond)
+	err = backend.AddRoot(root)
+	assert.NilError(t, err, "AddRoot")
+	err = backend.Start()
+	assert.NilError(t, err, "Start")
+
+	// The size changes, even if the modification time doesn't
+	err = modifiedPath.WriteFile([]byte("hello, world"), 0644)
+	assert.NilError(t, err, "WriteFile")
+	expectFilesystemEvent(t, backend.Events(), Event{
+		Path:      modifiedPath,
+		EventType: FileModified,
+	})
+	err = deletedPath.Remove()
+	assert.NilError(t, err, "Remove")
+	expectFilesystemEvent(t, backend.Events(), Event{
+		Path:      deletedPath,
+		EventType: FileDeleted,
+	})
+
+	err = backend.Close()
+	assert.NilError(t, err, "Close")
+	_, ok := <-backend.Events()
+	assert.Assert(t, !ok, "events are closed")
+	assert.ErrorIs(t, backend.Close(), ErrFilewatchingClosed)
+}
+
+func TestGetBackend(t *testing.T) {
+	logger := hclog.Default()
+	root := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+
+	backend, err := GetBackend(logger, root, BackendOpts{Watcher: WatcherPoll})
+	assert.NilError(t, err, "GetBackend")
+	poller, ok := backend.(*pollingBackend)
+	assert.Assert(t, ok, "expected the polling backend")
+	assert.Equal(t, poller.interval, DefaultPollInterval)
+	assert.Equal(t, CookieTimeout(backend, 500*time.Millisecond), 2500*time.Millisecond)
+	assert.NilError(t, backend.Close(), "Close")
+
+	backend, err = GetBackend(logger, root, BackendOpts{Watcher: WatcherNative})
+	assert.NilError(t, err, "GetBackend")
+	_, ok = backend.(*pollingBackend)
+	assert.Assert(t, !ok, "expected the native backend")
+	assert.Equal(t, CookieTimeout(backend, 500*time.Millisecond), 500*time.Millisecond)
+	assert.NilError(t, backend.Close(), "Close")
+
+	_, err = GetBackend(logger, root, BackendOpts{Watcher: "inotify"})
+	assert.ErrorContains(t, err, "unknown watcher")
+}
+
+func TestDiffFiles(t *testing.T) {
+	now := time.Now()
+	before := map[turbopath.AbsoluteSystemPath]fileState{
+		"/repo":         {mode: 0755},
+		"/repo/kept":    {modTime: now, size: 5, mode: 0644},
+		"/repo/touched": {modTime: now, size: 5, mode: 0644},
+		"/repo/removed": {modTime: now, size: 5, mode: 0644},
+	}
+	after := map[turbopath.AbsoluteSystemPath]fileState{
+		"/repo":         {mode: 0755},
+		"/repo/kept":    {modTime: now, size: 5, mode: 0644},
+		"/repo/touched": {modTime: now.Add(time.Second), size: 5, mode: 0644},
+		"/repo/new":     {mode: 0755},
+		"/repo/new/a":   {modTime: now, size: 1, mode: 0644},
+	}
+	assert.DeepEqual(t, diffFiles(before, after), []Event{
+		{Path: "/repo/new", EventType: FileAdded},
+		{Path: "/repo/new/a", EventType: FileAdded},
+		{Path: "/repo/removed", EventType: FileDeleted},
+		{Path: "/repo/touched", EventType: FileModified},
+	})
+}
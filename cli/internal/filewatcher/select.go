@@ -0,0 +1,71 @@
+package filewatcher
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+const (
+	// WatcherAuto uses the native backend, unless the repository is on a
+	// network filesystem, where it polls
+	WatcherAuto = "auto"
+	// WatcherNative uses fsevents on macOS and fsnotify elsewhere
+	WatcherNative = "native"
+	// WatcherPoll walks the repository every poll interval
+	WatcherPoll = "poll"
+)
+
+// DefaultPollInterval is how often the polling backend walks the repository,
+// unless it is configured
+const DefaultPollInterval = 1 * time.Second
+
+// BackendOpts selects the filewatching backend
+type BackendOpts struct {
+	// Watcher is one of WatcherAuto, WatcherNative or WatcherPoll. It
+	// defaults to WatcherAuto.
+	Watcher string
+	// PollInterval defaults to DefaultPollInterval
+	PollInterval time.Duration
+}
+
+// GetBackend returns the filewatching backend selected by opts for watching
+// repoRoot
+func GetBackend(logger hclog.Logger, repoRoot turbopath.AbsoluteSystemPath, opts BackendOpts) (Backend, error) {
+	interval := opts.PollInterval
+	if interval == 0 {
+		interval = DefaultPollInterval
+	} else if interval < 0 {
+		return nil, fmt.Errorf("invalid poll interval %v", interval)
+	}
+	switch opts.Watcher {
+	case WatcherNative:
+		return GetPlatformSpecificBackend(logger)
+	case WatcherPoll:
+		return newPollingBackend(logger, interval), nil
+	case "", WatcherAuto:
+		filesystem, err := networkFilesystem(repoRoot)
+		if err != nil {
+			logger.Warn(fmt.Sprintf("failed to detect the filesystem of %v, using the native watcher: %v", repoRoot, err))
+			return GetPlatformSpecificBackend(logger)
+		}
+		if filesystem != "" {
+			logger.Info(fmt.Sprintf("%v is on a %v filesystem, polling every %v for changes", repoRoot, filesystem, interval))
+			return newPollingBackend(logger, interval), nil
+		}
+		return GetPlatformSpecificBackend(logger)
+	default:
+		return nil, fmt.Errorf("unknown watcher %q, expected one of %v, %v or %v", opts.Watcher, WatcherAuto, WatcherNative, WatcherPoll)
+	}
+}
+
+// CookieTimeout returns how long to wait for a cookie file to be seen through
+// backend. Polling only sees a cookie on its next walk.
+func CookieTimeout(backend Backend, timeout time.Duration) time.Duration {
+	if poller, ok := backend.(*pollingBackend); ok {
+		return timeout + 2*poller.interval
+	}
+	return timeout
+}
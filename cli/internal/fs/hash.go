@@ -6,11 +6,51 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strconv"
+	"sync"
 
+	"github.com/vercel/turbo/cli/internal/turbopath"
 	"github.com/vercel/turbo/cli/internal/xxhash"
 )
 
+// HashVersion is a version of the algorithm that turns the inputs of tasks into
+// their hashes. A new version changes every hash, so the repository selects
+// one with "hashing" in turbo.json.
+type HashVersion int
+
+const (
+	// HashVersion1 hashes the inputs formatted with fmt, including the map of
+	// every input file to its hash
+	HashVersion1 HashVersion = 1
+	// HashVersion2 streams the hashes of input files into the hash in path
+	// order, instead of formatting them first, which is faster for packages
+	// with many files. Its hashes are marked with the version, so that they
+	// never collide with those of another version.
+	HashVersion2 HashVersion = 2
+	// HashVersion3 hashes like version 2, but leaves the external dependencies
+	// of the root package out of the global hash. Tasks hash the external
+	// dependencies of their own package, so bumping a dependency of the root
+	// package only changes the hashes of root tasks, and of the tasks of the
+	// packages that depend on the bumped dependency themselves.
+	HashVersion3 HashVersion = 3
+	// HashVersion4 hashes like version 3, but the tasks of the root package
+	// that don't configure inputs leave out the files of the other workspaces.
+	// Changing a workspace then doesn't change the hashes of root tasks, nor of
+	// the tasks that depend on them, such as a "//#codegen" task that every
+	// workspace builds after.
+	HashVersion4 HashVersion = 4
+	// DefaultHashVersion is used when turbo.json doesn't select a version
+	DefaultHashVersion = HashVersion1
+	// LatestHashVersion is the newest version
+	LatestHashVersion = HashVersion4
+)
+
+// IsValid returns whether the version is one that turbo can hash with
+func (v HashVersion) IsValid() bool {
+	return v >= HashVersion1 && v <= LatestHashVersion
+}
+
 func HashObject(i interface{}) (string, error) {
 	hash := xxhash.New()
 
@@ -19,6 +59,45 @@ func HashObject(i interface{}) (string, error) {
 	return hex.EncodeToString(hash.Sum(nil)), err
 }
 
+// HashObjectVersion hashes i with the given version of the algorithm. Version
+// 1 is HashObject.
+func HashObjectVersion(version HashVersion, i interface{}) (string, error) {
+	if version == HashVersion1 {
+		return HashObject(i)
+	}
+	hash := xxhash.New()
+
+	_, err := hash.Write([]byte(fmt.Sprintf("v%d|%v", version, i)))
+
+	return hex.EncodeToString(hash.Sum(nil)), err
+}
+
+// HashFileHashes hashes a map of files to their hashes with the given version
+// of the algorithm
+func HashFileHashes(version HashVersion, fileHashes map[turbopath.AnchoredUnixPath]string) (string, error) {
+	if version == HashVersion1 {
+		return HashObject(fileHashes)
+	}
+	files := make([]string, 0, len(fileHashes))
+	for file := range fileHashes {
+		files = append(files, file.ToString())
+	}
+	sort.Strings(files)
+
+	hash := xxhash.New()
+	if _, err := fmt.Fprintf(hash, "v%d|", version); err != nil {
+		return "", err
+	}
+	for _, file := range files {
+		// Paths can't contain a NUL, so entries can't run into each other
+		if _, err := io.WriteString(hash, file+"\x00"+fileHashes[turbopath.AnchoredUnixPath(file)]+"\x00"); err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
 func HashFile(filePath string) (string, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -27,13 +106,36 @@ func HashFile(filePath string) (string, error) {
 	defer file.Close()
 
 	hash := xxhash.New()
-	if _, err := io.Copy(hash, file); err != nil {
+	if err := streamToHash(hash, file); err != nil {
 		return "", err
 	}
 
 	return hex.EncodeToString(hash.Sum(nil)), nil
 }
 
+// _hashBufferSize is the size of the buffers that files are streamed through
+// while they are hashed, so that hashing a file of any size only needs this
+// much memory
+const _hashBufferSize = 64 * 1024
+
+var _hashBuffers = sync.Pool{
+	New: func() interface{} {
+		buffer := make([]byte, _hashBufferSize)
+		return &buffer
+	},
+}
+
+// streamToHash writes the content of reader into hash through a buffer shared
+// with the other files being hashed
+func streamToHash(hash io.Writer, reader io.Reader) error {
+	buffer := _hashBuffers.Get().(*[]byte)
+	defer _hashBuffers.Put(buffer)
+	// Hiding the WriterTo of files keeps io.CopyBuffer from allocating a
+	// buffer of its own
+	_, err := io.CopyBuffer(hash, struct{ io.Reader }{reader}, *buffer)
+	return err
+}
+
 // GitLikeHashFile is a function that mimics how Git
 // calculates the SHA1 for a file (or, in Git terms, a "blob") (without git)
 func GitLikeHashFile(filePath string) (string, error) {
@@ -53,7 +155,7 @@ func GitLikeHashFile(filePath string) (string, error) {
 	hash.Write([]byte(strconv.FormatInt(stat.Size(), 10)))
 	hash.Write([]byte{0})
 
-	if _, err := io.Copy(hash, file); err != nil {
+	if err := streamToHash(hash, file); err != nil {
 		return "", err
 	}
 
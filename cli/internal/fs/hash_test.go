@@ -3,6 +3,7 @@ package fs
 import (
 	"testing"
 
+	"github.com/vercel/turbo/cli/internal/turbopath"
 	"gotest.tools/v3/assert"
 )
 
@@ -51,3 +52,46 @@ func Test_HashObjectStability(t *testing.T) {
 		}
 	}
 }
+
+func Test_HashVersions(t *testing.T) {
+	fileHashes := map[turbopath.AnchoredUnixPath]string{
+		"package.json": "a4b1f2",
+		"src/index.ts": "9c3e0d",
+	}
+
+	// Version 1 is the original algorithm, so existing hashes don't change
+	v1, err := HashFileHashes(HashVersion1, fileHashes)
+	assert.NilError(t, err)
+	expected, err := HashObject(fileHashes)
+	assert.NilError(t, err)
+	assert.Equal(t, v1, expected)
+
+	v2, err := HashFileHashes(HashVersion2, fileHashes)
+	assert.NilError(t, err)
+	assert.Assert(t, v1 != v2)
+	for n := 0; n < _numOfRuns; n++ {
+		hash, err := HashFileHashes(HashVersion2, fileHashes)
+		assert.NilError(t, err)
+		assert.Equal(t, v2, hash)
+	}
+
+	// A file moving between paths changes the hash
+	moved, err := HashFileHashes(HashVersion2, map[turbopath.AnchoredUnixPath]string{
+		"package.json":  "a4b1f2",
+		"src/index.tsx": "9c3e0d",
+	})
+	assert.NilError(t, err)
+	assert.Assert(t, v2 != moved)
+
+	objV1, err := HashObjectVersion(HashVersion1, "inputs")
+	assert.NilError(t, err)
+	objV2, err := HashObjectVersion(HashVersion2, "inputs")
+	assert.NilError(t, err)
+	assert.Assert(t, objV1 != objV2)
+	objV3, err := HashObjectVersion(HashVersion3, "inputs")
+	assert.NilError(t, err)
+	assert.Assert(t, objV2 != objV3)
+	objV4, err := HashObjectVersion(HashVersion4, "inputs")
+	assert.NilError(t, err)
+	assert.Assert(t, objV3 != objV4)
+}
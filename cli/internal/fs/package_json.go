@@ -33,9 +33,14 @@ type PackageJSON struct {
 	InternalDeps           []string                     `json:"-"`
 	UnresolvedExternalDeps map[string]string            `json:"-"`
 	TransitiveDeps         []lockfile.Package           `json:"-"`
-	LegacyTurboConfig      *TurboJSON                   `json:"turbo"`
-	Mu                     sync.Mutex                   `json:"-"`
-	ExternalDepsHash       string                       `json:"-"`
+	// TurboConfig is the "turbo" key, which workspaces other than the root use
+	// to configure their tasks next to their scripts
+	TurboConfig      *TurboJSON `json:"turbo"`
+	Mu               sync.Mutex `json:"-"`
+	ExternalDepsHash string     `json:"-"`
+	// DefinedInTurboJSON is set for workspaces defined in the "workspaces" of
+	// turbo.json, for which PackageJSONPath is the path of their manifest
+	DefinedInTurboJSON bool `json:"-"`
 }
 
 type Workspaces []string
@@ -5,13 +5,19 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/muhammadmuzzammil1998/jsonc"
 	"github.com/pkg/errors"
+	"github.com/vercel/turbo/cli/internal/client"
 	"github.com/vercel/turbo/cli/internal/turbopath"
 	"github.com/vercel/turbo/cli/internal/util"
 )
@@ -20,6 +26,10 @@ const (
 	configFile                   = "turbo.json"
 	envPipelineDelimiter         = "$"
 	topologicalPipelineDelimiter = "^"
+	// ExtendsMarker in an array of a task definition appends the rest of the
+	// array to the value that is inherited from the turbo.json that is
+	// extended, instead of replacing it
+	ExtendsMarker = "$TURBO_EXTENDS$"
 )
 
 type rawTurboJSON struct {
@@ -32,6 +42,26 @@ type rawTurboJSON struct {
 	Pipeline Pipeline `json:"pipeline"`
 	// Configuration options when interfacing with the remote cache
 	RemoteCacheOptions RemoteCacheOptions `json:"remoteCache,omitempty"`
+	// Commands to run at points in the lifecycle of a run
+	Hooks Hooks `json:"hooks,omitempty"`
+	// Tags for workspaces and rules about which tags may depend on each other
+	Boundaries Boundaries `json:"boundaries,omitempty"`
+	// Webhooks that are sent the outcome of each run
+	Notifications []Notification `json:"notifications,omitempty"`
+	// Webhooks that are sent the status of tasks while the run is in progress
+	Webhooks []Webhook `json:"webhooks,omitempty"`
+	// Where a summary of each run is uploaded for fleet-wide analytics
+	Analytics Analytics `json:"analytics,omitempty"`
+	// How many run summaries are kept in .turbo/runs
+	Summaries SummariesOptions `json:"summaries,omitempty"`
+	// Which version of the algorithm hashes tasks
+	Hashing HashingOptions `json:"hashing,omitempty"`
+	// How the logs of tasks are prefixed
+	Logs LogsOptions `json:"logs,omitempty"`
+	// Named sets of flags for `turbo run --profile-name`
+	Profiles map[string]RunProfile `json:"profiles,omitempty"`
+	// Workspaces that aren't JavaScript packages, such as Go modules or Rust crates
+	Workspaces []WorkspaceDefinition `json:"workspaces,omitempty"`
 
 	// Extends can be the name of another workspace
 	Extends []string `json:"extends,omitempty"`
@@ -41,11 +71,21 @@ type rawTurboJSON struct {
 // Notably, it includes a PristinePipeline instead of the regular Pipeline. (i.e. TaskDefinition
 // instead of BookkeepingTaskDefinition.)
 type pristineTurboJSON struct {
-	GlobalDependencies []string           `json:"globalDependencies,omitempty"`
-	GlobalEnv          []string           `json:"globalEnv,omitempty"`
-	Pipeline           PristinePipeline   `json:"pipeline"`
-	RemoteCacheOptions RemoteCacheOptions `json:"remoteCache,omitempty"`
-	Extends            []string           `json:"extends,omitempty"`
+	GlobalDependencies []string              `json:"globalDependencies,omitempty"`
+	GlobalEnv          []string              `json:"globalEnv,omitempty"`
+	Pipeline           PristinePipeline      `json:"pipeline"`
+	RemoteCacheOptions RemoteCacheOptions    `json:"remoteCache,omitempty"`
+	Hooks              Hooks                 `json:"hooks,omitempty"`
+	Boundaries         Boundaries            `json:"boundaries,omitempty"`
+	Notifications      []Notification        `json:"notifications,omitempty"`
+	Webhooks           []Webhook             `json:"webhooks,omitempty"`
+	Analytics          Analytics             `json:"analytics,omitempty"`
+	Summaries          SummariesOptions      `json:"summaries,omitempty"`
+	Hashing            HashingOptions        `json:"hashing,omitempty"`
+	Logs               LogsOptions           `json:"logs,omitempty"`
+	Profiles           map[string]RunProfile `json:"profiles,omitempty"`
+	Workspaces         []WorkspaceDefinition `json:"workspaces,omitempty"`
+	Extends            []string              `json:"extends,omitempty"`
 }
 
 // TurboJSON represents a turbo.json configuration file
@@ -54,15 +94,302 @@ type TurboJSON struct {
 	GlobalEnv          []string
 	Pipeline           Pipeline
 	RemoteCacheOptions RemoteCacheOptions
+	Hooks              Hooks
+	Boundaries         Boundaries
+	Notifications      []Notification
+	Webhooks           []Webhook
+	Analytics          Analytics
+	Summaries          SummariesOptions
+	Hashing            HashingOptions
+	Logs               LogsOptions
+	Profiles           map[string]RunProfile
+	Workspaces         []WorkspaceDefinition
 
 	// A list of Workspace names
 	Extends []string
+
+	// PackageJSONPipeline is the "pipeline" in the "turbo" key of a workspace's
+	// package.json. Its task definitions apply after the ones in Pipeline.
+	PackageJSONPipeline Pipeline
 }
 
 // RemoteCacheOptions is a struct for deserializing .remoteCache of configFile
 type RemoteCacheOptions struct {
 	TeamID    string `json:"teamId,omitempty"`
 	Signature bool   `json:"signature,omitempty"`
+	// SignatureAlgorithm is SignatureHMACSHA256 (the default) or SignatureEd25519
+	SignatureAlgorithm string `json:"signatureAlgorithm,omitempty"`
+	// S3 stores artifacts in a bucket in place of the Vercel Remote Cache
+	S3 *S3CacheOptions `json:"s3,omitempty"`
+	// Compression is CompressionZstd (the default) or CompressionGzip. It only
+	// applies to uploads: downloads are decompressed according to their encoding.
+	Compression string `json:"compression,omitempty"`
+	// CompressionLevel is 0 for the default level of Compression
+	CompressionLevel int `json:"compressionLevel,omitempty"`
+	// Auth makes `turbo login` sign in with an OpenID Connect provider
+	// instead of Vercel
+	Auth *AuthOptions `json:"auth,omitempty"`
+	// Encryption encrypts artifacts before they are uploaded, with the keys in
+	// TURBO_REMOTE_CACHE_ENCRYPTION_KEY or TURBO_REMOTE_CACHE_ENCRYPTION_KEY_FILE
+	Encryption bool `json:"encryption,omitempty"`
+}
+
+// AuthOptions is a struct for deserializing .remoteCache.auth of configFile.
+// The token is requested with the OAuth device authorization flow.
+type AuthOptions struct {
+	// Issuer is the URL of the OpenID Connect provider, where
+	// /.well-known/openid-configuration is found
+	Issuer   string `json:"issuer"`
+	ClientID string `json:"clientId"`
+	// Scopes default to "openid offline_access", so that the token can be
+	// refreshed
+	Scopes []string `json:"scopes,omitempty"`
+}
+
+func (a *AuthOptions) validate() error {
+	if a == nil {
+		return nil
+	}
+	if issuer, err := url.Parse(a.Issuer); err != nil || (issuer.Scheme != "https" && issuer.Scheme != "http") || issuer.Host == "" {
+		return fmt.Errorf("Invalid \"issuer\" %q in \"remoteCache.auth\", expected a URL such as \"https://sso.example.com\"", a.Issuer)
+	}
+	if a.ClientID == "" {
+		return errors.New("Missing \"clientId\" in \"remoteCache.auth\"")
+	}
+	return nil
+}
+
+const (
+	// SignatureHMACSHA256 signs artifacts with a secret shared by every machine
+	// that reads or writes the remote cache
+	SignatureHMACSHA256 = "hmac-sha256"
+	// SignatureEd25519 signs artifacts with a private key, so that machines
+	// that only read the remote cache need just the public key
+	SignatureEd25519 = "ed25519"
+)
+
+// Compressions of remote cache artifacts, which are also the Content-Encoding
+// they are uploaded with
+const (
+	CompressionZstd = "zstd"
+	CompressionGzip = "gzip"
+)
+
+// MaxCompressionLevel is the highest level of each compression
+var MaxCompressionLevel = map[string]int{
+	CompressionZstd: 20,
+	CompressionGzip: 9,
+}
+
+// S3CacheOptions is a struct for deserializing .remoteCache.s3 of configFile.
+// Credentials are read from the standard AWS environment variables.
+type S3CacheOptions struct {
+	Bucket string `json:"bucket"`
+	// Prefix is prepended to the key of every artifact, such as "turbo/"
+	Prefix string `json:"prefix,omitempty"`
+	// Region defaults to AWS_REGION, then AWS_DEFAULT_REGION, then us-east-1
+	Region string `json:"region,omitempty"`
+	// Endpoint is the URL of an S3-compatible store other than Amazon S3
+	Endpoint string `json:"endpoint,omitempty"`
+	// ForcePathStyle puts the bucket in the path of URLs instead of in the host name
+	ForcePathStyle bool `json:"forcePathStyle,omitempty"`
+}
+
+// Hooks is a struct for deserializing .hooks of configFile.
+// Each hook is a list of commands that are run in order from the repository root.
+type Hooks struct {
+	PreRun      []string `json:"preRun,omitempty"`
+	PostRun     []string `json:"postRun,omitempty"`
+	PreTask     []string `json:"preTask,omitempty"`
+	PostTask    []string `json:"postTask,omitempty"`
+	OnCacheMiss []string `json:"onCacheMiss,omitempty"`
+}
+
+// Notification is a struct for deserializing an entry of .notifications of configFile.
+type Notification struct {
+	// URL is expanded with environment variables, so that webhook URLs can be
+	// kept out of turbo.json, e.g. "$SLACK_WEBHOOK_URL"
+	URL string `json:"url"`
+	// Format is "slack" or "teams"
+	Format string `json:"format"`
+	// OnlyFailures skips runs in which no task failed
+	OnlyFailures bool `json:"onlyFailures,omitempty"`
+}
+
+// Webhook is a struct for deserializing an entry of .webhooks of configFile.
+type Webhook struct {
+	// URL is expanded with environment variables, e.g. "$DASHBOARD_WEBHOOK_URL"
+	URL string `json:"url"`
+	// Secret signs each delivery with HMAC-SHA256 when it is set, and is
+	// expanded with environment variables, e.g. "$DASHBOARD_WEBHOOK_SECRET"
+	Secret string `json:"secret,omitempty"`
+	// Events are the events sent to the webhook, or every event if empty
+	Events []string `json:"events,omitempty"`
+	// Retries is how many times a failed delivery is sent again, 3 by default
+	Retries *int `json:"retries,omitempty"`
+}
+
+// Analytics is a struct for deserializing .analytics of configFile.
+// A summary of each run is posted to URL when it is set.
+type Analytics struct {
+	// URL is expanded with environment variables, e.g. "$TURBO_ANALYTICS_URL"
+	URL string `json:"url,omitempty"`
+	// Headers are sent with each upload, and their values are expanded with
+	// environment variables, e.g. {"Authorization": "Bearer $ANALYTICS_TOKEN"}
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// HashingOptions is a struct for deserializing .hashing of configFile.
+// Changing the version of the hashes misses the cache for every task, unless
+// the hashes of the previous version are looked up as a fallback while the
+// cache fills up with the new ones.
+type HashingOptions struct {
+	// Version defaults to DefaultHashVersion
+	Version HashVersion `json:"version,omitempty"`
+	// FallbackVersion is the version of the hashes that are looked up in the
+	// cache when a task misses it, or 0 for none
+	FallbackVersion HashVersion `json:"fallbackVersion,omitempty"`
+	// Workers is how many packages, and files of packages that git can't
+	// hash, are hashed at the same time. It defaults to the number of CPUs.
+	Workers int `json:"workers,omitempty"`
+}
+
+// HashVersion returns the version that tasks are hashed with
+func (h HashingOptions) HashVersion() HashVersion {
+	if h.Version == 0 {
+		return DefaultHashVersion
+	}
+	return h.Version
+}
+
+// WorkerCount returns how many packages and files are hashed at the same time
+func (h HashingOptions) WorkerCount() int {
+	if h.Workers == 0 {
+		return runtime.NumCPU()
+	}
+	return h.Workers
+}
+
+func (h HashingOptions) validate() error {
+	if h.Version != 0 && !h.Version.IsValid() {
+		return fmt.Errorf("Invalid \"version\" %v in \"hashing\", expected a version from %v to %v", h.Version, HashVersion1, LatestHashVersion)
+	}
+	if h.FallbackVersion != 0 && !h.FallbackVersion.IsValid() {
+		return fmt.Errorf("Invalid \"fallbackVersion\" %v in \"hashing\", expected a version from %v to %v", h.FallbackVersion, HashVersion1, LatestHashVersion)
+	}
+	if h.Workers < 0 {
+		return fmt.Errorf("Invalid \"workers\" %v in \"hashing\", expected a number greater than or equal to 1", h.Workers)
+	}
+	if h.FallbackVersion == h.HashVersion() {
+		return fmt.Errorf("Invalid \"fallbackVersion\" %v in \"hashing\", expected a version other than the one tasks are hashed with", h.FallbackVersion)
+	}
+	return nil
+}
+
+// SummariesOptions is a struct for deserializing .summaries of configFile.
+// Run summaries beyond either limit are removed after each run that saves one.
+type SummariesOptions struct {
+	// MaxCount is how many of the most recent run summaries are kept
+	MaxCount int `json:"maxCount,omitempty"`
+	// MaxAge is how long run summaries are kept, e.g. "72h" or "14d"
+	MaxAge string `json:"maxAge,omitempty"`
+}
+
+// LogsOptions is a struct for deserializing .logs of configFile.
+// The flags of the same name take precedence.
+type LogsOptions struct {
+	// Prefix is a template for the prefix of the lines that tasks log, such as
+	// "{package}:{task}: ", or "none" for no prefix
+	Prefix string `json:"prefix,omitempty"`
+	// PrefixColor is LogPrefixColorPackage (the default) or LogPrefixColorStatus
+	PrefixColor string `json:"prefixColor,omitempty"`
+}
+
+// Colors of the prefixes of task logs
+const (
+	// LogPrefixColorPackage colors prefixes by package, so that the lines of a
+	// package stand out from the lines of the packages next to it
+	LogPrefixColorPackage = "package"
+	// LogPrefixColorStatus colors prefixes by the status of the task: grey for
+	// lines replayed from the cache, and red once the task has failed
+	LogPrefixColorStatus = "status"
+)
+
+// RunProfile is a struct for deserializing a profile in .profiles of configFile.
+// Each field fills in the flag of the same name when it wasn't passed.
+type RunProfile struct {
+	// Concurrency is a number of tasks or a percentage of CPUs, e.g. "50%"
+	Concurrency string `json:"concurrency,omitempty"`
+	Continue    bool   `json:"continue,omitempty"`
+	// OutputLogs is a mode of --output-logs, e.g. "errors-only"
+	OutputLogs string `json:"outputLogs,omitempty"`
+	Force      bool   `json:"force,omitempty"`
+	NoCache    bool   `json:"noCache,omitempty"`
+	RemoteOnly bool   `json:"remoteOnly,omitempty"`
+}
+
+// Boundaries is a struct for deserializing .boundaries of configFile.
+// Tags label workspaces, and rules restrict which tags a tagged workspace may depend on.
+type Boundaries struct {
+	// Tags maps a workspace name to its tags
+	Tags  map[string][]string `json:"tags,omitempty"`
+	Rules []BoundaryRule      `json:"rules,omitempty"`
+}
+
+// BoundaryRule restricts the dependencies of the workspaces tagged with From
+type BoundaryRule struct {
+	From string `json:"from"`
+	// Allow, when set, lists the tags that dependencies must have at least one of
+	Allow []string `json:"allow,omitempty"`
+	// Deny lists the tags that dependencies must not have
+	Deny []string `json:"deny,omitempty"`
+}
+
+// WorkspaceDefinition is a struct for deserializing an entry of .workspaces of
+// configFile. It defines workspaces that have no package.json, whose tasks are
+// run with the shell rather than with the package manager.
+type WorkspaceDefinition struct {
+	// Packages are globs of the directories of the workspaces, relative to the
+	// repository root, e.g. "services/*"
+	Packages []string `json:"packages"`
+	// Manifest is the file that a directory must have to be a workspace, such
+	// as "go.mod" or "Cargo.toml"
+	Manifest string `json:"manifest"`
+	// NamePattern is a regular expression whose first group, matched against
+	// the manifest, is the name of the workspace, e.g. `^module\s+(\S+)`. The
+	// name of the directory is used when it is empty.
+	NamePattern string `json:"namePattern,omitempty"`
+	// Scripts are the commands of the tasks of every workspace
+	Scripts map[string]string `json:"scripts,omitempty"`
+	// Dependencies maps the name of a workspace to the names of the workspaces
+	// it depends on, which can be JavaScript packages too
+	Dependencies map[string][]string `json:"dependencies,omitempty"`
+}
+
+// validate checks the fields of the entry of .workspaces at index i
+func (wd WorkspaceDefinition) validate(i int) error {
+	if len(wd.Packages) == 0 {
+		return fmt.Errorf("Invalid entry %v in \"workspaces\": \"packages\" must list at least one glob", i)
+	}
+	if wd.Manifest == "" || strings.ContainsAny(wd.Manifest, "/\\") {
+		return fmt.Errorf("Invalid entry %v in \"workspaces\": \"manifest\" must be the name of a file, such as \"go.mod\"", i)
+	}
+	if wd.NamePattern != "" {
+		pattern, err := wd.CompileNamePattern()
+		if err != nil {
+			return fmt.Errorf("Invalid \"namePattern\" %q in \"workspaces\": %v", wd.NamePattern, err)
+		}
+		if pattern.NumSubexp() == 0 {
+			return fmt.Errorf("Invalid \"namePattern\" %q in \"workspaces\": it must have a group that matches the name", wd.NamePattern)
+		}
+	}
+	return nil
+}
+
+// CompileNamePattern compiles NamePattern, in which ^ and $ match at the start
+// and end of each line of the manifest
+func (wd WorkspaceDefinition) CompileNamePattern() (*regexp.Regexp, error) {
+	return regexp.Compile("(?m)" + wd.NamePattern)
 }
 
 // rawTaskWithDefaults exists to Marshal (i.e. turn a TaskDefinition into json).
@@ -76,6 +403,26 @@ type rawTaskWithDefaults struct {
 	OutputMode util.TaskOutputMode `json:"outputMode"`
 	Env        []string            `json:"env"`
 	Persistent bool                `json:"persistent"`
+	SetEnv     map[string]string   `json:"setEnv,omitempty"`
+	DotEnv     []string            `json:"dotEnv,omitempty"`
+	CacheLogs  *bool               `json:"cacheLogs,omitempty"`
+	Weight     int                 `json:"weight,omitempty"`
+	Priority   int                 `json:"priority,omitempty"`
+	// PassThroughEnv is omitted when empty, unlike Env
+	PassThroughEnv []string `json:"passThroughEnv,omitempty"`
+	Retries        int      `json:"retries,omitempty"`
+	RetryBackoff   int      `json:"retryBackoff,omitempty"`
+	Timeout        string   `json:"timeout,omitempty"`
+	// MaxOutputSize is a number of bytes
+	MaxOutputSize     string `json:"maxOutputSize,omitempty"`
+	MaxOutputSizeMode string `json:"maxOutputSizeMode,omitempty"`
+	Interactive       bool   `json:"interactive,omitempty"`
+	Label             string `json:"label,omitempty"`
+	// HashCommands are omitted when empty, like PassThroughEnv
+	HashCommands []string `json:"hashCommands,omitempty"`
+	Shell        string   `json:"shell,omitempty"`
+	Command      string   `json:"command,omitempty"`
+	Cwd          string   `json:"cwd,omitempty"`
 }
 
 // rawTask exists to Unmarshal from json. When fields are omitted, we _want_
@@ -88,8 +435,44 @@ type rawTask struct {
 	OutputMode *util.TaskOutputMode `json:"outputMode,omitempty"`
 	Env        []string             `json:"env,omitempty"`
 	Persistent *bool                `json:"persistent,omitempty"`
+	SetEnv     map[string]string    `json:"setEnv,omitempty"`
+	DotEnv     []string             `json:"dotEnv,omitempty"`
+	CacheLogs  *bool                `json:"cacheLogs,omitempty"`
+	Weight     *int                 `json:"weight,omitempty"`
+	Priority   *int                 `json:"priority,omitempty"`
+	// PassThroughEnv may use the same wildcards as Env
+	PassThroughEnv []string `json:"passThroughEnv,omitempty"`
+	Retries        *int     `json:"retries,omitempty"`
+	RetryBackoff   *int     `json:"retryBackoff,omitempty"`
+	// Timeout is a duration such as "10m"
+	Timeout *string `json:"timeout,omitempty"`
+	// MaxOutputSize is a size such as "5MB"
+	MaxOutputSize *string `json:"maxOutputSize,omitempty"`
+	// MaxOutputSizeMode is "error" or "warn"
+	MaxOutputSizeMode *string `json:"maxOutputSizeMode,omitempty"`
+	Interactive       *bool   `json:"interactive,omitempty"`
+	Label             *string `json:"label,omitempty"`
+	// HashCommands are shell commands whose output is part of the task's hash
+	HashCommands []string `json:"hashCommands,omitempty"`
+	// Shell is TaskShellBash, TaskShellPwsh or TaskShellNone
+	Shell *string `json:"shell,omitempty"`
+	// Command runs in place of the package.json script of the task
+	Command *string `json:"command,omitempty"`
+	// Cwd is relative to the package
+	Cwd *string `json:"cwd,omitempty"`
 }
 
+// Shells that a task's script can run with, in place of the package manager
+const (
+	// TaskShellBash runs the script with bash -c
+	TaskShellBash = "bash"
+	// TaskShellPwsh runs the script with pwsh -Command
+	TaskShellPwsh = "pwsh"
+	// TaskShellNone runs the script without a shell. Turbo splits it into
+	// words the same way on every platform, see run.splitPortableCommand.
+	TaskShellNone = "none"
+)
+
 // PristinePipeline contains original TaskDefinitions without the bookkeeping
 type PristinePipeline map[string]TaskDefinition
 
@@ -99,7 +482,9 @@ type Pipeline map[string]BookkeepingTaskDefinition
 // BookkeepingTaskDefinition holds the underlying TaskDefinition and some bookkeeping data
 // about the TaskDefinition. This wrapper struct allows us to leave TaskDefinition untouched.
 type BookkeepingTaskDefinition struct {
-	definedFields  util.Set
+	definedFields util.Set
+	// extendedFields are the definedFields that listed ExtendsMarker
+	extendedFields util.Set
 	TaskDefinition TaskDefinition
 }
 
@@ -133,6 +518,87 @@ type TaskDefinition struct {
 	// Persistent indicates whether the Task is expected to exit or not
 	// Tasks marked Persistent do not exit (e.g. --watch mode or dev servers)
 	Persistent bool
+
+	// Interactive connects the task's command to the terminal, so that it can
+	// prompt for input. Its output isn't captured, and it runs on its own.
+	Interactive bool
+
+	// Label groups the task with related tasks in the logs of a run, see
+	// LogsOptions
+	Label string
+
+	// SetEnv are environment variables set for the task's command. Values may
+	// contain placeholders such as ${TURBO_HASH}, see nodes.PackageTask.Env
+	SetEnv map[string]string
+
+	// DotEnv are env files that are loaded from the repository root, then from
+	// the task's package, and set for the task's command. See env.LoadDotEnv
+	DotEnv []string
+
+	// HashCommands are shell commands that run in the task's package while
+	// hashing, such as "rustc --version". Their output is part of the task's
+	// hash, for the inputs that aren't files or environment variables.
+	HashCommands []string
+
+	// ExcludeLogs keeps the task's log file out of its cache artifacts, so that
+	// cache hits do not replay its output. It is set with "cacheLogs": false.
+	ExcludeLogs bool
+
+	// PassThroughEnv are environment variables that the task reads, but that
+	// don't affect its hash. Like EnvVarDependencies, they may contain wildcards.
+	PassThroughEnv []string
+
+	// Weight is how many of the --concurrency slots the task takes while it
+	// runs. Unset (zero) counts as one slot.
+	Weight int
+
+	// Priority orders the tasks that are ready to run when there aren't enough
+	// --concurrency slots for all of them. Higher priorities start first.
+	Priority int
+
+	// Retries is how many more times the task's command runs after it fails
+	Retries int
+
+	// RetryBackoff is how many milliseconds to wait before the first retry.
+	// The wait doubles for every retry after that.
+	RetryBackoff int
+
+	// Timeout is how long the task's command may run before it is stopped and
+	// the task fails. Zero lets it run forever.
+	Timeout time.Duration
+
+	// MaxOutputSize is how many bytes the task's outputs may take up once it
+	// finishes, before the task fails. Zero is no limit.
+	MaxOutputSize int64
+
+	// WarnOnMaxOutputSize warns instead of failing the task when its outputs
+	// exceed MaxOutputSize. It is set with "maxOutputSizeMode": "warn".
+	WarnOnMaxOutputSize bool
+
+	// Shell runs the task's script with turbo in place of the package manager:
+	// TaskShellBash, TaskShellPwsh or TaskShellNone. Empty leaves it to the
+	// package manager.
+	Shell string
+
+	// Command runs in place of the task's script in package.json, with the
+	// task's Shell, or with sh -c (cmd /C on Windows) when it has none. The
+	// task then runs in packages that have no such script, or no package.json.
+	Command string
+
+	// Cwd is the directory the task's command runs in, relative to its
+	// package, in place of the package itself. It must be inside the
+	// repository.
+	Cwd string
+}
+
+// HasCommand returns whether the task has a command to run in pkg: a script
+// named taskName, or a "command" in place of the script
+func (c *TaskDefinition) HasCommand(pkg *PackageJSON, taskName string) bool {
+	if c.Command != "" {
+		return true
+	}
+	_, ok := pkg.Scripts[taskName]
+	return ok
 }
 
 // GetTask returns a TaskDefinition based on the ID (package#task format) or name (e.g. "build")
@@ -158,9 +624,9 @@ func (pc Pipeline) GetTask(taskID string, taskName string) (*BookkeepingTaskDefi
 // LoadTurboConfig loads, or optionally, synthesizes a TurboJSON instance
 func LoadTurboConfig(dir turbopath.AbsoluteSystemPath, rootPackageJSON *PackageJSON, includeSynthesizedFromRootPackageJSON bool) (*TurboJSON, error) {
 	// If the root package.json stil has a `turbo` key, log a warning and remove it.
-	if rootPackageJSON.LegacyTurboConfig != nil {
+	if rootPackageJSON.TurboConfig != nil {
 		log.Printf("[WARNING] \"turbo\" in package.json is no longer supported. Migrate to %s by running \"npx @turbo/codemod create-turbo-config\"\n", configFile)
-		rootPackageJSON.LegacyTurboConfig = nil
+		rootPackageJSON.TurboConfig = nil
 	}
 
 	var turboJSON *TurboJSON
@@ -219,6 +685,32 @@ func LoadTurboConfig(dir turbopath.AbsoluteSystemPath, rootPackageJSON *PackageJ
 	return turboJSON, nil
 }
 
+// LoadWorkspaceTurboConfig loads the configuration of a workspace other than the
+// root: its turbo.json, and the "pipeline" in the "turbo" key of its
+// package.json, which becomes PackageJSONPipeline. A workspace that only has
+// the "turbo" key extends the root turbo.json. It returns os.ErrNotExist if the
+// workspace has neither.
+func LoadWorkspaceTurboConfig(dir turbopath.AbsoluteSystemPath, packageJSON *PackageJSON) (*TurboJSON, error) {
+	turboJSON, err := readTurboConfig(dir.UntypedJoin(configFile))
+	packageJSONConfig := packageJSON.TurboConfig
+	if packageJSONConfig == nil {
+		return turboJSON, err
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		turboJSON = &TurboJSON{
+			Pipeline: make(Pipeline),
+			Extends:  []string{util.RootPkgName},
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	if len(packageJSONConfig.Extends) > 0 {
+		return nil, fmt.Errorf("package.json: \"extends\" is not supported in \"turbo\". Tasks in package.json apply over the ones in the workspace's %s, or in the root %s", configFile, configFile)
+	}
+	turboJSON.PackageJSONPipeline = packageJSONConfig.Pipeline
+	return turboJSON, nil
+}
+
 // TurboJSONValidation is the signature for a validation function passed to Validate()
 type TurboJSONValidation func(*TurboJSON) []error
 
@@ -251,6 +743,41 @@ func (to TaskOutputs) Sort() TaskOutputs {
 	return TaskOutputs{Inclusions: inclusions, Exclusions: exclusions}
 }
 
+// LoadRunProfile returns the profile with the given name from the turbo.json in dir
+func LoadRunProfile(dir turbopath.AbsoluteSystemPath, name string) (*RunProfile, error) {
+	turboJSON, err := readTurboConfig(dir.UntypedJoin(configFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("profile %q not found: there is no %s", name, configFile)
+	} else if err != nil {
+		return nil, err
+	}
+	profile, ok := turboJSON.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(turboJSON.Profiles))
+		for profileName := range turboJSON.Profiles {
+			names = append(names, profileName)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return nil, fmt.Errorf("profile %q not found: %s has no \"profiles\"", name, configFile)
+		}
+		return nil, fmt.Errorf("profile %q not found in %s, expected one of: %v", name, configFile, strings.Join(names, ", "))
+	}
+	return &profile, nil
+}
+
+// LoadWorkspaceDefinitions returns the workspaces defined in the turbo.json in
+// dir, or none if there is no turbo.json
+func LoadWorkspaceDefinitions(dir turbopath.AbsoluteSystemPath) ([]WorkspaceDefinition, error) {
+	turboJSON, err := readTurboConfig(dir.UntypedJoin(configFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return turboJSON.Workspaces, nil
+}
+
 // readTurboConfig reads turbo.json from a provided path
 func readTurboConfig(turboJSONPath turbopath.AbsoluteSystemPath) (*TurboJSON, error) {
 	// If the configFile exists, use that
@@ -331,9 +858,99 @@ func (btd BookkeepingTaskDefinition) hasField(fieldName string) bool {
 	return btd.definedFields.Includes(fieldName)
 }
 
+// _fieldKeys are the keys of a task in turbo.json that set each of the fields
+// in definedFields
+var _fieldKeys = map[string]string{
+	"Outputs":             "outputs",
+	"ShouldCache":         "cache",
+	"DependsOn":           "dependsOn",
+	"EnvVarDependencies":  "env",
+	"PassThroughEnv":      "passThroughEnv",
+	"Inputs":              "inputs",
+	"OutputMode":          "outputMode",
+	"Persistent":          "persistent",
+	"SetEnv":              "setEnv",
+	"DotEnv":              "dotEnv",
+	"ExcludeLogs":         "cacheLogs",
+	"Weight":              "weight",
+	"Priority":            "priority",
+	"Retries":             "retries",
+	"RetryBackoff":        "retryBackoff",
+	"Timeout":             "timeout",
+	"MaxOutputSize":       "maxOutputSize",
+	"WarnOnMaxOutputSize": "maxOutputSizeMode",
+	"Interactive":         "interactive",
+	"Label":               "label",
+	"HashCommands":        "hashCommands",
+	"Shell":               "shell",
+	"Command":             "command",
+	"Cwd":                 "cwd",
+}
+
+// DefinedKeys returns the keys, as written in turbo.json, that the definition
+// sets, sorted
+func (btd BookkeepingTaskDefinition) DefinedKeys() []string {
+	keys := []string{}
+	for field := range btd.definedFields {
+		keys = append(keys, _fieldKeys[field.(string)])
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ConflictingKeys returns the keys, as written in turbo.json, that both
+// definitions set, sorted
+func (btd BookkeepingTaskDefinition) ConflictingKeys(other BookkeepingTaskDefinition) []string {
+	keys := []string{}
+	for field := range btd.definedFields {
+		if other.hasField(field.(string)) {
+			keys = append(keys, _fieldKeys[field.(string)])
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// extendsField checks whether a field appends to the value it inherits
+func (btd BookkeepingTaskDefinition) extendsField(fieldName string) bool {
+	return btd.extendedFields.Includes(fieldName)
+}
+
+// stripExtendsMarker removes ExtendsMarker from values, and returns whether it was there
+func stripExtendsMarker(values []string) ([]string, bool) {
+	stripped := make([]string, 0, len(values))
+	for _, value := range values {
+		if value != ExtendsMarker {
+			stripped = append(stripped, value)
+		}
+	}
+	return stripped, len(stripped) != len(values)
+}
+
+// appendUnique returns inherited followed by the values of extra that aren't in it
+func appendUnique(inherited []string, extra []string) []string {
+	seen := make(util.Set, len(inherited))
+	merged := make([]string, 0, len(inherited)+len(extra))
+	for _, value := range append(append([]string{}, inherited...), extra...) {
+		if !seen.Includes(value) {
+			seen.Add(value)
+			merged = append(merged, value)
+		}
+	}
+	return merged
+}
+
+// appendSorted is appendUnique for the fields that are kept sorted
+func appendSorted(inherited []string, extra []string) []string {
+	merged := appendUnique(inherited, extra)
+	sort.Strings(merged)
+	return merged
+}
+
 // MergeTaskDefinitions accepts an array of BookkeepingTaskDefinitions and merges them into
 // a single TaskDefinition. It uses the bookkeeping definedFields to determine which fields should
-// be overwritten and when 0-values should be respected.
+// be overwritten and when 0-values should be respected. Fields that list ExtendsMarker are
+// appended to the merged value of the definitions before them instead.
 func MergeTaskDefinitions(taskDefinitions []BookkeepingTaskDefinition) (*TaskDefinition, error) {
 	// Start with an empty definition
 	mergedTaskDefinition := &TaskDefinition{}
@@ -346,7 +963,12 @@ func MergeTaskDefinitions(taskDefinitions []BookkeepingTaskDefinition) (*TaskDef
 	for _, bookkeepingTaskDef := range taskDefinitions {
 		taskDef := bookkeepingTaskDef.TaskDefinition
 
-		if bookkeepingTaskDef.hasField("Outputs") {
+		if bookkeepingTaskDef.extendsField("Outputs") {
+			mergedTaskDefinition.Outputs = TaskOutputs{
+				Inclusions: appendSorted(mergedTaskDefinition.Outputs.Inclusions, taskDef.Outputs.Inclusions),
+				Exclusions: appendSorted(mergedTaskDefinition.Outputs.Exclusions, taskDef.Outputs.Exclusions),
+			}
+		} else if bookkeepingTaskDef.hasField("Outputs") {
 			mergedTaskDefinition.Outputs = taskDef.Outputs
 		}
 
@@ -354,19 +976,26 @@ func MergeTaskDefinitions(taskDefinitions []BookkeepingTaskDefinition) (*TaskDef
 			mergedTaskDefinition.ShouldCache = taskDef.ShouldCache
 		}
 
-		if bookkeepingTaskDef.hasField("EnvVarDependencies") {
+		if bookkeepingTaskDef.extendsField("EnvVarDependencies") {
+			mergedTaskDefinition.EnvVarDependencies = appendSorted(mergedTaskDefinition.EnvVarDependencies, taskDef.EnvVarDependencies)
+		} else if bookkeepingTaskDef.hasField("EnvVarDependencies") {
 			mergedTaskDefinition.EnvVarDependencies = taskDef.EnvVarDependencies
 		}
 
-		if bookkeepingTaskDef.hasField("DependsOn") {
+		if bookkeepingTaskDef.extendsField("DependsOn") {
+			mergedTaskDefinition.TopologicalDependencies = appendSorted(mergedTaskDefinition.TopologicalDependencies, taskDef.TopologicalDependencies)
+			mergedTaskDefinition.TaskDependencies = appendSorted(mergedTaskDefinition.TaskDependencies, taskDef.TaskDependencies)
+		} else if bookkeepingTaskDef.hasField("DependsOn") {
 			mergedTaskDefinition.TopologicalDependencies = taskDef.TopologicalDependencies
-		}
-
-		if bookkeepingTaskDef.hasField("DependsOn") {
 			mergedTaskDefinition.TaskDependencies = taskDef.TaskDependencies
 		}
 
-		if bookkeepingTaskDef.hasField("Inputs") {
+		if bookkeepingTaskDef.extendsField("Inputs") {
+			// Without inputs, every file of the package is already an input
+			if len(mergedTaskDefinition.Inputs) > 0 {
+				mergedTaskDefinition.Inputs = appendUnique(mergedTaskDefinition.Inputs, taskDef.Inputs)
+			}
+		} else if bookkeepingTaskDef.hasField("Inputs") {
 			mergedTaskDefinition.Inputs = taskDef.Inputs
 		}
 
@@ -376,6 +1005,80 @@ func MergeTaskDefinitions(taskDefinitions []BookkeepingTaskDefinition) (*TaskDef
 		if bookkeepingTaskDef.hasField("Persistent") {
 			mergedTaskDefinition.Persistent = taskDef.Persistent
 		}
+
+		if bookkeepingTaskDef.hasField("SetEnv") {
+			mergedTaskDefinition.SetEnv = taskDef.SetEnv
+		}
+
+		if bookkeepingTaskDef.extendsField("DotEnv") {
+			mergedTaskDefinition.DotEnv = appendUnique(mergedTaskDefinition.DotEnv, taskDef.DotEnv)
+		} else if bookkeepingTaskDef.hasField("DotEnv") {
+			mergedTaskDefinition.DotEnv = taskDef.DotEnv
+		}
+
+		if bookkeepingTaskDef.hasField("ExcludeLogs") {
+			mergedTaskDefinition.ExcludeLogs = taskDef.ExcludeLogs
+		}
+
+		if bookkeepingTaskDef.extendsField("PassThroughEnv") {
+			mergedTaskDefinition.PassThroughEnv = appendSorted(mergedTaskDefinition.PassThroughEnv, taskDef.PassThroughEnv)
+		} else if bookkeepingTaskDef.hasField("PassThroughEnv") {
+			mergedTaskDefinition.PassThroughEnv = taskDef.PassThroughEnv
+		}
+
+		if bookkeepingTaskDef.hasField("Weight") {
+			mergedTaskDefinition.Weight = taskDef.Weight
+		}
+
+		if bookkeepingTaskDef.hasField("Priority") {
+			mergedTaskDefinition.Priority = taskDef.Priority
+		}
+
+		if bookkeepingTaskDef.hasField("Retries") {
+			mergedTaskDefinition.Retries = taskDef.Retries
+		}
+
+		if bookkeepingTaskDef.hasField("RetryBackoff") {
+			mergedTaskDefinition.RetryBackoff = taskDef.RetryBackoff
+		}
+
+		if bookkeepingTaskDef.hasField("Timeout") {
+			mergedTaskDefinition.Timeout = taskDef.Timeout
+		}
+
+		if bookkeepingTaskDef.hasField("MaxOutputSize") {
+			mergedTaskDefinition.MaxOutputSize = taskDef.MaxOutputSize
+		}
+
+		if bookkeepingTaskDef.hasField("WarnOnMaxOutputSize") {
+			mergedTaskDefinition.WarnOnMaxOutputSize = taskDef.WarnOnMaxOutputSize
+		}
+
+		if bookkeepingTaskDef.hasField("Interactive") {
+			mergedTaskDefinition.Interactive = taskDef.Interactive
+		}
+
+		if bookkeepingTaskDef.hasField("Label") {
+			mergedTaskDefinition.Label = taskDef.Label
+		}
+
+		if bookkeepingTaskDef.hasField("Shell") {
+			mergedTaskDefinition.Shell = taskDef.Shell
+		}
+
+		if bookkeepingTaskDef.hasField("Command") {
+			mergedTaskDefinition.Command = taskDef.Command
+		}
+
+		if bookkeepingTaskDef.hasField("Cwd") {
+			mergedTaskDefinition.Cwd = taskDef.Cwd
+		}
+
+		if bookkeepingTaskDef.extendsField("HashCommands") {
+			mergedTaskDefinition.HashCommands = appendUnique(mergedTaskDefinition.HashCommands, taskDef.HashCommands)
+		} else if bookkeepingTaskDef.hasField("HashCommands") {
+			mergedTaskDefinition.HashCommands = taskDef.HashCommands
+		}
 	}
 
 	return mergedTaskDefinition, nil
@@ -390,6 +1093,27 @@ func (btd *BookkeepingTaskDefinition) UnmarshalJSON(data []byte) error {
 	}
 
 	btd.definedFields = util.Set{}
+	btd.extendedFields = util.Set{}
+	// Strip ExtendsMarker from the arrays that can extend what they inherit,
+	// before their values are validated
+	for field, values := range map[string]*[]string{
+		"Outputs":            &task.Outputs,
+		"DependsOn":          &task.DependsOn,
+		"EnvVarDependencies": &task.Env,
+		"PassThroughEnv":     &task.PassThroughEnv,
+		"Inputs":             &task.Inputs,
+		"DotEnv":             &task.DotEnv,
+		"HashCommands":       &task.HashCommands,
+	} {
+		if *values == nil {
+			continue
+		}
+		stripped, extends := stripExtendsMarker(*values)
+		if extends {
+			btd.extendedFields.Add(field)
+		}
+		*values = stripped
+	}
 
 	if task.Outputs != nil {
 		var inclusions []string
@@ -473,13 +1197,24 @@ func (btd *BookkeepingTaskDefinition) UnmarshalJSON(data []byte) error {
 
 	sort.Strings(btd.TaskDefinition.EnvVarDependencies)
 
+	if task.PassThroughEnv != nil {
+		btd.definedFields.Add("PassThroughEnv")
+		for _, value := range task.PassThroughEnv {
+			if strings.HasPrefix(value, envPipelineDelimiter) {
+				return fmt.Errorf("You specified \"%s\" in the \"passThroughEnv\" key. You should not prefix your environment variables with \"$\"", value)
+			}
+		}
+		btd.TaskDefinition.PassThroughEnv = append([]string{}, task.PassThroughEnv...)
+		sort.Strings(btd.TaskDefinition.PassThroughEnv)
+	}
+
 	if task.Inputs != nil {
 		// Note that we don't require Inputs to be sorted, we're going to
 		// hash the resulting files and sort that instead
 		btd.definedFields.Add("Inputs")
 		// TODO: during rust port, this should be moved to a post-parse validation step
 		for _, input := range task.Inputs {
-			if filepath.IsAbs(input) {
+			if filepath.IsAbs(strings.TrimPrefix(input, "!")) {
 				log.Printf("[WARNING] Using an absolute path in \"inputs\" (%v) will not work and will be an error in a future version", input)
 			}
 		}
@@ -497,6 +1232,140 @@ func (btd *BookkeepingTaskDefinition) UnmarshalJSON(data []byte) error {
 	} else {
 		btd.TaskDefinition.Persistent = false
 	}
+
+	if task.SetEnv != nil {
+		btd.definedFields.Add("SetEnv")
+		for key := range task.SetEnv {
+			if key == "" || strings.Contains(key, "=") {
+				return fmt.Errorf("Invalid environment variable name %q in \"setEnv\"", key)
+			}
+		}
+		btd.TaskDefinition.SetEnv = task.SetEnv
+	}
+
+	if task.DotEnv != nil {
+		btd.definedFields.Add("DotEnv")
+		for _, file := range task.DotEnv {
+			clean := filepath.ToSlash(filepath.Clean(file))
+			if filepath.IsAbs(file) || clean == ".." || strings.HasPrefix(clean, "../") {
+				return fmt.Errorf("\"dotEnv\" files must be relative to the repository root and package directories, found %q", file)
+			}
+		}
+		btd.TaskDefinition.DotEnv = task.DotEnv
+	}
+
+	if task.CacheLogs != nil {
+		btd.definedFields.Add("ExcludeLogs")
+		btd.TaskDefinition.ExcludeLogs = !*task.CacheLogs
+	}
+
+	if task.Weight != nil {
+		btd.definedFields.Add("Weight")
+		if *task.Weight < 1 {
+			return fmt.Errorf("Invalid \"weight\" %v, expected a positive integer", *task.Weight)
+		}
+		btd.TaskDefinition.Weight = *task.Weight
+	}
+
+	if task.Priority != nil {
+		btd.definedFields.Add("Priority")
+		btd.TaskDefinition.Priority = *task.Priority
+	}
+
+	if task.Retries != nil {
+		btd.definedFields.Add("Retries")
+		if *task.Retries < 0 {
+			return fmt.Errorf("Invalid \"retries\" %v, expected zero or a positive integer", *task.Retries)
+		}
+		btd.TaskDefinition.Retries = *task.Retries
+	}
+
+	if task.RetryBackoff != nil {
+		btd.definedFields.Add("RetryBackoff")
+		if *task.RetryBackoff < 0 {
+			return fmt.Errorf("Invalid \"retryBackoff\" %v, expected a number of milliseconds", *task.RetryBackoff)
+		}
+		btd.TaskDefinition.RetryBackoff = *task.RetryBackoff
+	}
+
+	if task.Timeout != nil {
+		btd.definedFields.Add("Timeout")
+		timeout, err := time.ParseDuration(*task.Timeout)
+		if err != nil || timeout < 0 {
+			return fmt.Errorf("Invalid \"timeout\" %q, expected a duration such as \"10m\" or \"90s\"", *task.Timeout)
+		}
+		btd.TaskDefinition.Timeout = timeout
+	}
+
+	if task.MaxOutputSize != nil {
+		btd.definedFields.Add("MaxOutputSize")
+		// "0" turns off a limit that is inherited
+		if strings.TrimSpace(*task.MaxOutputSize) != "0" {
+			maxOutputSize, err := client.ParseSize(*task.MaxOutputSize)
+			if err != nil {
+				return fmt.Errorf("Invalid \"maxOutputSize\" %q, expected a size such as \"5MB\" or \"500KiB\"", *task.MaxOutputSize)
+			}
+			btd.TaskDefinition.MaxOutputSize = maxOutputSize
+		}
+	}
+
+	if task.MaxOutputSizeMode != nil {
+		btd.definedFields.Add("WarnOnMaxOutputSize")
+		switch *task.MaxOutputSizeMode {
+		case "error":
+			btd.TaskDefinition.WarnOnMaxOutputSize = false
+		case "warn":
+			btd.TaskDefinition.WarnOnMaxOutputSize = true
+		default:
+			return fmt.Errorf("Invalid \"maxOutputSizeMode\" %q, expected \"error\" or \"warn\"", *task.MaxOutputSizeMode)
+		}
+	}
+
+	if task.Interactive != nil {
+		btd.definedFields.Add("Interactive")
+		btd.TaskDefinition.Interactive = *task.Interactive
+	}
+
+	if task.Label != nil {
+		btd.definedFields.Add("Label")
+		btd.TaskDefinition.Label = *task.Label
+	}
+
+	if task.HashCommands != nil {
+		btd.definedFields.Add("HashCommands")
+		for _, command := range task.HashCommands {
+			if strings.TrimSpace(command) == "" {
+				return fmt.Errorf("\"hashCommands\" can't contain empty commands")
+			}
+		}
+		btd.TaskDefinition.HashCommands = task.HashCommands
+	}
+
+	if task.Shell != nil {
+		btd.definedFields.Add("Shell")
+		switch *task.Shell {
+		case TaskShellBash, TaskShellPwsh, TaskShellNone:
+			btd.TaskDefinition.Shell = *task.Shell
+		default:
+			return fmt.Errorf("Invalid \"shell\" %q, expected %q, %q or %q", *task.Shell, TaskShellBash, TaskShellPwsh, TaskShellNone)
+		}
+	}
+
+	if task.Command != nil {
+		btd.definedFields.Add("Command")
+		if strings.TrimSpace(*task.Command) == "" {
+			return fmt.Errorf("\"command\" can't be empty")
+		}
+		btd.TaskDefinition.Command = *task.Command
+	}
+
+	if task.Cwd != nil {
+		btd.definedFields.Add("Cwd")
+		if filepath.IsAbs(*task.Cwd) || strings.HasPrefix(filepath.ToSlash(*task.Cwd), "/") {
+			return fmt.Errorf("Invalid \"cwd\" %q, expected a path relative to the package", *task.Cwd)
+		}
+		btd.TaskDefinition.Cwd = *task.Cwd
+	}
 	return nil
 }
 
@@ -511,6 +1380,33 @@ func (c TaskDefinition) MarshalJSON() ([]byte, error) {
 	}
 
 	task.Persistent = c.Persistent
+	task.Interactive = c.Interactive
+	task.Label = c.Label
+	task.SetEnv = c.SetEnv
+	task.DotEnv = c.DotEnv
+	task.HashCommands = c.HashCommands
+	task.Shell = c.Shell
+	task.Command = c.Command
+	task.Cwd = c.Cwd
+	if c.ExcludeLogs {
+		cacheLogs := false
+		task.CacheLogs = &cacheLogs
+	}
+	task.Weight = c.Weight
+	task.Priority = c.Priority
+	task.PassThroughEnv = c.PassThroughEnv
+	task.Retries = c.Retries
+	task.RetryBackoff = c.RetryBackoff
+	if c.Timeout > 0 {
+		task.Timeout = c.Timeout.String()
+	}
+	if c.MaxOutputSize > 0 {
+		task.MaxOutputSize = strconv.FormatInt(c.MaxOutputSize, 10)
+		task.MaxOutputSizeMode = "error"
+		if c.WarnOnMaxOutputSize {
+			task.MaxOutputSizeMode = "warn"
+		}
+	}
 	task.Cache = &c.ShouldCache
 	task.OutputMode = c.OutputMode
 
@@ -582,6 +1478,37 @@ func (c *TurboJSON) UnmarshalJSON(data []byte) error {
 		}
 	}
 
+	switch raw.RemoteCacheOptions.SignatureAlgorithm {
+	case "", SignatureHMACSHA256, SignatureEd25519:
+	default:
+		return fmt.Errorf("Invalid \"signatureAlgorithm\" %q in \"remoteCache\", expected %q or %q", raw.RemoteCacheOptions.SignatureAlgorithm, SignatureHMACSHA256, SignatureEd25519)
+	}
+	compression := raw.RemoteCacheOptions.Compression
+	if compression == "" {
+		compression = CompressionZstd
+	}
+	maxLevel, ok := MaxCompressionLevel[compression]
+	if !ok {
+		return fmt.Errorf("Invalid \"compression\" %q in \"remoteCache\", expected %q or %q", compression, CompressionZstd, CompressionGzip)
+	}
+	if level := raw.RemoteCacheOptions.CompressionLevel; level < 0 || level > maxLevel {
+		return fmt.Errorf("Invalid \"compressionLevel\" %v in \"remoteCache\", expected a level from 1 to %v for %v", level, maxLevel, compression)
+	}
+	if err := raw.RemoteCacheOptions.Auth.validate(); err != nil {
+		return err
+	}
+	for i, definition := range raw.Workspaces {
+		if err := definition.validate(i); err != nil {
+			return err
+		}
+	}
+	if err := raw.Hashing.validate(); err != nil {
+		return err
+	}
+	if color := raw.Logs.PrefixColor; color != "" && color != LogPrefixColorPackage && color != LogPrefixColorStatus {
+		return fmt.Errorf("Invalid \"prefixColor\" %q in \"logs\", expected %q or %q", color, LogPrefixColorPackage, LogPrefixColorStatus)
+	}
+
 	// turn the set into an array and assign to the TurboJSON struct fields.
 	c.GlobalEnv = envVarDependencies.UnsafeListOfStrings()
 	sort.Strings(c.GlobalEnv)
@@ -591,6 +1518,16 @@ func (c *TurboJSON) UnmarshalJSON(data []byte) error {
 	// copy these over, we don't need any changes here.
 	c.Pipeline = raw.Pipeline
 	c.RemoteCacheOptions = raw.RemoteCacheOptions
+	c.Hooks = raw.Hooks
+	c.Boundaries = raw.Boundaries
+	c.Notifications = raw.Notifications
+	c.Webhooks = raw.Webhooks
+	c.Analytics = raw.Analytics
+	c.Summaries = raw.Summaries
+	c.Hashing = raw.Hashing
+	c.Logs = raw.Logs
+	c.Profiles = raw.Profiles
+	c.Workspaces = raw.Workspaces
 	c.Extends = raw.Extends
 
 	return nil
@@ -604,6 +1541,16 @@ func (c *TurboJSON) MarshalJSON() ([]byte, error) {
 	raw.GlobalEnv = c.GlobalEnv
 	raw.Pipeline = c.Pipeline.Pristine()
 	raw.RemoteCacheOptions = c.RemoteCacheOptions
+	raw.Hooks = c.Hooks
+	raw.Boundaries = c.Boundaries
+	raw.Notifications = c.Notifications
+	raw.Webhooks = c.Webhooks
+	raw.Analytics = c.Analytics
+	raw.Summaries = c.Summaries
+	raw.Hashing = c.Hashing
+	raw.Logs = c.Logs
+	raw.Profiles = c.Profiles
+	raw.Workspaces = c.Workspaces
 
 	return json.Marshal(&raw)
 }
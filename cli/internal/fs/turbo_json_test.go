@@ -1,11 +1,13 @@
 package fs
 
 import (
+	"encoding/json"
 	"os"
 	"reflect"
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/vercel/turbo/cli/internal/turbopath"
@@ -84,7 +86,7 @@ func Test_ReadTurboConfig(t *testing.T) {
 	}
 
 	validateOutput(t, turboJSON, pipelineExpected)
-	remoteCacheOptionsExpected := RemoteCacheOptions{"team_id", true}
+	remoteCacheOptionsExpected := RemoteCacheOptions{TeamID: "team_id", Signature: true}
 	assert.EqualValues(t, remoteCacheOptionsExpected, turboJSON.RemoteCacheOptions)
 }
 
@@ -134,9 +136,9 @@ func Test_LoadTurboConfig_BothCorrectAndLegacy(t *testing.T) {
 
 	validateOutput(t, turboJSON, pipelineExpected)
 
-	remoteCacheOptionsExpected := RemoteCacheOptions{"team_id", true}
+	remoteCacheOptionsExpected := RemoteCacheOptions{TeamID: "team_id", Signature: true}
 	assert.EqualValues(t, remoteCacheOptionsExpected, turboJSON.RemoteCacheOptions)
-	assert.Equal(t, rootPackageJSON.LegacyTurboConfig == nil, true)
+	assert.Equal(t, rootPackageJSON.TurboConfig == nil, true)
 }
 
 func Test_ReadTurboConfig_InvalidEnvDeclarations1(t *testing.T) {
@@ -252,3 +254,409 @@ func sortedArray(arr []string) []string {
 	sort.Strings(arr)
 	return arr
 }
+
+func Test_SetEnv(t *testing.T) {
+	root := BookkeepingTaskDefinition{}
+	assert.NoError(t, root.UnmarshalJSON([]byte(`{"setEnv": {"NODE_OPTIONS": "--max-old-space-size=4096", "BUILD_ID": "${TURBO_HASH}"}}`)))
+	workspace := BookkeepingTaskDefinition{}
+	assert.NoError(t, workspace.UnmarshalJSON([]byte(`{"setEnv": {"BUILD_ID": "web-${TURBO_HASH}"}}`)))
+	withoutSetEnv := BookkeepingTaskDefinition{}
+	assert.NoError(t, withoutSetEnv.UnmarshalJSON([]byte(`{"outputs": ["dist/**"]}`)))
+
+	merged, err := MergeTaskDefinitions([]BookkeepingTaskDefinition{root, withoutSetEnv})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"NODE_OPTIONS": "--max-old-space-size=4096", "BUILD_ID": "${TURBO_HASH}"}, merged.SetEnv)
+
+	merged, err = MergeTaskDefinitions([]BookkeepingTaskDefinition{root, workspace})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"BUILD_ID": "web-${TURBO_HASH}"}, merged.SetEnv, "setEnv is replaced, not merged, like other keys")
+
+	invalid := BookkeepingTaskDefinition{}
+	assert.EqualError(t, invalid.UnmarshalJSON([]byte(`{"setEnv": {"A=B": "c"}}`)), "Invalid environment variable name \"A=B\" in \"setEnv\"")
+}
+
+func Test_HashCommands(t *testing.T) {
+	root := BookkeepingTaskDefinition{}
+	assert.NoError(t, root.UnmarshalJSON([]byte(`{"hashCommands": ["rustc --version"]}`)))
+	workspace := BookkeepingTaskDefinition{}
+	assert.NoError(t, workspace.UnmarshalJSON([]byte(`{"hashCommands": ["$TURBO_EXTENDS$", "docker image inspect --format {{.Id}} base"]}`)))
+
+	merged, err := MergeTaskDefinitions([]BookkeepingTaskDefinition{root, workspace})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"rustc --version", "docker image inspect --format {{.Id}} base"}, merged.HashCommands)
+
+	marshaled, err := json.Marshal(root.TaskDefinition)
+	assert.NoError(t, err)
+	assert.Contains(t, string(marshaled), `"hashCommands":["rustc --version"]`)
+
+	invalid := BookkeepingTaskDefinition{}
+	assert.EqualError(t, invalid.UnmarshalJSON([]byte(`{"hashCommands": [" "]}`)), "\"hashCommands\" can't contain empty commands")
+}
+
+func Test_CacheLogs(t *testing.T) {
+	root := BookkeepingTaskDefinition{}
+	assert.NoError(t, root.UnmarshalJSON([]byte(`{"outputs": ["dist/**"], "cacheLogs": false}`)))
+	workspace := BookkeepingTaskDefinition{}
+	assert.NoError(t, workspace.UnmarshalJSON([]byte(`{"cacheLogs": true}`)))
+	withoutCacheLogs := BookkeepingTaskDefinition{}
+	assert.NoError(t, withoutCacheLogs.UnmarshalJSON([]byte(`{"outputs": ["lib/**"]}`)))
+
+	merged, err := MergeTaskDefinitions([]BookkeepingTaskDefinition{withoutCacheLogs})
+	assert.NoError(t, err)
+	assert.False(t, merged.ExcludeLogs, "logs are cached by default")
+
+	merged, err = MergeTaskDefinitions([]BookkeepingTaskDefinition{root, withoutCacheLogs})
+	assert.NoError(t, err)
+	assert.True(t, merged.ExcludeLogs)
+
+	merged, err = MergeTaskDefinitions([]BookkeepingTaskDefinition{root, workspace})
+	assert.NoError(t, err)
+	assert.False(t, merged.ExcludeLogs)
+
+	marshaled, err := json.Marshal(root.TaskDefinition)
+	assert.NoError(t, err)
+	assert.Contains(t, string(marshaled), `"cacheLogs":false`)
+}
+
+func Test_Weight(t *testing.T) {
+	root := BookkeepingTaskDefinition{}
+	assert.NoError(t, root.UnmarshalJSON([]byte(`{"weight": 4}`)))
+	workspace := BookkeepingTaskDefinition{}
+	assert.NoError(t, workspace.UnmarshalJSON([]byte(`{"outputs": ["lib/**"]}`)))
+
+	merged, err := MergeTaskDefinitions([]BookkeepingTaskDefinition{root, workspace})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, merged.Weight, "weight is kept when a workspace does not set it")
+
+	marshaled, err := json.Marshal(root.TaskDefinition)
+	assert.NoError(t, err)
+	assert.Contains(t, string(marshaled), `"weight":4`)
+	marshaled, err = json.Marshal(workspace.TaskDefinition)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(marshaled), `"weight"`)
+
+	invalid := BookkeepingTaskDefinition{}
+	assert.EqualError(t, invalid.UnmarshalJSON([]byte(`{"weight": 0}`)), "Invalid \"weight\" 0, expected a positive integer")
+}
+
+func Test_Interactive(t *testing.T) {
+	root := BookkeepingTaskDefinition{}
+	assert.NoError(t, root.UnmarshalJSON([]byte(`{"interactive": true, "cache": false}`)))
+	workspace := BookkeepingTaskDefinition{}
+	assert.NoError(t, workspace.UnmarshalJSON([]byte(`{"outputs": ["src/generated/**"]}`)))
+
+	merged, err := MergeTaskDefinitions([]BookkeepingTaskDefinition{root, workspace})
+	assert.NoError(t, err)
+	assert.True(t, merged.Interactive, "interactive is kept when a workspace does not set it")
+
+	marshaled, err := json.Marshal(root.TaskDefinition)
+	assert.NoError(t, err)
+	assert.Contains(t, string(marshaled), `"interactive":true`)
+	marshaled, err = json.Marshal(workspace.TaskDefinition)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(marshaled), `"interactive"`)
+}
+
+func Test_Priority(t *testing.T) {
+	root := BookkeepingTaskDefinition{}
+	assert.NoError(t, root.UnmarshalJSON([]byte(`{"priority": 10}`)))
+	workspace := BookkeepingTaskDefinition{}
+	assert.NoError(t, workspace.UnmarshalJSON([]byte(`{"priority": -1}`)))
+
+	merged, err := MergeTaskDefinitions([]BookkeepingTaskDefinition{root})
+	assert.NoError(t, err)
+	assert.Equal(t, 10, merged.Priority)
+	merged, err = MergeTaskDefinitions([]BookkeepingTaskDefinition{root, workspace})
+	assert.NoError(t, err)
+	assert.Equal(t, -1, merged.Priority, "workspaces can lower the priority of a task")
+
+	marshaled, err := json.Marshal(root.TaskDefinition)
+	assert.NoError(t, err)
+	assert.Contains(t, string(marshaled), `"priority":10`)
+}
+
+func Test_PassThroughEnv(t *testing.T) {
+	root := BookkeepingTaskDefinition{}
+	assert.NoError(t, root.UnmarshalJSON([]byte(`{"env": ["NEXT_PUBLIC_*"], "passThroughEnv": ["AWS_SECRET_ACCESS_KEY", "AWS_ACCESS_KEY_ID"]}`)))
+	workspace := BookkeepingTaskDefinition{}
+	assert.NoError(t, workspace.UnmarshalJSON([]byte(`{"passThroughEnv": ["SENTRY_*"]}`)))
+
+	assert.Equal(t, []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY"}, root.TaskDefinition.PassThroughEnv)
+	assert.Equal(t, []string{"NEXT_PUBLIC_*"}, root.TaskDefinition.EnvVarDependencies)
+
+	merged, err := MergeTaskDefinitions([]BookkeepingTaskDefinition{root, workspace})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"SENTRY_*"}, merged.PassThroughEnv, "passThroughEnv is replaced, not merged, like env")
+	assert.Equal(t, []string{"NEXT_PUBLIC_*"}, merged.EnvVarDependencies)
+
+	marshaled, err := json.Marshal(workspace.TaskDefinition)
+	assert.NoError(t, err)
+	assert.Contains(t, string(marshaled), `"passThroughEnv":["SENTRY_*"]`)
+
+	invalid := BookkeepingTaskDefinition{}
+	assert.EqualError(t, invalid.UnmarshalJSON([]byte(`{"passThroughEnv": ["$TOKEN"]}`)), "You specified \"$TOKEN\" in the \"passThroughEnv\" key. You should not prefix your environment variables with \"$\"")
+}
+
+func Test_Retries(t *testing.T) {
+	root := BookkeepingTaskDefinition{}
+	assert.NoError(t, root.UnmarshalJSON([]byte(`{"retries": 2, "retryBackoff": 500}`)))
+	workspace := BookkeepingTaskDefinition{}
+	assert.NoError(t, workspace.UnmarshalJSON([]byte(`{"retries": 0}`)))
+
+	merged, err := MergeTaskDefinitions([]BookkeepingTaskDefinition{root, workspace})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, merged.Retries, "a workspace can turn retries off")
+	assert.Equal(t, 500, merged.RetryBackoff, "retryBackoff is kept when a workspace does not set it")
+
+	marshaled, err := json.Marshal(root.TaskDefinition)
+	assert.NoError(t, err)
+	assert.Contains(t, string(marshaled), `"retries":2,"retryBackoff":500`)
+
+	invalid := BookkeepingTaskDefinition{}
+	assert.EqualError(t, invalid.UnmarshalJSON([]byte(`{"retries": -1}`)), "Invalid \"retries\" -1, expected zero or a positive integer")
+	assert.EqualError(t, invalid.UnmarshalJSON([]byte(`{"retryBackoff": -5}`)), "Invalid \"retryBackoff\" -5, expected a number of milliseconds")
+}
+
+func Test_Timeout(t *testing.T) {
+	root := BookkeepingTaskDefinition{}
+	assert.NoError(t, root.UnmarshalJSON([]byte(`{"timeout": "10m"}`)))
+	assert.Equal(t, 10*time.Minute, root.TaskDefinition.Timeout)
+	workspace := BookkeepingTaskDefinition{}
+	assert.NoError(t, workspace.UnmarshalJSON([]byte(`{"timeout": "0"}`)))
+
+	merged, err := MergeTaskDefinitions([]BookkeepingTaskDefinition{root, workspace})
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), merged.Timeout, "a workspace can turn the timeout off")
+
+	marshaled, err := json.Marshal(root.TaskDefinition)
+	assert.NoError(t, err)
+	assert.Contains(t, string(marshaled), `"timeout":"10m0s"`)
+
+	invalid := BookkeepingTaskDefinition{}
+	assert.EqualError(t, invalid.UnmarshalJSON([]byte(`{"timeout": "10"}`)), "Invalid \"timeout\" \"10\", expected a duration such as \"10m\" or \"90s\"")
+	assert.EqualError(t, invalid.UnmarshalJSON([]byte(`{"timeout": "-1m"}`)), "Invalid \"timeout\" \"-1m\", expected a duration such as \"10m\" or \"90s\"")
+}
+
+func Test_MaxOutputSize(t *testing.T) {
+	root := BookkeepingTaskDefinition{}
+	assert.NoError(t, root.UnmarshalJSON([]byte(`{"maxOutputSize": "5MB"}`)))
+	assert.Equal(t, int64(5_000_000), root.TaskDefinition.MaxOutputSize)
+	assert.False(t, root.TaskDefinition.WarnOnMaxOutputSize)
+	workspace := BookkeepingTaskDefinition{}
+	assert.NoError(t, workspace.UnmarshalJSON([]byte(`{"maxOutputSizeMode": "warn"}`)))
+
+	merged, err := MergeTaskDefinitions([]BookkeepingTaskDefinition{root, workspace})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5_000_000), merged.MaxOutputSize, "maxOutputSize is kept when a workspace does not set it")
+	assert.True(t, merged.WarnOnMaxOutputSize)
+
+	marshaled, err := json.Marshal(merged)
+	assert.NoError(t, err)
+	assert.Contains(t, string(marshaled), `"maxOutputSize":"5000000","maxOutputSizeMode":"warn"`)
+
+	unlimited := BookkeepingTaskDefinition{}
+	assert.NoError(t, unlimited.UnmarshalJSON([]byte(`{"maxOutputSize": "0"}`)))
+	merged, err = MergeTaskDefinitions([]BookkeepingTaskDefinition{root, unlimited})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), merged.MaxOutputSize, "a workspace can turn the limit off")
+
+	invalid := BookkeepingTaskDefinition{}
+	assert.EqualError(t, invalid.UnmarshalJSON([]byte(`{"maxOutputSize": "big"}`)), "Invalid \"maxOutputSize\" \"big\", expected a size such as \"5MB\" or \"500KiB\"")
+	assert.EqualError(t, invalid.UnmarshalJSON([]byte(`{"maxOutputSizeMode": "fail"}`)), "Invalid \"maxOutputSizeMode\" \"fail\", expected \"error\" or \"warn\"")
+}
+
+func Test_Shell(t *testing.T) {
+	root := BookkeepingTaskDefinition{}
+	assert.NoError(t, root.UnmarshalJSON([]byte(`{"shell": "bash"}`)))
+	assert.Equal(t, TaskShellBash, root.TaskDefinition.Shell)
+	workspace := BookkeepingTaskDefinition{}
+	assert.NoError(t, workspace.UnmarshalJSON([]byte(`{"outputs": ["dist/**"]}`)))
+
+	merged, err := MergeTaskDefinitions([]BookkeepingTaskDefinition{root, workspace})
+	assert.NoError(t, err)
+	assert.Equal(t, TaskShellBash, merged.Shell, "shell is kept when a workspace does not set it")
+
+	direct := BookkeepingTaskDefinition{}
+	assert.NoError(t, direct.UnmarshalJSON([]byte(`{"shell": "none"}`)))
+	merged, err = MergeTaskDefinitions([]BookkeepingTaskDefinition{root, direct})
+	assert.NoError(t, err)
+	assert.Equal(t, TaskShellNone, merged.Shell)
+
+	marshaled, err := json.Marshal(merged)
+	assert.NoError(t, err)
+	assert.Contains(t, string(marshaled), `"shell":"none"`)
+
+	invalid := BookkeepingTaskDefinition{}
+	assert.EqualError(t, invalid.UnmarshalJSON([]byte(`{"shell": "cmd"}`)), "Invalid \"shell\" \"cmd\", expected \"bash\", \"pwsh\" or \"none\"")
+}
+
+func Test_CommandAndCwd(t *testing.T) {
+	root := BookkeepingTaskDefinition{}
+	assert.NoError(t, root.UnmarshalJSON([]byte(`{"command": "protoc --go_out=. api.proto", "cwd": "../../proto"}`)))
+	assert.Equal(t, "protoc --go_out=. api.proto", root.TaskDefinition.Command)
+	assert.Equal(t, "../../proto", root.TaskDefinition.Cwd)
+	workspace := BookkeepingTaskDefinition{}
+	assert.NoError(t, workspace.UnmarshalJSON([]byte(`{"cwd": "generated"}`)))
+
+	merged, err := MergeTaskDefinitions([]BookkeepingTaskDefinition{root, workspace})
+	assert.NoError(t, err)
+	assert.Equal(t, "protoc --go_out=. api.proto", merged.Command, "command is kept when a workspace does not set it")
+	assert.Equal(t, "generated", merged.Cwd)
+
+	marshaled, err := json.Marshal(merged)
+	assert.NoError(t, err)
+	assert.Contains(t, string(marshaled), `"command":"protoc --go_out=. api.proto","cwd":"generated"`)
+
+	invalid := BookkeepingTaskDefinition{}
+	assert.EqualError(t, invalid.UnmarshalJSON([]byte(`{"command": " "}`)), "\"command\" can't be empty")
+	assert.EqualError(t, invalid.UnmarshalJSON([]byte(`{"cwd": "/tmp"}`)), "Invalid \"cwd\" \"/tmp\", expected a path relative to the package")
+}
+
+func Test_SignatureAlgorithm(t *testing.T) {
+	var turboJSON TurboJSON
+	assert.NoError(t, json.Unmarshal([]byte(`{"remoteCache": {"signature": true, "signatureAlgorithm": "ed25519"}}`), &turboJSON))
+	assert.Equal(t, SignatureEd25519, turboJSON.RemoteCacheOptions.SignatureAlgorithm)
+
+	err := json.Unmarshal([]byte(`{"remoteCache": {"signature": true, "signatureAlgorithm": "rsa"}}`), &turboJSON)
+	assert.EqualError(t, err, "Invalid \"signatureAlgorithm\" \"rsa\" in \"remoteCache\", expected \"hmac-sha256\" or \"ed25519\"")
+}
+
+func Test_Compression(t *testing.T) {
+	var turboJSON TurboJSON
+	assert.NoError(t, json.Unmarshal([]byte(`{"remoteCache": {"compression": "gzip", "compressionLevel": 9}}`), &turboJSON))
+	assert.Equal(t, CompressionGzip, turboJSON.RemoteCacheOptions.Compression)
+	assert.Equal(t, 9, turboJSON.RemoteCacheOptions.CompressionLevel)
+
+	err := json.Unmarshal([]byte(`{"remoteCache": {"compression": "brotli"}}`), &turboJSON)
+	assert.EqualError(t, err, "Invalid \"compression\" \"brotli\" in \"remoteCache\", expected \"zstd\" or \"gzip\"")
+	err = json.Unmarshal([]byte(`{"remoteCache": {"compressionLevel": 22}}`), &turboJSON)
+	assert.EqualError(t, err, "Invalid \"compressionLevel\" 22 in \"remoteCache\", expected a level from 1 to 20 for zstd")
+}
+
+func Test_RemoteCacheAuth(t *testing.T) {
+	var turboJSON TurboJSON
+	assert.NoError(t, json.Unmarshal([]byte(`{"remoteCache": {"auth": {"issuer": "https://sso.example.com", "clientId": "turbo"}}}`), &turboJSON))
+	assert.Equal(t, &AuthOptions{Issuer: "https://sso.example.com", ClientID: "turbo"}, turboJSON.RemoteCacheOptions.Auth)
+
+	err := json.Unmarshal([]byte(`{"remoteCache": {"auth": {"issuer": "sso.example.com", "clientId": "turbo"}}}`), &turboJSON)
+	assert.EqualError(t, err, "Invalid \"issuer\" \"sso.example.com\" in \"remoteCache.auth\", expected a URL such as \"https://sso.example.com\"")
+	err = json.Unmarshal([]byte(`{"remoteCache": {"auth": {"issuer": "https://sso.example.com"}}}`), &turboJSON)
+	assert.EqualError(t, err, "Missing \"clientId\" in \"remoteCache.auth\"")
+}
+
+func Test_Hashing(t *testing.T) {
+	var turboJSON TurboJSON
+	assert.NoError(t, json.Unmarshal([]byte(`{}`), &turboJSON))
+	assert.Equal(t, DefaultHashVersion, turboJSON.Hashing.HashVersion())
+
+	turboJSON = TurboJSON{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"hashing": {"version": 2, "fallbackVersion": 1}}`), &turboJSON))
+	assert.Equal(t, HashVersion2, turboJSON.Hashing.HashVersion())
+	assert.Equal(t, HashVersion1, turboJSON.Hashing.FallbackVersion)
+
+	turboJSON = TurboJSON{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"hashing": {"version": 4}}`), &turboJSON))
+	assert.Equal(t, HashVersion4, turboJSON.Hashing.HashVersion())
+
+	err := json.Unmarshal([]byte(`{"hashing": {"version": 5}}`), &turboJSON)
+	assert.EqualError(t, err, "Invalid \"version\" 5 in \"hashing\", expected a version from 1 to 4")
+	err = json.Unmarshal([]byte(`{"hashing": {"fallbackVersion": 1}}`), &turboJSON)
+	assert.EqualError(t, err, "Invalid \"fallbackVersion\" 1 in \"hashing\", expected a version other than the one tasks are hashed with")
+
+	turboJSON = TurboJSON{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"hashing": {"workers": 4}}`), &turboJSON))
+	assert.Equal(t, 4, turboJSON.Hashing.WorkerCount())
+	err = json.Unmarshal([]byte(`{"hashing": {"workers": -1}}`), &turboJSON)
+	assert.EqualError(t, err, "Invalid \"workers\" -1 in \"hashing\", expected a number greater than or equal to 1")
+}
+
+func Test_Logs(t *testing.T) {
+	var turboJSON TurboJSON
+	assert.NoError(t, json.Unmarshal([]byte(`{"logs": {"prefix": "[{label}] {task}: ", "prefixColor": "status"}, "pipeline": {"lint": {"label": "checks"}}}`), &turboJSON))
+	assert.Equal(t, "[{label}] {task}: ", turboJSON.Logs.Prefix)
+	assert.Equal(t, LogPrefixColorStatus, turboJSON.Logs.PrefixColor)
+	assert.Equal(t, "checks", turboJSON.Pipeline["lint"].TaskDefinition.Label)
+
+	err := json.Unmarshal([]byte(`{"logs": {"prefixColor": "rainbow"}}`), &turboJSON)
+	assert.EqualError(t, err, "Invalid \"prefixColor\" \"rainbow\" in \"logs\", expected \"package\" or \"status\"")
+}
+
+func Test_Workspaces(t *testing.T) {
+	var turboJSON TurboJSON
+	assert.NoError(t, json.Unmarshal([]byte(`{"workspaces": [{"packages": ["services/*"], "manifest": "go.mod", "namePattern": "^module (\\S+)"}]}`), &turboJSON))
+	assert.Equal(t, []WorkspaceDefinition{{Packages: []string{"services/*"}, Manifest: "go.mod", NamePattern: "^module (\\S+)"}}, turboJSON.Workspaces)
+
+	err := json.Unmarshal([]byte(`{"workspaces": [{"manifest": "go.mod"}]}`), &turboJSON)
+	assert.EqualError(t, err, "Invalid entry 0 in \"workspaces\": \"packages\" must list at least one glob")
+	err = json.Unmarshal([]byte(`{"workspaces": [{"packages": ["services/*"], "manifest": "services/go.mod"}]}`), &turboJSON)
+	assert.EqualError(t, err, "Invalid entry 0 in \"workspaces\": \"manifest\" must be the name of a file, such as \"go.mod\"")
+	err = json.Unmarshal([]byte(`{"workspaces": [{"packages": ["services/*"], "manifest": "go.mod", "namePattern": "^module \\S+"}]}`), &turboJSON)
+	assert.EqualError(t, err, "Invalid \"namePattern\" \"^module \\\\S+\" in \"workspaces\": it must have a group that matches the name")
+}
+
+func Test_LoadRunProfile(t *testing.T) {
+	dir := AbsoluteSystemPathFromUpstream(t.TempDir())
+	_, err := LoadRunProfile(dir, "ci")
+	assert.EqualError(t, err, "profile \"ci\" not found: there is no turbo.json")
+
+	assert.NoError(t, dir.UntypedJoin("turbo.json").WriteFile([]byte(`{"pipeline": {}, "profiles": {"ci": {"concurrency": "4", "noCache": true}}}`), 0644))
+	profile, err := LoadRunProfile(dir, "ci")
+	assert.NoError(t, err)
+	assert.Equal(t, RunProfile{Concurrency: "4", NoCache: true}, *profile)
+}
+
+func Test_LoadWorkspaceTurboConfig(t *testing.T) {
+	dir := AbsoluteSystemPathFromUpstream(t.TempDir())
+	packageJSON := &PackageJSON{}
+	_, err := LoadWorkspaceTurboConfig(dir, packageJSON)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// A workspace with only the "turbo" key in package.json extends the root
+	assert.NoError(t, json.Unmarshal([]byte(`{"turbo": {"pipeline": {"build": {"outputs": ["dist/**"]}}}}`), packageJSON))
+	turboJSON, err := LoadWorkspaceTurboConfig(dir, packageJSON)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{util.RootPkgName}, turboJSON.Extends)
+	assert.Equal(t, 0, len(turboJSON.Pipeline))
+	assert.Equal(t, []string{"dist/**"}, turboJSON.PackageJSONPipeline["build"].TaskDefinition.Outputs.Inclusions)
+
+	assert.NoError(t, dir.UntypedJoin("turbo.json").WriteFile([]byte(`{"extends": ["//"], "pipeline": {"build": {"outputs": ["lib/**"], "inputs": ["src/**"]}}}`), 0644))
+	turboJSON, err = LoadWorkspaceTurboConfig(dir, packageJSON)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"lib/**"}, turboJSON.Pipeline["build"].TaskDefinition.Outputs.Inclusions)
+	assert.Equal(t, []string{"outputs"}, turboJSON.Pipeline["build"].ConflictingKeys(turboJSON.PackageJSONPipeline["build"]))
+
+	packageJSON = &PackageJSON{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"turbo": {"extends": ["shared"], "pipeline": {}}}`), packageJSON))
+	_, err = LoadWorkspaceTurboConfig(dir, packageJSON)
+	assert.EqualError(t, err, "package.json: \"extends\" is not supported in \"turbo\". Tasks in package.json apply over the ones in the workspace's turbo.json, or in the root turbo.json")
+}
+
+func Test_ExtendsMarker(t *testing.T) {
+	root := BookkeepingTaskDefinition{}
+	assert.NoError(t, root.UnmarshalJSON([]byte(`{"outputs": ["dist/**", "!dist/cache/**"], "dependsOn": ["^build"], "inputs": ["src/**"], "env": ["NODE_ENV"], "dotEnv": [".env"]}`)))
+	workspace := BookkeepingTaskDefinition{}
+	assert.NoError(t, workspace.UnmarshalJSON([]byte(`{"outputs": ["$TURBO_EXTENDS$", "lib/**"], "dependsOn": ["$TURBO_EXTENDS$", "codegen"], "inputs": ["$TURBO_EXTENDS$", "public/**", "src/**"], "env": ["$TURBO_EXTENDS$", "API_URL"], "dotEnv": ["$TURBO_EXTENDS$", ".env.local"]}`)))
+
+	merged, err := MergeTaskDefinitions([]BookkeepingTaskDefinition{root, workspace})
+	assert.NoError(t, err)
+	assert.Equal(t, TaskOutputs{Inclusions: []string{"dist/**", "lib/**"}, Exclusions: []string{"dist/cache/**"}}, merged.Outputs)
+	assert.Equal(t, []string{"build"}, merged.TopologicalDependencies)
+	assert.Equal(t, []string{"codegen"}, merged.TaskDependencies)
+	assert.Equal(t, []string{"src/**", "public/**"}, merged.Inputs, "inputs keep their order")
+	assert.Equal(t, []string{"API_URL", "NODE_ENV"}, merged.EnvVarDependencies)
+	assert.Equal(t, []string{".env", ".env.local"}, merged.DotEnv)
+
+	// Only the marker keeps the inherited value
+	onlyMarker := BookkeepingTaskDefinition{}
+	assert.NoError(t, onlyMarker.UnmarshalJSON([]byte(`{"outputs": ["$TURBO_EXTENDS$"]}`)))
+	merged, err = MergeTaskDefinitions([]BookkeepingTaskDefinition{root, onlyMarker})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"dist/**"}, merged.Outputs.Inclusions)
+
+	// Without inputs to extend, every file is still an input
+	noInputs := BookkeepingTaskDefinition{}
+	assert.NoError(t, noInputs.UnmarshalJSON([]byte(`{"outputs": ["dist/**"]}`)))
+	merged, err = MergeTaskDefinitions([]BookkeepingTaskDefinition{noInputs, workspace})
+	assert.NoError(t, err)
+	assert.Empty(t, merged.Inputs)
+}
@@ -0,0 +1,45 @@
+// Package generate implements `turbo gen`, which scaffolds new workspaces
+// and runs custom generators defined within the repository.
+package generate
+
+import (
+	"fmt"
+
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+)
+
+// ExecuteGen executes the `gen` command.
+func ExecuteGen(helper *cmdutil.Helper, args *turbostate.ParsedArgsFromRust) error {
+	base, err := helper.GetCmdBase(args)
+	if err != nil {
+		return err
+	}
+	if args.TestRun {
+		base.UI.Info("Gen test run successful")
+		return nil
+	}
+
+	g := &generate{
+		base: base,
+	}
+	opts := args.Command.Gen
+	switch opts.Command {
+	case "Workspace":
+		err = g.workspace(opts)
+	case "Run":
+		err = g.runGenerator(opts)
+	default:
+		err = fmt.Errorf("unknown gen command: %v", opts.Command)
+	}
+
+	if err != nil {
+		base.LogError("gen failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+type generate struct {
+	base *cmdutil.CmdBase
+}
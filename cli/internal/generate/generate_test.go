@@ -0,0 +1,149 @@
+package generate
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+func Test_defaultDestination(t *testing.T) {
+	testCases := []struct {
+		name          string
+		globs         []string
+		workspaceType string
+		workspace     string
+		want          string
+		wantErr       bool
+	}{
+		{
+			name:          "package goes into packages",
+			globs:         []string{"apps/*", "packages/*"},
+			workspaceType: "package",
+			workspace:     "ui",
+			want:          filepath.Join("packages", "ui"),
+		},
+		{
+			name:          "app goes into apps",
+			globs:         []string{"apps/*", "packages/*"},
+			workspaceType: "app",
+			workspace:     "web",
+			want:          filepath.Join("apps", "web"),
+		},
+		{
+			name:          "scoped names use the unscoped directory",
+			globs:         []string{"packages/*"},
+			workspaceType: "package",
+			workspace:     "@acme/ui",
+			want:          filepath.Join("packages", "ui"),
+		},
+		{
+			name:          "falls back to the first usable glob",
+			globs:         []string{"!ignored/*", "libs/*"},
+			workspaceType: "app",
+			workspace:     "web",
+			want:          filepath.Join("libs", "web"),
+		},
+		{
+			name:          "names can't escape the workspace directory",
+			globs:         []string{"packages/*"},
+			workspaceType: "package",
+			workspace:     "../../x",
+			wantErr:       true,
+		},
+		{
+			name:          "no usable globs",
+			globs:         []string{"tools"},
+			workspaceType: "package",
+			workspace:     "ui",
+			wantErr:       true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := defaultDestination(tc.globs, tc.workspaceType, tc.workspace)
+			if tc.wantErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, got, tc.want)
+		})
+	}
+}
+
+func Test_parseGeneratorArgs(t *testing.T) {
+	vars, err := parseGeneratorArgs([]string{"name=ui", "description=a=b"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, vars, map[string]string{"name": "ui", "description": "a=b"})
+
+	_, err = parseGeneratorArgs([]string{"name"})
+	assert.ErrorContains(t, err, "invalid generator argument")
+}
+
+func Test_renderTemplates(t *testing.T) {
+	root := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	templates := root.UntypedJoin("templates")
+	assert.NilError(t, templates.UntypedJoin("src").MkdirAll(0755))
+	assert.NilError(t, templates.UntypedJoin("package.json.tmpl").WriteFile([]byte(`{"name": "{{ .name }}"}`), 0644))
+	assert.NilError(t, templates.UntypedJoin("src", "{{ .name }}.ts").WriteFile([]byte(`export const name = "{{ .name }}";`), 0644))
+
+	destination := root.UntypedJoin("out")
+	written, err := renderTemplates(templates, destination, map[string]string{"name": "ui"})
+	assert.NilError(t, err)
+	assert.Equal(t, len(written), 2)
+
+	contents, err := destination.UntypedJoin("package.json").ReadFile()
+	assert.NilError(t, err)
+	assert.Equal(t, string(contents), `{"name": "ui"}`)
+
+	contents, err = destination.UntypedJoin("src", "ui.ts").ReadFile()
+	assert.NilError(t, err)
+	assert.Equal(t, string(contents), `export const name = "ui";`)
+
+	// A second run must not overwrite what was generated
+	_, err = renderTemplates(templates, destination, map[string]string{"name": "ui"})
+	assert.ErrorContains(t, err, "refusing to overwrite")
+
+	// Rendered paths can't escape the destination
+	escaping := root.UntypedJoin("escaping")
+	assert.NilError(t, escaping.UntypedJoin("{{ .name }}").MkdirAll(0755))
+	assert.NilError(t, escaping.UntypedJoin("{{ .name }}", "file.ts").WriteFile([]byte(""), 0644))
+	_, err = renderTemplates(escaping, root.UntypedJoin("out2"), map[string]string{"name": "../.."})
+	assert.ErrorContains(t, err, "is outside of")
+	assert.Assert(t, !root.Dir().UntypedJoin("file.ts").Exists())
+
+	// Missing variables are an error rather than an empty string
+	_, err = renderTemplates(templates, root.UntypedJoin("other"), map[string]string{})
+	assert.ErrorContains(t, err, "failed to render")
+}
+
+func Test_joinWithin(t *testing.T) {
+	root := fs.AbsoluteSystemPathFromUpstream(filepath.FromSlash("/repo"))
+	testCases := []struct {
+		rel     string
+		want    string
+		wantErr bool
+	}{
+		{rel: "packages/ui", want: "/repo/packages/ui"},
+		{rel: "./packages/../apps/web", want: "/repo/apps/web"},
+		{rel: ".", want: "/repo"},
+		{rel: "..", wantErr: true},
+		{rel: "../../x", wantErr: true},
+		{rel: "packages/../../x", wantErr: true},
+		{rel: "/etc", wantErr: true},
+		{rel: "", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.rel, func(t *testing.T) {
+			got, err := joinWithin(root, filepath.FromSlash(tc.rel))
+			if tc.wantErr {
+				assert.Assert(t, err != nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, got.ToString(), filepath.FromSlash(tc.want))
+		})
+	}
+}
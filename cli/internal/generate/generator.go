@@ -0,0 +1,217 @@
+package generate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+	"github.com/vercel/turbo/cli/internal/ui"
+)
+
+const (
+	// _generatorsDir is where custom generators live, relative to the repo root
+	_generatorsDir       = "turbo/generators"
+	_generatorConfigFile = "generator.json"
+	_templatesDir        = "templates"
+	// _templateSuffix is stripped from generated file names, so that templates
+	// for files like package.json don't get picked up as real files
+	_templateSuffix = ".tmpl"
+)
+
+// generatorConfig is the contents of turbo/generators/<name>/generator.json
+type generatorConfig struct {
+	// Description is shown when a generator is listed
+	Description string `json:"description"`
+	// Destination is a template for the directory, relative to the repo root,
+	// that files are generated into. E.g. "packages/{{ .name }}"
+	Destination string `json:"destination"`
+	// Variables are the names of the variables that must be passed via --args
+	Variables []string `json:"variables"`
+}
+
+// runGenerator renders the templates of a custom generator into the repository
+func (g *generate) runGenerator(opts *turbostate.GenPayload) error {
+	generatorRoot := g.base.RepoRoot.UntypedJoin(_generatorsDir, opts.Generator)
+	config, err := readGeneratorConfig(generatorRoot)
+	if err != nil {
+		return err
+	}
+
+	vars, err := parseGeneratorArgs(opts.Args)
+	if err != nil {
+		return err
+	}
+	if missing := config.missingVariables(vars); len(missing) > 0 {
+		return fmt.Errorf("generator %v requires %v, pass them with --args <name>=<value>", opts.Generator, strings.Join(missing, ", "))
+	}
+
+	destination, err := renderString("destination", config.Destination, vars)
+	if err != nil {
+		return err
+	}
+	destinationPath, err := joinWithin(g.base.RepoRoot, destination)
+	if err != nil {
+		return err
+	}
+
+	written, err := renderTemplates(generatorRoot.UntypedJoin(_templatesDir), destinationPath, vars)
+	if err != nil {
+		return err
+	}
+
+	g.base.UI.Output(fmt.Sprintf("%s Generated %v files with %s", ui.Dim("•"), len(written), ui.Bold(opts.Generator)))
+	for _, file := range written {
+		relativePath, err := g.base.RepoRoot.RelativePathString(file.ToString())
+		if err != nil {
+			return err
+		}
+		g.base.UI.Output(ui.Dim(fmt.Sprintf("  %v", relativePath)))
+	}
+	return nil
+}
+
+func readGeneratorConfig(generatorRoot turbopath.AbsoluteSystemPath) (*generatorConfig, error) {
+	configPath := generatorRoot.UntypedJoin(_generatorConfigFile)
+	if !configPath.FileExists() {
+		return nil, fmt.Errorf("no generator found at %v", generatorRoot)
+	}
+	contents, err := configPath.ReadFile()
+	if err != nil {
+		return nil, err
+	}
+	config := &generatorConfig{}
+	if err := json.Unmarshal(contents, config); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %v", configPath)
+	}
+	if config.Destination == "" {
+		return nil, fmt.Errorf("%v: \"destination\" is required", configPath)
+	}
+	return config, nil
+}
+
+func (c *generatorConfig) missingVariables(vars map[string]string) []string {
+	var missing []string
+	for _, name := range c.Variables {
+		if _, ok := vars[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// parseGeneratorArgs converts a list of key=value pairs into a map
+func parseGeneratorArgs(args []string) (map[string]string, error) {
+	vars := make(map[string]string, len(args))
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid generator argument %q, expected <name>=<value>", arg)
+		}
+		vars[key] = value
+	}
+	return vars, nil
+}
+
+// renderTemplates renders every file under templateRoot into destination. Both file paths
+// and file contents are treated as templates. Existing files are never overwritten.
+func renderTemplates(templateRoot turbopath.AbsoluteSystemPath, destination turbopath.AbsoluteSystemPath, vars map[string]string) ([]turbopath.AbsoluteSystemPath, error) {
+	if !templateRoot.DirExists() {
+		return nil, fmt.Errorf("no templates found at %v", templateRoot)
+	}
+
+	type renderedFile struct {
+		path     turbopath.AbsoluteSystemPath
+		contents []byte
+	}
+	var files []renderedFile
+	err := fs.Walk(templateRoot.ToStringDuringMigration(), func(name string, isDir bool) error {
+		if isDir {
+			return nil
+		}
+		relativePath, err := templateRoot.RelativePathString(name)
+		if err != nil {
+			return err
+		}
+		renderedPath, err := renderString(relativePath, filepath.ToSlash(relativePath), vars)
+		if err != nil {
+			return err
+		}
+		renderedPath = strings.TrimSuffix(renderedPath, _templateSuffix)
+
+		raw, err := fs.UnsafeToAbsoluteSystemPath(name).ReadFile()
+		if err != nil {
+			return err
+		}
+		contents, err := renderString(relativePath, string(raw), vars)
+		if err != nil {
+			return err
+		}
+		path, err := joinWithin(destination, filepath.FromSlash(renderedPath))
+		if err != nil {
+			return err
+		}
+		files = append(files, renderedFile{
+			path:     path,
+			contents: []byte(contents),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Check everything up front so that we don't leave a partially generated directory behind
+	for _, file := range files {
+		if file.path.Exists() {
+			return nil, fmt.Errorf("refusing to overwrite existing file %v", file.path)
+		}
+	}
+
+	written := make([]turbopath.AbsoluteSystemPath, 0, len(files))
+	for _, file := range files {
+		if err := file.path.EnsureDir(); err != nil {
+			return nil, err
+		}
+		if err := file.path.WriteFile(file.contents, 0644); err != nil {
+			return nil, err
+		}
+		written = append(written, file.path)
+	}
+	sort.Slice(written, func(i, j int) bool {
+		return written[i] < written[j]
+	})
+	return written, nil
+}
+
+// joinWithin joins the relative path rel onto root, refusing paths that are
+// absolute or that escape root, such as those produced by a variable of "../x"
+func joinWithin(root turbopath.AbsoluteSystemPath, rel string) (turbopath.AbsoluteSystemPath, error) {
+	if rel == "" || filepath.IsAbs(rel) || filepath.VolumeName(rel) != "" {
+		return "", fmt.Errorf("%q must be a relative path", rel)
+	}
+	cleaned := filepath.Clean(rel)
+	if cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%q is outside of %v", rel, root)
+	}
+	return root.UntypedJoin(cleaned), nil
+}
+
+func renderString(name string, text string, vars map[string]string) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid template %v", name)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", errors.Wrapf(err, "failed to render %v", name)
+	}
+	return buf.String(), nil
+}
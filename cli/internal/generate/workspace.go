@@ -0,0 +1,206 @@
+package generate
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vercel/turbo/cli/internal/context"
+	"github.com/vercel/turbo/cli/internal/doublestar"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+	"github.com/vercel/turbo/cli/internal/ui"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// _skipWhenCopying are directory names that never make sense to carry over
+// from the workspace being copied.
+var _skipWhenCopying = util.SetFromStrings([]string{"node_modules", ".turbo", ".git"})
+
+// workspace creates a new workspace, either from scratch or by copying an
+// existing workspace, and writes a package.json with scripts for the tasks
+// defined in the root pipeline.
+func (g *generate) workspace(opts *turbostate.GenPayload) error {
+	repoRoot := g.base.RepoRoot
+	rootPackageJSON, err := fs.ReadPackageJSON(repoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		return fmt.Errorf("failed to read package.json: %w", err)
+	}
+	pkgDepGraph, err := context.BuildPackageGraph(repoRoot, rootPackageJSON)
+	if err != nil {
+		var warnings *context.Warnings
+		if !errors.As(err, &warnings) {
+			return errors.Wrap(err, "could not construct graph")
+		}
+		g.base.LogWarning("Issues occurred when constructing package graph", err)
+	}
+
+	if _, ok := pkgDepGraph.WorkspaceInfos.PackageJSONs[opts.Name]; ok {
+		return fmt.Errorf("a workspace named %v already exists", opts.Name)
+	}
+
+	workspaceGlobs, err := pkgDepGraph.PackageManager.GetWorkspaceGlobs(repoRoot)
+	if err != nil {
+		return errors.Wrap(err, "failed to read workspace globs")
+	}
+
+	destination := opts.Destination
+	if destination == "" {
+		destination, err = defaultDestination(workspaceGlobs, opts.WorkspaceType, opts.Name)
+		if err != nil {
+			return err
+		}
+	}
+	destinationPath, err := joinWithin(repoRoot, destination)
+	if err != nil {
+		return err
+	}
+	if destinationPath == repoRoot {
+		return fmt.Errorf("cannot create a workspace at the repository root")
+	}
+	if destinationPath.Exists() {
+		return fmt.Errorf("%v already exists", destination)
+	}
+
+	var pkgJSON *fs.PackageJSON
+	if opts.Copy != "" {
+		source, ok := pkgDepGraph.WorkspaceInfos.PackageJSONs[opts.Copy]
+		if !ok || opts.Copy == util.RootPkgName {
+			return fmt.Errorf("cannot copy unknown workspace %v", opts.Copy)
+		}
+		if err := copyWorkspace(source.Dir.RestoreAnchor(repoRoot), destinationPath); err != nil {
+			return errors.Wrapf(err, "failed to copy %v", opts.Copy)
+		}
+		pkgJSON, err = fs.ReadPackageJSON(destinationPath.UntypedJoin("package.json"))
+		if err != nil {
+			return err
+		}
+		pkgJSON.Name = opts.Name
+		pkgJSON.Version = "0.0.0"
+	} else {
+		turboJSON, err := fs.LoadTurboConfig(repoRoot, rootPackageJSON, false)
+		if err != nil {
+			return err
+		}
+		pkgJSON = &fs.PackageJSON{
+			Name:    opts.Name,
+			Version: "0.0.0",
+			Private: true,
+			Scripts: scaffoldScripts(turboJSON.Pipeline),
+		}
+		if err := destinationPath.MkdirAll(0755); err != nil {
+			return err
+		}
+	}
+
+	contents, err := fs.MarshalPackageJSON(pkgJSON)
+	if err != nil {
+		return err
+	}
+	if err := destinationPath.UntypedJoin("package.json").WriteFile(contents, 0644); err != nil {
+		return err
+	}
+
+	if !matchesWorkspaceGlobs(workspaceGlobs, destination) {
+		g.base.LogWarning("", fmt.Errorf("%v is not covered by your workspace globs (%v), it will not be part of the pipeline until it is added", destination, strings.Join(workspaceGlobs, ", ")))
+	}
+
+	g.base.UI.Output(fmt.Sprintf("%s Created workspace %s at %s", ui.Dim("•"), ui.Bold(opts.Name), destination))
+	g.base.UI.Output(ui.Dim(fmt.Sprintf("  Run your package manager's install command to link %v into the workspace graph.", opts.Name)))
+	return nil
+}
+
+// defaultDestination picks a directory for a new workspace based on the workspace
+// globs. Apps prefer a glob rooted in "apps", packages prefer one rooted in "packages",
+// otherwise the first glob that ends in a single wildcard segment is used.
+func defaultDestination(workspaceGlobs []string, workspaceType string, name string) (string, error) {
+	preferred := "packages"
+	if workspaceType == "app" {
+		preferred = "apps"
+	}
+
+	var parents []string
+	for _, glob := range workspaceGlobs {
+		glob = strings.TrimPrefix(filepath.ToSlash(glob), "./")
+		if strings.HasPrefix(glob, "!") {
+			continue
+		}
+		parent, wildcard := path.Split(glob)
+		if wildcard != "*" && wildcard != "**" {
+			continue
+		}
+		parent = strings.TrimSuffix(parent, "/")
+		if parent == "" || strings.ContainsAny(parent, "*?[{") {
+			continue
+		}
+		parents = append(parents, parent)
+	}
+	if len(parents) == 0 {
+		return "", fmt.Errorf("could not determine a destination for %v from workspace globs %v, pass --destination", name, workspaceGlobs)
+	}
+
+	dir := name
+	if strings.HasPrefix(name, "@") {
+		// Scoped packages live in a directory named after the unscoped name
+		dir = path.Base(name)
+	}
+	if dir == "." || dir == ".." || strings.ContainsAny(dir, `/\`) {
+		return "", fmt.Errorf("invalid workspace name %v", name)
+	}
+	for _, parent := range parents {
+		if parent == preferred || strings.HasSuffix(parent, "/"+preferred) {
+			return filepath.FromSlash(path.Join(parent, dir)), nil
+		}
+	}
+	return filepath.FromSlash(path.Join(parents[0], dir)), nil
+}
+
+// scaffoldScripts creates a placeholder script for every task in the root pipeline
+// so that the new workspace participates in the task graph from the start.
+func scaffoldScripts(pipeline fs.Pipeline) map[string]string {
+	scripts := make(map[string]string)
+	taskNames := make([]string, 0, len(pipeline))
+	for taskID := range pipeline {
+		if util.IsPackageTask(taskID) {
+			continue
+		}
+		taskNames = append(taskNames, taskID)
+	}
+	sort.Strings(taskNames)
+	for _, taskName := range taskNames {
+		scripts[taskName] = fmt.Sprintf("echo \"TODO: implement %v\"", taskName)
+	}
+	return scripts
+}
+
+func matchesWorkspaceGlobs(workspaceGlobs []string, destination string) bool {
+	destination = filepath.ToSlash(destination)
+	for _, glob := range workspaceGlobs {
+		glob = strings.TrimPrefix(filepath.ToSlash(glob), "./")
+		if matched, err := doublestar.Match(glob, destination); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+func copyWorkspace(from turbopath.AbsoluteSystemPath, to turbopath.AbsoluteSystemPath) error {
+	return fs.Walk(from.ToStringDuringMigration(), func(name string, isDir bool) error {
+		relativePath, err := from.RelativePathString(name)
+		if err != nil {
+			return err
+		}
+		if isDir && _skipWhenCopying.Includes(filepath.Base(name)) {
+			return filepath.SkipDir
+		}
+		dest := to.UntypedJoin(relativePath)
+		if isDir {
+			return dest.MkdirAll(0755)
+		}
+		return fs.CopyFile(&fs.LstatCachedFile{Path: fs.UnsafeToAbsoluteSystemPath(name)}, dest.ToString())
+	})
+}
@@ -134,6 +134,18 @@ func globWalkFs(fsys iofs.FS, fsysRoot string, basePath string, includePatterns
 	excludePattern = filepath.ToSlash(excludePattern)
 
 	err := doublestar.GlobWalk(fsys, includePattern, func(path string, dirEntry iofs.DirEntry) error {
+		if dirEntry.IsDir() && excludeCount > 0 {
+			// Excludes operate on entire folders, so there is no need to walk
+			// the contents of an excluded folder.
+			isExcluded, err := doublestar.Match(excludePattern, filepath.ToSlash(path))
+			if err != nil {
+				return err
+			}
+			if isExcluded {
+				return iofs.SkipDir
+			}
+		}
+
 		if !includeDirs && dirEntry.IsDir() {
 			return nil
 		}
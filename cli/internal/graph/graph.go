@@ -39,6 +39,17 @@ type CompleteGraph struct {
 	RepoRoot        turbopath.AbsoluteSystemPath
 
 	TaskHashTracker *taskhash.Tracker
+
+	// AssumedFresh are the dependencies that --only didn't run, by the taskID
+	// of the task that depends on them
+	AssumedFresh map[string][]string
+
+	// CacheKeySuffix is appended to the hash of every task, from
+	// --cache-key-suffix
+	CacheKeySuffix string
+
+	// PinnedHashes replace the hashes of tasks, by taskID, from --hash-lock
+	PinnedHashes map[string]string
 }
 
 // GetPackageTaskVisitor wraps a `visitor` function that is used for walking the TaskGraph
@@ -79,7 +90,7 @@ func (g *CompleteGraph) GetPackageTaskVisitor(
 			packageTask,
 			taskGraph.DownEdges(taskID),
 			logger,
-			getArgs(taskName),
+			getArgs(taskID),
 		)
 
 		// Not being able to construct the task hash is a hard error
@@ -88,16 +99,49 @@ func (g *CompleteGraph) GetPackageTaskVisitor(
 		}
 
 		pkgDir := pkg.Dir
+		packageTask.FallbackHash = g.TaskHashTracker.GetFallbackHash(taskID)
+		if g.CacheKeySuffix != "" {
+			hash = hash + "-" + g.CacheKeySuffix
+			if packageTask.FallbackHash != "" {
+				packageTask.FallbackHash = packageTask.FallbackHash + "-" + g.CacheKeySuffix
+			}
+		}
+		if g.PinnedHashes != nil {
+			if pinnedHash, ok := g.PinnedHashes[taskID]; ok {
+				if pinnedHash != hash {
+					logger.Debug("using pinned hash", "task", taskID, "computed", hash, "pinned", pinnedHash)
+				}
+				hash = pinnedHash
+				// Only the pinned artifact is restored
+				packageTask.FallbackHash = ""
+			} else if taskDefinition.HasCommand(pkg, taskName) && taskDefinition.ShouldCache {
+				return fmt.Errorf("%v has no pinned hash in the hash lock", taskID)
+			}
+		}
 		packageTask.Hash = hash
 		envVars := g.TaskHashTracker.GetEnvVars(taskID)
 		expandedInputs := g.TaskHashTracker.GetExpandedInputs(packageTask)
+		inputsHash := g.TaskHashTracker.GetInputsHash(packageTask)
 		framework := g.TaskHashTracker.GetFramework(taskID)
+		hashCommands := g.TaskHashTracker.GetHashCommands(taskID)
 
 		// Assign remaining fields to packageTask
 		var command string
 		if cmd, ok := pkg.Scripts[taskName]; ok {
 			command = cmd
 		}
+		// The "command" of the task runs in place of its script
+		if taskDefinition.Command != "" {
+			command = taskDefinition.Command
+		}
+		var workingDir string
+		if taskDefinition.Cwd != "" {
+			dir, err := packageTask.WorkingDir()
+			if err != nil {
+				return err
+			}
+			workingDir = dir.ToUnixPath().ToString()
+		}
 
 		logFile := repoRelativeLogFile(pkgDir, taskName)
 		packageTask.LogFile = logFile
@@ -107,18 +151,24 @@ func (g *CompleteGraph) GetPackageTaskVisitor(
 			TaskID:                 taskID,
 			Task:                   taskName,
 			Hash:                   hash,
+			FallbackHash:           packageTask.FallbackHash,
 			Package:                packageName,
 			Dir:                    pkgDir.ToString(),
 			Outputs:                taskDefinition.Outputs.Inclusions,
 			ExcludedOutputs:        taskDefinition.Outputs.Exclusions,
 			LogFile:                logFile,
 			ResolvedTaskDefinition: taskDefinition,
+			AssumedFresh:           g.AssumedFresh[taskID],
 			ExpandedInputs:         expandedInputs,
+			InputsHash:             inputsHash,
 			Command:                command,
 			Framework:              framework,
+			HashCommands:           hashCommands,
+			WorkingDir:             workingDir,
 			EnvVars: runsummary.TaskEnvVarSummary{
-				Configured: envVars.BySource.Explicit.ToSecretHashable(),
-				Inferred:   envVars.BySource.Matching.ToSecretHashable(),
+				Configured:  envVars.BySource.Explicit.ToSecretHashable(),
+				Inferred:    envVars.BySource.Matching.ToSecretHashable(),
+				PassThrough: envVars.PassThrough.ToSecretHashable(),
 			},
 		}
 
@@ -155,7 +205,13 @@ func (g *CompleteGraph) GetTurboConfigFromWorkspace(workspaceName string, isSing
 	// Note: pkgJSON.Dir for the root workspace will be an empty string, and for
 	// other workspaces, it will be a relative path.
 	workspaceAbsolutePath := workspacePackageJSON.Dir.RestoreAnchor(g.RepoRoot)
-	turboConfig, err := fs.LoadTurboConfig(workspaceAbsolutePath, workspacePackageJSON, isSinglePackage)
+	var turboConfig *fs.TurboJSON
+	var err error
+	if workspaceName == util.RootPkgName {
+		turboConfig, err = fs.LoadTurboConfig(workspaceAbsolutePath, workspacePackageJSON, isSinglePackage)
+	} else {
+		turboConfig, err = fs.LoadWorkspaceTurboConfig(workspaceAbsolutePath, workspacePackageJSON)
+	}
 
 	// If we failed to load a TurboConfig, bubble up the error
 	if err != nil {
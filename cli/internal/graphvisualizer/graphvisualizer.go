@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/mitchellh/cli"
@@ -20,9 +21,20 @@ import (
 
 // GraphVisualizer requirements
 type GraphVisualizer struct {
-	repoRoot  turbopath.AbsoluteSystemPath
-	ui        cli.Ui
-	TaskGraph *dag.AcyclicGraph
+	repoRoot    turbopath.AbsoluteSystemPath
+	ui          cli.Ui
+	TaskGraph   *dag.AcyclicGraph
+	annotations map[string]NodeAnnotation
+}
+
+// NodeAnnotation describes what a run is expected to do with a task in the graph
+type NodeAnnotation struct {
+	// CacheStatus is the expected cache status, such as "HIT (local)" or "MISS"
+	CacheStatus string
+	CacheHit    bool
+	// LastDuration is how long the task took the last time it was executed.
+	// It is zero if that isn't known.
+	LastDuration time.Duration
 }
 
 // hasGraphViz checks for the presence of https://graphviz.org/
@@ -49,12 +61,78 @@ func New(repoRoot turbopath.AbsoluteSystemPath, ui cli.Ui, TaskGraph *dag.Acycli
 	}
 }
 
+// SetAnnotations annotates the tasks in the graph, by task name
+func (g *GraphVisualizer) SetAnnotations(annotations map[string]NodeAnnotation) {
+	g.annotations = annotations
+}
+
+// annotationText describes the annotation of a task, or is empty if the task
+// isn't annotated
+func (g *GraphVisualizer) annotationText(name string) string {
+	annotation, ok := g.annotations[name]
+	if !ok {
+		return ""
+	}
+	var parts []string
+	if annotation.CacheStatus != "" {
+		parts = append(parts, annotation.CacheStatus)
+	}
+	if annotation.LastDuration > 0 {
+		parts = append(parts, formatDuration(annotation.LastDuration))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatDuration(duration time.Duration) string {
+	if duration < time.Second {
+		return duration.Round(time.Millisecond).String()
+	}
+	return duration.Round(100 * time.Millisecond).String()
+}
+
+// annotatedNames returns the names of the annotated tasks in the graph, sorted
+func (g *GraphVisualizer) annotatedNames() []string {
+	names := []string{}
+	for name := range g.annotations {
+		if g.TaskGraph.HasVertex(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// dotQuote quotes a string for a DOT file, escaping line breaks as \n
+func dotQuote(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + replacer.Replace(s) + `"`
+}
+
 // Converts the TaskGraph dag into a string
 func (g *GraphVisualizer) generateDotString() string {
-	return string(g.TaskGraph.Dot(&dag.DotOpts{
+	graphString := string(g.TaskGraph.Dot(&dag.DotOpts{
 		Verbose:    true,
 		DrawCycles: true,
 	}))
+	if len(g.annotations) == 0 {
+		return graphString
+	}
+	// Declare the annotated nodes at the top of the root subgraph, where the
+	// dag library writes the nodes and edges of the graph
+	var nodes strings.Builder
+	for _, name := range g.annotatedNames() {
+		label := name
+		if text := g.annotationText(name); text != "" {
+			label += "\n" + text
+		}
+		nodes.WriteString(fmt.Sprintf("\t\t%v [label = %v", dotQuote("[root] "+name), dotQuote(label)))
+		if g.annotations[name].CacheHit {
+			nodes.WriteString(`, style = "filled", fillcolor = "#d3f9d8"`)
+		}
+		nodes.WriteString("]\n")
+	}
+	const rootSubgraph = "subgraph \"root\" {\n"
+	return strings.Replace(graphString, rootSubgraph, rootSubgraph+nodes.String(), 1)
 }
 
 // Outputs a warning when a file was requested, but graphviz is not available
@@ -113,13 +191,42 @@ func (g *GraphVisualizer) generateMermaid(out io.StringWriter) error {
 		right := dag.VertexName(edge.Target())
 		leftName := cache.getName(left)
 		rightName := cache.getName(right)
-		if _, err := out.WriteString(fmt.Sprintf("\t%v(\"%v\") --> %v(\"%v\")\n", leftName, left, rightName, right)); err != nil {
+		if _, err := out.WriteString(fmt.Sprintf("\t%v(\"%v\") --> %v(\"%v\")\n", leftName, g.mermaidLabel(left), rightName, g.mermaidLabel(right))); err != nil {
+			return err
+		}
+	}
+	var hits []string
+	for _, name := range g.annotatedNames() {
+		if g.annotations[name].CacheHit {
+			hits = append(hits, cache.getName(name))
+		}
+	}
+	if len(hits) > 0 {
+		if _, err := out.WriteString(fmt.Sprintf("\tclassDef hit fill:#d3f9d8\n\tclass %v hit\n", strings.Join(hits, ","))); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+func (g *GraphVisualizer) mermaidLabel(name string) string {
+	if text := g.annotationText(name); text != "" {
+		return name + "<br/>" + text
+	}
+	return name
+}
+
+// RenderMermaidGraph renders a Mermaid flowchart for the current TaskGraph
+func (g *GraphVisualizer) RenderMermaidGraph() error {
+	var mermaid strings.Builder
+	if err := g.generateMermaid(&mermaid); err != nil {
+		return err
+	}
+	g.ui.Output("")
+	g.ui.Output(mermaid.String())
+	return nil
+}
+
 // GenerateGraphFile saves a visualization of the TaskGraph to a file (or renders a DotGraph as a fallback))
 func (g *GraphVisualizer) GenerateGraphFile(outputName string) error {
 	outputFilename := g.repoRoot.UntypedJoin(outputName)
@@ -129,7 +236,7 @@ func (g *GraphVisualizer) GenerateGraphFile(outputName string) error {
 		ext = ".jpg"
 		outputFilename = g.repoRoot.UntypedJoin(outputName + ext)
 	}
-	if ext == ".mermaid" {
+	if ext == ".mermaid" || ext == ".mmd" {
 		f, err := outputFilename.Create()
 		if err != nil {
 			return fmt.Errorf("error creating file: %w", err)
@@ -141,38 +248,14 @@ func (g *GraphVisualizer) GenerateGraphFile(outputName string) error {
 		g.ui.Output(fmt.Sprintf("✔ Generated task graph in %s", ui.Bold(outputFilename.ToString())))
 		return nil
 	}
-	graphString := g.generateDotString()
 	if ext == ".html" {
 		f, err := outputFilename.Create()
 		if err != nil {
 			return fmt.Errorf("error creating file: %w", err)
 		}
-		defer f.Close() //nolint errcheck
-		_, writeErr1 := f.WriteString(`<!DOCTYPE html>
-    <html>
-    <head>
-      <meta charset="utf-8">
-      <title>Graph</title>
-    </head>
-    <body>
-      <script src="https://cdn.jsdelivr.net/npm/viz.js@2.1.2-pre.1/viz.js"></script>
-      <script src="https://cdn.jsdelivr.net/npm/viz.js@2.1.2-pre.1/full.render.js"></script>
-      <script>`)
-		if writeErr1 != nil {
-			return fmt.Errorf("error writing graph contents: %w", writeErr1)
-		}
-
-		_, writeErr2 := f.WriteString("const s = `" + graphString + "`.replace(/\\_\\_\\_ROOT\\_\\_\\_/g, \"Root\").replace(/\\[root\\]/g, \"\");new Viz().renderSVGElement(s).then(el => document.body.appendChild(el)).catch(e => console.error(e));")
-		if writeErr2 != nil {
-			return fmt.Errorf("error creating file: %w", writeErr2)
-		}
-
-		_, writeErr3 := f.WriteString(`
-    </script>
-  </body>
-  </html>`)
-		if writeErr3 != nil {
-			return fmt.Errorf("error creating file: %w", writeErr3)
+		defer util.CloseAndIgnoreError(f)
+		if err := g.generateHTML(f); err != nil {
+			return fmt.Errorf("error writing graph contents: %w", err)
 		}
 
 		g.ui.Output("")
@@ -184,6 +267,7 @@ func (g *GraphVisualizer) GenerateGraphFile(outputName string) error {
 		}
 		return nil
 	}
+	graphString := g.generateDotString()
 	hasDot := hasGraphViz()
 	if hasDot {
 		dotArgs := []string{"-T" + ext[1:], "-o", outputFilename.ToString()}
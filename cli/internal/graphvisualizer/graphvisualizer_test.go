@@ -0,0 +1,63 @@
+package graphvisualizer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+func testVisualizer(t *testing.T) *GraphVisualizer {
+	graph := &dag.AcyclicGraph{}
+	for _, name := range []string{rootNodeName, "ui#build", "web#build", "web#test"} {
+		graph.Add(name)
+	}
+	graph.Connect(dag.BasicEdge("ui#build", rootNodeName))
+	graph.Connect(dag.BasicEdge("web#build", "ui#build"))
+	graph.Connect(dag.BasicEdge("web#test", "web#build"))
+	visualizer := New(fs.AbsoluteSystemPathFromUpstream(t.TempDir()), cli.NewMockUi(), graph)
+	visualizer.SetAnnotations(map[string]NodeAnnotation{
+		"ui#build":  {CacheStatus: "HIT (local)", CacheHit: true, LastDuration: 2340 * time.Millisecond},
+		"web#build": {CacheStatus: "MISS"},
+		"web#test":  {CacheStatus: "MISS", LastDuration: 80 * time.Millisecond},
+	})
+	return visualizer
+}
+
+func TestDotAnnotations(t *testing.T) {
+	dot := testVisualizer(t).generateDotString()
+
+	assert.Assert(t, strings.Contains(dot, `"[root] ui#build" [label = "ui#build\nHIT (local), 2.3s", style = "filled", fillcolor = "#d3f9d8"]`), dot)
+	assert.Assert(t, strings.Contains(dot, `"[root] web#build" [label = "web#build\nMISS"]`), dot)
+	assert.Assert(t, strings.Contains(dot, `"[root] web#test" [label = "web#test\nMISS, 80ms"]`), dot)
+	assert.Assert(t, strings.Contains(dot, `"[root] web#test" -> "[root] web#build"`), dot)
+}
+
+func TestMermaidAnnotations(t *testing.T) {
+	visualizer := testVisualizer(t)
+	var mermaid strings.Builder
+	assert.NilError(t, visualizer.generateMermaid(&mermaid))
+
+	assert.Assert(t, strings.Contains(mermaid.String(), `("web#test<br/>MISS, 80ms") --> `), mermaid.String())
+	assert.Assert(t, strings.Contains(mermaid.String(), `("ui#build<br/>HIT (local), 2.3s")`), mermaid.String())
+	assert.Assert(t, strings.Contains(mermaid.String(), "\tclassDef hit fill:#d3f9d8\n\tclass "), mermaid.String())
+}
+
+func TestHTMLNodes(t *testing.T) {
+	nodes := testVisualizer(t).htmlNodes()
+
+	assert.DeepEqual(t, nodes, []*htmlNode{
+		{Name: "ui#build", CacheStatus: "HIT (local)", CacheHit: true, Duration: "2.3s", Column: 0, Dependencies: []string{}},
+		{Name: "web#build", CacheStatus: "MISS", Column: 1, Dependencies: []string{"ui#build"}},
+		{Name: "web#test", CacheStatus: "MISS", Duration: "80ms", Column: 2, Dependencies: []string{"web#build"}},
+	})
+
+	var page strings.Builder
+	assert.NilError(t, testVisualizer(t).generateHTML(&page))
+	assert.Assert(t, strings.Contains(page.String(), `"name":"web#test"`), page.String())
+	assert.Assert(t, !strings.Contains(page.String(), "<script src="), "the page is self-contained")
+}
@@ -0,0 +1,208 @@
+package graphvisualizer
+
+import (
+	"html/template"
+	"io"
+	"sort"
+
+	"github.com/pyr-sh/dag"
+)
+
+// rootNodeName is the node that every task without dependencies depends on.
+// It only exists to anchor the graph, so the HTML page leaves it out.
+const rootNodeName = "___ROOT___"
+
+// htmlNode is a task in the graph rendered by the HTML page
+type htmlNode struct {
+	Name        string `json:"name"`
+	CacheStatus string `json:"cacheStatus,omitempty"`
+	CacheHit    bool   `json:"cacheHit"`
+	Duration    string `json:"duration,omitempty"`
+	// Column is the length of the longest chain of dependencies below the
+	// task, so that every task is drawn to the right of its dependencies
+	Column       int      `json:"column"`
+	Dependencies []string `json:"dependencies"`
+}
+
+func (g *GraphVisualizer) htmlNodes() []*htmlNode {
+	nodes := make(map[string]*htmlNode)
+	var names []string
+	for _, v := range g.TaskGraph.Vertices() {
+		name := dag.VertexName(v)
+		if name == rootNodeName {
+			continue
+		}
+		node := &htmlNode{Name: name, Column: -1, Dependencies: []string{}}
+		if annotation, ok := g.annotations[name]; ok {
+			node.CacheStatus = annotation.CacheStatus
+			node.CacheHit = annotation.CacheHit
+			if annotation.LastDuration > 0 {
+				node.Duration = formatDuration(annotation.LastDuration)
+			}
+		}
+		for _, dep := range g.TaskGraph.DownEdges(v).List() {
+			if depName := dag.VertexName(dep); depName != rootNodeName {
+				node.Dependencies = append(node.Dependencies, depName)
+			}
+		}
+		sort.Strings(node.Dependencies)
+		nodes[name] = node
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var column func(node *htmlNode) int
+	column = func(node *htmlNode) int {
+		if node.Column >= 0 {
+			return node.Column
+		}
+		node.Column = 0
+		for _, dep := range node.Dependencies {
+			if depColumn := column(nodes[dep]) + 1; depColumn > node.Column {
+				node.Column = depColumn
+			}
+		}
+		return node.Column
+	}
+	sorted := make([]*htmlNode, 0, len(names))
+	for _, name := range names {
+		column(nodes[name])
+		sorted = append(sorted, nodes[name])
+	}
+	return sorted
+}
+
+// generateHTML writes a page that draws the graph without loading anything
+// from the network. Clicking a task highlights everything it depends on and
+// everything that depends on it.
+func (g *GraphVisualizer) generateHTML(out io.Writer) error {
+	return _htmlTemplate.Execute(out, g.htmlNodes())
+}
+
+var _htmlTemplate = template.Must(template.New("graph").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>Task Graph</title>
+  <style>
+    body { margin: 0; font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; }
+    header { position: sticky; top: 0; padding: 12px 20px; background: #fff; border-bottom: 1px solid #ddd; }
+    header span { margin-left: 16px; color: #666; font-size: 13px; }
+    svg { display: block; }
+    .node rect { fill: #fff; stroke: #999; rx: 6; }
+    .node.hit rect { fill: #d3f9d8; stroke: #2b8a3e; }
+    .node text { font-size: 12px; pointer-events: none; }
+    .node .detail { fill: #666; font-size: 11px; }
+    .node { cursor: pointer; }
+    .edge { fill: none; stroke: #bbb; }
+    .dimmed { opacity: 0.15; }
+    .selected rect { stroke: #1c7ed6; stroke-width: 3; }
+    .match rect { stroke: #f08c00; stroke-width: 3; }
+  </style>
+</head>
+<body>
+  <header>
+    <input id="search" type="search" placeholder="Find a task" autofocus>
+    <span>Click a task to highlight its dependencies and dependents</span>
+  </header>
+  <svg id="graph" xmlns="http://www.w3.org/2000/svg"></svg>
+  <script>
+    const nodes = {{.}};
+    const width = 240, height = 44, columnGap = 80, rowGap = 16, margin = 20;
+    const svgNS = "http://www.w3.org/2000/svg";
+    const svg = document.getElementById("graph");
+    const byName = {};
+    const rows = [];
+    for (const node of nodes) {
+      byName[node.name] = node;
+      node.dependents = [];
+      rows[node.column] = (rows[node.column] || 0) + 1;
+      node.x = margin + node.column * (width + columnGap);
+      node.y = margin + (rows[node.column] - 1) * (height + rowGap);
+    }
+    for (const node of nodes) {
+      for (const dep of node.dependencies) {
+        byName[dep].dependents.push(node.name);
+      }
+    }
+    svg.setAttribute("width", margin * 2 + rows.length * (width + columnGap));
+    svg.setAttribute("height", margin * 2 + Math.max(0, ...rows) * (height + rowGap));
+
+    function el(name, attrs, parent) {
+      const e = document.createElementNS(svgNS, name);
+      for (const key in attrs) {
+        e.setAttribute(key, attrs[key]);
+      }
+      parent.appendChild(e);
+      return e;
+    }
+
+    const edges = [];
+    for (const node of nodes) {
+      for (const dep of node.dependencies) {
+        const target = byName[dep];
+        const x1 = node.x, y1 = node.y + height / 2;
+        const x2 = target.x + width, y2 = target.y + height / 2;
+        const path = el("path", {
+          class: "edge",
+          d: "M" + x1 + "," + y1 + " C" + (x1 - columnGap / 2) + "," + y1 + " " + (x2 + columnGap / 2) + "," + y2 + " " + x2 + "," + y2,
+        }, svg);
+        edges.push({ path, from: node.name, to: dep });
+      }
+    }
+    for (const node of nodes) {
+      const group = el("g", { class: "node" + (node.cacheHit ? " hit" : "") }, svg);
+      el("rect", { x: node.x, y: node.y, width, height }, group);
+      el("text", { x: node.x + 10, y: node.y + 18 }, group).textContent = node.name;
+      const detail = [node.cacheStatus, node.duration].filter(Boolean).join(", ");
+      el("text", { x: node.x + 10, y: node.y + 34, class: "detail" }, group).textContent = detail;
+      el("title", {}, group).textContent = node.name + (detail ? "\n" + detail : "");
+      group.addEventListener("click", (event) => {
+        event.stopPropagation();
+        select(node);
+      });
+      node.group = group;
+    }
+
+    function collect(name, key, seen) {
+      for (const next of byName[name][key]) {
+        if (!seen.has(next)) {
+          seen.add(next);
+          collect(next, key, seen);
+        }
+      }
+      return seen;
+    }
+
+    function select(node) {
+      const related = collect(node.name, "dependencies", new Set([node.name]));
+      collect(node.name, "dependents", related);
+      for (const other of nodes) {
+        other.group.classList.toggle("dimmed", !related.has(other.name));
+        other.group.classList.toggle("selected", other === node);
+      }
+      for (const edge of edges) {
+        edge.path.classList.toggle("dimmed", !(related.has(edge.from) && related.has(edge.to)));
+      }
+    }
+
+    function clear() {
+      for (const node of nodes) {
+        node.group.classList.remove("dimmed", "selected");
+      }
+      for (const edge of edges) {
+        edge.path.classList.remove("dimmed");
+      }
+    }
+    svg.addEventListener("click", clear);
+
+    document.getElementById("search").addEventListener("input", (event) => {
+      const query = event.target.value.trim();
+      for (const node of nodes) {
+        node.group.classList.toggle("match", query !== "" && node.name.includes(query));
+      }
+    });
+  </script>
+</body>
+</html>
+`))
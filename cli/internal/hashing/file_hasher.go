@@ -0,0 +1,57 @@
+package hashing
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// FileHasher hashes files without git, the way git hashes them. It is shared
+// by everything that hashes files during a run, so that at most its number of
+// workers files are open and streamed at the same time, however many packages
+// are hashed at once.
+type FileHasher struct {
+	workers chan struct{}
+}
+
+// NewFileHasher returns a FileHasher that hashes at most workers files at the
+// same time
+func NewFileHasher(workers int) *FileHasher {
+	if workers < 1 {
+		workers = 1
+	}
+	return &FileHasher{workers: make(chan struct{}, workers)}
+}
+
+// HashFiles returns the hashes of files, anchored at anchor, keyed by their
+// unix path
+func (h *FileHasher) HashFiles(anchor turbopath.AbsoluteSystemPath, files []turbopath.AnchoredSystemPath) (map[turbopath.AnchoredUnixPath]string, error) {
+	hashes := make([]string, len(files))
+	errs := make([]error, len(files))
+	var wg sync.WaitGroup
+	for i, file := range files {
+		// Waiting for a worker before starting the goroutine keeps the number
+		// of goroutines bounded too, for packages with many files
+		h.workers <- struct{}{}
+		wg.Add(1)
+		go func(i int, file turbopath.AnchoredSystemPath) {
+			defer func() {
+				<-h.workers
+				wg.Done()
+			}()
+			hashes[i], errs[i] = fs.GitLikeHashFile(file.RestoreAnchor(anchor).ToString())
+		}(i, file)
+	}
+	wg.Wait()
+
+	output := make(map[turbopath.AnchoredUnixPath]string, len(files))
+	for i, file := range files {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("could not hash file %v. \n%w", file.ToString(), errs[i])
+		}
+		output[file.ToUnixPath()] = hashes[i]
+	}
+	return output, nil
+}
@@ -0,0 +1,52 @@
+package hashing
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func TestFileHasher(t *testing.T) {
+	repoRoot := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	files := []turbopath.AnchoredSystemPath{
+		turbopath.AnchoredUnixPath("empty.json").ToSystemPath(),
+		turbopath.AnchoredUnixPath("src/hello.txt").ToSystemPath(),
+		turbopath.AnchoredUnixPath("fixtures/large.bin").ToSystemPath(),
+	}
+	contents := [][]byte{
+		{},
+		[]byte("hello\n"),
+		// Larger than the buffers that files are streamed through
+		bytes.Repeat([]byte("0123456789abcdef"), 1024*1024),
+	}
+	for i, file := range files {
+		path := file.RestoreAnchor(repoRoot)
+		assert.NilError(t, path.EnsureDir())
+		assert.NilError(t, path.WriteFile(contents[i], 0644))
+	}
+	for i := 0; i < 20; i++ {
+		file := turbopath.AnchoredUnixPath(fmt.Sprintf("src/file-%v.txt", i)).ToSystemPath()
+		assert.NilError(t, file.RestoreAnchor(repoRoot).WriteFile([]byte("hello\n"), 0644))
+		files = append(files, file)
+	}
+
+	hashes, err := NewFileHasher(2).HashFiles(repoRoot, files)
+	assert.NilError(t, err)
+	assert.Equal(t, len(hashes), len(files))
+	// The hashes are the ones of `git hash-object`
+	assert.Equal(t, hashes["empty.json"], "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391")
+	assert.Equal(t, hashes["src/hello.txt"], "ce013625030ba8dba906f756967f9e9ca394464a")
+	assert.Equal(t, hashes["src/file-19.txt"], "ce013625030ba8dba906f756967f9e9ca394464a")
+	gitHashes, err := gitHashObject(repoRoot, files[2:3])
+	if err == nil {
+		assert.Equal(t, hashes["fixtures/large.bin"], gitHashes["fixtures/large.bin"])
+	}
+
+	_, err = NewFileHasher(2).HashFiles(repoRoot, []turbopath.AnchoredSystemPath{
+		turbopath.AnchoredUnixPath("nonexistent.json").ToSystemPath(),
+	})
+	assert.ErrorContains(t, err, "could not hash file nonexistent.json")
+}
@@ -5,13 +5,15 @@ import (
 	"fmt"
 	"io"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
 
 	"github.com/pkg/errors"
+	"github.com/vercel/turbo/cli/internal/doublestar"
 	"github.com/vercel/turbo/cli/internal/encoding/gitoutput"
-	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/globby"
 	"github.com/vercel/turbo/cli/internal/turbopath"
 	"github.com/vercel/turbo/cli/internal/util"
@@ -26,15 +28,78 @@ type PackageDepsOptions struct {
 	InputPatterns []string
 }
 
+// InputGlobs are the `inputs` of a task, split into the globs that select
+// files and the globs prefixed with "!" that exclude them.
+type InputGlobs struct {
+	Inclusions []string
+	Exclusions []string
+}
+
+// ParseInputGlobs splits the `inputs` of a task into inclusions and exclusions
+func ParseInputGlobs(inputs []string) InputGlobs {
+	globs := InputGlobs{}
+	for _, input := range inputs {
+		if strings.HasPrefix(input, "!") {
+			globs.Exclusions = append(globs.Exclusions, input[1:])
+		} else {
+			globs.Inclusions = append(globs.Inclusions, input)
+		}
+	}
+	return globs
+}
+
+// Includes returns whether a file that was found for the inclusions, anchored
+// at the package, should be hashed. Files that match an exclusion, or are in a
+// folder that matches one, are not hashed. When there are inclusions, files
+// whose name or folder starts with a "." are only hashed if they are matched by
+// an inclusion that names a dotfile explicitly, such as ".env*" or "**/.babelrc".
+func (g InputGlobs) Includes(file turbopath.AnchoredUnixPath) bool {
+	filePath := file.ToString()
+	for _, exclusion := range g.Exclusions {
+		if matchesGlob(exclusion, filePath) || matchesGlob(path.Join(exclusion, "**"), filePath) {
+			return false
+		}
+	}
+	if len(g.Inclusions) == 0 || !hasDotSegment(filePath) {
+		return true
+	}
+	for _, inclusion := range g.Inclusions {
+		if hasDotSegment(inclusion) && matchesGlob(inclusion, filePath) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesGlob returns whether the unix path matches the glob. Invalid globs
+// don't match anything, since they don't match anything when globbing either.
+func matchesGlob(glob string, filePath string) bool {
+	matched, err := doublestar.Match(path.Clean(filepath.ToSlash(glob)), filePath)
+	return err == nil && matched
+}
+
+// hasDotSegment returns whether any segment of the unix path or glob starts
+// with a ".", not counting "." and ".." themselves
+func hasDotSegment(unixPath string) bool {
+	for _, segment := range strings.Split(unixPath, "/") {
+		if strings.HasPrefix(segment, ".") && segment != "." && segment != ".." {
+			return true
+		}
+	}
+	return false
+}
+
 // GetPackageDeps Builds an object containing git hashes for the files under the specified `packagePath` folder.
 func GetPackageDeps(rootPath turbopath.AbsoluteSystemPath, p *PackageDepsOptions) (map[turbopath.AnchoredUnixPath]string, error) {
 	pkgPath := rootPath.UntypedJoin(p.PackagePath.ToStringDuringMigration())
 	// Add all the checked in hashes.
 	var result map[turbopath.AnchoredUnixPath]string
 
-	// make a copy of the inputPatterns array, because we may be appending to it later.
-	calculatedInputs := make([]string, len(p.InputPatterns))
-	copy(calculatedInputs, p.InputPatterns)
+	globs := ParseInputGlobs(p.InputPatterns)
+
+	// make a copy of the inclusions, because we may be appending to it later.
+	calculatedInputs := make([]string, len(globs.Inclusions))
+	copy(calculatedInputs, globs.Inclusions)
 
 	if len(calculatedInputs) == 0 {
 		gitLsTreeOutput, err := gitLsTree(pkgPath)
@@ -57,30 +122,33 @@ func GetPackageDeps(rootPath turbopath.AbsoluteSystemPath, p *PackageDepsOptions
 		// The input patterns are relative to the package.
 		// However, we need to change the globbing to be relative to the repo root.
 		// Prepend the package path to each of the input patterns.
-		prefixedInputPatterns := make([]string, len(calculatedInputs))
-		for index, pattern := range calculatedInputs {
-			rerooted, err := rootPath.PathTo(pkgPath.UntypedJoin(pattern))
-			if err != nil {
-				return nil, err
-			}
-			prefixedInputPatterns[index] = rerooted
+		prefixedInputPatterns, err := rerootGlobs(rootPath, pkgPath, calculatedInputs)
+		if err != nil {
+			return nil, err
+		}
+		prefixedExclusions, err := rerootGlobs(rootPath, pkgPath, globs.Exclusions)
+		if err != nil {
+			return nil, err
 		}
 
-		absoluteFilesToHash, err := globby.GlobFiles(rootPath.ToStringDuringMigration(), prefixedInputPatterns, nil)
+		absoluteFilesToHash, err := globby.GlobFiles(rootPath.ToStringDuringMigration(), prefixedInputPatterns, prefixedExclusions)
 
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to resolve input globs %v", calculatedInputs)
 		}
 
-		filesToHash := make([]turbopath.AnchoredSystemPath, len(absoluteFilesToHash))
-		for i, rawPath := range absoluteFilesToHash {
+		filesToHash := make([]turbopath.AnchoredSystemPath, 0, len(absoluteFilesToHash))
+		for _, rawPath := range absoluteFilesToHash {
 			relativePathString, err := pkgPath.RelativePathString(rawPath)
 
 			if err != nil {
 				return nil, errors.Wrapf(err, "not relative to package: %v", rawPath)
 			}
 
-			filesToHash[i] = turbopath.AnchoredSystemPathFromUpstream(relativePathString)
+			relativePath := turbopath.AnchoredSystemPathFromUpstream(relativePathString)
+			if globs.Includes(relativePath.ToUnixPath()) {
+				filesToHash = append(filesToHash, relativePath)
+			}
 		}
 
 		hashes, err := gitHashObject(turbopath.AbsoluteSystemPathFromUpstream(pkgPath.ToStringDuringMigration()), filesToHash)
@@ -101,11 +169,18 @@ func GetPackageDeps(rootPath turbopath.AbsoluteSystemPath, p *PackageDepsOptions
 	for filePath, status := range gitStatusOutput {
 		if status.isDelete() {
 			delete(result, filePath)
-		} else {
+		} else if globs.Includes(filePath) {
 			filesToHash = append(filesToHash, filePath.ToSystemPath())
 		}
 	}
 
+	// Files from `git ls-tree` haven't been checked against the exclusions yet
+	for filePath := range result {
+		if !globs.Includes(filePath) {
+			delete(result, filePath)
+		}
+	}
+
 	hashes, err := gitHashObject(turbopath.AbsoluteSystemPathFromUpstream(pkgPath.ToString()), filesToHash)
 	if err != nil {
 		return nil, err
@@ -119,17 +194,17 @@ func GetPackageDeps(rootPath turbopath.AbsoluteSystemPath, p *PackageDepsOptions
 	return result, nil
 }
 
-func manuallyHashFiles(rootPath turbopath.AbsoluteSystemPath, files []turbopath.AnchoredSystemPath) (map[turbopath.AnchoredUnixPath]string, error) {
-	hashObject := make(map[turbopath.AnchoredUnixPath]string)
-	for _, file := range files {
-		hash, err := fs.GitLikeHashFile(file.ToString())
+// rerootGlobs changes globs relative to the package into globs relative to the repo root
+func rerootGlobs(rootPath turbopath.AbsoluteSystemPath, pkgPath turbopath.AbsoluteSystemPath, globs []string) ([]string, error) {
+	rerootedGlobs := make([]string, len(globs))
+	for index, pattern := range globs {
+		rerooted, err := rootPath.PathTo(pkgPath.UntypedJoin(pattern))
 		if err != nil {
-			return nil, fmt.Errorf("could not hash file %v. \n%w", file.ToString(), err)
+			return nil, err
 		}
-
-		hashObject[file.ToUnixPath()] = hash
+		rerootedGlobs[index] = rerooted
 	}
-	return hashObject, nil
+	return rerootedGlobs, nil
 }
 
 // GetHashableDeps hashes the list of given files, then returns a map of normalized path to hash
@@ -147,7 +222,7 @@ func GetHashableDeps(rootPath turbopath.AbsoluteSystemPath, files []turbopath.Ab
 	}
 	hashObject, err := gitHashObject(convertedRootPath, output)
 	if err != nil {
-		manuallyHashedObject, err := manuallyHashFiles(convertedRootPath, output)
+		manuallyHashedObject, err := NewFileHasher(runtime.NumCPU()).HashFiles(convertedRootPath, output)
 		if err != nil {
 			return nil, err
 		}
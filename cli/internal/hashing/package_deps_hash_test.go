@@ -232,6 +232,7 @@ func TestGetPackageDeps(t *testing.T) {
 	// <root>/
 	//   new-root-file <- new file not added to git
 	//   my-pkg/
+	//     .env
 	//     committed-file
 	//     deleted-file
 	//     uncommitted-file <- new file not added to git
@@ -260,6 +261,10 @@ func TestGetPackageDeps(t *testing.T) {
 	assert.NilError(t, nestedPath.EnsureDir(), "EnsureDir")
 	assert.NilError(t, nestedPath.WriteFile([]byte("nested"), 0644), "WriteFile")
 
+	// create a dotfile
+	dotenvPath := myPkgDir.UntypedJoin(".env")
+	assert.NilError(t, dotenvPath.WriteFile([]byte("dotenv"), 0644), "WriteFile")
+
 	// create a package.json
 	packageJSONPath := myPkgDir.UntypedJoin("package.json")
 	err = packageJSONPath.WriteFile([]byte("{}"), 0644)
@@ -296,6 +301,7 @@ func TestGetPackageDeps(t *testing.T) {
 				PackagePath: "my-pkg",
 			},
 			expected: map[turbopath.AnchoredUnixPath]string{
+				".env":             "40448e65cfd136e90f63850feb72119264305848",
 				"committed-file":   "3a29e62ea9ba15c4a4009d1f605d391cdd262033",
 				"uncommitted-file": "4e56ad89387e6379e4e91ddfe9872cf6a72c9976",
 				"package.json":     "9e26dfeeb6e641a33dae4961196235bdb965b21b",
@@ -365,6 +371,52 @@ func TestGetPackageDeps(t *testing.T) {
 				"uncommitted-file": "4e56ad89387e6379e4e91ddfe9872cf6a72c9976",
 			},
 		},
+		// inputs can exclude files with "!"
+		{
+			opts: &PackageDepsOptions{
+				PackagePath:   "my-pkg",
+				InputPatterns: []string{"**/*-file", "!uncommitted-file", "!dir"},
+			},
+			expected: map[turbopath.AnchoredUnixPath]string{
+				"committed-file": "3a29e62ea9ba15c4a4009d1f605d391cdd262033",
+				"package.json":   "9e26dfeeb6e641a33dae4961196235bdb965b21b",
+			},
+		},
+		// exclusions alone start from all of the files
+		{
+			opts: &PackageDepsOptions{
+				PackagePath:   "my-pkg",
+				InputPatterns: []string{"!**/*-file"},
+			},
+			expected: map[turbopath.AnchoredUnixPath]string{
+				".env":         "40448e65cfd136e90f63850feb72119264305848",
+				"package.json": "9e26dfeeb6e641a33dae4961196235bdb965b21b",
+			},
+		},
+		// wildcards only match dotfiles that are named explicitly
+		{
+			opts: &PackageDepsOptions{
+				PackagePath:   "my-pkg",
+				InputPatterns: []string{"*"},
+			},
+			expected: map[turbopath.AnchoredUnixPath]string{
+				"committed-file":   "3a29e62ea9ba15c4a4009d1f605d391cdd262033",
+				"package.json":     "9e26dfeeb6e641a33dae4961196235bdb965b21b",
+				"uncommitted-file": "4e56ad89387e6379e4e91ddfe9872cf6a72c9976",
+			},
+		},
+		{
+			opts: &PackageDepsOptions{
+				PackagePath:   "my-pkg",
+				InputPatterns: []string{"*", ".env*"},
+			},
+			expected: map[turbopath.AnchoredUnixPath]string{
+				".env":             "40448e65cfd136e90f63850feb72119264305848",
+				"committed-file":   "3a29e62ea9ba15c4a4009d1f605d391cdd262033",
+				"package.json":     "9e26dfeeb6e641a33dae4961196235bdb965b21b",
+				"uncommitted-file": "4e56ad89387e6379e4e91ddfe9872cf6a72c9976",
+			},
+		},
 	}
 	for _, tt := range tests {
 		got, err := GetPackageDeps(repoRoot, tt.opts)
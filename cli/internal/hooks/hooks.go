@@ -0,0 +1,153 @@
+// Package hooks runs the lifecycle hooks configured in the "hooks" key of turbo.json.
+//
+// Hooks are shell commands. They run from the root of the repository and receive
+// a JSON encoded Payload on stdin, along with a handful of TURBO_* environment
+// variables for simple scripts. Failures of "pre" hooks are returned as errors
+// so that callers can stop the run or task; other hooks only warn.
+package hooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/mitchellh/cli"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// Event identifies a point in the lifecycle of a run
+type Event string
+
+const (
+	// PreRun runs before any task is executed
+	PreRun Event = "preRun"
+	// PostRun runs after all tasks have finished
+	PostRun Event = "postRun"
+	// PreTask runs before a task is restored from cache or executed
+	PreTask Event = "preTask"
+	// PostTask runs after a task has finished
+	PostTask Event = "postTask"
+	// OnCacheMiss runs when a task was not found in the cache, before it executes
+	OnCacheMiss Event = "onCacheMiss"
+)
+
+// Payload describes the run or task that a hook is being run for
+type Payload struct {
+	Event    Event    `json:"event"`
+	Targets  []string `json:"targets,omitempty"`
+	Packages []string `json:"packages,omitempty"`
+
+	TaskID      string `json:"taskId,omitempty"`
+	Task        string `json:"task,omitempty"`
+	Package     string `json:"package,omitempty"`
+	Hash        string `json:"hash,omitempty"`
+	CacheStatus string `json:"cacheStatus,omitempty"`
+	// Status is only set for postTask: "cached", "built", "failed" or "timedOut"
+	Status string `json:"status,omitempty"`
+
+	// ExitCode is only set for postRun and postTask
+	ExitCode *int `json:"exitCode,omitempty"`
+	// Duration is in milliseconds, and only set for postRun and postTask
+	Duration *int64 `json:"duration,omitempty"`
+}
+
+// Runner runs the configured hooks
+type Runner struct {
+	hooks    fs.Hooks
+	repoRoot turbopath.AbsoluteSystemPath
+	logger   hclog.Logger
+}
+
+// New returns a Runner for the given hooks configuration
+func New(hooks fs.Hooks, repoRoot turbopath.AbsoluteSystemPath, logger hclog.Logger) *Runner {
+	return &Runner{
+		hooks:    hooks,
+		repoRoot: repoRoot,
+		logger:   logger.Named("hooks"),
+	}
+}
+
+func (r *Runner) commandsFor(event Event) []string {
+	switch event {
+	case PreRun:
+		return r.hooks.PreRun
+	case PostRun:
+		return r.hooks.PostRun
+	case PreTask:
+		return r.hooks.PreTask
+	case PostTask:
+		return r.hooks.PostTask
+	case OnCacheMiss:
+		return r.hooks.OnCacheMiss
+	}
+	return nil
+}
+
+// Run runs the commands configured for the payload's event in order, writing their
+// output to the given UI. It stops at the first command that fails.
+func (r *Runner) Run(payload *Payload, output cli.Ui) error {
+	commands := r.commandsFor(payload.Event)
+	if len(commands) == 0 {
+		return nil
+	}
+	stdin, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	env := append(os.Environ(), environment(payload)...)
+	for _, command := range commands {
+		r.logger.Debug("running hook", "event", payload.Event, "command", command, "task", payload.TaskID)
+		cmd := shellCommand(command)
+		cmd.Dir = r.repoRoot.ToString()
+		cmd.Env = env
+		cmd.Stdin = bytes.NewReader(stdin)
+		out, err := cmd.CombinedOutput()
+		for _, line := range strings.Split(strings.TrimRight(string(out), "\n"), "\n") {
+			if line != "" {
+				output.Output(line)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("%v hook %q failed: %w", payload.Event, command, err)
+		}
+	}
+	return nil
+}
+
+func environment(payload *Payload) []string {
+	env := []string{fmt.Sprintf("TURBO_HOOK=%v", payload.Event)}
+	if payload.TaskID != "" {
+		env = append(env,
+			fmt.Sprintf("TURBO_TASK_ID=%v", payload.TaskID),
+			fmt.Sprintf("TURBO_TASK=%v", payload.Task),
+			fmt.Sprintf("TURBO_PACKAGE=%v", payload.Package),
+			fmt.Sprintf("TURBO_HASH=%v", payload.Hash),
+		)
+	}
+	if payload.CacheStatus != "" {
+		env = append(env, fmt.Sprintf("TURBO_CACHE_STATUS=%v", payload.CacheStatus))
+	}
+	if payload.Status != "" {
+		env = append(env, fmt.Sprintf("TURBO_TASK_STATUS=%v", payload.Status))
+	}
+	if payload.ExitCode != nil {
+		env = append(env, fmt.Sprintf("TURBO_EXIT_CODE=%d", *payload.ExitCode))
+	}
+	if payload.Duration != nil {
+		env = append(env, fmt.Sprintf("TURBO_DURATION=%d", *payload.Duration))
+	}
+	return env
+}
+
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
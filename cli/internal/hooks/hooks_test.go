@@ -0,0 +1,57 @@
+package hooks
+
+import (
+	"encoding/json"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/mitchellh/cli"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+func TestRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks in this test are written for sh")
+	}
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	runner := New(fs.Hooks{
+		PreTask:  []string{"cat > payload.json", "echo \"$TURBO_HOOK $TURBO_TASK_ID $TURBO_HASH\""},
+		PostTask: []string{"echo \"$TURBO_TASK_STATUS exit $TURBO_EXIT_CODE in ${TURBO_DURATION}ms\"", "exit 3", "echo unreachable"},
+	}, repoRoot, hclog.Default())
+
+	ui := cli.NewMockUi()
+	err := runner.Run(&Payload{
+		Event:   PreTask,
+		TaskID:  "web#build",
+		Task:    "build",
+		Package: "web",
+		Hash:    "abc123",
+	}, ui)
+	assert.NilError(t, err)
+	assert.Equal(t, strings.TrimSpace(ui.OutputWriter.String()), "preTask web#build abc123")
+
+	contents, err := repoRoot.UntypedJoin("payload.json").ReadFile()
+	assert.NilError(t, err)
+	payload := &Payload{}
+	assert.NilError(t, json.Unmarshal(contents, payload))
+	assert.DeepEqual(t, payload, &Payload{
+		Event:   PreTask,
+		TaskID:  "web#build",
+		Task:    "build",
+		Package: "web",
+		Hash:    "abc123",
+	})
+
+	ui = cli.NewMockUi()
+	exitCode := 1
+	duration := int64(1500)
+	err = runner.Run(&Payload{Event: PostTask, TaskID: "web#build", Status: "failed", ExitCode: &exitCode, Duration: &duration}, ui)
+	assert.ErrorContains(t, err, "postTask hook \"exit 3\" failed")
+	assert.Equal(t, strings.TrimSpace(ui.OutputWriter.String()), "failed exit 1 in 1500ms")
+
+	// Events without any commands configured are a no-op
+	assert.NilError(t, runner.Run(&Payload{Event: PostRun}, cli.NewMockUi()))
+}
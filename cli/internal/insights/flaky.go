@@ -0,0 +1,70 @@
+package insights
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/runsummary"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// ExecuteFlaky executes the `flaky` command, which `turbo insights flaky` replaces
+func ExecuteFlaky(helper *cmdutil.Helper, args *turbostate.ParsedArgsFromRust) error {
+	base, err := helper.GetCmdBase(args)
+	if err != nil {
+		return err
+	}
+	if err := flaky(base, args.Command.Flaky); err != nil {
+		base.LogError("flaky failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// flaky reports the tasks whose outcome differed for the same hash in the run
+// summaries saved in the repository
+func flaky(base *cmdutil.CmdBase, opts *turbostate.FlakyPayload) error {
+	maxRuns := opts.Runs
+	if maxRuns <= 0 {
+		maxRuns = runsummary.DefaultHistoricalRuns
+	}
+	flakyTasks, err := runsummary.AnalyzeFlakiness(base.RepoRoot, maxRuns)
+	if err != nil {
+		return fmt.Errorf("failed to analyze run summaries: %w", err)
+	}
+
+	if opts.JSON {
+		rendered, err := json.MarshalIndent(flakyTasks, "", "  ")
+		if err != nil {
+			return err
+		}
+		base.UI.Output(string(rendered))
+		return nil
+	}
+
+	if len(flakyTasks) == 0 {
+		base.UI.Output(fmt.Sprintf("No flaky tasks found in the last %v runs. Run summaries are saved by `turbo run --summarize`", maxRuns))
+		return nil
+	}
+	base.UI.Output(util.Sprintf("${BOLD}%v flaky tasks${RESET} in the last %v runs", len(flakyTasks), maxRuns))
+	base.UI.Output("")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, util.Sprintf("  ${GREY}Task\tFlake rate\tFlaky failures\tExecutions\tHashes${RESET}"))
+	for _, flakyTask := range flakyTasks {
+		fmt.Fprintf(
+			w,
+			"  %s\t%.0f%%\t%d\t%d\t%s\n",
+			flakyTask.TaskID,
+			flakyTask.FlakeRate*100,
+			flakyTask.FlakyFailures,
+			flakyTask.Executions,
+			strings.Join(flakyTask.FlakyHashes, ", "),
+		)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,32 @@
+// Package insights implements `turbo insights`, which analyzes the run
+// summaries saved in the repository by `turbo run --summarize`
+package insights
+
+import (
+	"fmt"
+
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+)
+
+// ExecuteInsights executes the `insights` command.
+func ExecuteInsights(helper *cmdutil.Helper, args *turbostate.ParsedArgsFromRust) error {
+	base, err := helper.GetCmdBase(args)
+	if err != nil {
+		return err
+	}
+	if err := insights(base, args.Command.Insights); err != nil {
+		base.LogError("insights failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+func insights(base *cmdutil.CmdBase, opts *turbostate.InsightsPayload) error {
+	switch opts.Command {
+	case "Flaky":
+		return flaky(base, &turbostate.FlakyPayload{JSON: opts.JSON, Runs: opts.Runs})
+	default:
+		return fmt.Errorf("unknown subcommand: %v", opts.Command)
+	}
+}
@@ -76,7 +76,11 @@ type BerryLockfile struct {
 	patches map[_Locator]_Locator
 	// Descriptors that are only used by package extensions
 	packageExtensions map[_Descriptor]_void
-	hasCRLF           bool
+	// The "resolutions" of the root package.json, which override the version
+	// of the dependencies that match them. The lockfile has no descriptor for
+	// the dependencies they override.
+	resolutions map[string]string
+	hasCRLF     bool
 }
 
 // BerryDependencyMetaEntry Structure for holding if a package is optional or not
@@ -89,18 +93,80 @@ var _ Lockfile = (*BerryLockfile)(nil)
 
 // ResolvePackage Given a package and version returns the key, resolved version, and if it was found
 func (l *BerryLockfile) ResolvePackage(_workspace turbopath.AnchoredUnixPath, name string, version string) (Package, error) {
+	if locator, ok := l.resolveDescriptor(name, version); ok {
+		entry := l.packages[locator]
+		return Package{
+			Found:   true,
+			Key:     locator.String(),
+			Version: entry.Version,
+		}, nil
+	}
+
+	return Package{}, nil
+}
+
+func (l *BerryLockfile) resolveDescriptor(name string, version string) (_Locator, bool) {
 	for _, key := range berryPossibleKeys(name, version) {
 		if locator, ok := l.descriptors[key]; ok {
-			entry := l.packages[locator]
-			return Package{
-				Found:   true,
-				Key:     locator.String(),
-				Version: entry.Version,
-			}, nil
+			return locator, true
 		}
 	}
 
-	return Package{}, nil
+	override, ok := l.resolution(name, version)
+	if !ok {
+		return _Locator{}, false
+	}
+	// The lockfile keys patches by a descriptor that yarn extends with the
+	// workspace that declared them, so they are found by their patch file
+	if patchPath, isPatch := resolutionPatchPath(override); isPatch {
+		for _, patchLocator := range l.patches {
+			if path, _ := patchLocator.patchPath(); path == patchPath && patchLocator._Ident.String() == name {
+				return patchLocator, true
+			}
+		}
+		return _Locator{}, false
+	}
+	for _, key := range berryPossibleKeys(name, override) {
+		if locator, ok := l.descriptors[key]; ok {
+			return locator, true
+		}
+	}
+	return _Locator{}, false
+}
+
+// resolution returns the version that the resolutions of the root package.json
+// give a dependency on name@version. Resolutions that only apply to the
+// dependencies of a given package aren't supported.
+func (l *BerryLockfile) resolution(name string, version string) (string, bool) {
+	for _, pattern := range []string{
+		name,
+		fmt.Sprintf("%s@%s", name, version),
+		fmt.Sprintf("%s@npm:%s", name, version),
+	} {
+		for _, prefix := range []string{"", "**/"} {
+			if override, ok := l.resolutions[prefix+pattern]; ok {
+				return override, true
+			}
+		}
+	}
+	return "", false
+}
+
+// resolutionPatchPath returns the patch file of a resolution to a patch, e.g.
+// patch:lodash@npm:4.17.21#./.yarn/patches/lodash.patch
+func resolutionPatchPath(override string) (string, bool) {
+	if !strings.HasPrefix(override, "patch:") {
+		return "", false
+	}
+	patchFileIndex := strings.Index(override, "#")
+	if patchFileIndex == -1 {
+		return "", false
+	}
+	patchPath := override[patchFileIndex+1:]
+	if paramIndex := strings.Index(patchPath, "::"); paramIndex != -1 {
+		patchPath = patchPath[:paramIndex]
+	}
+	return strings.TrimPrefix(patchPath, "./"), true
 }
 
 // AllDependencies Given a lockfile key return all (dev/optional/peer) dependencies of that package
@@ -129,6 +195,12 @@ func (l *BerryLockfile) Subgraph(workspacePackages []turbopath.AnchoredSystemPat
 	prunedPackages := make(map[_Locator]*BerryLockfileEntry, len(packages))
 	prunedDescriptors := make(map[_Descriptor]_Locator, len(prunedPackages))
 	patches := make(map[_Locator]_Locator, len(l.patches))
+	// Patches that dependencies resolve to directly, through resolutions
+	resolvedPatches := make(map[_Locator]_void)
+	primaryLocators := make(map[_Locator]_Locator, len(l.patches))
+	for primaryLocator, patchLocator := range l.patches {
+		primaryLocators[patchLocator] = primaryLocator
+	}
 	reverseLookup := l.locatorToDescriptors()
 
 	// add workspace package entries
@@ -170,6 +242,13 @@ func (l *BerryLockfile) Subgraph(workspacePackages []turbopath.AnchoredSystemPat
 			patches[locator] = patchLocator
 			prunedPackages[patchLocator] = l.packages[patchLocator]
 		}
+		// A patch needs the package that it patches
+		primaryLocator, ok := primaryLocators[locator]
+		if primaryEntry, hasPrimary := l.packages[primaryLocator]; ok && hasPrimary {
+			patches[primaryLocator] = locator
+			prunedPackages[primaryLocator] = primaryEntry
+			resolvedPatches[locator] = _void{}
+		}
 	}
 
 	for _, entry := range prunedPackages {
@@ -197,10 +276,9 @@ func (l *BerryLockfile) Subgraph(workspacePackages []turbopath.AnchoredSystemPat
 			}
 
 			_, ok := prunedDescriptors[primaryDescriptor]
-			if ok {
-				if !ok {
-					panic(fmt.Sprintf("Unable to find patch for %s", &patchLocator))
-				}
+			_, isResolved := resolvedPatches[patchLocator]
+			if ok || isResolved {
+				prunedDescriptors[primaryDescriptor] = primaryLocator
 				prunedDescriptors[patch] = patchLocator
 			}
 		}
@@ -231,6 +309,7 @@ func (l *BerryLockfile) Subgraph(workspacePackages []turbopath.AnchoredSystemPat
 		descriptors:       prunedDescriptors,
 		patches:           patches,
 		packageExtensions: l.packageExtensions,
+		resolutions:       l.resolutions,
 		hasCRLF:           l.hasCRLF,
 	}, nil
 }
@@ -314,8 +393,9 @@ func (l *BerryLockfile) Patches() []turbopath.AnchoredUnixPath {
 	return patches
 }
 
-// DecodeBerryLockfile Takes the contents of a berry lockfile and returns a struct representation
-func DecodeBerryLockfile(contents []byte) (*BerryLockfile, error) {
+// DecodeBerryLockfile Takes the contents of a berry lockfile and the resolutions of the root
+// package.json, and returns a struct representation
+func DecodeBerryLockfile(contents []byte, resolutions map[string]string) (*BerryLockfile, error) {
 	var packages map[string]*BerryLockfileEntry
 
 	hasCRLF := bytes.HasSuffix(contents, _crlfLiteral)
@@ -396,6 +476,7 @@ func DecodeBerryLockfile(contents []byte) (*BerryLockfile, error) {
 		descriptors:       descriptorToLocator,
 		patches:           patches,
 		packageExtensions: packageExtensions,
+		resolutions:       resolutions,
 		hasCRLF:           hasCRLF,
 	}
 	return &lockfile, nil
@@ -13,7 +13,7 @@ func getBerryLockfile(t *testing.T, filename string) *BerryLockfile {
 	if err != nil {
 		t.Error(err)
 	}
-	lockfile, err := DecodeBerryLockfile(content)
+	lockfile, err := DecodeBerryLockfile(content, nil)
 	if err != nil {
 		t.Error(err)
 	}
@@ -145,7 +145,7 @@ func Test_BerryRoundtrip(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	lockfile, err := DecodeBerryLockfile(content)
+	lockfile, err := DecodeBerryLockfile(content, nil)
 	if err != nil {
 		t.Error(err)
 	}
@@ -271,3 +271,105 @@ func Test_BerryPruneDescriptors(t *testing.T) {
 	assert.Assert(t, !lockfileAHasB, "Expected lockfile a not to have descriptor used by b")
 	assert.Assert(t, !lockfileBHasA, "Expected lockfile b not to have descriptor used by a")
 }
+
+// The resolutions of the berry.lock fixture's root package.json
+var _berryResolutions = map[string]string{
+	"lodash@^4.17.21": "patch:lodash@npm:4.17.21#./.yarn/patches/lodash-npm-4.17.21-6382451519.patch",
+}
+
+const _lodashPatchLocator = "lodash@patch:lodash@npm%3A4.17.21#./.yarn/patches/lodash-npm-4.17.21-6382451519.patch::version=4.17.21&hash=2c6e9e&locator=berry-patch%40workspace%3A."
+
+func Test_BerryResolvePatchResolution(t *testing.T) {
+	content, err := getFixture(t, "berry.lock")
+	assert.NilError(t, err)
+	lockfile, err := DecodeBerryLockfile(content, _berryResolutions)
+	assert.NilError(t, err)
+
+	pkg, err := lockfile.ResolvePackage("apps/docs", "lodash", "^4.17.21")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, pkg, Package{Found: true, Key: _lodashPatchLocator, Version: "4.17.21"})
+
+	// Without the resolutions nothing in the lockfile matches the dependency
+	lockfile, err = DecodeBerryLockfile(content, nil)
+	assert.NilError(t, err)
+	pkg, err = lockfile.ResolvePackage("apps/docs", "lodash", "^4.17.21")
+	assert.NilError(t, err)
+	assert.Assert(t, !pkg.Found)
+}
+
+func Test_BerryPrunePatchResolution(t *testing.T) {
+	content, err := getFixture(t, "berry.lock")
+	assert.NilError(t, err)
+	lockfile, err := DecodeBerryLockfile(content, _berryResolutions)
+	assert.NilError(t, err)
+
+	prunedLockfile, err := lockfile.Subgraph(
+		[]turbopath.AnchoredSystemPath{turbopath.AnchoredUnixPath("apps/docs").ToSystemPath()},
+		[]string{_lodashPatchLocator},
+	)
+	assert.NilError(t, err)
+	berryLockfile := prunedLockfile.(*BerryLockfile)
+
+	// The patch is kept along with the package that it patches
+	assert.DeepEqual(t, berryLockfile.Patches(), []turbopath.AnchoredUnixPath{".yarn/patches/lodash-npm-4.17.21-6382451519.patch"})
+	for _, descriptor := range []string{
+		"lodash@npm:4.17.21",
+		"lodash@patch:lodash@npm%3A4.17.21#./.yarn/patches/lodash-npm-4.17.21-6382451519.patch::locator=berry-patch%40workspace%3A.",
+	} {
+		var d _Descriptor
+		assert.NilError(t, d.parseDescriptor(descriptor))
+		_, ok := berryLockfile.descriptors[d]
+		assert.Assert(t, ok, "expected the pruned lockfile to have %v", descriptor)
+	}
+}
+
+func Test_BerryPruneWorkspaceProtocol(t *testing.T) {
+	lockfile := getBerryLockfile(t, "berry-workspace-protocol.lock")
+	prunedLockfile, err := lockfile.Subgraph(
+		[]turbopath.AnchoredSystemPath{
+			turbopath.AnchoredUnixPath("packages/a").ToSystemPath(),
+			turbopath.AnchoredUnixPath("packages/c").ToSystemPath(),
+		},
+		[]string{"lodash@npm:4.17.21"},
+	)
+	assert.NilError(t, err)
+
+	// Only the workspace: descriptors of the kept workspaces are kept
+	var b bytes.Buffer
+	assert.NilError(t, prunedLockfile.Encode(&b))
+	assert.Equal(t, b.String(), `# This file is generated by running "yarn install" inside your project.
+# Manual changes might be lost - proceed with caution!
+
+__metadata:
+  version: 6
+  cacheKey: 8c8
+
+"a@workspace:packages/a":
+  version: 0.0.0-use.local
+  resolution: "a@workspace:packages/a"
+  dependencies:
+    c: "workspace:^"
+    lodash: ^4.17.0
+  languageName: unknown
+  linkType: soft
+
+"c@workspace:^, c@workspace:packages/c":
+  version: 0.0.0-use.local
+  resolution: "c@workspace:packages/c"
+  languageName: unknown
+  linkType: soft
+
+"lodash@npm:^4.17.0":
+  version: 4.17.21
+  resolution: "lodash@npm:4.17.21"
+  checksum: eb835a2e51d381e561e508ce932ea50a8e5a68f4ebdd771ea240d3048244a8d13658acbd502cd4829768c56f2e16bdd4340b9ea141297d472517b83868e677f7
+  languageName: node
+  linkType: hard
+
+"workspace-protocol@workspace:.":
+  version: 0.0.0-use.local
+  resolution: "workspace-protocol@workspace:."
+  languageName: unknown
+  linkType: soft
+`)
+}
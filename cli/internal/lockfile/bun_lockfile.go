@@ -0,0 +1,98 @@
+package lockfile
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// _bunLockfileHeader starts every bun.lockb, whatever the version of its format
+var _bunLockfileHeader = []byte("#!/usr/bin/env bun\nbun-lockfile-format-v0\n")
+
+// BunLockfile representation of bun's binary lockfile. Its format isn't
+// stable across versions of bun, so it is read through `bun bun.lockb`,
+// which prints the same packages in the format of a yarn v1 lockfile.
+type BunLockfile struct {
+	yarn *YarnLockfile
+}
+
+var _ Lockfile = (*BunLockfile)(nil)
+
+// printBunLockfile returns the yarn v1 lockfile that bun prints for the
+// bun.lockb at path
+var printBunLockfile = func(path string) ([]byte, error) {
+	cmd := exec.Command("bun", filepath.Base(path))
+	cmd.Dir = filepath.Dir(path)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "running `bun bun.lockb`: %s", stderr.String())
+	}
+	return out, nil
+}
+
+// ResolvePackage Given a package and version returns the key, resolved version, and if it was found
+func (l *BunLockfile) ResolvePackage(workspacePath turbopath.AnchoredUnixPath, name string, version string) (Package, error) {
+	return l.yarn.ResolvePackage(workspacePath, name, version)
+}
+
+// AllDependencies Given a lockfile key return all (dev/optional/peer) dependencies of that package
+func (l *BunLockfile) AllDependencies(key string) (map[string]string, bool) {
+	return l.yarn.AllDependencies(key)
+}
+
+// Subgraph Given a list of lockfile keys returns a Lockfile based off the original one that only contains the packages given
+func (l *BunLockfile) Subgraph(_ []turbopath.AnchoredSystemPath, _ []string) (Lockfile, error) {
+	return nil, errors.New("pruning bun.lockb is not supported")
+}
+
+// Encode encode the lockfile representation and write it to the given writer
+func (l *BunLockfile) Encode(_ io.Writer) error {
+	return errors.New("writing bun.lockb is not supported")
+}
+
+// Patches return a list of patches used in the lockfile
+func (l *BunLockfile) Patches() []turbopath.AnchoredUnixPath {
+	return nil
+}
+
+// GlobalChange checks if there are any differences between lockfiles that would completely invalidate
+// the cache.
+func (l *BunLockfile) GlobalChange(other Lockfile) bool {
+	_, ok := other.(*BunLockfile)
+	return !ok
+}
+
+// DecodeBunLockfile Takes the contents of a bun.lockb and returns a struct representation
+func DecodeBunLockfile(contents []byte) (*BunLockfile, error) {
+	if !bytes.HasPrefix(contents, _bunLockfileHeader) {
+		return nil, errors.New("Unable to decode bun.lockb: missing header")
+	}
+	// The contents may come from git rather than the repository, so bun reads
+	// a copy of them. It only prints files that are named bun.lockb.
+	dir, err := ioutil.TempDir("", "turbo-bun-lockfile")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+	path := filepath.Join(dir, "bun.lockb")
+	if err := ioutil.WriteFile(path, contents, 0644); err != nil {
+		return nil, err
+	}
+	printed, err := printBunLockfile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to decode bun.lockb")
+	}
+	yarn, err := DecodeYarnLockfile(printed)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to decode bun.lockb")
+	}
+	return &BunLockfile{yarn: yarn}, nil
+}
@@ -0,0 +1,46 @@
+package lockfile
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestDecodeBunLockfile(t *testing.T) {
+	printed, err := getFixture(t, "yarn.lock")
+	assert.NilError(t, err)
+	contents := append(append([]byte{}, _bunLockfileHeader...), 2, 0, 0, 0)
+	original := printBunLockfile
+	defer func() { printBunLockfile = original }()
+	var printedPath string
+	printBunLockfile = func(path string) ([]byte, error) {
+		printedPath = path
+		written, err := os.ReadFile(path)
+		assert.NilError(t, err)
+		assert.Assert(t, bytes.Equal(written, contents))
+		return printed, nil
+	}
+
+	lockfile, err := DecodeBunLockfile(contents)
+	assert.NilError(t, err)
+	assert.Equal(t, filepath.Base(printedPath), "bun.lockb")
+	// The copy bun read is removed
+	_, err = os.Stat(printedPath)
+	assert.Assert(t, os.IsNotExist(err))
+
+	pkg, err := lockfile.ResolvePackage("apps/web", "@babel/types", "^7.18.10")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, pkg, Package{Key: "@babel/types@^7.18.10", Version: "7.19.0", Found: true})
+	assert.Assert(t, !lockfile.GlobalChange(&BunLockfile{}))
+	assert.Assert(t, lockfile.GlobalChange(&YarnLockfile{}))
+	_, err = lockfile.Subgraph(nil, []string{"@babel/types@^7.18.10"})
+	assert.ErrorContains(t, err, "not supported")
+}
+
+func TestDecodeBunLockfile_missingHeader(t *testing.T) {
+	_, err := DecodeBunLockfile([]byte("# yarn lockfile v1\n"))
+	assert.ErrorContains(t, err, "missing header")
+}
@@ -23,11 +23,13 @@ type PnpmLockfile struct {
 
 	// Before 6.0 version was stored as a float, but as of 6.0+ it's a string
 	Version                   interface{}                `yaml:"lockfileVersion"`
+	Settings                  *LockfileSettings          `yaml:"settings,omitempty"`
 	NeverBuiltDependencies    []string                   `yaml:"neverBuiltDependencies,omitempty"`
 	OnlyBuiltDependencies     []string                   `yaml:"onlyBuiltDependencies,omitempty"`
 	Overrides                 map[string]string          `yaml:"overrides,omitempty"`
 	PackageExtensionsChecksum string                     `yaml:"packageExtensionsChecksum,omitempty"`
 	PatchedDependencies       map[string]PatchFile       `yaml:"patchedDependencies,omitempty"`
+	PnpmfileChecksum          string                     `yaml:"pnpmfileChecksum,omitempty"`
 	Importers                 map[string]ProjectSnapshot `yaml:"importers"`
 	Packages                  map[string]PackageSnapshot `yaml:"packages,omitempty"`
 	Time                      map[string]string          `yaml:"time,omitempty"`
@@ -35,6 +37,13 @@ type PnpmLockfile struct {
 
 var _ Lockfile = (*PnpmLockfile)(nil)
 
+// LockfileSettings are the settings that the lockfile was resolved with. pnpm
+// refuses to install from a lockfile whose settings don't match its own.
+type LockfileSettings struct {
+	AutoInstallPeers         bool `yaml:"autoInstallPeers"`
+	ExcludeLinksFromLockfile bool `yaml:"excludeLinksFromLockfile"`
+}
+
 // ProjectSnapshot Snapshot used to represent projects in the importers section
 type ProjectSnapshot struct {
 	// for v6 we omitempty
@@ -312,13 +321,16 @@ func (p *PnpmLockfile) Subgraph(workspacePackages []turbopath.AnchoredSystemPath
 
 	lockfile := PnpmLockfile{
 		Version:                   p.Version,
+		Settings:                  p.Settings,
 		Packages:                  lockfilePackages,
 		NeverBuiltDependencies:    p.NeverBuiltDependencies,
 		OnlyBuiltDependencies:     p.OnlyBuiltDependencies,
 		Overrides:                 p.Overrides,
 		PackageExtensionsChecksum: p.PackageExtensionsChecksum,
 		PatchedDependencies:       p.prunePatches(p.PatchedDependencies, lockfilePackages),
+		PnpmfileChecksum:          p.PnpmfileChecksum,
 		Importers:                 importers,
+		Time:                      pruneTime(p.Time, lockfilePackages),
 	}
 
 	return &lockfile, nil
@@ -348,6 +360,22 @@ func pruneImporters(importers map[string]ProjectSnapshot, workspacePackages []tu
 	return prunedImporters, nil
 }
 
+// pruneTime keeps the publish times of the packages that are kept, which are
+// recorded when pnpm uses time-based resolution
+func pruneTime(time map[string]string, packages map[string]PackageSnapshot) map[string]string {
+	if len(time) == 0 {
+		return nil
+	}
+
+	prunedTime := make(map[string]string, len(packages))
+	for key, publishedAt := range time {
+		if _, ok := packages[key]; ok {
+			prunedTime[key] = publishedAt
+		}
+	}
+	return prunedTime
+}
+
 func (p *PnpmLockfile) prunePatches(patches map[string]PatchFile, packages map[string]PackageSnapshot) map[string]PatchFile {
 	if len(patches) == 0 {
 		return nil
@@ -26,7 +26,7 @@ func getFixture(t *testing.T, name string) ([]byte, error) {
 }
 
 func Test_Roundtrip(t *testing.T) {
-	lockfiles := []string{"pnpm6-workspace.yaml", "pnpm7-workspace.yaml", "pnpm8.yaml"}
+	lockfiles := []string{"pnpm6-workspace.yaml", "pnpm7-workspace.yaml", "pnpm8.yaml", "pnpm-settings-v6.yaml"}
 
 	for _, lockfilePath := range lockfiles {
 		lockfileContent, err := getFixture(t, lockfilePath)
@@ -234,6 +234,30 @@ func Test_PnpmPrunePatchesV6(t *testing.T) {
 	assert.Equal(t, len(prunedLockfile.Patches()), 1)
 }
 
+func Test_PnpmSubgraphSettings(t *testing.T) {
+	contents, err := getFixture(t, "pnpm-settings-v6.yaml")
+	assert.NilError(t, err)
+
+	lockfile, err := DecodePnpmLockfile(contents)
+	assert.NilError(t, err)
+
+	prunedLockfile, err := lockfile.Subgraph(
+		[]turbopath.AnchoredSystemPath{turbopath.AnchoredSystemPath("packages/b")},
+		[]string{"/lodash@4.17.21"},
+	)
+	assert.NilError(t, err)
+
+	var b bytes.Buffer
+	assert.NilError(t, prunedLockfile.Encode(&b))
+	pruned, err := DecodePnpmLockfile(b.Bytes())
+	assert.NilError(t, err)
+
+	assert.DeepEqual(t, pruned.Settings, &LockfileSettings{AutoInstallPeers: true})
+	assert.Equal(t, pruned.PnpmfileChecksum, "6gs5qzpg7mskzqxdu4cgv3rxgu")
+	assert.DeepEqual(t, pruned.Time, map[string]string{"/lodash@4.17.21": "2021-02-20T15:42:16.891Z"})
+	assert.Equal(t, len(pruned.Importers), 2)
+}
+
 func Test_PnpmAbsoluteDependency(t *testing.T) {
 	type testCase struct {
 		fixture string
@@ -0,0 +1,120 @@
+// Package login implements `turbo login` for remote caches behind an OpenID
+// Connect provider, configured with the "auth" of "remoteCache" in turbo.json,
+// and `turbo token`, which prints the token that turbo uses
+package login
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/vercel/turbo/cli/internal/auth"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/config"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+	"github.com/vercel/turbo/cli/internal/ui"
+	"github.com/vercel/turbo/cli/internal/util"
+	"github.com/vercel/turbo/cli/internal/util/browser"
+)
+
+// ExecuteLogin executes the `login` command. It is only passed to Go when
+// turbo.json configures the "auth" of "remoteCache": the Vercel login is in Rust.
+func ExecuteLogin(ctx context.Context, helper *cmdutil.Helper, args *turbostate.ParsedArgsFromRust) error {
+	base, err := helper.GetCmdBase(args)
+	if err != nil {
+		return err
+	}
+	if err := login(ctx, base, args.Command.Login); err != nil {
+		base.LogError("login failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+func login(ctx context.Context, base *cmdutil.CmdBase, opts *turbostate.LoginPayload) error {
+	if opts.SSOTeam != "" {
+		return errors.New("--sso-team signs in to Vercel, and can't be used with the \"auth\" of \"remoteCache\" in turbo.json")
+	}
+	rootPackageJSON, err := fs.ReadPackageJSON(base.RepoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		return fmt.Errorf("failed to read package.json: %w", err)
+	}
+	turboJSON, err := fs.LoadTurboConfig(base.RepoRoot, rootPackageJSON, false)
+	if err != nil {
+		return err
+	}
+	options := turboJSON.RemoteCacheOptions.Auth
+	if options == nil {
+		return errors.New("turbo.json has no \"auth\" in \"remoteCache\"")
+	}
+
+	provider := &auth.Provider{
+		Issuer:   options.Issuer,
+		ClientID: options.ClientID,
+		Scopes:   options.Scopes,
+	}
+	code, err := provider.RequestDeviceCode(ctx)
+	if err != nil {
+		return err
+	}
+	verificationURI := code.VerificationURIComplete
+	if verificationURI == "" {
+		verificationURI = code.VerificationURI
+	}
+	base.UI.Output(fmt.Sprintf(">>> Opening browser to %v", verificationURI))
+	base.UI.Output(util.Sprintf("    Confirm the code ${BOLD}%v${RESET} to authorize turbo", code.UserCode))
+	if err := browser.OpenBrowser(verificationURI); err != nil {
+		base.UI.Warn(fmt.Sprintf("Failed to open browser. Please visit %v in your browser.", verificationURI))
+	}
+
+	token, err := provider.WaitForToken(ctx, code)
+	if err != nil {
+		return err
+	}
+	if err := base.UserConfig.SetIssuedToken(token.AccessToken, &config.IssuedToken{
+		Issuer:       options.Issuer,
+		ClientID:     options.ClientID,
+		RefreshToken: token.RefreshToken,
+		Expiry:       token.Expiry,
+	}); err != nil {
+		return fmt.Errorf("failed to save the token: %w", err)
+	}
+
+	base.UI.Output("")
+	base.UI.Output(fmt.Sprintf("%v Turborepo CLI authorized with %v", ui.Rainbow(">>> Success!"), options.Issuer))
+	if token.RefreshToken == "" {
+		base.UI.Output(ui.Dim("The provider didn't issue a refresh token, so run `turbo login` again when the token expires."))
+	}
+	base.UI.Output("")
+	return nil
+}
+
+// ExecuteToken executes the `token` command.
+func ExecuteToken(helper *cmdutil.Helper, args *turbostate.ParsedArgsFromRust) error {
+	base, err := helper.GetCmdBase(args)
+	if err != nil {
+		return err
+	}
+	if err := token(base, args.Command.Token.Command); err != nil {
+		base.LogError("token failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// token prints the token that turbo sends to the remote cache, which is
+// refreshed first if it is about to expire, so that CI jobs and other tools can
+// use it
+func token(base *cmdutil.CmdBase, command string) error {
+	switch command {
+	case "Print":
+		if base.RemoteConfig.Token == "" {
+			return errors.New("no token found. Run `turbo login`, or set TURBO_TOKEN")
+		}
+		base.UI.Output(base.RemoteConfig.Token)
+		return nil
+	default:
+		return fmt.Errorf("unknown subcommand: %v", command)
+	}
+}
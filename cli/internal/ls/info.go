@@ -0,0 +1,222 @@
+package ls
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/graph"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// WorkspaceInfo describes a single workspace in the output of `turbo info`
+type WorkspaceInfo struct {
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	// Dependencies are the workspaces that this one depends on
+	Dependencies []string `json:"dependencies"`
+	// Dependents are the workspaces that depend on this one
+	Dependents []string `json:"dependents"`
+	// ExternalDependencies are the versions of the packages this workspace
+	// depends on from the registry, as written in its package.json
+	ExternalDependencies map[string]string `json:"externalDependencies"`
+	Tasks                []*TaskInfo       `json:"tasks"`
+}
+
+// TaskInfo describes a script of a workspace, and the task definition that
+// `turbo run` runs it with
+type TaskInfo struct {
+	Name    string `json:"name"`
+	Command string `json:"command"`
+	// ResolvedTaskDefinition is null if no pipeline defines the task, in
+	// which case `turbo run` doesn't run it
+	ResolvedTaskDefinition *fs.TaskDefinition `json:"resolvedTaskDefinition"`
+	// ConfiguredBy are the definitions the task's definition is merged from,
+	// in the order they apply
+	ConfiguredBy []core.TaskDefinitionSource `json:"configuredBy"`
+}
+
+type infoSummary struct {
+	Workspaces []*WorkspaceInfo `json:"workspaces"`
+}
+
+// taskResolver resolves the definition of a task in a workspace, see
+// core.Engine.ResolveTaskDefinition
+type taskResolver func(pkg string, taskName string) (*fs.TaskDefinition, []core.TaskDefinitionSource, error)
+
+// ExecuteInfo executes the `info` command.
+func ExecuteInfo(helper *cmdutil.Helper, args *turbostate.ParsedArgsFromRust) error {
+	base, err := helper.GetCmdBase(args)
+	if err != nil {
+		return err
+	}
+	if err := info(base, args.Command.Info); err != nil {
+		base.LogError("info failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+func info(base *cmdutil.CmdBase, opts *turbostate.InfoPayload) error {
+	names, pkgDepGraph, err := resolveWorkspaces(base, opts.Filter)
+	if err != nil {
+		return err
+	}
+	g := &graph.CompleteGraph{
+		WorkspaceGraph:  pkgDepGraph.WorkspaceGraph,
+		WorkspaceInfos:  pkgDepGraph.WorkspaceInfos,
+		RootNode:        pkgDepGraph.RootNode,
+		TaskDefinitions: map[string]*fs.TaskDefinition{},
+		RepoRoot:        base.RepoRoot,
+	}
+	engine := core.NewEngine(g, false)
+	summary, err := newInfoSummary(names, pkgDepGraph.WorkspaceInfos.PackageJSONs, engine.ResolveTaskDefinition)
+	if err != nil {
+		return err
+	}
+
+	if opts.JSON {
+		rendered, err := json.MarshalIndent(summary, "", "  ")
+		if err != nil {
+			return err
+		}
+		base.UI.Output(string(rendered))
+		return nil
+	}
+
+	for i, workspace := range summary.Workspaces {
+		if i > 0 {
+			base.UI.Output("")
+		}
+		base.UI.Output(util.Sprintf("${BOLD}%s${RESET} ${GREY}%s %s${RESET}", workspace.Name, workspace.Path, workspace.Version))
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, util.Sprintf("  ${GREY}Dependencies\t=\t%s\t${RESET}", strings.Join(workspace.Dependencies, ", ")))
+		fmt.Fprintln(w, util.Sprintf("  ${GREY}Dependents\t=\t%s\t${RESET}", strings.Join(workspace.Dependents, ", ")))
+		fmt.Fprintln(w, util.Sprintf("  ${GREY}External Dependencies\t=\t%s\t${RESET}", strings.Join(formatExternalDependencies(workspace.ExternalDependencies), ", ")))
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if len(workspace.Tasks) == 0 {
+			continue
+		}
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, util.Sprintf("  ${GREY}Task\tCommand\tConfigured By${RESET}"))
+		for _, task := range workspace.Tasks {
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", task.Name, task.Command, formatConfiguredBy(task))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newInfoSummary describes the named workspaces, sorted by name. The root
+// workspace is never included.
+func newInfoSummary(names []string, packageJSONs map[string]*fs.PackageJSON, resolve taskResolver) (*infoSummary, error) {
+	sort.Strings(names)
+	dependents := make(map[string][]string)
+	for name, pkg := range packageJSONs {
+		for _, dependency := range pkg.InternalDeps {
+			dependents[dependency] = append(dependents[dependency], name)
+		}
+	}
+	summary := &infoSummary{Workspaces: make([]*WorkspaceInfo, 0, len(names))}
+	for _, name := range names {
+		if name == util.RootPkgName {
+			continue
+		}
+		pkg, ok := packageJSONs[name]
+		if !ok {
+			continue
+		}
+		workspace, err := newWorkspaceInfo(pkg, dependents[name], resolve)
+		if err != nil {
+			return nil, err
+		}
+		summary.Workspaces = append(summary.Workspaces, workspace)
+	}
+	return summary, nil
+}
+
+func newWorkspaceInfo(pkg *fs.PackageJSON, dependents []string, resolve taskResolver) (*WorkspaceInfo, error) {
+	dependencies := append([]string{}, pkg.InternalDeps...)
+	sort.Strings(dependencies)
+	dependents = append([]string{}, dependents...)
+	sort.Strings(dependents)
+	externalDependencies := make(map[string]string, len(pkg.UnresolvedExternalDeps))
+	for name, version := range pkg.UnresolvedExternalDeps {
+		externalDependencies[name] = version
+	}
+
+	taskNames := make([]string, 0, len(pkg.Scripts))
+	for taskName := range pkg.Scripts {
+		taskNames = append(taskNames, taskName)
+	}
+	sort.Strings(taskNames)
+	tasks := make([]*TaskInfo, 0, len(taskNames))
+	for _, taskName := range taskNames {
+		definition, sources, err := resolve(pkg.Name, taskName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %v: %w", util.GetTaskId(pkg.Name, taskName), err)
+		}
+		if sources == nil {
+			sources = []core.TaskDefinitionSource{}
+		}
+		command := pkg.Scripts[taskName]
+		if definition != nil && definition.Command != "" {
+			command = definition.Command
+		}
+		tasks = append(tasks, &TaskInfo{
+			Name:                   taskName,
+			Command:                command,
+			ResolvedTaskDefinition: definition,
+			ConfiguredBy:           sources,
+		})
+	}
+
+	return &WorkspaceInfo{
+		Name:                 pkg.Name,
+		Path:                 pkg.Dir.ToUnixPath().ToString(),
+		Version:              pkg.Version,
+		Dependencies:         dependencies,
+		Dependents:           dependents,
+		ExternalDependencies: externalDependencies,
+		Tasks:                tasks,
+	}, nil
+}
+
+// formatExternalDependencies lists external dependencies as name@version, sorted
+func formatExternalDependencies(dependencies map[string]string) []string {
+	formatted := make([]string, 0, len(dependencies))
+	for name, version := range dependencies {
+		formatted = append(formatted, name+"@"+version)
+	}
+	sort.Strings(formatted)
+	return formatted
+}
+
+// formatConfiguredBy lists the files that define a task, with the keys that
+// each of them sets
+func formatConfiguredBy(task *TaskInfo) string {
+	if task.ResolvedTaskDefinition == nil {
+		return util.Sprintf("${GREY}not in any pipeline${RESET}")
+	}
+	sources := make([]string, len(task.ConfiguredBy))
+	for i, source := range task.ConfiguredBy {
+		keys := strings.Join(source.Keys, ", ")
+		if keys == "" {
+			keys = "defaults"
+		}
+		sources[i] = fmt.Sprintf("%s %q (%s)", source.File, source.Key, keys)
+	}
+	return strings.Join(sources, "; ")
+}
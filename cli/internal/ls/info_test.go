@@ -0,0 +1,77 @@
+package ls
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+)
+
+func TestNewInfoSummary(t *testing.T) {
+	packageJSONs := map[string]*fs.PackageJSON{
+		util.RootPkgName: {Name: "monorepo"},
+		"web": {
+			Name:                   "web",
+			Version:                "1.0.0",
+			Dir:                    turbopath.AnchoredUnixPath("apps/web").ToSystemPath(),
+			Scripts:                map[string]string{"lint": "eslint", "build": "next build"},
+			InternalDeps:           []string{"ui"},
+			UnresolvedExternalDeps: map[string]string{"next": "13.0.0"},
+		},
+		"ui": {
+			Name:    "ui",
+			Version: "0.0.0",
+			Dir:     turbopath.AnchoredUnixPath("packages/ui").ToSystemPath(),
+		},
+	}
+	build := &fs.TaskDefinition{Outputs: fs.TaskOutputs{Inclusions: []string{".next/**"}}}
+	buildSources := []core.TaskDefinitionSource{{File: "turbo.json", Key: "build", Keys: []string{"outputs"}}}
+	resolve := func(pkg string, taskName string) (*fs.TaskDefinition, []core.TaskDefinitionSource, error) {
+		if pkg == "web" && taskName == "build" {
+			return build, buildSources, nil
+		}
+		return nil, nil, nil
+	}
+
+	summary, err := newInfoSummary([]string{"web", util.RootPkgName, "ui"}, packageJSONs, resolve)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, summary, &infoSummary{Workspaces: []*WorkspaceInfo{
+		{
+			Name:                 "ui",
+			Path:                 "packages/ui",
+			Version:              "0.0.0",
+			Dependencies:         []string{},
+			Dependents:           []string{"web"},
+			ExternalDependencies: map[string]string{},
+			Tasks:                []*TaskInfo{},
+		},
+		{
+			Name:                 "web",
+			Path:                 "apps/web",
+			Version:              "1.0.0",
+			Dependencies:         []string{"ui"},
+			Dependents:           []string{},
+			ExternalDependencies: map[string]string{"next": "13.0.0"},
+			Tasks: []*TaskInfo{
+				{Name: "build", Command: "next build", ResolvedTaskDefinition: build, ConfiguredBy: buildSources},
+				{Name: "lint", Command: "eslint", ConfiguredBy: []core.TaskDefinitionSource{}},
+			},
+		},
+	}})
+	assert.Equal(t, formatConfiguredBy(summary.Workspaces[1].Tasks[0]), `turbo.json "build" (outputs)`)
+}
+
+func TestNewInfoSummaryResolveError(t *testing.T) {
+	packageJSONs := map[string]*fs.PackageJSON{
+		"web": {Name: "web", Scripts: map[string]string{"build": "next build"}},
+	}
+	resolve := func(pkg string, taskName string) (*fs.TaskDefinition, []core.TaskDefinitionSource, error) {
+		return nil, nil, errors.New("cycle")
+	}
+	_, err := newInfoSummary([]string{"web"}, packageJSONs, resolve)
+	assert.ErrorContains(t, err, "failed to resolve web#build: cycle")
+}
@@ -0,0 +1,164 @@
+// Package ls implements `turbo ls`, which lists the workspaces in a monorepo,
+// and `turbo info`, which describes them in detail
+package ls
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/context"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/scm"
+	"github.com/vercel/turbo/cli/internal/scope"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// WorkspaceSummary describes a single workspace in the output of `turbo ls`
+type WorkspaceSummary struct {
+	Name         string                 `json:"name"`
+	Path         string                 `json:"path"`
+	Version      string                 `json:"version"`
+	Tasks        []string               `json:"tasks"`
+	Dependencies DependencyCountSummary `json:"dependencies"`
+}
+
+// DependencyCountSummary counts the dependencies of a workspace
+type DependencyCountSummary struct {
+	Internal int `json:"internal"`
+	External int `json:"external"`
+}
+
+type lsSummary struct {
+	Count      int                 `json:"count"`
+	Workspaces []*WorkspaceSummary `json:"workspaces"`
+}
+
+// ExecuteLs executes the `ls` command.
+func ExecuteLs(helper *cmdutil.Helper, args *turbostate.ParsedArgsFromRust) error {
+	base, err := helper.GetCmdBase(args)
+	if err != nil {
+		return err
+	}
+	if err := ls(base, args.Command.Ls); err != nil {
+		base.LogError("ls failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+func ls(base *cmdutil.CmdBase, opts *turbostate.LsPayload) error {
+	names, pkgDepGraph, err := resolveWorkspaces(base, opts.Filter)
+	if err != nil {
+		return err
+	}
+	summary := newLsSummary(names, pkgDepGraph.WorkspaceInfos.PackageJSONs)
+
+	if opts.JSON {
+		rendered, err := json.MarshalIndent(summary, "", "  ")
+		if err != nil {
+			return err
+		}
+		base.UI.Output(string(rendered))
+		return nil
+	}
+
+	base.UI.Output(util.Sprintf("${BOLD}%v workspaces${RESET}", summary.Count))
+	base.UI.Output("")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, util.Sprintf("  ${GREY}Name\tPath\tVersion\tDependencies\tTasks${RESET}"))
+	for _, workspace := range summary.Workspaces {
+		fmt.Fprintf(
+			w,
+			"  %s\t%s\t%s\t%d internal, %d external\t%s\n",
+			workspace.Name,
+			workspace.Path,
+			workspace.Version,
+			workspace.Dependencies.Internal,
+			workspace.Dependencies.External,
+			strings.Join(workspace.Tasks, ", "),
+		)
+	}
+	return w.Flush()
+}
+
+// resolveWorkspaces returns the names of the workspaces that match the filter,
+// which are all of them without one, and the package graph they are from
+func resolveWorkspaces(base *cmdutil.CmdBase, filter []string) ([]string, *context.Context, error) {
+	rootPackageJSON, err := fs.ReadPackageJSON(base.RepoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read package.json: %w", err)
+	}
+
+	pkgDepGraph, err := context.BuildPackageGraph(base.RepoRoot, rootPackageJSON)
+	if err != nil {
+		var warnings *context.Warnings
+		if !errors.As(err, &warnings) {
+			return nil, nil, err
+		}
+		base.LogWarning("Issues occurred when constructing package graph. Turbo will function, but some features may not be available", err)
+	}
+
+	scmInstance, err := scm.FromInRepo(base.RepoRoot)
+	if err != nil {
+		if errors.Is(err, scm.ErrFallback) {
+			base.Logger.Debug("falling back to manual file hashing", "reason", err)
+		} else {
+			return nil, nil, errors.Wrap(err, "failed to create SCM")
+		}
+	}
+	scopeOpts := &scope.Opts{
+		FilterPatterns: filter,
+	}
+	filteredPkgs, _, err := scope.ResolvePackages(scopeOpts, base.RepoRoot, scmInstance, pkgDepGraph, base.UI, base.Logger)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to resolve packages")
+	}
+	return filteredPkgs.UnsafeListOfStrings(), pkgDepGraph, nil
+}
+
+// newLsSummary summarizes the named workspaces, sorted by name. The root
+// workspace is never included.
+func newLsSummary(names []string, packageJSONs map[string]*fs.PackageJSON) *lsSummary {
+	sort.Strings(names)
+	summary := &lsSummary{
+		Workspaces: make([]*WorkspaceSummary, 0, len(names)),
+	}
+	for _, name := range names {
+		if name == util.RootPkgName {
+			continue
+		}
+		pkg, ok := packageJSONs[name]
+		if !ok {
+			continue
+		}
+		summary.Workspaces = append(summary.Workspaces, newWorkspaceSummary(pkg))
+	}
+	summary.Count = len(summary.Workspaces)
+	return summary
+}
+
+func newWorkspaceSummary(pkg *fs.PackageJSON) *WorkspaceSummary {
+	tasks := make([]string, 0, len(pkg.Scripts))
+	for task := range pkg.Scripts {
+		tasks = append(tasks, task)
+	}
+	sort.Strings(tasks)
+
+	return &WorkspaceSummary{
+		Name:    pkg.Name,
+		Path:    pkg.Dir.ToUnixPath().ToString(),
+		Version: pkg.Version,
+		Tasks:   tasks,
+		Dependencies: DependencyCountSummary{
+			Internal: len(pkg.InternalDeps),
+			External: len(pkg.UnresolvedExternalDeps),
+		},
+	}
+}
@@ -0,0 +1,76 @@
+package ls
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+)
+
+func TestNewWorkspaceSummary(t *testing.T) {
+	summary := newWorkspaceSummary(&fs.PackageJSON{
+		Name:                   "web",
+		Version:                "1.0.0",
+		Dir:                    turbopath.AnchoredUnixPath("apps/web").ToSystemPath(),
+		Scripts:                map[string]string{"lint": "eslint", "build": "next build"},
+		InternalDeps:           []string{"ui"},
+		UnresolvedExternalDeps: map[string]string{"next": "13.0.0", "react": "18.0.0"},
+	})
+	assert.DeepEqual(t, summary, &WorkspaceSummary{
+		Name:    "web",
+		Path:    "apps/web",
+		Version: "1.0.0",
+		Tasks:   []string{"build", "lint"},
+		Dependencies: DependencyCountSummary{
+			Internal: 1,
+			External: 2,
+		},
+	})
+}
+
+func TestLsSummaryJSON(t *testing.T) {
+	packageJSONs := map[string]*fs.PackageJSON{
+		util.RootPkgName: {Name: "monorepo"},
+		"web": {
+			Name:         "web",
+			Version:      "1.0.0",
+			Dir:          turbopath.AnchoredUnixPath("apps/web").ToSystemPath(),
+			Scripts:      map[string]string{"build": "next build"},
+			InternalDeps: []string{"ui"},
+		},
+		"ui": {
+			Name:    "ui",
+			Version: "0.0.0",
+			Dir:     turbopath.AnchoredUnixPath("packages/ui").ToSystemPath(),
+		},
+	}
+	summary := newLsSummary([]string{"web", util.RootPkgName, "ui", "unknown"}, packageJSONs)
+	rendered, err := json.Marshal(summary)
+	assert.NilError(t, err)
+
+	// The field names are the contract that scripts rely on
+	var parsed map[string]interface{}
+	assert.NilError(t, json.Unmarshal(rendered, &parsed))
+	assert.DeepEqual(t, parsed, map[string]interface{}{
+		"count": float64(2),
+		"workspaces": []interface{}{
+			map[string]interface{}{
+				"name":         "ui",
+				"path":         "packages/ui",
+				"version":      "0.0.0",
+				"tasks":        []interface{}{},
+				"dependencies": map[string]interface{}{"internal": float64(0), "external": float64(0)},
+			},
+			map[string]interface{}{
+				"name":         "web",
+				"path":         "apps/web",
+				"version":      "1.0.0",
+				"tasks":        []interface{}{"build"},
+				"dependencies": map[string]interface{}{"internal": float64(1), "external": float64(0)},
+			},
+		},
+	})
+}
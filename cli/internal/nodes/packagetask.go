@@ -3,8 +3,14 @@ package nodes
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
+	"github.com/vercel/turbo/cli/internal/env"
 	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
 )
 
 // PackageTask represents running a particular task in a particular package
@@ -20,20 +26,18 @@ type PackageTask struct {
 	ExcludedOutputs []string
 	LogFile         string
 	Hash            string
-}
-
-// OutputPrefix returns the prefix to be used for logging and ui for this task
-func (pt *PackageTask) OutputPrefix(isSinglePackage bool) string {
-	if isSinglePackage {
-		return pt.Task
-	}
-	return fmt.Sprintf("%v:%v", pt.PackageName, pt.Task)
+	// FallbackHash is the hash of the task with the fallback hash version, if
+	// one is configured
+	FallbackHash string
 }
 
 // HashableOutputs returns the package-relative globs for files to be considered outputs
-// of this task
+// of this task. The task's log file is included unless its logs are not cached.
 func (pt *PackageTask) HashableOutputs() fs.TaskOutputs {
-	inclusionOutputs := []string{fmt.Sprintf(".turbo/turbo-%v.log", pt.Task)}
+	inclusionOutputs := []string{}
+	if !pt.TaskDefinition.ExcludeLogs {
+		inclusionOutputs = append(inclusionOutputs, pt.LogGlob())
+	}
 	inclusionOutputs = append(inclusionOutputs, pt.TaskDefinition.Outputs.Inclusions...)
 
 	return fs.TaskOutputs{
@@ -41,3 +45,119 @@ func (pt *PackageTask) HashableOutputs() fs.TaskOutputs {
 		Exclusions: pt.TaskDefinition.Outputs.Exclusions,
 	}
 }
+
+// LogGlob returns the package-relative path of the log file that is cached with
+// this task's outputs
+func (pt *PackageTask) LogGlob() string {
+	return fmt.Sprintf(".turbo/turbo-%v.log", pt.Task)
+}
+
+// RepoRelativeOutputs returns the HashableOutputs of this task as globs relative
+// to the repository root
+func (pt *PackageTask) RepoRelativeOutputs() fs.TaskOutputs {
+	hashableOutputs := pt.HashableOutputs()
+	repoRelativeGlobs := fs.TaskOutputs{
+		Inclusions: make([]string, len(hashableOutputs.Inclusions)),
+		Exclusions: make([]string, len(hashableOutputs.Exclusions)),
+	}
+	for index, output := range hashableOutputs.Inclusions {
+		repoRelativeGlobs.Inclusions[index] = filepath.Join(pt.Pkg.Dir.ToStringDuringMigration(), output)
+	}
+	for index, output := range hashableOutputs.Exclusions {
+		repoRelativeGlobs.Exclusions[index] = filepath.Join(pt.Pkg.Dir.ToStringDuringMigration(), output)
+	}
+	return repoRelativeGlobs
+}
+
+// WorkingDir returns the directory that this task's command runs in, anchored
+// at the repository root: its package, or the "cwd" of its definition, which
+// is relative to the package. A "cwd" outside of the repository is an error.
+func (pt *PackageTask) WorkingDir() (turbopath.AnchoredSystemPath, error) {
+	if pt.TaskDefinition.Cwd == "" {
+		return pt.Pkg.Dir, nil
+	}
+	dir := filepath.Join(pt.Pkg.Dir.ToString(), filepath.FromSlash(pt.TaskDefinition.Cwd))
+	if dir == ".." || strings.HasPrefix(dir, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("the \"cwd\" %q of %v is outside of the repository", pt.TaskDefinition.Cwd, pt.TaskID)
+	}
+	return turbopath.AnchoredSystemPathFromUpstream(dir), nil
+}
+
+// DotEnv returns the variables defined in this task's "dotEnv" files, loaded
+// from the repository root and then from the task's package, so that package
+// files take precedence. See env.LoadDotEnv for the precedence within a directory.
+func (pt *PackageTask) DotEnv(repoRoot turbopath.AbsoluteSystemPath) (env.EnvironmentVariableMap, error) {
+	if len(pt.TaskDefinition.DotEnv) == 0 {
+		return env.EnvironmentVariableMap{}, nil
+	}
+	dirs := []turbopath.AbsoluteSystemPath{repoRoot, pt.Pkg.Dir.RestoreAnchor(repoRoot)}
+	return env.LoadDotEnv(dirs, pt.TaskDefinition.DotEnv)
+}
+
+// Env returns the KEY=value pairs to add to turbo's environment for this task's
+// command, sorted by key. They are the variables from DotEnv that are not
+// already set in turbo's environment, followed by the "setEnv" variables, which
+// always take precedence.
+//
+// These placeholders in "setEnv" values are replaced, and other text is left as is:
+//
+//	${TURBO_HASH}        the hash of this task
+//	${TURBO_TASK}        the name of this task
+//	${TURBO_PACKAGE}     the name of this task's package
+//	${TURBO_REPO_ROOT}   the absolute path of the repository root
+//	${TURBO_PACKAGE_DIR} the absolute path of this task's package
+//
+// Hash must be set before calling Env.
+func (pt *PackageTask) Env(repoRoot turbopath.AbsoluteSystemPath) ([]string, error) {
+	return pt.env(repoRoot, func(key string) bool {
+		_, ok := os.LookupEnv(key)
+		return ok
+	})
+}
+
+// EnvFor is Env for a command that inherits only environment, rather than all
+// of turbo's environment, as in strict env mode
+func (pt *PackageTask) EnvFor(repoRoot turbopath.AbsoluteSystemPath, environment env.EnvironmentVariableMap) ([]string, error) {
+	return pt.env(repoRoot, func(key string) bool {
+		_, ok := environment[key]
+		return ok
+	})
+}
+
+// env is Env for a command whose inherited environment has the variables for
+// which isSet is true
+func (pt *PackageTask) env(repoRoot turbopath.AbsoluteSystemPath, isSet func(key string) bool) ([]string, error) {
+	dotEnv, err := pt.DotEnv(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	setEnv := pt.TaskDefinition.SetEnv
+	var pairs []string
+	for _, key := range dotEnv.Names() {
+		inEnvironment := isSet(key)
+		_, inSetEnv := setEnv[key]
+		if !inEnvironment && !inSetEnv {
+			pairs = append(pairs, fmt.Sprintf("%v=%v", key, dotEnv[key]))
+		}
+	}
+
+	if len(setEnv) == 0 {
+		return pairs, nil
+	}
+	replacer := strings.NewReplacer(
+		"${TURBO_HASH}", pt.Hash,
+		"${TURBO_TASK}", pt.Task,
+		"${TURBO_PACKAGE}", pt.PackageName,
+		"${TURBO_REPO_ROOT}", repoRoot.ToString(),
+		"${TURBO_PACKAGE_DIR}", pt.Pkg.Dir.RestoreAnchor(repoRoot).ToString(),
+	)
+	keys := make([]string, 0, len(setEnv))
+	for key := range setEnv {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf("%v=%v", key, replacer.Replace(setEnv[key])))
+	}
+	return pairs, nil
+}
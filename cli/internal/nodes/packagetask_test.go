@@ -0,0 +1,106 @@
+package nodes
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func TestEnv(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	pt := &PackageTask{
+		Task:        "build",
+		PackageName: "web",
+		Hash:        "abc123",
+		Pkg:         &fs.PackageJSON{Dir: turbopath.AnchoredSystemPath(filepath.Join("apps", "web"))},
+		TaskDefinition: &fs.TaskDefinition{SetEnv: map[string]string{
+			"NODE_OPTIONS": "--max-old-space-size=4096",
+			"BUILD_ID":     "${TURBO_PACKAGE}-${TURBO_TASK}-${TURBO_HASH}",
+			"JEST_CONFIG":  "${TURBO_REPO_ROOT}/jest.config.js",
+			"OUT_DIR":      "${TURBO_PACKAGE_DIR}",
+			"LITERAL":      "$HOME ${UNKNOWN}",
+		}},
+	}
+	env, err := pt.Env(repoRoot)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, env, []string{
+		"BUILD_ID=web-build-abc123",
+		"JEST_CONFIG=" + repoRoot.ToString() + "/jest.config.js",
+		"LITERAL=$HOME ${UNKNOWN}",
+		"NODE_OPTIONS=--max-old-space-size=4096",
+		"OUT_DIR=" + repoRoot.UntypedJoin("apps", "web").ToString(),
+	})
+
+	pt.TaskDefinition = &fs.TaskDefinition{}
+	env, err = pt.Env(repoRoot)
+	assert.NilError(t, err)
+	assert.Assert(t, env == nil)
+}
+
+func TestEnvWithDotEnv(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	pkgDir := repoRoot.UntypedJoin("apps", "web")
+	assert.NilError(t, pkgDir.MkdirAll(0755))
+	assert.NilError(t, repoRoot.UntypedJoin(".env").WriteFile([]byte("API_URL=http://root\nFROM_PROCESS=file\nMODE=root"), 0644))
+	assert.NilError(t, pkgDir.UntypedJoin(".env").WriteFile([]byte("API_URL=http://web"), 0644))
+	t.Setenv("FROM_PROCESS", "process")
+
+	pt := &PackageTask{
+		Pkg: &fs.PackageJSON{Dir: turbopath.AnchoredSystemPath(filepath.Join("apps", "web"))},
+		TaskDefinition: &fs.TaskDefinition{
+			DotEnv: []string{".env"},
+			SetEnv: map[string]string{"MODE": "set"},
+		},
+	}
+	env, err := pt.Env(repoRoot)
+	assert.NilError(t, err)
+	// Package files override root files, turbo's environment is never
+	// overridden, and setEnv takes precedence over files
+	assert.DeepEqual(t, env, []string{"API_URL=http://web", "MODE=set"})
+
+	dotEnv, err := pt.DotEnv(repoRoot)
+	assert.NilError(t, err)
+	assert.Equal(t, dotEnv["FROM_PROCESS"], "file", "DotEnv returns the file values that are hashed")
+}
+
+func TestEnvFor(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	assert.NilError(t, repoRoot.UntypedJoin(".env").WriteFile([]byte("FROM_PROCESS=file\nSCRUBBED=file"), 0644))
+	t.Setenv("FROM_PROCESS", "process")
+	t.Setenv("SCRUBBED", "process")
+
+	pt := &PackageTask{
+		Pkg: &fs.PackageJSON{Dir: turbopath.AnchoredSystemPath("")},
+		TaskDefinition: &fs.TaskDefinition{
+			DotEnv: []string{".env"},
+			SetEnv: map[string]string{"MODE": "set"},
+		},
+	}
+	env, err := pt.EnvFor(repoRoot, map[string]string{"FROM_PROCESS": "process"})
+	assert.NilError(t, err)
+	// A variable that isn't inherited takes its value from the files
+	assert.DeepEqual(t, env, []string{"SCRUBBED=file", "MODE=set"})
+}
+
+func TestWorkingDir(t *testing.T) {
+	pt := &PackageTask{
+		TaskID:         "web#codegen",
+		Pkg:            &fs.PackageJSON{Dir: turbopath.AnchoredSystemPath(filepath.Join("apps", "web"))},
+		TaskDefinition: &fs.TaskDefinition{},
+	}
+	dir, err := pt.WorkingDir()
+	assert.NilError(t, err)
+	assert.Equal(t, dir, turbopath.AnchoredSystemPath(filepath.Join("apps", "web")))
+
+	pt.TaskDefinition.Cwd = "../../proto/gen"
+	dir, err = pt.WorkingDir()
+	assert.NilError(t, err)
+	assert.Equal(t, dir, turbopath.AnchoredSystemPath(filepath.Join("proto", "gen")))
+
+	pt.TaskDefinition.Cwd = "../../.."
+	_, err = pt.WorkingDir()
+	assert.ErrorContains(t, err, `the "cwd" "../../.." of web#codegen is outside of the repository`)
+}
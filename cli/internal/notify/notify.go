@@ -0,0 +1,191 @@
+// Package notify sends the outcome of a run to the webhooks configured in the
+// "notifications" key of turbo.json.
+//
+// Messages are formatted for Slack or Microsoft Teams incoming webhooks. They
+// contain a summary of the run, the failed tasks, and the end of each failed
+// task's log, so that CI does not need its own notification scripts.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/runsummary"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+const (
+	// FormatSlack formats messages for Slack incoming webhooks
+	FormatSlack = "slack"
+	// FormatTeams formats messages for Microsoft Teams incoming webhooks
+	FormatTeams = "teams"
+)
+
+const (
+	_excerptLines = 20
+	// Slack truncates long messages, so excerpts are kept well under its limits
+	_excerptBytes = 1500
+	_timeout      = 10 * time.Second
+)
+
+// Run describes the outcome of a run
+type Run struct {
+	Targets   []string
+	Attempted int
+	Succeeded int
+	Cached    int
+	Duration  time.Duration
+	// Tasks are the summaries of every task in the run, including their execution
+	Tasks []*runsummary.TaskSummary
+}
+
+// Failed returns the summaries of the tasks that failed
+func (r *Run) Failed() []*runsummary.TaskSummary {
+	failed := []*runsummary.TaskSummary{}
+	for _, task := range r.Tasks {
+		if task.Execution != nil && (task.Execution.Status == runsummary.TaskStatusFailed || task.Execution.Status == runsummary.TaskStatusTimedOut) {
+			failed = append(failed, task)
+		}
+	}
+	return failed
+}
+
+// Notifier sends run outcomes to the configured webhooks
+type Notifier struct {
+	notifications []fs.Notification
+	repoRoot      turbopath.AbsoluteSystemPath
+	client        *http.Client
+	logger        hclog.Logger
+}
+
+// New returns a Notifier for the given notifications configuration
+func New(notifications []fs.Notification, repoRoot turbopath.AbsoluteSystemPath, logger hclog.Logger) *Notifier {
+	return &Notifier{
+		notifications: notifications,
+		repoRoot:      repoRoot,
+		client:        &http.Client{Timeout: _timeout},
+		logger:        logger.Named("notify"),
+	}
+}
+
+// Send posts the outcome of the run to each configured webhook. Every webhook
+// is attempted; the returned error describes all of the ones that failed.
+func (n *Notifier) Send(ctx context.Context, run *Run) error {
+	failed := run.Failed()
+	var errs []string
+	for _, notification := range n.notifications {
+		if notification.OnlyFailures && len(failed) == 0 {
+			continue
+		}
+		url := os.ExpandEnv(notification.URL)
+		if url == "" {
+			n.logger.Debug("skipping notification with empty url", "url", notification.URL)
+			continue
+		}
+		if err := n.post(ctx, url, notification.Format, run, failed); err != nil {
+			// The URL is a secret, so it isn't included in the error
+			errs = append(errs, fmt.Sprintf("%v notification failed: %v", notification.Format, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%v", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (n *Notifier) post(ctx context.Context, url string, format string, run *Run, failed []*runsummary.TaskSummary) error {
+	var message interface{}
+	switch format {
+	case FormatSlack:
+		message = slackMessage(title(run, failed), n.body(run, failed))
+	case FormatTeams:
+		message = teamsMessage(title(run, failed), n.body(run, failed), len(failed) > 0)
+	default:
+		return fmt.Errorf("unknown format %q, expected %q or %q", format, FormatSlack, FormatTeams)
+	}
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("invalid url")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed")
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with %v", resp.Status)
+	}
+	return nil
+}
+
+func title(run *Run, failed []*runsummary.TaskSummary) string {
+	targets := strings.Join(run.Targets, ", ")
+	if len(failed) > 0 {
+		return fmt.Sprintf("turbo run %v failed: %v of %v tasks failed", targets, len(failed), run.Attempted)
+	}
+	return fmt.Sprintf("turbo run %v succeeded", targets)
+}
+
+// body is markdown, which both Slack and Teams render
+func (n *Notifier) body(run *Run, failed []*runsummary.TaskSummary) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Tasks: %v successful, %v total. Cached: %v. Time: %v\n", run.Succeeded, run.Attempted, run.Cached, run.Duration.Truncate(time.Millisecond))
+	for _, task := range failed {
+		fmt.Fprintf(&sb, "\n*%v* failed", task.TaskID)
+		if task.Execution.ExitCode != nil {
+			fmt.Fprintf(&sb, " with exit code %d", *task.Execution.ExitCode)
+		}
+		sb.WriteString("\n")
+		if excerpt := n.excerpt(task.LogFile); excerpt != "" {
+			fmt.Fprintf(&sb, "```\n%v\n```\n", excerpt)
+		}
+	}
+	return sb.String()
+}
+
+// excerpt returns the end of a task's log file, or "" if it can't be read
+func (n *Notifier) excerpt(logFile string) string {
+	if logFile == "" {
+		return ""
+	}
+	excerpt, err := runsummary.LogExcerpt(n.repoRoot, logFile, _excerptLines, _excerptBytes)
+	if err != nil {
+		n.logger.Debug("failed to read log file", "file", logFile, "error", err)
+		return ""
+	}
+	return excerpt
+}
+
+func slackMessage(title string, body string) interface{} {
+	return map[string]string{
+		"text": fmt.Sprintf("*%v*\n%v", title, body),
+	}
+}
+
+func teamsMessage(title string, body string, failed bool) interface{} {
+	themeColor := "2EB67D"
+	if failed {
+		themeColor = "E01E5A"
+	}
+	return map[string]string{
+		"@type":      "MessageCard",
+		"@context":   "https://schema.org/extensions",
+		"summary":    title,
+		"themeColor": themeColor,
+		"title":      title,
+		"text":       body,
+	}
+}
@@ -0,0 +1,92 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/runsummary"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestSend(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	logFile := repoRoot.UntypedJoin("apps", "web", ".turbo", "turbo-build.log")
+	assert.NilError(t, logFile.EnsureDir())
+	log := []string{}
+	for i := 0; i < 30; i++ {
+		log = append(log, fmt.Sprintf("line %d", i))
+	}
+	log = append(log, "\x1b[31mError: build failed\x1b[0m")
+	assert.NilError(t, logFile.WriteFile([]byte(strings.Join(log, "\n")+"\n"), 0644))
+
+	received := map[string]map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		message := map[string]string{}
+		assert.NilError(t, json.NewDecoder(r.Body).Decode(&message))
+		received[r.URL.Path] = message
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("TEST_WEBHOOK_URL", server.URL+"/from-env")
+
+	exitCode := 1
+	failedRun := &Run{
+		Targets:   []string{"build"},
+		Attempted: 2,
+		Succeeded: 1,
+		Cached:    1,
+		Duration:  2 * time.Second,
+		Tasks: []*runsummary.TaskSummary{
+			{TaskID: "docs#build", Execution: &runsummary.TaskExecutionSummary{Status: runsummary.TaskStatusCached}},
+			{TaskID: "web#build", LogFile: "apps/web/.turbo/turbo-build.log", Execution: &runsummary.TaskExecutionSummary{Status: runsummary.TaskStatusFailed, ExitCode: &exitCode}},
+		},
+	}
+	notifier := New([]fs.Notification{
+		{URL: server.URL + "/slack", Format: FormatSlack},
+		{URL: server.URL + "/teams", Format: FormatTeams, OnlyFailures: true},
+		{URL: "$TEST_WEBHOOK_URL", Format: FormatSlack},
+		{URL: "$UNSET_WEBHOOK_URL", Format: FormatSlack},
+	}, repoRoot, hclog.NewNullLogger())
+	assert.NilError(t, notifier.Send(context.Background(), failedRun))
+
+	slack := received["/slack"]["text"]
+	assert.Assert(t, cmp.Contains(slack, "*turbo run build failed: 1 of 2 tasks failed*"))
+	assert.Assert(t, cmp.Contains(slack, "*web#build* failed with exit code 1"))
+	assert.Assert(t, cmp.Contains(slack, "line 29\nError: build failed\n```"))
+	assert.Assert(t, !strings.Contains(slack, "line 10\n"), "only the end of the log is included")
+	assert.Assert(t, !strings.Contains(slack, "docs#build"))
+	assert.Equal(t, received["/teams"]["@type"], "MessageCard")
+	assert.Equal(t, received["/teams"]["title"], "turbo run build failed: 1 of 2 tasks failed")
+	assert.Equal(t, received["/from-env"]["text"], slack)
+	assert.Equal(t, len(received), 3)
+
+	received = map[string]map[string]string{}
+	successfulRun := &Run{Targets: []string{"build"}, Attempted: 1, Succeeded: 1, Tasks: failedRun.Tasks[:1]}
+	assert.NilError(t, notifier.Send(context.Background(), successfulRun))
+	assert.Assert(t, cmp.Contains(received["/slack"]["text"], "turbo run build succeeded"))
+	_, ok := received["/teams"]
+	assert.Assert(t, !ok, "onlyFailures notifications are not sent for successful runs")
+
+	notifier = New([]fs.Notification{
+		{URL: server.URL + "/broken", Format: FormatSlack},
+		{URL: server.URL + "/discord", Format: "discord"},
+		{URL: server.URL + "/teams", Format: FormatTeams},
+	}, repoRoot, hclog.NewNullLogger())
+	err := notifier.Send(context.Background(), failedRun)
+	assert.ErrorContains(t, err, "slack notification failed: webhook responded with 404")
+	assert.ErrorContains(t, err, "unknown format \"discord\"")
+	assert.Assert(t, !strings.Contains(err.Error(), server.URL), "webhook urls are secret")
+	_, ok = received["/teams"]
+	assert.Assert(t, ok, "a failing webhook does not stop the others")
+}
@@ -0,0 +1,338 @@
+// Package otlp exports the tasks of a run as OpenTelemetry spans, so that turbo
+// runs show up in the same traces as the rest of a build.
+//
+// Spans are sent with the OTLP/HTTP JSON protocol, which every OpenTelemetry
+// collector accepts, so no OpenTelemetry SDK is needed. The run is one span,
+// with a child span for each task that finished.
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/runsummary"
+)
+
+// The standard OpenTelemetry environment variables that configure the exporter
+const (
+	EndpointEnvVar       = "OTEL_EXPORTER_OTLP_ENDPOINT"
+	TracesEndpointEnvVar = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"
+	HeadersEnvVar        = "OTEL_EXPORTER_OTLP_HEADERS"
+	ServiceNameEnvVar    = "OTEL_SERVICE_NAME"
+	// TraceparentEnvVar holds a W3C trace context to attach the run to, as
+	// set by CI systems that trace their jobs
+	TraceparentEnvVar = "TRACEPARENT"
+)
+
+const (
+	_timeout = 10 * time.Second
+	// Span kinds and status codes from the OTLP protocol
+	_spanKindInternal = 1
+	_statusCodeOk     = 1
+	_statusCodeError  = 2
+)
+
+// Run describes a finished run and its tasks
+type Run struct {
+	TurboVersion string
+	Targets      []string
+	StartedAt    time.Time
+	Duration     time.Duration
+	ExitCode     int
+	// Tasks are the summaries of every task in the run. Tasks without an
+	// execution didn't finish, and aren't exported.
+	Tasks []*runsummary.TaskSummary
+}
+
+// Exporter sends runs to an OTLP/HTTP endpoint
+type Exporter struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+// TracesURL returns the URL that traces are sent to. endpoint is the base URL
+// of the collector, as in OTEL_EXPORTER_OTLP_ENDPOINT, which is used if
+// endpoint is empty. OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is used as-is if it is
+// set and endpoint is empty. It returns the empty string if nothing is configured.
+func TracesURL(endpoint string) string {
+	if endpoint == "" {
+		if tracesEndpoint := os.Getenv(TracesEndpointEnvVar); tracesEndpoint != "" {
+			return tracesEndpoint
+		}
+		endpoint = os.Getenv(EndpointEnvVar)
+	}
+	if endpoint == "" {
+		return ""
+	}
+	return strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+}
+
+// New returns an Exporter that sends spans to the given URL, with the headers
+// from OTEL_EXPORTER_OTLP_HEADERS
+func New(url string) (*Exporter, error) {
+	headers, err := parseHeaders(os.Getenv(HeadersEnvVar))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %v: %w", HeadersEnvVar, err)
+	}
+	return &Exporter{
+		url:     url,
+		headers: headers,
+		client:  &http.Client{Timeout: _timeout},
+	}, nil
+}
+
+// parseHeaders parses headers in the "key1=value1,key2=value2" format of
+// OTEL_EXPORTER_OTLP_HEADERS
+func parseHeaders(value string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, headerValue, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q is not of the form key=value", pair)
+		}
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(headerValue)
+	}
+	return headers, nil
+}
+
+// Export sends the spans of the run
+func (e *Exporter) Export(ctx context.Context, run *Run) error {
+	request, err := newTraceRequest(run, os.Getenv(TraceparentEnvVar), serviceName())
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to export spans to %v: %w", e.url, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to export spans to %v: %v %v", e.url, resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+func serviceName() string {
+	if name := os.Getenv(ServiceNameEnvVar); name != "" {
+		return name
+	}
+	return "turbo"
+}
+
+// The types below are the parts of the OTLP JSON encoding that turbo uses.
+// IDs are hex encoded and times are nanoseconds since the unix epoch, as strings.
+
+type traceRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type resource struct {
+	Attributes []attribute `json:"attributes"`
+}
+
+type scopeSpans struct {
+	Scope scope  `json:"scope"`
+	Spans []span `json:"spans"`
+}
+
+type scope struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type span struct {
+	TraceID           string      `json:"traceId"`
+	SpanID            string      `json:"spanId"`
+	ParentSpanID      string      `json:"parentSpanId,omitempty"`
+	Name              string      `json:"name"`
+	Kind              int         `json:"kind"`
+	StartTimeUnixNano string      `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+	Attributes        []attribute `json:"attributes"`
+	Status            status      `json:"status"`
+}
+
+type status struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type attribute struct {
+	Key   string         `json:"key"`
+	Value attributeValue `json:"value"`
+}
+
+type attributeValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	// IntValue is a string since it is an int64 in the protocol
+	IntValue *string `json:"intValue,omitempty"`
+}
+
+func stringAttribute(key string, value string) attribute {
+	return attribute{Key: key, Value: attributeValue{StringValue: &value}}
+}
+
+func intAttribute(key string, value int) attribute {
+	s := strconv.Itoa(value)
+	return attribute{Key: key, Value: attributeValue{IntValue: &s}}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// newTraceRequest creates the spans for the run. If traceparent is a valid W3C
+// trace context, the run span is a child of the span it names.
+func newTraceRequest(run *Run, traceparent string, service string) (*traceRequest, error) {
+	traceID, parentSpanID, ok := parseTraceparent(traceparent)
+	if !ok {
+		id, err := randomID(16)
+		if err != nil {
+			return nil, err
+		}
+		traceID = id
+		parentSpanID = ""
+	}
+	runSpanID, err := randomID(8)
+	if err != nil {
+		return nil, err
+	}
+
+	runStatus := status{Code: _statusCodeOk}
+	if run.ExitCode != 0 {
+		runStatus = status{Code: _statusCodeError, Message: fmt.Sprintf("exit code %v", run.ExitCode)}
+	}
+	spans := []span{{
+		TraceID:           traceID,
+		SpanID:            runSpanID,
+		ParentSpanID:      parentSpanID,
+		Name:              "turbo run " + strings.Join(run.Targets, " "),
+		Kind:              _spanKindInternal,
+		StartTimeUnixNano: unixNano(run.StartedAt),
+		EndTimeUnixNano:   unixNano(run.StartedAt.Add(run.Duration)),
+		Attributes: []attribute{
+			stringAttribute("turbo.targets", strings.Join(run.Targets, ",")),
+			intAttribute("turbo.exit_code", run.ExitCode),
+		},
+		Status: runStatus,
+	}}
+
+	for _, task := range run.Tasks {
+		execution := task.Execution
+		// Skipped tasks have no span, since they never started, and neither do
+		// the tasks that were canceled before they started
+		if execution == nil || execution.Status == runsummary.TaskStatusSkipped || (execution.Status == runsummary.TaskStatusCanceled && execution.StartTime == 0) {
+			continue
+		}
+		spanID, err := randomID(8)
+		if err != nil {
+			return nil, err
+		}
+		start := time.UnixMilli(execution.StartTime)
+		attributes := []attribute{
+			stringAttribute("turbo.task_id", task.TaskID),
+			stringAttribute("turbo.task", task.Task),
+			stringAttribute("turbo.package", task.Package),
+			stringAttribute("turbo.hash", task.Hash),
+			stringAttribute("turbo.cache_status", cacheStatus(execution.Status)),
+		}
+		if execution.ExitCode != nil {
+			attributes = append(attributes, intAttribute("turbo.exit_code", *execution.ExitCode))
+		}
+		taskStatus := status{Code: _statusCodeOk}
+		if execution.Status == runsummary.TaskStatusFailed || execution.Status == runsummary.TaskStatusTimedOut {
+			taskStatus = status{Code: _statusCodeError, Message: execution.Error}
+		}
+		spans = append(spans, span{
+			TraceID:           traceID,
+			SpanID:            spanID,
+			ParentSpanID:      runSpanID,
+			Name:              task.TaskID,
+			Kind:              _spanKindInternal,
+			StartTimeUnixNano: unixNano(start),
+			EndTimeUnixNano:   unixNano(start.Add(time.Duration(execution.Duration) * time.Millisecond)),
+			Attributes:        attributes,
+			Status:            taskStatus,
+		})
+	}
+
+	return &traceRequest{
+		ResourceSpans: []resourceSpans{{
+			Resource: resource{Attributes: []attribute{
+				stringAttribute("service.name", service),
+				stringAttribute("service.version", run.TurboVersion),
+			}},
+			ScopeSpans: []scopeSpans{{
+				Scope: scope{Name: "turbo", Version: run.TurboVersion},
+				Spans: spans,
+			}},
+		}},
+	}, nil
+}
+
+// cacheStatus describes whether a task was restored from the cache
+func cacheStatus(executionStatus string) string {
+	if executionStatus == runsummary.TaskStatusCached {
+		return "HIT"
+	}
+	return "MISS"
+}
+
+// parseTraceparent returns the trace ID and parent span ID of a W3C trace
+// context, such as "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+func parseTraceparent(traceparent string) (string, string, bool) {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return "", "", false
+	}
+	traceID, spanID := strings.ToLower(parts[1]), strings.ToLower(parts[2])
+	if !isHex(traceID) || !isHex(spanID) || strings.Trim(traceID, "0") == "" || strings.Trim(spanID, "0") == "" {
+		return "", "", false
+	}
+	return traceID, spanID, true
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+func randomID(bytes int) (string, error) {
+	id := make([]byte, bytes)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
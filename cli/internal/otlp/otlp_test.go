@@ -0,0 +1,123 @@
+package otlp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/runsummary"
+	"gotest.tools/v3/assert"
+)
+
+func TestTracesURL(t *testing.T) {
+	t.Setenv(EndpointEnvVar, "")
+	t.Setenv(TracesEndpointEnvVar, "")
+	assert.Equal(t, TracesURL(""), "")
+	assert.Equal(t, TracesURL("http://collector:4318/"), "http://collector:4318/v1/traces")
+
+	t.Setenv(EndpointEnvVar, "http://from-env:4318")
+	assert.Equal(t, TracesURL(""), "http://from-env:4318/v1/traces")
+
+	t.Setenv(TracesEndpointEnvVar, "http://traces:4318/custom")
+	assert.Equal(t, TracesURL(""), "http://traces:4318/custom")
+	assert.Equal(t, TracesURL("http://flag:4318"), "http://flag:4318/v1/traces")
+}
+
+func TestParseTraceparent(t *testing.T) {
+	traceID, spanID, ok := parseTraceparent("00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01")
+	assert.Assert(t, ok)
+	assert.Equal(t, traceID, "4bf92f3577b34da6a3ce929d0e0e4736")
+	assert.Equal(t, spanID, "00f067aa0ba902b7")
+
+	for _, invalid := range []string{"", "00-short-00f067aa0ba902b7-01", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", "00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01"} {
+		_, _, ok := parseTraceparent(invalid)
+		assert.Assert(t, !ok, invalid)
+	}
+}
+
+func TestExport(t *testing.T) {
+	var received traceRequest
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.URL.Path, "/v1/traces")
+		authorization = r.Header.Get("Authorization")
+		assert.NilError(t, json.NewDecoder(r.Body).Decode(&received))
+	}))
+	defer server.Close()
+	t.Setenv(HeadersEnvVar, "Authorization=Bearer secret, X-Team = build")
+	t.Setenv(TraceparentEnvVar, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	t.Setenv(ServiceNameEnvVar, "")
+
+	exitCode := 2
+	zero := 0
+	startedAt := time.UnixMilli(1000)
+	run := &Run{
+		TurboVersion: "1.9.0",
+		Targets:      []string{"build", "test"},
+		StartedAt:    startedAt,
+		Duration:     3 * time.Second,
+		ExitCode:     2,
+		Tasks: []*runsummary.TaskSummary{
+			{TaskID: "web#build", Task: "build", Package: "web", Hash: "abc", Execution: &runsummary.TaskExecutionSummary{StartTime: 1100, Duration: 500, Status: runsummary.TaskStatusCached, ExitCode: &zero}},
+			{TaskID: "web#test", Task: "test", Package: "web", Hash: "def", Execution: &runsummary.TaskExecutionSummary{StartTime: 1600, Duration: 1000, Status: runsummary.TaskStatusFailed, ExitCode: &exitCode, Error: "running web#test failed"}},
+			{TaskID: "docs#test", Task: "test", Package: "docs", Hash: "ghi"},
+		},
+	}
+	exporter, err := New(TracesURL(server.URL))
+	assert.NilError(t, err)
+	assert.NilError(t, exporter.Export(context.Background(), run))
+	assert.Equal(t, authorization, "Bearer secret")
+
+	assert.Equal(t, len(received.ResourceSpans), 1)
+	assert.Equal(t, *received.ResourceSpans[0].Resource.Attributes[0].Value.StringValue, "turbo")
+	spans := received.ResourceSpans[0].ScopeSpans[0].Spans
+	assert.Equal(t, len(spans), 3)
+
+	runSpan := spans[0]
+	assert.Equal(t, runSpan.Name, "turbo run build test")
+	assert.Equal(t, runSpan.TraceID, "4bf92f3577b34da6a3ce929d0e0e4736")
+	assert.Equal(t, runSpan.ParentSpanID, "00f067aa0ba902b7")
+	assert.Equal(t, runSpan.StartTimeUnixNano, "1000000000")
+	assert.Equal(t, runSpan.EndTimeUnixNano, "4000000000")
+	assert.Equal(t, runSpan.Status.Code, _statusCodeError)
+
+	cached := spans[1]
+	assert.Equal(t, cached.ParentSpanID, runSpan.SpanID)
+	assert.Equal(t, cached.StartTimeUnixNano, "1100000000")
+	assert.Equal(t, cached.EndTimeUnixNano, "1600000000")
+	assert.Equal(t, cached.Status.Code, _statusCodeOk)
+	attributes := map[string]attributeValue{}
+	for _, attr := range cached.Attributes {
+		attributes[attr.Key] = attr.Value
+	}
+	assert.Equal(t, *attributes["turbo.package"].StringValue, "web")
+	assert.Equal(t, *attributes["turbo.cache_status"].StringValue, "HIT")
+	assert.Equal(t, *attributes["turbo.hash"].StringValue, "abc")
+	assert.Equal(t, *attributes["turbo.exit_code"].IntValue, "0")
+
+	failed := spans[2]
+	assert.Equal(t, failed.Name, "web#test")
+	assert.Equal(t, failed.Status.Code, _statusCodeError)
+	assert.Equal(t, failed.Status.Message, "running web#test failed")
+}
+
+func TestExportReportsErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte("bad token\n"))
+	}))
+	defer server.Close()
+	t.Setenv(HeadersEnvVar, "")
+
+	exporter, err := New(TracesURL(server.URL))
+	assert.NilError(t, err)
+	err = exporter.Export(context.Background(), &Run{StartedAt: time.Now()})
+	assert.ErrorContains(t, err, "401 Unauthorized bad token")
+
+	t.Setenv(HeadersEnvVar, "not-a-header")
+	_, err = New(TracesURL(server.URL))
+	assert.ErrorContains(t, err, HeadersEnvVar)
+}
@@ -20,6 +20,8 @@ var nodejsBerry = PackageManager{
 	Specfile:   "package.json",
 	Lockfile:   "yarn.lock",
 	PackageDir: "node_modules",
+	// The yarn release and plugins that .yarnrc.yml points to are usually checked in
+	InstallConfigPaths: []string{".yarnrc.yml", ".yarn/releases", ".yarn/plugins"},
 
 	getWorkspaceGlobs: func(rootpath turbopath.AbsoluteSystemPath) ([]string, error) {
 		pkg, err := fs.ReadPackageJSON(rootpath.UntypedJoin("package.json"))
@@ -113,8 +115,19 @@ var nodejsBerry = PackageManager{
 		return true, nil
 	},
 
-	UnmarshalLockfile: func(contents []byte) (lockfile.Lockfile, error) {
-		return lockfile.DecodeBerryLockfile(contents)
+	UnmarshalLockfile: func(rootPackageJSON *fs.PackageJSON, contents []byte) (lockfile.Lockfile, error) {
+		// Dependencies that the resolutions override aren't in the lockfile
+		var resolutions map[string]string
+		if rootPackageJSON != nil {
+			untypedResolutions, _ := rootPackageJSON.RawJSON["resolutions"].(map[string]interface{})
+			resolutions = make(map[string]string, len(untypedResolutions))
+			for dependency, untypedVersion := range untypedResolutions {
+				if version, ok := untypedVersion.(string); ok {
+					resolutions[dependency] = version
+				}
+			}
+		}
+		return lockfile.DecodeBerryLockfile(contents, resolutions)
 	},
 
 	prunePatches: func(pkgJSON *fs.PackageJSON, patches []turbopath.AnchoredUnixPath) error {
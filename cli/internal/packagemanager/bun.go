@@ -0,0 +1,56 @@
+package packagemanager
+
+import (
+	"fmt"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/lockfile"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+var nodejsBun = PackageManager{
+	Name:       "nodejs-bun",
+	Slug:       "bun",
+	Command:    "bun",
+	Specfile:   "package.json",
+	Lockfile:   "bun.lockb",
+	PackageDir: "node_modules",
+	// bunfig.toml configures the registries and scopes of an install
+	InstallConfigPaths: []string{"bunfig.toml"},
+	// `bun run` passes the arguments after the script name to the script
+	ArgSeparator: nil,
+
+	getWorkspaceGlobs: func(rootpath turbopath.AbsoluteSystemPath) ([]string, error) {
+		pkg, err := fs.ReadPackageJSON(rootpath.UntypedJoin("package.json"))
+		if err != nil {
+			return nil, fmt.Errorf("package.json: %w", err)
+		}
+		if len(pkg.Workspaces) == 0 {
+			return nil, fmt.Errorf("package.json: no workspaces found. Turborepo requires Bun workspaces to be defined in the root package.json")
+		}
+		return pkg.Workspaces, nil
+	},
+
+	getWorkspaceIgnores: func(pm PackageManager, rootpath turbopath.AbsoluteSystemPath) ([]string, error) {
+		return []string{
+			"**/node_modules/**",
+		}, nil
+	},
+
+	Matches: func(manager string, version string) (bool, error) {
+		return manager == "bun", nil
+	},
+
+	detect: func(projectDirectory turbopath.AbsoluteSystemPath, packageManager *PackageManager) (bool, error) {
+		specfileExists := projectDirectory.UntypedJoin(packageManager.Specfile).FileExists()
+		lockfileExists := projectDirectory.UntypedJoin(packageManager.Lockfile).FileExists()
+
+		return (specfileExists && lockfileExists), nil
+	},
+
+	// bun.lockb can't be written, so there is no canPrune
+
+	UnmarshalLockfile: func(_rootPackageJSON *fs.PackageJSON, contents []byte) (lockfile.Lockfile, error) {
+		return lockfile.DecodeBunLockfile(contents)
+	},
+}
@@ -18,7 +18,18 @@ import (
 	"github.com/vercel/turbo/cli/internal/util"
 )
 
-// PackageManager is an abstraction across package managers
+// PackageManager is an abstraction across package managers. The rest of turbo
+// only goes through its fields and methods, so a package manager is added by
+// defining one in its own file and listing it in packageManagers:
+//
+//   - Matches and detect find it from the "packageManager" of the root
+//     package.json, or from the files in the repository
+//   - getWorkspaceGlobs and getWorkspaceIgnores find its workspaces
+//   - UnmarshalLockfile reads its Lockfile, to hash the external dependencies
+//     of each workspace and to find the workspaces they changed with --filter
+//   - Command, ScriptArgs and ArgSeparator run the scripts of tasks
+//   - canPrune, prunePatches and InstallConfigPaths support `turbo prune`,
+//     which also needs the Lockfile to implement Subgraph and Encode
 type PackageManager struct {
 	// The descriptive name of the Package Manager.
 	Name string
@@ -41,6 +52,10 @@ type PackageManager struct {
 	// The location of the file that defines the workspace. Empty if workspaces defined in package.json
 	WorkspaceConfigurationPath string
 
+	// Files and directories besides the lockfile that an install reads, relative to the
+	// repository root. `turbo prune` copies the ones that exist.
+	InstallConfigPaths []string
+
 	// The separator that the Package Manger uses to identify arguments that
 	// should be passed through to the underlying script.
 	ArgSeparator []string
@@ -60,14 +75,18 @@ type PackageManager struct {
 	// Detect if the project is using the Package Manager by inspecting the system.
 	detect func(projectDirectory turbopath.AbsoluteSystemPath, packageManager *PackageManager) (bool, error)
 
-	// Read a lockfile for a given package manager
-	UnmarshalLockfile func(contents []byte) (lockfile.Lockfile, error)
+	// Read a lockfile for a given package manager. Some lockfiles depend on
+	// settings of the root package.json.
+	UnmarshalLockfile func(rootPackageJSON *fs.PackageJSON, contents []byte) (lockfile.Lockfile, error)
 
 	// Prune the given pkgJSON to only include references to the given patches
 	prunePatches func(pkgJSON *fs.PackageJSON, patches []turbopath.AnchoredUnixPath) error
 }
 
 var packageManagers = []PackageManager{
+	// Bun is detected first, since it can also write a yarn.lock next to
+	// bun.lockb for tools that don't read bun.lockb
+	nodejsBun,
 	nodejsYarn,
 	nodejsBerry,
 	nodejsNpm,
@@ -76,7 +95,7 @@ var packageManagers = []PackageManager{
 }
 
 var (
-	packageManagerPattern = `(npm|pnpm|yarn)@(\d+)\.\d+\.\d+(-.+)?`
+	packageManagerPattern = `(npm|pnpm|yarn|bun)@(\d+)\.\d+\.\d+(-.+)?`
 	packageManagerRegex   = regexp.MustCompile(packageManagerPattern)
 )
 
@@ -159,6 +178,22 @@ func (pm PackageManager) GetWorkspaces(rootpath turbopath.AbsoluteSystemPath) ([
 	return f, nil
 }
 
+// ScriptArgs returns the arguments to Command that run the given script of a
+// workspace, passing it args
+func (pm PackageManager) ScriptArgs(script string, args []string) []string {
+	scriptArgs := []string{"run", script}
+	if len(args) > 0 {
+		scriptArgs = append(scriptArgs, pm.ArgSeparator...)
+		scriptArgs = append(scriptArgs, args...)
+	}
+	return scriptArgs
+}
+
+// GetWorkspaceGlobs returns the globs that define where workspaces live in the current repository.
+func (pm PackageManager) GetWorkspaceGlobs(rootpath turbopath.AbsoluteSystemPath) ([]string, error) {
+	return pm.getWorkspaceGlobs(rootpath)
+}
+
 // GetWorkspaceIgnores returns an array of globs not to search for workspaces.
 func (pm PackageManager) GetWorkspaceIgnores(rootpath turbopath.AbsoluteSystemPath) ([]string, error) {
 	return pm.getWorkspaceIgnores(pm, rootpath)
@@ -173,7 +208,7 @@ func (pm PackageManager) CanPrune(projectDirectory turbopath.AbsoluteSystemPath)
 }
 
 // ReadLockfile will read the applicable lockfile into memory
-func (pm PackageManager) ReadLockfile(projectDirectory turbopath.AbsoluteSystemPath) (lockfile.Lockfile, error) {
+func (pm PackageManager) ReadLockfile(projectDirectory turbopath.AbsoluteSystemPath, rootPackageJSON *fs.PackageJSON) (lockfile.Lockfile, error) {
 	if pm.UnmarshalLockfile == nil {
 		return nil, nil
 	}
@@ -181,7 +216,7 @@ func (pm PackageManager) ReadLockfile(projectDirectory turbopath.AbsoluteSystemP
 	if err != nil {
 		return nil, fmt.Errorf("reading %s: %w", pm.Lockfile, err)
 	}
-	return pm.UnmarshalLockfile(contents)
+	return pm.UnmarshalLockfile(rootPackageJSON, contents)
 }
 
 // PrunePatchedPackages will alter the provided pkgJSON to only reference the provided patches
@@ -83,6 +83,13 @@ func TestParsePackageManagerString(t *testing.T) {
 			wantVersion:    "111.0.1",
 			wantErr:        false,
 		},
+		{
+			name:           "supports bun",
+			packageManager: "bun@1.0.2",
+			wantManager:    "bun",
+			wantVersion:    "1.0.2",
+			wantErr:        false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -148,6 +155,13 @@ func TestGetPackageManager(t *testing.T) {
 			want:             "nodejs-berry",
 			wantErr:          false,
 		},
+		{
+			name:             "finds bun from a package manager string",
+			projectDirectory: cwd,
+			pkg:              &fs.PackageJSON{PackageManager: "bun@1.0.2"},
+			want:             "nodejs-bun",
+			wantErr:          false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -200,6 +214,12 @@ func Test_readPackageManager(t *testing.T) {
 			want:    "nodejs-berry",
 			wantErr: false,
 		},
+		{
+			name:    "finds bun from a package manager string",
+			pkg:     &fs.PackageJSON{PackageManager: "bun@1.0.2"},
+			want:    "nodejs-bun",
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -234,6 +254,7 @@ func Test_GetWorkspaces(t *testing.T) {
 		"nodejs-yarn":  repoRoot.UntypedJoin("../../../examples/with-yarn"),
 		"nodejs-pnpm":  repoRoot.UntypedJoin("../../../examples/basic"),
 		"nodejs-pnpm6": repoRoot.UntypedJoin("../../../examples/basic"),
+		"nodejs-bun":   repoRoot.UntypedJoin("../../../examples/with-yarn"),
 	}
 
 	want := map[string][]string{
@@ -272,6 +293,13 @@ func Test_GetWorkspaces(t *testing.T) {
 			filepath.ToSlash(filepath.Join(cwd, "../../../examples/basic/packages/tsconfig/package.json")),
 			filepath.ToSlash(filepath.Join(cwd, "../../../examples/basic/packages/ui/package.json")),
 		},
+		"nodejs-bun": {
+			filepath.ToSlash(filepath.Join(cwd, "../../../examples/with-yarn/apps/docs/package.json")),
+			filepath.ToSlash(filepath.Join(cwd, "../../../examples/with-yarn/apps/web/package.json")),
+			filepath.ToSlash(filepath.Join(cwd, "../../../examples/with-yarn/packages/eslint-config-custom/package.json")),
+			filepath.ToSlash(filepath.Join(cwd, "../../../examples/with-yarn/packages/tsconfig/package.json")),
+			filepath.ToSlash(filepath.Join(cwd, "../../../examples/with-yarn/packages/ui/package.json")),
+		},
 	}
 
 	tests := make([]test, len(packageManagers))
@@ -325,6 +353,7 @@ func Test_GetWorkspaceIgnores(t *testing.T) {
 		"nodejs-yarn":  {"apps/*/node_modules/**", "packages/*/node_modules/**"},
 		"nodejs-pnpm":  {"**/node_modules/**", "**/bower_components/**", "packages/skip"},
 		"nodejs-pnpm6": {"**/node_modules/**", "**/bower_components/**", "packages/skip"},
+		"nodejs-bun":   {"**/node_modules/**"},
 	}
 
 	tests := make([]test, len(packageManagers))
@@ -382,6 +411,7 @@ func Test_CanPrune(t *testing.T) {
 		"nodejs-yarn":  {true, false},
 		"nodejs-pnpm":  {true, false},
 		"nodejs-pnpm6": {true, false},
+		"nodejs-bun":   {false, false},
 	}
 
 	tests := make([]test, len(packageManagers))
@@ -409,3 +439,9 @@ func Test_CanPrune(t *testing.T) {
 		})
 	}
 }
+
+func TestScriptArgs(t *testing.T) {
+	assert.DeepEqual(t, nodejsNpm.ScriptArgs("build", nil), []string{"run", "build"})
+	assert.DeepEqual(t, nodejsNpm.ScriptArgs("test", []string{"--watch"}), []string{"run", "test", "--", "--watch"})
+	assert.DeepEqual(t, nodejsBun.ScriptArgs("test", []string{"--watch"}), []string{"run", "test", "--watch"})
+}
@@ -84,6 +84,8 @@ var nodejsPnpm = PackageManager{
 	// nil for empty slices.
 	ArgSeparator:               nil,
 	WorkspaceConfigurationPath: "pnpm-workspace.yaml",
+	// The checksum of the pnpmfile is recorded in the lockfile
+	InstallConfigPaths: []string{".pnpmfile.cjs"},
 
 	getWorkspaceGlobs: getPnpmWorkspaceGlobs,
 
@@ -117,7 +119,7 @@ var nodejsPnpm = PackageManager{
 		return true, nil
 	},
 
-	UnmarshalLockfile: func(contents []byte) (lockfile.Lockfile, error) {
+	UnmarshalLockfile: func(_rootPackageJSON *fs.PackageJSON, contents []byte) (lockfile.Lockfile, error) {
 		return lockfile.DecodePnpmLockfile(contents)
 	},
 
@@ -101,6 +101,11 @@ type NewInput struct {
 
 	// Logger receives debug log lines about the process state and transitions
 	Logger hclog.Logger
+
+	// Foreground keeps the process in turbo's process group, so that it can
+	// read from the terminal. Otherwise it gets a process group of its own,
+	// so that the processes it starts are stopped along with it.
+	Foreground bool
 }
 
 // New creates a new child process for management with high-level APIs for
@@ -117,7 +122,7 @@ func newChild(i NewInput) (*Child, error) {
 		killTimeout: i.KillTimeout,
 		splay:       i.Splay,
 		stopCh:      make(chan struct{}, 1),
-		setpgid:     true,
+		setpgid:     !i.Foreground,
 		Label:       label,
 		logger:      i.Logger.Named(label),
 	}
@@ -350,7 +355,11 @@ func (c *Child) kill(immediately bool) {
 	defer func() {
 		if !exited {
 			c.logger.Debug("PKill")
-			c.cmd.Process.Kill()
+			// Kill the process group too, so that no process started by the
+			// child is left running
+			if err := c.signal(os.Kill); err != nil {
+				c.cmd.Process.Kill()
+			}
 		}
 		c.cmd = nil
 	}()
@@ -11,7 +11,11 @@ package process
  */
 
 import (
+	"bytes"
+	"context"
 	"os/exec"
+	"strconv"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
@@ -188,3 +192,43 @@ func TestSetpgid(t *testing.T) {
 		}
 	})
 }
+
+func TestUsage(t *testing.T) {
+	mgr := newManager()
+	cmd := exec.Command("sh", "-c", "i=0; while [ $i -lt 20000 ]; do i=$((i+1)); done")
+	if usage := Usage(cmd); usage != nil {
+		t.Fatalf("expected no usage before the command runs, got %v", usage)
+	}
+	if err := mgr.Exec(cmd); err != nil {
+		t.Fatalf("expected %q to be nil", err)
+	}
+	usage := Usage(cmd)
+	if usage == nil {
+		t.Fatal("expected the usage of the command")
+	}
+	if usage.CPUTime <= 0 {
+		t.Errorf("expected CPU time, got %v", usage.CPUTime)
+	}
+	// sh takes at least a few hundred kilobytes
+	if usage.PeakRSS < 100*1024 {
+		t.Errorf("expected a peak RSS of at least 100KB, got %v", usage.PeakRSS)
+	}
+}
+
+func TestExecInteractive(t *testing.T) {
+	if _, err := exec.LookPath("ps"); err != nil {
+		t.Skip("ps is not available")
+	}
+	mgr := newManager()
+	out := &bytes.Buffer{}
+	cmd := exec.Command("sh", "-c", "ps -o pgid= -p $$")
+	cmd.Stdout = out
+	if err := mgr.ExecInteractive(context.Background(), cmd, 0); err != nil {
+		t.Fatalf("expected %q to be nil", err)
+	}
+
+	// the child stays in our process group, which owns the terminal
+	if pgid := strings.TrimSpace(out.String()); pgid != strconv.Itoa(syscall.Getpgrp()) {
+		t.Fatalf("expected the child to be in process group %v, found %q", syscall.Getpgrp(), pgid)
+	}
+}
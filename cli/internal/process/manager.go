@@ -1,6 +1,7 @@
 package process
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -16,6 +17,10 @@ import (
 // child processes will be stopped with this error.
 var ErrClosing = errors.New("process manager is already closing")
 
+// DefaultGracePeriod is how long children have to exit by default once the
+// manager closes
+const DefaultGracePeriod = 10 * time.Second
+
 // ChildExit is returned when a child process exits with a non-zero exit code
 type ChildExit struct {
 	ExitCode int
@@ -26,6 +31,40 @@ func (ce *ChildExit) Error() string {
 	return fmt.Sprintf("command %s exited (%d)", ce.Command, ce.ExitCode)
 }
 
+// TimedOut is returned when a child process is stopped for running longer than
+// its timeout
+type TimedOut struct {
+	Timeout time.Duration
+	Command string
+}
+
+func (to *TimedOut) Error() string {
+	return fmt.Sprintf("command %s timed out after %v", to.Command, to.Timeout)
+}
+
+// ResourceUsage is what a child process used, along with the processes it
+// started and waited for
+type ResourceUsage struct {
+	// CPUTime is the user and system CPU time
+	CPUTime time.Duration
+	// PeakRSS is the largest resident set size of any of the processes, in
+	// bytes. It is 0 where it isn't measured, on Windows.
+	PeakRSS int64
+}
+
+// Usage returns the resources used by an exited command, from its ProcessState.
+// It returns nil if the command didn't exit.
+func Usage(cmd *exec.Cmd) *ResourceUsage {
+	state := cmd.ProcessState
+	if state == nil {
+		return nil
+	}
+	return &ResourceUsage{
+		CPUTime: state.UserTime() + state.SystemTime(),
+		PeakRSS: peakRSS(state),
+	}
+}
+
 // Manager tracks all of the child processes that have been spawned
 type Manager struct {
 	done     bool
@@ -33,22 +72,58 @@ type Manager struct {
 	mu       sync.Mutex
 	doneCh   chan struct{}
 	logger   hclog.Logger
+	// gracePeriod is how long children have to exit once they are
+	// stopped, before they are killed
+	gracePeriod time.Duration
 }
 
 // NewManager creates a new properly-initialized Manager instance
 func NewManager(logger hclog.Logger) *Manager {
 	return &Manager{
-		children: make(map[*Child]struct{}),
-		doneCh:   make(chan struct{}),
-		logger:   logger,
+		children:    make(map[*Child]struct{}),
+		doneCh:      make(chan struct{}),
+		logger:      logger,
+		gracePeriod: DefaultGracePeriod,
 	}
 }
 
+// SetGracePeriod sets how long the children that are running when the manager
+// closes have to exit after SIGINT, before they are killed
+func (m *Manager) SetGracePeriod(gracePeriod time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gracePeriod = gracePeriod
+}
+
 // Exec spawns a child process to run the given command, then blocks
 // until it completes. Returns a nil error if the child process finished
 // successfully, ErrClosing if the manager closed during execution, and
 // a ChildExit error if the child process exited with a non-zero exit code.
 func (m *Manager) Exec(cmd *exec.Cmd) error {
+	return m.ExecWithTimeout(cmd, 0)
+}
+
+// ExecWithTimeout is like Exec, but once the child process runs for longer than
+// timeout, it is stopped along with the processes it started, and a TimedOut
+// error is returned. A zero timeout lets the child process run forever.
+func (m *Manager) ExecWithTimeout(cmd *exec.Cmd, timeout time.Duration) error {
+	return m.ExecWithContext(context.Background(), cmd, timeout)
+}
+
+// ExecWithContext is like ExecWithTimeout, but the child process is also
+// stopped once ctx is done, and the error of ctx is returned
+func (m *Manager) ExecWithContext(ctx context.Context, cmd *exec.Cmd, timeout time.Duration) error {
+	return m.exec(ctx, cmd, timeout, false)
+}
+
+// ExecInteractive is like ExecWithContext, but the child process stays in the
+// foreground of the terminal, so that it can prompt for input. The processes
+// it starts aren't stopped along with it.
+func (m *Manager) ExecInteractive(ctx context.Context, cmd *exec.Cmd, timeout time.Duration) error {
+	return m.exec(ctx, cmd, timeout, true)
+}
+
+func (m *Manager) exec(ctx context.Context, cmd *exec.Cmd, timeout time.Duration, foreground bool) error {
 	m.mu.Lock()
 	if m.done {
 		m.mu.Unlock()
@@ -59,11 +134,12 @@ func (m *Manager) Exec(cmd *exec.Cmd) error {
 		Cmd: cmd,
 		// Run forever by default
 		Timeout: 0,
-		// When it's time to exit, give a 10 second timeout
-		KillTimeout: 10 * time.Second,
+		// When it's time to exit, give the grace period
+		KillTimeout: m.gracePeriod,
 		// Send SIGINT to stop children
 		KillSignal: os.Interrupt,
 		Logger:     m.logger,
+		Foreground: foreground,
 	})
 	if err != nil {
 		return err
@@ -78,15 +154,36 @@ func (m *Manager) Exec(cmd *exec.Cmd) error {
 		m.mu.Unlock()
 		return err
 	}
+	var timeoutCh <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
 	err = nil
-	exitCode, ok := <-child.ExitCh()
-	if !ok {
-		err = ErrClosing
-	} else if exitCode != ExitCodeOK {
-		err = &ChildExit{
-			ExitCode: exitCode,
-			Command:  child.Command(),
+	select {
+	case exitCode, ok := <-child.ExitCh():
+		if !ok {
+			err = ErrClosing
+		} else if exitCode != ExitCodeOK {
+			err = &ChildExit{
+				ExitCode: exitCode,
+				Command:  child.Command(),
+			}
+		}
+	case <-timeoutCh:
+		m.logger.Debug("timed out", "command", child.Command(), "timeout", timeout)
+		child.Kill()
+		<-child.ExitCh()
+		err = &TimedOut{
+			Timeout: timeout,
+			Command: child.Command(),
 		}
+	case <-ctx.Done():
+		m.logger.Debug("canceled", "command", child.Command())
+		child.Kill()
+		<-child.ExitCh()
+		err = ctx.Err()
 	}
 
 	m.mu.Lock()
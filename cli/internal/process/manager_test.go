@@ -1,6 +1,7 @@
 package process
 
 import (
+	"context"
 	"errors"
 	"os/exec"
 	"sync"
@@ -92,3 +93,62 @@ func TestExitCode(t *testing.T) {
 		t.Error("expected non-zero exit code , got 0")
 	}
 }
+
+func TestExecWithTimeout(t *testing.T) {
+	mgr := newManager()
+
+	start := time.Now()
+	err := mgr.ExecWithTimeout(exec.Command("sleep", "5"), 100*time.Millisecond)
+	timedOut := &TimedOut{}
+	if !errors.As(err, &timedOut) {
+		t.Errorf("expected a TimedOut err, got %q", err)
+	}
+	if timedOut.Timeout != 100*time.Millisecond {
+		t.Errorf("expected a timeout of 100ms, got %v", timedOut.Timeout)
+	}
+	if duration := time.Since(start); duration >= 5*time.Second {
+		t.Errorf("expected to stop the command, total time was %q", duration)
+	}
+
+	err = mgr.ExecWithTimeout(exec.Command("sleep", "0.1"), 5*time.Second)
+	if err != nil {
+		t.Errorf("expected %q to be nil", err)
+	}
+}
+
+func TestExecWithContext(t *testing.T) {
+	mgr := newManager()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	err := mgr.ExecWithContext(ctx, exec.Command("sleep", "5"), 0)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a canceled err, got %q", err)
+	}
+	if duration := time.Since(start); duration >= 5*time.Second {
+		t.Errorf("expected to stop the command, total time was %q", duration)
+	}
+}
+
+func TestClose_gracePeriod(t *testing.T) {
+	mgr := newManager()
+	mgr.SetGracePeriod(100 * time.Millisecond)
+
+	errCh := make(chan error)
+	go func() {
+		// The child ignores SIGINT, so it is only stopped once the grace period ends
+		errCh <- mgr.Exec(exec.Command("sh", "-c", "trap '' INT; sleep 5"))
+	}()
+	// let the process kick off
+	time.Sleep(50 * time.Millisecond)
+	start := time.Now()
+	mgr.Close()
+	duration := time.Since(start)
+	if duration >= 2*time.Second {
+		t.Errorf("expected the child to be killed after the grace period, total time was %q", duration)
+	}
+	if err := <-errCh; err != ErrClosing {
+		t.Errorf("expected manager closing error, found %q", err)
+	}
+}
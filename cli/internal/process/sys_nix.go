@@ -9,7 +9,9 @@ package process
  */
 
 import (
+	"os"
 	"os/exec"
+	"runtime"
 	"syscall"
 )
 
@@ -21,3 +23,17 @@ func processNotFoundErr(err error) bool {
 	// ESRCH == no such process, ie. already exited
 	return err == syscall.ESRCH
 }
+
+// peakRSS returns the maxrss of the rusage of an exited process, which covers
+// the descendants it waited for. It is in bytes on macOS, and in kilobytes
+// elsewhere.
+func peakRSS(state *os.ProcessState) int64 {
+	rusage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok || rusage == nil {
+		return 0
+	}
+	if runtime.GOOS == "darwin" {
+		return int64(rusage.Maxrss)
+	}
+	return int64(rusage.Maxrss) * 1024
+}
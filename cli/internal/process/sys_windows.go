@@ -8,10 +8,19 @@ package process
  * https://github.com/hashicorp/consul-template/tree/3ea7d99ad8eff17897e0d63dac86d74770170bb8/child/sys_windows.go
  */
 
-import "os/exec"
+import (
+	"os"
+	"os/exec"
+)
 
 func setSetpgid(cmd *exec.Cmd, value bool) {}
 
 func processNotFoundErr(err error) bool {
 	return false
 }
+
+// peakRSS isn't measured on Windows, where the rusage of a process only has
+// its times
+func peakRSS(state *os.ProcessState) int64 {
+	return 0
+}
@@ -0,0 +1,182 @@
+package prune
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/packagemanager"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// The layers of the BuildKit context written with --docker-buildkit-context,
+// in the order they are copied into an image. Each layer only changes when the
+// files it holds do, so the layers after an unchanged one keep their cache.
+const (
+	// _lockfileLayer is the pruned lockfile and the configuration of the
+	// package manager, which is enough for pnpm to download every package
+	_lockfileLayer = "lockfile"
+	// _manifestsLayer is the package.json of every workspace, to install
+	_manifestsLayer = "json"
+	// _sourceLayer is the full source of every workspace
+	_sourceLayer = "full"
+)
+
+var _dockerContextLayers = []string{_lockfileLayer, _manifestsLayer, _sourceLayer}
+
+const (
+	// _dockerContextName is the name of the BuildKit context in the Dockerfile
+	_dockerContextName = "turbo"
+	// _dockerfileSnippet is the Dockerfile instructions that copy the layers
+	_dockerfileSnippet = "Dockerfile.turbo"
+	// _dockerContextManifest lists the files of each layer, with their hashes
+	_dockerContextManifest = "docker-context.json"
+)
+
+// dockerContextManifest is the content of every layer of the BuildKit context.
+// The digest of a layer can key a CI cache, and comparing two manifests shows
+// which files made a layer change.
+type dockerContextManifest struct {
+	PackageManager string                `json:"packageManager"`
+	Layers         []*dockerContextLayer `json:"layers"`
+}
+
+type dockerContextLayer struct {
+	Name string `json:"name"`
+	// Digest changes whenever a file of the layer is added, removed or changed
+	Digest string `json:"digest"`
+	// Files are the hashes of the files of the layer, keyed by their path in it
+	Files map[turbopath.AnchoredUnixPath]string `json:"files"`
+}
+
+// writeLockfileLayer copies the pruned lockfile, and the files an install
+// reads besides the lockfile, into the lockfile layer
+func writeLockfileLayer(repoRoot turbopath.AbsoluteSystemPath, outDir turbopath.AbsoluteSystemPath, packageManager *packagemanager.PackageManager) error {
+	layerDir := outDir.UntypedJoin(_lockfileLayer)
+	if err := layerDir.MkdirAll(0755); err != nil {
+		return errors.Wrapf(err, "failed to create folder %v", layerDir)
+	}
+	lockfile := fs.LstatCachedFile{Path: outDir.UntypedJoin(packageManager.Lockfile)}
+	if err := fs.CopyFile(&lockfile, layerDir.UntypedJoin(packageManager.Lockfile).ToString()); err != nil {
+		return errors.Wrap(err, "failed to copy the pruned lockfile")
+	}
+	for _, configPath := range append([]string{".npmrc"}, packageManager.InstallConfigPaths...) {
+		originalPath := repoRoot.UntypedJoin(configPath)
+		if !originalPath.Exists() {
+			continue
+		}
+		if err := fs.RecursiveCopy(originalPath.ToString(), layerDir.UntypedJoin(configPath).ToString()); err != nil {
+			return errors.Wrapf(err, "failed to copy %s", configPath)
+		}
+	}
+	return nil
+}
+
+// newDockerContextManifest hashes the files of every layer under outDir
+func newDockerContextManifest(outDir turbopath.AbsoluteSystemPath, packageManager *packagemanager.PackageManager) (*dockerContextManifest, error) {
+	manifest := &dockerContextManifest{PackageManager: packageManager.Slug}
+	for _, name := range _dockerContextLayers {
+		layerDir := outDir.UntypedJoin(name)
+		layer := &dockerContextLayer{Name: name, Files: make(map[turbopath.AnchoredUnixPath]string)}
+		err := fs.Walk(layerDir.ToString(), func(path string, isDir bool) error {
+			if isDir {
+				return nil
+			}
+			relative, err := filepath.Rel(layerDir.ToString(), path)
+			if err != nil {
+				return err
+			}
+			hash, err := fs.HashFile(path)
+			if err != nil {
+				return err
+			}
+			layer.Files[turbopath.AnchoredSystemPathFromUpstream(relative).ToUnixPath()] = hash
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to hash the %v layer", name)
+		}
+		// The digest doesn't depend on the hash version of turbo.json, so that
+		// it only changes with the files
+		if layer.Digest, err = fs.HashFileHashes(fs.HashVersion2, layer.Files); err != nil {
+			return nil, err
+		}
+		manifest.Layers = append(manifest.Layers, layer)
+	}
+	return manifest, nil
+}
+
+// installCommands returns the command that downloads the packages of the
+// lockfile, if the package manager has one, and the command that installs
+// them once the package.json files are there
+func installCommands(packageManager *packagemanager.PackageManager) (string, string) {
+	switch packageManager.Name {
+	case "nodejs-pnpm", "nodejs-pnpm6":
+		return "pnpm fetch", "pnpm install --offline --frozen-lockfile"
+	case "nodejs-berry":
+		return "", "yarn install --immutable"
+	case "nodejs-yarn":
+		return "", "yarn install --frozen-lockfile"
+	case "nodejs-bun":
+		return "", "bun install --frozen-lockfile"
+	default:
+		return "", "npm ci"
+	}
+}
+
+// dockerfileSnippet returns the Dockerfile instructions that copy each layer of
+// the BuildKit context at outputDir on its own, and install after the layers
+// that the install reads
+func dockerfileSnippet(outputDir string, packageManager *packagemanager.PackageManager) string {
+	fetch, install := installCommands(packageManager)
+	var snippet strings.Builder
+	fmt.Fprintf(&snippet, "# Generated by `turbo prune --docker-buildkit-context`. Build with the\n")
+	fmt.Fprintf(&snippet, "# pruned output as a named context:\n")
+	fmt.Fprintf(&snippet, "#   docker buildx build --build-context %v=%v .\n", _dockerContextName, filepath.ToSlash(outputDir))
+	fmt.Fprintf(&snippet, "COPY --from=%v %v/ .\n", _dockerContextName, _lockfileLayer)
+	if fetch != "" {
+		fmt.Fprintf(&snippet, "RUN %v\n", fetch)
+	}
+	fmt.Fprintf(&snippet, "COPY --from=%v %v/ .\n", _dockerContextName, _manifestsLayer)
+	fmt.Fprintf(&snippet, "RUN %v\n", install)
+	fmt.Fprintf(&snippet, "COPY --from=%v %v/ .\n", _dockerContextName, _sourceLayer)
+	return snippet.String()
+}
+
+// writeDockerContext completes the layers of the BuildKit context with the
+// lockfile layer, and writes the Dockerfile snippet and the content manifest
+func writeDockerContext(repoRoot turbopath.AbsoluteSystemPath, outDir turbopath.AbsoluteSystemPath, outputDir string, packageManager *packagemanager.PackageManager) (*dockerContextManifest, error) {
+	if err := writeLockfileLayer(repoRoot, outDir, packageManager); err != nil {
+		return nil, err
+	}
+	if err := outDir.UntypedJoin(_dockerfileSnippet).WriteFile([]byte(dockerfileSnippet(outputDir, packageManager)), 0644); err != nil {
+		return nil, errors.Wrapf(err, "failed to write %v", _dockerfileSnippet)
+	}
+	manifest, err := newDockerContextManifest(outDir, packageManager)
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := outDir.UntypedJoin(_dockerContextManifest).WriteFile(append(bytes, '\n'), 0644); err != nil {
+		return nil, errors.Wrapf(err, "failed to write %v", _dockerContextManifest)
+	}
+	return manifest, nil
+}
+
+// removeDockerContextLayers removes the layers of an earlier prune into the
+// same directory, so that files of workspaces that were pruned since don't
+// change the layers
+func removeDockerContextLayers(outDir turbopath.AbsoluteSystemPath) error {
+	for _, name := range _dockerContextLayers {
+		if err := outDir.UntypedJoin(name).RemoveAll(); err != nil {
+			return errors.Wrapf(err, "failed to remove the %v layer of an earlier prune", name)
+		}
+	}
+	return nil
+}
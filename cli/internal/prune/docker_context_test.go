@@ -0,0 +1,71 @@
+package prune
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/packagemanager"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func TestDockerfileSnippet(t *testing.T) {
+	pnpm := &packagemanager.PackageManager{Name: "nodejs-pnpm", Slug: "pnpm"}
+	snippet := dockerfileSnippet("out", pnpm)
+	assert.Assert(t, strings.Contains(snippet, "--build-context turbo=out"))
+	lines := strings.Split(strings.TrimSpace(snippet), "\n")
+	assert.DeepEqual(t, lines[len(lines)-5:], []string{
+		"COPY --from=turbo lockfile/ .",
+		"RUN pnpm fetch",
+		"COPY --from=turbo json/ .",
+		"RUN pnpm install --offline --frozen-lockfile",
+		"COPY --from=turbo full/ .",
+	})
+
+	npm := &packagemanager.PackageManager{Name: "nodejs-npm", Slug: "npm"}
+	assert.Assert(t, !strings.Contains(dockerfileSnippet("out", npm), "fetch"))
+	assert.Assert(t, strings.Contains(dockerfileSnippet("out", npm), "RUN npm ci\n"))
+}
+
+func TestWriteDockerContext(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	outDir := repoRoot.UntypedJoin("out")
+	files := map[string]string{
+		"package-lock.json":              "{}",
+		"json/package.json":              `{"name": "root"}`,
+		"json/apps/web/package.json":     `{"name": "web"}`,
+		"full/package.json":              `{"name": "root"}`,
+		"full/apps/web/package.json":     `{"name": "web"}`,
+		"full/apps/web/src/index.js":     "export {}",
+		"full/packages/ui/src/index.tsx": "export {}",
+	}
+	for path, content := range files {
+		file := outDir.UntypedJoin(path)
+		assert.NilError(t, file.EnsureDir())
+		assert.NilError(t, file.WriteFile([]byte(content), 0644))
+	}
+	assert.NilError(t, repoRoot.UntypedJoin(".npmrc").WriteFile([]byte("registry=https://example.com\n"), 0644))
+	npm := &packagemanager.PackageManager{Name: "nodejs-npm", Slug: "npm", Lockfile: "package-lock.json"}
+
+	manifest, err := writeDockerContext(repoRoot, outDir, "out", npm)
+	assert.NilError(t, err)
+	assert.Equal(t, manifest.PackageManager, "npm")
+	assert.Equal(t, len(manifest.Layers), 3)
+	lockfileLayer, manifestsLayer, sourceLayer := manifest.Layers[0], manifest.Layers[1], manifest.Layers[2]
+	assert.Equal(t, lockfileLayer.Name, "lockfile")
+	assert.Equal(t, len(lockfileLayer.Files), 2)
+	assert.Assert(t, lockfileLayer.Files[turbopath.AnchoredUnixPath(".npmrc")] != "")
+	assert.Equal(t, len(manifestsLayer.Files), 2)
+	assert.Equal(t, len(sourceLayer.Files), 4)
+	assert.Assert(t, outDir.UntypedJoin("Dockerfile.turbo").FileExists())
+	assert.Assert(t, outDir.UntypedJoin("docker-context.json").FileExists())
+
+	// Changing a source file only changes the source layer
+	assert.NilError(t, outDir.UntypedJoin("full", "apps", "web", "src", "index.js").WriteFile([]byte("export default 1"), 0644))
+	changed, err := writeDockerContext(repoRoot, outDir, "out", npm)
+	assert.NilError(t, err)
+	assert.Equal(t, changed.Layers[0].Digest, lockfileLayer.Digest)
+	assert.Equal(t, changed.Layers[1].Digest, manifestsLayer.Digest)
+	assert.Assert(t, changed.Layers[2].Digest != sourceLayer.Digest)
+}
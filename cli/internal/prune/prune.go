@@ -70,13 +70,15 @@ func (p *prune) prune(opts *turbostate.PrunePayload) error {
 		return errors.Wrap(err, "could not construct graph")
 	}
 	outDir := p.base.RepoRoot.UntypedJoin(opts.OutputDir)
+	// The BuildKit context adds a lockfile layer to the layout of --docker
+	docker := opts.Docker || opts.DockerBuildkitContext
 	fullDir := outDir
-	if opts.Docker {
+	if docker {
 		fullDir = fullDir.UntypedJoin("full")
 	}
 
 	p.base.Logger.Trace("scope", "value", strings.Join(opts.Scope, ", "))
-	p.base.Logger.Trace("docker", "value", opts.Docker)
+	p.base.Logger.Trace("docker", "value", docker)
 	p.base.Logger.Trace("out dir", "value", outDir.ToString())
 
 	for _, scope := range opts.Scope {
@@ -104,6 +106,15 @@ func (p *prune) prune(opts *turbostate.PrunePayload) error {
 
 	p.base.UI.Output(fmt.Sprintf("Generating pruned monorepo for %v in %v", ui.Bold(strings.Join(opts.Scope, ", ")), ui.Bold(outDir.ToString())))
 
+	if opts.DockerBuildkitContext {
+		// The layers are replaced, which must not remove the repository's own directories
+		if outDir == p.base.RepoRoot {
+			return errors.New("--docker-buildkit-context needs an --out-dir other than the repository root")
+		}
+		if err := removeDockerContextLayers(outDir); err != nil {
+			return err
+		}
+	}
 	packageJSONPath := outDir.UntypedJoin("package.json")
 	if err := packageJSONPath.EnsureDir(); err != nil {
 		return errors.Wrap(err, "could not create output directory")
@@ -116,7 +127,7 @@ func (p *prune) prune(opts *turbostate.PrunePayload) error {
 		if err := fs.CopyFile(&workspaceFile, fullDir.UntypedJoin(ctx.PackageManager.WorkspaceConfigurationPath).ToStringDuringMigration()); err != nil {
 			return errors.Wrapf(err, "could not copy %s", ctx.PackageManager.WorkspaceConfigurationPath)
 		}
-		if opts.Docker {
+		if docker {
 			if err := fs.CopyFile(&workspaceFile, outDir.UntypedJoin("json", ctx.PackageManager.WorkspaceConfigurationPath).ToStringDuringMigration()); err != nil {
 				return errors.Wrapf(err, "could not copy %s", ctx.PackageManager.WorkspaceConfigurationPath)
 			}
@@ -154,7 +165,7 @@ func (p *prune) prune(opts *turbostate.PrunePayload) error {
 		if err := fs.RecursiveCopy(ctx.WorkspaceInfos.PackageJSONs[internalDep].Dir.ToStringDuringMigration(), targetDir.ToStringDuringMigration()); err != nil {
 			return errors.Wrapf(err, "failed to copy %v into %v", internalDep, targetDir)
 		}
-		if opts.Docker {
+		if docker {
 			jsonDir := outDir.UntypedJoin("json", ctx.WorkspaceInfos.PackageJSONs[internalDep].PackageJSONPath.ToStringDuringMigration())
 			if err := jsonDir.EnsureDir(); err != nil {
 				return errors.Wrapf(err, "failed to create folder %v for %v", jsonDir, internalDep)
@@ -202,13 +213,28 @@ func (p *prune) prune(opts *turbostate.PrunePayload) error {
 		if err := fs.CopyFile(&fs.LstatCachedFile{Path: p.base.RepoRoot.UntypedJoin(".npmrc")}, fullDir.UntypedJoin(".npmrc").ToStringDuringMigration()); err != nil {
 			return errors.Wrap(err, "failed to copy root .npmrc")
 		}
-		if opts.Docker {
+		if docker {
 			if err := fs.CopyFile(&fs.LstatCachedFile{Path: p.base.RepoRoot.UntypedJoin(".npmrc")}, outDir.UntypedJoin("json/.npmrc").ToStringDuringMigration()); err != nil {
 				return errors.Wrap(err, "failed to copy root .npmrc")
 			}
 		}
 	}
 
+	for _, configPath := range ctx.PackageManager.InstallConfigPaths {
+		originalPath := p.base.RepoRoot.UntypedJoin(configPath)
+		if !originalPath.Exists() {
+			continue
+		}
+		if err := fs.RecursiveCopy(originalPath.ToString(), fullDir.UntypedJoin(configPath).ToString()); err != nil {
+			return errors.Wrapf(err, "failed to copy %s", configPath)
+		}
+		if docker {
+			if err := fs.RecursiveCopy(originalPath.ToString(), outDir.UntypedJoin("json", configPath).ToString()); err != nil {
+				return errors.Wrapf(err, "failed to copy %s", configPath)
+			}
+		}
+	}
+
 	turboJSON, err := fs.LoadTurboConfig(p.base.RepoRoot, rootPackageJSON, false)
 	if err != nil && !errors.Is(err, os.ErrNotExist) {
 		return errors.Wrap(err, "failed to read turbo.json")
@@ -291,7 +317,7 @@ func (p *prune) prune(opts *turbostate.PrunePayload) error {
 		}
 	}
 
-	if opts.Docker {
+	if docker {
 		// Copy from the package.json in the full directory so we get the pruned version if needed
 		if err := fs.CopyFile(
 			&fs.LstatCachedFile{Path: newPackageJSONPath},
@@ -301,5 +327,16 @@ func (p *prune) prune(opts *turbostate.PrunePayload) error {
 		}
 	}
 
+	if opts.DockerBuildkitContext {
+		manifest, err := writeDockerContext(p.base.RepoRoot, outDir, opts.OutputDir, ctx.PackageManager)
+		if err != nil {
+			return err
+		}
+		for _, layer := range manifest.Layers {
+			p.base.UI.Output(fmt.Sprintf(" - Layer %v: %v files, %v", ui.Bold(layer.Name), len(layer.Files), layer.Digest))
+		}
+		p.base.UI.Output(fmt.Sprintf("Copy the layers with the instructions in %v", ui.Bold(outDir.UntypedJoin(_dockerfileSnippet).ToString())))
+	}
+
 	return nil
 }
@@ -0,0 +1,286 @@
+package query
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/vercel/turbo/cli/internal/doublestar"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/util"
+	"github.com/vercel/turbo/cli/internal/workspace"
+)
+
+// Package is a workspace as returned by a query
+type Package struct {
+	Name         string   `json:"name"`
+	Path         string   `json:"path"`
+	Dependencies []string `json:"dependencies"`
+	Dependents   []string `json:"dependents"`
+	Tasks        []string `json:"tasks"`
+}
+
+// Task is a package task as returned by a query
+type Task struct {
+	TaskID  string `json:"taskId"`
+	Task    string `json:"task"`
+	Package string `json:"package"`
+	// Cached is whether turbo would cache the results of this task
+	Cached bool `json:"cached"`
+}
+
+// graph is the queryable view of the repository
+type graph struct {
+	packages map[string]*Package
+	pipeline fs.Pipeline
+}
+
+func newGraph(workspaceInfos workspace.Catalog, pipeline fs.Pipeline) *graph {
+	g := &graph{
+		packages: make(map[string]*Package),
+		pipeline: pipeline,
+	}
+	for name, pkg := range workspaceInfos.PackageJSONs {
+		if name == util.RootPkgName {
+			continue
+		}
+		tasks := make([]string, 0, len(pkg.Scripts))
+		for task := range pkg.Scripts {
+			tasks = append(tasks, task)
+		}
+		sort.Strings(tasks)
+		dependencies := make([]string, len(pkg.InternalDeps))
+		copy(dependencies, pkg.InternalDeps)
+		sort.Strings(dependencies)
+		g.packages[name] = &Package{
+			Name:         name,
+			Path:         pkg.Dir.ToUnixPath().ToString(),
+			Dependencies: dependencies,
+			Dependents:   []string{},
+			Tasks:        tasks,
+		}
+	}
+	for _, pkg := range g.packages {
+		for _, dependency := range pkg.Dependencies {
+			if dep, ok := g.packages[dependency]; ok {
+				dep.Dependents = append(dep.Dependents, pkg.Name)
+			}
+		}
+	}
+	for _, pkg := range g.packages {
+		sort.Strings(pkg.Dependents)
+	}
+	return g
+}
+
+// evaluate runs the stages of a query against the graph. The first stage selects
+// a source, and each following stage filters or projects the current results.
+func (g *graph) evaluate(stages []stage) (interface{}, error) {
+	if len(stages) == 0 {
+		return nil, fmt.Errorf("empty query")
+	}
+	source := stages[0]
+	switch source.name {
+	case "packages":
+		return g.evaluatePackages(g.allPackages(), stages[1:])
+	case "tasks":
+		return g.evaluateTasks(g.allTasks(), stages[1:])
+	case "owner":
+		if !source.hasArg {
+			return nil, fmt.Errorf("owner requires a file path, e.g. owner(packages/ui/index.ts)")
+		}
+		if len(stages) > 1 {
+			return nil, fmt.Errorf("owner(...) does not support further stages")
+		}
+		return g.owner(source.arg), nil
+	}
+	return nil, fmt.Errorf("unknown source %q, expected one of packages, tasks, owner(<file>)", source.name)
+}
+
+func (g *graph) allPackages() []*Package {
+	packages := make([]*Package, 0, len(g.packages))
+	for _, pkg := range g.packages {
+		packages = append(packages, pkg)
+	}
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].Name < packages[j].Name
+	})
+	return packages
+}
+
+func (g *graph) allTasks() []*Task {
+	var tasks []*Task
+	for _, pkg := range g.allPackages() {
+		for _, taskName := range pkg.Tasks {
+			taskID := util.GetTaskId(pkg.Name, taskName)
+			definition, ok := g.pipeline.GetTaskDefinition(taskID)
+			if !ok {
+				// Scripts that aren't in the pipeline aren't tasks turbo can run
+				continue
+			}
+			tasks = append(tasks, &Task{
+				TaskID:  taskID,
+				Task:    taskName,
+				Package: pkg.Name,
+				Cached:  definition.ShouldCache,
+			})
+		}
+	}
+	return tasks
+}
+
+func (g *graph) evaluatePackages(packages []*Package, stages []stage) (interface{}, error) {
+	for i, s := range stages {
+		if s.name == "names" {
+			if i != len(stages)-1 {
+				return nil, fmt.Errorf("names must be the last stage")
+			}
+			names := make([]string, len(packages))
+			for j, pkg := range packages {
+				names[j] = pkg.Name
+			}
+			return names, nil
+		}
+		if s.name == "count" {
+			if i != len(stages)-1 {
+				return nil, fmt.Errorf("count must be the last stage")
+			}
+			return len(packages), nil
+		}
+		predicate, err := g.packagePredicate(s)
+		if err != nil {
+			return nil, err
+		}
+		filtered := []*Package{}
+		for _, pkg := range packages {
+			if predicate(pkg) {
+				filtered = append(filtered, pkg)
+			}
+		}
+		packages = filtered
+	}
+	return packages, nil
+}
+
+func (g *graph) packagePredicate(s stage) (func(pkg *Package) bool, error) {
+	if !s.hasArg {
+		return nil, fmt.Errorf("unknown package filter %v", s)
+	}
+	switch s.name {
+	case "name":
+		return func(pkg *Package) bool { return globMatch(s.arg, pkg.Name) }, nil
+	case "path":
+		return func(pkg *Package) bool { return globMatch(s.arg, pkg.Path) }, nil
+	case "hasTask":
+		return func(pkg *Package) bool { return contains(pkg.Tasks, s.arg) }, nil
+	case "directlyDependsOn":
+		return func(pkg *Package) bool { return contains(pkg.Dependencies, s.arg) }, nil
+	case "dependsOn":
+		return func(pkg *Package) bool { return g.reachable(pkg.Name, s.arg, dependencies) }, nil
+	case "dependencyOf":
+		return func(pkg *Package) bool { return g.reachable(pkg.Name, s.arg, dependents) }, nil
+	}
+	return nil, fmt.Errorf("unknown package filter %v, expected one of name, path, hasTask, dependsOn, directlyDependsOn, dependencyOf, names, count", s)
+}
+
+func (g *graph) evaluateTasks(tasks []*Task, stages []stage) (interface{}, error) {
+	for i, s := range stages {
+		if s.name == "names" {
+			if i != len(stages)-1 {
+				return nil, fmt.Errorf("names must be the last stage")
+			}
+			names := make([]string, len(tasks))
+			for j, task := range tasks {
+				names[j] = task.TaskID
+			}
+			return names, nil
+		}
+		if s.name == "count" {
+			if i != len(stages)-1 {
+				return nil, fmt.Errorf("count must be the last stage")
+			}
+			return len(tasks), nil
+		}
+		var predicate func(task *Task) bool
+		switch {
+		case s.name == "task" && s.hasArg:
+			predicate = func(task *Task) bool { return globMatch(s.arg, task.Task) }
+		case s.name == "package" && s.hasArg:
+			predicate = func(task *Task) bool { return globMatch(s.arg, task.Package) }
+		case s.name == "cached" && !s.hasArg:
+			predicate = func(task *Task) bool { return task.Cached }
+		case s.name == "uncached" && !s.hasArg:
+			predicate = func(task *Task) bool { return !task.Cached }
+		default:
+			return nil, fmt.Errorf("unknown task filter %v, expected one of task, package, cached, uncached, names, count", s)
+		}
+		filtered := []*Task{}
+		for _, task := range tasks {
+			if predicate(task) {
+				filtered = append(filtered, task)
+			}
+		}
+		tasks = filtered
+	}
+	return tasks, nil
+}
+
+// owner returns the package that contains the given repo-relative file, or nil
+// if the file belongs to the root of the repository
+func (g *graph) owner(file string) *Package {
+	file = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(file)), "./")
+	var owner *Package
+	for _, pkg := range g.packages {
+		if file == pkg.Path || strings.HasPrefix(file, pkg.Path+"/") {
+			// Nested workspaces: the deepest match wins
+			if owner == nil || len(pkg.Path) > len(owner.Path) {
+				owner = pkg
+			}
+		}
+	}
+	return owner
+}
+
+type direction func(pkg *Package) []string
+
+func dependencies(pkg *Package) []string { return pkg.Dependencies }
+func dependents(pkg *Package) []string   { return pkg.Dependents }
+
+// reachable returns whether target can be reached from start by following edges in the given direction
+func (g *graph) reachable(start string, target string, next direction) bool {
+	visited := make(util.Set)
+	queue := []string{start}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		pkg, ok := g.packages[current]
+		if !ok {
+			continue
+		}
+		for _, neighbor := range next(pkg) {
+			if neighbor == target {
+				return true
+			}
+			if !visited.Includes(neighbor) {
+				visited.Add(neighbor)
+				queue = append(queue, neighbor)
+			}
+		}
+	}
+	return false
+}
+
+func globMatch(pattern string, value string) bool {
+	matched, err := doublestar.Match(pattern, value)
+	return err == nil && matched
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
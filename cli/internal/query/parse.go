@@ -0,0 +1,76 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// stage is a single step of a query, e.g. `hasTask(test)`
+type stage struct {
+	name string
+	// arg is empty for stages that don't take an argument
+	arg    string
+	hasArg bool
+}
+
+func (s stage) String() string {
+	if s.hasArg {
+		return fmt.Sprintf("%v(%v)", s.name, s.arg)
+	}
+	return s.name
+}
+
+var _stageRegex = regexp.MustCompile(`^([A-Za-z]+)\s*(?:\(\s*(?:"([^"]*)"|'([^']*)'|([^)"'\s]*))\s*\))?$`)
+
+// parse splits a query into its stages. Stages are separated by `|`, which may
+// appear inside of quoted arguments.
+func parse(query string) ([]stage, error) {
+	var parts []string
+	var current strings.Builder
+	var quote rune
+	for _, r := range query {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			current.WriteRune(r)
+		case r == '|':
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in query %q", query)
+	}
+	parts = append(parts, current.String())
+
+	stages := make([]stage, 0, len(parts))
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, fmt.Errorf("empty stage in query %q", query)
+		}
+		match := _stageRegex.FindStringSubmatch(part)
+		if match == nil {
+			return nil, fmt.Errorf("invalid stage %q, expected <name> or <name>(<argument>)", part)
+		}
+		s := stage{
+			name:   match[1],
+			hasArg: strings.Contains(part, "("),
+		}
+		for _, arg := range match[2:] {
+			if arg != "" {
+				s.arg = arg
+			}
+		}
+		stages = append(stages, s)
+	}
+	return stages, nil
+}
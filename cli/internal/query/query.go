@@ -0,0 +1,130 @@
+// Package query implements `turbo query`, a small pipeline language for querying
+// the packages, tasks, and file ownership of a monorepo. Results are printed as JSON
+// so that they can be used in scripts that enforce monorepo policies.
+//
+// A query is a source followed by any number of stages, separated by `|`:
+//
+//	packages | dependsOn(@acme/ui) | hasTask(test) | names
+//	tasks | package(@acme/*) | uncached
+//	owner(packages/ui/src/button.tsx)
+//
+// `turbo query filter <expr>` prints the packages that a --filter expression
+// selects instead, for debugging filters.
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/context"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/scm"
+	"github.com/vercel/turbo/cli/internal/scope"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+)
+
+// _filterCommand is the subcommand that resolves a --filter expression
+const _filterCommand = "filter"
+
+// ExecuteQuery executes the `query` command.
+func ExecuteQuery(helper *cmdutil.Helper, args *turbostate.ParsedArgsFromRust) error {
+	base, err := helper.GetCmdBase(args)
+	if err != nil {
+		return err
+	}
+	opts := args.Command.Query
+	if opts.Command == _filterCommand {
+		err = executeFilter(base, opts.Filter)
+	} else {
+		err = executeQuery(base, opts.Query)
+	}
+	if err != nil {
+		base.LogError("query failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+func executeQuery(base *cmdutil.CmdBase, query string) error {
+	stages, err := parse(query)
+	if err != nil {
+		return err
+	}
+
+	rootPackageJSON, err := fs.ReadPackageJSON(base.RepoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		return fmt.Errorf("failed to read package.json: %w", err)
+	}
+	pkgDepGraph, err := context.BuildPackageGraph(base.RepoRoot, rootPackageJSON)
+	if err != nil {
+		var warnings *context.Warnings
+		if !errors.As(err, &warnings) {
+			return err
+		}
+		base.LogWarning("Issues occurred when constructing package graph. Turbo will function, but some features may not be available", err)
+	}
+	turboJSON, err := fs.LoadTurboConfig(base.RepoRoot, rootPackageJSON, false)
+	if err != nil {
+		return err
+	}
+
+	g := newGraph(pkgDepGraph.WorkspaceInfos, turboJSON.Pipeline)
+	result, err := g.evaluate(stages)
+	if err != nil {
+		return err
+	}
+	rendered, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	base.UI.Output(string(rendered))
+	return nil
+}
+
+// executeFilter prints the names of the packages that a filter expression
+// selects, exactly as `turbo run --filter` would resolve it
+func executeFilter(base *cmdutil.CmdBase, filter string) error {
+	if filter == "" {
+		return fmt.Errorf("empty filter")
+	}
+	rootPackageJSON, err := fs.ReadPackageJSON(base.RepoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		return fmt.Errorf("failed to read package.json: %w", err)
+	}
+	pkgDepGraph, err := context.BuildPackageGraph(base.RepoRoot, rootPackageJSON)
+	if err != nil {
+		var warnings *context.Warnings
+		if !errors.As(err, &warnings) {
+			return err
+		}
+		base.LogWarning("Issues occurred when constructing package graph. Turbo will function, but some features may not be available", err)
+	}
+
+	scmInstance, err := scm.FromInRepo(base.RepoRoot)
+	if err != nil {
+		if errors.Is(err, scm.ErrFallback) {
+			base.Logger.Debug("falling back to manual file hashing", "reason", err)
+		} else {
+			return errors.Wrap(err, "failed to create SCM")
+		}
+	}
+	scopeOpts := &scope.Opts{
+		FilterPatterns: []string{filter},
+	}
+	filteredPkgs, _, err := scope.ResolvePackages(scopeOpts, base.RepoRoot, scmInstance, pkgDepGraph, base.UI, base.Logger)
+	if err != nil {
+		return errors.Wrap(err, "failed to resolve packages")
+	}
+
+	names := filteredPkgs.UnsafeListOfStrings()
+	sort.Strings(names)
+	rendered, err := json.MarshalIndent(names, "", "  ")
+	if err != nil {
+		return err
+	}
+	base.UI.Output(string(rendered))
+	return nil
+}
@@ -0,0 +1,199 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/workspace"
+	"gotest.tools/v3/assert"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name    string
+		query   string
+		want    []stage
+		wantErr bool
+	}{
+		{
+			name:  "source only",
+			query: "packages",
+			want:  []stage{{name: "packages"}},
+		},
+		{
+			name:  "stages with arguments",
+			query: "packages | dependsOn(@acme/ui) | hasTask('test') | names",
+			want: []stage{
+				{name: "packages"},
+				{name: "dependsOn", arg: "@acme/ui", hasArg: true},
+				{name: "hasTask", arg: "test", hasArg: true},
+				{name: "names"},
+			},
+		},
+		{
+			name:  "pipe inside quotes",
+			query: `packages | name("a|b")`,
+			want: []stage{
+				{name: "packages"},
+				{name: "name", arg: "a|b", hasArg: true},
+			},
+		},
+		{
+			name:    "empty stage",
+			query:   "packages || names",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated quote",
+			query:   `packages | name("web)`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid stage",
+			query:   "packages | has task",
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parse(tc.query)
+			if tc.wantErr {
+				assert.Assert(t, err != nil, "expected an error parsing %v", tc.query)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, got, tc.want, cmp.AllowUnexported(stage{}))
+		})
+	}
+}
+
+func testGraph() *graph {
+	packageJSONs := map[string]*fs.PackageJSON{
+		"//": {Name: "root"},
+		"web": {
+			Name:         "web",
+			Dir:          turbopath.AnchoredUnixPath("apps/web").ToSystemPath(),
+			Scripts:      map[string]string{"build": "next build", "test": "jest", "dev": "next dev"},
+			InternalDeps: []string{"ui"},
+		},
+		"ui": {
+			Name:         "ui",
+			Dir:          turbopath.AnchoredUnixPath("packages/ui").ToSystemPath(),
+			Scripts:      map[string]string{"build": "tsc", "lint": "eslint ."},
+			InternalDeps: []string{"config"},
+		},
+		"config": {
+			Name: "config",
+			Dir:  turbopath.AnchoredUnixPath("packages/config").ToSystemPath(),
+		},
+	}
+	pipeline := fs.Pipeline{
+		"build": fs.BookkeepingTaskDefinition{TaskDefinition: fs.TaskDefinition{ShouldCache: true}},
+		"test":  fs.BookkeepingTaskDefinition{TaskDefinition: fs.TaskDefinition{ShouldCache: true}},
+		"dev":   fs.BookkeepingTaskDefinition{TaskDefinition: fs.TaskDefinition{ShouldCache: false}},
+	}
+	return newGraph(workspace.Catalog{PackageJSONs: packageJSONs}, pipeline)
+}
+
+func TestEvaluate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		query   string
+		want    interface{}
+		wantErr bool
+	}{
+		{
+			name:  "all package names",
+			query: "packages | names",
+			want:  []string{"config", "ui", "web"},
+		},
+		{
+			name:  "transitive dependents",
+			query: "packages | dependsOn(config) | names",
+			want:  []string{"ui", "web"},
+		},
+		{
+			name:  "direct dependents",
+			query: "packages | directlyDependsOn(config) | names",
+			want:  []string{"ui"},
+		},
+		{
+			name:  "dependencies",
+			query: "packages | dependencyOf(web) | names",
+			want:  []string{"config", "ui"},
+		},
+		{
+			name:  "has task",
+			query: "packages | hasTask(build) | path(packages/*) | names",
+			want:  []string{"ui"},
+		},
+		{
+			name:  "count",
+			query: "packages | name(*) | count",
+			want:  3,
+		},
+		{
+			name:  "pipeline tasks only",
+			query: "tasks | names",
+			want:  []string{"ui#build", "web#build", "web#dev", "web#test"},
+		},
+		{
+			name:  "uncached tasks",
+			query: "tasks | package(w*) | uncached | names",
+			want:  []string{"web#dev"},
+		},
+		{
+			name:    "names must be last",
+			query:   "packages | names | hasTask(build)",
+			wantErr: true,
+		},
+		{
+			name:    "unknown source",
+			query:   "workspaces",
+			wantErr: true,
+		},
+		{
+			name:    "unknown filter",
+			query:   "tasks | hasTask(build)",
+			wantErr: true,
+		},
+	}
+	g := testGraph()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stages, err := parse(tc.query)
+			assert.NilError(t, err)
+			got, err := g.evaluate(stages)
+			if tc.wantErr {
+				assert.Assert(t, err != nil, "expected an error evaluating %v", tc.query)
+				return
+			}
+			assert.NilError(t, err)
+			assert.DeepEqual(t, got, tc.want)
+		})
+	}
+}
+
+func TestOwner(t *testing.T) {
+	g := testGraph()
+	testCases := []struct {
+		file string
+		want string
+	}{
+		{file: "packages/ui/src/button.tsx", want: "ui"},
+		{file: "./apps/web/package.json", want: "web"},
+		{file: "packages/ui-kit/index.ts", want: ""},
+		{file: "turbo.json", want: ""},
+	}
+	for _, tc := range testCases {
+		owner := g.owner(tc.file)
+		if tc.want == "" {
+			assert.Assert(t, owner == nil, "expected %v to have no owner", tc.file)
+			continue
+		}
+		assert.Assert(t, owner != nil, "expected %v to be owned by %v", tc.file, tc.want)
+		assert.Equal(t, owner.Name, tc.want)
+	}
+}
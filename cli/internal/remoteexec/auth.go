@@ -0,0 +1,109 @@
+package remoteexec
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TokenEnvVar holds the token that workers require and `turbo run` sends
+const TokenEnvVar = "TURBO_WORKER_TOKEN"
+
+const _bearerPrefix = "Bearer "
+
+// tokenCredentials attaches the worker token to every request
+type tokenCredentials string
+
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": _bearerPrefix + string(t)}, nil
+}
+
+// RequireTransportSecurity returns false so that workers on the same machine
+// can be reached without TLS. Workers refuse to listen on other addresses
+// without it.
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// authInterceptor rejects requests that don't carry the given token
+func authInterceptor(token string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		authorized := false
+		for _, value := range md.Get("authorization") {
+			if strings.HasPrefix(value, _bearerPrefix) && subtle.ConstantTimeCompare([]byte(value[len(_bearerPrefix):]), []byte(token)) == 1 {
+				authorized = true
+			}
+		}
+		if !authorized {
+			return nil, status.Error(codes.Unauthenticated, "invalid worker token")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// NewServer returns a gRPC server for the given worker that requires token.
+// If tlsConfig is nil, the server accepts plaintext connections.
+func NewServer(worker *Worker, token string, tlsConfig *tls.Config) *grpc.Server {
+	opts := []grpc.ServerOption{grpc.UnaryInterceptor(authInterceptor(token))}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	s := grpc.NewServer(opts...)
+	RegisterWorkerServer(s, worker)
+	return s
+}
+
+// ServerTLSConfig loads the certificate and key that a worker serves with
+func ServerTLSConfig(certFile string, keyFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// ClientTLSConfig trusts the certificate authority in caFile when connecting
+// to workers
+func ClientTLSConfig(caFile string) (*tls.Config, error) {
+	contents, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(contents) {
+		return nil, fmt.Errorf("no certificates found in %v", caFile)
+	}
+	return &tls.Config{
+		RootCAs:    pool,
+		MinVersion: tls.VersionTLS12,
+	}, nil
+}
+
+// isLoopback returns true if address, a host:port pair, only accepts
+// connections from the same machine
+func isLoopback(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
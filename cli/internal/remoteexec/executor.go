@@ -0,0 +1,80 @@
+package remoteexec
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// Executor dispatches tasks to a pool of workers
+type Executor struct {
+	conns   []*grpc.ClientConn
+	clients []*workerClient
+	next    uint32
+}
+
+// NewExecutor connects to the workers at the given addresses, authenticating
+// with token. Connections use TLS unless tlsConfig is nil, which is only
+// allowed for workers on loopback addresses. They are established lazily, so
+// an unreachable worker surfaces as an error from Execute.
+func NewExecutor(addresses []string, token string, tlsConfig *tls.Config) (*Executor, error) {
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("at least one worker address is required")
+	}
+	if token == "" {
+		return nil, fmt.Errorf("%v must be set to the token of the workers", TokenEnvVar)
+	}
+	transportCredentials := insecure.NewCredentials()
+	if tlsConfig != nil {
+		transportCredentials = credentials.NewTLS(tlsConfig)
+	} else {
+		// Like CheckListenAddress on the worker side, don't send the token
+		// and the task logs to other machines over plaintext
+		for _, address := range addresses {
+			if !isLoopback(address) {
+				return nil, fmt.Errorf("workers can only be reached at %v without TLS if it is a loopback address. Pass --experimental-remote-workers-ca", address)
+			}
+		}
+	}
+	e := &Executor{}
+	for _, address := range addresses {
+		conn, err := grpc.Dial(
+			address,
+			grpc.WithTransportCredentials(transportCredentials),
+			grpc.WithPerRPCCredentials(tokenCredentials(token)),
+		)
+		if err != nil {
+			_ = e.Close()
+			return nil, fmt.Errorf("failed to connect to worker %v: %w", address, err)
+		}
+		e.conns = append(e.conns, conn)
+		e.clients = append(e.clients, &workerClient{conn: conn})
+	}
+	return e, nil
+}
+
+// Execute runs the task on the next worker in the pool
+func (e *Executor) Execute(ctx context.Context, req *ExecuteRequest) (*ExecuteResponse, error) {
+	index := atomic.AddUint32(&e.next, 1) % uint32(len(e.clients))
+	resp, err := e.clients[index].Execute(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("worker %v: %w", e.conns[index].Target(), err)
+	}
+	return resp, nil
+}
+
+// Close closes the connections to all of the workers
+func (e *Executor) Close() error {
+	var firstErr error
+	for _, conn := range e.conns {
+		if err := conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
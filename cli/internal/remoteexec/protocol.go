@@ -0,0 +1,119 @@
+// Package remoteexec implements experimental distributed execution of tasks.
+//
+// `turbo worker` starts an agent that accepts tasks over gRPC, runs them in its
+// own checkout of the repository, and writes their outputs to the cache. Workers
+// must share a remote cache with the machine running `turbo run`, which restores
+// the outputs of remotely executed tasks exactly as it would a cache hit.
+//
+// Requests must carry the worker's token, and only name a task: the worker
+// looks up its script in its own checkout and recomputes its hash, refusing
+// tasks whose hash differs from the one `turbo run` computed. That keeps a
+// worker with a different checkout or environment from writing outputs to the
+// cache under a hash that doesn't describe them.
+package remoteexec
+
+import (
+	"context"
+	"encoding/json"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+// The worker protocol only has a single method, so rather than generating
+// protobuf bindings for it we describe the service by hand and send JSON.
+
+const (
+	_serviceName   = "turbo.remoteexec.Worker"
+	_executeMethod = "/" + _serviceName + "/Execute"
+	_codecName     = "json"
+)
+
+// ExecuteRequest describes a task for a worker to run
+type ExecuteRequest struct {
+	// TaskID identifies the task, such as web#build
+	TaskID string `json:"taskId"`
+	// Hash is the hash of the task computed by `turbo run`
+	Hash string `json:"hash"`
+	// Args are passed through to the task's script
+	Args []string `json:"args"`
+}
+
+// ExecuteResponse is the result of running a task on a worker
+type ExecuteResponse struct {
+	ExitCode int    `json:"exitCode"`
+	Logs     []byte `json:"logs"`
+	// Duration is how long the task took to run, in milliseconds
+	Duration int `json:"duration"`
+}
+
+// WorkerServer is the server side of the worker protocol
+type WorkerServer interface {
+	Execute(ctx context.Context, req *ExecuteRequest) (*ExecuteResponse, error)
+}
+
+// RegisterWorkerServer registers the given implementation with a gRPC server
+func RegisterWorkerServer(registrar grpc.ServiceRegistrar, server WorkerServer) {
+	registrar.RegisterService(&_workerServiceDesc, server)
+}
+
+var _workerServiceDesc = grpc.ServiceDesc{
+	ServiceName: _serviceName,
+	HandlerType: (*WorkerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Execute",
+			Handler:    executeHandler,
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+func executeHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := &ExecuteRequest{}
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WorkerServer).Execute(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: _executeMethod,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WorkerServer).Execute(ctx, req.(*ExecuteRequest))
+	}
+	return interceptor(ctx, req, info, handler)
+}
+
+// workerClient is the client side of the worker protocol
+type workerClient struct {
+	conn grpc.ClientConnInterface
+}
+
+func (c *workerClient) Execute(ctx context.Context, req *ExecuteRequest) (*ExecuteResponse, error) {
+	resp := &ExecuteResponse{}
+	if err := c.conn.Invoke(ctx, _executeMethod, req, resp, grpc.CallContentSubtype(_codecName)); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return _codecName
+}
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
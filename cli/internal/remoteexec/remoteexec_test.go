@@ -0,0 +1,251 @@
+package remoteexec
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gotest.tools/v3/assert"
+)
+
+type testCache struct {
+	puts map[string][]turbopath.AnchoredSystemPath
+}
+
+func (tc *testCache) Fetch(anchor turbopath.AbsoluteSystemPath, hash string, files []string) (bool, []turbopath.AnchoredSystemPath, int, error) {
+	return false, nil, 0, nil
+}
+
+func (tc *testCache) Exists(hash string) cache.ItemStatus {
+	return cache.ItemStatus{}
+}
+
+func (tc *testCache) Put(anchor turbopath.AbsoluteSystemPath, hash string, duration int, files []turbopath.AnchoredSystemPath) error {
+	tc.puts[hash] = files
+	return nil
+}
+
+func (tc *testCache) Prefetch(hashes []string)                  {}
+func (tc *testCache) Clean(anchor turbopath.AbsoluteSystemPath) {}
+func (tc *testCache) CleanAll()                                 {}
+func (tc *testCache) Shutdown()                                 {}
+
+const _testToken = "secret"
+
+// testTasks resolves tasks from a fixed set, ignoring passed through args
+type testTasks map[string]*Task
+
+func (tt testTasks) resolve(ctx context.Context, taskID string, args []string) (*Task, error) {
+	task, ok := tt[taskID]
+	if !ok {
+		return nil, fmt.Errorf("unknown task %v", taskID)
+	}
+	return task, nil
+}
+
+func startWorker(t *testing.T, repoRoot turbopath.AbsoluteSystemPath, turboCache cache.Cache, tasks testTasks) *Executor {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NilError(t, err)
+	s := NewServer(NewWorker(repoRoot, turboCache, tasks.resolve, hclog.Default()), _testToken, nil)
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+
+	return newExecutor(t, lis.Addr().String(), _testToken)
+}
+
+func newExecutor(t *testing.T, address string, token string) *Executor {
+	t.Helper()
+	executor, err := NewExecutor([]string{address}, token, nil)
+	assert.NilError(t, err)
+	t.Cleanup(func() { _ = executor.Close() })
+	return executor
+}
+
+func TestExecute(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("tasks in this test are written for sh")
+	}
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	assert.NilError(t, repoRoot.UntypedJoin("packages", "ui").MkdirAll(0755))
+	turboCache := &testCache{puts: make(map[string][]turbopath.AnchoredSystemPath)}
+	executor := startWorker(t, repoRoot, turboCache, testTasks{
+		"ui#build": {
+			Hash:             "abc123",
+			Dir:              "packages/ui",
+			Command:          "sh",
+			Args:             []string{"-c", "mkdir -p dist && echo $TURBO_HASH > dist/out.txt && echo built"},
+			LogFile:          "packages/ui/.turbo/turbo-build.log",
+			OutputInclusions: []string{"packages/ui/.turbo/turbo-build.log", "packages/ui/dist/**"},
+		},
+		"ui#test": {
+			Hash:    "def456",
+			Dir:     "packages/ui",
+			Command: "sh",
+			Args:    []string{"-c", "echo failing && exit 3"},
+			LogFile: "packages/ui/.turbo/turbo-test.log",
+		},
+	})
+
+	resp, err := executor.Execute(context.Background(), &ExecuteRequest{TaskID: "ui#build", Hash: "abc123"})
+	assert.NilError(t, err)
+	assert.Equal(t, resp.ExitCode, 0)
+	assert.Equal(t, strings.TrimSpace(string(resp.Logs)), "built")
+
+	logs, err := repoRoot.UntypedJoin("packages", "ui", ".turbo", "turbo-build.log").ReadFile()
+	assert.NilError(t, err)
+	assert.Equal(t, strings.TrimSpace(string(logs)), "built")
+	out, err := repoRoot.UntypedJoin("packages", "ui", "dist", "out.txt").ReadFile()
+	assert.NilError(t, err)
+	assert.Equal(t, strings.TrimSpace(string(out)), "abc123")
+
+	cached := []string{}
+	for _, file := range turboCache.puts["abc123"] {
+		cached = append(cached, file.ToUnixPath().ToString())
+	}
+	sort.Strings(cached)
+	assert.DeepEqual(t, cached, []string{"packages/ui/.turbo/turbo-build.log", "packages/ui/dist/out.txt"})
+
+	resp, err = executor.Execute(context.Background(), &ExecuteRequest{TaskID: "ui#test", Hash: "def456"})
+	assert.NilError(t, err)
+	assert.Equal(t, resp.ExitCode, 3)
+	assert.Equal(t, strings.TrimSpace(string(resp.Logs)), "failing")
+	_, ok := turboCache.puts["def456"]
+	assert.Assert(t, !ok, "failed tasks should not be cached")
+}
+
+func TestExecuteRejectsRequests(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	task := func(dir string, outputs ...string) *Task {
+		return &Task{
+			Hash:             "abc123",
+			Dir:              dir,
+			Command:          "true",
+			LogFile:          "packages/ui/.turbo/turbo-build.log",
+			OutputInclusions: outputs,
+		}
+	}
+	tasks := testTasks{
+		"ui#build":      task("packages/ui"),
+		"parent#build":  task("../elsewhere"),
+		"abs#build":     task("/tmp"),
+		"nested#build":  task("packages/../../elsewhere"),
+		"outputs#build": task("packages/ui", "packages/ui/dist/**", "../**"),
+	}
+	executor := startWorker(t, repoRoot, &testCache{puts: make(map[string][]turbopath.AnchoredSystemPath)}, tasks)
+	testCases := []struct {
+		name     string
+		executor *Executor
+		req      *ExecuteRequest
+		want     codes.Code
+	}{
+		{
+			name:     "wrong token",
+			executor: newExecutor(t, executor.conns[0].Target(), "wrong"),
+			req:      &ExecuteRequest{TaskID: "ui#build", Hash: "abc123"},
+			want:     codes.Unauthenticated,
+		},
+		{
+			name:     "hash mismatch",
+			executor: executor,
+			req:      &ExecuteRequest{TaskID: "ui#build", Hash: "def456"},
+			want:     codes.FailedPrecondition,
+		},
+		{
+			name:     "unknown task",
+			executor: executor,
+			req:      &ExecuteRequest{TaskID: "web#build", Hash: "abc123"},
+			want:     codes.FailedPrecondition,
+		},
+		{
+			name:     "dir in parent directory",
+			executor: executor,
+			req:      &ExecuteRequest{TaskID: "parent#build", Hash: "abc123"},
+			want:     codes.InvalidArgument,
+		},
+		{
+			name:     "absolute dir",
+			executor: executor,
+			req:      &ExecuteRequest{TaskID: "abs#build", Hash: "abc123"},
+			want:     codes.InvalidArgument,
+		},
+		{
+			name:     "dir escaping through a package",
+			executor: executor,
+			req:      &ExecuteRequest{TaskID: "nested#build", Hash: "abc123"},
+			want:     codes.InvalidArgument,
+		},
+		{
+			name:     "output glob outside of the repository",
+			executor: executor,
+			req:      &ExecuteRequest{TaskID: "outputs#build", Hash: "abc123"},
+			want:     codes.InvalidArgument,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.executor.Execute(context.Background(), tc.req)
+			assert.Equal(t, status.Code(errors.Unwrap(err)), tc.want, err)
+		})
+	}
+}
+
+func TestNewExecutorRequiresToken(t *testing.T) {
+	_, err := NewExecutor([]string{"127.0.0.1:9876"}, "", nil)
+	assert.ErrorContains(t, err, TokenEnvVar)
+}
+
+func TestCheckListenAddress(t *testing.T) {
+	testCases := []struct {
+		address string
+		tls     bool
+		wantErr bool
+	}{
+		{address: "127.0.0.1:9876"},
+		{address: "localhost:9876"},
+		{address: "[::1]:9876"},
+		{address: "0.0.0.0:9876", wantErr: true},
+		{address: ":9876", wantErr: true},
+		{address: "10.0.0.5:9876", wantErr: true},
+		{address: "0.0.0.0:9876", tls: true},
+		{address: ":9876", tls: true},
+	}
+	for _, tc := range testCases {
+		err := CheckListenAddress(tc.address, tc.tls)
+		assert.Equal(t, err != nil, tc.wantErr, "%v (tls: %v)", tc.address, tc.tls)
+	}
+}
+
+func TestNewExecutorRequiresTLSForRemoteWorkers(t *testing.T) {
+	testCases := []struct {
+		addresses []string
+		tlsConfig *tls.Config
+		wantErr   bool
+	}{
+		{addresses: []string{"127.0.0.1:9876", "localhost:9877"}},
+		{addresses: []string{"127.0.0.1:9876", "10.0.0.5:9876"}, wantErr: true},
+		{addresses: []string{"worker.internal:9876"}, wantErr: true},
+		{addresses: []string{"10.0.0.5:9876"}, tlsConfig: &tls.Config{MinVersion: tls.VersionTLS12}},
+	}
+	for _, tc := range testCases {
+		executor, err := NewExecutor(tc.addresses, "secret", tc.tlsConfig)
+		assert.Equal(t, err != nil, tc.wantErr, "%v (tls: %v)", tc.addresses, tc.tlsConfig != nil)
+		if err == nil {
+			assert.NilError(t, executor.Close())
+		} else {
+			assert.ErrorContains(t, err, "without TLS")
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package remoteexec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/globby"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Task describes how a task runs in a worker's checkout
+type Task struct {
+	// Hash is the hash of the task, computed the same way as `turbo run` does
+	Hash string
+	// Dir is the repo-relative unix path of the directory the task runs in:
+	// its package, or the "cwd" of its definition
+	Dir     string
+	Command string
+	Args    []string
+	// LogFile is the repo-relative unix path the task's logs are written to
+	LogFile string
+	// OutputInclusions and OutputExclusions are repo-relative unix globs for the
+	// files that are cached once the task succeeds
+	OutputInclusions []string
+	OutputExclusions []string
+	// Env are the KEY=value pairs set for the command, from "setEnv" in turbo.json
+	Env []string
+}
+
+// TaskResolver looks up a task, with the given arguments passed through to its
+// script, in the worker's checkout
+type TaskResolver func(ctx context.Context, taskID string, args []string) (*Task, error)
+
+// Worker runs tasks on behalf of `turbo run` and caches their outputs
+type Worker struct {
+	repoRoot    turbopath.AbsoluteSystemPath
+	cache       cache.Cache
+	resolveTask TaskResolver
+	logger      hclog.Logger
+}
+
+var _ WorkerServer = (*Worker)(nil)
+
+// NewWorker returns a Worker that runs tasks in the given checkout
+func NewWorker(repoRoot turbopath.AbsoluteSystemPath, turboCache cache.Cache, resolveTask TaskResolver, logger hclog.Logger) *Worker {
+	return &Worker{
+		repoRoot:    repoRoot,
+		cache:       turboCache,
+		resolveTask: resolveTask,
+		logger:      logger,
+	}
+}
+
+// Execute runs the requested task. A task that exits with a non-zero exit code is
+// not an error, it is reported via ExitCode.
+func (w *Worker) Execute(ctx context.Context, req *ExecuteRequest) (*ExecuteResponse, error) {
+	task, err := w.resolveTask(ctx, req.TaskID, req.Args)
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "failed to resolve %v: %v", req.TaskID, err)
+	}
+	if task.Hash != req.Hash {
+		return nil, status.Errorf(codes.FailedPrecondition, "%v has hash %v in the worker's checkout, not %v. The worker's checkout and environment must match the machine running `turbo run`", req.TaskID, task.Hash, req.Hash)
+	}
+	if err := task.validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	w.logger.Debug("executing task", "task", req.TaskID, "hash", task.Hash)
+
+	start := time.Now()
+	cmd := exec.CommandContext(ctx, task.Command, task.Args...)
+	cmd.Dir = turbopath.AnchoredUnixPath(task.Dir).ToSystemPath().RestoreAnchor(w.repoRoot).ToString()
+	cmd.Env = append(os.Environ(), fmt.Sprintf("TURBO_HASH=%v", task.Hash))
+	cmd.Env = append(cmd.Env, task.Env...)
+	logs, err := cmd.CombinedOutput()
+	duration := int(time.Since(start).Milliseconds())
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, status.Errorf(codes.Internal, "failed to run %v: %v", req.TaskID, err)
+		}
+		w.logger.Debug("task failed", "task", req.TaskID, "exitCode", exitErr.ExitCode())
+		return &ExecuteResponse{
+			ExitCode: exitErr.ExitCode(),
+			Logs:     logs,
+			Duration: duration,
+		}, nil
+	}
+
+	if err := w.saveOutputs(task, logs, duration); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to cache outputs of %v: %v", req.TaskID, err)
+	}
+	w.logger.Debug("task complete", "task", req.TaskID, "duration", duration)
+	return &ExecuteResponse{
+		ExitCode: 0,
+		Logs:     logs,
+		Duration: duration,
+	}, nil
+}
+
+// validate checks that the task only touches files inside of the repository
+func (t *Task) validate() error {
+	paths := []string{t.Dir, t.LogFile}
+	paths = append(paths, t.OutputInclusions...)
+	paths = append(paths, t.OutputExclusions...)
+	for _, repoRelative := range paths {
+		if !isRepoRelative(repoRelative) {
+			return fmt.Errorf("%v is not a path inside of the repository", repoRelative)
+		}
+	}
+	return nil
+}
+
+// saveOutputs writes the task's log file and puts its outputs into the cache, the
+// same way that a local run of the task would.
+func (w *Worker) saveOutputs(task *Task, logs []byte, duration int) error {
+	logFile := turbopath.AnchoredUnixPath(task.LogFile).ToSystemPath().RestoreAnchor(w.repoRoot)
+	if err := logFile.EnsureDir(); err != nil {
+		return err
+	}
+	if err := logFile.WriteFile(logs, 0644); err != nil {
+		return err
+	}
+
+	inclusions := make([]string, len(task.OutputInclusions))
+	for i, glob := range task.OutputInclusions {
+		inclusions[i] = filepath.FromSlash(glob)
+	}
+	exclusions := make([]string, len(task.OutputExclusions))
+	for i, glob := range task.OutputExclusions {
+		exclusions[i] = filepath.FromSlash(glob)
+	}
+	files, err := globby.GlobAll(w.repoRoot.ToStringDuringMigration(), inclusions, exclusions)
+	if err != nil {
+		return err
+	}
+	relativePaths := make([]turbopath.AnchoredSystemPath, len(files))
+	for i, file := range files {
+		relativePath, err := w.repoRoot.RelativePathString(file)
+		if err != nil {
+			return err
+		}
+		relativePaths[i] = fs.UnsafeToAnchoredSystemPath(relativePath)
+	}
+	return w.cache.Put(w.repoRoot, task.Hash, duration, relativePaths)
+}
+
+func isRepoRelative(unixPath string) bool {
+	cleaned := path.Clean(unixPath)
+	return !path.IsAbs(cleaned) && cleaned != ".." && !strings.HasPrefix(cleaned, "../")
+}
+
+// CheckListenAddress refuses to accept tasks from other machines over
+// plaintext, which would expose the token and the task logs
+func CheckListenAddress(address string, tls bool) error {
+	if !tls && !isLoopback(address) {
+		return fmt.Errorf("workers can only listen on %v without TLS if it is a loopback address. Pass --tls-cert and --tls-key, or listen on 127.0.0.1", address)
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+// Package run implements `turbo run`
+// This file implements `turbo bench`, which runs the same tasks repeatedly
+// and reports statistics about how long they took
+package run
+
+import (
+	gocontext "context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/runsummary"
+	"github.com/vercel/turbo/cli/internal/signals"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+	"github.com/vercel/turbo/cli/internal/ui"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// benchIteration is the outcome of a single run of the benchmarked tasks
+type benchIteration struct {
+	duration time.Duration
+	// tasks maps a task ID to how that task was executed
+	tasks map[string]*runsummary.TaskExecutionSummary
+}
+
+// durationStats summarizes a set of durations
+type durationStats struct {
+	mean   time.Duration
+	median time.Duration
+	p95    time.Duration
+}
+
+// ExecuteBench executes the `bench` command.
+func ExecuteBench(ctx gocontext.Context, helper *cmdutil.Helper, signalWatcher *signals.Watcher, args *turbostate.ParsedArgsFromRust) error {
+	base, err := helper.GetCmdBase(args)
+	if err != nil {
+		return err
+	}
+	benchPayload := args.Command.Bench
+	if len(benchPayload.Tasks) == 0 {
+		return errors.New("at least one task must be specified")
+	}
+	if benchPayload.Iterations < 1 {
+		return errors.New("--iterations must be at least 1")
+	}
+	if benchPayload.DryRun != "" || benchPayload.Graph != nil {
+		return errors.New("--dry-run and --graph cannot be used with bench")
+	}
+
+	// Each iteration gets freshly parsed options, since a run modifies its options
+	runArgs := *args
+	runArgs.Command = turbostate.Command{Run: &benchPayload.RunPayload}
+	iterations := []*benchIteration{}
+	for i := 0; i < benchPayload.Iterations; i++ {
+		opts, err := optsFromArgs(&runArgs)
+		if err != nil {
+			return err
+		}
+		opts.runOpts.passThroughArgs = benchPayload.PassThroughArgs
+		if benchPayload.ClearCache {
+			// Only the local cache can be cleared, so skip the remote cache entirely
+			opts.cacheOpts.SkipRemote = true
+			if err := clearLocalCache(base.RepoRoot, opts.cacheOpts); err != nil {
+				return errors.Wrap(err, "failed to clear the local cache")
+			}
+		}
+
+		base.UI.Output(util.Sprintf("${BOLD}Iteration %v of %v${RESET}", i+1, benchPayload.Iterations))
+		r := configureRun(base, opts, signalWatcher)
+		start := time.Now()
+		if err := r.run(ctx, benchPayload.Tasks); err != nil {
+			base.LogError("bench failed: iteration %v failed: %v", i+1, err)
+			return err
+		}
+		iterations = append(iterations, newBenchIteration(time.Since(start), r.summary))
+		base.UI.Output("")
+	}
+
+	printBenchResults(base, iterations)
+	return nil
+}
+
+func newBenchIteration(duration time.Duration, summary *runsummary.RunSummary) *benchIteration {
+	iteration := &benchIteration{
+		duration: duration,
+		tasks:    make(map[string]*runsummary.TaskExecutionSummary),
+	}
+	if summary == nil {
+		return iteration
+	}
+	for _, task := range summary.Tasks {
+		if task.Execution != nil && task.Execution.Status != runsummary.TaskStatusSkipped && task.Execution.Status != runsummary.TaskStatusCanceled {
+			iteration.tasks[task.TaskID] = task.Execution
+		}
+	}
+	return iteration
+}
+
+// clearLocalCache removes the entire local cache directory, including the
+// artifacts of tasks that aren't part of the benchmark
+func clearLocalCache(repoRoot turbopath.AbsoluteSystemPath, cacheOpts cache.Opts) error {
+	return cacheOpts.ResolveCacheDir(repoRoot).RemoveAll()
+}
+
+// computeStats returns the mean, median and 95th percentile of the given durations.
+// Percentiles use the nearest-rank method, so they are always one of the durations.
+func computeStats(durations []time.Duration) durationStats {
+	if len(durations) == 0 {
+		return durationStats{}
+	}
+	sorted := make([]time.Duration, len(durations))
+	copy(sorted, durations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, duration := range sorted {
+		total += duration
+	}
+	var median time.Duration
+	if mid := len(sorted) / 2; len(sorted)%2 == 0 {
+		median = (sorted[mid-1] + sorted[mid]) / 2
+	} else {
+		median = sorted[mid]
+	}
+	rank := int(math.Ceil(0.95*float64(len(sorted)))) - 1
+	return durationStats{
+		mean:   total / time.Duration(len(sorted)),
+		median: median,
+		p95:    sorted[rank],
+	}
+}
+
+func printBenchResults(base *cmdutil.CmdBase, iterations []*benchIteration) {
+	durations := make([]time.Duration, len(iterations))
+	taskDurations := make(map[string][]time.Duration)
+	cacheHits := make(map[string]int)
+	for i, iteration := range iterations {
+		durations[i] = iteration.duration
+		for taskID, execution := range iteration.tasks {
+			taskDurations[taskID] = append(taskDurations[taskID], time.Duration(execution.Duration)*time.Millisecond)
+			if execution.Status == runsummary.TaskStatusCached {
+				cacheHits[taskID]++
+			}
+		}
+	}
+	taskIDs := make([]string, 0, len(taskDurations))
+	for taskID := range taskDurations {
+		taskIDs = append(taskIDs, taskID)
+	}
+	sort.Strings(taskIDs)
+
+	total := computeStats(durations)
+	base.UI.Output(util.Sprintf("${BOLD}Results for %v iterations${RESET}", len(iterations)))
+	base.UI.Output(fmt.Sprintf("  %s   mean %v  median %v  p95 %v", ui.Bold("Total"), formatBenchDuration(total.mean), formatBenchDuration(total.median), formatBenchDuration(total.p95)))
+	base.UI.Output("")
+
+	width := 0
+	for _, taskID := range taskIDs {
+		if len(taskID) > width {
+			width = len(taskID)
+		}
+	}
+	for _, taskID := range taskIDs {
+		stats := computeStats(taskDurations[taskID])
+		runs := len(taskDurations[taskID])
+		base.UI.Output(fmt.Sprintf("  %s%s  mean %v  median %v  p95 %v  %s",
+			taskID,
+			strings.Repeat(" ", width-len(taskID)),
+			formatBenchDuration(stats.mean),
+			formatBenchDuration(stats.median),
+			formatBenchDuration(stats.p95),
+			ui.Dim(fmt.Sprintf("%v/%v cache hits", cacheHits[taskID], runs)),
+		))
+	}
+}
+
+func formatBenchDuration(duration time.Duration) string {
+	return duration.Round(time.Millisecond).String()
+}
@@ -0,0 +1,50 @@
+package run
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestComputeStats(t *testing.T) {
+	testCases := []struct {
+		name      string
+		durations []time.Duration
+		want      durationStats
+	}{
+		{
+			name:      "empty",
+			durations: nil,
+			want:      durationStats{},
+		},
+		{
+			name:      "single",
+			durations: []time.Duration{3 * time.Second},
+			want:      durationStats{mean: 3 * time.Second, median: 3 * time.Second, p95: 3 * time.Second},
+		},
+		{
+			name:      "odd count",
+			durations: []time.Duration{5 * time.Second, 1 * time.Second, 3 * time.Second},
+			want:      durationStats{mean: 3 * time.Second, median: 3 * time.Second, p95: 5 * time.Second},
+		},
+		{
+			name:      "even count",
+			durations: []time.Duration{4 * time.Second, 1 * time.Second, 2 * time.Second, 9 * time.Second},
+			want:      durationStats{mean: 4 * time.Second, median: 3 * time.Second, p95: 9 * time.Second},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, computeStats(tc.durations), tc.want)
+		})
+	}
+}
+
+func TestComputeStatsP95(t *testing.T) {
+	durations := make([]time.Duration, 20)
+	for i := range durations {
+		durations[i] = time.Duration(20-i) * time.Second
+	}
+	assert.Equal(t, computeStats(durations).p95, 19*time.Second)
+}
@@ -0,0 +1,79 @@
+package run
+
+import (
+	gocontext "context"
+	"fmt"
+
+	"github.com/vercel/turbo/cli/internal/cachecheck"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/graph"
+	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/runsummary"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/ui"
+)
+
+// checkTaskGraph hashes every task of the graph ahead of the run, like
+// prefetchArtifacts does, and checks their outputs and inputs against git.
+// Tasks that fail to hash are left out: the run reports the error when it
+// reaches them.
+func checkTaskGraph(ctx gocontext.Context, engine *core.Engine, g *graph.CompleteGraph, rs *runSpec, base *cmdutil.CmdBase) []*cachecheck.Warning {
+	taskSummaries := []*runsummary.TaskSummary{}
+	collect := func(ctx gocontext.Context, packageTask *nodes.PackageTask, taskSummary *runsummary.TaskSummary) error {
+		taskSummaries = append(taskSummaries, taskSummary)
+		return nil
+	}
+	getArgs := func(taskID string) []string {
+		return rs.ArgsForTask(taskID)
+	}
+	visitorFn := g.GetPackageTaskVisitor(ctx, engine.TaskGraph, getArgs, base.Logger, collect)
+	_ = engine.Execute(visitorFn, core.EngineExecutionOptions{Concurrency: 1})
+	return checkTasks(taskSummaries, base)
+}
+
+// checkTasks returns the warnings about the outputs and inputs of the tasks.
+// Repositories that aren't in git can't be checked, and have no warnings.
+func checkTasks(taskSummaries []*runsummary.TaskSummary, base *cmdutil.CmdBase) []*cachecheck.Warning {
+	tasks := make([]cachecheck.Task, 0, len(taskSummaries))
+	for _, taskSummary := range taskSummaries {
+		// Tasks that don't run, or aren't cached, don't restore or hash anything
+		if taskSummary.Command == "" || taskSummary.Command == runsummary.MissingTaskLabel {
+			continue
+		}
+		task := cachecheck.Task{
+			TaskID: taskSummary.TaskID,
+			Dir:    turbopath.AnchoredSystemPathFromUpstream(taskSummary.Dir).ToUnixPath(),
+		}
+		if definition := taskSummary.ResolvedTaskDefinition; definition != nil && definition.ShouldCache {
+			task.Outputs = taskSummary.Outputs
+			task.ExcludedOutputs = taskSummary.ExcludedOutputs
+			for file := range taskSummary.ExpandedInputs {
+				task.Inputs = append(task.Inputs, file)
+			}
+		}
+		tasks = append(tasks, task)
+	}
+	warnings, err := cachecheck.Check(base.RepoRoot, tasks)
+	if err != nil {
+		base.Logger.Debug("skipping the checks of task outputs and inputs", "error", err)
+		return nil
+	}
+	return warnings
+}
+
+// reportCacheWarnings prints the warnings, and fails the run with --strict
+func reportCacheWarnings(warnings []*cachecheck.Warning, rs *runSpec, base *cmdutil.CmdBase) error {
+	for _, warning := range warnings {
+		base.UI.Warn(fmt.Sprintf("%v %v", ui.WARNING_PREFIX, warning))
+	}
+	return strictCacheWarnings(warnings, rs)
+}
+
+// strictCacheWarnings returns an error if there are warnings and --strict is set
+func strictCacheWarnings(warnings []*cachecheck.Warning, rs *runSpec) error {
+	if rs.Opts.runOpts.strict && len(warnings) > 0 {
+		return fmt.Errorf("found %v problems with the outputs and inputs of tasks, and --strict is set", len(warnings))
+	}
+	return nil
+}
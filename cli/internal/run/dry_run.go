@@ -13,8 +13,11 @@ import (
 	"github.com/vercel/turbo/cli/internal/cache"
 	"github.com/vercel/turbo/cli/internal/cmdutil"
 	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/graph"
 	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/packagemanager"
+	"github.com/vercel/turbo/cli/internal/runcache"
 	"github.com/vercel/turbo/cli/internal/runsummary"
 	"github.com/vercel/turbo/cli/internal/taskhash"
 	"github.com/vercel/turbo/cli/internal/util"
@@ -31,6 +34,7 @@ func DryRun(
 	turboCache cache.Cache,
 	base *cmdutil.CmdBase,
 	summary *runsummary.RunSummary,
+	packageManager *packagemanager.PackageManager,
 ) error {
 	defer turboCache.Shutdown()
 
@@ -50,13 +54,37 @@ func DryRun(
 		return err
 	}
 
+	// Assign the Task Summaries to the main summary
+	summary.Tasks = taskSummaries
+
+	// `turbo replay` only needs the hashes, not the cache state
+	if rs.Opts.runOpts.replayRecord != nil {
+		current, err := newRunRecord(rs, base, engine, g.GlobalHash, summary, packageManager)
+		if err != nil {
+			return err
+		}
+		return verifyReplay(rs, base, current)
+	}
+
 	// We walk the graph with no concurrency.
 	// Populating the cache state is parallelizable.
 	// Do this _after_ walking the graph.
 	populateCacheState(turboCache, taskSummaries)
+	for _, task := range taskSummaries {
+		forced := rs.Opts.runcacheOpts.SkipsReadsFor(task.TaskID, task.Task, task.Package)
+		task.ExpectedCacheStatus = expectedCacheStatus(task, forced)
+	}
+	summary.Warnings = checkTasks(taskSummaries, base)
 
-	// Assign the Task Summaries to the main summary
-	summary.Tasks = taskSummaries
+	if rs.Opts.runOpts.recordFile != "" {
+		if err := writeRunRecord(rs, base, engine, g.GlobalHash, summary, packageManager); err != nil {
+			base.UI.Warn(fmt.Sprintf("Failed to write run record: %s", err))
+		}
+	}
+
+	if rs.Opts.runOpts.restoreDryRun {
+		return printRestoreDryRun(rs, turboCache, base, taskSummaries)
+	}
 
 	// Render the dry run as json
 	if dryRunJSON {
@@ -65,10 +93,11 @@ func DryRun(
 			return err
 		}
 		base.UI.Output(string(rendered))
-		return nil
+	} else if err := summary.FormatAndPrintText(base.UI, g.WorkspaceInfos, singlePackage); err != nil {
+		return err
 	}
 
-	return summary.FormatAndPrintText(base.UI, g.WorkspaceInfos, singlePackage)
+	return strictCacheWarnings(summary.Warnings, rs)
 }
 
 func executeDryRun(ctx gocontext.Context, engine *core.Engine, g *graph.CompleteGraph, taskHashTracker *taskhash.Tracker, rs *runSpec, base *cmdutil.CmdBase) ([]*runsummary.TaskSummary, error) {
@@ -151,6 +180,11 @@ func populateCacheState(turboCache cache.Cache, taskSummaries []*runsummary.Task
 			for index := range queue {
 				task := taskSummaries[index]
 				itemStatus := turboCache.Exists(task.Hash)
+				// A real run restores the entry of the fallback hash when the
+				// task has none for its hash
+				if !itemStatus.Local && !itemStatus.Remote && task.FallbackHash != "" {
+					itemStatus = turboCache.Exists(task.FallbackHash)
+				}
 				task.CacheState = itemStatus
 			}
 		}()
@@ -163,8 +197,64 @@ func populateCacheState(turboCache cache.Cache, taskSummaries []*runsummary.Task
 	wg.Wait()
 }
 
+// expectedCacheStatus predicts whether a real run restores the task from the
+// cache, in the terms of runcache.MissReason if it doesn't
+func expectedCacheStatus(task *runsummary.TaskSummary, forced bool) string {
+	switch {
+	case task.ResolvedTaskDefinition != nil && !task.ResolvedTaskDefinition.ShouldCache:
+		return string(runcache.MissCacheDisabled)
+	case forced:
+		return string(runcache.MissForced)
+	case task.CacheState.Local || task.CacheState.Remote:
+		return "hit"
+	default:
+		return string(runcache.MissNoCacheEntry)
+	}
+}
+
 var _isTurbo = regexp.MustCompile(fmt.Sprintf("(?:^|%v|\\s)turbo(?:$|\\s)", regexp.QuoteMeta(string(filepath.Separator))))
 
+// _turboChecks matches turbo commands that only inspect the repository, which are
+// safe to run from a root task. Like _isTurbo, it accepts turbo invoked by path.
+var _turboChecks = regexp.MustCompile(fmt.Sprintf("(?:^|\\s)(?:\\S*%v)?turbo\\s+boundaries(?:$|\\s)", regexp.QuoteMeta(string(filepath.Separator))))
+
 func commandLooksLikeTurbo(command string) bool {
-	return _isTurbo.MatchString(command)
+	return _isTurbo.MatchString(_turboChecks.ReplaceAllString(command, " "))
+}
+
+// printGlobalHashInputs prints everything that went into the global hash, instead
+// of the usual dry run summary.
+func printGlobalHashInputs(rs *runSpec, g *graph.CompleteGraph, globalHashable GlobalHashable, base *cmdutil.CmdBase) error {
+	inputs := newGlobalHashInputs(g.GlobalHash, base.TurboVersion, globalHashable, g.TaskHashTracker.HashVersion())
+
+	if rs.Opts.runOpts.dryRunJSON {
+		rendered, err := inputs.FormatJSON()
+		if err != nil {
+			return err
+		}
+		base.UI.Output(string(rendered))
+		return nil
+	}
+	return inputs.FormatAndPrintText(base.UI)
+}
+
+// newGlobalHashInputs lists what went into a global hash
+func newGlobalHashInputs(globalHash string, turboVersion string, globalHashable GlobalHashable, hashVersion fs.HashVersion) *runsummary.GlobalHashInputs {
+	rootExternalDeps := make([]string, len(globalHashable.rootExternalDeps))
+	for i, pkg := range globalHashable.rootExternalDeps {
+		rootExternalDeps[i] = fmt.Sprintf("%v@%v", pkg.Key, pkg.Version)
+	}
+	return runsummary.NewGlobalHashInputs(
+		globalHash,
+		turboVersion,
+		runsummary.NewGlobalHashSummary(
+			globalHashable.globalFileHashMap,
+			globalHashable.rootExternalDepsHash,
+			globalHashable.envVars,
+			globalHashable.globalCacheKey,
+			globalHashable.pipeline,
+			hashVersion,
+		),
+		rootExternalDeps,
+	)
 }
@@ -0,0 +1,45 @@
+package run
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/runsummary"
+	"gotest.tools/v3/assert"
+)
+
+func TestCommandLooksLikeTurbo(t *testing.T) {
+	localTurbo := "." + string(filepath.Separator) + filepath.Join("node_modules", ".bin", "turbo")
+	testCases := []struct {
+		command string
+		want    bool
+	}{
+		{command: "turbo run build", want: true},
+		{command: "turbo build", want: true},
+		{command: localTurbo + " run build", want: true},
+		{command: "turbo boundaries", want: false},
+		{command: localTurbo + " boundaries", want: false},
+		{command: "eslint . && turbo boundaries", want: false},
+		{command: "turbo boundaries && turbo run build", want: true},
+		{command: localTurbo + " boundaries && " + localTurbo + " run build", want: true},
+		{command: "turborepo-tool build", want: false},
+		{command: "tsc --noEmit", want: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.command, func(t *testing.T) {
+			assert.Equal(t, commandLooksLikeTurbo(tc.command), tc.want)
+		})
+	}
+}
+
+func TestExpectedCacheStatus(t *testing.T) {
+	task := func(shouldCache bool, cacheState cache.ItemStatus) *runsummary.TaskSummary {
+		return &runsummary.TaskSummary{CacheState: cacheState, ResolvedTaskDefinition: &fs.TaskDefinition{ShouldCache: shouldCache}}
+	}
+	assert.Equal(t, expectedCacheStatus(task(true, cache.ItemStatus{Remote: true}), false), "hit")
+	assert.Equal(t, expectedCacheStatus(task(true, cache.ItemStatus{}), false), "no_cache_entry")
+	assert.Equal(t, expectedCacheStatus(task(true, cache.ItemStatus{Local: true}), true), "forced")
+	assert.Equal(t, expectedCacheStatus(task(false, cache.ItemStatus{Local: true}), true), "cache_disabled")
+}
@@ -0,0 +1,148 @@
+package run
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/vercel/turbo/cli/internal/process"
+	"github.com/vercel/turbo/cli/internal/runsummary"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+)
+
+// NOTE: These *must* be kept in sync with the ExitCodeMode enum in the Rust shim
+const (
+	_exitCodeHighestValue = "Highest"
+	_exitCodeFirstValue   = "First"
+	_exitCodeFixedValue   = "Fixed"
+	_exitCodeBitmaskValue = "Bitmask"
+)
+
+// The bits of the exit code in the bitmask mode, one for each kind of failure,
+// so that scripts can tell them apart
+const (
+	_exitCodeTaskFailedBit = 1 << iota
+	_exitCodeTimedOutBit
+	_exitCodeErrorBit
+)
+
+// _canceledExitCode is the exit code of a run that was canceled by a signal,
+// whatever the mode, like a shell reports a command stopped by SIGINT
+const _canceledExitCode = 130
+
+// exitCodeOpts controls how the exit code of a failed run is chosen
+type exitCodeOpts struct {
+	mode string
+	// taskFailureCode and errorCode are used in the fixed mode
+	taskFailureCode int
+	errorCode       int
+}
+
+func exitCodeOptsFromArgs(runPayload *turbostate.RunPayload) (exitCodeOpts, error) {
+	opts := exitCodeOpts{
+		mode:            _exitCodeHighestValue,
+		taskFailureCode: 1,
+		errorCode:       1,
+	}
+	switch runPayload.ExitCode {
+	case "":
+	case _exitCodeHighestValue, _exitCodeFirstValue, _exitCodeFixedValue, _exitCodeBitmaskValue:
+		opts.mode = runPayload.ExitCode
+	default:
+		return opts, fmt.Errorf("invalid exit code mode: %v", runPayload.ExitCode)
+	}
+	if runPayload.TaskFailureExitCode != nil || runPayload.ErrorExitCode != nil {
+		if opts.mode != _exitCodeFixedValue {
+			return opts, errors.New("--task-failure-exit-code and --error-exit-code require --exit-code=fixed")
+		}
+		if runPayload.TaskFailureExitCode != nil {
+			opts.taskFailureCode = *runPayload.TaskFailureExitCode
+		}
+		if runPayload.ErrorExitCode != nil {
+			opts.errorCode = *runPayload.ErrorExitCode
+		}
+		if opts.taskFailureCode < 1 || opts.taskFailureCode > 255 || opts.errorCode < 1 || opts.errorCode > 255 {
+			return opts, errors.New("fixed exit codes must be between 1 and 255")
+		}
+	}
+	return opts, nil
+}
+
+// resolve chooses the exit code for a run that finished with errs. firstTaskID
+// and firstErr are the first task to fail and its error, if any task failed.
+func (o exitCodeOpts) resolve(errs []error, firstTaskID string, firstErr error) *runsummary.ExitCodeSummary {
+	summary := &runsummary.ExitCodeSummary{Mode: strings.ToLower(o.mode)}
+	if len(errs) == 0 {
+		return summary
+	}
+
+	highest := 0
+	highestTaskFailed := false
+	otherError := false
+	bitmask := 0
+	for _, err := range errs {
+		childExit := &process.ChildExit{}
+		timedOut := &process.TimedOut{}
+		if errors.As(err, &childExit) {
+			if childExit.ExitCode > highest {
+				highest = childExit.ExitCode
+				highestTaskFailed = true
+			}
+			bitmask |= _exitCodeTaskFailedBit
+		} else {
+			otherError = true
+			if errors.As(err, &timedOut) {
+				bitmask |= _exitCodeTimedOutBit
+			} else {
+				bitmask |= _exitCodeErrorBit
+			}
+		}
+	}
+
+	switch o.mode {
+	case _exitCodeFirstValue:
+		childExit := &process.ChildExit{}
+		if firstErr != nil && errors.As(firstErr, &childExit) {
+			summary.Code = childExit.ExitCode
+			summary.Reason = fmt.Sprintf("the exit code of %v, the first task to fail", firstTaskID)
+			return summary
+		}
+		summary.Code = 1
+		if firstTaskID != "" {
+			summary.Reason = fmt.Sprintf("%v, the first task to fail, did not exit with a code", firstTaskID)
+		} else {
+			summary.Reason = "the run failed before any task did"
+		}
+	case _exitCodeFixedValue:
+		if otherError {
+			summary.Code = o.errorCode
+			summary.Reason = "the fixed exit code for errors other than a task failing"
+		} else {
+			summary.Code = o.taskFailureCode
+			summary.Reason = "the fixed exit code for task failures"
+		}
+	case _exitCodeBitmaskValue:
+		summary.Code = bitmask
+		reasons := []string{}
+		if bitmask&_exitCodeTaskFailedBit != 0 {
+			reasons = append(reasons, "a task failed (1)")
+		}
+		if bitmask&_exitCodeTimedOutBit != 0 {
+			reasons = append(reasons, "a task timed out (2)")
+		}
+		if bitmask&_exitCodeErrorBit != 0 {
+			reasons = append(reasons, "turbo failed for another reason (4)")
+		}
+		summary.Reason = strings.Join(reasons, ", ")
+	default:
+		if highestTaskFailed {
+			summary.Code = highest
+			summary.Reason = "the highest exit code of any failed task"
+		} else {
+			// We hit some error, it shouldn't be exit code 0
+			summary.Code = 1
+			summary.Reason = "no failed task exited with a code"
+		}
+	}
+	return summary
+}
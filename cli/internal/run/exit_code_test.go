@@ -0,0 +1,111 @@
+package run
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/process"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+	"gotest.tools/v3/assert"
+)
+
+func TestExitCodeResolve(t *testing.T) {
+	first := fmt.Errorf("running web#build failed: %w", &process.ChildExit{ExitCode: 2})
+	second := fmt.Errorf("running docs#build failed: %w", &process.ChildExit{ExitCode: 7})
+	internal := errors.New("failed to hash inputs")
+	timedOut := fmt.Errorf("running web#test failed: %w", &process.TimedOut{Command: "jest"})
+
+	testCases := []struct {
+		name       string
+		payload    turbostate.RunPayload
+		errs       []error
+		firstErr   error
+		wantCode   int
+		wantReason string
+	}{
+		{
+			name:     "success",
+			payload:  turbostate.RunPayload{ExitCode: "First"},
+			wantCode: 0,
+		},
+		{
+			name:       "highest by default",
+			errs:       []error{first, second},
+			firstErr:   first,
+			wantCode:   7,
+			wantReason: "the highest exit code of any failed task",
+		},
+		{
+			name:       "highest without a task exit code",
+			errs:       []error{internal},
+			wantCode:   1,
+			wantReason: "no failed task exited with a code",
+		},
+		{
+			name:       "first",
+			payload:    turbostate.RunPayload{ExitCode: "First"},
+			errs:       []error{second, first},
+			firstErr:   first,
+			wantCode:   2,
+			wantReason: "the exit code of web#build, the first task to fail",
+		},
+		{
+			name:       "fixed task failure",
+			payload:    turbostate.RunPayload{ExitCode: "Fixed", TaskFailureExitCode: intPtr(3)},
+			errs:       []error{first, second},
+			firstErr:   first,
+			wantCode:   3,
+			wantReason: "the fixed exit code for task failures",
+		},
+		{
+			name:       "fixed error",
+			payload:    turbostate.RunPayload{ExitCode: "Fixed", ErrorExitCode: intPtr(70)},
+			errs:       []error{first, internal},
+			firstErr:   first,
+			wantCode:   70,
+			wantReason: "the fixed exit code for errors other than a task failing",
+		},
+		{
+			name:       "bitmask task failure",
+			payload:    turbostate.RunPayload{ExitCode: "Bitmask"},
+			errs:       []error{first, second},
+			firstErr:   first,
+			wantCode:   1,
+			wantReason: "a task failed (1)",
+		},
+		{
+			name:       "bitmask combined",
+			payload:    turbostate.RunPayload{ExitCode: "Bitmask"},
+			errs:       []error{timedOut, first, internal},
+			firstErr:   timedOut,
+			wantCode:   7,
+			wantReason: "a task failed (1), a task timed out (2), turbo failed for another reason (4)",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts, err := exitCodeOptsFromArgs(&tc.payload)
+			assert.NilError(t, err)
+			firstTaskID := ""
+			if tc.firstErr != nil {
+				firstTaskID = "web#build"
+			}
+			summary := opts.resolve(tc.errs, firstTaskID, tc.firstErr)
+			assert.Equal(t, summary.Code, tc.wantCode)
+			assert.Equal(t, summary.Reason, tc.wantReason)
+		})
+	}
+}
+
+func TestExitCodeOptsFromArgsErrors(t *testing.T) {
+	_, err := exitCodeOptsFromArgs(&turbostate.RunPayload{TaskFailureExitCode: intPtr(3)})
+	assert.ErrorContains(t, err, "require --exit-code=fixed")
+
+	_, err = exitCodeOptsFromArgs(&turbostate.RunPayload{ExitCode: "Fixed", ErrorExitCode: intPtr(256)})
+	assert.ErrorContains(t, err, "between 1 and 255")
+}
+
+func intPtr(i int) *int {
+	return &i
+}
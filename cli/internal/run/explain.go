@@ -0,0 +1,173 @@
+// Package run implements `turbo run`
+// This file implements `turbo explain`, which tells whether a task would be
+// restored from the cache without running it, and what changed since its last
+// run if it wouldn't
+package run
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/daemon"
+	"github.com/vercel/turbo/cli/internal/daemonclient"
+	"github.com/vercel/turbo/cli/internal/runsummary"
+	"github.com/vercel/turbo/cli/internal/signals"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// ExecuteExplain executes the `explain` command.
+func ExecuteExplain(ctx gocontext.Context, helper *cmdutil.Helper, signalWatcher *signals.Watcher, args *turbostate.ParsedArgsFromRust) error {
+	base, err := helper.GetCmdBase(args)
+	if err != nil {
+		return err
+	}
+	if err := explain(ctx, base, signalWatcher, args); err != nil {
+		base.LogError("explain failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+func explain(ctx gocontext.Context, base *cmdutil.CmdBase, signalWatcher *signals.Watcher, args *turbostate.ParsedArgsFromRust) error {
+	explainPayload := args.Command.Explain
+	maxRuns := explainPayload.Runs
+	if maxRuns <= 0 {
+		maxRuns = runsummary.DefaultHistoricalRuns
+	}
+
+	var explanation *runsummary.TaskExplanation
+	if explainPayload.Daemon {
+		turbodClient, err := daemon.GetClient(ctx, base.RepoRoot, base.Logger, base.TurboVersion, daemon.ClientOpts{})
+		if err != nil {
+			return errors.Wrap(err, "failed to contact turbod")
+		}
+		defer func() { _ = turbodClient.Close() }()
+		explanation, err = daemonclient.New(turbodClient).ExplainTask(ctx, explainPayload.Task, maxRuns)
+		if err != nil {
+			return err
+		}
+	} else {
+		var err error
+		explanation, err = ExplainTask(ctx, base, signalWatcher, args, explainPayload.Task, maxRuns)
+		if err != nil {
+			return err
+		}
+	}
+
+	if explainPayload.JSON {
+		rendered, err := json.MarshalIndent(explanation, "", "  ")
+		if err != nil {
+			return err
+		}
+		base.UI.Output(string(rendered))
+		return nil
+	}
+
+	base.UI.Output(util.Sprintf("${BOLD}%v${RESET} ${GREY}%v${RESET}", explanation.TaskID, explanation.Hash))
+	base.UI.Output(fmt.Sprintf("  %v", explanation.Reason))
+	if previous := explanation.PreviousRun; previous != nil {
+		base.UI.Output(util.Sprintf("  ${GREY}last run %v, %v ago: %v with hash %v${RESET}", previous.RunID, time.Since(previous.CreatedAt).Round(time.Second), previous.Status, previous.Hash))
+	} else {
+		base.UI.Output(util.Sprintf("  ${GREY}save the summaries of runs with --summarize to compare the next ones with them${RESET}"))
+	}
+	if len(explanation.GlobalChanges) > 0 {
+		base.UI.Output("")
+		base.UI.Output(util.Sprintf("${BOLD}The global hash changed${RESET}, which changes the hash of every task"))
+		for _, change := range explanation.GlobalChanges {
+			base.UI.Output(fmt.Sprintf("  %v", change))
+		}
+	}
+	if len(explanation.Changes) > 0 {
+		base.UI.Output("")
+		base.UI.Output(util.Sprintf("${BOLD}Changes since the last run${RESET}"))
+		for _, change := range explanation.Changes {
+			base.UI.Output(fmt.Sprintf("  %v", change))
+		}
+	}
+	return nil
+}
+
+// ExplainTask hashes a task, given as <package>#<task>, and the tasks that it
+// depends on like a dry run does, and explains whether it would be restored
+// from the cache by comparing it with its last run in the most recent maxRuns
+// run summaries saved in the repository. Nothing is executed. It also answers
+// the ExplainTask rpc of turbod, which hashes tasks with its own environment.
+func ExplainTask(ctx gocontext.Context, base *cmdutil.CmdBase, signalWatcher *signals.Watcher, args *turbostate.ParsedArgsFromRust, taskID string, maxRuns int) (*runsummary.TaskExplanation, error) {
+	if !util.IsPackageTask(taskID) {
+		return nil, fmt.Errorf("invalid task %q, expected <package>#<task>", taskID)
+	}
+	pkgName, taskName := util.GetPackageTaskFromId(taskID)
+	runArgs := *args
+	runArgs.Command = turbostate.Command{Run: &turbostate.RunPayload{
+		Tasks:  []string{taskName},
+		Filter: []string{pkgName},
+	}}
+	if base.UserConfig != nil {
+		applyUserDefaults(runArgs.Command.Run, base.UserConfig)
+	}
+	opts, err := optsFromArgs(&runArgs)
+	if err != nil {
+		return nil, err
+	}
+	r := configureRun(base, opts, signalWatcher)
+	prepared, err := r.prepare([]string{taskName})
+	if err != nil {
+		return nil, err
+	}
+	rs := prepared.rs
+
+	analyticsClient := r.initAnalyticsClient(ctx)
+	defer analyticsClient.CloseWithTimeout(50 * time.Millisecond)
+	turboCache, err := r.initCache(ctx, rs, analyticsClient)
+	if err != nil && !errors.Is(err, cache.ErrNoCachesEnabled) {
+		return nil, errors.Wrap(err, "failed to set up caching")
+	}
+	defer turboCache.Shutdown()
+
+	taskSummaries, err := executeDryRun(ctx, prepared.engine, prepared.g, prepared.taskHashTracker, rs, base)
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	for _, task := range taskSummaries {
+		if task.TaskID == taskID {
+			if task.Command == runsummary.MissingTaskLabel {
+				return nil, fmt.Errorf("%v has no %v script, nothing would run", pkgName, taskName)
+			}
+			found = true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%v is not part of the task graph, check the name of the package and that turbo.json has the task", taskID)
+	}
+	populateCacheState(turboCache, taskSummaries)
+	for _, task := range taskSummaries {
+		forced := rs.Opts.runcacheOpts.SkipsReadsFor(task.TaskID, task.Task, task.Package)
+		task.ExpectedCacheStatus = expectedCacheStatus(task, forced)
+	}
+
+	packagesInScope := rs.FilteredPkgs.UnsafeListOfStrings()
+	sort.Strings(packagesInScope)
+	globalHashable := prepared.globalHashable
+	summary := runsummary.NewRunSummary(
+		base.TurboVersion,
+		packagesInScope,
+		runsummary.NewGlobalHashSummary(
+			globalHashable.globalFileHashMap,
+			globalHashable.rootExternalDepsHash,
+			globalHashable.envVars,
+			globalHashable.globalCacheKey,
+			globalHashable.pipeline,
+			prepared.taskHashTracker.HashVersion(),
+		),
+	)
+	summary.Tasks = taskSummaries
+	return summary.ExplainTask(base.RepoRoot, taskID, rs.Opts.runOpts.singlePackage, maxRuns)
+}
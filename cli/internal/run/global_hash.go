@@ -29,6 +29,9 @@ type GlobalHashable struct {
 	envVars              env.DetailedMap
 	globalCacheKey       string
 	pipeline             fs.PristinePipeline
+	// rootExternalDeps are the lockfile entries behind rootExternalDepsHash.
+	// They are only used for reporting, the hash already accounts for them.
+	rootExternalDeps []lockfile.Package
 }
 
 // getGlobalHashable converts GlobalHashable into an anonymous struct.
@@ -61,6 +64,48 @@ func getGlobalHashable(named GlobalHashable) struct {
 	}
 }
 
+// forVersion returns the hashable of the given hash version. Since version 3,
+// the external dependencies of the root package are only hashed by the tasks of
+// the root package, along with the rest of its files.
+func (gh GlobalHashable) forVersion(version fs.HashVersion) GlobalHashable {
+	if version >= fs.HashVersion3 {
+		gh.rootExternalDepsHash = ""
+	}
+	return gh
+}
+
+// globalFileHasher hashes the files that match the globalDependencies globs,
+// other than the ignored ones, along with the given files, which are relative
+// to the repository root
+type globalFileHasher func(rootpath turbopath.AbsoluteSystemPath, globs []string, ignores []string, files []string) (map[turbopath.AnchoredUnixPath]string, error)
+
+// hashGlobalFiles is the globalFileHasher that hashes every file each time
+func hashGlobalFiles(rootpath turbopath.AbsoluteSystemPath, globs []string, ignores []string, files []string) (map[turbopath.AnchoredUnixPath]string, error) {
+	globalDeps := make(util.Set)
+	if len(globs) > 0 {
+		f, err := globby.GlobFiles(rootpath.ToStringDuringMigration(), globs, ignores)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, val := range f {
+			globalDeps.Add(val)
+		}
+	}
+	for _, file := range files {
+		globalDeps.Add(filepath.Join(rootpath.ToStringDuringMigration(), file))
+	}
+
+	// No prefix, global deps already have full paths
+	globalDepsArray := globalDeps.UnsafeListOfStrings()
+	globalDepsPaths := make([]turbopath.AbsoluteSystemPath, len(globalDepsArray))
+	for i, path := range globalDepsArray {
+		globalDepsPaths[i] = turbopath.AbsoluteSystemPathFromUpstream(path)
+	}
+
+	return hashing.GetHashableDeps(rootpath, globalDepsPaths)
+}
+
 func calculateGlobalHash(
 	rootpath turbopath.AbsoluteSystemPath,
 	rootPackageJSON *fs.PackageJSON,
@@ -68,7 +113,8 @@ func calculateGlobalHash(
 	envVarDependencies []string,
 	globalFileDependencies []string,
 	packageManager *packagemanager.PackageManager,
-	lockFile lockfile.Lockfile,
+	hasLockfile bool,
+	hashFiles globalFileHasher,
 	logger hclog.Logger,
 ) (GlobalHashable, error) {
 	// Calculate env var dependencies
@@ -83,37 +129,21 @@ func calculateGlobalHash(
 	logger.Debug("global hash env vars", "vars", globalHashableEnvVars.All.Names())
 
 	// Calculate global file dependencies
-	globalDeps := make(util.Set)
+	var ignores []string
 	if len(globalFileDependencies) > 0 {
-		ignores, err := packageManager.GetWorkspaceIgnores(rootpath)
-		if err != nil {
-			return GlobalHashable{}, err
-		}
-
-		f, err := globby.GlobFiles(rootpath.ToStringDuringMigration(), globalFileDependencies, ignores)
+		ignores, err = packageManager.GetWorkspaceIgnores(rootpath)
 		if err != nil {
 			return GlobalHashable{}, err
 		}
-
-		for _, val := range f {
-			globalDeps.Add(val)
-		}
 	}
 
-	if lockFile == nil {
+	var files []string
+	if !hasLockfile {
 		// If we don't have lockfile information available, add the specfile and lockfile to global deps
-		globalDeps.Add(filepath.Join(rootpath.ToStringDuringMigration(), packageManager.Specfile))
-		globalDeps.Add(filepath.Join(rootpath.ToStringDuringMigration(), packageManager.Lockfile))
-	}
-
-	// No prefix, global deps already have full paths
-	globalDepsArray := globalDeps.UnsafeListOfStrings()
-	globalDepsPaths := make([]turbopath.AbsoluteSystemPath, len(globalDepsArray))
-	for i, path := range globalDepsArray {
-		globalDepsPaths[i] = turbopath.AbsoluteSystemPathFromUpstream(path)
+		files = append(files, packageManager.Specfile, packageManager.Lockfile)
 	}
 
-	globalFileHashMap, err := hashing.GetHashableDeps(rootpath, globalDepsPaths)
+	globalFileHashMap, err := hashFiles(rootpath, globalFileDependencies, ignores, files)
 	if err != nil {
 		return GlobalHashable{}, fmt.Errorf("error hashing files: %w", err)
 	}
@@ -124,5 +154,6 @@ func calculateGlobalHash(
 		envVars:              globalHashableEnvVars,
 		globalCacheKey:       _globalCacheKey,
 		pipeline:             pipeline.Pristine(),
+		rootExternalDeps:     rootPackageJSON.TransitiveDeps,
 	}, nil
 }
@@ -0,0 +1,116 @@
+// Package run implements `turbo run`
+// This file implements `turbo globalhash`, which prints the global hash and,
+// with --explain, every input that went into it
+package run
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/context"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/graph"
+	"github.com/vercel/turbo/cli/internal/runsummary"
+	"github.com/vercel/turbo/cli/internal/signals"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// explainedGlobalHash is the output of `turbo globalhash --json`
+type explainedGlobalHash struct {
+	GlobalHash  string `json:"globalHash"`
+	HashVersion int    `json:"hashVersion"`
+	// Contributors are only listed with --explain
+	Contributors []*runsummary.GlobalHashContributor `json:"contributors,omitempty"`
+}
+
+// ExecuteGlobalHash executes the `globalhash` command.
+func ExecuteGlobalHash(helper *cmdutil.Helper, signalWatcher *signals.Watcher, args *turbostate.ParsedArgsFromRust) error {
+	base, err := helper.GetCmdBase(args)
+	if err != nil {
+		return err
+	}
+	if err := globalHash(base, signalWatcher, args); err != nil {
+		base.LogError("globalhash failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+func globalHash(base *cmdutil.CmdBase, signalWatcher *signals.Watcher, args *turbostate.ParsedArgsFromRust) error {
+	globalHashPayload := args.Command.GlobalHash
+	runArgs := *args
+	runArgs.Command = turbostate.Command{Run: &turbostate.RunPayload{}}
+	opts, err := optsFromArgs(&runArgs)
+	if err != nil {
+		return err
+	}
+	r := configureRun(base, opts, signalWatcher)
+
+	rootPackageJSON, err := fs.ReadPackageJSON(base.RepoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		return fmt.Errorf("failed to read package.json: %w", err)
+	}
+	// The package graph resolves the root external dependencies from the lockfile
+	pkgDepGraph, err := context.BuildPackageGraph(base.RepoRoot, rootPackageJSON)
+	if err != nil {
+		var warnings *context.Warnings
+		if !errors.As(err, &warnings) {
+			return err
+		}
+	}
+	g := &graph.CompleteGraph{
+		WorkspaceInfos: pkgDepGraph.WorkspaceInfos,
+		RepoRoot:       base.RepoRoot,
+	}
+	turboJSON, err := g.GetTurboConfigFromWorkspace(util.RootPkgName, false)
+	if err != nil {
+		return err
+	}
+	globalHashable, hash, err := r.globalHash(rootPackageJSON, pkgDepGraph, turboJSON)
+	if err != nil {
+		return err
+	}
+	hashVersion := turboJSON.Hashing.HashVersion()
+
+	explained := &explainedGlobalHash{GlobalHash: hash, HashVersion: int(hashVersion)}
+	if globalHashPayload.Explain {
+		inputs := newGlobalHashInputs(hash, base.TurboVersion, globalHashable, hashVersion)
+		if explained.Contributors, err = inputs.Contributors(); err != nil {
+			return err
+		}
+	}
+
+	if globalHashPayload.JSON {
+		rendered, err := json.MarshalIndent(explained, "", "  ")
+		if err != nil {
+			return err
+		}
+		base.UI.Output(string(rendered))
+		return nil
+	}
+	if !globalHashPayload.Explain {
+		base.UI.Output(hash)
+		return nil
+	}
+
+	base.UI.Output(util.Sprintf("${BOLD}%s${RESET} ${GREY}hash version %d${RESET}", hash, hashVersion))
+	var table strings.Builder
+	w := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+	for _, contributor := range explained.Contributors {
+		digest := contributor.Digest
+		if !contributor.Hashed {
+			digest = strings.TrimSpace(digest + " (not hashed)")
+		}
+		fmt.Fprintln(w, util.Sprintf("  %s\t%s\t${GREY}%s${RESET}", contributor.Kind, contributor.Name, digest))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	base.UI.Output(strings.TrimSuffix(table.String(), "\n"))
+	return nil
+}
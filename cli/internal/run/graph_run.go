@@ -2,24 +2,52 @@ package run
 
 import (
 	gocontext "context"
+	"fmt"
 
 	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/cache"
 	"github.com/vercel/turbo/cli/internal/cmdutil"
 	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/graph"
 	"github.com/vercel/turbo/cli/internal/graphvisualizer"
+	"github.com/vercel/turbo/cli/internal/runsummary"
+	"github.com/vercel/turbo/cli/internal/taskhash"
 	"github.com/vercel/turbo/cli/internal/util"
 )
 
 // GraphRun generates a visualization of the task graph rather than executing it.
-func GraphRun(ctx gocontext.Context, rs *runSpec, engine *core.Engine, base *cmdutil.CmdBase) error {
-	graph := engine.TaskGraph
+// Tasks are annotated with their expected cache status and how long they took
+// the last time they were executed.
+func GraphRun(
+	ctx gocontext.Context,
+	g *graph.CompleteGraph,
+	rs *runSpec,
+	engine *core.Engine,
+	taskHashTracker *taskhash.Tracker,
+	turboCache cache.Cache,
+	base *cmdutil.CmdBase,
+) error {
+	defer turboCache.Shutdown()
+
+	taskGraph := engine.TaskGraph
 	if rs.Opts.runOpts.singlePackage {
-		graph = filterSinglePackageGraphForDisplay(engine.TaskGraph)
+		taskGraph = filterSinglePackageGraphForDisplay(engine.TaskGraph)
+	}
+	visualizer := graphvisualizer.New(base.RepoRoot, base.UI, taskGraph)
+
+	annotations, err := graphAnnotations(ctx, g, rs, engine, taskHashTracker, turboCache, base)
+	if err != nil {
+		// The graph is still useful without annotations
+		base.UI.Warn(fmt.Sprintf("Failed to annotate the task graph: %v", err))
 	}
-	visualizer := graphvisualizer.New(base.RepoRoot, base.UI, graph)
+	visualizer.SetAnnotations(annotations)
 
 	if rs.Opts.runOpts.graphDot {
 		visualizer.RenderDotGraph()
+	} else if rs.Opts.runOpts.graphMermaid {
+		if err := visualizer.RenderMermaidGraph(); err != nil {
+			return err
+		}
 	} else {
 		err := visualizer.GenerateGraphFile(rs.Opts.runOpts.graphFile)
 		if err != nil {
@@ -29,6 +57,47 @@ func GraphRun(ctx gocontext.Context, rs *runSpec, engine *core.Engine, base *cmd
 	return nil
 }
 
+// graphAnnotations predicts the cache status of every task like a dry run does,
+// and looks up how long it took in the run summaries saved in the repository
+func graphAnnotations(
+	ctx gocontext.Context,
+	g *graph.CompleteGraph,
+	rs *runSpec,
+	engine *core.Engine,
+	taskHashTracker *taskhash.Tracker,
+	turboCache cache.Cache,
+	base *cmdutil.CmdBase,
+) (map[string]graphvisualizer.NodeAnnotation, error) {
+	taskSummaries, err := executeDryRun(ctx, engine, g, taskHashTracker, rs, base)
+	if err != nil {
+		return nil, err
+	}
+	populateCacheState(turboCache, taskSummaries)
+	durations, err := runsummary.LastDurations(base.RepoRoot, runsummary.DefaultHistoricalRuns)
+	if err != nil {
+		return nil, err
+	}
+
+	annotations := make(map[string]graphvisualizer.NodeAnnotation, len(taskSummaries))
+	for _, task := range taskSummaries {
+		forced := rs.Opts.runcacheOpts.SkipsReadsFor(task.TaskID, task.Task, task.Package)
+		task.ExpectedCacheStatus = expectedCacheStatus(task, forced)
+		cacheStatus, hit := task.CachePrediction()
+		name := task.TaskID
+		// Single package summaries are saved with the task name only, which
+		// is also how the task is displayed
+		if rs.Opts.runOpts.singlePackage {
+			name = task.Task
+		}
+		annotations[name] = graphvisualizer.NodeAnnotation{
+			CacheStatus:  cacheStatus,
+			CacheHit:     hit,
+			LastDuration: durations[name],
+		}
+	}
+	return annotations, nil
+}
+
 // filterSinglePackageGraphForDisplay builds an equivalent graph with package names stripped from tasks.
 // Given that this should only be used in a single-package context, all of the package names are expected
 // to be //. Also, all nodes are always connected to the root node, so we are not concerned with leaving
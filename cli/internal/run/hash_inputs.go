@@ -0,0 +1,140 @@
+// Package run implements `turbo run`
+// This file implements `turbo hash-inputs`, which lists the files that are
+// hashed for a task in each workspace
+package run
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/signals"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// hashedInputs are the files hashed for a single task
+type hashedInputs struct {
+	TaskID string `json:"taskId"`
+	// Inputs are the `inputs` configured for the task. Empty means every file
+	// in the workspace is hashed.
+	Inputs []string     `json:"inputs"`
+	Hash   string       `json:"hash"`
+	Files  []hashedFile `json:"files"`
+}
+
+// hashedFile is a file hashed for a task, relative to the task's workspace
+type hashedFile struct {
+	Path string `json:"path"`
+	Hash string `json:"hash"`
+}
+
+// ExecuteHashInputs executes the `hash-inputs` command.
+func ExecuteHashInputs(helper *cmdutil.Helper, signalWatcher *signals.Watcher, args *turbostate.ParsedArgsFromRust) error {
+	base, err := helper.GetCmdBase(args)
+	if err != nil {
+		return err
+	}
+	if err := hashInputs(base, signalWatcher, args); err != nil {
+		base.LogError("hash-inputs failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+func hashInputs(base *cmdutil.CmdBase, signalWatcher *signals.Watcher, args *turbostate.ParsedArgsFromRust) error {
+	hashInputsPayload := args.Command.HashInputs
+	if hashInputsPayload.Task == "" {
+		return errors.New("a task must be specified")
+	}
+	// The task's dependencies aren't hashed, since only its own files are listed
+	runArgs := *args
+	runArgs.Command = turbostate.Command{Run: &turbostate.RunPayload{
+		Tasks:  []string{hashInputsPayload.Task},
+		Filter: hashInputsPayload.Filter,
+		Only:   true,
+	}}
+	opts, err := optsFromArgs(&runArgs)
+	if err != nil {
+		return err
+	}
+	r := configureRun(base, opts, signalWatcher)
+	prepared, err := r.prepare([]string{hashInputsPayload.Task})
+	if err != nil {
+		return err
+	}
+
+	tasks := []*hashedInputs{}
+	for _, v := range prepared.engine.TaskGraph.Vertices() {
+		taskID, ok := v.(string)
+		if !ok || taskID == prepared.g.RootNode {
+			continue
+		}
+		pkgName, taskName := util.GetPackageTaskFromId(taskID)
+		if taskName != hashInputsPayload.Task {
+			continue
+		}
+		taskDefinition, ok := prepared.g.TaskDefinitions[taskID]
+		if !ok {
+			continue
+		}
+		packageTask := &nodes.PackageTask{
+			TaskID:         taskID,
+			Task:           taskName,
+			PackageName:    pkgName,
+			TaskDefinition: taskDefinition,
+		}
+		tasks = append(tasks, newHashedInputs(
+			taskID,
+			taskDefinition.Inputs,
+			prepared.taskHashTracker.GetInputsHash(packageTask),
+			prepared.taskHashTracker.GetExpandedInputs(packageTask),
+		))
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].TaskID < tasks[j].TaskID
+	})
+
+	if hashInputsPayload.JSON {
+		rendered, err := json.MarshalIndent(tasks, "", "  ")
+		if err != nil {
+			return err
+		}
+		base.UI.Output(string(rendered))
+		return nil
+	}
+
+	for i, task := range tasks {
+		if i > 0 {
+			base.UI.Output("")
+		}
+		base.UI.Output(util.Sprintf("${BOLD}%s${RESET} ${GREY}%s, %d files${RESET}", task.TaskID, task.Hash, len(task.Files)))
+		for _, file := range task.Files {
+			base.UI.Output(util.Sprintf("  %s ${GREY}%s${RESET}", file.Path, file.Hash))
+		}
+	}
+	return nil
+}
+
+// newHashedInputs lists the hashed files of a task, sorted by path
+func newHashedInputs(taskID string, inputs []string, hash string, files map[turbopath.AnchoredUnixPath]string) *hashedInputs {
+	hashed := &hashedInputs{
+		TaskID: taskID,
+		Inputs: inputs,
+		Hash:   hash,
+		Files:  make([]hashedFile, 0, len(files)),
+	}
+	if hashed.Inputs == nil {
+		hashed.Inputs = []string{}
+	}
+	for path, fileHash := range files {
+		hashed.Files = append(hashed.Files, hashedFile{Path: path.ToString(), Hash: fileHash})
+	}
+	sort.Slice(hashed.Files, func(i, j int) bool {
+		return hashed.Files[i].Path < hashed.Files[j].Path
+	})
+	return hashed
+}
@@ -0,0 +1,24 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func TestNewHashedInputs(t *testing.T) {
+	hashed := newHashedInputs("web#build", nil, "abc", map[turbopath.AnchoredUnixPath]string{
+		"src/index.ts": "2",
+		"package.json": "1",
+	})
+	assert.DeepEqual(t, hashed, &hashedInputs{
+		TaskID: "web#build",
+		Inputs: []string{},
+		Hash:   "abc",
+		Files: []hashedFile{
+			{Path: "package.json", Hash: "1"},
+			{Path: "src/index.ts", Hash: "2"},
+		},
+	})
+}
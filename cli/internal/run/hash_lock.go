@@ -0,0 +1,92 @@
+package run
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/runsummary"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// _hashLockVersion is the version of the format of hash locks
+const _hashLockVersion = 1
+
+// _cacheKeySuffixPattern matches the suffixes that can be part of the key of an
+// artifact
+var _cacheKeySuffixPattern = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+
+// hashLock holds the hashes of the tasks of a run, written with
+// --write-hash-lock. A run with --hash-lock restores every task from the cache
+// artifact of its pinned hash, whatever hash it computes, so that the outputs of
+// a past run can be restored exactly.
+type hashLock struct {
+	Version      int    `json:"version"`
+	TurboVersion string `json:"turboVersion"`
+	GlobalHash   string `json:"globalHash"`
+	// Tasks are the hashes of the tasks that were built or restored, by taskID
+	Tasks map[string]string `json:"tasks"`
+}
+
+// validateCacheKeySuffix checks a --cache-key-suffix
+func validateCacheKeySuffix(suffix string) error {
+	if !_cacheKeySuffixPattern.MatchString(suffix) {
+		return fmt.Errorf("invalid --cache-key-suffix %q: it can only contain letters, digits, '.', '_' and '-'", suffix)
+	}
+	return nil
+}
+
+// readHashLock reads the hash lock passed to --hash-lock
+func readHashLock(repoRoot turbopath.AbsoluteSystemPath, path string) (*hashLock, error) {
+	contents, err := fs.ResolveUnknownPath(repoRoot, path).ReadFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the hash lock: %w", err)
+	}
+	lock := &hashLock{}
+	if err := json.Unmarshal(contents, lock); err != nil {
+		return nil, fmt.Errorf("failed to parse the hash lock %v: %w", path, err)
+	}
+	if lock.Version != _hashLockVersion {
+		return nil, fmt.Errorf("the hash lock %v has version %v, and this version of turbo reads version %v", path, lock.Version, _hashLockVersion)
+	}
+	if lock.Tasks == nil {
+		lock.Tasks = map[string]string{}
+	}
+	return lock, nil
+}
+
+// newHashLock pins the hashes of the tasks of a run that were built or
+// restored from the cache, which are the ones that have an artifact
+func newHashLock(turboVersion string, globalHash string, tasks []*runsummary.TaskSummary) *hashLock {
+	lock := &hashLock{
+		Version:      _hashLockVersion,
+		TurboVersion: turboVersion,
+		GlobalHash:   globalHash,
+		Tasks:        map[string]string{},
+	}
+	for _, task := range tasks {
+		if task.Execution == nil || task.Command == "" || task.ResolvedTaskDefinition == nil || !task.ResolvedTaskDefinition.ShouldCache {
+			continue
+		}
+		if task.Execution.Status == runsummary.TaskStatusBuilt || task.Execution.Status == runsummary.TaskStatusCached {
+			lock.Tasks[task.TaskID] = task.Hash
+		}
+	}
+	return lock
+}
+
+// writeHashLock writes the hashes of the run to the file given with
+// --write-hash-lock
+func writeHashLock(base *cmdutil.CmdBase, rs *runSpec, globalHash string, tasks []*runsummary.TaskSummary) error {
+	rendered, err := json.MarshalIndent(newHashLock(base.TurboVersion, globalHash, tasks), "", "  ")
+	if err != nil {
+		return err
+	}
+	lockPath := fs.ResolveUnknownPath(base.RepoRoot, rs.Opts.runOpts.writeHashLockFile)
+	if err := lockPath.EnsureDir(); err != nil {
+		return err
+	}
+	return lockPath.WriteFile(append(rendered, '\n'), 0644)
+}
@@ -0,0 +1,56 @@
+package run
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/runsummary"
+	"gotest.tools/v3/assert"
+)
+
+func TestNewHashLock(t *testing.T) {
+	cached := &fs.TaskDefinition{ShouldCache: true}
+	tasks := []*runsummary.TaskSummary{
+		{TaskID: "web#build", Hash: "a", Command: "next build", ResolvedTaskDefinition: cached, Execution: &runsummary.TaskExecutionSummary{Status: runsummary.TaskStatusBuilt}},
+		{TaskID: "ui#build", Hash: "b", Command: "tsc", ResolvedTaskDefinition: cached, Execution: &runsummary.TaskExecutionSummary{Status: runsummary.TaskStatusCached}},
+		{TaskID: "web#test", Hash: "c", Command: "jest", ResolvedTaskDefinition: cached, Execution: &runsummary.TaskExecutionSummary{Status: runsummary.TaskStatusFailed}},
+		{TaskID: "web#dev", Hash: "d", Command: "next dev", ResolvedTaskDefinition: &fs.TaskDefinition{}, Execution: &runsummary.TaskExecutionSummary{Status: runsummary.TaskStatusBuilt}},
+		{TaskID: "docs#build", Hash: "e", ResolvedTaskDefinition: cached, Execution: &runsummary.TaskExecutionSummary{Status: runsummary.TaskStatusBuilt}},
+		{TaskID: "docs#lint", Hash: "f"},
+	}
+
+	lock := newHashLock("1.9.0", "global", tasks)
+	assert.DeepEqual(t, lock, &hashLock{
+		Version:      _hashLockVersion,
+		TurboVersion: "1.9.0",
+		GlobalHash:   "global",
+		Tasks:        map[string]string{"web#build": "a", "ui#build": "b"},
+	})
+}
+
+func TestReadHashLock(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	lock := newHashLock("1.9.0", "global", nil)
+	lock.Tasks["web#build"] = "a"
+	contents, err := json.Marshal(lock)
+	assert.NilError(t, err)
+	assert.NilError(t, repoRoot.UntypedJoin("release.lock").WriteFile(contents, 0644))
+
+	read, err := readHashLock(repoRoot, "release.lock")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, read, lock)
+
+	assert.NilError(t, repoRoot.UntypedJoin("future.lock").WriteFile([]byte(`{"version": 2, "tasks": {}}`), 0644))
+	_, err = readHashLock(repoRoot, "future.lock")
+	assert.ErrorContains(t, err, "has version 2")
+
+	_, err = readHashLock(repoRoot, "missing.lock")
+	assert.ErrorContains(t, err, "failed to read the hash lock")
+}
+
+func TestValidateCacheKeySuffix(t *testing.T) {
+	assert.NilError(t, validateCacheKeySuffix("v1.2.0-rc_1"))
+	assert.ErrorContains(t, validateCacheKeySuffix("release/1.2"), "invalid --cache-key-suffix")
+	assert.ErrorContains(t, validateCacheKeySuffix(""), "invalid --cache-key-suffix")
+}
@@ -0,0 +1,194 @@
+package run
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/mitchellh/cli"
+	"github.com/vercel/turbo/cli/internal/ui"
+)
+
+// logGroup holds the output of a task for --log-order=grouped, so that it can
+// be printed in one block when the task finishes instead of being interleaved
+// with the output of the tasks running next to it. It is a cli.Ui for turbo's
+// messages about the task, and an io.Writer for the output of its command.
+type logGroup struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+type logLevel int
+
+const (
+	logLevelOutput logLevel = iota
+	logLevelInfo
+	logLevelWarn
+	logLevelError
+	logLevelRaw
+)
+
+type logEntry struct {
+	level logLevel
+	text  string
+}
+
+var _ cli.Ui = (*logGroup)(nil)
+
+func (g *logGroup) add(level logLevel, text string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.entries = append(g.entries, logEntry{level: level, text: text})
+}
+
+// Ask isn't supported, since tasks don't prompt for input
+func (g *logGroup) Ask(string) (string, error) {
+	return "", fmt.Errorf("cannot ask for input while grouping logs")
+}
+
+// AskSecret isn't supported, since tasks don't prompt for input
+func (g *logGroup) AskSecret(string) (string, error) {
+	return "", fmt.Errorf("cannot ask for input while grouping logs")
+}
+
+func (g *logGroup) Output(message string) { g.add(logLevelOutput, message) }
+func (g *logGroup) Info(message string)   { g.add(logLevelInfo, message) }
+func (g *logGroup) Warn(message string)   { g.add(logLevelWarn, message) }
+func (g *logGroup) Error(message string)  { g.add(logLevelError, message) }
+
+// Write holds the output of the task's command, which is already prefixed
+func (g *logGroup) Write(p []byte) (int, error) {
+	g.add(logLevelRaw, string(p))
+	return len(p), nil
+}
+
+// logGroupWriter prints whole log groups, one at a time
+type logGroupWriter struct {
+	mu         sync.Mutex
+	ui         cli.Ui
+	taskOutput io.Writer
+	// githubActions wraps each group in the workflow commands that make it a
+	// collapsible section in the logs of GitHub Actions
+	githubActions bool
+	// labels are the "label"s of the tasks that have one. The groups of the
+	// tasks with the same label are held until all of them have finished, and
+	// printed together under the label.
+	labels map[string]string
+	// pending are the tasks of each label that haven't finished yet
+	pending map[string]map[string]bool
+	held    map[string][][]logEntry
+}
+
+func newLogGroupWriter(ui cli.Ui, taskOutput io.Writer, labels map[string]string) *logGroupWriter {
+	if taskOutput == nil {
+		taskOutput = os.Stdout
+	}
+	pending := make(map[string]map[string]bool)
+	for taskID, label := range labels {
+		if pending[label] == nil {
+			pending[label] = make(map[string]bool)
+		}
+		pending[label][taskID] = true
+	}
+	return &logGroupWriter{
+		ui:            ui,
+		taskOutput:    taskOutput,
+		githubActions: os.Getenv("GITHUB_ACTIONS") == "true",
+		labels:        labels,
+		pending:       pending,
+		held:          make(map[string][][]logEntry),
+	}
+}
+
+// flush prints everything that was written to the group of taskID, or holds it
+// until the other tasks with the same label have finished
+func (w *logGroupWriter) flush(taskID string, group *logGroup) {
+	group.mu.Lock()
+	entries := group.entries
+	group.entries = nil
+	group.mu.Unlock()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	label, ok := w.labels[taskID]
+	if !ok {
+		w.print(taskID, entries)
+		return
+	}
+	if len(entries) > 0 {
+		w.held[label] = append(w.held[label], entries)
+	}
+	delete(w.pending[label], taskID)
+	if len(w.pending[label]) == 0 {
+		w.printLabel(label)
+	}
+}
+
+// close prints the groups that are still held, since some of the tasks with
+// their label didn't run
+func (w *logGroupWriter) close() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	labels := make([]string, 0, len(w.held))
+	for label := range w.held {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		w.printLabel(label)
+	}
+}
+
+// printLabel prints the groups held for label, under it
+func (w *logGroupWriter) printLabel(label string) {
+	groups := w.held[label]
+	delete(w.held, label)
+	if len(groups) == 0 {
+		return
+	}
+	if !w.githubActions {
+		w.ui.Output(ui.Bold(label))
+	}
+	var entries []logEntry
+	for _, group := range groups {
+		entries = append(entries, group...)
+	}
+	w.print(label, entries)
+}
+
+// print prints entries in one block, under the given title
+func (w *logGroupWriter) print(title string, entries []logEntry) {
+	if len(entries) == 0 {
+		return
+	}
+	if w.githubActions {
+		_, _ = fmt.Fprintf(w.taskOutput, "::group::%v\n", title)
+	}
+	printLogEntries(entries, w.ui, w.taskOutput)
+	if w.githubActions {
+		_, _ = fmt.Fprintln(w.taskOutput, "::endgroup::")
+	}
+}
+
+// printLogEntries prints turbo's messages to ui, and the output of commands to taskOutput
+func printLogEntries(entries []logEntry, ui cli.Ui, taskOutput io.Writer) {
+	for _, entry := range entries {
+		switch entry.level {
+		case logLevelOutput:
+			ui.Output(entry.text)
+		case logLevelInfo:
+			ui.Info(entry.text)
+		case logLevelWarn:
+			ui.Warn(entry.text)
+		case logLevelError:
+			ui.Error(entry.text)
+		case logLevelRaw:
+			_, _ = io.WriteString(taskOutput, entry.text)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package run
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mitchellh/cli"
+	"gotest.tools/v3/assert"
+)
+
+func TestLogGroupWriter(t *testing.T) {
+	var out bytes.Buffer
+	ui := &cli.BasicUi{Writer: &out, ErrorWriter: &out}
+	writer := newLogGroupWriter(ui, &out, nil)
+
+	build := &logGroup{}
+	lint := &logGroup{}
+	build.Output("web:build: cache miss, executing abc123")
+	lint.Output("web:lint: cache miss, executing def456")
+	_, _ = build.Write([]byte("web:build: compiling\n"))
+	_, _ = lint.Write([]byte("web:lint: no problems\n"))
+	_, _ = build.Write([]byte("web:build: done\n"))
+
+	writer.flush("web#lint", lint)
+	writer.flush("web#build", build)
+	writer.flush("web#test", &logGroup{})
+	assert.Equal(t, out.String(), "web:lint: cache miss, executing def456\n"+
+		"web:lint: no problems\n"+
+		"web:build: cache miss, executing abc123\n"+
+		"web:build: compiling\n"+
+		"web:build: done\n")
+
+	out.Reset()
+	writer.githubActions = true
+	lint.Output("web:lint: cache hit, replaying output def456")
+	writer.flush("web#lint", lint)
+	assert.Equal(t, out.String(), "::group::web#lint\nweb:lint: cache hit, replaying output def456\n::endgroup::\n")
+}
+
+func TestLogGroupWriterLabels(t *testing.T) {
+	var out bytes.Buffer
+	ui := &cli.BasicUi{Writer: &out, ErrorWriter: &out}
+	writer := newLogGroupWriter(ui, &out, map[string]string{
+		"web#lint":      "checks",
+		"web#typecheck": "checks",
+		"docs#lint":     "checks",
+	})
+
+	lint := &logGroup{}
+	build := &logGroup{}
+	typecheck := &logGroup{}
+	lint.Output("web:lint: no problems")
+	build.Output("web:build: done")
+	typecheck.Output("web:typecheck: no errors")
+
+	writer.flush("web#lint", lint)
+	writer.flush("web#build", build)
+	assert.Equal(t, out.String(), "web:build: done\n", "web#lint is held until the other checks finish")
+
+	out.Reset()
+	writer.flush("web#typecheck", typecheck)
+	writer.close()
+	assert.Equal(t, out.String(), "checks\nweb:lint: no problems\nweb:typecheck: no errors\n", "docs#lint didn't run")
+}
@@ -0,0 +1,138 @@
+package run
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/vercel/turbo/cli/internal/colorcache"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/ui"
+)
+
+// _logPrefixNone removes the prefix from the logs of tasks
+const _logPrefixNone = "none"
+
+// The placeholders of a log prefix template
+const (
+	_logPrefixPackage = "{package}"
+	_logPrefixTask    = "{task}"
+	_logPrefixHash    = "{hash}"
+	_logPrefixElapsed = "{elapsed}"
+	_logPrefixLabel   = "{label}"
+)
+
+var _logPrefixPlaceholders = []string{_logPrefixPackage, _logPrefixTask, _logPrefixHash, _logPrefixElapsed, _logPrefixLabel}
+
+// _shortHashLength is how much of the hash of a task {hash} shows
+const _shortHashLength = 8
+
+// logPrefixStatus is how far a task has come, for prefixes colored by status
+type logPrefixStatus int
+
+const (
+	// logPrefixPending is before the cache is checked, so lines replayed from
+	// a cache hit keep it
+	logPrefixPending logPrefixStatus = iota
+	logPrefixExecuting
+	logPrefixFailed
+)
+
+// logPrefix renders the prefix of the lines that tasks log from a template,
+// such as "{package}:{task}: "
+type logPrefix struct {
+	// parts are the literal text and the placeholders of the template, in order
+	parts         []string
+	colorByStatus bool
+	colors        *colorcache.ColorCache
+}
+
+// newLogPrefix parses a --log-prefix template. An empty template selects the
+// default one, and "none" removes the prefix.
+func newLogPrefix(template string, prefixColor string, singlePackage bool, colors *colorcache.ColorCache) (*logPrefix, error) {
+	p := &logPrefix{
+		colorByStatus: prefixColor == fs.LogPrefixColorStatus,
+		colors:        colors,
+	}
+	switch template {
+	case _logPrefixNone:
+		return p, nil
+	case "":
+		template = _logPrefixPackage + ":" + _logPrefixTask + ": "
+		if singlePackage {
+			template = _logPrefixTask + ": "
+		}
+	}
+	for rest := template; rest != ""; {
+		start := strings.Index(rest, "{")
+		if start == -1 {
+			p.parts = append(p.parts, rest)
+			break
+		}
+		if start > 0 {
+			p.parts = append(p.parts, rest[:start])
+		}
+		end := strings.Index(rest[start:], "}")
+		if end == -1 {
+			return nil, fmt.Errorf("invalid log prefix %q: unclosed %q", template, rest[start:])
+		}
+		placeholder := rest[start : start+end+1]
+		if !isLogPrefixPlaceholder(placeholder) {
+			return nil, fmt.Errorf("invalid log prefix %q: unknown placeholder %v, expected one of %v", template, placeholder, strings.Join(_logPrefixPlaceholders, ", "))
+		}
+		p.parts = append(p.parts, placeholder)
+		rest = rest[start+end+1:]
+	}
+	return p, nil
+}
+
+func isLogPrefixPlaceholder(placeholder string) bool {
+	for _, known := range _logPrefixPlaceholders {
+		if placeholder == known {
+			return true
+		}
+	}
+	return false
+}
+
+// render returns the colored prefix of a task. elapsed is how long after the
+// start of the run the task started.
+func (p *logPrefix) render(packageTask *nodes.PackageTask, elapsed time.Duration, status logPrefixStatus) string {
+	var prefix strings.Builder
+	for _, part := range p.parts {
+		switch part {
+		case _logPrefixPackage:
+			prefix.WriteString(packageTask.PackageName)
+		case _logPrefixTask:
+			prefix.WriteString(packageTask.Task)
+		case _logPrefixHash:
+			hash := packageTask.Hash
+			if len(hash) > _shortHashLength {
+				hash = hash[:_shortHashLength]
+			}
+			prefix.WriteString(hash)
+		case _logPrefixElapsed:
+			prefix.WriteString("+" + formatElapsed(elapsed))
+		case _logPrefixLabel:
+			prefix.WriteString(packageTask.TaskDefinition.Label)
+		default:
+			prefix.WriteString(part)
+		}
+	}
+	if prefix.Len() == 0 {
+		return ""
+	}
+	if !p.colorByStatus {
+		return p.colors.WithColor(packageTask.PackageName, prefix.String())
+	}
+	switch status {
+	case logPrefixExecuting:
+		return color.CyanString("%s", prefix.String())
+	case logPrefixFailed:
+		return color.RedString("%s", prefix.String())
+	default:
+		return ui.Dim(prefix.String())
+	}
+}
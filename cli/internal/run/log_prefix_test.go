@@ -0,0 +1,41 @@
+package run
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/colorcache"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/nodes"
+	"gotest.tools/v3/assert"
+)
+
+func TestLogPrefix(t *testing.T) {
+	packageTask := &nodes.PackageTask{
+		PackageName:    "web",
+		Task:           "build",
+		Hash:           "0123456789abcdef",
+		TaskDefinition: &fs.TaskDefinition{Label: "apps"},
+	}
+	testCases := []struct {
+		template      string
+		singlePackage bool
+		want          string
+	}{
+		{template: "", want: "web:build: "},
+		{template: "", singlePackage: true, want: "build: "},
+		{template: "none", want: ""},
+		{template: "[{label}] {task} {hash} {elapsed} ", want: "[apps] build 01234567 +2.5s "},
+		{template: "{package} › ", want: "web › "},
+	}
+	for _, tc := range testCases {
+		prefix, err := newLogPrefix(tc.template, fs.LogPrefixColorPackage, tc.singlePackage, colorcache.New())
+		assert.NilError(t, err, tc.template)
+		assert.Equal(t, prefix.render(packageTask, 2500*time.Millisecond, logPrefixPending), tc.want, tc.template)
+	}
+
+	_, err := newLogPrefix("{pkg}:{task}", fs.LogPrefixColorPackage, false, nil)
+	assert.ErrorContains(t, err, "unknown placeholder {pkg}")
+	_, err = newLogPrefix("{task", fs.LogPrefixColorPackage, false, nil)
+	assert.ErrorContains(t, err, `unclosed "{task"`)
+}
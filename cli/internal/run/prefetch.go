@@ -0,0 +1,193 @@
+package run
+
+import (
+	gocontext "context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/graph"
+	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/runcache"
+	"github.com/vercel/turbo/cli/internal/runsummary"
+	"github.com/vercel/turbo/cli/internal/signals"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// prefetchArtifacts hashes every task of the graph ahead of the run, walking it
+// serially like a dry run does, and starts downloading the remote artifacts of
+// the tasks in the order they are likely to run. Tasks that fail to hash are
+// left out: the run reports the error when it reaches them.
+func prefetchArtifacts(ctx gocontext.Context, engine *core.Engine, g *graph.CompleteGraph, rs *runSpec, runCache *runcache.RunCache, base *cmdutil.CmdBase) {
+	tasks := []*nodes.PackageTask{}
+	collect := func(ctx gocontext.Context, packageTask *nodes.PackageTask, taskSummary *runsummary.TaskSummary) error {
+		if packageTask.Command != "" {
+			tasks = append(tasks, packageTask)
+		}
+		return nil
+	}
+	getArgs := func(taskID string) []string {
+		return rs.ArgsForTask(taskID)
+	}
+	visitorFn := g.GetPackageTaskVisitor(ctx, engine.TaskGraph, getArgs, base.Logger, collect)
+	_ = engine.Execute(visitorFn, core.EngineExecutionOptions{Concurrency: 1})
+	base.Logger.Debug("prefetching remote artifacts", "tasks", len(tasks))
+	runCache.Prefetch(tasks)
+}
+
+// ExecutePrefetch executes the `prefetch` command.
+func ExecutePrefetch(ctx gocontext.Context, helper *cmdutil.Helper, signalWatcher *signals.Watcher, args *turbostate.ParsedArgsFromRust) error {
+	base, err := helper.GetCmdBase(args)
+	if err != nil {
+		return err
+	}
+	if err := prefetch(ctx, base, signalWatcher, args); err != nil {
+		base.LogError("prefetch failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// prefetch hashes the tasks of the current tree like a dry run does, and
+// downloads the artifacts of the remote cache that the local cache is missing,
+// without running anything or changing the workspace
+func prefetch(ctx gocontext.Context, base *cmdutil.CmdBase, signalWatcher *signals.Watcher, args *turbostate.ParsedArgsFromRust) error {
+	prefetchPayload := args.Command.Prefetch
+	tasks := prefetchPayload.Tasks
+	if len(tasks) == 0 {
+		pipelineTasks, err := pipelineTaskNames(base.RepoRoot)
+		if err != nil {
+			return err
+		}
+		tasks = pipelineTasks
+	}
+	if len(tasks) == 0 {
+		return errors.New("no tasks to prefetch, turbo.json has no pipeline")
+	}
+	runArgs := *args
+	runArgs.Command = turbostate.Command{Run: &turbostate.RunPayload{
+		Tasks:    tasks,
+		Filter:   prefetchPayload.Filter,
+		CacheDir: prefetchPayload.CacheDir,
+	}}
+	if base.UserConfig != nil {
+		applyUserDefaults(runArgs.Command.Run, base.UserConfig)
+	}
+	opts, err := optsFromArgs(&runArgs)
+	if err != nil {
+		return err
+	}
+	r := configureRun(base, opts, signalWatcher)
+	if r.opts.cacheOpts.SkipFilesystem {
+		return errors.New("there is no local cache to prefetch into, TURBO_REMOTE_ONLY is set")
+	}
+	prepared, err := r.prepare(tasks)
+	if err != nil {
+		return err
+	}
+
+	analyticsClient := r.initAnalyticsClient(ctx)
+	defer analyticsClient.CloseWithTimeout(50 * time.Millisecond)
+	if r.opts.cacheOpts.SkipRemote {
+		return errors.New("remote caching is not enabled, run `turbo login` and `turbo link` to enable it")
+	}
+	turboCache, err := r.initCache(ctx, prepared.rs, analyticsClient)
+	if err != nil {
+		return errors.Wrap(err, "failed to set up caching")
+	}
+	defer turboCache.Shutdown()
+
+	taskSummaries, err := executeDryRun(ctx, prepared.engine, prepared.g, prepared.taskHashTracker, prepared.rs, base)
+	if err != nil {
+		return err
+	}
+	populateCacheState(turboCache, taskSummaries)
+
+	var cached, missing, failed int
+	toFetch := []*runsummary.TaskSummary{}
+	for _, task := range taskSummaries {
+		definition := task.ResolvedTaskDefinition
+		if task.Command == runsummary.MissingTaskLabel || (definition != nil && (!definition.ShouldCache || definition.Persistent)) {
+			continue
+		}
+		switch {
+		case task.CacheState.Local:
+			cached++
+		case task.CacheState.Remote:
+			toFetch = append(toFetch, task)
+		default:
+			missing++
+		}
+	}
+	hashes := make([]string, len(toFetch))
+	for i, task := range toFetch {
+		hashes[i] = task.Hash
+	}
+	turboCache.Prefetch(hashes)
+	for _, task := range toFetch {
+		if err := fetchArtifact(turboCache, task.Hash); err != nil {
+			failed++
+			base.LogWarning(fmt.Sprintf("failed to download the artifact of %v", task.TaskID), err)
+			continue
+		}
+		base.UI.Output(util.Sprintf("${GREY}downloaded${RESET} %s ${GREY}%s${RESET}", task.TaskID, task.Hash))
+	}
+	base.UI.Output("")
+	base.UI.Output(fmt.Sprintf("%v artifacts downloaded, %v already in the local cache and %v not in the remote cache", len(toFetch)-failed, cached, missing))
+	if failed > 0 {
+		return fmt.Errorf("%v artifacts failed to download", failed)
+	}
+	return nil
+}
+
+// fetchArtifact downloads the artifact for hash into the local cache. Fetching
+// it from the remote cache stores it in the local one, and the files are
+// restored into a temporary directory so that the workspace isn't changed.
+func fetchArtifact(turboCache cache.Cache, hash string) error {
+	dir, err := os.MkdirTemp("", "turbo-prefetch")
+	if err != nil {
+		return err
+	}
+	restoreRoot := fs.AbsoluteSystemPathFromUpstream(dir)
+	defer func() { _ = restoreRoot.RemoveAll() }()
+
+	hit, _, _, err := turboCache.Fetch(restoreRoot, hash, nil)
+	if err != nil {
+		return err
+	}
+	if !hit {
+		return errors.New("the remote cache no longer has it")
+	}
+	return nil
+}
+
+// pipelineTaskNames returns the names of the tasks in the pipeline of turbo.json,
+// which are prefetched when no tasks are given
+func pipelineTaskNames(repoRoot turbopath.AbsoluteSystemPath) ([]string, error) {
+	rootPackageJSON, err := fs.ReadPackageJSON(repoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package.json: %w", err)
+	}
+	turboJSON, err := fs.LoadTurboConfig(repoRoot, rootPackageJSON, false)
+	if err != nil {
+		return nil, err
+	}
+	names := make(util.Set)
+	for taskID := range turboJSON.Pipeline {
+		if util.IsPackageTask(taskID) {
+			_, taskID = util.GetPackageTaskFromId(taskID)
+		}
+		names.Add(taskID)
+	}
+	tasks := names.UnsafeListOfStrings()
+	sort.Strings(tasks)
+	return tasks, nil
+}
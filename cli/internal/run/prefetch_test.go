@@ -0,0 +1,25 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+func TestPipelineTaskNames(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	assert.NilError(t, repoRoot.UntypedJoin("package.json").WriteFile([]byte(`{"name": "monorepo"}`), 0644))
+	assert.NilError(t, repoRoot.UntypedJoin("turbo.json").WriteFile([]byte(`{
+		"pipeline": {
+			"build": {},
+			"web#build": {},
+			"docs#test": {},
+			"//#lint": {}
+		}
+	}`), 0644))
+
+	tasks, err := pipelineTaskNames(repoRoot)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, tasks, []string{"build", "lint", "test"})
+}
@@ -0,0 +1,240 @@
+package run
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/vercel/turbo/cli/internal/ui"
+)
+
+// _progressRefreshInterval is how often the progress line is redrawn, to count
+// down the time left while no output is written
+const _progressRefreshInterval = time.Second
+
+// _clearLine moves to the start of the line and erases it
+const _clearLine = "\r\x1b[K"
+
+// progressLine keeps a line at the bottom of the terminal for --progress, with
+// how many tasks have finished and how long the rest of the run is expected to
+// take. The output of tasks and turbo's messages are written through it, so
+// that the line is erased before they are written and drawn again after them.
+type progressLine struct {
+	mu  sync.Mutex
+	out io.Writer
+	now func() time.Time
+
+	total       int
+	concurrency int
+	// durations are how long tasks took the last time they were executed
+	durations map[string]time.Duration
+	started   map[string]time.Time
+	finished  int
+	// observed are the durations of the tasks that finished in this run,
+	// which stand in for the tasks without a duration from a previous run
+	observed []time.Duration
+
+	// drawn is whether the line is on the screen
+	drawn bool
+	// midLine is whether the last write didn't end with a newline, in which
+	// case the line waits for the rest of it before it is drawn
+	midLine bool
+
+	done    chan struct{}
+	stopped sync.WaitGroup
+}
+
+func newProgressLine(out io.Writer, total int, concurrency int, durations map[string]time.Duration) *progressLine {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &progressLine{
+		out:         out,
+		now:         time.Now,
+		total:       total,
+		concurrency: concurrency,
+		durations:   durations,
+		started:     make(map[string]time.Time),
+		done:        make(chan struct{}),
+	}
+}
+
+// start draws the line, and redraws it every second until stop is called
+func (p *progressLine) start() {
+	p.redraw()
+	p.stopped.Add(1)
+	go func() {
+		defer p.stopped.Done()
+		ticker := time.NewTicker(_progressRefreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.done:
+				return
+			case <-ticker.C:
+				p.redraw()
+			}
+		}
+	}()
+}
+
+// stop erases the line for good, before the summary of the run is printed
+func (p *progressLine) stop() {
+	close(p.done)
+	p.stopped.Wait()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.clear()
+}
+
+// taskStarted counts a task as running
+func (p *progressLine) taskStarted(taskID string) {
+	p.mu.Lock()
+	p.started[taskID] = p.now()
+	p.mu.Unlock()
+	p.redraw()
+}
+
+// taskFinished counts a task as finished, whatever its outcome
+func (p *progressLine) taskFinished(taskID string) {
+	p.mu.Lock()
+	if startedAt, ok := p.started[taskID]; ok {
+		p.observed = append(p.observed, p.now().Sub(startedAt))
+		delete(p.started, taskID)
+	}
+	p.finished++
+	p.mu.Unlock()
+	p.redraw()
+}
+
+func (p *progressLine) redraw() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.midLine {
+		return
+	}
+	p.clear()
+	_, _ = io.WriteString(p.out, p.line())
+	p.drawn = true
+}
+
+// clear erases the line if it is on the screen. p.mu must be held.
+func (p *progressLine) clear() {
+	if p.drawn {
+		_, _ = io.WriteString(p.out, _clearLine)
+		p.drawn = false
+	}
+}
+
+// line returns the text of the progress line. p.mu must be held.
+func (p *progressLine) line() string {
+	text := fmt.Sprintf("• %v/%v tasks done, %v running", p.finished, p.total, len(p.started))
+	if left, ok := p.timeLeft(); ok {
+		text += fmt.Sprintf(", about %v left", formatTimeLeft(left))
+	}
+	return ui.Dim(text)
+}
+
+// timeLeft estimates how long the tasks that haven't finished will take: the
+// work left spread over the concurrency of the run, but no less than the task
+// that is expected to finish last. Tasks without a duration from a previous
+// run are expected to take as long as the average of the others. There is no
+// estimate until some task has a duration. p.mu must be held.
+func (p *progressLine) timeLeft() (time.Duration, bool) {
+	var known time.Duration
+	count := 0
+	for _, duration := range p.durations {
+		known += duration
+		count++
+	}
+	for _, duration := range p.observed {
+		known += duration
+		count++
+	}
+	if count == 0 {
+		return 0, false
+	}
+	average := known / time.Duration(count)
+	expected := func(taskID string) time.Duration {
+		if duration, ok := p.durations[taskID]; ok {
+			return duration
+		}
+		return average
+	}
+
+	now := p.now()
+	var work, longest time.Duration
+	for taskID, startedAt := range p.started {
+		left := expected(taskID) - now.Sub(startedAt)
+		if left < 0 {
+			left = 0
+		}
+		work += left
+		if left > longest {
+			longest = left
+		}
+	}
+	if waiting := p.total - p.finished - len(p.started); waiting > 0 {
+		work += time.Duration(waiting) * average
+	}
+	left := work / time.Duration(p.concurrency)
+	if longest > left {
+		left = longest
+	}
+	return left, true
+}
+
+// formatTimeLeft rounds the estimate to what it can tell apart
+func formatTimeLeft(left time.Duration) string {
+	if left < time.Minute {
+		return left.Round(time.Second).String()
+	}
+	return (left + 30*time.Second).Truncate(time.Minute).String()
+}
+
+// writer returns a writer to w that keeps the line below what is written
+func (p *progressLine) writer(w io.Writer) io.Writer {
+	return &progressWriter{progress: p, w: w}
+}
+
+type progressWriter struct {
+	progress *progressLine
+	w        io.Writer
+}
+
+func (pw *progressWriter) Write(b []byte) (int, error) {
+	p := pw.progress
+	p.mu.Lock()
+	p.clear()
+	n, err := pw.w.Write(b)
+	p.midLine = len(b) > 0 && !bytes.HasSuffix(b, []byte("\n"))
+	p.mu.Unlock()
+	p.redraw()
+	return n, err
+}
+
+// progressUi is a cli.Ui that keeps the progress line below its messages
+type progressUi struct {
+	cli.Ui
+	progress *progressLine
+}
+
+var _ cli.Ui = (*progressUi)(nil)
+
+func (u *progressUi) write(print func(string), message string) {
+	p := u.progress
+	p.mu.Lock()
+	p.clear()
+	print(message)
+	p.midLine = false
+	p.mu.Unlock()
+	p.redraw()
+}
+
+func (u *progressUi) Output(message string) { u.write(u.Ui.Output, message) }
+func (u *progressUi) Info(message string)   { u.write(u.Ui.Info, message) }
+func (u *progressUi) Warn(message string)   { u.write(u.Ui.Warn, message) }
+func (u *progressUi) Error(message string)  { u.write(u.Ui.Error, message) }
@@ -0,0 +1,71 @@
+package run
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestProgressTimeLeft(t *testing.T) {
+	now := time.Unix(1000, 0)
+	p := newProgressLine(&bytes.Buffer{}, 4, 2, map[string]time.Duration{
+		"web#build": time.Minute,
+		"ui#build":  20 * time.Second,
+	})
+	p.now = func() time.Time { return now }
+	p.started["web#build"] = now.Add(-10 * time.Second)
+
+	// web#build has 50s left, and the three tasks that haven't started are
+	// expected to take the average of 40s, over a concurrency of 2
+	left, ok := p.timeLeft()
+	assert.Assert(t, ok)
+	assert.Equal(t, left, 85*time.Second)
+
+	// A running task that is expected to take longer than the rest of the work
+	// spread over the concurrency decides the estimate
+	p.finished = 3
+	left, _ = p.timeLeft()
+	assert.Equal(t, left, 50*time.Second)
+}
+
+func TestProgressTimeLeftWithoutDurations(t *testing.T) {
+	p := newProgressLine(&bytes.Buffer{}, 3, 1, nil)
+	_, ok := p.timeLeft()
+	assert.Assert(t, !ok)
+
+	// Tasks that finished in this run stand in for the durations of the others
+	p.observed = []time.Duration{30 * time.Second}
+	p.finished = 1
+	left, ok := p.timeLeft()
+	assert.Assert(t, ok)
+	assert.Equal(t, left, time.Minute)
+	assert.Equal(t, formatTimeLeft(left), "1m0s")
+	assert.Equal(t, formatTimeLeft(2*time.Minute+40*time.Second), "3m0s")
+}
+
+func TestProgressWriter(t *testing.T) {
+	var out bytes.Buffer
+	p := newProgressLine(&out, 2, 1, nil)
+	w := p.writer(&out)
+
+	p.taskStarted("web#build")
+	assert.Assert(t, strings.Contains(out.String(), "0/2 tasks done, 1 running"))
+
+	// The line is erased before output, and drawn again once a line is complete
+	out.Reset()
+	_, err := w.Write([]byte("web:build: compiling"))
+	assert.NilError(t, err)
+	assert.Equal(t, out.String(), _clearLine+"web:build: compiling")
+	_, err = w.Write([]byte("...\n"))
+	assert.NilError(t, err)
+	assert.Assert(t, strings.HasPrefix(out.String(), _clearLine+"web:build: compiling...\n"))
+	assert.Assert(t, strings.Contains(out.String(), "0/2 tasks done, 1 running"))
+
+	out.Reset()
+	p.taskFinished("web#build")
+	assert.Assert(t, strings.HasPrefix(out.String(), _clearLine))
+	assert.Assert(t, strings.Contains(out.String(), "1/2 tasks done, 0 running"))
+}
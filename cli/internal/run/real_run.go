@@ -2,11 +2,16 @@ package run
 
 import (
 	gocontext "context"
+	"crypto/tls"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fatih/color"
@@ -18,19 +23,39 @@ import (
 	"github.com/vercel/turbo/cli/internal/cmdutil"
 	"github.com/vercel/turbo/cli/internal/colorcache"
 	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/filetrace"
+	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/graph"
+	"github.com/vercel/turbo/cli/internal/hooks"
 	"github.com/vercel/turbo/cli/internal/logstreamer"
 	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/notify"
+	"github.com/vercel/turbo/cli/internal/otlp"
 	"github.com/vercel/turbo/cli/internal/packagemanager"
 	"github.com/vercel/turbo/cli/internal/process"
+	"github.com/vercel/turbo/cli/internal/remoteexec"
+	"github.com/vercel/turbo/cli/internal/runanalytics"
 	"github.com/vercel/turbo/cli/internal/runcache"
+	"github.com/vercel/turbo/cli/internal/runevents"
 	"github.com/vercel/turbo/cli/internal/runsummary"
+	"github.com/vercel/turbo/cli/internal/sandbox"
 	"github.com/vercel/turbo/cli/internal/spinner"
 	"github.com/vercel/turbo/cli/internal/taskhash"
 	"github.com/vercel/turbo/cli/internal/turbopath"
 	"github.com/vercel/turbo/cli/internal/ui"
+	"github.com/vercel/turbo/cli/internal/util"
+	"github.com/vercel/turbo/cli/internal/webhooks"
+	"golang.org/x/term"
 )
 
+// _analyticsWait is how long the end of a run waits for the upload of its
+// summary to the analytics endpoint
+const _analyticsWait = 2 * time.Second
+
+// _webhooksWait is how long the end of a run waits for the deliveries to the
+// webhooks that are still queued
+const _webhooksWait = 10 * time.Second
+
 // RealRun executes a set of tasks
 func RealRun(
 	ctx gocontext.Context,
@@ -48,12 +73,46 @@ func RealRun(
 ) error {
 	singlePackage := rs.Opts.runOpts.singlePackage
 
+	var events *runevents.Stream
+	if rs.Opts.runOpts.events != "" {
+		stream, err := runevents.Open(rs.Opts.runOpts.events)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := stream.Close(); err != nil {
+				base.LogWarning("", fmt.Errorf("failed to stream the events of the run: %w", err))
+			}
+		}()
+		events = stream
+	}
+	events.Emit(runevents.Event{Type: runevents.RunStarted, Targets: rs.Targets})
+	webhookDispatcher, err := webhooks.New(rs.Opts.runOpts.webhooks, runSummary.ID.String(), rs.Targets, base.Logger)
+	if err != nil {
+		return err
+	}
+
 	if singlePackage {
 		base.UI.Output(fmt.Sprintf("%s %s", ui.Dim("• Running"), ui.Dim(ui.Bold(strings.Join(rs.Targets, ", ")))))
 	} else {
 		base.UI.Output(fmt.Sprintf(ui.Dim("• Packages in scope: %v"), strings.Join(packagesInScope, ", ")))
 		base.UI.Output(fmt.Sprintf("%s %s %s", ui.Dim("• Running"), ui.Dim(ui.Bold(strings.Join(rs.Targets, ", "))), ui.Dim(fmt.Sprintf("in %v packages", rs.FilteredPkgs.Len()))))
 	}
+	if shard := rs.Opts.runOpts.shard; shard != nil {
+		base.UI.Output(ui.Dim(fmt.Sprintf("• Running shard %v with %v tasks", shard, countTasks(engine))))
+	}
+	if len(runSummary.TestSelection) > 0 {
+		selected := 0
+		for _, selection := range runSummary.TestSelection {
+			if selection.Selected {
+				selected++
+			}
+		}
+		base.UI.Output(ui.Dim(fmt.Sprintf("• Running %v of %v tests in the test map, see --dry for why", selected, len(runSummary.TestSelection))))
+	}
+	if assumedFresh := countAssumedFresh(g); assumedFresh > 0 {
+		base.UI.Output(ui.Dim(fmt.Sprintf("• Assuming %v dependencies are up to date (--only)", assumedFresh)))
+	}
 
 	// Log whether remote cache is enabled
 	useHTTPCache := !rs.Opts.cacheOpts.SkipRemote
@@ -63,15 +122,81 @@ func RealRun(
 		base.UI.Info(ui.Dim("• Remote caching disabled"))
 	}
 
-	defer func() {
-		_ = spinner.WaitFor(ctx, turboCache.Shutdown, base.UI, "...writing to cache...", 1500*time.Millisecond)
-	}()
+	hookRunner := hooks.New(rs.Opts.runOpts.hooks, base.RepoRoot, base.Logger)
+	runPayload := func(event hooks.Event) *hooks.Payload {
+		return &hooks.Payload{
+			Event:    event,
+			Targets:  rs.Targets,
+			Packages: packagesInScope,
+		}
+	}
+	if err := hookRunner.Run(runPayload(hooks.PreRun), base.UI); err != nil {
+		return err
+	}
+
+	// The cache is shut down as soon as the tasks finish, so that the run
+	// summary includes the uploads, but also on every early return
+	var shutdownOnce sync.Once
+	shutdownCache := func() {
+		shutdownOnce.Do(func() {
+			_ = spinner.WaitFor(ctx, turboCache.Shutdown, base.UI, "...writing to cache...", 1500*time.Millisecond)
+		})
+	}
+	defer shutdownCache()
 	colorCache := colorcache.New()
+	logPrefix, err := newLogPrefix(rs.Opts.runOpts.logPrefix, rs.Opts.runOpts.logPrefixColor, singlePackage, colorCache)
+	if err != nil {
+		return err
+	}
+
+	var durations map[string]time.Duration
+	if rs.Opts.runOpts.progress || (!rs.Opts.runOpts.parallel && !rs.Opts.runOpts.serialDeterministic) {
+		durations = taskDurations(base, singlePackage)
+	}
+	// The progress line is only drawn on a terminal, and not for `turbo serve`,
+	// which writes the output of tasks elsewhere
+	runcacheOpts := rs.Opts.runcacheOpts
+	var progress *progressLine
+	if rs.Opts.runOpts.progress && runcacheOpts.TaskOutput == nil {
+		if term.IsTerminal(int(os.Stdout.Fd())) {
+			total := countTasks(engine)
+			concurrency := rs.Opts.runOpts.concurrency
+			if rs.Opts.runOpts.parallel {
+				concurrency = total
+			}
+			progress = newProgressLine(os.Stdout, total, concurrency, durations)
+			runcacheOpts.TaskOutput = progress.writer(os.Stdout)
+		} else {
+			base.Logger.Debug("not showing --progress, since stdout isn't a terminal")
+		}
+	}
 
-	runCache := runcache.New(turboCache, base.RepoRoot, rs.Opts.runcacheOpts, colorCache)
+	runCache := runcache.New(turboCache, base.RepoRoot, runcacheOpts, colorCache)
+	if useHTTPCache {
+		prefetchArtifacts(ctx, engine, g, rs, runCache, base)
+	}
+
+	var remoteExecutor *remoteexec.Executor
+	if len(rs.Opts.runOpts.remoteWorkers) > 0 {
+		var tlsConfig *tls.Config
+		if rs.Opts.runOpts.remoteWorkersCA != "" {
+			config, err := remoteexec.ClientTLSConfig(rs.Opts.runOpts.remoteWorkersCA)
+			if err != nil {
+				return err
+			}
+			tlsConfig = config
+		}
+		executor, err := remoteexec.NewExecutor(rs.Opts.runOpts.remoteWorkers, os.Getenv(remoteexec.TokenEnvVar), tlsConfig)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = executor.Close() }()
+		remoteExecutor = executor
+		base.UI.Info(ui.Dim(fmt.Sprintf("• Dispatching cacheable tasks to %v workers (experimental)", len(rs.Opts.runOpts.remoteWorkers))))
+	}
 
 	ec := &execContext{
-		colorCache:      colorCache,
+		logPrefix:       logPrefix,
 		runState:        runState,
 		rs:              rs,
 		ui:              &cli.ConcurrentUi{Ui: base.UI},
@@ -82,20 +207,87 @@ func RealRun(
 		taskHashTracker: taskHashTracker,
 		repoRoot:        base.RepoRoot,
 		isSinglePackage: singlePackage,
+		hooks:           hookRunner,
+		remoteExecutor:  remoteExecutor,
+		engine:          engine,
+		completeGraph:   g,
+		globalFiles:     runSummary.GlobalHashSummary.GlobalFileHashMap,
+		events:          events,
+		webhooks:        webhookDispatcher,
+	}
+	if progress != nil {
+		ec.ui = &progressUi{Ui: ec.ui, progress: progress}
+	}
+	if rs.Opts.runOpts.logOrderGrouped {
+		// Persistent and interactive tasks are streamed, so they aren't held
+		// with the other tasks of their label
+		labels := make(map[string]string)
+		for _, vertex := range engine.TaskGraph.Vertices() {
+			taskID := dag.VertexName(vertex)
+			if definition, ok := g.TaskDefinitions[taskID]; ok && definition.Label != "" && !definition.Persistent && !definition.Interactive {
+				labels[taskID] = definition.Label
+			}
+		}
+		ec.logGroups = newLogGroupWriter(ec.ui, runcacheOpts.TaskOutput, labels)
+	}
+	if rs.Opts.runOpts.tui {
+		var taskIDs []string
+		interactive := false
+		for _, vertex := range engine.TaskGraph.Vertices() {
+			if taskID := dag.VertexName(vertex); !strings.Contains(taskID, core.ROOT_NODE_NAME) {
+				taskIDs = append(taskIDs, taskID)
+				if definition, ok := g.TaskDefinitions[taskID]; ok && definition.Interactive {
+					interactive = true
+				}
+			}
+		}
+		taskUI := newTUI("turbo run "+strings.Join(rs.Targets, " "), taskIDs, runState, processes.Close)
+		if interactive {
+			base.UI.Warn("interactive tasks need the terminal, streaming task logs instead of --ui=tui")
+		} else if err := taskUI.start(); err != nil {
+			base.UI.Warn(fmt.Sprintf("%v, streaming task logs instead", err))
+		} else {
+			defer taskUI.stop(base.UI)
+			ec.tui = taskUI
+			ec.ui = taskUI.messages
+		}
 	}
 
 	// run the thing
 	execOpts := core.EngineExecutionOptions{
-		Parallel:    rs.Opts.runOpts.parallel,
-		Concurrency: rs.Opts.runOpts.concurrency,
+		Parallel:      rs.Opts.runOpts.parallel,
+		Concurrency:   rs.Opts.runOpts.concurrency,
+		Deterministic: rs.Opts.runOpts.serialDeterministic,
+	}
+	if !execOpts.Parallel && !execOpts.Deterministic {
+		execOpts.TaskDurations = durations
+	}
+	if rs.Opts.runOpts.speculate {
+		// Tasks that are executed regardless of the cache, or elsewhere, can't
+		// be started early
+		switch {
+		case execOpts.Parallel || execOpts.Deterministic || rs.Opts.runOpts.checkDeterminism || remoteExecutor != nil || runcacheOpts.SkipReads || len(runcacheOpts.SkipReadsFor) > 0:
+			base.UI.Warn("--experimental-speculate is ignored with --parallel, --serial-deterministic, --check-determinism, --force and remote workers")
+		case runcacheOpts.OutputWatcher == nil:
+			base.UI.Warn("--experimental-speculate needs the daemon to watch the outputs of tasks, and is ignored")
+		default:
+			ec.speculation = planSpeculation(ctx, engine, g, rs, turboCache, runCache, base)
+			execOpts.Speculation = ec.speculation
+		}
 	}
 
-	taskSummaries := []*runsummary.TaskSummary{}
+	// Tasks run concurrently, so their summaries are collected by the run state
 	execFunc := func(ctx gocontext.Context, packageTask *nodes.PackageTask, taskSummary *runsummary.TaskSummary) error {
 		deps := engine.TaskGraph.DownEdges(packageTask.TaskID)
-		taskSummaries = append(taskSummaries, taskSummary)
+		runState.taskVisited(taskSummary)
 		// deps here are passed in to calculate the task hash
-		return ec.exec(ctx, packageTask, deps)
+		err := ec.exec(ctx, packageTask, deps)
+		// The summaries of tasks that were started early, but whose results
+		// were dropped, are left out of the run summary
+		if isRolledBack(err) {
+			runState.taskRolledBack(taskSummary)
+		}
+		return err
 	}
 
 	getArgs := func(taskID string) []string {
@@ -103,38 +295,252 @@ func RealRun(
 	}
 
 	visitorFn := g.GetPackageTaskVisitor(ctx, engine.TaskGraph, getArgs, base.Logger, execFunc)
-	errs := engine.Execute(visitorFn, execOpts)
-
-	// Track if we saw any child with a non-zero exit code
-	exitCode := 0
-	exitCodeErr := &process.ChildExit{}
+	var failedTasksMu sync.Mutex
+	var failedTasks []string
+	var canceledTaskIDs []string
+	if progress != nil {
+		progress.start()
+	}
+	errs := engine.Execute(func(taskID string) error {
+		// Once the run is canceled, the tasks that haven't started are recorded
+		// as canceled rather than run
+		if runState.isCanceled() {
+			runState.cancelTarget(taskID)
+			failedTasksMu.Lock()
+			canceledTaskIDs = append(canceledTaskIDs, taskID)
+			failedTasksMu.Unlock()
+			return nil
+		}
+		if progress != nil && !strings.Contains(taskID, core.ROOT_NODE_NAME) {
+			progress.taskStarted(taskID)
+			defer progress.taskFinished(taskID)
+		}
+		err := visitorFn(taskID)
+		if err != nil && !isRolledBack(err) {
+			failedTasksMu.Lock()
+			failedTasks = append(failedTasks, taskID)
+			failedTasksMu.Unlock()
+		}
+		return err
+	}, execOpts)
+	// Some of the tasks with a label may not have run, after a failure
+	ec.logGroups.close()
+	if progress != nil {
+		progress.stop()
+	}
+	taskSummaries := runState.taskSummaries()
+	// The tasks that depend on a failed task were never visited, so they are
+	// added to the summary as skipped
+	failedDependents := engine.FailedDependents(failedTasks)
+	skippedTaskIDs := make([]string, 0, len(failedDependents))
+	for taskID := range failedDependents {
+		skippedTaskIDs = append(skippedTaskIDs, taskID)
+	}
+	sort.Strings(skippedTaskIDs)
+	for _, taskID := range skippedTaskIDs {
+		runState.skip(taskID, failedDependents[taskID])
+	}
+	sort.Strings(canceledTaskIDs)
+	for _, taskID := range append(skippedTaskIDs, canceledTaskIDs...) {
+		packageName, taskName := util.GetPackageTaskFromId(taskID)
+		taskSummaries = append(taskSummaries, &runsummary.TaskSummary{
+			TaskID:  taskID,
+			Task:    taskName,
+			Package: packageName,
+		})
+	}
+	if ec.tui != nil {
+		ec.tui.stop(base.UI)
+	}
+	shutdownCache()
 
 	// Assign tasks after execution
 	runSummary.Tasks = taskSummaries
 
 	for _, err := range errs {
-		if errors.As(err, &exitCodeErr) {
-			if exitCodeErr.ExitCode > exitCode {
-				exitCode = exitCodeErr.ExitCode
-			}
-		} else if exitCode == 0 {
-			// We hit some error, it shouldn't be exit code 0
-			exitCode = 1
-		}
 		base.UI.Error(err.Error())
 	}
+	firstTaskID, firstErr := runState.FirstFailure()
+	runSummary.ExitCode = rs.Opts.runOpts.exitCode.resolve(errs, firstTaskID, firstErr)
+	if runState.isCanceled() {
+		runSummary.ExitCode.Code = _canceledExitCode
+		runSummary.ExitCode.Reason = "the run was canceled"
+	}
+	exitCode := runSummary.ExitCode.Code
+	events.Emit(runevents.Event{
+		Type:     runevents.RunFinished,
+		ExitCode: &exitCode,
+		Reason:   runSummary.ExitCode.Reason,
+	})
+
+	postRunPayload := runPayload(hooks.PostRun)
+	postRunPayload.ExitCode = &exitCode
+	runDuration := time.Since(runState.startedAt).Milliseconds()
+	postRunPayload.Duration = &runDuration
+	if err := hookRunner.Run(postRunPayload, base.UI); err != nil {
+		base.LogWarning("", err)
+	}
+
+	dependencies := make(map[string][]string, len(taskSummaries))
+	for _, taskSummary := range taskSummaries {
+		taskSummary.Execution = runState.executionSummary(taskSummary.TaskID)
+		for _, dependency := range engine.TaskGraph.DownEdges(taskSummary.TaskID).List() {
+			dependencies[taskSummary.TaskID] = append(dependencies[taskSummary.TaskID], dependency.(string))
+		}
+	}
+
+	executionSummary := runsummary.NewExecutionSummary(runState.startedAt, time.Since(runState.startedAt), exitCode, taskSummaries, dependencies, singlePackage)
+	executionSummary.AddRemoteCacheTransfers(rs.Opts.cacheOpts.RemoteStats.Transfers())
+	if reason := rs.Opts.cacheOpts.RemoteStats.Disabled(); reason != "" {
+		executionSummary.DisableRemoteCache(reason)
+	}
+	if rs.Groups != nil {
+		executionSummary.AddGroups(runGroupSummaries(engine, rs.Groups, singlePackage))
+	}
+	webhookDispatcher.RunFinished(executionSummary)
+	if rs.Opts.runOpts.failureBundles {
+		if err := runSummary.WriteFailureBundles(base.RepoRoot, time.Now()); err != nil {
+			base.UI.Warn(fmt.Sprintf("Failed to write failure bundles: %s", err))
+		}
+	}
 
-	if err := runState.Close(base.UI); err != nil {
+	// The upload happens while the rest of the run is reported, and the run
+	// waits for it only briefly
+	waitForAnalytics := func() error { return nil }
+	if rs.Opts.runOpts.analytics.URL != "" {
+		// The node version isn't looked up, so that the upload doesn't slow the run
+		environment := &runsummary.RecordEnvironment{OS: runtime.GOOS, Arch: runtime.GOARCH}
+		if packageManager != nil {
+			environment.PackageManager = packageManager.Name
+		}
+		uploader := runanalytics.New(rs.Opts.runOpts.analytics, base.RepoRoot, base.Logger)
+		waitForAnalytics = uploader.Send(ctx, runanalytics.NewRun(runSummary, rs.Targets, executionSummary, environment), _analyticsWait)
+	}
+
+	// The JSON summary replaces the text stats when it is printed to stdout
+	summaryToStdout := rs.Opts.runOpts.summaryJSON && rs.Opts.runOpts.summaryFile == ""
+	if !summaryToStdout {
+		if failedTasks := executionSummary.FormatFailedTasks(base.RepoRoot); len(failedTasks) > 0 {
+			base.UI.Output("")
+			for _, line := range failedTasks {
+				base.UI.Output(line)
+			}
+		}
+	}
+	if err := runState.Close(base.UI, !summaryToStdout, executionSummary); err != nil {
 		return errors.Wrap(err, "error with profiler")
 	}
+	if rs.Opts.runOpts.resourcesTable && !summaryToStdout {
+		if resources := executionSummary.FormatResources(); len(resources) > 0 {
+			for _, line := range resources {
+				base.UI.Output(line)
+			}
+			base.UI.Output("")
+		}
+	}
+	if rs.Opts.runOpts.summaryJSON {
+		if err := writeExecutionSummary(base, rs, executionSummary); err != nil {
+			base.UI.Warn(fmt.Sprintf("Failed to write execution summary: %s", err))
+		}
+	}
+	if rs.Opts.runOpts.junitReportFile != "" {
+		if err := writeJUnitReport(base, rs, executionSummary); err != nil {
+			base.UI.Warn(fmt.Sprintf("Failed to write JUnit report: %s", err))
+		}
+	}
+	if rs.Opts.runOpts.htmlReportFile != "" {
+		if err := writeHTMLReport(base, rs, executionSummary); err != nil {
+			base.UI.Warn(fmt.Sprintf("Failed to write HTML report: %s", err))
+		}
+	}
+
+	if len(rs.Opts.runOpts.notifications) > 0 {
+		notifier := notify.New(rs.Opts.runOpts.notifications, base.RepoRoot, base.Logger)
+		if err := notifier.Send(ctx, &notify.Run{
+			Targets:   rs.Targets,
+			Attempted: executionSummary.Attempted,
+			Succeeded: executionSummary.Successful,
+			Cached:    executionSummary.Cached,
+			Duration:  time.Since(runState.startedAt),
+			Tasks:     taskSummaries,
+		}); err != nil {
+			base.LogWarning("", err)
+		}
+	}
+
+	if rs.Opts.runOpts.otlpURL != "" {
+		exporter, err := otlp.New(rs.Opts.runOpts.otlpURL)
+		if err == nil {
+			err = exporter.Export(ctx, &otlp.Run{
+				TurboVersion: base.TurboVersion,
+				Targets:      rs.Targets,
+				StartedAt:    runState.startedAt,
+				Duration:     time.Since(runState.startedAt),
+				ExitCode:     exitCode,
+				Tasks:        taskSummaries,
+			})
+		}
+		if err != nil {
+			base.LogWarning("", err)
+		}
+	}
+
+	if rs.Opts.runOpts.prReportFile != "" {
+		if err := writePRReport(base, rs, runSummary); err != nil {
+			base.UI.Warn(fmt.Sprintf("Failed to write pull request report: %s", err))
+		}
+	}
+
+	if rs.Opts.runOpts.githubActions {
+		if err := reportToGitHubActions(base, rs, runSummary); err != nil {
+			base.UI.Warn(fmt.Sprintf("Failed to write the GitHub Actions job summary: %s", err))
+		}
+	}
+
+	if rs.Opts.runOpts.writeHashLockFile != "" {
+		if err := writeHashLock(base, rs, g.GlobalHash, taskSummaries); err != nil {
+			base.UI.Warn(fmt.Sprintf("Failed to write hash lock: %s", err))
+		}
+	}
+
+	if rs.Opts.runOpts.recordFile != "" {
+		if err := writeRunRecord(rs, base, engine, g.GlobalHash, runSummary, packageManager); err != nil {
+			base.UI.Warn(fmt.Sprintf("Failed to write run record: %s", err))
+		}
+	}
 
 	// Write Run Summary if we wanted to
 	if rs.Opts.runOpts.summarize {
+		// Saved so that runs can be compared with `turbo runs list`
+		runSummary.Execution = &executionSummary.ExecutionStats
+		runSummary.Environment = recordEnvironment(base, packageManager)
+		flakyTasks, err := runSummary.AnnotateFlakiness(base.RepoRoot, singlePackage)
+		if err != nil {
+			base.UI.Warn(fmt.Sprintf("Failed to analyze previous run summaries: %s", err))
+		}
+		for _, flakyTask := range flakyTasks {
+			base.UI.Warn(fmt.Sprintf("%v is flaky: %v of %v runs failed for a hash that has also passed", flakyTask.TaskID, flakyTask.FlakyFailures, flakyTask.Executions))
+		}
+		if len(flakyTasks) > 0 {
+			base.UI.Info(ui.Dim("Run `turbo insights flaky` to report flaky tasks across recent runs"))
+		}
 		if err := runSummary.Save(base.RepoRoot, singlePackage); err != nil {
 			base.UI.Warn(fmt.Sprintf("Failed to write run summary: %s", err))
+		} else if retention := rs.Opts.runOpts.summaryRetention; !retention.IsEmpty() {
+			if _, err := runsummary.CleanRuns(base.RepoRoot, retention, time.Now()); err != nil {
+				base.UI.Warn(fmt.Sprintf("Failed to remove old run summaries: %s", err))
+			}
 		}
 	}
 
+	if err := webhookDispatcher.Close(_webhooksWait); err != nil {
+		base.LogWarning("", err)
+	}
+
+	if err := waitForAnalytics(); err != nil {
+		base.Logger.Warn("failed to upload the run, it will be uploaded with the next run", "error", err)
+	}
+
 	if exitCode != 0 {
 		return &process.ChildExit{
 			ExitCode: exitCode,
@@ -144,7 +550,7 @@ func RealRun(
 }
 
 type execContext struct {
-	colorCache      *colorcache.ColorCache
+	logPrefix       *logPrefix
 	runState        *RunState
 	rs              *runSpec
 	ui              cli.Ui
@@ -155,6 +561,28 @@ type execContext struct {
 	taskHashTracker *taskhash.Tracker
 	repoRoot        turbopath.AbsoluteSystemPath
 	isSinglePackage bool
+	hooks           *hooks.Runner
+	remoteExecutor  *remoteexec.Executor
+	// engine, completeGraph and globalFiles describe the files that are
+	// materialized in the sandbox of a task with --experimental-sandbox
+	engine        *core.Engine
+	completeGraph *graph.CompleteGraph
+	globalFiles   map[turbopath.AnchoredUnixPath]string
+	// logGroups prints the output of each task in one block with
+	// --log-order=grouped, and is nil when output is streamed
+	logGroups *logGroupWriter
+	// tui shows the tasks in a full-screen terminal UI with --ui=tui, and
+	// takes the place of logGroups
+	tui *tui
+	// events streams what happens to each task with --events, and is nil
+	// otherwise
+	events *runevents.Stream
+	// webhooks sends the status of each task to the webhooks of turbo.json,
+	// and is nil without any
+	webhooks *webhooks.Dispatcher
+	// speculation starts tasks before their dependencies finish with
+	// --experimental-speculate, and is nil otherwise
+	speculation *core.Speculation
 }
 
 func (ec *execContext) logError(log hclog.Logger, prefix string, err error) {
@@ -176,7 +604,7 @@ func (ec *execContext) exec(ctx gocontext.Context, packageTask *nodes.PackageTas
 	// Setup tracer
 	tracer := ec.runState.Run(packageTask.TaskID)
 
-	passThroughArgs := ec.rs.ArgsForTask(packageTask.Task)
+	passThroughArgs := ec.rs.ArgsForTask(packageTask.TaskID)
 	hash := packageTask.Hash
 	ec.logger.Debug("task hash", "value", hash)
 	// TODO(gsoltis): if/when we fix https://github.com/vercel/turbo/issues/937
@@ -185,61 +613,438 @@ func (ec *execContext) exec(ctx gocontext.Context, packageTask *nodes.PackageTas
 	//
 	// bail if the script doesn't exist
 	if packageTask.Command == "" {
+		if ec.tui != nil {
+			ec.tui.remove(packageTask.TaskID)
+		} else if ec.logGroups != nil {
+			// Releases the tasks with the same label
+			ec.logGroups.flush(packageTask.TaskID, &logGroup{})
+		}
 		progressLogger.Debug("no task in package, skipping")
 		progressLogger.Debug("done", "status", "skipped", "duration", time.Since(cmdTime))
 		return nil
 	}
 
-	var prefix string
-	var prettyPrefix string
-	if ec.rs.Opts.runOpts.logPrefix == "none" {
-		prefix = ""
-	} else {
-		prefix = packageTask.OutputPrefix(ec.isSinglePackage)
+	startedAfter := time.Since(ec.runState.startedAt)
+	prettyPrefix := ec.logPrefix.render(packageTask, startedAfter, logPrefixPending)
+	ec.events.Emit(runevents.Event{Type: runevents.TaskStarted, TaskID: packageTask.TaskID, Hash: hash})
+	ec.webhooks.TaskStarted(packageTask.TaskID, hash)
+	emitTaskFinished := func(status string, exitCode int) {
+		duration := time.Since(cmdTime).Milliseconds()
+		ec.events.Emit(runevents.Event{
+			Type:     runevents.TaskFinished,
+			TaskID:   packageTask.TaskID,
+			Hash:     hash,
+			Status:   status,
+			ExitCode: &exitCode,
+			Duration: &duration,
+		})
+		ec.webhooks.TaskFinished(&webhooks.Task{
+			TaskID:   packageTask.TaskID,
+			Hash:     hash,
+			Status:   status,
+			ExitCode: &exitCode,
+			Duration: &duration,
+		})
 	}
 
-	prettyPrefix = ec.colorCache.PrefixWithColor(packageTask.PackageName, prefix)
-
 	// Cache ---------------------------------------------
 	taskCache := ec.runCache.TaskCache(packageTask, hash)
+	taskUI := ec.ui
+	if ec.tui != nil {
+		output := ec.tui.task(packageTask.TaskID)
+		taskUI = output
+		taskCache = taskCache.WithTaskOutput(output)
+	} else if ec.logGroups != nil && !packageTask.TaskDefinition.Persistent && !packageTask.TaskDefinition.Interactive {
+		// Persistent tasks never finish, and interactive ones write to the
+		// terminal, so their output is always streamed
+		group := &logGroup{}
+		defer ec.logGroups.flush(packageTask.TaskID, group)
+		taskUI = group
+		taskCache = taskCache.WithTaskOutput(group)
+	}
 	// Create a logger for replaying
 	prefixedUI := &cli.PrefixedUi{
-		Ui:           ec.ui,
+		Ui:           taskUI,
 		OutputPrefix: prettyPrefix,
 		InfoPrefix:   prettyPrefix,
 		ErrorPrefix:  prettyPrefix,
 		WarnPrefix:   prettyPrefix,
 	}
-	hit, err := taskCache.RestoreOutputs(ctx, prefixedUI, progressLogger)
+	// setPrefixStatus recolors the prefix once the status of the task changes,
+	// with --log-prefix-color=status
+	setPrefixStatus := func(status logPrefixStatus) {
+		if !ec.logPrefix.colorByStatus {
+			return
+		}
+		prettyPrefix = ec.logPrefix.render(packageTask, startedAfter, status)
+		prefixedUI.OutputPrefix = prettyPrefix
+		prefixedUI.InfoPrefix = prettyPrefix
+		prefixedUI.ErrorPrefix = prettyPrefix
+		prefixedUI.WarnPrefix = prettyPrefix
+	}
+	taskPayload := func(event hooks.Event) *hooks.Payload {
+		return &hooks.Payload{
+			Event:   event,
+			TaskID:  packageTask.TaskID,
+			Task:    packageTask.Task,
+			Package: packageTask.PackageName,
+			Hash:    hash,
+		}
+	}
+	// runPostTaskHook only warns on failure, since the task itself has already
+	// finished. It is called once the outcome of the task is known, so it also
+	// emits the event for it.
+	runPostTaskHook := func(status string, exitCode int) {
+		emitTaskFinished(status, exitCode)
+		payload := taskPayload(hooks.PostTask)
+		payload.CacheStatus = "MISS"
+		if status == runsummary.TaskStatusCached {
+			payload.CacheStatus = "HIT"
+		}
+		payload.Status = status
+		payload.ExitCode = &exitCode
+		duration := time.Since(cmdTime).Milliseconds()
+		payload.Duration = &duration
+		if err := ec.hooks.Run(payload, prefixedUI); err != nil {
+			prefixedUI.Warn(err.Error())
+		}
+	}
+
+	if err := ec.hooks.Run(taskPayload(hooks.PreTask), prefixedUI); err != nil {
+		tracer(TargetBuildFailed, err)
+		emitTaskFinished(runsummary.TaskStatusFailed, 1)
+		ec.logError(progressLogger, prettyPrefix, err)
+		if !ec.rs.Opts.runOpts.continueOnError {
+			ec.processes.Close()
+		}
+		return err
+	}
+
+	if ec.rs.Opts.runOpts.checkOutputs || ec.rs.Opts.runOpts.cleanOutputs {
+		ec.checkStaleOutputs(taskCache, prefixedUI)
+	}
+
+	hit, missReason, err := taskCache.RestoreOutputs(ctx, prefixedUI, progressLogger)
+	var cachedOutputs map[turbopath.AnchoredUnixPath]string
 	if err != nil {
 		prefixedUI.Error(fmt.Sprintf("error fetching from cache: %s", err))
 	} else if hit {
-		tracer(TargetCached, nil)
+		if ec.rs.Opts.runOpts.checkDeterminism {
+			// The task is executed anyway, and its outputs are compared with the
+			// ones that were just restored
+			cachedOutputs, err = taskCache.OutputHashes()
+			if err != nil {
+				prefixedUI.Warn(fmt.Sprintf("failed to hash cached outputs, skipping the determinism check: %v", err))
+			}
+		}
+		if cachedOutputs == nil {
+			// The limit isn't part of the hash, so it is checked for restored
+			// outputs as well
+			if err := ec.checkOutputSize(packageTask, taskCache, prefixedUI); err != nil {
+				tracer(TargetBuildFailed, err)
+				ec.logError(progressLogger, prettyPrefix, err)
+				if !ec.rs.Opts.runOpts.continueOnError {
+					ec.processes.Close()
+				}
+				runPostTaskHook(runsummary.TaskStatusFailed, 1)
+				return err
+			}
+			ec.events.Emit(runevents.Event{Type: runevents.CacheHit, TaskID: packageTask.TaskID, Hash: hash})
+			tracer(TargetCached, nil)
+			runPostTaskHook(runsummary.TaskStatusCached, 0)
+			return nil
+		}
+	}
+	if _, pinned := ec.completeGraph.PinnedHashes[packageTask.TaskID]; pinned && !hit {
+		// Executing the task could produce different outputs than the ones
+		// that were pinned
+		err := fmt.Errorf("the artifact of %v for its pinned hash %v is missing from the cache", packageTask.TaskID, hash)
+		tracer(TargetBuildFailed, err)
+		ec.logError(progressLogger, prettyPrefix, err)
+		if !ec.rs.Opts.runOpts.continueOnError {
+			ec.processes.Close()
+		}
+		runPostTaskHook(runsummary.TaskStatusFailed, 1)
+		return err
+	}
+	checkDeterminism := cachedOutputs != nil
+	setPrefixStatus(logPrefixExecuting)
+	if checkDeterminism {
+		prefixedUI.Output(fmt.Sprintf("checking determinism, executing %s", ui.Dim(hash)))
+	} else {
+		ec.runState.cacheMiss(packageTask.TaskID, missReason)
+		ec.events.Emit(runevents.Event{
+			Type:   runevents.CacheMiss,
+			TaskID: packageTask.TaskID,
+			Hash:   hash,
+			Reason: string(missReason),
+		})
+
+		if err := ec.hooks.Run(taskPayload(hooks.OnCacheMiss), prefixedUI); err != nil {
+			prefixedUI.Warn(err.Error())
+		}
+	}
+
+	// Persistent tasks never finish, and interactive ones prompt for input, so
+	// they always run locally. Workers can't
+	// select root tasks with a filter, nor run the scripts of workspaces defined
+	// in turbo.json, so those run locally too.
+	if ec.remoteExecutor != nil && !checkDeterminism && taskCache.ReadsAndWritesEnabled() && !packageTask.TaskDefinition.Persistent && !packageTask.TaskDefinition.Interactive && packageTask.PackageName != util.RootPkgName && !packageTask.Pkg.DefinedInTurboJSON {
+		exitCode, err := ec.execRemote(ctx, packageTask, passThroughArgs, taskCache, prefixedUI, progressLogger)
+		if err == nil {
+			if err = ec.checkOutputSize(packageTask, taskCache, prefixedUI); err != nil {
+				exitCode = 1
+			}
+		}
+		status := runsummary.TaskStatusBuilt
+		if err != nil {
+			status = runsummary.TaskStatusFailed
+		}
+		runPostTaskHook(status, exitCode)
+		if err != nil {
+			tracer(TargetBuildFailed, err)
+			progressLogger.Error(fmt.Sprintf("Error: remote execution finished with error: %v", err))
+			if !ec.rs.Opts.runOpts.continueOnError {
+				prefixedUI.Error(fmt.Sprintf("ERROR: command finished with error: %s", err))
+				ec.processes.Close()
+			} else {
+				prefixedUI.Warn("command finished with error, but continuing...")
+			}
+			return err
+		}
+		tracer(TargetBuilt, nil)
+		progressLogger.Debug("done", "status", "complete", "duration", time.Since(cmdTime))
 		return nil
 	}
 
+	workingDir, err := packageTask.WorkingDir()
+	if err != nil {
+		tracer(TargetBuildFailed, err)
+		ec.logError(progressLogger, prettyPrefix, err)
+		if !ec.rs.Opts.runOpts.continueOnError {
+			ec.processes.Close()
+		}
+		runPostTaskHook(runsummary.TaskStatusFailed, 1)
+		return err
+	}
+	// With --env-mode=strict, the task inherits only the variables it declares
+	environ := os.Environ()
+	var taskEnv, removedEnv []string
+	if ec.rs.Opts.runOpts.strictEnv {
+		kept, removed := ec.strictEnv(packageTask)
+		environ, removedEnv = kept.ToHashable(), removed
+		taskEnv, err = packageTask.EnvFor(ec.repoRoot, kept)
+	} else {
+		taskEnv, err = packageTask.Env(ec.repoRoot)
+	}
+	if err != nil {
+		tracer(TargetBuildFailed, err)
+		ec.logError(progressLogger, prettyPrefix, err)
+		if !ec.rs.Opts.runOpts.continueOnError {
+			ec.processes.Close()
+		}
+		runPostTaskHook(runsummary.TaskStatusFailed, 1)
+		return err
+	}
+	cmdEnv := append(environ, fmt.Sprintf("TURBO_HASH=%v", hash))
+	cmdEnv = append(cmdEnv, taskEnv...)
+
 	// Setup command execution
-	argsactual := append([]string{"run"}, packageTask.Task)
-	if len(passThroughArgs) > 0 {
-		// This will be either '--' or a typed nil
-		argsactual = append(argsactual, ec.packageManager.ArgSeparator...)
-		argsactual = append(argsactual, passThroughArgs...)
+	command, err := resolveTaskCommand(ec.packageManager, packageTask, passThroughArgs, ec.repoRoot, cmdEnv)
+	if err != nil {
+		tracer(TargetBuildFailed, err)
+		ec.logError(progressLogger, prettyPrefix, err)
+		if !ec.rs.Opts.runOpts.continueOnError {
+			ec.processes.Close()
+		}
+		runPostTaskHook(runsummary.TaskStatusFailed, 1)
+		return err
 	}
+	cmdEnv = append(cmdEnv, command.env...)
+	ec.runState.commandResolved(packageTask.TaskID, command.argv())
 
-	cmd := exec.Command(ec.packageManager.Command, argsactual...)
-	cmd.Dir = packageTask.Pkg.Dir.ToSystemPath().RestoreAnchor(ec.repoRoot).ToString()
-	envs := fmt.Sprintf("TURBO_HASH=%v", hash)
-	cmd.Env = append(os.Environ(), envs)
+	// Persistent tasks keep watching the files of the repository, so they are
+	// never sandboxed
+	cmdRoot, cmdDir := ec.repoRoot, workingDir.RestoreAnchor(ec.repoRoot)
+	var taskSandbox *sandbox.Sandbox
+	if ec.rs.Opts.runOpts.sandbox && !packageTask.TaskDefinition.Persistent {
+		taskSandbox, err = ec.newSandbox(packageTask)
+		if err != nil {
+			err = fmt.Errorf("failed to create the sandbox: %w", err)
+			tracer(TargetBuildFailed, err)
+			ec.logError(progressLogger, prettyPrefix, err)
+			if !ec.rs.Opts.runOpts.continueOnError {
+				ec.processes.Close()
+			}
+			runPostTaskHook(runsummary.TaskStatusFailed, 1)
+			return err
+		}
+		defer func() { _ = taskSandbox.Close() }()
+		cmdRoot, cmdDir = taskSandbox.Root, workingDir.RestoreAnchor(taskSandbox.Root)
+	}
+
+	// Run the command, and run it again after a failure while there are retries left
+	retries := packageTask.TaskDefinition.Retries
+	var trace *filetrace.Trace
+	var closeOutputs func() error
+	for attempt := 0; ; attempt++ {
+		cmd := exec.Command(command.name, command.args...)
+		cmd.Dir = cmdDir.ToString()
+		cmd.Env = cmdEnv
+		trace, closeOutputs, err = ec.setupOutputs(cmd, packageTask.TaskID, cmdRoot, cmdDir, taskCache, prettyPrefix, prefixedUI)
+		if err != nil {
+			tracer(TargetBuildFailed, err)
+			ec.logError(progressLogger, prettyPrefix, err)
+			if !ec.rs.Opts.runOpts.continueOnError {
+				ec.processes.Close()
+			}
+			runPostTaskHook(runsummary.TaskStatusFailed, 1)
+			return err
+		}
+
+		attemptStartedAt := time.Now()
+		if packageTask.TaskDefinition.Interactive {
+			// The command is connected to the terminal, so that it can prompt
+			// for input, and its output isn't captured
+			cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+			err = ec.processes.ExecInteractive(ec.speculation.Context(packageTask.TaskID), cmd, packageTask.TaskDefinition.Timeout)
+		} else {
+			err = ec.processes.ExecWithContext(ec.speculation.Context(packageTask.TaskID), cmd, packageTask.TaskDefinition.Timeout)
+		}
+		ec.runState.resourcesUsed(packageTask.TaskID, process.Usage(cmd))
+		if retries > 0 {
+			ec.runState.commandAttempted(packageTask.TaskID, time.Since(attemptStartedAt), err)
+		}
+		if err == nil || errors.Is(err, process.ErrClosing) || errors.Is(err, gocontext.Canceled) || attempt == retries {
+			break
+		}
+
+		// The outputs of the failed attempt are discarded
+		_ = closeOutputs()
+		if trace != nil {
+			_, _ = trace.Finish()
+		}
+		taskCache.OnError(prefixedUI, progressLogger)
+		delay := retryDelay(packageTask.TaskDefinition.RetryBackoff, attempt)
+		prefixedUI.Warn(fmt.Sprintf("command finished with error (attempt %v of %v), retrying in %v: %v", attempt+1, retries+1, delay, err))
+		if !waitForRetry(ctx, delay) {
+			// The run was cancelled while waiting, and the outputs are already closed
+			closeOutputs = func() error { return nil }
+			trace = nil
+			err = process.ErrClosing
+			break
+		}
+	}
+	if err == nil && taskSandbox != nil {
+		err = taskSandbox.CopyOutputs(packageTask.RepoRelativeOutputs())
+	}
+	// A task that was started before its dependencies finished keeps neither
+	// its outputs nor its failure unless they were all restored from the cache
+	if rollbackErr := ec.awaitSpeculation(packageTask.TaskID); rollbackErr != nil {
+		_ = closeOutputs()
+		if trace != nil {
+			_, _ = trace.Finish()
+		}
+		prefixedUI.Warn(fmt.Sprintf("dropping the results of a task started early: %v", rollbackErr))
+		emitTaskFinished(runsummary.TaskStatusCanceled, 1)
+		return rollbackErr
+	}
+	// A task whose outputs are over its limit fails, and isn't cached
+	if err == nil {
+		err = ec.checkOutputSize(packageTask, taskCache, prefixedUI)
+	}
 
-	// Setup stdout/stderr
-	// If we are not caching anything, then we don't need to write logs to disk
-	// be careful about this conditional given the default of cache = true
-	writer, err := taskCache.OutputWriter(prettyPrefix)
 	if err != nil {
+		// close off our outputs. We errored, so we mostly don't care if we fail to close
+		_ = closeOutputs()
+		if trace != nil {
+			// Clean up the trace; the accesses of a failed task aren't meaningful
+			_, _ = trace.Finish()
+		}
+		// if we already know we're in the process of exiting,
+		// we don't need to record an error to that effect.
+		if errors.Is(err, process.ErrClosing) {
+			if ec.runState.isCanceled() {
+				tracer(TargetBuildStopped, nil)
+				emitTaskFinished(runsummary.TaskStatusCanceled, 1)
+			}
+			return nil
+		}
 		tracer(TargetBuildFailed, err)
-		ec.logError(progressLogger, prettyPrefix, err)
+		setPrefixStatus(logPrefixFailed)
+		progressLogger.Error(fmt.Sprintf("Error: command finished with error: %v", err))
 		if !ec.rs.Opts.runOpts.continueOnError {
-			os.Exit(1)
+			prefixedUI.Error(fmt.Sprintf("ERROR: command finished with error: %s", err))
+			ec.processes.Close()
+		} else {
+			prefixedUI.Warn("command finished with error, but continuing...")
+		}
+		var sizeErr *outputSizeError
+		if taskSandbox != nil && !errors.As(err, &sizeErr) {
+			prefixedUI.Warn(_sandboxHint)
+		}
+		warnStrictEnv(taskCache.LogFileName, removedEnv, prefixedUI)
+
+		// If there was an error, flush the buffered output
+		taskCache.OnError(prefixedUI, progressLogger)
+
+		status := runsummary.TaskStatusFailed
+		exitCode := 1
+		var childExit *process.ChildExit
+		var timedOut *process.TimedOut
+		if errors.As(err, &childExit) {
+			exitCode = childExit.ExitCode
+		} else if errors.As(err, &timedOut) {
+			status = runsummary.TaskStatusTimedOut
+		}
+		runPostTaskHook(status, exitCode)
+
+		return err
+	}
+
+	duration := time.Since(cmdTime)
+	if trace != nil {
+		ec.reportFileTrace(trace, packageTask, prefixedUI)
+	}
+	// Close off our outputs and cache them
+	if err := closeOutputs(); err != nil {
+		ec.logError(progressLogger, "", err)
+	} else if checkDeterminism {
+		// The cache entry is kept, so that it can be compared with later runs
+		ec.compareOutputs(taskCache, packageTask.TaskID, cachedOutputs, prefixedUI)
+	} else {
+		if err = taskCache.SaveOutputs(ctx, progressLogger, prefixedUI, int(duration.Milliseconds())); err != nil {
+			ec.logError(progressLogger, "", fmt.Errorf("error caching output: %w", err))
+		}
+	}
+
+	runPostTaskHook(runsummary.TaskStatusBuilt, 0)
+
+	// Clean up tracing
+	tracer(TargetBuilt, nil)
+	progressLogger.Debug("done", "status", "complete", "duration", duration)
+	return nil
+}
+
+// setupOutputs streams the output of cmd to the terminal and to the task's log
+// file, and starts tracing its file accesses with --experimental-trace-files.
+// cmd runs in pkgDir, in the repository or sandbox at root. With --events, the
+// output is also streamed as events for taskID. The returned function closes the
+// outputs once cmd exits.
+func (ec *execContext) setupOutputs(cmd *exec.Cmd, taskID string, root turbopath.AbsoluteSystemPath, pkgDir turbopath.AbsoluteSystemPath, taskCache runcache.TaskCache, prettyPrefix string, prefixedUI *cli.PrefixedUi) (*filetrace.Trace, func() error, error) {
+	// Setup stdout/stderr
+	// The task's log file is written whether or not the task is cached
+	writer, err := taskCache.OutputWriter(prettyPrefix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var trace *filetrace.Trace
+	if ec.rs.Opts.runOpts.traceFiles {
+		var traceErr error
+		trace, traceErr = filetrace.Start(cmd, root, pkgDir)
+		if traceErr != nil {
+			prefixedUI.Warn(fmt.Sprintf("failed to trace file accesses: %v", traceErr))
 		}
 	}
 
@@ -251,6 +1056,13 @@ func (ec *execContext) exec(ctx gocontext.Context, packageTask *nodes.PackageTas
 	logStreamerErr := logstreamer.NewLogstreamer(logger, prettyPrefix, false)
 	cmd.Stderr = logStreamerErr
 	cmd.Stdout = logStreamerOut
+	var eventsOut, eventsErr io.WriteCloser
+	if ec.events != nil {
+		eventsOut = ec.events.LogWriter(taskID, "stdout")
+		eventsErr = ec.events.LogWriter(taskID, "stderr")
+		cmd.Stdout = io.MultiWriter(logStreamerOut, eventsOut)
+		cmd.Stderr = io.MultiWriter(logStreamerErr, eventsErr)
+	}
 	// Flush/Reset any error we recorded
 	logStreamerErr.FlushRecord()
 	logStreamerOut.FlushRecord()
@@ -264,6 +1076,10 @@ func (ec *execContext) exec(ctx gocontext.Context, packageTask *nodes.PackageTas
 		if err := logStreamerErr.Close(); err != nil {
 			closeErrors = append(closeErrors, errors.Wrap(err, "log stderr"))
 		}
+		if ec.events != nil {
+			_ = eventsOut.Close()
+			_ = eventsErr.Close()
+		}
 
 		if err := writer.Close(); err != nil {
 			closeErrors = append(closeErrors, errors.Wrap(err, "log file"))
@@ -277,43 +1093,334 @@ func (ec *execContext) exec(ctx gocontext.Context, packageTask *nodes.PackageTas
 		}
 		return nil
 	}
+	return trace, closeOutputs, nil
+}
 
-	// Run the command
-	if err := ec.processes.Exec(cmd); err != nil {
-		// close off our outputs. We errored, so we mostly don't care if we fail to close
-		_ = closeOutputs()
-		// if we already know we're in the process of exiting,
-		// we don't need to record an error to that effect.
-		if errors.Is(err, process.ErrClosing) {
-			return nil
+// taskDurations looks up how long each task took the last time it was executed
+// in the run summaries saved in the repository, so that the engine can start
+// the tasks on the longest chains first. It is only a hint, so errors are ignored.
+func taskDurations(base *cmdutil.CmdBase, singlePackage bool) map[string]time.Duration {
+	durations, err := runsummary.LastDurations(base.RepoRoot, runsummary.DefaultHistoricalRuns)
+	if err != nil {
+		base.Logger.Debug("failed to read task durations", "error", err)
+		return nil
+	}
+	if !singlePackage {
+		return durations
+	}
+	// Single package summaries are saved with the task name only
+	taskIDs := make(map[string]time.Duration, len(durations))
+	for task, duration := range durations {
+		taskIDs[util.RootTaskID(task)] = duration
+	}
+	return taskIDs
+}
+
+// shellCommand returns the command and arguments that run script with the
+// shell, with args appended to it
+func shellCommand(script string, args []string) (string, []string) {
+	for _, arg := range args {
+		script += " " + shellQuote(arg)
+	}
+	if runtime.GOOS == "windows" {
+		return "cmd", []string{"/C", script}
+	}
+	return "sh", []string{"-c", script}
+}
+
+// shellQuote quotes arg so that the shell passes it to the script unchanged
+func shellQuote(arg string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + strings.ReplaceAll(arg, `"`, `""`) + `"`
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// retryDelay is how long to wait before the given retry of a task, starting
+// from zero, when the first retry waits backoff milliseconds
+func retryDelay(backoff int, retry int) time.Duration {
+	return time.Duration(backoff) * time.Millisecond << retry
+}
+
+// waitForRetry waits for the given delay, and returns false if the run is
+// cancelled first
+func waitForRetry(ctx gocontext.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// reportFileTrace compares the files a task accessed with its "inputs" and
+// "outputs" configuration and prints the differences
+func (ec *execContext) reportFileTrace(trace *filetrace.Trace, packageTask *nodes.PackageTask, prefixedUI *cli.PrefixedUi) {
+	accesses, err := trace.Finish()
+	if err != nil {
+		prefixedUI.Warn(fmt.Sprintf("failed to trace file accesses: %v", err))
+		return
+	}
+	report := filetrace.Analyze(accesses, packageTask.Pkg.Dir.ToUnixPath().ToString(), packageTask.TaskDefinition)
+	if len(report.UndeclaredWrites) > 0 {
+		prefixedUI.Warn(fmt.Sprintf("wrote files that are not in \"outputs\" and won't be cached: %v", formatFiles(report.UndeclaredWrites)))
+	}
+	if len(report.UnusedOutputs) > 0 {
+		prefixedUI.Warn(fmt.Sprintf("\"outputs\" globs matched no written files: %v", formatFiles(report.UnusedOutputs)))
+	}
+	if len(report.UndeclaredReads) > 0 {
+		prefixedUI.Warn(fmt.Sprintf("read files that are not in \"inputs\" and don't affect the hash: %v", formatFiles(report.UndeclaredReads)))
+	}
+	if len(report.ExternalReads) > 0 {
+		prefixedUI.Warn(fmt.Sprintf("read files outside of the package, which must be covered by globalDependencies or a workspace dependency: %v", formatFiles(report.ExternalReads)))
+	}
+	if len(report.SuggestedOutputs) > 0 && (len(report.UndeclaredWrites) > 0 || len(report.UnusedOutputs) > 0) {
+		prefixedUI.Info(fmt.Sprintf("suggested \"outputs\": %v", strings.Join(report.SuggestedOutputs, ", ")))
+	}
+	if len(report.UndeclaredReads) > 0 {
+		prefixedUI.Info(fmt.Sprintf("suggested \"inputs\": %v", strings.Join(report.SuggestedInputs, ", ")))
+	}
+	if !accesses.ReadsTracked {
+		prefixedUI.Info(ui.Dim("install strace on Linux to also check which files are read"))
+	}
+}
+
+// compareOutputs records whether a task executed by --check-determinism wrote
+// the same outputs as the ones restored from the cache
+func (ec *execContext) compareOutputs(taskCache runcache.TaskCache, taskID string, cachedOutputs map[turbopath.AnchoredUnixPath]string, prefixedUI *cli.PrefixedUi) {
+	outputs, err := taskCache.OutputHashes()
+	if err != nil {
+		prefixedUI.Warn(fmt.Sprintf("failed to hash outputs, skipping the determinism check: %v", err))
+		return
+	}
+	changedOutputs := runcache.ChangedOutputs(cachedOutputs, outputs)
+	ec.runState.determinismChecked(taskID, changedOutputs)
+	if len(changedOutputs) > 0 {
+		prefixedUI.Warn(fmt.Sprintf("outputs differ from the cache: %v", formatFiles(changedOutputs)))
+	} else {
+		prefixedUI.Info(ui.Dim("outputs match the cache"))
+	}
+}
+
+// checkStaleOutputs reports the files in the outputs of a task that were
+// neither written by its last run nor restored from the cache, since they
+// would end up in its next cache entry. They are removed with --clean-outputs.
+func (ec *execContext) checkStaleOutputs(taskCache runcache.TaskCache, prefixedUI *cli.PrefixedUi) {
+	staleOutputs, err := taskCache.StaleOutputs()
+	if err != nil {
+		prefixedUI.Warn(fmt.Sprintf("failed to check for stale outputs: %v", err))
+		return
+	} else if len(staleOutputs) == 0 {
+		return
+	}
+	files := make([]string, len(staleOutputs))
+	for i, file := range staleOutputs {
+		files[i] = file.ToString()
+	}
+	if !ec.rs.Opts.runOpts.cleanOutputs {
+		prefixedUI.Warn(fmt.Sprintf("outputs contain files that weren't written by the last run nor restored from the cache: %v. Remove them with --clean-outputs", formatFiles(files)))
+		return
+	}
+	if err := taskCache.RemoveOutputs(staleOutputs); err != nil {
+		prefixedUI.Warn(fmt.Sprintf("failed to remove stale outputs: %v", err))
+		return
+	}
+	prefixedUI.Info(ui.Dim(fmt.Sprintf("removed stale outputs: %v", formatFiles(files))))
+}
+
+// outputSizeError is returned for a task whose outputs exceed its "maxOutputSize"
+type outputSizeError struct {
+	size    int64
+	maxSize int64
+}
+
+func (e *outputSizeError) Error() string {
+	return fmt.Sprintf("outputs take up %v bytes, over the \"maxOutputSize\" of %v bytes", e.size, e.maxSize)
+}
+
+// checkOutputSize measures the outputs of a task with a "maxOutputSize" and
+// records their size in the run summary. Outputs over the limit are an error,
+// unless the task's "maxOutputSizeMode" is "warn".
+func (ec *execContext) checkOutputSize(packageTask *nodes.PackageTask, taskCache runcache.TaskCache, prefixedUI *cli.PrefixedUi) error {
+	maxSize := packageTask.TaskDefinition.MaxOutputSize
+	if maxSize == 0 {
+		return nil
+	}
+	size, err := taskCache.OutputSize()
+	if err != nil {
+		prefixedUI.Warn(fmt.Sprintf("failed to measure outputs, skipping the output size check: %v", err))
+		return nil
+	}
+	ec.runState.outputSizeMeasured(packageTask.TaskID, size, maxSize)
+	if size <= maxSize {
+		return nil
+	}
+	sizeErr := &outputSizeError{size: size, maxSize: maxSize}
+	if packageTask.TaskDefinition.WarnOnMaxOutputSize {
+		prefixedUI.Warn(sizeErr.Error())
+		return nil
+	}
+	return sizeErr
+}
+
+// _maxReportedFiles limits how many files are listed in a single trace warning
+const _maxReportedFiles = 10
+
+func formatFiles(files []string) string {
+	if len(files) <= _maxReportedFiles {
+		return strings.Join(files, ", ")
+	}
+	return fmt.Sprintf("%v and %v more", strings.Join(files[:_maxReportedFiles], ", "), len(files)-_maxReportedFiles)
+}
+
+// execRemote runs the task on a worker and returns its exit code. Once the task
+// succeeds, its outputs are restored from the cache that the worker wrote them to.
+func (ec *execContext) execRemote(ctx gocontext.Context, packageTask *nodes.PackageTask, passThroughArgs []string, taskCache runcache.TaskCache, prefixedUI *cli.PrefixedUi, progressLogger hclog.Logger) (int, error) {
+	// The worker resolves the command and outputs of the task from its own
+	// checkout, and refuses to run it if it computes a different hash
+	req := &remoteexec.ExecuteRequest{
+		TaskID: packageTask.TaskID,
+		Hash:   packageTask.Hash,
+		Args:   passThroughArgs,
+	}
+
+	progressLogger.Debug("dispatching to worker")
+	resp, err := ec.remoteExecutor.Execute(ctx, req)
+	if err != nil {
+		return 1, err
+	}
+	if resp.ExitCode != 0 {
+		for _, line := range strings.Split(strings.TrimRight(string(resp.Logs), "\n"), "\n") {
+			prefixedUI.Output(line)
 		}
-		tracer(TargetBuildFailed, err)
-		progressLogger.Error(fmt.Sprintf("Error: command finished with error: %v", err))
-		if !ec.rs.Opts.runOpts.continueOnError {
-			prefixedUI.Error(fmt.Sprintf("ERROR: command finished with error: %s", err))
-			ec.processes.Close()
+		return resp.ExitCode, &process.ChildExit{
+			ExitCode: resp.ExitCode,
+			Command:  strings.Join(append([]string{ec.packageManager.Command}, ec.packageManager.ScriptArgs(packageTask.Task, passThroughArgs)...), " "),
+		}
+	}
+
+	hit, _, err := taskCache.RestoreOutputs(ctx, prefixedUI, progressLogger)
+	if err != nil {
+		return 1, err
+	}
+	if !hit {
+		return 1, fmt.Errorf("outputs of %v were not in the cache after remote execution. Check that the workers share a remote cache with this machine", packageTask.TaskID)
+	}
+	return 0, nil
+}
+
+// writePRReport writes the Markdown report of the run for a pull request comment
+func writePRReport(base *cmdutil.CmdBase, rs *runSpec, runSummary *runsummary.RunSummary) error {
+	var baseHits *runsummary.CacheHits
+	if rs.Opts.runOpts.prReportBase != "" {
+		hits, err := runsummary.ReadCacheHits(fs.ResolveUnknownPath(base.RepoRoot, rs.Opts.runOpts.prReportBase))
+		if err != nil {
+			// The report is still useful without the comparison
+			base.UI.Warn(fmt.Sprintf("Failed to read the base run summary: %s", err))
 		} else {
-			prefixedUI.Warn("command finished with error, but continuing...")
+			baseHits = &hits
 		}
+	}
+	report := runSummary.FormatMarkdown(base.RepoRoot, rs.Targets, baseHits, rs.Opts.runOpts.singlePackage)
+	reportPath := fs.ResolveUnknownPath(base.RepoRoot, rs.Opts.runOpts.prReportFile)
+	if err := reportPath.EnsureDir(); err != nil {
+		return err
+	}
+	return reportPath.WriteFile([]byte(report), 0644)
+}
 
-		// If there was an error, flush the buffered output
-		taskCache.OnError(prefixedUI, progressLogger)
+// reportToGitHubActions prints the annotations of the failed tasks, and appends
+// the Markdown report of the run to the job summary
+func reportToGitHubActions(base *cmdutil.CmdBase, rs *runSpec, runSummary *runsummary.RunSummary) error {
+	for _, annotation := range runSummary.GitHubAnnotations(base.RepoRoot, rs.Opts.runOpts.singlePackage) {
+		base.UI.Output(annotation.String())
+	}
+	// Set by GitHub Actions to the file that the job summary is read from
+	summaryPath := os.Getenv("GITHUB_STEP_SUMMARY")
+	if summaryPath == "" {
+		return nil
+	}
+	report := runSummary.FormatMarkdown(base.RepoRoot, rs.Targets, nil, rs.Opts.runOpts.singlePackage)
+	f, err := os.OpenFile(summaryPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(report); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
 
+// writeExecutionSummary prints the JSON summary of the run, or writes it to the
+// file given with --summary-file
+func writeExecutionSummary(base *cmdutil.CmdBase, rs *runSpec, summary *runsummary.ExecutionSummary) error {
+	rendered, err := summary.FormatJSON()
+	if err != nil {
+		return err
+	}
+	if rs.Opts.runOpts.summaryFile == "" {
+		base.UI.Output(string(rendered))
+		return nil
+	}
+	summaryPath := fs.ResolveUnknownPath(base.RepoRoot, rs.Opts.runOpts.summaryFile)
+	if err := summaryPath.EnsureDir(); err != nil {
 		return err
 	}
+	return summaryPath.WriteFile(rendered, 0644)
+}
 
-	duration := time.Since(cmdTime)
-	// Close off our outputs and cache them
-	if err := closeOutputs(); err != nil {
-		ec.logError(progressLogger, "", err)
-	} else {
-		if err = taskCache.SaveOutputs(ctx, progressLogger, prefixedUI, int(duration.Milliseconds())); err != nil {
-			ec.logError(progressLogger, "", fmt.Errorf("error caching output: %w", err))
+// writeJUnitReport writes the JUnit XML report of the run to the file given
+// with --report=junit:<path>
+func writeJUnitReport(base *cmdutil.CmdBase, rs *runSpec, summary *runsummary.ExecutionSummary) error {
+	report, err := summary.FormatJUnit(base.RepoRoot)
+	if err != nil {
+		return err
+	}
+	return writeReport(base, rs.Opts.runOpts.junitReportFile, report)
+}
+
+// writeHTMLReport writes the HTML report of the run to the file given with
+// --report=html:<path>
+func writeHTMLReport(base *cmdutil.CmdBase, rs *runSpec, summary *runsummary.ExecutionSummary) error {
+	report, err := summary.FormatHTML(base.RepoRoot)
+	if err != nil {
+		return err
+	}
+	return writeReport(base, rs.Opts.runOpts.htmlReportFile, report)
+}
+
+// writeReport writes a report of the run to a file given with --report,
+// relative to the repository root
+func writeReport(base *cmdutil.CmdBase, file string, report []byte) error {
+	reportPath := fs.ResolveUnknownPath(base.RepoRoot, file)
+	if err := reportPath.EnsureDir(); err != nil {
+		return err
+	}
+	return reportPath.WriteFile(report, 0644)
+}
+
+// countAssumedFresh returns how many tasks --only didn't run, even though tasks
+// that run depend on them
+func countAssumedFresh(g *graph.CompleteGraph) int {
+	assumedFresh := make(util.Set)
+	for _, dependencies := range g.AssumedFresh {
+		for _, dependency := range dependencies {
+			assumedFresh.Add(dependency)
 		}
 	}
+	return assumedFresh.Len()
+}
 
-	// Clean up tracing
-	tracer(TargetBuilt, nil)
-	progressLogger.Debug("done", "status", "complete", "duration", duration)
-	return nil
+// countTasks returns how many tasks are in the graph of engine
+func countTasks(engine *core.Engine) int {
+	count := 0
+	for _, vertex := range engine.TaskGraph.Vertices() {
+		if !strings.Contains(dag.VertexName(vertex), core.ROOT_NODE_NAME) {
+			count++
+		}
+	}
+	return count
 }
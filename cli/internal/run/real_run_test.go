@@ -0,0 +1,35 @@
+package run
+
+import (
+	gocontext "context"
+	"runtime"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, retryDelay(0, 0), time.Duration(0))
+	assert.Equal(t, retryDelay(0, 3), time.Duration(0))
+	assert.Equal(t, retryDelay(500, 0), 500*time.Millisecond)
+	assert.Equal(t, retryDelay(500, 1), time.Second)
+	assert.Equal(t, retryDelay(500, 2), 2*time.Second)
+}
+
+func TestWaitForRetry(t *testing.T) {
+	assert.Assert(t, waitForRetry(gocontext.Background(), 0))
+
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	cancel()
+	assert.Assert(t, !waitForRetry(ctx, time.Hour))
+}
+
+func TestShellCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the quoting of cmd differs")
+	}
+	name, args := shellCommand("go test ./...", []string{"-run", "Test It's"})
+	assert.Equal(t, name, "sh")
+	assert.DeepEqual(t, args, []string{"-c", `go test ./... '-run' 'Test It'\''s'`})
+}
@@ -0,0 +1,164 @@
+// Package run implements `turbo run`
+// This file implements `turbo run --record`, and `turbo replay`, which checks
+// that the environment matches a recorded run before running it again
+package run
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/packagemanager"
+	"github.com/vercel/turbo/cli/internal/runsummary"
+	"github.com/vercel/turbo/cli/internal/signals"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+	"github.com/vercel/turbo/cli/internal/ui"
+)
+
+// errReplayMismatch is returned by the verification dry run of `turbo replay`
+// when the environment doesn't match the recorded run
+var errReplayMismatch = errors.New("the environment does not match the recorded run, pass --ignore-mismatches to replay it anyway")
+
+// ExecuteReplay executes the `replay` command.
+func ExecuteReplay(ctx gocontext.Context, helper *cmdutil.Helper, signalWatcher *signals.Watcher, args *turbostate.ParsedArgsFromRust) error {
+	base, err := helper.GetCmdBase(args)
+	if err != nil {
+		return err
+	}
+	if err := replay(ctx, base, signalWatcher, args); err != nil {
+		base.LogError("replay failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+func replay(ctx gocontext.Context, base *cmdutil.CmdBase, signalWatcher *signals.Watcher, args *turbostate.ParsedArgsFromRust) error {
+	replayPayload := args.Command.Replay
+	record, err := runsummary.ReadRunRecord(fs.ResolveUnknownPath(base.RepoRoot, replayPayload.Bundle))
+	if err != nil {
+		return err
+	}
+	runPayload := &turbostate.RunPayload{}
+	if err := json.Unmarshal(record.Args, runPayload); err != nil {
+		return errors.Wrap(err, "failed to read the flags of the recorded run")
+	}
+	// Replaying a run shouldn't overwrite its record
+	runPayload.Record = ""
+	runArgs := *args
+	runArgs.Command = turbostate.Command{Run: runPayload}
+
+	// The recorded flags already include the user's defaults, so they aren't applied again
+	verifyOpts, err := optsFromArgs(&runArgs)
+	if err != nil {
+		return err
+	}
+	verifyOpts.runOpts.passThroughArgs = runPayload.PassThroughArgs
+	verifyOpts.runOpts.dryRun = true
+	verifyOpts.runOpts.replayRecord = record
+	verifyOpts.runOpts.ignoreReplayMismatches = replayPayload.IgnoreMismatches
+	if err := configureRun(base, verifyOpts, signalWatcher).run(ctx, runPayload.Tasks); err != nil {
+		return err
+	}
+	if replayPayload.VerifyOnly {
+		return nil
+	}
+
+	opts, err := optsFromArgs(&runArgs)
+	if err != nil {
+		return err
+	}
+	opts.runOpts.passThroughArgs = runPayload.PassThroughArgs
+	return configureRun(base, opts, signalWatcher).run(ctx, runPayload.Tasks)
+}
+
+// verifyReplay compares the tasks that would run now against the recorded run
+func verifyReplay(rs *runSpec, base *cmdutil.CmdBase, current *runsummary.RunRecord) error {
+	// Compare the record as it would be read back, so that empty and missing
+	// fields in the current run aren't reported as differences
+	contents, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	normalized := &runsummary.RunRecord{}
+	if err := json.Unmarshal(contents, normalized); err != nil {
+		return err
+	}
+
+	diffs := rs.Opts.runOpts.replayRecord.Compare(normalized)
+	if len(diffs) == 0 {
+		base.UI.Output(ui.Dim(fmt.Sprintf("• The environment matches the recorded run of %v tasks", len(current.Tasks))))
+		return nil
+	}
+	for _, diff := range diffs {
+		base.UI.Warn(diff)
+	}
+	if !rs.Opts.runOpts.ignoreReplayMismatches {
+		return errReplayMismatch
+	}
+	base.UI.Warn(fmt.Sprintf("Replaying despite %v differences from the recorded run", len(diffs)))
+	return nil
+}
+
+// newRunRecord creates a record of a run from its summary
+func newRunRecord(rs *runSpec, base *cmdutil.CmdBase, engine *core.Engine, globalHash string, summary *runsummary.RunSummary, packageManager *packagemanager.PackageManager) (*runsummary.RunRecord, error) {
+	environment := recordEnvironment(base, packageManager)
+	record := runsummary.NewRunRecord(summary, globalHash, *environment, rs.Opts.runOpts.recordArgs)
+	// Only dry runs fill in the dependencies of a task summary
+	for _, task := range record.Tasks {
+		dependencies, err := engine.GetTaskGraphAncestors(task.TaskID)
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(dependencies)
+		task.Dependencies = dependencies
+	}
+	return record, nil
+}
+
+// writeRunRecord writes the record of a run to the file given with --record
+func writeRunRecord(rs *runSpec, base *cmdutil.CmdBase, engine *core.Engine, globalHash string, summary *runsummary.RunSummary, packageManager *packagemanager.PackageManager) error {
+	record, err := newRunRecord(rs, base, engine, globalHash, summary, packageManager)
+	if err != nil {
+		return err
+	}
+	recordPath := fs.ResolveUnknownPath(base.RepoRoot, rs.Opts.runOpts.recordFile)
+	if err := record.Save(recordPath); err != nil {
+		return err
+	}
+	base.UI.Output(ui.Dim(fmt.Sprintf("• Recorded the run to %v, run `turbo replay %v` to repeat it", recordPath, rs.Opts.runOpts.recordFile)))
+	return nil
+}
+
+// recordEnvironment describes the machine turbo is running on
+func recordEnvironment(base *cmdutil.CmdBase, packageManager *packagemanager.PackageManager) *runsummary.RecordEnvironment {
+	environment := &runsummary.RecordEnvironment{
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+		NodeVersion: nodeVersion(base),
+	}
+	if packageManager != nil {
+		environment.PackageManager = packageManager.Name
+	}
+	return environment
+}
+
+// nodeVersion returns the version of node in the PATH, or the empty string if
+// it can't be run
+func nodeVersion(base *cmdutil.CmdBase) string {
+	cmd := exec.Command("node", "--version")
+	cmd.Dir = base.RepoRoot.ToString()
+	out, err := cmd.Output()
+	if err != nil {
+		base.Logger.Debug("failed to get the node version", "error", err)
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,200 @@
+// Package run implements `turbo run`
+// This file implements the logic for `turbo run --restore-dry-run`
+package run
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/runsummary"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+const (
+	_restoreCreate    = "create"
+	_restoreOverwrite = "overwrite"
+	_restoreUnchanged = "unchanged"
+)
+
+// restoreFile is a path that a cache hit would write, and what writing it
+// would do to the workspace
+type restoreFile struct {
+	Path   string `json:"path"`
+	Action string `json:"action"`
+}
+
+// restorePlan lists the files that restoring a task from the cache would write.
+// Files is empty for tasks that would not be restored.
+type restorePlan struct {
+	TaskID   string        `json:"taskId"`
+	Hash     string        `json:"hash"`
+	Restored bool          `json:"restored"`
+	Reason   string        `json:"reason,omitempty"`
+	Files    []restoreFile `json:"files"`
+}
+
+// printRestoreDryRun fetches the cache artifact of each task into a temporary
+// directory and compares its files against the workspace, without changing
+// anything in the workspace.
+func printRestoreDryRun(rs *runSpec, turboCache cache.Cache, base *cmdutil.CmdBase, taskSummaries []*runsummary.TaskSummary) error {
+	plans := make([]*restorePlan, len(taskSummaries))
+	for i, task := range taskSummaries {
+		plan, err := planTaskRestore(turboCache, base.RepoRoot, task)
+		if err != nil {
+			return fmt.Errorf("failed to inspect the cache for %v: %w", task.TaskID, err)
+		}
+		plans[i] = plan
+	}
+
+	if rs.Opts.runOpts.dryRunJSON {
+		rendered, err := json.MarshalIndent(plans, "", "  ")
+		if err != nil {
+			return err
+		}
+		base.UI.Output(string(rendered))
+		return nil
+	}
+
+	base.UI.Output("")
+	base.UI.Info(util.Sprintf("${CYAN}${BOLD}Cache Restore Dry Run${RESET}"))
+	counts := map[string]int{}
+	for _, plan := range plans {
+		taskName := plan.TaskID
+		if rs.Opts.runOpts.singlePackage {
+			taskName = util.RootTaskTaskName(taskName)
+		}
+		base.UI.Output("")
+		if !plan.Restored {
+			base.UI.Output(util.Sprintf("${BOLD}%s${RESET} ${GREY}%s, nothing would be restored${RESET}", taskName, plan.Reason))
+			continue
+		}
+		base.UI.Output(util.Sprintf("${BOLD}%s${RESET} ${GREY}%s${RESET}", taskName, plan.Hash))
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, file := range plan.Files {
+			counts[file.Action]++
+			color := "${GREY}"
+			switch file.Action {
+			case _restoreCreate:
+				color = "${GREEN}"
+			case _restoreOverwrite:
+				color = "${YELLOW}"
+			}
+			fmt.Fprintln(w, util.Sprintf("  %s%s${RESET}\t%s", color, file.Action, file.Path))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	base.UI.Output("")
+	base.UI.Output(fmt.Sprintf("%v files would be created, %v overwritten and %v left unchanged", counts[_restoreCreate], counts[_restoreOverwrite], counts[_restoreUnchanged]))
+	return nil
+}
+
+func planTaskRestore(turboCache cache.Cache, repoRoot turbopath.AbsoluteSystemPath, task *runsummary.TaskSummary) (*restorePlan, error) {
+	plan := &restorePlan{TaskID: task.TaskID, Hash: task.Hash, Files: []restoreFile{}}
+	if task.ResolvedTaskDefinition != nil && !task.ResolvedTaskDefinition.ShouldCache {
+		plan.Reason = "cache disabled"
+		return plan, nil
+	}
+	if !task.CacheState.Local && !task.CacheState.Remote {
+		plan.Reason = "cache miss"
+		return plan, nil
+	}
+
+	dir, err := os.MkdirTemp("", "turbo-restore-dry-run")
+	if err != nil {
+		return nil, err
+	}
+	restoreRoot := fs.AbsoluteSystemPathFromUpstream(dir)
+	defer func() { _ = restoreRoot.RemoveAll() }()
+
+	hit, files, _, err := turboCache.Fetch(restoreRoot, task.Hash, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !hit {
+		plan.Reason = "cache miss"
+		return plan, nil
+	}
+	plan.Restored = true
+	plan.Files, err = compareRestoredFiles(restoreRoot, repoRoot, files)
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// compareRestoredFiles decides, for each file restored under restoreRoot, whether
+// restoring it into repoRoot would create it, overwrite it or leave it unchanged.
+// Directories are not listed.
+func compareRestoredFiles(restoreRoot turbopath.AbsoluteSystemPath, repoRoot turbopath.AbsoluteSystemPath, files []turbopath.AnchoredSystemPath) ([]restoreFile, error) {
+	result := []restoreFile{}
+	for _, file := range files {
+		restored := file.RestoreAnchor(restoreRoot)
+		restoredInfo, err := restored.Lstat()
+		if err != nil {
+			return nil, err
+		}
+		if restoredInfo.IsDir() {
+			continue
+		}
+		action, err := restoreAction(restored, restoredInfo, file.RestoreAnchor(repoRoot))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, restoreFile{Path: file.ToUnixPath().ToString(), Action: action})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+	return result, nil
+}
+
+func restoreAction(restored turbopath.AbsoluteSystemPath, restoredInfo os.FileInfo, existing turbopath.AbsoluteSystemPath) (string, error) {
+	existingInfo, err := existing.Lstat()
+	if os.IsNotExist(err) {
+		return _restoreCreate, nil
+	} else if err != nil {
+		return "", err
+	}
+	if restoredInfo.Mode().Type() != existingInfo.Mode().Type() {
+		return _restoreOverwrite, nil
+	}
+	if restoredInfo.Mode()&os.ModeSymlink != 0 {
+		restoredTarget, err := restored.Readlink()
+		if err != nil {
+			return "", err
+		}
+		existingTarget, err := existing.Readlink()
+		if err != nil {
+			return "", err
+		}
+		if restoredTarget != existingTarget {
+			return _restoreOverwrite, nil
+		}
+		return _restoreUnchanged, nil
+	}
+	if restoredInfo.Size() != existingInfo.Size() {
+		return _restoreOverwrite, nil
+	}
+	restoredContents, err := restored.ReadFile()
+	if err != nil {
+		return "", err
+	}
+	existingContents, err := existing.ReadFile()
+	if err != nil {
+		return "", err
+	}
+	if !bytes.Equal(restoredContents, existingContents) {
+		return _restoreOverwrite, nil
+	}
+	return _restoreUnchanged, nil
+}
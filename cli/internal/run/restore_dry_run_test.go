@@ -0,0 +1,39 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func TestCompareRestoredFiles(t *testing.T) {
+	restoreRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+
+	writeFile := func(root turbopath.AbsoluteSystemPath, path string, contents string) {
+		file := root.UntypedJoin(path)
+		assert.NilError(t, file.EnsureDir())
+		assert.NilError(t, file.WriteFile([]byte(contents), 0644))
+	}
+	writeFile(restoreRoot, "dist/new.js", "new")
+	writeFile(restoreRoot, "dist/changed.js", "after")
+	writeFile(restoreRoot, "dist/same.js", "same")
+	writeFile(repoRoot, "dist/changed.js", "before")
+	writeFile(repoRoot, "dist/same.js", "same")
+
+	files := []turbopath.AnchoredSystemPath{
+		turbopath.AnchoredUnixPath("dist").ToSystemPath(),
+		turbopath.AnchoredUnixPath("dist/same.js").ToSystemPath(),
+		turbopath.AnchoredUnixPath("dist/new.js").ToSystemPath(),
+		turbopath.AnchoredUnixPath("dist/changed.js").ToSystemPath(),
+	}
+	result, err := compareRestoredFiles(restoreRoot, repoRoot, files)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, result, []restoreFile{
+		{Path: "dist/changed.js", Action: _restoreOverwrite},
+		{Path: "dist/new.js", Action: _restoreCreate},
+		{Path: "dist/same.js", Action: _restoreUnchanged},
+	})
+}
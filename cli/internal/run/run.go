@@ -2,27 +2,39 @@ package run
 
 import (
 	gocontext "context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
+	"os/user"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/vercel/turbo/cli/internal/analytics"
 	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/ci"
+	"github.com/vercel/turbo/cli/internal/client"
 	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/config"
 	"github.com/vercel/turbo/cli/internal/context"
 	"github.com/vercel/turbo/cli/internal/core"
 	"github.com/vercel/turbo/cli/internal/daemon"
 	"github.com/vercel/turbo/cli/internal/daemonclient"
 	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/graph"
+	"github.com/vercel/turbo/cli/internal/otlp"
+	"github.com/vercel/turbo/cli/internal/packagemanager"
 	"github.com/vercel/turbo/cli/internal/process"
 	"github.com/vercel/turbo/cli/internal/runsummary"
 	"github.com/vercel/turbo/cli/internal/scm"
 	"github.com/vercel/turbo/cli/internal/scope"
 	"github.com/vercel/turbo/cli/internal/signals"
 	"github.com/vercel/turbo/cli/internal/taskhash"
+	"github.com/vercel/turbo/cli/internal/testmap"
+	"github.com/vercel/turbo/cli/internal/turbopath"
 	"github.com/vercel/turbo/cli/internal/turbostate"
 	"github.com/vercel/turbo/cli/internal/ui"
 	"github.com/vercel/turbo/cli/internal/util"
@@ -48,25 +60,94 @@ func ExecuteRun(ctx gocontext.Context, helper *cmdutil.Helper, signalWatcher *si
 	if err != nil {
 		return err
 	}
+	if args.Command.Run.Watch {
+		if err := watchRun(ctx, base, signalWatcher, args); err != nil {
+			base.LogError("run failed: %v", err)
+			return err
+		}
+		return nil
+	}
+	if _, err := RunTasks(ctx, base, signalWatcher, args, nil); err != nil {
+		base.LogError("run failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// RunTasks runs the tasks in args.Command.Run with the given CmdBase. It lets
+// `turbo serve` run many times in one process, with output going to a different
+// UI for each run. The output of tasks goes to taskOutput, or to stdout if it is
+// nil. The returned summary is nil if no tasks were executed.
+func RunTasks(ctx gocontext.Context, base *cmdutil.CmdBase, signalWatcher *signals.Watcher, args *turbostate.ParsedArgsFromRust, taskOutput io.Writer) (*runsummary.RunSummary, error) {
 	tasks := args.Command.Run.Tasks
 	passThroughArgs := args.Command.Run.PassThroughArgs
 	if len(tasks) == 0 {
-		return errors.New("at least one task must be specified")
+		return nil, errors.New("at least one task must be specified")
+	}
+	if err := applyRunProfile(args.Command.Run, base.RepoRoot); err != nil {
+		return nil, err
+	}
+	if base.UserConfig != nil {
+		applyUserDefaults(args.Command.Run, base.UserConfig)
 	}
 	opts, err := optsFromArgs(args)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	opts.runOpts.passThroughArgs = passThroughArgs
+	opts.runcacheOpts.TaskOutput = taskOutput
 	run := configureRun(base, opts, signalWatcher)
-	if err := run.run(ctx, tasks); err != nil {
-		base.LogError("run failed: %v", err)
+	err = run.run(ctx, tasks)
+	return run.summary, err
+}
+
+// applyRunProfile fills in the flags that were not passed with the ones from
+// the profile selected with --profile-name. It is applied before the user's
+// defaults, since the profile is specific to the repository.
+func applyRunProfile(runPayload *turbostate.RunPayload, repoRoot turbopath.AbsoluteSystemPath) error {
+	if runPayload.ProfileName == "" {
+		return nil
+	}
+	profile, err := fs.LoadRunProfile(repoRoot, runPayload.ProfileName)
+	if err != nil {
 		return err
 	}
+	// --parallel and --serial-deterministic choose their own concurrency
+	if runPayload.Concurrency == "" && !runPayload.Parallel && !runPayload.SerialDeterministic {
+		runPayload.Concurrency = profile.Concurrency
+	}
+	if len(runPayload.OutputLogs) == 0 && !runPayload.SerialDeterministic && profile.OutputLogs != "" {
+		runPayload.OutputLogs = []string{profile.OutputLogs}
+	}
+	runPayload.ContinueExecution = runPayload.ContinueExecution || profile.Continue
+	runPayload.Force = runPayload.Force || profile.Force
+	runPayload.NoCache = runPayload.NoCache || profile.NoCache
+	runPayload.RemoteOnly = runPayload.RemoteOnly || profile.RemoteOnly
 	return nil
 }
 
+// applyUserDefaults fills in the flags that were not passed with the defaults
+// from the user config file. The environment takes precedence over the user's
+// cache directory, as it would over the default location.
+func applyUserDefaults(runPayload *turbostate.RunPayload, userConfig *config.UserConfig) {
+	if runPayload.Concurrency == "" {
+		runPayload.Concurrency = userConfig.Default("concurrency")
+	}
+	// --serial-deterministic chooses its own output mode
+	if len(runPayload.OutputLogs) == 0 && !runPayload.SerialDeterministic {
+		if outputLogs := userConfig.Default("output_logs"); outputLogs != "" {
+			runPayload.OutputLogs = []string{outputLogs}
+		}
+	}
+	if runPayload.CacheDir == "" && os.Getenv(cache.CacheDirEnvVar) == "" {
+		runPayload.CacheDir = userConfig.Default("cache_dir")
+	}
+	if runPayload.MaxCacheSize == "" && os.Getenv(cache.MaxCacheSizeEnvVar) == "" {
+		runPayload.MaxCacheSize = userConfig.Default("max_cache_size")
+	}
+}
+
 func optsFromArgs(args *turbostate.ParsedArgsFromRust) (*Opts, error) {
 	runPayload := args.Command.Run
 
@@ -77,19 +158,52 @@ func optsFromArgs(args *turbostate.ParsedArgsFromRust) (*Opts, error) {
 	// Cache flags
 	opts.clientOpts.Timeout = args.RemoteCacheTimeout
 	opts.cacheOpts.SkipFilesystem = runPayload.RemoteOnly
+	switch runPayload.Cache {
+	case "":
+	case _cacheModeLocalOnlyValue:
+		opts.cacheOpts.SkipRemote = true
+	case _cacheModeRemoteOnlyValue:
+		opts.cacheOpts.SkipFilesystem = true
+	case _cacheModeOffValue:
+		opts.cacheOpts.SkipRemote = true
+		opts.cacheOpts.SkipFilesystem = true
+		opts.runOpts.cacheOff = true
+	default:
+		return nil, fmt.Errorf("invalid cache mode: %v", runPayload.Cache)
+	}
 	opts.cacheOpts.OverrideDir = runPayload.CacheDir
 	opts.cacheOpts.Workers = runPayload.CacheWorkers
+	if runPayload.CacheScope == "" {
+		runPayload.CacheScope = os.Getenv(cache.CacheScopeEnvVar)
+	}
+	if runPayload.CacheScope != "" {
+		cacheScope, err := cache.NormalizeScope(runPayload.CacheScope)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --cache-scope: %w", err)
+		}
+		opts.cacheOpts.Scope = cacheScope
+	}
+	if runPayload.MaxCacheSize == "" {
+		runPayload.MaxCacheSize = os.Getenv(cache.MaxCacheSizeEnvVar)
+	}
+	if runPayload.MaxCacheSize != "" {
+		maxCacheSize, err := client.ParseSize(runPayload.MaxCacheSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --max-cache-size: %w", err)
+		}
+		opts.cacheOpts.MaxSize = maxCacheSize
+	}
 	opts.runOpts.logPrefix = runPayload.LogPrefix
+	opts.runOpts.logPrefixColor = runPayload.LogPrefixColor
 
 	// Runcache flags
 	opts.runcacheOpts.SkipReads = runPayload.Force
+	opts.runcacheOpts.SkipReadsFor = runPayload.ForceFilter
 	opts.runcacheOpts.SkipWrites = runPayload.NoCache
+	opts.runcacheOpts.SkipLogs = runPayload.NoCacheLogs
 
-	if runPayload.OutputLogs != "" {
-		err := opts.runcacheOpts.SetTaskOutputMode(runPayload.OutputLogs)
-		if err != nil {
-			return nil, err
-		}
+	if err := opts.runcacheOpts.SetTaskOutputModes(runPayload.OutputLogs); err != nil {
+		return nil, err
 	}
 
 	// Run flags
@@ -101,21 +215,103 @@ func optsFromArgs(args *turbostate.ParsedArgsFromRust) (*Opts, error) {
 		opts.runOpts.concurrency = concurrency
 	}
 	opts.runOpts.parallel = runPayload.Parallel
+	if runPayload.SerialDeterministic {
+		opts.runOpts.serialDeterministic = true
+		opts.runOpts.concurrency = 1
+		// Stream every task's output as it is written, rather than only on failure
+		if opts.runcacheOpts.TaskOutputModeOverride == nil {
+			fullOutput := util.FullTaskOutput
+			opts.runcacheOpts.TaskOutputModeOverride = &fullOutput
+		}
+	}
 	opts.runOpts.profile = runPayload.Profile
 	opts.runOpts.continueOnError = runPayload.ContinueExecution
+	if runPayload.GracePeriod != "" {
+		gracePeriod, err := time.ParseDuration(runPayload.GracePeriod)
+		if err != nil || gracePeriod < 0 {
+			return nil, fmt.Errorf("invalid --grace-period: %v", runPayload.GracePeriod)
+		}
+		opts.runOpts.gracePeriod = gracePeriod
+	}
+	exitCode, err := exitCodeOptsFromArgs(runPayload)
+	if err != nil {
+		return nil, err
+	}
+	opts.runOpts.exitCode = exitCode
 	opts.runOpts.only = runPayload.Only
+	if runPayload.Shard != "" {
+		shard, err := core.ParseShard(runPayload.Shard)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --shard: %w", err)
+		}
+		opts.runOpts.shard = &shard
+	}
+	opts.runOpts.testMap = runPayload.TestMap
 	opts.runOpts.noDaemon = runPayload.NoDaemon
 	opts.runOpts.singlePackage = args.Command.Run.SinglePackage
+	opts.runOpts.remoteWorkers = runPayload.ExperimentalRemoteWorkers
+	opts.runOpts.remoteWorkersCA = runPayload.ExperimentalRemoteWorkersCA
+	opts.runOpts.traceFiles = runPayload.ExperimentalTraceFiles
+	opts.runOpts.sandbox = runPayload.ExperimentalSandbox
+	opts.runOpts.speculate = runPayload.ExperimentalSpeculate
+	opts.runOpts.checkDeterminism = runPayload.CheckDeterminism
+	opts.runOpts.prReportFile = runPayload.PRReport
+	opts.runOpts.prReportBase = runPayload.PRReportBase
+	opts.runOpts.githubActions = runPayload.GitHubActions
+	opts.runOpts.failureBundles = runPayload.FailureBundles
+	opts.runOpts.checkOutputs = runPayload.CheckOutputs
+	opts.runOpts.cleanOutputs = runPayload.CleanOutputs
+	if runPayload.CacheKeySuffix != "" {
+		if err := validateCacheKeySuffix(runPayload.CacheKeySuffix); err != nil {
+			return nil, err
+		}
+		opts.runOpts.cacheKeySuffix = runPayload.CacheKeySuffix
+	}
+	if runPayload.HashLock != "" && len(runPayload.ForceFilter) > 0 {
+		return nil, errors.New("--hash-lock restores every task from the cache, and can't be used with --force-filter")
+	}
+	opts.runOpts.hashLockFile = runPayload.HashLock
+	opts.runOpts.writeHashLockFile = runPayload.WriteHashLock
+	opts.runOpts.otlpURL = otlp.TracesURL(runPayload.OTLPEndpoint)
+	opts.runOpts.events = runPayload.Events
+	opts.runOpts.strict = runPayload.Strict
+	if runPayload.Record != "" {
+		opts.runOpts.recordFile = runPayload.Record
+		// The flags are recorded after the user's defaults have been applied
+		recordArgs, err := json.Marshal(runPayload)
+		if err != nil {
+			return nil, err
+		}
+		opts.runOpts.recordArgs = recordArgs
+	}
+	for _, value := range runPayload.Also {
+		group, err := parseRunGroup(value)
+		if err != nil {
+			return nil, err
+		}
+		opts.runOpts.groups = append(opts.runOpts.groups, group)
+	}
+	for _, value := range runPayload.TaskArgs {
+		taskArg, err := parseTaskArg(value)
+		if err != nil {
+			return nil, err
+		}
+		opts.runOpts.taskArgs = append(opts.runOpts.taskArgs, taskArg)
+	}
 
 	// See comment on Graph in turbostate.go for an explanation on Graph's representation.
 	// If flag is passed...
 	if runPayload.Graph != nil {
-		// If no value is attached, we print to stdout
-		if *runPayload.Graph == "" {
+		switch *runPayload.Graph {
+		case "", _graphDotValue:
+			// If no value is attached, we print to stdout
 			opts.runOpts.graphDot = true
-		} else {
+		case _graphMermaidValue:
+			opts.runOpts.graphMermaid = true
+		case _graphHTMLValue:
+			opts.runOpts.graphFile = "graph.html"
+		default:
 			// Otherwise, we emit to the file name attached as value
-			opts.runOpts.graphDot = false
 			opts.runOpts.graphFile = *runPayload.Graph
 		}
 	}
@@ -125,11 +321,84 @@ func optsFromArgs(args *turbostate.ParsedArgsFromRust) (*Opts, error) {
 
 		if runPayload.DryRun == _dryRunTextValue || runPayload.DryRun == _dryRunJSONValue {
 			opts.runOpts.dryRun = true
+			opts.runOpts.showGlobalHashInputs = runPayload.ShowGlobalHashInputs
 		} else {
 			return nil, fmt.Errorf("invalid dry-run mode: %v", runPayload.DryRun)
 		}
 	}
 
+	switch runPayload.LogOrder {
+	case "", _logOrderStreamValue:
+	case _logOrderGroupedValue:
+		opts.runOpts.logOrderGrouped = true
+	default:
+		return nil, fmt.Errorf("invalid log order: %v", runPayload.LogOrder)
+	}
+
+	switch runPayload.EnvMode {
+	case "", _envModeLooseValue:
+	case _envModeStrictValue:
+		opts.runOpts.strictEnv = true
+	default:
+		return nil, fmt.Errorf("invalid env mode: %v", runPayload.EnvMode)
+	}
+
+	switch runPayload.UI {
+	case "", _uiStreamValue:
+	case _uiTuiValue:
+		opts.runOpts.tui = true
+	default:
+		return nil, fmt.Errorf("invalid ui: %v", runPayload.UI)
+	}
+	if runPayload.Progress && opts.runOpts.tui {
+		return nil, errors.New("--progress can't be used with --ui=tui, which shows the progress of tasks already")
+	}
+	opts.runOpts.progress = runPayload.Progress
+
+	switch runPayload.SummaryFormat {
+	case "", _summaryFormatTextValue:
+		if runPayload.SummaryFile != "" {
+			return nil, errors.New("--summary-file requires --summary-format=json")
+		}
+	case _summaryFormatJSONValue:
+		opts.runOpts.summaryJSON = true
+		opts.runOpts.summaryFile = runPayload.SummaryFile
+	default:
+		return nil, fmt.Errorf("invalid summary format: %v", runPayload.SummaryFormat)
+	}
+
+	if runPayload.Summarize != nil {
+		switch *runPayload.Summarize {
+		case "":
+			opts.runOpts.summarize = true
+		case _summarizeResourcesValue:
+			opts.runOpts.summarize = true
+			opts.runOpts.resourcesTable = true
+		default:
+			return nil, fmt.Errorf("invalid --summarize %q: it can only be passed \"resources\"", *runPayload.Summarize)
+		}
+	}
+
+	for _, report := range runPayload.Report {
+		format, reportFile, ok := strings.Cut(report, ":")
+		if !ok || reportFile == "" {
+			return nil, fmt.Errorf("invalid --report %q: it should be written as <format>:<path>, such as junit:turbo.xml", report)
+		}
+		switch format {
+		case _reportFormatJUnitValue:
+			opts.runOpts.junitReportFile = reportFile
+		case _reportFormatHTMLValue:
+			opts.runOpts.htmlReportFile = reportFile
+		default:
+			return nil, fmt.Errorf("invalid --report format %q: expected %v or %v", format, _reportFormatJUnitValue, _reportFormatHTMLValue)
+		}
+	}
+
+	if runPayload.RestoreDryRun {
+		opts.runOpts.dryRun = true
+		opts.runOpts.restoreDryRun = true
+	}
+
 	return opts, nil
 }
 
@@ -147,59 +416,67 @@ func configureRun(base *cmdutil.CmdBase, opts *Opts, signalWatcher *signals.Watc
 	}
 
 	processes := process.NewManager(base.Logger.Named("processes"))
-	signalWatcher.AddOnClose(processes.Close)
+	processes.SetGracePeriod(opts.runOpts.gracePeriod)
 	return &run{
-		base:      base,
-		opts:      opts,
-		processes: processes,
+		base:          base,
+		opts:          opts,
+		processes:     processes,
+		signalWatcher: signalWatcher,
 	}
 }
 
 type run struct {
-	base      *cmdutil.CmdBase
-	opts      *Opts
-	processes *process.Manager
+	base          *cmdutil.CmdBase
+	opts          *Opts
+	processes     *process.Manager
+	signalWatcher *signals.Watcher
+	// summary is set once tasks have been executed, so that `turbo bench` can
+	// inspect the outcome of each task
+	summary *runsummary.RunSummary
+	// daemonClient is set when the run is connected to turbod
+	daemonClient *daemonclient.DaemonClient
 }
 
-func (r *run) run(ctx gocontext.Context, targets []string) error {
-	startAt := time.Now()
+// preparedRun is everything that is known about a run before tasks execute
+type preparedRun struct {
+	g               *graph.CompleteGraph
+	rs              *runSpec
+	engine          *core.Engine
+	taskHashTracker *taskhash.Tracker
+	globalHashable  GlobalHashable
+	packageManager  *packagemanager.PackageManager
+	// hashDuration is the time spent hashing the files of every task
+	hashDuration time.Duration
+	// testSelection is why each of the tasks of the --test-map runs or not
+	testSelection []*testmap.Selection
+}
+
+// prepare builds the package and task graphs for targets and hashes the files
+// of every task in them
+func (r *run) prepare(targets []string) (*preparedRun, error) {
 	packageJSONPath := r.base.RepoRoot.UntypedJoin("package.json")
 	rootPackageJSON, err := fs.ReadPackageJSON(packageJSONPath)
 	if err != nil {
-		return fmt.Errorf("failed to read package.json: %w", err)
+		return nil, fmt.Errorf("failed to read package.json: %w", err)
 	}
 
 	var pkgDepGraph *context.Context
 	if r.opts.runOpts.singlePackage {
 		pkgDepGraph, err = context.SinglePackageGraph(r.base.RepoRoot, rootPackageJSON)
 	} else {
-		pkgDepGraph, err = context.BuildPackageGraph(r.base.RepoRoot, rootPackageJSON)
+		pkgDepGraph, err = r.buildPackageGraph(rootPackageJSON)
 	}
 	if err != nil {
 		var warnings *context.Warnings
 		if errors.As(err, &warnings) {
 			r.base.LogWarning("Issues occurred when constructing package graph. Turbo will function, but some features may not be available", err)
 		} else {
-			return err
-		}
-	}
-
-	if ui.IsCI && !r.opts.runOpts.noDaemon {
-		r.base.Logger.Info("skipping turbod since we appear to be in a non-interactive context")
-	} else if !r.opts.runOpts.noDaemon {
-		turbodClient, err := daemon.GetClient(ctx, r.base.RepoRoot, r.base.Logger, r.base.TurboVersion, daemon.ClientOpts{})
-		if err != nil {
-			r.base.LogWarning("", errors.Wrap(err, "failed to contact turbod. Continuing in standalone mode"))
-		} else {
-			defer func() { _ = turbodClient.Close() }()
-			r.base.Logger.Debug("running in daemon mode")
-			daemonClient := daemonclient.New(turbodClient)
-			r.opts.runcacheOpts.OutputWatcher = daemonClient
+			return nil, err
 		}
 	}
 
 	if err := util.ValidateGraph(&pkgDepGraph.WorkspaceGraph); err != nil {
-		return errors.Wrap(err, "Invalid package dependency graph")
+		return nil, errors.Wrap(err, "Invalid package dependency graph")
 	}
 
 	// TODO: consolidate some of these arguments
@@ -210,15 +487,42 @@ func (r *run) run(ctx gocontext.Context, targets []string) error {
 		RootNode:        pkgDepGraph.RootNode,
 		TaskDefinitions: map[string]*fs.TaskDefinition{},
 		RepoRoot:        r.base.RepoRoot,
+		CacheKeySuffix:  r.opts.runOpts.cacheKeySuffix,
+	}
+	if r.opts.runOpts.hashLockFile != "" {
+		lock, err := readHashLock(r.base.RepoRoot, r.opts.runOpts.hashLockFile)
+		if err != nil {
+			return nil, err
+		}
+		g.PinnedHashes = lock.Tasks
 	}
 
 	turboJSON, err := g.GetTurboConfigFromWorkspace(util.RootPkgName, r.opts.runOpts.singlePackage)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// TODO: these values come from a config file, hopefully viper can help us merge these
 	r.opts.cacheOpts.RemoteCacheOpts = turboJSON.RemoteCacheOptions
+	r.opts.runOpts.hooks = turboJSON.Hooks
+	r.opts.runOpts.globalEnv = turboJSON.GlobalEnv
+	r.opts.runOpts.notifications = turboJSON.Notifications
+	r.opts.runOpts.webhooks = turboJSON.Webhooks
+	r.opts.runOpts.analytics = turboJSON.Analytics
+	summaryRetention, err := runsummary.NewRetentionPolicy(turboJSON.Summaries)
+	if err != nil {
+		return nil, err
+	}
+	r.opts.runOpts.summaryRetention = summaryRetention
+	if r.opts.runOpts.logPrefix == "" {
+		r.opts.runOpts.logPrefix = turboJSON.Logs.Prefix
+	}
+	if r.opts.runOpts.logPrefixColor == "" {
+		r.opts.runOpts.logPrefixColor = turboJSON.Logs.PrefixColor
+	}
+	if _, err := newLogPrefix(r.opts.runOpts.logPrefix, r.opts.runOpts.logPrefixColor, r.opts.runOpts.singlePackage, nil); err != nil {
+		return nil, err
+	}
 
 	pipeline := turboJSON.Pipeline
 	g.Pipeline = pipeline
@@ -227,55 +531,37 @@ func (r *run) run(ctx gocontext.Context, targets []string) error {
 		if errors.Is(err, scm.ErrFallback) {
 			r.base.LogWarning("", err)
 		} else {
-			return errors.Wrap(err, "failed to create SCM")
+			return nil, errors.Wrap(err, "failed to create SCM")
 		}
 	}
-	filteredPkgs, isAllPackages, err := scope.ResolvePackages(&r.opts.scopeOpts, r.base.RepoRoot, scmInstance, pkgDepGraph, r.base.UI, r.base.Logger)
+	filteredPkgs, err := r.resolvePackages(&r.opts.scopeOpts, targets, pipeline, scmInstance, pkgDepGraph)
 	if err != nil {
-		return errors.Wrap(err, "failed to resolve packages to run")
-	}
-	if isAllPackages {
-		// if there is a root task for any of our targets, we need to add it
-		for _, target := range targets {
-			key := util.RootTaskID(target)
-			if _, ok := pipeline[key]; ok {
-				filteredPkgs.Add(util.RootPkgName)
-				// we only need to know we're running a root task once to add it for consideration
-				break
-			}
-		}
+		return nil, errors.Wrap(err, "failed to resolve packages to run")
 	}
-
-	globalHashable, err := calculateGlobalHash(
-		r.base.RepoRoot,
-		rootPackageJSON,
-		pipeline,
-		turboJSON.GlobalEnv,
-		turboJSON.GlobalDeps,
-		pkgDepGraph.PackageManager,
-		pkgDepGraph.Lockfile,
-		r.base.Logger,
-	)
-
+	// With --also, the groups of tasks share one graph, and the run is of every
+	// one of their tasks
+	groups, err := r.resolveRunGroups(targets, filteredPkgs, pipeline, scmInstance, pkgDepGraph)
 	if err != nil {
-		return fmt.Errorf("failed to collect global hash inputs: %v", err)
+		return nil, err
+	}
+	if groups != nil {
+		targets, filteredPkgs = mergeRunGroups(groups)
 	}
 
-	if globalHash, err := fs.HashObject(getGlobalHashable(globalHashable)); err == nil {
-		r.base.Logger.Debug("global hash", "value", globalHash)
-		g.GlobalHash = globalHash
-	} else {
-		return fmt.Errorf("failed to calculate global hash: %v", err)
+	globalHashable, globalHash, err := r.globalHash(rootPackageJSON, pkgDepGraph, turboJSON)
+	if err != nil {
+		return nil, err
 	}
+	g.GlobalHash = globalHash
 
-	r.base.Logger.Debug("local cache folder", "path", r.opts.cacheOpts.OverrideDir)
+	r.base.Logger.Debug("local cache folder", "path", r.opts.cacheOpts.ResolveCacheDir(r.base.RepoRoot))
 
 	rs := &runSpec{
 		Targets:      targets,
 		FilteredPkgs: filteredPkgs,
+		Groups:       groups,
 		Opts:         r.opts,
 	}
-	packageManager := pkgDepGraph.PackageManager
 
 	engine, err := buildTaskGraphEngine(
 		g,
@@ -284,30 +570,52 @@ func (r *run) run(ctx gocontext.Context, targets []string) error {
 	)
 
 	if err != nil {
-		return errors.Wrap(err, "error preparing engine")
+		return nil, errors.Wrap(err, "error preparing engine")
+	}
+	// With --parallel, the graph that is run is rebuilt after hashing, and
+	// its tests are selected and it is sharded then
+	var testSelection []*testmap.Selection
+	if !rs.Opts.runOpts.parallel {
+		if testSelection, err = r.selectTests(engine, g.WorkspaceInfos, scmInstance); err != nil {
+			return nil, err
+		}
+		if err := r.shardEngine(engine); err != nil {
+			return nil, err
+		}
 	}
 
 	taskHashTracker := taskhash.NewTracker(
+		r.base.RepoRoot,
 		g.RootNode,
 		g.GlobalHash,
 		// TODO(mehulkar): remove g,Pipeline, because we need to get task definitions from CompleteGaph instead
 		g.Pipeline,
+		turboJSON.Hashing.HashVersion(),
 	)
+	if fallbackVersion := turboJSON.Hashing.FallbackVersion; fallbackVersion != 0 {
+		fallbackGlobalHash, err := fs.HashObjectVersion(fallbackVersion, getGlobalHashable(globalHashable.forVersion(fallbackVersion)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to calculate global hash: %v", err)
+		}
+		taskHashTracker.SetFallback(fallbackVersion, fallbackGlobalHash)
+	}
 
 	g.TaskHashTracker = taskHashTracker
 
 	// CalculateFileHashes assigns PackageInputsExpandedHashes as a side-effect
+	hashStartedAt := time.Now()
 	err = taskHashTracker.CalculateFileHashes(
 		engine.TaskGraph.Vertices(),
-		rs.Opts.runOpts.concurrency,
+		turboJSON.Hashing.WorkerCount(),
 		g.WorkspaceInfos,
 		g.TaskDefinitions,
 		r.base.RepoRoot,
 	)
 
 	if err != nil {
-		return errors.Wrap(err, "error hashing package files")
+		return nil, errors.Wrap(err, "error hashing package files")
 	}
+	hashDuration := time.Since(hashStartedAt)
 
 	// If we are running in parallel, then we remove all the edges in the graph
 	// except for the root. Rebuild the task graph for backwards compatibility.
@@ -324,13 +632,135 @@ func (r *run) run(ctx gocontext.Context, targets []string) error {
 			r.opts.runOpts.singlePackage,
 		)
 		if err != nil {
-			return errors.Wrap(err, "error preparing engine")
+			return nil, errors.Wrap(err, "error preparing engine")
+		}
+		if testSelection, err = r.selectTests(engine, g.WorkspaceInfos, scmInstance); err != nil {
+			return nil, err
+		}
+		if err := r.shardEngine(engine); err != nil {
+			return nil, err
 		}
 	}
 
-	// Graph Run
-	if rs.Opts.runOpts.graphFile != "" || rs.Opts.runOpts.graphDot {
-		return GraphRun(ctx, rs, engine, r.base)
+	return &preparedRun{
+		g:               g,
+		rs:              rs,
+		engine:          engine,
+		taskHashTracker: taskHashTracker,
+		globalHashable:  globalHashable,
+		packageManager:  pkgDepGraph.PackageManager,
+		hashDuration:    hashDuration,
+		testSelection:   testSelection,
+	}, nil
+}
+
+// shardEngine removes the tasks that other shards run from the graph, when the
+// run is split with --shard. The shards are balanced with the durations of the
+// run summaries saved in the repository, so every shard needs the same ones.
+func (r *run) shardEngine(engine *core.Engine) error {
+	if r.opts.runOpts.shard == nil {
+		return nil
+	}
+	removed, err := engine.ShardTasks(*r.opts.runOpts.shard, taskDurations(r.base, r.opts.runOpts.singlePackage))
+	if err != nil {
+		return errors.Wrap(err, "error sharding task graph")
+	}
+	r.base.Logger.Debug("sharded task graph", "shard", r.opts.runOpts.shard.String(), "skipped", removed)
+	return nil
+}
+
+// buildPackageGraph builds the package graph from the one resolved by turbod,
+// unless it is out of date, which skips globbing for workspaces and parsing the
+// lockfile of large repositories
+func (r *run) buildPackageGraph(rootPackageJSON *fs.PackageJSON) (*context.Context, error) {
+	if r.daemonClient != nil {
+		resolution, err := r.daemonClient.PackageGraph(gocontext.Background())
+		if err != nil {
+			r.base.Logger.Debug("failed to get the package graph from turbod", "error", err)
+		} else if pkgDepGraph, err := context.BuildPackageGraphFromResolution(r.base.RepoRoot, rootPackageJSON, resolution); err != nil {
+			r.base.Logger.Debug("not using the package graph from turbod", "error", err)
+		} else {
+			r.base.Logger.Debug("using the package graph from turbod")
+			return pkgDepGraph, nil
+		}
+	}
+	return context.BuildPackageGraph(r.base.RepoRoot, rootPackageJSON)
+}
+
+// globalHash collects the inputs of the global hash and hashes them with the
+// hashing version of turbo.json
+func (r *run) globalHash(rootPackageJSON *fs.PackageJSON, pkgDepGraph *context.Context, turboJSON *fs.TurboJSON) (GlobalHashable, string, error) {
+	globalHashable, err := calculateGlobalHash(
+		r.base.RepoRoot,
+		rootPackageJSON,
+		turboJSON.Pipeline,
+		turboJSON.GlobalEnv,
+		turboJSON.GlobalDeps,
+		pkgDepGraph.PackageManager,
+		pkgDepGraph.HasLockfile(),
+		r.hashGlobalFiles,
+		r.base.Logger,
+	)
+	if err != nil {
+		return GlobalHashable{}, "", fmt.Errorf("failed to collect global hash inputs: %v", err)
+	}
+
+	hashVersion := turboJSON.Hashing.HashVersion()
+	globalHash, err := fs.HashObjectVersion(hashVersion, getGlobalHashable(globalHashable.forVersion(hashVersion)))
+	if err != nil {
+		return GlobalHashable{}, "", fmt.Errorf("failed to calculate global hash: %v", err)
+	}
+	r.base.Logger.Debug("global hash", "value", globalHash, "version", hashVersion)
+	return globalHashable, globalHash, nil
+}
+
+// hashGlobalFiles gets the hashes of the global dependencies from turbod, which
+// only hashes them again after they change, unless it isn't running
+func (r *run) hashGlobalFiles(rootpath turbopath.AbsoluteSystemPath, globs []string, ignores []string, files []string) (map[turbopath.AnchoredUnixPath]string, error) {
+	if r.daemonClient != nil {
+		fileHashes, err := r.daemonClient.GlobalFileHashes(gocontext.Background(), globs, ignores, files)
+		if err == nil {
+			r.base.Logger.Debug("using the global file hashes from turbod")
+			return fileHashes, nil
+		}
+		r.base.Logger.Debug("failed to get the global file hashes from turbod", "error", err)
+	}
+	return hashGlobalFiles(rootpath, globs, ignores, files)
+}
+
+func (r *run) run(ctx gocontext.Context, targets []string) error {
+	startAt := time.Now()
+	var daemonClient *daemonclient.DaemonClient
+	if ui.IsCI && !r.opts.runOpts.noDaemon {
+		r.base.Logger.Info("skipping turbod since we appear to be in a non-interactive context")
+	} else if !r.opts.runOpts.noDaemon {
+		turbodClient, err := daemon.GetClient(ctx, r.base.RepoRoot, r.base.Logger, r.base.TurboVersion, daemon.ClientOpts{})
+		if err != nil {
+			r.base.LogWarning("", errors.Wrap(err, "failed to contact turbod. Continuing in standalone mode"))
+		} else {
+			defer func() { _ = turbodClient.Close() }()
+			r.base.Logger.Debug("running in daemon mode")
+			daemonClient = daemonclient.New(turbodClient)
+			r.opts.runcacheOpts.OutputWatcher = daemonClient
+			r.daemonClient = daemonClient
+			defer func() { r.daemonClient = nil }()
+		}
+	}
+
+	prepared, err := r.prepare(targets)
+	if err != nil {
+		return err
+	}
+	g := prepared.g
+	rs := prepared.rs
+	engine := prepared.engine
+	taskHashTracker := prepared.taskHashTracker
+	globalHashable := prepared.globalHashable
+	packageManager := prepared.packageManager
+
+	// Global hash inputs
+	if rs.Opts.runOpts.showGlobalHashInputs {
+		return printGlobalHashInputs(rs, g, globalHashable, r.base)
 	}
 
 	packagesInScope := rs.FilteredPkgs.UnsafeListOfStrings()
@@ -342,7 +772,9 @@ func (r *run) run(ctx gocontext.Context, targets []string) error {
 
 	if err != nil {
 		if errors.Is(err, cache.ErrNoCachesEnabled) {
-			r.base.UI.Warn("No caches are enabled. You can try \"turbo login\", \"turbo link\", or ensuring you are not passing --remote-only to enable caching")
+			if !rs.Opts.runOpts.cacheOff {
+				r.base.UI.Warn("No caches are enabled. You can try \"turbo login\", \"turbo link\", or ensuring you are not passing --remote-only to enable caching")
+			}
 		} else {
 			return errors.Wrap(err, "failed to set up caching")
 		}
@@ -359,8 +791,15 @@ func (r *run) run(ctx gocontext.Context, targets []string) error {
 			globalHashable.envVars,
 			globalHashable.globalCacheKey,
 			globalHashable.pipeline,
+			taskHashTracker.HashVersion(),
 		),
 	)
+	summary.TestSelection = prepared.testSelection
+
+	// Graph Run
+	if rs.Opts.runOpts.graphFile != "" || rs.Opts.runOpts.graphDot || rs.Opts.runOpts.graphMermaid {
+		return GraphRun(ctx, g, rs, engine, taskHashTracker, turboCache, r.base)
+	}
 
 	// Dry Run
 	if rs.Opts.runOpts.dryRun {
@@ -373,13 +812,30 @@ func (r *run) run(ctx gocontext.Context, targets []string) error {
 			turboCache,
 			r.base,
 			summary,
+			packageManager,
 		)
 	}
 
+	summary.Warnings = checkTaskGraph(ctx, engine, g, rs, r.base)
+	if err := reportCacheWarnings(summary.Warnings, rs, r.base); err != nil {
+		return err
+	}
+
 	// RunState captures the runtime results for this run (e.g. timings of each task and profile)
 	runState := NewRunState(startAt, r.opts.runOpts.profile)
+	r.summary = summary
+	// Only cancel this run on a signal while it is running, since `turbo serve`
+	// and `turbo bench` run many times with the same watcher. The watcher is
+	// held until the summary of the canceled run is written.
+	removeOnClose := r.signalWatcher.AddOnClose(func() {
+		runState.cancel()
+		r.processes.Close()
+	})
+	defer removeOnClose()
+	release := r.signalWatcher.Hold()
+	defer release()
 	// Regular run
-	return RealRun(
+	err = RealRun(
 		ctx,
 		g,
 		rs,
@@ -394,36 +850,157 @@ func (r *run) run(ctx gocontext.Context, targets []string) error {
 		r.processes,
 		runState,
 	)
+	if daemonClient != nil {
+		r.notifyRunFinished(ctx, daemonClient, time.Since(startAt), prepared.hashDuration, err != nil)
+	}
+	return err
+}
+
+// notifyRunFinished counts the run and its cache hits and misses in the
+// daemon's metrics
+func (r *run) notifyRunFinished(ctx gocontext.Context, daemonClient *daemonclient.DaemonClient, duration time.Duration, hashDuration time.Duration, failed bool) {
+	cacheHits := 0
+	cacheMisses := 0
+	for _, task := range r.summary.Tasks {
+		if task.Execution == nil {
+			continue
+		}
+		switch task.Execution.Status {
+		case runsummary.TaskStatusCached:
+			cacheHits++
+		case runsummary.TaskStatusBuilt, runsummary.TaskStatusFailed, runsummary.TaskStatusTimedOut:
+			cacheMisses++
+		}
+	}
+	if err := daemonClient.NotifyRunFinished(ctx, cacheHits, cacheMisses, failed, duration, hashDuration); err != nil {
+		r.base.Logger.Debug("failed to report the run to turbod", "error", err)
+	}
 }
 
 func (r *run) initAnalyticsClient(ctx gocontext.Context) analytics.Client {
-	apiClient := r.base.APIClient
-	var analyticsSink analytics.Sink
-	if apiClient.IsLinked() {
-		analyticsSink = apiClient
-	} else {
+	if !r.base.APIClient.IsLinked() && r.opts.cacheOpts.RemoteCacheOpts.S3 == nil {
 		r.opts.cacheOpts.SkipRemote = true
-		analyticsSink = analytics.NullSink
 	}
-	analyticsClient := analytics.NewClient(ctx, analyticsSink, r.base.Logger.Named("analytics"))
+	analyticsClient := analytics.NewClient(ctx, r.base.AnalyticsSink(), r.base.Logger.Named("analytics"))
 	return analyticsClient
 }
 
 func (r *run) initCache(ctx gocontext.Context, rs *runSpec, analyticsClient analytics.Client) (cache.Cache, error) {
-	apiClient := r.base.APIClient
+	rs.Opts.cacheOpts.Provenance = artifactProvenance(r.base)
+	remoteClient, err := remoteCacheClient(r.base, rs.Opts.cacheOpts.RemoteCacheOpts, artifactMetadata(r.base, rs.Opts.cacheOpts))
+	if err != nil {
+		return nil, err
+	}
 	// Theoretically this is overkill, but bias towards not spamming the console
 	once := &sync.Once{}
+	// Collected for the run summary
+	rs.Opts.cacheOpts.RemoteStats = cache.NewRemoteStats()
 
-	return cache.New(rs.Opts.cacheOpts, r.base.RepoRoot, apiClient, analyticsClient, func(_cache cache.Cache, err error) {
+	return cache.New(rs.Opts.cacheOpts, r.base.RepoRoot, remoteClient, analyticsClient, func(_cache cache.Cache, err error) {
 		// Currently the HTTP Cache is the only one that can be disabled.
 		// With a cache system refactor, we might consider giving names to the caches so
 		// we can accurately report them here.
 		once.Do(func() {
 			r.base.LogWarning("Remote Caching is unavailable", err)
 		})
+		// Repeated at the end of the run, since the warning scrolls away
+		rs.Opts.cacheOpts.RemoteStats.Disable(err.Error())
 	})
 }
 
+// artifactMetadata returns the metadata that is uploaded with each artifact,
+// so that the artifacts of a cache can be traced back to where they came from
+func artifactMetadata(base *cmdutil.CmdBase, opts cache.Opts) map[string]string {
+	metadata := map[string]string{}
+	if opts.Scope != "" {
+		metadata["scope"] = opts.Scope
+	}
+	if branch := gitBranch(base); branch != "" {
+		metadata["branch"] = branch
+	}
+	if base.RemoteConfig.TeamSlug != "" {
+		metadata["team"] = base.RemoteConfig.TeamSlug
+	} else if base.RemoteConfig.TeamID != "" {
+		metadata["team"] = base.RemoteConfig.TeamID
+	}
+	if opts.Provenance != nil {
+		for key, value := range opts.Provenance.Metadata() {
+			metadata[key] = value
+		}
+	}
+	return metadata
+}
+
+// artifactProvenance returns who and what produces the artifacts of the run
+func artifactProvenance(base *cmdutil.CmdBase) *cache.Provenance {
+	provenance := &cache.Provenance{
+		CIJobURL:     ci.JobURL(),
+		GitSHA:       gitOutput(base, "rev-parse", "HEAD"),
+		TurboVersion: base.TurboVersion,
+	}
+	if current, err := user.Current(); err == nil {
+		provenance.User = current.Username
+	} else {
+		base.Logger.Debug("failed to get the current user", "error", err)
+	}
+	return provenance
+}
+
+// gitBranch returns the branch that is checked out in the repository, or the
+// empty string if it can't be found, such as when HEAD is detached
+func gitBranch(base *cmdutil.CmdBase) string {
+	branch := gitOutput(base, "rev-parse", "--abbrev-ref", "HEAD")
+	if branch == "HEAD" {
+		return ""
+	}
+	return branch
+}
+
+// gitOutput returns the trimmed output of git with args in the repository, or
+// the empty string if it fails
+func gitOutput(base *cmdutil.CmdBase, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = base.RepoRoot.ToString()
+	out, err := cmd.Output()
+	if err != nil {
+		base.Logger.Debug("failed to run git", "args", args, "error", err)
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// remoteCacheClient returns the client of the S3 bucket if turbo.json configures
+// one, and the client of the Vercel Remote Cache otherwise. Uploaded artifacts
+// carry metadata.
+func remoteCacheClient(base *cmdutil.CmdBase, opts fs.RemoteCacheOptions, metadata map[string]string) (cache.RemoteClient, error) {
+	if opts.S3 == nil {
+		base.APIClient.SetArtifactMetadata(metadata)
+		return base.APIClient, nil
+	}
+	region := opts.S3.Region
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	s3Client, err := client.NewS3Client(client.S3Config{
+		Bucket:          opts.S3.Bucket,
+		Prefix:          opts.S3.Prefix,
+		Region:          region,
+		Endpoint:        opts.S3.Endpoint,
+		PathStyle:       opts.S3.ForcePathStyle,
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		Metadata:        metadata,
+	}, base.Logger.Named("s3"), base.TurboVersion, base.ClientOpts)
+	if err != nil {
+		return nil, err
+	}
+	return s3Client, nil
+}
+
 func buildTaskGraphEngine(
 	g *graph.CompleteGraph,
 	rs *runSpec,
@@ -436,12 +1013,20 @@ func buildTaskGraphEngine(
 		engine.AddTask(taskName)
 	}
 
-	if err := engine.Prepare(&core.EngineBuildingOptions{
-		Packages:  rs.FilteredPkgs.UnsafeListOfStrings(),
-		TaskNames: rs.Targets,
-		TasksOnly: rs.Opts.runOpts.only,
-	}); err != nil {
-		return nil, err
+	groups := rs.Groups
+	if groups == nil {
+		groups = []*runGroupSpec{{Targets: rs.Targets, FilteredPkgs: rs.FilteredPkgs}}
+	}
+	// Every group adds its tasks to the same graph, so that a task that
+	// several groups need is only added once
+	for _, group := range groups {
+		if err := engine.Prepare(&core.EngineBuildingOptions{
+			Packages:  group.FilteredPkgs.UnsafeListOfStrings(),
+			TaskNames: group.Targets,
+			TasksOnly: rs.Opts.runOpts.only,
+		}); err != nil {
+			return nil, err
+		}
 	}
 
 	// Check for cycles in the DAG.
@@ -464,3 +1049,56 @@ const (
 	_dryRunJSONValue = "Json"
 	_dryRunTextValue = "Text"
 )
+
+// graph formats that can be passed instead of a file name. dot and mermaid
+// print to stdout, and html writes graph.html.
+const (
+	_graphDotValue     = "dot"
+	_graphMermaidValue = "mermaid"
+	_graphHTMLValue    = "html"
+)
+
+// log order custom flag
+// NOTE: These *must* be kept in sync with the LogOrder enum in the Rust shim
+const (
+	_logOrderGroupedValue = "Grouped"
+	_logOrderStreamValue  = "Stream"
+)
+
+// cache mode custom flag
+// NOTE: These *must* be kept in sync with the CacheMode enum in the Rust shim
+const (
+	_cacheModeLocalOnlyValue  = "LocalOnly"
+	_cacheModeRemoteOnlyValue = "RemoteOnly"
+	_cacheModeOffValue        = "Off"
+)
+
+// env mode custom flag
+// NOTE: These *must* be kept in sync with the EnvMode enum in the Rust shim
+const (
+	_envModeLooseValue  = "Loose"
+	_envModeStrictValue = "Strict"
+)
+
+// ui custom flag
+// NOTE: These *must* be kept in sync with the UiMode enum in the Rust shim
+const (
+	_uiStreamValue = "Stream"
+	_uiTuiValue    = "Tui"
+)
+
+// summary format custom flag
+// NOTE: These *must* be kept in sync with the SummaryFormat enum in the Rust shim
+const (
+	_summaryFormatJSONValue = "Json"
+	_summaryFormatTextValue = "Text"
+)
+
+// The value of --summarize that prints the resources used by each task
+const _summarizeResourcesValue = "resources"
+
+// The formats of --report, which the Rust shim passes through as written
+const (
+	_reportFormatJUnitValue = "junit"
+	_reportFormatHTMLValue  = "html"
+)
@@ -0,0 +1,159 @@
+package run
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vercel/turbo/cli/internal/context"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/runsummary"
+	"github.com/vercel/turbo/cli/internal/scm"
+	"github.com/vercel/turbo/cli/internal/scope"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// runGroup is a group of tasks that --also adds to a run, with the filters
+// that select the packages they run in
+type runGroup struct {
+	tasks   []string
+	filters []string
+}
+
+// parseRunGroup parses the value of --also, "<tasks> --filter=<filter>",
+// where there can be several tasks and filters. Without a filter, the tasks
+// run in every package.
+func parseRunGroup(value string) (runGroup, error) {
+	var group runGroup
+	fields := strings.Fields(value)
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		switch {
+		case strings.HasPrefix(field, "--filter="):
+			group.filters = append(group.filters, strings.TrimPrefix(field, "--filter="))
+		case field == "--filter" && i+1 < len(fields):
+			i++
+			group.filters = append(group.filters, fields[i])
+		case strings.HasPrefix(field, "-"):
+			return runGroup{}, fmt.Errorf("invalid --also %q: only --filter can be given with the tasks", value)
+		default:
+			group.tasks = append(group.tasks, field)
+		}
+	}
+	if len(group.tasks) == 0 {
+		return runGroup{}, fmt.Errorf("invalid --also %q: expected <tasks> --filter=<filter>", value)
+	}
+	return group, nil
+}
+
+// runGroupSpec is a group of tasks of a run, and the packages it runs them in
+type runGroupSpec struct {
+	Targets      []string
+	Filters      []string
+	FilteredPkgs util.Set
+}
+
+// resolvePackages returns the packages that targets run in. The root package
+// is included when every package is in scope and one of the targets is a root
+// task.
+func (r *run) resolvePackages(scopeOpts *scope.Opts, targets []string, pipeline fs.Pipeline, scmInstance scm.SCM, pkgDepGraph *context.Context) (util.Set, error) {
+	filteredPkgs, isAllPackages, err := scope.ResolvePackages(scopeOpts, r.base.RepoRoot, scmInstance, pkgDepGraph, r.base.UI, r.base.Logger)
+	if err != nil {
+		return nil, err
+	}
+	if isAllPackages {
+		// if there is a root task for any of our targets, we need to add it
+		for _, target := range targets {
+			key := util.RootTaskID(target)
+			if _, ok := pipeline[key]; ok {
+				filteredPkgs.Add(util.RootPkgName)
+				// we only need to know we're running a root task once to add it for consideration
+				break
+			}
+		}
+	}
+	return filteredPkgs, nil
+}
+
+// resolveRunGroups resolves the packages of each group of --also. The tasks on
+// the command line are the first group. The other scope flags, such as
+// --affected, apply to every group, but --scope only to the first one.
+func (r *run) resolveRunGroups(targets []string, filteredPkgs util.Set, pipeline fs.Pipeline, scmInstance scm.SCM, pkgDepGraph *context.Context) ([]*runGroupSpec, error) {
+	if len(r.opts.runOpts.groups) == 0 {
+		return nil, nil
+	}
+	groups := []*runGroupSpec{{
+		Targets:      targets,
+		Filters:      r.opts.scopeOpts.FilterPatterns,
+		FilteredPkgs: filteredPkgs,
+	}}
+	for _, group := range r.opts.runOpts.groups {
+		scopeOpts := r.opts.scopeOpts
+		scopeOpts.FilterPatterns = group.filters
+		scopeOpts.LegacyFilter.Entrypoints = nil
+		groupPkgs, err := r.resolvePackages(&scopeOpts, group.tasks, pipeline, scmInstance, pkgDepGraph)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve packages to run %v: %w", strings.Join(group.tasks, ", "), err)
+		}
+		groups = append(groups, &runGroupSpec{
+			Targets:      group.tasks,
+			Filters:      group.filters,
+			FilteredPkgs: groupPkgs,
+		})
+	}
+	return groups, nil
+}
+
+// mergeRunGroups returns every task of the groups, in the order they were
+// given, and every package that one of them runs in
+func mergeRunGroups(groups []*runGroupSpec) ([]string, util.Set) {
+	var targets []string
+	seen := make(util.Set)
+	filteredPkgs := make(util.Set)
+	for _, group := range groups {
+		for _, target := range group.Targets {
+			if !seen.Includes(target) {
+				seen.Add(target)
+				targets = append(targets, target)
+			}
+		}
+		for _, pkg := range group.FilteredPkgs.UnsafeListOfStrings() {
+			filteredPkgs.Add(pkg)
+		}
+	}
+	return targets, filteredPkgs
+}
+
+// runGroupSummaries lists the tasks of the graph that each group needs: its
+// own tasks, and the tasks they depend on, with the task ids of the summary
+func runGroupSummaries(engine *core.Engine, groups []*runGroupSpec, singlePackage bool) []runsummary.RunGroup {
+	summaries := make([]runsummary.RunGroup, 0, len(groups))
+	for _, group := range groups {
+		taskIDs := make(util.Set)
+		for _, pkg := range group.FilteredPkgs.UnsafeListOfStrings() {
+			for _, target := range group.Targets {
+				taskID := util.GetTaskId(pkg, target)
+				if !engine.TaskGraph.HasVertex(taskID) {
+					continue
+				}
+				taskIDs.Add(taskID)
+				ancestors, err := engine.GetTaskGraphAncestors(taskID)
+				if err != nil {
+					continue
+				}
+				for _, ancestor := range ancestors {
+					taskIDs.Add(ancestor)
+				}
+			}
+		}
+		summary := runsummary.RunGroup{Tasks: group.Targets, Filter: group.Filters}
+		for _, taskID := range taskIDs.UnsafeListOfStrings() {
+			if singlePackage {
+				taskID = util.StripPackageName(taskID)
+			}
+			summary.TaskIDs = append(summary.TaskIDs, taskID)
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
@@ -3,9 +3,18 @@
 package run
 
 import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/vercel/turbo/cli/internal/cache"
 	"github.com/vercel/turbo/cli/internal/client"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/process"
 	"github.com/vercel/turbo/cli/internal/runcache"
+	"github.com/vercel/turbo/cli/internal/runsummary"
 	"github.com/vercel/turbo/cli/internal/scope"
 	"github.com/vercel/turbo/cli/internal/util"
 )
@@ -20,22 +29,50 @@ type runSpec struct {
 	// FilteredPkgs is the list of packages that are relevant for this run.
 	FilteredPkgs util.Set
 
+	// Groups are the tasks and packages of each group of a run with --also,
+	// starting with the tasks on the command line. Targets and FilteredPkgs
+	// are those of every group. Groups is nil without --also.
+	Groups []*runGroupSpec
+
 	// Opts contains various opts, gathered from CLI flags,
 	// but bucketed in smaller structs based on what they mean.
 	Opts *Opts
 }
 
-// ArgsForTask returns the set of args that need to be passed through to the task
-func (rs *runSpec) ArgsForTask(task string) []string {
+// ArgsForTask returns the set of args that need to be passed through to the task:
+// the args after -- if the task was named on the command line, followed by the
+// args from --task-args that target the task
+func (rs *runSpec) ArgsForTask(taskID string) []string {
+	_, task := util.GetPackageTaskFromId(taskID)
 	passThroughArgs := make([]string, 0, len(rs.Opts.runOpts.passThroughArgs))
 	for _, target := range rs.Targets {
 		if target == task {
 			passThroughArgs = append(passThroughArgs, rs.Opts.runOpts.passThroughArgs...)
 		}
 	}
+	for _, taskArg := range rs.Opts.runOpts.taskArgs {
+		if taskArg.target == task || taskArg.target == taskID {
+			passThroughArgs = append(passThroughArgs, taskArg.arg)
+		}
+	}
 	return passThroughArgs
 }
 
+// taskArg is an argument passed to only some tasks with --task-args
+type taskArg struct {
+	// target is a task name, or a package task id
+	target string
+	arg    string
+}
+
+func parseTaskArg(value string) (taskArg, error) {
+	target, arg, ok := strings.Cut(value, "=")
+	if !ok || target == "" {
+		return taskArg{}, fmt.Errorf("invalid --task-args %q: expected <task>=<arg> or <package>#<task>=<arg>", value)
+	}
+	return taskArg{target: target, arg: arg}, nil
+}
+
 // Opts holds the current run operations configuration
 type Opts struct {
 	runOpts      runOpts
@@ -50,6 +87,7 @@ func getDefaultOptions() *Opts {
 	return &Opts{
 		runOpts: runOpts{
 			concurrency: 10,
+			gracePeriod: process.DefaultGracePeriod,
 		},
 		clientOpts: client.Opts{
 			Timeout: client.ClientTimeout,
@@ -63,26 +101,151 @@ type runOpts struct {
 	concurrency int
 	// Whether to execute in parallel (defaults to false)
 	parallel bool
+	// Whether to run tasks one at a time in a stable order (defaults to false)
+	serialDeterministic bool
 
 	// The filename to write a perf profile.
 	profile string
 	// If true, continue task executions even if a task fails.
 	continueOnError bool
+	// How the exit code is chosen when the run fails
+	exitCode        exitCodeOpts
 	passThroughArgs []string
+	// Args for only some tasks, in the order they were given
+	taskArgs []taskArg
+	// More groups of tasks and filters from --also, which run in the same task graph
+	groups []runGroup
 	// Restrict execution to only the listed task names. Default false
 	only bool
+	// The part of the task graph to run when it is split across machines
+	shard *core.Shard
+	// The map of the files that test tasks cover, which narrows down the
+	// tasks of an --affected run
+	testMap string
 	// Dry run flags
 	dryRun     bool
 	dryRunJSON bool
+	// Print only the inputs to the global hash instead of the dry run summary
+	showGlobalHashInputs bool
+	// List the files that cache hits would write instead of the dry run summary
+	restoreDryRun bool
 	// Graph flags
 	graphDot      bool
 	graphFile     string
+	graphMermaid  bool
 	noDaemon      bool
 	singlePackage bool
 
-	// logPrefix controls whether we should print a prefix in task logs
+	// logPrefix is the template for the prefix of task logs, or "none"
 	logPrefix string
+	// logPrefixColor is how the prefixes are colored, see fs.LogsOptions
+	logPrefixColor string
+	// Whether the output of each task is printed in one block once it finishes
+	logOrderGrouped bool
+	// Whether tasks are shown in a full-screen terminal UI instead of streaming their output
+	tui bool
+	// Whether a line with the progress of the run is kept below the output of tasks
+	progress bool
 
 	// Whether turbo should create a run summary
 	summarize bool
+	// Whether the CPU time and peak memory of each task are printed, from --summarize=resources
+	resourcesTable bool
+	// Whether the outcome of the run is written as JSON instead of text
+	summaryJSON bool
+	// Where to write the JSON outcome of the run, instead of stdout
+	summaryFile string
+	// Where to write a JUnit XML report of the run, from --report=junit:<path>
+	junitReportFile string
+	// Where to write an HTML report of the run, from --report=html:<path>
+	htmlReportFile string
+	// Where to write a Markdown report of the run for a pull request comment
+	prReportFile string
+	// A run summary to compare the cache hits in the report against
+	prReportBase string
+	// Whether failed tasks are reported as GitHub Actions annotations, and the
+	// run is added to the job summary
+	githubActions bool
+	// Whether a zip file with the context of each failed task is written to
+	// .turbo/failures
+	failureBundles bool
+	// Whether files in the outputs of a task that were neither written by its
+	// last run nor restored from the cache are reported before it runs, from
+	// --check-outputs
+	checkOutputs bool
+	// Whether those files are removed instead, from --clean-outputs
+	cleanOutputs bool
+	// The file of pinned hashes that tasks are restored from, from --hash-lock
+	hashLockFile string
+	// Where to write the hashes of the run, from --write-hash-lock
+	writeHashLockFile string
+	// Appended to the hash of every task, from --cache-key-suffix
+	cacheKeySuffix string
+	// Whether --cache=off turned off every cache, so that no warning is
+	// printed about it
+	cacheOff bool
+	// Where to write a record of the run for `turbo replay`
+	recordFile string
+	// The flags of the run, saved in its record
+	recordArgs json.RawMessage
+	// The recorded run that `turbo replay` checks this run against
+	replayRecord *runsummary.RunRecord
+	// Whether `turbo replay` continues when the run differs from the record
+	ignoreReplayMismatches bool
+	// The limits from turbo.json on the run summaries kept after saving one
+	summaryRetention runsummary.RetentionPolicy
+
+	// The lifecycle hooks configured in turbo.json
+	hooks fs.Hooks
+
+	// Whether tasks inherit only the environment variables they declare, and
+	// the system variables tools need, instead of turbo's whole environment
+	strictEnv bool
+	// The "globalEnv" variables from turbo.json, which every task inherits in
+	// strict env mode
+	globalEnv []string
+
+	// The webhooks configured in turbo.json that are sent the outcome of the run
+	notifications []fs.Notification
+
+	// The webhooks configured in turbo.json that are sent the status of tasks
+	// while the run is in progress
+	webhooks []fs.Webhook
+
+	// The endpoint from turbo.json that a summary of the run is uploaded to
+	analytics fs.Analytics
+
+	// Where the spans of the run are exported, from --otlp-endpoint or the
+	// OpenTelemetry environment variables
+	otlpURL string
+
+	// Where the events of the run are streamed as NDJSON while it runs, from --events
+	events string
+
+	// Whether to fail the run when the outputs or inputs of tasks are at odds
+	// with git, instead of warning about them
+	strict bool
+
+	// Addresses of workers to dispatch cacheable tasks to (experimental)
+	remoteWorkers []string
+
+	// CA certificate used to verify the workers' TLS certificates
+	remoteWorkersCA string
+
+	// Whether to record the files tasks access and compare them with their configuration (experimental)
+	traceFiles bool
+
+	// Whether to run tasks in a sandbox with only the files their hash covers (experimental)
+	sandbox bool
+
+	// Whether to start tasks that will likely miss the cache before their
+	// dependencies are restored from it (experimental)
+	speculate bool
+
+	// Whether to execute tasks that hit the cache and compare their outputs with the cached ones
+	checkDeterminism bool
+
+	// How long the commands of tasks have to exit when the run is canceled,
+	// before they are killed
+	gracePeriod time.Duration
 }
@@ -0,0 +1,56 @@
+package run
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestArgsForTask(t *testing.T) {
+	opts := getDefaultOptions()
+	opts.runOpts.passThroughArgs = []string{"--ci"}
+	for _, value := range []string{"web#test=--runInBand", "lint=--fix", "test=--coverage", "web#test=--watch=false"} {
+		taskArg, err := parseTaskArg(value)
+		assert.NilError(t, err)
+		opts.runOpts.taskArgs = append(opts.runOpts.taskArgs, taskArg)
+	}
+	rs := &runSpec{Targets: []string{"test"}, Opts: opts}
+
+	testCases := []struct {
+		taskID string
+		want   []string
+	}{
+		{taskID: "web#test", want: []string{"--ci", "--runInBand", "--coverage", "--watch=false"}},
+		{taskID: "docs#test", want: []string{"--ci", "--coverage"}},
+		{taskID: "//#test", want: []string{"--ci", "--coverage"}},
+		{taskID: "web#lint", want: []string{"--fix"}},
+		{taskID: "web#build", want: []string{}},
+	}
+	for _, tc := range testCases {
+		assert.DeepEqual(t, rs.ArgsForTask(tc.taskID), tc.want)
+	}
+}
+
+func TestParseTaskArgRejectsMissingTarget(t *testing.T) {
+	for _, value := range []string{"--runInBand", "=--runInBand"} {
+		_, err := parseTaskArg(value)
+		assert.ErrorContains(t, err, "expected <task>=<arg>")
+	}
+}
+
+func TestParseRunGroup(t *testing.T) {
+	group, err := parseRunGroup("lint test --filter=docs --filter ui")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, group.tasks, []string{"lint", "test"})
+	assert.DeepEqual(t, group.filters, []string{"docs", "ui"})
+
+	group, err = parseRunGroup("build")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, group.tasks, []string{"build"})
+	assert.Assert(t, group.filters == nil)
+
+	_, err = parseRunGroup("--filter=docs")
+	assert.ErrorContains(t, err, "expected <tasks> --filter=<filter>")
+	_, err = parseRunGroup("lint --force")
+	assert.ErrorContains(t, err, "only --filter can be given")
+}
@@ -1,13 +1,18 @@
 package run
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/vercel/turbo/cli/internal/chrometracing"
 	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/process"
+	"github.com/vercel/turbo/cli/internal/runcache"
+	"github.com/vercel/turbo/cli/internal/runsummary"
 	"github.com/vercel/turbo/cli/internal/ui"
 	"github.com/vercel/turbo/cli/internal/util"
 
@@ -36,10 +41,15 @@ type RunResultStatus int
 // The collection of expected build result statuses.
 const (
 	TargetBuilding RunResultStatus = iota
+	// TargetBuildStopped is for targets that were canceled, either while their
+	// command ran or before they started
 	TargetBuildStopped
 	TargetBuilt
 	TargetCached
 	TargetBuildFailed
+	// TargetSkipped is for targets that didn't run because a target they
+	// depend on failed
+	TargetSkipped
 )
 
 type BuildTargetState struct {
@@ -52,39 +62,167 @@ type BuildTargetState struct {
 	Status RunResultStatus
 	// Error, only populated for failure statuses
 	Err error
+	// Why the target wasn't restored from the cache, if it was looked up
+	MissReason runcache.MissReason
+	// How the outputs of a target executed by --check-determinism compare with
+	// the cached ones
+	Determinism *runsummary.TaskDeterminismSummary
+	// The size of the outputs of a target with a "maxOutputSize"
+	OutputSize *runsummary.TaskOutputSizeSummary
+	// Every run of the command of a target with "retries"
+	Attempts []*runsummary.TaskAttemptSummary
+	// The CPU time and peak memory of the command of a target
+	Resources *runsummary.TaskResourceSummary
+	// The command line that ran the script of a target
+	Command []string
+	// The failed targets that a skipped target depends on
+	FailedDependencies []string
 }
 
+// RunState tracks the targets of a run as they start and finish. Every change
+// is sent as an event to a single aggregator goroutine, which owns the state of
+// the targets and the counts of their outcomes, so that the tasks running
+// concurrently never race on them. Reads are sent as events too, so that they
+// see every change sent before them.
 type RunState struct {
-	mu      sync.Mutex
-	state   map[string]*BuildTargetState
+	events   chan func(tally *runTally)
+	stop     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+	// tally is only touched by the aggregator goroutine, until it stops
+	tally *runTally
+
+	startedAt time.Time
+
+	profileFilename string
+}
+
+// RunStats counts the targets of a run by their outcome
+type RunStats struct {
 	Success int
 	Failure int
-	// Is the output streaming?
-	Cached    int
+	Cached  int
+	// Attempted counts the targets that finished, whether they ran, failed or
+	// were cached
 	Attempted int
+	// Targets that didn't run because a dependency failed
+	Skipped int
+	// Failed targets whose command ran for longer than their "timeout"
+	TimedOut int
+	// Targets that were stopped, or never started, because the run was canceled
+	Canceled int
+}
 
-	startedAt time.Time
+// RunStateSnapshot is a consistent copy of the state of a run at one point,
+// for views such as the terminal UI that show it while the run goes on
+type RunStateSnapshot struct {
+	RunStats
+	// Targets holds every target that started, was skipped or was canceled
+	Targets map[string]BuildTargetState
+	// RunCanceled is whether the run was canceled
+	RunCanceled bool
+}
 
-	profileFilename string
+// runTally is the state that the aggregator goroutine of a RunState owns
+type runTally struct {
+	RunStats
+	state    map[string]*BuildTargetState
+	canceled bool
+	// The first task to fail, and its error
+	firstFailure    string
+	firstFailureErr error
+	// The summaries of the tasks that were visited, in the order they started
+	tasks []*runsummary.TaskSummary
 }
 
 // NewRunState creates a RunState instance for tracking events during the
-// course of a run.
+// course of a run, and starts its aggregator goroutine, which runs until the
+// RunState is closed.
 func NewRunState(startedAt time.Time, tracingProfile string) *RunState {
 	if tracingProfile != "" {
 		chrometracing.EnableTracing()
 	}
 
-	return &RunState{
-		Success:         0,
-		Failure:         0,
-		Cached:          0,
-		Attempted:       0,
-		state:           make(map[string]*BuildTargetState),
+	r := &RunState{
+		events:  make(chan func(tally *runTally)),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+		tally: &runTally{
+			state: make(map[string]*BuildTargetState),
+		},
 		profileFilename: tracingProfile,
 
 		startedAt: startedAt,
 	}
+	go r.aggregate()
+	return r
+}
+
+// aggregate applies the events sent to the run state, one at a time, until it
+// is closed
+func (r *RunState) aggregate() {
+	for {
+		select {
+		case event := <-r.events:
+			event(r.tally)
+		case <-r.stop:
+			close(r.stopped)
+			return
+		}
+	}
+}
+
+// stopAggregator stops the aggregator goroutine, once the changes sent before
+// have been applied
+func (r *RunState) stopAggregator() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+		<-r.stopped
+	})
+}
+
+// update sends a change to the aggregator goroutine. Changes sent once the
+// run state is closed are dropped.
+func (r *RunState) update(event func(tally *runTally)) {
+	select {
+	case r.events <- event:
+	case <-r.stopped:
+	}
+}
+
+// read runs query on the aggregator goroutine, after every change sent before
+// it, and returns once it is done. Once the run state is closed, nothing
+// changes the state anymore, so query reads it directly.
+func (r *RunState) read(query func(tally *runTally)) {
+	done := make(chan struct{})
+	select {
+	case r.events <- func(tally *runTally) {
+		query(tally)
+		close(done)
+	}:
+		<-done
+	case <-r.stopped:
+		query(r.tally)
+	}
+}
+
+// Snapshot returns a copy of the state of the run, which doesn't change as the
+// run goes on
+func (r *RunState) Snapshot() *RunStateSnapshot {
+	var snapshot *RunStateSnapshot
+	r.read(func(tally *runTally) {
+		snapshot = &RunStateSnapshot{
+			RunStats:    tally.RunStats,
+			Targets:     make(map[string]BuildTargetState, len(tally.state)),
+			RunCanceled: tally.canceled,
+		}
+		for label, state := range tally.state {
+			target := *state
+			target.Attempts = append([]*runsummary.TaskAttemptSummary(nil), state.Attempts...)
+			snapshot.Targets[label] = target
+		}
+	})
+	return snapshot
 }
 
 func (r *RunState) Run(label string) func(outcome RunResultStatus, err error) {
@@ -93,7 +231,7 @@ func (r *RunState) Run(label string) func(outcome RunResultStatus, err error) {
 		Time:   start,
 		Label:  label,
 		Status: TargetBuilding,
-	}, label, true)
+	})
 
 	tracer := chrometracing.Event(label)
 
@@ -109,48 +247,333 @@ func (r *RunState) Run(label string) func(outcome RunResultStatus, err error) {
 		if err != nil {
 			result.Err = fmt.Errorf("running %v failed: %w", label, err)
 		}
-		r.add(result, label, false)
+		r.add(result)
 	}
 }
 
-func (r *RunState) add(result *RunResult, previous string, active bool) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	if s, ok := r.state[result.Label]; ok {
-		s.Status = result.Status
-		s.Err = result.Err
-		s.Duration = result.Duration
-	} else {
-		r.state[result.Label] = &BuildTargetState{
-			StartAt:  result.Time,
-			Label:    result.Label,
-			Status:   result.Status,
-			Err:      result.Err,
-			Duration: result.Duration,
+func (r *RunState) add(result *RunResult) {
+	r.update(func(tally *runTally) {
+		if s, ok := tally.state[result.Label]; ok {
+			s.Status = result.Status
+			s.Err = result.Err
+			s.Duration = result.Duration
+		} else {
+			tally.state[result.Label] = &BuildTargetState{
+				StartAt:  result.Time,
+				Label:    result.Label,
+				Status:   result.Status,
+				Err:      result.Err,
+				Duration: result.Duration,
+			}
+		}
+		switch {
+		case result.Status == TargetBuildFailed:
+			tally.Failure++
+			tally.Attempted++
+			var timedOut *process.TimedOut
+			if errors.As(result.Err, &timedOut) {
+				tally.TimedOut++
+			}
+			if tally.firstFailure == "" {
+				tally.firstFailure = result.Label
+				tally.firstFailureErr = result.Err
+			}
+		case result.Status == TargetCached:
+			tally.Cached++
+			tally.Attempted++
+		case result.Status == TargetBuilt:
+			tally.Success++
+			tally.Attempted++
+		case result.Status == TargetBuildStopped:
+			tally.Canceled++
+		}
+	})
+}
+
+// cancel records that the run was canceled, after which no more targets start
+func (r *RunState) cancel() {
+	r.update(func(tally *runTally) {
+		tally.canceled = true
+	})
+}
+
+// isCanceled returns whether the run was canceled
+func (r *RunState) isCanceled() bool {
+	canceled := false
+	r.read(func(tally *runTally) {
+		canceled = tally.canceled
+	})
+	return canceled
+}
+
+// cancelTarget records a target that never started because the run was canceled
+func (r *RunState) cancelTarget(label string) {
+	r.update(func(tally *runTally) {
+		tally.state[label] = &BuildTargetState{
+			Label:  label,
+			Status: TargetBuildStopped,
+		}
+		tally.Canceled++
+	})
+}
+
+// skip records a target that didn't run because the given targets it depends on
+// failed. Skipped targets aren't attempted.
+func (r *RunState) skip(label string, failedDependencies []string) {
+	r.update(func(tally *runTally) {
+		tally.state[label] = &BuildTargetState{
+			Label:              label,
+			Status:             TargetSkipped,
+			FailedDependencies: failedDependencies,
+		}
+		tally.Skipped++
+	})
+}
+
+// taskVisited records the summary of a task that the run visited, before it
+// is executed
+func (r *RunState) taskVisited(taskSummary *runsummary.TaskSummary) {
+	r.update(func(tally *runTally) {
+		tally.tasks = append(tally.tasks, taskSummary)
+	})
+}
+
+// taskRolledBack drops the summary of a task that was started early, but whose
+// results were dropped, so that it is left out of the run summary
+func (r *RunState) taskRolledBack(taskSummary *runsummary.TaskSummary) {
+	r.update(func(tally *runTally) {
+		kept := make([]*runsummary.TaskSummary, 0, len(tally.tasks))
+		for _, visited := range tally.tasks {
+			if visited != taskSummary {
+				kept = append(kept, visited)
+			}
 		}
+		tally.tasks = kept
+	})
+}
+
+// taskSummaries returns the summaries of the tasks that the run visited and
+// kept, in the order they started
+func (r *RunState) taskSummaries() []*runsummary.TaskSummary {
+	var taskSummaries []*runsummary.TaskSummary
+	r.read(func(tally *runTally) {
+		taskSummaries = append([]*runsummary.TaskSummary{}, tally.tasks...)
+	})
+	return taskSummaries
+}
+
+// FirstFailure returns the ID and error of the first task to fail, or an empty
+// ID if no task failed
+func (r *RunState) FirstFailure() (string, error) {
+	var label string
+	var err error
+	r.read(func(tally *runTally) {
+		label = tally.firstFailure
+		err = tally.firstFailureErr
+	})
+	return label, err
+}
+
+// targetState returns a copy of the state of a target, and false if it hasn't started
+func (r *RunState) targetState(label string) (BuildTargetState, bool) {
+	var state BuildTargetState
+	ok := false
+	r.read(func(tally *runTally) {
+		if s, found := tally.state[label]; found {
+			state = *s
+			ok = true
+		}
+	})
+	return state, ok
+}
+
+// updateTarget applies change to the state of a target that started
+func (r *RunState) updateTarget(label string, change func(s *BuildTargetState)) {
+	r.update(func(tally *runTally) {
+		if s, ok := tally.state[label]; ok {
+			change(s)
+		}
+	})
+}
+
+// cacheMiss records why a task that started wasn't restored from the cache
+func (r *RunState) cacheMiss(label string, reason runcache.MissReason) {
+	r.updateTarget(label, func(s *BuildTargetState) {
+		s.MissReason = reason
+	})
+}
+
+// determinismChecked records the outputs of a target that differed from the
+// cache when it was executed by --check-determinism
+func (r *RunState) determinismChecked(label string, changedOutputs []string) {
+	r.updateTarget(label, func(s *BuildTargetState) {
+		s.Determinism = &runsummary.TaskDeterminismSummary{
+			Deterministic:  len(changedOutputs) == 0,
+			ChangedOutputs: changedOutputs,
+		}
+	})
+}
+
+// outputSizeMeasured records the size of the outputs of a target with a
+// "maxOutputSize"
+func (r *RunState) outputSizeMeasured(label string, size int64, maxSize int64) {
+	r.updateTarget(label, func(s *BuildTargetState) {
+		s.OutputSize = &runsummary.TaskOutputSizeSummary{
+			Bytes:    size,
+			MaxBytes: maxSize,
+			Exceeded: size > maxSize,
+		}
+	})
+}
+
+// commandAttempted records a run of the command of a target that can be
+// retried, and the error it exited with
+func (r *RunState) commandAttempted(label string, duration time.Duration, err error) {
+	attempt := &runsummary.TaskAttemptSummary{Duration: duration.Milliseconds()}
+	var childExit *process.ChildExit
+	if err == nil {
+		exitCode := 0
+		attempt.ExitCode = &exitCode
+	} else if errors.As(err, &childExit) {
+		attempt.ExitCode = &childExit.ExitCode
+	}
+	r.updateTarget(label, func(s *BuildTargetState) {
+		s.Attempts = append(s.Attempts, attempt)
+	})
+}
+
+// commandResolved records the command line that runs the script of a target
+func (r *RunState) commandResolved(label string, argv []string) {
+	r.updateTarget(label, func(s *BuildTargetState) {
+		s.Command = argv
+	})
+}
+
+// resourcesUsed records the resources used by a run of the command of a
+// target. The CPU time of every run is added up, and the peak memory is the
+// largest of them.
+func (r *RunState) resourcesUsed(label string, usage *process.ResourceUsage) {
+	if usage == nil {
+		return
 	}
-	switch {
-	case result.Status == TargetBuildFailed:
-		r.Failure++
-		r.Attempted++
-	case result.Status == TargetCached:
-		r.Cached++
-		r.Attempted++
-	case result.Status == TargetBuilt:
-		r.Success++
-		r.Attempted++
+	r.updateTarget(label, func(s *BuildTargetState) {
+		// The summary is replaced rather than changed, since copies of the
+		// state share it
+		resources := &runsummary.TaskResourceSummary{}
+		if s.Resources != nil {
+			*resources = *s.Resources
+		}
+		resources.CPUTime += usage.CPUTime.Milliseconds()
+		if usage.PeakRSS > resources.PeakMemory {
+			resources.PeakMemory = usage.PeakRSS
+		}
+		s.Resources = resources
+	})
+}
+
+// determinismChecks returns how many targets were checked by --check-determinism,
+// and how many of those reproduced their cached outputs
+func (snapshot *RunStateSnapshot) determinismChecks() (int, int) {
+	checked := 0
+	deterministic := 0
+	for _, state := range snapshot.Targets {
+		if state.Determinism != nil {
+			checked++
+			if state.Determinism.Deterministic {
+				deterministic++
+			}
+		}
 	}
+	return checked, deterministic
+}
+
+// missReasons describes how many of the tasks that executed missed the cache
+// for each reason, such as "2 hash changed, 1 forced"
+func (snapshot *RunStateSnapshot) missReasons() string {
+	counts := make(map[runcache.MissReason]int)
+	for _, state := range snapshot.Targets {
+		if state.MissReason != "" && (state.Status == TargetBuilt || state.Status == TargetBuildFailed) {
+			counts[state.MissReason]++
+		}
+	}
+	reasons := []string{}
+	for _, reason := range []runcache.MissReason{runcache.MissHashChanged, runcache.MissNoCacheEntry, runcache.MissCorrupted, runcache.MissForced, runcache.MissCacheDisabled} {
+		if counts[reason] > 0 {
+			reasons = append(reasons, fmt.Sprintf("%v %v", counts[reason], strings.ReplaceAll(string(reason), "_", " ")))
+		}
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// executionSummary returns the outcome of the given task, or nil if the task
+// didn't finish
+func (r *RunState) executionSummary(taskID string) *runsummary.TaskExecutionSummary {
+	state, ok := r.targetState(taskID)
+	if !ok {
+		return nil
+	}
+	execution := &runsummary.TaskExecutionSummary{
+		Duration: state.Duration.Milliseconds(),
+	}
+	if !state.StartAt.IsZero() {
+		execution.StartTime = state.StartAt.UnixMilli()
+	}
+	if state.Status != TargetCached {
+		execution.CacheMissReason = string(state.MissReason)
+	}
+	execution.Determinism = state.Determinism
+	execution.OutputSize = state.OutputSize
+	execution.Attempts = state.Attempts
+	execution.Resources = state.Resources
+	execution.Command = state.Command
+	exitCode := 0
+	switch state.Status {
+	case TargetBuilt:
+		execution.Status = runsummary.TaskStatusBuilt
+		execution.ExitCode = &exitCode
+	case TargetCached:
+		execution.Status = runsummary.TaskStatusCached
+		execution.ExitCode = &exitCode
+	case TargetBuildFailed:
+		execution.Status = runsummary.TaskStatusFailed
+		var timedOut *process.TimedOut
+		if errors.As(state.Err, &timedOut) {
+			execution.Status = runsummary.TaskStatusTimedOut
+		}
+		var childExit *process.ChildExit
+		if errors.As(state.Err, &childExit) {
+			execution.ExitCode = &childExit.ExitCode
+		}
+		if state.Err != nil {
+			execution.Error = state.Err.Error()
+		}
+	case TargetSkipped:
+		execution.Status = runsummary.TaskStatusSkipped
+		execution.FailedDependencies = state.FailedDependencies
+	case TargetBuildStopped:
+		execution.Status = runsummary.TaskStatusCanceled
+	default:
+		return nil
+	}
+	return execution
 }
 
 // Close finishes a trace of a turbo run. The tracing file will be written if applicable,
-// and run stats are written to the terminal
-func (r *RunState) Close(terminal cli.Ui) error {
+// and run stats are written to the terminal unless printStats is false, followed
+// by the critical path if there is one. The aggregator goroutine stops: the
+// state can still be read, but later changes are dropped.
+func (r *RunState) Close(terminal cli.Ui, printStats bool, summary *runsummary.ExecutionSummary) error {
+	snapshot := r.Snapshot()
+	r.stopAggregator()
 	if err := writeChrometracing(r.profileFilename, terminal); err != nil {
 		terminal.Error(fmt.Sprintf("Error writing tracing data: %v", err))
 	}
+	if !printStats {
+		return nil
+	}
 
 	maybeFullTurbo := ""
-	if r.Cached == r.Attempted && r.Attempted > 0 {
+	if snapshot.Cached == snapshot.Attempted && snapshot.Attempted > 0 {
 		terminalProgram := os.Getenv("TERM_PROGRAM")
 		// On the macOS Terminal, the rainbow colors show up as a magenta background
 		// with a gray background on a single letter. Instead, we print in bold magenta
@@ -162,15 +585,45 @@ func (r *RunState) Close(terminal cli.Ui) error {
 		}
 	}
 
-	if r.Attempted == 0 {
+	if snapshot.Attempted == 0 {
 		terminal.Output("") // Clear the line
 		terminal.Warn("No tasks were executed as part of this run.")
 	}
 	terminal.Output("") // Clear the line
-	terminal.Output(util.Sprintf("${BOLD} Tasks:${BOLD_GREEN}    %v successful${RESET}${GRAY}, %v total${RESET}", r.Cached+r.Success, r.Attempted))
-	terminal.Output(util.Sprintf("${BOLD}Cached:    %v cached${RESET}${GRAY}, %v total${RESET}", r.Cached, r.Attempted))
+	terminal.Output(util.Sprintf("${BOLD} Tasks:${BOLD_GREEN}    %v successful${RESET}${GRAY}, %v total${RESET}", snapshot.Cached+snapshot.Success, snapshot.Attempted))
+	terminal.Output(util.Sprintf("${BOLD}Cached:    %v cached${RESET}${GRAY}, %v total${RESET}", snapshot.Cached, snapshot.Attempted))
+	if snapshot.Skipped > 0 {
+		terminal.Output(util.Sprintf("${BOLD}Skipped:   %v skipped${RESET}${GRAY}, a dependency failed${RESET}", snapshot.Skipped))
+	}
+	if snapshot.TimedOut > 0 {
+		terminal.Output(util.Sprintf("${BOLD}Timeouts:  %v timed out${RESET}${GRAY}, their command was stopped${RESET}", snapshot.TimedOut))
+	}
+	if snapshot.Canceled > 0 {
+		terminal.Output(util.Sprintf("${BOLD}Canceled:  %v canceled${RESET}${GRAY}, the run was interrupted${RESET}", snapshot.Canceled))
+	}
+	if missReasons := snapshot.missReasons(); missReasons != "" {
+		terminal.Output(util.Sprintf("${BOLD}Misses:    ${RESET}${GRAY}%v${RESET}", missReasons))
+	}
+	if checked, deterministic := snapshot.determinismChecks(); checked > 0 {
+		terminal.Output(util.Sprintf("${BOLD}Checks:    %v reproduced the cache${RESET}${GRAY}, %v checked${RESET}", deterministic, checked))
+	}
 	terminal.Output(util.Sprintf("${BOLD}  Time:    %v${RESET} %v${RESET}", time.Since(r.startedAt).Truncate(time.Millisecond), maybeFullTurbo))
+	if summary.RemoteCache != nil {
+		terminal.Output(util.Sprintf("${GRAY}Remote cache: %v${RESET}", summary.RemoteCache.FormatText()))
+		if summary.RemoteCache.Disabled != "" {
+			terminal.Warn(fmt.Sprintf("The remote cache was turned off during the run (%v), so the tasks after that weren't cached remotely", summary.RemoteCache.Disabled))
+		}
+	}
+	for _, line := range summary.FormatGroups() {
+		terminal.Output(line)
+	}
 	terminal.Output("")
+	if summary.CriticalPath != nil {
+		for _, line := range summary.CriticalPath.FormatText() {
+			terminal.Output(line)
+		}
+		terminal.Output("")
+	}
 	return nil
 }
 
@@ -0,0 +1,177 @@
+package run
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/process"
+	"github.com/vercel/turbo/cli/internal/runsummary"
+	"gotest.tools/v3/assert"
+)
+
+func TestCommandAttempted(t *testing.T) {
+	runState := NewRunState(time.Now(), "")
+	tracer := runState.Run("web#test")
+	runState.commandAttempted("web#test", 1500*time.Millisecond, &process.ChildExit{ExitCode: 2})
+	runState.commandAttempted("web#test", 1200*time.Millisecond, nil)
+	tracer(TargetBuilt, nil)
+
+	failed := 2
+	passed := 0
+	execution := runState.executionSummary("web#test")
+	assert.Equal(t, execution.Status, runsummary.TaskStatusBuilt)
+	assert.DeepEqual(t, execution.Attempts, []*runsummary.TaskAttemptSummary{
+		{Duration: 1500, ExitCode: &failed},
+		{Duration: 1200, ExitCode: &passed},
+	})
+}
+
+func TestSkip(t *testing.T) {
+	runState := NewRunState(time.Now(), "")
+	runState.Run("ui#build")(TargetBuildFailed, &process.ChildExit{ExitCode: 1})
+	runState.skip("web#build", []string{"ui#build"})
+
+	snapshot := runState.Snapshot()
+	assert.Equal(t, snapshot.Attempted, 1)
+	assert.Equal(t, snapshot.Skipped, 1)
+	execution := runState.executionSummary("web#build")
+	assert.Equal(t, execution.Status, runsummary.TaskStatusSkipped)
+	assert.Equal(t, execution.StartTime, int64(0))
+	assert.Assert(t, execution.ExitCode == nil)
+	assert.DeepEqual(t, execution.FailedDependencies, []string{"ui#build"})
+}
+
+func TestTimedOut(t *testing.T) {
+	runState := NewRunState(time.Now(), "")
+	runState.Run("web#dev")(TargetBuildFailed, &process.TimedOut{Timeout: 10 * time.Minute, Command: "npm run dev"})
+
+	snapshot := runState.Snapshot()
+	assert.Equal(t, snapshot.Failure, 1)
+	assert.Equal(t, snapshot.TimedOut, 1)
+	execution := runState.executionSummary("web#dev")
+	assert.Equal(t, execution.Status, runsummary.TaskStatusTimedOut)
+	assert.Assert(t, execution.ExitCode == nil)
+	assert.Equal(t, execution.Error, "running web#dev failed: command npm run dev timed out after 10m0s")
+}
+
+func TestOutputSizeMeasured(t *testing.T) {
+	runState := NewRunState(time.Now(), "")
+	tracer := runState.Run("web#build")
+	runState.outputSizeMeasured("web#build", 6_000_000, 5_000_000)
+	err := &outputSizeError{size: 6_000_000, maxSize: 5_000_000}
+	tracer(TargetBuildFailed, err)
+
+	execution := runState.executionSummary("web#build")
+	assert.Equal(t, execution.Status, runsummary.TaskStatusFailed)
+	assert.Assert(t, execution.ExitCode == nil)
+	assert.DeepEqual(t, execution.OutputSize, &runsummary.TaskOutputSizeSummary{Bytes: 6_000_000, MaxBytes: 5_000_000, Exceeded: true})
+	assert.Equal(t, execution.Error, "running web#build failed: outputs take up 6000000 bytes, over the \"maxOutputSize\" of 5000000 bytes")
+}
+
+func TestResourcesUsed(t *testing.T) {
+	runState := NewRunState(time.Now(), "")
+	tracer := runState.Run("web#test")
+	runState.resourcesUsed("web#test", &process.ResourceUsage{CPUTime: 1500 * time.Millisecond, PeakRSS: 300_000_000})
+	runState.resourcesUsed("web#test", &process.ResourceUsage{CPUTime: 1200 * time.Millisecond, PeakRSS: 200_000_000})
+	runState.resourcesUsed("web#test", nil)
+	tracer(TargetBuilt, nil)
+
+	execution := runState.executionSummary("web#test")
+	assert.DeepEqual(t, execution.Resources, &runsummary.TaskResourceSummary{CPUTime: 2700, PeakMemory: 300_000_000})
+}
+
+func TestCancel(t *testing.T) {
+	runState := NewRunState(time.Now(), "")
+	runState.Run("web#build")(TargetBuilt, nil)
+	runState.cancel()
+	assert.Assert(t, runState.isCanceled())
+	runState.Run("web#dev")(TargetBuildStopped, nil)
+	runState.cancelTarget("web#test")
+
+	snapshot := runState.Snapshot()
+	assert.Equal(t, snapshot.Attempted, 1)
+	assert.Equal(t, snapshot.Canceled, 2)
+	assert.Assert(t, snapshot.RunCanceled)
+	stopped := runState.executionSummary("web#dev")
+	assert.Equal(t, stopped.Status, runsummary.TaskStatusCanceled)
+	assert.Assert(t, stopped.StartTime > 0)
+	assert.Assert(t, stopped.ExitCode == nil)
+	notStarted := runState.executionSummary("web#test")
+	assert.Equal(t, notStarted.Status, runsummary.TaskStatusCanceled)
+	assert.Equal(t, notStarted.StartTime, int64(0))
+}
+
+func TestRunStateConcurrentTargets(t *testing.T) {
+	runState := NewRunState(time.Now(), "")
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		label := fmt.Sprintf("pkg-%v#build", i)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tracer := runState.Run(label)
+			runState.resourcesUsed(label, &process.ResourceUsage{CPUTime: time.Millisecond})
+			// A live view reads the state while the targets change it
+			_ = runState.Snapshot()
+			if i%5 == 0 {
+				tracer(TargetBuildFailed, &process.ChildExit{ExitCode: 1})
+			} else {
+				tracer(TargetCached, nil)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	snapshot := runState.Snapshot()
+	assert.Equal(t, len(snapshot.Targets), 50)
+	assert.Equal(t, snapshot.Attempted, 50)
+	assert.Equal(t, snapshot.Failure, 10)
+	assert.Equal(t, snapshot.Cached, 40)
+
+	// The snapshot doesn't change with the run
+	runState.Run("web#dev")
+	assert.Equal(t, len(snapshot.Targets), 50)
+
+	// Once closed, the state can still be read, and changes are dropped
+	runState.stopAggregator()
+	runState.cancel()
+	assert.Assert(t, !runState.isCanceled())
+	assert.Equal(t, len(runState.Snapshot().Targets), 51)
+}
+
+func TestRunStateConcurrentTasks(t *testing.T) {
+	runState := NewRunState(time.Now(), "")
+	taskSummaries := make([]*runsummary.TaskSummary, 50)
+	var wg sync.WaitGroup
+	for i := range taskSummaries {
+		taskSummaries[i] = &runsummary.TaskSummary{TaskID: fmt.Sprintf("pkg-%v#build", i)}
+		wg.Add(1)
+		go func(taskSummary *runsummary.TaskSummary, rolledBack bool) {
+			defer wg.Done()
+			runState.taskVisited(taskSummary)
+			tracer := runState.Run(taskSummary.TaskID)
+			if rolledBack {
+				runState.taskRolledBack(taskSummary)
+			}
+			tracer(TargetBuilt, nil)
+		}(taskSummaries[i], i%10 == 0)
+	}
+	wg.Wait()
+
+	kept := runState.taskSummaries()
+	assert.Equal(t, len(kept), 45)
+	keptIDs := make(map[string]bool, len(kept))
+	for _, taskSummary := range kept {
+		keptIDs[taskSummary.TaskID] = true
+	}
+	for i, taskSummary := range taskSummaries {
+		assert.Equal(t, keptIDs[taskSummary.TaskID], i%10 != 0, taskSummary.TaskID)
+	}
+
+	// The returned summaries are a copy, which the run doesn't change
+	runState.taskVisited(&runsummary.TaskSummary{TaskID: "web#dev"})
+	assert.Equal(t, len(kept), 45)
+	assert.Equal(t, len(runState.taskSummaries()), 46)
+}
@@ -0,0 +1,38 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+	"gotest.tools/v3/assert"
+)
+
+func TestApplyRunProfile(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	turboJSON := `{
+		"pipeline": {},
+		"profiles": {
+			"ci": {"concurrency": "50%", "continue": true, "outputLogs": "errors-only", "remoteOnly": true}
+		}
+	}`
+	assert.NilError(t, repoRoot.UntypedJoin("turbo.json").WriteFile([]byte(turboJSON), 0644))
+
+	runPayload := &turbostate.RunPayload{ProfileName: "ci", OutputLogs: []string{"full"}}
+	assert.NilError(t, applyRunProfile(runPayload, repoRoot))
+	assert.Equal(t, runPayload.Concurrency, "50%")
+	assert.Assert(t, runPayload.ContinueExecution)
+	assert.Assert(t, runPayload.RemoteOnly)
+	assert.Assert(t, !runPayload.Force)
+	// Flags that were passed take precedence
+	assert.DeepEqual(t, runPayload.OutputLogs, []string{"full"})
+
+	runPayload = &turbostate.RunPayload{ProfileName: "ci", Parallel: true}
+	assert.NilError(t, applyRunProfile(runPayload, repoRoot))
+	assert.Equal(t, runPayload.Concurrency, "")
+	assert.DeepEqual(t, runPayload.OutputLogs, []string{"errors-only"})
+
+	err := applyRunProfile(&turbostate.RunPayload{ProfileName: "local"}, repoRoot)
+	assert.ErrorContains(t, err, `profile "local" not found in turbo.json, expected one of: ci`)
+	assert.NilError(t, applyRunProfile(&turbostate.RunPayload{}, repoRoot))
+}
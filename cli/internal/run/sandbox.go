@@ -0,0 +1,74 @@
+package run
+
+import (
+	"github.com/vercel/turbo/cli/internal/globby"
+	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/sandbox"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// _sandboxHint is shown when a sandboxed task fails, since the most likely
+// reason is a file that the task reads but that its hash doesn't cover
+const _sandboxHint = `the task ran in a sandbox with only the files its hash covers. If it failed to read a file, add the file to "inputs", or to "globalDependencies" if it is outside of the package`
+
+// newSandbox creates the sandbox of a task with --experimental-sandbox. It
+// holds the files the task's hash covers: the root package.json, the global
+// dependencies, the task's inputs, and the inputs and outputs of every task it
+// depends on.
+func (ec *execContext) newSandbox(packageTask *nodes.PackageTask) (*sandbox.Sandbox, error) {
+	files := []turbopath.AnchoredUnixPath{"package.json"}
+	for file := range ec.globalFiles {
+		files = append(files, file)
+	}
+	for file := range ec.taskHashTracker.GetExpandedInputs(packageTask) {
+		files = append(files, file)
+	}
+	dirs := []turbopath.AnchoredSystemPath{"", packageTask.Pkg.Dir}
+	// The command runs in its "cwd", which must exist in the sandbox
+	workingDir, err := packageTask.WorkingDir()
+	if err != nil {
+		return nil, err
+	}
+	dirs = append(dirs, workingDir)
+
+	dependencies, err := ec.engine.GetTaskGraphAncestors(packageTask.TaskID)
+	if err != nil {
+		return nil, err
+	}
+	for _, taskID := range dependencies {
+		packageName, taskName := util.GetPackageTaskFromId(taskID)
+		pkg, ok := ec.completeGraph.WorkspaceInfos.PackageJSONs[packageName]
+		if !ok {
+			continue
+		}
+		taskDefinition, ok := ec.completeGraph.TaskDefinitions[taskID]
+		if !ok {
+			continue
+		}
+		dependency := &nodes.PackageTask{
+			TaskID:         taskID,
+			Task:           taskName,
+			PackageName:    packageName,
+			Pkg:            pkg,
+			TaskDefinition: taskDefinition,
+		}
+		for file := range ec.taskHashTracker.GetExpandedInputs(dependency) {
+			files = append(files, file)
+		}
+		outputs := dependency.RepoRelativeOutputs()
+		outputFiles, err := globby.GlobFiles(ec.repoRoot.ToStringDuringMigration(), outputs.Inclusions, outputs.Exclusions)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range outputFiles {
+			relativePath, err := ec.repoRoot.RelativePathString(file)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, turbopath.AnchoredSystemPath(relativePath).ToUnixPath())
+		}
+		dirs = append(dirs, pkg.Dir)
+	}
+	return sandbox.New(ec.repoRoot, files, dirs)
+}
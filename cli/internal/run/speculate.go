@@ -0,0 +1,137 @@
+package run
+
+import (
+	gocontext "context"
+	"errors"
+	"sort"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/graph"
+	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/runcache"
+	"github.com/vercel/turbo/cli/internal/runsummary"
+)
+
+// planSpeculation hashes every task of the graph ahead of the run, like
+// prefetchArtifacts does, and picks the tasks that --experimental-speculate
+// starts before their dependencies finish. Those are the tasks that will likely
+// miss the cache, while all of the tasks they depend on will likely be restored
+// from it without changing any files, since the daemon saw that their outputs
+// are still the ones in the cache.
+func planSpeculation(ctx gocontext.Context, engine *core.Engine, g *graph.CompleteGraph, rs *runSpec, turboCache cache.Cache, runCache *runcache.RunCache, base *cmdutil.CmdBase) *core.Speculation {
+	tasks := make(map[string]*nodes.PackageTask)
+	collect := func(ctx gocontext.Context, packageTask *nodes.PackageTask, taskSummary *runsummary.TaskSummary) error {
+		tasks[packageTask.TaskID] = packageTask
+		return nil
+	}
+	getArgs := func(taskID string) []string {
+		return rs.ArgsForTask(taskID)
+	}
+	visitorFn := g.GetPackageTaskVisitor(ctx, engine.TaskGraph, getArgs, base.Logger, collect)
+	_ = engine.Execute(visitorFn, core.EngineExecutionOptions{Concurrency: 1})
+
+	cached := func(packageTask *nodes.PackageTask) bool {
+		status := turboCache.Exists(packageTask.Hash)
+		return status.Local || status.Remote
+	}
+	// restored is whether a task will likely be restored from the cache
+	// without changing any files, or has nothing to restore
+	restored := func(taskID string) bool {
+		if taskID == core.ROOT_NODE_NAME {
+			return true
+		}
+		packageTask, ok := tasks[taskID]
+		if !ok {
+			return false
+		}
+		if packageTask.Command == "" {
+			return true
+		}
+		definition := packageTask.TaskDefinition
+		if !definition.ShouldCache || definition.Persistent || !cached(packageTask) {
+			return false
+		}
+		return runCache.TaskCache(packageTask, packageTask.Hash).OutputsUnchanged(ctx)
+	}
+	upstreamRestored := make(map[string]bool)
+	var allRestored func(taskID string) bool
+	allRestored = func(taskID string) bool {
+		if ok, seen := upstreamRestored[taskID]; seen {
+			return ok
+		}
+		ok := restored(taskID)
+		for _, dependency := range engine.TaskGraph.DownEdges(taskID).List() {
+			ok = allRestored(dag.VertexName(dependency)) && ok
+		}
+		upstreamRestored[taskID] = ok
+		return ok
+	}
+
+	var speculative []string
+	for taskID, packageTask := range tasks {
+		definition := packageTask.TaskDefinition
+		if packageTask.Command == "" || !definition.ShouldCache || definition.Persistent || definition.Interactive || cached(packageTask) {
+			continue
+		}
+		dependencies := engine.TaskGraph.DownEdges(taskID).List()
+		if len(dependencies) == 1 && dag.VertexName(dependencies[0]) == core.ROOT_NODE_NAME {
+			// The task starts right away anyway
+			continue
+		}
+		ok := true
+		for _, dependency := range dependencies {
+			ok = ok && allRestored(dag.VertexName(dependency))
+		}
+		if ok {
+			speculative = append(speculative, taskID)
+		}
+	}
+	sort.Strings(speculative)
+	base.Logger.Debug("speculating", "tasks", speculative)
+	return core.NewSpeculation(speculative)
+}
+
+// awaitSpeculation waits for the dependencies of a task that was started
+// before they finished, and returns an error if its results have to be dropped:
+// core.ErrUpstreamFailed if one of them failed, so that the task is skipped, and
+// core.ErrSpeculationStale if one of them executed, since the task may have
+// read the outputs that it replaced, so that the task runs again.
+func (ec *execContext) awaitSpeculation(taskID string) error {
+	if !ec.speculation.StartedEarly(taskID) {
+		return nil
+	}
+	if err := ec.speculation.Wait(taskID); err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	var executed func(taskID string) bool
+	executed = func(taskID string) bool {
+		for _, dependency := range ec.engine.TaskGraph.DownEdges(taskID).List() {
+			dependencyID := dag.VertexName(dependency)
+			if seen[dependencyID] {
+				continue
+			}
+			seen[dependencyID] = true
+			if state, ok := ec.runState.targetState(dependencyID); ok && state.Status == TargetBuilt {
+				return true
+			}
+			if executed(dependencyID) {
+				return true
+			}
+		}
+		return false
+	}
+	if executed(taskID) {
+		return core.ErrSpeculationStale
+	}
+	return nil
+}
+
+// isRolledBack is whether err is returned for a task that was started early, and
+// whose results were dropped by awaitSpeculation
+func isRolledBack(err error) bool {
+	return errors.Is(err, core.ErrUpstreamFailed) || errors.Is(err, core.ErrSpeculationStale)
+}
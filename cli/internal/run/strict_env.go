@@ -0,0 +1,37 @@
+package run
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mitchellh/cli"
+	"github.com/vercel/turbo/cli/internal/env"
+	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// strictEnv returns the environment a task's command inherits with
+// --env-mode=strict: the variables in the task's "env" and "passThroughEnv",
+// the "globalEnv" variables, and the system variables tools need to run. The
+// names of the other variables of turbo's environment are returned as well.
+func (ec *execContext) strictEnv(packageTask *nodes.PackageTask) (env.EnvironmentVariableMap, []string) {
+	envVars := ec.taskHashTracker.GetEnvVars(packageTask.TaskID)
+	keys := append(append([]string{}, ec.rs.Opts.runOpts.globalEnv...), _defaultEnvVars...)
+	return env.StrictEnvironment(keys, envVars.All, envVars.PassThrough)
+}
+
+// warnStrictEnv points out the variables removed from the environment of a task
+// that failed with --env-mode=strict that its output mentions, since they are
+// the most likely reason for the failure
+func warnStrictEnv(logFile turbopath.AbsoluteSystemPath, removed []string, prefixedUI cli.Ui) {
+	if len(removed) == 0 {
+		return
+	}
+	output, err := logFile.ReadFile()
+	if err != nil {
+		return
+	}
+	if mentioned := env.MentionedEnvVars(output, removed); len(mentioned) > 0 {
+		prefixedUI.Warn(fmt.Sprintf("the task ran with --env-mode=strict, and its output mentions variables that were removed from its environment: %v. Add the ones it reads to \"env\", or to \"passThroughEnv\" if they shouldn't change its hash", strings.Join(mentioned, ", ")))
+	}
+}
@@ -0,0 +1,243 @@
+package run
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/packagemanager"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// taskCommand is how the script of a task is run
+type taskCommand struct {
+	name string
+	args []string
+	// env are variables to add to the environment of the command
+	env []string
+}
+
+// argv returns the command line of the command, for the run summary
+func (c taskCommand) argv() []string {
+	return append([]string{c.name}, c.args...)
+}
+
+// resolveTaskCommand returns the command that runs the script of packageTask,
+// with args appended to it, in the given environment. The package manager runs
+// the script, unless the task sets a "shell" or a "command", or its workspace
+// is defined in turbo.json and has no package.json for the package manager to
+// read. A "command" without a "shell" runs with sh -c, or cmd /C on Windows.
+// Scripts that the package manager doesn't run find the executables of
+// node_modules/.bin, like they do when the package manager runs them.
+func resolveTaskCommand(packageManager *packagemanager.PackageManager, packageTask *nodes.PackageTask, args []string, repoRoot turbopath.AbsoluteSystemPath, environ []string) (taskCommand, error) {
+	shell := packageTask.TaskDefinition.Shell
+	if shell == "" && packageTask.TaskDefinition.Command == "" {
+		if packageTask.Pkg.DefinedInTurboJSON {
+			name, args := shellCommand(packageTask.Command, args)
+			return taskCommand{name: name, args: args}, nil
+		}
+		return taskCommand{name: packageManager.Command, args: packageManager.ScriptArgs(packageTask.Task, args)}, nil
+	}
+
+	command := taskCommand{}
+	path := getenv(environ, "PATH")
+	if !packageTask.Pkg.DefinedInTurboJSON {
+		pkgDir := packageTask.Pkg.Dir.RestoreAnchor(repoRoot)
+		bins := []string{pkgDir.UntypedJoin("node_modules", ".bin").ToString(), repoRoot.UntypedJoin("node_modules", ".bin").ToString()}
+		if path != "" {
+			bins = append(bins, path)
+		}
+		path = strings.Join(bins, string(os.PathListSeparator))
+		command.env = []string{"PATH=" + path}
+	}
+
+	switch shell {
+	case "":
+		command.name, command.args = shellCommand(packageTask.Command, args)
+	case fs.TaskShellBash:
+		script := packageTask.Command
+		for _, arg := range args {
+			script += " " + bashQuote(arg)
+		}
+		command.name, command.args = "bash", []string{"-c", script}
+	case fs.TaskShellPwsh:
+		script := packageTask.Command
+		for _, arg := range args {
+			script += " " + pwshQuote(arg)
+		}
+		command.name, command.args = "pwsh", []string{"-NoLogo", "-NoProfile", "-NonInteractive", "-Command", script}
+	case fs.TaskShellNone:
+		words, err := splitPortableCommand(packageTask.Command, func(name string) string {
+			return getenv(environ, name)
+		})
+		if err != nil {
+			return taskCommand{}, fmt.Errorf("invalid script for %v: %w", packageTask.TaskID, err)
+		}
+		command.name, command.args = lookPathIn(words[0], path), append(words[1:], args...)
+	default:
+		return taskCommand{}, fmt.Errorf("unknown shell %q for %v", shell, packageTask.TaskID)
+	}
+	return command, nil
+}
+
+// getenv returns the last value of name in environ, which is the one that a
+// command sees. Names are case-insensitive on Windows.
+func getenv(environ []string, name string) string {
+	for i := len(environ) - 1; i >= 0; i-- {
+		key, value, ok := strings.Cut(environ[i], "=")
+		if ok && (key == name || (runtime.GOOS == "windows" && strings.EqualFold(key, name))) {
+			return value
+		}
+	}
+	return ""
+}
+
+// lookPathIn returns the path of the executable name in the directories of
+// path, since exec.Command only looks it up in the PATH of turbo. Names with a
+// separator, and names that aren't found, are returned unchanged.
+func lookPathIn(name string, path string) string {
+	if strings.ContainsAny(name, `/\`) {
+		return name
+	}
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			continue
+		}
+		if file, err := exec.LookPath(filepath.Join(dir, name)); err == nil {
+			return file
+		}
+	}
+	return name
+}
+
+// bashQuote quotes arg so that bash passes it to the script unchanged
+func bashQuote(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// pwshQuote quotes arg so that PowerShell passes it to the script unchanged
+func pwshQuote(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", "''") + "'"
+}
+
+// _shellOperators can't appear outside of quotes in scripts that run without
+// a shell, since nothing would interpret them
+const _shellOperators = "|&;<>()`"
+
+// splitPortableCommand splits a script that runs without a shell into words,
+// the same way on every platform:
+//
+//   - words are separated by spaces, tabs and newlines
+//   - single quotes keep everything up to the next single quote
+//   - double quotes keep everything up to the next double quote, except that
+//     variables are expanded and \" \\ \$ are escapes
+//   - outside of quotes, a backslash escapes a quote, a backslash, a $ or a
+//     space, and is kept before any other character, so that Windows paths
+//     can be written as they are
+//   - $NAME and ${NAME} are replaced by the value of the variable, outside of
+//     single quotes
+//
+// Pipes, redirections and other shell operators are errors.
+func splitPortableCommand(script string, getenv func(string) string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	runes := []rune(script)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case r == '\'':
+			inWord = true
+			end := indexRune(runes, i+1, '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote in %q", script)
+			}
+			word.WriteString(string(runes[i+1 : end]))
+			i = end
+		case r == '"':
+			inWord = true
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				switch {
+				case runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune(`"\$`, runes[i+1]):
+					i++
+					word.WriteRune(runes[i])
+				case runes[i] == '$':
+					i = expandVariable(runes, i, getenv, &word)
+				default:
+					word.WriteRune(runes[i])
+				}
+			}
+			if i == len(runes) {
+				return nil, fmt.Errorf("unterminated double quote in %q", script)
+			}
+		case r == '\\':
+			inWord = true
+			if i+1 < len(runes) && strings.ContainsRune("'\"\\$ \t", runes[i+1]) {
+				i++
+			}
+			word.WriteRune(runes[i])
+		case r == '$':
+			inWord = true
+			i = expandVariable(runes, i, getenv, &word)
+		case strings.ContainsRune(_shellOperators, r):
+			return nil, fmt.Errorf("%q needs a shell to interpret %q. Set \"shell\" to %q or %q", script, string(r), fs.TaskShellBash, fs.TaskShellPwsh)
+		default:
+			inWord = true
+			word.WriteRune(r)
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("the script is empty")
+	}
+	return words, nil
+}
+
+func indexRune(runes []rune, from int, r rune) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// expandVariable writes the value of the variable that starts with the $ at
+// runes[i] to word, and returns the index of the last rune of the variable. A $
+// that doesn't start a variable is kept.
+func expandVariable(runes []rune, i int, getenv func(string) string, word *strings.Builder) int {
+	isNameRune := func(r rune) bool {
+		return r == '_' || (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9')
+	}
+	if i+1 < len(runes) && runes[i+1] == '{' {
+		end := indexRune(runes, i+2, '}')
+		if end > i+2 {
+			word.WriteString(getenv(string(runes[i+2 : end])))
+			return end
+		}
+	}
+	end := i + 1
+	for end < len(runes) && isNameRune(runes[end]) {
+		end++
+	}
+	if end == i+1 {
+		word.WriteRune('$')
+		return i
+	}
+	word.WriteString(getenv(string(runes[i+1 : end])))
+	return end - 1
+}
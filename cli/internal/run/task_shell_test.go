@@ -0,0 +1,96 @@
+package run
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/packagemanager"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func TestSplitPortableCommand(t *testing.T) {
+	getenv := func(name string) string {
+		return map[string]string{"OUT": "dist dir", "MODE": "prod"}[name]
+	}
+	testCases := []struct {
+		script string
+		words  []string
+		err    string
+	}{
+		{script: "tsc -p tsconfig.json", words: []string{"tsc", "-p", "tsconfig.json"}},
+		{script: `  eslint  'src/**/*.ts'   "--ext" .ts `, words: []string{"eslint", "src/**/*.ts", "--ext", ".ts"}},
+		{script: `node build.js --out "$OUT" --mode=${MODE} '$MODE'`, words: []string{"node", "build.js", "--out", "dist dir", "--mode=prod", "$MODE"}},
+		{script: `echo "say \"hi\"" a\ b \$HOME`, words: []string{"echo", `say "hi"`, "a b", "$HOME"}},
+		{script: `tools\build.exe C:\out`, words: []string{`tools\build.exe`, `C:\out`}},
+		{script: `echo $ ""`, words: []string{"echo", "$", ""}},
+		{script: "tsc && vite build", err: `"tsc && vite build" needs a shell to interpret "&". Set "shell" to "bash" or "pwsh"`},
+		{script: "echo 'hi", err: `unterminated single quote in "echo 'hi"`},
+		{script: `echo "hi`, err: `unterminated double quote in "echo \"hi"`},
+		{script: " ", err: "the script is empty"},
+	}
+	for _, tc := range testCases {
+		words, err := splitPortableCommand(tc.script, getenv)
+		if tc.err != "" {
+			assert.Error(t, err, tc.err, tc.script)
+			continue
+		}
+		assert.NilError(t, err, tc.script)
+		assert.DeepEqual(t, words, tc.words)
+	}
+}
+
+func TestResolveTaskCommand(t *testing.T) {
+	repoRoot := turbopath.AbsoluteSystemPath(t.TempDir())
+	packageManager := &packagemanager.PackageManager{Command: "npm"}
+	packageTask := func(shell string, script string) *nodes.PackageTask {
+		return &nodes.PackageTask{
+			TaskID:         "ui#build",
+			Task:           "build",
+			Pkg:            &fs.PackageJSON{Dir: turbopath.AnchoredUnixPath("packages/ui").ToSystemPath()},
+			TaskDefinition: &fs.TaskDefinition{Shell: shell},
+			Command:        script,
+		}
+	}
+	environ := []string{"PATH=/usr/bin", "NAME=ui"}
+	bins := repoRoot.UntypedJoin("packages", "ui", "node_modules", ".bin").ToString() + string(os.PathListSeparator) + repoRoot.UntypedJoin("node_modules", ".bin").ToString()
+
+	command, err := resolveTaskCommand(packageManager, packageTask("", "tsc"), []string{"--watch"}, repoRoot, environ)
+	assert.NilError(t, err)
+	assert.Equal(t, command.name, "npm")
+	assert.Assert(t, command.env == nil, "the package manager sets up the PATH of its scripts")
+
+	command, err = resolveTaskCommand(packageManager, packageTask(fs.TaskShellBash, "tsc && vite build"), []string{"--mode", "it's"}, repoRoot, environ)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, command.argv(), []string{"bash", "-c", `tsc && vite build '--mode' 'it'\''s'`})
+	assert.DeepEqual(t, command.env, []string{"PATH=" + bins + string(os.PathListSeparator) + "/usr/bin"})
+
+	command, err = resolveTaskCommand(packageManager, packageTask(fs.TaskShellPwsh, "Write-Output $env:NAME"), []string{"it's"}, repoRoot, environ)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, command.argv(), []string{"pwsh", "-NoLogo", "-NoProfile", "-NonInteractive", "-Command", "Write-Output $env:NAME 'it''s'"})
+
+	// A "command" in place of the script runs with the shell of the platform
+	overridden := packageTask("", "protoc --go_out=. api.proto")
+	overridden.TaskDefinition.Command = "protoc --go_out=. api.proto"
+	command, err = resolveTaskCommand(packageManager, overridden, nil, repoRoot, environ)
+	assert.NilError(t, err)
+	name, args := shellCommand("protoc --go_out=. api.proto", nil)
+	assert.DeepEqual(t, command.argv(), append([]string{name}, args...))
+	assert.DeepEqual(t, command.env, []string{"PATH=" + bins + string(os.PathListSeparator) + "/usr/bin"})
+
+	if runtime.GOOS != "windows" {
+		tsc := repoRoot.UntypedJoin("node_modules", ".bin", "tsc")
+		assert.NilError(t, tsc.EnsureDir())
+		assert.NilError(t, tsc.WriteFile([]byte("#!/bin/sh\n"), 0755))
+		command, err = resolveTaskCommand(packageManager, packageTask(fs.TaskShellNone, "tsc --outDir dist/$NAME"), []string{"--watch"}, repoRoot, environ)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, command.argv(), []string{filepath.Join(repoRoot.ToString(), "node_modules", ".bin", "tsc"), "--outDir", "dist/ui", "--watch"})
+	}
+
+	_, err = resolveTaskCommand(packageManager, packageTask(fs.TaskShellNone, "tsc | tee log"), nil, repoRoot, environ)
+	assert.ErrorContains(t, err, "invalid script for ui#build")
+}
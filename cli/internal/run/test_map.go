@@ -0,0 +1,98 @@
+package run
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/scm"
+	"github.com/vercel/turbo/cli/internal/scope"
+	"github.com/vercel/turbo/cli/internal/testmap"
+	"github.com/vercel/turbo/cli/internal/util"
+	"github.com/vercel/turbo/cli/internal/workspace"
+)
+
+// selectTests removes the tasks of the --test-map that don't cover any of the
+// files changed since the --affected base from the graph, and returns why each
+// of the tasks of the map runs or not. Tasks that other tasks in the graph
+// depend on still run.
+func (r *run) selectTests(engine *core.Engine, workspaceInfos workspace.Catalog, scmInstance scm.SCM) ([]*testmap.Selection, error) {
+	if r.opts.runOpts.testMap == "" || r.opts.scopeOpts.AffectedBase == "" {
+		return nil, nil
+	}
+	testMap, err := testmap.Load(fs.ResolveUnknownPath(r.base.RepoRoot, r.opts.runOpts.testMap))
+	if err != nil {
+		return nil, err
+	}
+	files, globalChanged, err := scope.AffectedFiles(&r.opts.scopeOpts, scmInstance, r.base.RepoRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the files changed since %v: %w", r.opts.scopeOpts.AffectedBase, err)
+	}
+
+	var taskIDs []string
+	for _, v := range engine.TaskGraph.Vertices() {
+		if taskID := dag.VertexName(v); !strings.Contains(taskID, core.ROOT_NODE_NAME) {
+			taskIDs = append(taskIDs, taskID)
+		}
+	}
+	selections := testMap.Select(taskIDs, testmap.Changes{
+		Files:         files,
+		GlobalChanged: globalChanged,
+		Workspace:     fileWorkspace(workspaceInfos, r.opts.runOpts.singlePackage),
+	})
+
+	var deselected []string
+	for _, selection := range selections {
+		if !selection.Selected {
+			deselected = append(deselected, selection.TaskID)
+		}
+	}
+	kept := make(util.Set)
+	for _, taskID := range engine.RemoveEntryTasks(deselected) {
+		kept.Add(taskID)
+	}
+	for _, selection := range selections {
+		if kept.Includes(selection.TaskID) {
+			selection.Selected = true
+			selection.Reason = fmt.Sprintf("%v, but other tasks depend on it", selection.Reason)
+		}
+	}
+	r.base.Logger.Debug("selected tests", "changed", len(files), "skipped", len(deselected)-kept.Len())
+	return selections, nil
+}
+
+// fileWorkspace returns a function that finds the workspace that a file, relative
+// to the repository root, is in. Files in the root workspace aren't in any
+// workspace, unless the repository is a single package.
+func fileWorkspace(workspaceInfos workspace.Catalog, singlePackage bool) func(file string) (string, bool) {
+	type workspaceDir struct {
+		name string
+		dir  string
+	}
+	var dirs []workspaceDir
+	for name, pkg := range workspaceInfos.PackageJSONs {
+		if name == util.RootPkgName && !singlePackage {
+			continue
+		}
+		dir := pkg.Dir.ToUnixPath().ToString()
+		if dir == "." {
+			dir = ""
+		}
+		dirs = append(dirs, workspaceDir{name: name, dir: dir})
+	}
+	// Nested workspaces are found before the workspaces that contain them
+	sort.Slice(dirs, func(i, j int) bool {
+		return len(dirs[i].dir) > len(dirs[j].dir)
+	})
+	return func(file string) (string, bool) {
+		for _, d := range dirs {
+			if d.dir == "" || file == d.dir || strings.HasPrefix(file, d.dir+"/") {
+				return d.name, true
+			}
+		}
+		return "", false
+	}
+}
@@ -0,0 +1,552 @@
+package run
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/vercel/turbo/cli/internal/process"
+	"github.com/vercel/turbo/cli/internal/ui"
+	"golang.org/x/term"
+)
+
+// _tuiMaxLines is how many lines of output are kept for each task
+const _tuiMaxLines = 5000
+
+// _tuiRefreshInterval is how often the table is redrawn, to update elapsed times
+const _tuiRefreshInterval = 100 * time.Millisecond
+
+// tuiTask holds the output of a task for --ui=tui. Like a logGroup, it is a
+// cli.Ui for turbo's messages about the task, and an io.Writer for the output
+// of its command.
+type tuiTask struct {
+	taskID string
+	mu     sync.Mutex
+	lines  []string
+	// partial is the end of the output that isn't a whole line yet
+	partial string
+}
+
+var _ cli.Ui = (*tuiTask)(nil)
+
+// Ask isn't supported, since tasks don't prompt for input
+func (t *tuiTask) Ask(string) (string, error) {
+	return "", fmt.Errorf("cannot ask for input in the terminal UI")
+}
+
+// AskSecret isn't supported, since tasks don't prompt for input
+func (t *tuiTask) AskSecret(string) (string, error) {
+	return "", fmt.Errorf("cannot ask for input in the terminal UI")
+}
+
+func (t *tuiTask) Output(message string) { t.addMessage(message) }
+func (t *tuiTask) Info(message string)   { t.addMessage(message) }
+func (t *tuiTask) Warn(message string)   { t.addMessage(message) }
+func (t *tuiTask) Error(message string)  { t.addMessage(message) }
+
+// Write holds the output of the task's command, which is already prefixed
+func (t *tuiTask) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	lines := strings.Split(t.partial+string(p), "\n")
+	t.partial = lines[len(lines)-1]
+	t.appendLines(lines[:len(lines)-1])
+	return len(p), nil
+}
+
+func (t *tuiTask) addMessage(message string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.appendLines(strings.Split(message, "\n"))
+}
+
+// appendLines must be called with mu held. Old lines are dropped in batches,
+// so that a task that prints a lot doesn't copy its output on every line.
+func (t *tuiTask) appendLines(lines []string) {
+	for _, line := range lines {
+		t.lines = append(t.lines, strings.TrimSuffix(line, "\r"))
+	}
+	if len(t.lines) > 2*_tuiMaxLines {
+		t.lines = append([]string(nil), t.lines[len(t.lines)-_tuiMaxLines:]...)
+	}
+}
+
+// output returns the last lines of output of the task, including an
+// unfinished last line
+func (t *tuiTask) output() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	lines := t.lines
+	if len(lines) > _tuiMaxLines {
+		lines = lines[len(lines)-_tuiMaxLines:]
+	}
+	if t.partial != "" {
+		lines = append(lines[:len(lines):len(lines)], t.partial)
+	}
+	return lines
+}
+
+// tui is the full-screen terminal UI of `turbo run --ui=tui`. It shows a table
+// of the tasks of the run with their status, cache state and elapsed time, and
+// the output of one task once it is focused.
+type tui struct {
+	mu       sync.Mutex
+	title    string
+	tasks    []*tuiTask
+	byID     map[string]*tuiTask
+	runState *RunState
+	selected int
+	// focused shows the output of the selected task instead of the table
+	focused bool
+	// scroll is how many lines the output of the focused task is scrolled back
+	// from its end, which follows new output
+	scroll int
+
+	// quit stops the run when q or ctrl-c is pressed, since the terminal in
+	// raw mode doesn't send a signal for ctrl-c
+	quit     func()
+	quitOnce sync.Once
+	// messages holds turbo's messages that aren't about a task, which are
+	// printed once the terminal UI is closed
+	messages *logGroup
+
+	out             io.Writer
+	done            chan struct{}
+	drawing         sync.WaitGroup
+	readingKeys     chan struct{}
+	restoreTerminal func()
+	stopOnce        sync.Once
+}
+
+func newTUI(title string, taskIDs []string, runState *RunState, quit func()) *tui {
+	sortedIDs := append([]string(nil), taskIDs...)
+	sort.Strings(sortedIDs)
+	t := &tui{
+		title:    title,
+		byID:     make(map[string]*tuiTask, len(sortedIDs)),
+		runState: runState,
+		quit:     quit,
+		messages: &logGroup{},
+		done:     make(chan struct{}),
+	}
+	for _, taskID := range sortedIDs {
+		task := &tuiTask{taskID: taskID}
+		t.tasks = append(t.tasks, task)
+		t.byID[taskID] = task
+	}
+	return t
+}
+
+// task returns where the output of the given task goes
+func (t *tui) task(taskID string) *tuiTask {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if task, ok := t.byID[taskID]; ok {
+		return task
+	}
+	task := &tuiTask{taskID: taskID}
+	t.tasks = append(t.tasks, task)
+	t.byID[taskID] = task
+	return task
+}
+
+// remove drops a task from the table, for packages that don't have the task's script
+func (t *tui) remove(taskID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.byID, taskID)
+	for i, task := range t.tasks {
+		if task.taskID == taskID {
+			t.tasks = append(t.tasks[:i], t.tasks[i+1:]...)
+			break
+		}
+	}
+	if t.selected >= len(t.tasks) && t.selected > 0 {
+		t.selected = len(t.tasks) - 1
+	}
+}
+
+// start switches the terminal to a full screen in raw mode, and draws the UI
+// until stop is called
+func (t *tui) start() error {
+	stdin := int(os.Stdin.Fd())
+	if !term.IsTerminal(stdin) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return errors.New("--ui=tui requires a terminal")
+	}
+	oldState, err := term.MakeRaw(stdin)
+	if err != nil {
+		return err
+	}
+	t.restoreTerminal = func() { _ = term.Restore(stdin, oldState) }
+	t.out = os.Stdout
+	// The alternate screen leaves the scrollback of the terminal as it was
+	_, _ = io.WriteString(t.out, "\x1b[?1049h\x1b[?25l")
+
+	keys := make(chan string)
+	t.readingKeys = make(chan struct{})
+	go func() {
+		defer close(t.readingKeys)
+		readKeys(os.Stdin, keys, t.done)
+	}()
+	t.drawing.Add(1)
+	go t.loop(keys)
+	return nil
+}
+
+// stop closes the terminal UI, then prints the output of the tasks that
+// failed and turbo's messages, which would otherwise go with the full screen
+func (t *tui) stop(base cli.Ui) {
+	t.stopOnce.Do(func() {
+		close(t.done)
+		t.drawing.Wait()
+		_, _ = io.WriteString(t.out, "\x1b[?25h\x1b[?1049l")
+		t.restoreTerminal()
+		// Unblock the read of the key reader, so that it doesn't take input
+		// meant for whatever reads stdin next
+		if err := os.Stdin.SetReadDeadline(time.Now()); err == nil {
+			select {
+			case <-t.readingKeys:
+			case <-time.After(_tuiRefreshInterval):
+			}
+			_ = os.Stdin.SetReadDeadline(time.Time{})
+		}
+
+		t.mu.Lock()
+		tasks := append([]*tuiTask(nil), t.tasks...)
+		t.mu.Unlock()
+		snapshot := t.runState.Snapshot()
+		for _, task := range tasks {
+			if state, ok := snapshot.Targets[task.taskID]; ok && state.Status == TargetBuildFailed {
+				for _, line := range task.output() {
+					base.Output(line)
+				}
+			}
+		}
+		t.messages.mu.Lock()
+		entries := t.messages.entries
+		t.messages.entries = nil
+		t.messages.mu.Unlock()
+		printLogEntries(entries, base, os.Stdout)
+	})
+}
+
+func (t *tui) loop(keys <-chan string) {
+	defer t.drawing.Done()
+	ticker := time.NewTicker(_tuiRefreshInterval)
+	defer ticker.Stop()
+	for {
+		width, height, err := term.GetSize(int(os.Stdout.Fd()))
+		if err != nil {
+			width, height = 80, 24
+		}
+		t.draw(t.render(width, height, time.Now()))
+		select {
+		case <-t.done:
+			return
+		case <-ticker.C:
+		case key := <-keys:
+			if t.handleKey(key, height) {
+				// Stopping the processes can take a while, and the UI keeps
+				// showing them in the meantime
+				t.quitOnce.Do(func() { go t.quit() })
+			}
+		}
+	}
+}
+
+// draw replaces the screen with lines
+func (t *tui) draw(lines []string) {
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for i, line := range lines {
+		if i > 0 {
+			// Raw mode doesn't turn \n into \r\n
+			b.WriteString("\r\n")
+		}
+		b.WriteString(line)
+		b.WriteString("\x1b[K")
+	}
+	b.WriteString("\x1b[J")
+	_, _ = io.WriteString(t.out, b.String())
+}
+
+// _escapeSequences are the keys sent as escape sequences that the UI handles
+var _escapeSequences = map[string]string{
+	"[A":  "up",
+	"OA":  "up",
+	"[B":  "down",
+	"OB":  "down",
+	"[5~": "pgup",
+	"[6~": "pgdown",
+	"[H":  "home",
+	"[F":  "end",
+}
+
+// readKeys sends the keys pressed in the terminal until done is closed or
+// reading fails
+func readKeys(r io.Reader, keys chan<- string, done <-chan struct{}) {
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			return
+		}
+		for _, key := range parseKeys(buf[:n]) {
+			select {
+			case keys <- key:
+			case <-done:
+				return
+			}
+		}
+	}
+}
+
+// parseKeys turns input from a terminal in raw mode into the names of keys
+func parseKeys(input []byte) []string {
+	var keys []string
+	for i := 0; i < len(input); i++ {
+		switch input[i] {
+		case 3, 'q':
+			keys = append(keys, "quit")
+		case '\r', '\n':
+			keys = append(keys, "enter")
+		case 'k':
+			keys = append(keys, "up")
+		case 'j':
+			keys = append(keys, "down")
+		case 'g':
+			keys = append(keys, "home")
+		case 'G':
+			keys = append(keys, "end")
+		case 0x1b:
+			key := "esc"
+			for sequence, name := range _escapeSequences {
+				if strings.HasPrefix(string(input[i+1:]), sequence) {
+					key = name
+					i += len(sequence)
+					break
+				}
+			}
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// handleKey updates the UI for a key, and returns true if the run should stop.
+// height is the height of the terminal, which is how far pgup and pgdown go.
+func (t *tui) handleKey(key string, height int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	page := height - 4
+	if page < 1 {
+		page = 1
+	}
+	switch key {
+	case "quit":
+		return true
+	case "enter":
+		if len(t.tasks) > 0 {
+			t.focused = true
+			t.scroll = 0
+		}
+	case "esc":
+		t.focused = false
+	case "up":
+		t.move(1, -1)
+	case "down":
+		t.move(-1, 1)
+	case "pgup":
+		t.move(page, -page)
+	case "pgdown":
+		t.move(-page, page)
+	case "home":
+		t.move(_tuiMaxLines, -len(t.tasks))
+	case "end":
+		t.move(-_tuiMaxLines, len(t.tasks))
+	}
+	return false
+}
+
+// move scrolls the focused output by scroll lines, or moves the selection in
+// the table by rows. The scroll is limited to the output when it is rendered.
+func (t *tui) move(scroll int, rows int) {
+	if t.focused {
+		t.scroll = clamp(t.scroll+scroll, 0, _tuiMaxLines)
+		return
+	}
+	t.selected = clamp(t.selected+rows, 0, len(t.tasks)-1)
+}
+
+// clamp limits value to [low, high], and to low if high is lower than low
+func clamp(value int, low int, high int) int {
+	if value > high {
+		value = high
+	}
+	if value < low {
+		value = low
+	}
+	return value
+}
+
+// render returns the lines of the screen, without ANSI codes, for a terminal
+// of the given size
+func (t *tui) render(width int, height int, now time.Time) []string {
+	// Every row shows the run at the same point
+	snapshot := t.runState.Snapshot()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var lines []string
+	if t.focused && t.selected < len(t.tasks) {
+		lines = t.renderOutput(snapshot, t.tasks[t.selected], height, now)
+	} else {
+		lines = t.renderTable(snapshot, height, now)
+	}
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	for i, line := range lines {
+		lines[i] = truncate(line, width)
+	}
+	return lines
+}
+
+type tuiRow struct {
+	status  string
+	cache   string
+	elapsed time.Duration
+}
+
+func (t *tui) row(snapshot *RunStateSnapshot, task *tuiTask, now time.Time) tuiRow {
+	state, ok := snapshot.Targets[task.taskID]
+	if !ok {
+		return tuiRow{status: "waiting"}
+	}
+	row := tuiRow{elapsed: state.Duration}
+	switch state.Status {
+	case TargetBuilding:
+		row.status = "running"
+		row.elapsed = now.Sub(state.StartAt)
+	case TargetBuilt:
+		row.status = "done"
+	case TargetCached:
+		row.status = "cached"
+		row.cache = "HIT"
+	case TargetBuildFailed:
+		row.status = "failed"
+		var timedOut *process.TimedOut
+		if errors.As(state.Err, &timedOut) {
+			row.status = "timeout"
+		}
+	case TargetBuildStopped:
+		row.status = "canceled"
+	case TargetSkipped:
+		row.status = "skipped"
+	}
+	if state.MissReason != "" {
+		row.cache = "MISS"
+	}
+	return row
+}
+
+func (t *tui) renderTable(snapshot *RunStateSnapshot, height int, now time.Time) []string {
+	rows := make([]tuiRow, len(t.tasks))
+	idWidth := len("TASK")
+	counts := map[string]int{}
+	for i, task := range t.tasks {
+		rows[i] = t.row(snapshot, task, now)
+		counts[rows[i].status]++
+		if len(task.taskID) > idWidth {
+			idWidth = len(task.taskID)
+		}
+	}
+
+	lines := []string{
+		fmt.Sprintf("%v · %v tasks · %v running · %v done · %v failed · %v",
+			t.title, len(t.tasks), counts["running"], counts["done"]+counts["cached"], counts["failed"]+counts["timeout"], formatElapsed(now.Sub(t.runState.startedAt))),
+		fmt.Sprintf("  %-*v  %-7v  %-5v  %v", idWidth, "TASK", "STATUS", "CACHE", "TIME"),
+	}
+
+	// Scroll the table so that the selected task is visible
+	visible := height - len(lines) - 1
+	if visible < 1 {
+		visible = 1
+	}
+	first := 0
+	if t.selected >= visible {
+		first = t.selected - visible + 1
+	}
+	for i := first; i < len(t.tasks) && i < first+visible; i++ {
+		marker := " "
+		if i == t.selected {
+			marker = ">"
+		}
+		elapsed := ""
+		if rows[i].status != "waiting" {
+			elapsed = formatElapsed(rows[i].elapsed)
+		}
+		lines = append(lines, fmt.Sprintf("%v %-*v  %-7v  %-5v  %v", marker, idWidth, t.tasks[i].taskID, rows[i].status, rows[i].cache, elapsed))
+	}
+	for len(lines) < height-1 {
+		lines = append(lines, "")
+	}
+	return append(lines, "↑/↓ select · enter show output · q quit")
+}
+
+func (t *tui) renderOutput(snapshot *RunStateSnapshot, task *tuiTask, height int, now time.Time) []string {
+	row := t.row(snapshot, task, now)
+	header := fmt.Sprintf("%v · %v", task.taskID, row.status)
+	if row.cache != "" {
+		header += " · " + row.cache
+	}
+	if row.status != "waiting" {
+		header += " · " + formatElapsed(row.elapsed)
+	}
+	lines := []string{header, ""}
+
+	output := task.output()
+	visible := height - len(lines) - 1
+	if visible < 1 {
+		visible = 1
+	}
+	t.scroll = clamp(t.scroll, 0, len(output)-visible)
+	end := len(output) - t.scroll
+	start := end - visible
+	if start < 0 {
+		start = 0
+	}
+	for _, line := range output[start:end] {
+		lines = append(lines, strings.ReplaceAll(ui.StripAnsi(line), "\t", "    "))
+	}
+	for len(lines) < height-1 {
+		lines = append(lines, "")
+	}
+	footer := "↑/↓ scroll · esc back · q quit"
+	if t.scroll > 0 {
+		footer += fmt.Sprintf(" · %v lines back", t.scroll)
+	}
+	return append(lines, footer)
+}
+
+// formatElapsed shows tenths of a second for the first minute
+func formatElapsed(elapsed time.Duration) string {
+	if elapsed < time.Minute {
+		return fmt.Sprintf("%.1fs", elapsed.Seconds())
+	}
+	return elapsed.Round(time.Second).String()
+}
+
+// truncate cuts line to width runes, and marks that it was cut
+func truncate(line string, width int) string {
+	runes := []rune(line)
+	if len(runes) <= width || width < 1 {
+		return line
+	}
+	return string(runes[:width-1]) + "…"
+}
@@ -0,0 +1,72 @@
+package run
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/runcache"
+	"gotest.tools/v3/assert"
+)
+
+func TestParseKeys(t *testing.T) {
+	assert.DeepEqual(t, parseKeys([]byte("\x1b[A\x1b[Bjk\r")), []string{"up", "down", "down", "up", "enter"})
+	assert.DeepEqual(t, parseKeys([]byte("\x1b[5~\x1b[6~gG")), []string{"pgup", "pgdown", "home", "end"})
+	assert.DeepEqual(t, parseKeys([]byte("\x1b")), []string{"esc"})
+	assert.DeepEqual(t, parseKeys([]byte{3, 'q', 'x'}), []string{"quit", "quit"})
+}
+
+func TestTUITaskOutput(t *testing.T) {
+	task := &tuiTask{taskID: "web#build"}
+	_, _ = task.Write([]byte("web:build: one\nweb:build: tw"))
+	_, _ = task.Write([]byte("o\r\nweb:build: three"))
+	task.Warn("web:build: a warning")
+	assert.DeepEqual(t, task.output(), []string{"web:build: one", "web:build: two", "web:build: a warning", "web:build: three"})
+}
+
+func TestTUIRender(t *testing.T) {
+	startedAt := time.Now()
+	runState := NewRunState(startedAt, "")
+	tui := newTUI("turbo run build", []string{"web#build", "docs#build", "ui#build"}, runState, func() {})
+
+	runState.Run("docs#build")(TargetCached, nil)
+	runState.Run("web#build")
+	runState.cacheMiss("web#build", runcache.MissReason("hash changed"))
+
+	lines := tui.render(80, 8, startedAt.Add(1500*time.Millisecond))
+	assert.Equal(t, len(lines), 8)
+	assert.Equal(t, lines[0], "turbo run build · 3 tasks · 1 running · 1 done · 0 failed · 1.5s")
+	assert.Equal(t, lines[1], "  TASK        STATUS   CACHE  TIME")
+	assert.Assert(t, strings.HasPrefix(lines[2], "> docs#build  cached   HIT    "))
+	assert.Equal(t, lines[3], "  ui#build    waiting         ")
+	assert.Assert(t, strings.HasPrefix(lines[4], "  web#build   running  MISS   "))
+	assert.Equal(t, lines[7], "↑/↓ select · enter show output · q quit")
+
+	// Narrow terminals cut the lines
+	assert.Equal(t, tui.render(10, 8, startedAt)[1], "  TASK   …")
+
+	assert.Assert(t, !tui.handleKey("end", 8))
+	assert.Assert(t, !tui.handleKey("enter", 8))
+	output := tui.task("web#build")
+	for i := 0; i < 10; i++ {
+		output.Output(strings.Repeat("x", i))
+	}
+	lines = tui.render(60, 6, startedAt)
+	assert.Assert(t, strings.HasPrefix(lines[0], "web#build · running · MISS"))
+	assert.DeepEqual(t, lines[2:5], []string{"xxxxxxx", "xxxxxxxx", "xxxxxxxxx"})
+
+	// Scrolling back stops at the start of the output
+	for i := 0; i < 20; i++ {
+		tui.handleKey("up", 6)
+	}
+	lines = tui.render(60, 6, startedAt)
+	assert.DeepEqual(t, lines[2:5], []string{"", "x", "xx"})
+	assert.Equal(t, lines[5], "↑/↓ scroll · esc back · q quit · 7 lines back")
+
+	tui.handleKey("esc", 6)
+	tui.remove("ui#build")
+	lines = tui.render(60, 6, startedAt)
+	assert.Assert(t, strings.HasPrefix(lines[3], "> web#build"))
+
+	assert.Assert(t, tui.handleKey("quit", 6))
+}
@@ -0,0 +1,319 @@
+// Package run implements `turbo run`
+// This file implements `turbo run --watch`, which runs the tasks again in the
+// packages affected by each change to the workspace
+package run
+
+import (
+	gocontext "context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/context"
+	"github.com/vercel/turbo/cli/internal/doublestar"
+	"github.com/vercel/turbo/cli/internal/filewatcher"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/runsummary"
+	"github.com/vercel/turbo/cli/internal/scope"
+	"github.com/vercel/turbo/cli/internal/signals"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+	"github.com/vercel/turbo/cli/internal/ui"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// _watchDebounce is how long files have to stop changing before the tasks run
+// again, since saving a file or switching branches changes many files at once
+const _watchDebounce = 200 * time.Millisecond
+
+// changeCollector is a filewatcher.FileWatchClient that gathers the paths that
+// changed until they are taken
+type changeCollector struct {
+	mu    sync.Mutex
+	paths map[turbopath.AbsoluteSystemPath]struct{}
+	// changed has a value when paths is not empty
+	changed chan struct{}
+}
+
+func newChangeCollector() *changeCollector {
+	return &changeCollector{
+		paths:   make(map[turbopath.AbsoluteSystemPath]struct{}),
+		changed: make(chan struct{}, 1),
+	}
+}
+
+// OnFileWatchEvent implements filewatcher.FileWatchClient.OnFileWatchEvent
+func (c *changeCollector) OnFileWatchEvent(ev filewatcher.Event) {
+	c.mu.Lock()
+	c.paths[ev.Path] = struct{}{}
+	c.mu.Unlock()
+	select {
+	case c.changed <- struct{}{}:
+	default:
+	}
+}
+
+// OnFileWatchError implements filewatcher.FileWatchClient.OnFileWatchError
+func (c *changeCollector) OnFileWatchError(err error) {}
+
+// OnFileWatchClosed implements filewatcher.FileWatchClient.OnFileWatchClosed
+func (c *changeCollector) OnFileWatchClosed() {}
+
+// wait blocks until files change and then stop changing for _watchDebounce, and
+// returns the paths that changed. It returns false if turbo is shutting down.
+func (c *changeCollector) wait(ctx gocontext.Context, done <-chan struct{}) ([]turbopath.AbsoluteSystemPath, bool) {
+	select {
+	case <-c.changed:
+	case <-ctx.Done():
+		return nil, false
+	case <-done:
+		return nil, false
+	}
+	for {
+		select {
+		case <-c.changed:
+		case <-time.After(_watchDebounce):
+			return c.take(), true
+		case <-ctx.Done():
+			return nil, false
+		case <-done:
+			return nil, false
+		}
+	}
+}
+
+func (c *changeCollector) take() []turbopath.AbsoluteSystemPath {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	paths := make([]turbopath.AbsoluteSystemPath, 0, len(c.paths))
+	for path := range c.paths {
+		paths = append(paths, path)
+	}
+	c.paths = make(map[turbopath.AbsoluteSystemPath]struct{})
+	return paths
+}
+
+// watchRun runs the tasks, and then runs them again whenever files change until
+// turbo is interrupted. Runs after the first one only include the packages in
+// scope that changed, or that depend on a package that changed. The tasks they
+// depend on are hashed as usual, and restored from the cache if nothing changed.
+func watchRun(ctx gocontext.Context, base *cmdutil.CmdBase, signalWatcher *signals.Watcher, args *turbostate.ParsedArgsFromRust) error {
+	runPayload := args.Command.Run
+	if len(runPayload.Tasks) == 0 {
+		return errors.New("at least one task must be specified")
+	}
+	if err := applyRunProfile(runPayload, base.RepoRoot); err != nil {
+		return err
+	}
+	if base.UserConfig != nil {
+		applyUserDefaults(runPayload, base.UserConfig)
+	}
+	opts, err := optsFromArgs(args)
+	if err != nil {
+		return err
+	}
+
+	// As with the daemon, repositories on network filesystems are polled
+	backend, err := filewatcher.GetBackend(base.Logger, base.RepoRoot, filewatcher.BackendOpts{})
+	if err != nil {
+		return errors.Wrap(err, "failed to watch files")
+	}
+	// Cache artifacts are never task inputs
+	fileWatcher := filewatcher.New(base.Logger.Named("FileWatcher"), base.RepoRoot, backend, opts.cacheOpts.ResolveCacheDir(base.RepoRoot))
+	changes := newChangeCollector()
+	fileWatcher.AddClient(changes)
+	if err := fileWatcher.Start(); err != nil {
+		return errors.Wrapf(err, "failed to watch %v", base.RepoRoot)
+	}
+	defer func() { _ = fileWatcher.Close() }()
+
+	// fullRun is the summary of the last run of every package in scope. Later
+	// runs are limited to its packages, and the outputs of its tasks aren't
+	// treated as changes.
+	var fullRun *runsummary.RunSummary
+	// packages is nil to run every package in scope
+	var packages []string
+	for {
+		summary, err := watchIteration(ctx, base, signalWatcher, args, packages)
+		if err != nil {
+			// A failed run is reported, and the next change runs the tasks again
+			base.LogError("run failed: %v", err)
+		}
+		if packages == nil && summary != nil {
+			fullRun = summary
+		}
+
+		base.UI.Output(ui.Dim("• Watching for changes..."))
+		next, ok := waitForAffectedPackages(ctx, base, signalWatcher, changes, fullRun, opts.runOpts.singlePackage)
+		if !ok {
+			return nil
+		}
+		packages = next
+		if packages == nil {
+			base.UI.Output(ui.Dim("• Files changed, running again"))
+		} else {
+			base.UI.Output(ui.Dim(fmt.Sprintf("• Files changed, running again in %v", strings.Join(packages, ", "))))
+		}
+	}
+}
+
+// watchIteration runs the tasks once. The run is limited to the given packages,
+// or includes every package in scope if packages is nil.
+func watchIteration(ctx gocontext.Context, base *cmdutil.CmdBase, signalWatcher *signals.Watcher, args *turbostate.ParsedArgsFromRust, packages []string) (*runsummary.RunSummary, error) {
+	// Each run gets freshly parsed options, since a run modifies its options
+	opts, err := optsFromArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	opts.runOpts.passThroughArgs = args.Command.Run.PassThroughArgs
+	if packages != nil {
+		opts.scopeOpts.FilterPatterns = packages
+		opts.scopeOpts.LegacyFilter = scope.LegacyFilter{}
+		opts.scopeOpts.PackageInferenceRoot = ""
+	}
+	r := configureRun(base, opts, signalWatcher)
+	err = r.run(ctx, args.Command.Run.Tasks)
+	return r.summary, err
+}
+
+// waitForAffectedPackages waits for files to change, and returns the packages
+// to run again, or nil to run every package in scope. Changes that don't affect
+// any package in scope are skipped. It returns false if turbo is shutting down.
+func waitForAffectedPackages(ctx gocontext.Context, base *cmdutil.CmdBase, signalWatcher *signals.Watcher, changes *changeCollector, fullRun *runsummary.RunSummary, singlePackage bool) ([]string, bool) {
+	for {
+		paths, ok := changes.wait(ctx, signalWatcher.Done())
+		if !ok {
+			return nil, false
+		}
+		changed := []turbopath.AnchoredSystemPath{}
+		for _, path := range paths {
+			relativePath, err := path.RelativeTo(base.RepoRoot)
+			if err != nil || isTaskOutput(relativePath, fullRun) {
+				continue
+			}
+			changed = append(changed, relativePath)
+		}
+		if len(changed) == 0 {
+			continue
+		}
+		base.Logger.Debug("files changed", "paths", changed)
+		// A single package is always run in full, as is everything after a
+		// failure to list the packages
+		if singlePackage || fullRun == nil {
+			return nil, true
+		}
+
+		pkgDepGraph, err := readPackageGraph(base)
+		if err != nil {
+			base.LogWarning("", errors.Wrap(err, "failed to find the packages that changed, running every package"))
+			return nil, true
+		}
+		packages, all := affectedPackages(pkgDepGraph, changed, fullRun.Packages)
+		if all {
+			return nil, true
+		}
+		if len(packages) > 0 {
+			return packages, true
+		}
+		base.Logger.Debug("no packages in scope changed")
+	}
+}
+
+// readPackageGraph reads the package graph again, since the change may have
+// added packages or dependencies
+func readPackageGraph(base *cmdutil.CmdBase) (*context.Context, error) {
+	rootPackageJSON, err := fs.ReadPackageJSON(base.RepoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package.json: %w", err)
+	}
+	pkgDepGraph, err := context.BuildPackageGraph(base.RepoRoot, rootPackageJSON)
+	if err != nil {
+		var warnings *context.Warnings
+		if !errors.As(err, &warnings) {
+			return nil, err
+		}
+	}
+	return pkgDepGraph, nil
+}
+
+// affectedPackages returns the packages in scope that contain a changed file,
+// or that depend on a package that does. It returns true instead if a file
+// outside of every package changed, such as the root package.json, since that
+// can change the hash of any task.
+func affectedPackages(pkgDepGraph *context.Context, changed []turbopath.AnchoredSystemPath, packagesInScope []string) ([]string, bool) {
+	affected := make(util.Set)
+	for _, path := range changed {
+		pkg := packageContaining(pkgDepGraph, path)
+		if pkg == "" {
+			return nil, true
+		}
+		if affected.Includes(pkg) {
+			continue
+		}
+		affected.Add(pkg)
+		dependents, err := pkgDepGraph.WorkspaceGraph.Descendents(pkg)
+		if err != nil {
+			return nil, true
+		}
+		for _, dependent := range dependents.List() {
+			affected.Add(dependent)
+		}
+	}
+
+	packages := []string{}
+	for _, pkg := range packagesInScope {
+		if affected.Includes(pkg) {
+			packages = append(packages, pkg)
+		}
+	}
+	sort.Strings(packages)
+	return packages, false
+}
+
+// packageContaining returns the name of the innermost package that contains the
+// path, or the empty string if it isn't in any package other than the root
+func packageContaining(pkgDepGraph *context.Context, path turbopath.AnchoredSystemPath) string {
+	pkg := ""
+	pkgDir := ""
+	for name, pkgJSON := range pkgDepGraph.WorkspaceInfos.PackageJSONs {
+		if name == util.RootPkgName {
+			continue
+		}
+		dir := pkgJSON.Dir.ToString()
+		if dir == "" || dir == "." || len(dir) <= len(pkgDir) {
+			continue
+		}
+		if path.ToString() == dir || strings.HasPrefix(path.ToString(), dir+string(filepath.Separator)) {
+			pkg = name
+			pkgDir = dir
+		}
+	}
+	return pkg
+}
+
+// isTaskOutput returns true if the path is a log file, or matches the outputs
+// of a task in the summary. Runs write these files themselves, so changes to
+// them don't run the tasks again.
+func isTaskOutput(path turbopath.AnchoredSystemPath, summary *runsummary.RunSummary) bool {
+	for _, segment := range strings.Split(path.ToString(), string(filepath.Separator)) {
+		if segment == ".turbo" {
+			return true
+		}
+	}
+	if summary == nil {
+		return false
+	}
+	for _, task := range summary.Tasks {
+		for _, output := range task.Outputs {
+			if matched, err := doublestar.PathMatch(filepath.Join(task.Dir, output), path.ToString()); err == nil && matched {
+				return true
+			}
+		}
+	}
+	return false
+}
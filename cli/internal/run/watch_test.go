@@ -0,0 +1,85 @@
+package run
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pyr-sh/dag"
+	"github.com/vercel/turbo/cli/internal/context"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/runsummary"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+	"github.com/vercel/turbo/cli/internal/workspace"
+	"gotest.tools/v3/assert"
+)
+
+func TestAffectedPackages(t *testing.T) {
+	pkgDepGraph := &context.Context{
+		WorkspaceInfos: workspace.Catalog{
+			PackageJSONs: map[string]*fs.PackageJSON{
+				util.RootPkgName: {Dir: turbopath.AnchoredSystemPath("")},
+				"ui":             {Dir: turbopath.AnchoredSystemPath(filepath.Join("packages", "ui"))},
+				"ui-icons":       {Dir: turbopath.AnchoredSystemPath(filepath.Join("packages", "ui", "icons"))},
+				"web":            {Dir: turbopath.AnchoredSystemPath(filepath.Join("apps", "web"))},
+				"docs":           {Dir: turbopath.AnchoredSystemPath(filepath.Join("apps", "docs"))},
+			},
+		},
+	}
+	for _, pkg := range []string{"ui", "ui-icons", "web", "docs"} {
+		pkgDepGraph.WorkspaceGraph.Add(pkg)
+	}
+	// web depends on ui, which depends on ui-icons
+	pkgDepGraph.WorkspaceGraph.Connect(dag.BasicEdge("web", "ui"))
+	pkgDepGraph.WorkspaceGraph.Connect(dag.BasicEdge("ui", "ui-icons"))
+
+	path := func(parts ...string) turbopath.AnchoredSystemPath {
+		return turbopath.AnchoredSystemPath(filepath.Join(parts...))
+	}
+	allPackages := []string{"docs", "ui", "ui-icons", "web"}
+
+	testCases := []struct {
+		name    string
+		changed []turbopath.AnchoredSystemPath
+		scope   []string
+		want    []string
+		wantAll bool
+	}{
+		{name: "leaf", changed: []turbopath.AnchoredSystemPath{path("apps", "web", "index.ts")}, scope: allPackages, want: []string{"web"}},
+		{name: "dependents", changed: []turbopath.AnchoredSystemPath{path("packages", "ui", "button.ts")}, scope: allPackages, want: []string{"ui", "web"}},
+		{name: "nested package", changed: []turbopath.AnchoredSystemPath{path("packages", "ui", "icons", "star.svg")}, scope: allPackages, want: []string{"ui", "ui-icons", "web"}},
+		{name: "out of scope", changed: []turbopath.AnchoredSystemPath{path("apps", "docs", "index.md")}, scope: []string{"web"}, want: []string{}},
+		{name: "similar prefix", changed: []turbopath.AnchoredSystemPath{path("apps", "website", "index.ts")}, scope: allPackages, wantAll: true},
+		{name: "root file", changed: []turbopath.AnchoredSystemPath{path("apps", "web", "index.ts"), path("package.json")}, scope: allPackages, wantAll: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			packages, all := affectedPackages(pkgDepGraph, tc.changed, tc.scope)
+			assert.Equal(t, all, tc.wantAll)
+			if !tc.wantAll {
+				assert.DeepEqual(t, packages, tc.want)
+			}
+		})
+	}
+}
+
+func TestIsTaskOutput(t *testing.T) {
+	summary := &runsummary.RunSummary{
+		Tasks: []*runsummary.TaskSummary{
+			{TaskID: "web#build", Dir: filepath.Join("apps", "web"), Outputs: []string{filepath.Join(".next", "**"), "!.next/cache/**"}},
+		},
+	}
+	testCases := []struct {
+		path string
+		want bool
+	}{
+		{path: filepath.Join("apps", "web", ".next", "server", "page.js"), want: true},
+		{path: filepath.Join("apps", "web", ".turbo", "turbo-build.log"), want: true},
+		{path: filepath.Join("apps", "web", "pages", "index.tsx"), want: false},
+		{path: filepath.Join("apps", "docs", ".next", "page.js"), want: false},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, isTaskOutput(turbopath.AnchoredSystemPath(tc.path), summary), tc.want, tc.path)
+	}
+	assert.Equal(t, isTaskOutput(turbopath.AnchoredSystemPath(filepath.Join("apps", "web", ".next", "page.js")), nil), false)
+}
@@ -0,0 +1,205 @@
+package run
+
+import (
+	gocontext "context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/analytics"
+	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/remoteexec"
+	"github.com/vercel/turbo/cli/internal/runsummary"
+	"github.com/vercel/turbo/cli/internal/signals"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+	"github.com/vercel/turbo/cli/internal/ui"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// ExecuteWorker executes the `worker` command.
+func ExecuteWorker(ctx gocontext.Context, helper *cmdutil.Helper, signalWatcher *signals.Watcher, args *turbostate.ParsedArgsFromRust) error {
+	base, err := helper.GetCmdBase(args)
+	if err != nil {
+		return err
+	}
+	if err := runWorker(ctx, base, signalWatcher, args.Command.Worker); err != nil {
+		base.LogError("worker failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+func runWorker(ctx gocontext.Context, base *cmdutil.CmdBase, signalWatcher *signals.Watcher, opts *turbostate.WorkerPayload) error {
+	var tlsConfig *tls.Config
+	if opts.TLSCert != "" || opts.TLSKey != "" {
+		var err error
+		tlsConfig, err = remoteexec.ServerTLSConfig(opts.TLSCert, opts.TLSKey)
+		if err != nil {
+			return err
+		}
+	}
+	if err := remoteexec.CheckListenAddress(opts.Listen, tlsConfig != nil); err != nil {
+		return err
+	}
+	token := os.Getenv(remoteexec.TokenEnvVar)
+	generatedToken := token == ""
+	if generatedToken {
+		bytes := make([]byte, 32)
+		if _, err := rand.Read(bytes); err != nil {
+			return err
+		}
+		token = hex.EncodeToString(bytes)
+	}
+
+	rootPackageJSON, err := fs.ReadPackageJSON(base.RepoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		return fmt.Errorf("failed to read package.json: %w", err)
+	}
+	turboJSON, err := fs.LoadTurboConfig(base.RepoRoot, rootPackageJSON, false)
+	if err != nil {
+		return err
+	}
+	if !base.APIClient.IsLinked() && turboJSON.RemoteCacheOptions.S3 == nil {
+		return fmt.Errorf("workers write task outputs to the remote cache, which is not configured. Run `turbo login` and `turbo link`, set TURBO_TOKEN and TURBO_TEAM, or configure remoteCache.s3 in turbo.json")
+	}
+	// Writes are synchronous so that outputs are in the remote cache by the time
+	// we respond to `turbo run`.
+	cacheOpts := cache.Opts{
+		RemoteCacheOpts: turboJSON.RemoteCacheOptions,
+	}
+	// Workers serve every run, so their scope can only come from their environment
+	if envScope := os.Getenv(cache.CacheScopeEnvVar); envScope != "" {
+		cacheOpts.Scope, err = cache.NormalizeScope(envScope)
+		if err != nil {
+			return fmt.Errorf("invalid %v: %w", cache.CacheScopeEnvVar, err)
+		}
+	}
+	remoteClient, err := remoteCacheClient(base, turboJSON.RemoteCacheOptions, artifactMetadata(base, cacheOpts))
+	if err != nil {
+		return err
+	}
+
+	analyticsClient := analytics.NewClient(ctx, base.AnalyticsSink(), base.Logger.Named("analytics"))
+	defer analyticsClient.CloseWithTimeout(50 * time.Millisecond)
+	turboCache, err := cache.New(cacheOpts, base.RepoRoot, remoteClient, analyticsClient, func(_cache cache.Cache, err error) {
+		base.LogWarning("Remote Caching is unavailable", err)
+	})
+	if err != nil {
+		return err
+	}
+	defer turboCache.Shutdown()
+
+	lis, err := net.Listen("tcp", opts.Listen)
+	if err != nil {
+		return err
+	}
+	worker := remoteexec.NewWorker(base.RepoRoot, turboCache, workerTaskResolver(base), base.Logger.Named("worker"))
+	s := remoteexec.NewServer(worker, token, tlsConfig)
+	base.UI.Info(fmt.Sprintf("Worker listening on %v", lis.Addr()))
+	if generatedToken {
+		base.UI.Info(fmt.Sprintf("Token: %v %v", token, ui.Dim(fmt.Sprintf("(set %v to choose the token)", remoteexec.TokenEnvVar))))
+	}
+
+	errCh := make(chan error)
+	go func(errCh chan<- error) {
+		if err := s.Serve(lis); err != nil {
+			errCh <- err
+		}
+		close(errCh)
+	}(errCh)
+
+	var exitErr error
+	select {
+	case err, ok := <-errCh:
+		if ok {
+			exitErr = err
+		}
+	case <-ctx.Done():
+		s.GracefulStop()
+	case <-signalWatcher.Done():
+		s.GracefulStop()
+	}
+	for range errCh {
+	}
+	return exitErr
+}
+
+// workerTaskResolver resolves tasks by preparing a run of the task in its
+// package, so that the worker computes hashes exactly as `turbo run` does.
+// Root tasks are not supported, since they can't be selected with a filter.
+func workerTaskResolver(base *cmdutil.CmdBase) remoteexec.TaskResolver {
+	return func(ctx gocontext.Context, taskID string, args []string) (*remoteexec.Task, error) {
+		packageName, taskName := util.GetPackageTaskFromId(taskID)
+		if packageName == "" || packageName == util.RootPkgName {
+			return nil, fmt.Errorf("%v is not a workspace task", taskID)
+		}
+		opts := getDefaultOptions()
+		opts.scopeOpts.FilterPatterns = []string{packageName}
+		opts.runOpts.passThroughArgs = args
+		opts.runOpts.noDaemon = true
+		r := &run{base: base, opts: opts}
+		prepared, err := r.prepare([]string{taskName})
+		if err != nil {
+			return nil, err
+		}
+
+		var task *remoteexec.Task
+		visitor := func(ctx gocontext.Context, packageTask *nodes.PackageTask, _ *runsummary.TaskSummary) error {
+			if packageTask.TaskID != taskID {
+				return nil
+			}
+			if !packageTask.TaskDefinition.HasCommand(packageTask.Pkg, taskName) {
+				return fmt.Errorf("%v has no %v script", packageName, taskName)
+			}
+			workingDir, err := packageTask.WorkingDir()
+			if err != nil {
+				return err
+			}
+			outputs := packageTask.RepoRelativeOutputs()
+			taskEnv, err := packageTask.Env(base.RepoRoot)
+			if err != nil {
+				return err
+			}
+			command, err := resolveTaskCommand(prepared.packageManager, packageTask, prepared.rs.ArgsForTask(taskID), base.RepoRoot, append(os.Environ(), taskEnv...))
+			if err != nil {
+				return err
+			}
+			task = &remoteexec.Task{
+				Hash:             packageTask.Hash,
+				Dir:              workingDir.ToUnixPath().ToString(),
+				Command:          command.name,
+				Args:             command.args,
+				LogFile:          filepath.ToSlash(packageTask.LogFile),
+				OutputInclusions: toSlash(outputs.Inclusions),
+				OutputExclusions: toSlash(outputs.Exclusions),
+				Env:              append(taskEnv, command.env...),
+			}
+			return nil
+		}
+		visitorFn := prepared.g.GetPackageTaskVisitor(ctx, prepared.engine.TaskGraph, prepared.rs.ArgsForTask, base.Logger, visitor)
+		if errs := prepared.engine.Execute(visitorFn, core.EngineExecutionOptions{Concurrency: 1}); len(errs) > 0 {
+			return nil, errs[0]
+		}
+		if task == nil {
+			return nil, fmt.Errorf("%v is not defined in the pipeline", taskID)
+		}
+		return task, nil
+	}
+}
+
+func toSlash(paths []string) []string {
+	slashed := make([]string, len(paths))
+	for i, path := range paths {
+		slashed[i] = filepath.ToSlash(path)
+	}
+	return slashed
+}
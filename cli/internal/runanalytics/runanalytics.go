@@ -0,0 +1,245 @@
+// Package runanalytics uploads a summary of each run to the endpoint configured
+// in the "analytics" key of turbo.json, so that platform teams can chart cache
+// effectiveness across every repository and CI job.
+//
+// Runs are queued in .turbo/analytics and uploaded in batches in the background.
+// A run waits a short time for the upload, and never fails because of it: the
+// runs that couldn't be uploaded stay queued, and are sent with the next run.
+package runanalytics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/runsummary"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// SchemaVersion is the version of Batch. It changes when a field is removed or
+// changes meaning, but not when one is added.
+const SchemaVersion = 1
+
+const (
+	// _maxBatch is how many queued runs are sent in one request
+	_maxBatch = 50
+	// _maxQueued is how many runs are kept when the endpoint is unreachable.
+	// The oldest runs are dropped beyond it.
+	_maxQueued = 500
+	_timeout   = 10 * time.Second
+)
+
+// Batch is the body of each request to the endpoint
+type Batch struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	Runs          []*Run `json:"runs"`
+}
+
+// Run is the summary of one run. Runs can be uploaded again if a response is
+// lost, so the endpoint should ignore the IDs it has already seen.
+type Run struct {
+	ID           string   `json:"id"`
+	TurboVersion string   `json:"turboVersion"`
+	Targets      []string `json:"targets"`
+	// CacheHitRate is the share of the attempted tasks that were cached, from 0
+	// to 1. It is 0 if no task was attempted.
+	CacheHitRate float64 `json:"cacheHitRate"`
+	// Environment is the machine that the run happened on
+	Environment *runsummary.RecordEnvironment `json:"environment,omitempty"`
+	// The counts, durations and remote cache transfers of the run, and the
+	// execution of each task, as written by --summary-format=json
+	*runsummary.ExecutionSummary
+}
+
+// NewRun returns the summary of a run to upload
+func NewRun(runSummary *runsummary.RunSummary, targets []string, executionSummary *runsummary.ExecutionSummary, environment *runsummary.RecordEnvironment) *Run {
+	run := &Run{
+		ID:               runSummary.ID.String(),
+		TurboVersion:     runSummary.TurboVersion,
+		Targets:          targets,
+		Environment:      environment,
+		ExecutionSummary: executionSummary,
+	}
+	if executionSummary.Attempted > 0 {
+		run.CacheHitRate = float64(executionSummary.Cached) / float64(executionSummary.Attempted)
+	}
+	return run
+}
+
+// Uploader queues runs and uploads them to the configured endpoint
+type Uploader struct {
+	config   fs.Analytics
+	queueDir turbopath.AbsoluteSystemPath
+	client   *http.Client
+	logger   hclog.Logger
+}
+
+// New returns an Uploader for the given analytics configuration
+func New(config fs.Analytics, repoRoot turbopath.AbsoluteSystemPath, logger hclog.Logger) *Uploader {
+	return &Uploader{
+		config:   config,
+		queueDir: repoRoot.UntypedJoin(".turbo", "analytics"),
+		client:   &http.Client{Timeout: _timeout},
+		logger:   logger.Named("analytics"),
+	}
+}
+
+// Send queues run, and starts uploading the queued runs in the background. The
+// returned function waits for the upload for at most wait, and returns its
+// error, if it finished.
+func (u *Uploader) Send(ctx context.Context, run *Run, wait time.Duration) func() error {
+	if err := u.queue(run); err != nil {
+		return func() error { return err }
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- u.Flush(ctx)
+	}()
+	return func() error {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case err := <-done:
+			return err
+		case <-timer.C:
+			u.logger.Debug("stopped waiting for the upload, the remaining runs stay queued")
+			return nil
+		}
+	}
+}
+
+func (u *Uploader) queue(run *Run) error {
+	payload, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	if err := u.queueDir.MkdirAll(0755); err != nil {
+		return fmt.Errorf("failed to queue the run: %w", err)
+	}
+	// IDs sort in the order the runs started
+	if err := u.queueDir.UntypedJoin(run.ID+".json").WriteFile(payload, 0644); err != nil {
+		return fmt.Errorf("failed to queue the run: %w", err)
+	}
+	return nil
+}
+
+// Flush uploads the queued runs in batches, oldest first, and stops at the first
+// batch that fails
+func (u *Uploader) Flush(ctx context.Context) error {
+	url := os.ExpandEnv(u.config.URL)
+	if url == "" {
+		u.logger.Debug("skipping upload with empty url", "url", u.config.URL)
+		return nil
+	}
+	queued, err := u.queued()
+	if err != nil {
+		return err
+	}
+	for len(queued) > 0 {
+		n := len(queued)
+		if n > _maxBatch {
+			n = _maxBatch
+		}
+		batch, files := u.readBatch(queued[:n])
+		queued = queued[n:]
+		if len(batch.Runs) == 0 {
+			continue
+		}
+		if err := u.post(ctx, url, batch); err != nil {
+			return fmt.Errorf("failed to upload %v runs to the analytics endpoint: %w", len(batch.Runs), err)
+		}
+		for _, file := range files {
+			// Another run may have uploaded it at the same time
+			if err := file.Remove(); err != nil && !errors.Is(err, os.ErrNotExist) {
+				u.logger.Debug("failed to remove uploaded run", "file", file, "error", err)
+			}
+		}
+	}
+	return nil
+}
+
+// queued returns the files of the queued runs, oldest first, after dropping the
+// oldest ones beyond _maxQueued
+func (u *Uploader) queued() ([]turbopath.AbsoluteSystemPath, error) {
+	entries, err := os.ReadDir(u.queueDir.ToString())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".json" {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	if len(names) > _maxQueued {
+		for _, name := range names[:len(names)-_maxQueued] {
+			_ = u.queueDir.UntypedJoin(name).Remove()
+		}
+		u.logger.Debug("dropped the oldest queued runs", "count", len(names)-_maxQueued)
+		names = names[len(names)-_maxQueued:]
+	}
+	files := make([]turbopath.AbsoluteSystemPath, len(names))
+	for i, name := range names {
+		files[i] = u.queueDir.UntypedJoin(name)
+	}
+	return files, nil
+}
+
+// readBatch reads the given queued runs. Files that are gone or corrupted are
+// skipped, and the corrupted ones are removed.
+func (u *Uploader) readBatch(queued []turbopath.AbsoluteSystemPath) (*Batch, []turbopath.AbsoluteSystemPath) {
+	batch := &Batch{SchemaVersion: SchemaVersion, Runs: []*Run{}}
+	files := []turbopath.AbsoluteSystemPath{}
+	for _, file := range queued {
+		payload, err := file.ReadFile()
+		if err != nil {
+			continue
+		}
+		run := &Run{}
+		if err := json.Unmarshal(payload, run); err != nil {
+			u.logger.Debug("removing corrupted queued run", "file", file, "error", err)
+			_ = file.Remove()
+			continue
+		}
+		batch.Runs = append(batch.Runs, run)
+		files = append(files, file)
+	}
+	return batch, files
+}
+
+func (u *Uploader) post(ctx context.Context, url string, batch *Batch) error {
+	payload, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("invalid url")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range u.config.Headers {
+		req.Header.Set(name, os.ExpandEnv(value))
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		// The URL may contain a secret, so it isn't included in the error
+		return fmt.Errorf("request failed")
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("endpoint responded with %v", resp.Status)
+	}
+	return nil
+}
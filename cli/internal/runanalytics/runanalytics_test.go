@@ -0,0 +1,125 @@
+package runanalytics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/runsummary"
+	"gotest.tools/v3/assert"
+)
+
+func testRun(id string, attempted int, cached int) *Run {
+	return &Run{
+		ID:           id,
+		TurboVersion: "1.8.0",
+		Targets:      []string{"build"},
+		ExecutionSummary: &runsummary.ExecutionSummary{
+			ExecutionStats: runsummary.ExecutionStats{Attempted: attempted, Cached: cached},
+			Tasks:          []*runsummary.ExecutionTaskSummary{},
+		},
+	}
+}
+
+func TestSend(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	available := false
+	batches := []map[string]interface{}{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		assert.Equal(t, r.Header.Get("Authorization"), "Bearer secret")
+		batch := map[string]interface{}{}
+		assert.NilError(t, json.NewDecoder(r.Body).Decode(&batch))
+		batches = append(batches, batch)
+	}))
+	defer server.Close()
+	t.Setenv("TEST_ANALYTICS_URL", server.URL)
+	t.Setenv("TEST_ANALYTICS_TOKEN", "secret")
+
+	uploader := New(fs.Analytics{
+		URL:     "$TEST_ANALYTICS_URL",
+		Headers: map[string]string{"Authorization": "Bearer $TEST_ANALYTICS_TOKEN"},
+	}, repoRoot, hclog.NewNullLogger())
+
+	// The run stays queued while the endpoint is unavailable
+	err := uploader.Send(context.Background(), testRun("1", 2, 1), time.Minute)()
+	assert.ErrorContains(t, err, "failed to upload 1 runs to the analytics endpoint: endpoint responded with 503")
+	queued, err := uploader.queued()
+	assert.NilError(t, err)
+	assert.Equal(t, len(queued), 1)
+
+	// and is sent with the next run
+	available = true
+	err = uploader.Send(context.Background(), testRun("2", 4, 4), time.Minute)()
+	assert.NilError(t, err)
+	queued, err = uploader.queued()
+	assert.NilError(t, err)
+	assert.Equal(t, len(queued), 0)
+
+	assert.Equal(t, len(batches), 1)
+	assert.Equal(t, batches[0]["schemaVersion"], float64(SchemaVersion))
+	runs := batches[0]["runs"].([]interface{})
+	assert.Equal(t, len(runs), 2)
+	first := runs[0].(map[string]interface{})
+	assert.Equal(t, first["id"], "1")
+	assert.Equal(t, first["attempted"], float64(2))
+	assert.Equal(t, first["cached"], float64(1))
+	assert.Equal(t, runs[1].(map[string]interface{})["id"], "2")
+}
+
+func TestFlushBatches(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	sizes := []int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		batch := &Batch{}
+		assert.NilError(t, json.NewDecoder(r.Body).Decode(batch))
+		sizes = append(sizes, len(batch.Runs))
+	}))
+	defer server.Close()
+
+	uploader := New(fs.Analytics{URL: server.URL}, repoRoot, hclog.NewNullLogger())
+	for i := 0; i < _maxBatch+5; i++ {
+		assert.NilError(t, uploader.queue(testRun(fmt.Sprintf("%03d", i), 1, 0)))
+	}
+	corrupted := repoRoot.UntypedJoin(".turbo", "analytics", "corrupted.json")
+	assert.NilError(t, corrupted.WriteFile([]byte("{"), 0644))
+
+	assert.NilError(t, uploader.Flush(context.Background()))
+	assert.DeepEqual(t, sizes, []int{_maxBatch, 5})
+	queued, err := uploader.queued()
+	assert.NilError(t, err)
+	assert.Equal(t, len(queued), 0)
+}
+
+func TestFlushWithoutURL(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	uploader := New(fs.Analytics{URL: "$UNSET_ANALYTICS_URL"}, repoRoot, hclog.NewNullLogger())
+	assert.NilError(t, uploader.queue(testRun("1", 1, 1)))
+	assert.NilError(t, uploader.Flush(context.Background()))
+	queued, err := uploader.queued()
+	assert.NilError(t, err)
+	assert.Equal(t, len(queued), 1)
+}
+
+func TestNewRun(t *testing.T) {
+	executionSummary := &runsummary.ExecutionSummary{
+		ExecutionStats: runsummary.ExecutionStats{Attempted: 4, Cached: 3},
+	}
+	runSummary := runsummary.NewRunSummary("1.8.0", []string{"web"}, nil)
+	run := NewRun(runSummary, []string{"build"}, executionSummary, &runsummary.RecordEnvironment{OS: "linux"})
+	assert.Equal(t, run.ID, runSummary.ID.String())
+	assert.Equal(t, run.CacheHitRate, 0.75)
+	assert.Equal(t, run.Environment.OS, "linux")
+
+	run = NewRun(runSummary, []string{"build"}, &runsummary.ExecutionSummary{}, nil)
+	assert.Equal(t, run.CacheHitRate, 0.0)
+}
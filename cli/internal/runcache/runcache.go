@@ -3,16 +3,19 @@ package runcache
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/fatih/color"
 	"github.com/hashicorp/go-hclog"
 	"github.com/mitchellh/cli"
 	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/cacheitem"
 	"github.com/vercel/turbo/cli/internal/colorcache"
 	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/globby"
@@ -28,11 +31,23 @@ type LogReplayer = func(logger hclog.Logger, output *cli.PrefixedUi, logFile tur
 
 // Opts holds the configurable options for a RunCache instance
 type Opts struct {
-	SkipReads              bool
-	SkipWrites             bool
+	SkipReads bool
+	// SkipReadsFor limits SkipReads to the tasks matching one of its entries, each
+	// of which is a task ID, a task name or a package name. Reads are skipped for
+	// every task if it is empty.
+	SkipReadsFor []string
+	SkipWrites   bool
+	// SkipLogs keeps the log files of every task out of the cache, as if each
+	// task had "cacheLogs": false
+	SkipLogs               bool
 	TaskOutputModeOverride *util.TaskOutputMode
-	LogReplayer            LogReplayer
-	OutputWatcher          OutputWatcher
+	// TaskOutputModeOverrides are keyed by task ID or task name, and take precedence
+	// over TaskOutputModeOverride
+	TaskOutputModeOverrides map[string]util.TaskOutputMode
+	LogReplayer             LogReplayer
+	OutputWatcher           OutputWatcher
+	// TaskOutput receives the output of tasks. Defaults to os.Stdout.
+	TaskOutput io.Writer
 }
 
 // SetTaskOutputMode parses the task output mode from string and then sets it in opts
@@ -45,6 +60,46 @@ func (opts *Opts) SetTaskOutputMode(value string) error {
 	return nil
 }
 
+// SetTaskOutputModes parses the values of --output-logs, each of which is either a
+// mode for every task or <task>=<mode> for one task, and sets them in opts
+func (opts *Opts) SetTaskOutputModes(values []string) error {
+	for _, value := range values {
+		task, mode, ok := strings.Cut(value, "=")
+		if !ok {
+			if err := opts.SetTaskOutputMode(value); err != nil {
+				return err
+			}
+			continue
+		}
+		if task == "" {
+			return fmt.Errorf("expected <task>=<mode>, got %q", value)
+		}
+		outputMode, err := util.FromTaskOutputModeString(mode)
+		if err != nil {
+			return fmt.Errorf("output mode for %v must be one of \"%v\"", task, TaskOutputModes())
+		}
+		if opts.TaskOutputModeOverrides == nil {
+			opts.TaskOutputModeOverrides = make(map[string]util.TaskOutputMode)
+		}
+		opts.TaskOutputModeOverrides[task] = outputMode
+	}
+	return nil
+}
+
+// SkipsReadsFor returns true if the options bypass the cache for the task with
+// the given ID, name and package, as a RunCache created with them would
+func (opts *Opts) SkipsReadsFor(taskID string, task string, packageName string) bool {
+	if len(opts.SkipReadsFor) == 0 {
+		return opts.SkipReads
+	}
+	for _, entry := range opts.SkipReadsFor {
+		if entry == taskID || entry == task || entry == packageName {
+			return true
+		}
+	}
+	return false
+}
+
 // TaskOutputModes creates the description string for task outputs
 func TaskOutputModes() string {
 	var builder strings.Builder
@@ -62,27 +117,41 @@ func TaskOutputModes() string {
 
 // RunCache represents the interface to the cache for a single `turbo run`
 type RunCache struct {
-	taskOutputModeOverride *util.TaskOutputMode
-	cache                  cache.Cache
-	readsDisabled          bool
-	writesDisabled         bool
-	repoRoot               turbopath.AbsoluteSystemPath
-	logReplayer            LogReplayer
-	outputWatcher          OutputWatcher
-	colorCache             *colorcache.ColorCache
+	taskOutputModeOverride  *util.TaskOutputMode
+	taskOutputModeOverrides map[string]util.TaskOutputMode
+	cache                   cache.Cache
+	readsDisabled           bool
+	readsDisabledFor        util.Set
+	writesDisabled          bool
+	logsDisabled            bool
+	repoRoot                turbopath.AbsoluteSystemPath
+	logReplayer             LogReplayer
+	outputWatcher           OutputWatcher
+	colorCache              *colorcache.ColorCache
+	taskOutput              io.Writer
 }
 
 // New returns a new instance of RunCache, wrapping the given cache
 func New(cache cache.Cache, repoRoot turbopath.AbsoluteSystemPath, opts Opts, colorCache *colorcache.ColorCache) *RunCache {
 	rc := &RunCache{
-		taskOutputModeOverride: opts.TaskOutputModeOverride,
-		cache:                  cache,
-		readsDisabled:          opts.SkipReads,
-		writesDisabled:         opts.SkipWrites,
-		repoRoot:               repoRoot,
-		logReplayer:            opts.LogReplayer,
-		outputWatcher:          opts.OutputWatcher,
-		colorCache:             colorCache,
+		taskOutputModeOverride:  opts.TaskOutputModeOverride,
+		taskOutputModeOverrides: opts.TaskOutputModeOverrides,
+		cache:                   cache,
+		readsDisabled:           opts.SkipReads || len(opts.SkipReadsFor) > 0,
+		writesDisabled:          opts.SkipWrites,
+		logsDisabled:            opts.SkipLogs,
+		repoRoot:                repoRoot,
+		logReplayer:             opts.LogReplayer,
+		outputWatcher:           opts.OutputWatcher,
+		colorCache:              colorCache,
+		taskOutput:              opts.TaskOutput,
+	}
+
+	if len(opts.SkipReadsFor) > 0 {
+		rc.readsDisabledFor = make(util.Set)
+		for _, entry := range opts.SkipReadsFor {
+			rc.readsDisabledFor.Add(entry)
+		}
 	}
 
 	if rc.logReplayer == nil {
@@ -91,6 +160,9 @@ func New(cache cache.Cache, repoRoot turbopath.AbsoluteSystemPath, opts Opts, co
 	if rc.outputWatcher == nil {
 		rc.outputWatcher = &NoOpOutputWatcher{}
 	}
+	if rc.taskOutput == nil {
+		rc.taskOutput = os.Stdout
+	}
 	return rc
 }
 
@@ -103,17 +175,42 @@ type TaskCache struct {
 	pt                *nodes.PackageTask
 	taskOutputMode    util.TaskOutputMode
 	cachingDisabled   bool
+	readsDisabled     bool
+	logsDisabled      bool
+	taskOutput        io.Writer
 	LogFileName       turbopath.AbsoluteSystemPath
 }
 
+// MissReason describes why a task wasn't restored from the cache
+type MissReason string
+
+// The reasons a task can miss the cache
+const (
+	// MissCacheDisabled is for tasks with caching turned off in the pipeline
+	MissCacheDisabled MissReason = "cache_disabled"
+	// MissForced is for tasks that bypassed the cache with --force or --force-filter
+	MissForced MissReason = "forced"
+	// MissHashChanged is for tasks that ran before, but with different inputs
+	MissHashChanged MissReason = "hash_changed"
+	// MissNoCacheEntry is for tasks without a previous run, and with no entry
+	// for their hash in the local or remote cache
+	MissNoCacheEntry MissReason = "no_cache_entry"
+	// MissCorrupted is for tasks whose cache entry didn't match its manifest,
+	// for example because it was cut off while being written
+	MissCorrupted MissReason = "corrupted_artifact"
+)
+
 // RestoreOutputs attempts to restore output for the corresponding task from the cache.
-// Returns true if successful.
-func (tc TaskCache) RestoreOutputs(ctx context.Context, prefixedUI *cli.PrefixedUi, progressLogger hclog.Logger) (bool, error) {
-	if tc.cachingDisabled || tc.rc.readsDisabled {
+// Returns true if successful, and otherwise why the task missed the cache.
+func (tc TaskCache) RestoreOutputs(ctx context.Context, prefixedUI *cli.PrefixedUi, progressLogger hclog.Logger) (bool, MissReason, error) {
+	if tc.cachingDisabled || tc.readsDisabled {
 		if tc.taskOutputMode != util.NoTaskOutput && tc.taskOutputMode != util.ErrorTaskOutput {
 			prefixedUI.Output(fmt.Sprintf("cache bypass, force executing %s", ui.Dim(tc.hash)))
 		}
-		return false, nil
+		if tc.cachingDisabled {
+			return false, MissCacheDisabled, nil
+		}
+		return false, MissForced, nil
 	}
 	changedOutputGlobs, err := tc.rc.outputWatcher.GetChangedOutputs(ctx, tc.hash, tc.repoRelativeGlobs.Inclusions)
 	if err != nil {
@@ -127,14 +224,25 @@ func (tc TaskCache) RestoreOutputs(ctx context.Context, prefixedUI *cli.Prefixed
 		// Note that we currently don't use the output globs when restoring, but we could in the
 		// future to avoid doing unnecessary file I/O. We also need to pass along the exclusion
 		// globs as well.
-		hit, _, _, err := tc.rc.cache.Fetch(tc.rc.repoRoot, tc.hash, nil)
-		if err != nil {
-			return false, err
+		hit, files, _, err := tc.rc.cache.Fetch(tc.rc.repoRoot, tc.hash, nil)
+		if errors.Is(err, cacheitem.ErrCorrupted) {
+			prefixedUI.Warn(fmt.Sprintf("%v, executing %s", err, ui.Dim(tc.hash)))
+			return false, MissCorrupted, nil
+		} else if err != nil {
+			return false, "", err
 		} else if !hit {
+			if hit, files, err = tc.restoreFallback(prefixedUI, progressLogger); err != nil {
+				return false, "", err
+			}
+		}
+		if !hit {
 			if tc.taskOutputMode != util.NoTaskOutput && tc.taskOutputMode != util.ErrorTaskOutput {
 				prefixedUI.Output(fmt.Sprintf("cache miss, executing %s", ui.Dim(tc.hash)))
 			}
-			return false, nil
+			return false, tc.missReason(), nil
+		}
+		if err := tc.recordOutputs(tc.hash, files); err != nil {
+			progressLogger.Debug("failed to record the restored outputs", "error", err)
 		}
 
 		if err := tc.rc.outputWatcher.NotifyOutputsWritten(ctx, tc.hash, tc.repoRelativeGlobs); err != nil {
@@ -152,6 +260,10 @@ func (tc TaskCache) RestoreOutputs(ctx context.Context, prefixedUI *cli.Prefixed
 	case util.HashTaskOutput:
 		prefixedUI.Info(fmt.Sprintf("cache hit, suppressing output %s", ui.Dim(tc.hash)))
 	case util.FullTaskOutput:
+		if tc.logsDisabled {
+			prefixedUI.Info(fmt.Sprintf("cache hit, logs are not cached %s", ui.Dim(tc.hash)))
+			break
+		}
 		progressLogger.Debug("log file", "path", tc.LogFileName)
 		prefixedUI.Info(fmt.Sprintf("cache hit, replaying output %s", ui.Dim(tc.hash)))
 		tc.ReplayLogFile(prefixedUI, progressLogger)
@@ -161,7 +273,61 @@ func (tc TaskCache) RestoreOutputs(ctx context.Context, prefixedUI *cli.Prefixed
 		// NoLogs, do not output anything
 	}
 
-	return true, nil
+	return true, "", nil
+}
+
+// restoreFallback restores the outputs cached under the task's hash with the
+// fallback hash version, while a repository migrates to a new one. The outputs
+// are then cached again under the current hash, so that the next run hits it.
+func (tc TaskCache) restoreFallback(prefixedUI *cli.PrefixedUi, progressLogger hclog.Logger) (bool, []turbopath.AnchoredSystemPath, error) {
+	fallbackHash := tc.pt.FallbackHash
+	if fallbackHash == "" {
+		return false, nil, nil
+	}
+	hit, files, duration, err := tc.rc.cache.Fetch(tc.rc.repoRoot, fallbackHash, nil)
+	if errors.Is(err, cacheitem.ErrCorrupted) {
+		progressLogger.Debug("fallback cache entry is corrupted", "hash", fallbackHash, "error", err)
+		return false, nil, nil
+	} else if err != nil || !hit {
+		return false, nil, err
+	}
+	progressLogger.Debug("restored outputs from the fallback hash", "hash", tc.hash, "fallback", fallbackHash)
+	if tc.taskOutputMode != util.NoTaskOutput && tc.taskOutputMode != util.ErrorTaskOutput {
+		prefixedUI.Output(fmt.Sprintf("cache hit with fallback hash %s", ui.Dim(fallbackHash)))
+	}
+	if !tc.rc.writesDisabled {
+		if err := tc.rc.cache.Put(tc.rc.repoRoot, tc.hash, duration, files); err != nil {
+			prefixedUI.Warn(ui.Dim(fmt.Sprintf("Failed to cache outputs under %v: %v", tc.hash, err)))
+		}
+	}
+	return true, files, nil
+}
+
+// missReason tells apart the tasks that ran before from the ones that never
+// did, once there is no cache entry for the hash. Running a task leaves its log
+// file behind, and so does restoring it from the cache unless logs aren't
+// cached, so a log file means the task had a different hash the last time.
+func (tc TaskCache) missReason() MissReason {
+	if tc.LogFileName.FileExists() {
+		return MissHashChanged
+	}
+	return MissNoCacheEntry
+}
+
+// ReadsAndWritesEnabled returns true if this task's outputs will be both written to and read from the cache
+func (tc TaskCache) ReadsAndWritesEnabled() bool {
+	return !tc.cachingDisabled && !tc.readsDisabled && !tc.rc.writesDisabled
+}
+
+// OutputsUnchanged is whether the outputs of the task are still the ones that
+// were last restored or saved for its hash, so that restoring it from the cache
+// won't change any files. It needs the daemon to watch the outputs.
+func (tc TaskCache) OutputsUnchanged(ctx context.Context) bool {
+	if tc.cachingDisabled || tc.readsDisabled {
+		return false
+	}
+	changedOutputGlobs, err := tc.rc.outputWatcher.GetChangedOutputs(ctx, tc.hash, tc.repoRelativeGlobs.Inclusions)
+	return err == nil && len(changedOutputGlobs) == 0
 }
 
 // ReplayLogFile writes out the stored logfile to the terminal
@@ -179,13 +345,6 @@ func (tc TaskCache) OnError(terminal *cli.PrefixedUi, logger hclog.Logger) {
 	}
 }
 
-// nopWriteCloser is modeled after io.NopCloser, which is for Readers
-type nopWriteCloser struct {
-	io.Writer
-}
-
-func (nopWriteCloser) Close() error { return nil }
-
 type fileWriterCloser struct {
 	io.Writer
 	file  *os.File
@@ -200,14 +359,12 @@ func (fwc *fileWriterCloser) Close() error {
 }
 
 // OutputWriter creates a sink suitable for handling the output of the command associated
-// with this task.
+// with this task. The output is always captured to the task's log file, even when the
+// task isn't cached, so that --output-mode=errors-only can replay it on failure.
 func (tc TaskCache) OutputWriter(prefix string) (io.WriteCloser, error) {
-	// an os.Stdout wrapper that will add prefixes before printing to stdout
-	stdoutWriter := logstreamer.NewPrettyStdoutWriter(prefix)
+	// a wrapper that will add prefixes before printing to the task output
+	stdoutWriter := logstreamer.NewPrettyWriter(tc.taskOutput, prefix)
 
-	if tc.cachingDisabled || tc.rc.writesDisabled {
-		return nopWriteCloser{stdoutWriter}, nil
-	}
 	// Setup log file
 	if err := tc.LogFileName.EnsureDir(); err != nil {
 		return nil, err
@@ -263,6 +420,9 @@ func (tc TaskCache) SaveOutputs(ctx context.Context, logger hclog.Logger, termin
 	if err = tc.rc.cache.Put(tc.rc.repoRoot, tc.hash, duration, relativePaths); err != nil {
 		return err
 	}
+	if err := tc.recordOutputs(tc.hash, relativePaths); err != nil {
+		logger.Debug("failed to record the cached outputs", "error", err)
+	}
 	err = tc.rc.outputWatcher.NotifyOutputsWritten(ctx, tc.hash, tc.repoRelativeGlobs)
 	if err != nil {
 		// Don't fail the cache write because we also failed to record it, we will just do
@@ -273,25 +433,106 @@ func (tc TaskCache) SaveOutputs(ctx context.Context, logger hclog.Logger, termin
 	return nil
 }
 
+// OutputHashes returns the hashes of the task's output files, other than its
+// log file, keyed by their path from the repository root. Symlinks are hashed
+// by their target rather than followed.
+func (tc TaskCache) OutputHashes() (map[turbopath.AnchoredUnixPath]string, error) {
+	files, err := globby.GlobFiles(tc.rc.repoRoot.ToStringDuringMigration(), tc.repoRelativeGlobs.Inclusions, tc.repoRelativeGlobs.Exclusions)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make(map[turbopath.AnchoredUnixPath]string, len(files))
+	for _, file := range files {
+		if file == tc.LogFileName.ToString() {
+			continue
+		}
+		relativePath, err := tc.rc.repoRoot.RelativePathString(file)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Lstat(file)
+		if err != nil {
+			return nil, err
+		}
+		var hash string
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(file)
+			if err != nil {
+				return nil, err
+			}
+			hash = "symlink:" + target
+		} else if hash, err = fs.GitLikeHashFile(file); err != nil {
+			return nil, err
+		}
+		hashes[fs.UnsafeToAnchoredSystemPath(relativePath).ToUnixPath()] = hash
+	}
+	return hashes, nil
+}
+
+// OutputSize returns how many bytes the task's output files take up, other than
+// its log file. Symlinks count as the size of the link rather than its target.
+func (tc TaskCache) OutputSize() (int64, error) {
+	files, err := globby.GlobFiles(tc.rc.repoRoot.ToStringDuringMigration(), tc.repoRelativeGlobs.Inclusions, tc.repoRelativeGlobs.Exclusions)
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, file := range files {
+		if file == tc.LogFileName.ToString() {
+			continue
+		}
+		info, err := os.Lstat(file)
+		if err != nil {
+			return 0, err
+		}
+		size += info.Size()
+	}
+	return size, nil
+}
+
+// ChangedOutputs returns the sorted paths of the files that were added, removed
+// or modified between two results of OutputHashes
+func ChangedOutputs(before map[turbopath.AnchoredUnixPath]string, after map[turbopath.AnchoredUnixPath]string) []string {
+	changed := []string{}
+	for path, hash := range before {
+		if afterHash, ok := after[path]; !ok || afterHash != hash {
+			changed = append(changed, path.ToString())
+		}
+	}
+	for path := range after {
+		if _, ok := before[path]; !ok {
+			changed = append(changed, path.ToString())
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
 // TaskCache returns a TaskCache instance, providing an interface to the underlying cache specific
 // to this run and the given PackageTask
 func (rc *RunCache) TaskCache(pt *nodes.PackageTask, hash string) TaskCache {
 	logFileName := rc.repoRoot.UntypedJoin(pt.LogFile)
-	hashableOutputs := pt.HashableOutputs()
-	repoRelativeGlobs := fs.TaskOutputs{
-		Inclusions: make([]string, len(hashableOutputs.Inclusions)),
-		Exclusions: make([]string, len(hashableOutputs.Exclusions)),
-	}
-
-	for index, output := range hashableOutputs.Inclusions {
-		repoRelativeGlobs.Inclusions[index] = filepath.Join(pt.Pkg.Dir.ToStringDuringMigration(), output)
-	}
-	for index, output := range hashableOutputs.Exclusions {
-		repoRelativeGlobs.Exclusions[index] = filepath.Join(pt.Pkg.Dir.ToStringDuringMigration(), output)
+	repoRelativeGlobs := pt.RepoRelativeOutputs()
+	logsDisabled := pt.TaskDefinition.ExcludeLogs || rc.logsDisabled
+	if rc.logsDisabled && !pt.TaskDefinition.ExcludeLogs {
+		// The log file is part of the task's outputs for hashing, but is left out
+		// of what gets saved and restored
+		logGlob := filepath.Join(pt.Pkg.Dir.ToStringDuringMigration(), pt.LogGlob())
+		inclusions := []string{}
+		for _, inclusion := range repoRelativeGlobs.Inclusions {
+			if inclusion != logGlob {
+				inclusions = append(inclusions, inclusion)
+			}
+		}
+		repoRelativeGlobs.Inclusions = inclusions
 	}
 
 	taskOutputMode := pt.TaskDefinition.OutputMode
-	if rc.taskOutputModeOverride != nil {
+	if mode, ok := rc.taskOutputModeOverrides[pt.TaskID]; ok {
+		taskOutputMode = mode
+	} else if mode, ok := rc.taskOutputModeOverrides[pt.Task]; ok {
+		taskOutputMode = mode
+	} else if rc.taskOutputModeOverride != nil {
 		taskOutputMode = *rc.taskOutputModeOverride
 	}
 
@@ -302,10 +543,51 @@ func (rc *RunCache) TaskCache(pt *nodes.PackageTask, hash string) TaskCache {
 		pt:                pt,
 		taskOutputMode:    taskOutputMode,
 		cachingDisabled:   !pt.TaskDefinition.ShouldCache,
+		readsDisabled:     rc.taskReadsDisabled(pt),
+		logsDisabled:      logsDisabled,
+		taskOutput:        rc.taskOutput,
 		LogFileName:       logFileName,
 	}
 }
 
+// Prefetch starts downloading the remote artifacts of tasks, in order, so that
+// they are ready by the time the tasks restore them. Tasks whose cache is
+// disabled or bypassed are left out.
+func (rc *RunCache) Prefetch(tasks []*nodes.PackageTask) {
+	hashes := make([]string, 0, len(tasks))
+	for _, pt := range tasks {
+		if pt.Hash == "" || !pt.TaskDefinition.ShouldCache || pt.TaskDefinition.Persistent || rc.taskReadsDisabled(pt) {
+			continue
+		}
+		hashes = append(hashes, pt.Hash)
+	}
+	if len(hashes) > 0 {
+		rc.cache.Prefetch(hashes)
+	}
+}
+
+// WithTaskOutput returns a copy of the TaskCache that writes the output of the
+// task's command to w, rather than to the output of the RunCache
+func (tc TaskCache) WithTaskOutput(w io.Writer) TaskCache {
+	tc.taskOutput = w
+	return tc
+}
+
+// taskReadsDisabled returns true if the cache should be bypassed for the given
+// task, either because reads are disabled for every task or because the task
+// was selected by --force-filter
+func (rc *RunCache) taskReadsDisabled(pt *nodes.PackageTask) bool {
+	if !rc.readsDisabled {
+		return false
+	}
+	if rc.readsDisabledFor == nil {
+		return true
+	}
+	return rc.readsDisabledFor.Includes(pt.TaskID) ||
+		rc.readsDisabledFor.Includes(pt.Task) ||
+		rc.readsDisabledFor.Includes(pt.PackageName)
+}
+
 // defaultLogReplayer will try to replay logs back to the given Ui instance
 func defaultLogReplayer(logger hclog.Logger, output *cli.PrefixedUi, logFileName turbopath.AbsoluteSystemPath) {
 	logger.Debug("start replaying logs")
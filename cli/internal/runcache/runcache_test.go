@@ -0,0 +1,373 @@
+package runcache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/mitchellh/cli"
+	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/cacheitem"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/nodes"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+	"gotest.tools/v3/assert"
+)
+
+func TestSetTaskOutputModes(t *testing.T) {
+	opts := &Opts{}
+	err := opts.SetTaskOutputModes([]string{"new-only", "web#build=errors-only", "test=full"})
+	assert.NilError(t, err)
+
+	assert.Equal(t, *opts.TaskOutputModeOverride, util.NewTaskOutput)
+	assert.DeepEqual(t, opts.TaskOutputModeOverrides, map[string]util.TaskOutputMode{
+		"web#build": util.ErrorTaskOutput,
+		"test":      util.FullTaskOutput,
+	})
+}
+
+func TestSetTaskOutputModesErrors(t *testing.T) {
+	testCases := []struct {
+		value string
+		want  string
+	}{
+		{value: "loud", want: "must be one of"},
+		{value: "web#build=loud", want: "output mode for web#build must be one of"},
+		{value: "=full", want: "expected <task>=<mode>"},
+	}
+	for _, tc := range testCases {
+		opts := &Opts{}
+		err := opts.SetTaskOutputModes([]string{tc.value})
+		assert.ErrorContains(t, err, tc.want)
+	}
+}
+
+func TestTaskReadsDisabled(t *testing.T) {
+	webBuild := &nodes.PackageTask{TaskID: "web#build", Task: "build", PackageName: "web"}
+	docsBuild := &nodes.PackageTask{TaskID: "docs#build", Task: "build", PackageName: "docs"}
+	webLint := &nodes.PackageTask{TaskID: "web#lint", Task: "lint", PackageName: "web"}
+
+	testCases := []struct {
+		name string
+		opts Opts
+		want []bool
+	}{
+		{name: "no force", opts: Opts{}, want: []bool{false, false, false}},
+		{name: "force", opts: Opts{SkipReads: true}, want: []bool{true, true, true}},
+		{name: "task id", opts: Opts{SkipReadsFor: []string{"web#build"}}, want: []bool{true, false, false}},
+		{name: "task name", opts: Opts{SkipReadsFor: []string{"build"}}, want: []bool{true, true, false}},
+		{name: "package", opts: Opts{SkipReads: true, SkipReadsFor: []string{"web"}}, want: []bool{true, false, true}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rc := New(nil, "", tc.opts, nil)
+			got := []bool{rc.taskReadsDisabled(webBuild), rc.taskReadsDisabled(docsBuild), rc.taskReadsDisabled(webLint)}
+			assert.DeepEqual(t, got, tc.want)
+			// Dry runs predict the same without a RunCache
+			got = []bool{}
+			for _, pt := range []*nodes.PackageTask{webBuild, docsBuild, webLint} {
+				got = append(got, tc.opts.SkipsReadsFor(pt.TaskID, pt.Task, pt.PackageName))
+			}
+			assert.DeepEqual(t, got, tc.want)
+		})
+	}
+}
+
+// emptyCache is a cache without any entries, whose fetches fail with err
+type emptyCache struct {
+	err error
+}
+
+func (c emptyCache) Fetch(anchor turbopath.AbsoluteSystemPath, hash string, files []string) (bool, []turbopath.AnchoredSystemPath, int, error) {
+	return false, nil, 0, c.err
+}
+func (emptyCache) Exists(hash string) cache.ItemStatus { return cache.ItemStatus{} }
+func (emptyCache) Put(anchor turbopath.AbsoluteSystemPath, hash string, duration int, files []turbopath.AnchoredSystemPath) error {
+	return nil
+}
+func (emptyCache) Prefetch(hashes []string)                  {}
+func (emptyCache) Clean(anchor turbopath.AbsoluteSystemPath) {}
+func (emptyCache) CleanAll()                                 {}
+func (emptyCache) Shutdown()                                 {}
+
+// prefetchCache records the hashes it is asked to prefetch
+type prefetchCache struct {
+	emptyCache
+	prefetched []string
+}
+
+func (c *prefetchCache) Prefetch(hashes []string) {
+	c.prefetched = append(c.prefetched, hashes...)
+}
+
+func TestPrefetch(t *testing.T) {
+	cached := &fs.TaskDefinition{ShouldCache: true}
+	tasks := []*nodes.PackageTask{
+		{TaskID: "web#build", Task: "build", PackageName: "web", Hash: "web-build", TaskDefinition: cached},
+		{TaskID: "docs#build", Task: "build", PackageName: "docs", Hash: "docs-build", TaskDefinition: cached},
+		{TaskID: "web#lint", Task: "lint", PackageName: "web", Hash: "web-lint", TaskDefinition: &fs.TaskDefinition{}},
+		{TaskID: "web#dev", Task: "dev", PackageName: "web", Hash: "web-dev", TaskDefinition: &fs.TaskDefinition{ShouldCache: true, Persistent: true}},
+	}
+	turboCache := &prefetchCache{}
+	New(turboCache, "", Opts{SkipReadsFor: []string{"docs"}}, nil).Prefetch(tasks)
+	assert.DeepEqual(t, turboCache.prefetched, []string{"web-build"})
+}
+
+// fallbackCache has an entry for a single hash, and records the hashes put in it
+type fallbackCache struct {
+	emptyCache
+	hash string
+	puts []string
+}
+
+func (c *fallbackCache) Fetch(anchor turbopath.AbsoluteSystemPath, hash string, files []string) (bool, []turbopath.AnchoredSystemPath, int, error) {
+	if hash != c.hash {
+		return false, nil, 0, nil
+	}
+	return true, []turbopath.AnchoredSystemPath{"web/dist/index.js"}, 100, nil
+}
+
+func (c *fallbackCache) Put(anchor turbopath.AbsoluteSystemPath, hash string, duration int, files []turbopath.AnchoredSystemPath) error {
+	c.puts = append(c.puts, hash)
+	return nil
+}
+
+func TestRestoreOutputsFallbackHash(t *testing.T) {
+	testCases := []struct {
+		name         string
+		opts         Opts
+		fallbackHash string
+		wantHit      bool
+		wantPuts     []string
+	}{
+		{name: "fallback hit", fallbackHash: "v1-hash", wantHit: true, wantPuts: []string{"v2-hash"}},
+		{name: "fallback hit without writes", opts: Opts{SkipWrites: true}, fallbackHash: "v1-hash", wantHit: true},
+		{name: "fallback miss", fallbackHash: "other-hash"},
+		{name: "no fallback"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+			pt := &nodes.PackageTask{
+				TaskID:         "web#build",
+				Task:           "build",
+				PackageName:    "web",
+				Pkg:            &fs.PackageJSON{Dir: turbopath.AnchoredSystemPath("web")},
+				TaskDefinition: &fs.TaskDefinition{ShouldCache: true},
+				LogFile:        "web/.turbo/turbo-build.log",
+				FallbackHash:   tc.fallbackHash,
+			}
+			turboCache := &fallbackCache{hash: "v1-hash"}
+			rc := New(turboCache, repoRoot, tc.opts, nil)
+			prefixedUI := &cli.PrefixedUi{Ui: cli.NewMockUi()}
+
+			hit, _, err := rc.TaskCache(pt, "v2-hash").RestoreOutputs(context.Background(), prefixedUI, hclog.NewNullLogger())
+			assert.NilError(t, err)
+			assert.Equal(t, hit, tc.wantHit)
+			assert.DeepEqual(t, turboCache.puts, tc.wantPuts)
+		})
+	}
+}
+
+func TestRestoreOutputsMissReason(t *testing.T) {
+	testCases := []struct {
+		name        string
+		opts        Opts
+		shouldCache bool
+		previousRun bool
+		fetchErr    error
+		want        MissReason
+	}{
+		{name: "new task", shouldCache: true, want: MissNoCacheEntry},
+		{name: "changed task", shouldCache: true, previousRun: true, want: MissHashChanged},
+		{name: "force", opts: Opts{SkipReads: true}, shouldCache: true, previousRun: true, want: MissForced},
+		{name: "cache disabled", opts: Opts{SkipReads: true}, want: MissCacheDisabled},
+		{name: "corrupted artifact", shouldCache: true, previousRun: true, fetchErr: fmt.Errorf("%w: index.js is missing", cacheitem.ErrCorrupted), want: MissCorrupted},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+			pt := &nodes.PackageTask{
+				TaskID:         "web#build",
+				Task:           "build",
+				PackageName:    "web",
+				Pkg:            &fs.PackageJSON{Dir: turbopath.AnchoredSystemPath("web")},
+				TaskDefinition: &fs.TaskDefinition{ShouldCache: tc.shouldCache},
+				LogFile:        "web/.turbo/turbo-build.log",
+			}
+			if tc.previousRun {
+				logFile := repoRoot.UntypedJoin(pt.LogFile)
+				assert.NilError(t, logFile.EnsureDir())
+				assert.NilError(t, logFile.WriteFile([]byte("built\n"), 0644))
+			}
+			rc := New(emptyCache{err: tc.fetchErr}, repoRoot, tc.opts, nil)
+			prefixedUI := &cli.PrefixedUi{Ui: cli.NewMockUi()}
+
+			hit, reason, err := rc.TaskCache(pt, "abc123").RestoreOutputs(context.Background(), prefixedUI, hclog.NewNullLogger())
+			assert.NilError(t, err)
+			assert.Equal(t, hit, false)
+			assert.Equal(t, reason, tc.want)
+		})
+	}
+}
+
+func TestOutputHashes(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	pt := &nodes.PackageTask{
+		TaskID:      "web#build",
+		Task:        "build",
+		PackageName: "web",
+		Pkg:         &fs.PackageJSON{Dir: turbopath.AnchoredSystemPath("web")},
+		TaskDefinition: &fs.TaskDefinition{
+			ShouldCache: true,
+			Outputs:     fs.TaskOutputs{Inclusions: []string{"dist/**"}},
+		},
+		LogFile: "web/.turbo/turbo-build.log",
+	}
+	writeFile := func(path string, contents string) {
+		file := repoRoot.UntypedJoin(path)
+		assert.NilError(t, file.EnsureDir())
+		assert.NilError(t, file.WriteFile([]byte(contents), 0644))
+	}
+	writeFile("web/dist/index.js", "index")
+	writeFile("web/dist/types.d.ts", "types")
+	writeFile("web/dist/stale.js", "stale")
+	writeFile(pt.LogFile, "built in 1s")
+	taskCache := New(emptyCache{}, repoRoot, Opts{}, nil).TaskCache(pt, "abc123")
+
+	before, err := taskCache.OutputHashes()
+	assert.NilError(t, err)
+	assert.Equal(t, len(before), 3, "the log file is not an output to compare")
+
+	writeFile("web/dist/index.js", "index, generated at a different time")
+	writeFile("web/dist/chunk.js", "chunk")
+	assert.NilError(t, repoRoot.UntypedJoin("web", "dist", "stale.js").Remove())
+	writeFile(pt.LogFile, "built in 2s")
+	after, err := taskCache.OutputHashes()
+	assert.NilError(t, err)
+
+	assert.DeepEqual(t, ChangedOutputs(before, after), []string{"web/dist/chunk.js", "web/dist/index.js", "web/dist/stale.js"})
+	assert.DeepEqual(t, ChangedOutputs(after, after), []string{})
+}
+
+func TestOutputSize(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	pt := &nodes.PackageTask{
+		TaskID:      "web#build",
+		Task:        "build",
+		PackageName: "web",
+		Pkg:         &fs.PackageJSON{Dir: turbopath.AnchoredSystemPath("web")},
+		TaskDefinition: &fs.TaskDefinition{
+			ShouldCache: true,
+			Outputs:     fs.TaskOutputs{Inclusions: []string{"dist/**"}, Exclusions: []string{"dist/**/*.map"}},
+		},
+		LogFile: "web/.turbo/turbo-build.log",
+	}
+	writeFile := func(path string, contents string) {
+		file := repoRoot.UntypedJoin(path)
+		assert.NilError(t, file.EnsureDir())
+		assert.NilError(t, file.WriteFile([]byte(contents), 0644))
+	}
+	writeFile("web/dist/index.js", "index")
+	writeFile("web/dist/chunks/a.js", "chunk a")
+	writeFile("web/dist/index.js.map", "excluded")
+	writeFile("web/src/index.ts", "not an output")
+	writeFile(pt.LogFile, "built in 1s")
+	taskCache := New(emptyCache{}, repoRoot, Opts{}, nil).TaskCache(pt, "abc123")
+
+	size, err := taskCache.OutputSize()
+	assert.NilError(t, err)
+	assert.Equal(t, size, int64(len("index")+len("chunk a")), "the log file and excluded files are not counted")
+}
+
+func TestStaleOutputs(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	pt := &nodes.PackageTask{
+		TaskID:      "web#build",
+		Task:        "build",
+		PackageName: "web",
+		Pkg:         &fs.PackageJSON{Dir: turbopath.AnchoredSystemPath("web")},
+		TaskDefinition: &fs.TaskDefinition{
+			ShouldCache: true,
+			Outputs:     fs.TaskOutputs{Inclusions: []string{"dist/**"}},
+		},
+		LogFile: "web/.turbo/turbo-build.log",
+	}
+	writeFile := func(path string, contents string) {
+		file := repoRoot.UntypedJoin(path)
+		assert.NilError(t, file.EnsureDir())
+		assert.NilError(t, file.WriteFile([]byte(contents), 0644))
+	}
+	writeFile("web/dist/index.js", "index")
+	writeFile(pt.LogFile, "built in 1s")
+	taskCache := New(emptyCache{}, repoRoot, Opts{}, nil).TaskCache(pt, "abc123")
+
+	stale, err := taskCache.StaleOutputs()
+	assert.NilError(t, err)
+	assert.Assert(t, stale == nil, "outputs that were never cached aren't stale")
+
+	assert.NilError(t, taskCache.SaveOutputs(context.Background(), hclog.NewNullLogger(), cli.NewMockUi(), 1))
+	stale, err = taskCache.StaleOutputs()
+	assert.NilError(t, err)
+	assert.Equal(t, len(stale), 0)
+
+	// Written by a build of another branch, for instance
+	writeFile("web/dist/old/chunk.js", "chunk")
+	writeFile("web/dist/about.js", "about")
+	writeFile(pt.LogFile, "built in 2s")
+	stale, err = taskCache.StaleOutputs()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, stale, []turbopath.AnchoredUnixPath{"web/dist/about.js", "web/dist/old/chunk.js"})
+
+	assert.NilError(t, taskCache.RemoveOutputs(stale))
+	assert.Assert(t, !repoRoot.UntypedJoin("web", "dist", "about.js").FileExists())
+	assert.Assert(t, repoRoot.UntypedJoin("web", "dist", "index.js").FileExists())
+	stale, err = taskCache.StaleOutputs()
+	assert.NilError(t, err)
+	assert.Equal(t, len(stale), 0)
+}
+
+func TestOutputWriterWritesLogFile(t *testing.T) {
+	testCases := []struct {
+		name        string
+		opts        Opts
+		shouldCache bool
+		outputMode  util.TaskOutputMode
+		wantOutput  string
+	}{
+		{name: "cached", shouldCache: true, wantOutput: "prefix: building\n"},
+		{name: "cache disabled", wantOutput: "prefix: building\n"},
+		{name: "writes disabled", opts: Opts{SkipWrites: true}, shouldCache: true, wantOutput: "prefix: building\n"},
+		{name: "errors only", outputMode: util.ErrorTaskOutput, wantOutput: ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+			pt := &nodes.PackageTask{
+				TaskID:         "web#build",
+				Task:           "build",
+				PackageName:    "web",
+				Pkg:            &fs.PackageJSON{Dir: turbopath.AnchoredSystemPath("web")},
+				TaskDefinition: &fs.TaskDefinition{ShouldCache: tc.shouldCache, OutputMode: tc.outputMode},
+				LogFile:        "web/.turbo/turbo-build.log",
+			}
+			stdout := &bytes.Buffer{}
+			tc.opts.TaskOutput = stdout
+			taskCache := New(emptyCache{}, repoRoot, tc.opts, nil).TaskCache(pt, "abc123")
+
+			writer, err := taskCache.OutputWriter("prefix: ")
+			assert.NilError(t, err)
+			_, err = writer.Write([]byte("building\n"))
+			assert.NilError(t, err)
+			assert.NilError(t, writer.Close())
+
+			logContents, err := taskCache.LogFileName.ReadFile()
+			assert.NilError(t, err)
+			assert.Equal(t, string(logContents), "building\n")
+			assert.Equal(t, stdout.String(), tc.wantOutput)
+		})
+	}
+}
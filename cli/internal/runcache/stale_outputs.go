@@ -0,0 +1,105 @@
+package runcache
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/globby"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// outputsManifest lists the outputs that were last saved to or restored from
+// the cache for a task, next to its log file. A file that matches the outputs
+// of the task but isn't in the manifest was written by something else, such as
+// a build of another branch, and would end up in the next artifact of the task.
+type outputsManifest struct {
+	Hash string `json:"hash"`
+	// Files are the repo-relative unix paths of the outputs, including
+	// directories
+	Files []turbopath.AnchoredUnixPath `json:"files"`
+}
+
+// outputsManifestFile returns the path of the outputs manifest of the task
+func (tc TaskCache) outputsManifestFile() turbopath.AbsoluteSystemPath {
+	return tc.rc.repoRoot.UntypedJoin(tc.pt.Pkg.Dir.ToStringDuringMigration(), ".turbo", fmt.Sprintf("turbo-%v.outputs.json", tc.pt.Task))
+}
+
+// recordOutputs writes the outputs manifest of the task, once its outputs were
+// saved to or restored from the cache under hash
+func (tc TaskCache) recordOutputs(hash string, files []turbopath.AnchoredSystemPath) error {
+	manifest := outputsManifest{Hash: hash, Files: make([]turbopath.AnchoredUnixPath, 0, len(files))}
+	for _, file := range files {
+		if file != "" {
+			manifest.Files = append(manifest.Files, file.ToUnixPath())
+		}
+	}
+	sort.Slice(manifest.Files, func(i, j int) bool { return manifest.Files[i] < manifest.Files[j] })
+	contents, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	manifestFile := tc.outputsManifestFile()
+	if err := manifestFile.EnsureDir(); err != nil {
+		return err
+	}
+	return manifestFile.WriteFile(contents, 0644)
+}
+
+// StaleOutputs returns the sorted files that match the outputs of the task, but
+// that were neither written by its last run nor restored from the cache: the
+// files that aren't in its outputs manifest. It returns nil when the task has
+// no manifest, because its outputs were never saved nor restored.
+func (tc TaskCache) StaleOutputs() ([]turbopath.AnchoredUnixPath, error) {
+	if tc.cachingDisabled {
+		return nil, nil
+	}
+	manifestFile := tc.outputsManifestFile()
+	contents, err := manifestFile.ReadFile()
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var manifest outputsManifest
+	if err := json.Unmarshal(contents, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid outputs manifest %v: %w", manifestFile, err)
+	}
+	recorded := make(map[turbopath.AnchoredUnixPath]bool, len(manifest.Files))
+	for _, file := range manifest.Files {
+		recorded[file] = true
+	}
+
+	files, err := globby.GlobFiles(tc.rc.repoRoot.ToStringDuringMigration(), tc.repoRelativeGlobs.Inclusions, tc.repoRelativeGlobs.Exclusions)
+	if err != nil {
+		return nil, err
+	}
+	var stale []turbopath.AnchoredUnixPath
+	for _, file := range files {
+		if file == tc.LogFileName.ToString() || file == manifestFile.ToString() {
+			continue
+		}
+		relativePath, err := tc.rc.repoRoot.RelativePathString(file)
+		if err != nil {
+			return nil, err
+		}
+		unixPath := fs.UnsafeToAnchoredSystemPath(relativePath).ToUnixPath()
+		if !recorded[unixPath] {
+			stale = append(stale, unixPath)
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i] < stale[j] })
+	return stale, nil
+}
+
+// RemoveOutputs removes the given files, as returned by StaleOutputs
+func (tc TaskCache) RemoveOutputs(files []turbopath.AnchoredUnixPath) error {
+	for _, file := range files {
+		if err := file.ToSystemPath().RestoreAnchor(tc.rc.repoRoot).Remove(); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+// Package runevents streams what happens during a run as newline-delimited
+// JSON, one event per line, while the run is in progress. Tools such as IDE
+// integrations and dashboards read the stream from a file descriptor that they
+// pass to turbo, or from a Unix socket that they listen on, instead of parsing
+// the output of turbo in the terminal.
+package runevents
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The types of events, in the order that they happen for a task
+const (
+	RunStarted   = "runStarted"
+	TaskStarted  = "taskStarted"
+	CacheHit     = "cacheHit"
+	CacheMiss    = "cacheMiss"
+	LogChunk     = "logChunk"
+	TaskFinished = "taskFinished"
+	RunFinished  = "runFinished"
+)
+
+// Event is a line of the stream. Only the fields that apply to its type are set.
+type Event struct {
+	Type string `json:"type"`
+	// Time is in milliseconds since the unix epoch
+	Time   int64  `json:"time"`
+	TaskID string `json:"taskId,omitempty"`
+	Hash   string `json:"hash,omitempty"`
+	// Targets are the tasks that a run was started with
+	Targets []string `json:"targets,omitempty"`
+	// Reason is why a task missed the cache, or why a run exited with its
+	// exit code
+	Reason string `json:"reason,omitempty"`
+	// Stream is "stdout" or "stderr", and Data is a line that the task's
+	// command wrote to it, with its newline
+	Stream string `json:"stream,omitempty"`
+	Data   string `json:"data,omitempty"`
+	// Status is that of the task's execution in the run summary, such as
+	// "built" or "failed"
+	Status   string `json:"status,omitempty"`
+	ExitCode *int   `json:"exitCode,omitempty"`
+	// Duration is in milliseconds
+	Duration *int64 `json:"duration,omitempty"`
+}
+
+// Stream writes events to a destination. It is safe for concurrent use, and a
+// nil Stream discards events.
+type Stream struct {
+	mu  sync.Mutex
+	w   io.WriteCloser
+	now func() time.Time
+	// err is the first error writing to the destination, after which events
+	// are discarded, so that a reader going away doesn't fail the run
+	err error
+}
+
+// Open connects to a destination: "fd:N" for a file descriptor that turbo
+// inherited, or "unix:PATH" for a Unix socket that is listening.
+func Open(destination string) (*Stream, error) {
+	kind, target, ok := strings.Cut(destination, ":")
+	if !ok || target == "" {
+		return nil, fmt.Errorf("invalid --events %q, expected fd:N or unix:PATH", destination)
+	}
+	switch kind {
+	case "fd":
+		fd, err := strconv.Atoi(target)
+		if err != nil || fd < 0 {
+			return nil, fmt.Errorf("invalid --events %q, expected a file descriptor such as fd:3", destination)
+		}
+		f := os.NewFile(uintptr(fd), destination)
+		if f == nil {
+			return nil, fmt.Errorf("file descriptor %v is not open", fd)
+		}
+		if _, err := f.Stat(); err != nil {
+			return nil, fmt.Errorf("file descriptor %v is not open: %w", fd, err)
+		}
+		return New(f), nil
+	case "unix":
+		conn, err := net.Dial("unix", target)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to %v: %w", target, err)
+		}
+		return New(conn), nil
+	default:
+		return nil, fmt.Errorf("invalid --events %q, expected fd:N or unix:PATH", destination)
+	}
+}
+
+// New returns a Stream that writes events to w, and closes it when the Stream
+// is closed
+func New(w io.WriteCloser) *Stream {
+	return &Stream{w: w, now: time.Now}
+}
+
+// Emit writes an event, setting its time if it isn't set
+func (s *Stream) Emit(event Event) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return
+	}
+	if event.Time == 0 {
+		event.Time = s.now().UnixMilli()
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		s.err = err
+		return
+	}
+	if _, err := s.w.Write(append(line, '\n')); err != nil {
+		s.err = err
+	}
+}
+
+// Close closes the destination, and returns the error that stopped events from
+// being written, if any
+func (s *Stream) Close() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	closeErr := s.w.Close()
+	if s.err != nil {
+		return s.err
+	}
+	return closeErr
+}
+
+// LogWriter returns a writer for the output of a task's command, which emits a
+// LogChunk event for every line written to it. A line without a newline at the
+// end is emitted when the writer is closed.
+func (s *Stream) LogWriter(taskID string, stream string) io.WriteCloser {
+	return &logWriter{events: s, taskID: taskID, stream: stream}
+}
+
+type logWriter struct {
+	events *Stream
+	taskID string
+	stream string
+	buf    bytes.Buffer
+}
+
+func (l *logWriter) Write(p []byte) (int, error) {
+	if l.events == nil {
+		return len(p), nil
+	}
+	l.buf.Write(p)
+	for {
+		i := bytes.IndexByte(l.buf.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+		l.emit(string(l.buf.Next(i + 1)))
+	}
+	return len(p), nil
+}
+
+func (l *logWriter) Close() error {
+	if l.buf.Len() > 0 {
+		l.emit(l.buf.String())
+		l.buf.Reset()
+	}
+	return nil
+}
+
+func (l *logWriter) emit(data string) {
+	l.events.Emit(Event{
+		Type:   LogChunk,
+		TaskID: l.taskID,
+		Stream: l.stream,
+		Data:   data,
+	})
+}
@@ -0,0 +1,109 @@
+package runevents
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+type bufferCloser struct {
+	strings.Builder
+	closed bool
+}
+
+func (b *bufferCloser) Close() error {
+	b.closed = true
+	return nil
+}
+
+func readEvents(t *testing.T, ndjson string) []Event {
+	t.Helper()
+	var events []Event
+	for _, line := range strings.Split(strings.TrimSuffix(ndjson, "\n"), "\n") {
+		var event Event
+		assert.NilError(t, json.Unmarshal([]byte(line), &event), line)
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestStream(t *testing.T) {
+	out := &bufferCloser{}
+	stream := New(out)
+	stream.now = func() time.Time { return time.UnixMilli(1000) }
+
+	exitCode := 0
+	stream.Emit(Event{Type: TaskStarted, TaskID: "web#build", Hash: "abc"})
+	stream.Emit(Event{Type: CacheMiss, TaskID: "web#build", Reason: "no_cache_entry"})
+	stdout := stream.LogWriter("web#build", "stdout")
+	_, _ = stdout.Write([]byte("building\ncomp"))
+	_, _ = stdout.Write([]byte("iled\nno newline"))
+	assert.NilError(t, stdout.Close())
+	stream.Emit(Event{Type: TaskFinished, TaskID: "web#build", Status: "built", ExitCode: &exitCode, Time: 2000})
+	assert.NilError(t, stream.Close())
+	assert.Assert(t, out.closed)
+
+	assert.Equal(t, strings.Split(out.String(), "\n")[0], `{"type":"taskStarted","time":1000,"taskId":"web#build","hash":"abc"}`)
+	assert.DeepEqual(t, readEvents(t, out.String()), []Event{
+		{Type: TaskStarted, Time: 1000, TaskID: "web#build", Hash: "abc"},
+		{Type: CacheMiss, Time: 1000, TaskID: "web#build", Reason: "no_cache_entry"},
+		{Type: LogChunk, Time: 1000, TaskID: "web#build", Stream: "stdout", Data: "building\n"},
+		{Type: LogChunk, Time: 1000, TaskID: "web#build", Stream: "stdout", Data: "compiled\n"},
+		{Type: LogChunk, Time: 1000, TaskID: "web#build", Stream: "stdout", Data: "no newline"},
+		{Type: TaskFinished, Time: 2000, TaskID: "web#build", Status: "built", ExitCode: &exitCode},
+	})
+}
+
+func TestNilStream(t *testing.T) {
+	var stream *Stream
+	stream.Emit(Event{Type: RunStarted})
+	n, err := stream.LogWriter("web#build", "stderr").Write([]byte("ignored\n"))
+	assert.NilError(t, err)
+	assert.Equal(t, n, 8)
+	assert.NilError(t, stream.Close())
+}
+
+func TestOpenUnixSocket(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "events.sock")
+	listener, err := net.Listen("unix", socketPath)
+	assert.NilError(t, err)
+	defer func() { _ = listener.Close() }()
+
+	lines := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			close(lines)
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	stream, err := Open("unix:" + socketPath)
+	assert.NilError(t, err)
+	stream.Emit(Event{Type: RunStarted, Targets: []string{"build"}})
+	assert.NilError(t, stream.Close())
+	events := readEvents(t, <-lines)
+	assert.Equal(t, events[0].Type, RunStarted)
+	assert.DeepEqual(t, events[0].Targets, []string{"build"})
+}
+
+func TestOpenInvalid(t *testing.T) {
+	for _, destination := range []string{"", "stdout", "fd:", "fd:three", "tcp:localhost:9000"} {
+		_, err := Open(destination)
+		assert.ErrorContains(t, err, "invalid --events", destination)
+	}
+	_, err := Open("fd:987")
+	assert.ErrorContains(t, err, "file descriptor 987 is not open")
+}
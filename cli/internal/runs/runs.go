@@ -0,0 +1,127 @@
+// Package runs implements `turbo runs`, which lists and manages the run
+// summaries saved in the repository by `turbo run --summarize`
+package runs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/runsummary"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// ExecuteRuns executes the `runs` command.
+func ExecuteRuns(helper *cmdutil.Helper, args *turbostate.ParsedArgsFromRust) error {
+	base, err := helper.GetCmdBase(args)
+	if err != nil {
+		return err
+	}
+	if err := runs(base, args.Command.Runs); err != nil {
+		base.LogError("runs failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+func runs(base *cmdutil.CmdBase, opts *turbostate.RunsPayload) error {
+	switch opts.Command {
+	case "Clean":
+		return clean(base, opts)
+	case "List":
+		return list(base, opts)
+	default:
+		return fmt.Errorf("unknown subcommand: %v", opts.Command)
+	}
+}
+
+func clean(base *cmdutil.CmdBase, opts *turbostate.RunsPayload) error {
+	policy, err := retentionPolicy(base, opts)
+	if err != nil {
+		return err
+	}
+	removed, err := runsummary.CleanRuns(base.RepoRoot, policy, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to remove run summaries: %w", err)
+	}
+	base.UI.Output(fmt.Sprintf("Removed %v run summaries", removed))
+	return nil
+}
+
+// _defaultListLimit is how many run summaries `turbo runs list` shows by default
+const _defaultListLimit = 20
+
+func list(base *cmdutil.CmdBase, opts *turbostate.RunsPayload) error {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = _defaultListLimit
+	}
+	savedRuns, err := runsummary.ListRuns(base.RepoRoot, limit)
+	if err != nil {
+		return fmt.Errorf("failed to read run summaries: %w", err)
+	}
+	if opts.JSON {
+		rendered, err := json.MarshalIndent(savedRuns, "", "  ")
+		if err != nil {
+			return err
+		}
+		base.UI.Output(string(rendered))
+		return nil
+	}
+	if len(savedRuns) == 0 {
+		base.UI.Output("No run summaries found. Run summaries are saved by `turbo run --summarize`")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, util.Sprintf("${GREY}ID\tSaved\tDuration\tTasks\tCached\tFailed\tExit code${RESET}"))
+	for _, savedRun := range savedRuns {
+		duration := "-"
+		if savedRun.Duration != nil {
+			duration = (time.Duration(*savedRun.Duration) * time.Millisecond).String()
+		}
+		exitCode := "-"
+		if savedRun.ExitCode != nil {
+			exitCode = strconv.Itoa(*savedRun.ExitCode)
+		}
+		fmt.Fprintf(
+			w,
+			"%s\t%s\t%s\t%d\t%d (%.0f%%)\t%d\t%s\n",
+			savedRun.ID,
+			savedRun.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+			duration,
+			savedRun.Tasks,
+			savedRun.Cached,
+			savedRun.CacheHitRate*100,
+			savedRun.Failed,
+			exitCode,
+		)
+	}
+	return w.Flush()
+}
+
+// retentionPolicy returns the policy from the flags if any were passed, and
+// otherwise the one configured in turbo.json
+func retentionPolicy(base *cmdutil.CmdBase, opts *turbostate.RunsPayload) (runsummary.RetentionPolicy, error) {
+	if opts.MaxCount != 0 || opts.MaxAge != "" {
+		return runsummary.NewRetentionPolicy(fs.SummariesOptions{
+			MaxCount: opts.MaxCount,
+			MaxAge:   opts.MaxAge,
+		})
+	}
+	rootPackageJSON, err := fs.ReadPackageJSON(base.RepoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		return runsummary.RetentionPolicy{}, fmt.Errorf("failed to read package.json: %w", err)
+	}
+	turboJSON, err := fs.LoadTurboConfig(base.RepoRoot, rootPackageJSON, false)
+	if err != nil {
+		return runsummary.RetentionPolicy{}, err
+	}
+	return runsummary.NewRetentionPolicy(turboJSON.Summaries)
+}
@@ -0,0 +1,252 @@
+package runsummary
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// RunComparison describes how the tasks of a run saved in the repository
+// changed since an earlier one
+type RunComparison struct {
+	Before string `json:"before"`
+	After  string `json:"after"`
+	// GlobalChanges are the changes to the inputs of the hash of every task
+	GlobalChanges []string          `json:"globalChanges"`
+	Tasks         []*TaskComparison `json:"tasks"`
+}
+
+// TaskComparison describes how a task changed between two runs
+type TaskComparison struct {
+	TaskID string `json:"taskId"`
+	// HashBefore is missing if the task wasn't part of the earlier run, and
+	// HashAfter if it isn't part of the later one
+	HashBefore string `json:"hashBefore,omitempty"`
+	HashAfter  string `json:"hashAfter,omitempty"`
+	// Changes explain why the hash changed: the input files, environment
+	// variables, task definition or dependencies that changed
+	Changes      []string `json:"changes,omitempty"`
+	StatusBefore string   `json:"statusBefore,omitempty"`
+	StatusAfter  string   `json:"statusAfter,omitempty"`
+	// DurationBefore and DurationAfter are in milliseconds, and missing if the
+	// task didn't run
+	DurationBefore *int64 `json:"durationBefore,omitempty"`
+	DurationAfter  *int64 `json:"durationAfter,omitempty"`
+}
+
+// HashChanged is whether the task has a different hash, or is only part of one of the runs
+func (tc *TaskComparison) HashChanged() bool {
+	return tc.HashBefore != tc.HashAfter
+}
+
+// comparedTask holds the fields of a persisted TaskSummary that explain its hash.
+// Single package summaries only have Task, not TaskID.
+type comparedTask struct {
+	TaskID       string   `json:"taskId"`
+	Task         string   `json:"task"`
+	Hash         string   `json:"hash"`
+	Dependencies []string `json:"dependencies"`
+	// The task definition is only compared, so it isn't decoded
+	ResolvedTaskDefinition json.RawMessage                       `json:"resolvedTaskDefinition"`
+	ExpandedInputs         map[turbopath.AnchoredUnixPath]string `json:"expandedInputs"`
+	EnvVars                TaskEnvVarSummary                     `json:"environmentVariables"`
+	Execution              *TaskExecutionSummary                 `json:"execution"`
+	HashCommands           map[string]string                     `json:"hashCommands"`
+}
+
+func (t *comparedTask) id() string {
+	if t.TaskID == "" {
+		return t.Task
+	}
+	return t.TaskID
+}
+
+// duration is missing for tasks that didn't run, or didn't finish
+func (t *comparedTask) duration() *int64 {
+	if t.Execution == nil || t.Execution.Status == TaskStatusSkipped || t.Execution.Status == TaskStatusCanceled {
+		return nil
+	}
+	duration := t.Execution.Duration
+	return &duration
+}
+
+func (t *comparedTask) status() string {
+	if t.Execution == nil {
+		return ""
+	}
+	return t.Execution.Status
+}
+
+// comparedRun is a persisted RunSummary. Single package summaries have no
+// GlobalHashSummary.
+type comparedRun struct {
+	GlobalHashSummary *struct {
+		HashVersion          int                                   `json:"hashVersion"`
+		GlobalFileHashMap    map[turbopath.AnchoredUnixPath]string `json:"globalFileHashMap"`
+		RootExternalDepsHash string                                `json:"rootExternalDepsHash"`
+		GlobalCacheKey       string                                `json:"globalCacheKey"`
+		Pipeline             json.RawMessage                       `json:"pipeline"`
+	} `json:"globalHashSummary"`
+	Tasks []*comparedTask `json:"tasks"`
+
+	tasksByID map[string]*comparedTask
+}
+
+func (r *comparedRun) task(taskID string) *comparedTask {
+	if r.tasksByID == nil {
+		r.tasksByID = make(map[string]*comparedTask, len(r.Tasks))
+		for _, task := range r.Tasks {
+			r.tasksByID[task.id()] = task
+		}
+	}
+	return r.tasksByID[taskID]
+}
+
+// globalEnvVars are the global variables, which every task of a run records
+func (r *comparedRun) globalEnvVars() []string {
+	if len(r.Tasks) == 0 {
+		return nil
+	}
+	return r.Tasks[0].EnvVars.Global
+}
+
+// readComparedRun reads a run summary given its ID, or its path, which is
+// resolved from the repository root if it is relative
+func readComparedRun(repoRoot turbopath.AbsoluteSystemPath, run string) (*comparedRun, error) {
+	path := runsDir(repoRoot).UntypedJoin(fmt.Sprintf("%s.json", run))
+	if !path.FileExists() {
+		path = fs.ResolveUnknownPath(repoRoot, run)
+	}
+	contents, err := path.ReadFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run %v, expected the ID of a run from `turbo runs list` or the path to a run summary: %w", run, err)
+	}
+	summary := &comparedRun{}
+	if err := json.Unmarshal(contents, summary); err != nil {
+		return nil, fmt.Errorf("%v is not a run summary: %w", path, err)
+	}
+	return summary, nil
+}
+
+// CompareRuns compares two run summaries, each given as the ID of a run saved
+// in the repository or the path to a summary
+func CompareRuns(repoRoot turbopath.AbsoluteSystemPath, before string, after string) (*RunComparison, error) {
+	beforeRun, err := readComparedRun(repoRoot, before)
+	if err != nil {
+		return nil, err
+	}
+	afterRun, err := readComparedRun(repoRoot, after)
+	if err != nil {
+		return nil, err
+	}
+	comparison := compareRuns(beforeRun, afterRun)
+	comparison.Before = before
+	comparison.After = after
+	return comparison, nil
+}
+
+func compareRuns(before *comparedRun, after *comparedRun) *RunComparison {
+	comparison := &RunComparison{
+		GlobalChanges: compareGlobals(before, after),
+		Tasks:         []*TaskComparison{},
+	}
+
+	taskIDs := make(map[string]struct{}, len(before.Tasks))
+	for _, task := range before.Tasks {
+		taskIDs[task.id()] = struct{}{}
+	}
+	for _, task := range after.Tasks {
+		taskIDs[task.id()] = struct{}{}
+	}
+	for taskID := range taskIDs {
+		taskComparison := &TaskComparison{TaskID: taskID}
+		beforeTask, afterTask := before.task(taskID), after.task(taskID)
+		if beforeTask != nil {
+			taskComparison.HashBefore = beforeTask.Hash
+			taskComparison.StatusBefore = beforeTask.status()
+			taskComparison.DurationBefore = beforeTask.duration()
+		}
+		if afterTask != nil {
+			taskComparison.HashAfter = afterTask.Hash
+			taskComparison.StatusAfter = afterTask.status()
+			taskComparison.DurationAfter = afterTask.duration()
+		}
+		if beforeTask != nil && afterTask != nil && beforeTask.Hash != afterTask.Hash {
+			taskComparison.Changes = compareTasks(before, beforeTask, after, afterTask)
+		}
+		comparison.Tasks = append(comparison.Tasks, taskComparison)
+	}
+	sort.Slice(comparison.Tasks, func(i, j int) bool {
+		return comparison.Tasks[i].TaskID < comparison.Tasks[j].TaskID
+	})
+	return comparison
+}
+
+// compareGlobals describes the changes to the global hash, one per line
+func compareGlobals(before *comparedRun, after *comparedRun) []string {
+	changes := []string{}
+	for _, d := range compareEnvVars(before.globalEnvVars(), after.globalEnvVars()) {
+		changes = append(changes, "global env var "+d)
+	}
+	if before.GlobalHashSummary == nil || after.GlobalHashSummary == nil {
+		return changes
+	}
+	for _, d := range compareFileHashes(before.GlobalHashSummary.GlobalFileHashMap, after.GlobalHashSummary.GlobalFileHashMap) {
+		changes = append(changes, "global file "+d)
+	}
+	// Since hash version 3, root external dependencies only change the hashes
+	// of root tasks
+	rootDepsHashed := before.GlobalHashSummary.HashVersion < int(fs.HashVersion3) || after.GlobalHashSummary.HashVersion < int(fs.HashVersion3)
+	if rootDepsHashed && before.GlobalHashSummary.RootExternalDepsHash != after.GlobalHashSummary.RootExternalDepsHash {
+		changes = append(changes, "root external dependencies changed")
+	}
+	if before.GlobalHashSummary.GlobalCacheKey != after.GlobalHashSummary.GlobalCacheKey {
+		changes = append(changes, "global cache key changed")
+	}
+	if !jsonEqual(before.GlobalHashSummary.Pipeline, after.GlobalHashSummary.Pipeline) {
+		changes = append(changes, "the root pipeline changed")
+	}
+	return changes
+}
+
+// compareTasks explains why the hash of a task changed, one reason per line
+func compareTasks(before *comparedRun, beforeTask *comparedTask, after *comparedRun, afterTask *comparedTask) []string {
+	changes := compareFileHashes(beforeTask.ExpandedInputs, afterTask.ExpandedInputs)
+	for _, d := range compareEnvVars(
+		append(append([]string{}, beforeTask.EnvVars.Configured...), beforeTask.EnvVars.Inferred...),
+		append(append([]string{}, afterTask.EnvVars.Configured...), afterTask.EnvVars.Inferred...),
+	) {
+		changes = append(changes, "env var "+d)
+	}
+	if !jsonEqual(beforeTask.ResolvedTaskDefinition, afterTask.ResolvedTaskDefinition) {
+		changes = append(changes, "task definition changed")
+	}
+	for _, d := range compareHashCommands(beforeTask.HashCommands, afterTask.HashCommands) {
+		changes = append(changes, "hash command "+d)
+	}
+	for _, dependency := range afterTask.Dependencies {
+		beforeDependency, afterDependency := before.task(dependency), after.task(dependency)
+		if beforeDependency == nil {
+			changes = append(changes, fmt.Sprintf("dependency %v added", dependency))
+		} else if afterDependency != nil && beforeDependency.Hash != afterDependency.Hash {
+			changes = append(changes, fmt.Sprintf("dependency %v changed", dependency))
+		}
+	}
+	if len(changes) == 0 {
+		changes = append(changes, "hash changed")
+	}
+	return changes
+}
+
+// jsonEqual compares JSON values, ignoring whitespace
+func jsonEqual(a json.RawMessage, b json.RawMessage) bool {
+	var compactA, compactB bytes.Buffer
+	if json.Compact(&compactA, a) != nil || json.Compact(&compactB, b) != nil {
+		return bytes.Equal(a, b)
+	}
+	return bytes.Equal(compactA.Bytes(), compactB.Bytes())
+}
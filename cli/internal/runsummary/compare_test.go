@@ -0,0 +1,107 @@
+package runsummary
+
+import (
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+func TestCompareRuns(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	dir := runsDir(repoRoot)
+	assert.NilError(t, dir.MkdirAll(0755))
+
+	before := `{
+		"globalHashSummary": {"globalFileHashMap": {"tsconfig.json": "1"}, "rootExternalDepsHash": "a", "pipeline": {"build": {"outputs": []}}},
+		"tasks": [
+			{
+				"taskId": "ui#build", "hash": "ui1",
+				"expandedInputs": {"packages/ui/index.ts": "1", "packages/ui/old.ts": "1"},
+				"environmentVariables": {"configured": [], "inferred": [], "global": ["CI=1"]},
+				"execution": {"status": "built", "duration": 1000}
+			},
+			{
+				"taskId": "web#build", "hash": "web1", "dependencies": ["ui#build"],
+				"resolvedTaskDefinition": {"outputs": []},
+				"expandedInputs": {"apps/web/index.ts": "1"},
+				"environmentVariables": {"configured": ["API_URL=1"], "inferred": [], "global": ["CI=1"]},
+				"hashCommands": {"rustc --version": "1"},
+				"execution": {"status": "built", "duration": 4000}
+			},
+			{"taskId": "docs#build", "hash": "docs1", "execution": {"status": "cached", "duration": 20}}
+		]
+	}`
+	after := `{
+		"globalHashSummary": {"globalFileHashMap": {"tsconfig.json": "2"}, "rootExternalDepsHash": "a", "pipeline": {"build": {"outputs": []}}},
+		"tasks": [
+			{
+				"taskId": "ui#build", "hash": "ui2",
+				"expandedInputs": {"packages/ui/index.ts": "2", "packages/ui/new.ts": "1"},
+				"environmentVariables": {"configured": [], "inferred": [], "global": ["CI=1"]},
+				"execution": {"status": "built", "duration": 1500}
+			},
+			{
+				"taskId": "web#build", "hash": "web2", "dependencies": ["ui#build"],
+				"resolvedTaskDefinition": {"outputs": ["dist/**"]},
+				"expandedInputs": {"apps/web/index.ts": "1"},
+				"environmentVariables": {"configured": ["API_URL=2"], "inferred": [], "global": ["CI=1"]},
+				"hashCommands": {"rustc --version": "2"},
+				"execution": {"status": "failed", "duration": 3000}
+			},
+			{"taskId": "web#lint", "hash": "lint1", "execution": {"status": "skipped"}}
+		]
+	}`
+	assert.NilError(t, dir.UntypedJoin("2Qbefore.json").WriteFile([]byte(before), 0644))
+	assert.NilError(t, repoRoot.UntypedJoin("after.json").WriteFile([]byte(after), 0644))
+
+	comparison, err := CompareRuns(repoRoot, "2Qbefore", "after.json")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, comparison.GlobalChanges, []string{"global file tsconfig.json changed"})
+	assert.Equal(t, len(comparison.Tasks), 4)
+
+	docs := comparison.Tasks[0]
+	assert.Equal(t, docs.TaskID, "docs#build")
+	assert.Equal(t, docs.HashAfter, "")
+	assert.Assert(t, docs.HashChanged())
+	assert.Assert(t, docs.DurationAfter == nil)
+
+	ui := comparison.Tasks[1]
+	assert.Equal(t, ui.TaskID, "ui#build")
+	assert.DeepEqual(t, ui.Changes, []string{
+		"packages/ui/index.ts changed",
+		"packages/ui/new.ts added",
+		"packages/ui/old.ts removed",
+	})
+	assert.Equal(t, *ui.DurationBefore, int64(1000))
+	assert.Equal(t, *ui.DurationAfter, int64(1500))
+
+	web := comparison.Tasks[2]
+	assert.Equal(t, web.TaskID, "web#build")
+	assert.DeepEqual(t, web.Changes, []string{
+		"env var API_URL changed",
+		"task definition changed",
+		"hash command \"rustc --version\" changed",
+		"dependency ui#build changed",
+	})
+	assert.Equal(t, web.StatusBefore, TaskStatusBuilt)
+	assert.Equal(t, web.StatusAfter, TaskStatusFailed)
+
+	lint := comparison.Tasks[3]
+	assert.Equal(t, lint.TaskID, "web#lint")
+	assert.Equal(t, lint.HashBefore, "")
+	assert.Assert(t, lint.DurationAfter == nil)
+
+	_, err = CompareRuns(repoRoot, "2Qbefore", "missing")
+	assert.ErrorContains(t, err, "failed to read run missing")
+}
+
+func TestCompareRunsUnchanged(t *testing.T) {
+	run := &comparedRun{Tasks: []*comparedTask{{Task: "build", Hash: "a"}}}
+	comparison := compareRuns(run, run)
+	assert.DeepEqual(t, comparison.GlobalChanges, []string{})
+	assert.Equal(t, len(comparison.Tasks), 1)
+	assert.Equal(t, comparison.Tasks[0].TaskID, "build")
+	assert.Assert(t, !comparison.Tasks[0].HashChanged())
+	assert.Assert(t, comparison.Tasks[0].Changes == nil)
+}
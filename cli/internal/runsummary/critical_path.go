@@ -0,0 +1,99 @@
+package runsummary
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// CriticalPath is the chain of dependent tasks that determined how long a run
+// took. It ends with the last task to finish, and each task before it is the
+// dependency that finished last, and so held up the task after it.
+type CriticalPath struct {
+	// Duration is the sum of the durations of the tasks, in milliseconds
+	Duration int64 `json:"duration"`
+	// Tasks are in the order they ran
+	Tasks []*CriticalPathTask `json:"tasks"`
+}
+
+// CriticalPathTask is one task on a CriticalPath
+type CriticalPathTask struct {
+	TaskID string `json:"taskId"`
+	// Duration is in milliseconds
+	Duration int64 `json:"duration"`
+}
+
+// newCriticalPath finds the critical path through the tasks that finished,
+// given the direct dependencies of each task. It returns nil if no task finished.
+func newCriticalPath(tasks []*TaskSummary, dependencies map[string][]string) *CriticalPath {
+	finished := make(map[string]*TaskSummary)
+	var last *TaskSummary
+	for _, task := range tasks {
+		if task.Execution == nil || task.Execution.Status == TaskStatusSkipped || task.Execution.Status == TaskStatusCanceled {
+			continue
+		}
+		finished[task.TaskID] = task
+		if last == nil || finishedBefore(last, task) {
+			last = task
+		}
+	}
+	if last == nil {
+		return nil
+	}
+
+	path := []*TaskSummary{}
+	for task := last; task != nil; {
+		path = append(path, task)
+		var blocker *TaskSummary
+		for _, dependency := range dependencies[task.TaskID] {
+			if candidate, ok := finished[dependency]; ok && (blocker == nil || finishedBefore(blocker, candidate)) {
+				blocker = candidate
+			}
+		}
+		task = blocker
+	}
+
+	criticalPath := &CriticalPath{Tasks: make([]*CriticalPathTask, len(path))}
+	for i, task := range path {
+		criticalPath.Duration += task.Execution.Duration
+		criticalPath.Tasks[len(path)-1-i] = &CriticalPathTask{
+			TaskID:   task.TaskID,
+			Duration: task.Execution.Duration,
+		}
+	}
+	return criticalPath
+}
+
+// finishedBefore returns true if a finished before b. Tasks that finished at the
+// same time are ordered by ID, so that the path doesn't depend on the order of
+// the tasks.
+func finishedBefore(a *TaskSummary, b *TaskSummary) bool {
+	aEnd := a.Execution.StartTime + a.Execution.Duration
+	bEnd := b.Execution.StartTime + b.Execution.Duration
+	if aEnd != bEnd {
+		return aEnd < bEnd
+	}
+	return a.TaskID < b.TaskID
+}
+
+// FormatText describes the critical path for the terminal, one task per line
+func (path *CriticalPath) FormatText() []string {
+	width := 0
+	for _, task := range path.Tasks {
+		if len(task.TaskID) > width {
+			width = len(task.TaskID)
+		}
+	}
+	lines := []string{util.Sprintf("${BOLD}Critical path:${RESET} %v${GRAY} across %v tasks${RESET}", formatMilliseconds(path.Duration), len(path.Tasks))}
+	for _, task := range path.Tasks {
+		padding := strings.Repeat(" ", width-len(task.TaskID))
+		lines = append(lines, util.Sprintf("  %v%v  ${GRAY}%v${RESET}", task.TaskID, padding, formatMilliseconds(task.Duration)))
+	}
+	return lines
+}
+
+func formatMilliseconds(milliseconds int64) string {
+	return fmt.Sprint(time.Duration(milliseconds) * time.Millisecond)
+}
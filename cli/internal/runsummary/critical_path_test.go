@@ -0,0 +1,38 @@
+package runsummary
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestNewCriticalPath(t *testing.T) {
+	finished := func(taskID string, startTime int64, duration int64) *TaskSummary {
+		return &TaskSummary{TaskID: taskID, Execution: &TaskExecutionSummary{StartTime: startTime, Duration: duration}}
+	}
+	tasks := []*TaskSummary{
+		finished("ui#build", 0, 400),
+		finished("utils#build", 0, 900),
+		finished("web#build", 900, 2000),
+		finished("docs#build", 400, 300),
+		finished("web#lint", 0, 100),
+		{TaskID: "docs#test"},
+	}
+	dependencies := map[string][]string{
+		"web#build":  {"ui#build", "utils#build", "___ROOT___"},
+		"docs#build": {"ui#build"},
+		"docs#test":  {"docs#build"},
+	}
+
+	path := newCriticalPath(tasks, dependencies)
+	assert.DeepEqual(t, path, &CriticalPath{
+		Duration: 2900,
+		Tasks: []*CriticalPathTask{
+			{TaskID: "utils#build", Duration: 900},
+			{TaskID: "web#build", Duration: 2000},
+		},
+	})
+	assert.Equal(t, len(path.FormatText()), 3)
+
+	assert.Assert(t, newCriticalPath(tasks[5:], dependencies) == nil)
+}
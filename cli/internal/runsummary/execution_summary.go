@@ -0,0 +1,261 @@
+package runsummary
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// ExecutionSummary is the outcome of a `turbo run`, written with
+// --summary-format=json for tools that would otherwise read the text printed
+// at the end of the run
+type ExecutionSummary struct {
+	ExecutionStats
+	Tasks []*ExecutionTaskSummary `json:"tasks"`
+	// CriticalPath is missing if no task finished
+	CriticalPath *CriticalPath `json:"criticalPath,omitempty"`
+	// Groups are the outcome of each group of tasks of a run with --also,
+	// starting with the tasks on the command line. They are missing without
+	// --also.
+	Groups []*GroupSummary `json:"groups,omitempty"`
+	// Deduplicated counts the tasks that more than one group needed, which
+	// only ran once
+	Deduplicated int `json:"deduplicated,omitempty"`
+}
+
+// ExecutionStats are the overall counts and timing of a run. They are also
+// saved in the run summary, so that runs can be compared with `turbo runs list`.
+type ExecutionStats struct {
+	// Attempted counts the tasks that finished, whether they ran, failed or were cached
+	Attempted int `json:"attempted"`
+	// Successful includes the cached tasks, as in the text summary
+	Successful int `json:"successful"`
+	Cached     int `json:"cached"`
+	// Failed includes the tasks that timed out
+	Failed   int `json:"failed"`
+	TimedOut int `json:"timedOut"`
+	// Skipped counts the tasks that didn't run because a dependency failed.
+	// They aren't attempted.
+	Skipped int `json:"skipped"`
+	// Canceled counts the tasks that were stopped, or never started, because
+	// the run was interrupted. They aren't attempted either.
+	Canceled int `json:"canceled"`
+	// StartTime is in milliseconds since the unix epoch
+	StartTime int64 `json:"startTime"`
+	// Duration is in milliseconds
+	Duration  int64 `json:"duration"`
+	ExitCode  int   `json:"exitCode"`
+	FullTurbo bool  `json:"fullTurbo"`
+	// RemoteCache is missing if the remote cache wasn't used
+	RemoteCache *RemoteCacheStats `json:"remoteCache,omitempty"`
+}
+
+// RemoteCacheStats totals the artifacts transferred by the remote cache
+type RemoteCacheStats struct {
+	Downloads       int   `json:"downloads"`
+	BytesDownloaded int64 `json:"bytesDownloaded"`
+	Uploads         int   `json:"uploads"`
+	BytesUploaded   int64 `json:"bytesUploaded"`
+	// Duration is the time spent on transfers in milliseconds. Transfers run
+	// concurrently, so it can be longer than the run.
+	Duration int64 `json:"duration"`
+	// Failed counts the transfers that didn't get the expected response
+	Failed int `json:"failed"`
+	// CompressionRatio is the size of the artifacts before they were
+	// compressed over their size when transferred. It is missing if no
+	// artifact was read.
+	CompressionRatio float64 `json:"compressionRatio,omitempty"`
+	// Disabled is why the run stopped using the remote cache partway, such as
+	// too many failed requests in a row. It is missing if it didn't.
+	Disabled string `json:"disabled,omitempty"`
+}
+
+// RemoteCacheTransfer is a download or upload of a task's artifact
+type RemoteCacheTransfer struct {
+	// Direction is "download" or "upload"
+	Direction string `json:"direction"`
+	Bytes     int64  `json:"bytes"`
+	// UncompressedBytes is the size of the artifact's tar. It is missing, like
+	// the compression, if the artifact wasn't read.
+	UncompressedBytes int64 `json:"uncompressedBytes,omitempty"`
+	// Compression is "zstd" or "gzip"
+	Compression      string  `json:"compression,omitempty"`
+	CompressionRatio float64 `json:"compressionRatio,omitempty"`
+	// Duration is in milliseconds
+	Duration int64 `json:"duration"`
+	// StatusCode is missing if the request failed without a response
+	StatusCode int `json:"statusCode,omitempty"`
+}
+
+// ExecutionTaskSummary is the outcome of one task in an ExecutionSummary
+type ExecutionTaskSummary struct {
+	TaskID  string `json:"taskId"`
+	Task    string `json:"task"`
+	Package string `json:"package,omitempty"`
+	Hash    string `json:"hash"`
+	// Execution is missing for tasks that didn't finish
+	Execution *TaskExecutionSummary `json:"execution"`
+	// logFile is where the output of the task was written, relative to the
+	// repository root, for the excerpts of the JUnit and HTML reports
+	logFile string
+}
+
+// NewExecutionSummary creates an ExecutionSummary from the tasks of a run,
+// once their executions have been filled in. dependencies holds the direct
+// dependencies of each task, to find the critical path.
+func NewExecutionSummary(startedAt time.Time, duration time.Duration, exitCode int, tasks []*TaskSummary, dependencies map[string][]string, singlePackage bool) *ExecutionSummary {
+	summary := &ExecutionSummary{
+		ExecutionStats: ExecutionStats{
+			StartTime: startedAt.UnixMilli(),
+			Duration:  duration.Milliseconds(),
+			ExitCode:  exitCode,
+		},
+		Tasks:        make([]*ExecutionTaskSummary, len(tasks)),
+		CriticalPath: newCriticalPath(tasks, dependencies),
+	}
+	if singlePackage && summary.CriticalPath != nil {
+		for _, task := range summary.CriticalPath.Tasks {
+			task.TaskID = util.StripPackageName(task.TaskID)
+		}
+	}
+	for i, task := range tasks {
+		taskSummary := &ExecutionTaskSummary{
+			TaskID:    task.TaskID,
+			Task:      task.Task,
+			Package:   task.Package,
+			Hash:      task.Hash,
+			Execution: task.Execution,
+			logFile:   task.LogFile,
+		}
+		if singlePackage {
+			taskSummary.TaskID = util.StripPackageName(task.TaskID)
+			taskSummary.Package = ""
+		}
+		summary.Tasks[i] = taskSummary
+
+		if task.Execution == nil {
+			continue
+		}
+		if task.Execution.Status == TaskStatusSkipped {
+			summary.Skipped++
+			continue
+		}
+		if task.Execution.Status == TaskStatusCanceled {
+			summary.Canceled++
+			continue
+		}
+		summary.Attempted++
+		switch task.Execution.Status {
+		case TaskStatusBuilt:
+			summary.Successful++
+		case TaskStatusCached:
+			summary.Successful++
+			summary.Cached++
+		case TaskStatusFailed:
+			summary.Failed++
+		case TaskStatusTimedOut:
+			summary.Failed++
+			summary.TimedOut++
+		}
+	}
+	summary.FullTurbo = summary.Attempted > 0 && summary.Cached == summary.Attempted
+	return summary
+}
+
+// AddRemoteCacheTransfers attributes the transfers of the remote cache to the
+// tasks with the same hash, and totals them
+func (summary *ExecutionSummary) AddRemoteCacheTransfers(transfers []cache.RemoteTransfer) {
+	if len(transfers) == 0 {
+		return
+	}
+	stats := &RemoteCacheStats{}
+	byHash := make(map[string][]*RemoteCacheTransfer)
+	var compressedBytes, uncompressedBytes int64
+	for _, transfer := range transfers {
+		switch transfer.Direction {
+		case cache.TransferDownload:
+			stats.Downloads++
+			stats.BytesDownloaded += transfer.Bytes
+			// A missing artifact is a cache miss, not a failure
+			if transfer.StatusCode != http.StatusOK && transfer.StatusCode != http.StatusNotFound {
+				stats.Failed++
+			}
+		case cache.TransferUpload:
+			stats.Uploads++
+			stats.BytesUploaded += transfer.Bytes
+			if transfer.StatusCode != http.StatusOK {
+				stats.Failed++
+			}
+		}
+		stats.Duration += transfer.Duration.Milliseconds()
+		if transfer.UncompressedBytes > 0 && transfer.Bytes > 0 {
+			compressedBytes += transfer.Bytes
+			uncompressedBytes += transfer.UncompressedBytes
+		}
+		byHash[transfer.Hash] = append(byHash[transfer.Hash], &RemoteCacheTransfer{
+			Direction:         transfer.Direction,
+			Bytes:             transfer.Bytes,
+			UncompressedBytes: transfer.UncompressedBytes,
+			Compression:       transfer.Compression,
+			CompressionRatio:  compressionRatio(transfer.UncompressedBytes, transfer.Bytes),
+			Duration:          transfer.Duration.Milliseconds(),
+			StatusCode:        transfer.StatusCode,
+		})
+	}
+	stats.CompressionRatio = compressionRatio(uncompressedBytes, compressedBytes)
+	for _, task := range summary.Tasks {
+		if task.Execution != nil {
+			task.Execution.RemoteCache = byHash[task.Hash]
+		}
+	}
+	summary.RemoteCache = stats
+}
+
+// DisableRemoteCache records why the run stopped using the remote cache
+// partway, so that the tasks after it were only cached locally
+func (summary *ExecutionSummary) DisableRemoteCache(reason string) {
+	if summary.RemoteCache == nil {
+		summary.RemoteCache = &RemoteCacheStats{}
+	}
+	summary.RemoteCache.Disabled = reason
+}
+
+// FormatText describes the totals in one line, such as
+// "1.2 MB downloaded, 0.3 MB uploaded, 2.1s"
+func (stats *RemoteCacheStats) FormatText() string {
+	text := fmt.Sprintf("%.1f MB downloaded, %.1f MB uploaded, %v", megabytes(stats.BytesDownloaded), megabytes(stats.BytesUploaded), (time.Duration(stats.Duration) * time.Millisecond).Truncate(time.Millisecond))
+	if stats.CompressionRatio > 0 {
+		text += fmt.Sprintf(", %.1fx compression", stats.CompressionRatio)
+	}
+	if stats.Failed > 0 {
+		text += fmt.Sprintf(", %v failed", stats.Failed)
+	}
+	return text
+}
+
+// compressionRatio is rounded to two decimals, or 0 if either size is unknown
+func compressionRatio(uncompressedBytes int64, compressedBytes int64) float64 {
+	if uncompressedBytes <= 0 || compressedBytes <= 0 {
+		return 0
+	}
+	return math.Round(float64(uncompressedBytes)/float64(compressedBytes)*100) / 100
+}
+
+func megabytes(bytes int64) float64 {
+	return float64(bytes) / (1024 * 1024)
+}
+
+// FormatJSON returns the summary as indented JSON
+func (summary *ExecutionSummary) FormatJSON() ([]byte, error) {
+	bytes, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to render JSON")
+	}
+	return bytes, nil
+}
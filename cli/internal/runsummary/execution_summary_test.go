@@ -0,0 +1,92 @@
+package runsummary
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/cache"
+	"gotest.tools/v3/assert"
+)
+
+func TestNewExecutionSummary(t *testing.T) {
+	exitCode := 0
+	failedCode := 2
+	tasks := []*TaskSummary{
+		{TaskID: "web#build", Task: "build", Package: "web", Hash: "a", Execution: &TaskExecutionSummary{Status: TaskStatusCached, Duration: 12, ExitCode: &exitCode}},
+		{TaskID: "docs#build", Task: "build", Package: "docs", Hash: "b", Execution: &TaskExecutionSummary{Status: TaskStatusBuilt, Duration: 1500, ExitCode: &exitCode}},
+		{TaskID: "web#test", Task: "test", Package: "web", Hash: "c", Execution: &TaskExecutionSummary{Status: TaskStatusFailed, Duration: 2000, ExitCode: &failedCode}},
+		{TaskID: "docs#test", Task: "test", Package: "docs", Hash: "d"},
+		{TaskID: "web#e2e", Task: "e2e", Package: "web", Execution: &TaskExecutionSummary{Status: TaskStatusSkipped, FailedDependencies: []string{"web#test"}}},
+		{TaskID: "docs#dev", Task: "dev", Package: "docs", Hash: "e", Execution: &TaskExecutionSummary{Status: TaskStatusTimedOut, Duration: 600000}},
+	}
+	startedAt := time.UnixMilli(1000)
+
+	summary := NewExecutionSummary(startedAt, 3*time.Second, 2, tasks, nil, false)
+	assert.Equal(t, summary.Attempted, 4)
+	assert.Equal(t, summary.Successful, 2)
+	assert.Equal(t, summary.Cached, 1)
+	assert.Equal(t, summary.Failed, 2)
+	assert.Equal(t, summary.TimedOut, 1)
+	assert.Equal(t, summary.Skipped, 1)
+	assert.Equal(t, summary.StartTime, int64(1000))
+	assert.Equal(t, summary.Duration, int64(3000))
+	assert.Equal(t, summary.ExitCode, 2)
+	assert.Equal(t, summary.FullTurbo, false)
+	assert.Equal(t, len(summary.Tasks), 6)
+	assert.Equal(t, summary.Tasks[2].Package, "web")
+	assert.Equal(t, *summary.Tasks[2].Execution.ExitCode, 2)
+	assert.Assert(t, summary.Tasks[3].Execution == nil)
+	// Skipped tasks never started, so they aren't on the critical path
+	skipped := NewExecutionSummary(startedAt, time.Second, 1, tasks[4:5], nil, false)
+	assert.Equal(t, skipped.Attempted, 0)
+	assert.Assert(t, skipped.CriticalPath == nil)
+
+	fullTurbo := NewExecutionSummary(startedAt, time.Second, 0, tasks[:1], nil, true)
+	assert.Equal(t, fullTurbo.FullTurbo, true)
+	assert.Equal(t, fullTurbo.Tasks[0].TaskID, "build")
+	assert.Equal(t, fullTurbo.Tasks[0].Package, "")
+	assert.Equal(t, fullTurbo.CriticalPath.Tasks[0].TaskID, "build")
+
+	rendered, err := fullTurbo.FormatJSON()
+	assert.NilError(t, err)
+	assert.Assert(t, len(rendered) > 0)
+}
+
+func TestAddRemoteCacheTransfers(t *testing.T) {
+	tasks := []*TaskSummary{
+		{TaskID: "web#build", Task: "build", Package: "web", Hash: "a", Execution: &TaskExecutionSummary{Status: TaskStatusCached}},
+		{TaskID: "docs#build", Task: "build", Package: "docs", Hash: "b", Execution: &TaskExecutionSummary{Status: TaskStatusBuilt}},
+		{TaskID: "web#test", Task: "test", Package: "web", Hash: "c", Execution: &TaskExecutionSummary{Status: TaskStatusBuilt}},
+	}
+	summary := NewExecutionSummary(time.UnixMilli(1000), 3*time.Second, 0, tasks, nil, false)
+	summary.AddRemoteCacheTransfers([]cache.RemoteTransfer{
+		{Hash: "a", Direction: cache.TransferDownload, Bytes: 3 * 1024 * 1024, UncompressedBytes: 12 * 1024 * 1024, Compression: "zstd", Duration: 1200 * time.Millisecond, StatusCode: 200},
+		{Hash: "b", Direction: cache.TransferDownload, Duration: 100 * time.Millisecond, StatusCode: 404},
+		{Hash: "b", Direction: cache.TransferUpload, Bytes: 512 * 1024, UncompressedBytes: 1024 * 1024, Compression: "gzip", Duration: 700 * time.Millisecond, StatusCode: 200},
+		{Hash: "c", Direction: cache.TransferUpload, Bytes: 1024, Duration: 50 * time.Millisecond, StatusCode: 500},
+	})
+
+	assert.DeepEqual(t, summary.RemoteCache, &RemoteCacheStats{
+		Downloads:       2,
+		BytesDownloaded: 3 * 1024 * 1024,
+		Uploads:         2,
+		BytesUploaded:   512*1024 + 1024,
+		Duration:        2050,
+		Failed:          1,
+		// 13 MB before compression over 3.5 MB transferred
+		CompressionRatio: 3.71,
+	})
+	assert.Equal(t, summary.RemoteCache.FormatText(), "3.0 MB downloaded, 0.5 MB uploaded, 2.05s, 3.7x compression, 1 failed")
+	assert.DeepEqual(t, tasks[1].Execution.RemoteCache, []*RemoteCacheTransfer{
+		{Direction: cache.TransferDownload, Duration: 100, StatusCode: 404},
+		{Direction: cache.TransferUpload, Bytes: 512 * 1024, UncompressedBytes: 1024 * 1024, Compression: "gzip", CompressionRatio: 2, Duration: 700, StatusCode: 200},
+	})
+	assert.Equal(t, len(summary.Tasks[0].Execution.RemoteCache), 1)
+
+	summary = NewExecutionSummary(time.UnixMilli(1000), 3*time.Second, 0, tasks, nil, false)
+	summary.AddRemoteCacheTransfers(nil)
+	assert.Assert(t, summary.RemoteCache == nil)
+
+	summary.DisableRemoteCache("3 requests to the remote cache failed in a row")
+	assert.DeepEqual(t, summary.RemoteCache, &RemoteCacheStats{Disabled: "3 requests to the remote cache failed in a row"})
+}
@@ -0,0 +1,175 @@
+package runsummary
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// TaskExplanation tells whether a task would be restored from the cache if it
+// ran now, and what changed since the last run saved in the repository that
+// executed it or restored it
+type TaskExplanation struct {
+	TaskID     string           `json:"taskId"`
+	Hash       string           `json:"hash"`
+	CacheState cache.ItemStatus `json:"cacheState"`
+	// ExpectedCacheStatus is "hit" if the task would be restored from the
+	// cache, or else why it would run, as in the summaries of dry runs
+	ExpectedCacheStatus string `json:"expectedCacheStatus"`
+	// Reason explains ExpectedCacheStatus in a sentence
+	Reason string `json:"reason"`
+	// PreviousRun is missing if no saved run summary has the task
+	PreviousRun *PreviousTaskRun `json:"previousRun,omitempty"`
+	// GlobalChanges and Changes are what changed since PreviousRun, in the
+	// terms of `turbo compare`. They are empty if the hash didn't change.
+	GlobalChanges []string `json:"globalChanges"`
+	Changes       []string `json:"changes"`
+}
+
+// PreviousTaskRun is the last run of a task saved in the repository
+type PreviousTaskRun struct {
+	RunID     string    `json:"runId"`
+	CreatedAt time.Time `json:"createdAt"`
+	Hash      string    `json:"hash"`
+	Status    string    `json:"status"`
+}
+
+// WouldRun is whether the task would be executed rather than restored from the cache
+func (e *TaskExplanation) WouldRun() bool {
+	return e.ExpectedCacheStatus != "hit"
+}
+
+// HashChanged is whether the hash of the task changed since its previous run
+func (e *TaskExplanation) HashChanged() bool {
+	return e.PreviousRun != nil && e.PreviousRun.Hash != e.Hash
+}
+
+// savedTaskRun is a task of a run summary saved in the repository
+type savedTaskRun struct {
+	id        string
+	createdAt time.Time
+	run       *comparedRun
+	task      *comparedTask
+}
+
+// lastTaskRun returns the newest of the most recent maxRuns run summaries
+// saved in the repository that executed the task or restored it. Dry runs, and
+// runs that skipped or canceled the task, don't. Files that can't be read are
+// skipped.
+func lastTaskRun(repoRoot turbopath.AbsoluteSystemPath, taskID string, maxRuns int) (*savedTaskRun, error) {
+	files, err := listRunSummaries(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) > maxRuns {
+		files = files[:maxRuns]
+	}
+	for _, file := range files {
+		contents, err := turbopath.AbsoluteSystemPath(file).ReadFile()
+		if err != nil {
+			continue
+		}
+		run := &comparedRun{}
+		if err := json.Unmarshal(contents, run); err != nil {
+			continue
+		}
+		if task := run.task(taskID); task != nil && task.duration() != nil {
+			return &savedTaskRun{
+				id:        strings.TrimSuffix(filepath.Base(file), ".json"),
+				createdAt: createdAt(file),
+				run:       run,
+				task:      task,
+			}, nil
+		}
+	}
+	return nil, nil
+}
+
+// ExplainTask explains whether a task of the summary of a dry run would be
+// restored from the cache, by comparing it with the last run of the task in the
+// most recent maxRuns run summaries saved in the repository. The tasks that it
+// depends on must be part of the summary, so that their changes are listed.
+func (summary *RunSummary) ExplainTask(repoRoot turbopath.AbsoluteSystemPath, taskID string, singlePackage bool, maxRuns int) (*TaskExplanation, error) {
+	var task *TaskSummary
+	for _, t := range summary.Tasks {
+		if t.TaskID == taskID {
+			task = t
+			break
+		}
+	}
+	if task == nil {
+		return nil, fmt.Errorf("%v is not part of the run", taskID)
+	}
+
+	// The summary is compared in the same form as the saved ones
+	rendered, err := summary.FormatJSON(singlePackage)
+	if err != nil {
+		return nil, err
+	}
+	current := &comparedRun{}
+	if err := json.Unmarshal(rendered, current); err != nil {
+		return nil, err
+	}
+	comparedID := taskID
+	if singlePackage {
+		comparedID = util.RootTaskTaskName(taskID)
+	}
+	previous, err := lastTaskRun(repoRoot, comparedID, maxRuns)
+	if err != nil {
+		return nil, err
+	}
+
+	explanation := &TaskExplanation{
+		TaskID:              taskID,
+		Hash:                task.Hash,
+		CacheState:          task.CacheState,
+		ExpectedCacheStatus: task.ExpectedCacheStatus,
+		GlobalChanges:       []string{},
+		Changes:             []string{},
+	}
+	if previous != nil {
+		explanation.PreviousRun = &PreviousTaskRun{
+			RunID:     previous.id,
+			CreatedAt: previous.createdAt,
+			Hash:      previous.task.Hash,
+			Status:    previous.task.status(),
+		}
+		if explanation.HashChanged() {
+			explanation.GlobalChanges = compareGlobals(previous.run, current)
+			explanation.Changes = compareTasks(previous.run, previous.task, current, current.task(comparedID))
+		}
+	}
+	explanation.Reason = explanation.reason()
+	return explanation, nil
+}
+
+// reason explains the expected cache status, given the previous run
+func (e *TaskExplanation) reason() string {
+	switch e.ExpectedCacheStatus {
+	case "hit":
+		if e.CacheState.Local {
+			return "would be restored from the local cache"
+		}
+		return "would be restored from the remote cache"
+	case "cache_disabled":
+		return "would run, because caching is turned off for the task"
+	case "forced":
+		return "would run, because reading from the cache is turned off with --force"
+	}
+	switch {
+	case e.PreviousRun == nil:
+		return "would run, because its hash isn't in the cache, and no saved run summary has the task to compare it with"
+	case e.HashChanged():
+		return fmt.Sprintf("would run, because its hash changed since run %v", e.PreviousRun.RunID)
+	case e.PreviousRun.Status == TaskStatusFailed || e.PreviousRun.Status == TaskStatusTimedOut:
+		return fmt.Sprintf("would run, because it failed with the same hash in run %v, and failures aren't cached", e.PreviousRun.RunID)
+	default:
+		return fmt.Sprintf("would run, because the artifact of its hash from run %v isn't in the cache anymore", e.PreviousRun.RunID)
+	}
+}
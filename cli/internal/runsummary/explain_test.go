@@ -0,0 +1,97 @@
+package runsummary
+
+import (
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func TestExplainTask(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	dir := runsDir(repoRoot)
+	assert.NilError(t, dir.MkdirAll(0755))
+
+	older := `{
+		"globalHashSummary": {"globalFileHashMap": {"tsconfig.json": "1"}, "pipeline": {}},
+		"tasks": [
+			{"taskId": "ui#build", "hash": "ui1", "execution": {"status": "built", "duration": 1000}},
+			{
+				"taskId": "web#build", "hash": "web1", "dependencies": ["ui#build"], "resolvedTaskDefinition": null,
+				"expandedInputs": {"apps/web/index.ts": "1"},
+				"environmentVariables": {"configured": ["API_URL=1"], "inferred": [], "global": []},
+				"execution": {"status": "built", "duration": 4000}
+			},
+			{"taskId": "web#lint", "hash": "lint1", "execution": {"status": "failed", "duration": 100}}
+		]
+	}`
+	// The newer run skipped web#build, so it is compared with the older one
+	newer := `{
+		"globalHashSummary": {"globalFileHashMap": {"tsconfig.json": "1"}, "pipeline": {}},
+		"tasks": [
+			{"taskId": "ui#build", "hash": "ui1", "execution": {"status": "failed", "duration": 1000}},
+			{"taskId": "web#build", "hash": "web1", "dependencies": ["ui#build"], "execution": {"status": "skipped"}}
+		]
+	}`
+	assert.NilError(t, dir.UntypedJoin("2Qa.json").WriteFile([]byte(older), 0644))
+	assert.NilError(t, dir.UntypedJoin("2Qb.json").WriteFile([]byte(newer), 0644))
+
+	summary := NewRunSummary("1.0.0", []string{"ui", "web"}, &GlobalHashSummary{
+		GlobalFileHashMap: map[turbopath.AnchoredUnixPath]string{"tsconfig.json": "2"},
+		Pipeline:          fs.PristinePipeline{},
+	})
+	summary.Tasks = []*TaskSummary{
+		{TaskID: "ui#build", Hash: "ui2", ExpectedCacheStatus: "no_cache_entry"},
+		{
+			TaskID:              "web#build",
+			Hash:                "web2",
+			Dependencies:        []string{"ui#build"},
+			ExpandedInputs:      map[turbopath.AnchoredUnixPath]string{"apps/web/index.ts": "2"},
+			EnvVars:             TaskEnvVarSummary{Configured: []string{"API_URL=2"}},
+			ExpectedCacheStatus: "no_cache_entry",
+		},
+		{TaskID: "web#lint", Hash: "lint1", ExpectedCacheStatus: "no_cache_entry"},
+		{TaskID: "web#test", Hash: "test1", ExpectedCacheStatus: "hit", CacheState: cache.ItemStatus{Remote: true}},
+		{TaskID: "web#dev", Hash: "dev1", ExpectedCacheStatus: "cache_disabled"},
+	}
+
+	explanation, err := summary.ExplainTask(repoRoot, "web#build", false, DefaultHistoricalRuns)
+	assert.NilError(t, err)
+	assert.Assert(t, explanation.WouldRun())
+	assert.Equal(t, explanation.PreviousRun.RunID, "2Qa")
+	assert.Equal(t, explanation.PreviousRun.Hash, "web1")
+	assert.Equal(t, explanation.PreviousRun.Status, TaskStatusBuilt)
+	assert.Equal(t, explanation.Reason, "would run, because its hash changed since run 2Qa")
+	assert.DeepEqual(t, explanation.GlobalChanges, []string{"global file tsconfig.json changed"})
+	assert.DeepEqual(t, explanation.Changes, []string{
+		"apps/web/index.ts changed",
+		"env var API_URL changed",
+		"dependency ui#build changed",
+	})
+
+	testCases := []struct {
+		taskID string
+		reason string
+	}{
+		{taskID: "web#lint", reason: "would run, because it failed with the same hash in run 2Qa, and failures aren't cached"},
+		{taskID: "web#test", reason: "would be restored from the remote cache"},
+		{taskID: "web#dev", reason: "would run, because caching is turned off for the task"},
+	}
+	for _, tc := range testCases {
+		explanation, err := summary.ExplainTask(repoRoot, tc.taskID, false, DefaultHistoricalRuns)
+		assert.NilError(t, err)
+		assert.Equal(t, explanation.Reason, tc.reason, tc.taskID)
+		assert.DeepEqual(t, explanation.Changes, []string{})
+	}
+
+	// Only the newest run is searched
+	explanation, err = summary.ExplainTask(repoRoot, "web#build", false, 1)
+	assert.NilError(t, err)
+	assert.Assert(t, explanation.PreviousRun == nil)
+	assert.Equal(t, explanation.Reason, "would run, because its hash isn't in the cache, and no saved run summary has the task to compare it with")
+
+	_, err = summary.ExplainTask(repoRoot, "docs#build", false, DefaultHistoricalRuns)
+	assert.ErrorContains(t, err, "docs#build is not part of the run")
+}
@@ -0,0 +1,45 @@
+package runsummary
+
+import (
+	"strings"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// How much of the output of each failed task is repeated at the end of a run
+const (
+	_failedTaskExcerptLines = 10
+	_failedTaskExcerptBytes = 4000
+)
+
+// FormatFailedTasks lists the tasks that failed for the terminal, with why they
+// failed and the end of their output, so that the failures of a long run don't
+// have to be found in its logs. It returns nothing if no task failed.
+func (summary *ExecutionSummary) FormatFailedTasks(repoRoot turbopath.AbsoluteSystemPath) []string {
+	lines := []string{}
+	for _, task := range summary.Tasks {
+		execution := task.Execution
+		if execution == nil || (execution.Status != TaskStatusFailed && execution.Status != TaskStatusTimedOut) {
+			continue
+		}
+		if len(lines) == 0 {
+			lines = append(lines, util.Sprintf("${BOLD_RED}Failed tasks:${RESET}"))
+		}
+		lines = append(lines, util.Sprintf("  ${BOLD}%v${RESET} ${GREY}%v${RESET}", task.TaskID, failureMessage(execution)))
+		if execution.FailureBundle != "" {
+			lines = append(lines, util.Sprintf("    ${GREY}Failure bundle: %v${RESET}", execution.FailureBundle))
+		}
+		if task.logFile == "" {
+			continue
+		}
+		excerpt, err := LogExcerpt(repoRoot, task.logFile, _failedTaskExcerptLines, _failedTaskExcerptBytes)
+		if err != nil || excerpt == "" {
+			continue
+		}
+		for _, line := range strings.Split(excerpt, "\n") {
+			lines = append(lines, "    "+line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,55 @@
+package runsummary
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+func TestFormatFailedTasks(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	logFile := repoRoot.UntypedJoin("apps", "web", ".turbo", "turbo-test.log")
+	assert.NilError(t, logFile.EnsureDir())
+	output := []string{}
+	for i := 1; i <= 12; i++ {
+		output = append(output, fmt.Sprintf("line %v", i))
+	}
+	assert.NilError(t, logFile.WriteFile([]byte(strings.Join(output, "\n")+"\n"), 0644))
+
+	exitCode := 2
+	tasks := []*TaskSummary{
+		{TaskID: "web#build", Task: "build", Package: "web", Execution: &TaskExecutionSummary{Status: TaskStatusBuilt}},
+		{TaskID: "web#test", Task: "test", Package: "web", LogFile: "apps/web/.turbo/turbo-test.log", Execution: &TaskExecutionSummary{Status: TaskStatusFailed, ExitCode: &exitCode}},
+		{TaskID: "docs#test", Task: "test", Package: "docs", Execution: &TaskExecutionSummary{Status: TaskStatusTimedOut, FailureBundle: ".turbo/failures/docs-test-20230405T060708Z.zip"}},
+		{TaskID: "docs#e2e", Task: "e2e", Package: "docs", Execution: &TaskExecutionSummary{Status: TaskStatusSkipped}},
+	}
+	summary := NewExecutionSummary(time.UnixMilli(1000), time.Second, 2, tasks, nil, false)
+
+	lines := summary.FormatFailedTasks(repoRoot)
+	for i, line := range lines {
+		lines[i] = _ansiEscape.ReplaceAllString(line, "")
+	}
+	assert.DeepEqual(t, lines, []string{
+		"Failed tasks:",
+		"  web#test the task exited with code 2",
+		"    line 3",
+		"    line 4",
+		"    line 5",
+		"    line 6",
+		"    line 7",
+		"    line 8",
+		"    line 9",
+		"    line 10",
+		"    line 11",
+		"    line 12",
+		"  docs#test the task timed out",
+		"    Failure bundle: .turbo/failures/docs-test-20230405T060708Z.zip",
+	})
+
+	summary = NewExecutionSummary(time.UnixMilli(1000), time.Second, 0, tasks[:1], nil, false)
+	assert.Equal(t, len(summary.FormatFailedTasks(repoRoot)), 0)
+}
@@ -0,0 +1,217 @@
+package runsummary
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/globby"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// How much of a failed task's log and outputs is kept in its failure bundle.
+// The end of the log is kept, since that is where errors usually are, and the
+// start of each output file.
+const (
+	_failureBundleLogBytes        = 1 << 20
+	_failureBundleOutputFileBytes = 64 << 10
+	_failureBundleOutputsBytes    = 4 << 20
+)
+
+// _unsafeFileNameChars are replaced in the task IDs that bundles are named after
+var _unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// failureBundleManifest is task.json in a failure bundle
+type failureBundleManifest struct {
+	TurboVersion string                `json:"turboVersion"`
+	CreatedAt    int64                 `json:"createdAt"`
+	Task         *TaskSummary          `json:"task"`
+	Outputs      []*failureBundleEntry `json:"outputs"`
+	LogTruncated bool                  `json:"logTruncated"`
+}
+
+// failureBundleEntry describes an output file of the task, which is truncated
+// to _failureBundleOutputFileBytes in the bundle, or left out once the bundle
+// has _failureBundleOutputsBytes of outputs
+type failureBundleEntry struct {
+	Path      string `json:"path"`
+	Size      int64  `json:"size"`
+	Truncated bool   `json:"truncated,omitempty"`
+	Omitted   bool   `json:"omitted,omitempty"`
+}
+
+// WriteFailureBundles writes a zip file to .turbo/failures for every task of
+// the run that failed or timed out, with its log, its environment variables,
+// the hashes of its inputs, the version of turbo and the start of its
+// outputs, so that the failure can be attached to a bug report. The path of
+// each bundle is set on the execution of its task.
+func (summary *RunSummary) WriteFailureBundles(repoRoot turbopath.AbsoluteSystemPath, now time.Time) error {
+	for _, task := range summary.Tasks {
+		execution := task.Execution
+		if execution == nil || (execution.Status != TaskStatusFailed && execution.Status != TaskStatusTimedOut) {
+			continue
+		}
+		name := strings.Trim(_unsafeFileNameChars.ReplaceAllString(task.TaskID, "-"), "-")
+		bundlePath := filepath.Join(".turbo", "failures", fmt.Sprintf("%v-%v.zip", name, now.UTC().Format("20060102T150405Z")))
+		if err := summary.writeFailureBundle(repoRoot, bundlePath, task, now); err != nil {
+			return fmt.Errorf("failed to write the failure bundle of %v: %w", task.TaskID, err)
+		}
+		execution.FailureBundle = filepath.ToSlash(bundlePath)
+	}
+	return nil
+}
+
+func (summary *RunSummary) writeFailureBundle(repoRoot turbopath.AbsoluteSystemPath, bundlePath string, task *TaskSummary, now time.Time) error {
+	path := repoRoot.UntypedJoin(bundlePath)
+	if err := path.EnsureDir(); err != nil {
+		return err
+	}
+	f, err := path.Create()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	archive := zip.NewWriter(f)
+
+	// The global variables are only set on the tasks when the summary is
+	// formatted
+	bundledTask := *task
+	if summary.GlobalHashSummary != nil {
+		bundledTask.EnvVars.Global = summary.GlobalHashSummary.EnvVars
+	}
+	manifest := &failureBundleManifest{
+		TurboVersion: summary.TurboVersion,
+		CreatedAt:    now.UnixMilli(),
+		Task:         &bundledTask,
+		Outputs:      []*failureBundleEntry{},
+	}
+
+	if task.LogFile != "" {
+		truncated, err := addTail(archive, "log.txt", repoRoot.UntypedJoin(task.LogFile).ToString(), _failureBundleLogBytes)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		manifest.LogTruncated = truncated
+	}
+
+	inclusions := make([]string, len(task.Outputs))
+	for i, output := range task.Outputs {
+		inclusions[i] = filepath.Join(task.Dir, output)
+	}
+	exclusions := make([]string, len(task.ExcludedOutputs))
+	for i, output := range task.ExcludedOutputs {
+		exclusions[i] = filepath.Join(task.Dir, output)
+	}
+	if task.LogFile != "" {
+		exclusions = append(exclusions, task.LogFile)
+	}
+	files := []string{}
+	if len(inclusions) > 0 {
+		files, err = globby.GlobFiles(repoRoot.ToStringDuringMigration(), inclusions, exclusions)
+		if err != nil {
+			return err
+		}
+	}
+	remaining := int64(_failureBundleOutputsBytes)
+	for _, file := range files {
+		relative, err := filepath.Rel(repoRoot.ToStringDuringMigration(), file)
+		if err != nil {
+			return err
+		}
+		entry := &failureBundleEntry{Path: filepath.ToSlash(relative)}
+		manifest.Outputs = append(manifest.Outputs, entry)
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		entry.Size = info.Size()
+		if remaining <= 0 {
+			entry.Omitted = true
+			continue
+		}
+		limit := int64(_failureBundleOutputFileBytes)
+		if remaining < limit {
+			limit = remaining
+		}
+		written, err := addHead(archive, "outputs/"+entry.Path, file, limit)
+		if err != nil {
+			return err
+		}
+		entry.Truncated = written < entry.Size
+		remaining -= written
+	}
+
+	if err := addJSON(archive, "task.json", manifest); err != nil {
+		return err
+	}
+	if err := addJSON(archive, "env.json", bundledTask.EnvVars); err != nil {
+		return err
+	}
+	if err := addJSON(archive, "inputs.json", task.ExpandedInputs); err != nil {
+		return err
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func addJSON(archive *zip.Writer, name string, value interface{}) error {
+	contents, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(contents)
+	return err
+}
+
+// addHead adds up to limit bytes from the start of file to the archive, and
+// returns how many were added
+func addHead(archive *zip.Writer, name string, file string, limit int64) (int64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = f.Close() }()
+	w, err := archive.Create(name)
+	if err != nil {
+		return 0, err
+	}
+	return io.Copy(w, io.LimitReader(f, limit))
+}
+
+// addTail adds up to limit bytes from the end of file to the archive, and
+// returns whether the start of the file was left out
+func addTail(archive *zip.Writer, name string, file string, limit int64) (bool, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	truncated := info.Size() > limit
+	if truncated {
+		if _, err := f.Seek(info.Size()-limit, io.SeekStart); err != nil {
+			return false, err
+		}
+	}
+	w, err := archive.Create(name)
+	if err != nil {
+		return false, err
+	}
+	_, err = io.Copy(w, f)
+	return truncated, err
+}
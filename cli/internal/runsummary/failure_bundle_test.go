@@ -0,0 +1,96 @@
+package runsummary
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func readBundle(t *testing.T, path turbopath.AbsoluteSystemPath) map[string]string {
+	t.Helper()
+	archive, err := zip.OpenReader(path.ToString())
+	assert.NilError(t, err)
+	defer func() { _ = archive.Close() }()
+	files := make(map[string]string)
+	for _, file := range archive.File {
+		r, err := file.Open()
+		assert.NilError(t, err)
+		contents, err := io.ReadAll(r)
+		assert.NilError(t, err)
+		assert.NilError(t, r.Close())
+		files[file.Name] = string(contents)
+	}
+	return files
+}
+
+func TestWriteFailureBundles(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	writeFile := func(path string, contents string) {
+		file := repoRoot.UntypedJoin(strings.Split(path, "/")...)
+		assert.NilError(t, file.EnsureDir())
+		assert.NilError(t, file.WriteFile([]byte(contents), 0644))
+	}
+	writeFile("apps/web/.turbo/turbo-build.log", "building\nerror: oops\n")
+	writeFile("apps/web/dist/index.js", "console.log('hi')")
+	writeFile("apps/web/dist/big.js", strings.Repeat("x", _failureBundleOutputFileBytes+1))
+	writeFile("apps/web/dist/index.js.map", "{}")
+
+	exitCode := 1
+	failed := &TaskSummary{
+		TaskID:          "@acme/web#build",
+		Task:            "build",
+		Package:         "@acme/web",
+		Hash:            "abc123",
+		Dir:             "apps/web",
+		LogFile:         "apps/web/.turbo/turbo-build.log",
+		Outputs:         []string{"dist/**"},
+		ExcludedOutputs: []string{"dist/**/*.map"},
+		ExpandedInputs:  map[turbopath.AnchoredUnixPath]string{"src/index.ts": "e69de29"},
+		EnvVars:         TaskEnvVarSummary{Configured: []string{"API_URL=5d41402a"}},
+		Execution:       &TaskExecutionSummary{Status: TaskStatusFailed, ExitCode: &exitCode},
+	}
+	built := &TaskSummary{TaskID: "ui#build", Execution: &TaskExecutionSummary{Status: TaskStatusBuilt}}
+	summary := &RunSummary{
+		TurboVersion:      "1.9.0",
+		GlobalHashSummary: &GlobalHashSummary{EnvVars: []string{"CI=hash"}},
+		Tasks:             []*TaskSummary{failed, built},
+	}
+
+	now := time.Date(2023, 4, 5, 6, 7, 8, 0, time.UTC)
+	assert.NilError(t, summary.WriteFailureBundles(repoRoot, now))
+	assert.Equal(t, failed.Execution.FailureBundle, ".turbo/failures/acme-web-build-20230405T060708Z.zip")
+	assert.Equal(t, built.Execution.FailureBundle, "")
+
+	files := readBundle(t, repoRoot.UntypedJoin(".turbo", "failures", "acme-web-build-20230405T060708Z.zip"))
+	assert.Equal(t, files["log.txt"], "building\nerror: oops\n")
+	assert.Equal(t, files["outputs/apps/web/dist/index.js"], "console.log('hi')")
+	assert.Equal(t, len(files["outputs/apps/web/dist/big.js"]), _failureBundleOutputFileBytes)
+	_, ok := files["outputs/apps/web/dist/index.js.map"]
+	assert.Assert(t, !ok, "excluded outputs aren't bundled")
+
+	var manifest struct {
+		TurboVersion string                `json:"turboVersion"`
+		Task         *TaskSummary          `json:"task"`
+		Outputs      []*failureBundleEntry `json:"outputs"`
+	}
+	assert.NilError(t, json.Unmarshal([]byte(files["task.json"]), &manifest))
+	assert.Equal(t, manifest.TurboVersion, "1.9.0")
+	assert.Equal(t, manifest.Task.Hash, "abc123")
+	assert.DeepEqual(t, manifest.Outputs, []*failureBundleEntry{
+		{Path: "apps/web/dist/big.js", Size: _failureBundleOutputFileBytes + 1, Truncated: true},
+		{Path: "apps/web/dist/index.js", Size: 17},
+	})
+
+	var envVars TaskEnvVarSummary
+	assert.NilError(t, json.Unmarshal([]byte(files["env.json"]), &envVars))
+	assert.DeepEqual(t, envVars.Configured, []string{"API_URL=5d41402a"})
+	assert.DeepEqual(t, envVars.Global, []string{"CI=hash"})
+	assert.Equal(t, files["inputs.json"], "{\n  \"src/index.ts\": \"e69de29\"\n}")
+}
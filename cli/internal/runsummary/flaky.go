@@ -0,0 +1,210 @@
+package runsummary
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"sort"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// FlakyTask describes a task that has both failed and succeeded for the same hash.
+// Since the hash covers all of a task's inputs, differing outcomes for the same hash
+// mean that something other than the inputs, such as timing, decided the result.
+type FlakyTask struct {
+	TaskID string `json:"taskId"`
+	// Executions is how many times the task ran, rather than being restored from cache
+	Executions int `json:"executions"`
+	// FlakyFailures is how many of those executions failed for a hash that also succeeded
+	FlakyFailures int      `json:"flakyFailures"`
+	FlakyHashes   []string `json:"flakyHashes"`
+	FlakeRate     float64  `json:"flakeRate"`
+}
+
+// taskOutcome is the result of a single execution of a task
+type taskOutcome struct {
+	taskID string
+	hash   string
+	status string
+	// duration is in milliseconds
+	duration int64
+}
+
+// historicalTask holds the fields of a persisted TaskSummary that are needed to
+// analyze outcomes. Single package summaries only have Task, not TaskID.
+type historicalTask struct {
+	TaskID    string                `json:"taskId"`
+	Task      string                `json:"task"`
+	Hash      string                `json:"hash"`
+	Execution *TaskExecutionSummary `json:"execution"`
+}
+
+type historicalRun struct {
+	Tasks []historicalTask `json:"tasks"`
+	// These are missing from summaries saved by older versions of turbo, and
+	// from dry runs
+	ExitCode    *ExitCodeSummary   `json:"exitCode"`
+	Execution   *ExecutionStats    `json:"execution"`
+	Environment *RecordEnvironment `json:"environment"`
+}
+
+func runsDir(repoRoot turbopath.AbsoluteSystemPath) turbopath.AbsoluteSystemPath {
+	return repoRoot.UntypedJoin(filepath.Join(".turbo", "runs"))
+}
+
+// listRunSummaries returns the paths of the run summaries saved in the
+// repository, newest first. It returns none if no summary was saved.
+func listRunSummaries(repoRoot turbopath.AbsoluteSystemPath) ([]string, error) {
+	dir := runsDir(repoRoot)
+	if !dir.DirExists() {
+		return nil, nil
+	}
+	files, err := filepath.Glob(filepath.Join(dir.ToString(), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	// Summaries are named after their KSUID, which sorts by creation time
+	sort.Sort(sort.Reverse(sort.StringSlice(files)))
+	return files, nil
+}
+
+// DefaultHistoricalRuns is how many of the most recent run summaries are
+// analyzed for flakiness by default
+const DefaultHistoricalRuns = 100
+
+// loadOutcomes reads the outcomes of every task execution recorded in the most
+// recent maxRuns run summaries saved in the repository. Files that can't be read
+// are skipped.
+func loadOutcomes(repoRoot turbopath.AbsoluteSystemPath, maxRuns int) ([]taskOutcome, error) {
+	files, err := listRunSummaries(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) > maxRuns {
+		files = files[:maxRuns]
+	}
+	var outcomes []taskOutcome
+	// Outcomes are in the order the runs were saved
+	for i := len(files) - 1; i >= 0; i-- {
+		contents, err := turbopath.AbsoluteSystemPath(files[i]).ReadFile()
+		if err != nil {
+			continue
+		}
+		run := &historicalRun{}
+		if err := json.Unmarshal(contents, run); err != nil {
+			continue
+		}
+		for _, task := range run.Tasks {
+			taskID := task.TaskID
+			if taskID == "" {
+				taskID = task.Task
+			}
+			if task.Execution != nil {
+				outcomes = append(outcomes, taskOutcome{taskID: taskID, hash: task.Hash, status: task.Execution.Status, duration: task.Execution.Duration})
+			}
+		}
+	}
+	return outcomes, nil
+}
+
+// analyzeFlakiness finds the tasks that have both failed and succeeded for the
+// same hash. Cache hits are ignored, since they don't say anything new about the
+// outcome of a hash. The result is sorted by descending flake rate.
+func analyzeFlakiness(outcomes []taskOutcome) []*FlakyTask {
+	type hashOutcomes struct {
+		built  int
+		failed int
+	}
+	executions := make(map[string]int)
+	byHash := make(map[string]map[string]*hashOutcomes)
+	for _, outcome := range outcomes {
+		if outcome.status != TaskStatusBuilt && outcome.status != TaskStatusFailed && outcome.status != TaskStatusTimedOut {
+			continue
+		}
+		executions[outcome.taskID]++
+		hashes, ok := byHash[outcome.taskID]
+		if !ok {
+			hashes = make(map[string]*hashOutcomes)
+			byHash[outcome.taskID] = hashes
+		}
+		counts, ok := hashes[outcome.hash]
+		if !ok {
+			counts = &hashOutcomes{}
+			hashes[outcome.hash] = counts
+		}
+		if outcome.status == TaskStatusBuilt {
+			counts.built++
+		} else {
+			counts.failed++
+		}
+	}
+
+	flakyTasks := []*FlakyTask{}
+	for taskID, hashes := range byHash {
+		flakyTask := &FlakyTask{
+			TaskID:      taskID,
+			Executions:  executions[taskID],
+			FlakyHashes: []string{},
+		}
+		for hash, counts := range hashes {
+			if counts.built > 0 && counts.failed > 0 {
+				flakyTask.FlakyHashes = append(flakyTask.FlakyHashes, hash)
+				flakyTask.FlakyFailures += counts.failed
+			}
+		}
+		if len(flakyTask.FlakyHashes) == 0 {
+			continue
+		}
+		sort.Strings(flakyTask.FlakyHashes)
+		flakyTask.FlakeRate = float64(flakyTask.FlakyFailures) / float64(flakyTask.Executions)
+		flakyTasks = append(flakyTasks, flakyTask)
+	}
+	sort.Slice(flakyTasks, func(i, j int) bool {
+		if flakyTasks[i].FlakeRate != flakyTasks[j].FlakeRate {
+			return flakyTasks[i].FlakeRate > flakyTasks[j].FlakeRate
+		}
+		return flakyTasks[i].TaskID < flakyTasks[j].TaskID
+	})
+	return flakyTasks
+}
+
+// AnalyzeFlakiness returns the flaky tasks in the most recent maxRuns run
+// summaries saved in the repository, sorted by descending flake rate.
+func AnalyzeFlakiness(repoRoot turbopath.AbsoluteSystemPath, maxRuns int) ([]*FlakyTask, error) {
+	outcomes, err := loadOutcomes(repoRoot, maxRuns)
+	if err != nil {
+		return nil, err
+	}
+	return analyzeFlakiness(outcomes), nil
+}
+
+// AnnotateFlakiness sets FlakeRate on the tasks in this run, based on the most recent
+// run summaries previously saved in the repository along with the outcomes of this
+// run. It returns the flaky tasks that were part of this run.
+func (summary *RunSummary) AnnotateFlakiness(repoRoot turbopath.AbsoluteSystemPath, singlePackage bool) ([]*FlakyTask, error) {
+	outcomes, err := loadOutcomes(repoRoot, DefaultHistoricalRuns)
+	if err != nil {
+		return nil, err
+	}
+	taskIDs := make(map[string]*TaskSummary, len(summary.Tasks))
+	for _, task := range summary.Tasks {
+		taskID := task.TaskID
+		if singlePackage {
+			// Match the key used for the persisted single package summaries
+			taskID = task.Task
+		}
+		taskIDs[taskID] = task
+		if task.Execution != nil {
+			outcomes = append(outcomes, taskOutcome{taskID: taskID, hash: task.Hash, status: task.Execution.Status})
+		}
+	}
+
+	inThisRun := []*FlakyTask{}
+	for _, flakyTask := range analyzeFlakiness(outcomes) {
+		if task, ok := taskIDs[flakyTask.TaskID]; ok {
+			task.FlakeRate = flakyTask.FlakeRate
+			inThisRun = append(inThisRun, flakyTask)
+		}
+	}
+	return inThisRun, nil
+}
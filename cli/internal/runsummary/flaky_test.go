@@ -0,0 +1,99 @@
+package runsummary
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+func TestAnalyzeFlakiness(t *testing.T) {
+	testCases := []struct {
+		name     string
+		outcomes []taskOutcome
+		want     []*FlakyTask
+	}{
+		{
+			name: "consistent outcomes are not flaky",
+			outcomes: []taskOutcome{
+				{taskID: "web#test", hash: "a", status: TaskStatusFailed},
+				{taskID: "web#test", hash: "a", status: TaskStatusFailed},
+				{taskID: "web#test", hash: "b", status: TaskStatusBuilt},
+			},
+			want: []*FlakyTask{},
+		},
+		{
+			name: "cache hits don't count as executions",
+			outcomes: []taskOutcome{
+				{taskID: "web#test", hash: "a", status: TaskStatusFailed},
+				{taskID: "web#test", hash: "a", status: TaskStatusCached},
+			},
+			want: []*FlakyTask{},
+		},
+		{
+			name: "failed then passed for the same hash",
+			outcomes: []taskOutcome{
+				{taskID: "web#test", hash: "a", status: TaskStatusFailed},
+				{taskID: "web#test", hash: "a", status: TaskStatusBuilt},
+				{taskID: "web#test", hash: "b", status: TaskStatusBuilt},
+				{taskID: "web#test", hash: "c", status: TaskStatusFailed},
+				{taskID: "docs#test", hash: "d", status: TaskStatusBuilt},
+				{taskID: "docs#test", hash: "d", status: TaskStatusFailed},
+			},
+			want: []*FlakyTask{
+				{TaskID: "docs#test", Executions: 2, FlakyFailures: 1, FlakyHashes: []string{"d"}, FlakeRate: 0.5},
+				{TaskID: "web#test", Executions: 4, FlakyFailures: 1, FlakyHashes: []string{"a"}, FlakeRate: 0.25},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.DeepEqual(t, analyzeFlakiness(tc.outcomes), tc.want)
+		})
+	}
+}
+
+func TestAnnotateFlakiness(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+
+	// No history yet
+	summary := NewRunSummary("1.0.0", []string{"web"}, &GlobalHashSummary{})
+	summary.Tasks = []*TaskSummary{
+		{TaskID: "web#test", Task: "test", Hash: "a", Execution: &TaskExecutionSummary{Status: TaskStatusFailed}},
+	}
+	flakyTasks, err := summary.AnnotateFlakiness(repoRoot, false)
+	assert.NilError(t, err)
+	assert.Equal(t, len(flakyTasks), 0)
+	assert.NilError(t, summary.Save(repoRoot, false))
+
+	summary = NewRunSummary("1.0.0", []string{"web"}, &GlobalHashSummary{})
+	summary.Tasks = []*TaskSummary{
+		{TaskID: "web#test", Task: "test", Hash: "a", Execution: &TaskExecutionSummary{Status: TaskStatusBuilt}},
+		{TaskID: "web#build", Task: "build", Hash: "b", Execution: &TaskExecutionSummary{Status: TaskStatusBuilt}},
+	}
+	flakyTasks, err = summary.AnnotateFlakiness(repoRoot, false)
+	assert.NilError(t, err)
+	assert.Equal(t, len(flakyTasks), 1)
+	assert.Equal(t, flakyTasks[0].TaskID, "web#test")
+	assert.Equal(t, summary.Tasks[0].FlakeRate, 0.5)
+	assert.Equal(t, summary.Tasks[1].FlakeRate, 0.0)
+}
+
+func TestLoadOutcomesReadsRecentRuns(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	dir := runsDir(repoRoot)
+	assert.NilError(t, dir.MkdirAll(0755))
+	for i, hash := range []string{"a", "b", "c"} {
+		contents := fmt.Sprintf(`{"tasks": [{"taskId": "web#test", "hash": "%v", "execution": {"status": "built"}}]}`, hash)
+		assert.NilError(t, dir.UntypedJoin(fmt.Sprintf("%v.json", i)).WriteFile([]byte(contents), 0644))
+	}
+
+	outcomes, err := loadOutcomes(repoRoot, 2)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, outcomes, []taskOutcome{
+		{taskID: "web#test", hash: "b", status: TaskStatusBuilt},
+		{taskID: "web#test", hash: "c", status: TaskStatusBuilt},
+	}, cmp.AllowUnexported(taskOutcome{}))
+}
@@ -0,0 +1,186 @@
+package runsummary
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+const (
+	// GitHub only shows the first 10 error annotations of each step
+	_githubMaxAnnotationsPerTask = 10
+	_githubFallbackExcerptLines  = 10
+	_githubFallbackExcerptBytes  = 2000
+)
+
+// The error formats that are annotated at their file and line
+var (
+	// tsc: src/index.ts(12,5): error TS2322: Type 'string' is not assignable...
+	_tscError = regexp.MustCompile(`^(\S[^(]*)\((\d+),(\d+)\): error (.+)$`)
+	// gcc, clang, go, tsc --pretty and eslint --format=unix:
+	// src/index.ts:12:5 - error TS2322: ..., main.go:3:2: undefined: x
+	_fileLineColumnError = regexp.MustCompile(`^(\S+\.[A-Za-z0-9]+):(\d+):(\d+)(?::| -) (.+)$`)
+	// rustc reports the location on a line after the error:
+	// error[E0308]: mismatched types
+	//   --> src/main.rs:2:5
+	_rustError    = regexp.MustCompile(`^error(\[\w+\])?: (.+)$`)
+	_rustLocation = regexp.MustCompile(`^\s*--> (\S+):(\d+):(\d+)$`)
+	// jest and vitest name the failed test, then point at it in a stack trace:
+	// ● math › adds numbers
+	//     at Object.<anonymous> (src/math.test.ts:4:17)
+	_testFailure    = regexp.MustCompile(`^\s*(?:●|FAIL) (.+)$`)
+	_testStackFrame = regexp.MustCompile(`^\s*(?:at (?:.* \()?|❯ )([^()\s]+):(\d+):(\d+)\)?$`)
+)
+
+// Annotation is an error shown by GitHub Actions on the summary of a workflow
+// run, and next to the line of the file it points to
+type Annotation struct {
+	TaskID string
+	// File is relative to the repository root, and empty if the error doesn't
+	// point at a file
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+// String renders the annotation as a workflow command
+func (a *Annotation) String() string {
+	properties := []string{}
+	if a.File != "" {
+		properties = append(properties,
+			fmt.Sprintf("file=%v", escapeGitHubProperty(a.File)),
+			fmt.Sprintf("line=%d", a.Line),
+			fmt.Sprintf("col=%d", a.Column),
+		)
+	}
+	properties = append(properties, fmt.Sprintf("title=%v", escapeGitHubProperty(fmt.Sprintf("%v failed", a.TaskID))))
+	return fmt.Sprintf("::error %v::%v", strings.Join(properties, ","), escapeGitHubData(a.Message))
+}
+
+// escapeGitHubData escapes the message of a workflow command
+// https://github.com/actions/toolkit/blob/main/packages/core/src/command.ts
+func escapeGitHubData(s string) string {
+	s = strings.ReplaceAll(s, "%", "%25")
+	s = strings.ReplaceAll(s, "\r", "%0D")
+	return strings.ReplaceAll(s, "\n", "%0A")
+}
+
+func escapeGitHubProperty(s string) string {
+	s = escapeGitHubData(s)
+	s = strings.ReplaceAll(s, ":", "%3A")
+	return strings.ReplaceAll(s, ",", "%2C")
+}
+
+// GitHubAnnotations describes the failed tasks of a run as GitHub Actions
+// annotations. Errors in common compiler and test runner formats are annotated
+// at their file and line. A failed task without any is annotated with the end
+// of its log.
+func (summary *RunSummary) GitHubAnnotations(repoRoot turbopath.AbsoluteSystemPath, isSinglePackage bool) []*Annotation {
+	annotations := []*Annotation{}
+	for _, task := range summary.Tasks {
+		if task.Execution == nil || (task.Execution.Status != TaskStatusFailed && task.Execution.Status != TaskStatusTimedOut) {
+			continue
+		}
+		taskID := markdownTaskName(task, isSinglePackage)
+		var taskAnnotations []*Annotation
+		if task.LogFile != "" {
+			taskAnnotations, _ = parseLogAnnotations(repoRoot, task.LogFile, turbopath.AnchoredSystemPath(task.Dir))
+		}
+		if len(taskAnnotations) == 0 {
+			message := task.Execution.Error
+			if task.LogFile != "" {
+				if excerpt, err := LogExcerpt(repoRoot, task.LogFile, _githubFallbackExcerptLines, _githubFallbackExcerptBytes); err == nil && excerpt != "" {
+					message = excerpt
+				}
+			}
+			if message == "" {
+				message = "(no log output)"
+			}
+			taskAnnotations = []*Annotation{{Message: message}}
+		}
+		for _, annotation := range taskAnnotations {
+			annotation.TaskID = taskID
+		}
+		annotations = append(annotations, taskAnnotations...)
+	}
+	return annotations
+}
+
+// parseLogAnnotations finds the errors in a task's log that point at a file in
+// the repository. Paths in the log are relative to the package directory.
+func parseLogAnnotations(repoRoot turbopath.AbsoluteSystemPath, logFile string, packageDir turbopath.AnchoredSystemPath) ([]*Annotation, error) {
+	f, err := os.Open(repoRoot.UntypedJoin(logFile).ToString())
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	annotations := []*Annotation{}
+	seen := make(util.Set)
+	add := func(file string, line string, column string, message string) {
+		repoFile, ok := repoRelativeFile(repoRoot, packageDir, file)
+		if !ok || len(annotations) == _githubMaxAnnotationsPerTask {
+			return
+		}
+		annotation := &Annotation{File: repoFile, Message: strings.TrimSpace(message)}
+		annotation.Line, _ = strconv.Atoi(line)
+		annotation.Column, _ = strconv.Atoi(column)
+		key := fmt.Sprintf("%v:%d:%d:%v", annotation.File, annotation.Line, annotation.Column, annotation.Message)
+		if !seen.Includes(key) {
+			seen.Add(key)
+			annotations = append(annotations, annotation)
+		}
+	}
+
+	// The errors whose location is on a later line
+	pendingRustError, pendingTestFailure := "", ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := _ansiEscape.ReplaceAllString(scanner.Text(), "")
+		if match := _tscError.FindStringSubmatch(line); match != nil {
+			add(match[1], match[2], match[3], match[4])
+		} else if match := _fileLineColumnError.FindStringSubmatch(line); match != nil {
+			add(match[1], match[2], match[3], match[4])
+		} else if match := _rustError.FindStringSubmatch(line); match != nil {
+			pendingRustError = match[2]
+		} else if match := _rustLocation.FindStringSubmatch(line); match != nil && pendingRustError != "" {
+			add(match[1], match[2], match[3], pendingRustError)
+			pendingRustError = ""
+		} else if match := _testFailure.FindStringSubmatch(line); match != nil {
+			pendingTestFailure = match[1]
+		} else if match := _testStackFrame.FindStringSubmatch(line); match != nil && pendingTestFailure != "" {
+			// The first frame outside of node_modules is the test itself
+			if !strings.Contains(match[1], "node_modules") {
+				add(match[1], match[2], match[3], pendingTestFailure)
+				pendingTestFailure = ""
+			}
+		}
+	}
+	return annotations, scanner.Err()
+}
+
+// repoRelativeFile resolves a path from a log to a file in the repository,
+// relative to the repository root. Paths that don't lead to a file in the
+// repository are most likely not paths at all.
+func repoRelativeFile(repoRoot turbopath.AbsoluteSystemPath, packageDir turbopath.AnchoredSystemPath, file string) (string, bool) {
+	file = strings.TrimPrefix(file, "file://")
+	var absoluteFile turbopath.AbsoluteSystemPath
+	if filepath.IsAbs(file) {
+		absoluteFile = turbopath.AbsoluteSystemPath(filepath.Clean(file))
+	} else {
+		absoluteFile = packageDir.RestoreAnchor(repoRoot).UntypedJoin(file)
+	}
+	relative, err := absoluteFile.RelativeTo(repoRoot)
+	if err != nil || strings.HasPrefix(relative.ToString(), "..") || !absoluteFile.FileExists() {
+		return "", false
+	}
+	return relative.ToUnixPath().ToString(), true
+}
@@ -0,0 +1,69 @@
+package runsummary
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+func TestFormatGitHubAnnotations(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	for _, file := range []string{"apps/web/src/index.ts", "apps/web/src/app.test.ts", "crates/cli/src/main.rs"} {
+		path := repoRoot.UntypedJoin(file)
+		assert.NilError(t, path.EnsureDir())
+		assert.NilError(t, path.WriteFile([]byte{}, 0644))
+	}
+
+	webLog := strings.Join([]string{
+		"> tsc && jest",
+		"src/index.ts(12,5): error TS2322: Type 'string' is not assignable to type 'number'.",
+		"\x1b[31msrc/index.ts:14:1 - error TS1005: ',' expected.\x1b[0m",
+		"src/index.ts(12,5): error TS2322: Type 'string' is not assignable to type 'number'.",
+		"src/missing.ts(1,1): error TS2307: Cannot find module 'x'.",
+		"  ● math › adds numbers, 50% of the time",
+		"    at expect (node_modules/expect/build/index.js:10:3)",
+		"    at Object.<anonymous> (src/app.test.ts:4:17)",
+		"",
+	}, "\n")
+	webLogFile := repoRoot.UntypedJoin("apps", "web", ".turbo", "turbo-test.log")
+	assert.NilError(t, webLogFile.EnsureDir())
+	assert.NilError(t, webLogFile.WriteFile([]byte(webLog), 0644))
+
+	cliLog := "error[E0308]: mismatched types\n --> src/main.rs:2:5\n"
+	cliLogFile := repoRoot.UntypedJoin("crates", "cli", ".turbo", "turbo-build.log")
+	assert.NilError(t, cliLogFile.EnsureDir())
+	assert.NilError(t, cliLogFile.WriteFile([]byte(cliLog), 0644))
+
+	docsLogFile := repoRoot.UntypedJoin("apps", "docs", ".turbo", "turbo-build.log")
+	assert.NilError(t, docsLogFile.EnsureDir())
+	assert.NilError(t, docsLogFile.WriteFile([]byte("Killed\n"), 0644))
+
+	summary := &RunSummary{
+		Tasks: []*TaskSummary{
+			{TaskID: "web#build", Execution: &TaskExecutionSummary{Status: TaskStatusBuilt}},
+			{TaskID: "web#test", Dir: "apps/web", LogFile: "apps/web/.turbo/turbo-test.log", Execution: &TaskExecutionSummary{Status: TaskStatusFailed}},
+			{TaskID: "cli#build", Dir: "crates/cli", LogFile: "crates/cli/.turbo/turbo-build.log", Execution: &TaskExecutionSummary{Status: TaskStatusFailed}},
+			{TaskID: "docs#build", Dir: "apps/docs", LogFile: "apps/docs/.turbo/turbo-build.log", Execution: &TaskExecutionSummary{Status: TaskStatusTimedOut}},
+			{TaskID: "docs#lint", Dir: "apps/docs", Execution: &TaskExecutionSummary{Status: TaskStatusFailed, Error: "command (apps/docs) npm run lint exited (1)"}},
+		},
+	}
+
+	got := []string{}
+	for _, annotation := range summary.GitHubAnnotations(repoRoot, false) {
+		got = append(got, annotation.String())
+	}
+	assert.DeepEqual(t, got, []string{
+		"::error file=apps/web/src/index.ts,line=12,col=5,title=web#test failed::TS2322: Type 'string' is not assignable to type 'number'.",
+		"::error file=apps/web/src/index.ts,line=14,col=1,title=web#test failed::error TS1005: ',' expected.",
+		"::error file=apps/web/src/app.test.ts,line=4,col=17,title=web#test failed::math › adds numbers, 50%25 of the time",
+		"::error file=crates/cli/src/main.rs,line=2,col=5,title=cli#build failed::mismatched types",
+		"::error title=docs#build failed::Killed",
+		"::error title=docs#lint failed::command (apps/docs) npm run lint exited (1)",
+	})
+}
+
+func TestEscapeGitHubProperty(t *testing.T) {
+	assert.Equal(t, escapeGitHubProperty("web#test: 100%, done\n"), "web#test%3A 100%25%2C done%0A")
+}
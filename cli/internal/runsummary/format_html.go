@@ -0,0 +1,204 @@
+package runsummary
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+const (
+	_htmlExcerptLines = 500
+	_htmlExcerptBytes = 100000
+)
+
+// htmlReport is what the HTML report of a run shows
+type htmlReport struct {
+	Stats    ExecutionStats
+	Duration string
+	// Ticks mark the timeline every tenth of the run
+	Ticks []string
+	Tasks []*htmlTask
+}
+
+// htmlTask is a row of the timeline of the HTML report
+type htmlTask struct {
+	TaskID string
+	// Status is the status of the task, or "unfinished" if it didn't finish
+	Status   string
+	Detail   string
+	Duration string
+	// Bar places the task on the timeline, from when it started relative to
+	// the run
+	Bar      template.CSS
+	Critical bool
+	Log      string
+	start    int64
+}
+
+// FormatHTML renders the outcome of a run as a standalone HTML page, with a
+// timeline of when each task ran colored by its status, and the end of each
+// task's log in an expandable section. The page doesn't load anything from
+// the network, so that it can be kept as a CI artifact.
+func (summary *ExecutionSummary) FormatHTML(repoRoot turbopath.AbsoluteSystemPath) ([]byte, error) {
+	// The timeline spans the run, or the tasks if they ran longer than the run
+	// was measured to
+	span := summary.Duration
+	for _, task := range summary.Tasks {
+		if execution := task.Execution; execution != nil && execution.StartTime > 0 {
+			if end := execution.StartTime - summary.StartTime + execution.Duration; end > span {
+				span = end
+			}
+		}
+	}
+	critical := make(map[string]bool)
+	if summary.CriticalPath != nil {
+		for _, task := range summary.CriticalPath.Tasks {
+			critical[task.TaskID] = true
+		}
+	}
+
+	report := &htmlReport{
+		Stats:    summary.ExecutionStats,
+		Duration: htmlDuration(summary.Duration),
+	}
+	for i := 0; i <= 10; i++ {
+		report.Ticks = append(report.Ticks, htmlDuration(span*int64(i)/10))
+	}
+	for _, task := range summary.Tasks {
+		row := &htmlTask{
+			TaskID:   task.TaskID,
+			Status:   "unfinished",
+			Detail:   "the task didn't finish",
+			Critical: critical[task.TaskID],
+		}
+		report.Tasks = append(report.Tasks, row)
+		execution := task.Execution
+		if execution == nil {
+			continue
+		}
+		row.Status = execution.Status
+		row.Duration = htmlDuration(execution.Duration)
+		switch execution.Status {
+		case TaskStatusBuilt:
+			row.Detail = fmt.Sprintf("ran (%v)", task.Hash)
+		case TaskStatusCached:
+			row.Detail = fmt.Sprintf("restored from the cache (%v)", task.Hash)
+		case TaskStatusFailed, TaskStatusTimedOut:
+			row.Detail = failureMessage(execution)
+		case TaskStatusSkipped:
+			row.Detail = fmt.Sprintf("a dependency failed: %v", strings.Join(execution.FailedDependencies, ", "))
+		case TaskStatusCanceled:
+			row.Detail = "the run was canceled"
+		}
+		if execution.StartTime > 0 && span > 0 {
+			row.start = execution.StartTime - summary.StartTime
+			left := 100 * float64(row.start) / float64(span)
+			width := 100 * float64(execution.Duration) / float64(span)
+			row.Bar = template.CSS(fmt.Sprintf("left: %.2f%%; width: %.2f%%", left, width))
+		}
+		if task.logFile != "" {
+			if excerpt, err := LogExcerpt(repoRoot, task.logFile, _htmlExcerptLines, _htmlExcerptBytes); err == nil {
+				row.Log = excerpt
+			}
+		}
+	}
+	// Tasks are in the order they started, and the tasks that never started last
+	sort.SliceStable(report.Tasks, func(i, j int) bool {
+		a, b := report.Tasks[i], report.Tasks[j]
+		if (a.Bar == "") != (b.Bar == "") {
+			return b.Bar == ""
+		}
+		return a.start < b.start
+	})
+
+	var out bytes.Buffer
+	if err := _htmlReportTemplate.Execute(&out, report); err != nil {
+		return nil, errors.Wrap(err, "failed to render the HTML report")
+	}
+	return out.Bytes(), nil
+}
+
+// htmlDuration formats a duration in milliseconds
+func htmlDuration(milliseconds int64) string {
+	return fmt.Sprint(time.Duration(milliseconds) * time.Millisecond)
+}
+
+var _htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>turbo run</title>
+  <style>
+    body { margin: 0; padding: 20px; font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; font-size: 13px; }
+    h1 { margin: 0 0 12px; font-size: 20px; }
+    .stats span { display: inline-block; margin: 0 16px 16px 0; }
+    .stats b { font-size: 16px; }
+    .legend span { display: inline-block; margin: 0 12px 12px 0; }
+    .legend i { display: inline-block; width: 10px; height: 10px; margin-right: 4px; border-radius: 2px; }
+    .task { display: flex; align-items: center; border-bottom: 1px solid #eee; }
+    .name { flex: 0 0 280px; overflow: hidden; text-overflow: ellipsis; white-space: nowrap; padding: 4px 8px 4px 0; }
+    .critical .name { font-weight: bold; }
+    .lane { flex: 1; position: relative; height: 20px; }
+    .bar { position: absolute; top: 3px; height: 14px; min-width: 2px; border-radius: 2px; }
+    .critical .bar { outline: 2px solid #1c7ed6; }
+    .duration { flex: 0 0 90px; text-align: right; color: #666; }
+    .ticks { display: flex; margin-left: 280px; margin-right: 90px; justify-content: space-between; color: #999; font-size: 11px; }
+    details { margin: 0 0 0 280px; }
+    summary { cursor: pointer; color: #666; padding: 2px 0; }
+    pre { margin: 4px 0 8px; padding: 8px; max-height: 400px; overflow: auto; background: #f6f6f6; font-size: 12px; }
+    .built { background: #1c7ed6; }
+    .cached { background: #2b8a3e; }
+    .failed, .timedOut { background: #e03131; }
+    .skipped, .canceled, .unfinished { background: #adb5bd; }
+  </style>
+</head>
+<body>
+  <h1>turbo run</h1>
+  <div class="stats">
+    <span><b>{{.Duration}}</b> total</span>
+    <span><b>{{.Stats.Attempted}}</b> attempted</span>
+    <span><b>{{.Stats.Successful}}</b> successful</span>
+    <span><b>{{.Stats.Cached}}</b> cached</span>
+    <span><b>{{.Stats.Failed}}</b> failed</span>
+    {{- if .Stats.Skipped}}
+    <span><b>{{.Stats.Skipped}}</b> skipped</span>
+    {{- end}}
+    {{- if .Stats.Canceled}}
+    <span><b>{{.Stats.Canceled}}</b> canceled</span>
+    {{- end}}
+    <span>exit code <b>{{.Stats.ExitCode}}</b></span>
+  </div>
+  <div class="legend">
+    <span><i class="built"></i>ran</span>
+    <span><i class="cached"></i>cached</span>
+    <span><i class="failed"></i>failed</span>
+    <span><i class="skipped"></i>didn't run</span>
+    <span>Tasks in bold are on the critical path</span>
+  </div>
+  <div class="ticks">
+    {{- range .Ticks}}
+    <span>{{.}}</span>
+    {{- end}}
+  </div>
+  {{- range .Tasks}}
+  <div class="task{{if .Critical}} critical{{end}}" title="{{.TaskID}}: {{.Detail}}">
+    <div class="name">{{.TaskID}}</div>
+    <div class="lane">{{if .Bar}}<div class="bar {{.Status}}" style="{{.Bar}}"></div>{{end}}</div>
+    <div class="duration">{{.Duration}}</div>
+  </div>
+  {{- if .Log}}
+  <details>
+    <summary>{{.Detail}}</summary>
+    <pre>{{.Log}}</pre>
+  </details>
+  {{- end}}
+  {{- end}}
+</body>
+</html>
+`))
@@ -0,0 +1,41 @@
+package runsummary
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+func TestFormatHTML(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	logFile := repoRoot.UntypedJoin("apps", "web", ".turbo", "turbo-test.log")
+	assert.NilError(t, logFile.EnsureDir())
+	assert.NilError(t, logFile.WriteFile([]byte("> jest\n\x1b[31mexpected 1 < 2\x1b[0m\n"), 0644))
+
+	exitCode := 1
+	tasks := []*TaskSummary{
+		{TaskID: "web#test", Task: "test", Package: "web", Hash: "c", LogFile: "apps/web/.turbo/turbo-test.log", Execution: &TaskExecutionSummary{Status: TaskStatusFailed, StartTime: 2000, Duration: 2000, ExitCode: &exitCode}},
+		{TaskID: "web#build", Task: "build", Package: "web", Hash: "a", Execution: &TaskExecutionSummary{Status: TaskStatusCached, StartTime: 1000, Duration: 1000}},
+		{TaskID: "web#e2e", Task: "e2e", Package: "web", Execution: &TaskExecutionSummary{Status: TaskStatusSkipped, FailedDependencies: []string{"web#test"}}},
+	}
+	dependencies := map[string][]string{"web#test": {"web#build"}, "web#e2e": {"web#test"}}
+	summary := NewExecutionSummary(time.UnixMilli(1000), 3*time.Second, 1, tasks, dependencies, false)
+
+	report, err := summary.FormatHTML(repoRoot)
+	assert.NilError(t, err)
+	page := string(report)
+	assert.Assert(t, strings.Contains(page, `<div class="task critical" title="web#build: restored from the cache (a)">`))
+	assert.Assert(t, strings.Contains(page, `<div class="bar cached" style="left: 0.00%; width: 33.33%"></div>`))
+	assert.Assert(t, strings.Contains(page, `<div class="bar failed" style="left: 33.33%; width: 66.67%"></div>`))
+	assert.Assert(t, strings.Contains(page, `<summary>the task exited with code 1</summary>`))
+	assert.Assert(t, strings.Contains(page, "<pre>&gt; jest\nexpected 1 &lt; 2</pre>"))
+	// Tasks are in the order they started, and the skipped task that never started is last
+	build := strings.Index(page, `title="web#build`)
+	test := strings.Index(page, `title="web#test`)
+	e2e := strings.Index(page, `title="web#e2e`)
+	assert.Assert(t, build < test && test < e2e)
+	assert.Assert(t, !strings.Contains(page, `class="bar skipped"`))
+}
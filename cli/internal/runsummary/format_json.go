@@ -28,7 +28,11 @@ func (summary *RunSummary) formatJSONSinglePackage() ([]byte, error) {
 		singlePackageTasks[i] = task.toSinglePackageTask()
 	}
 
-	spSummary := &singlePackageRunSummary{Tasks: singlePackageTasks}
+	spSummary := &singlePackageRunSummary{
+		Tasks:       singlePackageTasks,
+		Execution:   summary.Execution,
+		Environment: summary.Environment,
+	}
 
 	bytes, err := json.MarshalIndent(spSummary, "", "  ")
 	if err != nil {
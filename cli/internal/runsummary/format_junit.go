@@ -0,0 +1,165 @@
+package runsummary
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+const (
+	_junitExcerptLines = 50
+	_junitExcerptBytes = 8000
+	// _junitRootSuite holds the tasks without a package, as in single-package repos
+	_junitRootSuite = "//"
+)
+
+// junitTestSuites is the root element of a JUnit report. Each package of the
+// run is a test suite, and each of its tasks a test case.
+type junitTestSuites struct {
+	XMLName  xml.Name          `xml:"testsuites"`
+	Name     string            `xml:"name,attr"`
+	Tests    int               `xml:"tests,attr"`
+	Failures int               `xml:"failures,attr"`
+	Skipped  int               `xml:"skipped,attr"`
+	Time     string            `xml:"time,attr"`
+	Suites   []*junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string           `xml:"name,attr"`
+	Tests     int              `xml:"tests,attr"`
+	Failures  int              `xml:"failures,attr"`
+	Skipped   int              `xml:"skipped,attr"`
+	Time      string           `xml:"time,attr"`
+	Timestamp string           `xml:"timestamp,attr,omitempty"`
+	Cases     []*junitTestCase `xml:"testcase"`
+	duration  time.Duration
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	// Type is the status of the task, "failed" or "timedOut"
+	Type   string `xml:"type,attr"`
+	Output string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// FormatJUnit renders the outcome of a run as a JUnit XML report, for CI
+// systems such as Jenkins and GitLab to show the tasks of the run like tests.
+// Failed tasks include the end of their log, and tasks that were skipped or
+// canceled are reported as skipped.
+func (summary *ExecutionSummary) FormatJUnit(repoRoot turbopath.AbsoluteSystemPath) ([]byte, error) {
+	report := &junitTestSuites{
+		Name: "turbo run",
+		Time: junitSeconds(time.Duration(summary.Duration) * time.Millisecond),
+	}
+	suites := make(map[string]*junitTestSuite)
+	for _, task := range summary.Tasks {
+		suiteName := task.Package
+		if suiteName == "" {
+			suiteName = _junitRootSuite
+		}
+		suite, ok := suites[suiteName]
+		if !ok {
+			suite = &junitTestSuite{Name: suiteName}
+			suites[suiteName] = suite
+			report.Suites = append(report.Suites, suite)
+		}
+		testCase := &junitTestCase{
+			Name:      task.Task,
+			ClassName: suiteName,
+			Time:      junitSeconds(0),
+		}
+		suite.Cases = append(suite.Cases, testCase)
+		suite.Tests++
+
+		execution := task.Execution
+		if execution == nil {
+			testCase.Skipped = &junitSkipped{Message: "the task didn't finish"}
+			suite.Skipped++
+			continue
+		}
+		duration := time.Duration(execution.Duration) * time.Millisecond
+		testCase.Time = junitSeconds(duration)
+		suite.duration += duration
+		if execution.StartTime > 0 {
+			startedAt := time.UnixMilli(execution.StartTime).UTC().Format("2006-01-02T15:04:05")
+			if suite.Timestamp == "" || startedAt < suite.Timestamp {
+				suite.Timestamp = startedAt
+			}
+		}
+
+		switch execution.Status {
+		case TaskStatusCached:
+			testCase.SystemOut = fmt.Sprintf("restored from the cache (%v)", task.Hash)
+		case TaskStatusFailed, TaskStatusTimedOut:
+			testCase.Failure = &junitFailure{
+				Message: failureMessage(execution),
+				Type:    execution.Status,
+			}
+			if task.logFile != "" {
+				if excerpt, err := LogExcerpt(repoRoot, task.logFile, _junitExcerptLines, _junitExcerptBytes); err == nil {
+					testCase.Failure.Output = excerpt
+				}
+			}
+			suite.Failures++
+		case TaskStatusSkipped:
+			testCase.Skipped = &junitSkipped{Message: fmt.Sprintf("a dependency failed: %v", strings.Join(execution.FailedDependencies, ", "))}
+			suite.Skipped++
+		case TaskStatusCanceled:
+			testCase.Skipped = &junitSkipped{Message: "the run was canceled"}
+			suite.Skipped++
+		}
+	}
+	sort.Slice(report.Suites, func(i, j int) bool {
+		return report.Suites[i].Name < report.Suites[j].Name
+	})
+	for _, suite := range report.Suites {
+		suite.Time = junitSeconds(suite.duration)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Skipped += suite.Skipped
+	}
+
+	bytes, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to render JUnit XML")
+	}
+	return append([]byte(xml.Header), append(bytes, '\n')...), nil
+}
+
+// failureMessage describes why a task failed in one line
+func failureMessage(execution *TaskExecutionSummary) string {
+	if execution.Error != "" {
+		return execution.Error
+	}
+	if execution.Status == TaskStatusTimedOut {
+		return "the task timed out"
+	}
+	if execution.ExitCode != nil {
+		return fmt.Sprintf("the task exited with code %v", *execution.ExitCode)
+	}
+	return "the task failed"
+}
+
+// junitSeconds formats a duration in seconds, as JUnit reports do
+func junitSeconds(duration time.Duration) string {
+	return fmt.Sprintf("%.3f", duration.Seconds())
+}
@@ -0,0 +1,61 @@
+package runsummary
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+func TestFormatJUnit(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	logFile := repoRoot.UntypedJoin("apps", "web", ".turbo", "turbo-test.log")
+	assert.NilError(t, logFile.EnsureDir())
+	assert.NilError(t, logFile.WriteFile([]byte("> jest\n\x1b[31mexpected 1 < 2\x1b[0m\n"), 0644))
+
+	exitCode := 1
+	tasks := []*TaskSummary{
+		{TaskID: "web#build", Task: "build", Package: "web", Hash: "a", Execution: &TaskExecutionSummary{Status: TaskStatusCached, StartTime: 1000, Duration: 12}},
+		{TaskID: "docs#build", Task: "build", Package: "docs", Hash: "b", Execution: &TaskExecutionSummary{Status: TaskStatusBuilt, StartTime: 1000, Duration: 1500}},
+		{TaskID: "web#test", Task: "test", Package: "web", Hash: "c", LogFile: "apps/web/.turbo/turbo-test.log", Execution: &TaskExecutionSummary{Status: TaskStatusFailed, StartTime: 2000, Duration: 2000, ExitCode: &exitCode}},
+		{TaskID: "web#e2e", Task: "e2e", Package: "web", Execution: &TaskExecutionSummary{Status: TaskStatusSkipped, FailedDependencies: []string{"web#test"}}},
+	}
+	summary := NewExecutionSummary(time.UnixMilli(1000), 3*time.Second, 1, tasks, nil, false)
+
+	report, err := summary.FormatJUnit(repoRoot)
+	assert.NilError(t, err)
+	assert.Equal(t, string(report), strings.Join([]string{
+		`<?xml version="1.0" encoding="UTF-8"?>`,
+		`<testsuites name="turbo run" tests="4" failures="1" skipped="1" time="3.000">`,
+		`  <testsuite name="docs" tests="1" failures="0" skipped="0" time="1.500" timestamp="1970-01-01T00:00:01">`,
+		`    <testcase name="build" classname="docs" time="1.500"></testcase>`,
+		`  </testsuite>`,
+		`  <testsuite name="web" tests="3" failures="1" skipped="1" time="2.012" timestamp="1970-01-01T00:00:01">`,
+		`    <testcase name="build" classname="web" time="0.012">`,
+		`      <system-out>restored from the cache (a)</system-out>`,
+		`    </testcase>`,
+		`    <testcase name="test" classname="web" time="2.000">`,
+		`      <failure message="the task exited with code 1" type="failed">&gt; jest&#xA;expected 1 &lt; 2</failure>`,
+		`    </testcase>`,
+		`    <testcase name="e2e" classname="web" time="0.000">`,
+		`      <skipped message="a dependency failed: web#test"></skipped>`,
+		`    </testcase>`,
+		`  </testsuite>`,
+		`</testsuites>`,
+		``,
+	}, "\n"))
+}
+
+func TestFormatJUnitSinglePackage(t *testing.T) {
+	tasks := []*TaskSummary{
+		{TaskID: "//#build", Task: "build", Package: "//", Execution: &TaskExecutionSummary{Status: TaskStatusTimedOut, Duration: 600000}},
+	}
+	summary := NewExecutionSummary(time.UnixMilli(1000), 3*time.Second, 1, tasks, nil, true)
+
+	report, err := summary.FormatJUnit(fs.AbsoluteSystemPathFromUpstream(t.TempDir()))
+	assert.NilError(t, err)
+	assert.Assert(t, strings.Contains(string(report), `<testsuite name="//" tests="1" failures="1" skipped="0" time="600.000">`))
+	assert.Assert(t, strings.Contains(string(report), `<failure message="the task timed out" type="timedOut"></failure>`))
+}
@@ -0,0 +1,129 @@
+package runsummary
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+const (
+	_markdownExcerptLines = 50
+	// GitHub rejects comments over 65536 characters, so each log stays well under that
+	_markdownExcerptBytes = 4000
+)
+
+// CacheHits counts the tasks in a run that were restored from the cache
+type CacheHits struct {
+	Hits  int
+	Total int
+}
+
+// Delta describes the change in cache hits from base to c
+func (c CacheHits) Delta(base CacheHits) string {
+	return fmt.Sprintf("%+d vs base (%v/%v)", c.Hits-base.Hits, base.Hits, base.Total)
+}
+
+// ReadCacheHits counts the cache hits in a run summary saved by `turbo run --summarize`,
+// such as one from a run on the base branch of a pull request
+func ReadCacheHits(path turbopath.AbsoluteSystemPath) (CacheHits, error) {
+	contents, err := path.ReadFile()
+	if err != nil {
+		return CacheHits{}, err
+	}
+	run := &historicalRun{}
+	if err := json.Unmarshal(contents, run); err != nil {
+		return CacheHits{}, fmt.Errorf("%v is not a run summary: %w", path, err)
+	}
+	hits := CacheHits{Total: len(run.Tasks)}
+	for _, task := range run.Tasks {
+		if task.Execution != nil && task.Execution.Status == TaskStatusCached {
+			hits.Hits++
+		}
+	}
+	return hits, nil
+}
+
+// FormatMarkdown renders the outcome of a run as a compact Markdown report for
+// posting as a pull request comment. The log of each failed task is included in a
+// collapsed section. If base is not nil, cache hits are compared against it.
+func (summary *RunSummary) FormatMarkdown(repoRoot turbopath.AbsoluteSystemPath, targets []string, base *CacheHits, isSinglePackage bool) string {
+	var failed []*TaskSummary
+	hits := CacheHits{Total: len(summary.Tasks)}
+	for _, task := range summary.Tasks {
+		if task.Execution == nil {
+			continue
+		}
+		switch task.Execution.Status {
+		case TaskStatusFailed, TaskStatusTimedOut:
+			failed = append(failed, task)
+		case TaskStatusCached:
+			hits.Hits++
+		}
+	}
+
+	var sb strings.Builder
+	command := fmt.Sprintf("`turbo run %v`", strings.Join(targets, " "))
+	if len(failed) > 0 {
+		fmt.Fprintf(&sb, "### :x: %v failed\n\n", command)
+	} else {
+		fmt.Fprintf(&sb, "### :white_check_mark: %v succeeded\n\n", command)
+	}
+	fmt.Fprintf(&sb, "**%v** tasks · **%v** failed · **%v** cached (%v)", hits.Total, len(failed), hits.Hits, percentage(hits))
+	if base != nil {
+		fmt.Fprintf(&sb, " · cache hits %v", hits.Delta(*base))
+	}
+	sb.WriteString("\n\n")
+
+	sb.WriteString("| Task | Result | Cache | Duration |\n")
+	sb.WriteString("| --- | --- | --- | --- |\n")
+	for _, task := range summary.Tasks {
+		result, cacheStatus, duration := "skipped", "-", "-"
+		if task.Execution != nil && task.Execution.Status != TaskStatusSkipped {
+			result = task.Execution.Status
+			if task.Execution.ExitCode != nil && task.Execution.Status == TaskStatusFailed {
+				result = fmt.Sprintf("%v (exit %d)", result, *task.Execution.ExitCode)
+			}
+			switch task.Execution.Status {
+			case TaskStatusCached:
+				cacheStatus = "HIT"
+			case TaskStatusCanceled:
+				// The cache wasn't written, nor restored
+			default:
+				cacheStatus = "MISS"
+			}
+			duration = (time.Duration(task.Execution.Duration) * time.Millisecond).String()
+		}
+		fmt.Fprintf(&sb, "| `%v` | %v | %v | %v |\n", markdownTaskName(task, isSinglePackage), result, cacheStatus, duration)
+	}
+
+	for _, task := range failed {
+		fmt.Fprintf(&sb, "\n<details>\n<summary><code>%v</code> log</summary>\n\n", markdownTaskName(task, isSinglePackage))
+		excerpt := ""
+		if task.LogFile != "" {
+			excerpt, _ = LogExcerpt(repoRoot, task.LogFile, _markdownExcerptLines, _markdownExcerptBytes)
+		}
+		if excerpt == "" {
+			excerpt = "(no log output)"
+		}
+		fmt.Fprintf(&sb, "```\n%v\n```\n\n</details>\n", excerpt)
+	}
+	return sb.String()
+}
+
+func markdownTaskName(task *TaskSummary, isSinglePackage bool) string {
+	if isSinglePackage {
+		return util.RootTaskTaskName(task.TaskID)
+	}
+	return task.TaskID
+}
+
+func percentage(hits CacheHits) string {
+	if hits.Total == 0 {
+		return "0%"
+	}
+	return fmt.Sprintf("%.0f%%", float64(hits.Hits)/float64(hits.Total)*100)
+}
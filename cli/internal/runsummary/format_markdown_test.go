@@ -0,0 +1,69 @@
+package runsummary
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+func TestFormatMarkdown(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	logFile := repoRoot.UntypedJoin("apps", "web", ".turbo", "turbo-test.log")
+	assert.NilError(t, logFile.EnsureDir())
+	assert.NilError(t, logFile.WriteFile([]byte("\x1b[31mFAIL\x1b[0m src/app.test.ts\n```\nexpected 1 to be 2\n"), 0644))
+
+	exitCode := 1
+	summary := &RunSummary{
+		Tasks: []*TaskSummary{
+			{TaskID: "web#build", Execution: &TaskExecutionSummary{Status: TaskStatusCached, Duration: 12}},
+			{TaskID: "docs#build", Execution: &TaskExecutionSummary{Status: TaskStatusBuilt, Duration: 1500}},
+			{TaskID: "web#test", LogFile: "apps/web/.turbo/turbo-test.log", Execution: &TaskExecutionSummary{Status: TaskStatusFailed, Duration: 2000, ExitCode: &exitCode}},
+			{TaskID: "docs#test"},
+		},
+	}
+
+	got := summary.FormatMarkdown(repoRoot, []string{"build", "test"}, &CacheHits{Hits: 0, Total: 4}, false)
+	want := strings.Join([]string{
+		"### :x: `turbo run build test` failed",
+		"",
+		"**4** tasks · **1** failed · **1** cached (25%) · cache hits +1 vs base (0/4)",
+		"",
+		"| Task | Result | Cache | Duration |",
+		"| --- | --- | --- | --- |",
+		"| `web#build` | cached | HIT | 12ms |",
+		"| `docs#build` | built | MISS | 1.5s |",
+		"| `web#test` | failed (exit 1) | MISS | 2s |",
+		"| `docs#test` | skipped | - | - |",
+		"",
+		"<details>",
+		"<summary><code>web#test</code> log</summary>",
+		"",
+		"```",
+		"FAIL src/app.test.ts",
+		"'''",
+		"expected 1 to be 2",
+		"```",
+		"",
+		"</details>",
+		"",
+	}, "\n")
+	assert.Equal(t, got, want)
+}
+
+func TestReadCacheHits(t *testing.T) {
+	dir := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	path := dir.UntypedJoin("base.json")
+	contents := `{"tasks": [
+		{"taskId": "web#build", "execution": {"status": "cached"}},
+		{"taskId": "docs#build", "execution": {"status": "built"}},
+		{"taskId": "web#test"}
+	]}`
+	assert.NilError(t, path.WriteFile([]byte(contents), 0644))
+
+	hits, err := ReadCacheHits(path)
+	assert.NilError(t, err)
+	assert.Equal(t, hits, CacheHits{Hits: 1, Total: 3})
+	assert.Equal(t, CacheHits{Hits: 3, Total: 3}.Delta(hits), "+2 vs base (1/3)")
+}
@@ -3,7 +3,9 @@ package runsummary
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"text/tabwriter"
@@ -15,12 +17,17 @@ import (
 
 // FormatAndPrintText prints a Run Summary to the Terminal UI
 func (summary RunSummary) FormatAndPrintText(ui cli.Ui, workspaceInfos workspace.Catalog, isSinglePackage bool) error {
+	return summary.formatAndPrintText(ui, os.Stdout, workspaceInfos, isSinglePackage)
+}
+
+// formatAndPrintText prints the headings to ui and the tables to out
+func (summary RunSummary) formatAndPrintText(ui cli.Ui, out io.Writer, workspaceInfos workspace.Catalog, isSinglePackage bool) error {
 	summary.normalize() // normalize data
 
 	if !isSinglePackage {
 		ui.Output("")
 		ui.Info(util.Sprintf("${CYAN}${BOLD}Packages in Scope${RESET}"))
-		p := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+		p := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
 		fmt.Fprintln(p, "Name\tPath\t")
 		for _, pkg := range summary.Packages {
 			fmt.Fprintf(p, "%s\t%s\t\n", pkg, workspaceInfos.PackageJSONs[pkg].Dir)
@@ -34,12 +41,13 @@ func (summary RunSummary) FormatAndPrintText(ui cli.Ui, workspaceInfos workspace
 	for range summary.GlobalHashSummary.GlobalFileHashMap {
 		fileCount = fileCount + 1
 	}
-	w1 := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	w1 := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
 	ui.Output("")
 	ui.Info(util.Sprintf("${CYAN}${BOLD}Global Hash Inputs${RESET}"))
 	fmt.Fprintln(w1, util.Sprintf("  ${GREY}Global Files\t=\t%d${RESET}", fileCount))
 	fmt.Fprintln(w1, util.Sprintf("  ${GREY}External Dependencies Hash\t=\t%s${RESET}", summary.GlobalHashSummary.RootExternalDepsHash))
 	fmt.Fprintln(w1, util.Sprintf("  ${GREY}Global Cache Key\t=\t%s${RESET}", summary.GlobalHashSummary.GlobalCacheKey))
+	fmt.Fprintln(w1, util.Sprintf("  ${GREY}Hash Version\t=\t%d${RESET}", summary.GlobalHashSummary.HashVersion))
 	if bytes, err := json.Marshal(summary.GlobalHashSummary.Pipeline); err == nil {
 		fmt.Fprintln(w1, util.Sprintf("  ${GREY}Root pipeline\t=\t%s${RESET}", bytes))
 	}
@@ -47,8 +55,40 @@ func (summary RunSummary) FormatAndPrintText(ui cli.Ui, workspaceInfos workspace
 		return err
 	}
 
+	if len(summary.Warnings) > 0 {
+		ui.Output("")
+		ui.Info(util.Sprintf("${CYAN}${BOLD}Warnings${RESET}"))
+		for _, warning := range summary.Warnings {
+			ui.Output(util.Sprintf("  ${YELLOW}%s${RESET}: %s", warning.TaskID, warning.Message))
+			ui.Output(util.Sprintf("    ${GREY}%s${RESET}", warning.Fix))
+		}
+	}
+
+	if len(summary.TestSelection) > 0 {
+		ui.Output("")
+		ui.Info(util.Sprintf("${CYAN}${BOLD}Test Selection${RESET}"))
+		selections := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		for _, selection := range summary.TestSelection {
+			status := "${GREEN}run"
+			if !selection.Selected {
+				status = "${GREY}skip"
+			}
+			fmt.Fprintln(selections, util.Sprintf("  %s\t%s${RESET}\t${GREY}%s${RESET}", selection.TaskID, status, selection.Reason))
+		}
+		if err := selections.Flush(); err != nil {
+			return err
+		}
+	}
+
+	hits := 0
+	for _, task := range summary.Tasks {
+		if _, hit := task.CachePrediction(); hit {
+			hits++
+		}
+	}
 	ui.Output("")
 	ui.Info(util.Sprintf("${CYAN}${BOLD}Tasks to Run${RESET}"))
+	ui.Output(util.Sprintf("${GREY}%d of %d tasks are expected to be restored from the cache${RESET}", hits, len(summary.Tasks)))
 
 	for _, task := range summary.Tasks {
 		taskName := task.TaskID
@@ -58,7 +98,7 @@ func (summary RunSummary) FormatAndPrintText(ui cli.Ui, workspaceInfos workspace
 		}
 
 		ui.Info(util.Sprintf("${BOLD}%s${RESET}", taskName))
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+		w := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
 		fmt.Fprintln(w, util.Sprintf("  ${GREY}Task\t=\t%s\t${RESET}", task.Task))
 
 		var dependencies []string
@@ -80,23 +120,55 @@ func (summary RunSummary) FormatAndPrintText(ui cli.Ui, workspaceInfos workspace
 		}
 
 		fmt.Fprintln(w, util.Sprintf("  ${GREY}Hash\t=\t%s\t${RESET}", task.Hash))
+		if task.FallbackHash != "" {
+			fmt.Fprintln(w, util.Sprintf("  ${GREY}Fallback Hash\t=\t%s\t${RESET}", task.FallbackHash))
+		}
+		fmt.Fprintln(w, util.Sprintf("  ${GREY}Inputs Hash\t=\t%s\t${RESET}", task.InputsHash))
 		fmt.Fprintln(w, util.Sprintf("  ${GREY}Cached (Local)\t=\t%s\t${RESET}", strconv.FormatBool(task.CacheState.Local)))
 		fmt.Fprintln(w, util.Sprintf("  ${GREY}Cached (Remote)\t=\t%s\t${RESET}", strconv.FormatBool(task.CacheState.Remote)))
+		prediction, hit := task.CachePrediction()
+		predictionColor := "${YELLOW}"
+		if hit {
+			predictionColor = "${GREEN}"
+		}
+		fmt.Fprintln(w, util.Sprintf("  ${GREY}Cache Prediction\t=\t${RESET}%s%s\t${RESET}", predictionColor, prediction))
 
 		if !isSinglePackage {
 			fmt.Fprintln(w, util.Sprintf("  ${GREY}Directory\t=\t%s\t${RESET}", task.Dir))
 		}
 
+		if task.WorkingDir != "" {
+			fmt.Fprintln(w, util.Sprintf("  ${GREY}Working Directory\t=\t%s\t${RESET}", task.WorkingDir))
+		}
 		fmt.Fprintln(w, util.Sprintf("  ${GREY}Command\t=\t%s\t${RESET}", task.Command))
 		fmt.Fprintln(w, util.Sprintf("  ${GREY}Outputs\t=\t%s\t${RESET}", strings.Join(task.Outputs, ", ")))
 		fmt.Fprintln(w, util.Sprintf("  ${GREY}Log File\t=\t%s\t${RESET}", task.LogFile))
 		fmt.Fprintln(w, util.Sprintf("  ${GREY}Dependencies\t=\t%s\t${RESET}", strings.Join(dependencies, ", ")))
 		fmt.Fprintln(w, util.Sprintf("  ${GREY}Dependendents\t=\t%s\t${RESET}", strings.Join(dependents, ", ")))
+		if len(task.AssumedFresh) > 0 {
+			assumedFresh := task.AssumedFresh
+			if isSinglePackage {
+				assumedFresh = make([]string, len(task.AssumedFresh))
+				for i, dependency := range task.AssumedFresh {
+					assumedFresh[i] = util.StripPackageName(dependency)
+				}
+			}
+			fmt.Fprintln(w, util.Sprintf("  ${GREY}Assumed Fresh (--only)\t=\t%s\t${RESET}", strings.Join(assumedFresh, ", ")))
+		}
 		fmt.Fprintln(w, util.Sprintf("  ${GREY}Inputs Files Considered\t=\t%d\t${RESET}", len(task.ExpandedInputs)))
 
 		fmt.Fprintln(w, util.Sprintf("  ${GREY}Configured Environment Variables\t=\t%s\t${RESET}", strings.Join(task.EnvVars.Configured, ", ")))
 		fmt.Fprintln(w, util.Sprintf("  ${GREY}Inferred Environment Variables\t=\t%s\t${RESET}", strings.Join(task.EnvVars.Inferred, ", ")))
 		fmt.Fprintln(w, util.Sprintf("  ${GREY}Global Environment Variables\t=\t%s\t${RESET}", strings.Join(task.EnvVars.Global, ", ")))
+		fmt.Fprintln(w, util.Sprintf("  ${GREY}Passthrough Environment Variables\t=\t%s\t${RESET}", strings.Join(task.EnvVars.PassThrough, ", ")))
+		if len(task.HashCommands) > 0 {
+			commands := make([]string, 0, len(task.HashCommands))
+			for command, hash := range task.HashCommands {
+				commands = append(commands, fmt.Sprintf("%s=%s", command, hash))
+			}
+			sort.Strings(commands)
+			fmt.Fprintln(w, util.Sprintf("  ${GREY}Hash Commands\t=\t%s\t${RESET}", strings.Join(commands, ", ")))
+		}
 
 		bytes, err := json.Marshal(task.ResolvedTaskDefinition)
 		// If there's an error, we can silently ignore it, we don't need to block the entire print.
@@ -111,3 +183,24 @@ func (summary RunSummary) FormatAndPrintText(ui cli.Ui, workspaceInfos workspace
 	}
 	return nil
 }
+
+// CachePrediction describes whether a real run is expected to restore the task
+// from the cache, based on the cache lookup made by the dry run
+func (ts *TaskSummary) CachePrediction() (string, bool) {
+	if ts.ResolvedTaskDefinition != nil && !ts.ResolvedTaskDefinition.ShouldCache {
+		return "MISS (cache disabled)", false
+	}
+	if ts.ExpectedCacheStatus == "forced" {
+		return "MISS (forced)", false
+	}
+	switch {
+	case ts.CacheState.Local && ts.CacheState.Remote:
+		return "HIT (local, remote)", true
+	case ts.CacheState.Local:
+		return "HIT (local)", true
+	case ts.CacheState.Remote:
+		return "HIT (remote)", true
+	default:
+		return "MISS", false
+	}
+}
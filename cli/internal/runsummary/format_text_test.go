@@ -0,0 +1,83 @@
+package runsummary
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/cli"
+	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/util"
+	"github.com/vercel/turbo/cli/internal/workspace"
+	"gotest.tools/v3/assert"
+)
+
+func TestCachePrediction(t *testing.T) {
+	testCases := []struct {
+		name        string
+		cacheState  cache.ItemStatus
+		shouldCache bool
+		expected    string
+		want        string
+		wantHit     bool
+	}{
+		{name: "miss", shouldCache: true, want: "MISS"},
+		{name: "local hit", cacheState: cache.ItemStatus{Local: true}, shouldCache: true, want: "HIT (local)", wantHit: true},
+		{name: "remote hit", cacheState: cache.ItemStatus{Remote: true}, shouldCache: true, want: "HIT (remote)", wantHit: true},
+		{name: "local and remote hit", cacheState: cache.ItemStatus{Local: true, Remote: true}, shouldCache: true, want: "HIT (local, remote)", wantHit: true},
+		{name: "cache disabled", cacheState: cache.ItemStatus{Local: true}, shouldCache: false, want: "MISS (cache disabled)"},
+		{name: "forced", cacheState: cache.ItemStatus{Local: true}, shouldCache: true, expected: "forced", want: "MISS (forced)"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			task := &TaskSummary{
+				CacheState:             tc.cacheState,
+				ExpectedCacheStatus:    tc.expected,
+				ResolvedTaskDefinition: &fs.TaskDefinition{ShouldCache: tc.shouldCache},
+			}
+			got, hit := task.CachePrediction()
+			assert.Equal(t, got, tc.want)
+			assert.Equal(t, hit, tc.wantHit)
+		})
+	}
+}
+
+func TestFormatTextCachePrediction(t *testing.T) {
+	summary := RunSummary{
+		GlobalHashSummary: &GlobalHashSummary{},
+		Tasks: []*TaskSummary{
+			{
+				TaskID:                 "web#build",
+				Task:                   "build",
+				Package:                "web",
+				CacheState:             cache.ItemStatus{Local: true},
+				ResolvedTaskDefinition: &fs.TaskDefinition{ShouldCache: true},
+			},
+			{
+				TaskID:                 "docs#build",
+				Task:                   "build",
+				Package:                "docs",
+				ResolvedTaskDefinition: &fs.TaskDefinition{ShouldCache: true},
+			},
+		},
+	}
+	ui := cli.NewMockUi()
+	var out bytes.Buffer
+	assert.NilError(t, summary.formatAndPrintText(ui, &out, workspace.Catalog{}, false))
+
+	// The prediction is in the block of each task, in the order of the tasks
+	var predictions []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		if strings.Contains(line, "Cache Prediction") {
+			predictions = append(predictions, line)
+		}
+	}
+	assert.Equal(t, len(predictions), 2)
+	assert.Assert(t, strings.Contains(predictions[0], util.Sprintf("${GREEN}HIT (local)")), predictions[0])
+	assert.Assert(t, strings.Contains(predictions[1], util.Sprintf("${YELLOW}MISS")), predictions[1])
+
+	headings := ui.OutputWriter.String()
+	assert.Assert(t, strings.Contains(headings, "1 of 2 tasks are expected to be restored from the cache"), headings)
+	assert.Assert(t, !strings.Contains(headings, "Cache Predictions"), headings)
+}
@@ -0,0 +1,154 @@
+package runsummary
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mitchellh/cli"
+	"github.com/pkg/errors"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// GlobalHashInputs lists everything that was hashed into the global hash, for
+// `turbo run --dry --show-global-hash-inputs`
+type GlobalHashInputs struct {
+	GlobalHash string `json:"globalHash"`
+	*GlobalHashSummary
+	// EnvVars shadows GlobalHashSummary.EnvVars so that the hashed values are included
+	EnvVars []string `json:"envVars"`
+	// RootExternalDeps are the lockfile entries ("name@version") of the root workspace
+	// that make up RootExternalDepsHash
+	RootExternalDeps []string `json:"rootExternalDeps"`
+	// TurboVersion is not part of the global hash, it is reported for reference
+	TurboVersion string `json:"turboVersion"`
+}
+
+// NewGlobalHashInputs creates a GlobalHashInputs from a GlobalHashSummary and the
+// root workspace's external dependencies
+func NewGlobalHashInputs(globalHash string, turboVersion string, summary *GlobalHashSummary, rootExternalDeps []string) *GlobalHashInputs {
+	envVars := []string(summary.EnvVars)
+	if envVars == nil {
+		envVars = []string{}
+	}
+	if rootExternalDeps == nil {
+		rootExternalDeps = []string{}
+	}
+	return &GlobalHashInputs{
+		GlobalHash:        globalHash,
+		GlobalHashSummary: summary,
+		EnvVars:           envVars,
+		RootExternalDeps:  rootExternalDeps,
+		TurboVersion:      turboVersion,
+	}
+}
+
+// GlobalHashContributor is one of the inputs of the global hash, with a digest
+// of its own, so that a change to the global hash can be traced to its input
+type GlobalHashContributor struct {
+	// Kind is "file", "env", "externalDeps", "cacheKey", "pipeline" or
+	// "turboVersion"
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Digest string `json:"digest,omitempty"`
+	// Hashed is false for contributors that are only reported for reference,
+	// and don't change the global hash
+	Hashed bool `json:"hashed"`
+}
+
+// Contributors lists every input of the global hash with its own digest: each
+// global file, each environment variable with a digest of its value, the root
+// external dependencies from the lockfile, the global cache key, the root
+// pipeline and the turbo version.
+func (inputs *GlobalHashInputs) Contributors() ([]*GlobalHashContributor, error) {
+	version := fs.HashVersion(inputs.HashVersion)
+	if version == 0 {
+		version = fs.DefaultHashVersion
+	}
+	contributors := []*GlobalHashContributor{}
+	files := make([]string, 0, len(inputs.GlobalFileHashMap))
+	for file := range inputs.GlobalFileHashMap {
+		files = append(files, file.ToString())
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		contributors = append(contributors, &GlobalHashContributor{
+			Kind:   "file",
+			Name:   file,
+			Digest: inputs.GlobalFileHashMap[turbopath.AnchoredUnixPath(file)],
+			Hashed: true,
+		})
+	}
+	for _, envVar := range inputs.EnvVars {
+		name, digest, _ := strings.Cut(envVar, "=")
+		contributors = append(contributors, &GlobalHashContributor{Kind: "env", Name: name, Digest: digest, Hashed: true})
+	}
+	// Since hash version 3, root external dependencies only change the hashes
+	// of root tasks
+	contributors = append(contributors, &GlobalHashContributor{
+		Kind:   "externalDeps",
+		Name:   fmt.Sprintf("%v root external dependencies", len(inputs.RootExternalDeps)),
+		Digest: inputs.RootExternalDepsHash,
+		Hashed: version < fs.HashVersion3,
+	})
+	cacheKeyDigest, err := fs.HashObjectVersion(version, inputs.GlobalCacheKey)
+	if err != nil {
+		return nil, err
+	}
+	contributors = append(contributors, &GlobalHashContributor{Kind: "cacheKey", Name: "global cache key", Digest: cacheKeyDigest, Hashed: true})
+	pipelineDigest, err := fs.HashObjectVersion(version, inputs.Pipeline)
+	if err != nil {
+		return nil, err
+	}
+	contributors = append(contributors, &GlobalHashContributor{Kind: "pipeline", Name: "root pipeline", Digest: pipelineDigest, Hashed: true})
+	contributors = append(contributors, &GlobalHashContributor{Kind: "turboVersion", Name: inputs.TurboVersion, Hashed: false})
+	return contributors, nil
+}
+
+// FormatJSON returns a json string representing the GlobalHashInputs
+func (inputs *GlobalHashInputs) FormatJSON() ([]byte, error) {
+	bytes, err := json.MarshalIndent(inputs, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to render JSON")
+	}
+	return bytes, nil
+}
+
+// FormatAndPrintText prints the GlobalHashInputs to the Terminal UI
+func (inputs *GlobalHashInputs) FormatAndPrintText(ui cli.Ui) error {
+	files := make([]turbopath.AnchoredUnixPath, 0, len(inputs.GlobalFileHashMap))
+	for file := range inputs.GlobalFileHashMap {
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i] < files[j] })
+
+	ui.Output("")
+	ui.Info(util.Sprintf("${CYAN}${BOLD}Global Hash Inputs${RESET}"))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	fmt.Fprintln(w, util.Sprintf("  ${GREY}Global Hash\t=\t%s${RESET}", inputs.GlobalHash))
+	fmt.Fprintln(w, util.Sprintf("  ${GREY}Hash Version\t=\t%d${RESET}", inputs.HashVersion))
+	fmt.Fprintln(w, util.Sprintf("  ${GREY}Global Files\t=\t%d${RESET}", len(files)))
+	for _, file := range files {
+		fmt.Fprintln(w, util.Sprintf("    ${GREY}%s\t=\t%s${RESET}", file, inputs.GlobalFileHashMap[file]))
+	}
+	fmt.Fprintln(w, util.Sprintf("  ${GREY}Environment Variables\t=\t%d${RESET}", len(inputs.EnvVars)))
+	for _, envVar := range inputs.EnvVars {
+		fmt.Fprintln(w, util.Sprintf("    ${GREY}%s${RESET}", envVar))
+	}
+	fmt.Fprintln(w, util.Sprintf("  ${GREY}External Dependencies Hash\t=\t%s${RESET}", inputs.RootExternalDepsHash))
+	fmt.Fprintln(w, util.Sprintf("  ${GREY}External Dependencies\t=\t%d${RESET}", len(inputs.RootExternalDeps)))
+	for _, dep := range inputs.RootExternalDeps {
+		fmt.Fprintln(w, util.Sprintf("    ${GREY}%s${RESET}", dep))
+	}
+	fmt.Fprintln(w, util.Sprintf("  ${GREY}Global Cache Key\t=\t%s${RESET}", inputs.GlobalCacheKey))
+	if bytes, err := json.Marshal(inputs.Pipeline); err == nil {
+		fmt.Fprintln(w, util.Sprintf("  ${GREY}Root pipeline\t=\t%s${RESET}", bytes))
+	}
+	fmt.Fprintln(w, util.Sprintf("  ${GREY}Turbo Version (not hashed)\t=\t%s${RESET}", inputs.TurboVersion))
+	return w.Flush()
+}
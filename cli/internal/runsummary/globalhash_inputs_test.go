@@ -0,0 +1,68 @@
+package runsummary
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/env"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func TestGlobalHashInputsFormatJSON(t *testing.T) {
+	summary := NewGlobalHashSummary(
+		map[turbopath.AnchoredUnixPath]string{"tsconfig.json": "abc123"},
+		"deps-hash",
+		env.DetailedMap{All: env.EnvironmentVariableMap{"API_URL": "https://example.com"}},
+		"cache-key",
+		fs.PristinePipeline{},
+		fs.HashVersion1,
+	)
+	inputs := NewGlobalHashInputs("global-hash", "1.2.3", summary, []string{"react@18.2.0"})
+
+	rendered, err := inputs.FormatJSON()
+	assert.NilError(t, err)
+
+	var got map[string]interface{}
+	assert.NilError(t, json.Unmarshal(rendered, &got))
+	assert.Equal(t, got["globalHash"], "global-hash")
+	assert.Equal(t, got["rootExternalDepsHash"], "deps-hash")
+	assert.Equal(t, got["globalCacheKey"], "cache-key")
+	assert.Equal(t, got["turboVersion"], "1.2.3")
+	assert.Equal(t, got["hashVersion"], float64(1))
+	assert.DeepEqual(t, got["globalFileHashMap"], map[string]interface{}{"tsconfig.json": "abc123"})
+	assert.DeepEqual(t, got["rootExternalDeps"], []interface{}{"react@18.2.0"})
+	envVars := got["envVars"].([]interface{})
+	assert.Equal(t, len(envVars), 1)
+	// Values are hashed, never printed as-is
+	assert.Assert(t, envVars[0] != "API_URL=https://example.com")
+}
+
+func TestGlobalHashInputsContributors(t *testing.T) {
+	summary := NewGlobalHashSummary(
+		map[turbopath.AnchoredUnixPath]string{"tsconfig.json": "abc123", ".env": "def456"},
+		"deps-hash",
+		env.DetailedMap{All: env.EnvironmentVariableMap{"API_URL": "https://example.com"}},
+		"cache-key",
+		fs.PristinePipeline{},
+		fs.HashVersion3,
+	)
+	inputs := NewGlobalHashInputs("global-hash", "1.2.3", summary, []string{"react@18.2.0"})
+
+	contributors, err := inputs.Contributors()
+	assert.NilError(t, err)
+	kinds := []string{}
+	for _, contributor := range contributors {
+		kinds = append(kinds, contributor.Kind)
+		assert.Assert(t, contributor.Kind == "turboVersion" || contributor.Digest != "", contributor.Kind)
+	}
+	assert.DeepEqual(t, kinds, []string{"file", "file", "env", "externalDeps", "cacheKey", "pipeline", "turboVersion"})
+	assert.DeepEqual(t, contributors[0], &GlobalHashContributor{Kind: "file", Name: ".env", Digest: "def456", Hashed: true})
+	assert.Equal(t, contributors[2].Name, "API_URL")
+	// Values are hashed, never printed as-is
+	assert.Assert(t, contributors[2].Digest != "https://example.com")
+	// With hash version 3, root external dependencies are hashed by root tasks instead
+	assert.DeepEqual(t, contributors[3], &GlobalHashContributor{Kind: "externalDeps", Name: "1 root external dependencies", Digest: "deps-hash", Hashed: false})
+	assert.DeepEqual(t, contributors[6], &GlobalHashContributor{Kind: "turboVersion", Name: "1.2.3", Hashed: false})
+}
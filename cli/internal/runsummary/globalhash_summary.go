@@ -13,6 +13,9 @@ type GlobalHashSummary struct {
 	GlobalCacheKey       string                                `json:"globalCacheKey"`
 	Pipeline             fs.PristinePipeline                   `json:"pipeline"`
 	EnvVars              env.EnvironmentVariablePairs          `json:"-"`
+	// HashVersion is the version of the hashing algorithm that task hashes were
+	// computed with
+	HashVersion int `json:"hashVersion,omitempty"`
 }
 
 // NewGlobalHashSummary creates a GlobalHashSummary struct from a set of fields.
@@ -22,6 +25,7 @@ func NewGlobalHashSummary(
 	envVars env.DetailedMap,
 	globalCacheKey string,
 	pipeline fs.PristinePipeline,
+	hashVersion fs.HashVersion,
 ) *GlobalHashSummary {
 	return &GlobalHashSummary{
 		EnvVars:              envVars.All.ToSecretHashable(),
@@ -29,5 +33,6 @@ func NewGlobalHashSummary(
 		RootExternalDepsHash: rootExternalDepsHash,
 		GlobalCacheKey:       globalCacheKey,
 		Pipeline:             pipeline,
+		HashVersion:          int(hashVersion),
 	}
 }
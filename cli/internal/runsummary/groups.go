@@ -0,0 +1,98 @@
+package runsummary
+
+import (
+	"strings"
+
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// RunGroup is a group of tasks of a run with --also: the tasks on the command
+// line, or the tasks of one --also
+type RunGroup struct {
+	Tasks  []string
+	Filter []string
+	// TaskIDs are the tasks of the run that the group needs, its own tasks and
+	// the tasks they depend on
+	TaskIDs []string
+}
+
+// GroupSummary counts the outcome of the tasks that a group of a run with
+// --also needs. A task that several groups need ran once, and counts in each
+// of them.
+type GroupSummary struct {
+	Tasks  []string `json:"tasks"`
+	Filter []string `json:"filter,omitempty"`
+	// Total counts the tasks of the group, whether or not they finished
+	Total int `json:"total"`
+	// Attempted, Successful, Cached and Failed are counted as in ExecutionStats
+	Attempted  int `json:"attempted"`
+	Successful int `json:"successful"`
+	Cached     int `json:"cached"`
+	Failed     int `json:"failed"`
+}
+
+// AddGroups counts the outcome of the tasks of each group of a run with
+// --also, and how many tasks were shared by more than one group
+func (summary *ExecutionSummary) AddGroups(groups []RunGroup) {
+	tasks := make(map[string]*ExecutionTaskSummary, len(summary.Tasks))
+	for _, task := range summary.Tasks {
+		tasks[task.TaskID] = task
+	}
+	groupCounts := make(map[string]int)
+	for _, group := range groups {
+		groupSummary := &GroupSummary{Tasks: group.Tasks, Filter: group.Filter}
+		for _, taskID := range group.TaskIDs {
+			task, ok := tasks[taskID]
+			if !ok {
+				continue
+			}
+			groupSummary.Total++
+			groupCounts[taskID]++
+			if task.Execution == nil {
+				continue
+			}
+			switch task.Execution.Status {
+			case TaskStatusBuilt:
+				groupSummary.Attempted++
+				groupSummary.Successful++
+			case TaskStatusCached:
+				groupSummary.Attempted++
+				groupSummary.Successful++
+				groupSummary.Cached++
+			case TaskStatusFailed, TaskStatusTimedOut:
+				groupSummary.Attempted++
+				groupSummary.Failed++
+			}
+		}
+		summary.Groups = append(summary.Groups, groupSummary)
+	}
+	for _, count := range groupCounts {
+		if count > 1 {
+			summary.Deduplicated++
+		}
+	}
+}
+
+// FormatGroups describes the outcome of each group of a run with --also in one
+// line, for the text summary
+func (summary *ExecutionSummary) FormatGroups() []string {
+	if len(summary.Groups) == 0 {
+		return nil
+	}
+	lines := []string{util.Sprintf("${BOLD}Groups:${RESET}")}
+	for _, group := range summary.Groups {
+		name := strings.Join(group.Tasks, " ")
+		for _, filter := range group.Filter {
+			name += " --filter=" + filter
+		}
+		line := util.Sprintf("  ${BOLD}%v${RESET} ${GREY}%v successful, %v cached, %v total", name, group.Successful, group.Cached, group.Total)
+		if group.Failed > 0 {
+			line += util.Sprintf(", %v failed", group.Failed)
+		}
+		lines = append(lines, line+util.Sprintf("${RESET}"))
+	}
+	if summary.Deduplicated > 0 {
+		lines = append(lines, util.Sprintf("  ${GREY}%v tasks needed by more than one group ran once${RESET}", summary.Deduplicated))
+	}
+	return lines
+}
@@ -0,0 +1,34 @@
+package runsummary
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestAddGroups(t *testing.T) {
+	tasks := []*TaskSummary{
+		{TaskID: "ui#build", Task: "build", Package: "ui", Hash: "a", Execution: &TaskExecutionSummary{Status: TaskStatusCached}},
+		{TaskID: "web#build", Task: "build", Package: "web", Hash: "b", Execution: &TaskExecutionSummary{Status: TaskStatusBuilt}},
+		{TaskID: "docs#lint", Task: "lint", Package: "docs", Hash: "c", Execution: &TaskExecutionSummary{Status: TaskStatusFailed}},
+		{TaskID: "docs#test", Task: "test", Package: "docs", Hash: "d"},
+	}
+	summary := NewExecutionSummary(time.UnixMilli(1000), time.Second, 1, tasks, nil, false)
+	summary.AddGroups([]RunGroup{
+		{Tasks: []string{"build"}, Filter: []string{"web"}, TaskIDs: []string{"ui#build", "web#build"}},
+		{Tasks: []string{"lint", "test"}, Filter: []string{"docs"}, TaskIDs: []string{"ui#build", "docs#lint", "docs#test"}},
+	})
+
+	assert.Equal(t, len(summary.Groups), 2)
+	assert.DeepEqual(t, summary.Groups[0], &GroupSummary{Tasks: []string{"build"}, Filter: []string{"web"}, Total: 2, Attempted: 2, Successful: 2, Cached: 1})
+	assert.DeepEqual(t, summary.Groups[1], &GroupSummary{Tasks: []string{"lint", "test"}, Filter: []string{"docs"}, Total: 3, Attempted: 2, Successful: 1, Cached: 1, Failed: 1})
+	// ui#build is needed by both groups, and only ran once
+	assert.Equal(t, summary.Deduplicated, 1)
+	assert.Equal(t, summary.Attempted, 3)
+
+	lines := summary.FormatGroups()
+	assert.Equal(t, len(lines), 4)
+
+	assert.Assert(t, NewExecutionSummary(time.UnixMilli(1000), time.Second, 0, tasks, nil, false).FormatGroups() == nil)
+}
@@ -0,0 +1,101 @@
+package runsummary
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// SavedRun describes a run summary saved in the repository by `turbo run --summarize`
+type SavedRun struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Duration is in milliseconds. It is missing from summaries saved by older
+	// versions of turbo.
+	Duration     *int64  `json:"duration,omitempty"`
+	Tasks        int     `json:"tasks"`
+	Cached       int     `json:"cached"`
+	Failed       int     `json:"failed"`
+	CacheHitRate float64 `json:"cacheHitRate"`
+	// ExitCode is missing for dry runs and older summaries
+	ExitCode    *int               `json:"exitCode,omitempty"`
+	Environment *RecordEnvironment `json:"environment,omitempty"`
+}
+
+// ListRuns describes the most recent maxRuns run summaries saved in the
+// repository, newest first. Files that can't be read are skipped.
+func ListRuns(repoRoot turbopath.AbsoluteSystemPath, maxRuns int) ([]*SavedRun, error) {
+	savedRuns := []*SavedRun{}
+	files, err := listRunSummaries(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		if len(savedRuns) == maxRuns {
+			break
+		}
+		contents, err := turbopath.AbsoluteSystemPath(file).ReadFile()
+		if err != nil {
+			continue
+		}
+		run := &historicalRun{}
+		if err := json.Unmarshal(contents, run); err != nil {
+			continue
+		}
+		savedRuns = append(savedRuns, newSavedRun(file, run))
+	}
+	return savedRuns, nil
+}
+
+func newSavedRun(file string, run *historicalRun) *SavedRun {
+	savedRun := &SavedRun{
+		ID:          strings.TrimSuffix(filepath.Base(file), ".json"),
+		CreatedAt:   createdAt(file),
+		Tasks:       len(run.Tasks),
+		Environment: run.Environment,
+	}
+	for _, task := range run.Tasks {
+		if task.Execution == nil {
+			continue
+		}
+		switch task.Execution.Status {
+		case TaskStatusCached:
+			savedRun.Cached++
+		case TaskStatusFailed, TaskStatusTimedOut:
+			savedRun.Failed++
+		}
+	}
+	if savedRun.Tasks > 0 {
+		savedRun.CacheHitRate = float64(savedRun.Cached) / float64(savedRun.Tasks)
+	}
+	if run.Execution != nil {
+		duration := run.Execution.Duration
+		savedRun.Duration = &duration
+	}
+	if run.ExitCode != nil {
+		exitCode := run.ExitCode.Code
+		savedRun.ExitCode = &exitCode
+	}
+	return savedRun
+}
+
+// LastDurations returns how long each task took the last time it was executed,
+// rather than restored from the cache, in the most recent maxRuns run summaries
+// saved in the repository. Single package tasks are keyed by their task name.
+func LastDurations(repoRoot turbopath.AbsoluteSystemPath, maxRuns int) (map[string]time.Duration, error) {
+	outcomes, err := loadOutcomes(repoRoot, maxRuns)
+	if err != nil {
+		return nil, err
+	}
+	durations := make(map[string]time.Duration)
+	// Outcomes are ordered from the oldest run to the newest
+	for _, outcome := range outcomes {
+		if outcome.status == TaskStatusBuilt {
+			durations[outcome.taskID] = time.Duration(outcome.duration) * time.Millisecond
+		}
+	}
+	return durations, nil
+}
@@ -0,0 +1,97 @@
+package runsummary
+
+import (
+	"testing"
+	"time"
+
+	"github.com/segmentio/ksuid"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"gotest.tools/v3/assert"
+)
+
+func TestListRuns(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	dir := runsDir(repoRoot)
+	assert.NilError(t, dir.MkdirAll(0755))
+
+	now := time.Now()
+	olderID, err := ksuid.NewRandomWithTime(now.Add(-time.Hour))
+	assert.NilError(t, err)
+	newerID, err := ksuid.NewRandomWithTime(now)
+	assert.NilError(t, err)
+
+	// A summary saved by an older version of turbo, without execution stats
+	older := `{"tasks": [
+		{"taskId": "web#build", "execution": {"status": "cached"}},
+		{"taskId": "web#test", "execution": {"status": "failed"}}
+	]}`
+	newer := `{
+		"tasks": [
+			{"taskId": "web#build", "execution": {"status": "cached"}},
+			{"taskId": "web#test", "execution": {"status": "cached"}}
+		],
+		"exitCode": {"code": 0, "mode": "highest"},
+		"execution": {"duration": 1200},
+		"environment": {"os": "linux", "arch": "amd64"}
+	}`
+	assert.NilError(t, dir.UntypedJoin(olderID.String()+".json").WriteFile([]byte(older), 0644))
+	assert.NilError(t, dir.UntypedJoin(newerID.String()+".json").WriteFile([]byte(newer), 0644))
+	assert.NilError(t, dir.UntypedJoin("broken.json").WriteFile([]byte("{"), 0644))
+
+	runs, err := ListRuns(repoRoot, 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(runs), 2)
+
+	assert.Equal(t, runs[0].ID, newerID.String())
+	assert.Equal(t, *runs[0].Duration, int64(1200))
+	assert.Equal(t, *runs[0].ExitCode, 0)
+	assert.Equal(t, runs[0].CacheHitRate, 1.0)
+	assert.Equal(t, runs[0].Environment.OS, "linux")
+
+	assert.Equal(t, runs[1].ID, olderID.String())
+	assert.Assert(t, runs[1].Duration == nil)
+	assert.Assert(t, runs[1].ExitCode == nil)
+	assert.Equal(t, runs[1].Cached, 1)
+	assert.Equal(t, runs[1].Failed, 1)
+	assert.Equal(t, runs[1].CacheHitRate, 0.5)
+
+	runs, err = ListRuns(repoRoot, 1)
+	assert.NilError(t, err)
+	assert.Equal(t, len(runs), 1)
+	assert.Equal(t, runs[0].ID, newerID.String())
+
+	runs, err = ListRuns(fs.AbsoluteSystemPathFromUpstream(t.TempDir()), 10)
+	assert.NilError(t, err)
+	assert.Equal(t, len(runs), 0)
+}
+
+func TestLastDurations(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	dir := runsDir(repoRoot)
+	assert.NilError(t, dir.MkdirAll(0755))
+
+	now := time.Now()
+	olderID, err := ksuid.NewRandomWithTime(now.Add(-time.Hour))
+	assert.NilError(t, err)
+	newerID, err := ksuid.NewRandomWithTime(now)
+	assert.NilError(t, err)
+
+	older := `{"tasks": [
+		{"taskId": "web#build", "execution": {"status": "built", "duration": 3000}},
+		{"taskId": "web#test", "execution": {"status": "built", "duration": 1500}}
+	]}`
+	newer := `{"tasks": [
+		{"taskId": "web#build", "execution": {"status": "built", "duration": 2500}},
+		{"taskId": "web#test", "execution": {"status": "cached", "duration": 20}},
+		{"taskId": "web#lint", "execution": {"status": "failed", "duration": 100}}
+	]}`
+	assert.NilError(t, dir.UntypedJoin(olderID.String()+".json").WriteFile([]byte(older), 0644))
+	assert.NilError(t, dir.UntypedJoin(newerID.String()+".json").WriteFile([]byte(newer), 0644))
+
+	durations, err := LastDurations(repoRoot, 10)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, durations, map[string]time.Duration{
+		"web#build": 2500 * time.Millisecond,
+		"web#test":  1500 * time.Millisecond,
+	})
+}
@@ -0,0 +1,40 @@
+package runsummary
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// _ansiEscape matches the color codes that tasks commonly write to their logs
+var _ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// LogExcerpt returns the last maxLines lines of a task's log file, without color
+// codes and truncated to maxBytes, for showing in a Markdown code block.
+func LogExcerpt(repoRoot turbopath.AbsoluteSystemPath, logFile string, maxLines int, maxBytes int) (string, error) {
+	f, err := os.Open(repoRoot.UntypedJoin(logFile).ToString())
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	lines := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, _ansiEscape.ReplaceAllString(scanner.Text(), ""))
+		if len(lines) > maxLines {
+			lines = lines[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	excerpt := strings.Join(lines, "\n")
+	if len(excerpt) > maxBytes {
+		excerpt = "..." + excerpt[len(excerpt)-maxBytes:]
+	}
+	// Keep log output from closing the code block it is shown in
+	return strings.ReplaceAll(excerpt, "```", "'''"), nil
+}
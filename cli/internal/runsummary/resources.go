@@ -0,0 +1,55 @@
+package runsummary
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// FormatResources describes the CPU time and peak memory of the tasks whose
+// command ran, most CPU time first, as a table for the terminal. It returns nil
+// if no command ran.
+func (summary *ExecutionSummary) FormatResources() []string {
+	tasks := []*ExecutionTaskSummary{}
+	var cpuTime int64
+	for _, task := range summary.Tasks {
+		if task.Execution != nil && task.Execution.Resources != nil {
+			tasks = append(tasks, task)
+			cpuTime += task.Execution.Resources.CPUTime
+		}
+	}
+	if len(tasks) == 0 {
+		return nil
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		a, b := tasks[i].Execution.Resources, tasks[j].Execution.Resources
+		if a.CPUTime != b.CPUTime {
+			return a.CPUTime > b.CPUTime
+		}
+		return tasks[i].TaskID < tasks[j].TaskID
+	})
+
+	table := &strings.Builder{}
+	w := tabwriter.NewWriter(table, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  Task\tCPU time\tPeak memory")
+	for _, task := range tasks {
+		resources := task.Execution.Resources
+		peakMemory := "-"
+		if resources.PeakMemory > 0 {
+			peakMemory = fmt.Sprintf("%.1f MB", megabytes(resources.PeakMemory))
+		}
+		fmt.Fprintf(w, "  %v\t%v\t%v\n", task.TaskID, formatCPUTime(resources.CPUTime), peakMemory)
+	}
+	_ = w.Flush()
+
+	lines := []string{util.Sprintf("${BOLD}Resources:${RESET} %v${GRAY} of CPU time across %v tasks${RESET}", formatCPUTime(cpuTime), len(tasks))}
+	return append(lines, strings.Split(strings.TrimSuffix(table.String(), "\n"), "\n")...)
+}
+
+func formatCPUTime(milliseconds int64) string {
+	return fmt.Sprint((time.Duration(milliseconds) * time.Millisecond).Truncate(10 * time.Millisecond))
+}
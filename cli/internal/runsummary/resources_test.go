@@ -0,0 +1,30 @@
+package runsummary
+
+import (
+	"strings"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestFormatResources(t *testing.T) {
+	summary := &ExecutionSummary{
+		Tasks: []*ExecutionTaskSummary{
+			{TaskID: "ui#build", Execution: &TaskExecutionSummary{Resources: &TaskResourceSummary{CPUTime: 1200, PeakMemory: 80 * 1024 * 1024}}},
+			{TaskID: "web#build", Execution: &TaskExecutionSummary{Resources: &TaskResourceSummary{CPUTime: 5400, PeakMemory: 512 * 1024 * 1024}}},
+			{TaskID: "web#lint", Execution: &TaskExecutionSummary{Resources: &TaskResourceSummary{CPUTime: 300}}},
+			{TaskID: "docs#build", Execution: &TaskExecutionSummary{Status: TaskStatusCached}},
+			{TaskID: "docs#test"},
+		},
+	}
+
+	lines := summary.FormatResources()
+	assert.Equal(t, len(lines), 5)
+	assert.Assert(t, strings.Contains(lines[0], "6.9s"))
+	assert.Equal(t, lines[1], "  Task       CPU time  Peak memory")
+	assert.Equal(t, lines[2], "  web#build  5.4s      512.0 MB")
+	assert.Equal(t, lines[3], "  ui#build   1.2s      80.0 MB")
+	assert.Equal(t, lines[4], "  web#lint   300ms     -")
+
+	assert.Assert(t, (&ExecutionSummary{Tasks: summary.Tasks[3:]}).FormatResources() == nil)
+}
@@ -0,0 +1,114 @@
+package runsummary
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/ksuid"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// RetentionPolicy limits the run summaries kept in .turbo/runs. A zero value
+// for either field means that limit doesn't apply.
+type RetentionPolicy struct {
+	MaxCount int
+	MaxAge   time.Duration
+}
+
+// NewRetentionPolicy creates a RetentionPolicy from the .summaries of turbo.json
+func NewRetentionPolicy(opts fs.SummariesOptions) (RetentionPolicy, error) {
+	if opts.MaxCount < 0 {
+		return RetentionPolicy{}, fmt.Errorf("summaries.maxCount must not be negative, got %v", opts.MaxCount)
+	}
+	policy := RetentionPolicy{MaxCount: opts.MaxCount}
+	if opts.MaxAge != "" {
+		maxAge, err := ParseMaxAge(opts.MaxAge)
+		if err != nil {
+			return RetentionPolicy{}, fmt.Errorf("invalid summaries.maxAge: %w", err)
+		}
+		policy.MaxAge = maxAge
+	}
+	return policy, nil
+}
+
+// IsEmpty returns true if the policy keeps every run summary
+func (p RetentionPolicy) IsEmpty() bool {
+	return p.MaxCount == 0 && p.MaxAge == 0
+}
+
+// ParseMaxAge parses a duration such as "72h". A number of days, such as "14d",
+// is also accepted since that's how retention is usually thought about.
+func ParseMaxAge(value string) (time.Duration, error) {
+	var maxAge time.Duration
+	if strings.HasSuffix(value, "d") {
+		n, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a duration", value)
+		}
+		maxAge = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, err
+		}
+		maxAge = d
+	}
+	if maxAge <= 0 {
+		return 0, fmt.Errorf("%q must be positive", value)
+	}
+	return maxAge, nil
+}
+
+// CleanRuns removes the run summaries saved in the repository that are beyond
+// the policy's limits, and returns how many were removed. An empty policy
+// removes every run summary.
+func CleanRuns(repoRoot turbopath.AbsoluteSystemPath, policy RetentionPolicy, now time.Time) (int, error) {
+	files, err := listRunSummaries(repoRoot)
+	if err != nil {
+		return 0, err
+	}
+
+	var expired []string
+	for i, file := range files {
+		if policy.IsEmpty() || isExpired(file, i+1, policy, now) {
+			expired = append(expired, file)
+		}
+	}
+	for i, file := range expired {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return i, err
+		}
+	}
+	return len(expired), nil
+}
+
+// isExpired returns true if the summary at file, which is the newest'th most
+// recent summary, is beyond the policy's limits
+func isExpired(file string, newest int, policy RetentionPolicy, now time.Time) bool {
+	if policy.MaxCount > 0 && newest > policy.MaxCount {
+		return true
+	}
+	if policy.MaxAge > 0 {
+		return now.Sub(createdAt(file)) > policy.MaxAge
+	}
+	return false
+}
+
+// createdAt returns when the summary at file was saved, from its KSUID name, or
+// from its modification time for files that weren't named by turbo
+func createdAt(file string) time.Time {
+	id, err := ksuid.Parse(strings.TrimSuffix(filepath.Base(file), ".json"))
+	if err == nil {
+		return id.Time()
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
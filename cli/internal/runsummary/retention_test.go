@@ -0,0 +1,103 @@
+package runsummary
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/segmentio/ksuid"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func TestParseMaxAge(t *testing.T) {
+	testCases := []struct {
+		value   string
+		want    time.Duration
+		wantErr string
+	}{
+		{value: "72h", want: 72 * time.Hour},
+		{value: "14d", want: 14 * 24 * time.Hour},
+		{value: "d", wantErr: `"d" is not a duration`},
+		{value: "0d", wantErr: `"0d" must be positive`},
+		{value: "soon", wantErr: `time: invalid duration "soon"`},
+	}
+	for _, tc := range testCases {
+		got, err := ParseMaxAge(tc.value)
+		if tc.wantErr != "" {
+			assert.Error(t, err, tc.wantErr)
+			continue
+		}
+		assert.NilError(t, err)
+		assert.Equal(t, got, tc.want)
+	}
+}
+
+func TestNewRetentionPolicyRejectsInvalidOptions(t *testing.T) {
+	_, err := NewRetentionPolicy(fs.SummariesOptions{MaxCount: -1})
+	assert.ErrorContains(t, err, "summaries.maxCount")
+	_, err = NewRetentionPolicy(fs.SummariesOptions{MaxAge: "forever"})
+	assert.ErrorContains(t, err, "invalid summaries.maxAge")
+}
+
+func TestCleanRuns(t *testing.T) {
+	now := time.Now()
+	ages := []time.Duration{30 * 24 * time.Hour, 10 * 24 * time.Hour, 2 * 24 * time.Hour, time.Hour, time.Minute}
+
+	testCases := []struct {
+		name   string
+		policy RetentionPolicy
+		// kept are the indexes in ages of the summaries that should remain
+		kept []int
+	}{
+		{name: "max count", policy: RetentionPolicy{MaxCount: 2}, kept: []int{3, 4}},
+		{name: "max age", policy: RetentionPolicy{MaxAge: 7 * 24 * time.Hour}, kept: []int{2, 3, 4}},
+		{name: "both", policy: RetentionPolicy{MaxCount: 4, MaxAge: 14 * 24 * time.Hour}, kept: []int{1, 2, 3, 4}},
+		{name: "empty policy removes everything", policy: RetentionPolicy{}, kept: []int{}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+			dir := runsDir(repoRoot)
+			assert.NilError(t, dir.MkdirAll(0755))
+			names := make([]string, len(ages))
+			for i, age := range ages {
+				id, err := ksuid.NewRandomWithTime(now.Add(-age))
+				assert.NilError(t, err)
+				names[i] = id.String() + ".json"
+				assert.NilError(t, dir.UntypedJoin(names[i]).WriteFile([]byte("{}"), 0644))
+			}
+
+			removed, err := CleanRuns(repoRoot, tc.policy, now)
+			assert.NilError(t, err)
+			assert.Equal(t, removed, len(ages)-len(tc.kept))
+
+			want := make([]string, len(tc.kept))
+			for i, index := range tc.kept {
+				want[i] = names[index]
+			}
+			assert.DeepEqual(t, remainingRuns(t, dir), want)
+		})
+	}
+}
+
+func TestCleanRunsWithoutRunsDir(t *testing.T) {
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	removed, err := CleanRuns(repoRoot, RetentionPolicy{MaxCount: 1}, time.Now())
+	assert.NilError(t, err)
+	assert.Equal(t, removed, 0)
+}
+
+func remainingRuns(t *testing.T, dir turbopath.AbsoluteSystemPath) []string {
+	entries, err := os.ReadDir(dir.ToString())
+	assert.NilError(t, err)
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, filepath.Base(entry.Name()))
+	}
+	sort.Strings(names)
+	return names
+}
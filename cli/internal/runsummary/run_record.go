@@ -0,0 +1,264 @@
+package runsummary
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// _runRecordVersion changes when RunRecord changes incompatibly
+const _runRecordVersion = "1"
+
+// RunRecord is the bundle written by `turbo run --record`. It holds what is needed
+// to repeat a run, and to check whether another environment computes the same
+// hashes for it. Environment variable values are only recorded as hashes.
+type RunRecord struct {
+	Version      string            `json:"version"`
+	TurboVersion string            `json:"turboVersion"`
+	RecordedAt   time.Time         `json:"recordedAt"`
+	Environment  RecordEnvironment `json:"environment"`
+	// Args are the flags of the recorded `turbo run`
+	Args              json.RawMessage    `json:"args"`
+	GlobalHash        string             `json:"globalHash"`
+	GlobalHashSummary *GlobalHashSummary `json:"globalHashSummary"`
+	// GlobalEnvVars are the hashed values from GlobalHashSummary.EnvVars
+	GlobalEnvVars []string        `json:"globalEnvVars"`
+	Tasks         []*RecordedTask `json:"tasks"`
+	tasksByID     map[string]*RecordedTask
+}
+
+// RecordEnvironment describes the machine a run was recorded on, for run records
+// and saved run summaries
+type RecordEnvironment struct {
+	OS             string `json:"os"`
+	Arch           string `json:"arch"`
+	PackageManager string `json:"packageManager"`
+	// NodeVersion is empty if node could not be run
+	NodeVersion string `json:"nodeVersion"`
+}
+
+// RecordedTask is what went into the hash of a task in a recorded run
+type RecordedTask struct {
+	TaskID                 string                                `json:"taskId"`
+	Hash                   string                                `json:"hash"`
+	Dependencies           []string                              `json:"dependencies"`
+	ResolvedTaskDefinition *fs.TaskDefinition                    `json:"resolvedTaskDefinition"`
+	ExpandedInputs         map[turbopath.AnchoredUnixPath]string `json:"expandedInputs"`
+	EnvVars                TaskEnvVarSummary                     `json:"environmentVariables"`
+	HashCommands           map[string]string                     `json:"hashCommands,omitempty"`
+}
+
+// NewRunRecord creates a RunRecord from the summary of a run, which may be a dry run
+func NewRunRecord(summary *RunSummary, globalHash string, environment RecordEnvironment, args json.RawMessage) *RunRecord {
+	globalEnvVars := []string(summary.GlobalHashSummary.EnvVars)
+	if globalEnvVars == nil {
+		globalEnvVars = []string{}
+	}
+	record := &RunRecord{
+		Version:           _runRecordVersion,
+		TurboVersion:      summary.TurboVersion,
+		RecordedAt:        time.Now().UTC(),
+		Environment:       environment,
+		Args:              args,
+		GlobalHash:        globalHash,
+		GlobalHashSummary: summary.GlobalHashSummary,
+		GlobalEnvVars:     globalEnvVars,
+		Tasks:             make([]*RecordedTask, len(summary.Tasks)),
+	}
+	for i, task := range summary.Tasks {
+		record.Tasks[i] = &RecordedTask{
+			TaskID:                 task.TaskID,
+			Hash:                   task.Hash,
+			Dependencies:           task.Dependencies,
+			ResolvedTaskDefinition: task.ResolvedTaskDefinition,
+			ExpandedInputs:         task.ExpandedInputs,
+			EnvVars:                task.EnvVars,
+			HashCommands:           task.HashCommands,
+		}
+	}
+	return record
+}
+
+// Save writes the record to the given file
+func (r *RunRecord) Save(path turbopath.AbsoluteSystemPath) error {
+	contents, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := path.EnsureDir(); err != nil {
+		return err
+	}
+	return path.WriteFile(contents, 0644)
+}
+
+// ReadRunRecord reads a record written by `turbo run --record`
+func ReadRunRecord(path turbopath.AbsoluteSystemPath) (*RunRecord, error) {
+	contents, err := path.ReadFile()
+	if err != nil {
+		return nil, err
+	}
+	record := &RunRecord{}
+	if err := json.Unmarshal(contents, record); err != nil {
+		return nil, fmt.Errorf("%v is not a run record: %w", path, err)
+	}
+	if record.Version != _runRecordVersion {
+		return nil, fmt.Errorf("%v is a version %q run record, this version of turbo reads version %q", path, record.Version, _runRecordVersion)
+	}
+	return record, nil
+}
+
+func (r *RunRecord) task(taskID string) *RecordedTask {
+	if r.tasksByID == nil {
+		r.tasksByID = make(map[string]*RecordedTask, len(r.Tasks))
+		for _, task := range r.Tasks {
+			r.tasksByID[task.TaskID] = task
+		}
+	}
+	return r.tasksByID[taskID]
+}
+
+// Compare describes how current differs from the recorded run, one difference
+// per line. It returns nothing if the environments match and every hash is the same.
+func (r *RunRecord) Compare(current *RunRecord) []string {
+	var diffs []string
+	diff := func(what string, recorded string, now string) {
+		if recorded != now {
+			diffs = append(diffs, fmt.Sprintf("%v: recorded %q, now %q", what, recorded, now))
+		}
+	}
+	diff("turbo version", r.TurboVersion, current.TurboVersion)
+	diff("os", r.Environment.OS, current.Environment.OS)
+	diff("arch", r.Environment.Arch, current.Environment.Arch)
+	diff("package manager", r.Environment.PackageManager, current.Environment.PackageManager)
+	diff("node version", r.Environment.NodeVersion, current.Environment.NodeVersion)
+
+	if r.GlobalHash != current.GlobalHash {
+		diffs = append(diffs, fmt.Sprintf("global hash: recorded %v, now %v", r.GlobalHash, current.GlobalHash))
+		for _, d := range compareFileHashes(r.GlobalHashSummary.GlobalFileHashMap, current.GlobalHashSummary.GlobalFileHashMap) {
+			diffs = append(diffs, "global file "+d)
+		}
+		for _, d := range compareEnvVars(r.GlobalEnvVars, current.GlobalEnvVars) {
+			diffs = append(diffs, "global env var "+d)
+		}
+		diff("root external dependencies hash", r.GlobalHashSummary.RootExternalDepsHash, current.GlobalHashSummary.RootExternalDepsHash)
+		diff("global cache key", r.GlobalHashSummary.GlobalCacheKey, current.GlobalHashSummary.GlobalCacheKey)
+		if !reflect.DeepEqual(r.GlobalHashSummary.Pipeline, current.GlobalHashSummary.Pipeline) {
+			diffs = append(diffs, "the root pipeline changed")
+		}
+	}
+
+	for _, recorded := range r.Tasks {
+		now := current.task(recorded.TaskID)
+		if now == nil {
+			diffs = append(diffs, fmt.Sprintf("%v: not part of this run", recorded.TaskID))
+			continue
+		}
+		if recorded.Hash == now.Hash {
+			continue
+		}
+		taskDiffs := compareFileHashes(recorded.ExpandedInputs, now.ExpandedInputs)
+		taskDiffs = append(taskDiffs, compareEnvVars(
+			append(append([]string{}, recorded.EnvVars.Configured...), recorded.EnvVars.Inferred...),
+			append(append([]string{}, now.EnvVars.Configured...), now.EnvVars.Inferred...),
+		)...)
+		if !reflect.DeepEqual(recorded.ResolvedTaskDefinition, now.ResolvedTaskDefinition) {
+			taskDiffs = append(taskDiffs, "task definition changed")
+		}
+		for _, d := range compareHashCommands(recorded.HashCommands, now.HashCommands) {
+			taskDiffs = append(taskDiffs, "hash command "+d)
+		}
+		if len(taskDiffs) == 0 {
+			for _, dep := range recorded.Dependencies {
+				if recordedDep, nowDep := r.task(dep), current.task(dep); recordedDep != nil && nowDep != nil && recordedDep.Hash != nowDep.Hash {
+					taskDiffs = append(taskDiffs, fmt.Sprintf("dependency %v changed", dep))
+				}
+			}
+		}
+		if len(taskDiffs) == 0 {
+			taskDiffs = append(taskDiffs, "hash changed")
+		}
+		for _, d := range taskDiffs {
+			diffs = append(diffs, fmt.Sprintf("%v: %v", recorded.TaskID, d))
+		}
+	}
+	for _, task := range current.Tasks {
+		if r.task(task.TaskID) == nil {
+			diffs = append(diffs, fmt.Sprintf("%v: not part of the recorded run", task.TaskID))
+		}
+	}
+	return diffs
+}
+
+// compareFileHashes describes the files that were added, removed or changed
+func compareFileHashes(recorded map[turbopath.AnchoredUnixPath]string, now map[turbopath.AnchoredUnixPath]string) []string {
+	var diffs []string
+	for file, hash := range recorded {
+		if nowHash, ok := now[file]; !ok {
+			diffs = append(diffs, fmt.Sprintf("%v removed", file))
+		} else if nowHash != hash {
+			diffs = append(diffs, fmt.Sprintf("%v changed", file))
+		}
+	}
+	for file := range now {
+		if _, ok := recorded[file]; !ok {
+			diffs = append(diffs, fmt.Sprintf("%v added", file))
+		}
+	}
+	sort.Strings(diffs)
+	return diffs
+}
+
+// compareHashCommands describes the hash commands that were added, removed or
+// whose output changed, given the hashes of their output
+func compareHashCommands(recorded map[string]string, now map[string]string) []string {
+	var diffs []string
+	for command, hash := range recorded {
+		if nowHash, ok := now[command]; !ok {
+			diffs = append(diffs, fmt.Sprintf("%q removed", command))
+		} else if nowHash != hash {
+			diffs = append(diffs, fmt.Sprintf("%q changed", command))
+		}
+	}
+	for command := range now {
+		if _, ok := recorded[command]; !ok {
+			diffs = append(diffs, fmt.Sprintf("%q added", command))
+		}
+	}
+	sort.Strings(diffs)
+	return diffs
+}
+
+// compareEnvVars describes the variables that were added, removed or changed,
+// given KEY=hash pairs
+func compareEnvVars(recorded []string, now []string) []string {
+	toMap := func(pairs []string) map[string]string {
+		m := make(map[string]string, len(pairs))
+		for _, pair := range pairs {
+			key, value, _ := strings.Cut(pair, "=")
+			m[key] = value
+		}
+		return m
+	}
+	recordedVars, nowVars := toMap(recorded), toMap(now)
+	var diffs []string
+	for key, value := range recordedVars {
+		if nowValue, ok := nowVars[key]; !ok {
+			diffs = append(diffs, fmt.Sprintf("%v unset", key))
+		} else if nowValue != value {
+			diffs = append(diffs, fmt.Sprintf("%v changed", key))
+		}
+	}
+	for key := range nowVars {
+		if _, ok := recordedVars[key]; !ok {
+			diffs = append(diffs, fmt.Sprintf("%v set", key))
+		}
+	}
+	sort.Strings(diffs)
+	return diffs
+}
@@ -0,0 +1,93 @@
+package runsummary
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func TestRunRecordCompare(t *testing.T) {
+	newRecord := func() *RunRecord {
+		return &RunRecord{
+			Version:      _runRecordVersion,
+			TurboVersion: "1.9.0",
+			Environment:  RecordEnvironment{OS: "linux", Arch: "amd64", PackageManager: "nodejs-pnpm", NodeVersion: "v18.0.0"},
+			GlobalHash:   "global",
+			GlobalHashSummary: &GlobalHashSummary{
+				GlobalFileHashMap: map[turbopath.AnchoredUnixPath]string{"tsconfig.json": "a"},
+			},
+			GlobalEnvVars: []string{"CI=1"},
+			Tasks: []*RecordedTask{
+				{
+					TaskID:                 "lib#build",
+					Hash:                   "lib",
+					ResolvedTaskDefinition: &fs.TaskDefinition{},
+					ExpandedInputs:         map[turbopath.AnchoredUnixPath]string{"src/index.ts": "a"},
+				},
+				{
+					TaskID:                 "web#build",
+					Hash:                   "web",
+					Dependencies:           []string{"lib#build"},
+					ResolvedTaskDefinition: &fs.TaskDefinition{},
+					ExpandedInputs:         map[turbopath.AnchoredUnixPath]string{"src/index.ts": "a"},
+					EnvVars:                TaskEnvVarSummary{Configured: []string{"API_URL=x"}},
+				},
+			},
+		}
+	}
+
+	t.Run("identical runs match", func(t *testing.T) {
+		assert.Equal(t, len(newRecord().Compare(newRecord())), 0)
+	})
+
+	t.Run("differences are described", func(t *testing.T) {
+		recorded := newRecord()
+		current := newRecord()
+		current.Environment.NodeVersion = "v20.0.0"
+		current.GlobalHash = "other"
+		current.GlobalHashSummary.GlobalFileHashMap["tsconfig.json"] = "b"
+		current.GlobalEnvVars = nil
+		current.Tasks[0].Hash = "lib2"
+		current.Tasks[0].ExpandedInputs = map[turbopath.AnchoredUnixPath]string{"src/index.ts": "a", "src/new.ts": "b"}
+		current.Tasks[1].Hash = "web2"
+		current.Tasks = append(current.Tasks, &RecordedTask{TaskID: "docs#build", Hash: "docs"})
+
+		assert.DeepEqual(t, recorded.Compare(current), []string{
+			`node version: recorded "v18.0.0", now "v20.0.0"`,
+			"global hash: recorded global, now other",
+			"global file tsconfig.json changed",
+			"global env var CI unset",
+			"lib#build: src/new.ts added",
+			"web#build: dependency lib#build changed",
+			"docs#build: not part of the recorded run",
+		})
+	})
+}
+
+func TestRunRecordSaveAndRead(t *testing.T) {
+	path := fs.AbsoluteSystemPathFromUpstream(t.TempDir()).UntypedJoin("records", "run.json")
+	summary := &RunSummary{
+		TurboVersion:      "1.9.0",
+		GlobalHashSummary: &GlobalHashSummary{EnvVars: []string{"CI=1"}},
+		Tasks:             []*TaskSummary{{TaskID: "web#build", Hash: "web"}},
+	}
+	record := NewRunRecord(summary, "global", RecordEnvironment{OS: "linux"}, []byte(`{"tasks":["build"]}`))
+	assert.NilError(t, record.Save(path))
+
+	read, err := ReadRunRecord(path)
+	assert.NilError(t, err)
+	assert.Equal(t, read.GlobalHash, "global")
+	assert.DeepEqual(t, read.GlobalEnvVars, []string{"CI=1"})
+	args := &bytes.Buffer{}
+	assert.NilError(t, json.Compact(args, read.Args))
+	assert.Equal(t, args.String(), `{"tasks":["build"]}`)
+	assert.Equal(t, len(record.Compare(read)), 0)
+
+	assert.NilError(t, path.WriteFile([]byte(`{"version":"0"}`), 0644))
+	_, err = ReadRunRecord(path)
+	assert.ErrorContains(t, err, `version "0" run record`)
+}
@@ -7,7 +7,9 @@ import (
 
 	"github.com/segmentio/ksuid"
 	"github.com/vercel/turbo/cli/internal/cache"
+	"github.com/vercel/turbo/cli/internal/cachecheck"
 	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/testmap"
 	"github.com/vercel/turbo/cli/internal/turbopath"
 	"github.com/vercel/turbo/cli/internal/util"
 )
@@ -27,6 +29,24 @@ type RunSummary struct {
 	GlobalHashSummary *GlobalHashSummary `json:"globalHashSummary"`
 	Packages          []string           `json:"packages"`
 	Tasks             []*TaskSummary     `json:"tasks"`
+	// ExitCode is missing for dry runs
+	ExitCode *ExitCodeSummary `json:"exitCode,omitempty"`
+	// Execution and Environment are only filled in for summaries that are saved
+	Execution   *ExecutionStats    `json:"execution,omitempty"`
+	Environment *RecordEnvironment `json:"environment,omitempty"`
+	// Warnings are the outputs and inputs of tasks that are at odds with git
+	Warnings []*cachecheck.Warning `json:"warnings,omitempty"`
+	// TestSelection is why each of the tasks of the --test-map runs or not
+	TestSelection []*testmap.Selection `json:"testSelection,omitempty"`
+}
+
+// ExitCodeSummary records the exit code of a run and how it was chosen
+type ExitCodeSummary struct {
+	Code int `json:"code"`
+	// Mode is "highest", "first", "fixed" or "bitmask", from --exit-code
+	Mode string `json:"mode"`
+	// Reason explains the code of a failed run
+	Reason string `json:"reason,omitempty"`
 }
 
 // NewRunSummary returns a RunSummary instance
@@ -88,6 +108,119 @@ type TaskSummary struct {
 	ExpandedInputs         map[turbopath.AnchoredUnixPath]string `json:"expandedInputs"`
 	Framework              string                                `json:"framework"`
 	EnvVars                TaskEnvVarSummary                     `json:"environmentVariables"`
+	Execution              *TaskExecutionSummary                 `json:"execution,omitempty"`
+	// FlakeRate is the fraction of this task's executions that failed for a hash
+	// that has also succeeded, across the persisted run summaries
+	FlakeRate float64 `json:"flakeRate,omitempty"`
+	// InputsHash is the hash of the files in ExpandedInputs
+	InputsHash string `json:"inputsHash"`
+	// ExpectedCacheStatus is set by dry runs: "hit" if a real run is expected to
+	// restore the task from the cache, or else why it is expected to miss:
+	// "cache_disabled", "forced" or "no_cache_entry"
+	ExpectedCacheStatus string `json:"expectedCacheStatus,omitempty"`
+	// AssumedFresh are the dependencies that --only didn't run, on the
+	// assumption that their outputs are up to date
+	AssumedFresh []string `json:"assumedFresh,omitempty"`
+	// FallbackHash is the hash of the task with the "hashing.fallbackVersion" of
+	// turbo.json, whose cache entry is restored if there is none for Hash
+	FallbackHash string `json:"fallbackHash,omitempty"`
+	// HashCommands are the hashes of the output of the task's "hashCommands",
+	// by command
+	HashCommands map[string]string `json:"hashCommands,omitempty"`
+	// WorkingDir is the directory the task's command runs in, relative to the
+	// repository root, when its "cwd" moves it out of Dir
+	WorkingDir string `json:"workingDirectory,omitempty"`
+}
+
+// Statuses for TaskExecutionSummary
+const (
+	TaskStatusBuilt  = "built"
+	TaskStatusCached = "cached"
+	TaskStatusFailed = "failed"
+	// TaskStatusTimedOut is for tasks that failed because their command ran for
+	// longer than their "timeout"
+	TaskStatusTimedOut = "timedOut"
+	// TaskStatusSkipped is for tasks that didn't run because a task they
+	// depend on failed
+	TaskStatusSkipped = "skipped"
+	// TaskStatusCanceled is for tasks that were stopped, or never started,
+	// because the run was interrupted
+	TaskStatusCanceled = "canceled"
+)
+
+// TaskExecutionSummary contains the outcome of running a task
+type TaskExecutionSummary struct {
+	// StartTime is in milliseconds since the unix epoch, or 0 for skipped tasks
+	StartTime int64 `json:"startTime"`
+	// Duration is in milliseconds. For tasks that timed out, it is how long
+	// their command ran before it was stopped.
+	Duration int64  `json:"duration"`
+	Status   string `json:"status"`
+	// ExitCode is missing if the task failed without running a command, or
+	// timed out
+	ExitCode *int   `json:"exitCode,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// CacheMissReason is why a task that executed wasn't restored from the
+	// cache: "hash_changed", "no_cache_entry", "corrupted_artifact", "forced" or
+	// "cache_disabled"
+	CacheMissReason string `json:"cacheMissReason,omitempty"`
+	// Determinism is set with --check-determinism for tasks that hit the cache
+	// and were executed anyway
+	Determinism *TaskDeterminismSummary `json:"determinism,omitempty"`
+	// OutputSize is set for tasks with a "maxOutputSize" that finished
+	OutputSize *TaskOutputSizeSummary `json:"outputSize,omitempty"`
+	// RemoteCache lists the downloads and uploads of the task's artifact
+	RemoteCache []*RemoteCacheTransfer `json:"remoteCache,omitempty"`
+	// Attempts lists every run of the command of a task with "retries"
+	Attempts []*TaskAttemptSummary `json:"attempts,omitempty"`
+	// Resources is set for tasks whose command exited
+	Resources *TaskResourceSummary `json:"resources,omitempty"`
+	// Command is the command line that ran the task's script, after its
+	// "shell" was resolved
+	Command []string `json:"command,omitempty"`
+	// FailedDependencies are the failed tasks that a skipped task depends on,
+	// directly or transitively
+	FailedDependencies []string `json:"failedDependencies,omitempty"`
+	// FailureBundle is the path of the zip file with the context of a failed
+	// task, from --failure-bundles
+	FailureBundle string `json:"failureBundle,omitempty"`
+}
+
+// TaskAttemptSummary is one run of the command of a task that can be retried
+type TaskAttemptSummary struct {
+	// Duration is in milliseconds
+	Duration int64 `json:"duration"`
+	// ExitCode is missing if the command failed without exiting
+	ExitCode *int `json:"exitCode,omitempty"`
+}
+
+// TaskResourceSummary is what the command of a task used, along with the
+// processes it started and waited for. The attempts of a task with "retries"
+// are added up.
+type TaskResourceSummary struct {
+	// CPUTime is the user and system CPU time in milliseconds
+	CPUTime int64 `json:"cpuTime"`
+	// PeakMemory is the largest resident set size of the processes in bytes.
+	// It is missing on Windows, where it isn't measured.
+	PeakMemory int64 `json:"peakMemory,omitempty"`
+}
+
+// TaskDeterminismSummary compares the outputs of a task that was executed on a
+// cache hit with the outputs restored from the cache
+type TaskDeterminismSummary struct {
+	Deterministic bool `json:"deterministic"`
+	// ChangedOutputs are the output files that were added, removed or modified
+	// by executing the task, from the repository root
+	ChangedOutputs []string `json:"changedOutputs"`
+}
+
+// TaskOutputSizeSummary compares the size of the outputs of a task with its
+// "maxOutputSize"
+type TaskOutputSizeSummary struct {
+	// Bytes is the size of the output files, other than the log file
+	Bytes    int64 `json:"bytes"`
+	MaxBytes int64 `json:"maxBytes"`
+	Exceeded bool  `json:"exceeded"`
 }
 
 // TaskEnvVarSummary contains the environment variables that impacted a task's hash
@@ -95,6 +228,8 @@ type TaskEnvVarSummary struct {
 	Configured []string `json:"configured"`
 	Inferred   []string `json:"inferred"`
 	Global     []string `json:"global"`
+	// PassThrough are the variables from "passThroughEnv", which don't affect the hash
+	PassThrough []string `json:"passthrough"`
 }
 
 // toSinglePackageTask converts a TaskSummary into a singlePackageTaskSummary
@@ -107,6 +242,10 @@ func (ht *TaskSummary) toSinglePackageTask() singlePackageTaskSummary {
 	for i, dependent := range ht.Dependents {
 		dependents[i] = util.StripPackageName(dependent)
 	}
+	var assumedFresh []string
+	for _, dependency := range ht.AssumedFresh {
+		assumedFresh = append(assumedFresh, util.StripPackageName(dependency))
+	}
 
 	return singlePackageTaskSummary{
 		Task:                   util.RootTaskTaskName(ht.TaskID),
@@ -121,5 +260,12 @@ func (ht *TaskSummary) toSinglePackageTask() singlePackageTaskSummary {
 		Framework:              ht.Framework,
 		ExpandedInputs:         ht.ExpandedInputs,
 		EnvVars:                ht.EnvVars,
+		Execution:              ht.Execution,
+		FlakeRate:              ht.FlakeRate,
+		InputsHash:             ht.InputsHash,
+		ExpectedCacheStatus:    ht.ExpectedCacheStatus,
+		AssumedFresh:           assumedFresh,
+		HashCommands:           ht.HashCommands,
+		WorkingDir:             ht.WorkingDir,
 	}
 }
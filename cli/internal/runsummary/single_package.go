@@ -10,7 +10,9 @@ import (
 // to the internal struct for a single package. It's likely that we can use the
 // same struct for Single Package repos in the future.
 type singlePackageRunSummary struct {
-	Tasks []singlePackageTaskSummary `json:"tasks"`
+	Tasks       []singlePackageTaskSummary `json:"tasks"`
+	Execution   *ExecutionStats            `json:"execution,omitempty"`
+	Environment *RecordEnvironment         `json:"environment,omitempty"`
 }
 
 // singlePackageTaskSummary is generally identical to TaskSummary, except that it doesn't contain
@@ -30,4 +32,11 @@ type singlePackageTaskSummary struct {
 	ExpandedInputs         map[turbopath.AnchoredUnixPath]string `json:"expandedInputs"`
 	Framework              string                                `json:"framework"`
 	EnvVars                TaskEnvVarSummary                     `json:"environmentVariables"`
+	Execution              *TaskExecutionSummary                 `json:"execution,omitempty"`
+	FlakeRate              float64                               `json:"flakeRate,omitempty"`
+	InputsHash             string                                `json:"inputsHash"`
+	ExpectedCacheStatus    string                                `json:"expectedCacheStatus,omitempty"`
+	AssumedFresh           []string                              `json:"assumedFresh,omitempty"`
+	HashCommands           map[string]string                     `json:"hashCommands,omitempty"`
+	WorkingDir             string                                `json:"workingDirectory,omitempty"`
 }
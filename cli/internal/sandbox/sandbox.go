@@ -0,0 +1,144 @@
+// Package sandbox runs tasks in a temporary copy of the repository that only
+// holds the files their hash covers. A task that reads any other file fails,
+// instead of producing outputs that the cache can't tell apart from the ones
+// of a different version of that file.
+//
+// Dependencies are not copied: node_modules directories are recreated with
+// links to the installed packages, which the hash covers through the lockfile.
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/globby"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// Sandbox is a temporary copy of part of a repository
+type Sandbox struct {
+	repoRoot turbopath.AbsoluteSystemPath
+	// Root has the same layout as the repository
+	Root turbopath.AbsoluteSystemPath
+}
+
+// New creates a sandbox with a copy of each of files, given relative to the
+// repository root, and creates each of dirs with its node_modules directory.
+// Files that no longer exist are skipped, and so are repeated dirs.
+func New(repoRoot turbopath.AbsoluteSystemPath, files []turbopath.AnchoredUnixPath, dirs []turbopath.AnchoredSystemPath) (*Sandbox, error) {
+	root, err := os.MkdirTemp("", "turbo-sandbox-*")
+	if err != nil {
+		return nil, err
+	}
+	s := &Sandbox{
+		repoRoot: repoRoot,
+		Root:     fs.AbsoluteSystemPathFromUpstream(root),
+	}
+	if err := s.populate(files, dirs); err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Sandbox) populate(files []turbopath.AnchoredUnixPath, dirs []turbopath.AnchoredSystemPath) error {
+	for _, file := range files {
+		from := &fs.LstatCachedFile{Path: file.ToSystemPath().RestoreAnchor(s.repoRoot)}
+		to := file.ToSystemPath().RestoreAnchor(s.Root)
+		if err := fs.CopyFile(from, to.ToString()); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return errors.Wrapf(err, "failed to copy %v to the sandbox", file)
+		}
+	}
+	mirrored := make(util.Set)
+	for _, dir := range dirs {
+		key := filepath.Clean(dir.ToString())
+		if mirrored.Includes(key) {
+			continue
+		}
+		mirrored.Add(key)
+		if err := dir.RestoreAnchor(s.Root).MkdirAll(fs.DirPermissions); err != nil {
+			return err
+		}
+		nodeModules := dir.Join("node_modules")
+		if err := s.mirror(nodeModules.RestoreAnchor(s.repoRoot), nodeModules.RestoreAnchor(s.Root)); err != nil {
+			return errors.Wrapf(err, "failed to link %v into the sandbox", nodeModules)
+		}
+	}
+	return nil
+}
+
+// mirror creates a link in the sandboxed directory to each entry of the real
+// one. Symlinks are copied as they are, so that the links that package managers
+// create to workspaces lead to the sandbox. Scopes are mirrored too, since they
+// hold links to workspaces as well.
+func (s *Sandbox) mirror(real turbopath.AbsoluteSystemPath, sandboxed turbopath.AbsoluteSystemPath) error {
+	entries, err := os.ReadDir(real.ToString())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := sandboxed.MkdirAll(fs.DirPermissions); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		from := real.UntypedJoin(entry.Name())
+		to := sandboxed.UntypedJoin(entry.Name())
+		switch {
+		case entry.Type()&os.ModeSymlink != 0:
+			target, err := from.Readlink()
+			if err != nil {
+				return err
+			}
+			if filepath.IsAbs(target) {
+				if relative, err := s.repoRoot.RelativePathString(target); err == nil && !strings.HasPrefix(relative, "..") {
+					target = s.Root.UntypedJoin(relative).ToString()
+				}
+			}
+			if err := to.Symlink(target); err != nil {
+				return err
+			}
+		case entry.IsDir() && strings.HasPrefix(entry.Name(), "@"):
+			if err := s.mirror(from, to); err != nil {
+				return err
+			}
+		default:
+			if err := to.Symlink(from.ToString()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CopyOutputs copies the files in the sandbox that match the given globs, which
+// are relative to the repository root, back to the repository
+func (s *Sandbox) CopyOutputs(outputs fs.TaskOutputs) error {
+	files, err := globby.GlobFiles(s.Root.ToStringDuringMigration(), outputs.Inclusions, outputs.Exclusions)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		relative, err := s.Root.RelativePathString(file)
+		if err != nil {
+			return err
+		}
+		from := &fs.LstatCachedFile{Path: fs.UnsafeToAbsoluteSystemPath(file)}
+		if err := fs.CopyFile(from, s.repoRoot.UntypedJoin(relative).ToString()); err != nil {
+			return errors.Wrapf(err, "failed to copy %v from the sandbox", relative)
+		}
+	}
+	return nil
+}
+
+// Close removes the sandbox
+func (s *Sandbox) Close() error {
+	return s.Root.RemoveAll()
+}
@@ -0,0 +1,68 @@
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func writeFile(t *testing.T, path turbopath.AbsoluteSystemPath, contents string) {
+	t.Helper()
+	assert.NilError(t, path.EnsureDir())
+	assert.NilError(t, path.WriteFile([]byte(contents), 0644))
+}
+
+func TestSandbox(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks requires privileges on Windows")
+	}
+	repoRoot := fs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	writeFile(t, repoRoot.UntypedJoin("package.json"), "{}")
+	writeFile(t, repoRoot.UntypedJoin("apps", "web", "package.json"), "{}")
+	writeFile(t, repoRoot.UntypedJoin("apps", "web", "src", "index.ts"), "index")
+	writeFile(t, repoRoot.UntypedJoin("apps", "web", "undeclared.ts"), "undeclared")
+	writeFile(t, repoRoot.UntypedJoin("packages", "ui", "index.ts"), "ui")
+	writeFile(t, repoRoot.UntypedJoin("node_modules", "react", "index.js"), "react")
+	assert.NilError(t, repoRoot.UntypedJoin("node_modules", "@acme").MkdirAll(0755))
+	assert.NilError(t, repoRoot.UntypedJoin("node_modules", "@acme", "ui").Symlink(filepath.Join("..", "..", "packages", "ui")))
+
+	s, err := New(
+		repoRoot,
+		[]turbopath.AnchoredUnixPath{"package.json", "apps/web/package.json", "apps/web/src/index.ts", "packages/ui/index.ts", "apps/web/deleted.ts"},
+		[]turbopath.AnchoredSystemPath{"", turbopath.AnchoredUnixPath("apps/web").ToSystemPath(), "."},
+	)
+	assert.NilError(t, err)
+	defer func() { assert.NilError(t, s.Close()) }()
+
+	contents, err := s.Root.UntypedJoin("apps", "web", "src", "index.ts").ReadFile()
+	assert.NilError(t, err)
+	assert.Equal(t, string(contents), "index")
+	assert.Assert(t, !s.Root.UntypedJoin("apps", "web", "undeclared.ts").Exists())
+
+	// Installed packages are linked, and workspaces resolve to the sandbox
+	contents, err = s.Root.UntypedJoin("node_modules", "react", "index.js").ReadFile()
+	assert.NilError(t, err)
+	assert.Equal(t, string(contents), "react")
+	uiDir, err := filepath.EvalSymlinks(s.Root.UntypedJoin("node_modules", "@acme", "ui").ToString())
+	assert.NilError(t, err)
+	sandboxRoot, err := filepath.EvalSymlinks(s.Root.ToString())
+	assert.NilError(t, err)
+	assert.Equal(t, uiDir, filepath.Join(sandboxRoot, "packages", "ui"))
+
+	writeFile(t, s.Root.UntypedJoin("apps", "web", "dist", "index.js"), "built")
+	writeFile(t, s.Root.UntypedJoin("apps", "web", "dist", "index.js.map"), "map")
+	assert.NilError(t, s.CopyOutputs(fs.TaskOutputs{
+		Inclusions: []string{filepath.Join("apps", "web", "dist", "**")},
+		Exclusions: []string{filepath.Join("apps", "web", "dist", "*.map")},
+	}))
+	contents, err = repoRoot.UntypedJoin("apps", "web", "dist", "index.js").ReadFile()
+	assert.NilError(t, err)
+	assert.Equal(t, string(contents), "built")
+	_, err = os.Stat(repoRoot.UntypedJoin("apps", "web", "dist", "index.js.map").ToString())
+	assert.Assert(t, os.IsNotExist(err))
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/pyr-sh/dag"
 	"github.com/vercel/turbo/cli/internal/doublestar"
+	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/turbopath"
 	"github.com/vercel/turbo/cli/internal/util"
 	"github.com/vercel/turbo/cli/internal/workspace"
@@ -21,6 +22,10 @@ type SelectedPackages struct {
 // packages that have changed in a particular range of git refs.
 type PackagesChangedInRange = func(fromRef string, toRef string) (util.Set, error)
 
+// ResolveTaskDefinition is the signature of a function to provide the
+// definition of a task in a package, which is nil if no pipeline defines it.
+type ResolveTaskDefinition = func(pkgName string, taskName string) (*fs.TaskDefinition, error)
+
 // PackageInference holds the information we have inferred from the working-directory
 // (really --infer-filter-root flag) about which packages are of interest.
 type PackageInference struct {
@@ -40,6 +45,9 @@ type Resolver struct {
 	Cwd                    turbopath.AbsoluteSystemPath
 	Inference              *PackageInference
 	PackagesChangedInRange PackagesChangedInRange
+	// ResolveTaskDefinition, if set, lets task filters match the packages
+	// whose task has a "command" in the pipeline rather than a script
+	ResolveTaskDefinition ResolveTaskDefinition
 }
 
 // GetPackagesFromPatterns compiles filter patterns and applies them, returning
@@ -163,20 +171,14 @@ func (r *Resolver) filterGraph(selectors []*TargetSelector) (*SelectedPackages,
 func (r *Resolver) filterGraphWithSelectors(selectors []*TargetSelector) (*SelectedPackages, error) {
 	unmatchedSelectors := []*TargetSelector{}
 
-	cherryPickedPackages := make(dag.Set)
-	walkedDependencies := make(dag.Set)
-	walkedDependents := make(dag.Set)
-	walkedDependentsDependencies := make(dag.Set)
-
+	allPkgs := make(util.Set)
 	for _, selector := range selectors {
 		// TODO(gsoltis): this should be a list?
 		entryPackages, err := r.filterGraphWithSelector(selector)
 		if err != nil {
 			return nil, err
 		}
-		if entryPackages.Len() == 0 {
-			unmatchedSelectors = append(unmatchedSelectors, selector)
-		}
+		selectedPackages := make(util.Set)
 		for _, pkg := range entryPackages {
 			if selector.includeDependencies {
 				dependencies, err := r.Graph.Ancestors(pkg)
@@ -184,10 +186,10 @@ func (r *Resolver) filterGraphWithSelectors(selectors []*TargetSelector) (*Selec
 					return nil, errors.Wrapf(err, "failed to get dependencies of package %v", pkg)
 				}
 				for dep := range dependencies {
-					walkedDependencies.Add(dep)
+					selectedPackages.Add(dep)
 				}
 				if !selector.excludeSelf {
-					walkedDependencies.Add(pkg)
+					selectedPackages.Add(pkg)
 				}
 			}
 			if selector.includeDependents {
@@ -196,38 +198,37 @@ func (r *Resolver) filterGraphWithSelectors(selectors []*TargetSelector) (*Selec
 					return nil, errors.Wrapf(err, "failed to get dependents of package %v", pkg)
 				}
 				for dep := range dependents {
-					walkedDependents.Add(dep)
+					selectedPackages.Add(dep)
 					if selector.includeDependencies {
 						dependentDeps, err := r.Graph.Ancestors(dep)
 						if err != nil {
 							return nil, errors.Wrapf(err, "failed to get dependencies of dependent %v", dep)
 						}
 						for dependentDep := range dependentDeps {
-							walkedDependentsDependencies.Add(dependentDep)
+							selectedPackages.Add(dependentDep)
 						}
 					}
 				}
 				if !selector.excludeSelf {
-					walkedDependents.Add(pkg)
+					selectedPackages.Add(pkg)
 				}
 			}
 			if !selector.includeDependencies && !selector.includeDependents {
-				cherryPickedPackages.Add(pkg)
+				selectedPackages.Add(pkg)
 			}
 		}
-	}
-	allPkgs := make(util.Set)
-	for pkg := range cherryPickedPackages {
-		allPkgs.Add(pkg)
-	}
-	for pkg := range walkedDependencies {
-		allPkgs.Add(pkg)
-	}
-	for pkg := range walkedDependents {
-		allPkgs.Add(pkg)
-	}
-	for pkg := range walkedDependentsDependencies {
-		allPkgs.Add(pkg)
+		// The task applies to the dependencies and dependents that were walked
+		// as well as to the packages the selector matched
+		selectedPackages, err = r.filterPackagesWithTask(selector.taskName, selectedPackages)
+		if err != nil {
+			return nil, err
+		}
+		if entryPackages.Len() == 0 || (selector.taskName != "" && selectedPackages.Len() == 0) {
+			unmatchedSelectors = append(unmatchedSelectors, selector)
+		}
+		for pkg := range selectedPackages {
+			allPkgs.Add(pkg)
+		}
 	}
 	return &SelectedPackages{
 		pkgs:          allPkgs,
@@ -242,6 +243,48 @@ func (r *Resolver) filterGraphWithSelector(selector *TargetSelector) (util.Set,
 	return r.filterNodesWithSelector(selector)
 }
 
+// filterPackagesWithTask returns the packages that have a command for the given
+// task, or all of the packages if no task was given
+func (r *Resolver) filterPackagesWithTask(taskName string, packages util.Set) (util.Set, error) {
+	if taskName == "" {
+		return packages, nil
+	}
+	matched := make(util.Set)
+	for _, pkg := range packages {
+		pkgName, ok := pkg.(string)
+		if !ok {
+			continue
+		}
+		if pkgJSON, ok := r.WorkspaceInfos.PackageJSONs[pkgName]; ok {
+			hasCommand, err := r.hasCommand(pkgName, pkgJSON, taskName)
+			if err != nil {
+				return nil, err
+			}
+			if hasCommand {
+				matched.Add(pkgName)
+			}
+		}
+	}
+	return matched, nil
+}
+
+// hasCommand returns whether the task has a command to run in the package: a
+// script, or a "command" in the pipeline. The root package only runs the tasks
+// that its own scripts define.
+func (r *Resolver) hasCommand(pkgName string, pkgJSON *fs.PackageJSON, taskName string) (bool, error) {
+	taskDefinition := &fs.TaskDefinition{}
+	if r.ResolveTaskDefinition != nil && pkgName != util.RootPkgName {
+		resolved, err := r.ResolveTaskDefinition(pkgName, taskName)
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to resolve %v", util.GetTaskId(pkgName, taskName))
+		}
+		if resolved != nil {
+			taskDefinition = resolved
+		}
+	}
+	return taskDefinition.HasCommand(pkgJSON, taskName), nil
+}
+
 // filterNodesWithSelector returns the set of nodes that match a given selector
 func (r *Resolver) filterNodesWithSelector(selector *TargetSelector) (util.Set, error) {
 	entryPackages := make(util.Set)
@@ -313,6 +356,14 @@ func (r *Resolver) filterNodesWithSelector(selector *TargetSelector) (util.Set,
 			entryPackages = matched
 		}
 	}
+	if !selectorWasUsed && selector.taskName != "" {
+		// only a task was given, start from every package and let the
+		// task narrow them down
+		for name := range r.WorkspaceInfos.PackageJSONs {
+			entryPackages.Add(name)
+		}
+		selectorWasUsed = true
+	}
 	// TODO(gsoltis): we can do this earlier
 	// Check if the selector specified anything
 	if !selectorWasUsed {
@@ -335,7 +386,7 @@ func (r *Resolver) filterSubtreesWithSelector(selector *TargetSelector) (util.Se
 	for name, pkg := range r.WorkspaceInfos.PackageJSONs {
 		if parentDir == "" {
 			entryPackages.Add(name)
-		} else if matches, err := doublestar.PathMatch(parentDir.ToString(), pkg.Dir.RestoreAnchor(r.Cwd).ToString()); err != nil {
+		} else if matches, err := doublestar.PathMatch(r.Cwd.Join(parentDir).ToString(), pkg.Dir.RestoreAnchor(r.Cwd).ToString()); err != nil {
 			return nil, fmt.Errorf("failed to resolve directory relationship %v contains %v: %v", selector.parentDir, pkg.Dir, err)
 		} else if matches {
 			entryPackages.Add(name)
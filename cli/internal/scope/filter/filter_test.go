@@ -49,13 +49,15 @@ func Test_filter(t *testing.T) {
 	}
 	graph.Add("project-1")
 	packageJSONs["project-1"] = &fs.PackageJSON{
-		Name: "project-1",
-		Dir:  turbopath.AnchoredUnixPath("packages/project-1").ToSystemPath(),
+		Name:    "project-1",
+		Dir:     turbopath.AnchoredUnixPath("packages/project-1").ToSystemPath(),
+		Scripts: map[string]string{"test": "jest"},
 	}
 	graph.Add("project-2")
 	packageJSONs["project-2"] = &fs.PackageJSON{
-		Name: "project-2",
-		Dir:  "project-2",
+		Name:    "project-2",
+		Dir:     "project-2",
+		Scripts: map[string]string{"test": "jest"},
 	}
 	graph.Add("project-3")
 	packageJSONs["project-3"] = &fs.PackageJSON{
@@ -75,8 +77,9 @@ func Test_filter(t *testing.T) {
 	// Note: inside project-5
 	graph.Add("project-6")
 	packageJSONs["project-6"] = &fs.PackageJSON{
-		Name: "project-6",
-		Dir:  turbopath.AnchoredUnixPath("project-5/packages/project-6").ToSystemPath(),
+		Name:    "project-6",
+		Dir:     turbopath.AnchoredUnixPath("project-5/packages/project-6").ToSystemPath(),
+		Scripts: map[string]string{"test": "jest"},
 	}
 	// Add dependencies
 	graph.Connect(dag.BasicEdge("project-0", "project-1"))
@@ -227,6 +230,50 @@ func Test_filter(t *testing.T) {
 			nil,
 			[]string{"project-5"},
 		},
+		{
+			"select packages that define a task",
+			[]*TargetSelector{
+				{
+					taskName: "test",
+				},
+			},
+			nil,
+			[]string{"project-1", "project-2", "project-6"},
+		},
+		{
+			"select by parentDir and task",
+			[]*TargetSelector{
+				{
+					parentDir: turbopath.MakeRelativeSystemPath("packages", "*"),
+					taskName:  "test",
+				},
+			},
+			nil,
+			[]string{"project-1"},
+		},
+		{
+			"select dependencies that define a task",
+			[]*TargetSelector{
+				{
+					includeDependencies: true,
+					namePattern:         "project-0",
+					taskName:            "test",
+				},
+			},
+			nil,
+			[]string{"project-1", "project-2"},
+		},
+		{
+			"select all packages except those that define a task",
+			[]*TargetSelector{
+				{
+					exclude:  true,
+					taskName: "test",
+				},
+			},
+			nil,
+			[]string{"project-0", "project-3", "project-4", "project-5"},
+		},
 		{
 			"select all packages except one",
 			[]*TargetSelector{
@@ -306,6 +353,32 @@ func Test_filter(t *testing.T) {
 		})
 	}
 
+	t.Run("select packages whose task has a command", func(t *testing.T) {
+		r := &Resolver{
+			Graph:          graph,
+			WorkspaceInfos: workspaceInfos,
+			Cwd:            root,
+			ResolveTaskDefinition: func(pkgName string, taskName string) (*fs.TaskDefinition, error) {
+				switch pkgName {
+				case "project-3":
+					return &fs.TaskDefinition{Command: "jest"}, nil
+				case "project-4":
+					return &fs.TaskDefinition{}, nil
+				}
+				return nil, nil
+			},
+		}
+		pkgs, err := r.getFilteredPackages([]*TargetSelector{
+			{
+				taskName: "test",
+			},
+		})
+		if err != nil {
+			t.Fatalf("task filter failed to filter packages: %v", err)
+		}
+		setMatches(t, "task with a command", pkgs.pkgs, []string{"project-1", "project-2", "project-3", "project-6"})
+	})
+
 	t.Run("report unmatched filters", func(t *testing.T) {
 		r := &Resolver{
 			Graph:          graph,
@@ -19,11 +19,12 @@ type TargetSelector struct {
 	namePattern         string
 	fromRef             string
 	toRefOverride       string
+	taskName            string
 	raw                 string
 }
 
 func (ts *TargetSelector) IsValid() bool {
-	return ts.fromRef != "" || ts.parentDir != "" || ts.namePattern != ""
+	return ts.fromRef != "" || ts.parentDir != "" || ts.namePattern != "" || ts.taskName != ""
 }
 
 // getToRef returns the git ref to use for upper bound of the comparison when finding changed
@@ -65,6 +66,15 @@ func ParseTargetSelector(rawSelector string) (*TargetSelector, error) {
 			selector = selector[1:]
 		}
 	}
+	// A trailing #<task> only selects packages that define the task
+	taskName := ""
+	if i := strings.LastIndex(selector, "#"); i != -1 {
+		taskName = selector[i+1:]
+		if taskName == "" {
+			return nil, errors.New("empty task specification")
+		}
+		selector = selector[:i]
+	}
 
 	matches := targetSelectorRegex.FindAllStringSubmatch(selector, -1)
 
@@ -72,9 +82,11 @@ func ParseTargetSelector(rawSelector string) (*TargetSelector, error) {
 		if relativePath, ok := isSelectorByLocation(selector); ok {
 			return &TargetSelector{
 				exclude:             exclude,
+				excludeSelf:         excludeSelf,
 				includeDependencies: includeDependencies,
 				includeDependents:   includeDependents,
 				parentDir:           relativePath,
+				taskName:            taskName,
 				raw:                 rawSelector,
 			}, nil
 		}
@@ -84,6 +96,7 @@ func ParseTargetSelector(rawSelector string) (*TargetSelector, error) {
 			includeDependencies: includeDependencies,
 			includeDependents:   includeDependents,
 			namePattern:         selector,
+			taskName:            taskName,
 			raw:                 rawSelector,
 		}, nil
 	}
@@ -138,6 +151,7 @@ func ParseTargetSelector(rawSelector string) (*TargetSelector, error) {
 		includeDependents:   includeDependents,
 		namePattern:         namePattern,
 		parentDir:           parentDir,
+		taskName:            taskName,
 		raw:                 rawSelector,
 	}, nil
 }
@@ -285,6 +285,53 @@ func TestParseTargetSelector(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"./apps/**",
+			&TargetSelector{
+				parentDir: turbopath.MakeRelativeSystemPath("apps", "**"),
+			},
+			false,
+		},
+		{
+			"#test",
+			&TargetSelector{
+				taskName: "test",
+			},
+			false,
+		},
+		{
+			"./apps/**#test",
+			&TargetSelector{
+				parentDir: turbopath.MakeRelativeSystemPath("apps", "**"),
+				taskName:  "test",
+			},
+			false,
+		},
+		{
+			"...^@acme/ui#test",
+			&TargetSelector{
+				excludeSelf:       true,
+				includeDependents: true,
+				namePattern:       "@acme/ui",
+				taskName:          "test",
+			},
+			false,
+		},
+		{
+			"{apps/*}[main]#build...",
+			&TargetSelector{
+				fromRef:             "main",
+				includeDependencies: true,
+				parentDir:           turbopath.MakeRelativeSystemPath("apps", "*"),
+				taskName:            "build",
+			},
+			false,
+		},
+		{
+			"foo#",
+			&TargetSelector{},
+			true,
+		},
 		{
 			"......[master]",
 			&TargetSelector{},
@@ -11,6 +11,9 @@ import (
 	"github.com/mitchellh/cli"
 	"github.com/pkg/errors"
 	"github.com/vercel/turbo/cli/internal/context"
+	"github.com/vercel/turbo/cli/internal/core"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/graph"
 	"github.com/vercel/turbo/cli/internal/lockfile"
 	"github.com/vercel/turbo/cli/internal/scm"
 	scope_filter "github.com/vercel/turbo/cli/internal/scope/filter"
@@ -54,6 +57,9 @@ type Opts struct {
 	GlobalDepPatterns []string
 	// Patterns are the filter patterns supplied to --filter on the commandline
 	FilterPatterns []string
+	// AffectedBase is the git ref that --affected compares against, and is empty
+	// without --affected
+	AffectedBase string
 
 	PackageInferenceRoot string
 }
@@ -76,9 +82,37 @@ func OptsFromArgs(opts *Opts, args *turbostate.ParsedArgsFromRust) {
 	opts.IgnorePatterns = args.Command.Run.Ignore
 	opts.GlobalDepPatterns = args.Command.Run.GlobalDeps
 	opts.PackageInferenceRoot = args.Command.Run.PkgInferenceRoot
+	if args.Command.Run.Affected {
+		opts.AffectedBase = affectedBase(args.Command.Run.AffectedBase)
+	}
 	addLegacyFlagsFromArgs(&opts.LegacyFilter, args)
 }
 
+// _scmBaseEnvVar sets the default ref for --affected, e.g. in CI
+const _scmBaseEnvVar = "TURBO_SCM_BASE"
+
+// affectedBase returns the ref that --affected compares against
+func affectedBase(flag string) string {
+	if flag != "" {
+		return flag
+	}
+	if base := os.Getenv(_scmBaseEnvVar); base != "" {
+		return base
+	}
+	return "main"
+}
+
+// affectedFilterPattern selects the packages with changes since the base ref,
+// and their dependents unless --no-deps is set. Changed files are found by the
+// same means as --filter=[ref], so lockfile and global dependency changes are
+// handled the same way.
+func (o *Opts) affectedFilterPattern() string {
+	if o.LegacyFilter.SkipDependents {
+		return fmt.Sprintf("[%v]", o.AffectedBase)
+	}
+	return fmt.Sprintf("...[%v]", o.AffectedBase)
+}
+
 // asFilterPatterns normalizes legacy selectors to filter syntax
 func (l *LegacyFilter) asFilterPatterns() []string {
 	var patterns []string
@@ -123,21 +157,46 @@ func ResolvePackages(opts *Opts, repoRoot turbopath.AbsoluteSystemPath, scm scm.
 	if err != nil {
 		return nil, false, err
 	}
+	// Task filters resolve task definitions as `turbo run` does, since tasks
+	// with a "command" run in packages without a script of their name
+	engine := core.NewEngine(&graph.CompleteGraph{
+		WorkspaceGraph: ctx.WorkspaceGraph,
+		WorkspaceInfos: ctx.WorkspaceInfos,
+		RootNode:       ctx.RootNode,
+		RepoRoot:       repoRoot,
+	}, false)
 	filterResolver := &scope_filter.Resolver{
 		Graph:                  &ctx.WorkspaceGraph,
 		WorkspaceInfos:         ctx.WorkspaceInfos,
 		Cwd:                    repoRoot,
 		Inference:              inferenceBase,
 		PackagesChangedInRange: opts.getPackageChangeFunc(scm, repoRoot, ctx),
+		ResolveTaskDefinition: func(pkgName string, taskName string) (*fs.TaskDefinition, error) {
+			taskDefinition, _, err := engine.ResolveTaskDefinition(pkgName, taskName)
+			return taskDefinition, err
+		},
 	}
 	filterPatterns := opts.FilterPatterns
 	legacyFilterPatterns := opts.LegacyFilter.asFilterPatterns()
 	filterPatterns = append(filterPatterns, legacyFilterPatterns...)
+	// --affected narrows down the packages that the other filters select,
+	// rather than adding the affected packages to them
+	narrowToAffected := opts.AffectedBase != "" && len(filterPatterns) > 0
+	if opts.AffectedBase != "" && !narrowToAffected {
+		filterPatterns = append(filterPatterns, opts.affectedFilterPattern())
+	}
 	isAllPackages := len(filterPatterns) == 0 && opts.PackageInferenceRoot == ""
 	filteredPkgs, err := filterResolver.GetPackagesFromPatterns(filterPatterns)
 	if err != nil {
 		return nil, false, err
 	}
+	if narrowToAffected {
+		affectedPkgs, err := filterResolver.GetPackagesFromPatterns([]string{opts.affectedFilterPattern()})
+		if err != nil {
+			return nil, false, err
+		}
+		filteredPkgs = filteredPkgs.Intersection(affectedPkgs)
+	}
 
 	if isAllPackages {
 		// no filters specified, run every package
@@ -237,6 +296,30 @@ func (o *Opts) getPackageChangeFunc(scm scm.SCM, cwd turbopath.AbsoluteSystemPat
 	}
 }
 
+// AffectedFiles returns the files that changed since the --affected base ref,
+// as repo-relative unix paths, without the ones that match --ignore. It also
+// returns whether a global dependency changed, which affects every task.
+func AffectedFiles(opts *Opts, scm scm.SCM, repoRoot turbopath.AbsoluteSystemPath) ([]string, bool, error) {
+	changedFiles, err := scm.ChangedFiles(opts.AffectedBase, "", true, repoRoot.ToStringDuringMigration())
+	if err != nil {
+		return nil, false, err
+	}
+	globalChanged, err := repoGlobalFileHasChanged(opts, getDefaultGlobalDeps(), changedFiles)
+	if err != nil {
+		return nil, false, err
+	}
+	filteredChangedFiles, err := filterIgnoredFiles(opts, changedFiles)
+	if err != nil {
+		return nil, false, err
+	}
+	files := make([]string, len(filteredChangedFiles))
+	for i, file := range filteredChangedFiles {
+		files[i] = filepath.ToSlash(file)
+	}
+	sort.Strings(files)
+	return files, globalChanged, nil
+}
+
 func getChangesFromLockfile(scm scm.SCM, ctx *context.Context, changedFiles []string, fromRef string) ([]string, bool) {
 	lockfileFilter, err := filter.Compile([]string{ctx.PackageManager.Lockfile})
 	if err != nil {
@@ -253,7 +336,8 @@ func getChangesFromLockfile(scm scm.SCM, ctx *context.Context, changedFiles []st
 		return nil, false
 	}
 
-	if lockfile.IsNil(ctx.Lockfile) {
+	currentLockfile, err := ctx.LoadLockfile()
+	if err != nil || lockfile.IsNil(currentLockfile) {
 		return nil, true
 	}
 
@@ -262,7 +346,9 @@ func getChangesFromLockfile(scm scm.SCM, ctx *context.Context, changedFiles []st
 		// unable to reconstruct old lockfile, assume everything changed
 		return nil, true
 	}
-	prevLockfile, err := ctx.PackageManager.UnmarshalLockfile(prevContents)
+	// The current root package.json stands in for the previous one, which
+	// changing would select every package anyway
+	prevLockfile, err := ctx.PackageManager.UnmarshalLockfile(ctx.WorkspaceInfos.PackageJSONs[util.RootPkgName], prevContents)
 	if err != nil {
 		// unable to parse old lockfile, assume everything changed
 		return nil, true
@@ -222,6 +222,7 @@ func TestResolvePackages(t *testing.T) {
 	testCases := []struct {
 		name                string
 		changed             []string
+		filter              []string
 		expected            []string
 		expectAllPackages   bool
 		scope               []string
@@ -234,6 +235,7 @@ func TestResolvePackages(t *testing.T) {
 		currLockfile        *mockLockfile
 		prevLockfile        *mockLockfile
 		inferPkgPath        string
+		affected            string
 	}{
 		{
 			name:                "Just scope and dependencies",
@@ -286,6 +288,42 @@ func TestResolvePackages(t *testing.T) {
 			expected: []string{"libB"},
 			since:    "dummy",
 		},
+		{
+			name:              "Affected package and its dependents",
+			changed:           []string{"libs/libB/src/index.ts"},
+			expected:          []string{"libB", "libA", "app0", "app1", "app2"},
+			affected:          "main",
+			includeDependents: true,
+		},
+		{
+			name:     "Affected package with --no-deps",
+			changed:  []string{"libs/libB/src/index.ts"},
+			expected: []string{"libB"},
+			affected: "main",
+		},
+		{
+			name:              "Affected packages among the filtered ones",
+			changed:           []string{"libs/libB/src/index.ts"},
+			filter:            []string{"./app/*"},
+			expected:          []string{"app0", "app1", "app2"},
+			affected:          "main",
+			includeDependents: true,
+		},
+		{
+			name:     "Affected packages among the filtered ones with --no-deps",
+			changed:  []string{"libs/libB/src/index.ts", "libs/libD/src/index.ts"},
+			filter:   []string{"app2-a", "libC", "libD"},
+			expected: []string{"libD"},
+			affected: "main",
+		},
+		{
+			name:              "Affected packages after a lockfile change",
+			changed:           []string{"package-lock.json"},
+			expected:          []string{"//", "app0", "app1", "app2", "app2-a", "libA", "libB", "libC", "libD"},
+			affected:          "main",
+			includeDependents: true,
+			lockfile:          "package-lock.json",
+		},
 		{
 			name:     "One package manifest changed",
 			changed:  []string{"libs/libB/package.json"},
@@ -507,10 +545,11 @@ func TestResolvePackages(t *testing.T) {
 			for _, path := range systemSeparatorChanged {
 				scm.contents[path] = nil
 			}
-			readLockfile := func(contents []byte) (lockfile.Lockfile, error) {
+			readLockfile := func(_rootPackageJSON *fs.PackageJSON, contents []byte) (lockfile.Lockfile, error) {
 				return tc.prevLockfile, nil
 			}
 			pkgs, isAllPackages, err := ResolvePackages(&Opts{
+				FilterPatterns: tc.filter,
 				LegacyFilter: LegacyFilter{
 					Entrypoints:         tc.scope,
 					Since:               tc.since,
@@ -520,6 +559,7 @@ func TestResolvePackages(t *testing.T) {
 				IgnorePatterns:       []string{tc.ignore},
 				GlobalDepPatterns:    tc.globalDeps,
 				PackageInferenceRoot: tc.inferPkgPath,
+				AffectedBase:         tc.affected,
 			}, root, scm, &context.Context{
 				WorkspaceInfos: workspaceInfos,
 				WorkspaceNames: packageNames,
@@ -544,3 +584,17 @@ func TestResolvePackages(t *testing.T) {
 		})
 	}
 }
+
+func TestAffectedBase(t *testing.T) {
+	t.Setenv(_scmBaseEnvVar, "")
+	if got := affectedBase(""); got != "main" {
+		t.Errorf("affectedBase(\"\") got %v, want main", got)
+	}
+	t.Setenv(_scmBaseEnvVar, "origin/develop")
+	if got := affectedBase(""); got != "origin/develop" {
+		t.Errorf("affectedBase(\"\") got %v, want origin/develop", got)
+	}
+	if got := affectedBase("HEAD^"); got != "HEAD^" {
+		t.Errorf("affectedBase(\"HEAD^\") got %v, want HEAD^", got)
+	}
+}
@@ -0,0 +1,127 @@
+// Package serve implements `turbo serve`, which exposes a local HTTP API for
+// submitting runs, streaming their output and fetching their summaries. It lets
+// developer portals and bots drive turbo without parsing its terminal output.
+//
+// Every request must carry the server's token as a bearer token. The token is
+// read from TURBO_SERVE_TOKEN, or generated and printed at startup.
+package serve
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/run"
+	"github.com/vercel/turbo/cli/internal/signals"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+	"github.com/vercel/turbo/cli/internal/ui"
+)
+
+const _tokenEnvVar = "TURBO_SERVE_TOKEN"
+
+// ExecuteServe executes the `serve` command.
+func ExecuteServe(ctx context.Context, helper *cmdutil.Helper, signalWatcher *signals.Watcher, args *turbostate.ParsedArgsFromRust) error {
+	base, err := helper.GetCmdBase(args)
+	if err != nil {
+		return err
+	}
+	if err := runServer(ctx, base, signalWatcher, args); err != nil {
+		base.LogError("serve failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+func runServer(ctx context.Context, base *cmdutil.CmdBase, signalWatcher *signals.Watcher, args *turbostate.ParsedArgsFromRust) error {
+	servePayload := args.Command.Serve
+	token := os.Getenv(_tokenEnvVar)
+	generatedToken := token == ""
+	if generatedToken {
+		var err error
+		token, err = generateToken()
+		if err != nil {
+			return err
+		}
+	}
+
+	server := newServer(token, runTasks(base, signalWatcher, args), base.Logger.Named("serve"))
+
+	lis, err := net.Listen("tcp", servePayload.Listen)
+	if err != nil {
+		return err
+	}
+	httpServer := &http.Server{
+		Handler:           server,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go server.processRuns(runCtx)
+
+	base.UI.Info(fmt.Sprintf("Listening on http://%v", lis.Addr()))
+	if generatedToken {
+		base.UI.Info(fmt.Sprintf("Token: %v %v", token, ui.Dim(fmt.Sprintf("(set %v to choose the token)", _tokenEnvVar))))
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		if err := httpServer.Serve(lis); err != nil && err != http.ErrServerClosed {
+			errCh <- err
+		}
+		close(errCh)
+	}()
+
+	select {
+	case err, ok := <-errCh:
+		if ok {
+			return err
+		}
+	case <-ctx.Done():
+	case <-signalWatcher.Done():
+	}
+	cancel()
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		// Clients that are still streaming events are disconnected
+		return httpServer.Close()
+	}
+	return nil
+}
+
+// runTasks returns a runFunc that runs tasks in the repository of base, sending
+// all output, including the output of tasks, to the UI of the run
+func runTasks(base *cmdutil.CmdBase, signalWatcher *signals.Watcher, args *turbostate.ParsedArgsFromRust) runFunc {
+	return func(ctx context.Context, output cli.Ui, payload *turbostate.RunPayload) ([]byte, error) {
+		// The repository doesn't change between runs, so clients don't decide this
+		payload.SinglePackage = args.Command.Serve.SinglePackage
+		runBase := *base
+		runBase.UI = output
+		runArgs := *args
+		runArgs.Command = turbostate.Command{Run: payload}
+		summary, err := run.RunTasks(ctx, &runBase, signalWatcher, &runArgs, ui.OutputWriter(output))
+		if summary == nil {
+			return nil, err
+		}
+		summaryJSON, summaryErr := summary.FormatJSON(payload.SinglePackage)
+		if summaryErr != nil {
+			base.Logger.Warn("failed to format run summary", "error", summaryErr)
+		}
+		return summaryJSON, err
+	}
+}
+
+func generateToken() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
@@ -0,0 +1,380 @@
+package serve
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/mitchellh/cli"
+	"github.com/segmentio/ksuid"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+)
+
+// Statuses of a run
+const (
+	StatusQueued  = "queued"
+	StatusRunning = "running"
+	StatusPassed  = "passed"
+	StatusFailed  = "failed"
+)
+
+// Types of events
+const (
+	EventOutput = "output"
+	EventInfo   = "info"
+	EventWarn   = "warn"
+	EventError  = "error"
+	EventStatus = "status"
+)
+
+// _maxQueuedRuns is how many runs can be waiting to start before new requests are rejected
+const _maxQueuedRuns = 64
+
+// Finished runs are kept so that clients can fetch their events and summaries.
+// Beyond _maxFinishedRuns, or after _finishedRunTTL, the oldest are forgotten.
+const (
+	_maxFinishedRuns = 100
+	_finishedRunTTL  = time.Hour
+)
+
+// Event is a line of output from a run, or a change in its status
+type Event struct {
+	Type    string `json:"type"`
+	Message string `json:"message,omitempty"`
+	Status  string `json:"status,omitempty"`
+	// Time is in milliseconds since the unix epoch
+	Time int64 `json:"time"`
+}
+
+// RunInfo describes a run that was submitted to the server
+type RunInfo struct {
+	ID        string   `json:"id"`
+	Status    string   `json:"status"`
+	Tasks     []string `json:"tasks"`
+	CreatedAt int64    `json:"createdAt"`
+	Error     string   `json:"error,omitempty"`
+	// Summary is the run summary, in the same format as `turbo run --summarize`.
+	// It is only set once the run has finished executing tasks.
+	Summary json.RawMessage `json:"summary,omitempty"`
+}
+
+// runFunc runs the tasks described by payload, writing output to the given UI.
+// It returns the JSON encoded run summary, if tasks were executed.
+type runFunc func(ctx context.Context, output cli.Ui, payload *turbostate.RunPayload) ([]byte, error)
+
+type runRecord struct {
+	mu      sync.Mutex
+	info    RunInfo
+	payload *turbostate.RunPayload
+	events  []Event
+	// changed is closed and replaced whenever an event is added
+	changed chan struct{}
+	// finishedAt is set once the run has passed or failed
+	finishedAt time.Time
+}
+
+func (r *runRecord) addEvent(event Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	event.Time = time.Now().UnixMilli()
+	r.events = append(r.events, event)
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+func (r *runRecord) setStatus(status string) {
+	r.mu.Lock()
+	r.info.Status = status
+	r.mu.Unlock()
+	r.addEvent(Event{Type: EventStatus, Status: status})
+}
+
+func (r *runRecord) snapshot() RunInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.info
+}
+
+func (r *runRecord) isDone() bool {
+	return r.info.Status == StatusPassed || r.info.Status == StatusFailed
+}
+
+// Server accepts run requests over HTTP and runs them one at a time
+type Server struct {
+	token  string
+	run    runFunc
+	logger hclog.Logger
+
+	mu    sync.Mutex
+	runs  map[string]*runRecord
+	queue chan *runRecord
+}
+
+func newServer(token string, run runFunc, logger hclog.Logger) *Server {
+	return &Server{
+		token:  token,
+		run:    run,
+		logger: logger,
+		runs:   make(map[string]*runRecord),
+		queue:  make(chan *runRecord, _maxQueuedRuns),
+	}
+}
+
+// processRuns runs queued runs in order until ctx is done. Runs are not executed
+// concurrently since they would race on the outputs of the same tasks.
+func (s *Server) processRuns(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case record := <-s.queue:
+			s.execute(ctx, record)
+		}
+	}
+}
+
+func (s *Server) execute(ctx context.Context, record *runRecord) {
+	s.logger.Debug("starting run", "id", record.info.ID)
+	record.setStatus(StatusRunning)
+	summary, err := s.run(ctx, &eventUI{record: record}, record.payload)
+	record.mu.Lock()
+	record.info.Summary = summary
+	if err != nil {
+		record.info.Error = err.Error()
+	}
+	record.finishedAt = time.Now()
+	record.mu.Unlock()
+	if err != nil {
+		record.setStatus(StatusFailed)
+	} else {
+		record.setStatus(StatusPassed)
+	}
+	s.logger.Debug("finished run", "id", record.info.ID, "error", err)
+	s.evictFinishedRuns(time.Now())
+}
+
+// evictFinishedRuns forgets finished runs that are older than _finishedRunTTL,
+// along with the oldest finished runs beyond _maxFinishedRuns. Clients that are
+// streaming the events of an evicted run keep receiving them.
+func (s *Server) evictFinishedRuns(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var finished []*runRecord
+	for id, record := range s.runs {
+		record.mu.Lock()
+		finishedAt := record.finishedAt
+		record.mu.Unlock()
+		if finishedAt.IsZero() {
+			continue
+		}
+		if now.Sub(finishedAt) > _finishedRunTTL {
+			delete(s.runs, id)
+			continue
+		}
+		finished = append(finished, record)
+	}
+	if len(finished) <= _maxFinishedRuns {
+		return
+	}
+	// IDs are KSUIDs, which sort by creation time
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].info.ID < finished[j].info.ID
+	})
+	for _, record := range finished[:len(finished)-_maxFinishedRuns] {
+		delete(s.runs, record.info.ID)
+	}
+}
+
+// ServeHTTP implements http.Handler
+//
+//	POST /runs              submit a run. The body has the same fields as `turbo run`
+//	GET  /runs              list runs, newest first
+//	GET  /runs/{id}         get the status and summary of a run
+//	GET  /runs/{id}/events  stream the events of a run as newline-delimited JSON
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !s.authorized(req) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, errors.New("missing or invalid token"))
+		return
+	}
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "runs" && req.Method == http.MethodPost:
+		s.submitRun(w, req)
+	case len(parts) == 1 && parts[0] == "runs" && req.Method == http.MethodGet:
+		s.listRuns(w)
+	case len(parts) == 2 && parts[0] == "runs" && req.Method == http.MethodGet:
+		s.getRun(w, parts[1])
+	case len(parts) == 3 && parts[0] == "runs" && parts[2] == "events" && req.Method == http.MethodGet:
+		s.streamEvents(w, req, parts[1])
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("no route for %v %v", req.Method, req.URL.Path))
+	}
+}
+
+func (s *Server) authorized(req *http.Request) bool {
+	header := req.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return false
+	}
+	token := strings.TrimPrefix(header, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+func (s *Server) submitRun(w http.ResponseWriter, req *http.Request) {
+	payload := &turbostate.RunPayload{}
+	if err := json.NewDecoder(req.Body).Decode(payload); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid run request: %w", err))
+		return
+	}
+	if len(payload.Tasks) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("at least one task must be specified"))
+		return
+	}
+	if payload.Graph != nil {
+		writeError(w, http.StatusBadRequest, errors.New("graph is not supported by the server"))
+		return
+	}
+	record := &runRecord{
+		info: RunInfo{
+			ID:        ksuid.New().String(),
+			Status:    StatusQueued,
+			Tasks:     payload.Tasks,
+			CreatedAt: time.Now().UnixMilli(),
+		},
+		payload: payload,
+		changed: make(chan struct{}),
+	}
+	s.mu.Lock()
+	s.runs[record.info.ID] = record
+	s.mu.Unlock()
+	select {
+	case s.queue <- record:
+	default:
+		s.mu.Lock()
+		delete(s.runs, record.info.ID)
+		s.mu.Unlock()
+		writeError(w, http.StatusServiceUnavailable, errors.New("too many runs are queued"))
+		return
+	}
+	writeJSON(w, http.StatusAccepted, record.snapshot())
+}
+
+func (s *Server) listRuns(w http.ResponseWriter) {
+	s.evictFinishedRuns(time.Now())
+	s.mu.Lock()
+	runs := make([]RunInfo, 0, len(s.runs))
+	for _, record := range s.runs {
+		info := record.snapshot()
+		// Summaries can be large, fetch them for a single run instead
+		info.Summary = nil
+		runs = append(runs, info)
+	}
+	s.mu.Unlock()
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].ID > runs[j].ID
+	})
+	writeJSON(w, http.StatusOK, runs)
+}
+
+func (s *Server) getRecord(w http.ResponseWriter, id string) *runRecord {
+	s.mu.Lock()
+	record, ok := s.runs[id]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no run with id %v", id))
+		return nil
+	}
+	return record
+}
+
+func (s *Server) getRun(w http.ResponseWriter, id string) {
+	if record := s.getRecord(w, id); record != nil {
+		writeJSON(w, http.StatusOK, record.snapshot())
+	}
+}
+
+// streamEvents writes every event of the run so far, then follows new events
+// until the run finishes or the client disconnects
+func (s *Server) streamEvents(w http.ResponseWriter, req *http.Request, id string) {
+	record := s.getRecord(w, id)
+	if record == nil {
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	next := 0
+	for {
+		record.mu.Lock()
+		events := record.events[next:]
+		next = len(record.events)
+		changed := record.changed
+		done := record.isDone()
+		record.mu.Unlock()
+
+		for _, event := range events {
+			if err := encoder.Encode(event); err != nil {
+				return
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if done {
+			return
+		}
+		select {
+		case <-changed:
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// eventUI is a cli.Ui that records output as events of a run
+type eventUI struct {
+	record *runRecord
+}
+
+var _ cli.Ui = (*eventUI)(nil)
+
+func (u *eventUI) add(eventType string, message string) {
+	for _, line := range strings.Split(strings.TrimRight(message, "\n"), "\n") {
+		u.record.addEvent(Event{Type: eventType, Message: line})
+	}
+}
+
+func (u *eventUI) Output(message string) { u.add(EventOutput, message) }
+func (u *eventUI) Info(message string)   { u.add(EventInfo, message) }
+func (u *eventUI) Warn(message string)   { u.add(EventWarn, message) }
+func (u *eventUI) Error(message string)  { u.add(EventError, message) }
+
+// Ask is not supported, since there is no one to answer
+func (u *eventUI) Ask(query string) (string, error) {
+	return "", errors.New("input is not available for runs started by turbo serve")
+}
+
+// AskSecret is not supported, since there is no one to answer
+func (u *eventUI) AskSecret(query string) (string, error) {
+	return u.Ask(query)
+}
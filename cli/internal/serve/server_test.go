@@ -0,0 +1,238 @@
+package serve
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/mitchellh/cli"
+	"github.com/segmentio/ksuid"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/signals"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+	"github.com/vercel/turbo/cli/internal/ui"
+	"gotest.tools/v3/assert"
+)
+
+const _testToken = "secret"
+
+func startServer(t *testing.T, run runFunc) *httptest.Server {
+	t.Helper()
+	server := newServer(_testToken, run, hclog.NewNullLogger())
+	ctx, cancel := context.WithCancel(context.Background())
+	go server.processRuns(ctx)
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(func() {
+		httpServer.Close()
+		cancel()
+	})
+	return httpServer
+}
+
+func request(t *testing.T, method string, url string, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	assert.NilError(t, err)
+	req.Header.Set("Authorization", "Bearer "+_testToken)
+	resp, err := http.DefaultClient.Do(req)
+	assert.NilError(t, err)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp
+}
+
+func decode(t *testing.T, resp *http.Response, value interface{}) {
+	t.Helper()
+	assert.NilError(t, json.NewDecoder(resp.Body).Decode(value))
+}
+
+// readEvents reads the event stream of a run until the run finishes
+func readEvents(t *testing.T, baseURL string, id string) []Event {
+	t.Helper()
+	resp := request(t, http.MethodGet, baseURL+"/runs/"+id+"/events", "")
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+	var events []Event
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		event := Event{}
+		assert.NilError(t, json.Unmarshal(scanner.Bytes(), &event))
+		event.Time = 0
+		events = append(events, event)
+	}
+	assert.NilError(t, scanner.Err())
+	return events
+}
+
+func TestRequiresToken(t *testing.T) {
+	httpServer := startServer(t, nil)
+	for _, header := range []string{"", "Bearer wrong", _testToken} {
+		req, err := http.NewRequest(http.MethodGet, httpServer.URL+"/runs", nil)
+		assert.NilError(t, err)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.NilError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, resp.StatusCode, http.StatusUnauthorized, header)
+	}
+	resp := request(t, http.MethodGet, httpServer.URL+"/runs", "")
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+}
+
+func TestSubmitRun(t *testing.T) {
+	var received *turbostate.RunPayload
+	httpServer := startServer(t, func(ctx context.Context, output cli.Ui, payload *turbostate.RunPayload) ([]byte, error) {
+		received = payload
+		output.Output("building\ndone")
+		output.Warn("careful")
+		return []byte(`{"tasks":[]}`), nil
+	})
+
+	resp := request(t, http.MethodPost, httpServer.URL+"/runs", `{"tasks": ["build"], "filter": ["web"], "force": true}`)
+	assert.Equal(t, resp.StatusCode, http.StatusAccepted)
+	submitted := RunInfo{}
+	decode(t, resp, &submitted)
+	assert.Equal(t, submitted.Status, StatusQueued)
+	assert.DeepEqual(t, submitted.Tasks, []string{"build"})
+
+	events := readEvents(t, httpServer.URL, submitted.ID)
+	assert.DeepEqual(t, events, []Event{
+		{Type: EventStatus, Status: StatusRunning},
+		{Type: EventOutput, Message: "building"},
+		{Type: EventOutput, Message: "done"},
+		{Type: EventWarn, Message: "careful"},
+		{Type: EventStatus, Status: StatusPassed},
+	})
+	assert.DeepEqual(t, received.Filter, []string{"web"})
+	assert.Equal(t, received.Force, true)
+
+	resp = request(t, http.MethodGet, httpServer.URL+"/runs/"+submitted.ID, "")
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+	finished := RunInfo{}
+	decode(t, resp, &finished)
+	assert.Equal(t, finished.Status, StatusPassed)
+	assert.Equal(t, string(finished.Summary), `{"tasks":[]}`)
+
+	resp = request(t, http.MethodGet, httpServer.URL+"/runs", "")
+	var runs []RunInfo
+	decode(t, resp, &runs)
+	assert.Equal(t, len(runs), 1)
+	assert.Equal(t, runs[0].ID, submitted.ID)
+	assert.Assert(t, runs[0].Summary == nil)
+}
+
+func TestFailedRun(t *testing.T) {
+	httpServer := startServer(t, func(ctx context.Context, output cli.Ui, payload *turbostate.RunPayload) ([]byte, error) {
+		return nil, errors.New("command exited (1)")
+	})
+	resp := request(t, http.MethodPost, httpServer.URL+"/runs", `{"tasks": ["test"]}`)
+	submitted := RunInfo{}
+	decode(t, resp, &submitted)
+	events := readEvents(t, httpServer.URL, submitted.ID)
+	assert.Equal(t, events[len(events)-1].Status, StatusFailed)
+
+	resp = request(t, http.MethodGet, httpServer.URL+"/runs/"+submitted.ID, "")
+	finished := RunInfo{}
+	decode(t, resp, &finished)
+	assert.Equal(t, finished.Error, "command exited (1)")
+}
+
+func TestInvalidRequests(t *testing.T) {
+	httpServer := startServer(t, nil)
+	testCases := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{http.MethodPost, "/runs", `{}`, http.StatusBadRequest},
+		{http.MethodPost, "/runs", `not json`, http.StatusBadRequest},
+		{http.MethodPost, "/runs", `{"tasks": ["build"], "graph": ""}`, http.StatusBadRequest},
+		{http.MethodGet, "/runs/unknown", "", http.StatusNotFound},
+		{http.MethodGet, "/runs/unknown/events", "", http.StatusNotFound},
+		{http.MethodDelete, "/runs", "", http.StatusNotFound},
+	}
+	for _, tc := range testCases {
+		resp := request(t, tc.method, httpServer.URL+tc.path, tc.body)
+		assert.Equal(t, resp.StatusCode, tc.status, "%v %v %v", tc.method, tc.path, tc.body)
+	}
+}
+
+func TestEvictFinishedRuns(t *testing.T) {
+	server := newServer(_testToken, nil, hclog.NewNullLogger())
+	now := time.Now()
+	addRun := func(finishedAt time.Time) string {
+		id := ksuid.New().String()
+		server.runs[id] = &runRecord{info: RunInfo{ID: id}, finishedAt: finishedAt}
+		return id
+	}
+	running := addRun(time.Time{})
+	expired := addRun(now.Add(-2 * _finishedRunTTL))
+	var finished []string
+	for i := 0; i < _maxFinishedRuns+1; i++ {
+		finished = append(finished, addRun(now))
+	}
+
+	server.evictFinishedRuns(now)
+	_, ok := server.runs[running]
+	assert.Assert(t, ok, "runs that haven't finished are kept")
+	_, ok = server.runs[expired]
+	assert.Assert(t, !ok, "expired runs are evicted")
+	_, ok = server.runs[finished[0]]
+	assert.Assert(t, !ok, "the oldest finished run is evicted")
+	assert.Equal(t, len(server.runs), _maxFinishedRuns+1)
+}
+
+func TestRunRealTask(t *testing.T) {
+	if _, err := exec.LookPath("npm"); err != nil {
+		t.Skip("npm is required to run tasks")
+	}
+	repoRoot := t.TempDir()
+	files := map[string]string{
+		"package.json":              `{"name": "root", "packageManager": "npm@8.19.2", "workspaces": ["packages/*"]}`,
+		"turbo.json":                `{"pipeline": {"build": {"outputs": []}}}`,
+		"packages/web/package.json": `{"name": "web", "scripts": {"build": "echo hello from build"}}`,
+	}
+	for path, contents := range files {
+		file := filepath.Join(repoRoot, path)
+		assert.NilError(t, os.MkdirAll(filepath.Dir(file), 0755))
+		assert.NilError(t, os.WriteFile(file, []byte(contents), 0644))
+	}
+	args := &turbostate.ParsedArgsFromRust{
+		CWD:     repoRoot,
+		NoColor: true,
+		Command: turbostate.Command{Serve: &turbostate.ServePayload{}},
+	}
+	helper := cmdutil.NewHelper("test", args)
+	base, err := helper.GetCmdBase(args)
+	assert.NilError(t, err)
+	t.Cleanup(func() { helper.Cleanup(args) })
+	signalWatcher := signals.NewContextWatcher(context.Background())
+	t.Cleanup(signalWatcher.Close)
+	httpServer := startServer(t, runTasks(base, signalWatcher, args))
+
+	resp := request(t, http.MethodPost, httpServer.URL+"/runs", `{"tasks": ["build"], "no_daemon": true, "cache_workers": 1}`)
+	assert.Equal(t, resp.StatusCode, http.StatusAccepted)
+	submitted := RunInfo{}
+	decode(t, resp, &submitted)
+
+	events := readEvents(t, httpServer.URL, submitted.ID)
+	assert.Equal(t, events[len(events)-1].Status, StatusPassed, "%v", events)
+	found := false
+	for _, event := range events {
+		if event.Type == EventOutput && strings.Contains(ui.StripAnsi(event.Message), "web:build: hello from build") {
+			found = true
+		}
+	}
+	assert.Assert(t, found, "the output of the task should be an event: %v", events)
+}
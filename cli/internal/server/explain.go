@@ -0,0 +1,45 @@
+package server
+
+import (
+	"context"
+
+	"github.com/vercel/turbo/cli/internal/runsummary"
+	"github.com/vercel/turbo/cli/internal/turbodprotocol"
+)
+
+// TaskExplainer explains whether a task, given as <package>#<task>, would be
+// restored from the cache, comparing it with its last run in the most recent
+// maxRuns run summaries saved in the repository. It is implemented by the run
+// package, which depends on the daemon.
+type TaskExplainer func(ctx context.Context, taskID string, maxRuns int) (*runsummary.TaskExplanation, error)
+
+// SetTaskExplainer sets what answers the ExplainTask rpc, which is
+// unimplemented until then
+func (s *Server) SetTaskExplainer(explainer TaskExplainer) {
+	s.explainMu.Lock()
+	defer s.explainMu.Unlock()
+	s.explainTask = explainer
+}
+
+// explainTaskResponse converts an explanation for an ExplainTask response
+func explainTaskResponse(explanation *runsummary.TaskExplanation) *turbodprotocol.ExplainTaskResponse {
+	resp := &turbodprotocol.ExplainTaskResponse{
+		TaskId:              explanation.TaskID,
+		Hash:                explanation.Hash,
+		Local:               explanation.CacheState.Local,
+		Remote:              explanation.CacheState.Remote,
+		ExpectedCacheStatus: explanation.ExpectedCacheStatus,
+		Reason:              explanation.Reason,
+		GlobalChanges:       explanation.GlobalChanges,
+		Changes:             explanation.Changes,
+	}
+	if previous := explanation.PreviousRun; previous != nil {
+		resp.PreviousRun = &turbodprotocol.PreviousTaskRun{
+			RunId:         previous.RunID,
+			CreatedAtMsec: uint64(previous.CreatedAt.UnixMilli()),
+			Hash:          previous.Hash,
+			Status:        previous.Status,
+		}
+	}
+	return resp
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/pkg/errors"
+	"github.com/vercel/turbo/cli/internal/doublestar"
+	"github.com/vercel/turbo/cli/internal/globby"
+	"github.com/vercel/turbo/cli/internal/hashing"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// globalFiles tracks the hashes of the global dependencies of the repository,
+// so that `turbo run` doesn't hash every one of them again. Only the files that
+// changed are hashed again, and the globs are only expanded again after a
+// change that could add or remove a file.
+type globalFiles struct {
+	logger   hclog.Logger
+	repoRoot turbopath.AbsoluteSystemPath
+	// glob and hashFiles are replaced in tests
+	glob      func(repoRoot turbopath.AbsoluteSystemPath, globs []string, ignores []string) ([]turbopath.AbsoluteSystemPath, error)
+	hashFiles func(repoRoot turbopath.AbsoluteSystemPath, files []turbopath.AbsoluteSystemPath) (map[turbopath.AnchoredUnixPath]string, error)
+
+	mu sync.Mutex
+	// sets are keyed by the globs, ignores and files that they were asked for
+	// with, which only change when turbo.json does
+	sets map[string]*globalFileSet
+}
+
+type globalFileSet struct {
+	globs   []string
+	ignores []string
+	files   []string
+	hashes  map[turbopath.AnchoredUnixPath]string
+	// stale is set until the globs are expanded, and after a change that could
+	// add or remove a file
+	stale bool
+	// dirty are the hashed files with changes since they were hashed
+	dirty util.Set
+}
+
+func newGlobalFiles(logger hclog.Logger, repoRoot turbopath.AbsoluteSystemPath) *globalFiles {
+	return &globalFiles{
+		logger:    logger,
+		repoRoot:  repoRoot,
+		glob:      globFiles,
+		hashFiles: hashing.GetHashableDeps,
+		sets:      make(map[string]*globalFileSet),
+	}
+}
+
+// globFiles expands the globs from the repository root, as `turbo run` does
+func globFiles(repoRoot turbopath.AbsoluteSystemPath, globs []string, ignores []string) ([]turbopath.AbsoluteSystemPath, error) {
+	if len(globs) == 0 {
+		return nil, nil
+	}
+	files, err := globby.GlobFiles(repoRoot.ToStringDuringMigration(), globs, ignores)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]turbopath.AbsoluteSystemPath, len(files))
+	for i, file := range files {
+		paths[i] = turbopath.AbsoluteSystemPathFromUpstream(file)
+	}
+	return paths, nil
+}
+
+// get returns the current hashes of the files that match globs, other than the
+// ignored ones, and of the given files, keyed by their path relative to the
+// repository root
+func (g *globalFiles) get(globs []string, ignores []string, files []string) (map[string]string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	key := strings.Join([]string{strings.Join(globs, "\x00"), strings.Join(ignores, "\x00"), strings.Join(files, "\x00")}, "\x01")
+	set, ok := g.sets[key]
+	if !ok {
+		set = &globalFileSet{
+			globs:   globs,
+			ignores: ignores,
+			files:   files,
+			hashes:  make(map[turbopath.AnchoredUnixPath]string),
+			stale:   true,
+			dirty:   make(util.Set),
+		}
+		g.sets[key] = set
+	}
+	if err := g.refresh(set); err != nil {
+		return nil, err
+	}
+	fileHashes := make(map[string]string, len(set.hashes))
+	for file, hash := range set.hashes {
+		fileHashes[file.ToString()] = hash
+	}
+	return fileHashes, nil
+}
+
+// refresh expands the globs of a set if it is stale, and hashes its new and
+// dirty files. It must be called with mu held.
+func (g *globalFiles) refresh(set *globalFileSet) error {
+	var toHash []turbopath.AbsoluteSystemPath
+	for _, file := range set.dirty.UnsafeListOfStrings() {
+		path := turbopath.AnchoredUnixPath(file).ToSystemPath().RestoreAnchor(g.repoRoot)
+		// A deleted file is no longer part of the set
+		if !path.FileExists() {
+			set.stale = true
+			break
+		}
+		toHash = append(toHash, path)
+	}
+	hashes := set.hashes
+	if set.stale {
+		paths, err := g.glob(g.repoRoot, set.globs, set.ignores)
+		if err != nil {
+			return err
+		}
+		for _, file := range set.files {
+			paths = append(paths, g.repoRoot.UntypedJoin(file))
+		}
+		hashes = make(map[turbopath.AnchoredUnixPath]string, len(paths))
+		toHash = nil
+		for _, path := range paths {
+			relative, err := path.RelativeTo(g.repoRoot)
+			if err != nil {
+				return err
+			}
+			file := relative.ToUnixPath()
+			if hash, ok := set.hashes[file]; ok && !set.dirty.Includes(file.ToString()) {
+				hashes[file] = hash
+			} else {
+				toHash = append(toHash, path)
+			}
+		}
+	}
+	if len(toHash) > 0 {
+		fileHashes, err := g.hashFiles(g.repoRoot, toHash)
+		if err != nil {
+			return errors.Wrap(err, "failed to hash global dependencies")
+		}
+		for file, hash := range fileHashes {
+			hashes[file] = hash
+		}
+		g.logger.Debug(fmt.Sprintf("hashed %v of %v global dependencies", len(toHash), len(hashes)))
+	}
+	set.hashes = hashes
+	set.stale = false
+	set.dirty = make(util.Set)
+	return nil
+}
+
+// invalidate marks a changed file as dirty in the sets that hashed it. A
+// change to a file that the globs of a set match, or to a directory that
+// contains one of its files, could add or remove a file, so the globs of that
+// set are expanded again.
+func (g *globalFiles) invalidate(path turbopath.AbsoluteSystemPath) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	relative, err := path.RelativeTo(g.repoRoot)
+	if err != nil {
+		return
+	}
+	file := relative.ToUnixPath()
+	for _, set := range g.sets {
+		if set.stale {
+			continue
+		}
+		if _, ok := set.hashes[file]; ok {
+			set.dirty.Add(file.ToString())
+		} else if set.matches(file) || set.contains(file) {
+			set.stale = true
+		}
+	}
+}
+
+// matches returns whether the globs of the set match the file
+func (set *globalFileSet) matches(file turbopath.AnchoredUnixPath) bool {
+	matches := func(globs []string) bool {
+		for _, glob := range globs {
+			if ok, err := doublestar.Match(filepath.ToSlash(glob), file.ToString()); err == nil && ok {
+				return true
+			}
+		}
+		return false
+	}
+	return matches(set.globs) && !matches(set.ignores)
+}
+
+// contains returns whether one of the hashed files is in the directory dir
+func (set *globalFileSet) contains(dir turbopath.AnchoredUnixPath) bool {
+	prefix := dir.ToString() + "/"
+	for file := range set.hashes {
+		if strings.HasPrefix(file.ToString(), prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"gotest.tools/v3/assert"
+
+	turbofs "github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// fakeGlobalFiles hashes a file by counting the changes to it
+type fakeGlobalFiles struct {
+	repoRoot turbopath.AbsoluteSystemPath
+	globs    int
+	hashed   []string
+	version  map[string]int
+}
+
+func (f *fakeGlobalFiles) write(t *testing.T, file string) {
+	path := f.repoRoot.UntypedJoin(file)
+	assert.NilError(t, path.EnsureDir())
+	assert.NilError(t, path.WriteFile([]byte(file), 0644))
+}
+
+func (f *fakeGlobalFiles) glob(repoRoot turbopath.AbsoluteSystemPath, globs []string, ignores []string) ([]turbopath.AbsoluteSystemPath, error) {
+	f.globs++
+	return globFiles(repoRoot, globs, ignores)
+}
+
+func (f *fakeGlobalFiles) hash(repoRoot turbopath.AbsoluteSystemPath, files []turbopath.AbsoluteSystemPath) (map[turbopath.AnchoredUnixPath]string, error) {
+	hashes := make(map[turbopath.AnchoredUnixPath]string)
+	for _, path := range files {
+		relative, err := path.RelativeTo(repoRoot)
+		if err != nil {
+			return nil, err
+		}
+		file := relative.ToUnixPath()
+		f.hashed = append(f.hashed, file.ToString())
+		hashes[file] = fmt.Sprintf("%v-%v", file, f.version[file.ToString()])
+	}
+	sort.Strings(f.hashed)
+	return hashes, nil
+}
+
+func TestGlobalFiles(t *testing.T) {
+	repoRoot := turbofs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	fake := &fakeGlobalFiles{repoRoot: repoRoot, version: make(map[string]int)}
+	fake.write(t, "config/a.json")
+	fake.write(t, "config/b.json")
+	fake.write(t, "config/ignored.json")
+	fake.write(t, "package.json")
+	g := newGlobalFiles(hclog.NewNullLogger(), repoRoot)
+	g.glob = fake.glob
+	g.hashFiles = fake.hash
+	globs := []string{"config/*.json"}
+	ignores := []string{"config/ignored.json"}
+	files := []string{"package.json"}
+
+	hashes, err := g.get(globs, ignores, files)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, hashes, map[string]string{
+		"config/a.json": "config/a.json-0",
+		"config/b.json": "config/b.json-0",
+		"package.json":  "package.json-0",
+	})
+	assert.DeepEqual(t, fake.hashed, []string{"config/a.json", "config/b.json", "package.json"})
+
+	// Nothing is hashed again until a file changes
+	fake.hashed = nil
+	g.invalidate(repoRoot.UntypedJoin("README.md"))
+	g.invalidate(repoRoot.UntypedJoin("config", "ignored.json"))
+	_, err = g.get(globs, ignores, files)
+	assert.NilError(t, err)
+	assert.Equal(t, len(fake.hashed), 0)
+	assert.Equal(t, fake.globs, 1)
+
+	// Only the file that changed is hashed again
+	fake.version["config/a.json"]++
+	g.invalidate(repoRoot.UntypedJoin("config", "a.json"))
+	hashes, err = g.get(globs, ignores, files)
+	assert.NilError(t, err)
+	assert.Equal(t, hashes["config/a.json"], "config/a.json-1")
+	assert.DeepEqual(t, fake.hashed, []string{"config/a.json"})
+	assert.Equal(t, fake.globs, 1)
+
+	// A new file that the globs match is added
+	fake.hashed = nil
+	fake.write(t, "config/c.json")
+	g.invalidate(repoRoot.UntypedJoin("config", "c.json"))
+	hashes, err = g.get(globs, ignores, files)
+	assert.NilError(t, err)
+	assert.Equal(t, hashes["config/c.json"], "config/c.json-0")
+	assert.DeepEqual(t, fake.hashed, []string{"config/c.json"})
+	assert.Equal(t, fake.globs, 2)
+
+	// A deleted file is removed
+	fake.hashed = nil
+	assert.NilError(t, repoRoot.UntypedJoin("config", "b.json").Remove())
+	g.invalidate(repoRoot.UntypedJoin("config", "b.json"))
+	hashes, err = g.get(globs, ignores, files)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, hashes, map[string]string{
+		"config/a.json": "config/a.json-1",
+		"config/c.json": "config/c.json-0",
+		"package.json":  "package.json-0",
+	})
+	assert.Equal(t, len(fake.hashed), 0)
+	assert.Equal(t, fake.globs, 3)
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Upper bounds, in seconds, of the buckets of the duration histograms
+var (
+	_hashDurationBuckets = []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	_runDurationBuckets  = []float64{1, 5, 10, 30, 60, 120, 300, 600, 1800}
+)
+
+// Metrics counts the runs reported to the daemon and the file changes it
+// watches, and serves them in the Prometheus text format
+type Metrics struct {
+	mu              sync.Mutex
+	runs            uint64
+	failedRuns      uint64
+	cacheHits       uint64
+	cacheMisses     uint64
+	fileWatchEvents uint64
+	hashDuration    *histogram
+	runDuration     *histogram
+}
+
+// NewMetrics returns Metrics with every counter at zero
+func NewMetrics() *Metrics {
+	return &Metrics{
+		hashDuration: newHistogram(_hashDurationBuckets),
+		runDuration:  newHistogram(_runDurationBuckets),
+	}
+}
+
+// RunFinished counts a run and the cache hits and misses of its tasks.
+// hashDuration is the time the run spent hashing the files of its packages.
+func (m *Metrics) RunFinished(cacheHits int, cacheMisses int, failed bool, duration time.Duration, hashDuration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.runs++
+	if failed {
+		m.failedRuns++
+	}
+	m.cacheHits += uint64(cacheHits)
+	m.cacheMisses += uint64(cacheMisses)
+	m.runDuration.observe(duration.Seconds())
+	m.hashDuration.observe(hashDuration.Seconds())
+}
+
+// FileWatchEvent counts a change to a file in the repository
+func (m *Metrics) FileWatchEvent() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fileWatchEvents++
+}
+
+// ServeHTTP implements http.Handler for the /metrics endpoint
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(w)
+}
+
+func (m *Metrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	writeCounter(w, "turbo_runs_total", "Runs reported to the daemon.", m.runs)
+	writeCounter(w, "turbo_runs_failed_total", "Runs reported to the daemon that failed.", m.failedRuns)
+	writeCounter(w, "turbo_cache_hits_total", "Tasks restored from the cache.", m.cacheHits)
+	writeCounter(w, "turbo_cache_misses_total", "Tasks that missed the cache and were executed.", m.cacheMisses)
+	writeCounter(w, "turbo_file_watch_events_total", "File changes seen by the daemon.", m.fileWatchEvents)
+	m.hashDuration.write(w, "turbo_hash_duration_seconds", "Time runs spent hashing package files.")
+	m.runDuration.write(w, "turbo_run_duration_seconds", "Duration of runs.")
+}
+
+func writeCounter(w io.Writer, name string, help string, value uint64) {
+	fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v counter\n%v %v\n", name, help, name, name, value)
+}
+
+// histogram counts observations in cumulative buckets
+type histogram struct {
+	bounds []float64
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)),
+	}
+}
+
+func (h *histogram) observe(value float64) {
+	for i, bound := range h.bounds {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += value
+	h.count++
+}
+
+func (h *histogram) write(w io.Writer, name string, help string) {
+	fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v histogram\n", name, help, name)
+	for i, bound := range h.bounds {
+		fmt.Fprintf(w, "%v_bucket{le=\"%v\"} %v\n", name, strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i])
+	}
+	fmt.Fprintf(w, "%v_bucket{le=\"+Inf\"} %v\n", name, h.count)
+	fmt.Fprintf(w, "%v_sum %v\n", name, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(w, "%v_count %v\n", name, h.count)
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.RunFinished(3, 1, false, 20*time.Second, 200*time.Millisecond)
+	metrics.RunFinished(0, 2, true, 90*time.Second, 2*time.Second)
+	metrics.FileWatchEvent()
+
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+
+	for _, line := range []string{
+		"# TYPE turbo_runs_total counter",
+		"turbo_runs_total 2",
+		"turbo_runs_failed_total 1",
+		"turbo_cache_hits_total 3",
+		"turbo_cache_misses_total 3",
+		"turbo_file_watch_events_total 1",
+		"# TYPE turbo_hash_duration_seconds histogram",
+		`turbo_hash_duration_seconds_bucket{le="0.1"} 0`,
+		`turbo_hash_duration_seconds_bucket{le="0.25"} 1`,
+		`turbo_hash_duration_seconds_bucket{le="2.5"} 2`,
+		`turbo_hash_duration_seconds_bucket{le="+Inf"} 2`,
+		"turbo_hash_duration_seconds_sum 2.2",
+		"turbo_hash_duration_seconds_count 2",
+		`turbo_run_duration_seconds_bucket{le="30"} 1`,
+		`turbo_run_duration_seconds_bucket{le="120"} 2`,
+	} {
+		assert.Assert(t, strings.Contains(body, line+"\n"), "missing %q in:\n%v", line, body)
+	}
+}
@@ -0,0 +1,165 @@
+package server
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	turbocontext "github.com/vercel/turbo/cli/internal/context"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbodprotocol"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// _packageGraphRebuildDelay batches the file changes of an install or a
+// checkout into a single rebuild of the package graph
+var _packageGraphRebuildDelay = 500 * time.Millisecond
+
+// packageGraph keeps the resolved package graph of the repository, so that
+// `turbo run` doesn't need to resolve it again. It is resolved again after a
+// change to one of the files it was resolved from.
+type packageGraph struct {
+	logger   hclog.Logger
+	repoRoot turbopath.AbsoluteSystemPath
+	// resolve is replaced in tests
+	resolve func(repoRoot turbopath.AbsoluteSystemPath) (*turbocontext.Resolution, error)
+
+	mu sync.Mutex
+	// resolution is nil until the graph is asked for, and after a change
+	resolution   *turbocontext.Resolution
+	rebuildTimer *time.Timer
+	closed       bool
+}
+
+func newPackageGraph(logger hclog.Logger, repoRoot turbopath.AbsoluteSystemPath) *packageGraph {
+	return &packageGraph{
+		logger:   logger,
+		repoRoot: repoRoot,
+		resolve:  resolvePackageGraph,
+	}
+}
+
+// resolvePackageGraph builds the package graph of the repository. Graphs built
+// with warnings aren't kept, so that `turbo run` builds them itself and reports
+// the warnings.
+func resolvePackageGraph(repoRoot turbopath.AbsoluteSystemPath) (*turbocontext.Resolution, error) {
+	rootPackageJSON, err := fs.ReadPackageJSON(repoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package.json: %w", err)
+	}
+	pkgDepGraph, err := turbocontext.BuildPackageGraph(repoRoot, rootPackageJSON)
+	if err != nil {
+		return nil, err
+	}
+	return turbocontext.NewResolution(repoRoot, pkgDepGraph)
+}
+
+// get returns the resolved package graph, resolving it first if needed
+func (p *packageGraph) get() (*turbocontext.Resolution, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.resolution == nil {
+		resolution, err := p.resolve(p.repoRoot)
+		if err != nil {
+			return nil, err
+		}
+		p.resolution = resolution
+	}
+	return p.resolution, nil
+}
+
+// invalidate drops the resolved graph after a change to one of the files it
+// was resolved from, to a directory that contains one of them, or to any
+// package.json file, which could be a new workspace. The graph is resolved
+// again shortly after, so that it is ready for the next run.
+func (p *packageGraph) invalidate(path turbopath.AbsoluteSystemPath) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.resolution == nil {
+		return
+	}
+	relative, err := path.RelativeTo(p.repoRoot)
+	if err != nil {
+		return
+	}
+	changed := path.Base() == "package.json"
+	for _, input := range p.resolution.Inputs {
+		if changed {
+			break
+		}
+		changed = input.ToSystemPath().HasPrefix(relative)
+	}
+	if changed {
+		p.reset()
+	}
+}
+
+// invalidateAll drops the resolved graph, for tools that change the workspaces
+func (p *packageGraph) invalidateAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reset()
+}
+
+// reset must be called with mu held
+func (p *packageGraph) reset() {
+	p.resolution = nil
+	if p.rebuildTimer == nil && !p.closed {
+		p.rebuildTimer = time.AfterFunc(_packageGraphRebuildDelay, p.rebuild)
+	}
+}
+
+func (p *packageGraph) rebuild() {
+	p.mu.Lock()
+	p.rebuildTimer = nil
+	closed := p.closed
+	p.mu.Unlock()
+	if closed {
+		return
+	}
+	if _, err := p.get(); err != nil {
+		p.logger.Debug(fmt.Sprintf("failed to resolve the package graph: %v", err))
+	}
+}
+
+func (p *packageGraph) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	if p.rebuildTimer != nil {
+		p.rebuildTimer.Stop()
+		p.rebuildTimer = nil
+	}
+}
+
+// packageGraphResponse converts a resolution for a GetPackageGraph response
+func packageGraphResponse(resolution *turbocontext.Resolution) *turbodprotocol.GetPackageGraphResponse {
+	resp := &turbodprotocol.GetPackageGraphResponse{
+		Fingerprint: resolution.Fingerprint,
+		Inputs:      make([]string, 0, len(resolution.Inputs)),
+		HasLockfile: resolution.HasLockfile,
+		Workspaces:  make([]*turbodprotocol.WorkspaceResolution, 0, len(resolution.Workspaces)),
+	}
+	for _, input := range resolution.Inputs {
+		resp.Inputs = append(resp.Inputs, input.ToString())
+	}
+	for _, workspace := range resolution.Workspaces {
+		transitiveDeps := make([]*turbodprotocol.LockfilePackage, 0, len(workspace.TransitiveDeps))
+		for _, dep := range workspace.TransitiveDeps {
+			transitiveDeps = append(transitiveDeps, &turbodprotocol.LockfilePackage{
+				Key:     dep.Key,
+				Version: dep.Version,
+				Found:   dep.Found,
+			})
+		}
+		resp.Workspaces = append(resp.Workspaces, &turbodprotocol.WorkspaceResolution{
+			Name:             workspace.Name,
+			PackageJsonPath:  workspace.PackageJSONPath.ToString(),
+			InternalDeps:     workspace.InternalDeps,
+			TransitiveDeps:   transitiveDeps,
+			ExternalDepsHash: workspace.ExternalDepsHash,
+		})
+	}
+	return resp
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"google.golang.org/protobuf/testing/protocmp"
+	"gotest.tools/v3/assert"
+
+	turbocontext "github.com/vercel/turbo/cli/internal/context"
+	turbofs "github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/lockfile"
+	"github.com/vercel/turbo/cli/internal/turbodprotocol"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// fakeResolutions counts how many times the package graph was resolved
+type fakeResolutions struct {
+	mu          sync.Mutex
+	resolutions int
+}
+
+func (f *fakeResolutions) resolve(repoRoot turbopath.AbsoluteSystemPath) (*turbocontext.Resolution, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.resolutions++
+	return &turbocontext.Resolution{
+		Fingerprint: "abc123",
+		Inputs:      []turbopath.AnchoredUnixPath{"package-lock.json", "package.json", "packages/ui/package.json"},
+		HasLockfile: true,
+		Workspaces: []*turbocontext.WorkspaceResolution{{
+			Name:             "ui",
+			PackageJSONPath:  "packages/ui/package.json",
+			InternalDeps:     []string{},
+			TransitiveDeps:   []lockfile.Package{{Key: "node_modules/react", Version: "18.2.0", Found: true}},
+			ExternalDepsHash: "def456",
+		}},
+	}, nil
+}
+
+func (f *fakeResolutions) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.resolutions
+}
+
+func TestPackageGraph(t *testing.T) {
+	repoRoot := turbofs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	fake := &fakeResolutions{}
+	p := newPackageGraph(hclog.NewNullLogger(), repoRoot)
+	p.resolve = fake.resolve
+	defer p.close()
+
+	resolution, err := p.get()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, packageGraphResponse(resolution), &turbodprotocol.GetPackageGraphResponse{
+		Fingerprint: "abc123",
+		Inputs:      []string{"package-lock.json", "package.json", "packages/ui/package.json"},
+		HasLockfile: true,
+		Workspaces: []*turbodprotocol.WorkspaceResolution{{
+			Name:             "ui",
+			PackageJsonPath:  "packages/ui/package.json",
+			InternalDeps:     []string{},
+			TransitiveDeps:   []*turbodprotocol.LockfilePackage{{Key: "node_modules/react", Version: "18.2.0", Found: true}},
+			ExternalDepsHash: "def456",
+		}},
+	}, protocmp.Transform())
+
+	// Changes to other files keep the resolved graph
+	p.invalidate(repoRoot.UntypedJoin("packages", "ui", "src", "index.ts"))
+	p.invalidate(repoRoot.UntypedJoin("README.md"))
+	_, err = p.get()
+	assert.NilError(t, err)
+	assert.Equal(t, fake.count(), 1)
+
+	for _, changed := range []turbopath.AbsoluteSystemPath{
+		repoRoot.UntypedJoin("package-lock.json"),
+		repoRoot.UntypedJoin("packages", "ui"),
+		repoRoot.UntypedJoin("apps", "docs", "package.json"),
+	} {
+		before := fake.count()
+		p.invalidate(changed)
+		_, err = p.get()
+		assert.NilError(t, err)
+		assert.Equal(t, fake.count(), before+1, "a change to %v resolves the graph again", changed)
+	}
+}
+
+func TestPackageGraphInvalidateAll(t *testing.T) {
+	repoRoot := turbofs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	fake := &fakeResolutions{}
+	p := newPackageGraph(hclog.NewNullLogger(), repoRoot)
+	p.resolve = fake.resolve
+	defer p.close()
+	defer func(delay time.Duration) { _packageGraphRebuildDelay = delay }(_packageGraphRebuildDelay)
+	_packageGraphRebuildDelay = 10 * time.Millisecond
+
+	_, err := p.get()
+	assert.NilError(t, err)
+	p.invalidateAll()
+	// The graph is resolved again in the background
+	deadline := time.Now().Add(2 * time.Second)
+	for fake.count() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, fake.count(), 2)
+}
@@ -0,0 +1,283 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/pkg/errors"
+	turbocontext "github.com/vercel/turbo/cli/internal/context"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/taskhash"
+	"github.com/vercel/turbo/cli/internal/turbodprotocol"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// _packageRehashDelay batches the file changes of a save or a checkout into a
+// single rehash of the packages they're in
+var _packageRehashDelay = 200 * time.Millisecond
+
+// A subscriber that falls this far behind misses changes, and can ask for
+// every hash again
+const _subscriberBuffer = 64
+
+// packageHashes tracks the hash of the files of each package in the repository.
+// A package is only hashed again after one of its files changes.
+type packageHashes struct {
+	logger   hclog.Logger
+	repoRoot turbopath.AbsoluteSystemPath
+	// readPackages and hashPackage are replaced in tests
+	readPackages func(repoRoot turbopath.AbsoluteSystemPath) (map[string]*fs.PackageJSON, error)
+	hashPackage  func(pkg *fs.PackageJSON, repoRoot turbopath.AbsoluteSystemPath) (string, error)
+
+	mu sync.Mutex
+	// packages is nil until the package graph is read, and after a change that
+	// could add, remove or move a package
+	packages map[string]*fs.PackageJSON
+	hashes   map[string]string
+	// dirty are the packages with changes since they were hashed
+	dirty       util.Set
+	subscribers map[*packageSubscriber]struct{}
+	rehashTimer *time.Timer
+	closed      bool
+	done        chan struct{}
+}
+
+type packageSubscriber struct {
+	// packages is empty to watch every package
+	packages util.Set
+	changes  chan *turbodprotocol.PackageHashChange
+}
+
+func newPackageHashes(logger hclog.Logger, repoRoot turbopath.AbsoluteSystemPath) *packageHashes {
+	return &packageHashes{
+		logger:       logger,
+		repoRoot:     repoRoot,
+		readPackages: readPackages,
+		hashPackage:  taskhash.PackageFileHash,
+		hashes:       make(map[string]string),
+		dirty:        make(util.Set),
+		subscribers:  make(map[*packageSubscriber]struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+// readPackages reads the packages of the repository, other than the root
+func readPackages(repoRoot turbopath.AbsoluteSystemPath) (map[string]*fs.PackageJSON, error) {
+	rootPackageJSON, err := fs.ReadPackageJSON(repoRoot.UntypedJoin("package.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package.json: %w", err)
+	}
+	pkgDepGraph, err := turbocontext.BuildPackageGraph(repoRoot, rootPackageJSON)
+	if err != nil {
+		var warnings *turbocontext.Warnings
+		if !errors.As(err, &warnings) {
+			return nil, err
+		}
+	}
+	packages := make(map[string]*fs.PackageJSON, len(pkgDepGraph.WorkspaceInfos.PackageJSONs))
+	for name, pkg := range pkgDepGraph.WorkspaceInfos.PackageJSONs {
+		if name != util.RootPkgName {
+			packages[name] = pkg
+		}
+	}
+	return packages, nil
+}
+
+// get returns the current hashes of the given packages, or of every package
+func (p *packageHashes) get(names []string) ([]*turbodprotocol.PackageHash, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.refresh(); err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		for name := range p.packages {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	packageHashes := make([]*turbodprotocol.PackageHash, 0, len(names))
+	for _, name := range names {
+		pkg, ok := p.packages[name]
+		if !ok {
+			return nil, fmt.Errorf("package %v not found", name)
+		}
+		packageHashes = append(packageHashes, &turbodprotocol.PackageHash{
+			Package: name,
+			Path:    pkg.Dir.ToUnixPath().ToString(),
+			Hash:    p.hashes[name],
+		})
+	}
+	return packageHashes, nil
+}
+
+// refresh reads the packages if they changed, hashes the dirty ones, and sends
+// the hashes that changed to the subscribers. It must be called with mu held.
+func (p *packageHashes) refresh() error {
+	if p.packages == nil {
+		packages, err := p.readPackages(p.repoRoot)
+		if err != nil {
+			return err
+		}
+		for name, pkg := range packages {
+			if previous, ok := p.packages[name]; !ok || previous.Dir != pkg.Dir {
+				p.dirty.Add(name)
+			}
+		}
+		p.packages = packages
+	}
+
+	changes := []*turbodprotocol.PackageHashChange{}
+	for name, previousHash := range p.hashes {
+		if _, ok := p.packages[name]; !ok {
+			changes = append(changes, &turbodprotocol.PackageHashChange{Package: name, PreviousHash: previousHash})
+		}
+	}
+	hashes := make(map[string]string, p.dirty.Len())
+	for _, name := range p.dirty.UnsafeListOfStrings() {
+		pkg, ok := p.packages[name]
+		if !ok {
+			continue
+		}
+		hash, err := p.hashPackage(pkg, p.repoRoot)
+		if err != nil {
+			return errors.Wrapf(err, "failed to hash package %v", name)
+		}
+		hashes[name] = hash
+		if previousHash := p.hashes[name]; previousHash != hash {
+			changes = append(changes, &turbodprotocol.PackageHashChange{
+				Package:      name,
+				Path:         pkg.Dir.ToUnixPath().ToString(),
+				PreviousHash: previousHash,
+				Hash:         hash,
+			})
+		}
+	}
+
+	for _, change := range changes {
+		if change.Hash == "" {
+			delete(p.hashes, change.Package)
+		}
+	}
+	for name, hash := range hashes {
+		p.hashes[name] = hash
+	}
+	p.dirty = make(util.Set)
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Package < changes[j].Package
+	})
+	p.broadcast(changes)
+	return nil
+}
+
+func (p *packageHashes) broadcast(changes []*turbodprotocol.PackageHashChange) {
+	for subscriber := range p.subscribers {
+		for _, change := range changes {
+			if subscriber.packages.Len() > 0 && !subscriber.packages.Includes(change.Package) {
+				continue
+			}
+			select {
+			case subscriber.changes <- change:
+			default:
+				p.logger.Warn(fmt.Sprintf("dropped a change to package %v for a subscriber that fell behind", change.Package))
+			}
+		}
+	}
+}
+
+// invalidate marks the package that contains a changed file as dirty. Changes
+// to package.json files or to the directory of a package could also change the
+// packages themselves, so those are read again.
+func (p *packageHashes) invalidate(path turbopath.AbsoluteSystemPath) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// Nothing is hashed before the packages are read
+	if p.packages == nil {
+		return
+	}
+	relative, err := path.RelativeTo(p.repoRoot)
+	if err != nil {
+		return
+	}
+	name, pkg := p.packageContaining(relative)
+	if name != "" {
+		p.dirty.Add(name)
+	}
+	if path.Base() == "package.json" || path.Base() == "pnpm-workspace.yaml" || (pkg != nil && pkg.Dir == relative) {
+		p.packages = nil
+	} else if name == "" {
+		return
+	}
+	// Without subscribers, packages are hashed when they're asked for
+	if len(p.subscribers) > 0 && p.rehashTimer == nil {
+		p.rehashTimer = time.AfterFunc(_packageRehashDelay, p.rehash)
+	}
+}
+
+func (p *packageHashes) rehash() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rehashTimer = nil
+	if p.closed {
+		return
+	}
+	if err := p.refresh(); err != nil {
+		p.logger.Error(fmt.Sprintf("failed to hash packages: %v", err))
+	}
+}
+
+// packageContaining returns the innermost package that contains the path
+func (p *packageHashes) packageContaining(path turbopath.AnchoredSystemPath) (string, *fs.PackageJSON) {
+	name := ""
+	var innermost *fs.PackageJSON
+	for pkgName, pkg := range p.packages {
+		if path.HasPrefix(pkg.Dir) && (innermost == nil || len(pkg.Dir) > len(innermost.Dir)) {
+			name = pkgName
+			innermost = pkg
+		}
+	}
+	return name, innermost
+}
+
+// subscribe hashes every package, so that later changes can be detected, and
+// returns a subscriber that receives the changes to the given packages, or to
+// every package
+func (p *packageHashes) subscribe(names []string) (*packageSubscriber, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.refresh(); err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if _, ok := p.packages[name]; !ok {
+			return nil, fmt.Errorf("package %v not found", name)
+		}
+	}
+	subscriber := &packageSubscriber{
+		packages: util.SetFromStrings(names),
+		changes:  make(chan *turbodprotocol.PackageHashChange, _subscriberBuffer),
+	}
+	p.subscribers[subscriber] = struct{}{}
+	return subscriber, nil
+}
+
+func (p *packageHashes) unsubscribe(subscriber *packageSubscriber) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.subscribers, subscriber)
+}
+
+// close ends every subscription, since the server can't stop while a
+// subscription is open
+func (p *packageHashes) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.closed {
+		p.closed = true
+		close(p.done)
+	}
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"google.golang.org/protobuf/testing/protocmp"
+	"gotest.tools/v3/assert"
+
+	turbofs "github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbodprotocol"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// fakePackages hashes a package by counting the changes to it
+type fakePackages struct {
+	dirs    map[string]string
+	reads   int
+	version map[string]int
+}
+
+func (f *fakePackages) read(repoRoot turbopath.AbsoluteSystemPath) (map[string]*turbofs.PackageJSON, error) {
+	f.reads++
+	packages := make(map[string]*turbofs.PackageJSON)
+	for name, dir := range f.dirs {
+		packages[name] = &turbofs.PackageJSON{Name: name, Dir: turbopath.AnchoredUnixPath(dir).ToSystemPath()}
+	}
+	return packages, nil
+}
+
+func (f *fakePackages) hash(pkg *turbofs.PackageJSON, repoRoot turbopath.AbsoluteSystemPath) (string, error) {
+	return fmt.Sprintf("%v-%v", pkg.Name, f.version[pkg.Name]), nil
+}
+
+func newFakePackageHashes(t *testing.T) (*packageHashes, *fakePackages, turbopath.AbsoluteSystemPath) {
+	repoRoot := turbofs.AbsoluteSystemPathFromUpstream(t.TempDir())
+	fake := &fakePackages{
+		dirs:    map[string]string{"web": "apps/web", "ui": "packages/ui"},
+		version: make(map[string]int),
+	}
+	p := newPackageHashes(hclog.NewNullLogger(), repoRoot)
+	p.readPackages = fake.read
+	p.hashPackage = fake.hash
+	return p, fake, repoRoot
+}
+
+func TestPackageHashes(t *testing.T) {
+	p, fake, repoRoot := newFakePackageHashes(t)
+
+	hashes, err := p.get(nil)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, hashes, []*turbodprotocol.PackageHash{
+		{Package: "ui", Path: "packages/ui", Hash: "ui-0"},
+		{Package: "web", Path: "apps/web", Hash: "web-0"},
+	}, protocmp.Transform())
+
+	// Only the package with a change is hashed again
+	fake.version["ui"]++
+	fake.version["web"]++
+	p.invalidate(repoRoot.UntypedJoin("packages", "ui", "src", "index.ts"))
+	p.invalidate(repoRoot.UntypedJoin("README.md"))
+	hashes, err = p.get([]string{"web", "ui"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, hashes, []*turbodprotocol.PackageHash{
+		{Package: "web", Path: "apps/web", Hash: "web-0"},
+		{Package: "ui", Path: "packages/ui", Hash: "ui-1"},
+	}, protocmp.Transform())
+	assert.Equal(t, fake.reads, 1)
+
+	// A new package.json adds a package
+	fake.dirs["docs"] = "apps/docs"
+	p.invalidate(repoRoot.UntypedJoin("apps", "docs", "package.json"))
+	hashes, err = p.get([]string{"docs"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, hashes, []*turbodprotocol.PackageHash{
+		{Package: "docs", Path: "apps/docs", Hash: "docs-0"},
+	}, protocmp.Transform())
+	assert.Equal(t, fake.reads, 2)
+
+	_, err = p.get([]string{"missing"})
+	assert.ErrorContains(t, err, "package missing not found")
+}
+
+func TestWatchPackageHashes(t *testing.T) {
+	p, fake, repoRoot := newFakePackageHashes(t)
+	defer p.close()
+
+	subscriber, err := p.subscribe([]string{"ui"})
+	assert.NilError(t, err)
+	defer p.unsubscribe(subscriber)
+
+	fake.version["ui"]++
+	fake.version["web"]++
+	p.invalidate(repoRoot.UntypedJoin("apps", "web", "index.ts"))
+	p.invalidate(repoRoot.UntypedJoin("packages", "ui", "index.ts"))
+	p.invalidate(repoRoot.UntypedJoin("packages", "ui", "button.ts"))
+
+	select {
+	case change := <-subscriber.changes:
+		assert.DeepEqual(t, change, &turbodprotocol.PackageHashChange{
+			Package:      "ui",
+			Path:         "packages/ui",
+			PreviousHash: "ui-0",
+			Hash:         "ui-1",
+		}, protocmp.Transform())
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a change to package ui")
+	}
+
+	// Removing the package is a change too
+	delete(fake.dirs, "ui")
+	p.invalidate(repoRoot.UntypedJoin("packages", "ui"))
+	select {
+	case change := <-subscriber.changes:
+		assert.DeepEqual(t, change, &turbodprotocol.PackageHashChange{
+			Package:      "ui",
+			PreviousHash: "ui-1",
+		}, protocmp.Transform())
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for package ui to be removed")
+	}
+	assert.Equal(t, len(subscriber.changes), 0)
+}
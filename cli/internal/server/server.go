@@ -10,6 +10,7 @@ import (
 	"github.com/vercel/turbo/cli/internal/filewatcher"
 	"github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/globwatcher"
+	"github.com/vercel/turbo/cli/internal/runsummary"
 	"github.com/vercel/turbo/cli/internal/turbodprotocol"
 	"github.com/vercel/turbo/cli/internal/turbopath"
 	"google.golang.org/grpc"
@@ -33,6 +34,20 @@ type Server struct {
 	repoRoot     turbopath.AbsoluteSystemPath
 	closerMu     sync.Mutex
 	closer       *closer
+	metrics      *Metrics
+	// packageHashes are the hashes of the files of each package, which
+	// are kept up to date as files change
+	packageHashes *packageHashes
+	// packageGraph is the resolved package graph, which is resolved again
+	// after the files it was resolved from change
+	packageGraph *packageGraph
+	// globalFiles are the hashes of the global dependencies, which are hashed
+	// again after they change
+	globalFiles *globalFiles
+	// explainTask answers the ExplainTask rpc. Explaining a task hashes every
+	// task it depends on, so explanations are answered one at a time.
+	explainMu   sync.Mutex
+	explainTask TaskExplainer
 }
 
 // GRPCServer is the interface that the turbo server needs to the underlying
@@ -62,26 +77,32 @@ func (c *closer) close() {
 
 var _defaultCookieTimeout = 500 * time.Millisecond
 
-// New returns a new instance of Server
-func New(serverName string, logger hclog.Logger, repoRoot turbopath.AbsoluteSystemPath, turboVersion string, logFilePath turbopath.AbsoluteSystemPath) (*Server, error) {
-	cookieDir := fs.GetTurboDataDir().UntypedJoin("cookies", serverName)
-	cookieJar, err := filewatcher.NewCookieJar(cookieDir, _defaultCookieTimeout)
+// New returns a new instance of Server. Changes in cacheDir are not watched,
+// since they are never task inputs or outputs.
+func New(serverName string, logger hclog.Logger, repoRoot turbopath.AbsoluteSystemPath, turboVersion string, logFilePath turbopath.AbsoluteSystemPath, cacheDir turbopath.AbsoluteSystemPath, watcherOpts filewatcher.BackendOpts) (*Server, error) {
+	watcher, err := filewatcher.GetBackend(logger, repoRoot, watcherOpts)
 	if err != nil {
 		return nil, err
 	}
-	watcher, err := filewatcher.GetPlatformSpecificBackend(logger)
+	cookieDir := fs.GetTurboDataDir().UntypedJoin("cookies", serverName)
+	cookieJar, err := filewatcher.NewCookieJar(cookieDir, filewatcher.CookieTimeout(watcher, _defaultCookieTimeout))
 	if err != nil {
+		_ = watcher.Close()
 		return nil, err
 	}
-	fileWatcher := filewatcher.New(logger.Named("FileWatcher"), repoRoot, watcher)
+	fileWatcher := filewatcher.New(logger.Named("FileWatcher"), repoRoot, watcher, cacheDir)
 	globWatcher := globwatcher.New(logger.Named("GlobWatcher"), repoRoot, cookieJar)
 	server := &Server{
-		watcher:      fileWatcher,
-		globWatcher:  globWatcher,
-		turboVersion: turboVersion,
-		started:      time.Now(),
-		logFilePath:  logFilePath,
-		repoRoot:     repoRoot,
+		watcher:       fileWatcher,
+		globWatcher:   globWatcher,
+		turboVersion:  turboVersion,
+		started:       time.Now(),
+		logFilePath:   logFilePath,
+		repoRoot:      repoRoot,
+		metrics:       NewMetrics(),
+		packageHashes: newPackageHashes(logger.Named("PackageHashes"), repoRoot),
+		packageGraph:  newPackageGraph(logger.Named("PackageGraph"), repoRoot),
+		globalFiles:   newGlobalFiles(logger.Named("GlobalFiles"), repoRoot),
 	}
 	server.watcher.AddClient(cookieJar)
 	server.watcher.AddClient(globWatcher)
@@ -97,6 +118,8 @@ func New(serverName string, logger hclog.Logger, repoRoot turbopath.AbsoluteSyst
 }
 
 func (s *Server) tryClose() bool {
+	s.packageHashes.close()
+	s.packageGraph.close()
 	s.closerMu.Lock()
 	defer s.closerMu.Unlock()
 	if s.closer != nil {
@@ -109,6 +132,10 @@ func (s *Server) tryClose() bool {
 // OnFileWatchEvent implements filewatcher.FileWatchClient.OnFileWatchEvent
 // In the event that the root of the monorepo is deleted, shut down the server.
 func (s *Server) OnFileWatchEvent(ev filewatcher.Event) {
+	s.metrics.FileWatchEvent()
+	s.packageHashes.invalidate(ev.Path)
+	s.packageGraph.invalidate(ev.Path)
+	s.globalFiles.invalidate(ev.Path)
 	if ev.EventType == filewatcher.FileDeleted && ev.Path == s.repoRoot {
 		_ = s.tryClose()
 	}
@@ -120,8 +147,15 @@ func (s *Server) OnFileWatchError(err error) {}
 // OnFileWatchClosed implements filewatcher.FileWatchClient.OnFileWatchClosed
 func (s *Server) OnFileWatchClosed() {}
 
+// Metrics returns the metrics of this server, to be served at /metrics
+func (s *Server) Metrics() *Metrics {
+	return s.metrics
+}
+
 // Close is used for shutting down this copy of the server
 func (s *Server) Close() error {
+	s.packageHashes.close()
+	s.packageGraph.close()
 	return s.watcher.Close()
 }
 
@@ -161,6 +195,92 @@ func (s *Server) GetChangedOutputs(ctx context.Context, req *turbodprotocol.GetC
 	}, nil
 }
 
+// NotifyRunFinished implements the NotifyRunFinished rpc from turbo.proto
+func (s *Server) NotifyRunFinished(ctx context.Context, req *turbodprotocol.NotifyRunFinishedRequest) (*turbodprotocol.NotifyRunFinishedResponse, error) {
+	duration := time.Duration(req.DurationMsec) * time.Millisecond
+	hashDuration := time.Duration(req.HashDurationMsec) * time.Millisecond
+	s.metrics.RunFinished(int(req.CacheHits), int(req.CacheMisses), req.Failed, duration, hashDuration)
+	return &turbodprotocol.NotifyRunFinishedResponse{}, nil
+}
+
+// GetPackageHashes implements the GetPackageHashes rpc from turbo.proto
+func (s *Server) GetPackageHashes(ctx context.Context, req *turbodprotocol.GetPackageHashesRequest) (*turbodprotocol.GetPackageHashesResponse, error) {
+	packageHashes, err := s.packageHashes.get(req.Packages)
+	if err != nil {
+		return nil, err
+	}
+	return &turbodprotocol.GetPackageHashesResponse{
+		PackageHashes: packageHashes,
+	}, nil
+}
+
+// WatchPackageHashes implements the WatchPackageHashes rpc from turbo.proto.
+// It streams the changes to the hashes of packages until the client
+// disconnects or the server stops.
+func (s *Server) WatchPackageHashes(req *turbodprotocol.WatchPackageHashesRequest, stream turbodprotocol.Turbod_WatchPackageHashesServer) error {
+	subscriber, err := s.packageHashes.subscribe(req.Packages)
+	if err != nil {
+		return err
+	}
+	defer s.packageHashes.unsubscribe(subscriber)
+	for {
+		select {
+		case change := <-subscriber.changes:
+			if err := stream.Send(change); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		case <-s.packageHashes.done:
+			return nil
+		}
+	}
+}
+
+// GetPackageGraph implements the GetPackageGraph rpc from turbo.proto
+func (s *Server) GetPackageGraph(ctx context.Context, req *turbodprotocol.GetPackageGraphRequest) (*turbodprotocol.GetPackageGraphResponse, error) {
+	resolution, err := s.packageGraph.get()
+	if err != nil {
+		return nil, err
+	}
+	return packageGraphResponse(resolution), nil
+}
+
+// InvalidatePackageGraph implements the InvalidatePackageGraph rpc from turbo.proto
+func (s *Server) InvalidatePackageGraph(ctx context.Context, req *turbodprotocol.InvalidatePackageGraphRequest) (*turbodprotocol.InvalidatePackageGraphResponse, error) {
+	s.packageGraph.invalidateAll()
+	return &turbodprotocol.InvalidatePackageGraphResponse{}, nil
+}
+
+// GetGlobalFileHashes implements the GetGlobalFileHashes rpc from turbo.proto
+func (s *Server) GetGlobalFileHashes(ctx context.Context, req *turbodprotocol.GetGlobalFileHashesRequest) (*turbodprotocol.GetGlobalFileHashesResponse, error) {
+	fileHashes, err := s.globalFiles.get(req.Globs, req.Ignores, req.Files)
+	if err != nil {
+		return nil, err
+	}
+	return &turbodprotocol.GetGlobalFileHashesResponse{
+		FileHashes: fileHashes,
+	}, nil
+}
+
+// ExplainTask implements the ExplainTask rpc from turbo.proto
+func (s *Server) ExplainTask(ctx context.Context, req *turbodprotocol.ExplainTaskRequest) (*turbodprotocol.ExplainTaskResponse, error) {
+	s.explainMu.Lock()
+	defer s.explainMu.Unlock()
+	if s.explainTask == nil {
+		return nil, status.Error(codes.Unimplemented, "this daemon can't explain tasks")
+	}
+	maxRuns := int(req.Runs)
+	if maxRuns == 0 {
+		maxRuns = runsummary.DefaultHistoricalRuns
+	}
+	explanation, err := s.explainTask(ctx, req.TaskId, maxRuns)
+	if err != nil {
+		return nil, err
+	}
+	return explainTaskResponse(explanation), nil
+}
+
 // Hello implements the Hello rpc from turbo.proto
 func (s *Server) Hello(ctx context.Context, req *turbodprotocol.HelloRequest) (*turbodprotocol.HelloResponse, error) {
 	clientVersion := req.Version
@@ -9,6 +9,7 @@ import (
 	"google.golang.org/grpc"
 	"gotest.tools/v3/assert"
 
+	"github.com/vercel/turbo/cli/internal/filewatcher"
 	turbofs "github.com/vercel/turbo/cli/internal/fs"
 	"github.com/vercel/turbo/cli/internal/turbodprotocol"
 )
@@ -24,27 +25,35 @@ func (m *mockGrpc) GracefulStop() {
 func (m *mockGrpc) RegisterService(desc *grpc.ServiceDesc, impl interface{}) {}
 
 func TestDeleteRepoRoot(t *testing.T) {
-	logger := hclog.Default()
-	logger.SetLevel(hclog.Debug)
-	repoRootRaw := t.TempDir()
-	repoRoot := turbofs.AbsoluteSystemPathFromUpstream(repoRootRaw)
-
-	grpcServer := &mockGrpc{
-		stopped: make(chan struct{}),
+	watchers := []filewatcher.BackendOpts{
+		{Watcher: filewatcher.WatcherNative},
+		{Watcher: filewatcher.WatcherPoll, PollInterval: 100 * time.Millisecond},
 	}
+	for _, watcherOpts := range watchers {
+		t.Run(watcherOpts.Watcher, func(t *testing.T) {
+			logger := hclog.Default()
+			logger.SetLevel(hclog.Debug)
+			repoRootRaw := t.TempDir()
+			repoRoot := turbofs.AbsoluteSystemPathFromUpstream(repoRootRaw)
 
-	s, err := New("testServer", logger, repoRoot, "some-version", "/log/file/path")
-	assert.NilError(t, err, "New")
-	s.Register(grpcServer)
+			grpcServer := &mockGrpc{
+				stopped: make(chan struct{}),
+			}
 
-	// Delete the repo root, ensure that GracefulStop got called
-	err = repoRoot.Remove()
-	assert.NilError(t, err, "Remove")
+			s, err := New("testServer", logger, repoRoot, "some-version", "/log/file/path", repoRoot.UntypedJoin(".turbo-cache"), watcherOpts)
+			assert.NilError(t, err, "New")
+			s.Register(grpcServer)
 
-	select {
-	case <-grpcServer.stopped:
-	case <-time.After(2 * time.Second):
-		t.Error("timed out waiting for graceful stop to be called")
+			// Delete the repo root, ensure that GracefulStop got called
+			err = repoRoot.Remove()
+			assert.NilError(t, err, "Remove")
+
+			select {
+			case <-grpcServer.stopped:
+			case <-time.After(2 * time.Second):
+				t.Error("timed out waiting for graceful stop to be called")
+			}
+		})
 	}
 }
 
@@ -57,7 +66,7 @@ func TestShutdown(t *testing.T) {
 		stopped: make(chan struct{}),
 	}
 
-	s, err := New("testServer", logger, repoRoot, "some-version", "/log/file/path")
+	s, err := New("testServer", logger, repoRoot, "some-version", "/log/file/path", repoRoot.UntypedJoin(".turbo-cache"), filewatcher.BackendOpts{})
 	assert.NilError(t, err, "New")
 	s.Register(grpcServer)
 
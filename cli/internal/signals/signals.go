@@ -1,6 +1,7 @@
 package signals
 
 import (
+	"context"
 	"os"
 	"os/signal"
 	"sync"
@@ -13,33 +14,90 @@ type Watcher struct {
 	doneCh  chan struct{}
 	closed  bool
 	mu      sync.Mutex
-	closers []func()
+	closers []*closer
+	nextID  int
+	// holds counts the work that delays Done, and closersRan is set once
+	// the cleanup handlers have run
+	holds      int
+	closersRan bool
 }
 
-// AddOnClose registers a cleanup handler to run when a signal is received
-func (w *Watcher) AddOnClose(closer func()) {
+type closer struct {
+	id int
+	fn func()
+}
+
+// AddOnClose registers a cleanup handler to run when a signal is received. The
+// returned function unregisters it, for handlers of work that has finished
+// while the process keeps running.
+func (w *Watcher) AddOnClose(fn func()) func() {
 	w.mu.Lock()
 	defer w.mu.Unlock()
-	w.closers = append(w.closers, closer)
+	w.nextID++
+	id := w.nextID
+	w.closers = append(w.closers, &closer{id: id, fn: fn})
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		for i, c := range w.closers {
+			if c.id == id {
+				w.closers = append(w.closers[:i], w.closers[i+1:]...)
+				return
+			}
+		}
+	}
 }
 
-// Close runs the cleanup handlers registered with this watcher
+// Close runs the cleanup handlers registered with this watcher. Done is closed
+// once they have run and every hold has been released.
 func (w *Watcher) Close() {
 	w.mu.Lock()
-	defer w.mu.Unlock()
 	if w.closed {
+		w.mu.Unlock()
 		return
 	}
 	w.closed = true
-	for _, closer := range w.closers {
-		closer()
-	}
+	closers := w.closers
 	w.closers = nil
-	close(w.doneCh)
+	w.mu.Unlock()
+
+	for _, c := range closers {
+		c.fn()
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closersRan = true
+	if w.holds == 0 {
+		close(w.doneCh)
+	}
+}
+
+// Hold delays Done until the returned function is called, for work that must
+// finish after a signal, such as writing the summary of the tasks it stopped
+func (w *Watcher) Hold() func() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closersRan && w.holds == 0 {
+		// Done is already closed
+		return func() {}
+	}
+	w.holds++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			w.holds--
+			if w.closersRan && w.holds == 0 {
+				close(w.doneCh)
+			}
+		})
+	}
 }
 
 // Done returns a channel that will be closed after all of the cleanup
-// handlers have been run.
+// handlers have been run, and the holds on this watcher have been released.
 func (w *Watcher) Done() <-chan struct{} {
 	return w.doneCh
 }
@@ -54,7 +112,28 @@ func NewWatcher() *Watcher {
 	}
 	go func() {
 		<-signalCh
-		w.Close()
+		go w.Close()
+		// A second signal exits right away, rather than waiting for the
+		// children to stop and the run to finish
+		<-signalCh
+		os.Exit(1)
+	}()
+	return w
+}
+
+// NewContextWatcher returns a new Watcher that is closed when ctx is done, rather
+// than when this process receives a signal. It is for programs that embed turbo
+// and handle signals themselves.
+func NewContextWatcher(ctx context.Context) *Watcher {
+	w := &Watcher{
+		doneCh: make(chan struct{}),
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			w.Close()
+		case <-w.Done():
+		}
 	}()
 	return w
 }
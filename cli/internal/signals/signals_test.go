@@ -0,0 +1,40 @@
+package signals
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestRemovedClosersAreNotRun(t *testing.T) {
+	w := &Watcher{doneCh: make(chan struct{})}
+	var ran []string
+	w.AddOnClose(func() { ran = append(ran, "first") })
+	remove := w.AddOnClose(func() { ran = append(ran, "second") })
+	w.AddOnClose(func() { ran = append(ran, "third") })
+	remove()
+	// Removing twice is harmless
+	remove()
+
+	w.Close()
+	assert.DeepEqual(t, ran, []string{"first", "third"})
+	<-w.Done()
+}
+
+func TestHoldDelaysDone(t *testing.T) {
+	w := &Watcher{doneCh: make(chan struct{})}
+	release := w.Hold()
+	w.Close()
+	select {
+	case <-w.Done():
+		t.Fatal("Done was closed while the watcher was held")
+	default:
+	}
+	release()
+	// Releasing twice is harmless
+	release()
+	<-w.Done()
+
+	// Holding a watcher that is done has no effect
+	w.Hold()()
+}
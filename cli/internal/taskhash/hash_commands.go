@@ -0,0 +1,86 @@
+package taskhash
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+// hashCommandKey identifies the output of a hash command, which only depends
+// on the directory that it runs in
+type hashCommandKey struct {
+	dir     turbopath.AbsoluteSystemPath
+	command string
+}
+
+// hashCommandResult is the hash of the output of a hash command, which only
+// runs once per directory, even when several tasks list it
+type hashCommandResult struct {
+	once sync.Once
+	hash string
+	err  error
+}
+
+// hashCommands runs the "hashCommands" of a task in dir, and returns the hash
+// of the output of each command, by command
+func (th *Tracker) hashCommands(dir turbopath.AbsoluteSystemPath, commands []string) (map[string]string, error) {
+	if len(commands) == 0 {
+		return nil, nil
+	}
+	hashes := make(map[string]string, len(commands))
+	for _, command := range commands {
+		key := hashCommandKey{dir: dir, command: command}
+		th.hashCommandsMu.Lock()
+		result, ok := th.hashCommandResults[key]
+		if !ok {
+			result = &hashCommandResult{}
+			th.hashCommandResults[key] = result
+		}
+		th.hashCommandsMu.Unlock()
+
+		result.once.Do(func() {
+			result.hash, result.err = runHashCommand(dir, command)
+		})
+		if result.err != nil {
+			return nil, result.err
+		}
+		hashes[command] = result.hash
+	}
+	return hashes, nil
+}
+
+// runHashCommand runs command through the shell in dir, and hashes its
+// standard output. The command fails the hash if it exits with an error.
+func runHashCommand(dir turbopath.AbsoluteSystemPath, command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Dir = dir.ToString()
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("hash command %q failed: %v: %v", command, err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("hash command %q failed: %v", command, err)
+	}
+	return fs.HashObject(string(output))
+}
+
+// hashCommandsToHashable lists the hashes of hash commands in a stable order
+func hashCommandsToHashable(hashes map[string]string) []string {
+	hashable := make([]string, 0, len(hashes))
+	for command, hash := range hashes {
+		hashable = append(hashable, command+"="+hash)
+	}
+	sort.Strings(hashable)
+	return hashable
+}
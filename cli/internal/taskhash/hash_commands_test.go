@@ -0,0 +1,53 @@
+package taskhash
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/turbopath"
+)
+
+func Test_hashCommands(t *testing.T) {
+	dir := turbopath.AbsoluteSystemPathFromUpstream(t.TempDir())
+	th := NewTracker(dir, "___ROOT___", "global", fs.Pipeline{}, fs.LatestHashVersion)
+
+	hashes, err := th.hashCommands(dir, []string{"echo hello", "echo runs >> runs.txt"})
+	if err != nil {
+		t.Fatalf("failed to run hash commands: %v", err)
+	}
+	output := "hello\n"
+	if runtime.GOOS == "windows" {
+		output = "hello\r\n"
+	}
+	want, err := fs.HashObject(output)
+	if err != nil {
+		t.Fatalf("failed to hash output: %v", err)
+	}
+	if got := hashes["echo hello"]; got != want {
+		t.Errorf("hash of \"echo hello\", got %v want %v", got, want)
+	}
+
+	// Commands only run once per directory
+	if _, err := th.hashCommands(dir, []string{"echo runs >> runs.txt"}); err != nil {
+		t.Fatalf("failed to run hash commands: %v", err)
+	}
+	runs, err := dir.UntypedJoin("runs.txt").ReadFile()
+	if err != nil {
+		t.Fatalf("failed to read runs.txt: %v", err)
+	}
+	if count := strings.Count(string(runs), "runs"); count != 1 {
+		t.Errorf("hash command ran %v times, want 1", count)
+	}
+
+	hashable := hashCommandsToHashable(hashes)
+	if len(hashable) != 2 || !strings.HasPrefix(hashable[0], "echo hello=") {
+		t.Errorf("hashable hash commands, got %v", hashable)
+	}
+
+	_, err = th.hashCommands(dir, []string{"echo broken 1>&2 && exit 1"})
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("expected the failing hash command to report its output, got %v", err)
+	}
+}
@@ -5,6 +5,8 @@ package taskhash
 
 import (
 	"fmt"
+	"path"
+	"runtime"
 	"sort"
 	"strings"
 	"sync"
@@ -30,37 +32,69 @@ import (
 // package-task hashing is threadsafe, provided topographical order is
 // respected.
 type Tracker struct {
+	repoRoot   turbopath.AbsoluteSystemPath
 	rootNode   string
 	globalHash string
 	pipeline   fs.Pipeline
+	// hashVersion is the version of the algorithm that tasks are hashed with
+	hashVersion fs.HashVersion
+	// fallbackVersion is the version of the fallback hashes of tasks, which
+	// are only calculated when it isn't 0, from fallbackGlobalHash
+	fallbackVersion    fs.HashVersion
+	fallbackGlobalHash string
 
-	packageInputsHashes packageFileHashes
+	packageInputsHashes         packageFileHashes
+	packageInputsFallbackHashes packageFileHashes
 
 	// packageInputsExpandedHashes is a map of a hashkey to a list of files that are inputs to the task.
 	// Writes to this map happen during CalculateFileHash(). Since this happens synchronously
 	// before walking the task graph, it does not need to be protected by a mutex.
 	packageInputsExpandedHashes map[packageFileHashKey]map[turbopath.AnchoredUnixPath]string
 
+	// hashCommandResults are the results of the hash commands that ran, so
+	// that each one only runs once per directory
+	hashCommandsMu     sync.Mutex
+	hashCommandResults map[hashCommandKey]*hashCommandResult
+
 	// mu is a mutex that we can lock/unlock to read/write from maps
 	// the fields below should be protected by the mutex.
 	mu                   sync.RWMutex
 	packageTaskEnvVars   map[string]env.DetailedMap // taskId -> envvar pairs that affect the hash.
 	packageTaskHashes    map[string]string          // taskID -> hash
+	packageTaskFallbacks map[string]string          // taskID -> hash with the fallback version
 	packageTaskFramework map[string]string          // taskID -> inferred framework for package
+
+	// packageTaskCommands are the hashes of the output of hash commands, by
+	// taskID, then by command
+	packageTaskCommands map[string]map[string]string
 }
 
 // NewTracker creates a tracker for package-inputs combinations and package-task combinations.
-func NewTracker(rootNode string, globalHash string, pipeline fs.Pipeline) *Tracker {
+// The global hash must have been calculated with the same hash version.
+func NewTracker(repoRoot turbopath.AbsoluteSystemPath, rootNode string, globalHash string, pipeline fs.Pipeline, hashVersion fs.HashVersion) *Tracker {
 	return &Tracker{
+		repoRoot:             repoRoot,
 		rootNode:             rootNode,
 		globalHash:           globalHash,
 		pipeline:             pipeline,
+		hashVersion:          hashVersion,
 		packageTaskHashes:    make(map[string]string),
+		packageTaskFallbacks: make(map[string]string),
 		packageTaskFramework: make(map[string]string),
 		packageTaskEnvVars:   make(map[string]env.DetailedMap),
+		packageTaskCommands:  make(map[string]map[string]string),
+		hashCommandResults:   make(map[hashCommandKey]*hashCommandResult),
 	}
 }
 
+// SetFallback makes the tracker also hash tasks with another version of the
+// algorithm, from the global hash of that version, so that the cache entries of
+// that version can be looked up. Must be called before calculating hashes.
+func (th *Tracker) SetFallback(version fs.HashVersion, globalHash string) {
+	th.fallbackVersion = version
+	th.fallbackGlobalHash = globalHash
+}
+
 // packageFileSpec defines a combination of a package and optional set of input globs
 type packageFileSpec struct {
 	pkg    string
@@ -91,13 +125,13 @@ func safeCompileIgnoreFile(filepath string) (*gitignore.GitIgnore, error) {
 	return gitignore.CompileIgnoreLines([]string{}...), nil
 }
 
-func (pfs *packageFileSpec) getHashObject(pkg *fs.PackageJSON, repoRoot turbopath.AbsoluteSystemPath) map[turbopath.AnchoredUnixPath]string {
+func (pfs *packageFileSpec) getHashObject(pkg *fs.PackageJSON, repoRoot turbopath.AbsoluteSystemPath, fileHasher *hashing.FileHasher) map[turbopath.AnchoredUnixPath]string {
 	hashObject, pkgDepsErr := hashing.GetPackageDeps(repoRoot, &hashing.PackageDepsOptions{
 		PackagePath:   pkg.Dir,
 		InputPatterns: pfs.inputs,
 	})
 	if pkgDepsErr != nil {
-		manualHashObject, err := manuallyHashPackage(pkg, pfs.inputs, repoRoot)
+		manualHashObject, err := manuallyHashPackage(pkg, pfs.inputs, repoRoot, fileHasher)
 		if err != nil {
 			return make(map[turbopath.AnchoredUnixPath]string)
 		}
@@ -107,16 +141,50 @@ func (pfs *packageFileSpec) getHashObject(pkg *fs.PackageJSON, repoRoot turbopat
 	return hashObject
 }
 
-func (pfs *packageFileSpec) hash(hashObject map[turbopath.AnchoredUnixPath]string) (string, error) {
-	hashOfFiles, otherErr := fs.HashObject(hashObject)
+// withoutWorkspaces leaves the files of the workspaces other than the root
+// out of the files of the root workspace
+func withoutWorkspaces(hashObject map[turbopath.AnchoredUnixPath]string, workspaceInfos workspace.Catalog) map[turbopath.AnchoredUnixPath]string {
+	workspaceDirs := make(util.Set)
+	for name, pkg := range workspaceInfos.PackageJSONs {
+		if name != util.RootPkgName {
+			workspaceDirs.Add(pkg.Dir.ToUnixPath().ToString())
+		}
+	}
+	files := make(map[turbopath.AnchoredUnixPath]string, len(hashObject))
+	for file, hash := range hashObject {
+		inWorkspace := false
+		for dir := path.Dir(file.ToString()); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			if workspaceDirs.Includes(dir) {
+				inWorkspace = true
+				break
+			}
+		}
+		if !inWorkspace {
+			files[file] = hash
+		}
+	}
+	return files
+}
+
+func (pfs *packageFileSpec) hash(version fs.HashVersion, hashObject map[turbopath.AnchoredUnixPath]string) (string, error) {
+	hashOfFiles, otherErr := fs.HashFileHashes(version, hashObject)
 	if otherErr != nil {
 		return "", otherErr
 	}
 	return hashOfFiles, nil
 }
 
-func manuallyHashPackage(pkg *fs.PackageJSON, inputs []string, rootPath turbopath.AbsoluteSystemPath) (map[turbopath.AnchoredUnixPath]string, error) {
-	hashObject := make(map[turbopath.AnchoredUnixPath]string)
+// PackageFileHash hashes the files of a package, the same way they are hashed
+// for its tasks that don't configure inputs with the default hash version
+func PackageFileHash(pkg *fs.PackageJSON, repoRoot turbopath.AbsoluteSystemPath) (string, error) {
+	spec := &packageFileSpec{pkg: pkg.Name}
+	return spec.hash(fs.DefaultHashVersion, spec.getHashObject(pkg, repoRoot, hashing.NewFileHasher(runtime.NumCPU())))
+}
+
+// manuallyHashPackage hashes the files of a package without git. The files are
+// listed first, then hashed by fileHasher.
+func manuallyHashPackage(pkg *fs.PackageJSON, inputs []string, rootPath turbopath.AbsoluteSystemPath, fileHasher *hashing.FileHasher) (map[turbopath.AnchoredUnixPath]string, error) {
+	var filesToHash []turbopath.AnchoredSystemPath
 	// Instead of implementing all gitignore properly, we hack it. We only respect .gitignore in the root and in
 	// the directory of a package.
 	ignore, err := safeCompileIgnoreFile(rootPath.UntypedJoin(".gitignore").ToString())
@@ -129,9 +197,10 @@ func manuallyHashPackage(pkg *fs.PackageJSON, inputs []string, rootPath turbopat
 		return nil, err
 	}
 
+	globs := hashing.ParseInputGlobs(inputs)
 	includePattern := ""
-	if len(inputs) > 0 {
-		includePattern = "{" + strings.Join(inputs, ",") + "}"
+	if len(globs.Inclusions) > 0 {
+		includePattern = "{" + strings.Join(globs.Inclusions, ",") + "}"
 	}
 
 	pathPrefix := rootPath.UntypedJoin(pkg.Dir.ToStringDuringMigration()).ToString()
@@ -142,8 +211,12 @@ func manuallyHashPackage(pkg *fs.PackageJSON, inputs []string, rootPath turbopat
 		otherMatch := ignorePkg.MatchesPath(convertedName.ToString())
 		if !rootMatch && !otherMatch {
 			if !isDir {
+				relativePath, err := convertedName.RelativeTo(convertedPathPrefix)
+				if err != nil {
+					return fmt.Errorf("File path cannot be made relative: %w", err)
+				}
 				if includePattern != "" {
-					val, err := doublestar.PathMatch(includePattern, convertedName.ToString())
+					val, err := doublestar.Match(includePattern, relativePath.ToUnixPath().ToString())
 					if err != nil {
 						return err
 					}
@@ -151,21 +224,15 @@ func manuallyHashPackage(pkg *fs.PackageJSON, inputs []string, rootPath turbopat
 						return nil
 					}
 				}
-				hash, err := fs.GitLikeHashFile(convertedName.ToString())
-				if err != nil {
-					return fmt.Errorf("could not hash file %v. \n%w", convertedName.ToString(), err)
-				}
-
-				relativePath, err := convertedName.RelativeTo(convertedPathPrefix)
-				if err != nil {
-					return fmt.Errorf("File path cannot be made relative: %w", err)
+				if !globs.Includes(relativePath.ToUnixPath()) {
+					return nil
 				}
-				hashObject[relativePath.ToUnixPath()] = hash
+				filesToHash = append(filesToHash, relativePath)
 			}
 		}
 		return nil
 	})
-	return hashObject, nil
+	return fileHasher.HashFiles(convertedPathPrefix, filesToHash)
 }
 
 // packageFileHashes is a map from a package and optional input globs to the hash of
@@ -173,7 +240,9 @@ func manuallyHashPackage(pkg *fs.PackageJSON, inputs []string, rootPath turbopat
 type packageFileHashes map[packageFileHashKey]string
 
 // CalculateFileHashes hashes each unique package-inputs combination that is present
-// in the task graph. Must be called before calculating task hashes.
+// in the task graph. Must be called before calculating task hashes. At most
+// workerCount packages are hashed at the same time, and, when git can't hash
+// them, at most workerCount files.
 func (th *Tracker) CalculateFileHashes(
 	allTasks []dag.Vertex,
 	workerCount int,
@@ -210,9 +279,11 @@ func (th *Tracker) CalculateFileHashes(
 	}
 
 	hashes := make(map[packageFileHashKey]string, len(hashTasks))
+	fallbackHashes := make(map[packageFileHashKey]string, len(hashTasks))
 	hashObjects := make(map[packageFileHashKey]map[turbopath.AnchoredUnixPath]string, len(hashTasks))
 	hashQueue := make(chan *packageFileSpec, workerCount)
 	hashErrs := &errgroup.Group{}
+	fileHasher := hashing.NewFileHasher(workerCount)
 
 	for i := 0; i < workerCount; i++ {
 		hashErrs.Go(func() error {
@@ -221,15 +292,37 @@ func (th *Tracker) CalculateFileHashes(
 				if !ok {
 					return fmt.Errorf("cannot find package %v", packageFileSpec.pkg)
 				}
-				hashObject := packageFileSpec.getHashObject(pkg, repoRoot)
-				hash, err := packageFileSpec.hash(hashObject)
+				hashObject := packageFileSpec.getHashObject(pkg, repoRoot, fileHasher)
+				filesForVersion := func(version fs.HashVersion) map[turbopath.AnchoredUnixPath]string {
+					return hashObject
+				}
+				if packageFileSpec.pkg == util.RootPkgName && len(packageFileSpec.inputs) == 0 {
+					rootHashObject := withoutWorkspaces(hashObject, workspaceInfos)
+					filesForVersion = func(version fs.HashVersion) map[turbopath.AnchoredUnixPath]string {
+						if version >= fs.HashVersion4 {
+							return rootHashObject
+						}
+						return hashObject
+					}
+				}
+				hash, err := packageFileSpec.hash(th.hashVersion, filesForVersion(th.hashVersion))
 				if err != nil {
 					return err
 				}
+				// The files are only read once, whichever versions they are
+				// hashed with
+				var fallbackHash string
+				if th.fallbackVersion != 0 {
+					fallbackHash, err = packageFileSpec.hash(th.fallbackVersion, filesForVersion(th.fallbackVersion))
+					if err != nil {
+						return err
+					}
+				}
 				th.mu.Lock()
 				pfsKey := packageFileSpec.ToKey()
 				hashes[pfsKey] = hash
-				hashObjects[pfsKey] = hashObject
+				fallbackHashes[pfsKey] = fallbackHash
+				hashObjects[pfsKey] = filesForVersion(th.hashVersion)
 				th.mu.Unlock()
 			}
 			return nil
@@ -244,6 +337,7 @@ func (th *Tracker) CalculateFileHashes(
 		return err
 	}
 	th.packageInputsHashes = hashes
+	th.packageInputsFallbackHashes = fallbackHashes
 	th.packageInputsExpandedHashes = hashObjects
 	return nil
 }
@@ -258,9 +352,14 @@ type taskHashInputs struct {
 	hashableEnvPairs     []string
 	globalHash           string
 	taskDependencyHashes []string
+	setEnv               map[string]string
+	dotEnvPairs          []string
+	hashCommands         []string
 }
 
-func (th *Tracker) calculateDependencyHashes(dependencySet dag.Set) ([]string, error) {
+// calculateDependencyHashes looks up the hashes of the dependencies in
+// taskHashes, which is either packageTaskHashes or packageTaskFallbacks
+func (th *Tracker) calculateDependencyHashes(dependencySet dag.Set, taskHashes map[string]string) ([]string, error) {
 	dependencyHashSet := make(util.Set)
 
 	rootPrefix := th.rootNode + util.TaskDelimiter
@@ -277,7 +376,7 @@ func (th *Tracker) calculateDependencyHashes(dependencySet dag.Set) ([]string, e
 		if strings.HasPrefix(dependencyTask, rootPrefix) {
 			continue
 		}
-		dependencyHash, ok := th.packageTaskHashes[dependencyTask]
+		dependencyHash, ok := taskHashes[dependencyTask]
 		if !ok {
 			return nil, fmt.Errorf("missing hash for dependent task: %v", dependencyTask)
 		}
@@ -317,15 +416,24 @@ func (th *Tracker) CalculateTaskHash(packageTask *nodes.PackageTask, dependencyS
 		return "", err
 	}
 	hashableEnvPairs := envVars.All.ToHashable()
+	envVars.PassThrough = env.GetPassThroughEnvVars(packageTask.TaskDefinition.PassThroughEnv)
+	dotEnv, err := packageTask.DotEnv(th.repoRoot)
+	if err != nil {
+		return "", err
+	}
+	hashCommands, err := th.hashCommands(packageTask.Pkg.Dir.RestoreAnchor(th.repoRoot), packageTask.TaskDefinition.HashCommands)
+	if err != nil {
+		return "", err
+	}
 	outputs := packageTask.HashableOutputs()
-	taskDependencyHashes, err := th.calculateDependencyHashes(dependencySet)
+	taskDependencyHashes, err := th.calculateDependencyHashes(dependencySet, th.packageTaskHashes)
 	if err != nil {
 		return "", err
 	}
 	// log any auto detected env vars
 	logger.Debug(fmt.Sprintf("task hash env vars for %s:%s", packageTask.PackageName, packageTask.Task), "vars", hashableEnvPairs)
 
-	hash, err := fs.HashObject(&taskHashInputs{
+	inputs := taskHashInputs{
 		packageDir:           packageTask.Pkg.Dir.ToUnixPath(),
 		hashOfFiles:          hashOfFiles,
 		externalDepsHash:     packageTask.Pkg.ExternalDepsHash,
@@ -335,13 +443,39 @@ func (th *Tracker) CalculateTaskHash(packageTask *nodes.PackageTask, dependencyS
 		hashableEnvPairs:     hashableEnvPairs,
 		globalHash:           th.globalHash,
 		taskDependencyHashes: taskDependencyHashes,
-	})
+		setEnv:               packageTask.TaskDefinition.SetEnv,
+		dotEnvPairs:          dotEnv.ToHashable(),
+		hashCommands:         hashCommandsToHashable(hashCommands),
+	}
+	hash, err := fs.HashObjectVersion(th.hashVersion, &inputs)
 	if err != nil {
 		return "", fmt.Errorf("failed to hash task %v: %v", packageTask.TaskID, hash)
 	}
+
+	// The fallback hash differs in the hashes that the task hash is made of
+	var fallbackHash string
+	if th.fallbackVersion != 0 {
+		inputs.hashOfFiles = th.packageInputsFallbackHashes[pkgFileHashKey]
+		inputs.globalHash = th.fallbackGlobalHash
+		inputs.taskDependencyHashes, err = th.calculateDependencyHashes(dependencySet, th.packageTaskFallbacks)
+		if err != nil {
+			return "", err
+		}
+		fallbackHash, err = fs.HashObjectVersion(th.fallbackVersion, &inputs)
+		if err != nil {
+			return "", fmt.Errorf("failed to hash task %v with version %v: %v", packageTask.TaskID, th.fallbackVersion, err)
+		}
+	}
+
 	th.mu.Lock()
 	th.packageTaskEnvVars[packageTask.TaskID] = envVars
 	th.packageTaskHashes[packageTask.TaskID] = hash
+	if hashCommands != nil {
+		th.packageTaskCommands[packageTask.TaskID] = hashCommands
+	}
+	if fallbackHash != "" {
+		th.packageTaskFallbacks[packageTask.TaskID] = fallbackHash
+	}
 	if framework != nil {
 		th.packageTaskFramework[packageTask.TaskID] = framework.Slug
 	}
@@ -362,6 +496,26 @@ func (th *Tracker) GetExpandedInputs(packageTask *nodes.PackageTask) map[turbopa
 	return inputsCopy
 }
 
+// GetInputsHash returns the hash of the files that are inputs to a given
+// PackageTask, which is part of its task hash
+func (th *Tracker) GetInputsHash(packageTask *nodes.PackageTask) string {
+	pfs := specFromPackageTask(packageTask)
+	return th.packageInputsHashes[pfs.ToKey()]
+}
+
+// GetFallbackHash returns the hash of a task with the fallback hash version, or
+// "" without a fallback
+func (th *Tracker) GetFallbackHash(taskID string) string {
+	th.mu.RLock()
+	defer th.mu.RUnlock()
+	return th.packageTaskFallbacks[taskID]
+}
+
+// HashVersion returns the version of the algorithm that tasks are hashed with
+func (th *Tracker) HashVersion() fs.HashVersion {
+	return th.hashVersion
+}
+
 // GetEnvVars returns the hashed env vars for a given taskID
 func (th *Tracker) GetEnvVars(taskID string) env.DetailedMap {
 	th.mu.RLock()
@@ -369,6 +523,14 @@ func (th *Tracker) GetEnvVars(taskID string) env.DetailedMap {
 	return th.packageTaskEnvVars[taskID]
 }
 
+// GetHashCommands returns the hashes of the output of the hash commands of a
+// given taskID, by command
+func (th *Tracker) GetHashCommands(taskID string) map[string]string {
+	th.mu.RLock()
+	defer th.mu.RUnlock()
+	return th.packageTaskCommands[taskID]
+}
+
 // GetFramework returns the inferred framework for a given taskID
 func (th *Tracker) GetFramework(taskID string) string {
 	th.mu.RLock()
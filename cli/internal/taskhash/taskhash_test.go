@@ -2,11 +2,15 @@ package taskhash
 
 import (
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/hashing"
 	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+	"github.com/vercel/turbo/cli/internal/workspace"
 )
 
 func Test_manuallyHashPackage(t *testing.T) {
@@ -83,7 +87,7 @@ func Test_manuallyHashPackage(t *testing.T) {
 	pkg := &fs.PackageJSON{
 		Dir: pkgName,
 	}
-	hashes, err := manuallyHashPackage(pkg, []string{}, repoRoot)
+	hashes, err := manuallyHashPackage(pkg, []string{}, repoRoot, hashing.NewFileHasher(2))
 	if err != nil {
 		t.Fatalf("failed to calculate manual hashes: %v", err)
 	}
@@ -110,7 +114,7 @@ func Test_manuallyHashPackage(t *testing.T) {
 	}
 
 	count = 0
-	justFileHashes, err := manuallyHashPackage(pkg, []string{filepath.FromSlash("**/*file")}, repoRoot)
+	justFileHashes, err := manuallyHashPackage(pkg, []string{filepath.FromSlash("**/*file")}, repoRoot, hashing.NewFileHasher(2))
 	if err != nil {
 		t.Fatalf("failed to calculate manual hashes: %v", err)
 	}
@@ -135,3 +139,33 @@ func Test_manuallyHashPackage(t *testing.T) {
 		t.Errorf("found extra hashes in %v", hashes)
 	}
 }
+
+func Test_withoutWorkspaces(t *testing.T) {
+	workspaceInfos := workspace.Catalog{
+		PackageJSONs: map[string]*fs.PackageJSON{
+			util.RootPkgName: {Dir: turbopath.AnchoredSystemPath("")},
+			"web":            {Dir: turbopath.AnchoredUnixPath("apps/web").ToSystemPath()},
+			"ui":             {Dir: turbopath.AnchoredUnixPath("packages/ui").ToSystemPath()},
+		},
+	}
+	hashObject := map[turbopath.AnchoredUnixPath]string{
+		"package.json":               "a",
+		"scripts/codegen.js":         "b",
+		"apps/web/package.json":      "c",
+		"apps/web/src/index.ts":      "d",
+		"apps/webby/package.json":    "e",
+		"packages/ui/package.json":   "f",
+		"packages/ui/src/button.tsx": "g",
+		"packages/README.md":         "h",
+	}
+	got := withoutWorkspaces(hashObject, workspaceInfos)
+	want := map[turbopath.AnchoredUnixPath]string{
+		"package.json":            "a",
+		"scripts/codegen.js":      "b",
+		"apps/webby/package.json": "e",
+		"packages/README.md":      "h",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("withoutWorkspaces got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,59 @@
+// Package telemetry implements `turbo telemetry`, which reports and changes
+// whether turbo sends anonymous usage events
+package telemetry
+
+import (
+	"fmt"
+
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+	"github.com/vercel/turbo/cli/internal/ui"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// ExecuteTelemetry executes the `telemetry` command.
+func ExecuteTelemetry(helper *cmdutil.Helper, args *turbostate.ParsedArgsFromRust) error {
+	base, err := helper.GetCmdBase(args)
+	if err != nil {
+		return err
+	}
+	if err := telemetry(base, args.Command.Telemetry.Command); err != nil {
+		base.LogError("telemetry failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+func telemetry(base *cmdutil.CmdBase, command string) error {
+	switch command {
+	case "Enable":
+		if err := base.UserConfig.SetTelemetryDisabled(false); err != nil {
+			return err
+		}
+	case "Disable":
+		if err := base.UserConfig.SetTelemetryDisabled(true); err != nil {
+			return err
+		}
+	case "Status":
+	default:
+		return fmt.Errorf("unknown subcommand: %v", command)
+	}
+	printStatus(base)
+	return nil
+}
+
+func printStatus(base *cmdutil.CmdBase) {
+	if base.UserConfig.TelemetryDisabled() {
+		base.UI.Output(util.Sprintf("Telemetry is ${BOLD}disabled${RESET}"))
+		base.UI.Output(ui.Dim("turbo does not send usage events. Run `turbo telemetry enable` to send them."))
+	} else {
+		base.UI.Output(util.Sprintf("Telemetry is ${BOLD}enabled${RESET}"))
+		if base.APIClient.IsLinked() {
+			base.UI.Output("turbo records the hash, source, duration and hit or miss of each cache lookup, with a random session id.")
+		} else {
+			base.UI.Output("This repository is not linked, so turbo does not send any events.")
+		}
+		base.UI.Output(ui.Dim("Run `turbo telemetry disable`, or set DO_NOT_TRACK=1 or TURBO_TELEMETRY_DISABLED=1, to stop sending them."))
+	}
+	base.UI.Output(ui.Dim("Set TURBO_TELEMETRY_DEBUG=1 to print every event to stderr as it is sent."))
+}
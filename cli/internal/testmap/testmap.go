@@ -0,0 +1,130 @@
+// Package testmap narrows the test tasks of an --affected run down to the ones
+// that cover the files that changed, with a map of the files that each test
+// task covers, such as one built from coverage reports. Tasks run when the map
+// can't tell whether they are affected, so that no test is skipped by mistake.
+package testmap
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// _maxReasonFiles is how many of the changed files a reason lists
+const _maxReasonFiles = 3
+
+// Map is a test map file, such as:
+//
+//	{
+//	  "files": {
+//	    "packages/ui/src/button.tsx": ["ui#test", "web#test"]
+//	  }
+//	}
+type Map struct {
+	// Files maps files, relative to the repository root, to the IDs of the
+	// tasks that cover them
+	Files map[string][]string `json:"files"`
+}
+
+// Load reads the test map at path
+func Load(path turbopath.AbsoluteSystemPath) (*Map, error) {
+	contents, err := path.ReadFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the test map: %w", err)
+	}
+	m := &Map{}
+	if err := json.Unmarshal(contents, m); err != nil {
+		return nil, fmt.Errorf("failed to parse the test map %v: %w", path, err)
+	}
+	return m, nil
+}
+
+// Selection is whether a task of the test map runs, and why
+type Selection struct {
+	TaskID   string `json:"taskId"`
+	Selected bool   `json:"selected"`
+	Reason   string `json:"reason"`
+}
+
+// Changes are the changes that tasks are selected for
+type Changes struct {
+	// Files changed since the --affected base, relative to the repository root
+	Files []string
+	// GlobalChanged is whether a global dependency changed
+	GlobalChanged bool
+	// Workspace returns the name of the workspace that a file is in, other
+	// than the root
+	Workspace func(file string) (string, bool)
+}
+
+// Select decides which of the given tasks run, by the files that changed. A
+// task that the map lists runs if it covers a changed file, if a file in its
+// workspace that the map doesn't list changed, or if a file outside of the
+// workspaces that the map doesn't list changed, since the map can't tell
+// whether those affect it. Tasks that the map doesn't list aren't selected
+// from, and are left out. Selections are sorted by task ID.
+func (m *Map) Select(taskIDs []string, changes Changes) []*Selection {
+	files := make(map[string][]string, len(m.Files))
+	covered := make(map[string]int)
+	for file, coveringTaskIDs := range m.Files {
+		file = path.Clean(file)
+		files[file] = append(files[file], coveringTaskIDs...)
+		for _, taskID := range coveringTaskIDs {
+			covered[taskID]++
+		}
+	}
+	coveredChanges := make(map[string][]string)
+	unmappedChanges := make(map[string][]string)
+	var outsideChanges []string
+	for _, file := range changes.Files {
+		if coveringTaskIDs, ok := files[file]; ok {
+			for _, taskID := range coveringTaskIDs {
+				coveredChanges[taskID] = append(coveredChanges[taskID], file)
+			}
+		} else if workspace, ok := changes.Workspace(file); ok {
+			unmappedChanges[workspace] = append(unmappedChanges[workspace], file)
+		} else {
+			outsideChanges = append(outsideChanges, file)
+		}
+	}
+
+	selections := []*Selection{}
+	for _, taskID := range taskIDs {
+		if _, ok := covered[taskID]; !ok {
+			continue
+		}
+		workspace, _ := util.GetPackageTaskFromId(taskID)
+		selection := &Selection{TaskID: taskID, Selected: true}
+		switch {
+		case changes.GlobalChanged:
+			selection.Reason = "a global dependency changed"
+		case len(outsideChanges) > 0:
+			selection.Reason = fmt.Sprintf("%v changed outside of the workspaces, and isn't in the test map", listFiles(outsideChanges))
+		case len(coveredChanges[taskID]) > 0:
+			selection.Reason = fmt.Sprintf("it covers %v, which changed", listFiles(coveredChanges[taskID]))
+		case len(unmappedChanges[workspace]) > 0:
+			selection.Reason = fmt.Sprintf("%v changed in its workspace, and isn't in the test map", listFiles(unmappedChanges[workspace]))
+		default:
+			selection.Selected = false
+			selection.Reason = fmt.Sprintf("none of the %v files it covers changed", covered[taskID])
+		}
+		selections = append(selections, selection)
+	}
+	sort.Slice(selections, func(i, j int) bool {
+		return selections[i].TaskID < selections[j].TaskID
+	})
+	return selections
+}
+
+// listFiles lists the first few of files
+func listFiles(files []string) string {
+	if len(files) <= _maxReasonFiles {
+		return strings.Join(files, ", ")
+	}
+	return fmt.Sprintf("%v and %v more", strings.Join(files[:_maxReasonFiles], ", "), len(files)-_maxReasonFiles)
+}
@@ -0,0 +1,104 @@
+package testmap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vercel/turbo/cli/internal/turbopath"
+	"gotest.tools/v3/assert"
+)
+
+func workspaceOf(file string) (string, bool) {
+	switch {
+	case strings.HasPrefix(file, "packages/ui/"):
+		return "ui", true
+	case strings.HasPrefix(file, "apps/web/"):
+		return "web", true
+	}
+	return "", false
+}
+
+func TestSelect(t *testing.T) {
+	m := &Map{Files: map[string][]string{
+		"packages/ui/src/button.tsx": {"ui#test", "web#test"},
+		"packages/ui/src/menu.tsx":   {"ui#test"},
+		"apps/web/src/page.tsx":      {"web#test", "web#test:e2e"},
+		"./apps/web/src/nav.tsx":     {"web#test:e2e"},
+	}}
+	taskIDs := []string{"web#test:e2e", "web#test", "ui#test", "ui#lint"}
+
+	testCases := []struct {
+		name       string
+		changes    Changes
+		selections []*Selection
+	}{
+		{
+			name:    "covered file",
+			changes: Changes{Files: []string{"packages/ui/src/menu.tsx"}},
+			selections: []*Selection{
+				{TaskID: "ui#test", Selected: true, Reason: "it covers packages/ui/src/menu.tsx, which changed"},
+				{TaskID: "web#test", Selected: false, Reason: "none of the 2 files it covers changed"},
+				{TaskID: "web#test:e2e", Selected: false, Reason: "none of the 2 files it covers changed"},
+			},
+		},
+		{
+			name:    "paths are cleaned",
+			changes: Changes{Files: []string{"apps/web/src/nav.tsx"}},
+			selections: []*Selection{
+				{TaskID: "ui#test", Selected: false, Reason: "none of the 2 files it covers changed"},
+				{TaskID: "web#test", Selected: false, Reason: "none of the 2 files it covers changed"},
+				{TaskID: "web#test:e2e", Selected: true, Reason: "it covers apps/web/src/nav.tsx, which changed"},
+			},
+		},
+		{
+			name:    "file in a workspace that isn't in the map",
+			changes: Changes{Files: []string{"apps/web/src/new.tsx"}},
+			selections: []*Selection{
+				{TaskID: "ui#test", Selected: false, Reason: "none of the 2 files it covers changed"},
+				{TaskID: "web#test", Selected: true, Reason: "apps/web/src/new.tsx changed in its workspace, and isn't in the test map"},
+				{TaskID: "web#test:e2e", Selected: true, Reason: "apps/web/src/new.tsx changed in its workspace, and isn't in the test map"},
+			},
+		},
+		{
+			name:    "file outside of the workspaces",
+			changes: Changes{Files: []string{"tsconfig.base.json"}},
+			selections: []*Selection{
+				{TaskID: "ui#test", Selected: true, Reason: "tsconfig.base.json changed outside of the workspaces, and isn't in the test map"},
+				{TaskID: "web#test", Selected: true, Reason: "tsconfig.base.json changed outside of the workspaces, and isn't in the test map"},
+				{TaskID: "web#test:e2e", Selected: true, Reason: "tsconfig.base.json changed outside of the workspaces, and isn't in the test map"},
+			},
+		},
+		{
+			name:    "global dependency",
+			changes: Changes{GlobalChanged: true},
+			selections: []*Selection{
+				{TaskID: "ui#test", Selected: true, Reason: "a global dependency changed"},
+				{TaskID: "web#test", Selected: true, Reason: "a global dependency changed"},
+				{TaskID: "web#test:e2e", Selected: true, Reason: "a global dependency changed"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.changes.Workspace = workspaceOf
+			assert.DeepEqual(t, m.Select(taskIDs, tc.changes), tc.selections)
+		})
+	}
+}
+
+func TestListFiles(t *testing.T) {
+	assert.Equal(t, listFiles([]string{"a", "b"}), "a, b")
+	assert.Equal(t, listFiles([]string{"a", "b", "c", "d", "e"}), "a, b, c and 2 more")
+}
+
+func TestLoad(t *testing.T) {
+	dir := turbopath.AbsoluteSystemPath(t.TempDir())
+	file := dir.UntypedJoin("test-map.json")
+	assert.NilError(t, file.WriteFile([]byte(`{"files": {"apps/web/src/page.tsx": ["web#test"]}}`), 0644))
+	m, err := Load(file)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, m.Files, map[string][]string{"apps/web/src/page.tsx": {"web#test"}})
+
+	_, err = Load(dir.UntypedJoin("missing.json"))
+	assert.ErrorContains(t, err, "failed to read the test map")
+}
@@ -71,6 +71,11 @@ func (p AbsoluteSystemPath) OpenFile(flags int, mode os.FileMode) (*os.File, err
 	return os.OpenFile(p.ToString(), flags, mode)
 }
 
+// Chmod implements os.Chmod for an absolute path
+func (p AbsoluteSystemPath) Chmod(mode os.FileMode) error {
+	return os.Chmod(p.ToString(), mode)
+}
+
 // Lstat implements os.Lstat for absolute path
 func (p AbsoluteSystemPath) Lstat() (os.FileInfo, error) {
 	return os.Lstat(p.ToString())
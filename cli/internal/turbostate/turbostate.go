@@ -14,31 +14,211 @@ type RepoState struct {
 	Mode string `json:"mode"`
 }
 
+// AuditPayload is the extra flags passed for the `audit` subcommand
+type AuditPayload struct {
+	JSON bool `json:"json"`
+}
+
+// BenchPayload is the extra flags passed for the `bench` subcommand, along with
+// the flags it shares with `run`
+type BenchPayload struct {
+	RunPayload
+	Iterations int  `json:"iterations"`
+	ClearCache bool `json:"clear_cache"`
+}
+
+// BoundariesPayload is the extra flags passed for the `boundaries` subcommand
+type BoundariesPayload struct{}
+
+// CachePayload is the subcommand and flags passed for the `cache` subcommand
+type CachePayload struct {
+	Command  string `json:"command"`
+	Listen   string `json:"listen"`
+	Dir      string `json:"dir"`
+	MaxAge   string `json:"max_age"`
+	MaxSize  string `json:"max_size"`
+	CacheDir string `json:"cache_dir"`
+	Hash     string `json:"hash"`
+	To       string `json:"to"`
+	JSON     bool   `json:"json"`
+}
+
+// ComparePayload is the runs and flags passed for the `compare` subcommand
+type ComparePayload struct {
+	Before string `json:"before"`
+	After  string `json:"after"`
+	JSON   bool   `json:"json"`
+}
+
+// CompletePayload is the kind of candidates requested by the `__complete` subcommand
+type CompletePayload struct {
+	Kind string `json:"kind"`
+}
+
+// ConfigPayload is the subcommand and arguments passed for the `config` subcommand
+type ConfigPayload struct {
+	Command string `json:"command"`
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+}
+
 // DaemonPayload is the extra flags and command that are
 // passed for the `daemon` subcommand
 type DaemonPayload struct {
-	IdleTimeout string `json:"idle_time"`
-	Command     string `json:"command"`
-	JSON        bool   `json:"json"`
+	IdleTimeout  string `json:"idle_time"`
+	MetricsAddr  string `json:"metrics_addr"`
+	Watcher      string `json:"watcher"`
+	PollInterval string `json:"poll_interval"`
+	Command      string `json:"command"`
+	JSON         bool   `json:"json"`
+}
+
+// DoctorPayload is the extra flags passed for the `doctor` subcommand
+type DoctorPayload struct {
+	CacheDir string `json:"cache_dir"`
+	JSON     bool   `json:"json"`
+}
+
+// ExplainPayload is the task and flags passed for the `explain` subcommand
+type ExplainPayload struct {
+	// Task is <package>#<task>
+	Task   string `json:"task"`
+	Runs   int    `json:"runs"`
+	Daemon bool   `json:"daemon"`
+	JSON   bool   `json:"json"`
+}
+
+// FlakyPayload is the extra flags passed for the `flaky` subcommand, which is
+// the same as `insights flaky`
+type FlakyPayload struct {
+	JSON bool `json:"json"`
+	Runs int  `json:"runs"`
+}
+
+// InsightsPayload is the subcommand and flags passed for the `insights` subcommand
+type InsightsPayload struct {
+	Command string `json:"command"`
+	JSON    bool   `json:"json"`
+	Runs    int    `json:"runs"`
+}
+
+// GenPayload is the extra flags and subcommand passed for the `gen` subcommand
+type GenPayload struct {
+	Command       string   `json:"command"`
+	Name          string   `json:"name"`
+	WorkspaceType string   `json:"workspace_type"`
+	Copy          string   `json:"copy"`
+	Destination   string   `json:"destination"`
+	Generator     string   `json:"generator"`
+	Args          []string `json:"args"`
+}
+
+// GlobalHashPayload is the extra flags passed for the `globalhash` subcommand
+type GlobalHashPayload struct {
+	Explain bool `json:"explain"`
+	JSON    bool `json:"json"`
+}
+
+// HashInputsPayload is the task and flags passed for the `hash-inputs` subcommand
+type HashInputsPayload struct {
+	Task   string   `json:"task"`
+	Filter []string `json:"filter"`
+	JSON   bool     `json:"json"`
+}
+
+// InfoPayload is the extra flags passed for the `info` subcommand
+type InfoPayload struct {
+	Filter []string `json:"filter"`
+	JSON   bool     `json:"json"`
+}
+
+// LoginPayload is the extra flags passed for the `login` subcommand, which is
+// only run in Go with the "auth" of "remoteCache" in turbo.json
+type LoginPayload struct {
+	SSOTeam string `json:"sso_team"`
+}
+
+// LsPayload is the extra flags passed for the `ls` subcommand
+type LsPayload struct {
+	Filter []string `json:"filter"`
+	JSON   bool     `json:"json"`
+}
+
+// PrefetchPayload is the tasks and flags passed for the `prefetch` subcommand
+type PrefetchPayload struct {
+	Tasks    []string `json:"tasks"`
+	Filter   []string `json:"filter"`
+	CacheDir string   `json:"cache_dir"`
 }
 
 // PrunePayload is the extra flags passed for the `prune` subcommand
 type PrunePayload struct {
-	Scope     []string `json:"scope"`
-	Docker    bool     `json:"docker"`
-	OutputDir string   `json:"output_dir"`
+	Scope  []string `json:"scope"`
+	Docker bool     `json:"docker"`
+	// DockerBuildkitContext writes the layers of --docker, a lockfile layer,
+	// a Dockerfile snippet and a manifest of the content of the layers
+	DockerBuildkitContext bool   `json:"docker_buildkit_context"`
+	OutputDir             string `json:"output_dir"`
+}
+
+// QueryPayload is the query or subcommand passed to the `query` subcommand
+type QueryPayload struct {
+	Command string `json:"command"`
+	Query   string `json:"query"`
+	Filter  string `json:"filter"`
 }
 
 // RunPayload is the extra flags passed for the `run` subcommand
 type RunPayload struct {
-	CacheDir          string   `json:"cache_dir"`
-	CacheWorkers      int      `json:"cache_workers"`
-	Concurrency       string   `json:"concurrency"`
-	ContinueExecution bool     `json:"continue_execution"`
-	DryRun            string   `json:"dry_run"`
-	Filter            []string `json:"filter"`
-	Force             bool     `json:"force"`
-	GlobalDeps        []string `json:"global_deps"`
+	Affected     bool   `json:"affected"`
+	AffectedBase string `json:"affected_base"`
+	// Also are more groups of tasks to run in the same task graph, from --also
+	Also []string `json:"also"`
+	// Cache is which caches the run uses, from --cache
+	Cache    string `json:"cache"`
+	CacheDir string `json:"cache_dir"`
+	// CacheKeySuffix is appended to the hash of every task
+	CacheKeySuffix string `json:"cache_key_suffix"`
+	// CacheScope is the namespace that artifacts are uploaded to in the remote cache
+	CacheScope       string `json:"cache_scope"`
+	CacheWorkers     int    `json:"cache_workers"`
+	CheckDeterminism bool   `json:"check_determinism"`
+	// CheckOutputs warns about stale files in the outputs of tasks, and
+	// CleanOutputs removes them
+	CheckOutputs      bool   `json:"check_outputs"`
+	CleanOutputs      bool   `json:"clean_outputs"`
+	Concurrency       string `json:"concurrency"`
+	ContinueExecution bool   `json:"continue_execution"`
+	DryRun            string `json:"dry_run"`
+	// EnvMode is whether tasks inherit turbo's whole environment, or only the
+	// variables they declare
+	EnvMode       string `json:"env_mode"`
+	ErrorExitCode *int   `json:"error_exit_code"`
+	// Events is where the events of the run are streamed, "fd:N" or "unix:PATH"
+	Events   string `json:"events"`
+	ExitCode string `json:"exit_code"`
+	// ExperimentalRemoteWorkers are the addresses of `turbo worker` agents to dispatch tasks to
+	ExperimentalRemoteWorkers []string `json:"experimental_remote_workers"`
+	// ExperimentalRemoteWorkersCA is a CA certificate used to verify the TLS certificates of the workers
+	ExperimentalRemoteWorkersCA string `json:"experimental_remote_workers_ca"`
+	// ExperimentalSandbox runs tasks in a copy of the repository with only the files their hash covers
+	ExperimentalSandbox bool `json:"experimental_sandbox"`
+	// ExperimentalSpeculate starts tasks that will likely miss the cache
+	// before their dependencies are restored from it
+	ExperimentalSpeculate bool `json:"experimental_speculate"`
+	// ExperimentalTraceFiles records the files tasks access to check their inputs and outputs
+	ExperimentalTraceFiles bool `json:"experimental_trace_files"`
+	// FailureBundles writes a zip file with the context of every task that fails
+	FailureBundles bool     `json:"failure_bundles"`
+	Filter         []string `json:"filter"`
+	Force          bool     `json:"force"`
+	ForceFilter    []string `json:"force_filter"`
+	GitHubActions  bool     `json:"github_actions"`
+	GlobalDeps     []string `json:"global_deps"`
+	// GracePeriod is how long tasks have to exit when the run is canceled, such as "30s"
+	GracePeriod string `json:"grace_period"`
+	// HashLock is a file of pinned hashes that tasks are restored from
+	HashLock string `json:"hash_lock"`
 	// NOTE: Graph has three effective states that is modeled using a *string:
 	//   nil -> no flag passed
 	//   ""  -> flag passed but no file name attached: print to stdout
@@ -48,49 +228,153 @@ type RunPayload struct {
 	Graph               *string  `json:"graph"`
 	Ignore              []string `json:"ignore"`
 	IncludeDependencies bool     `json:"include_dependencies"`
-	NoCache             bool     `json:"no_cache"`
-	NoDaemon            bool     `json:"no_daemon"`
-	NoDeps              bool     `json:"no_deps"`
-	Only                bool     `json:"only"`
-	OutputLogs          string   `json:"output_logs"`
-	PassThroughArgs     []string `json:"pass_through_args"`
-	Parallel            bool     `json:"parallel"`
-	Profile             string   `json:"profile"`
-	RemoteOnly          bool     `json:"remote_only"`
-	Scope               []string `json:"scope"`
-	Since               string   `json:"since"`
-	SinglePackage       bool     `json:"single_package"`
+	// MaxCacheSize caps the size of the filesystem cache, such as "10GB"
+	MaxCacheSize string   `json:"max_cache_size"`
+	NoCache      bool     `json:"no_cache"`
+	NoCacheLogs  bool     `json:"no_cache_logs"`
+	NoDaemon     bool     `json:"no_daemon"`
+	NoDeps       bool     `json:"no_deps"`
+	Only         bool     `json:"only"`
+	OutputLogs   []string `json:"output_logs"`
+	// OTLPEndpoint is the base URL of an OpenTelemetry collector to export the run's spans to
+	OTLPEndpoint    string   `json:"otlp_endpoint"`
+	PassThroughArgs []string `json:"pass_through_args"`
+	Parallel        bool     `json:"parallel"`
+	PRReport        string   `json:"pr_report"`
+	PRReportBase    string   `json:"pr_report_base"`
+	Profile         string   `json:"profile"`
+	Progress        bool     `json:"progress"`
+	// ProfileName selects a profile from turbo.json that fills in the flags that weren't passed
+	ProfileName string `json:"profile_name"`
+	// Record is the file to write a record of the run to, for `turbo replay`
+	Record               string   `json:"record"`
+	RemoteOnly           bool     `json:"remote_only"`
+	Report               []string `json:"report"`
+	RestoreDryRun        bool     `json:"restore_dry_run"`
+	Scope                []string `json:"scope"`
+	SerialDeterministic  bool     `json:"serial_deterministic"`
+	Shard                string   `json:"shard"`
+	ShowGlobalHashInputs bool     `json:"show_global_hash_inputs"`
+	Since                string   `json:"since"`
+	SinglePackage        bool     `json:"single_package"`
+	// Strict fails the run when the outputs or inputs of tasks are at odds with git
+	Strict bool `json:"strict"`
+	// Summarize is nil without --summarize, "" to save a run summary, and
+	// "resources" to also print the resources used by each task
+	Summarize *string `json:"summarize"`
+	// SummaryFile is where the JSON summary from SummaryFormat is written, instead of stdout
+	SummaryFile string `json:"summary_file"`
+	// SummaryFormat is how the outcome of the run is written at the end of it
+	SummaryFormat       string   `json:"summary_format"`
+	TaskArgs            []string `json:"task_args"`
+	TaskFailureExitCode *int     `json:"task_failure_exit_code"`
 	Tasks               []string `json:"tasks"`
-	PkgInferenceRoot    string   `json:"pkg_inference_root"`
-	LogPrefix           string   `json:"log_prefix"`
+	// TestMap is a map of the files that test tasks cover, for --affected
+	TestMap string `json:"test_map"`
+	// Watch keeps turbo running, and runs the tasks again when files change
+	Watch bool `json:"watch"`
+	// WriteHashLock is the file to write the hashes of the run to, for HashLock
+	WriteHashLock    string `json:"write_hash_lock"`
+	PkgInferenceRoot string `json:"pkg_inference_root"`
+	LogPrefix        string `json:"log_prefix"`
+	LogPrefixColor   string `json:"log_prefix_color"`
+	LogOrder         string `json:"log_order"`
+	UI               string `json:"ui"`
+}
+
+// ReplayPayload is the bundle and flags passed for the `replay` subcommand
+type ReplayPayload struct {
+	Bundle           string `json:"bundle"`
+	IgnoreMismatches bool   `json:"ignore_mismatches"`
+	VerifyOnly       bool   `json:"verify_only"`
+}
+
+// RunsPayload is the subcommand and flags passed for the `runs` subcommand
+type RunsPayload struct {
+	Command  string `json:"command"`
+	MaxCount int    `json:"max_count"`
+	MaxAge   string `json:"max_age"`
+	JSON     bool   `json:"json"`
+	Limit    int    `json:"limit"`
+}
+
+// ServePayload is the extra flags passed for the `serve` subcommand
+type ServePayload struct {
+	Listen        string `json:"listen"`
+	SinglePackage bool   `json:"single_package"`
+}
+
+// TelemetryPayload is the subcommand passed for the `telemetry` subcommand
+type TelemetryPayload struct {
+	Command string `json:"command"`
+}
+
+// TokenPayload is the subcommand passed for the `token` subcommand
+type TokenPayload struct {
+	Command string `json:"command"`
+}
+
+// WorkerPayload is the extra flags passed for the `worker` subcommand
+type WorkerPayload struct {
+	Listen  string `json:"listen"`
+	TLSCert string `json:"tls_cert"`
+	TLSKey  string `json:"tls_key"`
 }
 
 // Command consists of the data necessary to run a command.
 // Only one of these fields should be initialized at a time.
 type Command struct {
-	Daemon *DaemonPayload `json:"daemon"`
-	Prune  *PrunePayload  `json:"prune"`
-	Run    *RunPayload    `json:"run"`
+	Audit      *AuditPayload      `json:"audit"`
+	Bench      *BenchPayload      `json:"bench"`
+	Boundaries *BoundariesPayload `json:"boundaries"`
+	Cache      *CachePayload      `json:"cache"`
+	Compare    *ComparePayload    `json:"compare"`
+	Complete   *CompletePayload   `json:"complete"`
+	Config     *ConfigPayload     `json:"config"`
+	Daemon     *DaemonPayload     `json:"daemon"`
+	Doctor     *DoctorPayload     `json:"doctor"`
+	Explain    *ExplainPayload    `json:"explain"`
+	Flaky      *FlakyPayload      `json:"flaky"`
+	Gen        *GenPayload        `json:"gen"`
+	GlobalHash *GlobalHashPayload `json:"globalhash"`
+	HashInputs *HashInputsPayload `json:"hash_inputs"`
+	Info       *InfoPayload       `json:"info"`
+	Insights   *InsightsPayload   `json:"insights"`
+	Login      *LoginPayload      `json:"login"`
+	Ls         *LsPayload         `json:"ls"`
+	Prefetch   *PrefetchPayload   `json:"prefetch"`
+	Prune      *PrunePayload      `json:"prune"`
+	Query      *QueryPayload      `json:"query"`
+	Replay     *ReplayPayload     `json:"replay"`
+	Run        *RunPayload        `json:"run"`
+	Runs       *RunsPayload       `json:"runs"`
+	Serve      *ServePayload      `json:"serve"`
+	Telemetry  *TelemetryPayload  `json:"telemetry"`
+	Token      *TokenPayload      `json:"token"`
+	Worker     *WorkerPayload     `json:"worker"`
 }
 
 // ParsedArgsFromRust are the parsed command line arguments passed
 // from the Rust shim
 type ParsedArgsFromRust struct {
-	API                string  `json:"api"`
-	Color              bool    `json:"color"`
-	CPUProfile         string  `json:"cpu_profile"`
-	CWD                string  `json:"cwd"`
-	Heap               string  `json:"heap"`
-	Login              string  `json:"login"`
-	NoColor            bool    `json:"no_color"`
-	Preflight          bool    `json:"preflight"`
-	RemoteCacheTimeout uint64  `json:"remote_cache_timeout"`
-	Team               string  `json:"team"`
-	Token              string  `json:"token"`
-	Trace              string  `json:"trace"`
-	Verbosity          int     `json:"verbosity"`
-	TestRun            bool    `json:"test_run"`
-	Command            Command `json:"command"`
+	API                      string  `json:"api"`
+	Color                    bool    `json:"color"`
+	CPUProfile               string  `json:"cpu_profile"`
+	CWD                      string  `json:"cwd"`
+	Heap                     string  `json:"heap"`
+	Login                    string  `json:"login"`
+	NoColor                  bool    `json:"no_color"`
+	Preflight                bool    `json:"preflight"`
+	RemoteCacheTimeout       uint64  `json:"remote_cache_timeout"`
+	RemoteCacheUploadLimit   string  `json:"remote_cache_upload_limit"`
+	RemoteCacheDownloadLimit string  `json:"remote_cache_download_limit"`
+	RemoteCacheTransferLimit string  `json:"remote_cache_transfer_limit"`
+	Team                     string  `json:"team"`
+	Token                    string  `json:"token"`
+	Trace                    string  `json:"trace"`
+	Verbosity                int     `json:"verbosity"`
+	TestRun                  bool    `json:"test_run"`
+	Command                  Command `json:"command"`
 }
 
 // GetColor returns the value of the `color` flag.
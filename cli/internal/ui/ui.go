@@ -70,6 +70,11 @@ func Rainbow(text string) string {
 	return strings.Join(rainbowStr, "")
 }
 
+// StripAnsi removes ANSI escape codes, such as colors, from str
+func StripAnsi(str string) string {
+	return ansiRegex.ReplaceAllString(str, "")
+}
+
 type stripAnsiWriter struct {
 	wrappedWriter io.Writer
 }
@@ -89,6 +94,21 @@ func (into *stripAnsiWriter) Write(p []byte) (int, error) {
 	return len(p), nil
 }
 
+// OutputWriter returns a writer that passes what is written to u.Output, for
+// output that is produced by an io.Writer. Each write should be whole lines.
+func OutputWriter(u cli.Ui) io.Writer {
+	return &outputWriter{ui: u}
+}
+
+type outputWriter struct {
+	ui cli.Ui
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+	w.ui.Output(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
+
 // Default returns the default colored ui
 func Default() *cli.ColoredUi {
 	return BuildColoredUi(ColorModeUndefined)
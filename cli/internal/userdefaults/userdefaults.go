@@ -0,0 +1,68 @@
+// Package userdefaults implements `turbo config`, which manages the defaults
+// in the user config file that apply to every repository
+package userdefaults
+
+import (
+	"fmt"
+
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/config"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+	"github.com/vercel/turbo/cli/internal/ui"
+	"github.com/vercel/turbo/cli/internal/util"
+)
+
+// ExecuteConfig executes the `config` command.
+func ExecuteConfig(helper *cmdutil.Helper, args *turbostate.ParsedArgsFromRust) error {
+	base, err := helper.GetCmdBase(args)
+	if err != nil {
+		return err
+	}
+	if err := configCommand(base, args.Command.Config); err != nil {
+		base.LogError("config failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+func configCommand(base *cmdutil.CmdBase, opts *turbostate.ConfigPayload) error {
+	switch opts.Command {
+	case "Get":
+		return get(base, opts.Key)
+	case "Set":
+		if err := base.UserConfig.SetDefault(opts.Key, opts.Value); err != nil {
+			return err
+		}
+		base.UI.Output(fmt.Sprintf("Set %v to %v", opts.Key, opts.Value))
+		return nil
+	case "Unset":
+		if err := base.UserConfig.UnsetDefault(opts.Key); err != nil {
+			return err
+		}
+		base.UI.Output(fmt.Sprintf("Unset %v", opts.Key))
+		return nil
+	default:
+		return fmt.Errorf("unknown subcommand: %v", opts.Command)
+	}
+}
+
+// get prints the value of one default, or every default with its description
+// if key is empty
+func get(base *cmdutil.CmdBase, key string) error {
+	if key != "" {
+		if err := config.CheckUserDefaultKey(key); err != nil {
+			return err
+		}
+		base.UI.Output(base.UserConfig.Default(key))
+		return nil
+	}
+	for _, key := range config.UserDefaultKeys() {
+		value := base.UserConfig.Default(key)
+		if value == "" {
+			value = ui.Dim("(not set)")
+		}
+		base.UI.Output(util.Sprintf("${BOLD}%v${RESET} = %v", key, value))
+		base.UI.Output(ui.Dim("  " + config.DescribeUserDefault(key)))
+	}
+	return nil
+}
@@ -0,0 +1,347 @@
+// Package webhooks posts the status of tasks and runs to the endpoints
+// configured in the "webhooks" key of turbo.json while a run is in progress,
+// so that build dashboards are updated as tasks start and finish, without
+// polling the API of the CI provider.
+//
+// Deliveries are sent in the background, in order for each webhook, and are
+// retried when the endpoint can't be reached or responds with a server error.
+// A failed delivery never fails the run.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/runsummary"
+)
+
+// The events that webhooks are sent
+const (
+	TaskStarted = "taskStarted"
+	// TaskFinished is sent for the tasks that didn't fail, including the
+	// cached and canceled ones
+	TaskFinished = "taskFinished"
+	TaskFailed   = "taskFailed"
+	RunFinished  = "runFinished"
+)
+
+var _events = []string{TaskStarted, TaskFinished, TaskFailed, RunFinished}
+
+// The headers of each delivery, besides the Content-Type
+const (
+	EventHeader = "X-Turbo-Event"
+	// DeliveryHeader is the same for every attempt of a delivery, so that
+	// endpoints can ignore the ones that they already received
+	DeliveryHeader = "X-Turbo-Delivery"
+	// SignatureHeader is "sha256=" followed by the hex HMAC-SHA256 of the body
+	// with the secret of the webhook. It is missing if there is no secret.
+	SignatureHeader = "X-Turbo-Signature-256"
+)
+
+const (
+	_timeout        = 10 * time.Second
+	_defaultRetries = 3
+	// _retryDelay doubles after every attempt
+	_retryDelay = 500 * time.Millisecond
+	// _maxQueued is how many deliveries wait for a slow webhook, after which
+	// the new ones are dropped rather than slowing the run down
+	_maxQueued = 4096
+)
+
+// Payload is the JSON body of each delivery
+type Payload struct {
+	Event string `json:"event"`
+	// Time is in milliseconds since the unix epoch
+	Time int64 `json:"time"`
+	// RunID is the same for every delivery of a run, and is the id of its run
+	// summary
+	RunID   string   `json:"runId"`
+	Targets []string `json:"targets"`
+	// Task is set for the events of tasks
+	Task *Task `json:"task,omitempty"`
+	// Summary is set for runFinished, as written by --summary-format=json
+	Summary *runsummary.ExecutionSummary `json:"summary,omitempty"`
+}
+
+// Task is the status of a task
+type Task struct {
+	TaskID string `json:"taskId"`
+	Hash   string `json:"hash"`
+	// Status, ExitCode and Duration are set once the task finished. Status is
+	// that of the task's execution in the run summary, such as "built" or
+	// "failed", and Duration is in milliseconds.
+	Status   string `json:"status,omitempty"`
+	ExitCode *int   `json:"exitCode,omitempty"`
+	Duration *int64 `json:"duration,omitempty"`
+}
+
+// Dispatcher sends the events of a run to the configured webhooks. It is safe
+// for concurrent use, and a nil Dispatcher discards events.
+type Dispatcher struct {
+	runID      string
+	targets    []string
+	webhooks   []*webhook
+	client     *http.Client
+	logger     hclog.Logger
+	now        func() time.Time
+	retryDelay time.Duration
+	wg         sync.WaitGroup
+
+	mu sync.Mutex
+	// closed is set once Close is called, after which events are discarded
+	closed bool
+}
+
+type webhook struct {
+	// index is how the webhook is named in errors, since its URL may contain a secret
+	index   int
+	url     string
+	secret  string
+	events  map[string]bool
+	retries int
+	queue   chan *delivery
+	// failed and err are only written by the goroutine of the webhook
+	failed int
+	err    error
+}
+
+type delivery struct {
+	id    string
+	event string
+	body  []byte
+}
+
+// New returns a Dispatcher for the given webhooks configuration, or nil if no
+// webhook has a URL. The URLs and secrets are expanded with environment
+// variables.
+func New(config []fs.Webhook, runID string, targets []string, logger hclog.Logger) (*Dispatcher, error) {
+	d := &Dispatcher{
+		runID:      runID,
+		targets:    targets,
+		client:     &http.Client{Timeout: _timeout},
+		logger:     logger.Named("webhooks"),
+		now:        time.Now,
+		retryDelay: _retryDelay,
+	}
+	for i, webhookConfig := range config {
+		w := &webhook{
+			index:   i + 1,
+			url:     os.ExpandEnv(webhookConfig.URL),
+			secret:  os.ExpandEnv(webhookConfig.Secret),
+			events:  make(map[string]bool),
+			retries: _defaultRetries,
+		}
+		if webhookConfig.Retries != nil {
+			if *webhookConfig.Retries < 0 {
+				return nil, fmt.Errorf("invalid retries %v of webhook %v, expected 0 or more", *webhookConfig.Retries, w.index)
+			}
+			w.retries = *webhookConfig.Retries
+		}
+		events := webhookConfig.Events
+		if len(events) == 0 {
+			events = _events
+		}
+		for _, event := range events {
+			if !isEvent(event) {
+				return nil, fmt.Errorf("invalid event %q of webhook %v, expected one of %v", event, w.index, strings.Join(_events, ", "))
+			}
+			w.events[event] = true
+		}
+		if w.url == "" {
+			d.logger.Debug("skipping webhook with empty url", "url", webhookConfig.URL)
+			continue
+		}
+		d.webhooks = append(d.webhooks, w)
+	}
+	if len(d.webhooks) == 0 {
+		return nil, nil
+	}
+	for _, w := range d.webhooks {
+		w.queue = make(chan *delivery, _maxQueued)
+		d.wg.Add(1)
+		go d.deliver(w)
+	}
+	return d, nil
+}
+
+func isEvent(event string) bool {
+	for _, e := range _events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// TaskStarted sends the start of a task
+func (d *Dispatcher) TaskStarted(taskID string, hash string) {
+	d.send(&Payload{Event: TaskStarted, Task: &Task{TaskID: taskID, Hash: hash}})
+}
+
+// TaskFinished sends the outcome of a task, as taskFailed if it failed or
+// timed out
+func (d *Dispatcher) TaskFinished(task *Task) {
+	event := TaskFinished
+	if task.Status == runsummary.TaskStatusFailed || task.Status == runsummary.TaskStatusTimedOut {
+		event = TaskFailed
+	}
+	d.send(&Payload{Event: event, Task: task})
+}
+
+// RunFinished sends the outcome of the run
+func (d *Dispatcher) RunFinished(summary *runsummary.ExecutionSummary) {
+	d.send(&Payload{Event: RunFinished, Summary: summary})
+}
+
+func (d *Dispatcher) send(payload *Payload) {
+	if d == nil {
+		return
+	}
+	payload.Time = d.now().UnixMilli()
+	payload.RunID = d.runID
+	payload.Targets = d.targets
+	body, err := json.Marshal(payload)
+	if err != nil {
+		d.logger.Debug("failed to render webhook payload", "error", err)
+		return
+	}
+	delivery := &delivery{id: deliveryID(), event: payload.Event, body: body}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	for _, w := range d.webhooks {
+		if !w.events[payload.Event] {
+			continue
+		}
+		select {
+		case w.queue <- delivery:
+		default:
+			d.logger.Debug("dropped webhook delivery, too many are queued", "webhook", w.index, "event", payload.Event)
+		}
+	}
+}
+
+// deliveryID returns a random id for a delivery
+func deliveryID() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// Close stops accepting events, and waits at most wait for the queued
+// deliveries to be sent. It returns an error describing the webhooks with
+// failed deliveries, or that deliveries were still pending.
+func (d *Dispatcher) Close(wait time.Duration) error {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		for _, w := range d.webhooks {
+			close(w.queue)
+		}
+	}
+	d.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		return fmt.Errorf("stopped waiting for webhook deliveries after %v, the remaining ones weren't sent", wait)
+	}
+	var errs []string
+	for _, w := range d.webhooks {
+		if w.failed > 0 {
+			errs = append(errs, fmt.Sprintf("%v deliveries to webhook %v failed, the last one with: %v", w.failed, w.index, w.err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%v", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// deliver sends the deliveries of a webhook in order, until its queue is closed
+func (d *Dispatcher) deliver(w *webhook) {
+	defer d.wg.Done()
+	for delivery := range w.queue {
+		if err := d.attempt(w, delivery); err != nil {
+			d.logger.Debug("webhook delivery failed", "webhook", w.index, "event", delivery.event, "error", err)
+			w.failed++
+			w.err = err
+		}
+	}
+}
+
+// attempt posts a delivery, and posts it again after a delay that doubles
+// every time, as long as the error can be retried
+func (d *Dispatcher) attempt(w *webhook, delivery *delivery) error {
+	delay := d.retryDelay
+	var err error
+	for attempt := 0; attempt <= w.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+		var retry bool
+		if retry, err = d.post(w, delivery); err == nil || !retry {
+			return err
+		}
+	}
+	return err
+}
+
+// post sends a delivery once, and returns whether it is worth retrying if it
+// failed: requests that didn't get a response, or got a server error or 429
+func (d *Dispatcher) post(w *webhook, delivery *delivery) (bool, error) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, w.url, bytes.NewReader(delivery.body))
+	if err != nil {
+		return false, fmt.Errorf("invalid url")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, delivery.event)
+	req.Header.Set(DeliveryHeader, delivery.id)
+	if w.secret != "" {
+		req.Header.Set(SignatureHeader, Sign(w.secret, delivery.body))
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		// The URL may contain a secret, so it isn't included in the error
+		return true, fmt.Errorf("request failed")
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= 300 {
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("webhook responded with %v", resp.Status)
+	}
+	return false, nil
+}
+
+// Sign returns the value of the SignatureHeader of a body, which endpoints
+// compute with the same secret to check that a delivery came from turbo
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,167 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/vercel/turbo/cli/internal/fs"
+	"github.com/vercel/turbo/cli/internal/runsummary"
+	"gotest.tools/v3/assert"
+)
+
+type request struct {
+	header http.Header
+	body   []byte
+}
+
+// recorder is a webhook endpoint that records the requests it receives, and
+// responds to them with the given statuses, then 200
+type recorder struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []request
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, request{header: req.Header.Clone(), body: body})
+	if len(r.statuses) > 0 {
+		w.WriteHeader(r.statuses[0])
+		r.statuses = r.statuses[1:]
+	}
+}
+
+func newDispatcher(t *testing.T, config []fs.Webhook) *Dispatcher {
+	t.Helper()
+	d, err := New(config, "run-id", []string{"build"}, hclog.NewNullLogger())
+	assert.NilError(t, err)
+	assert.Assert(t, d != nil)
+	d.retryDelay = time.Millisecond
+	return d
+}
+
+func TestDispatcher_Deliveries(t *testing.T) {
+	endpoint := &recorder{}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	d := newDispatcher(t, []fs.Webhook{{URL: server.URL, Secret: "secret"}})
+	d.TaskStarted("web#build", "abc")
+	exitCode := 1
+	d.TaskFinished(&Task{TaskID: "web#build", Hash: "abc", Status: runsummary.TaskStatusFailed, ExitCode: &exitCode})
+	d.RunFinished(&runsummary.ExecutionSummary{})
+	assert.NilError(t, d.Close(time.Second))
+
+	assert.Equal(t, len(endpoint.requests), 3)
+	for i, event := range []string{TaskStarted, TaskFailed, RunFinished} {
+		req := endpoint.requests[i]
+		assert.Equal(t, req.header.Get(EventHeader), event)
+		assert.Equal(t, req.header.Get(SignatureHeader), Sign("secret", req.body))
+		var payload Payload
+		assert.NilError(t, json.Unmarshal(req.body, &payload))
+		assert.Equal(t, payload.Event, event)
+		assert.Equal(t, payload.RunID, "run-id")
+		assert.DeepEqual(t, payload.Targets, []string{"build"})
+	}
+}
+
+func TestDispatcher_Retries(t *testing.T) {
+	endpoint := &recorder{statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	d := newDispatcher(t, []fs.Webhook{{URL: server.URL}})
+	d.TaskStarted("web#build", "abc")
+	assert.NilError(t, d.Close(time.Second))
+
+	assert.Equal(t, len(endpoint.requests), 3)
+	delivery := endpoint.requests[0].header.Get(DeliveryHeader)
+	assert.Assert(t, delivery != "")
+	for _, req := range endpoint.requests {
+		assert.Equal(t, req.header.Get(DeliveryHeader), delivery)
+		assert.Equal(t, req.header.Get(SignatureHeader), "")
+	}
+}
+
+func TestDispatcher_Failures(t *testing.T) {
+	endpoint := &recorder{statuses: []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusInternalServerError}}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	retries := 1
+	d := newDispatcher(t, []fs.Webhook{{URL: server.URL, Retries: &retries}})
+	d.TaskStarted("web#build", "abc")
+	d.TaskStarted("docs#build", "def")
+	err := d.Close(time.Second)
+
+	// A client error isn't retried, a server error is retried once
+	assert.Equal(t, len(endpoint.requests), 3)
+	assert.ErrorContains(t, err, "2 deliveries to webhook 1 failed, the last one with: webhook responded with 500")
+}
+
+func TestDispatcher_Events(t *testing.T) {
+	endpoint := &recorder{}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	d := newDispatcher(t, []fs.Webhook{{URL: server.URL, Events: []string{TaskFailed}}})
+	d.TaskStarted("web#build", "abc")
+	d.TaskFinished(&Task{TaskID: "web#build", Hash: "abc", Status: runsummary.TaskStatusBuilt})
+	d.TaskFinished(&Task{TaskID: "docs#build", Hash: "def", Status: runsummary.TaskStatusTimedOut})
+	d.RunFinished(&runsummary.ExecutionSummary{})
+	assert.NilError(t, d.Close(time.Second))
+
+	assert.Equal(t, len(endpoint.requests), 1)
+	var payload Payload
+	assert.NilError(t, json.Unmarshal(endpoint.requests[0].body, &payload))
+	assert.Equal(t, payload.Task.TaskID, "docs#build")
+}
+
+func TestNew(t *testing.T) {
+	retries := -1
+	testCases := []struct {
+		name    string
+		config  []fs.Webhook
+		wantErr string
+	}{
+		{
+			name: "no webhooks",
+		},
+		{
+			name:   "empty url",
+			config: []fs.Webhook{{URL: "$TURBO_TEST_UNSET_WEBHOOK_URL"}},
+		},
+		{
+			name:    "invalid event",
+			config:  []fs.Webhook{{URL: "http://localhost", Events: []string{"taskStarted", "taskSkipped"}}},
+			wantErr: `invalid event "taskSkipped" of webhook 1`,
+		},
+		{
+			name:    "invalid retries",
+			config:  []fs.Webhook{{URL: "http://localhost", Retries: &retries}},
+			wantErr: "invalid retries -1 of webhook 1",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := New(tc.config, "run-id", nil, hclog.NewNullLogger())
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Assert(t, d == nil)
+			// A nil Dispatcher discards events
+			d.TaskStarted("web#build", "abc")
+			assert.NilError(t, d.Close(time.Second))
+		})
+	}
+}
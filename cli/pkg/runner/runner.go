@@ -0,0 +1,245 @@
+// Package runner lets Go programs run turbo tasks in-process, instead of
+// executing the turbo CLI and parsing its output.
+//
+// Unlike the packages under internal/, the types and functions in this package
+// are a stable API: fields may be added, but existing ones will not change
+// meaning or be removed outside of a major release.
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/vercel/turbo/cli/internal/cmdutil"
+	"github.com/vercel/turbo/cli/internal/process"
+	"github.com/vercel/turbo/cli/internal/run"
+	"github.com/vercel/turbo/cli/internal/runsummary"
+	"github.com/vercel/turbo/cli/internal/signals"
+	"github.com/vercel/turbo/cli/internal/turbostate"
+	"github.com/vercel/turbo/cli/internal/ui"
+)
+
+// Options describe a run. Apart from RepoRoot and Version, they mirror the flags
+// of `turbo run`.
+type Options struct {
+	// RepoRoot is the root of the repository. Required.
+	RepoRoot string
+	// Tasks are the tasks to run. Required.
+	Tasks []string
+	// Filters select the packages to run tasks in, using the syntax of --filter
+	Filters []string
+	// PassThroughArgs are passed to the tasks named in Tasks, like arguments after --
+	PassThroughArgs []string
+	// Concurrency limits how many tasks run at once, as a number or a percentage
+	// of CPUs such as "50%". Defaults to 10.
+	Concurrency     string
+	ContinueOnError bool
+	Parallel        bool
+	// Force ignores existing cache entries
+	Force bool
+	// NoCache skips writing to the cache
+	NoCache bool
+	// OutputLogs is one of "full", "none", "hash-only", "new-only" or "errors-only".
+	// Defaults to "full".
+	OutputLogs string
+	// SinglePackage runs tasks in a repository that is not a monorepo
+	SinglePackage bool
+	// Verbosity sets the level of turbo's own logs, which go to stderr, from 0 to 3
+	Verbosity int
+	// Version is reported as the turbo version in summaries and to the remote cache.
+	// Defaults to "embedded".
+	Version string
+}
+
+// EventType identifies the kind of an Event
+type EventType string
+
+const (
+	// EventOutput is regular output, including each line that tasks write to
+	// stdout or stderr, prefixed with the task's ID
+	EventOutput EventType = "output"
+	// EventInfo is informational output from turbo
+	EventInfo EventType = "info"
+	// EventWarn is a warning from turbo
+	EventWarn EventType = "warn"
+	// EventError is an error from turbo or a task
+	EventError EventType = "error"
+)
+
+// Event is a line of output from a run, without color codes
+type Event struct {
+	Type    EventType
+	Message string
+}
+
+// TaskStatus is the outcome of a task
+type TaskStatus string
+
+const (
+	// TaskBuilt means the task was executed and succeeded
+	TaskBuilt TaskStatus = TaskStatus(runsummary.TaskStatusBuilt)
+	// TaskCached means the task's outputs were restored from the cache
+	TaskCached TaskStatus = TaskStatus(runsummary.TaskStatusCached)
+	// TaskFailed means the task was executed and failed
+	TaskFailed TaskStatus = TaskStatus(runsummary.TaskStatusFailed)
+	// TaskNotRun means the task was not reached, for example because a dependency failed
+	TaskNotRun TaskStatus = "notRun"
+)
+
+// TaskResult is the outcome of a single task in a run
+type TaskResult struct {
+	TaskID   string
+	Package  string
+	Task     string
+	Hash     string
+	Status   TaskStatus
+	Duration time.Duration
+	// ExitCode is nil if the task's command was not run
+	ExitCode *int
+}
+
+// Summary is the outcome of a run
+type Summary struct {
+	ID    string
+	Tasks []*TaskResult
+}
+
+// ExitError is returned when at least one task failed
+type ExitError struct {
+	// ExitCode is the highest exit code of the failed tasks
+	ExitCode int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("tasks failed with exit code %v", e.ExitCode)
+}
+
+// Run runs the tasks described by opts and returns their outcomes. If onEvent is
+// not nil, it is called with each line of output; calls are never concurrent.
+//
+// The summary is returned along with an *ExitError when tasks fail. It is nil if
+// the run failed before executing tasks. Cancelling ctx stops running tasks.
+// The daemon is not used, so that embedding programs don't leave one running.
+func Run(ctx context.Context, opts Options, onEvent func(Event)) (*Summary, error) {
+	if opts.RepoRoot == "" {
+		return nil, errors.New("RepoRoot is required")
+	}
+	if len(opts.Tasks) == 0 {
+		return nil, errors.New("at least one task must be specified")
+	}
+	version := opts.Version
+	if version == "" {
+		version = "embedded"
+	}
+
+	args := parsedArgs(opts)
+	helper := cmdutil.NewHelper(version, args)
+	base, err := helper.GetCmdBase(args)
+	if err != nil {
+		return nil, err
+	}
+	defer helper.Cleanup(args)
+	base.UI = &eventUI{onEvent: onEvent}
+
+	signalWatcher := signals.NewContextWatcher(ctx)
+	defer signalWatcher.Close()
+	summary, err := run.RunTasks(ctx, base, signalWatcher, args, ui.OutputWriter(base.UI))
+	var exitErr *process.ChildExit
+	if errors.As(err, &exitErr) {
+		err = &ExitError{ExitCode: exitErr.ExitCode}
+	}
+	if summary == nil {
+		return nil, err
+	}
+	return newSummary(summary), err
+}
+
+func parsedArgs(opts Options) *turbostate.ParsedArgsFromRust {
+	var outputLogs []string
+	if opts.OutputLogs != "" {
+		outputLogs = []string{opts.OutputLogs}
+	}
+	return &turbostate.ParsedArgsFromRust{
+		CWD:       opts.RepoRoot,
+		NoColor:   true,
+		Verbosity: opts.Verbosity,
+		Command: turbostate.Command{
+			Run: &turbostate.RunPayload{
+				Tasks:             opts.Tasks,
+				Filter:            opts.Filters,
+				PassThroughArgs:   opts.PassThroughArgs,
+				Concurrency:       opts.Concurrency,
+				ContinueExecution: opts.ContinueOnError,
+				Parallel:          opts.Parallel,
+				Force:             opts.Force,
+				NoCache:           opts.NoCache,
+				OutputLogs:        outputLogs,
+				SinglePackage:     opts.SinglePackage,
+				NoDaemon:          true,
+				CacheWorkers:      10,
+			},
+		},
+	}
+}
+
+func newSummary(summary *runsummary.RunSummary) *Summary {
+	result := &Summary{
+		ID:    summary.ID.String(),
+		Tasks: make([]*TaskResult, 0, len(summary.Tasks)),
+	}
+	for _, task := range summary.Tasks {
+		taskResult := &TaskResult{
+			TaskID:  task.TaskID,
+			Package: task.Package,
+			Task:    task.Task,
+			Hash:    task.Hash,
+			Status:  TaskNotRun,
+		}
+		if task.Execution != nil {
+			taskResult.Status = TaskStatus(task.Execution.Status)
+			taskResult.Duration = time.Duration(task.Execution.Duration) * time.Millisecond
+			taskResult.ExitCode = task.Execution.ExitCode
+		}
+		result.Tasks = append(result.Tasks, taskResult)
+	}
+	return result
+}
+
+// eventUI is a cli.Ui that reports each line of output as an Event
+type eventUI struct {
+	mu      sync.Mutex
+	onEvent func(Event)
+}
+
+var _ cli.Ui = (*eventUI)(nil)
+
+func (u *eventUI) emit(eventType EventType, message string) {
+	if u.onEvent == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for _, line := range strings.Split(strings.TrimRight(ui.StripAnsi(message), "\n"), "\n") {
+		u.onEvent(Event{Type: eventType, Message: line})
+	}
+}
+
+func (u *eventUI) Output(message string) { u.emit(EventOutput, message) }
+func (u *eventUI) Info(message string)   { u.emit(EventInfo, message) }
+func (u *eventUI) Warn(message string)   { u.emit(EventWarn, message) }
+func (u *eventUI) Error(message string)  { u.emit(EventError, message) }
+
+// Ask is not supported, since embedded runs are not interactive
+func (u *eventUI) Ask(query string) (string, error) {
+	return "", errors.New("input is not available in embedded runs")
+}
+
+// AskSecret is not supported, since embedded runs are not interactive
+func (u *eventUI) AskSecret(query string) (string, error) {
+	return u.Ask(query)
+}
@@ -0,0 +1,131 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/segmentio/ksuid"
+	"github.com/vercel/turbo/cli/internal/runsummary"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestRunValidatesOptions(t *testing.T) {
+	_, err := Run(context.Background(), Options{Tasks: []string{"build"}}, nil)
+	assert.Error(t, err, "RepoRoot is required")
+
+	_, err = Run(context.Background(), Options{RepoRoot: t.TempDir()}, nil)
+	assert.Error(t, err, "at least one task must be specified")
+}
+
+func TestParsedArgs(t *testing.T) {
+	args := parsedArgs(Options{
+		RepoRoot:        "/repo",
+		Tasks:           []string{"build", "test"},
+		Filters:         []string{"web..."},
+		PassThroughArgs: []string{"--coverage"},
+		Force:           true,
+		OutputLogs:      "errors-only",
+	})
+	assert.Equal(t, args.CWD, "/repo")
+	assert.Equal(t, args.NoColor, true)
+	payload := args.Command.Run
+	assert.DeepEqual(t, payload.Tasks, []string{"build", "test"})
+	assert.DeepEqual(t, payload.Filter, []string{"web..."})
+	assert.DeepEqual(t, payload.PassThroughArgs, []string{"--coverage"})
+	assert.Equal(t, payload.Force, true)
+	assert.DeepEqual(t, payload.OutputLogs, []string{"errors-only"})
+	assert.Equal(t, payload.NoDaemon, true)
+}
+
+func TestNewSummary(t *testing.T) {
+	exitCode := 1
+	id := ksuid.New()
+	summary := newSummary(&runsummary.RunSummary{
+		ID: id,
+		Tasks: []*runsummary.TaskSummary{
+			{
+				TaskID:  "web#build",
+				Package: "web",
+				Task:    "build",
+				Hash:    "abc",
+				Execution: &runsummary.TaskExecutionSummary{
+					Status:   runsummary.TaskStatusCached,
+					Duration: 1500,
+				},
+			},
+			{
+				TaskID:  "web#test",
+				Package: "web",
+				Task:    "test",
+				Hash:    "def",
+				Execution: &runsummary.TaskExecutionSummary{
+					Status:   runsummary.TaskStatusFailed,
+					Duration: 20,
+					ExitCode: &exitCode,
+				},
+			},
+			{
+				TaskID:  "web#lint",
+				Package: "web",
+				Task:    "lint",
+				Hash:    "ghi",
+			},
+		},
+	})
+	assert.DeepEqual(t, summary, &Summary{
+		ID: id.String(),
+		Tasks: []*TaskResult{
+			{TaskID: "web#build", Package: "web", Task: "build", Hash: "abc", Status: TaskCached, Duration: 1500 * time.Millisecond},
+			{TaskID: "web#test", Package: "web", Task: "test", Hash: "def", Status: TaskFailed, Duration: 20 * time.Millisecond, ExitCode: &exitCode},
+			{TaskID: "web#lint", Package: "web", Task: "lint", Hash: "ghi", Status: TaskNotRun},
+		},
+	})
+}
+
+func TestEventUI(t *testing.T) {
+	var events []Event
+	u := &eventUI{onEvent: func(event Event) {
+		events = append(events, event)
+	}}
+	u.Output("\x1b[2mweb:build: \x1b[0mcompiled\nweb:build: done\n")
+	u.Warn("careful")
+	assert.DeepEqual(t, events, []Event{
+		{Type: EventOutput, Message: "web:build: compiled"},
+		{Type: EventOutput, Message: "web:build: done"},
+		{Type: EventWarn, Message: "careful"},
+	})
+
+	// A nil callback discards output
+	(&eventUI{}).Output("ignored")
+}
+
+func TestRunRealTask(t *testing.T) {
+	if _, err := exec.LookPath("npm"); err != nil {
+		t.Skip("npm is required to run tasks")
+	}
+	repoRoot := t.TempDir()
+	files := map[string]string{
+		"package.json":              `{"name": "root", "packageManager": "npm@8.19.2", "workspaces": ["packages/*"]}`,
+		"turbo.json":                `{"pipeline": {"build": {"outputs": []}}}`,
+		"packages/web/package.json": `{"name": "web", "scripts": {"build": "echo hello from build"}}`,
+	}
+	for path, contents := range files {
+		file := filepath.Join(repoRoot, path)
+		assert.NilError(t, os.MkdirAll(filepath.Dir(file), 0755))
+		assert.NilError(t, os.WriteFile(file, []byte(contents), 0644))
+	}
+
+	var events []Event
+	summary, err := Run(context.Background(), Options{RepoRoot: repoRoot, Tasks: []string{"build"}}, func(event Event) {
+		events = append(events, event)
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, len(summary.Tasks), 1)
+	assert.Equal(t, summary.Tasks[0].Status, TaskBuilt)
+	assert.Assert(t, cmp.Contains(events, Event{Type: EventOutput, Message: "web:build: hello from build"}), "%v", events)
+}
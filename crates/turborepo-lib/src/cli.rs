@@ -1,19 +1,19 @@
 use std::{
     env::{self, current_dir},
-    io, mem,
+    mem,
     path::{Path, PathBuf},
     process,
 };
 
 use anyhow::{anyhow, Result};
-use clap::{ArgAction, CommandFactory, Parser, Subcommand, ValueEnum};
-use clap_complete::{generate, Shell};
+use clap::{ArgAction, Parser, Subcommand, ValueEnum};
+use clap_complete::Shell;
 use dunce::canonicalize as fs_canonicalize;
 use log::{debug, error};
 use serde::Serialize;
 
 use crate::{
-    commands::{bin, link, login, logout, unlink, CommandBase},
+    commands::{bin, completion, link, login, logout, unlink, CommandBase},
     get_version,
     shim::{RepoMode, RepoState},
     ui::UI,
@@ -44,6 +44,45 @@ impl Default for OutputLogsMode {
     }
 }
 
+/// A value of `--output-logs`: the mode for every task, or `<task>=<mode>` to
+/// override the mode of one task. It is passed to Go as the string it was
+/// parsed from.
+#[derive(Clone, Debug, PartialEq)]
+pub enum OutputLogs {
+    All(OutputLogsMode),
+    Task { task: String, mode: OutputLogsMode },
+}
+
+impl OutputLogs {
+    fn parse(value: &str) -> Result<Self, String> {
+        match value.split_once('=') {
+            Some(("", _)) => Err(format!("expected <task>=<mode>, got \"{value}\"")),
+            Some((task, mode)) => Ok(Self::Task {
+                task: task.to_string(),
+                mode: OutputLogsMode::from_str(mode, false)?,
+            }),
+            None => Ok(Self::All(OutputLogsMode::from_str(value, false)?)),
+        }
+    }
+}
+
+impl Serialize for OutputLogs {
+    fn serialize<S: serde::Serializer>(&self, serializer: S) -> Result<S::Ok, S::Error> {
+        let mode_name = |mode: &OutputLogsMode| {
+            mode.to_possible_value()
+                .expect("output logs modes are not skipped")
+                .get_name()
+                .to_string()
+        };
+        match self {
+            Self::All(mode) => serializer.serialize_str(&mode_name(mode)),
+            Self::Task { task, mode } => {
+                serializer.serialize_str(&format!("{}={}", task, mode_name(mode)))
+            }
+        }
+    }
+}
+
 // NOTE: These *must* be kept in sync with the `_dryRunJSONValue`
 // and `_dryRunTextValue` constants in run.go.
 #[derive(Copy, Clone, Debug, PartialEq, Serialize, ValueEnum)]
@@ -52,6 +91,45 @@ pub enum DryRunMode {
     Json,
 }
 
+// NOTE: These *must* be kept in sync with the `_exitCode*Value` constants in
+// exit_code.go.
+#[derive(Copy, Clone, Debug, PartialEq, Serialize, ValueEnum)]
+pub enum ExitCodeMode {
+    /// The highest exit code of any failed task
+    Highest,
+    /// The exit code of the first task to fail
+    First,
+    /// A fixed exit code for each kind of failure
+    Fixed,
+    /// A bit for each kind of failure: 1 if a task failed, 2 if a task timed
+    /// out and 4 if turbo failed for another reason
+    Bitmask,
+}
+
+// NOTE: These *must* be kept in sync with the `Watcher*` constants in
+// filewatcher/select.go.
+#[derive(Copy, Clone, Debug, PartialEq, Serialize, ValueEnum)]
+#[serde(rename_all = "lowercase")]
+pub enum WatcherBackend {
+    /// Use the native watcher, unless the repository is on a network
+    /// filesystem such as NFS or virtiofs
+    Auto,
+    /// Use fsevents on macOS and inotify or the equivalent elsewhere
+    Native,
+    /// Walk the repository every poll interval to find changes
+    Poll,
+}
+
+// NOTE: These *must* be kept in sync with the `_summaryFormat*Value` constants
+// in run.go.
+#[derive(Copy, Clone, Debug, PartialEq, Serialize, ValueEnum)]
+pub enum SummaryFormat {
+    /// The colored task counts and time printed to the terminal
+    Text,
+    /// A JSON document with the outcome, duration and exit code of each task
+    Json,
+}
+
 #[derive(Parser, Clone, Default, Debug, PartialEq, Serialize)]
 #[clap(author, about = "The build system that makes ship happen", long_about = None)]
 #[clap(disable_help_subcommand = true)]
@@ -96,6 +174,16 @@ pub struct Args {
     /// Set a timeout for all HTTP requests.
     #[clap(long, value_name = "TIMEOUT", global = true, value_parser)]
     pub remote_cache_timeout: Option<u64>,
+    /// Limit the combined rate of remote cache uploads, e.g. "5MB" per second
+    #[clap(long, value_name = "RATE", global = true)]
+    pub remote_cache_upload_limit: Option<String>,
+    /// Limit the combined rate of remote cache downloads, e.g. "20MB" per
+    /// second
+    #[clap(long, value_name = "RATE", global = true)]
+    pub remote_cache_download_limit: Option<String>,
+    /// Limit the rate of each individual remote cache upload or download
+    #[clap(long, value_name = "RATE", global = true)]
+    pub remote_cache_transfer_limit: Option<String>,
     /// Set the team slug for API calls
     #[clap(long, global = true, value_parser)]
     pub team: Option<String>,
@@ -164,6 +252,207 @@ pub enum DaemonCommand {
     Stop,
 }
 
+#[derive(Subcommand, Clone, Debug, Serialize, PartialEq)]
+#[serde(tag = "command")]
+pub enum ConfigCommand {
+    /// Print a user default, or every user default if no setting is given
+    Get { key: Option<String> },
+    /// Save a user default, e.g. `turbo config set concurrency 4`
+    Set { key: String, value: String },
+    /// Remove a user default
+    Unset { key: String },
+}
+
+#[derive(Subcommand, Clone, Debug, Serialize, PartialEq)]
+#[serde(tag = "command")]
+pub enum TelemetryCommand {
+    /// Reports whether usage events are sent
+    Status,
+    /// Sends anonymous usage events
+    Enable,
+    /// Stops sending usage events
+    Disable,
+}
+
+#[derive(Subcommand, Clone, Debug, Serialize, PartialEq)]
+#[serde(tag = "command")]
+pub enum TokenCommand {
+    /// Print the token sent to the remote cache, refreshing it first if it
+    /// was issued by the provider in "remoteCache.auth" and is about to expire
+    Print,
+}
+
+#[derive(Subcommand, Clone, Debug, Serialize, PartialEq)]
+#[serde(tag = "command")]
+pub enum CacheCommand {
+    /// Serve the remote cache API from a directory on local disk, for use as
+    /// a self-hosted remote cache with `--api`
+    ///
+    /// Requests must send the token printed at startup, or the value of
+    /// TURBO_CACHE_SERVER_TOKEN, as a bearer token. Clients also need a team,
+    /// which can be any value. Metrics are served at /metrics without a token.
+    Serve {
+        /// The address to listen for requests on
+        #[clap(long, default_value = "127.0.0.1:9878")]
+        listen: String,
+        /// The directory to store artifacts in. Relative paths are resolved
+        /// from the repository root. Defaults to .turbo/cache-server
+        #[clap(long)]
+        dir: Option<String>,
+        /// Remove artifacts that haven't been used for this long, e.g. "72h"
+        /// or "14d"
+        #[clap(long, default_value = "7d")]
+        max_age: String,
+        /// Remove the least recently used artifacts while the artifacts take
+        /// up more than this much space, e.g. "10GB"
+        #[clap(long)]
+        max_size: Option<String>,
+    },
+    /// Remove artifacts from the local filesystem cache
+    ///
+    /// Artifacts that haven't been used for --max-age are removed, and then
+    /// the least recently used artifacts while the cache is larger than
+    /// --max-size.
+    Gc {
+        /// The filesystem cache directory. Defaults to TURBO_CACHE_DIR, the
+        /// cache_dir user default, or ./node_modules/.cache/turbo
+        #[clap(long)]
+        cache_dir: Option<String>,
+        /// Remove artifacts that haven't been used for this long, e.g. "72h"
+        /// or "14d"
+        #[clap(long)]
+        max_age: Option<String>,
+        /// Remove the least recently used artifacts while the cache takes up
+        /// more than this much space, e.g. "10GB". Defaults to
+        /// TURBO_MAX_CACHE_SIZE or the max_cache_size user default
+        #[clap(long)]
+        max_size: Option<String>,
+    },
+    /// List the files in an artifact of the local filesystem cache, with its
+    /// size and the duration of the task that produced it
+    Inspect {
+        /// The hash of the task, as shown by `turbo run`
+        hash: String,
+        /// The filesystem cache directory. Defaults to TURBO_CACHE_DIR, the
+        /// cache_dir user default, or ./node_modules/.cache/turbo
+        #[clap(long)]
+        cache_dir: Option<String>,
+        /// Output the artifact's contents as JSON
+        #[clap(long)]
+        json: bool,
+    },
+    /// Restore an artifact of the local filesystem cache into any directory
+    Restore {
+        /// The hash of the task, as shown by `turbo run`
+        hash: String,
+        /// The directory to restore the files into. Relative paths are
+        /// resolved from the repository root
+        #[clap(long)]
+        to: String,
+        /// The filesystem cache directory. Defaults to TURBO_CACHE_DIR, the
+        /// cache_dir user default, or ./node_modules/.cache/turbo
+        #[clap(long)]
+        cache_dir: Option<String>,
+    },
+    /// Show who or what produced an artifact: the user, CI job, git commit
+    /// and turbo version of the run that wrote it, and the hash of its inputs
+    ///
+    /// The metadata of the local filesystem cache is read first, and then the
+    /// metadata that the Remote Cache returns for the artifact.
+    Provenance {
+        /// The hash of the task, as shown by `turbo run`
+        hash: String,
+        /// The filesystem cache directory. Defaults to TURBO_CACHE_DIR, the
+        /// cache_dir user default, or ./node_modules/.cache/turbo
+        #[clap(long)]
+        cache_dir: Option<String>,
+        /// Output the provenance as JSON
+        #[clap(long)]
+        json: bool,
+    },
+}
+
+#[derive(Subcommand, Clone, Debug, Serialize, PartialEq)]
+#[serde(tag = "command")]
+pub enum QueryCommand {
+    /// Print the packages that a --filter expression selects, as JSON
+    Filter {
+        /// The filter expression, such as './apps/**' or '...@acme/ui#test'
+        filter: String,
+    },
+}
+
+#[derive(Subcommand, Clone, Debug, Serialize, PartialEq)]
+#[serde(tag = "command")]
+pub enum InsightsCommand {
+    /// Report the tasks that have both failed and passed for the same hash in
+    /// the run summaries saved by `turbo run --summarize`
+    Flaky {
+        /// How many of the most recent run summaries to analyze
+        #[clap(long, default_value_t = 100)]
+        runs: usize,
+        /// Output the flaky tasks as JSON
+        #[clap(long)]
+        json: bool,
+    },
+}
+
+#[derive(Subcommand, Clone, Debug, Serialize, PartialEq)]
+#[serde(tag = "command")]
+pub enum RunsCommand {
+    /// Remove saved run summaries
+    ///
+    /// Without flags, the limits under "summaries" in turbo.json are applied,
+    /// or every run summary is removed if there are none.
+    Clean {
+        /// Keep only this many of the most recent run summaries
+        #[clap(long)]
+        max_count: Option<usize>,
+        /// Remove run summaries older than this, e.g. "72h" or "14d"
+        #[clap(long)]
+        max_age: Option<String>,
+    },
+    /// List the saved run summaries, newest first, with the duration, cache
+    /// hit rate and exit code of each run
+    List {
+        /// Output the runs as JSON
+        #[clap(long)]
+        json: bool,
+        /// How many of the most recent runs to list
+        #[clap(long, default_value_t = 20)]
+        limit: usize,
+    },
+}
+
+#[derive(Subcommand, Clone, Debug, Serialize, PartialEq)]
+#[serde(tag = "command")]
+pub enum GenCommand {
+    /// Create a new workspace that is wired into the pipeline
+    Workspace {
+        /// Name of the new workspace
+        #[clap(long)]
+        name: String,
+        /// Type of workspace to create
+        #[clap(long = "type", default_value = "package", value_parser = ["app", "package"])]
+        workspace_type: String,
+        /// Name of an existing workspace to copy as a starting point
+        #[clap(long)]
+        copy: Option<String>,
+        /// Directory to create the workspace in, relative to the repository
+        /// root
+        #[clap(long)]
+        destination: Option<String>,
+    },
+    /// Run a custom generator defined in turbo/generators
+    Run {
+        /// Name of the generator to run
+        generator: String,
+        /// Variables to pass to the generator templates, as key=value pairs
+        #[clap(long = "args", action = ArgAction::Append)]
+        args: Vec<String>,
+    },
+}
+
 impl Args {
     pub fn new() -> Result<Self> {
         let mut clap_args = match Args::try_parse() {
@@ -217,20 +506,180 @@ impl Args {
 pub enum Command {
     // NOTE: Empty variants still have an empty struct attached so that serde serializes
     // them as `{ "Bin": {} }` instead of as `"Bin"`.
+    /// Find package scripts and turbo.json configuration that prevent
+    /// effective caching
+    Audit {
+        /// Output the findings as JSON
+        #[clap(long)]
+        json: bool,
+    },
+    /// Run tasks repeatedly and report statistics about how long they take
+    ///
+    /// Accepts the same arguments as `turbo run`. Use it to compare the
+    /// performance of changes to the pipeline or to the machine running it.
+    Bench {
+        /// The number of times to run the tasks
+        #[clap(long, default_value_t = 5)]
+        iterations: u32,
+        /// Delete the whole local cache directory, including artifacts of
+        /// unrelated tasks, and skip the remote cache before every iteration
+        #[clap(long)]
+        clear_cache: bool,
+        #[clap(flatten)]
+        #[serde(flatten)]
+        run_args: Box<RunArgs>,
+    },
     /// Get the path to the Turbo binary
     Bin {},
+    /// Check the dependencies between workspaces against the boundary rules
+    /// in turbo.json
+    Boundaries {},
+    /// Manage the local cache and self-hosted remote caches
+    Cache {
+        #[clap(subcommand)]
+        #[serde(flatten)]
+        command: CacheCommand,
+    },
+    /// Compare two run summaries saved by `turbo run --summarize`: which task
+    /// hashes changed and why, and how the durations of the tasks moved
+    Compare {
+        /// The earlier run, as an ID from `turbo runs list` or the path to a
+        /// run summary
+        before: String,
+        /// The later run, as an ID from `turbo runs list` or the path to a run
+        /// summary
+        after: String,
+        /// Output the comparison as JSON
+        #[clap(long)]
+        json: bool,
+    },
+    /// Print the candidates for dynamic shell completions, one per line.
+    /// Used by the scripts generated by `turbo completion`
+    #[clap(name = "__complete", hide = true)]
+    Complete {
+        #[clap(value_enum)]
+        kind: CompletionKind,
+    },
     /// Generate the autocompletion script for the specified shell
     #[serde(skip)]
     Completion { shell: Shell },
+    /// Manage the defaults in your user config file, which apply to every
+    /// repository beneath its configuration, environment variables and flags
+    ///
+    /// The settings are cache_dir, color, concurrency, max_cache_size,
+    /// output_logs and team.
+    Config {
+        #[clap(subcommand)]
+        #[serde(flatten)]
+        command: ConfigCommand,
+    },
     /// Runs the Turborepo background daemon
     Daemon {
         /// Set the idle timeout for turbod (default 4h0m0s)
         #[clap(long)]
         idle_time: Option<String>,
+        /// Serve Prometheus metrics at /metrics on this address, such as
+        /// 127.0.0.1:9464. Defaults to TURBO_DAEMON_METRICS_ADDR.
+        #[clap(long)]
+        metrics_addr: Option<String>,
+        /// How to watch the repository for changes. Defaults to
+        /// TURBO_DAEMON_WATCHER, or else auto.
+        #[clap(long, value_enum)]
+        watcher: Option<WatcherBackend>,
+        /// How often to walk the repository when polling, such as 500ms or 2s
+        /// (default 1s). Defaults to TURBO_DAEMON_POLL_INTERVAL.
+        #[clap(long)]
+        poll_interval: Option<String>,
         #[clap(subcommand)]
         #[serde(flatten)]
         command: Option<DaemonCommand>,
     },
+    /// Check your environment and repository configuration for problems
+    Doctor {
+        /// Check this filesystem cache directory instead of the one from
+        /// TURBO_CACHE_DIR or the default one
+        #[clap(long)]
+        cache_dir: Option<String>,
+        /// Output the diagnostics as JSON
+        #[clap(long)]
+        json: bool,
+    },
+    /// Tell whether a task would be restored from the cache without running
+    /// it, and which inputs, environment variables and dependencies changed
+    /// since its last run saved by `turbo run --summarize`
+    Explain {
+        /// The task to explain, as <package>#<task>
+        task: String,
+        /// How many of the most recent run summaries to search for the last
+        /// run of the task
+        #[clap(long, default_value_t = 100)]
+        runs: usize,
+        /// Ask the daemon, which hashes the task with the environment
+        /// variables it was started with
+        #[clap(long)]
+        daemon: bool,
+        /// Output the explanation as JSON
+        #[clap(long)]
+        json: bool,
+    },
+    /// Same as `turbo insights flaky`, which replaces it
+    #[clap(hide = true)]
+    Flaky {
+        /// How many of the most recent run summaries to analyze
+        #[clap(long, default_value_t = 100)]
+        runs: usize,
+        /// Output the flaky tasks as JSON
+        #[clap(long)]
+        json: bool,
+    },
+    /// Generate a new workspace or run a custom generator
+    Gen {
+        #[clap(subcommand)]
+        #[serde(flatten)]
+        command: GenCommand,
+    },
+    /// Print the global hash, which is part of the hash of every task
+    Globalhash {
+        /// List every input of the global hash with a digest of its own: the
+        /// globalDependencies files, the environment variables, the root
+        /// external dependencies from the lockfile and the root pipeline
+        #[clap(long)]
+        explain: bool,
+        /// Output the global hash and its inputs as JSON
+        #[clap(long)]
+        json: bool,
+    },
+    /// List the files that are hashed for a task in each workspace, to debug
+    /// the `inputs` of the task
+    #[serde(rename = "hash_inputs")]
+    HashInputs {
+        /// The task to list the hashed files of
+        task: String,
+        /// Use the given selector to specify which workspaces to list the
+        /// files of. Uses the same syntax as `turbo run --filter`
+        #[clap(long, action = ArgAction::Append)]
+        filter: Vec<String>,
+        /// Output the hashed files as JSON
+        #[clap(long)]
+        json: bool,
+    },
+    /// Describe workspaces: their dependencies, their tasks, and the
+    /// configuration files that define each task
+    Info {
+        /// The workspaces to describe. Uses the same syntax as `turbo run
+        /// --filter`
+        #[clap(long, action = ArgAction::Append)]
+        filter: Vec<String>,
+        /// Output the workspaces as JSON
+        #[clap(long)]
+        json: bool,
+    },
+    /// Analyze the run summaries saved by `turbo run --summarize`
+    Insights {
+        #[clap(subcommand)]
+        #[serde(flatten)]
+        command: InsightsCommand,
+    },
     /// Link your local directory to a Vercel organization and enable remote
     /// caching.
     Link {
@@ -238,23 +687,85 @@ pub enum Command {
         #[clap(long)]
         no_gitignore: bool,
     },
-    /// Login to your Vercel account
+    /// List the workspaces in your monorepo
+    Ls {
+        /// Use the given selector to specify which workspaces to list.
+        /// Uses the same syntax as `turbo run --filter`
+        #[clap(long, action = ArgAction::Append)]
+        filter: Vec<String>,
+        /// Output the workspaces as JSON
+        #[clap(long)]
+        json: bool,
+    },
+    /// Login to your Vercel account, or to the OpenID Connect provider
+    /// configured in the "auth" of "remoteCache" in turbo.json
     Login {
         #[clap(long = "sso-team")]
         sso_team: Option<String>,
     },
     /// Logout to your Vercel account
     Logout {},
+    /// Download the remote cache artifacts of tasks into the local cache
+    /// without running them, to warm the cache of a fresh clone or CI machine
+    Prefetch {
+        /// The tasks to download the artifacts of. Defaults to every task in
+        /// the pipeline
+        tasks: Vec<String>,
+        /// Use the given selector to specify which workspaces to download the
+        /// artifacts of. Uses the same syntax as `turbo run --filter`
+        #[clap(long, action = ArgAction::Append)]
+        filter: Vec<String>,
+        /// The filesystem cache directory to download the artifacts into.
+        /// Defaults to TURBO_CACHE_DIR, the cache_dir user default, or
+        /// ./node_modules/.cache/turbo
+        #[clap(long)]
+        cache_dir: Option<String>,
+    },
     /// Prepare a subset of your monorepo.
     Prune {
         #[clap(long)]
         scope: Vec<String>,
         #[clap(long)]
         docker: bool,
+        /// Write the layout of --docker as a BuildKit context, with a layer of
+        /// only the lockfile, a Dockerfile snippet that copies each layer on
+        /// its own, and a manifest of the content of the layers
+        #[clap(long)]
+        docker_buildkit_context: bool,
         #[clap(long = "out-dir", default_value_t = String::from("out"), value_parser)]
         output_dir: String,
     },
 
+    /// Query the package and task graph and output the results as JSON
+    ///
+    /// Queries are a source followed by filters, separated by `|`. For example:
+    /// packages | dependsOn(@acme/ui) | hasTask(test) | names
+    #[clap(args_conflicts_with_subcommands = true, subcommand_negates_reqs = true)]
+    Query {
+        #[clap(subcommand)]
+        #[serde(flatten)]
+        command: Option<QueryCommand>,
+        /// The query to run
+        #[clap(required = true)]
+        query: Option<String>,
+    },
+
+    /// Check that the environment matches a run recorded with `turbo run
+    /// --record`, then run it again with the recorded flags
+    Replay {
+        /// The record written by `turbo run --record`. Relative paths are
+        /// resolved from the repository root
+        bundle: String,
+        /// Replay the run even if the environment or the inputs of its tasks
+        /// differ from the record
+        #[clap(long)]
+        ignore_mismatches: bool,
+        /// Only check that the environment matches the record, without running
+        /// any tasks
+        #[clap(long)]
+        verify_only: bool,
+    },
+
     /// Run tasks across projects in your monorepo
     ///
     /// By default, turbo executes tasks in topological order (i.e.
@@ -265,32 +776,193 @@ pub enum Command {
     ///
     /// Arguments passed after '--' will be passed through to the named tasks.
     Run(Box<RunArgs>),
+    /// List and manage the run summaries saved in .turbo/runs by `turbo run
+    /// --summarize`
+    Runs {
+        #[clap(subcommand)]
+        #[serde(flatten)]
+        command: RunsCommand,
+    },
+    /// Serve a local HTTP API for submitting runs, streaming their output and
+    /// fetching their summaries
+    ///
+    /// Requests must send the token printed at startup, or the value of
+    /// TURBO_SERVE_TOKEN, as a bearer token.
+    Serve {
+        /// The address to listen for requests on
+        #[clap(long, default_value = "127.0.0.1:9877")]
+        listen: String,
+        #[clap(skip)]
+        single_package: bool,
+    },
+    /// Manage the anonymous usage events turbo sends
+    ///
+    /// Set TURBO_TELEMETRY_DEBUG=1 to print every event to stderr as it is
+    /// sent.
+    Telemetry {
+        #[clap(subcommand)]
+        #[serde(flatten)]
+        command: TelemetryCommand,
+    },
+    /// Manage the token that turbo sends to the remote cache
+    Token {
+        #[clap(subcommand)]
+        #[serde(flatten)]
+        command: TokenCommand,
+    },
     /// Unlink the current directory from your Vercel organization and disable
     /// Remote Caching
     Unlink {},
+    /// Experimental: run a worker that executes tasks dispatched by
+    /// `turbo run --experimental-remote-workers`
+    ///
+    /// Workers resolve tasks from the checkout they are started in and refuse
+    /// to run a task whose hash differs from the one sent by `turbo run`.
+    /// Outputs are written to the remote cache, which must be shared with the
+    /// machine running `turbo run`. Requests must carry the token in
+    /// TURBO_WORKER_TOKEN, and workers only listen on non-loopback addresses
+    /// with TLS.
+    Worker {
+        /// The address to listen for tasks on
+        #[clap(long, default_value = "127.0.0.1:9876")]
+        listen: String,
+        /// The TLS certificate to serve
+        #[clap(long, requires = "tls_key")]
+        tls_cert: Option<String>,
+        /// The private key of the TLS certificate
+        #[clap(long, requires = "tls_cert")]
+        tls_key: Option<String>,
+    },
+}
+
+#[derive(Copy, Clone, Debug, PartialEq, Serialize, ValueEnum)]
+pub enum CompletionKind {
+    /// The names of the tasks in the pipeline
+    #[serde(rename = "tasks")]
+    Tasks,
+    /// The names of the workspaces in the monorepo
+    #[serde(rename = "packages")]
+    Packages,
 }
 
 #[derive(Parser, Clone, Debug, Default, Serialize, PartialEq)]
 pub struct RunArgs {
-    /// Override the filesystem cache directory.
+    /// Only run the packages with changes since --affected-base, including
+    /// uncommitted changes, and the packages that depend on them. Changes to
+    /// the lockfile select the packages whose dependencies changed, and
+    /// changes to global dependencies select every package. With --filter,
+    /// only the filtered packages among those run
+    #[clap(long, conflicts_with_all = ["scope", "since"])]
+    pub affected: bool,
+    /// The git ref that --affected compares against. Defaults to the value
+    /// of TURBO_SCM_BASE, then to "main"
+    #[clap(long, requires = "affected")]
+    pub affected_base: Option<String>,
+    /// Run more tasks in other packages in the same run, as "<tasks>
+    /// --filter=<filter>", e.g. --also="lint --filter=docs". Can be repeated.
+    /// The tasks of every group share one task graph, so a task that several
+    /// groups depend on only runs once
+    #[clap(long, action = ArgAction::Append, value_name = "TASKS --filter=FILTER")]
+    pub also: Vec<String>,
+    /// Which caches the run reads and writes. The remote cache is still
+    /// turned off partway through a run after repeated failed requests
+    #[clap(long, value_enum, conflicts_with = "remote_only")]
+    pub cache: Option<CacheMode>,
+    /// Override the filesystem cache directory. Relative paths are resolved
+    /// from the repository root. Defaults to the value of TURBO_CACHE_DIR, then
+    /// to node_modules/.cache/turbo.
     #[clap(long)]
     pub cache_dir: Option<String>,
+    /// Append a suffix to the hash of every task, so that the artifacts of
+    /// the run are kept apart from others in the cache, such as the version
+    /// of a release
+    #[clap(long)]
+    pub cache_key_suffix: Option<String>,
+    /// Upload artifacts to a namespace of the remote cache, such as the name
+    /// of a feature branch. Artifacts are read from the namespace first, then
+    /// from the shared cache. Defaults to the value of TURBO_CACHE_SCOPE.
+    #[clap(long)]
+    pub cache_scope: Option<String>,
     /// Set the number of concurrent cache operations (default 10)
     #[clap(long, default_value_t = 10)]
     pub cache_workers: u32,
+    /// Execute tasks even when they hit the cache, and report the outputs
+    /// that differ from the cached ones. The cache is left unchanged
+    #[clap(long)]
+    pub check_determinism: bool,
+    /// Before running a task, warn about the files that match its "outputs"
+    /// but were neither written by its last run nor restored from the cache,
+    /// such as build outputs left behind by another branch
+    #[clap(long)]
+    pub check_outputs: bool,
+    /// Like --check-outputs, but remove those files before the task runs or
+    /// is restored from the cache
+    #[clap(long)]
+    pub clean_outputs: bool,
     /// Limit the concurrency of task execution. Use 1 for serial (i.e.
     /// one-at-a-time) execution.
     #[clap(long)]
     pub concurrency: Option<String>,
     /// Continue execution even if a task exits with an error or non-zero
-    /// exit code. The default behavior is to bail
-    #[clap(long = "continue")]
+    /// exit code. Tasks that depend on a failed task are skipped. The
+    /// default behavior is to bail
+    #[clap(long = "continue", alias = "continue-on-error")]
     pub continue_execution: bool,
     #[clap(alias = "dry", long = "dry-run", num_args = 0..=1, default_missing_value = "text")]
     pub dry_run: Option<DryRunMode>,
+    /// Which environment variables tasks inherit. Use "strict" to run tasks
+    /// with only the variables in their "env" and "passThroughEnv", the
+    /// "globalEnv" variables, and the system variables tools need, such as
+    /// PATH and HOME. (default loose)
+    #[clap(long, value_enum)]
+    pub env_mode: Option<EnvMode>,
+    /// Stream the events of the run as newline-delimited JSON while it runs:
+    /// "fd:3" writes them to file descriptor 3, and "unix:/tmp/turbo.sock"
+    /// connects to a Unix socket
+    #[clap(long, value_name = "fd:N|unix:PATH")]
+    pub events: Option<String>,
+    /// How the exit code is chosen when the run fails. Defaults to the
+    /// highest exit code of any failed task
+    #[clap(long, value_enum)]
+    pub exit_code: Option<ExitCodeMode>,
+    /// With --exit-code=fixed, the exit code when a task fails (default 1)
+    #[clap(long, requires = "exit_code")]
+    pub task_failure_exit_code: Option<i32>,
+    /// With --exit-code=fixed, the exit code when turbo fails for a reason
+    /// other than a task's command failing (default 1)
+    #[clap(long, requires = "exit_code")]
+    pub error_exit_code: Option<i32>,
+    /// Experimental: dispatch cacheable tasks to the `turbo worker` agents at
+    /// the given addresses. Outputs are restored through the remote cache.
+    #[clap(long, value_delimiter = ',')]
+    pub experimental_remote_workers: Vec<String>,
+    /// Experimental: the CA certificate used to verify the TLS certificates of
+    /// the workers. Without it, workers are reached over plaintext, which is
+    /// only allowed on loopback addresses.
+    #[clap(long, requires = "experimental_remote_workers")]
+    pub experimental_remote_workers_ca: Option<String>,
+    /// Experimental: run each task in a temporary copy of the repository that
+    /// only holds the files its hash covers, so that reading an undeclared
+    /// input fails instead of going unnoticed by the cache
+    #[clap(long)]
+    pub experimental_sandbox: bool,
+    /// Experimental: when concurrency slots are idle, start the tasks that
+    /// will likely miss the cache before their dependencies are restored from
+    /// it. Needs the daemon
+    #[clap(long)]
+    pub experimental_speculate: bool,
+    /// Experimental: record the files each executed task reads and writes,
+    /// and compare them with its "inputs" and "outputs" configuration
+    #[clap(long)]
+    pub experimental_trace_files: bool,
     /// Run turbo in single-package mode
     #[clap(long, global = true)]
     pub single_package: bool,
+    /// Write a zip file to .turbo/failures for every task that fails, with
+    /// its log, environment variables, input hashes, the version of turbo
+    /// and the start of its outputs, to attach to bug reports
+    #[clap(long)]
+    pub failure_bundles: bool,
     /// Use the given selector to specify package(s) to act as
     /// entry points. The syntax mirrors pnpm's syntax, and
     /// additional documentation and examples can be found in
@@ -300,15 +972,37 @@ pub struct RunArgs {
     /// Ignore the existing cache (to force execution)
     #[clap(long)]
     pub force: bool,
+    /// Only ignore the cache for the given tasks or packages, e.g.
+    /// --force-filter=web#build. Entries may be a task id, a task name or a
+    /// package name. Other tasks still use cached results
+    #[clap(long, action = ArgAction::Append, value_delimiter = ',')]
+    pub force_filter: Vec<String>,
+    /// Report the errors of failed tasks as GitHub Actions annotations, at
+    /// the file and line of compiler and test errors where they can be
+    /// parsed, and add a table of the tasks to the job summary
+    #[clap(long)]
+    pub github_actions: bool,
     /// Specify glob of global filesystem dependencies to be hashed. Useful
     /// for .env and files
     #[clap(long = "global-deps", action = ArgAction::Append)]
     pub global_deps: Vec<String>,
     /// Generate a graph of the task execution and output to a file when a
-    /// filename is specified (.svg, .png, .jpg, .pdf, .json,
-    /// .html). Outputs dot graph to stdout when if no filename is provided
+    /// filename is specified (.svg, .png, .jpg, .pdf, .json, .html,
+    /// .mermaid). Outputs dot graph to stdout when if no filename is provided.
+    /// Pass `mermaid` to output a Mermaid graph to stdout, or `html` to write
+    /// an interactive graph.html. Tasks are annotated with their expected
+    /// cache status and last duration
     #[clap(long, num_args = 0..=1, default_missing_value = "")]
     pub graph: Option<String>,
+    /// How long tasks have to exit after SIGINT when the run is canceled,
+    /// before they are killed, e.g. "30s". Defaults to 10s
+    #[clap(long)]
+    pub grace_period: Option<String>,
+    /// Restore every task from the artifact of the hash pinned for it in a
+    /// file written by --write-hash-lock, whatever hash it computes. Tasks
+    /// whose artifact is missing from the cache fail instead of executing
+    #[clap(long, conflicts_with = "force")]
+    pub hash_lock: Option<String>,
     /// Files to ignore when calculating changed files (i.e. --since).
     /// Supports globs.
     #[clap(long)]
@@ -316,10 +1010,19 @@ pub struct RunArgs {
     /// Include the dependencies of tasks in execution.
     #[clap(long)]
     pub include_dependencies: bool,
+    /// Remove the least recently used artifacts from the filesystem cache
+    /// at the end of the run while it is larger than this, e.g. "10GB".
+    /// Defaults to TURBO_MAX_CACHE_SIZE
+    #[clap(long)]
+    pub max_cache_size: Option<String>,
     /// Avoid saving task results to the cache. Useful for development/watch
     /// tasks.
     #[clap(long)]
     pub no_cache: bool,
+    /// Keep task log files out of cache artifacts. Cache hits will not replay
+    /// the output of their tasks
+    #[clap(long)]
+    pub no_cache_logs: bool,
     /// Run without using turbo's daemon process
     #[clap(long)]
     pub no_daemon: bool,
@@ -329,10 +1032,20 @@ pub struct RunArgs {
     /// Set type of process output logging. Use "full" to show
     /// all output. Use "hash-only" to show only turbo-computed
     /// task hashes. Use "new-only" to show only new output with
-    /// only hashes for cached tasks. Use "none" to hide process
+    /// only hashes for cached tasks. Use "errors-only" to show only
+    /// output from failed tasks. Use "none" to hide process
     /// output. (default full)
-    #[clap(long, value_enum)]
-    pub output_logs: Option<OutputLogsMode>,
+    ///
+    /// Prefix a mode with a task to override it for that task only, e.g.
+    /// "new-only,web#build=errors-only,test=full".
+    #[clap(long, value_delimiter = ',', value_parser = OutputLogs::parse, value_name = "[TASK=]MODE")]
+    pub output_logs: Vec<OutputLogs>,
+    /// Export the run and each of its tasks as OpenTelemetry spans to the
+    /// OTLP/HTTP collector at this base URL, e.g. "http://localhost:4318".
+    /// Defaults to OTEL_EXPORTER_OTLP_ENDPOINT, and headers are read from
+    /// OTEL_EXPORTER_OTLP_HEADERS
+    #[clap(long)]
+    pub otlp_endpoint: Option<String>,
     #[clap(long, hide = true)]
     pub only: bool,
     /// Execute all tasks in parallel.
@@ -340,29 +1053,143 @@ pub struct RunArgs {
     pub parallel: bool,
     #[clap(long, hide = true, default_missing_value = "")]
     pub pkg_inference_root: Option<String>,
+    /// Write a Markdown report of the run, formatted for a pull request
+    /// comment, to the given file. Relative paths are resolved from the
+    /// repository root
+    #[clap(long)]
+    pub pr_report: Option<String>,
+    /// A run summary saved by `--summarize`, e.g. from the base branch, to
+    /// compare the cache hits in the pull request report against
+    #[clap(long, requires = "pr_report")]
+    pub pr_report_base: Option<String>,
     /// File to write turbo's performance profile output into.
     /// You can load the file up in chrome://tracing to see
     /// which parts of your build were slow.
     #[clap(long)]
     pub profile: Option<String>,
+    /// Apply the flags of the named profile in the "profiles" of turbo.json,
+    /// e.g. `--profile-name=ci`. Flags passed on the command line take
+    /// precedence over the profile's
+    #[clap(long)]
+    pub profile_name: Option<String>,
+    /// Keep a line below the output of tasks with how many tasks have
+    /// finished, and an estimate of the time left from how long tasks took in
+    /// the run summaries saved by `--summarize`. Only shown on a terminal
+    #[clap(long)]
+    pub progress: bool,
+    /// Write a record of the run to the given file, with the resolved flags,
+    /// hashed environment variables, input file hashes and tool versions, so
+    /// that `turbo replay` can check another environment and repeat the run.
+    /// Relative paths are resolved from the repository root
+    #[clap(long)]
+    pub record: Option<String>,
     /// Ignore the local filesystem cache for all tasks. Only
     /// allow reading and caching artifacts using the remote cache.
     #[clap(long)]
     pub remote_only: bool,
+    /// Write a report of the run, as <format>:<path>, e.g.
+    /// `--report=junit:reports/turbo.xml`. With junit, each task is a test
+    /// case with its duration and the end of its log if it failed. With html,
+    /// the report is a standalone page with a timeline of the tasks and their
+    /// logs. Can be repeated to write several reports. Relative paths are
+    /// resolved from the repository root
+    #[clap(long, action = ArgAction::Append, value_name = "FORMAT:PATH")]
+    pub report: Vec<String>,
+    /// List the files that restoring each task from the cache would create or
+    /// overwrite in the workspace, without running or restoring anything.
+    /// Combine with --dry=json for JSON output
+    #[clap(long, conflicts_with = "show_global_hash_inputs")]
+    pub restore_dry_run: bool,
     /// Specify package(s) to act as entry points for task execution.
     /// Supports globs.
     #[clap(long)]
     pub scope: Vec<String>,
+    /// Run tasks one at a time, in dependency order with ties broken by task
+    /// name, streaming the output of every task. Use it to reproduce failures
+    /// that depend on the order tasks run in
+    #[clap(long, conflicts_with_all = ["parallel", "concurrency"])]
+    pub serial_deterministic: bool,
+    /// Run one part of the task graph, as <index>/<total>, e.g. `--shard=2/5`,
+    /// to split a run across several CI machines. Independent tasks are
+    /// balanced between the shards with the durations of the run summaries in
+    /// .turbo/runs, and tasks shared between shards are restored from the
+    /// remote cache
+    #[clap(long, value_name = "INDEX/TOTAL", conflicts_with = "watch")]
+    pub shard: Option<String>,
+    /// With --dry, print everything that is hashed into the global hash
+    /// (global files, env vars, root lockfile entries) instead of the tasks
+    #[clap(long, requires = "dry_run")]
+    pub show_global_hash_inputs: bool,
     /// Limit/Set scope to changed packages since a mergebase.
     /// This uses the git diff ${target_branch}... mechanism
     /// to identify which packages have changed.
     #[clap(long)]
     pub since: Option<String>,
-    /// Use "none" to remove prefixes from task logs. Note that tasks running
-    /// in parallel interleave their logs and prefix is the only way
-    /// to identify which task produced a log.
+    /// Fail before running any task when the outputs of a task match files
+    /// tracked by git, or its inputs match files that git ignores. These are
+    /// reported as warnings otherwise
+    #[clap(long)]
+    pub strict: bool,
+    /// Save a summary of the run in .turbo/runs. Pass `resources` to also
+    /// print the CPU time and peak memory of each task
+    #[clap(long, num_args = 0..=1, default_missing_value = "")]
+    pub summarize: Option<String>,
+    /// Write the JSON summary to the given file instead of stdout, keeping the
+    /// text summary in the terminal. Relative paths are resolved from the
+    /// repository root
+    #[clap(long, requires = "summary_format")]
+    pub summary_file: Option<String>,
+    /// How the outcome of the run is written when it finishes. Use "json" for
+    /// a machine-readable summary of every task's status, duration and exit
+    /// code, printed to stdout in place of the text summary
+    #[clap(long, value_enum)]
+    pub summary_format: Option<SummaryFormat>,
+    /// Pass an argument to only some tasks, as <task>=<arg> or
+    /// <package>#<task>=<arg>. Can be repeated to pass several arguments.
+    /// Arguments after -- are still passed to every task named on the command
+    /// line.
+    #[clap(long, action = ArgAction::Append, value_name = "TASK=ARG")]
+    pub task_args: Vec<String>,
+    /// With --affected, only run the test tasks that cover the files that
+    /// changed, from a JSON map of files to the IDs of the tasks that cover
+    /// them, such as one built from coverage reports. Why each task of the map
+    /// runs or not is listed by --dry. Relative paths are resolved from the
+    /// repository root
+    #[clap(long, requires = "affected", value_name = "PATH")]
+    pub test_map: Option<String>,
+    /// Keep running after the tasks finish, and run them again whenever files
+    /// in the workspace change. Only the packages with changes, and the
+    /// packages that depend on them, are run again
+    #[clap(long, conflicts_with_all = ["dry_run", "graph", "record"])]
+    pub watch: bool,
+    /// Write the hashes of the tasks that were built or restored from the
+    /// cache to a file, to restore exactly the same outputs later with
+    /// --hash-lock
+    #[clap(long, conflicts_with_all = ["dry_run", "watch"])]
+    pub write_hash_lock: Option<String>,
+    /// A template for the prefix of task logs, with the placeholders
+    /// {package}, {task}, {hash}, {elapsed} and {label}, or "none" to remove
+    /// prefixes. Note that tasks running in parallel interleave their logs
+    /// and prefix is the only way to identify which task produced a log.
+    /// (default "{package}:{task}: ")
+    #[clap(long)]
+    pub log_prefix: Option<String>,
+    /// How the prefixes of task logs are colored. Use "status" to color them
+    /// by the status of the task instead of by package. (default package)
+    #[clap(long, value_enum)]
+    pub log_prefix_color: Option<LogPrefixColor>,
+    /// How the logs of tasks that run at the same time are ordered. Use
+    /// "grouped" to print the logs of each task in one block once it
+    /// finishes, wrapped in a collapsible section on GitHub Actions.
+    /// Persistent tasks are always streamed. (default stream)
+    #[clap(long, value_enum)]
+    pub log_order: Option<LogOrder>,
+    /// How tasks are shown while they run. Use "tui" for a full-screen table
+    /// of the tasks with their status, cache state and elapsed time, where a
+    /// task can be selected to scroll its output. Falls back to "stream" when
+    /// the output isn't a terminal. (default stream)
     #[clap(long, value_enum)]
-    pub log_prefix: Option<LogPrefix>,
+    pub ui: Option<UiMode>,
     // NOTE: The following two are hidden because clap displays them in the help text incorrectly:
     // > Usage: turbo [OPTIONS] [TASKS]... [-- <FORWARDED_ARGS>...] [COMMAND]
     #[clap(hide = true)]
@@ -371,10 +1198,58 @@ pub struct RunArgs {
     pub pass_through_args: Vec<String>,
 }
 
-#[derive(clap::ValueEnum, Clone, Copy, Debug, PartialEq, Serialize)]
-pub enum LogPrefix {
-    #[serde(rename = "none")]
-    None,
+// NOTE: These *must* be kept in sync with the `_logOrder*Value` constants in
+// run.go.
+#[derive(Copy, Clone, Debug, PartialEq, Serialize, ValueEnum)]
+pub enum LogOrder {
+    /// Print lines as tasks write them, interleaving the logs of tasks
+    Stream,
+    /// Print the logs of each task in one block once it finishes
+    Grouped,
+}
+
+// NOTE: These *must* be kept in sync with the `_envMode*Value` constants in
+// run.go.
+#[derive(Copy, Clone, Debug, PartialEq, Serialize, ValueEnum)]
+pub enum EnvMode {
+    /// Tasks inherit turbo's whole environment
+    Loose,
+    /// Tasks inherit only the variables they declare, and system variables
+    Strict,
+}
+
+// NOTE: These *must* be kept in sync with the `_cacheMode*Value` constants in
+// run.go.
+#[derive(Copy, Clone, Debug, PartialEq, Serialize, ValueEnum)]
+pub enum CacheMode {
+    /// Only use the local filesystem cache
+    LocalOnly,
+    /// Only use the remote cache, like --remote-only
+    RemoteOnly,
+    /// Neither read nor write any cache
+    Off,
+}
+
+// NOTE: These *must* be kept in sync with the `_ui*Value` constants in
+// run.go.
+#[derive(Copy, Clone, Debug, PartialEq, Serialize, ValueEnum)]
+pub enum UiMode {
+    /// Print the logs of tasks as they run
+    Stream,
+    /// Show a full-screen terminal UI with a table of the tasks
+    Tui,
+}
+
+// NOTE: These *must* be kept in sync with the `LogPrefixColor*` constants in
+// turbo_json.go.
+#[derive(Copy, Clone, Debug, PartialEq, Serialize, ValueEnum)]
+#[serde(rename_all = "lowercase")]
+pub enum LogPrefixColor {
+    /// Give the prefixes of each package their own color
+    Package,
+    /// Grey for lines replayed from the cache, cyan while the task runs and
+    /// red once it failed
+    Status,
 }
 
 /// Runs the CLI by parsing arguments with clap, then either calling Rust code
@@ -409,7 +1284,9 @@ pub async fn run(repo_state: Option<RepoState>) -> Result<Payload> {
     // If this is a run command, and we know the actual invocation path, set the
     // inference root, as long as the user hasn't overridden the cwd
     if clap_args.cwd.is_none() {
-        if let Some(Command::Run(run_args)) = &mut clap_args.command {
+        if let Some(Command::Run(run_args) | Command::Bench { run_args, .. }) =
+            &mut clap_args.command
+        {
             if let Ok(invocation_dir) = env::var(INVOCATION_DIR_ENV_VAR) {
                 let invocation_path = Path::new(&invocation_dir);
 
@@ -434,9 +1311,14 @@ pub async fn run(repo_state: Option<RepoState>) -> Result<Payload> {
 
     // Do this after the above, since we're now always setting cwd.
     if let Some(repo_state) = repo_state {
-        if let Some(Command::Run(run_args)) = &mut clap_args.command {
+        if let Some(Command::Run(run_args) | Command::Bench { run_args, .. }) =
+            &mut clap_args.command
+        {
             run_args.single_package = matches!(repo_state.mode, RepoMode::SinglePackage);
         }
+        if let Some(Command::Serve { single_package, .. }) = &mut clap_args.command {
+            *single_package = matches!(repo_state.mode, RepoMode::SinglePackage);
+        }
         clap_args.cwd = Some(repo_state.root);
     }
 
@@ -467,6 +1349,11 @@ pub async fn run(repo_state: Option<RepoState>) -> Result<Payload> {
                 return Ok(Payload::Rust(Ok(0)));
             }
 
+            // Logins to other providers than Vercel are implemented in Go
+            if login::has_custom_auth(&repo_root) {
+                return Ok(Payload::Go(Box::new(clap_args)));
+            }
+
             let sso_team = sso_team.clone();
 
             let mut base = CommandBase::new(clap_args, repo_root)?;
@@ -506,11 +1393,35 @@ pub async fn run(repo_state: Option<RepoState>) -> Result<Payload> {
 
             Ok(Payload::Rust(Ok(0)))
         }
-        Command::Daemon { .. } | Command::Prune { .. } | Command::Run(_) => {
-            Ok(Payload::Go(Box::new(clap_args)))
-        }
+        Command::Audit { .. }
+        | Command::Bench { .. }
+        | Command::Boundaries { .. }
+        | Command::Cache { .. }
+        | Command::Compare { .. }
+        | Command::Complete { .. }
+        | Command::Config { .. }
+        | Command::Daemon { .. }
+        | Command::Doctor { .. }
+        | Command::Explain { .. }
+        | Command::Flaky { .. }
+        | Command::Gen { .. }
+        | Command::Globalhash { .. }
+        | Command::HashInputs { .. }
+        | Command::Info { .. }
+        | Command::Insights { .. }
+        | Command::Ls { .. }
+        | Command::Prefetch { .. }
+        | Command::Prune { .. }
+        | Command::Query { .. }
+        | Command::Replay { .. }
+        | Command::Run(_)
+        | Command::Runs { .. }
+        | Command::Serve { .. }
+        | Command::Telemetry { .. }
+        | Command::Token { .. }
+        | Command::Worker { .. } => Ok(Payload::Go(Box::new(clap_args))),
         Command::Completion { shell } => {
-            generate(*shell, &mut Args::command(), "turbo", &mut io::stdout());
+            completion::completion(*shell)?;
 
             Ok(Payload::Rust(Ok(0)))
         }
@@ -533,7 +1444,7 @@ impl Args {
 mod test {
     use std::path::PathBuf;
 
-    use clap::Parser;
+    use clap::{CommandFactory, Parser};
     use itertools::Itertools;
     use pretty_assertions::assert_eq;
 
@@ -547,7 +1458,7 @@ mod test {
     fn get_default_run_args() -> RunArgs {
         RunArgs {
             cache_workers: 10,
-            output_logs: None,
+            output_logs: vec![],
             ..RunArgs::default()
         }
     }
@@ -586,7 +1497,11 @@ mod test {
 
     use anyhow::Result;
 
-    use crate::cli::{Args, Command, DryRunMode, OutputLogsMode, RunArgs, Verbosity};
+    use crate::cli::{
+        Args, CacheCommand, CacheMode, Command, DryRunMode, EnvMode, ExitCodeMode, InsightsCommand,
+        LogOrder, LogPrefixColor, OutputLogs, OutputLogsMode, QueryCommand, RunArgs, RunsCommand,
+        SummaryFormat, TokenCommand, UiMode, Verbosity,
+    };
 
     #[test]
     fn test_parse_run() -> Result<()> {
@@ -660,6 +1575,18 @@ mod test {
             }
         );
 
+        assert_eq!(
+            Args::try_parse_from(["turbo", "run", "build", "--continue-on-error"]).unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    continue_execution: true,
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+
         assert_eq!(
             Args::try_parse_from(["turbo", "run", "build", "--dry-run"]).unwrap(),
             Args {
@@ -717,6 +1644,30 @@ mod test {
             }
         );
 
+        assert_eq!(
+            Args::try_parse_from([
+                "turbo",
+                "run",
+                "build",
+                "--force-filter=web#build,docs",
+                "--force-filter",
+                "lint"
+            ])
+            .unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    force_filter: vec![
+                        "web#build".to_string(),
+                        "docs".to_string(),
+                        "lint".to_string()
+                    ],
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+
         assert_eq!(
             Args::try_parse_from(["turbo", "run", "build", "--global-deps", ".env"]).unwrap(),
             Args {
@@ -826,11 +1777,11 @@ mod test {
         );
 
         assert_eq!(
-            Args::try_parse_from(["turbo", "run", "build", "--no-daemon"]).unwrap(),
+            Args::try_parse_from(["turbo", "run", "build", "--check-determinism"]).unwrap(),
             Args {
                 command: Some(Command::Run(Box::new(RunArgs {
                     tasks: vec!["build".to_string()],
-                    no_daemon: true,
+                    check_determinism: true,
                     ..get_default_run_args()
                 }))),
                 ..Args::default()
@@ -838,30 +1789,113 @@ mod test {
         );
 
         assert_eq!(
-            Args::try_parse_from(["turbo", "run", "build", "--no-deps"]).unwrap(),
+            Args::try_parse_from(["turbo", "run", "build", "--log-order", "grouped"]).unwrap(),
             Args {
                 command: Some(Command::Run(Box::new(RunArgs {
                     tasks: vec!["build".to_string()],
-                    no_deps: true,
+                    log_order: Some(LogOrder::Grouped),
                     ..get_default_run_args()
                 }))),
                 ..Args::default()
             }
         );
 
-        // Test that ouput-logs is not serialized by default
         assert_eq!(
-            serde_json::to_string(&Args::try_parse_from(["turbo", "run", "build"]).unwrap())?
-                .contains("\"output_logs\":null"),
-            true
-        );
-
+            Args::try_parse_from(["turbo", "run", "build", "--env-mode", "strict"]).unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    env_mode: Some(EnvMode::Strict),
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+
+        assert_eq!(
+            Args::try_parse_from(["turbo", "run", "build", "--ui", "tui"]).unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    ui: Some(UiMode::Tui),
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+
+        assert_eq!(
+            Args::try_parse_from([
+                "turbo",
+                "run",
+                "build",
+                "--affected",
+                "--affected-base=origin/main"
+            ])
+            .unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    affected: true,
+                    affected_base: Some("origin/main".to_string()),
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+        assert!(
+            Args::try_parse_from(["turbo", "run", "build", "--affected", "--since=main"]).is_err()
+        );
+
+        assert_eq!(
+            Args::try_parse_from(["turbo", "run", "build", "--no-cache-logs"]).unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    no_cache_logs: true,
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+
+        assert_eq!(
+            Args::try_parse_from(["turbo", "run", "build", "--no-daemon"]).unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    no_daemon: true,
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+
+        assert_eq!(
+            Args::try_parse_from(["turbo", "run", "build", "--no-deps"]).unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    no_deps: true,
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+
+        // Test that ouput-logs is empty by default
+        assert_eq!(
+            serde_json::to_string(&Args::try_parse_from(["turbo", "run", "build"]).unwrap())?
+                .contains("\"output_logs\":[]"),
+            true
+        );
+
         assert_eq!(
             Args::try_parse_from(["turbo", "run", "build", "--output-logs", "full"]).unwrap(),
             Args {
                 command: Some(Command::Run(Box::new(RunArgs {
                     tasks: vec!["build".to_string()],
-                    output_logs: Some(OutputLogsMode::Full),
+                    output_logs: vec![OutputLogs::All(OutputLogsMode::Full)],
                     ..get_default_run_args()
                 }))),
                 ..Args::default()
@@ -873,7 +1907,7 @@ mod test {
             Args {
                 command: Some(Command::Run(Box::new(RunArgs {
                     tasks: vec!["build".to_string()],
-                    output_logs: Some(OutputLogsMode::None),
+                    output_logs: vec![OutputLogs::All(OutputLogsMode::None)],
                     ..get_default_run_args()
                 }))),
                 ..Args::default()
@@ -885,12 +1919,51 @@ mod test {
             Args {
                 command: Some(Command::Run(Box::new(RunArgs {
                     tasks: vec!["build".to_string()],
-                    output_logs: Some(OutputLogsMode::HashOnly),
+                    output_logs: vec![OutputLogs::All(OutputLogsMode::HashOnly)],
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+
+        assert_eq!(
+            Args::try_parse_from([
+                "turbo",
+                "run",
+                "build",
+                "--output-logs",
+                "new-only,web#build=errors-only",
+                "--output-logs=test=full"
+            ])
+            .unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    output_logs: vec![
+                        OutputLogs::All(OutputLogsMode::NewOnly),
+                        OutputLogs::Task {
+                            task: "web#build".to_string(),
+                            mode: OutputLogsMode::ErrorsOnly
+                        },
+                        OutputLogs::Task {
+                            task: "test".to_string(),
+                            mode: OutputLogsMode::Full
+                        },
+                    ],
                     ..get_default_run_args()
                 }))),
                 ..Args::default()
             }
         );
+        assert!(serde_json::to_string(
+            &Args::try_parse_from(["turbo", "run", "build", "--output-logs=web#build=none"])
+                .unwrap()
+        )?
+        .contains("\"output_logs\":[\"web#build=none\"]"));
+        assert!(
+            Args::try_parse_from(["turbo", "run", "build", "--output-logs", "web#build=loud"])
+                .is_err()
+        );
 
         assert_eq!(
             Args::try_parse_from(["turbo", "run", "build", "--parallel"]).unwrap(),
@@ -1038,6 +2111,21 @@ mod test {
         .test();
     }
 
+    #[test]
+    fn test_parse_token() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "token", "print"]).unwrap(),
+            Args {
+                command: Some(Command::Token {
+                    command: TokenCommand::Print,
+                }),
+                ..Args::default()
+            }
+        );
+
+        assert!(Args::try_parse_from(["turbo", "token"]).is_err());
+    }
+
     #[test]
     fn test_parse_logout() {
         assert_eq!(
@@ -1089,6 +2177,7 @@ mod test {
         let default_prune = Command::Prune {
             scope: Vec::new(),
             docker: false,
+            docker_buildkit_context: false,
             output_dir: "out".to_string(),
         };
 
@@ -1118,6 +2207,7 @@ mod test {
                 command: Some(Command::Prune {
                     scope: vec!["bar".to_string()],
                     docker: false,
+                    docker_buildkit_context: false,
                     output_dir: "out".to_string(),
                 }),
                 ..Args::default()
@@ -1130,6 +2220,20 @@ mod test {
                 command: Some(Command::Prune {
                     scope: Vec::new(),
                     docker: true,
+                    docker_buildkit_context: false,
+                    output_dir: "out".to_string(),
+                }),
+                ..Args::default()
+            }
+        );
+
+        assert_eq!(
+            Args::try_parse_from(["turbo", "prune", "--docker-buildkit-context"]).unwrap(),
+            Args {
+                command: Some(Command::Prune {
+                    scope: Vec::new(),
+                    docker: false,
+                    docker_buildkit_context: true,
                     output_dir: "out".to_string(),
                 }),
                 ..Args::default()
@@ -1142,6 +2246,7 @@ mod test {
                 command: Some(Command::Prune {
                     scope: Vec::new(),
                     docker: false,
+                    docker_buildkit_context: false,
                     output_dir: "dist".to_string(),
                 }),
                 ..Args::default()
@@ -1156,6 +2261,7 @@ mod test {
                 command: Some(Command::Prune {
                     scope: Vec::new(),
                     docker: true,
+                    docker_buildkit_context: false,
                     output_dir: "dist".to_string(),
                 }),
                 ..Args::default()
@@ -1171,6 +2277,7 @@ mod test {
                 command: Some(Command::Prune {
                     scope: Vec::new(),
                     docker: true,
+                    docker_buildkit_context: false,
                     output_dir: "dist".to_string(),
                 }),
                 cwd: Some(PathBuf::from("../examples/with-yarn")),
@@ -1191,6 +2298,7 @@ mod test {
                 command: Some(Command::Prune {
                     scope: vec!["foo".to_string()],
                     docker: true,
+                    docker_buildkit_context: false,
                     output_dir: "dist".to_string(),
                 }),
                 ..Args::default()
@@ -1241,6 +2349,953 @@ mod test {
         );
     }
 
+    #[test]
+    fn test_task_args() {
+        assert_eq!(
+            Args::try_parse_from([
+                "turbo",
+                "run",
+                "test",
+                "--task-args",
+                "web#test=--runInBand",
+                "--task-args=lint=--fix",
+                "--",
+                "--ci"
+            ])
+            .unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["test".to_string()],
+                    task_args: vec!["web#test=--runInBand".to_string(), "lint=--fix".to_string()],
+                    pass_through_args: vec!["--ci".to_string()],
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+    }
+
+    #[test]
+    fn test_also() {
+        assert_eq!(
+            Args::try_parse_from([
+                "turbo",
+                "run",
+                "build",
+                "--filter=web",
+                "--also",
+                "lint --filter=docs",
+                "--also=test"
+            ])
+            .unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    filter: vec!["web".to_string()],
+                    also: vec!["lint --filter=docs".to_string(), "test".to_string()],
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+    }
+
+    #[test]
+    fn test_pr_report() {
+        assert_eq!(
+            Args::try_parse_from([
+                "turbo",
+                "run",
+                "build",
+                "--pr-report",
+                "report.md",
+                "--pr-report-base=base.json"
+            ])
+            .unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    pr_report: Some("report.md".to_string()),
+                    pr_report_base: Some("base.json".to_string()),
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+
+        assert!(
+            Args::try_parse_from(["turbo", "run", "build", "--pr-report-base", "base.json"])
+                .is_err()
+        );
+    }
+
+    #[test]
+    fn test_check_outputs() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "run", "build", "--check-outputs"]).unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    check_outputs: true,
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+        assert_eq!(
+            Args::try_parse_from(["turbo", "run", "build", "--clean-outputs"]).unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    clean_outputs: true,
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+    }
+
+    #[test]
+    fn test_failure_bundles() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "run", "build", "--failure-bundles"]).unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    failure_bundles: true,
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+    }
+
+    #[test]
+    fn test_hash_lock() {
+        assert_eq!(
+            Args::try_parse_from([
+                "turbo",
+                "run",
+                "build",
+                "--cache-key-suffix",
+                "v1.2.0",
+                "--hash-lock",
+                "release.lock",
+                "--write-hash-lock",
+                "out.lock"
+            ])
+            .unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    cache_key_suffix: Some("v1.2.0".to_string()),
+                    hash_lock: Some("release.lock".to_string()),
+                    write_hash_lock: Some("out.lock".to_string()),
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+
+        assert!(Args::try_parse_from([
+            "turbo",
+            "run",
+            "build",
+            "--hash-lock",
+            "release.lock",
+            "--force"
+        ])
+        .is_err());
+    }
+
+    #[test]
+    fn test_cache_mode() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "run", "build", "--cache", "local-only"]).unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    cache: Some(CacheMode::LocalOnly),
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+
+        assert_eq!(
+            Args::try_parse_from(["turbo", "run", "build", "--cache=off"]).unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    cache: Some(CacheMode::Off),
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+
+        assert!(Args::try_parse_from([
+            "turbo",
+            "run",
+            "build",
+            "--cache",
+            "local-only",
+            "--remote-only"
+        ])
+        .is_err());
+    }
+
+    #[test]
+    fn test_github_actions() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "run", "build", "--github-actions"]).unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    github_actions: true,
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+    }
+
+    #[test]
+    fn test_experimental_sandbox() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "run", "build", "--experimental-sandbox"]).unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    experimental_sandbox: true,
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+    }
+
+    #[test]
+    fn test_log_prefix() {
+        assert_eq!(
+            Args::try_parse_from([
+                "turbo",
+                "run",
+                "build",
+                "--log-prefix",
+                "[{task}] ",
+                "--log-prefix-color",
+                "status"
+            ])
+            .unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    log_prefix: Some("[{task}] ".to_string()),
+                    log_prefix_color: Some(LogPrefixColor::Status),
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+    }
+
+    #[test]
+    fn test_experimental_speculate() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "run", "build", "--experimental-speculate"]).unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    experimental_speculate: true,
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+    }
+
+    #[test]
+    fn test_profile_name() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "run", "build", "--profile-name", "ci"]).unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    profile_name: Some("ci".to_string()),
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+    }
+
+    #[test]
+    fn test_serial_deterministic() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "run", "build", "--serial-deterministic"]).unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    serial_deterministic: true,
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+
+        assert!(Args::try_parse_from([
+            "turbo",
+            "run",
+            "build",
+            "--serial-deterministic",
+            "--parallel"
+        ])
+        .is_err());
+    }
+
+    #[test]
+    fn test_show_global_hash_inputs() {
+        assert_eq!(
+            Args::try_parse_from([
+                "turbo",
+                "run",
+                "build",
+                "--dry=json",
+                "--show-global-hash-inputs"
+            ])
+            .unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    dry_run: Some(DryRunMode::Json),
+                    show_global_hash_inputs: true,
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+
+        assert!(
+            Args::try_parse_from(["turbo", "run", "build", "--show-global-hash-inputs"]).is_err()
+        );
+
+        assert_eq!(
+            Args::try_parse_from([
+                "turbo",
+                "run",
+                "build",
+                "--exit-code",
+                "fixed",
+                "--task-failure-exit-code",
+                "3",
+                "--error-exit-code",
+                "70"
+            ])
+            .unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    exit_code: Some(ExitCodeMode::Fixed),
+                    task_failure_exit_code: Some(3),
+                    error_exit_code: Some(70),
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+
+        assert!(
+            Args::try_parse_from(["turbo", "run", "build", "--task-failure-exit-code", "3"])
+                .is_err()
+        );
+
+        assert_eq!(
+            Args::try_parse_from(["turbo", "run", "build", "--exit-code", "bitmask"]).unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    exit_code: Some(ExitCodeMode::Bitmask),
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+
+        assert_eq!(
+            Args::try_parse_from(["turbo", "run", "build", "--restore-dry-run"]).unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    restore_dry_run: true,
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+
+        assert_eq!(
+            Args::try_parse_from([
+                "turbo",
+                "run",
+                "build",
+                "--summary-format",
+                "json",
+                "--summary-file",
+                "summary.json"
+            ])
+            .unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    summary_format: Some(SummaryFormat::Json),
+                    summary_file: Some("summary.json".to_string()),
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+
+        assert!(
+            Args::try_parse_from(["turbo", "run", "build", "--summary-file", "summary.json"])
+                .is_err()
+        );
+
+        assert_eq!(
+            Args::try_parse_from(["turbo", "run", "build", "--summarize=resources"]).unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    summarize: Some("resources".to_string()),
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+
+        assert_eq!(
+            Args::try_parse_from([
+                "turbo",
+                "run",
+                "build",
+                "--otlp-endpoint",
+                "http://localhost:4318"
+            ])
+            .unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    otlp_endpoint: Some("http://localhost:4318".to_string()),
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+
+        assert_eq!(
+            Args::try_parse_from(["turbo", "run", "build", "--events", "fd:3"]).unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    events: Some("fd:3".to_string()),
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+
+        assert_eq!(
+            Args::try_parse_from(["turbo", "run", "build", "--strict"]).unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    strict: true,
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+
+        assert_eq!(
+            Args::try_parse_from(["turbo", "run", "build", "--watch"]).unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    watch: true,
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+
+        assert!(Args::try_parse_from(["turbo", "run", "build", "--watch", "--dry"]).is_err());
+
+        assert_eq!(
+            Args::try_parse_from(["turbo", "run", "build", "--record", "run.json"]).unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    record: Some("run.json".to_string()),
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+
+        assert_eq!(
+            Args::try_parse_from(["turbo", "replay", "run.json", "--verify-only"]).unwrap(),
+            Args {
+                command: Some(Command::Replay {
+                    bundle: "run.json".to_string(),
+                    ignore_mismatches: false,
+                    verify_only: true,
+                }),
+                ..Args::default()
+            }
+        );
+    }
+
+    #[test]
+    fn test_parse_cache_serve() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "cache", "serve", "--max-size", "10GB"]).unwrap(),
+            Args {
+                command: Some(Command::Cache {
+                    command: CacheCommand::Serve {
+                        listen: "127.0.0.1:9878".to_string(),
+                        dir: None,
+                        max_age: "7d".to_string(),
+                        max_size: Some("10GB".to_string()),
+                    },
+                }),
+                ..Args::default()
+            }
+        );
+    }
+
+    #[test]
+    fn test_parse_cache_gc() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "cache", "gc", "--max-age", "14d"]).unwrap(),
+            Args {
+                command: Some(Command::Cache {
+                    command: CacheCommand::Gc {
+                        cache_dir: None,
+                        max_age: Some("14d".to_string()),
+                        max_size: None,
+                    },
+                }),
+                ..Args::default()
+            }
+        );
+        assert_eq!(
+            Args::try_parse_from(["turbo", "run", "build", "--max-cache-size", "10GB"]).unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    max_cache_size: Some("10GB".to_string()),
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+    }
+
+    #[test]
+    fn test_parse_cache_inspect() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "cache", "inspect", "a1b2c3", "--json"]).unwrap(),
+            Args {
+                command: Some(Command::Cache {
+                    command: CacheCommand::Inspect {
+                        hash: "a1b2c3".to_string(),
+                        cache_dir: None,
+                        json: true,
+                    },
+                }),
+                ..Args::default()
+            }
+        );
+    }
+
+    #[test]
+    fn test_parse_cache_restore() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "cache", "restore", "a1b2c3", "--to", "/tmp/out"])
+                .unwrap(),
+            Args {
+                command: Some(Command::Cache {
+                    command: CacheCommand::Restore {
+                        hash: "a1b2c3".to_string(),
+                        to: "/tmp/out".to_string(),
+                        cache_dir: None,
+                    },
+                }),
+                ..Args::default()
+            }
+        );
+        assert!(Args::try_parse_from(["turbo", "cache", "restore", "a1b2c3"]).is_err());
+    }
+
+    #[test]
+    fn test_parse_cache_provenance() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "cache", "provenance", "a1b2c3", "--json"]).unwrap(),
+            Args {
+                command: Some(Command::Cache {
+                    command: CacheCommand::Provenance {
+                        hash: "a1b2c3".to_string(),
+                        cache_dir: None,
+                        json: true,
+                    },
+                }),
+                ..Args::default()
+            }
+        );
+    }
+
+    #[test]
+    fn test_parse_grace_period() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "run", "dev", "--grace-period", "30s"]).unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["dev".to_string()],
+                    grace_period: Some("30s".to_string()),
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+    }
+
+    #[test]
+    fn test_parse_cache_scope() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "run", "build", "--cache-scope", "feature/login"])
+                .unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    cache_scope: Some("feature/login".to_string()),
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+    }
+
+    #[test]
+    fn test_parse_progress() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "run", "build", "--progress"]).unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    progress: true,
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+    }
+
+    #[test]
+    fn test_parse_report() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "run", "test", "--report=junit:reports/turbo.xml"])
+                .unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["test".to_string()],
+                    report: vec!["junit:reports/turbo.xml".to_string()],
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+        assert_eq!(
+            Args::try_parse_from([
+                "turbo",
+                "run",
+                "test",
+                "--report=junit:reports/turbo.xml",
+                "--report=html:reports/turbo.html"
+            ])
+            .unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["test".to_string()],
+                    report: vec![
+                        "junit:reports/turbo.xml".to_string(),
+                        "html:reports/turbo.html".to_string()
+                    ],
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+    }
+
+    #[test]
+    fn test_parse_shard() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "run", "build", "--shard=2/5"]).unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["build".to_string()],
+                    shard: Some("2/5".to_string()),
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+        assert!(Args::try_parse_from(["turbo", "run", "build", "--shard=2/5", "--watch"]).is_err());
+    }
+
+    #[test]
+    fn test_parse_test_map() {
+        assert_eq!(
+            Args::try_parse_from([
+                "turbo",
+                "run",
+                "test",
+                "--affected",
+                "--test-map=coverage/test-map.json"
+            ])
+            .unwrap(),
+            Args {
+                command: Some(Command::Run(Box::new(RunArgs {
+                    tasks: vec!["test".to_string()],
+                    affected: true,
+                    test_map: Some("coverage/test-map.json".to_string()),
+                    ..get_default_run_args()
+                }))),
+                ..Args::default()
+            }
+        );
+        assert!(Args::try_parse_from(["turbo", "run", "test", "--test-map=test-map.json"]).is_err());
+    }
+
+    #[test]
+    fn test_parse_insights_flaky() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "insights", "flaky", "--runs", "20", "--json"]).unwrap(),
+            Args {
+                command: Some(Command::Insights {
+                    command: InsightsCommand::Flaky {
+                        runs: 20,
+                        json: true,
+                    },
+                }),
+                ..Args::default()
+            }
+        );
+        assert_eq!(
+            Args::try_parse_from(["turbo", "flaky"]).unwrap(),
+            Args {
+                command: Some(Command::Flaky {
+                    runs: 100,
+                    json: false,
+                }),
+                ..Args::default()
+            }
+        );
+    }
+
+    #[test]
+    fn test_help_insights_flaky() {
+        let mut command = Args::command();
+        // The deprecated top level command is hidden in favor of `insights flaky`
+        let help = command.render_help().to_string();
+        assert!(help.contains("insights"));
+        assert!(!help.contains("flaky"));
+
+        let insights_help = command
+            .find_subcommand_mut("insights")
+            .unwrap()
+            .render_help()
+            .to_string();
+        assert!(insights_help.contains("flaky  Report the tasks that have both failed and passed"));
+        assert!(!insights_help.contains("replaces it"));
+    }
+
+    #[test]
+    fn test_parse_compare() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "compare", "2Qa", "2Qb", "--json"]).unwrap(),
+            Args {
+                command: Some(Command::Compare {
+                    before: "2Qa".to_string(),
+                    after: "2Qb".to_string(),
+                    json: true,
+                }),
+                ..Args::default()
+            }
+        );
+    }
+
+    #[test]
+    fn test_parse_explain() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "explain", "web#build", "--json"]).unwrap(),
+            Args {
+                command: Some(Command::Explain {
+                    task: "web#build".to_string(),
+                    runs: 100,
+                    daemon: false,
+                    json: true,
+                }),
+                ..Args::default()
+            }
+        );
+    }
+
+    #[test]
+    fn test_parse_globalhash() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "globalhash", "--explain"]).unwrap(),
+            Args {
+                command: Some(Command::Globalhash {
+                    explain: true,
+                    json: false,
+                }),
+                ..Args::default()
+            }
+        );
+    }
+
+    #[test]
+    fn test_parse_hash_inputs() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "hash-inputs", "build", "--filter", "web"]).unwrap(),
+            Args {
+                command: Some(Command::HashInputs {
+                    task: "build".to_string(),
+                    filter: vec!["web".to_string()],
+                    json: false,
+                }),
+                ..Args::default()
+            }
+        );
+    }
+
+    #[test]
+    fn test_parse_info() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "info", "--filter", "web", "--json"]).unwrap(),
+            Args {
+                command: Some(Command::Info {
+                    filter: vec!["web".to_string()],
+                    json: true,
+                }),
+                ..Args::default()
+            }
+        );
+    }
+
+    #[test]
+    fn test_parse_daemon() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "daemon", "--watcher", "poll", "--poll-interval", "2s"])
+                .unwrap(),
+            Args {
+                command: Some(Command::Daemon {
+                    idle_time: None,
+                    metrics_addr: None,
+                    watcher: Some(WatcherBackend::Poll),
+                    poll_interval: Some("2s".to_string()),
+                    command: None,
+                }),
+                ..Args::default()
+            }
+        );
+        assert_eq!(
+            Args::try_parse_from(["turbo", "daemon", "--watcher=native", "restart"]).unwrap(),
+            Args {
+                command: Some(Command::Daemon {
+                    idle_time: None,
+                    metrics_addr: None,
+                    watcher: Some(WatcherBackend::Native),
+                    poll_interval: None,
+                    command: Some(DaemonCommand::Restart),
+                }),
+                ..Args::default()
+            }
+        );
+        assert!(Args::try_parse_from(["turbo", "daemon", "--watcher", "inotify"]).is_err());
+    }
+
+    #[test]
+    fn test_parse_prefetch() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "prefetch"]).unwrap(),
+            Args {
+                command: Some(Command::Prefetch {
+                    tasks: vec![],
+                    filter: vec![],
+                    cache_dir: None,
+                }),
+                ..Args::default()
+            }
+        );
+        assert_eq!(
+            Args::try_parse_from(["turbo", "prefetch", "build", "test", "--filter", "web"])
+                .unwrap(),
+            Args {
+                command: Some(Command::Prefetch {
+                    tasks: vec!["build".to_string(), "test".to_string()],
+                    filter: vec!["web".to_string()],
+                    cache_dir: None,
+                }),
+                ..Args::default()
+            }
+        );
+    }
+
+    #[test]
+    fn test_parse_runs() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "runs", "list", "--limit", "5"]).unwrap(),
+            Args {
+                command: Some(Command::Runs {
+                    command: RunsCommand::List {
+                        json: false,
+                        limit: 5,
+                    },
+                }),
+                ..Args::default()
+            }
+        );
+    }
+
+    #[test]
+    fn test_parse_query() {
+        assert_eq!(
+            Args::try_parse_from(["turbo", "query", "packages | names"]).unwrap(),
+            Args {
+                command: Some(Command::Query {
+                    command: None,
+                    query: Some("packages | names".to_string()),
+                }),
+                ..Args::default()
+            }
+        );
+        assert_eq!(
+            Args::try_parse_from(["turbo", "query", "filter", "./apps/**#test"]).unwrap(),
+            Args {
+                command: Some(Command::Query {
+                    command: Some(QueryCommand::Filter {
+                        filter: "./apps/**#test".to_string(),
+                    }),
+                    query: None,
+                }),
+                ..Args::default()
+            }
+        );
+        assert!(Args::try_parse_from(["turbo", "query"]).is_err());
+    }
+
     #[test]
     fn test_verbosity_serialization() -> Result<(), serde_json::Error> {
         assert_eq!(
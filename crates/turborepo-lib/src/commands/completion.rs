@@ -0,0 +1,207 @@
+use std::io::{self, Write};
+
+use anyhow::Result;
+use clap::CommandFactory;
+use clap_complete::{generate, Shell};
+
+use crate::Args;
+
+// The scripts generated by clap only know about the static parts of the CLI.
+// Each of these snippets wraps the generated completion function so that
+// package names are offered after `--filter`/`--scope` and task names are
+// offered when no other subcommand has been given. The candidates come from the
+// hidden `turbo __complete` command so they always reflect the current repo.
+// `__SUBCOMMANDS__` is replaced with the names of every subcommand except
+// `run`.
+
+const BASH_DYNAMIC: &str = r#"
+_turbo_dynamic() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    local prev="${COMP_WORDS[COMP_CWORD-1]}"
+    case "${prev}" in
+        --filter|--scope)
+            COMPREPLY=( $(compgen -W "$(turbo __complete packages 2>/dev/null)" -- "${cur}") )
+            return 0
+            ;;
+    esac
+    _turbo "$@"
+    if [[ "${cur}" == -* ]]; then
+        return 0
+    fi
+    local i
+    for (( i=1; i<COMP_CWORD; i++ )); do
+        case "${COMP_WORDS[i]}" in
+            __SUBCOMMANDS__)
+                return 0
+                ;;
+        esac
+    done
+    COMPREPLY+=( $(compgen -W "$(turbo __complete tasks 2>/dev/null)" -- "${cur}") )
+}
+
+complete -F _turbo_dynamic -o bashdefault -o default turbo
+"#;
+
+const ZSH_AUTOLOAD: &str = r#"if [ "$funcstack[1]" = "_turbo" ]; then"#;
+
+const ZSH_DYNAMIC: &str = r#"_turbo_dynamic() {
+    if [[ ${words[CURRENT-1]} == (--filter|--scope) ]]; then
+        local -a packages
+        packages=(${(f)"$(turbo __complete packages 2>/dev/null)"})
+        compadd -a packages
+        return
+    fi
+    local ret=1
+    if [[ ${words[CURRENT]} != -* ]] && (( ! ${words[(I)(__SUBCOMMANDS__)]} )); then
+        local -a tasks
+        tasks=(${(f)"$(turbo __complete tasks 2>/dev/null)"})
+        compadd -a tasks && ret=0
+    fi
+    _turbo "$@" && ret=0
+    return ret
+}
+
+if [ "$funcstack[1]" = "_turbo" ]; then
+    compdef _turbo_dynamic turbo
+    _turbo_dynamic "$@"
+else
+    compdef _turbo_dynamic turbo
+fi
+"#;
+
+const FISH_DYNAMIC: &str = r#"
+complete -c turbo -l filter -x -a "(turbo __complete packages 2>/dev/null)"
+complete -c turbo -l scope -x -a "(turbo __complete packages 2>/dev/null)"
+complete -c turbo -n "not __fish_seen_subcommand_from __SUBCOMMANDS__" -f -a "(turbo __complete tasks 2>/dev/null)"
+"#;
+
+const POWERSHELL_REGISTER: &str =
+    "Register-ArgumentCompleter -Native -CommandName 'turbo' -ScriptBlock {";
+
+const POWERSHELL_DYNAMIC: &str = r#"
+Register-ArgumentCompleter -Native -CommandName 'turbo' -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+
+    $words = @($commandAst.CommandElements |
+        Where-Object { $_.Extent.StartOffset -lt $cursorPosition } |
+        ForEach-Object { $_.ToString() })
+    if ($wordToComplete) {
+        $words = $words[0..($words.Count - 2)]
+    }
+    $toResults = {
+        $input | Where-Object { $_ -like "$wordToComplete*" } |
+            ForEach-Object { [CompletionResult]::new($_, $_, [CompletionResultType]::ParameterValue, $_) }
+    }
+
+    if ($words[-1] -in '--filter', '--scope') {
+        return turbo __complete packages 2>$null | & $toResults
+    }
+    $results = @(& $turboStaticCompleter $wordToComplete $commandAst $cursorPosition)
+    $subcommands = @(__SUBCOMMANDS__)
+    if (-not $wordToComplete.StartsWith('-') -and -not ($words | Where-Object { $_ -in $subcommands })) {
+        $results += turbo __complete tasks 2>$null | & $toResults
+    }
+    $results
+}
+"#;
+
+pub fn completion(shell: Shell) -> Result<()> {
+    let script = script(shell)?;
+    io::stdout().write_all(script.as_bytes())?;
+
+    Ok(())
+}
+
+fn script(shell: Shell) -> Result<String> {
+    let mut command = Args::command();
+    let subcommands = command
+        .get_subcommands()
+        .filter(|subcommand| !subcommand.is_hide_set() && subcommand.get_name() != "run")
+        .map(|subcommand| subcommand.get_name().to_string())
+        .collect::<Vec<_>>();
+
+    let mut buffer = Vec::new();
+    generate(shell, &mut command, "turbo", &mut buffer);
+    let mut script = String::from_utf8(buffer)?;
+
+    match shell {
+        Shell::Bash => {
+            script.push_str(&BASH_DYNAMIC.replace("__SUBCOMMANDS__", &subcommands.join("|")));
+        }
+        Shell::Zsh => {
+            // The generated script ends by either invoking or registering `_turbo`.
+            // Replace that with our wrapper so that it is the function that gets used.
+            if let Some(index) = script.rfind(ZSH_AUTOLOAD) {
+                script.truncate(index);
+            }
+            script.push_str(&ZSH_DYNAMIC.replace("__SUBCOMMANDS__", &subcommands.join("|")));
+        }
+        Shell::Fish => {
+            script.push_str(&FISH_DYNAMIC.replace("__SUBCOMMANDS__", &subcommands.join(" ")));
+        }
+        Shell::PowerShell => {
+            // Keep the generated completer around as a script block so that our
+            // completer can fall back to it.
+            script = script.replace(POWERSHELL_REGISTER, "$turboStaticCompleter = {");
+            let subcommands = subcommands
+                .iter()
+                .map(|subcommand| format!("'{}'", subcommand))
+                .collect::<Vec<_>>()
+                .join(", ");
+            script.push_str(&POWERSHELL_DYNAMIC.replace("__SUBCOMMANDS__", &subcommands));
+        }
+        // Elvish only gets the static completions
+        _ => {}
+    }
+
+    Ok(script)
+}
+
+#[cfg(test)]
+mod test {
+    use clap_complete::Shell;
+
+    use super::script;
+
+    #[test]
+    fn test_bash_completes_packages_and_tasks() {
+        let script = script(Shell::Bash).unwrap();
+        assert!(script.contains("turbo __complete packages"));
+        assert!(script.contains("turbo __complete tasks"));
+        assert!(script.contains("complete -F _turbo_dynamic"));
+        assert!(script.contains("|prune|"));
+        assert!(!script.contains("|run|"));
+        assert!(!script.contains("__SUBCOMMANDS__"));
+    }
+
+    #[test]
+    fn test_zsh_registers_wrapper() {
+        let script = script(Shell::Zsh).unwrap();
+        assert!(!script.contains("compdef _turbo turbo"));
+        assert!(script.contains("compdef _turbo_dynamic turbo"));
+        assert_eq!(script.matches(super::ZSH_AUTOLOAD).count(), 1);
+    }
+
+    #[test]
+    fn test_powershell_falls_back_to_static_completer() {
+        let script = script(Shell::PowerShell).unwrap();
+        assert!(script.contains("$turboStaticCompleter = {"));
+        assert_eq!(script.matches(super::POWERSHELL_REGISTER).count(), 1);
+        assert!(!script.contains("'__complete'"));
+    }
+
+    #[test]
+    fn test_hidden_subcommands_are_excluded() {
+        let script = script(Shell::Fish).unwrap();
+        let subcommands: Vec<&str> = script
+            .split("__fish_seen_subcommand_from ")
+            .last()
+            .and_then(|rest| rest.split('"').next())
+            .unwrap()
+            .split_whitespace()
+            .collect();
+        assert!(subcommands.contains(&"prune"));
+        assert!(!subcommands.contains(&"__complete"));
+        assert!(!subcommands.contains(&"run"));
+    }
+}
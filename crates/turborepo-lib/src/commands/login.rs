@@ -1,10 +1,11 @@
 #[cfg(not(test))]
 use std::net::SocketAddr;
-use std::sync::Arc;
+use std::{fs, path::Path, sync::Arc};
 
 use anyhow::{anyhow, Context, Result};
 #[cfg(not(test))]
 use axum::{extract::Query, response::Redirect, routing::get, Router};
+use jsonc_parser::{parse_to_serde_value, ParseOptions};
 use log::debug;
 #[cfg(not(test))]
 use log::warn;
@@ -28,6 +29,29 @@ const DEFAULT_HOST_NAME: &str = "127.0.0.1";
 const DEFAULT_PORT: u16 = 9789;
 const DEFAULT_SSO_PROVIDER: &str = "SAML/OIDC Single Sign-On";
 
+/// Returns true if the turbo.json at the root of the repository configures the
+/// "auth" of "remoteCache", in which case `turbo login` signs in with that
+/// OpenID Connect provider instead of Vercel. A turbo.json that can't be read
+/// is left for `turbo run` to report.
+pub fn has_custom_auth(repo_root: &Path) -> bool {
+    let contents = match fs::read_to_string(repo_root.join("turbo.json")) {
+        Ok(contents) => contents,
+        Err(_) => return false,
+    };
+    let options = ParseOptions {
+        allow_comments: true,
+        allow_trailing_commas: true,
+        allow_loose_object_property_names: false,
+    };
+    match parse_to_serde_value(&contents, &options) {
+        Ok(Some(turbo_json)) => turbo_json
+            .get("remoteCache")
+            .and_then(|remote_cache| remote_cache.get("auth"))
+            .is_some(),
+        _ => false,
+    }
+}
+
 pub async fn sso_login(base: &mut CommandBase, sso_team: &str) -> Result<()> {
     let redirect_url = format!("http://{DEFAULT_HOST_NAME}:{DEFAULT_PORT}");
     let mut login_url = Url::parse(&format!("{}/api/auth/sso", base.repo_config()?.login_url()))?;
@@ -309,7 +333,7 @@ mod test {
         client::{CachingStatus, CachingStatusResponse, User, UserResponse, VerificationResponse},
         commands::{
             login,
-            login::{get_token_and_redirect, SsoPayload, EXPECTED_TOKEN_TEST},
+            login::{get_token_and_redirect, has_custom_auth, SsoPayload, EXPECTED_TOKEN_TEST},
             CommandBase,
         },
         config::{ClientConfigLoader, RepoConfigLoader, UserConfigLoader},
@@ -480,6 +504,29 @@ mod test {
             .await?)
     }
 
+    #[test]
+    fn test_has_custom_auth() {
+        let repo_root = tempfile::tempdir().unwrap();
+        assert!(!has_custom_auth(repo_root.path()));
+
+        fs::write(
+            repo_root.path().join("turbo.json"),
+            r#"{
+                // Sign in with the company's SSO
+                "remoteCache": { "auth": { "issuer": "https://sso.example.com", "clientId": "turbo" } },
+            }"#,
+        )
+        .unwrap();
+        assert!(has_custom_auth(repo_root.path()));
+
+        fs::write(
+            repo_root.path().join("turbo.json"),
+            r#"{ "remoteCache": { "signature": true } }"#,
+        )
+        .unwrap();
+        assert!(!has_custom_auth(repo_root.path()));
+    }
+
     #[test]
     fn test_get_token_and_redirect() {
         assert_eq!(
@@ -14,6 +14,7 @@ use crate::{
 };
 
 pub(crate) mod bin;
+pub(crate) mod completion;
 pub(crate) mod link;
 pub(crate) mod login;
 pub(crate) mod logout;
@@ -44,6 +44,8 @@ impl From<Workspaces> for Vec<String> {
 pub enum PackageManager {
     #[allow(dead_code)]
     Berry,
+    #[allow(dead_code)]
+    Bun,
     Npm,
     Pnpm,
     #[allow(dead_code)]
@@ -106,7 +108,10 @@ impl PackageManager {
                     pnpm_workspace.packages
                 }
             }
-            PackageManager::Berry | PackageManager::Npm | PackageManager::Yarn => {
+            PackageManager::Berry
+            | PackageManager::Bun
+            | PackageManager::Npm
+            | PackageManager::Yarn => {
                 let package_json_text = fs::read_to_string(root_path.join("package.json"))?;
                 let package_json: PackageJsonWorkspaces = serde_json::from_str(&package_json_text)?;
 
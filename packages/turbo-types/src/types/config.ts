@@ -32,7 +32,8 @@ export interface WorkspaceSchema extends BaseSchema {
    * and overrides with the keys provided
    * in your Workspace Configs.
    *
-   * Currently, only the "//" value is allowed.
+   * Either "//" for the root `turbo.json`, or the name of
+   * another workspace whose `turbo.json` extends the root.
    *
    * @default ["//"]
    */
@@ -65,6 +66,9 @@ export interface RootSchema extends BaseSchema {
    *
    * The variables included in this list will affect all task hashes.
    *
+   * Use `*` to match many variables (e.g. `NEXT_PUBLIC_*`), and prefix a
+   * name with `!` to leave out the variables it matches.
+   *
    * Documentation: https://turbo.build/repo/docs/reference/configuration#globalenv
    *
    * @default []
@@ -79,6 +83,84 @@ export interface RootSchema extends BaseSchema {
    * @default {}
    */
   remoteCache?: RemoteCache;
+
+  /**
+   * Commands that turbo runs at points in the lifecycle of `turbo run`.
+   *
+   * Hooks run from the root of the repository and receive a JSON description
+   * of the run or task on stdin. A failing preRun or preTask hook stops the run
+   * or task, which makes them suitable for policy checks.
+   *
+   * @default {}
+   */
+  hooks?: Hooks;
+
+  /**
+   * Tags for workspaces and rules that restrict which tagged workspaces may
+   * depend on each other. Checked by `turbo boundaries`.
+   *
+   * @default {}
+   */
+  boundaries?: Boundaries;
+
+  /**
+   * Webhooks that are sent the outcome of each `turbo run`, including the
+   * failed tasks and the end of their logs.
+   *
+   * @default []
+   */
+  notifications?: Notification[];
+
+  /**
+   * An endpoint that a summary of each `turbo run` is posted to, with its
+   * durations, cache hit rate and remote cache transfers, for dashboards of
+   * cache effectiveness across repositories and CI jobs.
+   *
+   * @default {}
+   */
+  analytics?: Analytics;
+
+  /**
+   * Endpoints that are sent the start and outcome of each task while a
+   * `turbo run` is in progress, and the summary of the run when it ends, for
+   * build dashboards of long CI runs.
+   *
+   * @default []
+   */
+  webhooks?: Webhook[];
+
+  /**
+   * The version of the hashing algorithm that task hashes are computed with,
+   * and the version whose cache entries are restored while migrating from it.
+   *
+   * @default {}
+   */
+  hashing?: Hashing;
+
+  /**
+   * How the lines that tasks log are prefixed. --log-prefix and
+   * --log-prefix-color take precedence.
+   *
+   * @default {}
+   */
+  logs?: Logs;
+
+  /**
+   * Limits on the run summaries kept in .turbo/runs by `turbo run --summarize`.
+   * Older summaries are removed after each run, or by `turbo runs clean`.
+   *
+   * @default {}
+   */
+  summaries?: Summaries;
+
+  /**
+   * Named sets of `turbo run` flags, such as `ci` or `local-dev`, selected
+   * with `--profile-name`. Flags passed on the command line take precedence
+   * over the profile.
+   *
+   * @default {}
+   */
+  profiles?: Record<string, RunProfile>;
 }
 
 export interface Pipeline {
@@ -108,12 +190,26 @@ export interface Pipeline {
    * You no longer need to use the $ prefix.
    * (e.g. $GITHUB_TOKEN -> GITHUB_TOKEN)
    *
+   * Use `*` to match many variables (e.g. `NEXT_PUBLIC_*`), and prefix a
+   * name with `!` to leave out the variables it matches.
+   *
    * Documentation: https://turbo.build/repo/docs/reference/configuration#env
    *
    * @default []
    */
   env?: string[];
 
+  /**
+   * A list of environment variables that this task reads, but that should
+   * not affect its hash, such as credentials for deployment.
+   *
+   * They support the same wildcards as `env`, and are listed in dry runs and
+   * run summaries.
+   *
+   * @default []
+   */
+  passThroughEnv?: string[];
+
   /**
    * The set of glob patterns indicating a task's cacheable filesystem outputs.
    *
@@ -155,6 +251,19 @@ export interface Pipeline {
    */
   inputs?: string[];
 
+  /**
+   * Shell commands whose output is part of the task's hash, for inputs that
+   * aren't files or environment variables, such as `["rustc --version"]`.
+   *
+   * Commands run in the task's workspace while tasks are hashed, once per
+   * workspace. A command that exits with an error fails the run.
+   *
+   * Documentation: https://turbo.build/repo/docs/reference/configuration#hashcommands
+   *
+   * @default []
+   */
+  hashCommands?: string[];
+
   /**
    * Output mode for the task.
    *
@@ -184,6 +293,162 @@ export interface Pipeline {
    * @default false
    */
   persistent?: boolean;
+
+  /**
+   * Connects the task's command to the terminal, so that tools that prompt for
+   * input, such as code generators, can be answered. The task runs on its own,
+   * and its output isn't captured in its log file.
+   *
+   * Documentation: https://turbo.build/repo/docs/reference/configuration#interactive
+   *
+   * @default false
+   */
+  interactive?: boolean;
+
+  /**
+   * A label that groups the task with related tasks in the logs of a run. It
+   * is shown by the {label} placeholder of the log prefix, and with
+   * --log-order=grouped the logs of the tasks with the same label are printed
+   * together.
+   *
+   * Documentation: https://turbo.build/repo/docs/reference/configuration#label
+   */
+  label?: string;
+
+  /**
+   * Runs the task's script with turbo instead of the package manager:
+   *
+   * - "bash": with `bash -c`
+   * - "pwsh": with `pwsh -Command`
+   * - "none": without a shell. The script is split into words the same way on
+   *   every platform, and may not use pipes or other shell operators.
+   *
+   * Documentation: https://turbo.build/repo/docs/reference/configuration#shell
+   */
+  shell?: "bash" | "pwsh" | "none";
+
+  /**
+   * A command that runs in place of the task's script in package.json, so
+   * that the task also runs in packages without such a script, or without a
+   * package.json. It runs with the task's `shell`, or with `sh -c` (`cmd /C`
+   * on Windows) when the task has none.
+   *
+   * Documentation: https://turbo.build/repo/docs/reference/configuration#command
+   */
+  command?: string;
+
+  /**
+   * The directory that the task's command runs in, relative to its package.
+   * It may be outside of the package, but must be inside of the repository.
+   *
+   * Documentation: https://turbo.build/repo/docs/reference/configuration#cwd
+   */
+  cwd?: string;
+
+  /**
+   * Environment variables to set for the task's command. Values are part of
+   * the task's hash, and may contain these placeholders:
+   *
+   * - `${TURBO_HASH}`: the hash of the task
+   * - `${TURBO_TASK}`: the name of the task
+   * - `${TURBO_PACKAGE}`: the name of the task's workspace
+   * - `${TURBO_REPO_ROOT}`: the absolute path of the repository root
+   * - `${TURBO_PACKAGE_DIR}`: the absolute path of the task's workspace
+   *
+   * @default {}
+   */
+  setEnv?: Record<string, string>;
+
+  /**
+   * Env files to load into the task's environment, such as
+   * `[".env.local", ".env"]`. Their values are part of the task's hash.
+   *
+   * Files are loaded from the repository root, then from the task's
+   * workspace, and a variable in a workspace file overrides the same variable
+   * in a root file. Within a directory, files earlier in the list take
+   * precedence. Variables that are already set in turbo's environment are
+   * never overridden, and `setEnv` takes precedence over all files.
+   *
+   * @default []
+   */
+  dotEnv?: string[];
+
+  /**
+   * Whether the task's log file (`.turbo/turbo-<task>.log`) is stored in its
+   * cache artifacts. When `false`, cache hits restore the task's outputs but
+   * do not replay its logs.
+   *
+   * Pass `--no-cache-logs` to leave logs out of the cache for every task.
+   *
+   * @default true
+   */
+  cacheLogs?: boolean;
+
+  /**
+   * How many of the `--concurrency` slots the task takes while it runs. Give
+   * memory or CPU heavy tasks a higher weight so that fewer tasks run next to
+   * them. Weights above the concurrency are capped, so those tasks run alone.
+   *
+   * @default 1
+   */
+  weight?: number;
+
+  /**
+   * Which tasks start first when more tasks are ready to run than there are
+   * free `--concurrency` slots. Tasks with a higher priority start first, and
+   * so do the tasks they depend on. Between tasks with the same priority, the
+   * ones with the longest chain of dependent tasks left to run start first,
+   * based on how long those took in the run summaries saved by `--summarize`.
+   *
+   * @default 0
+   */
+  priority?: number;
+
+  /**
+   * How many more times to run the task's command after it fails, for tasks
+   * that fail now and then, such as integration tests. Every attempt and its
+   * duration is listed in the run summary.
+   *
+   * @default 0
+   */
+  retries?: number;
+
+  /**
+   * How many milliseconds to wait before the first retry of a failed task.
+   * The wait doubles for every retry after that.
+   *
+   * @default 0
+   */
+  retryBackoff?: number;
+
+  /**
+   * How long the task's command may run, as a duration such as "10m" or
+   * "90s". Once it is exceeded, the command and every process it started are
+   * stopped, and the task fails with the status "timedOut" in the run
+   * summary. Use --continue to keep running the other tasks.
+   *
+   * @default undefined (no timeout)
+   */
+  timeout?: string;
+
+  /**
+   * How much space the task's outputs may take up once it finishes, as a size
+   * such as "5MB" or "500KiB". The log file doesn't count. When the outputs
+   * are over this size, the task fails and its outputs aren't cached, unless
+   * `maxOutputSizeMode` is "warn". Outputs restored from the cache are checked
+   * too. The size of the outputs is recorded in the run summary.
+   *
+   * @default undefined (no limit)
+   */
+  maxOutputSize?: string;
+
+  /**
+   * Whether outputs over `maxOutputSize` fail the task ("error") or only print
+   * a warning ("warn").
+   *
+   * @default "error"
+   */
+  maxOutputSizeMode?: "error" | "warn";
 }
 
 export interface RemoteCache {
@@ -196,6 +461,374 @@ export interface RemoteCache {
    * @default false
    */
   signature?: boolean;
+
+  /**
+   * How artifacts are signed when `signature` is `true`. `"hmac-sha256"` uses
+   * the secret in `TURBO_REMOTE_CACHE_SIGNATURE_KEY`. `"ed25519"` signs uploads
+   * with the PEM-encoded private key in `TURBO_REMOTE_CACHE_SIGNATURE_PRIVATE_KEY`
+   * and verifies downloads with the PEM-encoded public key in
+   * `TURBO_REMOTE_CACHE_SIGNATURE_PUBLIC_KEY`, so that machines that only read
+   * the cache never hold a key that can sign artifacts.
+   *
+   * @default "hmac-sha256"
+   */
+  signatureAlgorithm?: "hmac-sha256" | "ed25519";
+
+  /**
+   * How artifacts are compressed before they are uploaded. The compression is
+   * sent as the `Content-Encoding` of the upload, and downloads are
+   * decompressed according to theirs, so artifacts compressed either way can
+   * be shared by the same cache.
+   *
+   * @default "zstd"
+   */
+  compression?: "zstd" | "gzip";
+
+  /**
+   * The level of `compression`, from 1 (fastest) to 20 for `"zstd"` or 9 for
+   * `"gzip"`. Lower levels upload large artifacts sooner, at the cost of
+   * transferring more bytes.
+   *
+   * @default 5 for "zstd", 6 for "gzip"
+   */
+  compressionLevel?: number;
+
+  /**
+   * Stores artifacts in a bucket of an S3-compatible object store, such as
+   * Amazon S3, Google Cloud Storage or MinIO, instead of the Vercel Remote Cache.
+   * Requests are signed with the credentials in the `AWS_ACCESS_KEY_ID`,
+   * `AWS_SECRET_ACCESS_KEY` and `AWS_SESSION_TOKEN` environment variables.
+   */
+  s3?: S3RemoteCache;
+
+  /**
+   * Makes `turbo login` sign in with an OpenID Connect provider, such as a
+   * corporate single sign-on, instead of Vercel. The token is requested with
+   * the OAuth device authorization flow and refreshed when it expires.
+   */
+  auth?: RemoteCacheAuth;
+
+  /**
+   * Encrypts artifacts with AES-256-GCM before they are uploaded, so that the
+   * remote cache only stores ciphertext. The key is read from the
+   * `TURBO_REMOTE_CACHE_ENCRYPTION_KEY` environment variable, or from the file
+   * in `TURBO_REMOTE_CACHE_ENCRYPTION_KEY_FILE`, as 32 bytes encoded in base64.
+   * Older keys can follow the newest one, separated by commas or newlines, to
+   * keep decrypting the artifacts they encrypted.
+   *
+   * @default false
+   */
+  encryption?: boolean;
+}
+
+export interface RemoteCacheAuth {
+  /**
+   * The URL of the provider, where `/.well-known/openid-configuration` is
+   * found.
+   */
+  issuer: string;
+
+  /**
+   * The client ID that `turbo` is registered with at the provider, as a
+   * public client allowed to use the device authorization grant.
+   */
+  clientId: string;
+
+  /**
+   * The scopes to request.
+   *
+   * @default ["openid", "offline_access"]
+   */
+  scopes?: Array<string>;
+}
+
+export interface S3RemoteCache {
+  /**
+   * The name of the bucket.
+   */
+  bucket: string;
+
+  /**
+   * Prepended to the key of every artifact, such as `"turbo/"`.
+   *
+   * @default ""
+   */
+  prefix?: string;
+
+  /**
+   * The region of the bucket. Defaults to the `AWS_REGION` or
+   * `AWS_DEFAULT_REGION` environment variable, and then `us-east-1`.
+   */
+  region?: string;
+
+  /**
+   * The URL of an S3-compatible store other than Amazon S3, such as
+   * `"https://storage.googleapis.com"`.
+   */
+  endpoint?: string;
+
+  /**
+   * Puts the bucket in the path of URLs instead of in the host name, as most
+   * self-hosted stores expect.
+   *
+   * @default false
+   */
+  forcePathStyle?: boolean;
+}
+
+export interface Hooks {
+  /**
+   * Commands to run before any task is executed.
+   *
+   * @default []
+   */
+  preRun?: string[];
+
+  /**
+   * Commands to run after all tasks have finished. Failures are reported
+   * as warnings. The exit code and duration of the run are passed as
+   * `TURBO_EXIT_CODE` and `TURBO_DURATION`.
+   *
+   * @default []
+   */
+  postRun?: string[];
+
+  /**
+   * Commands to run before each task is restored from cache or executed.
+   *
+   * @default []
+   */
+  preTask?: string[];
+
+  /**
+   * Commands to run after each task has finished. Failures are reported
+   * as warnings. The status of the task ("cached", "built", "failed" or
+   * "timedOut"), its exit code and duration in milliseconds are passed as
+   * `TURBO_TASK_STATUS`, `TURBO_EXIT_CODE` and `TURBO_DURATION`.
+   *
+   * @default []
+   */
+  postTask?: string[];
+
+  /**
+   * Commands to run when a task is not found in the cache, before it
+   * is executed.
+   *
+   * @default []
+   */
+  onCacheMiss?: string[];
+}
+
+export interface Boundaries {
+  /**
+   * A map from workspace name to the tags of that workspace.
+   *
+   * @default {}
+   */
+  tags?: Record<string, string[]>;
+
+  /**
+   * Rules that restrict the dependencies of workspaces with a given tag.
+   *
+   * @default []
+   */
+  rules?: BoundaryRule[];
+}
+
+export interface BoundaryRule {
+  /**
+   * The tag of the workspaces this rule applies to.
+   */
+  from: string;
+
+  /**
+   * When set, dependencies must have at least one of these tags.
+   *
+   * @default []
+   */
+  allow?: string[];
+
+  /**
+   * Dependencies may not have any of these tags.
+   *
+   * @default []
+   */
+  deny?: string[];
+}
+
+export interface Notification {
+  /**
+   * The incoming webhook URL. Environment variables are expanded, so that
+   * the URL can be kept out of turbo.json, e.g. "$SLACK_WEBHOOK_URL". The
+   * notification is skipped if the URL expands to an empty string.
+   */
+  url: string;
+
+  /**
+   * The kind of webhook the message is formatted for.
+   */
+  format: "slack" | "teams";
+
+  /**
+   * Only send the notification when a task failed.
+   *
+   * @default false
+   */
+  onlyFailures?: boolean;
+}
+
+export interface Analytics {
+  /**
+   * The URL that runs are posted to. Environment variables are expanded, e.g.
+   * "$TURBO_ANALYTICS_URL", and nothing is uploaded if it expands to an empty
+   * string.
+   */
+  url?: string;
+
+  /**
+   * Headers sent with each upload, such as credentials. Environment variables
+   * in their values are expanded, e.g. "Bearer $ANALYTICS_TOKEN".
+   *
+   * @default {}
+   */
+  headers?: Record<string, string>;
+}
+
+export interface Webhook {
+  /**
+   * The URL that events are posted to. Environment variables are expanded,
+   * e.g. "$TURBO_WEBHOOK_URL", and the webhook is skipped if it expands to an
+   * empty string.
+   */
+  url: string;
+
+  /**
+   * The secret that the body of each delivery is signed with, in the
+   * X-Turbo-Signature-256 header. Environment variables are expanded, e.g.
+   * "$TURBO_WEBHOOK_SECRET".
+   */
+  secret?: string;
+
+  /**
+   * The events that are sent to the webhook.
+   *
+   * @default ["taskStarted", "taskFinished", "taskFailed", "runFinished"]
+   */
+  events?: Array<"taskStarted" | "taskFinished" | "taskFailed" | "runFinished">;
+
+  /**
+   * How many times a delivery is sent again when the webhook can't be
+   * reached, or responds with a server error or 429.
+   *
+   * @default 3
+   */
+  retries?: number;
+}
+
+export interface Hashing {
+  /**
+   * The version of the hashing algorithm that task hashes are computed with.
+   * Version 2 streams the hashes of input files into the hash instead of
+   * formatting them first, which is faster for packages with many files.
+   * Version 3 also leaves the external dependencies of the root package.json
+   * out of the global hash, so that bumping them only changes the hashes of
+   * root tasks and of the workspaces that depend on them.
+   * Version 4 also leaves the files of the other workspaces out of the hashes
+   * of root tasks that don't set `inputs`, so that tasks can depend on a root
+   * task such as `//#codegen` without missing the cache on every change.
+   *
+   * @default 1
+   */
+  version?: 1 | 2 | 3 | 4;
+
+  /**
+   * A previous version whose cache entries are restored when there is none
+   * for the task hash, and cached again under it, while migrating to a new
+   * version.
+   */
+  fallbackVersion?: 1 | 2 | 3 | 4;
+
+  /**
+   * How many packages, and files of packages that git can't hash, are hashed
+   * at the same time. Files are streamed through a fixed buffer, so lowering
+   * it bounds the memory and open files that hashing needs.
+   *
+   * @default the number of CPUs
+   */
+  workers?: number;
+}
+
+export interface Logs {
+  /**
+   * A template for the prefix of the lines that tasks log, with the
+   * placeholders {package}, {task}, {hash}, {elapsed} and {label}, or "none"
+   * to remove the prefix.
+   *
+   * @default "{package}:{task}: "
+   */
+  prefix?: string;
+
+  /**
+   * Whether prefixes are colored by workspace, or by the status of the task:
+   * grey for lines replayed from the cache, cyan while the task runs and red
+   * once it failed.
+   *
+   * @default "package"
+   */
+  prefixColor?: "package" | "status";
+}
+
+export interface Summaries {
+  /**
+   * How many of the most recent run summaries to keep.
+   */
+  maxCount?: number;
+
+  /**
+   * How long to keep run summaries, as a duration such as "72h" or "14d".
+   */
+  maxAge?: string;
+}
+
+export interface RunProfile {
+  /**
+   * The value of `--concurrency`, as a number of tasks or a percentage of
+   * CPUs, such as "4" or "50%".
+   */
+  concurrency?: string;
+
+  /**
+   * Whether to pass `--continue`.
+   *
+   * @default false
+   */
+  continue?: boolean;
+
+  /**
+   * The value of `--output-logs`.
+   */
+  outputLogs?: OutputMode;
+
+  /**
+   * Whether to pass `--force`.
+   *
+   * @default false
+   */
+  force?: boolean;
+
+  /**
+   * Whether to pass `--no-cache`.
+   *
+   * @default false
+   */
+  noCache?: boolean;
+
+  /**
+   * Whether to pass `--remote-only`.
+   *
+   * @default false
+   */
+  remoteOnly?: boolean;
 }
 
 export type OutputMode =